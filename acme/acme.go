@@ -0,0 +1,291 @@
+// Package acme proves that a certificate's key is resident in a TPM, for the
+// ACME device-attest-01 challenge (draft-acme-device-attest), as used by MDM
+// enrollments.
+//
+// The client answers the challenge with an attestation object, like that of
+// WebAuthn, with a "tpm" attestation statement (WebAuthn Level 2, Section
+// 8.3): its AK certifies the key (with TPM2_Certify), over the SHA-256 digest
+// of the challenge's key authorization, and the statement holds the AK's
+// certificate chain:
+//
+//	obj, err := acme.Attest(ak, akChain, key, keyAuthorization)
+//	...
+//	payload, err := obj.ChallengeResponse()
+//	// POST payload to the challenge URL
+//
+// The ACME server checks the attestation object with Verify, and later that
+// the CSR's public key is the certified key.
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+const (
+	// ChallengeType is the type of the ACME challenges answered with
+	// attestation objects.
+	ChallengeType = "device-attest-01"
+	// Format is the format of the attestation objects of TPMs.
+	Format = "tpm"
+	// Version is the version of the "tpm" attestation statements.
+	Version = "2.0"
+)
+
+// tcg-kp-AIKCertificate, the extended key usage of AK certificates.
+var oidAKCertificateUsage = asn1.ObjectIdentifier{2, 23, 133, 8, 3}
+
+// AttestationObject is the attestation object of a device-attest-01 challenge
+// response. Unlike that of WebAuthn, it has no authenticator data.
+type AttestationObject struct {
+	Format    string    `cbor:"fmt"`
+	Statement Statement `cbor:"attStmt"`
+}
+
+// Statement is a "tpm" attestation statement.
+type Statement struct {
+	Version string `cbor:"ver"`
+	// The COSE algorithm of the signature, such as -257 (RS256) or -7 (ES256).
+	Alg int64 `cbor:"alg"`
+	// The DER encoded AK certificate, followed by its issuing certificates.
+	X5C [][]byte `cbor:"x5c"`
+	// The AK's signature of CertInfo: an RSASSA-PKCS1-v1_5 signature, or an
+	// ASN.1 encoded ECDSA signature, as checked by x509.Certificate.
+	Sig []byte `cbor:"sig"`
+	// The TPMS_ATTEST structure of TPM2_Certify.
+	CertInfo []byte `cbor:"certInfo"`
+	// The TPMT_PUBLIC public area of the certified key.
+	PubArea []byte `cbor:"pubArea"`
+}
+
+// COSE algorithm identifiers of the AK signatures, from the IANA COSE
+// Algorithms registry.
+const (
+	algRS256 = -257
+	algRS384 = -258
+	algRS512 = -259
+	algES256 = -7
+	algES384 = -35
+	algES512 = -36
+)
+
+var coseAlgs = map[tpm2.Algorithm]map[tpm2.Algorithm]int64{
+	tpm2.AlgRSASSA: {tpm2.AlgSHA256: algRS256, tpm2.AlgSHA384: algRS384, tpm2.AlgSHA512: algRS512},
+	tpm2.AlgECDSA:  {tpm2.AlgSHA256: algES256, tpm2.AlgSHA384: algES384, tpm2.AlgSHA512: algES512},
+}
+
+// KeyAuthorizationDigest returns the extraData of the certification of a
+// challenge's key: the SHA-256 digest of its key authorization (RFC 8555,
+// Section 8.1).
+func KeyAuthorizationDigest(keyAuthorization string) []byte {
+	digest := sha256.Sum256([]byte(keyAuthorization))
+	return digest[:]
+}
+
+// Attest certifies a TPM key with an AK (see client.Key.Certify), over the
+// digest of an ACME key authorization, and returns the attestation object
+// answering the challenge. The akChain holds the DER encoded AK certificate,
+// followed by any certificates needed to verify it, such as one issued by
+// server.AKCertIssuer.
+func Attest(ak *client.Key, akChain [][]byte, key *client.Key, keyAuthorization string) (*AttestationObject, error) {
+	if len(akChain) == 0 {
+		return nil, errors.New("missing AK certificate")
+	}
+	akCert, err := x509.ParseCertificate(akChain[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AK certificate: %w", err)
+	}
+	if !publicKeysEqual(akCert.PublicKey, ak.PublicKey()) {
+		return nil, errors.New("AK certificate is not for the AK")
+	}
+	certification, err := ak.Certify(key, KeyAuthorizationDigest(keyAuthorization))
+	if err != nil {
+		return nil, err
+	}
+	sig, err := tpm2.DecodeSignature(bytes.NewBuffer(certification.GetRawSig()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode certification signature: %w", err)
+	}
+	stmt := Statement{
+		Version:  Version,
+		X5C:      akChain,
+		CertInfo: certification.GetCertifyInfo(),
+		PubArea:  certification.GetPublicArea(),
+	}
+	var hashAlg tpm2.Algorithm
+	switch {
+	case sig.RSA != nil:
+		hashAlg = sig.RSA.HashAlg
+		stmt.Sig = sig.RSA.Signature
+	case sig.ECC != nil:
+		hashAlg = sig.ECC.HashAlg
+		if stmt.Sig, err = asn1.Marshal(struct{ R, S *big.Int }{sig.ECC.R, sig.ECC.S}); err != nil {
+			return nil, err
+		}
+	}
+	var ok bool
+	if stmt.Alg, ok = coseAlgs[sig.Alg][hashAlg]; !ok {
+		return nil, fmt.Errorf("unsupported AK signature algorithm %v with %v", sig.Alg, hashAlg)
+	}
+	return &AttestationObject{Format: Format, Statement: stmt}, nil
+}
+
+// Marshal returns the CBOR encoding of the attestation object.
+func (o *AttestationObject) Marshal() ([]byte, error) {
+	encMode, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		return nil, err
+	}
+	return encMode.Marshal(o)
+}
+
+// ChallengeResponse returns the JSON payload posted to the challenge URL, in
+// which the attestation object is base64url encoded.
+func (o *AttestationObject) ChallengeResponse() ([]byte, error) {
+	attObj, err := o.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		AttObj string `json:"attObj"`
+	}{base64.RawURLEncoding.EncodeToString(attObj)})
+}
+
+// VerifyOpts configures Verify.
+type VerifyOpts struct {
+	// The roots the AK certificate must chain to.
+	Roots *x509.CertPool
+	// The time at which the AK certificate chain must be valid. If zero, the
+	// current time is used.
+	CurrentTime time.Time
+}
+
+// Result is a verified attestation object.
+type Result struct {
+	// The AK certificate, which identifies the device.
+	AKCertificate *x509.Certificate
+	// The public area and public key of the certified key, which the CSR
+	// finalizing the order must use.
+	PublicArea tpm2.Public
+	PublicKey  crypto.PublicKey
+}
+
+// Verify checks the CBOR attestation object of a device-attest-01 challenge
+// (the base64url decoded attObj of the challenge response): that its AK certificate chains to a trusted root and has the AK certificate
+// extended key usage, and that the AK certified a key over the digest of the
+// challenge's key authorization, which cannot leave the TPM. It returns the
+// certified key.
+func Verify(attObj []byte, keyAuthorization string, opts VerifyOpts) (*Result, error) {
+	var obj AttestationObject
+	if err := cbor.Unmarshal(attObj, &obj); err != nil {
+		return nil, fmt.Errorf("failed to decode attestation object: %w", err)
+	}
+	if obj.Format != Format {
+		return nil, fmt.Errorf("attestation object has format %q, want %q", obj.Format, Format)
+	}
+	stmt := obj.Statement
+	if stmt.Version != Version {
+		return nil, fmt.Errorf("attestation statement has version %q, want %q", stmt.Version, Version)
+	}
+
+	if len(stmt.X5C) == 0 {
+		return nil, errors.New("missing AK certificate")
+	}
+	certs := make([]*x509.Certificate, len(stmt.X5C))
+	for i, der := range stmt.X5C {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %w", i, err)
+		}
+		certs[i] = cert
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	akCert := certs[0]
+	if _, err := akCert.Verify(x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   opts.CurrentTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to verify AK certificate: %w", err)
+	}
+	if !hasAKUsage(akCert) {
+		return nil, errors.New("AK certificate lacks the tcg-kp-AIKCertificate extended key usage")
+	}
+
+	rawSig, err := encodeSignature(stmt.Alg, stmt.Sig)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := internal.VerifyKeyCertification(&tpmpb.KeyCertification{
+		CertifyInfo: stmt.CertInfo,
+		RawSig:      rawSig,
+		PublicArea:  stmt.PubArea,
+	}, akCert.PublicKey, KeyAuthorizationDigest(keyAuthorization))
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify key certification: %w", err)
+	}
+	if pub.Attributes&tpm2.FlagFixedTPM == 0 {
+		return nil, errors.New("certified key can leave the TPM")
+	}
+	key, err := pub.Key()
+	if err != nil {
+		return nil, err
+	}
+	return &Result{AKCertificate: akCert, PublicArea: pub, PublicKey: key}, nil
+}
+
+func hasAKUsage(cert *x509.Certificate) bool {
+	for _, usage := range cert.UnknownExtKeyUsage {
+		if usage.Equal(oidAKCertificateUsage) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeSignature encodes the signature of an attestation statement as a
+// TPMT_SIGNATURE.
+func encodeSignature(alg int64, sig []byte) ([]byte, error) {
+	for sigAlg, hashAlgs := range coseAlgs {
+		for hashAlg, coseAlg := range hashAlgs {
+			if coseAlg != alg {
+				continue
+			}
+			if sigAlg == tpm2.AlgRSASSA {
+				return tpmutil.Pack(sigAlg, hashAlg, tpmutil.U16Bytes(sig))
+			}
+			var ecdsaSig struct{ R, S *big.Int }
+			if rest, err := asn1.Unmarshal(sig, &ecdsaSig); err != nil || len(rest) != 0 {
+				return nil, errors.New("invalid ECDSA signature")
+			}
+			return tpmutil.Pack(sigAlg, hashAlg, tpmutil.U16Bytes(ecdsaSig.R.Bytes()), tpmutil.U16Bytes(ecdsaSig.S.Bytes()))
+		}
+	}
+	return nil, fmt.Errorf("unsupported signature algorithm %d", alg)
+}
+
+func publicKeysEqual(k1, k2 crypto.PublicKey) bool {
+	key, ok := k1.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(k2)
+}
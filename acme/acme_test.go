@@ -0,0 +1,242 @@
+package acme_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/google/go-tpm-tools/acme"
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+const keyAuthorization = "token.thumbprint"
+
+// issueAKCert returns a CA, and an AK certificate it issued.
+func issueAKCert(t *testing.T, ak *client.Key, usages ...asn1.ObjectIdentifier) (*x509.CertPool, []byte) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "AK CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	akTemplate := &x509.Certificate{
+		SerialNumber:       big.NewInt(2),
+		Subject:            pkix.Name{CommonName: "AK"},
+		NotBefore:          now.Add(-time.Hour),
+		NotAfter:           now.Add(time.Hour),
+		KeyUsage:           x509.KeyUsageDigitalSignature,
+		UnknownExtKeyUsage: usages,
+	}
+	akDER, err := x509.CreateCertificate(rand.Reader, akTemplate, ca, ak.PublicKey(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return roots, akDER
+}
+
+var akUsage = asn1.ObjectIdentifier{2, 23, 133, 8, 3}
+
+func TestAttestVerify(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	for _, tc := range []struct {
+		name  string
+		newAK func(io.ReadWriter) (*client.Key, error)
+		alg   int64
+	}{
+		{"RSA", client.AttestationKeyRSA, -257},
+		{"ECC", client.AttestationKeyECC, -7},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ak, err := tc.newAK(rwc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ak.Close()
+			key, err := client.LDevIDKeyECC(rwc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer key.Close()
+			roots, akCert := issueAKCert(t, ak, akUsage)
+
+			obj, err := acme.Attest(ak, [][]byte{akCert}, key, keyAuthorization)
+			if err != nil {
+				t.Fatalf("Attest() failed: %v", err)
+			}
+			if obj.Statement.Alg != tc.alg {
+				t.Errorf("got alg %d, want %d", obj.Statement.Alg, tc.alg)
+			}
+			payload, err := obj.ChallengeResponse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var response struct {
+				AttObj string `json:"attObj"`
+			}
+			if err := json.Unmarshal(payload, &response); err != nil {
+				t.Fatal(err)
+			}
+			attObj, err := base64.RawURLEncoding.DecodeString(response.AttObj)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := acme.Verify(attObj, keyAuthorization, acme.VerifyOpts{Roots: roots})
+			if err != nil {
+				t.Fatalf("Verify() failed: %v", err)
+			}
+			if !key.PublicKey().(*ecdsa.PublicKey).Equal(result.PublicKey) {
+				t.Error("Verify() returned another key than the certified key")
+			}
+
+			if _, err := acme.Verify(attObj, "other.thumbprint", acme.VerifyOpts{Roots: roots}); err == nil {
+				t.Error("Verify() with another key authorization should fail")
+			}
+			if _, err := acme.Verify(attObj, keyAuthorization, acme.VerifyOpts{Roots: x509.NewCertPool()}); err == nil {
+				t.Error("Verify() with untrusted roots should fail")
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	key, err := client.LDevIDKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+
+	roots, akCert := issueAKCert(t, ak, akUsage)
+	obj, err := acme.Attest(ak, [][]byte{akCert}, key, keyAuthorization)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name   string
+		modify func(*acme.AttestationObject)
+	}{
+		{"WrongFormat", func(o *acme.AttestationObject) { o.Format = "apple" }},
+		{"WrongVersion", func(o *acme.AttestationObject) { o.Statement.Version = "1.2" }},
+		{"NoCertificate", func(o *acme.AttestationObject) { o.Statement.X5C = nil }},
+		{"WrongAlg", func(o *acme.AttestationObject) { o.Statement.Alg = -35 }},
+		{"BadSignature", func(o *acme.AttestationObject) {
+			o.Statement.Sig = append([]byte(nil), o.Statement.Sig[:len(o.Statement.Sig)-1]...)
+		}},
+		{"OtherPubArea", func(o *acme.AttestationObject) {
+			pub, err := ak.PublicArea().Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			o.Statement.PubArea = pub
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			modified := *obj
+			tc.modify(&modified)
+			attObj, err := modified.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := acme.Verify(attObj, keyAuthorization, acme.VerifyOpts{Roots: roots}); err == nil {
+				t.Error("Verify() should fail")
+			}
+		})
+	}
+
+	// The AK certificate must be for an AK.
+	roots, akCert = issueAKCert(t, ak)
+	if obj, err = acme.Attest(ak, [][]byte{akCert}, key, keyAuthorization); err != nil {
+		t.Fatal(err)
+	}
+	attObj, err := obj.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acme.Verify(attObj, keyAuthorization, acme.VerifyOpts{Roots: roots}); err == nil {
+		t.Error("Verify() with a certificate without the AK usage should fail")
+	}
+
+	// Keys which can be duplicated cannot be attested.
+	template := client.DevIDTemplateECC()
+	template.Attributes &^= tpm2.FlagFixedTPM | tpm2.FlagFixedParent
+	dupKey, err := client.NewKey(rwc, tpm2.HandleOwner, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dupKey.Close()
+	roots, akCert = issueAKCert(t, ak, akUsage)
+	if obj, err = acme.Attest(ak, [][]byte{akCert}, dupKey, keyAuthorization); err != nil {
+		t.Fatal(err)
+	}
+	if attObj, err = obj.Marshal(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acme.Verify(attObj, keyAuthorization, acme.VerifyOpts{Roots: roots}); err == nil {
+		t.Error("Verify() of a key which can leave the TPM should fail")
+	}
+}
+
+func TestAttestWrongAKCertificate(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	other, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	key, err := client.LDevIDKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+
+	_, otherCert := issueAKCert(t, other, akUsage)
+	if _, err := acme.Attest(ak, [][]byte{otherCert}, key, keyAuthorization); err == nil {
+		t.Error("Attest() with another AK's certificate should fail")
+	}
+}
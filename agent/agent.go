@@ -0,0 +1,456 @@
+// Package agent runs a long-lived attestation agent, which attests its
+// machine to remote Verifier services (such as server.VerifierService) at a
+// regular interval.
+//
+// The agent's trust configuration (the verifiers it attests to, the CA
+// certificates and pins their TLS certificates must match, and what it
+// collects) is read from an AgentConfig file signed by a key the agent trusts
+// (see SignConfig), so it can be rolled out to a fleet through an untrusted
+// channel. The file is reloaded on SIGHUP, or when it changes, without
+// restarting the agent: its AK stays loaded, and attestations in progress
+// finish with the configuration they started with. Each config must have a
+// greater serial than the one in use, so an older signed config cannot be
+// replayed to the agent.
+//
+//	a := &agent.Agent{
+//		Attester:   ak,
+//		ConfigPath: "/etc/gotpm/agent.conf",
+//		ConfigKeys: []crypto.PublicKey{fleetConfigKey},
+//	}
+//	err := a.Run(ctx)
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/google/go-tpm-tools/client"
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+)
+
+// Defaults of the AgentConfig's CollectionSettings, and of Agent.PollInterval.
+const (
+	DefaultInterval     = 5 * time.Minute
+	DefaultTimeout      = time.Minute
+	DefaultPollInterval = 10 * time.Second
+)
+
+// ErrConfigSignature is wrapped by the errors of ParseConfig (and so of
+// Agent.Reload) when a config file is not signed by a trusted key.
+var ErrConfigSignature = errors.New("agent config is not signed by a trusted key")
+
+// ErrConfigRollback is wrapped by the errors of Agent.Reload when a config
+// file is not newer than the config in use (or Agent.MinConfigSerial).
+var ErrConfigRollback = errors.New("agent config is older than the one in use")
+
+// SignConfig encodes a config as a SignedAgentConfig file, signed by signer,
+// which must be an ECDSA or RSA key.
+func SignConfig(config *verifierpb.AgentConfig, signer crypto.Signer) ([]byte, error) {
+	data, err := proto.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign agent config: %w", err)
+	}
+	return proto.Marshal(&verifierpb.SignedAgentConfig{Config: data, Signature: signature})
+}
+
+// ParseConfig decodes a SignedAgentConfig file, which must be signed by one of
+// the keys, and checks the config.
+func ParseConfig(data []byte, keys []crypto.PublicKey) (*verifierpb.AgentConfig, error) {
+	var signed verifierpb.SignedAgentConfig
+	if err := proto.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("failed to decode signed agent config: %w", err)
+	}
+	digest := sha256.Sum256(signed.GetConfig())
+	trusted := false
+	for _, key := range keys {
+		if verifySignature(key, digest[:], signed.GetSignature()) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return nil, ErrConfigSignature
+	}
+	config := &verifierpb.AgentConfig{}
+	if err := proto.Unmarshal(signed.GetConfig(), config); err != nil {
+		return nil, fmt.Errorf("failed to decode agent config: %w", err)
+	}
+	for i, verifier := range config.GetVerifiers() {
+		if verifier.GetAddress() == "" {
+			return nil, fmt.Errorf("verifier %d has no address", i)
+		}
+		if _, err := transportCredentials(verifier); err != nil {
+			return nil, fmt.Errorf("verifier %s: %w", verifier.GetAddress(), err)
+		}
+	}
+	return config, nil
+}
+
+func verifySignature(key crypto.PublicKey, digest, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest, signature)
+	default:
+		return false
+	}
+}
+
+// transportCredentials returns the TLS credentials for a verifier, checking
+// its certificate against the endpoint's CA certificates and pins.
+func transportCredentials(verifier *verifierpb.VerifierEndpoint) (credentials.TransportCredentials, error) {
+	tlsConfig := &tls.Config{ServerName: verifier.GetServerName(), MinVersion: tls.VersionTLS12}
+	if len(verifier.GetCaCerts()) != 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		for _, der := range verifier.GetCaCerts() {
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CA certificate: %w", err)
+			}
+			tlsConfig.RootCAs.AddCert(cert)
+		}
+	}
+	for _, pin := range verifier.GetCaPins() {
+		if len(pin) != sha256.Size {
+			return nil, fmt.Errorf("CA pin is %d bytes, want a %d byte SHA-256 digest", len(pin), sha256.Size)
+		}
+	}
+	if pins := verifier.GetCaPins(); len(pins) != 0 {
+		tlsConfig.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+			for _, chain := range chains {
+				for _, cert := range chain {
+					digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+					for _, pin := range pins {
+						if bytes.Equal(digest[:], pin) {
+							return nil
+						}
+					}
+				}
+			}
+			return errors.New("verifier certificate does not chain to a pinned CA")
+		}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// Result is the result of an attestation to a verifier.
+type Result struct {
+	// The verifier's address
+	Address  string
+	Response *verifierpb.VerifyAttestationResponse
+	Err      error
+}
+
+// Agent attests its machine to the verifiers in its config file. Its methods
+// are safe for concurrent use.
+type Agent struct {
+	// The machine's AK, which stays loaded while the config is reloaded
+	Attester client.Attester
+	// The SignedAgentConfig file
+	ConfigPath string
+	// The keys trusted to sign the config file
+	ConfigKeys []crypto.PublicKey
+	// The lowest serial of a config the agent loads. Configs loaded later
+	// must have a greater serial than the one in use. Setting this to the
+	// serial last loaded (see Config) prevents older configs from being
+	// replayed when the agent restarts.
+	MinConfigSerial uint64
+	// How often Run checks whether the config file changed. Defaults to
+	// DefaultPollInterval.
+	PollInterval time.Duration
+	// Options used when dialing verifiers, in addition to their TLS
+	// credentials
+	DialOptions []grpc.DialOption
+	// If non-nil, Run calls this with the results of each round of
+	// attestations.
+	Results func([]Result)
+	// If non-nil, Run calls this when the config file changes, but cannot be
+	// reloaded. The previous config stays in use.
+	ReloadErrors func(error)
+	// If non-nil, Reload calls this with each config it loads, for example to
+	// persist its serial as the MinConfigSerial of the agent's next run.
+	ConfigLoaded func(*verifierpb.AgentConfig)
+
+	mu      sync.Mutex
+	current *generation
+	// The digest of the last config file rejected by Reload
+	rejected [sha256.Size]byte
+}
+
+// generation is a loaded config, and the connections to its verifiers. It is
+// retired when a new config is loaded, and its connections are closed once
+// the attestations using them finish.
+type generation struct {
+	config   *verifierpb.AgentConfig
+	conns    []*grpc.ClientConn
+	digest   [sha256.Size]byte
+	inFlight sync.WaitGroup
+}
+
+func (g *generation) close() {
+	g.inFlight.Wait()
+	for _, conn := range g.conns {
+		conn.Close()
+	}
+}
+
+// Config returns the config in use, or nil if none has been loaded.
+func (a *Agent) Config() *verifierpb.AgentConfig {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.current == nil {
+		return nil
+	}
+	return a.current.config
+}
+
+// Reload reads the config file, and uses it from the next attestation if it
+// changed. If the file cannot be loaded, or its serial is not greater than
+// that of the config in use, an error is returned and the previous config
+// stays in use.
+func (a *Agent) Reload() error {
+	data, err := ioutil.ReadFile(a.ConfigPath)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(data)
+	a.mu.Lock()
+	unchanged := a.current != nil && a.current.digest == digest
+	a.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	config, err := a.load(data, digest)
+	if err != nil {
+		a.mu.Lock()
+		a.rejected = digest
+		a.mu.Unlock()
+		return err
+	}
+	if a.ConfigLoaded != nil {
+		a.ConfigLoaded(config)
+	}
+	return nil
+}
+
+// load parses a config file, dials its verifiers and puts it in use.
+func (a *Agent) load(data []byte, digest [sha256.Size]byte) (*verifierpb.AgentConfig, error) {
+	config, err := ParseConfig(data, a.ConfigKeys)
+	if err == nil {
+		a.mu.Lock()
+		err = a.checkSerial(config)
+		a.mu.Unlock()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", a.ConfigPath, err)
+	}
+	next := &generation{config: config, digest: digest}
+	for _, verifier := range config.GetVerifiers() {
+		creds, err := transportCredentials(verifier)
+		if err == nil {
+			var conn *grpc.ClientConn
+			// Dialing does not block, so an unreachable verifier does not
+			// prevent the config from loading.
+			conn, err = grpc.Dial(verifier.GetAddress(), append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, a.DialOptions...)...)
+			if err == nil {
+				next.conns = append(next.conns, conn)
+				continue
+			}
+		}
+		next.close()
+		return nil, fmt.Errorf("failed to dial verifier %s: %w", verifier.GetAddress(), err)
+	}
+
+	a.mu.Lock()
+	// Another config may have been loaded while dialing.
+	if err := a.checkSerial(config); err != nil {
+		a.mu.Unlock()
+		next.close()
+		return nil, fmt.Errorf("failed to load %s: %w", a.ConfigPath, err)
+	}
+	previous := a.current
+	a.current = next
+	a.mu.Unlock()
+	if previous != nil {
+		go previous.close()
+	}
+	return config, nil
+}
+
+// checkSerial checks that a config is newer than the one in use. a.mu must be
+// held.
+func (a *Agent) checkSerial(config *verifierpb.AgentConfig) error {
+	if config.GetSerial() < a.MinConfigSerial {
+		return fmt.Errorf("%w: serial %d is less than the minimum %d", ErrConfigRollback, config.GetSerial(), a.MinConfigSerial)
+	}
+	if a.current != nil && config.GetSerial() <= a.current.config.GetSerial() {
+		return fmt.Errorf("%w: serial %d is not greater than %d", ErrConfigRollback, config.GetSerial(), a.current.config.GetSerial())
+	}
+	return nil
+}
+
+// acquire returns the config in use, which must be released once the caller
+// stops using its connections.
+func (a *Agent) acquire() *generation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.current != nil {
+		a.current.inFlight.Add(1)
+	}
+	return a.current
+}
+
+// AttestOnce attests to each verifier in the config, and returns the results.
+func (a *Agent) AttestOnce(ctx context.Context) ([]Result, error) {
+	g := a.acquire()
+	if g == nil {
+		return nil, errors.New("no agent config is loaded")
+	}
+	defer g.inFlight.Done()
+
+	collection := g.config.GetCollection()
+	opts := client.AttestOpts{ClearIndicators: collection.GetClearIndicators()}
+	if path := collection.GetCanonicalEventLogPath(); path != "" {
+		var err error
+		if opts.CanonicalEventLog, err = ioutil.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read canonical event log: %w", err)
+		}
+	}
+	timeout := DefaultTimeout
+	if seconds := collection.GetTimeoutSeconds(); seconds != 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	results := make([]Result, len(g.conns))
+	for i, conn := range g.conns {
+		attestCtx, cancel := context.WithTimeout(ctx, timeout)
+		results[i].Address = g.config.GetVerifiers()[i].GetAddress()
+		results[i].Response, results[i].Err = client.AttestToVerifierWithOpts(attestCtx, a.Attester, verifierpb.NewVerifierClient(conn), opts)
+		cancel()
+	}
+	return results, nil
+}
+
+// Close closes the connections to the verifiers, once the attestations using
+// them finish.
+func (a *Agent) Close() error {
+	a.mu.Lock()
+	current := a.current
+	a.current = nil
+	a.mu.Unlock()
+	if current != nil {
+		current.close()
+	}
+	return nil
+}
+
+// interval returns the time between rounds of attestations.
+func (a *Agent) interval() time.Duration {
+	if seconds := a.Config().GetCollection().GetIntervalSeconds(); seconds != 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return DefaultInterval
+}
+
+// Run loads the config file, then attests to its verifiers every interval
+// until ctx is done, when it returns ctx.Err() and closes the agent. The config
+// is reloaded when the process receives SIGHUP, or when Run sees the file
+// change. A round of attestations in progress keeps using the config it
+// started with, and a new interval applies from the next round.
+func (a *Agent) Run(ctx context.Context) error {
+	if err := a.Reload(); err != nil {
+		return err
+	}
+	defer a.Close()
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+	pollInterval := a.PollInterval
+	if pollInterval == 0 {
+		pollInterval = DefaultPollInterval
+	}
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+
+	next := time.NewTimer(0)
+	defer next.Stop()
+	done := make(chan struct{})
+	running := false
+	defer func() {
+		if running {
+			<-done
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-hangups:
+			a.reload(true)
+		case <-poll.C:
+			a.reload(false)
+		case <-next.C:
+			running = true
+			go func() {
+				results, err := a.AttestOnce(ctx)
+				if err != nil {
+					results = []Result{{Err: err}}
+				}
+				if a.Results != nil {
+					a.Results(results)
+				}
+				done <- struct{}{}
+			}()
+		case <-done:
+			running = false
+			next.Reset(a.interval())
+		}
+	}
+}
+
+// reload reloads the config file for Run, reporting errors once per file
+// contents unless the reload was requested.
+func (a *Agent) reload(requested bool) {
+	// Reload records the file it rejects, so compare with the one rejected
+	// before.
+	a.mu.Lock()
+	rejected := a.rejected
+	a.mu.Unlock()
+	err := a.Reload()
+	if err == nil || a.ReloadErrors == nil {
+		return
+	}
+	if !requested {
+		data, readErr := ioutil.ReadFile(a.ConfigPath)
+		if readErr == nil && sha256.Sum256(data) == rejected {
+			return
+		}
+	}
+	a.ReloadErrors(err)
+}
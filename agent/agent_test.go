@@ -0,0 +1,435 @@
+package agent
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+	"github.com/google/go-tpm-tools/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testVerifier is a VerifierService served over TLS on an in-memory
+// connection, whatever address the agent dials.
+type testVerifier struct {
+	ca          *x509.Certificate
+	dialOptions []grpc.DialOption
+}
+
+func startVerifier(t *testing.T, trustedAK crypto.PublicKey) *testVerifier {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Verifier CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "verifier.test"},
+		DNSNames:     []string{"verifier.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := server.NewVerifierService(server.VerifierServiceOpts{
+		Signer:     signer,
+		VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{trustedAK}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{leafDER}, PrivateKey: leafKey}}})
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(append(service.ServerOptions(), grpc.Creds(creds))...)
+	verifierpb.RegisterVerifierServer(grpcServer, service)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	return &testVerifier{
+		ca: ca,
+		dialOptions: []grpc.DialOption{
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		},
+	}
+}
+
+// endpoint returns a VerifierEndpoint for the verifier, pinned to pin.
+func (v *testVerifier) endpoint(address string, pin []byte) *verifierpb.VerifierEndpoint {
+	return &verifierpb.VerifierEndpoint{
+		Address:    address,
+		ServerName: "verifier.test",
+		CaCerts:    [][]byte{v.ca.Raw},
+		CaPins:     [][]byte{pin},
+	}
+}
+
+func (v *testVerifier) pin() []byte {
+	digest := sha256.Sum256(v.ca.RawSubjectPublicKeyInfo)
+	return digest[:]
+}
+
+func writeConfig(t *testing.T, path string, config *verifierpb.AgentConfig, signer crypto.Signer) {
+	t.Helper()
+	data, err := SignConfig(config, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestAgent(t *testing.T, attester client.Attester, v *testVerifier) (*Agent, *ecdsa.PrivateKey) {
+	t.Helper()
+	configKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Agent{
+		Attester:    attester,
+		ConfigPath:  filepath.Join(t.TempDir(), "agent.conf"),
+		ConfigKeys:  []crypto.PublicKey{configKey.Public()},
+		DialOptions: v.dialOptions,
+	}
+	t.Cleanup(func() { a.Close() })
+	return a, configKey
+}
+
+func TestAgentReload(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	v := startVerifier(t, ak.PublicKey())
+	a, configKey := newTestAgent(t, ak, v)
+
+	if _, err := a.AttestOnce(context.Background()); err == nil {
+		t.Error("AttestOnce() without a config succeeded, want error")
+	}
+	writeConfig(t, a.ConfigPath, &verifierpb.AgentConfig{
+		Verifiers: []*verifierpb.VerifierEndpoint{v.endpoint("verifier.test:443", v.pin())},
+		Serial:    1,
+	}, configKey)
+	if err := a.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+	results, err := a.AttestOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err != nil || results[0].Response.GetMachineState() == nil {
+		t.Errorf("AttestOnce() with a pinned verifier = %+v, want a MachineState", results)
+	}
+
+	// A verifier whose CA is not pinned is not trusted.
+	wrongPin := make([]byte, sha256.Size)
+	writeConfig(t, a.ConfigPath, &verifierpb.AgentConfig{
+		Verifiers: []*verifierpb.VerifierEndpoint{v.endpoint("verifier.test:443", wrongPin)},
+		Serial:    2,
+	}, configKey)
+	if err := a.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+	if results, err = a.AttestOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err == nil {
+		t.Errorf("AttestOnce() to a verifier with an unpinned CA = %+v, want error", results)
+	}
+
+	// A config signed by an untrusted key is rejected, and the previous
+	// config stays in use.
+	untrusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, a.ConfigPath, &verifierpb.AgentConfig{Serial: 3}, untrusted)
+	if err := a.Reload(); !errors.Is(err, ErrConfigSignature) {
+		t.Errorf("Reload() of an untrusted config = %v, want ErrConfigSignature", err)
+	}
+	if got := a.Config().GetVerifiers(); len(got) != 1 || len(got[0].GetCaPins()) != 1 || got[0].GetCaPins()[0][0] != 0 {
+		t.Errorf("Config() after rejecting a config = %v, want the previous config", got)
+	}
+
+	invalid := []*verifierpb.VerifierEndpoint{
+		{ServerName: "verifier.test"},
+		{Address: "verifier.test:443", CaCerts: [][]byte{{1, 2, 3}}},
+		{Address: "verifier.test:443", CaPins: [][]byte{{1, 2, 3}}},
+	}
+	for _, endpoint := range invalid {
+		data, err := SignConfig(&verifierpb.AgentConfig{Verifiers: []*verifierpb.VerifierEndpoint{endpoint}}, configKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseConfig(data, a.ConfigKeys); err == nil {
+			t.Errorf("ParseConfig() of verifier %v succeeded, want error", endpoint)
+		}
+	}
+}
+
+func TestAgentReloadRejectsOlderConfig(t *testing.T) {
+	configKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded []uint64
+	a := &Agent{
+		ConfigPath:      filepath.Join(t.TempDir(), "agent.conf"),
+		ConfigKeys:      []crypto.PublicKey{configKey.Public()},
+		MinConfigSerial: 2,
+		ConfigLoaded: func(config *verifierpb.AgentConfig) {
+			loaded = append(loaded, config.GetSerial())
+		},
+	}
+	defer a.Close()
+	interval := func(seconds uint32, serial uint64) *verifierpb.AgentConfig {
+		return &verifierpb.AgentConfig{
+			Collection: &verifierpb.CollectionSettings{IntervalSeconds: seconds},
+			Serial:     serial,
+		}
+	}
+
+	writeConfig(t, a.ConfigPath, interval(60, 1), configKey)
+	if err := a.Reload(); !errors.Is(err, ErrConfigRollback) {
+		t.Errorf("Reload() of a config older than MinConfigSerial = %v, want ErrConfigRollback", err)
+	}
+	writeConfig(t, a.ConfigPath, interval(60, 2), configKey)
+	if err := a.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+	writeConfig(t, a.ConfigPath, interval(120, 3), configKey)
+	if err := a.Reload(); err != nil {
+		t.Fatalf("Reload() of a newer config failed: %v", err)
+	}
+
+	// Replaying an older config, or another config with the same serial, is
+	// rejected, and the newest config stays in use.
+	for _, config := range []*verifierpb.AgentConfig{interval(60, 2), interval(30, 3)} {
+		writeConfig(t, a.ConfigPath, config, configKey)
+		if err := a.Reload(); !errors.Is(err, ErrConfigRollback) {
+			t.Errorf("Reload() of config with serial %d = %v, want ErrConfigRollback", config.GetSerial(), err)
+		}
+		if got := a.Config().GetCollection().GetIntervalSeconds(); got != 120 {
+			t.Errorf("Config() after rejecting a config has interval %d, want 120", got)
+		}
+	}
+	if !reflect.DeepEqual(loaded, []uint64{2, 3}) {
+		t.Errorf("ConfigLoaded was called with serials %v, want [2 3]", loaded)
+	}
+}
+
+func TestAgentReloadDialFailure(t *testing.T) {
+	configKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reloadErrors []error
+	a := &Agent{
+		ConfigPath: filepath.Join(t.TempDir(), "agent.conf"),
+		ConfigKeys: []crypto.PublicKey{configKey.Public()},
+		// An invalid service config makes dialing every verifier fail.
+		DialOptions:  []grpc.DialOption{grpc.WithDefaultServiceConfig("not a service config")},
+		ReloadErrors: func(err error) { reloadErrors = append(reloadErrors, err) },
+		ConfigLoaded: func(config *verifierpb.AgentConfig) {
+			t.Errorf("ConfigLoaded was called with %v, want no config loaded", config)
+		},
+	}
+	defer a.Close()
+	writeConfig(t, a.ConfigPath, &verifierpb.AgentConfig{
+		Verifiers: []*verifierpb.VerifierEndpoint{{Address: "verifier.test:443"}},
+		Serial:    1,
+	}, configKey)
+
+	// The failure is reported once per file contents, like other reload
+	// failures.
+	a.reload(false)
+	a.reload(false)
+	if len(reloadErrors) != 1 {
+		t.Errorf("got reload errors %v, want one", reloadErrors)
+	}
+	if a.Config() != nil {
+		t.Errorf("Config() = %v, want no config loaded", a.Config())
+	}
+}
+
+// blockingAttester waits for release before attesting.
+type blockingAttester struct {
+	client.Attester
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingAttester) Attest(opts client.AttestOpts) (*pb.Attestation, error) {
+	close(b.started)
+	<-b.release
+	return b.Attester.Attest(opts)
+}
+
+func TestAgentReloadDuringAttestation(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	v := startVerifier(t, ak.PublicKey())
+	attester := &blockingAttester{Attester: ak, started: make(chan struct{}), release: make(chan struct{})}
+	a, configKey := newTestAgent(t, attester, v)
+	writeConfig(t, a.ConfigPath, &verifierpb.AgentConfig{
+		Verifiers: []*verifierpb.VerifierEndpoint{v.endpoint("old.verifier.test:443", v.pin())},
+		Serial:    1,
+	}, configKey)
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan []Result)
+	go func() {
+		results, err := a.AttestOnce(context.Background())
+		if err != nil {
+			results = []Result{{Err: err}}
+		}
+		done <- results
+	}()
+	<-attester.started
+	writeConfig(t, a.ConfigPath, &verifierpb.AgentConfig{
+		Verifiers: []*verifierpb.VerifierEndpoint{v.endpoint("new.verifier.test:443", v.pin())},
+		Serial:    2,
+	}, configKey)
+	if err := a.Reload(); err != nil {
+		t.Fatalf("Reload() during an attestation failed: %v", err)
+	}
+	if got := a.Config().GetVerifiers()[0].GetAddress(); got != "new.verifier.test:443" {
+		t.Errorf("Config() after Reload() has verifier %s, want new.verifier.test:443", got)
+	}
+	close(attester.release)
+
+	results := <-done
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("attestation in progress during Reload() = %+v, want success", results)
+	}
+	if results[0].Address != "old.verifier.test:443" {
+		t.Errorf("attestation in progress used verifier %s, want the old config's", results[0].Address)
+	}
+}
+
+func TestAgentRunReloadsOnSIGHUP(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGHUP cannot be sent on Windows")
+	}
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	v := startVerifier(t, ak.PublicKey())
+	a, configKey := newTestAgent(t, ak, v)
+	// Only SIGHUP reloads the config within the test.
+	a.PollInterval = time.Hour
+	rounds := make(chan []Result, 10)
+	a.Results = func(results []Result) { rounds <- results }
+	writeConfig(t, a.ConfigPath, &verifierpb.AgentConfig{
+		Verifiers:  []*verifierpb.VerifierEndpoint{v.endpoint("verifier.test:443", v.pin())},
+		Collection: &verifierpb.CollectionSettings{IntervalSeconds: 3600},
+		Serial:     1,
+	}, configKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- a.Run(ctx) }()
+	select {
+	case results := <-rounds:
+		if len(results) != 1 || results[0].Err != nil {
+			t.Errorf("first round of attestations = %+v, want success", results)
+		}
+	case err := <-stopped:
+		t.Fatalf("Run() failed: %v", err)
+	}
+
+	writeConfig(t, a.ConfigPath, &verifierpb.AgentConfig{
+		Verifiers:  []*verifierpb.VerifierEndpoint{v.endpoint("other.verifier.test:443", v.pin())},
+		Collection: &verifierpb.CollectionSettings{IntervalSeconds: 3600},
+		Serial:     2,
+	}, configKey)
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for a.Config().GetVerifiers()[0].GetAddress() != "other.verifier.test:443" {
+		if time.Now().After(deadline) {
+			t.Fatal("Run() did not reload the config on SIGHUP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() = %v, want context.Canceled", err)
+	}
+	if a.Config() != nil {
+		t.Error("Run() did not close the agent")
+	}
+}
@@ -0,0 +1,182 @@
+// Package atrest encrypts local credentials at rest, such as SSH private keys,
+// known_hosts files and kubeconfig tokens, so that they can only be decrypted
+// on the machine whose TPM encrypted them.
+//
+// Each piece of data is encrypted with a fresh AES-256-GCM data key, and the
+// data key is sealed by the TPM's ECC Storage Root Key, which acts as the key
+// encryption key. The data key can additionally be sealed to PCRs (or an NV
+// counter) with client.SealOpts, so the data can only be decrypted while the
+// machine is in the same state.
+//
+// Files written by WriteFile are encoded EncryptedData protos. ReadFile, and
+// the SSH helpers built on it, decrypt them transparently:
+//
+//	signer, err := atrest.SSHSigner(rw, filepath.Join(home, ".ssh", "id_ed25519.tpm"))
+//	callback, err := atrest.KnownHosts(rw, filepath.Join(home, ".ssh", "known_hosts.tpm"))
+//	config := &ssh.ClientConfig{
+//		User:            user,
+//		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+//		HostKeyCallback: callback,
+//	}
+package atrest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// KeySize is the size (in bytes) of the AES-256 data keys.
+const KeySize = 32
+
+// additionalData is authenticated along with every ciphertext, so that data
+// encrypted by this package cannot be confused with other AES-GCM ciphertexts.
+const additionalData = "GOTPM ENCRYPTED DATA\x00"
+
+// Encrypt encrypts plaintext with a new data key, which is sealed by the TPM's
+// ECC Storage Root Key according to opts.
+func Encrypt(rw io.ReadWriter, plaintext []byte, opts client.SealOpts) (*pb.EncryptedData, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	srk, err := client.StorageRootKeyECC(rw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SRK: %w", err)
+	}
+	defer srk.Close()
+	sealed, err := srk.Seal(key, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal data key: %w", err)
+	}
+	return &pb.EncryptedData{
+		SealedKey:  sealed,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(additionalData)),
+	}, nil
+}
+
+// Decrypt unseals the data key of data from Encrypt, and decrypts it. This
+// fails on any other TPM, or if the PCRs (or NV counter) the data key was
+// sealed to have changed.
+func Decrypt(rw io.ReadWriter, data *pb.EncryptedData) ([]byte, error) {
+	srk, err := client.StorageRootKeyECC(rw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SRK: %w", err)
+	}
+	defer srk.Close()
+	key, err := srk.Unseal(data.GetSealedKey(), client.UnsealOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal data key: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.GetNonce()) != aead.NonceSize() {
+		return nil, fmt.Errorf("nonce has size %d, want %d", len(data.GetNonce()), aead.NonceSize())
+	}
+	plaintext, err := aead.Open(nil, data.GetNonce(), data.GetCiphertext(), []byte(additionalData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("data key has size %d, want %d", len(key), KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// WriteFile encrypts data with Encrypt, and writes it to the named file with
+// the given permissions. The file is replaced atomically, so it is never left
+// partially written.
+func WriteFile(rw io.ReadWriter, name string, data []byte, perm os.FileMode, opts client.SealOpts) error {
+	encrypted, err := Encrypt(rw, data, opts)
+	if err != nil {
+		return err
+	}
+	out, err := proto.Marshal(encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to encode encrypted data: %w", err)
+	}
+	return replaceFile(name, out, perm)
+}
+
+// ReadFile reads the named file written by WriteFile (or EncryptFile), and
+// returns its decrypted contents.
+func ReadFile(rw io.ReadWriter, name string) ([]byte, error) {
+	in, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var encrypted pb.EncryptedData
+	if err := proto.Unmarshal(in, &encrypted); err != nil {
+		return nil, fmt.Errorf("%s is not an encrypted file: %w", name, err)
+	}
+	data, err := Decrypt(rw, &encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// EncryptFile encrypts an existing plaintext file in place, keeping its
+// permissions. Use ReadFile to read it afterwards.
+func EncryptFile(rw io.ReadWriter, name string, opts client.SealOpts) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return WriteFile(rw, name, data, info.Mode().Perm(), opts)
+}
+
+// replaceFile writes data to a temporary file in the same directory as name,
+// and then renames it to name.
+func replaceFile(name string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,230 @@
+package atrest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+var testSel = tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{test.DebugPCR}}
+
+func TestEncryptDecrypt(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	plaintext := []byte("kubeconfig token")
+	encrypted, err := Encrypt(rwc, plaintext, client.SealOpts{Current: testSel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted.GetCiphertext(), plaintext) {
+		t.Error("ciphertext contains the plaintext")
+	}
+	decrypted, err := Decrypt(rwc, encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt() failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypt() = %q, want %q", decrypted, plaintext)
+	}
+
+	encrypted.Ciphertext[0] ^= 1
+	if _, err := Decrypt(rwc, encrypted); err == nil {
+		t.Error("Decrypt() should fail for modified ciphertext")
+	}
+	encrypted.Ciphertext[0] ^= 1
+
+	extension := bytes.Repeat([]byte{0xAA}, 32)
+	if err := tpm2.PCRExtend(rwc, tpmutil.Handle(test.DebugPCR), tpm2.AlgSHA256, extension, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(rwc, encrypted); err == nil {
+		t.Error("Decrypt() should fail after the PCRs changed")
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	name := filepath.Join(t.TempDir(), "token")
+	plaintext := []byte("secret token")
+	if err := os.WriteFile(name, plaintext, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptFile(rwc, name, client.SealOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(contents, plaintext) {
+		t.Error("encrypted file contains the plaintext")
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("encrypted file has permissions %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+	decrypted, err := ReadFile(rwc, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("ReadFile() = %q, want %q", decrypted, plaintext)
+	}
+
+	if err := os.WriteFile(name, plaintext, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFile(rwc, name); err == nil {
+		t.Error("ReadFile() should fail for a plaintext file")
+	}
+}
+
+func TestSSH(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	dir := t.TempDir()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := WriteFile(rwc, keyFile, pemBytes, 0600, client.SealOpts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := SSHSigner(rwc, keyFile)
+	if err != nil {
+		t.Fatalf("SSHSigner() failed: %v", err)
+	}
+	sig, err := signer.Sign(rand.Reader, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.PublicKey().Verify([]byte("data"), sig); err != nil {
+		t.Error(err)
+	}
+
+	keyring := agent.NewKeyring()
+	if err := AddSSHKey(rwc, keyFile, keyring, "test key", 0); err != nil {
+		t.Fatalf("AddSSHKey() failed: %v", err)
+	}
+	keys, err := keyring.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !bytes.Equal(keys[0].Marshal(), signer.PublicKey().Marshal()) || keys[0].Comment != "test key" {
+		t.Errorf("agent has keys %v, want the added key", keys)
+	}
+
+	knownHosts := filepath.Join(dir, "known_hosts")
+	if err := AddKnownHost(rwc, knownHosts, []string{"example.com:22"}, signer.PublicKey(), client.SealOpts{}); err != nil {
+		t.Fatalf("AddKnownHost() failed: %v", err)
+	}
+	callback, err := KnownHosts(rwc, knownHosts)
+	if err != nil {
+		t.Fatalf("KnownHosts() failed: %v", err)
+	}
+	addr := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+	if err := callback("example.com:22", addr, signer.PublicKey()); err != nil {
+		t.Errorf("known host was rejected: %v", err)
+	}
+	if err := callback("other.example.com:22", addr, signer.PublicKey()); err == nil {
+		t.Error("unknown host was accepted")
+	}
+}
+
+func TestWrapBlob(t *testing.T) {
+	rwc := test.GetTPM(t)
+	blob := []byte("sealed_blob: 1\n")
+	wrapped, err := WrapBlob(rwc, blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsWrapped(wrapped) || IsWrapped(blob) {
+		t.Error("IsWrapped() should only be true for the wrapped blob")
+	}
+	if bytes.Contains(wrapped, blob) {
+		t.Error("wrapped blob contains the plaintext")
+	}
+	for _, data := range [][]byte{wrapped, blob} {
+		unwrapped, err := UnwrapBlob(rwc, data)
+		if err != nil {
+			t.Fatalf("UnwrapBlob() failed: %v", err)
+		}
+		if !bytes.Equal(unwrapped, blob) {
+			t.Errorf("UnwrapBlob() = %q, want %q", unwrapped, blob)
+		}
+	}
+
+	client.CheckedClose(t, rwc)
+
+	// A new simulator has a different SRK, like another machine.
+	other := test.GetPlatformTPM(t, test.Debian10GCE)
+	defer client.CheckedClose(t, other)
+	if _, err := UnwrapBlob(other, wrapped); err == nil {
+		t.Error("UnwrapBlob() should fail on another TPM")
+	}
+}
+
+func TestWrapBlobFile(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	name := filepath.Join(t.TempDir(), "sealed")
+	blob := []byte("sealed_blob: 1\n")
+	if err := os.WriteFile(name, blob, 0640); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false} {
+		wrapped, err := WrapBlobFile(rwc, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wrapped != want {
+			t.Errorf("WrapBlobFile() call %d = %v, want %v", i, wrapped, want)
+		}
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("wrapped file has permissions %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+	contents, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := UnwrapBlob(rwc, contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(unwrapped, blob) {
+		t.Errorf("UnwrapBlob() = %q, want %q", unwrapped, blob)
+	}
+}
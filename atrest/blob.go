@@ -0,0 +1,79 @@
+package atrest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"google.golang.org/protobuf/encoding/prototext"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// WrapBlob encrypts a serialized blob, such as the sealed data written by
+// "gotpm seal", with Encrypt, and returns the EncryptedData in the protobuf
+// text format. The data key is sealed by the Storage Root Key without any
+// policy, so the blob can be unwrapped on this machine in any state, but is
+// useless on any other machine, before any policy in the blob is checked.
+//
+// Wrapped blobs are read with UnwrapBlob, which also accepts plaintext blobs.
+func WrapBlob(rw io.ReadWriter, blob []byte) ([]byte, error) {
+	encrypted, err := Encrypt(rw, blob, client.SealOpts{})
+	if err != nil {
+		return nil, err
+	}
+	return prototext.MarshalOptions{Multiline: true}.Marshal(encrypted)
+}
+
+// IsWrapped reports whether data was returned by WrapBlob.
+func IsWrapped(data []byte) bool {
+	_, ok := parseWrapped(data)
+	return ok
+}
+
+func parseWrapped(data []byte) (*pb.EncryptedData, bool) {
+	var encrypted pb.EncryptedData
+	if err := prototext.Unmarshal(data, &encrypted); err != nil || encrypted.GetSealedKey() == nil {
+		return nil, false
+	}
+	return &encrypted, true
+}
+
+// UnwrapBlob returns the blob in data. If data was returned by WrapBlob, it is
+// decrypted. Otherwise, data is a plaintext blob (such as one written before
+// wrapping was enabled), which is returned unchanged, so callers can read both
+// kinds of blob while existing blobs are migrated with WrapBlobFile.
+func UnwrapBlob(rw io.ReadWriter, data []byte) ([]byte, error) {
+	encrypted, ok := parseWrapped(data)
+	if !ok {
+		return data, nil
+	}
+	return Decrypt(rw, encrypted)
+}
+
+// WrapBlobFile migrates a plaintext blob file to a wrapped one in place (see
+// WrapBlob), keeping its permissions. It returns false, without changing the
+// file, if the file is already wrapped.
+func WrapBlobFile(rw io.ReadWriter, name string) (bool, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return false, err
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return false, err
+	}
+	if IsWrapped(data) {
+		return false, nil
+	}
+	wrapped, err := WrapBlob(rw, data)
+	if err != nil {
+		return false, fmt.Errorf("wrapping %s: %w", name, err)
+	}
+	if err := replaceFile(name, wrapped, info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
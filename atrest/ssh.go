@@ -0,0 +1,93 @@
+package atrest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/google/go-tpm-tools/client"
+)
+
+// SSHSigner reads an encrypted SSH private key (in any format accepted by
+// ssh.ParsePrivateKey) from the named file, and returns a signer for it. The
+// key must not also be protected by a passphrase.
+func SSHSigner(rw io.ReadWriter, name string) (ssh.Signer, error) {
+	pemBytes, err := ReadFile(rw, name)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH private key in %s: %w", name, err)
+	}
+	return signer, nil
+}
+
+// AddSSHKey reads an encrypted SSH private key from the named file, and adds
+// it to an SSH agent, so that the decrypted key is only held in the agent's
+// memory. The key is added with the given comment and lifetime (in seconds,
+// or 0 to keep it until the agent exits).
+func AddSSHKey(rw io.ReadWriter, name string, a agent.Agent, comment string, lifetimeSecs uint32) error {
+	pemBytes, err := ReadFile(rw, name)
+	if err != nil {
+		return err
+	}
+	key, err := ssh.ParseRawPrivateKey(pemBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse SSH private key in %s: %w", name, err)
+	}
+	if err := a.Add(agent.AddedKey{PrivateKey: key, Comment: comment, LifetimeSecs: lifetimeSecs}); err != nil {
+		return fmt.Errorf("failed to add SSH key to agent: %w", err)
+	}
+	return nil
+}
+
+// KnownHosts returns a host key callback (like knownhosts.New) for the named
+// encrypted known_hosts files. As knownhosts only reads files, each file is
+// briefly decrypted into a temporary file readable only by the current user.
+func KnownHosts(rw io.ReadWriter, names ...string) (ssh.HostKeyCallback, error) {
+	var plainNames []string
+	defer func() {
+		for _, name := range plainNames {
+			os.Remove(name)
+		}
+	}()
+	for _, name := range names {
+		data, err := ReadFile(rw, name)
+		if err != nil {
+			return nil, err
+		}
+		plain, err := ioutil.TempFile("", "known_hosts")
+		if err != nil {
+			return nil, err
+		}
+		plainNames = append(plainNames, plain.Name())
+		_, err = plain.Write(data)
+		if closeErr := plain.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return knownhosts.New(plainNames...)
+}
+
+// AddKnownHost appends a known_hosts line for the given addresses and host key
+// to the named encrypted known_hosts file, creating it if it does not exist.
+// The file is then encrypted again according to opts.
+func AddKnownHost(rw io.ReadWriter, name string, addresses []string, key ssh.PublicKey, opts client.SealOpts) error {
+	data, err := ReadFile(rw, name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	data = append(data, knownhosts.Line(addresses, key)...)
+	data = append(data, '\n')
+	return WriteFile(rw, name, data, 0600, opts)
+}
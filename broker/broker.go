@@ -0,0 +1,219 @@
+// Package broker lets unprivileged workloads, such as containers running under
+// a strict seccomp profile or without the TPM device passed through, attest
+// with the host's TPM.
+//
+// A privileged host agent (such as "gotpm broker") serves a Service, which
+// attests and quotes with the host TPM's AK on behalf of its clients over a
+// Unix socket. These are the only TPM operations clients can perform, so they
+// cannot extend PCRs, use other keys, or change the TPM's state. Access to the
+// broker is controlled by the socket's file permissions.
+//
+// Workloads call Open, which uses the broker if one is configured, and the
+// TPM directly otherwise, so the same code runs inside and outside containers:
+//
+//	tpm, err := broker.Open("")
+//	...
+//	defer tpm.Close()
+//	attestation, err := tpm.Attest(client.AttestOpts{Nonce: nonce})
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	brokerpb "github.com/google/go-tpm-tools/proto/broker"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// DefaultSocket is where the broker listens by default, and where Open looks
+// for it. Containers use the broker by mounting this socket.
+const DefaultSocket = "/run/gotpm/broker.sock"
+
+// SocketEnv is the environment variable which overrides DefaultSocket in Open.
+const SocketEnv = "GOTPM_BROKER"
+
+// TPM is the interface to a TPM offered by the broker: attesting and quoting
+// with its AK. It is implemented both by a Client of a broker, and by the TPM
+// opened directly (see Open).
+type TPM interface {
+	client.Attester
+	// Quote quotes the PCRs in sel with the AK, like client.Key.Quote.
+	Quote(sel tpm2.PCRSelection, extraData []byte) (*tpmpb.Quote, error)
+	Close() error
+}
+
+// AttestationKey loads the AK used by the broker, and by the TPM returned by
+// Open when no broker is configured, so verifiers trust the same AK either way.
+func AttestationKey(rw io.ReadWriter) (*client.Key, error) {
+	return client.AttestationKeyECC(rw)
+}
+
+// Service implements the Broker gRPC service with an AK. To serve it:
+//
+//	grpcServer := grpc.NewServer()
+//	brokerpb.RegisterBrokerServer(grpcServer, broker.NewService(ak))
+//	grpcServer.Serve(listener)
+type Service struct {
+	brokerpb.UnimplementedBrokerServer
+	// Keys cannot be used concurrently.
+	mu sync.Mutex
+	ak *client.Key
+}
+
+// NewService creates a Service which attests and quotes with the AK. The AK
+// must stay loaded while the Service is used.
+func NewService(ak *client.Key) *Service {
+	return &Service{ak: ak}
+}
+
+// Attest returns an Attestation generated with the AK for the request's nonce.
+func (s *Service) Attest(ctx context.Context, req *brokerpb.AttestRequest) (*brokerpb.AttestResponse, error) {
+	if len(req.GetNonce()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing nonce")
+	}
+	opts := client.AttestOpts{Nonce: req.GetNonce()}
+	if alg := req.GetNonceHash(); alg != tpmpb.HashAlgo_HASH_INVALID {
+		hash, err := tpm2.Algorithm(alg).Hash()
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported nonce hash algorithm %v", alg)
+		}
+		opts.NonceHash = hash
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attestation, err := s.ak.Attest(opts)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to attest: %v", err)
+	}
+	return &brokerpb.AttestResponse{Attestation: attestation}, nil
+}
+
+// Quote returns a quote of the requested PCRs with the AK.
+func (s *Service) Quote(ctx context.Context, req *brokerpb.QuoteRequest) (*brokerpb.QuoteResponse, error) {
+	if len(req.GetPcrs()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no PCRs to quote")
+	}
+	sel := tpm2.PCRSelection{Hash: tpm2.Algorithm(req.GetHash())}
+	for _, pcr := range req.GetPcrs() {
+		sel.PCRs = append(sel.PCRs, int(pcr))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	quote, err := s.ak.Quote(sel, req.GetExtraData())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to quote: %v", err)
+	}
+	return &brokerpb.QuoteResponse{Quote: quote}, nil
+}
+
+// Client is a TPM which attests and quotes with a broker.
+type Client struct {
+	conn   *grpc.ClientConn
+	broker brokerpb.BrokerClient
+}
+
+var _ TPM = (*Client)(nil)
+
+// Dial connects to the broker listening on the Unix socket at path.
+func Dial(path string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{grpc.WithInsecure()}, opts...)
+	conn, err := grpc.Dial("unix://"+path, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial broker: %w", err)
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a Client using an existing connection to a broker. Closing
+// the Client closes the connection.
+func NewClient(conn *grpc.ClientConn) *Client {
+	return &Client{conn, brokerpb.NewBrokerClient(conn)}
+}
+
+// Attest generates an Attestation with the broker's AK. The
+// CanonicalEventLog option is not supported, as clients cannot extend PCRs.
+func (c *Client) Attest(opts client.AttestOpts) (*pb.Attestation, error) {
+	if len(opts.CanonicalEventLog) != 0 {
+		return nil, errors.New("the broker does not support canonical event logs")
+	}
+	req := &brokerpb.AttestRequest{Nonce: opts.Nonce}
+	if opts.NonceHash != 0 {
+		alg, err := tpm2.HashToAlgorithm(opts.NonceHash)
+		if err != nil {
+			return nil, err
+		}
+		req.NonceHash = tpmpb.HashAlgo(alg)
+	}
+	resp, err := c.broker.Attest(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("broker failed to attest: %w", err)
+	}
+	return resp.GetAttestation(), nil
+}
+
+// Quote quotes the PCRs in sel with the broker's AK.
+func (c *Client) Quote(sel tpm2.PCRSelection, extraData []byte) (*tpmpb.Quote, error) {
+	req := &brokerpb.QuoteRequest{Hash: tpmpb.HashAlgo(sel.Hash), ExtraData: extraData}
+	for _, pcr := range sel.PCRs {
+		req.Pcrs = append(req.Pcrs, uint32(pcr))
+	}
+	resp, err := c.broker.Quote(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("broker failed to quote: %w", err)
+	}
+	return resp.GetQuote(), nil
+}
+
+// Close closes the connection to the broker.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// localTPM is a TPM opened directly.
+type localTPM struct {
+	*client.Key
+	rwc io.ReadWriteCloser
+}
+
+func (l localTPM) Close() error {
+	l.Key.Close()
+	return l.rwc.Close()
+}
+
+// Open returns the TPM to attest with. If the SocketEnv environment variable
+// is set, or a broker is listening on DefaultSocket, the broker is used.
+// Otherwise, the TPM at tpmPath (see client.OpenTPM) is opened directly, and
+// attests with the same AK as the broker.
+func Open(tpmPath string) (TPM, error) {
+	socket := os.Getenv(SocketEnv)
+	if socket == "" {
+		if _, err := os.Stat(DefaultSocket); err == nil {
+			socket = DefaultSocket
+		}
+	}
+	if socket != "" {
+		return Dial(socket)
+	}
+
+	rwc, err := client.OpenTPM(tpmPath)
+	if err != nil {
+		return nil, err
+	}
+	ak, err := AttestationKey(rwc)
+	if err != nil {
+		rwc.Close()
+		return nil, fmt.Errorf("failed to load AK: %w", err)
+	}
+	return localTPM{ak, rwc}, nil
+}
@@ -0,0 +1,109 @@
+package broker
+
+import (
+	"crypto"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/grpc"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/internal/test"
+	brokerpb "github.com/google/go-tpm-tools/proto/broker"
+	"github.com/google/go-tpm-tools/server"
+)
+
+// startBroker serves a Service with the TPM's AK on a Unix socket, and makes
+// Open use it.
+func startBroker(t *testing.T, ak *client.Key) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "broker.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	brokerpb.RegisterBrokerServer(grpcServer, NewService(ak))
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	if err := os.Setenv(SocketEnv, socket); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Unsetenv(SocketEnv) })
+}
+
+func TestBroker(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := AttestationKey(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	startBroker(t, ak)
+
+	tpm, err := Open("/dev/null")
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer tpm.Close()
+	if _, ok := tpm.(*Client); !ok {
+		t.Fatalf("Open() = %T, want the broker client", tpm)
+	}
+
+	for _, hash := range []crypto.Hash{0, crypto.SHA256} {
+		nonce := []byte("container nonce")
+		attestation, err := tpm.Attest(client.AttestOpts{Nonce: nonce, NonceHash: hash})
+		if err != nil {
+			t.Fatalf("Attest() failed: %v", err)
+		}
+		opts := server.VerifyOpts{Nonce: nonce, NonceHash: hash, TrustedAKs: []crypto.PublicKey{ak.PublicKey()}}
+		if _, err := server.VerifyAttestation(attestation, opts); err != nil {
+			t.Errorf("failed to verify the broker's attestation: %v", err)
+		}
+	}
+
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{0, 7}}
+	quote, err := tpm.Quote(sel, []byte("quote nonce"))
+	if err != nil {
+		t.Fatalf("Quote() failed: %v", err)
+	}
+	if err := internal.VerifyQuote(quote, ak.PublicKey(), []byte("quote nonce")); err != nil {
+		t.Errorf("failed to verify the broker's quote: %v", err)
+	}
+	if len(quote.GetPcrs().GetPcrs()) != 2 {
+		t.Errorf("got quote of PCRs %v, want 0 and 7", quote.GetPcrs().GetPcrs())
+	}
+}
+
+func TestBrokerInvalidRequests(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := AttestationKey(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	startBroker(t, ak)
+
+	tpm, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpm.Close()
+
+	if _, err := tpm.Attest(client.AttestOpts{}); err == nil {
+		t.Error("attesting without a nonce should fail")
+	}
+	if _, err := tpm.Attest(client.AttestOpts{Nonce: []byte("nonce"), CanonicalEventLog: []byte("cel")}); err == nil {
+		t.Error("attesting with a canonical event log should fail")
+	}
+	if _, err := tpm.Quote(tpm2.PCRSelection{Hash: tpm2.AlgSHA256}, nil); err == nil {
+		t.Error("quoting no PCRs should fail")
+	}
+}
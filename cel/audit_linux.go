@@ -0,0 +1,62 @@
+package cel
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// The Linux audit netlink multicast group for reading audit records, from
+// linux/audit.h.
+const auditNetlinkReadLog = 1
+
+// AuditConn receives records from the Linux audit subsystem, alongside (and
+// without interfering with) the audit daemon. Listening requires the
+// CAP_AUDIT_READ capability.
+type AuditConn struct {
+	file *os.File
+	buf  []byte
+	// Records received but not yet returned by Receive.
+	pending []AuditRecord
+}
+
+// ListenAudit subscribes to the kernel's audit records.
+func ListenAudit() (*AuditConn, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, syscall.NETLINK_AUDIT)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit netlink socket: %w", err)
+	}
+	addr := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: auditNetlinkReadLog}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("subscribing to audit records (CAP_AUDIT_READ is required): %w", err)
+	}
+	// A non-blocking file uses the runtime's poller, so Close interrupts
+	// Receive.
+	return &AuditConn{file: os.NewFile(uintptr(fd), "audit"), buf: make([]byte, os.Getpagesize()*4)}, nil
+}
+
+// Receive returns the next audit record.
+func (c *AuditConn) Receive() (AuditRecord, error) {
+	for len(c.pending) == 0 {
+		n, err := c.file.Read(c.buf)
+		if err != nil {
+			return AuditRecord{}, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(c.buf[:n])
+		if err != nil {
+			return AuditRecord{}, fmt.Errorf("parsing audit netlink message: %w", err)
+		}
+		for _, msg := range msgs {
+			c.pending = append(c.pending, AuditRecord{msg.Header.Type, string(msg.Data)})
+		}
+	}
+	record := c.pending[0]
+	c.pending = c.pending[1:]
+	return record, nil
+}
+
+// Close stops receiving audit records.
+func (c *AuditConn) Close() error {
+	return c.file.Close()
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+// +build !linux
+
+package cel
+
+import "errors"
+
+// AuditConn receives records from the Linux audit subsystem. It is only
+// supported on Linux.
+type AuditConn struct{}
+
+// ListenAudit subscribes to the kernel's audit records. It is only supported
+// on Linux.
+func ListenAudit() (*AuditConn, error) {
+	return nil, errors.New("the audit subsystem is only supported on Linux")
+}
+
+// Receive returns the next audit record.
+func (c *AuditConn) Receive() (AuditRecord, error) {
+	return AuditRecord{}, errors.New("the audit subsystem is only supported on Linux")
+}
+
+// Close stops receiving audit records.
+func (c *AuditConn) Close() error {
+	return nil
+}
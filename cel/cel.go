@@ -0,0 +1,551 @@
+// Package cel contains some basic operations of Canonical Eventlog.
+// Based on Canonical EventLog Spec (Draft) Version: TCG_IWG_CEL_v1_r0p37.
+package cel
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+const (
+	// CEL spec 5.1
+	recnumTypeValue  uint8 = 0
+	pcrTypeValue     uint8 = 1
+	nvIndexTypeValue uint8 = 2
+	digestsTypeValue uint8 = 3
+
+	tlvTypeFieldLength   int = 1
+	tlvLengthFieldLength int = 4
+
+	recnumValueLength  uint32 = 8 // support up to 2^64 records
+	pcrValueLength     uint32 = 1 // support up to 256 PCRs
+	nvIndexValueLength uint32 = 4
+)
+
+// TLV definition according to CEL spec TCG_IWG_CEL_v1_r0p37, page 16.
+// Length is implicitly defined by len(Value), using uint32 big-endian
+// when encoding.
+type TLV struct {
+	Type  uint8
+	Value []byte
+}
+
+// MarshalBinary marshals a TLV to a byte slice.
+func (t TLV) MarshalBinary() (data []byte, err error) {
+	buf := make([]byte, len(t.Value)+tlvTypeFieldLength+tlvLengthFieldLength)
+
+	buf[0] = t.Type
+	binary.BigEndian.PutUint32(buf[tlvTypeFieldLength:], uint32(len(t.Value)))
+	copy(buf[tlvTypeFieldLength+tlvLengthFieldLength:], t.Value)
+
+	return buf, nil
+}
+
+// UnmarshalBinary unmarshal a byte slice to a TLV.
+func (t *TLV) UnmarshalBinary(data []byte) error {
+	if len(data) < tlvTypeFieldLength+tlvLengthFieldLength {
+		return fmt.Errorf("TLV is %d bytes, shorter than its %d byte header",
+			len(data), tlvTypeFieldLength+tlvLengthFieldLength)
+	}
+	valueLength := binary.BigEndian.Uint32(data[tlvTypeFieldLength : tlvTypeFieldLength+tlvLengthFieldLength])
+
+	if valueLength != uint32(len(data[tlvTypeFieldLength+tlvLengthFieldLength:])) {
+		return fmt.Errorf("TLV Length doesn't match the size of its Value")
+	}
+	t.Type = data[0]
+	t.Value = data[tlvTypeFieldLength+tlvLengthFieldLength:]
+
+	return nil
+}
+
+// GetTLV returns the TLV itself, allowing a plain TLV to be used as Content.
+func (t TLV) GetTLV() (TLV, error) {
+	return t, nil
+}
+
+// GenerateDigest hashes the binary encoding of the TLV. This is the digest
+// extended into a PCR when a plain TLV is used as Content.
+func (t TLV) GenerateDigest(hashAlgo crypto.Hash) ([]byte, error) {
+	contentTLV, err := t.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	hash := hashAlgo.New()
+	if _, err = hash.Write(contentTLV); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// UnmarshalFirstTLV reads and parse the first TLV from the bytes buffer. The function can
+// return io.EOF if the buffer is empty, or io.ErrUnexpectedEOF if the buffer
+// ends in the middle of a TLV.
+func UnmarshalFirstTLV(buf *bytes.Buffer) (tlv TLV, err error) {
+	typeByte, err := buf.ReadByte()
+	if err != nil {
+		return tlv, err
+	}
+	var data []byte
+	data = append(data, typeByte)
+
+	// get the length
+	lengthBytes := make([]byte, tlvLengthFieldLength)
+	bytesRead, err := buf.Read(lengthBytes)
+	if err != nil {
+		return TLV{}, unexpectedEOF(err)
+	}
+	if bytesRead != tlvLengthFieldLength {
+		return TLV{}, io.ErrUnexpectedEOF
+	}
+	valueLength := binary.BigEndian.Uint32(lengthBytes)
+	data = append(data, lengthBytes...)
+	// The length is untrusted, so check it against the remaining data before
+	// allocating the value.
+	if uint64(valueLength) > uint64(buf.Len()) {
+		return TLV{}, io.ErrUnexpectedEOF
+	}
+
+	valueBytes := make([]byte, valueLength)
+	bytesRead, err = buf.Read(valueBytes)
+	if err != nil && valueLength != 0 {
+		return TLV{}, unexpectedEOF(err)
+	}
+	if uint32(bytesRead) != valueLength {
+		return TLV{}, io.ErrUnexpectedEOF
+	}
+	data = append(data, valueBytes...)
+
+	if err = (&tlv).UnmarshalBinary(data); err != nil {
+		return TLV{}, err
+	}
+	return tlv, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Content is the data of a single CEL record. The digest of the Content is
+// what gets extended into the record's PCR.
+type Content interface {
+	GetTLV() (TLV, error)
+	GenerateDigest(crypto.Hash) ([]byte, error)
+}
+
+// Record represents a Canonical Eventlog Record.
+type Record struct {
+	RecNum uint64
+	PCR    uint8
+	// If nonzero, the record was extended into this NV extend index (see
+	// AppendNVEvent) instead of PCR.
+	NVIndex uint32
+	Digests map[crypto.Hash][]byte
+	Content TLV
+}
+
+// CEL represents a Canonical Eventlog, which contains a list of Records.
+type CEL struct {
+	Records []Record
+}
+
+// AppendEvent appends a new record to the CEL, and extends the digest of the
+// event content into the given PCR of the TPM, once for every hash algorithm
+// in hashAlgos.
+func (c *CEL) AppendEvent(tpm io.ReadWriter, pcr int, hashAlgos []crypto.Hash, event Content) error {
+	if len(hashAlgos) == 0 {
+		return fmt.Errorf("need to specify at least one hash algorithm")
+	}
+	digestsMap, err := generateDigests(hashAlgos, event)
+	if err != nil {
+		return err
+	}
+	for hash, digest := range digestsMap {
+		tpmHash, err := tpm2.HashToAlgorithm(hash)
+		if err != nil {
+			return err
+		}
+		if err := tpm2.PCRExtend(tpm, tpmutil.Handle(pcr), tpmHash, digest, ""); err != nil {
+			return fmt.Errorf("failed to extend event to PCR%d: %v", pcr, err)
+		}
+	}
+	return c.appendRecord(pcr, digestsMap, event)
+}
+
+// AppendNVEvent appends a new record to the CEL, and extends the digest of the
+// event content into an NV extend index of the TPM (with TPM2_NV_Extend,
+// authorized with the owner's empty password). The index's name algorithm
+// must be hashAlgo.
+func (c *CEL) AppendNVEvent(tpm io.ReadWriter, index uint32, hashAlgo crypto.Hash, event Content) error {
+	if index == 0 {
+		return fmt.Errorf("NV index must not be zero")
+	}
+	digest, err := event.GenerateDigest(hashAlgo)
+	if err != nil {
+		return err
+	}
+	auth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+	if _, err := internal.RunCommand(tpm, internal.CmdNVExtend, []tpmutil.Handle{tpm2.HandleOwner, tpmutil.Handle(index)},
+		[]tpm2.AuthCommand{auth}, tpmutil.U16Bytes(digest)); err != nil {
+		return fmt.Errorf("failed to extend event to NV index 0x%x: %v", index, err)
+	}
+	eventTLV, err := event.GetTLV()
+	if err != nil {
+		return err
+	}
+	c.Records = append(c.Records, Record{
+		RecNum:  uint64(len(c.Records)),
+		NVIndex: index,
+		Digests: map[crypto.Hash][]byte{hashAlgo: digest},
+		Content: eventTLV,
+	})
+	return nil
+}
+
+func (c *CEL) appendRecord(pcr int, digests map[crypto.Hash][]byte, event Content) error {
+	eventTLV, err := event.GetTLV()
+	if err != nil {
+		return err
+	}
+	c.Records = append(c.Records, Record{
+		RecNum:  uint64(len(c.Records)),
+		PCR:     uint8(pcr),
+		Digests: digests,
+		Content: eventTLV,
+	})
+	return nil
+}
+
+func generateDigests(hashAlgos []crypto.Hash, event Content) (map[crypto.Hash][]byte, error) {
+	digestsMap := make(map[crypto.Hash][]byte)
+	for _, hashAlgo := range hashAlgos {
+		digest, err := event.GenerateDigest(hashAlgo)
+		if err != nil {
+			return nil, err
+		}
+		digestsMap[hashAlgo] = digest
+	}
+	return digestsMap, nil
+}
+
+func createRecNumField(recNum uint64) TLV {
+	value := make([]byte, recnumValueLength)
+	binary.BigEndian.PutUint64(value, recNum)
+	return TLV{recnumTypeValue, value}
+}
+
+// unmarshalRecNum takes in a TLV with its type equals to the recnum type value (0), and
+// return its record number.
+func unmarshalRecNum(tlv TLV) (uint64, error) {
+	if tlv.Type != recnumTypeValue {
+		return 0, fmt.Errorf("type of the TLV [%d] indicates it is not a recnum field [%d]",
+			tlv.Type, recnumTypeValue)
+	}
+	if uint32(len(tlv.Value)) != recnumValueLength {
+		return 0, fmt.Errorf(
+			"length of the value of the TLV [%d] doesn't match the defined length [%d] of value for recnum",
+			len(tlv.Value), recnumValueLength)
+	}
+	return binary.BigEndian.Uint64(tlv.Value), nil
+}
+
+func createPCRField(pcrNum uint8) TLV {
+	return TLV{pcrTypeValue, []byte{pcrNum}}
+}
+
+// unmarshalPCR takes in a TLV with its type equals to the PCR type value (1), and
+// return its PCR number.
+func unmarshalPCR(tlv TLV) (pcrNum uint8, err error) {
+	if tlv.Type != pcrTypeValue {
+		return 0, fmt.Errorf("type of the TLV [%d] indicates it is not a PCR field [%d]",
+			tlv.Type, pcrTypeValue)
+	}
+	if uint32(len(tlv.Value)) != pcrValueLength {
+		return 0, fmt.Errorf(
+			"length of the value of the TLV [%d] doesn't match the defined length [%d] of value for a PCR field",
+			len(tlv.Value), pcrValueLength)
+	}
+
+	return tlv.Value[0], nil
+}
+
+func createNVIndexField(index uint32) TLV {
+	value := make([]byte, nvIndexValueLength)
+	binary.BigEndian.PutUint32(value, index)
+	return TLV{nvIndexTypeValue, value}
+}
+
+// unmarshalNVIndex takes in a TLV with its type equals to the nvindex type
+// value (2), and return its NV index.
+func unmarshalNVIndex(tlv TLV) (uint32, error) {
+	if tlv.Type != nvIndexTypeValue {
+		return 0, fmt.Errorf("type of the TLV [%d] indicates it is not an NV index field [%d]",
+			tlv.Type, nvIndexTypeValue)
+	}
+	if uint32(len(tlv.Value)) != nvIndexValueLength {
+		return 0, fmt.Errorf(
+			"length of the value of the TLV [%d] doesn't match the defined length [%d] of value for an NV index field",
+			len(tlv.Value), nvIndexValueLength)
+	}
+	index := binary.BigEndian.Uint32(tlv.Value)
+	if index == 0 {
+		return 0, fmt.Errorf("NV index field is zero")
+	}
+	return index, nil
+}
+
+func createDigestField(digestMap map[crypto.Hash][]byte) (TLV, error) {
+	var buf bytes.Buffer
+	// Encode the digests in a fixed order, so encoding is deterministic.
+	for _, hashAlgo := range []crypto.Hash{crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		digest, ok := digestMap[hashAlgo]
+		if !ok {
+			continue
+		}
+		tpmHashAlg, err := tpm2.HashToAlgorithm(hashAlgo)
+		if err != nil {
+			return TLV{}, err
+		}
+		singleDigestTLV := TLV{uint8(tpmHashAlg), digest}
+		d, err := singleDigestTLV.MarshalBinary()
+		if err != nil {
+			return TLV{}, err
+		}
+		buf.Write(d)
+	}
+	if buf.Len() == 0 && len(digestMap) != 0 {
+		return TLV{}, fmt.Errorf("no supported hash algorithms in digests")
+	}
+	return TLV{digestsTypeValue, buf.Bytes()}, nil
+}
+
+// unmarshalDigests takes in a TLV with its type equals to the digests type value (3), and
+// return its digests content in a map, the key is its TPM hash algorithm.
+func unmarshalDigests(tlv TLV) (digestsMap map[crypto.Hash][]byte, err error) {
+	if tlv.Type != digestsTypeValue {
+		return nil, fmt.Errorf("type of the TLV indicates it doesn't contain digests")
+	}
+
+	buf := bytes.NewBuffer(tlv.Value)
+	digestsMap = make(map[crypto.Hash][]byte)
+
+	for buf.Len() > 0 {
+		digestTLV, err := UnmarshalFirstTLV(buf)
+		if err == io.EOF {
+			return nil, fmt.Errorf("buffer ends unexpectedly")
+		} else if err != nil {
+			return nil, err
+		}
+		hashAlg, err := tpm2.Algorithm(digestTLV.Type).Hash()
+		if err != nil {
+			return nil, err
+		}
+		digestsMap[hashAlg] = digestTLV.Value
+	}
+	return digestsMap, nil
+}
+
+// EncodeCELRecord encodes the CEL record to bytes according to the CEL spec and
+// writes them to the bytes byffer.
+func (r *Record) EncodeCELRecord(buf *bytes.Buffer) error {
+	recnumField, err := createRecNumField(r.RecNum).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	indexField := createPCRField(r.PCR)
+	if r.NVIndex != 0 {
+		indexField = createNVIndexField(r.NVIndex)
+	}
+	pcrField, err := indexField.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	digests, err := createDigestField(r.Digests)
+	if err != nil {
+		return err
+	}
+	digestsField, err := digests.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	eventField, err := r.Content.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	buf.Write(recnumField)
+	buf.Write(pcrField)
+	buf.Write(digestsField)
+	buf.Write(eventField)
+	return nil
+}
+
+// EncodeCEL encodes the CEL to bytes according to the CEL spec and writes them
+// to the bytes buffer.
+func (c *CEL) EncodeCEL(buf *bytes.Buffer) error {
+	for _, record := range c.Records {
+		if err := record.EncodeCELRecord(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeToCEL will read the buf for CEL, will return err if the buffer
+// is not complete.
+func DecodeToCEL(buf *bytes.Buffer) (CEL, error) {
+	var cel CEL
+	for buf.Len() > 0 {
+		celr, err := decodeToCELR(buf)
+		if err == io.EOF {
+			return CEL{}, fmt.Errorf("buffer ends unexpectedly")
+		}
+		if err != nil {
+			return CEL{}, err
+		}
+		cel.Records = append(cel.Records, celr)
+	}
+	return cel, nil
+}
+
+// decodeToCELR will read the buf for the next CELR, will return err if
+// failed to unmarshal a correct CELR TLV from the buffer.
+func decodeToCELR(buf *bytes.Buffer) (r Record, err error) {
+	recnum, err := UnmarshalFirstTLV(buf)
+	if err != nil {
+		return Record{}, err
+	}
+	r.RecNum, err = unmarshalRecNum(recnum)
+	if err != nil {
+		return Record{}, err
+	}
+
+	pcr, err := UnmarshalFirstTLV(buf)
+	if err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+	if pcr.Type == nvIndexTypeValue {
+		r.NVIndex, err = unmarshalNVIndex(pcr)
+	} else {
+		r.PCR, err = unmarshalPCR(pcr)
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	digests, err := UnmarshalFirstTLV(buf)
+	if err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+	r.Digests, err = unmarshalDigests(digests)
+	if err != nil {
+		return Record{}, err
+	}
+
+	r.Content, err = UnmarshalFirstTLV(buf)
+	if err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+	return r, nil
+}
+
+// Replay takes the digests from a Canonical Event Log and carries out the
+// extend sequence for each PCR in the log. It then compares the final digests
+// against a bank of PCR values to see if they match. The content of each
+// record must hash to its digest, so the content can be trusted once the
+// replay succeeds. Records extended into NV indexes are rejected (see
+// ReplayNV).
+func (c *CEL) Replay(bank *pb.PCRs) error {
+	tpmAlg := tpm2.Algorithm(bank.GetHash())
+	cryptoHash, err := tpmAlg.Hash()
+	if err != nil {
+		return err
+	}
+	replayed := make(map[uint8][]byte)
+	for _, record := range c.Records {
+		if record.NVIndex != 0 {
+			return fmt.Errorf("CEL record %d was extended into NV index 0x%x, not a PCR", record.RecNum, record.NVIndex)
+		}
+		if _, ok := replayed[record.PCR]; !ok {
+			replayed[record.PCR] = make([]byte, cryptoHash.Size())
+		}
+		digest, err := record.contentDigest(cryptoHash)
+		if err != nil {
+			return err
+		}
+		replayed[record.PCR] = extend(cryptoHash, replayed[record.PCR], digest)
+	}
+
+	var failedReplayPcrs []uint8
+	for replayPcr, replayDigest := range replayed {
+		bankDigest, ok := bank.GetPcrs()[uint32(replayPcr)]
+		if !ok {
+			return fmt.Errorf("the CEL contained record(s) for PCR%d without a matching PCR in the bank to verify", replayPcr)
+		}
+		if !bytes.Equal(bankDigest, replayDigest) {
+			failedReplayPcrs = append(failedReplayPcrs, replayPcr)
+		}
+	}
+
+	if len(failedReplayPcrs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("CEL replay failed for these PCRs in bank %v: %v", cryptoHash, failedReplayPcrs)
+}
+
+// ReplayNV carries out the extend sequence of the records in the log which
+// were extended into an NV extend index, and compares the result with the
+// index's contents. The index's name algorithm must be hashAlgo. As with
+// Replay, the content of each of these records must hash to its digest.
+func (c *CEL) ReplayNV(index uint32, hashAlgo crypto.Hash, contents []byte) error {
+	replayed := make([]byte, hashAlgo.Size())
+	for _, record := range c.Records {
+		if record.NVIndex != index {
+			continue
+		}
+		digest, err := record.contentDigest(hashAlgo)
+		if err != nil {
+			return err
+		}
+		replayed = extend(hashAlgo, replayed, digest)
+	}
+	if !bytes.Equal(replayed, contents) {
+		return fmt.Errorf("CEL replay failed for NV index 0x%x", index)
+	}
+	return nil
+}
+
+// contentDigest returns the record's digest for hashAlgo, checking that it is
+// the digest of the record's content. Otherwise, the content of a record could
+// be replaced while keeping the digests which replay.
+func (r Record) contentDigest(hashAlgo crypto.Hash) ([]byte, error) {
+	digest, ok := r.Digests[hashAlgo]
+	if !ok {
+		return nil, fmt.Errorf("the CEL record did not contain a %v digest", hashAlgo)
+	}
+	contentDigest, err := r.Content.GenerateDigest(hashAlgo)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(digest, contentDigest) {
+		return nil, fmt.Errorf("the content of CEL record %d does not match its %v digest", r.RecNum, hashAlgo)
+	}
+	return digest, nil
+}
+
+// extend implements the TPM2_PCR_Extend operation: new = H(old || digest).
+func extend(hashAlgo crypto.Hash, pcrValue, digest []byte) []byte {
+	hash := hashAlgo.New()
+	hash.Write(pcrValue)
+	hash.Write(digest)
+	return hash.Sum(nil)
+}
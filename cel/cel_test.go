@@ -0,0 +1,126 @@
+package cel
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+	"reflect"
+	"testing"
+
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+var measuredHashes = []crypto.Hash{crypto.SHA1, crypto.SHA256}
+
+func TestTLVEncodingDecoding(t *testing.T) {
+	tlv := TLV{Type: 3, Value: []byte("sample value")}
+	data, err := tlv.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalFirstTLV(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tlv, decoded) {
+		t.Errorf("got %v, want %v", decoded, tlv)
+	}
+
+	if _, err := UnmarshalFirstTLV(bytes.NewBuffer(nil)); err != io.EOF {
+		t.Errorf("empty buffer: got error %v, want io.EOF", err)
+	}
+	if _, err := UnmarshalFirstTLV(bytes.NewBuffer(data[:len(data)-1])); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated buffer: got error %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, err := UnmarshalFirstTLV(bytes.NewBuffer([]byte{3, 0xff, 0xff, 0xff, 0xff, 1})); err != io.ErrUnexpectedEOF {
+		t.Errorf("oversized length: got error %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestTLVUnmarshalBinary(t *testing.T) {
+	subtests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"Empty", nil, true},
+		{"TypeOnly", []byte{3}, true},
+		{"TruncatedLength", []byte{3, 0, 0, 0}, true},
+		{"EmptyValue", []byte{3, 0, 0, 0, 0}, false},
+		{"ShortValue", []byte{3, 0, 0, 0, 2, 1}, true},
+		{"LongValue", []byte{3, 0, 0, 0, 1, 1, 2}, true},
+		{"Value", []byte{3, 0, 0, 0, 2, 1, 2}, false},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			var tlv TLV
+			err := tlv.UnmarshalBinary(subtest.data)
+			if gotErr := err != nil; gotErr != subtest.wantErr {
+				t.Errorf("UnmarshalBinary(%v): got error %v, want error %v", subtest.data, err, subtest.wantErr)
+			}
+		})
+	}
+}
+
+func TestCELDecodingTruncated(t *testing.T) {
+	cel := &CEL{}
+	if err := cel.appendRecord(debugPCR, map[crypto.Hash][]byte{crypto.SHA256: make([]byte, 32)}, TLV{1, []byte("event")}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := cel.EncodeCEL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	for _, size := range []int{1, 20, len(data) - 1} {
+		if _, err := DecodeToCEL(bytes.NewBuffer(data[:size])); err == nil {
+			t.Errorf("decoding %d of %d bytes should have failed", size, len(data))
+		}
+	}
+}
+
+func TestCELReplayContent(t *testing.T) {
+	const pcr = 16
+	const index = 0x01c10100
+	newLog := func() (*CEL, *pb.PCRs, []byte) {
+		log := &CEL{}
+		pcrValue := make([]byte, crypto.SHA256.Size())
+		nvValue := make([]byte, crypto.SHA256.Size())
+		for i, event := range []TLV{{1, []byte("lockdown=integrity")}, {1, []byte("second")}} {
+			digests, err := generateDigests([]crypto.Hash{crypto.SHA256}, event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			log.Records = append(log.Records, Record{RecNum: uint64(i), PCR: pcr, Digests: digests, Content: event})
+			pcrValue = extend(crypto.SHA256, pcrValue, digests[crypto.SHA256])
+			nvValue = extend(crypto.SHA256, nvValue, digests[crypto.SHA256])
+		}
+		return log, &pb.PCRs{Hash: pb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{pcr: pcrValue}}, nvValue
+	}
+
+	log, pcrs, nvValue := newLog()
+	if err := log.Replay(pcrs); err != nil {
+		t.Fatalf("Replay() failed: %v", err)
+	}
+	// Replacing the content, but keeping the digests which replay, is
+	// detected.
+	log.Records[0].Content = TLV{1, []byte("lockdown=none")}
+	if err := log.Replay(pcrs); err == nil {
+		t.Error("Replay() of a record whose content does not match its digest should fail")
+	}
+
+	log, _, _ = newLog()
+	for i := range log.Records {
+		log.Records[i].NVIndex = index
+	}
+	if err := log.ReplayNV(index, crypto.SHA256, nvValue); err != nil {
+		t.Fatalf("ReplayNV() failed: %v", err)
+	}
+	if err := log.Replay(pcrs); err == nil {
+		t.Error("Replay() of records extended into an NV index should fail")
+	}
+	log.Records[1].Content = TLV{1, []byte("forged")}
+	if err := log.ReplayNV(index, crypto.SHA256, nvValue); err == nil {
+		t.Error("ReplayNV() of a record whose content does not match its digest should fail")
+	}
+}
@@ -0,0 +1,162 @@
+package cel
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ConfigFileType is the CEL content type of ConfigFileEvent records. It is
+// not one of the content types defined by the CEL specification.
+const ConfigFileType uint8 = 82
+
+// The types of the TLVs in the value of a ConfigFileEvent's TLV.
+const (
+	configFilePathType   uint8 = 0
+	configFileDigestType uint8 = 1
+)
+
+// CommonConfigFiles are security-relevant configuration files found on many
+// Linux machines: the SSH server and sudo configuration, and the
+// configuration of common container runtimes. Files which do not exist on a
+// machine are measured as missing.
+var CommonConfigFiles = []string{
+	"/etc/ssh/sshd_config",
+	"/etc/sudoers",
+	"/etc/containerd/config.toml",
+	"/etc/docker/daemon.json",
+	"/etc/crio/crio.conf",
+}
+
+// ConfigFileEvent is CEL content recording the SHA-256 digest of the contents
+// of a configuration file. An empty Digest means the file did not exist.
+type ConfigFileEvent struct {
+	// The absolute, cleaned path of the file
+	Path   string
+	Digest []byte
+}
+
+// GetTLV encodes the event as a TLV of ConfigFileType, whose value is a TLV
+// holding the path, followed by a TLV holding the digest.
+func (e ConfigFileEvent) GetTLV() (TLV, error) {
+	return fileDigestTLV(ConfigFileType, e.Path, e.Digest)
+}
+
+// GenerateDigest hashes the event's TLV encoding.
+func (e ConfigFileEvent) GenerateDigest(hashAlgo crypto.Hash) ([]byte, error) {
+	tlv, err := e.GetTLV()
+	if err != nil {
+		return nil, err
+	}
+	return tlv.GenerateDigest(hashAlgo)
+}
+
+// ParseConfigFileEvent decodes the content of a record of ConfigFileType.
+func ParseConfigFileEvent(content TLV) (ConfigFileEvent, error) {
+	path, digest, err := parseFileDigestTLV(content, ConfigFileType, "config file")
+	if err != nil {
+		return ConfigFileEvent{}, err
+	}
+	return ConfigFileEvent{path, digest}, nil
+}
+
+// fileDigestTLV encodes a file's path and digest as a TLV of the given type,
+// whose value is a TLV holding the path, followed by a TLV holding the digest.
+func fileDigestTLV(typ uint8, path string, digest []byte) (TLV, error) {
+	pathTLV, err := TLV{configFilePathType, []byte(path)}.MarshalBinary()
+	if err != nil {
+		return TLV{}, err
+	}
+	digestTLV, err := TLV{configFileDigestType, digest}.MarshalBinary()
+	if err != nil {
+		return TLV{}, err
+	}
+	return TLV{typ, append(pathTLV, digestTLV...)}, nil
+}
+
+// parseFileDigestTLV decodes a TLV encoded by fileDigestTLV. The digest must
+// be empty or a SHA-256 digest.
+func parseFileDigestTLV(content TLV, typ uint8, name string) (string, []byte, error) {
+	if content.Type != typ {
+		return "", nil, fmt.Errorf("content type %d is not a %s event", content.Type, name)
+	}
+	buf := bytes.NewBuffer(content.Value)
+	path, err := UnmarshalFirstTLV(buf)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid %s event: %w", name, err)
+	}
+	digest, err := UnmarshalFirstTLV(buf)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid %s event: %w", name, err)
+	}
+	if path.Type != configFilePathType || digest.Type != configFileDigestType {
+		return "", nil, fmt.Errorf("invalid %s event: unexpected field types %d and %d", name, path.Type, digest.Type)
+	}
+	if buf.Len() != 0 {
+		return "", nil, fmt.Errorf("invalid %s event: %d trailing bytes", name, buf.Len())
+	}
+	if len(digest.Value) != 0 && len(digest.Value) != sha256.Size {
+		return "", nil, fmt.Errorf("invalid %s event: digest length %d", name, len(digest.Value))
+	}
+	return string(path.Value), digest.Value, nil
+}
+
+// hashFile returns the SHA-256 digest of a file's contents, or nil if the
+// file does not exist. Relative paths are made absolute.
+func hashFile(path string) (string, []byte, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return path, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", nil, err
+	}
+	return path, hash.Sum(nil), nil
+}
+
+// ReadConfigFile hashes the contents of a configuration file. If the file
+// does not exist, the returned event has an empty Digest. Relative paths are
+// made absolute, so the verifier sees the file's full path.
+func ReadConfigFile(path string) (ConfigFileEvent, error) {
+	path, digest, err := hashFile(path)
+	if err != nil {
+		return ConfigFileEvent{}, err
+	}
+	return ConfigFileEvent{path, digest}, nil
+}
+
+// MeasureConfigFiles hashes each of the configuration files with
+// ReadConfigFile, and appends them to the CEL, extending them into the given
+// PCR once for every hash algorithm in hashAlgos. It is meant to be called
+// when the machine boots or an agent starts, and whenever the files may have
+// changed, so a verifier can check the files against expected digests (see
+// the config_files field of a server Policy).
+//
+// As with MeasureKernelSecurity, the PCR must only be extended by this CEL,
+// and should not be resettable.
+func (c *CEL) MeasureConfigFiles(tpm io.ReadWriter, pcr int, hashAlgos []crypto.Hash, paths []string) error {
+	for _, path := range paths {
+		event, err := ReadConfigFile(path)
+		if err != nil {
+			return fmt.Errorf("reading config file: %w", err)
+		}
+		if err := c.AppendEvent(tpm, pcr, hashAlgos, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cel
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigFileEventEncoding(t *testing.T) {
+	digest := sha256.Sum256([]byte("PermitRootLogin no\n"))
+	for _, event := range []ConfigFileEvent{
+		{"/etc/ssh/sshd_config", digest[:]},
+		{"/etc/sudoers", nil},
+	} {
+		tlv, err := event.GetTLV()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseConfigFileEvent(tlv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Path != event.Path || !bytes.Equal(parsed.Digest, event.Digest) {
+			t.Errorf("got %v, want %v", parsed, event)
+		}
+	}
+
+	tlv, err := ConfigFileEvent{"/etc/sudoers", digest[:]}.GetTLV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	short, err := ConfigFileEvent{"/etc/sudoers", digest[:20]}.GetTLV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped, err := TLV{configFileDigestType, nil}.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []TLV{
+		{KernelSecurityType, tlv.Value},
+		{ConfigFileType, nil},
+		{ConfigFileType, tlv.Value[:10]},
+		{ConfigFileType, append(tlv.Value, 0)},
+		{ConfigFileType, append(swapped, swapped...)},
+		short,
+	} {
+		if _, err := ParseConfigFileEvent(bad); err == nil {
+			t.Errorf("ParseConfigFileEvent(%v) should fail", bad)
+		}
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sshd_config")
+	contents := []byte("PermitRootLogin no\n")
+	if err := ioutil.WriteFile(path, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+	event, err := ReadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(contents)
+	if event.Path != path || !bytes.Equal(event.Digest, digest[:]) {
+		t.Errorf("ReadConfigFile() = %v, want the file's digest", event)
+	}
+
+	missing := filepath.Join(dir, "missing")
+	event, err = ReadConfigFile(missing)
+	if err != nil || event.Path != missing || event.Digest != nil {
+		t.Errorf("ReadConfigFile() of a missing file = (%v, %v), want an empty digest", event, err)
+	}
+	if _, err := ReadConfigFile(dir); err == nil {
+		t.Error("ReadConfigFile() of a directory should fail")
+	}
+}
@@ -0,0 +1,269 @@
+package cel
+
+import (
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ExecType is the CEL content type of ExecEvent records. It is not one of the
+// content types defined by the CEL specification.
+const ExecType uint8 = 83
+
+// The Linux audit record types used to detect executions, from
+// linux/audit.h.
+const (
+	AuditSyscall uint16 = 1300
+	AuditExecve  uint16 = 1309
+	AuditEOE     uint16 = 1320
+)
+
+// ExecEvent is CEL content recording the execution of a file outside an
+// ExecCollector's allowlist, with the SHA-256 digest of the file's contents
+// when it was measured. An empty Digest means the file could not be read,
+// for example because it no longer existed.
+type ExecEvent struct {
+	// The absolute path of the executed file
+	Path   string
+	Digest []byte
+}
+
+// GetTLV encodes the event as a TLV of ExecType, whose value is a TLV holding
+// the path, followed by a TLV holding the digest (as for ConfigFileEvent).
+func (e ExecEvent) GetTLV() (TLV, error) {
+	return fileDigestTLV(ExecType, e.Path, e.Digest)
+}
+
+// GenerateDigest hashes the event's TLV encoding.
+func (e ExecEvent) GenerateDigest(hashAlgo crypto.Hash) ([]byte, error) {
+	tlv, err := e.GetTLV()
+	if err != nil {
+		return nil, err
+	}
+	return tlv.GenerateDigest(hashAlgo)
+}
+
+// ParseExecEvent decodes the content of a record of ExecType.
+func ParseExecEvent(content TLV) (ExecEvent, error) {
+	path, digest, err := parseFileDigestTLV(content, ExecType, "exec")
+	if err != nil {
+		return ExecEvent{}, err
+	}
+	return ExecEvent{path, digest}, nil
+}
+
+// AuditRecord is a record from the Linux audit subsystem, as received from the
+// kernel: Data is the record's text, such as
+// `audit(1700000000.123:42): arch=c000003e syscall=59 success=yes ...`.
+type AuditRecord struct {
+	Type uint16
+	Data string
+}
+
+// AuditSource is a stream of Linux audit records, such as an AuditConn.
+type AuditSource interface {
+	Receive() (AuditRecord, error)
+}
+
+// ExecCollector measures executions reported by the Linux audit subsystem
+// into a CEL as they happen, so a suspicious execution is visible in the next
+// attestation, rather than only after a reboot or a periodic scan. Each
+// executed file outside the Allowlist is hashed and appended to Log as an
+// ExecEvent, extending PCR once for every hash algorithm in HashAlgos. A file
+// is measured again only if its contents change, so the log grows with the
+// number of distinct unexpected executables, not executions.
+//
+// The kernel only reports executions matching an audit rule, so a rule such as
+// `auditctl -a always,exit -F arch=b64 -S execve,execveat` must be loaded.
+// Executions are measured after they happen: the measurement cannot prevent an
+// execution, and a process which compromises the machine could extend the PCR
+// itself to hide later executions, but not the ones measured before it ran.
+//
+// As with MeasureKernelSecurity, the PCR must only be extended by this CEL,
+// and should not be resettable. Log must only be accessed through WithLog
+// while the collector runs.
+type ExecCollector struct {
+	TPM       io.ReadWriter
+	Log       *CEL
+	PCR       int
+	HashAlgos []crypto.Hash
+	// Executables which are not measured: absolute paths, filepath.Match
+	// patterns (e.g. "/usr/bin/*"), or directories ending with "/", allowing
+	// everything beneath them.
+	Allowlist []string
+	// If set, called with each event after it is measured.
+	OnMeasure func(ExecEvent)
+
+	mu       sync.Mutex
+	measured map[string]bool
+	// The audit event being received, identified by its serial number.
+	serial  string
+	exe     string
+	success bool
+	execve  bool
+}
+
+// Run measures the executions reported by the source, until receiving fails
+// (e.g. because the source was closed) or a measurement fails, returning the
+// error.
+func (c *ExecCollector) Run(source AuditSource) error {
+	for {
+		record, err := source.Receive()
+		if err != nil {
+			return err
+		}
+		if err := c.HandleRecord(record); err != nil {
+			return err
+		}
+	}
+}
+
+// HandleRecord processes a single audit record, measuring an execution once
+// all of its records have been received. Records of other types are ignored.
+// Records must be handled in the order they were received, by one goroutine.
+func (c *ExecCollector) HandleRecord(record AuditRecord) error {
+	if record.Type != AuditSyscall && record.Type != AuditExecve && record.Type != AuditEOE {
+		return nil
+	}
+	serial, fields, err := parseAuditRecord(record.Data)
+	if err != nil {
+		return nil
+	}
+	// The records of an event are sent together, ending with an EOE record,
+	// but the EOE record may be missing if the kernel's backlog overflowed.
+	if serial != c.serial {
+		if err := c.endEvent(); err != nil {
+			return err
+		}
+		c.serial = serial
+	}
+	switch record.Type {
+	case AuditSyscall:
+		c.success = fields["success"] == "yes"
+		c.exe, _ = decodeAuditString(fields["exe"])
+	case AuditExecve:
+		c.execve = true
+	case AuditEOE:
+		return c.endEvent()
+	}
+	return nil
+}
+
+// endEvent measures the execution reported by the event being received, if
+// any, and resets the event.
+func (c *ExecCollector) endEvent() error {
+	exe, executed := c.exe, c.execve && c.success && c.exe != ""
+	c.serial, c.exe, c.success, c.execve = "", "", false, false
+	if !executed {
+		return nil
+	}
+	allowed, err := c.allowed(exe)
+	if err != nil || allowed {
+		return err
+	}
+	return c.measure(exe)
+}
+
+func (c *ExecCollector) allowed(path string) (bool, error) {
+	for _, pattern := range c.Allowlist {
+		if strings.HasSuffix(pattern, "/") {
+			if strings.HasPrefix(path, pattern) {
+				return true, nil
+			}
+			continue
+		}
+		match, err := filepath.Match(pattern, path)
+		if err != nil {
+			return false, fmt.Errorf("allowlist pattern %q: %w", pattern, err)
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *ExecCollector) measure(exe string) error {
+	// Failing to read the file must not stop the collector, or executing an
+	// unreadable file would hide later executions.
+	path, digest, err := hashFile(exe)
+	if err != nil {
+		path, digest = exe, nil
+	}
+	event := ExecEvent{path, digest}
+	key := path + "\x00" + hex.EncodeToString(digest)
+
+	c.mu.Lock()
+	if c.measured[key] {
+		c.mu.Unlock()
+		return nil
+	}
+	err = c.Log.AppendEvent(c.TPM, c.PCR, c.HashAlgos, event)
+	if err == nil {
+		if c.measured == nil {
+			c.measured = make(map[string]bool)
+		}
+		c.measured[key] = true
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if c.OnMeasure != nil {
+		c.OnMeasure(event)
+	}
+	return nil
+}
+
+// WithLog calls f with the collector's CEL, while no executions are measured.
+// An attestation must encode the CEL and quote the PCR inside f, so the CEL
+// replays to the quoted PCR value.
+func (c *ExecCollector) WithLog(f func(log *CEL) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return f(c.Log)
+}
+
+// parseAuditRecord splits the text of an audit record into its serial number
+// and its fields. Field values are returned as logged, so strings may be
+// quoted or hex encoded (see decodeAuditString).
+func parseAuditRecord(data string) (string, map[string]string, error) {
+	header := strings.Index(data, "): ")
+	if !strings.HasPrefix(data, "audit(") || header < 0 {
+		return "", nil, errors.New("audit record has no header")
+	}
+	id := data[len("audit("):header]
+	colon := strings.LastIndexByte(id, ':')
+	if colon < 0 {
+		return "", nil, fmt.Errorf("invalid audit record ID %q", id)
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Fields(data[header+len("): "):]) {
+		if eq := strings.IndexByte(field, '='); eq > 0 {
+			fields[field[:eq]] = field[eq+1:]
+		}
+	}
+	return id[colon+1:], fields, nil
+}
+
+// decodeAuditString decodes a string field of an audit record: the kernel
+// quotes strings, unless they contain spaces, quotes or control characters,
+// when it hex encodes them instead.
+func decodeAuditString(value string) (string, error) {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1], nil
+	}
+	if value == "(null)" {
+		return "", errors.New("null audit string")
+	}
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid audit string %q: %w", value, err)
+	}
+	return string(decoded), nil
+}
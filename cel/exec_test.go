@@ -0,0 +1,59 @@
+package cel
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestExecEventEncoding(t *testing.T) {
+	digest := sha256.Sum256([]byte("payload"))
+	event := ExecEvent{"/tmp/payload", digest[:]}
+	tlv, err := event.GetTLV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseExecEvent(tlv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Path != event.Path || !bytes.Equal(parsed.Digest, event.Digest) {
+		t.Errorf("got %v, want %v", parsed, event)
+	}
+	if _, err := ParseExecEvent(TLV{ConfigFileType, tlv.Value}); err == nil {
+		t.Error("ParseExecEvent() of a config file event should fail")
+	}
+	if _, err := ParseConfigFileEvent(tlv); err == nil {
+		t.Error("ParseConfigFileEvent() of an exec event should fail")
+	}
+}
+
+func TestParseAuditRecord(t *testing.T) {
+	serial, fields, err := parseAuditRecord(`audit(1700000000.123:42): arch=c000003e syscall=59 success=yes exe="/usr/bin/id" key=(null)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial != "42" || fields["success"] != "yes" || fields["exe"] != `"/usr/bin/id"` || fields["key"] != "(null)" {
+		t.Errorf("got serial %q and fields %v", serial, fields)
+	}
+	for _, bad := range []string{"", "arch=c000003e", "audit(1700000000.123): arch=c000003e"} {
+		if _, _, err := parseAuditRecord(bad); err == nil {
+			t.Errorf("parseAuditRecord(%q) should fail", bad)
+		}
+	}
+
+	for value, want := range map[string]string{
+		`"/usr/bin/id"`:                        "/usr/bin/id",
+		hex.EncodeToString([]byte("/tmp/a b")): "/tmp/a b",
+	} {
+		if got, err := decodeAuditString(value); err != nil || got != want {
+			t.Errorf("decodeAuditString(%q) = (%q, %v), want %q", value, got, err, want)
+		}
+	}
+	for _, bad := range []string{"(null)", "/usr/bin/id", `"`} {
+		if _, err := decodeAuditString(bad); err == nil {
+			t.Errorf("decodeAuditString(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cel
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyDerivationType is the CEL content type of KeyDerivationEvent records. It
+// is not one of the content types defined by the CEL specification.
+const KeyDerivationType uint8 = 85
+
+// The types of the TLVs in the value of a KeyDerivationEvent's TLV.
+const (
+	keyDerivationNameType  uint8 = 0
+	keyDerivationLabelType uint8 = 1
+)
+
+// KeyDerivationEvent is CEL content recording that keys were derived for a
+// purpose from a TPM master key (see client.Key.DeriveKey).
+type KeyDerivationEvent struct {
+	// The Name of the master key, encoded as a TPMT_HA (see client.Key.Name)
+	KeyName []byte
+	// The label of the derived keys' purpose, which cannot contain a zero
+	// byte (see CheckDerivationLabel)
+	Label string
+}
+
+// GetTLV encodes the event as a TLV of KeyDerivationType, whose value is a TLV
+// holding the key name, followed by a TLV holding the label.
+func (e KeyDerivationEvent) GetTLV() (TLV, error) {
+	name, err := TLV{keyDerivationNameType, e.KeyName}.MarshalBinary()
+	if err != nil {
+		return TLV{}, err
+	}
+	label, err := TLV{keyDerivationLabelType, []byte(e.Label)}.MarshalBinary()
+	if err != nil {
+		return TLV{}, err
+	}
+	return TLV{KeyDerivationType, append(name, label...)}, nil
+}
+
+// GenerateDigest hashes the event's TLV encoding.
+func (e KeyDerivationEvent) GenerateDigest(hashAlgo crypto.Hash) ([]byte, error) {
+	tlv, err := e.GetTLV()
+	if err != nil {
+		return nil, err
+	}
+	return tlv.GenerateDigest(hashAlgo)
+}
+
+// ParseKeyDerivationEvent decodes the content of a record of
+// KeyDerivationType.
+func ParseKeyDerivationEvent(content TLV) (KeyDerivationEvent, error) {
+	if content.Type != KeyDerivationType {
+		return KeyDerivationEvent{}, fmt.Errorf("TLV type %d is not a key derivation event (%d)", content.Type, KeyDerivationType)
+	}
+	buf := bytes.NewBuffer(content.Value)
+	name, err := UnmarshalFirstTLV(buf)
+	if err != nil {
+		return KeyDerivationEvent{}, fmt.Errorf("invalid key derivation event: %w", err)
+	}
+	label, err := UnmarshalFirstTLV(buf)
+	if err != nil {
+		return KeyDerivationEvent{}, fmt.Errorf("invalid key derivation event: %w", err)
+	}
+	if name.Type != keyDerivationNameType || label.Type != keyDerivationLabelType {
+		return KeyDerivationEvent{}, fmt.Errorf("invalid key derivation event: unexpected field types %d and %d", name.Type, label.Type)
+	}
+	if buf.Len() != 0 {
+		return KeyDerivationEvent{}, fmt.Errorf("invalid key derivation event: %d trailing bytes", buf.Len())
+	}
+	if err := CheckDerivationLabel(string(label.Value)); err != nil {
+		return KeyDerivationEvent{}, fmt.Errorf("invalid key derivation event: %w", err)
+	}
+	return KeyDerivationEvent{name.Value, string(label.Value)}, nil
+}
+
+// CheckDerivationLabel checks that a label can be used to derive keys with
+// client.Key.DeriveKey: it must not be empty, or contain a zero byte.
+func CheckDerivationLabel(label string) error {
+	if label == "" {
+		return errors.New("a derived key must have a label")
+	}
+	if strings.IndexByte(label, 0) >= 0 {
+		return fmt.Errorf("derivation label %q contains a zero byte", label)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package cel
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeyDerivationEventEncoding(t *testing.T) {
+	event := KeyDerivationEvent{[]byte{0, 0xb, 1}, "disk-encryption"}
+	tlv, err := event.GetTLV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseKeyDerivationEvent(tlv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parsed.KeyName, event.KeyName) || parsed.Label != event.Label {
+		t.Errorf("got %v, want %v", parsed, event)
+	}
+	if _, err := ParseKeyDerivationEvent(TLV{ExecType, tlv.Value}); err == nil {
+		t.Error("ParseKeyDerivationEvent() of an exec event should fail")
+	}
+	if _, err := ParseKeyDerivationEvent(TLV{KeyDerivationType, append(tlv.Value, 0)}); err == nil {
+		t.Error("ParseKeyDerivationEvent() with trailing bytes should fail")
+	}
+	for _, label := range []string{"", "disk-encryption\x00volume"} {
+		tlv, err := KeyDerivationEvent{event.KeyName, label}.GetTLV()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseKeyDerivationEvent(tlv); err == nil {
+			t.Errorf("ParseKeyDerivationEvent() with label %q should fail", label)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package cel
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// KernelSecurityType is the CEL content type of KernelSecurityEvent records.
+// It is not one of the content types defined by the CEL specification.
+const KernelSecurityType uint8 = 81
+
+// KernelSetting is a Linux kernel security setting recorded by a
+// KernelSecurityEvent.
+type KernelSetting uint8
+
+// The kernel security settings, and where they are read from.
+const (
+	// The selected mode in /sys/kernel/security/lockdown: "none",
+	// "integrity" or "confidentiality".
+	KernelLockdown KernelSetting = iota
+	// /sys/module/module/parameters/sig_enforce: "Y" or "N".
+	KernelModuleSigEnforce
+	// /proc/sys/kernel/kexec_load_disabled: "0" or "1".
+	KernelKexecLoadDisabled
+)
+
+// kernelSettingPaths are the files holding the value of each KernelSetting.
+var kernelSettingPaths = []struct {
+	setting KernelSetting
+	path    string
+}{
+	{KernelLockdown, "/sys/kernel/security/lockdown"},
+	{KernelModuleSigEnforce, "/sys/module/module/parameters/sig_enforce"},
+	{KernelKexecLoadDisabled, "/proc/sys/kernel/kexec_load_disabled"},
+}
+
+func (s KernelSetting) String() string {
+	switch s {
+	case KernelLockdown:
+		return "lockdown"
+	case KernelModuleSigEnforce:
+		return "module.sig_enforce"
+	case KernelKexecLoadDisabled:
+		return "kexec_load_disabled"
+	default:
+		return fmt.Sprintf("KernelSetting(%d)", uint8(s))
+	}
+}
+
+// KernelSecurityEvent is CEL content recording the value of a kernel security
+// setting, as reported by the running kernel. An empty Value means the kernel
+// does not support the setting.
+type KernelSecurityEvent struct {
+	Setting KernelSetting
+	Value   string
+}
+
+// GetTLV encodes the event as a TLV of KernelSecurityType, whose value is a
+// TLV with the setting as its type and the setting's value as its value.
+func (e KernelSecurityEvent) GetTLV() (TLV, error) {
+	inner, err := TLV{uint8(e.Setting), []byte(e.Value)}.MarshalBinary()
+	if err != nil {
+		return TLV{}, err
+	}
+	return TLV{KernelSecurityType, inner}, nil
+}
+
+// GenerateDigest hashes the event's TLV encoding.
+func (e KernelSecurityEvent) GenerateDigest(hashAlgo crypto.Hash) ([]byte, error) {
+	tlv, err := e.GetTLV()
+	if err != nil {
+		return nil, err
+	}
+	return tlv.GenerateDigest(hashAlgo)
+}
+
+// ParseKernelSecurityEvent decodes the content of a record of
+// KernelSecurityType.
+func ParseKernelSecurityEvent(content TLV) (KernelSecurityEvent, error) {
+	if content.Type != KernelSecurityType {
+		return KernelSecurityEvent{}, fmt.Errorf("content type %d is not a kernel security event", content.Type)
+	}
+	buf := bytes.NewBuffer(content.Value)
+	inner, err := UnmarshalFirstTLV(buf)
+	if err != nil {
+		return KernelSecurityEvent{}, fmt.Errorf("invalid kernel security event: %w", err)
+	}
+	if buf.Len() != 0 {
+		return KernelSecurityEvent{}, fmt.Errorf("invalid kernel security event: %d trailing bytes", buf.Len())
+	}
+	return KernelSecurityEvent{KernelSetting(inner.Type), string(inner.Value)}, nil
+}
+
+// ReadKernelSecurity reads the current value of every KernelSetting from the
+// running kernel. Settings which the kernel does not support have an empty
+// value.
+func ReadKernelSecurity() ([]KernelSecurityEvent, error) {
+	events := make([]KernelSecurityEvent, 0, len(kernelSettingPaths))
+	for _, s := range kernelSettingPaths {
+		value, err := readKernelSetting(s.setting, s.path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %v: %w", s.setting, err)
+		}
+		events = append(events, KernelSecurityEvent{s.setting, value})
+	}
+	return events, nil
+}
+
+func readKernelSetting(setting KernelSetting, path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(data))
+	if setting == KernelLockdown {
+		// The selected mode is bracketed: "none [integrity] confidentiality".
+		start, end := strings.IndexByte(value, '['), strings.IndexByte(value, ']')
+		if start < 0 || end < start {
+			return "", fmt.Errorf("no mode selected in %q", value)
+		}
+		value = value[start+1 : end]
+	}
+	return value, nil
+}
+
+// MeasureKernelSecurity reads the running kernel's security settings with
+// ReadKernelSecurity, and appends them to the CEL, extending them into the
+// given PCR once for every hash algorithm in hashAlgos.
+//
+// The PCR must not be extended by anything other than this CEL, or replaying
+// the CEL will fail. It also should not be resettable (like PCRs 16 and 23),
+// as then any process with access to the TPM could forge the measurements.
+func (c *CEL) MeasureKernelSecurity(tpm io.ReadWriter, pcr int, hashAlgos []crypto.Hash) error {
+	events, err := ReadKernelSecurity()
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := c.AppendEvent(tpm, pcr, hashAlgos, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package cel
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestKernelSecurityEventEncoding(t *testing.T) {
+	event := KernelSecurityEvent{KernelLockdown, "integrity"}
+	tlv, err := event.GetTLV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseKernelSecurityEvent(tlv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != event {
+		t.Errorf("got %v, want %v", parsed, event)
+	}
+
+	for _, bad := range []TLV{
+		{1, tlv.Value},
+		{KernelSecurityType, nil},
+		{KernelSecurityType, append(tlv.Value, 0)},
+	} {
+		if _, err := ParseKernelSecurityEvent(bad); err == nil {
+			t.Errorf("ParseKernelSecurityEvent(%v) should fail", bad)
+		}
+	}
+}
+
+func TestReadKernelSetting(t *testing.T) {
+	subtests := []struct {
+		name      string
+		setting   KernelSetting
+		contents  string
+		wantValue string
+		wantErr   bool
+	}{
+		{"LockdownNone", KernelLockdown, "[none] integrity confidentiality\n", "none", false},
+		{"LockdownIntegrity", KernelLockdown, "none [integrity] confidentiality\n", "integrity", false},
+		{"LockdownUnselected", KernelLockdown, "none integrity confidentiality\n", "", true},
+		{"SigEnforce", KernelModuleSigEnforce, "Y\n", "Y", false},
+		{"KexecLoadDisabled", KernelKexecLoadDisabled, "0\n", "0", false},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "setting")
+			if err := ioutil.WriteFile(path, []byte(subtest.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+			value, err := readKernelSetting(subtest.setting, path)
+			if (err != nil) != subtest.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, subtest.wantErr)
+			}
+			if value != subtest.wantValue {
+				t.Errorf("got value %q, want %q", value, subtest.wantValue)
+			}
+		})
+	}
+
+	value, err := readKernelSetting(KernelLockdown, filepath.Join(t.TempDir(), "missing"))
+	if err != nil || value != "" {
+		t.Errorf("missing setting: got (%q, %v), want an empty value", value, err)
+	}
+}
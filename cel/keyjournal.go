@@ -0,0 +1,238 @@
+package cel
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// KeyEventType is the CEL content type of KeyEvent records. It is not one of
+// the content types defined by the CEL specification.
+const KeyEventType uint8 = 84
+
+// KeyJournalIndex is the default NV index of a KeyJournal, in the range for
+// owner-defined indexes.
+const KeyJournalIndex uint32 = 0x01008F01
+
+// The types of the TLVs in the value of a KeyEvent's TLV.
+const (
+	keyEventActionType       uint8 = 0
+	keyEventNameType         uint8 = 1
+	keyEventHandleType       uint8 = 2
+	keyEventLabelType        uint8 = 3
+	keyEventPreviousNameType uint8 = 4
+)
+
+// KeyAction is what happened to a key in a KeyEvent. Its values match those
+// of attest.KeyAction.
+type KeyAction uint8
+
+// The KeyActions of KeyEvents.
+const (
+	KeyCreated KeyAction = iota + 1
+	// The key was made persistent at Handle.
+	KeyPersisted
+	// The key was removed from its persistent Handle.
+	KeyEvicted
+	// The key replaced the key named PreviousName.
+	KeyRotated
+)
+
+func (a KeyAction) String() string {
+	switch a {
+	case KeyCreated:
+		return "created"
+	case KeyPersisted:
+		return "persisted"
+	case KeyEvicted:
+		return "evicted"
+	case KeyRotated:
+		return "rotated"
+	default:
+		return fmt.Sprintf("KeyAction(%d)", uint8(a))
+	}
+}
+
+// KeyEvent is CEL content recording an event in the lifecycle of a TPM key.
+type KeyEvent struct {
+	Action KeyAction
+	// The Name of the key, encoded as a TPMT_HA (see client.Key.Name)
+	Name []byte
+	// The persistent handle, for KeyPersisted and KeyEvicted events
+	Handle uint32
+	// A description of the key's purpose, such as "ak" or "tls"
+	Label string
+	// The Name of the replaced key, for KeyRotated events
+	PreviousName []byte
+}
+
+// GetTLV encodes the event as a TLV of KeyEventType, whose value is a sequence
+// of TLVs holding the action, the name, and the handle, label and previous
+// name if they are set.
+func (e KeyEvent) GetTLV() (TLV, error) {
+	fields := []TLV{
+		{keyEventActionType, []byte{uint8(e.Action)}},
+		{keyEventNameType, e.Name},
+	}
+	if e.Handle != 0 {
+		handle := make([]byte, 4)
+		binary.BigEndian.PutUint32(handle, e.Handle)
+		fields = append(fields, TLV{keyEventHandleType, handle})
+	}
+	if e.Label != "" {
+		fields = append(fields, TLV{keyEventLabelType, []byte(e.Label)})
+	}
+	if len(e.PreviousName) != 0 {
+		fields = append(fields, TLV{keyEventPreviousNameType, e.PreviousName})
+	}
+	var value []byte
+	for _, field := range fields {
+		data, err := field.MarshalBinary()
+		if err != nil {
+			return TLV{}, err
+		}
+		value = append(value, data...)
+	}
+	return TLV{KeyEventType, value}, nil
+}
+
+// GenerateDigest hashes the event's TLV encoding.
+func (e KeyEvent) GenerateDigest(hashAlgo crypto.Hash) ([]byte, error) {
+	tlv, err := e.GetTLV()
+	if err != nil {
+		return nil, err
+	}
+	return tlv.GenerateDigest(hashAlgo)
+}
+
+// ParseKeyEvent decodes the content of a record of KeyEventType.
+func ParseKeyEvent(content TLV) (KeyEvent, error) {
+	if content.Type != KeyEventType {
+		return KeyEvent{}, fmt.Errorf("TLV type %d is not a key event (%d)", content.Type, KeyEventType)
+	}
+	var event KeyEvent
+	buf := bytes.NewBuffer(content.Value)
+	for buf.Len() > 0 {
+		field, err := UnmarshalFirstTLV(buf)
+		if err != nil {
+			return KeyEvent{}, fmt.Errorf("invalid key event: %w", err)
+		}
+		switch field.Type {
+		case keyEventActionType:
+			if len(field.Value) != 1 {
+				return KeyEvent{}, fmt.Errorf("key event action is %d bytes, want 1", len(field.Value))
+			}
+			event.Action = KeyAction(field.Value[0])
+		case keyEventNameType:
+			event.Name = field.Value
+		case keyEventHandleType:
+			if len(field.Value) != 4 {
+				return KeyEvent{}, fmt.Errorf("key event handle is %d bytes, want 4", len(field.Value))
+			}
+			event.Handle = binary.BigEndian.Uint32(field.Value)
+		case keyEventLabelType:
+			event.Label = string(field.Value)
+		case keyEventPreviousNameType:
+			event.PreviousName = field.Value
+		default:
+			return KeyEvent{}, fmt.Errorf("unknown key event field type %d", field.Type)
+		}
+	}
+	if event.Action < KeyCreated || event.Action > KeyRotated {
+		return KeyEvent{}, fmt.Errorf("invalid key event action %v", event.Action)
+	}
+	if len(event.Name) == 0 {
+		return KeyEvent{}, fmt.Errorf("key event has no key name")
+	}
+	return event, nil
+}
+
+// keyJournalAttributes allow the owner to extend the index, and anyone to
+// read (and so certify) it.
+const keyJournalAttributes = internal.NVTypeExtend | tpm2.AttrOwnerWrite | tpm2.AttrOwnerRead |
+	tpm2.AttrAuthRead | tpm2.AttrNoDA
+
+// KeyJournal records the lifecycle events of a machine's TPM keys (creation,
+// persistence, eviction and rotation) in a CEL whose records are extended
+// into an NV extend index, rather than a PCR. Unlike a host log, the history
+// cannot be changed or truncated without the index changing, and unlike a PCR,
+// it survives reboots. Attestations include the journal (see
+// client.AttestOpts.KeyJournalIndex) so verifiers can audit the key
+// management history.
+//
+// Log must hold the records extended into the index since it was defined, so
+// it should be saved after each Record, and loaded before the journal is
+// used again (see LoadKeyJournal). The owner can still delete and redefine the
+// index, restarting the history: verifiers should check that each
+// attestation's history extends the last one they saw (see
+// server.KeyJournalExtends).
+type KeyJournal struct {
+	TPM   io.ReadWriter
+	Log   *CEL
+	Index uint32
+
+	mu sync.Mutex
+}
+
+// LoadKeyJournal returns the journal in an NV index, defining the index (with
+// the owner's empty password) if it does not exist. The log must hold the
+// records extended into an existing index, and is checked against it.
+func LoadKeyJournal(rw io.ReadWriter, index uint32, log *CEL) (*KeyJournal, error) {
+	if log == nil {
+		log = &CEL{}
+	}
+	pub, err := tpm2.NVReadPublic(rw, tpmutil.Handle(index))
+	if err != nil {
+		pub = tpm2.NVPublic{
+			NVIndex:    tpmutil.Handle(index),
+			NameAlg:    tpm2.AlgSHA256,
+			Attributes: keyJournalAttributes,
+			DataSize:   uint16(crypto.SHA256.Size()),
+		}
+		if err := tpm2.NVDefineSpaceEx(rw, tpm2.HandleOwner, "", pub, tpm2.AuthCommand{
+			Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to define key journal NV index: %w", err)
+		}
+		if len(log.Records) != 0 {
+			return nil, fmt.Errorf("key journal NV index 0x%x did not exist, but its log has %d records", index, len(log.Records))
+		}
+		return &KeyJournal{TPM: rw, Log: log, Index: index}, nil
+	}
+	if pub.Attributes&internal.NVTypeMask != internal.NVTypeExtend || pub.NameAlg != tpm2.AlgSHA256 {
+		return nil, fmt.Errorf("NV index 0x%x is not a SHA-256 extend index", index)
+	}
+	contents := make([]byte, crypto.SHA256.Size())
+	if pub.Attributes&tpm2.AttrWritten != 0 {
+		if contents, err = tpm2.NVReadEx(rw, tpmutil.Handle(index), tpmutil.Handle(index), "", 0); err != nil {
+			return nil, fmt.Errorf("failed to read key journal NV index: %w", err)
+		}
+	}
+	if err := log.ReplayNV(index, crypto.SHA256, contents); err != nil {
+		return nil, fmt.Errorf("key journal log does not match its NV index: %w", err)
+	}
+	return &KeyJournal{TPM: rw, Log: log, Index: index}, nil
+}
+
+// Record appends an event to the journal, extending it into the NV index.
+func (j *KeyJournal) Record(event KeyEvent) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.Log.AppendNVEvent(j.TPM, j.Index, crypto.SHA256, event)
+}
+
+// WithLog calls f with the journal's CEL, while no events are recorded. An
+// attestation must encode the CEL and certify the NV index inside f, so the
+// CEL replays to the certified contents.
+func (j *KeyJournal) WithLog(f func(log *CEL) error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return f(j.Log)
+}
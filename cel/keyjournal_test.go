@@ -0,0 +1,53 @@
+package cel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeyEventEncoding(t *testing.T) {
+	events := []KeyEvent{
+		{Action: KeyCreated, Name: []byte{0, 0xb, 1, 2}},
+		{Action: KeyPersisted, Name: []byte{0, 0xb, 1, 2}, Handle: 0x81000001, Label: "ak"},
+		{Action: KeyRotated, Name: []byte{0, 0xb, 3, 4}, Label: "tls", PreviousName: []byte{0, 0xb, 1, 2}},
+	}
+	for _, event := range events {
+		tlv, err := event.GetTLV()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseKeyEvent(tlv)
+		if err != nil {
+			t.Fatalf("ParseKeyEvent() failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, event) {
+			t.Errorf("ParseKeyEvent() = %+v, want %+v", got, event)
+		}
+	}
+
+	invalid := []struct {
+		name  string
+		event KeyEvent
+	}{
+		{"NoAction", KeyEvent{Name: []byte{1}}},
+		{"UnknownAction", KeyEvent{Action: KeyRotated + 1, Name: []byte{1}}},
+		{"NoName", KeyEvent{Action: KeyCreated}},
+	}
+	for _, subtest := range invalid {
+		t.Run(subtest.name, func(t *testing.T) {
+			tlv, err := subtest.event.GetTLV()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ParseKeyEvent(tlv); err == nil {
+				t.Error("ParseKeyEvent() succeeded, want error")
+			}
+		})
+	}
+	if _, err := ParseKeyEvent(TLV{KeyEventType, []byte{keyEventLabelType + 10, 0, 0, 0, 0}}); err == nil {
+		t.Error("ParseKeyEvent() with an unknown field succeeded, want error")
+	}
+	if _, err := ParseKeyEvent(TLV{ExecType, nil}); err == nil {
+		t.Error("ParseKeyEvent() of another content type succeeded, want error")
+	}
+}
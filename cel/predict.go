@@ -0,0 +1,50 @@
+package cel
+
+import (
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// Event is a single planned measurement: the Content which will be measured
+// into the given PCR.
+type Event struct {
+	PCR     int
+	Content Content
+}
+
+// PredictPCRs computes the PCR values the TPM will hold after each of the
+// planned events has been measured (in order) with AppendEvent, starting
+// from the currentPCRs. Only the PCRs present in currentPCRs are returned,
+// and every planned event must target one of those PCRs.
+//
+// This allows data to be sealed to a future state of the TPM, by passing the
+// result as client.SealOpts.Target before the events are measured.
+func PredictPCRs(currentPCRs *pb.PCRs, plannedEvents []Event) (*pb.PCRs, error) {
+	hashAlgo, err := tpm2.Algorithm(currentPCRs.GetHash()).Hash()
+	if err != nil {
+		return nil, fmt.Errorf("invalid PCR bank: %w", err)
+	}
+
+	predicted := &pb.PCRs{Hash: currentPCRs.GetHash(), Pcrs: make(map[uint32][]byte)}
+	for pcr, value := range currentPCRs.GetPcrs() {
+		if len(value) != hashAlgo.Size() {
+			return nil, fmt.Errorf("PCR%d has length %d, expected %d for %v", pcr, len(value), hashAlgo.Size(), hashAlgo)
+		}
+		predicted.Pcrs[pcr] = append([]byte(nil), value...)
+	}
+
+	for i, event := range plannedEvents {
+		value, ok := predicted.Pcrs[uint32(event.PCR)]
+		if !ok {
+			return nil, fmt.Errorf("planned event %d extends PCR%d, which is not in the current PCRs", i, event.PCR)
+		}
+		digest, err := event.Content.GenerateDigest(hashAlgo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to digest planned event %d: %w", i, err)
+		}
+		predicted.Pcrs[uint32(event.PCR)] = extend(hashAlgo, value, digest)
+	}
+	return predicted, nil
+}
@@ -0,0 +1,53 @@
+package cel
+
+import (
+	"bytes"
+	"testing"
+
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// The PCRs of internal/test, which the tests in package cel cannot import, as
+// it imports the client package, which imports cel.
+const (
+	debugPCR       = 16
+	applicationPCR = 23
+)
+
+func TestPredictPCRsNoEvents(t *testing.T) {
+	current := &pb.PCRs{Hash: pb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{uint32(debugPCR): make([]byte, 32)}}
+	predicted, err := PredictPCRs(current, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(predicted.GetPcrs()[uint32(debugPCR)], current.GetPcrs()[uint32(debugPCR)]) {
+		t.Errorf("PCR value changed without any planned events")
+	}
+
+	// The input must not be modified by the prediction.
+	if _, err := PredictPCRs(current, []Event{{debugPCR, TLV{1, []byte("event")}}}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(current.GetPcrs()[uint32(debugPCR)], make([]byte, 32)) {
+		t.Error("PredictPCRs modified the current PCRs")
+	}
+}
+
+func TestPredictPCRsFailures(t *testing.T) {
+	event := []Event{{debugPCR, TLV{1, []byte("event")}}}
+	subtests := []struct {
+		name    string
+		current *pb.PCRs
+	}{
+		{"MissingPCR", &pb.PCRs{Hash: pb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{uint32(applicationPCR): make([]byte, 32)}}},
+		{"WrongLength", &pb.PCRs{Hash: pb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{uint32(debugPCR): make([]byte, 20)}}},
+		{"UnknownHash", &pb.PCRs{Hash: pb.HashAlgo_HASH_INVALID, Pcrs: map[uint32][]byte{uint32(debugPCR): make([]byte, 32)}}},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, err := PredictPCRs(subtest.current, event); err == nil {
+				t.Error("PredictPCRs() should have failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+// The tests using a TPM are in package cel_test, as the client package, which
+// they use to read PCRs, imports cel.
+package cel_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/cel"
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+var measuredHashes = []crypto.Hash{crypto.SHA1, crypto.SHA256}
+
+func TestCELEncodingDecoding(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	eventLog := &cel.CEL{}
+	events := []cel.TLV{{1, []byte("first event")}, {2, []byte("second event")}, {1, nil}}
+	for _, event := range events {
+		if err := eventLog.AppendEvent(rwc, test.DebugPCR, measuredHashes, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := eventLog.AppendEvent(rwc, test.ApplicationPCR, measuredHashes, cel.TLV{5, []byte("app event")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := eventLog.EncodeCEL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := cel.DecodeToCEL(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Records) != len(eventLog.Records) {
+		t.Fatalf("decoded %d records, want %d", len(decoded.Records), len(eventLog.Records))
+	}
+	for i, record := range decoded.Records {
+		want := eventLog.Records[i]
+		if record.RecNum != want.RecNum || record.PCR != want.PCR {
+			t.Errorf("record %d: got (recnum %d, pcr %d), want (recnum %d, pcr %d)",
+				i, record.RecNum, record.PCR, want.RecNum, want.PCR)
+		}
+		if !reflect.DeepEqual(record.Digests, want.Digests) {
+			t.Errorf("record %d: got digests %v, want %v", i, record.Digests, want.Digests)
+		}
+		if record.Content.Type != want.Content.Type || !bytes.Equal(record.Content.Value, want.Content.Value) {
+			t.Errorf("record %d: got content %v, want %v", i, record.Content, want.Content)
+		}
+	}
+}
+
+func TestCELReplay(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	// Replay starts from all-zero PCRs, which is only the case for a fresh TPM.
+	initial, err := tpm2.ReadPCR(rwc, test.DebugPCR, tpm2.AlgSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(initial, make([]byte, len(initial))) {
+		t.Skipf("PCR%d has already been extended", test.DebugPCR)
+	}
+
+	eventLog := &cel.CEL{}
+	for _, event := range []cel.TLV{{1, []byte("first")}, {1, []byte("second")}} {
+		if err := eventLog.AppendEvent(rwc, test.DebugPCR, measuredHashes, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, hash := range []tpm2.Algorithm{tpm2.AlgSHA1, tpm2.AlgSHA256} {
+		sel := tpm2.PCRSelection{Hash: hash, PCRs: []int{test.DebugPCR}}
+		pcrs, err := client.ReadPCRs(rwc, sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := eventLog.Replay(pcrs); err != nil {
+			t.Errorf("replay failed for %v: %v", hash, err)
+		}
+	}
+
+	badPCRs := &pb.PCRs{Hash: pb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{uint32(test.DebugPCR): make([]byte, 32)}}
+	if err := eventLog.Replay(badPCRs); err == nil {
+		t.Error("replay against wrong PCR values should have failed")
+	}
+	missingPCRs := &pb.PCRs{Hash: pb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{}}
+	if err := eventLog.Replay(missingPCRs); err == nil {
+		t.Error("replay without the measured PCR should have failed")
+	}
+}
+
+func TestMeasureConfigFiles(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	initial, err := tpm2.ReadPCR(rwc, test.DebugPCR, tpm2.AlgSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(initial, make([]byte, len(initial))) {
+		t.Skipf("PCR%d has already been extended", test.DebugPCR)
+	}
+
+	dir := t.TempDir()
+	present := filepath.Join(dir, "daemon.json")
+	if err := ioutil.WriteFile(present, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{present, filepath.Join(dir, "missing")}
+	eventLog := &cel.CEL{}
+	if err := eventLog.MeasureConfigFiles(rwc, test.DebugPCR, measuredHashes, paths); err != nil {
+		t.Fatal(err)
+	}
+	if len(eventLog.Records) != len(paths) {
+		t.Fatalf("got %d records, want %d", len(eventLog.Records), len(paths))
+	}
+	for i, record := range eventLog.Records {
+		event, err := cel.ParseConfigFileEvent(record.Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Path != paths[i] {
+			t.Errorf("record %d is for %q, want %q", i, event.Path, paths[i])
+		}
+	}
+
+	pcrs, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{test.DebugPCR}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eventLog.Replay(pcrs); err != nil {
+		t.Errorf("replay failed: %v", err)
+	}
+}
+
+// auditRecords is an AuditSource returning a fixed list of records.
+type auditRecords []cel.AuditRecord
+
+func (r *auditRecords) Receive() (cel.AuditRecord, error) {
+	if len(*r) == 0 {
+		return cel.AuditRecord{}, io.EOF
+	}
+	record := (*r)[0]
+	*r = (*r)[1:]
+	return record, nil
+}
+
+// execRecords returns the audit records of an execution of exe.
+func execRecords(serial int, success string, exe string) []cel.AuditRecord {
+	id := fmt.Sprintf("audit(1700000000.000:%d): ", serial)
+	return []cel.AuditRecord{
+		{cel.AuditSyscall, id + fmt.Sprintf("arch=c000003e syscall=59 success=%s exit=0 comm=\"sh\" exe=%s key=(null)", success, exe)},
+		{cel.AuditExecve, id + `argc=1 a0="sh"`},
+		{1307, id + `cwd="/"`},
+		{cel.AuditEOE, id},
+	}
+}
+
+func TestExecCollector(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	initial, err := tpm2.ReadPCR(rwc, test.DebugPCR, tpm2.AlgSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(initial, make([]byte, len(initial))) {
+		t.Skipf("PCR%d has already been extended", test.DebugPCR)
+	}
+
+	dir := t.TempDir()
+	payload := filepath.Join(dir, "payload")
+	spaced := filepath.Join(dir, "pay load")
+	for _, path := range []string{payload, spaced} {
+		if err := ioutil.WriteFile(path, []byte(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var source auditRecords
+	source = append(source, execRecords(1, "yes", `"`+payload+`"`)...)
+	// Executing the same file again is not measured again.
+	source = append(source, execRecords(2, "yes", `"`+payload+`"`)...)
+	// Allowlisted and failed executions, and other syscalls, are not measured.
+	source = append(source, execRecords(3, "yes", `"/usr/bin/id"`)...)
+	source = append(source, execRecords(4, "yes", `"/bin/sh"`)...)
+	source = append(source, execRecords(5, "no", `"`+payload+"2\"")...)
+	source = append(source, cel.AuditRecord{cel.AuditSyscall, `audit(1700000000.000:6): syscall=2 success=yes exe="` + payload + `"`}, cel.AuditRecord{cel.AuditEOE, "audit(1700000000.000:6): "})
+	// Paths with spaces are hex encoded, and an event may lack its EOE record.
+	spacedRecords := execRecords(7, "yes", hex.EncodeToString([]byte(spaced)))
+	source = append(source, spacedRecords[:len(spacedRecords)-1]...)
+	source = append(source, cel.AuditRecord{1305, "audit(1700000000.000:8): op=set"})
+
+	var measured []cel.ExecEvent
+	collector := &cel.ExecCollector{
+		TPM:       rwc,
+		Log:       &cel.CEL{},
+		PCR:       test.DebugPCR,
+		HashAlgos: measuredHashes,
+		Allowlist: []string{"/usr/bin/", "/bin/sh"},
+		OnMeasure: func(event cel.ExecEvent) { measured = append(measured, event) },
+	}
+	if err := collector.Run(&source); err != io.EOF {
+		t.Fatalf("Run() returned %v, want %v", err, io.EOF)
+	}
+	// A changed file is measured again.
+	if err := ioutil.WriteFile(payload, []byte("changed"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	source = execRecords(9, "yes", `"`+payload+`"`)
+	if err := collector.Run(&source); err != io.EOF {
+		t.Fatalf("Run() returned %v, want %v", err, io.EOF)
+	}
+
+	wantPaths := []string{payload, spaced, payload}
+	if len(measured) != len(wantPaths) {
+		t.Fatalf("measured %v, want executions of %v", measured, wantPaths)
+	}
+	for i, event := range measured {
+		if event.Path != wantPaths[i] {
+			t.Errorf("execution %d is of %q, want %q", i, event.Path, wantPaths[i])
+		}
+	}
+	changed := sha256.Sum256([]byte("changed"))
+	if !bytes.Equal(measured[2].Digest, changed[:]) {
+		t.Errorf("changed file measured with digest %x, want %x", measured[2].Digest, changed)
+	}
+
+	pcrs, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{test.DebugPCR}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = collector.WithLog(func(log *cel.CEL) error {
+		if len(log.Records) != len(measured) {
+			t.Errorf("CEL has %d records, want %d", len(log.Records), len(measured))
+		}
+		return log.Replay(pcrs)
+	})
+	if err != nil {
+		t.Errorf("replay failed: %v", err)
+	}
+
+	collector.Allowlist = []string{"["}
+	source = execRecords(10, "yes", `"`+payload+`"`)
+	if err := collector.Run(&source); err == nil || err == io.EOF {
+		t.Errorf("Run() with an invalid allowlist pattern returned %v", err)
+	}
+}
+
+func TestMeasureKernelSecurity(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	initial, err := tpm2.ReadPCR(rwc, test.DebugPCR, tpm2.AlgSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(initial, make([]byte, len(initial))) {
+		t.Skipf("PCR%d has already been extended", test.DebugPCR)
+	}
+
+	// The settings in the order they are measured.
+	kernelSettings := []cel.KernelSetting{cel.KernelLockdown, cel.KernelModuleSigEnforce, cel.KernelKexecLoadDisabled}
+	eventLog := &cel.CEL{}
+	if err := eventLog.MeasureKernelSecurity(rwc, test.DebugPCR, measuredHashes); err != nil {
+		t.Fatal(err)
+	}
+	if len(eventLog.Records) != len(kernelSettings) {
+		t.Fatalf("got %d records, want %d", len(eventLog.Records), len(kernelSettings))
+	}
+	for i, record := range eventLog.Records {
+		event, err := cel.ParseKernelSecurityEvent(record.Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Setting != kernelSettings[i] {
+			t.Errorf("record %d is for %v, want %v", i, event.Setting, kernelSettings[i])
+		}
+	}
+
+	pcrs, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{test.DebugPCR}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eventLog.Replay(pcrs); err != nil {
+		t.Errorf("replay failed: %v", err)
+	}
+}
+
+func TestKeyJournal(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	defer tpm2.NVUndefineSpace(rwc, "", tpm2.HandleOwner, tpmutil.Handle(cel.KeyJournalIndex))
+
+	journal, err := cel.LoadKeyJournal(rwc, cel.KeyJournalIndex, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []cel.KeyEvent{
+		{Action: cel.KeyCreated, Name: []byte{0, 0xb, 1}, Label: "ak"},
+		{Action: cel.KeyPersisted, Name: []byte{0, 0xb, 1}, Handle: 0x81000001, Label: "ak"},
+	}
+	for _, event := range events {
+		if err := journal.Record(event); err != nil {
+			t.Fatalf("Record() failed: %v", err)
+		}
+	}
+	// A PCR event in the same log is not part of the journal.
+	if err := journal.Log.AppendEvent(rwc, test.DebugPCR, measuredHashes, cel.TLV{1, []byte("event")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var encoded bytes.Buffer
+	if err := journal.WithLog(func(log *cel.CEL) error { return log.EncodeCEL(&encoded) }); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := cel.DecodeToCEL(&encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Records[0].NVIndex != cel.KeyJournalIndex || decoded.Records[2].NVIndex != 0 {
+		t.Errorf("decoded NV indexes %#x, %#x, want %#x, 0", decoded.Records[0].NVIndex, decoded.Records[2].NVIndex, cel.KeyJournalIndex)
+	}
+	pcrs, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{test.DebugPCR}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Replay(pcrs); err == nil {
+		t.Error("Replay() of a log with NV records should fail")
+	}
+	for i, event := range events {
+		got, err := cel.ParseKeyEvent(decoded.Records[i].Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, event) {
+			t.Errorf("record %d = %+v, want %+v", i, got, event)
+		}
+	}
+
+	// Reloading the journal checks the log against the index.
+	reloaded, err := cel.LoadKeyJournal(rwc, cel.KeyJournalIndex, &decoded)
+	if err != nil {
+		t.Fatalf("LoadKeyJournal() of the saved log failed: %v", err)
+	}
+	if err := reloaded.Record(cel.KeyEvent{Action: cel.KeyEvicted, Name: []byte{0, 0xb, 1}, Handle: 0x81000001}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cel.LoadKeyJournal(rwc, cel.KeyJournalIndex, &cel.CEL{}); err == nil {
+		t.Error("LoadKeyJournal() of a truncated log succeeded, want error")
+	}
+	contents, err := tpm2.NVReadEx(rwc, tpmutil.Handle(cel.KeyJournalIndex), tpmutil.Handle(cel.KeyJournalIndex), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Log.ReplayNV(cel.KeyJournalIndex, crypto.SHA256, contents); err != nil {
+		t.Errorf("ReplayNV() failed: %v", err)
+	}
+}
+
+func TestPredictPCRsMatchesTPM(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	planned := []cel.Event{
+		{test.DebugPCR, cel.TLV{1, []byte("first")}},
+		{test.ApplicationPCR, cel.TLV{2, []byte("second")}},
+		{test.DebugPCR, cel.TLV{3, []byte("third")}},
+		{test.DebugPCR, cel.TLV{1, nil}},
+	}
+	for _, hash := range []tpm2.Algorithm{tpm2.AlgSHA1, tpm2.AlgSHA256} {
+		sel := tpm2.PCRSelection{Hash: hash, PCRs: []int{test.DebugPCR, test.ApplicationPCR}}
+		current, err := client.ReadPCRs(rwc, sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		predicted, err := cel.PredictPCRs(current, planned)
+		if err != nil {
+			t.Fatalf("PredictPCRs() failed: %v", err)
+		}
+
+		eventLog := &cel.CEL{}
+		for _, event := range planned {
+			if err := eventLog.AppendEvent(rwc, event.PCR, measuredHashes, event.Content); err != nil {
+				t.Fatal(err)
+			}
+		}
+		actual, err := client.ReadPCRs(rwc, sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for pcr, want := range actual.GetPcrs() {
+			if got := predicted.GetPcrs()[pcr]; !bytes.Equal(got, want) {
+				t.Errorf("%v PCR%d: predicted %x, TPM has %x", hash, pcr, got, want)
+			}
+		}
+	}
+}
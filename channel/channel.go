@@ -0,0 +1,262 @@
+// Package channel establishes a shared key between two machines with TPMs,
+// which is only available if both machines are running in verified states.
+//
+// Each peer runs a Handshake, exchanging three messages with the other peer
+// over any transport:
+//  1. A ChannelHello, containing a nonce and the peer's EK.
+//  2. An Attestation, bound to both peers' nonces.
+//  3. An EncryptedCredential, only created once the other peer's Attestation
+//     has been verified. It contains a secret which can only be recovered by
+//     the TPM holding both the other peer's EK and the AK which signed its
+//     Attestation (see server.GenerateChallenge).
+//
+// The channel key is derived from the secrets generated by both peers, so it
+// can only be computed by machines which have had their attestations verified
+// by each other, and whose AKs are resident in TPMs with trusted EKs.
+package channel
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm-tools/server"
+	"github.com/google/go-tpm/tpm2"
+)
+
+const (
+	nonceSize = 32
+	// KeySize is the size (in bytes) of the derived channel key.
+	KeySize = 32
+
+	attestLabel = "GOTPM CHANNEL ATTESTATION\x00"
+	keyLabel    = "GOTPM CHANNEL KEY"
+)
+
+// Config configures the local side of a Handshake.
+type Config struct {
+	// The local TPM's EK, and an AK in the same TPM. The AK is used to attest
+	// to the peer, and the EK is used to recover the peer's secret.
+	EK *client.Key
+	AK *client.Key
+	// The local TPM's EK certificate, DER encoded. If set, it is sent to the
+	// peer so that it can verify our EK against its EK roots.
+	EKCert []byte
+	// Options for verifying the peer's Attestation. The Nonce, TrustedAKs,
+	// and EKCert fields are set by the Handshake. All other fields (such as
+	// EKRoots) are used as provided.
+	VerifyOpts server.VerifyOpts
+	// EKs of peers which are trusted without an EK certificate. If the peer
+	// sends an EK certificate, its EK does not need to be in this list.
+	TrustedEKs []crypto.PublicKey
+	// If non-nil, the peer's MachineState must satisfy this Policy.
+	Policy *pb.Policy
+}
+
+// Handshake is one side of a channel handshake. Its methods must be called in
+// order, each one taking the corresponding message from the peer.
+type Handshake struct {
+	config Config
+	nonce  []byte
+	secret []byte
+
+	peerHello *pb.ChannelHello
+	peerEK    crypto.PublicKey
+	peerState *pb.MachineState
+}
+
+// NewHandshake starts a new handshake using the provided Config.
+func NewHandshake(config Config) (*Handshake, error) {
+	if config.EK == nil || config.AK == nil {
+		return nil, fmt.Errorf("both an EK and an AK must be provided")
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return &Handshake{config: config, nonce: nonce}, nil
+}
+
+// Hello returns the first message to send to the peer.
+func (h *Handshake) Hello() (*pb.ChannelHello, error) {
+	ekPub, err := x509.MarshalPKIXPublicKey(h.config.EK.PublicKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode EK: %w", err)
+	}
+	return &pb.ChannelHello{
+		Nonce:  h.nonce,
+		EkPub:  ekPub,
+		EkCert: h.config.EKCert,
+	}, nil
+}
+
+// Attest takes the peer's ChannelHello, and returns the Attestation to send
+// to the peer.
+func (h *Handshake) Attest(peerHello *pb.ChannelHello) (*pb.Attestation, error) {
+	if h.peerHello != nil {
+		return nil, fmt.Errorf("peer hello already received")
+	}
+	if len(peerHello.GetNonce()) != nonceSize {
+		return nil, fmt.Errorf("peer nonce has size %d, want %d", len(peerHello.GetNonce()), nonceSize)
+	}
+	// A peer reflecting our own hello back to us must not be accepted.
+	if bytes.Equal(peerHello.GetNonce(), h.nonce) {
+		return nil, fmt.Errorf("peer nonce matches our nonce")
+	}
+	peerEK, err := x509.ParsePKIXPublicKey(peerHello.GetEkPub())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse peer EK: %w", err)
+	}
+	if err := h.checkEKTrusted(peerEK, peerHello.GetEkCert()); err != nil {
+		return nil, err
+	}
+	h.peerHello = peerHello
+	h.peerEK = peerEK
+
+	attestation, err := h.config.AK.Attest(client.AttestOpts{
+		Nonce: attestationNonce(peerHello.GetNonce(), h.nonce),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attest: %w", err)
+	}
+	return attestation, nil
+}
+
+// Challenge takes the peer's Attestation, and verifies it. If the peer is in
+// an acceptable state, the EncryptedCredential to send to the peer is
+// returned.
+func (h *Handshake) Challenge(peerAttestation *pb.Attestation) (*tpmpb.EncryptedCredential, error) {
+	if h.peerHello == nil {
+		return nil, fmt.Errorf("peer hello not yet received")
+	}
+	if h.secret != nil {
+		return nil, fmt.Errorf("peer attestation already received")
+	}
+	akPubArea, err := tpm2.DecodePublic(peerAttestation.GetAkPub())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode peer AK public area: %w", err)
+	}
+	// The AK must only sign TPM-generated data, or the peer could sign a
+	// "quote" of any state with a software key loaded into its TPM.
+	if err := server.CheckAKPublicArea(akPubArea); err != nil {
+		return nil, fmt.Errorf("peer AK is not suitable: %w", err)
+	}
+	akPub, err := akPubArea.Key()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get peer AK public key: %w", err)
+	}
+	akName, err := akPubArea.Name()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute peer AK name: %w", err)
+	}
+
+	// We trust the peer's AK here, as the credential we issue below can only
+	// be recovered if the AK is in the same TPM as the (trusted) peer EK.
+	opts := h.config.VerifyOpts
+	opts.Nonce = attestationNonce(h.nonce, h.peerHello.GetNonce())
+	opts.TrustedAKs = []crypto.PublicKey{akPub}
+	opts.EKCert = h.peerHello.GetEkCert()
+	state, err := server.VerifyAttestation(peerAttestation, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify peer attestation: %w", err)
+	}
+	if h.config.Policy != nil {
+		result, err := server.EvaluatePolicy(state, h.config.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("peer does not satisfy policy: %w", err)
+		}
+		result.Record(state)
+	}
+
+	challenge, secret, err := server.GenerateChallenge(h.peerEK, akName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	h.peerState = state
+	h.secret = secret
+	return challenge, nil
+}
+
+// Finish takes the peer's EncryptedCredential, and returns the derived channel
+// key. Both peers derive the same key if the handshake succeeded. The key
+// should be used with an AEAD cipher (such as AES-GCM), so any mismatch is
+// detected when the first message is received.
+func (h *Handshake) Finish(peerChallenge *tpmpb.EncryptedCredential) ([]byte, error) {
+	if h.secret == nil {
+		return nil, fmt.Errorf("peer attestation not yet verified")
+	}
+	peerSecret, err := h.config.EK.ActivateCredential(h.config.AK, peerChallenge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover peer secret: %w", err)
+	}
+
+	// Order the secrets and nonces consistently, so both peers derive the
+	// same key regardless of which one is computing it.
+	lowNonce, highNonce := h.nonce, h.peerHello.GetNonce()
+	lowSecret, highSecret := h.secret, peerSecret
+	if bytes.Compare(lowNonce, highNonce) > 0 {
+		lowNonce, highNonce = highNonce, lowNonce
+		lowSecret, highSecret = highSecret, lowSecret
+	}
+	key, err := tpm2.KDFa(
+		tpm2.AlgSHA256,
+		append(append([]byte{}, lowSecret...), highSecret...),
+		keyLabel,
+		lowNonce,
+		highNonce,
+		KeySize*8)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive channel key: %w", err)
+	}
+	return key, nil
+}
+
+// PeerState returns the peer's verified MachineState, or nil if the peer's
+// Attestation has not yet been verified.
+func (h *Handshake) PeerState() *pb.MachineState {
+	return h.peerState
+}
+
+func (h *Handshake) checkEKTrusted(ek crypto.PublicKey, ekCert []byte) error {
+	// A peer's EK certificate is verified along with its Attestation, which
+	// accepts the same formats as ParseEKCertificate.
+	if len(ekCert) != 0 {
+		cert, err := server.ParseEKCertificate(ekCert)
+		if err != nil {
+			return fmt.Errorf("failed to parse peer EK certificate: %w", err)
+		}
+		if !publicKeysEqual(cert.PublicKey, ek) {
+			return fmt.Errorf("peer EK does not match its EK certificate: %w", cert.CheckEK(ek))
+		}
+		return nil
+	}
+	for _, trusted := range h.config.TrustedEKs {
+		if publicKeysEqual(trusted, ek) {
+			return nil
+		}
+	}
+	return fmt.Errorf("peer EK is not trusted")
+}
+
+func publicKeysEqual(k1, k2 crypto.PublicKey) bool {
+	key, ok := k1.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(k2)
+}
+
+// attestationNonce binds an attestation to the verifier's nonce (for
+// freshness) and the attester's nonce, so that attestations from one
+// handshake cannot be used in another.
+func attestationNonce(verifierNonce, attesterNonce []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(attestLabel))
+	h.Write(verifierNonce)
+	h.Write(attesterNonce)
+	return h.Sum(nil)
+}
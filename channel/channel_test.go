@@ -0,0 +1,210 @@
+package channel
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// runHandshake exchanges messages between two Handshakes, returning the keys
+// derived by each side.
+func runHandshake(t *testing.T, a, b *Handshake) ([]byte, []byte) {
+	t.Helper()
+	helloA, err := a.Hello()
+	if err != nil {
+		t.Fatal(err)
+	}
+	helloB, err := b.Hello()
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestA, err := a.Attest(helloB)
+	if err != nil {
+		t.Fatalf("peer A failed to attest: %v", err)
+	}
+	attestB, err := b.Attest(helloA)
+	if err != nil {
+		t.Fatalf("peer B failed to attest: %v", err)
+	}
+	challengeA, err := a.Challenge(attestB)
+	if err != nil {
+		t.Fatalf("peer A failed to verify peer B: %v", err)
+	}
+	challengeB, err := b.Challenge(attestA)
+	if err != nil {
+		t.Fatalf("peer B failed to verify peer A: %v", err)
+	}
+	keyA, err := a.Finish(challengeB)
+	if err != nil {
+		t.Fatalf("peer A failed to finish: %v", err)
+	}
+	keyB, err := b.Finish(challengeA)
+	if err != nil {
+		t.Fatalf("peer B failed to finish: %v", err)
+	}
+	return keyA, keyB
+}
+
+func TestHandshake(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	// Both peers share the simulator, so they share an EK but not an AK.
+	ek, err := client.EndorsementKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ek.Close()
+	akA, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer akA.Close()
+	akB, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer akB.Close()
+
+	trusted := []crypto.PublicKey{ek.PublicKey()}
+	a, err := NewHandshake(Config{EK: ek, AK: akA, TrustedEKs: trusted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewHandshake(Config{EK: ek, AK: akB, TrustedEKs: trusted, Policy: &pb.Policy{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyA, keyB := runHandshake(t, a, b)
+	if !bytes.Equal(keyA, keyB) {
+		t.Errorf("peers derived different keys: %x and %x", keyA, keyB)
+	}
+	if len(keyA) != KeySize {
+		t.Errorf("got key of size %d, want %d", len(keyA), KeySize)
+	}
+	if a.PeerState() == nil || b.PeerState() == nil {
+		t.Error("PeerState() should be set after a successful handshake")
+	}
+
+	// A new handshake between the same peers derives a different key.
+	a2, err := NewHandshake(Config{EK: ek, AK: akA, TrustedEKs: trusted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := NewHandshake(Config{EK: ek, AK: akB, TrustedEKs: trusted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyA2, _ := runHandshake(t, a2, b2); bytes.Equal(keyA, keyA2) {
+		t.Error("separate handshakes derived the same key")
+	}
+}
+
+func TestHandshakeFailures(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ek, err := client.EndorsementKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ek.Close()
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	trusted := []crypto.PublicKey{ek.PublicKey()}
+
+	newHandshake := func(config Config) *Handshake {
+		h, err := NewHandshake(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	hello := func(h *Handshake) *pb.ChannelHello {
+		msg, err := h.Hello()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	t.Run("UntrustedEK", func(t *testing.T) {
+		a := newHandshake(Config{EK: ek, AK: ak})
+		b := newHandshake(Config{EK: ek, AK: ak, TrustedEKs: trusted})
+		if _, err := a.Attest(hello(b)); err == nil {
+			t.Error("Attest() should fail for an untrusted peer EK")
+		}
+	})
+	t.Run("ReflectedHello", func(t *testing.T) {
+		a := newHandshake(Config{EK: ek, AK: ak, TrustedEKs: trusted})
+		if _, err := a.Attest(hello(a)); err == nil {
+			t.Error("Attest() should fail for our own hello")
+		}
+	})
+	t.Run("ReplayedAttestation", func(t *testing.T) {
+		a := newHandshake(Config{EK: ek, AK: ak, TrustedEKs: trusted})
+		b := newHandshake(Config{EK: ek, AK: ak, TrustedEKs: trusted})
+		c := newHandshake(Config{EK: ek, AK: ak, TrustedEKs: trusted})
+		// An attestation made for c cannot be used in a handshake with a.
+		attestation, err := b.Attest(hello(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.Attest(hello(b)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.Challenge(attestation); err == nil {
+			t.Error("Challenge() should fail for an attestation from another handshake")
+		}
+	})
+	t.Run("UnrestrictedSigningKey", func(t *testing.T) {
+		// An unrestricted key can sign arbitrary data, including fake quotes,
+		// so an attestation claiming to use one must be rejected.
+		template := client.AKTemplateRSA()
+		template.Attributes &^= tpm2.FlagRestricted
+		signer, err := client.NewKey(rwc, tpm2.HandleEndorsement, template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer signer.Close()
+		signerPub, err := signer.PublicArea().Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a := newHandshake(Config{EK: ek, AK: ak, TrustedEKs: trusted})
+		b := newHandshake(Config{EK: ek, AK: ak, TrustedEKs: trusted})
+		if _, err := a.Attest(hello(b)); err != nil {
+			t.Fatal(err)
+		}
+		attestation, err := b.Attest(hello(a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestation.AkPub = signerPub
+		if _, err := a.Challenge(attestation); err == nil {
+			t.Error("Challenge() should fail for an unrestricted signing key")
+		}
+	})
+	t.Run("OutOfOrder", func(t *testing.T) {
+		a := newHandshake(Config{EK: ek, AK: ak, TrustedEKs: trusted})
+		if _, err := a.Challenge(&pb.Attestation{}); err == nil {
+			t.Error("Challenge() should fail before Attest()")
+		}
+		if _, err := a.Finish(nil); err == nil {
+			t.Error("Finish() should fail before Challenge()")
+		}
+	})
+	t.Run("MissingKeys", func(t *testing.T) {
+		if _, err := NewHandshake(Config{EK: ek}); err == nil {
+			t.Error("NewHandshake() should fail without an AK")
+		}
+	})
+}
@@ -1,9 +1,14 @@
 package client
 
 import (
+	"crypto"
+	"crypto/x509"
 	"fmt"
 
+	"github.com/google/go-tpm-tools/internal"
 	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
 )
 
 // AttestOpts allows for customizing the functionality of Attest.
@@ -19,12 +24,55 @@ type AttestOpts struct {
 	// attestation protocols:
 	// https://citeseerx.ist.psu.edu/viewdoc/download?doi=10.1.1.70.4562&rep=rep1&type=pdf
 	Nonce []byte
+	// The hash algorithm (SHA-256, SHA-384 or SHA-512) used to bind the Nonce
+	// to the quotes. If zero, the quotes sign the Nonce itself, which must then
+	// be no larger than the TPM's largest digest (see Key.Quote). Otherwise,
+	// they sign the digest of the Nonce, so nonces of any length can be used.
+	// The algorithm is recorded in the attestation's nonce_hash, and the
+	// verifier must expect the same algorithm (see server.VerifyOpts).
+	NonceHash crypto.Hash
+	// An optional encoded Canonical Event Log (see the cel package) to include
+	// in the attestation, such as one recording the kernel's security settings.
+	// The PCRs it extends are covered by the attestation's quotes.
+	CanonicalEventLog []byte
+	// An optional EK certificate of the TPM to include in the attestation,
+	// DER encoded or as read from the TPM's NVRAM, so the verifier can check
+	// the TPM's manufacturer without being sent the certificate separately.
+	EKCert []byte
+	// Optional DER encoded certificates issuing EKCert, starting with its
+	// issuer, to include in the attestation's intermediate_certs.
+	IntermediateCerts [][]byte
+	// If set, the certificates issuing EKCert (or the last of the
+	// IntermediateCerts) are fetched over HTTP with FetchIssuingCertificates,
+	// and added to the attestation's intermediate_certs, so that a verifier
+	// without network access can verify the certificate. Attest fails if they
+	// cannot be fetched.
+	FetchIntermediates *FetchOpts
+	// If set, the attestation includes the values which change when the TPM
+	// is cleared (see GetClearIndicators), so the verifier can detect that the
+	// TPM was cleared since an earlier attestation.
+	ClearIndicators bool
+	// If nonzero, the NV extend index of a key journal (see cel.KeyJournal),
+	// whose contents are certified by this key over the nonce, and included in
+	// the attestation's key_journal with KeyJournalLog, the journal's encoded
+	// CEL. The index must have been extended at least once.
+	KeyJournalIndex uint32
+	KeyJournalLog   []byte
 }
 
-// Attest generates an Attestation containing the TCG Event Log and a Quote over
-// all PCR banks. The provided nonce can be used to guarantee freshness of the
-// attestation. This function will return an error if the key is not a
-// restricted signing key.
+// Attester generates Attestations. It is implemented by Key. Code which only
+// needs to attest can depend on an Attester, so that it can be tested with a
+// fake (such as testutil.Attester) instead of a TPM.
+type Attester interface {
+	Attest(opts AttestOpts) (*pb.Attestation, error)
+}
+
+var _ Attester = (*Key)(nil)
+
+// Attest generates an Attestation containing the TCG Event Log, a Quote over
+// all PCR banks, and the TPM's capabilities (see GetCapabilities). The
+// provided nonce can be used to guarantee freshness of the attestation. This
+// function will return an error if the key is not a restricted signing key.
 //
 // AttestOpts is used for additional configuration of the Attestation process.
 // This is primarily used to pass the attestation's nonce:
@@ -34,17 +82,28 @@ func (k *Key) Attest(opts AttestOpts) (*pb.Attestation, error) {
 	if len(opts.Nonce) == 0 {
 		return nil, fmt.Errorf("provided nonce must not be empty")
 	}
+	extraData, err := internal.NonceExtraData(opts.Nonce, opts.NonceHash)
+	if err != nil {
+		return nil, err
+	}
 	sels, err := implementedPCRs(k.rw)
 	if err != nil {
 		return nil, err
 	}
 
 	attestation := pb.Attestation{}
+	if opts.NonceHash != 0 {
+		alg, err := tpm2.HashToAlgorithm(opts.NonceHash)
+		if err != nil {
+			return nil, err
+		}
+		attestation.NonceHash = tpmpb.HashAlgo(alg)
+	}
 	if attestation.AkPub, err = k.PublicArea().Encode(); err != nil {
 		return nil, fmt.Errorf("failed to encode public area: %w", err)
 	}
 	for _, sel := range sels {
-		quote, err := k.Quote(sel, opts.Nonce)
+		quote, err := k.Quote(sel, extraData)
 		if err != nil {
 			return nil, err
 		}
@@ -53,5 +112,75 @@ func (k *Key) Attest(opts AttestOpts) (*pb.Attestation, error) {
 	if attestation.EventLog, err = GetEventLog(k.rw); err != nil {
 		return nil, fmt.Errorf("failed to retrieve TCG Event Log: %w", err)
 	}
+	attestation.CanonicalEventLog = opts.CanonicalEventLog
+	caps, err := GetCapabilities(k.rw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TPM capabilities: %w", err)
+	}
+	attestation.Capabilities = caps.Proto()
+	if err := addEKCertChain(&attestation, opts); err != nil {
+		return nil, err
+	}
+	if opts.ClearIndicators {
+		if attestation.ClearIndicators, err = GetClearIndicators(k.rw); err != nil {
+			return nil, fmt.Errorf("failed to get TPM clear indicators: %w", err)
+		}
+	}
+	if opts.KeyJournalIndex != 0 {
+		if attestation.KeyJournal, err = k.certifyKeyJournal(opts.KeyJournalIndex, opts.KeyJournalLog, extraData); err != nil {
+			return nil, err
+		}
+	}
 	return &attestation, nil
 }
+
+// certifyKeyJournal certifies the contents of a key journal's NV index.
+func (k *Key) certifyKeyJournal(index uint32, log []byte, extraData []byte) (*pb.KeyJournal, error) {
+	n, err := OpenNVIndex(k.rw, index, tpm2.PCRSelection{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key journal: %w", err)
+	}
+	if n.Public().Attributes&tpm2.AttrWritten == 0 {
+		return nil, fmt.Errorf("key journal NV index 0x%x has no events", index)
+	}
+	certification, err := k.CertifyNV(n, extraData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to certify key journal: %w", err)
+	}
+	return &pb.KeyJournal{Certification: certification, Log: log}, nil
+}
+
+// addEKCertChain adds the EK certificate and its issuing certificates to an
+// attestation.
+func addEKCertChain(attestation *pb.Attestation, opts AttestOpts) error {
+	if len(opts.EKCert) == 0 {
+		if len(opts.IntermediateCerts) != 0 || opts.FetchIntermediates != nil {
+			return fmt.Errorf("intermediate certificates require an EK certificate")
+		}
+		return nil
+	}
+	der, err := internal.TrimNVCertificate(opts.EKCert)
+	if err != nil {
+		return fmt.Errorf("failed to parse EK certificate: %w", err)
+	}
+	attestation.EkCert = der
+	attestation.IntermediateCerts = append([][]byte(nil), opts.IntermediateCerts...)
+	if opts.FetchIntermediates == nil {
+		return nil
+	}
+	last := der
+	if len(opts.IntermediateCerts) != 0 {
+		last = opts.IntermediateCerts[len(opts.IntermediateCerts)-1]
+	}
+	cert, err := x509.ParseCertificate(last)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	certs, urls, err := FetchIssuingCertificates(cert, *opts.FetchIntermediates)
+	if err != nil {
+		return fmt.Errorf("failed to fetch EK certificate chain: %w", err)
+	}
+	attestation.IntermediateCerts = append(attestation.IntermediateCerts, certs...)
+	attestation.ChainFetchedFrom = urls
+	return nil
+}
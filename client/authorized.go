@@ -0,0 +1,132 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/policy"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// authorizedPolicy returns the auth policy of data sealed to an authority,
+// TPM2_PolicyAuthorize with the authority's key and policyRef, and its record
+// in the sealed data.
+func authorizedPolicy(authority tpm2.Public, policyRef []byte) ([]byte, *pb.AuthorizedPolicy, error) {
+	if _, err := internal.GetSigningHashAlg(authority); err != nil {
+		return nil, nil, fmt.Errorf("authority is not a signing key: %w", err)
+	}
+	name, err := policy.ObjectName(authority)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth, err := policy.New(SessionHashAlg).Authorize(name, policyRef).Digest()
+	if err != nil {
+		return nil, nil, err
+	}
+	encoded, err := authority.Encode()
+	if err != nil {
+		return nil, nil, err
+	}
+	return auth, &pb.AuthorizedPolicy{Authority: encoded, PolicyRef: policyRef}, nil
+}
+
+// authorizedSession satisfies the policy of data sealed to an authority, with
+// the first of the authority's signed PCR policies matching the current PCR
+// values.
+type authorizedSession struct {
+	rw        io.ReadWriter
+	session   tpmutil.Handle
+	authority tpm2.Public
+	policyRef []byte
+	policies  []*pb.SignedPCRPolicy
+}
+
+func newAuthorizedSession(rw io.ReadWriter, authorized *pb.AuthorizedPolicy, policies []*pb.SignedPCRPolicy) (session, error) {
+	if len(policies) == 0 {
+		return nil, errors.New("data is sealed to an authority, but no signed PCR policies were provided")
+	}
+	authority, err := tpm2.DecodePublic(authorized.GetAuthority())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode authority: %w", err)
+	}
+	session, err := startAuthSession(rw)
+	return authorizedSession{rw, session, authority, authorized.GetPolicyRef(), policies}, err
+}
+
+func (s authorizedSession) Auth() (auth tpm2.AuthCommand, err error) {
+	signed, err := s.matchingPolicy()
+	if err != nil {
+		return
+	}
+	if err = tpm2.PolicyPCR(s.rw, s.session, nil, internal.PCRSelection(signed.GetPcrs())); err != nil {
+		return
+	}
+	approved, err := tpm2.PolicyGetDigest(s.rw, s.session)
+	if err != nil {
+		return
+	}
+	ticket, err := s.verifyApproval(approved, signed.GetSignature())
+	if err != nil {
+		return
+	}
+	name, err := policy.ObjectName(s.authority)
+	if err != nil {
+		return
+	}
+	if _, err = internal.RunCommand(s.rw, internal.CmdPolicyAuthorize, []tpmutil.Handle{s.session}, nil,
+		tpmutil.U16Bytes(approved), tpmutil.U16Bytes(s.policyRef), tpmutil.U16Bytes(name), ticket); err != nil {
+		return auth, fmt.Errorf("PolicyAuthorize failed: %w", err)
+	}
+	return tpm2.AuthCommand{Session: s.session, Attributes: tpm2.AttrContinueSession}, nil
+}
+
+// matchingPolicy returns the first signed policy whose PCR values are the
+// current ones.
+func (s authorizedSession) matchingPolicy() (*pb.SignedPCRPolicy, error) {
+	for _, signed := range s.policies {
+		current, err := ReadPCRs(s.rw, internal.PCRSelection(signed.GetPcrs()))
+		if err != nil {
+			return nil, err
+		}
+		if internal.CheckSubset(signed.GetPcrs(), current) == nil {
+			return signed, nil
+		}
+	}
+	return nil, fmt.Errorf("none of the %d signed PCR policies match the current PCR values", len(s.policies))
+}
+
+// verifyApproval has the TPM check the authority's signature of the approved
+// policy, returning the ticket needed by TPM2_PolicyAuthorize.
+func (s authorizedSession) verifyApproval(approved, signature []byte) (tpm2.Ticket, error) {
+	// TPM2_PolicyAuthorize computes aHash with the authority's name
+	// algorithm, whatever the hash algorithm of its signing scheme.
+	nameAlg, err := s.authority.NameAlg.Hash()
+	if err != nil {
+		return tpm2.Ticket{}, err
+	}
+	// A key loaded into the null hierarchy only produces null tickets, which
+	// TPM2_PolicyAuthorize rejects.
+	authority, _, err := tpm2.LoadExternal(s.rw, s.authority, tpm2.Private{}, tpm2.HandleOwner)
+	if err != nil {
+		return tpm2.Ticket{}, fmt.Errorf("failed to load authority: %w", err)
+	}
+	defer tpm2.FlushContext(s.rw, authority)
+	resp, err := internal.RunCommand(s.rw, internal.CmdVerifySignature, []tpmutil.Handle{authority}, nil,
+		tpmutil.U16Bytes(policy.ApprovalDigest(nameAlg, approved, s.policyRef)), tpmutil.RawBytes(signature))
+	if err != nil {
+		return tpm2.Ticket{}, fmt.Errorf("signed PCR policy does not verify: %w", err)
+	}
+	var ticket tpm2.Ticket
+	if _, err := tpmutil.Unpack(resp, &ticket); err != nil {
+		return tpm2.Ticket{}, fmt.Errorf("decoding verification ticket: %w", err)
+	}
+	return ticket, nil
+}
+
+func (s authorizedSession) Close() error {
+	return tpm2.FlushContext(s.rw, s.session)
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// Capabilities describes a TPM, as reported by TPM2_GetCapability.
+type Capabilities struct {
+	// The TCG vendor ID of the TPM manufacturer (e.g. 0x49465800 for Infineon).
+	ManufacturerID uint32
+	// The vendor-specific string identifying the TPM model.
+	VendorString string
+	// The vendor-specific firmware version. This is also the firmwareVersion
+	// of quotes signed by keys in the endorsement or platform hierarchy (it is
+	// obfuscated for other keys).
+	FirmwareVersion uint64
+	// The version of the TPM specification the TPM implements, multiplied by
+	// 100 (e.g. 138 for revision 1.38).
+	SpecRevision uint32
+	// The algorithms the TPM implements, in increasing order of ID.
+	Algorithms []tpm2.AlgorithmDescription
+	// The PCR banks, with the PCRs allocated in each. Banks without allocated
+	// PCRs are inactive.
+	PCRBanks []tpm2.PCRSelection
+	// The handles of the persistent objects in the TPM.
+	PersistentHandles []tpmutil.Handle
+	// The defined NV indexes.
+	NVIndexes []tpmutil.Handle
+}
+
+// GetCapabilities reads the TPM's fixed properties, supported algorithms, PCR
+// banks, persistent handles and NV indexes.
+func GetCapabilities(rw io.ReadWriter) (*Capabilities, error) {
+	fixed, err := TPMProperties(rw, tpm2.SpecRevision, tpm2.FirmwareVersion2)
+	if err != nil {
+		return nil, err
+	}
+	var vendor bytes.Buffer
+	for _, prop := range []tpm2.TPMProp{tpm2.VendorString1, tpm2.VendorString2, tpm2.VendorString3, tpm2.VendorString4} {
+		binary.Write(&vendor, binary.BigEndian, fixed[prop])
+	}
+	caps := &Capabilities{
+		ManufacturerID:  fixed[tpm2.Manufacturer],
+		VendorString:    strings.TrimRight(vendor.String(), "\x00"),
+		FirmwareVersion: uint64(fixed[tpm2.FirmwareVersion1])<<32 | uint64(fixed[tpm2.FirmwareVersion2]),
+		SpecRevision:    fixed[tpm2.SpecRevision],
+	}
+	if caps.Algorithms, err = algorithms(rw); err != nil {
+		return nil, err
+	}
+	if caps.PCRBanks, err = implementedPCRs(rw); err != nil {
+		return nil, err
+	}
+	if caps.PersistentHandles, err = Handles(rw, tpm2.HandleTypePersistent); err != nil {
+		return nil, fmt.Errorf("listing persistent handles: %w", err)
+	}
+	if caps.NVIndexes, err = Handles(rw, tpm2.HandleTypeNVIndex); err != nil {
+		return nil, fmt.Errorf("listing NV indexes: %w", err)
+	}
+	return caps, nil
+}
+
+// Proto returns the capabilities in the form recorded in an Attestation.
+func (c *Capabilities) Proto() *pb.TpmCapabilities {
+	caps := &pb.TpmCapabilities{
+		ManufacturerId:  c.ManufacturerID,
+		VendorString:    c.VendorString,
+		FirmwareVersion: c.FirmwareVersion,
+		SpecRevision:    c.SpecRevision,
+	}
+	for _, alg := range c.Algorithms {
+		caps.Algorithms = append(caps.Algorithms, uint32(alg.ID))
+	}
+	for _, bank := range c.PCRBanks {
+		if len(bank.PCRs) > 0 {
+			caps.PcrBanks = append(caps.PcrBanks, tpmpb.HashAlgo(bank.Hash))
+		}
+	}
+	for _, handle := range c.PersistentHandles {
+		caps.PersistentHandles = append(caps.PersistentHandles, uint32(handle))
+	}
+	for _, index := range c.NVIndexes {
+		caps.NvIndexes = append(caps.NvIndexes, uint32(index))
+	}
+	return caps
+}
+
+// TPMProperties reads the TPM properties (TPM_PT values) from first to last,
+// such as the lockout properties from tpm2.TPMAPermanent to
+// tpm2.LockoutRecovery. Properties the TPM does not report are absent from the
+// result.
+func TPMProperties(rw io.ReadWriter, first, last tpm2.TPMProp) (map[tpm2.TPMProp]uint32, error) {
+	props := make(map[tpm2.TPMProp]uint32)
+	for next := first; next <= last; {
+		caps, moreData, err := tpm2.GetCapability(rw, tpm2.CapabilityTPMProperties, uint32(last-next+1), uint32(next))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get TPM properties 0x%x to 0x%x: %w", uint32(next), uint32(last), err)
+		}
+		for _, c := range caps {
+			prop, ok := c.(tpm2.TaggedProperty)
+			if !ok || prop.Tag < next {
+				return nil, errors.New("unexpected data from GetCapability")
+			}
+			if prop.Tag <= last {
+				props[prop.Tag] = prop.Value
+			}
+			next = prop.Tag + 1
+		}
+		if !moreData || len(caps) == 0 {
+			break
+		}
+	}
+	return props, nil
+}
+
+// algorithms returns the algorithms the TPM implements.
+func algorithms(rw io.ReadWriter) ([]tpm2.AlgorithmDescription, error) {
+	var algs []tpm2.AlgorithmDescription
+	for next := uint32(0); ; {
+		caps, moreData, err := tpm2.GetCapability(rw, tpm2.CapabilityAlgs, math.MaxUint32, next)
+		if err != nil {
+			return nil, fmt.Errorf("listing algorithms: %w", err)
+		}
+		for _, c := range caps {
+			alg, ok := c.(tpm2.AlgorithmDescription)
+			if !ok {
+				return nil, errors.New("unexpected data from GetCapability")
+			}
+			algs = append(algs, alg)
+			next = uint32(alg.ID) + 1
+		}
+		if !moreData || len(caps) == 0 {
+			return algs, nil
+		}
+	}
+}
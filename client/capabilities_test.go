@@ -0,0 +1,72 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+func TestGetCapabilities(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	key, err := client.NewKey(rwc, tpm2.HandleOwner, client.SRKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+	if err := tpm2.EvictControl(rwc, "", tpm2.HandleOwner, key.Handle(), 0x81008F01); err != nil {
+		t.Fatal(err)
+	}
+	defer tpm2.EvictControl(rwc, "", tpm2.HandleOwner, 0x81008F01, 0x81008F01)
+	const index = 0x01500309
+	if err := client.CreateNVCounter(rwc, index); err != nil {
+		t.Fatal(err)
+	}
+	defer tpm2.NVUndefineSpace(rwc, "", tpm2.HandleOwner, index)
+
+	caps, err := client.GetCapabilities(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.ManufacturerID == 0 || caps.VendorString == "" || caps.SpecRevision < 100 {
+		t.Errorf("got capabilities %+v, want the simulator's fixed properties", caps)
+	}
+	for _, want := range []tpm2.Algorithm{tpm2.AlgRSA, tpm2.AlgSHA256, tpm2.AlgECC} {
+		found := false
+		for _, alg := range caps.Algorithms {
+			found = found || alg.ID == want
+		}
+		if !found {
+			t.Errorf("algorithms %v are missing 0x%x", caps.Algorithms, want)
+		}
+	}
+	if !containsHandle(caps.PersistentHandles, 0x81008F01) {
+		t.Errorf("persistent handles %v are missing the persisted key", caps.PersistentHandles)
+	}
+	if !containsHandle(caps.NVIndexes, index) {
+		t.Errorf("NV indexes %v are missing 0x%x", caps.NVIndexes, index)
+	}
+
+	// Banks without allocated PCRs are not recorded.
+	want := len(caps.Proto().GetPcrBanks())
+	if want == 0 {
+		t.Fatal("no active PCR banks")
+	}
+	caps.PCRBanks = append(caps.PCRBanks, tpm2.PCRSelection{Hash: tpm2.AlgSHA512})
+	if got := caps.Proto().GetPcrBanks(); len(got) != want {
+		t.Errorf("PCR banks %v should not include banks without allocated PCRs", got)
+	}
+}
+
+func containsHandle(handles []tpmutil.Handle, handle tpmutil.Handle) bool {
+	for _, h := range handles {
+		if h == handle {
+			return true
+		}
+	}
+	return false
+}
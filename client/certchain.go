@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultFetchTimeout  = 10 * time.Second
+	defaultFetchMaxCerts = 4
+	// The largest certificate (or PEM bundle) fetched from an issuer URL.
+	maxFetchedCertSize = 64 << 10
+)
+
+// FetchOpts configures fetching the certificates issuing an EK certificate
+// (see FetchIssuingCertificates).
+type FetchOpts struct {
+	// Cancels fetching. If nil, context.Background() is used.
+	Context context.Context
+	// The HTTP client used to fetch certificates. If nil, http.DefaultClient
+	// is used.
+	Client *http.Client
+	// The time allowed for fetching the whole chain. If zero, 10 seconds.
+	Timeout time.Duration
+	// The maximum number of certificates fetched. If zero, 4.
+	MaxCerts int
+}
+
+// FetchIssuingCertificates fetches the chain of certificates issuing cert,
+// following the CA Issuers URLs in the Authority Information Access extension
+// of cert and of each fetched certificate. It returns the DER encoded
+// certificates, starting with cert's issuer, and the URL each was fetched
+// from. Fetching stops at a self-signed certificate, which is not returned
+// (verifiers must already trust their roots), or at a certificate without CA
+// Issuers URLs. Each URL must return a DER or PEM encoded certificate which
+// signed the previous certificate.
+func FetchIssuingCertificates(cert *x509.Certificate, opts FetchOpts) ([][]byte, []string, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = defaultFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	maxCerts := opts.MaxCerts
+	if maxCerts == 0 {
+		maxCerts = defaultFetchMaxCerts
+	}
+
+	var certs [][]byte
+	var urls []string
+	for len(cert.IssuingCertificateURL) != 0 {
+		if len(certs) >= maxCerts {
+			return nil, nil, fmt.Errorf("certificate chain is longer than %d certificates", maxCerts)
+		}
+		issuer, url, err := fetchIssuer(ctx, client, cert)
+		if err != nil {
+			return nil, nil, err
+		}
+		if issuer.CheckSignatureFrom(issuer) == nil {
+			break
+		}
+		certs = append(certs, issuer.Raw)
+		urls = append(urls, url)
+		cert = issuer
+	}
+	return certs, urls, nil
+}
+
+// fetchIssuer tries each of a certificate's CA Issuers URLs in turn, returning
+// the first certificate which signed it.
+func fetchIssuer(ctx context.Context, client *http.Client, cert *x509.Certificate) (*x509.Certificate, string, error) {
+	var errs []error
+	for _, url := range cert.IssuingCertificateURL {
+		issuer, err := fetchCertificate(ctx, client, url)
+		if err == nil {
+			if err = cert.CheckSignatureFrom(issuer); err == nil {
+				return issuer, url, nil
+			}
+			err = fmt.Errorf("certificate from %s did not sign %q: %w", url, cert.Subject, err)
+		}
+		errs = append(errs, err)
+	}
+	return nil, "", fmt.Errorf("failed to fetch issuer of %q: %v", cert.Subject, errs)
+}
+
+func fetchCertificate(ctx context.Context, client *http.Client, url string) (*x509.Certificate, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned status %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchedCertSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	if len(data) > maxFetchedCertSize {
+		return nil, fmt.Errorf("certificate at %s is larger than %d bytes", url, maxFetchedCertSize)
+	}
+	if block, _ := pem.Decode(data); block != nil && block.Type == "CERTIFICATE" {
+		data = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate from %s: %w", url, err)
+	}
+	return cert, nil
+}
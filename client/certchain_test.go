@@ -0,0 +1,171 @@
+package client_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+type chainCert struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// newChainCert issues a certificate whose issuer can be fetched from
+// issuerURL. If parent is nil, the certificate is self-signed.
+func newChainCert(t *testing.T, name string, parent *chainCert, issuerURL string) *chainCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	if issuerURL != "" {
+		template.IssuingCertificateURL = []string{issuerURL}
+	}
+	issuer, issuerKey := template, crypto.Signer(key)
+	if parent != nil {
+		issuer, issuerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &chainCert{cert, key}
+}
+
+// serveChain starts a server of a root and an intermediate CA, and returns
+// them with an EK certificate issued by the intermediate, whose issuers can be
+// fetched from the server.
+func serveChain(t *testing.T) (server *httptest.Server, root, intermediate, ek *chainCert) {
+	t.Helper()
+	mux := http.NewServeMux()
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	root = newChainCert(t, "Test Root CA", nil, "")
+	intermediate = newChainCert(t, "Test Intermediate CA", root, server.URL+"/root.crt")
+	ek = newChainCert(t, "Test EK", intermediate, server.URL+"/intermediate.pem")
+	mux.HandleFunc("/root.crt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(root.cert.Raw)
+	})
+	mux.HandleFunc("/intermediate.pem", func(w http.ResponseWriter, r *http.Request) {
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: intermediate.cert.Raw})
+	})
+	return server, root, intermediate, ek
+}
+
+func TestFetchIssuingCertificates(t *testing.T) {
+	server, _, intermediate, ek := serveChain(t)
+
+	certs, urls, err := client.FetchIssuingCertificates(ek.cert, client.FetchOpts{})
+	if err != nil {
+		t.Fatalf("FetchIssuingCertificates() failed: %v", err)
+	}
+	if len(certs) != 1 || !bytes.Equal(certs[0], intermediate.cert.Raw) {
+		t.Errorf("fetched %d certificates, want only the intermediate", len(certs))
+	}
+	if len(urls) != 1 || urls[0] != server.URL+"/intermediate.pem" {
+		t.Errorf("fetched from %v, want the intermediate's URL", urls)
+	}
+
+	other := newChainCert(t, "Other CA", nil, "")
+	for name, cert := range map[string]*x509.Certificate{
+		"NotFound":    newChainCert(t, "Test EK", intermediate, server.URL+"/missing.crt").cert,
+		"WrongIssuer": newChainCert(t, "Test EK", other, server.URL+"/intermediate.pem").cert,
+		"BadURL":      newChainCert(t, "Test EK", intermediate, "http://\x00").cert,
+	} {
+		if _, _, err := client.FetchIssuingCertificates(cert, client.FetchOpts{}); err == nil {
+			t.Errorf("FetchIssuingCertificates() of %s should fail", name)
+		}
+	}
+	if _, _, err := client.FetchIssuingCertificates(ek.cert, client.FetchOpts{MaxCerts: -1}); err == nil {
+		t.Error("FetchIssuingCertificates() of a chain longer than MaxCerts should fail")
+	}
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-blocked:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	slowCert := newChainCert(t, "Test EK", intermediate, slow.URL+"/intermediate.crt").cert
+	if _, _, err := client.FetchIssuingCertificates(slowCert, client.FetchOpts{Timeout: 50 * time.Millisecond}); err == nil {
+		t.Error("FetchIssuingCertificates() should time out")
+	}
+}
+
+func TestAttestEKCertChain(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	server, _, intermediate, ek := serveChain(t)
+	nonce := []byte("super secret nonce")
+
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce, EKCert: ek.cert.Raw, FetchIntermediates: &client.FetchOpts{}})
+	if err != nil {
+		t.Fatalf("Attest() failed: %v", err)
+	}
+	if !bytes.Equal(attestation.GetEkCert(), ek.cert.Raw) {
+		t.Error("attestation does not contain the EK certificate")
+	}
+	if certs := attestation.GetIntermediateCerts(); len(certs) != 1 || !bytes.Equal(certs[0], intermediate.cert.Raw) {
+		t.Errorf("attestation has %d intermediate certificates, want only the intermediate", len(certs))
+	}
+	if urls := attestation.GetChainFetchedFrom(); len(urls) != 1 || urls[0] != server.URL+"/intermediate.pem" {
+		t.Errorf("attestation chain was fetched from %v", urls)
+	}
+
+	// Provided intermediates are not fetched again.
+	attestation, err = ak.Attest(client.AttestOpts{
+		Nonce:              nonce,
+		EKCert:             ek.cert.Raw,
+		IntermediateCerts:  [][]byte{intermediate.cert.Raw},
+		FetchIntermediates: &client.FetchOpts{},
+	})
+	if err != nil {
+		t.Fatalf("Attest() failed: %v", err)
+	}
+	if len(attestation.GetIntermediateCerts()) != 1 || len(attestation.GetChainFetchedFrom()) != 0 {
+		t.Errorf("attestation has intermediates %d fetched from %v, want only the provided intermediate", len(attestation.GetIntermediateCerts()), attestation.GetChainFetchedFrom())
+	}
+
+	if _, err := ak.Attest(client.AttestOpts{Nonce: nonce, IntermediateCerts: [][]byte{intermediate.cert.Raw}}); err == nil {
+		t.Error("Attest() with intermediates but no EK certificate should fail")
+	}
+	if _, err := ak.Attest(client.AttestOpts{Nonce: nonce, EKCert: []byte("not a certificate")}); err == nil {
+		t.Error("Attest() with an invalid EK certificate should fail")
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// Certify has the TPM sign the public area of another loaded key with this
+// key, so a verifier trusting this key (usually an AK) knows that the
+// certified key is resident in the same TPM, for example before issuing an
+// LDevID certificate. The extraData (typically a nonce) is included in the
+// signed data. This function will return an error if this key is not a
+// restricted signing key, or if the admin role of the certified key needs a
+// policy other than that of the DevID templates.
+//
+// The certification can be checked with server.VerifyKeyCertification, or
+// server.VerifyDevIDCertification for DevID keys.
+func (k *Key) Certify(key *Key, extraData []byte) (*pb.KeyCertification, error) {
+	if _, err := internal.GetSigningHashAlg(k.pubArea); err != nil {
+		return nil, err
+	}
+	if !k.hasAttribute(tpm2.FlagRestricted) {
+		return nil, fmt.Errorf("unrestricted keys are insecure to use with Certify")
+	}
+	keyAuth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+	if key.hasAttribute(tpm2.FlagAdminWithPolicy) {
+		if !bytes.Equal(key.pubArea.AuthPolicy, devIDAuthPolicy()) {
+			return nil, fmt.Errorf("unknown auth policy for certifying key")
+		}
+		session, err := startAuthSession(k.rw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+		defer tpm2.FlushContext(k.rw, session)
+		if err := tpm2.PolicyCommandCode(k.rw, session, tpm2.CmdCertify); err != nil {
+			return nil, err
+		}
+		keyAuth.Session = session
+	}
+	signerAuth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+
+	resp, err := internal.RunCommand(k.rw, tpm2.CmdCertify,
+		[]tpmutil.Handle{key.Handle(), k.Handle()}, []tpm2.AuthCommand{keyAuth, signerAuth},
+		tpmutil.U16Bytes(extraData), tpm2.AlgNull)
+	if err != nil {
+		return nil, fmt.Errorf("failed to certify key: %w", err)
+	}
+	buf := bytes.NewBuffer(resp)
+	var certifyInfo tpmutil.U16Bytes
+	if err := tpmutil.UnpackBuf(buf, &certifyInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode key certification: %w", err)
+	}
+	publicArea, err := key.pubArea.Encode()
+	if err != nil {
+		return nil, err
+	}
+	certification := &pb.KeyCertification{
+		CertifyInfo: certifyInfo,
+		RawSig:      buf.Bytes(),
+		PublicArea:  publicArea,
+	}
+
+	// Verify the certification client-side to make sure we didn't mess things
+	// up. NOTE: it still must be verified server-side as well.
+	if _, err := internal.VerifyKeyCertification(certification, k.PublicKey(), extraData); err != nil {
+		return nil, fmt.Errorf("failed to verify key certification: %w", err)
+	}
+	return certification, nil
+}
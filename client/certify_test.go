@@ -0,0 +1,73 @@
+package client_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+func TestCertifyDevID(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+
+	keys := []struct {
+		name string
+		new  func(rw io.ReadWriter) (*client.Key, error)
+	}{
+		{"IDevIDRSA", client.IDevIDKeyRSA},
+		{"IDevIDECC", client.IDevIDKeyECC},
+		{"LDevIDRSA", client.LDevIDKeyRSA},
+		{"LDevIDECC", client.LDevIDKeyECC},
+	}
+	for _, key := range keys {
+		t.Run(key.name, func(t *testing.T) {
+			devID, err := key.new(rwc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer devID.Close()
+
+			// Certify verifies the certification itself.
+			if _, err := ak.Certify(devID, []byte("nonce")); err != nil {
+				t.Errorf("Certify() failed: %v", err)
+			}
+			if _, err := devID.Certify(ak, []byte("nonce")); err == nil {
+				t.Error("certifying with an unrestricted key should fail")
+			}
+
+			// Unlike AKs, DevIDs can sign arbitrary digests.
+			signer, err := devID.GetSigner()
+			if err != nil {
+				t.Fatal(err)
+			}
+			digest := sha256.Sum256([]byte("TLS handshake"))
+			sig, err := signer.Sign(nil, digest[:], crypto.SHA256)
+			if err != nil {
+				t.Fatalf("Sign() failed: %v", err)
+			}
+			switch pub := signer.Public().(type) {
+			case *rsa.PublicKey:
+				err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+			case *ecdsa.PublicKey:
+				if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+					t.Error("invalid ECDSA signature")
+				}
+			}
+			if err != nil {
+				t.Errorf("invalid signature: %v", err)
+			}
+		})
+	}
+}
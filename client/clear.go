@@ -0,0 +1,105 @@
+package client
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// The size of the random value written by WriteClearMarker.
+const clearMarkerSize = 16
+
+// WriteClearMarker defines ClearMarkerNVIndex holding a random value, unless it
+// is already defined, and returns its contents. TPM2_Clear deletes the NV
+// indexes defined by the owner, so if the marker is missing (or different) in a
+// later attestation, the TPM was cleared. The index is write locked once it is
+// written (with TPM2_NV_WriteLock), and stays locked until it is deleted, so the
+// marker can only be written once.
+func WriteClearMarker(rw io.ReadWriter) ([]byte, error) {
+	index := tpmutil.Handle(ClearMarkerNVIndex)
+	marker, err := readClearMarker(rw)
+	if err != nil {
+		return nil, err
+	}
+	if marker == nil {
+		marker = make([]byte, clearMarkerSize)
+		if _, err := io.ReadFull(rand.Reader, marker); err != nil {
+			return nil, err
+		}
+		attrs := tpm2.AttrOwnerWrite | tpm2.AttrOwnerRead | tpm2.AttrAuthRead | tpm2.AttrNoDA | tpm2.AttrWriteDefine
+		if err := tpm2.NVDefineSpace(rw, tpm2.HandleOwner, index, "", "", nil, attrs, clearMarkerSize); err != nil {
+			return nil, fmt.Errorf("failed to define clear marker NV index: %w", err)
+		}
+		if err := tpm2.NVWrite(rw, tpm2.HandleOwner, index, "", marker, 0); err != nil {
+			return nil, fmt.Errorf("failed to write clear marker: %w", err)
+		}
+	}
+	// A marker written by an earlier call may not have been locked, if that
+	// call was interrupted.
+	public, err := tpm2.NVReadPublic(rw, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clear marker NV index: %w", err)
+	}
+	if public.Attributes&tpm2.AttrWriteLocked == 0 {
+		if err := tpm2.NVWriteLock(rw, tpm2.HandleOwner, index, ""); err != nil {
+			return nil, fmt.Errorf("failed to write lock clear marker: %w", err)
+		}
+	}
+	return marker, nil
+}
+
+// readClearMarker returns the contents of ClearMarkerNVIndex, or nil if it is
+// not defined.
+func readClearMarker(rw io.ReadWriter) ([]byte, error) {
+	indexes, err := Handles(rw, tpm2.HandleTypeNVIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list NV indexes: %w", err)
+	}
+	for _, index := range indexes {
+		if uint32(index) == ClearMarkerNVIndex {
+			marker, err := tpm2.NVReadEx(rw, index, tpm2.HandleOwner, "", 0)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read clear marker: %w", err)
+			}
+			return marker, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetClearIndicators returns the values which show whether a TPM was cleared
+// between two attestations (see server.DetectTPMClear): the Names of the EK
+// and SRK (from the default RSA templates, or the ECC templates if the TPM
+// does not support RSA), and the contents of the clear marker NV index, if
+// WriteClearMarker defined it.
+func GetClearIndicators(rw io.ReadWriter) (*pb.ClearIndicators, error) {
+	indicators := &pb.ClearIndicators{}
+	var err error
+	if indicators.EkName, err = keyName(rw, EndorsementKeyRSA, EndorsementKeyECC); err != nil {
+		return nil, fmt.Errorf("failed to create EK: %w", err)
+	}
+	if indicators.SrkName, err = keyName(rw, StorageRootKeyRSA, StorageRootKeyECC); err != nil {
+		return nil, fmt.Errorf("failed to create SRK: %w", err)
+	}
+	if indicators.NvMarker, err = readClearMarker(rw); err != nil {
+		return nil, err
+	}
+	return indicators, nil
+}
+
+// keyName returns the encoded Name of the key returned by rsaKey, or by eccKey
+// if that fails.
+func keyName(rw io.ReadWriter, rsaKey, eccKey func(io.ReadWriter) (*Key, error)) ([]byte, error) {
+	key, err := rsaKey(rw)
+	if err != nil {
+		if key, err = eccKey(rw); err != nil {
+			return nil, err
+		}
+	}
+	defer key.Close()
+	return key.Name().Digest.Encode()
+}
@@ -0,0 +1,60 @@
+package client_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+func TestClearIndicators(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	indicators, err := client.GetClearIndicators(rwc)
+	if err != nil {
+		t.Fatalf("GetClearIndicators() failed: %v", err)
+	}
+	if len(indicators.GetEkName()) == 0 || len(indicators.GetSrkName()) == 0 {
+		t.Error("clear indicators are missing the EK or SRK name")
+	}
+	if indicators.GetNvMarker() != nil {
+		t.Error("clear indicators have a marker before one was written")
+	}
+
+	marker, err := client.WriteClearMarker(rwc)
+	if err != nil {
+		t.Fatalf("WriteClearMarker() failed: %v", err)
+	}
+	defer tpm2.NVUndefineSpace(rwc, "", tpm2.HandleOwner, tpmutil.Handle(client.ClearMarkerNVIndex))
+	again, err := client.WriteClearMarker(rwc)
+	if err != nil {
+		t.Fatalf("WriteClearMarker() of a defined marker failed: %v", err)
+	}
+	if !bytes.Equal(marker, again) {
+		t.Errorf("WriteClearMarker() replaced the marker %x with %x", marker, again)
+	}
+	if err := tpm2.NVWrite(rwc, tpm2.HandleOwner, tpmutil.Handle(client.ClearMarkerNVIndex), "", make([]byte, len(marker)), 0); err == nil {
+		t.Error("overwriting the clear marker succeeded")
+	}
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: []byte("super secret nonce"), ClearIndicators: true})
+	if err != nil {
+		t.Fatalf("Attest() failed: %v", err)
+	}
+	got := attestation.GetClearIndicators()
+	if !bytes.Equal(got.GetNvMarker(), marker) {
+		t.Errorf("attestation has clear marker %x, want %x", got.GetNvMarker(), marker)
+	}
+	if !bytes.Equal(got.GetEkName(), indicators.GetEkName()) || !bytes.Equal(got.GetSrkName(), indicators.GetSrkName()) {
+		t.Error("attestation has different EK or SRK names")
+	}
+}
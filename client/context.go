@@ -0,0 +1,189 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// WithContext returns a ReadWriter which runs the TPM commands written to it
+// on rw until ctx is done, after which each command fails with ctx.Err(). It
+// can be passed to any function taking a TPM, to cancel long-running
+// operations (such as creating an RSA primary key) or bound them with a
+// deadline:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+//	defer cancel()
+//	ek, err := client.EndorsementKeyRSA(client.WithContext(ctx, rw))
+//
+// A TPM command cannot be interrupted, so when ctx is done during a command,
+// the command's response is discarded (and any object or session it created
+// is flushed) in the background, and the next command waits for it.
+// TPM2_FlushContext is always run, even after ctx is done, so keys created
+// through the returned ReadWriter can still be closed. The returned
+// ReadWriter must not be used concurrently.
+func WithContext(ctx context.Context, rw io.ReadWriter) io.ReadWriter {
+	return &contextRW{ctx: ctx, rw: rw}
+}
+
+type contextRW struct {
+	ctx  context.Context
+	rw   io.ReadWriter
+	resp []byte
+	// Closed once the command abandoned when ctx was done has finished, or
+	// nil if no command is running.
+	running chan struct{}
+}
+
+type commandResult struct {
+	resp []byte
+	err  error
+}
+
+// Write runs a TPM command, returning once it finishes or ctx is done. The
+// response can then be read with Read.
+func (c *contextRW) Write(cmd []byte) (int, error) {
+	if len(cmd) < commandHeaderSize {
+		return 0, fmt.Errorf("TPM command of %d bytes is too short", len(cmd))
+	}
+	c.resp = nil
+	command := tpmutil.Command(binary.BigEndian.Uint32(cmd[6:10]))
+	if command == tpm2.CmdFlushContext {
+		if c.running != nil {
+			<-c.running
+			c.running = nil
+		}
+		return c.finish(runCommand(c.rw, cmd))
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if c.running != nil {
+		select {
+		case <-c.running:
+			c.running = nil
+		case <-c.ctx.Done():
+			return 0, c.ctx.Err()
+		}
+	}
+
+	results := make(chan commandResult)
+	abandoned := make(chan struct{})
+	running := make(chan struct{})
+	go func() {
+		defer close(running)
+		resp, err := runCommand(c.rw, cmd)
+		select {
+		case results <- commandResult{resp, err}:
+		case <-abandoned:
+			flushCreated(c.rw, command, resp)
+		}
+	}()
+	select {
+	case result := <-results:
+		return c.finish(result.resp, result.err)
+	case <-c.ctx.Done():
+		close(abandoned)
+		c.running = running
+		return 0, c.ctx.Err()
+	}
+}
+
+func (c *contextRW) finish(resp []byte, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	c.resp = resp
+	return len(resp), nil
+}
+
+// Read returns the response to the last command written.
+func (c *contextRW) Read(p []byte) (int, error) {
+	if c.resp == nil {
+		return 0, errors.New("no TPM response to read")
+	}
+	if len(p) < len(c.resp) {
+		return 0, io.ErrShortBuffer
+	}
+	n := copy(p, c.resp)
+	c.resp = nil
+	return n, nil
+}
+
+// EventLog returns the event log of the wrapped TPM (see GetEventLog).
+func (c *contextRW) EventLog() ([]byte, error) {
+	return GetEventLog(c.rw)
+}
+
+// runCommand writes a command to rw and reads its response.
+func runCommand(rw io.ReadWriter, cmd []byte) ([]byte, error) {
+	if _, err := rw.Write(cmd); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, maxResponseSize)
+	n, err := rw.Read(resp)
+	if err != nil {
+		return nil, err
+	}
+	if n < responseHeaderSize {
+		return nil, fmt.Errorf("TPM response of %d bytes is too short", n)
+	}
+	return resp[:n], nil
+}
+
+// flushCreated flushes the object or session created by a successful command
+// whose response was discarded.
+func flushCreated(rw io.ReadWriter, command tpmutil.Command, resp []byte) {
+	if len(resp) < responseHeaderSize+4 || commandHandles[command].out != 1 ||
+		binary.BigEndian.Uint32(resp[6:10]) != uint32(tpmutil.RCSuccess) {
+		return
+	}
+	tpm2.FlushContext(rw, tpmutil.Handle(binary.BigEndian.Uint32(resp[responseHeaderSize:])))
+}
+
+// withContext returns a copy of the key which runs its commands through
+// WithContext(ctx, k.rw).
+func (k *Key) withContext(ctx context.Context) *Key {
+	key := *k
+	key.rw = WithContext(ctx, k.rw)
+	return &key
+}
+
+// ContextAttester is implemented by Attesters which can be cancelled, such as
+// Key. AttestToVerifier uses AttestContext if the Attester implements it.
+type ContextAttester interface {
+	Attester
+	AttestContext(ctx context.Context, opts AttestOpts) (*pb.Attestation, error)
+}
+
+var _ ContextAttester = (*Key)(nil)
+
+// AttestContext is like Attest, but stops once ctx is done (see WithContext).
+// If opts.FetchIntermediates has no Context, the certificates are fetched
+// with ctx.
+func (k *Key) AttestContext(ctx context.Context, opts AttestOpts) (*pb.Attestation, error) {
+	if opts.FetchIntermediates != nil && opts.FetchIntermediates.Context == nil {
+		fetch := *opts.FetchIntermediates
+		fetch.Context = ctx
+		opts.FetchIntermediates = &fetch
+	}
+	return k.withContext(ctx).Attest(opts)
+}
+
+// SealContext is like Seal, but stops once ctx is done (see WithContext).
+func (k *Key) SealContext(ctx context.Context, sensitive []byte, opts SealOpts) (*tpmpb.SealedBytes, error) {
+	return k.withContext(ctx).Seal(sensitive, opts)
+}
+
+// UnsealContext is like Unseal, but stops once ctx is done (see WithContext).
+func (k *Key) UnsealContext(ctx context.Context, in *tpmpb.SealedBytes, opts UnsealOpts) ([]byte, error) {
+	return k.withContext(ctx).Unseal(in, opts)
+}
@@ -0,0 +1,123 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// slowTPM delays each command by the time read from delay.
+type slowTPM struct {
+	io.ReadWriter
+	delay chan time.Duration
+}
+
+func (s slowTPM) Write(cmd []byte) (int, error) {
+	select {
+	case d := <-s.delay:
+		time.Sleep(d)
+	default:
+	}
+	return s.ReadWriter.Write(cmd)
+}
+
+func TestWithContext(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	tpm := slowTPM{rwc, make(chan time.Duration, 1)}
+
+	before, err := client.Handles(rwc, tpm2.HandleTypeTransient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	tpm.delay <- 500 * time.Millisecond
+	start := time.Now()
+	if _, err := client.StorageRootKeyRSA(client.WithContext(ctx, tpm)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("creating a key past the deadline returned %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("creating a key returned after %v, not at the deadline", elapsed)
+	}
+
+	// The next command waits for the abandoned one, whose key is flushed.
+	rw := client.WithContext(context.Background(), tpm)
+	after, err := client.Handles(rw, tpm2.HandleTypeTransient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("abandoned command left %d transient objects, want %d", len(after), len(before))
+	}
+
+	// Keys can be closed after the context is done.
+	ctx, cancel = context.WithCancel(context.Background())
+	srk, err := client.StorageRootKeyECC(client.WithContext(ctx, rwc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := srk.Seal([]byte("secret"), client.SealOpts{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Seal() after cancellation returned %v, want context.Canceled", err)
+	}
+	srk.Close()
+	if after, err = client.Handles(rwc, tpm2.HandleTypeTransient); err != nil || len(after) != len(before) {
+		t.Errorf("closing a key after cancellation left %d transient objects (%v), want %d", len(after), err, len(before))
+	}
+}
+
+func TestContextVariants(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+
+	ctx := context.Background()
+	secret := []byte("secret")
+	sealed, err := srk.SealContext(ctx, secret, client.SealOpts{})
+	if err != nil {
+		t.Fatalf("SealContext() failed: %v", err)
+	}
+	unsealed, err := srk.UnsealContext(ctx, sealed, client.UnsealOpts{})
+	if err != nil {
+		t.Fatalf("UnsealContext() failed: %v", err)
+	}
+	if !bytes.Equal(unsealed, secret) {
+		t.Errorf("UnsealContext() = %q, want %q", unsealed, secret)
+	}
+	if _, err := ak.AttestContext(ctx, client.AttestOpts{Nonce: []byte("super secret nonce")}); err != nil {
+		t.Errorf("AttestContext() failed: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := srk.SealContext(cancelled, secret, client.SealOpts{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("SealContext() returned %v, want context.Canceled", err)
+	}
+	if _, err := srk.UnsealContext(cancelled, sealed, client.UnsealOpts{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("UnsealContext() returned %v, want context.Canceled", err)
+	}
+	if _, err := ak.AttestContext(cancelled, client.AttestOpts{Nonce: []byte("super secret nonce")}); !errors.Is(err, context.Canceled) {
+		t.Errorf("AttestContext() returned %v, want context.Canceled", err)
+	}
+	// The keys still work without the cancelled context.
+	if _, err := srk.Unseal(sealed, client.UnsealOpts{}); err != nil {
+		t.Errorf("Unseal() after a cancelled UnsealContext() failed: %v", err)
+	}
+}
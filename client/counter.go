@@ -0,0 +1,122 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// Values from Part 2 of the spec: TPM_NT_COUNTER (in the TPM_NT field of the
+// NV attributes), and TPM_EO_EQ (used with TPM2_PolicyNV).
+const (
+	nvTypeMask    tpm2.NVAttr = 0xF << 4
+	nvTypeCounter tpm2.NVAttr = 0x1 << 4
+	eoEqual       uint16      = 0x0000
+)
+
+// Anyone with access to the TPM can read or increment the counter (it uses an
+// empty auth value), but only the owner can delete it.
+const counterAttributes = nvTypeCounter | tpm2.AttrAuthRead | tpm2.AttrAuthWrite |
+	tpm2.AttrOwnerRead | tpm2.AttrNoDA
+
+const counterSize = 8
+
+// CreateNVCounter defines a monotonic counter at an NV index, for use with
+// SealOpts.CounterIndex. The counter is incremented once, as TPM counters
+// cannot be read until they are first incremented. The TPM initializes a new
+// counter to the largest value any counter on the TPM has ever had, so deleting
+// and recreating a counter never lowers its value. The index is defined using
+// the owner hierarchy and an empty password.
+func CreateNVCounter(rw io.ReadWriter, index uint32) error {
+	pub := tpm2.NVPublic{
+		NVIndex:    tpmutil.Handle(index),
+		NameAlg:    SessionHashAlgTpm,
+		Attributes: counterAttributes,
+		DataSize:   counterSize,
+	}
+	ownerAuth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+	if err := tpm2.NVDefineSpaceEx(rw, tpm2.HandleOwner, "", pub, ownerAuth); err != nil {
+		return fmt.Errorf("failed to define NV counter: %w", err)
+	}
+	_, err := IncrementNVCounter(rw, index)
+	return err
+}
+
+// IncrementNVCounter increments a counter created with CreateNVCounter, and
+// returns its new value. Any data sealed to the old value can no longer be
+// unsealed.
+func IncrementNVCounter(rw io.ReadWriter, index uint32) (uint64, error) {
+	if err := tpm2.NVIncrement(rw, tpmutil.Handle(index), ""); err != nil {
+		return 0, fmt.Errorf("failed to increment NV counter: %w", err)
+	}
+	return ReadNVCounter(rw, index)
+}
+
+// ReadNVCounter returns the current value of a counter created with
+// CreateNVCounter.
+func ReadNVCounter(rw io.ReadWriter, index uint32) (uint64, error) {
+	data, err := tpm2.NVReadEx(rw, tpmutil.Handle(index), tpmutil.Handle(index), "", 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read NV counter: %w", err)
+	}
+	if len(data) != counterSize {
+		return 0, fmt.Errorf("NV counter is %d bytes, expected %d", len(data), counterSize)
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// DeleteNVCounter removes a counter created with CreateNVCounter, using the
+// owner hierarchy and an empty password.
+func DeleteNVCounter(rw io.ReadWriter, index uint32) error {
+	return tpm2.NVUndefineSpace(rw, "", tpm2.HandleOwner, tpmutil.Handle(index))
+}
+
+// counterPolicy returns the binding of sealed data to the current value of the
+// counter, and extends the sealed object's auth policy to require that value.
+func counterPolicy(rw io.ReadWriter, index uint32, auth []byte) (*pb.NVCounter, []byte, error) {
+	pub, err := tpm2.NVReadPublic(rw, tpmutil.Handle(index))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read NV counter public area: %w", err)
+	}
+	if pub.Attributes&nvTypeMask != nvTypeCounter || pub.Attributes&counterAttributes != counterAttributes {
+		return nil, nil, fmt.Errorf("NV index 0x%x is not a counter created with CreateNVCounter", index)
+	}
+	value, err := ReadNVCounter(rw, index)
+	if err != nil {
+		return nil, nil, err
+	}
+	name, err := internal.NVName(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth, err = internal.PolicyNVAuth(auth, name, counterValue(value), 0, eoEqual, SessionHashAlg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &pb.NVCounter{Index: index, Value: value}, auth, nil
+}
+
+// policyCounter asserts, in a policy session, that the counter still has the
+// value the data was sealed to.
+func policyCounter(rw io.ReadWriter, session tpmutil.Handle, counter *pb.NVCounter) error {
+	index := tpmutil.Handle(counter.GetIndex())
+	auth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+	_, err := internal.RunCommand(rw, internal.CmdPolicyNV,
+		[]tpmutil.Handle{index, index, session}, []tpm2.AuthCommand{auth},
+		tpmutil.U16Bytes(counterValue(counter.GetValue())), uint16(0), eoEqual)
+	if err != nil {
+		return fmt.Errorf("NV counter 0x%x no longer has value %d: %w", counter.GetIndex(), counter.GetValue(), err)
+	}
+	return nil
+}
+
+func counterValue(value uint64) []byte {
+	encoded := make([]byte, counterSize)
+	binary.BigEndian.PutUint64(encoded, value)
+	return encoded
+}
@@ -0,0 +1,116 @@
+package client_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+const testCounterIndex = 0x01500100
+
+func TestNVCounter(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	if err := client.CreateNVCounter(rwc, testCounterIndex); err != nil {
+		t.Fatal(err)
+	}
+	defer client.DeleteNVCounter(rwc, testCounterIndex)
+
+	value, err := client.ReadNVCounter(rwc, testCounterIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newValue, err := client.IncrementNVCounter(rwc, testCounterIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newValue != value+1 {
+		t.Errorf("IncrementNVCounter() = %d, want %d", newValue, value+1)
+	}
+}
+
+func TestSealWithNVCounter(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	if err := client.CreateNVCounter(rwc, testCounterIndex); err != nil {
+		t.Fatal(err)
+	}
+	defer client.DeleteNVCounter(rwc, testCounterIndex)
+
+	secret := []byte("test")
+	testcases := []struct {
+		name string
+		opts client.SealOpts
+	}{
+		{"CounterOnly", client.SealOpts{CounterIndex: testCounterIndex}},
+		{"CounterAndPCRs", client.SealOpts{
+			Current:      tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{7}},
+			CounterIndex: testCounterIndex,
+		}},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			sealed, err := srk.Seal(secret, testcase.opts)
+			if err != nil {
+				t.Fatalf("failed to seal: %v", err)
+			}
+			if sealed.GetCounter().GetIndex() != testCounterIndex {
+				t.Errorf("sealed data has counter index 0x%x, want 0x%x", sealed.GetCounter().GetIndex(), testCounterIndex)
+			}
+			unsealed, err := srk.Unseal(sealed, client.UnsealOpts{})
+			if err != nil {
+				t.Fatalf("failed to unseal: %v", err)
+			}
+			if !bytes.Equal(unsealed, secret) {
+				t.Fatalf("unsealed (%v) not equal to secret (%v)", unsealed, secret)
+			}
+
+			if _, err := client.IncrementNVCounter(rwc, testCounterIndex); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := srk.Unseal(sealed, client.UnsealOpts{}); err == nil {
+				t.Error("unseal should fail after the counter is incremented")
+			}
+		})
+	}
+}
+
+func TestSealWithNVCounterFails(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+
+	// An undefined index cannot be used.
+	if _, err := srk.Seal([]byte("test"), client.SealOpts{CounterIndex: testCounterIndex}); err == nil {
+		t.Error("sealing to an undefined NV counter should fail")
+	}
+
+	// Nor can an index which is not a counter.
+	if err := tpm2.NVDefineSpace(rwc, tpm2.HandleOwner, testCounterIndex, "", "", nil,
+		tpm2.AttrAuthRead|tpm2.AttrAuthWrite|tpm2.AttrOwnerRead, 8); err != nil {
+		t.Fatal(err)
+	}
+	defer client.DeleteNVCounter(rwc, testCounterIndex)
+	if err := tpm2.NVWrite(rwc, testCounterIndex, testCounterIndex, "", make([]byte, 8), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srk.Seal([]byte("test"), client.SealOpts{CounterIndex: testCounterIndex}); err == nil {
+		t.Error("sealing to an ordinary NV index should fail")
+	}
+}
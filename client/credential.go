@@ -0,0 +1,31 @@
+package client
+
+import (
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// ActivateCredential recovers the secret contained in an EncryptedCredential.
+// The key used must be the EK the credential was encrypted to, and ak must be
+// the key whose name the credential is bound to. The TPM will only release the
+// secret if both keys are loaded, proving to the server that ak resides in the
+// same TPM as the EK. The challenge parameter should come from
+// server.GenerateChallenge.
+func (k *Key) ActivateCredential(ak *Key, challenge *pb.EncryptedCredential) ([]byte, error) {
+	akAuth, err := ak.session.Auth()
+	if err != nil {
+		return nil, err
+	}
+	ekAuth, err := k.session.Auth()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := tpm2.ActivateCredentialUsingAuth(k.rw, []tpm2.AuthCommand{akAuth, ekAuth},
+		ak.Handle(), k.Handle(), challenge.GetCredentialBlob(), challenge.GetEncryptedSecret())
+	if err != nil {
+		return nil, fmt.Errorf("activate credential failed: %w", err)
+	}
+	return secret, nil
+}
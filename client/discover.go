@@ -0,0 +1,109 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// TPMDiscovery reports which TPMs this process can find and use, and how to
+// fix problems with opening them. It is returned by DiscoverTPM, and included
+// in the DeviceError returned when OpenTPM cannot open a TPM device.
+type TPMDiscovery struct {
+	// The TPM device nodes (or on Windows, the TPM Base Services) which were
+	// checked, whether or not they exist
+	Devices []TPMDevice
+	// Whether the operating system found a TPM, even if its devices are
+	// missing or inaccessible
+	KernelFound bool
+	// Concrete steps which may fix problems found with the TPM, empty if no
+	// problems were found
+	Hints []string
+}
+
+// TPMDevice describes a TPM device node or interface.
+type TPMDevice struct {
+	// The device path, such as /dev/tpmrm0
+	Path string
+	// Whether the device exists
+	Exists bool
+	// The device's permission bits
+	Mode os.FileMode
+	// The owner and group of the device, as names if they are known, and
+	// numeric IDs otherwise
+	Owner, Group string
+	// Whether this process can read from and write to the device
+	Accessible bool
+	// The kernel driver bound to the TPM, such as "tpm_crb" or "tpm_tis",
+	// empty if unknown
+	Driver string
+	// The major version of the TPM (1 or 2), or 0 if unknown
+	Version int
+}
+
+func (d TPMDevice) String() string {
+	if !d.Exists {
+		return d.Path + ": missing"
+	}
+	var desc []string
+	if d.Mode != 0 {
+		desc = append(desc, fmt.Sprintf("%v %s:%s", d.Mode, d.Owner, d.Group))
+	}
+	if d.Driver != "" {
+		desc = append(desc, "driver "+d.Driver)
+	}
+	if d.Version != 0 {
+		desc = append(desc, fmt.Sprintf("TPM %d", d.Version))
+	}
+	if d.Accessible {
+		desc = append(desc, "accessible")
+	} else {
+		desc = append(desc, "not accessible")
+	}
+	return fmt.Sprintf("%s: %s", d.Path, strings.Join(desc, ", "))
+}
+
+// DeviceError is returned by OpenTPM when a TPM device cannot be opened. Its
+// Discovery describes the TPMs which are present, and suggests how to make
+// them usable.
+type DeviceError struct {
+	// The device which could not be opened, empty for the default device
+	Path string
+	// The error from opening the device
+	Err error
+	// The TPMs found when opening the device failed
+	Discovery *TPMDiscovery
+}
+
+func (e *DeviceError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = DefaultTPMDescription
+	}
+	msg := fmt.Sprintf("opening %s: %v", path, e.Err)
+	if hints := e.Discovery.Hints; len(hints) != 0 {
+		msg += " (" + strings.Join(hints, "; ") + ")"
+	}
+	return msg
+}
+
+func (e *DeviceError) Unwrap() error {
+	return e.Err
+}
+
+// openDeviceWithDiscovery opens a TPM device like openDevice, returning a
+// DeviceError if it fails.
+func openDeviceWithDiscovery(path string) (io.ReadWriteCloser, error) {
+	rwc, err := openDevice(path)
+	if err == nil {
+		return rwc, nil
+	}
+	discovery := DiscoverTPM()
+	if errors.Is(err, syscall.EBUSY) {
+		discovery.Hints = append(discovery.Hints, fmt.Sprintf("%s is in use by another process (such as tpm2-abrmd), use the resource manager device /dev/tpmrm0 to share the TPM", path))
+	}
+	return nil, &DeviceError{Path: path, Err: err, Discovery: discovery}
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Where Linux exposes TPMs: the device nodes, and the kernel's view of the
+// TPMs in sysfs.
+var (
+	devDir      = "/dev"
+	sysClassTPM = "/sys/class/tpm"
+)
+
+// Permission bits for access(2), from unistd.h.
+const (
+	accessRead  = 0x4
+	accessWrite = 0x2
+)
+
+// DiscoverTPM checks the TPM device nodes (/dev/tpmN and /dev/tpmrmN), their
+// permissions, and the TPMs and drivers known to the kernel, returning
+// remediation hints for any problems found.
+func DiscoverTPM() *TPMDiscovery {
+	discovery := &TPMDiscovery{}
+	// The kernel's TPMs, and any device nodes without a kernel TPM.
+	indexes := map[int]bool{0: true}
+	sysEntries, _ := ioutil.ReadDir(sysClassTPM)
+	for _, entry := range sysEntries {
+		if i, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "tpm")); err == nil {
+			indexes[i] = true
+			discovery.KernelFound = true
+		}
+	}
+	devEntries, _ := filepath.Glob(filepath.Join(devDir, "tpm*"))
+	for _, path := range devEntries {
+		name := strings.TrimPrefix(strings.TrimPrefix(filepath.Base(path), "tpmrm"), "tpm")
+		if i, err := strconv.Atoi(name); err == nil {
+			indexes[i] = true
+		}
+	}
+	var sorted []int
+	for i := range indexes {
+		sorted = append(sorted, i)
+	}
+	sort.Ints(sorted)
+
+	for _, i := range sorted {
+		sysDir := filepath.Join(sysClassTPM, fmt.Sprintf("tpm%d", i))
+		driver, version := tpmDriver(sysDir)
+		for _, name := range []string{"tpmrm%d", "tpm%d"} {
+			device := statDevice(filepath.Join(devDir, fmt.Sprintf(name, i)))
+			device.Driver, device.Version = driver, version
+			discovery.Devices = append(discovery.Devices, device)
+		}
+	}
+	discovery.Hints = linuxHints(discovery)
+	return discovery
+}
+
+// tpmDriver returns the driver and major version of a TPM in sysfs, if known.
+func tpmDriver(sysDir string) (string, int) {
+	driver := ""
+	if link, err := os.Readlink(filepath.Join(sysDir, "device", "driver")); err == nil {
+		driver = filepath.Base(link)
+	}
+	// Only reported by Linux 5.6 and later.
+	data, err := ioutil.ReadFile(filepath.Join(sysDir, "tpm_version_major"))
+	if err != nil {
+		return driver, 0
+	}
+	version, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return driver, version
+}
+
+func statDevice(path string) TPMDevice {
+	device := TPMDevice{Path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return device
+	}
+	device.Exists = true
+	device.Mode = info.Mode()
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		device.Owner = strconv.FormatUint(uint64(stat.Uid), 10)
+		if u, err := user.LookupId(device.Owner); err == nil {
+			device.Owner = u.Username
+		}
+		device.Group = strconv.FormatUint(uint64(stat.Gid), 10)
+		if g, err := user.LookupGroupId(device.Group); err == nil {
+			device.Group = g.Name
+		}
+	}
+	device.Accessible = syscall.Access(path, accessRead|accessWrite) == nil
+	return device
+}
+
+// linuxHints suggests fixes for the problems found by DiscoverTPM.
+func linuxHints(discovery *TPMDiscovery) []string {
+	var hints []string
+	anyExists, anyAccessible, anyResourceManager := false, false, false
+	for _, device := range discovery.Devices {
+		if !device.Exists {
+			continue
+		}
+		anyExists = true
+		anyAccessible = anyAccessible || device.Accessible
+		anyResourceManager = anyResourceManager || isResourceManager(device.Path)
+		if device.Version == 1 {
+			hints = append(hints, fmt.Sprintf("%s is a TPM 1.2, but only TPM 2.0 is supported", device.Path))
+		}
+	}
+
+	switch {
+	case !discovery.KernelFound && !anyExists:
+		hints = append(hints,
+			"the kernel found no TPM: enable the TPM (also called fTPM, PTT or Security Chip) in the firmware settings, or add a virtual TPM to the VM",
+			"if the TPM is enabled, check that the tpm_crb or tpm_tis driver is loaded (modprobe tpm_crb tpm_tis) and look for TPM errors in dmesg")
+	case !anyExists:
+		hints = append(hints, fmt.Sprintf("the kernel found a TPM, but there are no TPM devices in %s: in a container, pass the device in (e.g. docker run --device /dev/tpmrm0)", devDir))
+	case !anyAccessible:
+		hints = append(hints, permissionHint(discovery.Devices))
+	}
+	if anyExists && !anyResourceManager && len(hints) == 0 {
+		hints = append(hints, "there is no resource manager device (/dev/tpmrmN, from Linux 4.12), so only one process at a time can use the TPM")
+	}
+	return hints
+}
+
+func isResourceManager(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "tpmrm")
+}
+
+// permissionHint suggests how to get access to the TPM devices, which exist
+// but are not accessible.
+func permissionHint(devices []TPMDevice) string {
+	username := "$USER"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	for _, device := range devices {
+		// Distributions' udev rules usually give the tss group access to the
+		// resource manager.
+		if device.Exists && device.Mode&0060 == 0060 && device.Group != "" && device.Group != "root" && device.Group != "0" {
+			return fmt.Sprintf("this user cannot access %s: add the user to the group %q (sudo usermod -aG %s %s), then log in again", device.Path, device.Group, device.Group, username)
+		}
+	}
+	return "this user cannot access the TPM devices: run as root, or install udev rules giving a group (usually tss) read and write access to /dev/tpmrm0, and add the user to it"
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeTPMDirs points DiscoverTPM at empty device and sysfs directories for
+// the duration of the test.
+func fakeTPMDirs(t *testing.T) (dev string, sys string) {
+	t.Helper()
+	oldDev, oldSys := devDir, sysClassTPM
+	t.Cleanup(func() { devDir, sysClassTPM = oldDev, oldSys })
+	devDir, sysClassTPM = t.TempDir(), t.TempDir()
+	return devDir, sysClassTPM
+}
+
+func addSysTPM(t *testing.T, sys, name, driver, version string) {
+	t.Helper()
+	dir := filepath.Join(sys, name, "device")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../../bus/platform/drivers/"+driver, filepath.Join(dir, "driver")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(sys, name, "tpm_version_major"), []byte(version+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hintsContain(hints []string, substr string) bool {
+	for _, hint := range hints {
+		if strings.Contains(hint, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDiscoverTPM(t *testing.T) {
+	subtests := []struct {
+		name       string
+		sysTPMs    map[string]string
+		devices    []string
+		wantKernel bool
+		wantHint   string
+	}{
+		{"NoTPM", nil, nil, false, "firmware settings"},
+		{"NoDevices", map[string]string{"tpm0": "2"}, nil, true, "--device /dev/tpmrm0"},
+		{"NoResourceManager", map[string]string{"tpm0": "2"}, []string{"tpm0"}, true, "resource manager"},
+		{"TPM12", map[string]string{"tpm0": "1"}, []string{"tpm0"}, true, "TPM 1.2"},
+		{"Working", map[string]string{"tpm0": "2"}, []string{"tpm0", "tpmrm0"}, true, ""},
+		{"DeviceWithoutSysfs", nil, []string{"tpmrm0"}, false, ""},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			dev, sys := fakeTPMDirs(t)
+			for name, version := range subtest.sysTPMs {
+				addSysTPM(t, sys, name, "tpm_crb", version)
+			}
+			for _, name := range subtest.devices {
+				if err := ioutil.WriteFile(filepath.Join(dev, name), nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			discovery := DiscoverTPM()
+			if discovery.KernelFound != subtest.wantKernel {
+				t.Errorf("got KernelFound %v, want %v", discovery.KernelFound, subtest.wantKernel)
+			}
+			if len(discovery.Devices) != 2 {
+				t.Fatalf("got devices %v, want tpmrm0 and tpm0", discovery.Devices)
+			}
+			for _, device := range discovery.Devices {
+				wantExists := containsString(subtest.devices, filepath.Base(device.Path))
+				if device.Exists != wantExists {
+					t.Errorf("%v: got Exists %v, want %v", device, device.Exists, wantExists)
+				}
+				if subtest.sysTPMs != nil && device.Driver != "tpm_crb" {
+					t.Errorf("%v: got driver %q, want tpm_crb", device, device.Driver)
+				}
+			}
+			if subtest.wantHint == "" && len(discovery.Hints) != 0 {
+				t.Errorf("got hints %q, want none", discovery.Hints)
+			}
+			if subtest.wantHint != "" && !hintsContain(discovery.Hints, subtest.wantHint) {
+				t.Errorf("got hints %q, want a hint containing %q", discovery.Hints, subtest.wantHint)
+			}
+		})
+	}
+}
+
+func TestDiscoverTPMPermissions(t *testing.T) {
+	// The devices' permissions are set explicitly, as root can access any
+	// device.
+	discovery := &TPMDiscovery{KernelFound: true, Devices: []TPMDevice{
+		{Path: "/dev/tpmrm0", Exists: true, Mode: os.ModeDevice | os.ModeCharDevice | 0660, Owner: "tss", Group: "tss"},
+		{Path: "/dev/tpm0", Exists: true, Mode: os.ModeDevice | os.ModeCharDevice | 0600, Owner: "tss", Group: "root"},
+	}}
+	hints := linuxHints(discovery)
+	if !hintsContain(hints, "usermod -aG tss") {
+		t.Errorf("got hints %q, want a hint to join the tss group", hints)
+	}
+
+	discovery.Devices[0].Mode = os.ModeDevice | os.ModeCharDevice | 0600
+	hints = linuxHints(discovery)
+	if !hintsContain(hints, "udev rules") {
+		t.Errorf("got hints %q, want a hint to install udev rules", hints)
+	}
+
+	discovery.Devices[0].Accessible = true
+	if hints = linuxHints(discovery); len(hints) != 0 {
+		t.Errorf("got hints %q, want none for an accessible device", hints)
+	}
+}
+
+func TestOpenTPMDeviceError(t *testing.T) {
+	dev, _ := fakeTPMDirs(t)
+	path := filepath.Join(dev, "tpmrm0")
+	_, err := OpenTPM(path)
+	var deviceErr *DeviceError
+	if !errors.As(err, &deviceErr) {
+		t.Fatalf("OpenTPM() = %v, want a DeviceError", err)
+	}
+	if deviceErr.Path != path || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got DeviceError for %q (%v), want a missing %q", deviceErr.Path, deviceErr.Err, path)
+	}
+	if !strings.Contains(err.Error(), "firmware settings") {
+		t.Errorf("error %q does not include the hints", err)
+	}
+}
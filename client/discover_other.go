@@ -0,0 +1,13 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package client
+
+// DiscoverTPM checks for TPM device nodes and their permissions. Only Linux and
+// Windows TPMs are supported, so on other platforms it only suggests using a
+// simulator.
+func DiscoverTPM() *TPMDiscovery {
+	return &TPMDiscovery{Hints: []string{
+		"TPMs are only supported on Linux and Windows: use a simulator such as swtpm instead (e.g. swtpm:host=localhost,port=2321)",
+	}}
+}
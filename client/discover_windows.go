@@ -0,0 +1,44 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/google/go-tpm/tpmutil/tbs"
+)
+
+// tbsDevicePath is the TPMDevice.Path of the TPM Base Services.
+const tbsDevicePath = "TBS"
+
+// DiscoverTPM checks whether the TPM Base Services (TBS) can find a TPM, and
+// its version, returning remediation hints for any problems found.
+func DiscoverTPM() *TPMDiscovery {
+	device := TPMDevice{Path: tbsDevicePath}
+	discovery := &TPMDiscovery{}
+	info, err := tbs.GetDeviceInfo()
+	switch {
+	case err == nil:
+		device.Exists, device.Accessible = true, true
+		device.Version = int(info.TPMVersion)
+		discovery.KernelFound = true
+		if info.TPMVersion == tbs.TPMVersion12 {
+			discovery.Hints = append(discovery.Hints, "the TPM is a TPM 1.2, but only TPM 2.0 is supported")
+		}
+	case errors.Is(err, tbs.ErrTPMNotFound):
+		discovery.Hints = append(discovery.Hints,
+			"Windows found no TPM: enable the TPM (also called fTPM, PTT or Security Chip) in the firmware settings, or add a virtual TPM to the VM, then check its status in tpm.msc")
+	case errors.Is(err, tbs.ErrServiceNotRunning), errors.Is(err, tbs.ErrServiceDisabled):
+		device.Exists = true
+		discovery.KernelFound = true
+		discovery.Hints = append(discovery.Hints,
+			"the TPM Base Services are not running: start the TBS service (sc.exe start TBS) and set its start type to automatic")
+	case errors.Is(err, tbs.ErrAccessDenied):
+		device.Exists = true
+		discovery.KernelFound = true
+		discovery.Hints = append(discovery.Hints,
+			"access to the TPM was denied: run as an administrator, or check the TBS command blocking group policy")
+	default:
+		discovery.Hints = append(discovery.Hints, "querying the TPM Base Services failed: "+err.Error())
+	}
+	discovery.Devices = []TPMDevice{device}
+	return discovery
+}
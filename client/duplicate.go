@@ -0,0 +1,330 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/policy"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// ErrUnconstrainedDuplicate is returned (wrapped) by ImportDuplicate when the
+// duplicated key could be duplicated again to a parent other than its new
+// parent.
+var ErrUnconstrainedDuplicate = errors.New("duplicated key is not constrained to its new parent")
+
+// flagEncryptedDuplication is the encryptedDuplication object attribute, which
+// go-tpm does not define.
+const flagEncryptedDuplication tpm2.KeyProp = 0x00000800
+
+// innerWrapper is the symmetric algorithm of the inner wrapper of duplicates of
+// keys with the encryptedDuplication attribute.
+var innerWrapper = tpm2.SymScheme{Alg: tpm2.AlgAES, KeyBits: 128, Mode: tpm2.AlgCFB}
+
+// maxNewParents is the most new parents a duplication policy can name, as each
+// is a branch of a single TPM2_PolicyOR.
+const maxNewParents = 8
+
+// DuplicationOpts configures which parents a key created by
+// NewDuplicableKeyWithOpts can be duplicated to, and when.
+type DuplicationOpts struct {
+	// The storage keys (usually of other TPMs) the key can be duplicated to,
+	// between 1 and 8. For key escrow, these are the escrow service's storage
+	// key and the SRKs of the machines the key may later be migrated to. A key
+	// imported under one of them can be duplicated again to any of the others.
+	NewParents []tpm2.Public
+	// If set, the key can only be duplicated while these PCRs have these
+	// values, such as during a known good boot of the machine. This also
+	// applies to duplicating the key again from its new parent's TPM.
+	PCRs *pb.PCRs
+	// If set, the key has the encryptedDuplication attribute, so the TPM only
+	// duplicates it with an inner wrapper, whose key Key.DuplicateWithOpts
+	// returns separately from the blob. Both are needed to import the key, so
+	// they can be sent through different channels, or the inner key kept by
+	// the key's owner until the key needs to be recovered.
+	EncryptedDuplication bool
+}
+
+// DuplicationPolicy returns the auth policy of a key which can only be
+// duplicated to newParent: a TPM2_PolicyDuplicationSelect policy naming the new
+// parent, using the key's name algorithm.
+func DuplicationPolicy(nameAlg tpm2.Algorithm, newParent tpm2.Public) ([]byte, error) {
+	return DuplicationPolicyWithOpts(nameAlg, DuplicationOpts{NewParents: []tpm2.Public{newParent}})
+}
+
+// DuplicationPolicyWithOpts returns the auth policy of a key which can only be
+// duplicated as configured by opts, using the key's name algorithm. Each new
+// parent is a branch of TPM2_PolicyPCR (if opts.PCRs is set) followed by
+// TPM2_PolicyDuplicationSelect naming that parent. With several new parents,
+// the policy is the TPM2_PolicyOR of the branches.
+func DuplicationPolicyWithOpts(nameAlg tpm2.Algorithm, opts DuplicationOpts) ([]byte, error) {
+	hash, err := nameAlg.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("invalid name algorithm: %w", err)
+	}
+	branches, err := duplicationBranches(nameAlg, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(branches) == 1 {
+		return branches[0], nil
+	}
+	return policy.New(hash).ORDigests(branches...).Digest()
+}
+
+// duplicationBranches returns the digest of the duplication policy branch of
+// each of the new parents in opts.
+func duplicationBranches(nameAlg tpm2.Algorithm, opts DuplicationOpts) ([][]byte, error) {
+	hash, err := nameAlg.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("invalid name algorithm: %w", err)
+	}
+	if len(opts.NewParents) == 0 || len(opts.NewParents) > maxNewParents {
+		return nil, fmt.Errorf("a duplicable key must have between 1 and %d new parents, got %d", maxNewParents, len(opts.NewParents))
+	}
+	branches := make([][]byte, len(opts.NewParents))
+	for i, newParent := range opts.NewParents {
+		newParentName, err := policy.ObjectName(newParent)
+		if err != nil {
+			return nil, fmt.Errorf("invalid new parent %d: %w", i, err)
+		}
+		branch := policy.New(hash)
+		if opts.PCRs != nil {
+			branch.PCRValues(opts.PCRs)
+		}
+		if branches[i], err = branch.DuplicationSelect(nil, newParentName, false).Digest(); err != nil {
+			return nil, err
+		}
+	}
+	return branches, nil
+}
+
+// NewDuplicableKey creates a key from the template under the parent, which can
+// later be duplicated (see Key.Duplicate), but only to newParent. This is
+// usually the SRK of another TPM, whose public area has been sent to this
+// machine. The parent must be a storage key usable with an empty password,
+// such as an SRK.
+//
+// The key's fixedTPM and fixedParent attributes are cleared, and its auth
+// policy only allows TPM2_Duplicate to newParent, so that neither this
+// machine's OS nor anyone else can export the key to a parent of their
+// choosing. The key is used with an empty password, so the template must not
+// have an auth policy.
+func NewDuplicableKey(rw io.ReadWriter, parent tpmutil.Handle, template tpm2.Public, newParent tpm2.Public) (*Key, error) {
+	return NewDuplicableKeyWithOpts(rw, parent, template, DuplicationOpts{NewParents: []tpm2.Public{newParent}})
+}
+
+// NewDuplicableKeyWithOpts is like NewDuplicableKey, but the key can be
+// duplicated to any of several new parents, only while the PCRs have certain
+// values, or only with an inner wrapper, as configured by opts. Such keys are
+// duplicated with Key.DuplicateWithOpts, and imported with
+// Key.ImportDuplicateWithOpts, using the same opts.
+func NewDuplicableKeyWithOpts(rw io.ReadWriter, parent tpmutil.Handle, template tpm2.Public, opts DuplicationOpts) (*Key, error) {
+	if len(template.AuthPolicy) != 0 {
+		return nil, errors.New("template for a duplicable key must not have an auth policy")
+	}
+	policy, err := DuplicationPolicyWithOpts(template.NameAlg, opts)
+	if err != nil {
+		return nil, err
+	}
+	template.AuthPolicy = policy
+	template.Attributes &^= tpm2.FlagFixedTPM | tpm2.FlagFixedParent
+	template.Attributes |= tpm2.FlagUserWithAuth
+	if opts.EncryptedDuplication {
+		template.Attributes |= flagEncryptedDuplication
+	}
+
+	priv, pub, _, _, _, err := tpm2.CreateKey(rw, parent, tpm2.PCRSelection{}, "", "", template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key under parent 0x%x: %w", parent, err)
+	}
+	handle, _, err := tpm2.Load(rw, parent, "", pub, priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key under parent 0x%x: %w", parent, err)
+	}
+	return childKey(rw, handle, parent, pub, priv, nullSession{})
+}
+
+// Duplicate exports a key created by NewDuplicableKey, wrapped so that it can
+// only be imported under newParent (with the new parent's Key.ImportDuplicate).
+// The TPM refuses to duplicate the key to any parent other than the one named
+// by its auth policy. The key's sensitive area is never exposed outside of the
+// two TPMs.
+func (k *Key) Duplicate(newParent tpm2.Public) (*pb.ImportBlob, error) {
+	blob, _, err := k.DuplicateWithOpts(newParent, DuplicationOpts{NewParents: []tpm2.Public{newParent}})
+	return blob, err
+}
+
+// DuplicateWithOpts exports a key created by NewDuplicableKeyWithOpts (or
+// imported by Key.ImportDuplicateWithOpts) with the same opts, wrapped so that
+// it can only be imported under newParent, one of opts.NewParents. If the key
+// has the encryptedDuplication attribute, the duplicate also has an inner
+// wrapper, whose key is returned; otherwise, the returned key is nil.
+func (k *Key) DuplicateWithOpts(newParent tpm2.Public, opts DuplicationOpts) (*pb.ImportBlob, []byte, error) {
+	branches, err := duplicationBranches(k.pubArea.NameAlg, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if want, err := DuplicationPolicyWithOpts(k.pubArea.NameAlg, opts); err != nil {
+		return nil, nil, err
+	} else if !bytes.Equal(k.pubArea.AuthPolicy, want) {
+		return nil, nil, errors.New("key's auth policy is not the duplication policy of the options")
+	}
+	objectName, err := k.name.Digest.Encode()
+	if err != nil {
+		return nil, nil, err
+	}
+	newParentName, err := policy.ObjectName(newParent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid new parent: %w", err)
+	}
+	publicArea, err := k.pubArea.Encode()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Only the new parent's public area is needed to wrap the key to it.
+	parentHandle, _, err := tpm2.LoadExternal(k.rw, newParent, tpm2.Private{}, tpm2.HandleNull)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load new parent: %w", err)
+	}
+	defer tpm2.FlushContext(k.rw, parentHandle)
+
+	session, err := startAuthSession(k.rw)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tpm2.FlushContext(k.rw, session)
+	if opts.PCRs != nil {
+		sel := tpm2.PCRSelection{Hash: tpm2.Algorithm(opts.PCRs.GetHash())}
+		for pcr := range opts.PCRs.GetPcrs() {
+			sel.PCRs = append(sel.PCRs, int(pcr))
+		}
+		if err := tpm2.PolicyPCR(k.rw, session, nil, sel); err != nil {
+			return nil, nil, fmt.Errorf("PolicyPCR failed: %w", err)
+		}
+	}
+	if _, err = internal.RunCommand(k.rw, internal.CmdPolicyDuplicationSelect, []tpmutil.Handle{session}, nil,
+		tpmutil.U16Bytes(objectName), tpmutil.U16Bytes(newParentName), byte(0)); err != nil {
+		return nil, nil, fmt.Errorf("PolicyDuplicationSelect failed: %w", err)
+	}
+	if len(branches) > 1 {
+		digests := tpm2.TPMLDigest{}
+		for _, branch := range branches {
+			digests.Digests = append(digests.Digests, branch)
+		}
+		if err := tpm2.PolicyOr(k.rw, session, digests); err != nil {
+			return nil, nil, fmt.Errorf("PolicyOR failed (is the new parent in the options?): %w", err)
+		}
+	}
+
+	// The TPM generates the inner wrapper's key, as encryptionKeyIn is empty.
+	// Without an inner wrapper (symmetricAlg is TPM_ALG_NULL), the key is only
+	// protected by the outer wrapper to the new parent.
+	symmetricAlg := []interface{}{tpm2.AlgNull}
+	if k.pubArea.Attributes&flagEncryptedDuplication != 0 {
+		symmetricAlg = []interface{}{innerWrapper.Alg, uint16(innerWrapper.KeyBits), innerWrapper.Mode}
+	}
+	auth := tpm2.AuthCommand{Session: session, Attributes: tpm2.AttrContinueSession}
+	resp, err := internal.RunCommand(k.rw, internal.CmdDuplicate, []tpmutil.Handle{k.handle, parentHandle},
+		[]tpm2.AuthCommand{auth}, append([]interface{}{tpmutil.U16Bytes(nil)}, symmetricAlg...)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to duplicate key: %w", err)
+	}
+	var encryptionKey, duplicate, seed tpmutil.U16Bytes
+	if _, err := tpmutil.Unpack(resp, &encryptionKey, &duplicate, &seed); err != nil {
+		return nil, nil, fmt.Errorf("decoding duplicate: %w", err)
+	}
+	blob := &pb.ImportBlob{
+		Duplicate:     duplicate,
+		EncryptedSeed: seed,
+		PublicArea:    publicArea,
+	}
+	if len(encryptionKey) == 0 {
+		return blob, nil, nil
+	}
+	return blob, encryptionKey, nil
+}
+
+// ImportDuplicate imports and loads a key duplicated to this key (usually an
+// SRK) by Key.Duplicate, returning the loaded key.
+//
+// Before importing, it checks that the duplicated key's auth policy is that of
+// NewDuplicableKey, naming this key as the new parent. As this key is its
+// parent once imported, the duplicated key cannot be exported again. Keys which
+// could be (such as keys with other auth policies) are rejected with
+// ErrUnconstrainedDuplicate.
+func (k *Key) ImportDuplicate(blob *pb.ImportBlob) (*Key, error) {
+	return k.ImportDuplicateWithOpts(blob, nil, DuplicationOpts{NewParents: []tpm2.Public{k.pubArea}})
+}
+
+// ImportDuplicateWithOpts imports and loads a key duplicated to this key by
+// Key.DuplicateWithOpts, returning the loaded key. The innerKey is the key of
+// the duplicate's inner wrapper, if the duplicated key has the
+// encryptedDuplication attribute.
+//
+// Before importing, it checks that this key is one of opts.NewParents, and that
+// the duplicated key's auth policy and encryptedDuplication attribute are those
+// of NewDuplicableKeyWithOpts with the same opts, so that it can only be
+// duplicated again to opts.NewParents. Keys which could be duplicated
+// elsewhere are rejected with ErrUnconstrainedDuplicate.
+func (k *Key) ImportDuplicateWithOpts(blob *pb.ImportBlob, innerKey []byte, opts DuplicationOpts) (*Key, error) {
+	public, err := tpm2.DecodePublic(blob.GetPublicArea())
+	if err != nil {
+		return nil, fmt.Errorf("invalid public area: %w", err)
+	}
+	if err := checkDuplicationPolicy(public, k.pubArea, opts); err != nil {
+		return nil, err
+	}
+	var sym *tpm2.SymScheme
+	if public.Attributes&flagEncryptedDuplication != 0 {
+		if len(innerKey) == 0 {
+			return nil, errors.New("the inner wrapper's key is needed to import a key with encryptedDuplication")
+		}
+		sym = &innerWrapper
+	} else {
+		innerKey = nil
+	}
+	handle, private, err := loadHandle(k, blob, innerKey, sym)
+	if err != nil {
+		return nil, err
+	}
+	return childKey(k.rw, handle, k.handle, blob.GetPublicArea(), private, nullSession{})
+}
+
+// checkDuplicationPolicy checks that a duplicated object can only be
+// duplicated to the new parents in opts, one of which is its new parent.
+func checkDuplicationPolicy(public, newParent tpm2.Public, opts DuplicationOpts) error {
+	if public.Attributes&(tpm2.FlagFixedTPM|tpm2.FlagFixedParent) != 0 {
+		return errors.New("key is not duplicable")
+	}
+	if opts.EncryptedDuplication && public.Attributes&flagEncryptedDuplication == 0 {
+		return fmt.Errorf("%w: it does not have the encryptedDuplication attribute", ErrUnconstrainedDuplicate)
+	}
+	newParentName, err := policy.ObjectName(newParent)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, parent := range opts.NewParents {
+		if name, err := policy.ObjectName(parent); err == nil && bytes.Equal(name, newParentName) {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: this parent is not one of its new parents", ErrUnconstrainedDuplicate)
+	}
+	want, err := DuplicationPolicyWithOpts(public.NameAlg, opts)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(public.AuthPolicy, want) {
+		return fmt.Errorf("%w: its auth policy is not a duplication policy for this parent", ErrUnconstrainedDuplicate)
+	}
+	return nil
+}
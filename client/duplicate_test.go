@@ -0,0 +1,315 @@
+package client_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+func TestPolicyDuplicationSelect(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	objectName, err := srk.Name().Digest.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newParentName := append([]byte{0x00, 0x0b}, bytes.Repeat([]byte{0xab}, sha256.Size)...)
+
+	for _, includeObject := range []bool{false, true} {
+		session, _, err := tpm2.StartAuthSession(rwc, tpm2.HandleNull, tpm2.HandleNull,
+			make([]byte, sha256.Size), nil, tpm2.SessionTrial, tpm2.AlgNull, tpm2.AlgSHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		include := byte(0)
+		if includeObject {
+			include = 1
+		}
+		_, err = internal.RunCommand(rwc, internal.CmdPolicyDuplicationSelect, []tpmutil.Handle{session}, nil,
+			tpmutil.U16Bytes(objectName), tpmutil.U16Bytes(newParentName), include)
+		if err != nil {
+			tpm2.FlushContext(rwc, session)
+			t.Fatal(err)
+		}
+		digest, err := tpm2.PolicyGetDigest(rwc, session)
+		tpm2.FlushContext(rwc, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := internal.PolicyDuplicationSelect(nil, objectName, newParentName, includeObject, crypto.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, digest) {
+			t.Errorf("PolicyDuplicationSelect(includeObject=%v) = %x, want %x", includeObject, got, digest)
+		}
+	}
+}
+
+func duplicableTemplate() tpm2.Public {
+	template := client.DevIDTemplateECC()
+	template.AuthPolicy = nil
+	return template
+}
+
+func TestDuplicateKey(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	// Duplicate between the SRKs of one TPM, standing in for two TPMs.
+	srcSRK, err := client.StorageRootKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srcSRK.Close()
+	dstSRK, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dstSRK.Close()
+
+	key, err := client.NewDuplicableKey(rwc, srcSRK.Handle(), duplicableTemplate(), dstSRK.PublicArea())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+	if attrs := key.PublicArea().Attributes; attrs&(tpm2.FlagFixedTPM|tpm2.FlagFixedParent) != 0 {
+		t.Errorf("duplicable key has attributes %v, want fixedTPM and fixedParent clear", attrs)
+	}
+
+	blob, err := key.Duplicate(dstSRK.PublicArea())
+	if err != nil {
+		t.Fatalf("Duplicate() failed: %v", err)
+	}
+	imported, err := dstSRK.ImportDuplicate(blob)
+	if err != nil {
+		t.Fatalf("ImportDuplicate() failed: %v", err)
+	}
+	defer imported.Close()
+
+	digest := sha256.Sum256([]byte("duplicated"))
+	signer, err := imported.GetSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := signer.Sign(nil, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ecdsa.VerifyASN1(key.PublicKey().(*ecdsa.PublicKey), digest[:], sig) {
+		t.Error("signature of the imported key does not verify with the original key")
+	}
+
+	// The imported key cannot be duplicated onward, even back to its source.
+	if _, err := imported.Duplicate(srcSRK.PublicArea()); err == nil {
+		t.Error("Duplicate() of the imported key to another parent should fail")
+	}
+}
+
+func TestDuplicateConstrainedToNewParent(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	srkRSA, err := client.StorageRootKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srkRSA.Close()
+	srkECC, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srkECC.Close()
+
+	key, err := client.NewDuplicableKey(rwc, srkRSA.Handle(), duplicableTemplate(), srkECC.PublicArea())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+
+	// The TPM refuses to duplicate the key to any other parent.
+	if _, err := key.Duplicate(srkRSA.PublicArea()); err == nil {
+		t.Error("Duplicate() to a parent not in the key's policy should fail")
+	}
+
+	blob, err := key.Duplicate(srkECC.PublicArea())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srkRSA.ImportDuplicate(blob); !errors.Is(err, client.ErrUnconstrainedDuplicate) {
+		t.Errorf("ImportDuplicate() under another parent = %v, want ErrUnconstrainedDuplicate", err)
+	}
+
+	// Keys which could be duplicated to another parent are rejected.
+	public := key.PublicArea()
+	if public.AuthPolicy, err = internal.PolicyDuplicationSelect(nil, nil, make([]byte, 2+sha256.Size), false, crypto.SHA256); err != nil {
+		t.Fatal(err)
+	}
+	if blob.PublicArea, err = public.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srkECC.ImportDuplicate(blob); !errors.Is(err, client.ErrUnconstrainedDuplicate) {
+		t.Errorf("ImportDuplicate() of a key with another policy = %v, want ErrUnconstrainedDuplicate", err)
+	}
+
+	if _, err := client.NewDuplicableKey(rwc, srkRSA.Handle(), client.DevIDTemplateECC(), srkECC.PublicArea()); err == nil {
+		t.Error("NewDuplicableKey() with a template with an auth policy should fail")
+	}
+}
+
+func TestDuplicateWithOpts(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	// The key is created under one storage key, escrowed to a second, and
+	// recovered from escrow to a third, standing in for three TPMs.
+	srcSRK, err := client.StorageRootKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	escrow, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer escrow.Close()
+	targetTemplate := client.SRKTemplateECC()
+	targetTemplate.NameAlg = tpm2.AlgSHA384
+	targetTemplate.ECCParameters.CurveID = tpm2.CurveNISTP384
+	target, err := client.NewKey(rwc, tpm2.HandleOwner, targetTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	pcrs, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{test.DebugPCR}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := client.DuplicationOpts{
+		NewParents:           []tpm2.Public{escrow.PublicArea(), target.PublicArea()},
+		PCRs:                 pcrs,
+		EncryptedDuplication: true,
+	}
+	key, err := client.NewDuplicableKeyWithOpts(rwc, srcSRK.Handle(), duplicableTemplate(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.PublicKey()
+
+	if _, _, err := key.DuplicateWithOpts(srcSRK.PublicArea(), opts); err == nil {
+		t.Error("DuplicateWithOpts() to a parent not in the options should fail")
+	}
+	if _, err := key.Duplicate(escrow.PublicArea()); err == nil {
+		t.Error("Duplicate() with other options than the key's should fail")
+	}
+	blob, innerKey, err := key.DuplicateWithOpts(escrow.PublicArea(), opts)
+	if err != nil {
+		t.Fatalf("DuplicateWithOpts() failed: %v", err)
+	}
+	if len(innerKey) == 0 {
+		t.Fatal("DuplicateWithOpts() of a key with encryptedDuplication returned no inner key")
+	}
+
+	// The blob alone is not enough to import the key.
+	if _, err := escrow.ImportDuplicateWithOpts(blob, nil, opts); err == nil {
+		t.Error("ImportDuplicateWithOpts() without the inner key should fail")
+	}
+	if _, err := escrow.ImportDuplicateWithOpts(blob, make([]byte, len(innerKey)), opts); err == nil {
+		t.Error("ImportDuplicateWithOpts() with the wrong inner key should fail")
+	}
+	if _, err := srcSRK.ImportDuplicateWithOpts(blob, innerKey, opts); !errors.Is(err, client.ErrUnconstrainedDuplicate) {
+		t.Errorf("ImportDuplicateWithOpts() under a parent not in the options = %v, want ErrUnconstrainedDuplicate", err)
+	}
+	withoutPCRs := opts
+	withoutPCRs.PCRs = nil
+	if _, err := escrow.ImportDuplicateWithOpts(blob, innerKey, withoutPCRs); !errors.Is(err, client.ErrUnconstrainedDuplicate) {
+		t.Errorf("ImportDuplicateWithOpts() with other options = %v, want ErrUnconstrainedDuplicate", err)
+	}
+	// Free object slots in the TPM for the copies of the key.
+	key.Close()
+	srcSRK.Close()
+	escrowed, err := escrow.ImportDuplicateWithOpts(blob, innerKey, opts)
+	if err != nil {
+		t.Fatalf("ImportDuplicateWithOpts() failed: %v", err)
+	}
+	defer escrowed.Close()
+
+	// The escrowed key can be recovered to the other new parent.
+	blob, innerKey, err = escrowed.DuplicateWithOpts(target.PublicArea(), opts)
+	if err != nil {
+		t.Fatalf("DuplicateWithOpts() of the escrowed key failed: %v", err)
+	}
+	recovered, err := target.ImportDuplicateWithOpts(blob, innerKey, opts)
+	if err != nil {
+		t.Fatalf("ImportDuplicateWithOpts() of the escrowed key failed: %v", err)
+	}
+	defer recovered.Close()
+	digest := sha256.Sum256([]byte("recovered"))
+	signer, err := recovered.GetSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := signer.Sign(nil, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], sig) {
+		t.Error("signature of the recovered key does not verify with the original key")
+	}
+
+	// Once the PCRs change, the key can no longer be exported.
+	if err := tpm2.PCRExtend(rwc, tpmutil.Handle(test.DebugPCR), tpm2.AlgSHA256, bytes.Repeat([]byte{0x01}, sha256.Size), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := escrowed.DuplicateWithOpts(target.PublicArea(), opts); err == nil {
+		t.Error("DuplicateWithOpts() after the PCRs changed should fail")
+	}
+}
+
+func TestDuplicationPolicyWithOpts(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+
+	if _, err := client.DuplicationPolicyWithOpts(tpm2.AlgSHA256, client.DuplicationOpts{}); err == nil {
+		t.Error("DuplicationPolicyWithOpts() without new parents should fail")
+	}
+	parents := make([]tpm2.Public, 9)
+	for i := range parents {
+		parents[i] = srk.PublicArea()
+	}
+	if _, err := client.DuplicationPolicyWithOpts(tpm2.AlgSHA256, client.DuplicationOpts{NewParents: parents}); err == nil {
+		t.Error("DuplicationPolicyWithOpts() with 9 new parents should fail")
+	}
+	single, err := client.DuplicationPolicy(tpm2.AlgSHA256, srk.PublicArea())
+	if err != nil {
+		t.Fatal(err)
+	}
+	withOpts, err := client.DuplicationPolicyWithOpts(tpm2.AlgSHA256, client.DuplicationOpts{NewParents: parents[:1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(single, withOpts) {
+		t.Errorf("DuplicationPolicyWithOpts() with a single parent = %x, want DuplicationPolicy() = %x", withOpts, single)
+	}
+}
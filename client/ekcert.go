@@ -0,0 +1,230 @@
+package client
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-tpm/tpm2"
+)
+
+// EKMismatchError is returned when an EK does not match the public key of an
+// EK certificate, describing the first difference between them.
+type EKMismatchError struct {
+	// The part of the key which differs: "key type", "RSA key size", "RSA
+	// modulus", "RSA exponent", "ECC curve" or "ECC point". If the keys are
+	// equal, but the certificate's SubjectPublicKeyInfo encodes the key
+	// differently, it is "algorithm identifier" or "encoding".
+	Field string
+	// The certificate's and the EK's values of the field. Long values, such as
+	// moduli, are abbreviated.
+	Cert string
+	EK   string
+}
+
+func (e *EKMismatchError) Error() string {
+	return fmt.Sprintf("EK %s (%s) does not match the EK certificate's (%s)", e.Field, e.EK, e.Cert)
+}
+
+// CompareEKPublicKey compares an EK's public key byte-for-byte with the
+// SubjectPublicKeyInfo of its EK certificate, returning an *EKMismatchError
+// describing the difference if they do not match.
+func CompareEKPublicKey(cert *x509.Certificate, ek crypto.PublicKey) error {
+	ekSPKI, err := x509.MarshalPKIXPublicKey(ek)
+	if err != nil {
+		return fmt.Errorf("failed to encode EK: %w", err)
+	}
+	if bytes.Equal(ekSPKI, cert.RawSubjectPublicKeyInfo) {
+		return nil
+	}
+	mismatch := func(field, certValue, ekValue string) error {
+		return &EKMismatchError{Field: field, Cert: certValue, EK: ekValue}
+	}
+
+	switch certKey := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		ekKey, ok := ek.(*rsa.PublicKey)
+		switch {
+		case !ok:
+			return mismatch("key type", "RSA", keyTypeName(ek))
+		case certKey.Size() != ekKey.Size():
+			return mismatch("RSA key size", fmt.Sprint(certKey.Size()*8), fmt.Sprint(ekKey.Size()*8))
+		case certKey.N.Cmp(ekKey.N) != 0:
+			return mismatch("RSA modulus", abbreviate(certKey.N.Bytes()), abbreviate(ekKey.N.Bytes()))
+		case certKey.E != ekKey.E:
+			return mismatch("RSA exponent", fmt.Sprint(certKey.E), fmt.Sprint(ekKey.E))
+		}
+	case *ecdsa.PublicKey:
+		ekKey, ok := ek.(*ecdsa.PublicKey)
+		switch {
+		case !ok:
+			return mismatch("key type", "ECC", keyTypeName(ek))
+		case certKey.Curve != ekKey.Curve:
+			return mismatch("ECC curve", certKey.Curve.Params().Name, ekKey.Curve.Params().Name)
+		case certKey.X.Cmp(ekKey.X) != 0 || certKey.Y.Cmp(ekKey.Y) != 0:
+			return mismatch("ECC point", abbreviate(certKey.X.Bytes()), abbreviate(ekKey.X.Bytes()))
+		}
+	default:
+		return mismatch("key type", cert.PublicKeyAlgorithm.String(), keyTypeName(ek))
+	}
+
+	// The keys are equal, but are encoded differently: for example, an RSA
+	// key with the id-RSAES-OAEP algorithm.
+	var certInfo, ekInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &certInfo); err != nil {
+		return mismatch("encoding", "invalid SubjectPublicKeyInfo", fmt.Sprintf("%d bytes", len(ekSPKI)))
+	}
+	if _, err := asn1.Unmarshal(ekSPKI, &ekInfo); err != nil {
+		return fmt.Errorf("failed to decode EK: %w", err)
+	}
+	if !certInfo.Algorithm.Algorithm.Equal(ekInfo.Algorithm.Algorithm) ||
+		!bytes.Equal(certInfo.Algorithm.Parameters.FullBytes, ekInfo.Algorithm.Parameters.FullBytes) {
+		return mismatch("algorithm identifier", algorithmName(certInfo.Algorithm), algorithmName(ekInfo.Algorithm))
+	}
+	return mismatch("encoding", fmt.Sprintf("%d bytes", len(cert.RawSubjectPublicKeyInfo)), fmt.Sprintf("%d bytes", len(ekSPKI)))
+}
+
+func keyTypeName(pub crypto.PublicKey) string {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return "RSA"
+	case *ecdsa.PublicKey:
+		return "ECC"
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
+
+func algorithmName(id pkix.AlgorithmIdentifier) string {
+	if len(id.Parameters.FullBytes) == 0 {
+		return id.Algorithm.String()
+	}
+	return fmt.Sprintf("%v with parameters %x", id.Algorithm, id.Parameters.FullBytes)
+}
+
+// abbreviate returns the hex encoding of the start and end of a long value.
+func abbreviate(value []byte) string {
+	if len(value) <= 8 {
+		return fmt.Sprintf("%x", value)
+	}
+	return fmt.Sprintf("%x...%x", value[:4], value[len(value)-4:])
+}
+
+// EKVariant is one of the templates from which a TPM may have created the EK
+// certified by its EK certificate.
+type EKVariant struct {
+	// The template's name in the TCG EK Credential Profile (such as "L-1" or
+	// "H-2"), or "NV-provisioned" for the template returned by
+	// ProvisionedEKTemplate.
+	Name        string
+	Range       EKTemplateRange
+	Provisioned bool
+	Template    tpm2.Public
+}
+
+// EKCandidate is the result of recreating the EK from an EKVariant, and
+// comparing it with an EK certificate.
+type EKCandidate struct {
+	EKVariant
+	// The recreated EK, or nil if the EK could not be created.
+	PublicKey crypto.PublicKey
+	// Nil if the EK matches the certificate. Otherwise, the error creating
+	// the EK, or an *EKMismatchError describing how the EK differs.
+	Err error
+}
+
+// EKCertificateCheck is the result of CheckEKCertificate.
+type EKCertificateCheck struct {
+	// The variant whose EK matches the certificate, or nil if none does.
+	Match *EKCandidate
+	// The variants tried, in order, up to and including the match.
+	Candidates []*EKCandidate
+}
+
+// Err returns nil if an EK matched the certificate, or an error describing
+// how each EK differs from it.
+func (c *EKCertificateCheck) Err() error {
+	if c.Match != nil {
+		return nil
+	}
+	var reasons []string
+	for _, candidate := range c.Candidates {
+		reasons = append(reasons, fmt.Sprintf("%s: %v", candidate.Name, candidate.Err))
+	}
+	return fmt.Errorf("no EK template matches the EK certificate: %s", strings.Join(reasons, "; "))
+}
+
+// CheckEKCertificate recreates the EK from each template the TPM may have
+// used (the manufacturer-provisioned template if any, then the low and high
+// range templates of the certificate's key type), and compares each EK with
+// the certificate's public key until one matches. The returned check reports
+// which variant matched, or how each EK differs from the certificate. The EKs
+// are created in the endorsement hierarchy, which must have an empty password.
+func CheckEKCertificate(rw io.ReadWriter, cert *x509.Certificate) (*EKCertificateCheck, error) {
+	var keyType tpm2.Algorithm
+	switch cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		keyType = tpm2.AlgRSA
+	case *ecdsa.PublicKey:
+		keyType = tpm2.AlgECC
+	default:
+		return nil, fmt.Errorf("unsupported EK certificate key type: %v", cert.PublicKeyAlgorithm)
+	}
+
+	var variants []EKVariant
+	provisioned, ok, err := ProvisionedEKTemplate(rw, keyType)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		variants = append(variants, EKVariant{Name: "NV-provisioned", Range: EKLowRange, Provisioned: true, Template: provisioned})
+	}
+	for _, r := range []EKTemplateRange{EKLowRange, EKHighRange} {
+		template, err := EKTemplate(keyType, r)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, EKVariant{Name: ekTemplateName(keyType, r), Range: r, Template: template})
+	}
+
+	check := &EKCertificateCheck{}
+	for _, variant := range variants {
+		candidate := &EKCandidate{EKVariant: variant}
+		check.Candidates = append(check.Candidates, candidate)
+		ek, err := NewKey(rw, tpm2.HandleEndorsement, variant.Template)
+		if err != nil {
+			candidate.Err = fmt.Errorf("failed to create EK: %w", err)
+			continue
+		}
+		candidate.PublicKey = ek.PublicKey()
+		ek.Close()
+		if candidate.Err = CompareEKPublicKey(cert, candidate.PublicKey); candidate.Err == nil {
+			check.Match = candidate
+			break
+		}
+	}
+	return check, nil
+}
+
+// ekTemplateName returns the name of an EK template in the TCG EK Credential
+// Profile.
+func ekTemplateName(keyType tpm2.Algorithm, r EKTemplateRange) string {
+	prefix := "L"
+	if r == EKHighRange {
+		prefix = "H"
+	}
+	if keyType == tpm2.AlgECC {
+		return prefix + "-2"
+	}
+	return prefix + "-1"
+}
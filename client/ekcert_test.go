@@ -0,0 +1,210 @@
+package client_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// certForKey returns a certificate with the given public key, which is all
+// CheckEKCertificate uses.
+func certForKey(t *testing.T, pub crypto.PublicKey) *x509.Certificate {
+	t.Helper()
+	spki, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &x509.Certificate{PublicKey: pub, RawSubjectPublicKeyInfo: spki}
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		cert.PublicKeyAlgorithm = x509.RSA
+	case *ecdsa.PublicKey:
+		cert.PublicKeyAlgorithm = x509.ECDSA
+	}
+	return cert
+}
+
+func createEKPublicKey(t *testing.T, rwc io.ReadWriter, keyType tpm2.Algorithm, r client.EKTemplateRange) crypto.PublicKey {
+	t.Helper()
+	ek, err := client.EndorsementKey(rwc, keyType, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ek.Close()
+	return ek.PublicKey()
+}
+
+func TestCheckEKCertificate(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	tests := []struct {
+		keyType    tpm2.Algorithm
+		r          client.EKTemplateRange
+		match      string
+		candidates int
+	}{
+		{tpm2.AlgRSA, client.EKLowRange, "L-1", 1},
+		{tpm2.AlgECC, client.EKLowRange, "L-2", 1},
+		{tpm2.AlgRSA, client.EKHighRange, "H-1", 2},
+		{tpm2.AlgECC, client.EKHighRange, "H-2", 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.match, func(t *testing.T) {
+			cert := certForKey(t, createEKPublicKey(t, rwc, tc.keyType, tc.r))
+			check, err := client.CheckEKCertificate(rwc, cert)
+			if err != nil {
+				t.Fatalf("CheckEKCertificate() failed: %v", err)
+			}
+			if err := check.Err(); err != nil {
+				t.Fatalf("EK does not match: %v", err)
+			}
+			if check.Match.Name != tc.match || check.Match.Range != tc.r {
+				t.Errorf("matched %s (%v range), want %s", check.Match.Name, check.Match.Range, tc.match)
+			}
+			if len(check.Candidates) != tc.candidates {
+				t.Errorf("tried %d candidates, want %d", len(check.Candidates), tc.candidates)
+			}
+			var mismatch *client.EKMismatchError
+			for _, candidate := range check.Candidates[:len(check.Candidates)-1] {
+				if !errors.As(candidate.Err, &mismatch) {
+					t.Errorf("candidate %s failed with %v, want an EKMismatchError", candidate.Name, candidate.Err)
+				}
+			}
+		})
+	}
+
+	t.Run("Provisioned", func(t *testing.T) {
+		nonce := []byte("manufacturer nonce")
+		defer defineEKIndex(t, rwc, client.EKNonceNVIndexECC, nonce)()
+		template, _, err := client.ProvisionedEKTemplate(rwc, tpm2.AlgECC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ek, err := client.NewKey(rwc, tpm2.HandleEndorsement, template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert := certForKey(t, ek.PublicKey())
+		ek.Close()
+
+		check, err := client.CheckEKCertificate(rwc, cert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if check.Match == nil || !check.Match.Provisioned {
+			t.Errorf("EK certificate should match the NV-provisioned template: %v", check.Err())
+		}
+	})
+
+	t.Run("NoMatch", func(t *testing.T) {
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check, err := client.CheckEKCertificate(rwc, certForKey(t, &priv.PublicKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if check.Err() == nil {
+			t.Fatal("EK certificate for another key should not match")
+		}
+		if len(check.Candidates) != 2 {
+			t.Errorf("tried %d candidates, want 2", len(check.Candidates))
+		}
+		for _, candidate := range check.Candidates {
+			var mismatch *client.EKMismatchError
+			if !errors.As(candidate.Err, &mismatch) || mismatch.Field != "RSA modulus" {
+				t.Errorf("candidate %s failed with %v, want an RSA modulus mismatch", candidate.Name, candidate.Err)
+			}
+		}
+	})
+}
+
+func TestCompareEKPublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherExponent := rsaKey.PublicKey
+	otherExponent.E = 3
+	rsaKey3072, err := rsa.GenerateKey(rand.Reader, 3072)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherP256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same RSA key, encoded with the id-RSAES-OAEP algorithm, as some
+	// EK certificates are.
+	oaepCert := certForKey(t, &rsaKey.PublicKey)
+	var spki struct {
+		Algorithm struct {
+			Algorithm  asn1.ObjectIdentifier
+			Parameters asn1.RawValue `asn1:"optional"`
+		}
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(oaepCert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		t.Fatal(err)
+	}
+	spki.Algorithm.Algorithm = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}
+	spki.Algorithm.Parameters = asn1.RawValue{}
+	if oaepCert.RawSubjectPublicKeyInfo, err = asn1.Marshal(spki); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		cert  *x509.Certificate
+		ek    crypto.PublicKey
+		field string
+	}{
+		{"Match", certForKey(t, &rsaKey.PublicKey), &rsaKey.PublicKey, ""},
+		{"KeyType", certForKey(t, &rsaKey.PublicKey), &p256Key.PublicKey, "key type"},
+		{"KeySize", certForKey(t, &rsaKey.PublicKey), &rsaKey3072.PublicKey, "RSA key size"},
+		{"Exponent", certForKey(t, &otherExponent), &rsaKey.PublicKey, "RSA exponent"},
+		{"Curve", certForKey(t, &p256Key.PublicKey), &p384Key.PublicKey, "ECC curve"},
+		{"Point", certForKey(t, &p256Key.PublicKey), &otherP256Key.PublicKey, "ECC point"},
+		{"AlgorithmIdentifier", oaepCert, &rsaKey.PublicKey, "algorithm identifier"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := client.CompareEKPublicKey(tc.cert, tc.ek)
+			if tc.field == "" {
+				if err != nil {
+					t.Errorf("CompareEKPublicKey() failed: %v", err)
+				}
+				return
+			}
+			var mismatch *client.EKMismatchError
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("CompareEKPublicKey() returned %v, want an EKMismatchError", err)
+			}
+			if mismatch.Field != tc.field {
+				t.Errorf("mismatched field is %q, want %q (%v)", mismatch.Field, tc.field, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/google/go-tpm/tpmutil/tbs"
+)
+
+var tbsGetTCGLogEx = syscall.NewLazyDLL("Tbs.dll").NewProc("Tbsi_Get_TCG_Log_Ex")
+
+const (
+	// TBS_TCGLOG_SRTM_CURRENT, from tbs.h: the static root of trust log for the
+	// current boot, as opposed to the log from before hibernation, or the
+	// dynamic root of trust log.
+	tbsTCGLogSRTMCurrent = 0
+	// The registry value holding the Windows Boot Configuration Log (WBCL),
+	// which on TPM 2.0 machines is a crypto-agile TCG event log.
+	wbclKey   = `SYSTEM\CurrentControlSet\Control\IntegrityServices`
+	wbclValue = "WBCL"
+)
+
+// On Windows, the event log is held by the TPM Base Services. Older versions
+// of Windows lack Tbsi_Get_TCG_Log_Ex, and only expose the log in the
+// registry.
+func getRealEventLog() ([]byte, error) {
+	log, tbsErr := getSRTMLog()
+	if tbsErr == nil {
+		return log, nil
+	}
+	log, regErr := getWBCL()
+	if regErr == nil {
+		return log, nil
+	}
+	return nil, fmt.Errorf("failed to get event log: from TBS: %v, from the registry: %v", tbsErr, regErr)
+}
+
+func getSRTMLog() ([]byte, error) {
+	if err := tbsGetTCGLogEx.Find(); err != nil {
+		return nil, err
+	}
+	// The first call returns the size of the log.
+	var size uint32
+	if result, _, _ := tbsGetTCGLogEx.Call(tbsTCGLogSRTMCurrent, 0, uintptr(unsafe.Pointer(&size))); result != 0 {
+		return nil, tbs.Error(result)
+	}
+	if size == 0 {
+		return nil, errors.New("event log is empty")
+	}
+	log := make([]byte, size)
+	if result, _, _ := tbsGetTCGLogEx.Call(tbsTCGLogSRTMCurrent,
+		uintptr(unsafe.Pointer(&log[0])), uintptr(unsafe.Pointer(&size))); result != 0 {
+		return nil, tbs.Error(result)
+	}
+	return log[:size], nil
+}
+
+func getWBCL() ([]byte, error) {
+	keyName, err := syscall.UTF16PtrFromString(wbclKey)
+	if err != nil {
+		return nil, err
+	}
+	valueName, err := syscall.UTF16PtrFromString(wbclValue)
+	if err != nil {
+		return nil, err
+	}
+	var key syscall.Handle
+	if err := syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, keyName, 0, syscall.KEY_QUERY_VALUE, &key); err != nil {
+		return nil, err
+	}
+	defer syscall.RegCloseKey(key)
+
+	var valueType, size uint32
+	if err := syscall.RegQueryValueEx(key, valueName, nil, &valueType, nil, &size); err != nil {
+		return nil, err
+	}
+	if valueType != syscall.REG_BINARY || size == 0 {
+		return nil, fmt.Errorf("%s is not a binary value", wbclValue)
+	}
+	log := make([]byte, size)
+	if err := syscall.RegQueryValueEx(key, valueName, nil, &valueType, &log[0], &size); err != nil {
+		return nil, err
+	}
+	return log[:size], nil
+}
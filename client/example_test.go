@@ -1,12 +1,19 @@
 package client_test
 
 import (
+	"bufio"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
 	"io"
 	"log"
+	"math/big"
+	"time"
 
 	"github.com/google/go-tpm-tools/client"
 	"github.com/google/go-tpm-tools/internal"
@@ -118,7 +125,7 @@ func ExampleKey_Attest() {
 	}
 	defer ak.Close()
 
-	attestation, err := ak.Attest(client.AttestOpts{nonce})
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
 	if err != nil {
 		log.Fatalf("failed to attest: %v", err)
 	}
@@ -277,3 +284,112 @@ func ExampleKey_SignData() {
 	}
 	// Output:
 }
+
+// exampleCertificate issues a certificate for a TLS server and client.
+func exampleCertificate(name string, pub crypto.PublicKey, issuer *x509.Certificate, issuerKey crypto.Signer) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  issuer == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if issuer == nil {
+		issuer = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, pub, issuerKey)
+	if err != nil {
+		log.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		log.Fatalf("failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func ExampleKey_TLSCertificate() {
+	// TODO: use real TPMs, one for the server and one for the client.
+	simulator, err := simulator.Get()
+	if err != nil {
+		log.Fatalf("failed to initialize simulator: %v", err)
+	}
+	defer simulator.Close()
+
+	// A CA issues certificates for the server and client DevID keys, after
+	// checking they are in a trusted TPM (see Key.Certify).
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		log.Fatalf("failed to generate CA key: %v", err)
+	}
+	ca := exampleCertificate("Example CA", caKey.Public(), nil, caKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	serverKey, err := client.LDevIDKeyECC(simulator)
+	if err != nil {
+		log.Fatalf("failed to create server key: %v", err)
+	}
+	defer serverKey.Close()
+	serverCert, err := serverKey.TLSCertificate([][]byte{
+		exampleCertificate("server.example", serverKey.PublicKey(), ca, caKey).Raw,
+	})
+	if err != nil {
+		log.Fatalf("failed to create server certificate: %v", err)
+	}
+	clientKey, err := client.IDevIDKeyECC(simulator)
+	if err != nil {
+		log.Fatalf("failed to create client key: %v", err)
+	}
+	defer clientKey.Close()
+	clientCert, err := clientKey.TLSCertificate([][]byte{
+		exampleCertificate("client.example", clientKey.PublicKey(), ca, caKey).Raw,
+	})
+	if err != nil {
+		log.Fatalf("failed to create client certificate: %v", err)
+	}
+
+	// The server requires clients to have a certificate from the CA.
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    roots,
+	})
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	received := make(chan string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatalf("failed to accept: %v", err)
+		}
+		defer conn.Close()
+		message, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			log.Fatalf("failed to read: %v", err)
+		}
+		peer := conn.(*tls.Conn).ConnectionState().PeerCertificates[0].Subject.CommonName
+		received <- fmt.Sprintf("%s sent %q", peer, message)
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      roots,
+		ServerName:   "server.example",
+	})
+	if err != nil {
+		log.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello\n")); err != nil {
+		log.Fatalf("failed to write: %v", err)
+	}
+	fmt.Println(<-received)
+	// Output: client.example sent "hello\n"
+}
@@ -24,12 +24,49 @@ const (
 	DefaultAKRSAHandle = tpmutil.Handle(0x81008F01)
 )
 
-// NV Indices holding GCE AK Templates
+// ClearMarkerNVIndex is the owner NV index written by WriteClearMarker, in the
+// owner range, mirroring the go-tpm-tools range of persistent handles.
+const ClearMarkerNVIndex uint32 = 0x01008F00
+
+// Handles from the go-tpm-tools range used by NewPersistentKey.
+const (
+	FirstPersistentKeyHandle = tpmutil.Handle(0x81008F80)
+	LastPersistentKeyHandle  = tpmutil.Handle(0x81008FFF)
+)
+
+// Owner hierarchy persistent handles from TPM 2.0 Handles and Localities 2.3.1.
+const (
+	firstOwnerPersistentHandle = tpmutil.Handle(0x81000000)
+	lastOwnerPersistentHandle  = tpmutil.Handle(0x817FFFFF)
+)
+
+// NV Indices holding GCE AK Templates and certificates
 const (
+	GceAKCertNVIndexRSA     uint32 = 0x01c10000
 	GceAKTemplateNVIndexRSA uint32 = 0x01c10001
+	GceAKCertNVIndexECC     uint32 = 0x01c10002
 	GceAKTemplateNVIndexECC uint32 = 0x01c10003
 )
 
+// NV Indices holding EK certificates, nonces and templates from "TCG EK
+// Credential Profile for TPM Family 2.0" - Section 2.2.1.4
+const (
+	EKCertNVIndexRSA     uint32 = 0x01c00002
+	EKNonceNVIndexRSA    uint32 = 0x01c00003
+	EKTemplateNVIndexRSA uint32 = 0x01c00004
+	EKCertNVIndexECC     uint32 = 0x01c0000a
+	EKNonceNVIndexECC    uint32 = 0x01c0000b
+	EKTemplateNVIndexECC uint32 = 0x01c0000c
+)
+
+// NV Indices holding the certificates of EKs from the high range templates
+// (see EKHighRange), from "TCG EK Credential Profile for TPM Family 2.0" -
+// Version 2.3 onwards
+const (
+	EKCertNVIndexRSAHighRange uint32 = 0x01c00012
+	EKCertNVIndexECCHighRange uint32 = 0x01c00014
+)
+
 func isHierarchy(h tpmutil.Handle) bool {
 	return h == tpm2.HandleOwner || h == tpm2.HandleEndorsement ||
 		h == tpm2.HandlePlatform || h == tpm2.HandleNull
@@ -9,32 +9,35 @@ import (
 	"github.com/google/go-tpm/tpmutil"
 )
 
-func loadHandle(k *Key, blob *pb.ImportBlob) (tpmutil.Handle, error) {
+// loadHandle imports and loads the object in the blob under the key, returning
+// its handle and its private area (encrypted to the key). The encryptionKey
+// and sym are only given for a duplicate with an inner wrapper.
+func loadHandle(k *Key, blob *pb.ImportBlob, encryptionKey []byte, sym *tpm2.SymScheme) (tpmutil.Handle, []byte, error) {
 	auth, err := k.session.Auth()
 	if err != nil {
-		return tpm2.HandleNull, err
+		return tpm2.HandleNull, nil, err
 	}
-	private, err := tpm2.Import(k.rw, k.Handle(), auth, blob.PublicArea, blob.Duplicate, blob.EncryptedSeed, nil, nil)
+	private, err := tpm2.Import(k.rw, k.Handle(), auth, blob.PublicArea, blob.Duplicate, blob.EncryptedSeed, encryptionKey, sym)
 	if err != nil {
-		return tpm2.HandleNull, fmt.Errorf("import failed: %w", err)
+		return tpm2.HandleNull, nil, fmt.Errorf("import failed: %w", err)
 	}
 
 	auth, err = k.session.Auth()
 	if err != nil {
-		return tpm2.HandleNull, err
+		return tpm2.HandleNull, nil, err
 	}
 	handle, _, err := tpm2.LoadUsingAuth(k.rw, k.Handle(), auth, blob.PublicArea, private)
 	if err != nil {
-		return tpm2.HandleNull, fmt.Errorf("load failed: %w", err)
+		return tpm2.HandleNull, nil, fmt.Errorf("load failed: %w", err)
 	}
-	return handle, nil
+	return handle, private, nil
 }
 
 // Import decrypts the secret contained in an encoded import request.
 // The key used must be an encryption key (signing keys cannot be used).
 // The req parameter should come from server.CreateImportBlob.
 func (k *Key) Import(blob *pb.ImportBlob) ([]byte, error) {
-	handle, err := loadHandle(k, blob)
+	handle, _, err := loadHandle(k, blob, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +64,7 @@ func (k *Key) Import(blob *pb.ImportBlob) ([]byte, error) {
 // The parent key must be an encryption key (signing keys cannot be used).
 // The req parameter should come from server.CreateSigningKeyImportBlob.
 func (k *Key) ImportSigningKey(blob *pb.ImportBlob) (key *Key, err error) {
-	handle, err := loadHandle(k, blob)
+	handle, _, err := loadHandle(k, blob, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/go-tpm-tools/cel"
+)
+
+// DeriveKey derives a key of the given length in bytes from an HMAC key (see
+// HMACTemplate), such as a master secret which never leaves the TPM, using
+// TPM2_HMAC as the PRF of HKDF-Expand (RFC 5869, section 2.3). The HMAC key
+// takes the place of HKDF's pseudorandom key, and the info is the label,
+// followed by a zero byte and the context. Labels cannot contain zero bytes
+// (see cel.CheckDerivationLabel), so the info has only one label and context.
+//
+// Each purpose should have its own label (such as "disk-encryption" or
+// "session-tickets"), so that a key derived for one purpose reveals nothing
+// about keys derived for another. The context can further bind the key, for
+// example to a volume or tenant ID. The same master key, label and context
+// always derive the same key, so derived keys do not need to be stored. See
+// KeyDeriver to record the labels used in a Canonical Event Log.
+func (k *Key) DeriveKey(label string, context []byte, length int) ([]byte, error) {
+	if err := cel.CheckDerivationLabel(label); err != nil {
+		return nil, err
+	}
+	if k.pubArea.KeyedHashParameters == nil {
+		return nil, errors.New("key is not an HMAC key")
+	}
+	hash, err := k.pubArea.KeyedHashParameters.Hash.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("invalid HMAC hash algorithm: %w", err)
+	}
+	if length <= 0 || length > 255*hash.Size() {
+		return nil, fmt.Errorf("derived key length must be between 1 and %d bytes, got %d", 255*hash.Size(), length)
+	}
+
+	info := append(append([]byte(label), 0), context...)
+	var out, block []byte
+	for counter := byte(1); len(out) < length; counter++ {
+		input := append(append(block, info...), counter)
+		if block, err = k.HMAC(input); err != nil {
+			return nil, err
+		}
+		out = append(out, block...)
+	}
+	return out[:length], nil
+}
+
+// KeyDeriver derives per-purpose keys from a master HMAC key in the TPM (see
+// Key.DeriveKey), recording the purposes it derives keys for in a CEL, so that
+// verifiers can tell which keys a machine has been using. The first time a
+// label is used, a cel.KeyDerivationEvent is appended to Log, extending PCR
+// once for every hash algorithm in HashAlgos, before any key is derived for
+// it. The context of a derivation is not recorded.
+//
+// As with cel.CEL.MeasureKernelSecurity, the PCR must only be extended by this
+// CEL, and should not be resettable. Log must only be accessed through WithLog
+// while the deriver is in use.
+type KeyDeriver struct {
+	TPM       io.ReadWriter
+	Key       *Key
+	Log       *cel.CEL
+	PCR       int
+	HashAlgos []crypto.Hash
+
+	mu       sync.Mutex
+	recorded map[string]bool
+}
+
+// DeriveKey records the label (if it was not recorded before), and derives a
+// key of the given length in bytes for it with Key.
+func (d *KeyDeriver) DeriveKey(label string, context []byte, length int) ([]byte, error) {
+	if err := d.record(label); err != nil {
+		return nil, err
+	}
+	return d.Key.DeriveKey(label, context, length)
+}
+
+func (d *KeyDeriver) record(label string) error {
+	if err := cel.CheckDerivationLabel(label); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.recorded[label] {
+		return nil
+	}
+	name, err := d.Key.Name().Digest.Encode()
+	if err != nil {
+		return err
+	}
+	if err := d.Log.AppendEvent(d.TPM, d.PCR, d.HashAlgos, cel.KeyDerivationEvent{KeyName: name, Label: label}); err != nil {
+		return fmt.Errorf("recording key derivation %q: %w", label, err)
+	}
+	if d.recorded == nil {
+		d.recorded = make(map[string]bool)
+	}
+	d.recorded[label] = true
+	return nil
+}
+
+// WithLog calls f with the deriver's CEL, while no derivations are recorded.
+// An attestation must encode the CEL and quote the PCR inside f, so the CEL
+// replays to the quoted PCR value.
+func (d *KeyDeriver) WithLog(f func(log *cel.CEL) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return f(d.Log)
+}
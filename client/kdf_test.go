@@ -0,0 +1,195 @@
+package client_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/google/go-tpm-tools/cel"
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+func TestDeriveKey(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	master := bytes.Repeat([]byte{0x0b}, sha256.Size)
+	const handle = tpmutil.Handle(0x81008F04)
+	key := loadKeyWithSensitive(t, rwc, client.HMACTemplate(tpm2.AlgSHA256), master, handle)
+	defer tpm2.EvictControl(rwc, "", tpm2.HandleOwner, handle, handle)
+
+	for _, length := range []int{16, 32, 33, 100} {
+		t.Run(fmt.Sprint(length), func(t *testing.T) {
+			got, err := key.DeriveKey("disk-encryption", []byte("volume 1"), length)
+			if err != nil {
+				t.Fatalf("DeriveKey() failed: %v", err)
+			}
+			want := make([]byte, length)
+			if _, err := io.ReadFull(hkdf.Expand(sha256.New, master, []byte("disk-encryption\x00volume 1")), want); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("DeriveKey() = %x, want HKDF-Expand %x", got, want)
+			}
+		})
+	}
+
+	// Keys for other purposes or contexts differ.
+	base, err := key.DeriveKey("disk-encryption", []byte("volume 1"), 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, other := range []struct {
+		label   string
+		context string
+	}{
+		{"session-tickets", "volume 1"},
+		{"disk-encryption", "volume 2"},
+	} {
+		derived, err := key.DeriveKey(other.label, []byte(other.context), 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(derived, base) {
+			t.Errorf("DeriveKey(%q, %q) derived the same key", other.label, other.context)
+		}
+	}
+
+	for _, length := range []int{0, 255*sha256.Size + 1} {
+		if _, err := key.DeriveKey("disk-encryption", nil, length); err == nil {
+			t.Errorf("DeriveKey() of %d bytes should fail", length)
+		}
+	}
+	if _, err := key.DeriveKey("", nil, 32); err == nil {
+		t.Error("DeriveKey() without a label should fail")
+	}
+	// Otherwise the label and context would be ambiguous.
+	if _, err := key.DeriveKey("disk-encryption\x00volume", []byte(" 1"), 32); err == nil {
+		t.Error("DeriveKey() with a zero byte in the label should fail")
+	}
+}
+
+func TestDeriveKeyFromStoredMaster(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	// The master key is generated by the TPM, and stored as a key file which
+	// can only be loaded by the same TPM.
+	srk, err := client.NewKey(rwc, tpm2.HandleOwner, client.TSS2SRKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	master, err := client.NewKey(rwc, srk.Handle(), client.HMACTemplate(tpm2.AlgSHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile, err := master.ExportTSS2PEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := master.DeriveKey("session-tickets", nil, 32)
+	master.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := client.LoadTSS2PEM(rwc, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Close()
+	second, err := reloaded.DeriveKey("session-tickets", nil, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("DeriveKey() with the reloaded master key derived another key")
+	}
+}
+
+func TestKeyDeriver(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	initial, err := tpm2.ReadPCR(rwc, test.DebugPCR, tpm2.AlgSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(initial, make([]byte, len(initial))) {
+		t.Skipf("PCR%d has already been extended", test.DebugPCR)
+	}
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	master, err := client.NewKey(rwc, srk.Handle(), client.HMACTemplate(tpm2.AlgSHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+
+	deriver := &client.KeyDeriver{TPM: rwc, Key: master, Log: &cel.CEL{}, PCR: test.DebugPCR, HashAlgos: []crypto.Hash{crypto.SHA1, crypto.SHA256}}
+	// Each label is recorded once, whatever the context.
+	for _, derivation := range []struct {
+		label   string
+		context string
+	}{
+		{"disk-encryption", "volume 1"},
+		{"disk-encryption", "volume 2"},
+		{"session-tickets", ""},
+		{"disk-encryption", "volume 1"},
+	} {
+		got, err := deriver.DeriveKey(derivation.label, []byte(derivation.context), 32)
+		if err != nil {
+			t.Fatalf("DeriveKey() failed: %v", err)
+		}
+		want, err := master.DeriveKey(derivation.label, []byte(derivation.context), 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("KeyDeriver.DeriveKey(%q) differs from Key.DeriveKey()", derivation.label)
+		}
+	}
+
+	if _, err := deriver.DeriveKey("disk-encryption\x00volume", []byte(" 1"), 32); err == nil {
+		t.Error("DeriveKey() with a zero byte in the label should fail")
+	}
+
+	pcrs, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{test.DebugPCR}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	masterName, err := master.Name().Digest.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = deriver.WithLog(func(log *cel.CEL) error {
+		wantLabels := []string{"disk-encryption", "session-tickets"}
+		if len(log.Records) != len(wantLabels) {
+			t.Fatalf("CEL has %d records, want %d", len(log.Records), len(wantLabels))
+		}
+		for i, record := range log.Records {
+			event, err := cel.ParseKeyDerivationEvent(record.Content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Label != wantLabels[i] || !bytes.Equal(event.KeyName, masterName) {
+				t.Errorf("record %d = %+v, want label %q of key %x", i, event, wantLabels[i], masterName)
+			}
+		}
+		return log.Replay(pcrs)
+	})
+	if err != nil {
+		t.Errorf("replay failed: %v", err)
+	}
+}
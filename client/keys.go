@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"io"
 
@@ -14,9 +15,10 @@ import (
 	"github.com/google/go-tpm/tpmutil"
 )
 
-// Key wraps an active asymmetric TPM2 key. This can either be a signing key or
-// an encryption key. Users of Key should be sure to call Close() when the Key
-// is no longer needed, so that the underlying TPM handle can be freed.
+// Key wraps an active TPM2 key. This can either be an asymmetric signing key or
+// encryption key, or an HMAC or symmetric key (see HMACTemplate and
+// AESTemplate). Users of Key should be sure to call Close() when the Key is no
+// longer needed, so that the underlying TPM handle can be freed.
 type Key struct {
 	rw      io.ReadWriter
 	handle  tpmutil.Handle
@@ -24,16 +26,41 @@ type Key struct {
 	pubKey  crypto.PublicKey
 	name    tpm2.Name
 	session session
+	// The parent and private area of keys created under another key, which
+	// allow the key to be exported (see ExportTSS2PEM), with the password and
+	// policy commands of keys loaded from a TSS2 PEM file.
+	parent      tpmutil.Handle
+	pubBlob     []byte
+	privBlob    []byte
+	hasPassword bool
+	policy      []tss2Policy
 }
 
-// EndorsementKeyRSA generates and loads a key from DefaultEKTemplateRSA.
+// EndorsementKeyRSA generates and loads a key from DefaultEKTemplateRSA, or
+// from the EK Template and EK Nonce in the TPM's NV, if the manufacturer
+// provisioned them (see ProvisionedEKTemplate).
 func EndorsementKeyRSA(rw io.ReadWriter) (*Key, error) {
-	return NewCachedKey(rw, tpm2.HandleEndorsement, DefaultEKTemplateRSA(), EKReservedHandle)
+	return endorsementKey(rw, tpm2.AlgRSA, EKReservedHandle)
 }
 
-// EndorsementKeyECC generates and loads a key from DefaultEKTemplateECC.
+// EndorsementKeyECC generates and loads a key from DefaultEKTemplateECC, or
+// from the EK Template and EK Nonce in the TPM's NV, if the manufacturer
+// provisioned them (see ProvisionedEKTemplate).
 func EndorsementKeyECC(rw io.ReadWriter) (*Key, error) {
-	return NewCachedKey(rw, tpm2.HandleEndorsement, DefaultEKTemplateECC(), EKECCReservedHandle)
+	return endorsementKey(rw, tpm2.AlgECC, EKECCReservedHandle)
+}
+
+func endorsementKey(rw io.ReadWriter, keyType tpm2.Algorithm, cachedHandle tpmutil.Handle) (*Key, error) {
+	template, provisioned, err := ProvisionedEKTemplate(rw, keyType)
+	if err != nil {
+		return nil, err
+	}
+	if provisioned {
+		// A cached key only has to match the template, not its unique field,
+		// so it could be an EK created without the nonce.
+		return NewKey(rw, tpm2.HandleEndorsement, template)
+	}
+	return NewCachedKey(rw, tpm2.HandleEndorsement, template, cachedHandle)
 }
 
 // StorageRootKeyRSA generates and loads a key from SRKTemplateRSA.
@@ -56,6 +83,32 @@ func AttestationKeyECC(rw io.ReadWriter) (*Key, error) {
 	return NewCachedKey(rw, tpm2.HandleOwner, AKTemplateECC(), DefaultAKECCHandle)
 }
 
+// IDevIDKeyRSA generates and loads an Initial Device Identity (IDevID) key from
+// DevIDTemplateRSA in the Endorsement hierarchy, so it lasts for the life of
+// the TPM, like the EK.
+func IDevIDKeyRSA(rw io.ReadWriter) (*Key, error) {
+	return NewKey(rw, tpm2.HandleEndorsement, DevIDTemplateRSA())
+}
+
+// IDevIDKeyECC generates and loads an Initial Device Identity (IDevID) key from
+// DevIDTemplateECC in the Endorsement hierarchy.
+func IDevIDKeyECC(rw io.ReadWriter) (*Key, error) {
+	return NewKey(rw, tpm2.HandleEndorsement, DevIDTemplateECC())
+}
+
+// LDevIDKeyRSA generates and loads a Locally significant Device Identity
+// (LDevID) key from DevIDTemplateRSA in the Owner hierarchy, so it changes when
+// the TPM is cleared, such as when the device changes owner.
+func LDevIDKeyRSA(rw io.ReadWriter) (*Key, error) {
+	return NewKey(rw, tpm2.HandleOwner, DevIDTemplateRSA())
+}
+
+// LDevIDKeyECC generates and loads a Locally significant Device Identity
+// (LDevID) key from DevIDTemplateECC in the Owner hierarchy.
+func LDevIDKeyECC(rw io.ReadWriter) (*Key, error) {
+	return NewKey(rw, tpm2.HandleOwner, DevIDTemplateECC())
+}
+
 // EndorsementKeyFromNvIndex generates and loads an endorsement key using the
 // template stored at the provided nvdata index. This is useful for TPMs which
 // have a preinstalled AK template.
@@ -81,15 +134,23 @@ func GceAttestationKeyECC(rw io.ReadWriter) (*Key, error) {
 // (possibly a hierarchy root tpm2.Handle{Owner|Endorsement|Platform|Null})
 // using the template stored at the provided nvdata index.
 func KeyFromNvIndex(rw io.ReadWriter, parent tpmutil.Handle, idx uint32) (*Key, error) {
+	template, err := templateFromNvIndex(rw, idx)
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(rw, parent, template)
+}
+
+func templateFromNvIndex(rw io.ReadWriter, idx uint32) (tpm2.Public, error) {
 	data, err := tpm2.NVReadEx(rw, tpmutil.Handle(idx), tpm2.HandleOwner, "", 0)
 	if err != nil {
-		return nil, fmt.Errorf("read error at index %d: %w", idx, err)
+		return tpm2.Public{}, fmt.Errorf("read error at index %d: %w", idx, err)
 	}
 	template, err := tpm2.DecodePublic(data)
 	if err != nil {
-		return nil, fmt.Errorf("index %d data was not a TPM key template: %w", idx, err)
+		return tpm2.Public{}, fmt.Errorf("index %d data was not a TPM key template: %w", idx, err)
 	}
-	return NewKey(rw, parent, template)
+	return template, nil
 }
 
 // NewCachedKey is almost identical to NewKey, except that it initially tries to
@@ -134,38 +195,56 @@ func NewCachedKey(rw io.ReadWriter, parent tpmutil.Handle, template tpm2.Public,
 //   - If parent is tpm2.Handle{Owner|Endorsement|Platform|Null} a primary key
 //     is created in the specified hierarchy (using CreatePrimary).
 //   - If parent is a valid key handle, a normal key object is created under
-//     that parent (using Create and Load). NOTE: Not yet supported.
+//     that parent (using Create and Load). The parent must be a storage key
+//     usable with an empty password, such as an SRK.
 // This function also assumes that the desired key:
 //   - Does not have its usage locked to specific PCR values
 //   - Usable with empty authorization sessions (i.e. doesn't need a password)
-func NewKey(rw io.ReadWriter, parent tpmutil.Handle, template tpm2.Public) (k *Key, err error) {
+// Keys with other auth policies (such as an AK whose use must be approved by
+// a controller) can be used once their policy is set with Key.SetPolicy.
+func NewKey(rw io.ReadWriter, parent tpmutil.Handle, template tpm2.Public) (*Key, error) {
 	if !isHierarchy(parent) {
-		// TODO add support for normal objects with Create() and Load()
-		return nil, fmt.Errorf("unsupported parent handle: %x", parent)
+		return newChildKey(rw, parent, template)
 	}
 
-	handle, pubArea, _, _, _, _, err :=
-		tpm2.CreatePrimaryEx(rw, parent, tpm2.PCRSelection{}, "", "", template)
+	return createPrimary(rw, parent, nil, nil, template, nil)
+}
+
+func newChildKey(rw io.ReadWriter, parent tpmutil.Handle, template tpm2.Public) (*Key, error) {
+	priv, pub, _, _, _, err := tpm2.CreateKey(rw, parent, tpm2.PCRSelection{}, "", "", template)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create key under parent 0x%x: %w", parent, err)
 	}
+	handle, _, err := tpm2.Load(rw, parent, "", pub, priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key under parent 0x%x: %w", parent, err)
+	}
+	return childKey(rw, handle, parent, pub, priv, nil)
+}
+
+// childKey returns a loaded key, which was created under parent, using the
+// provided session for authorization (or a session based on its auth policy
+// if nil).
+func childKey(rw io.ReadWriter, handle, parent tpmutil.Handle, pub, priv []byte, s session) (k *Key, err error) {
+	k = &Key{rw: rw, handle: handle, parent: parent, pubBlob: pub, privBlob: priv, session: s}
 	defer func() {
 		if err != nil {
-			tpm2.FlushContext(rw, handle)
+			k.Close()
 		}
 	}()
-
-	k = &Key{rw: rw, handle: handle}
-	if k.pubArea, err = tpm2.DecodePublic(pubArea); err != nil {
-		return
+	if k.pubArea, err = tpm2.DecodePublic(pub); err != nil {
+		return nil, err
 	}
 	return k, k.finish()
 }
 
 func (k *Key) finish() error {
 	var err error
-	if k.pubKey, err = k.pubArea.Key(); err != nil {
-		return err
+	// HMAC and symmetric keys have no public key.
+	if k.pubArea.Type == tpm2.AlgRSA || k.pubArea.Type == tpm2.AlgECC {
+		if k.pubKey, err = k.pubArea.Key(); err != nil {
+			return err
+		}
 	}
 	if k.name, err = k.pubArea.Name(); err != nil {
 		return err
@@ -176,10 +255,16 @@ func (k *Key) finish() error {
 			if k.session, err = newEKSession(k.rw); err != nil {
 				return err
 			}
-		} else if len(k.pubArea.AuthPolicy) == 0 {
+		} else if len(k.pubArea.AuthPolicy) == 0 || bytes.Equal(k.pubArea.AuthPolicy, devIDAuthPolicy()) {
+			// DevIDs only need a policy for their admin role.
+			k.session = nullSession{}
+		} else if k.hasAttribute(tpm2.FlagUserWithAuth) {
+			// Keys with other policies (such as high range EKs) can also be
+			// used with their (empty) password.
 			k.session = nullSession{}
 		} else {
-			return fmt.Errorf("unknown auth policy when creating key")
+			// The policy must be set before the key can be used.
+			k.session = unknownPolicySession{}
 		}
 	}
 	return nil
@@ -203,7 +288,8 @@ func (k *Key) PublicArea() tpm2.Public {
 	return k.pubArea
 }
 
-// PublicKey provides a go interface to the loaded key's public area.
+// PublicKey provides a go interface to the loaded key's public area. It is nil
+// for HMAC and symmetric keys.
 func (k *Key) PublicKey() crypto.PublicKey {
 	return k.pubKey
 }
@@ -224,6 +310,11 @@ func (k *Key) Close() {
 // There must not be overlap in PCRs between SealOpts' Current and Target.
 // During the sealing process, certification data will be created allowing
 // Unseal() to validate the state of the TPM during the sealing process.
+// If SealOpts.CounterIndex is set, the data is also bound to the current value
+// of that NV counter, and can be revoked with IncrementNVCounter. If
+// SealOpts.Authority is set, the data is instead sealed to PCR policies signed
+// by that authority. If SealOpts.TargetDigest is set, the data is sealed to the
+// PCR values with that digest.
 func (k *Key) Seal(sensitive []byte, opts SealOpts) (*pb.SealedBytes, error) {
 	var pcrs *pb.PCRs
 	var err error
@@ -233,8 +324,35 @@ func (k *Key) Seal(sensitive []byte, opts SealOpts) (*pb.SealedBytes, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid SealOpts: %v", err)
 	}
+	sel := internal.PCRSelection(pcrs)
 	if len(pcrs.GetPcrs()) > 0 {
-		auth = internal.PCRSessionAuth(pcrs, SessionHashAlg)
+		if auth, err = internal.PCRSessionAuth(pcrs, SessionHashAlg); err != nil {
+			return nil, err
+		}
+	}
+	if opts.TargetDigest != nil {
+		if len(pcrs.GetPcrs()) > 0 {
+			return nil, errors.New("invalid SealOpts: data sealed to a TargetDigest cannot also be sealed to Current or Target PCRs")
+		}
+		if auth, err = targetDigestAuth(opts.TargetSelection, opts.TargetDigest); err != nil {
+			return nil, fmt.Errorf("invalid SealOpts: %w", err)
+		}
+		sel = opts.TargetSelection
+	}
+	var authorized *pb.AuthorizedPolicy
+	if opts.Authority != nil {
+		if len(sel.PCRs) > 0 || opts.CounterIndex != 0 {
+			return nil, errors.New("invalid SealOpts: data sealed to an Authority cannot also be sealed to PCRs or a counter")
+		}
+		if auth, authorized, err = authorizedPolicy(*opts.Authority, opts.AuthorityPolicyRef); err != nil {
+			return nil, fmt.Errorf("invalid SealOpts: %w", err)
+		}
+	}
+	var counter *pb.NVCounter
+	if opts.CounterIndex != 0 {
+		if counter, auth, err = counterPolicy(k.rw, opts.CounterIndex, auth); err != nil {
+			return nil, fmt.Errorf("invalid SealOpts: %w", err)
+		}
 	}
 	certifySel := FullPcrSel(CertifyHashAlgTpm)
 	sb, err := sealHelper(k.rw, k.Handle(), auth, sensitive, certifySel)
@@ -242,11 +360,13 @@ func (k *Key) Seal(sensitive []byte, opts SealOpts) (*pb.SealedBytes, error) {
 		return nil, err
 	}
 
-	for pcrNum := range pcrs.GetPcrs() {
-		sb.Pcrs = append(sb.Pcrs, pcrNum)
+	for _, pcrNum := range sel.PCRs {
+		sb.Pcrs = append(sb.Pcrs, uint32(pcrNum))
 	}
-	sb.Hash = pcrs.GetHash()
+	sb.Hash = pb.HashAlgo(sel.Hash)
 	sb.Srk = pb.ObjectType(k.pubArea.Type)
+	sb.Counter = counter
+	sb.AuthorizedPolicy = authorized
 	return sb, nil
 }
 
@@ -369,7 +489,12 @@ func (k *Key) Unseal(in *pb.SealedBytes, opts UnsealOpts) ([]byte, error) {
 		sel.PCRs = append(sel.PCRs, int(pcr))
 	}
 
-	session, err := newPCRSession(k.rw, sel)
+	var session session
+	if in.GetAuthorizedPolicy() != nil {
+		session, err = newAuthorizedSession(k.rw, in.GetAuthorizedPolicy(), opts.SignedPolicies)
+	} else {
+		session, err = newSealedSession(k.rw, sel, in.GetCounter())
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
@@ -386,6 +511,11 @@ func (k *Key) Unseal(in *pb.SealedBytes, opts UnsealOpts) ([]byte, error) {
 // some extra data (typically a nonce), sign it with the given signing key, and return
 // the signature and the attestation data. This function will return an error if
 // the key is not a restricted signing key.
+//
+// The TPM limits extraData to the size of its largest digest: 32 bytes on TPMs
+// which only implement SHA-256, and 48 or 64 bytes on those which also
+// implement SHA-384 or SHA-512. Longer nonces should be hashed first, as
+// Attest does when AttestOpts.NonceHash is set.
 func (k *Key) Quote(selpcr tpm2.PCRSelection, extraData []byte) (*pb.Quote, error) {
 	// Make sure that we have a valid signing key before trying quote
 	var err error
@@ -396,8 +526,12 @@ func (k *Key) Quote(selpcr tpm2.PCRSelection, extraData []byte) (*pb.Quote, erro
 		return nil, fmt.Errorf("unrestricted keys are insecure to use with Quote")
 	}
 
+	auth, err := k.session.Auth()
+	if err != nil {
+		return nil, err
+	}
 	quote := &pb.Quote{}
-	quote.Quote, quote.RawSig, err = tpm2.QuoteRaw(k.rw, k.Handle(), "", "", extraData, selpcr, tpm2.AlgNull)
+	quote.Quote, quote.RawSig, err = quoteRaw(k.rw, k.Handle(), auth, extraData, selpcr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to quote: %w", err)
 	}
@@ -413,6 +547,43 @@ func (k *Key) Quote(selpcr tpm2.PCRSelection, extraData []byte) (*pb.Quote, erro
 	return quote, nil
 }
 
+// quoteRaw runs TPM2_Quote with the given authorization for the signing key
+// (unlike tpm2.QuoteRaw, which only takes a password), returning the quoted
+// TPMS_ATTEST and the encoded signature.
+func quoteRaw(rw io.ReadWriter, handle tpmutil.Handle, auth tpm2.AuthCommand, extraData []byte, sel tpm2.PCRSelection) ([]byte, []byte, error) {
+	encodedSel, err := encodePCRSelection(sel)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := internal.RunCommand(rw, tpm2.CmdQuote, []tpmutil.Handle{handle}, []tpm2.AuthCommand{auth},
+		tpmutil.U16Bytes(extraData), tpm2.AlgNull, tpmutil.RawBytes(encodedSel))
+	if err != nil {
+		return nil, nil, err
+	}
+	var quoted tpmutil.U16Bytes
+	read, err := tpmutil.Unpack(resp, &quoted)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding quote: %w", err)
+	}
+	return quoted, resp[read:], nil
+}
+
+// encodePCRSelection encodes a PCR selection of a single bank as a
+// TPML_PCR_SELECTION, which is empty if no PCRs are selected.
+func encodePCRSelection(sel tpm2.PCRSelection) ([]byte, error) {
+	if len(sel.PCRs) == 0 {
+		return tpmutil.Pack(uint32(0))
+	}
+	bits := make([]byte, 3)
+	for _, pcr := range sel.PCRs {
+		if pcr < 0 || pcr >= 8*len(bits) {
+			return nil, fmt.Errorf("PCR index %d is out of range", pcr)
+		}
+		bits[pcr/8] |= 1 << uint(pcr%8)
+	}
+	return tpmutil.Pack(uint32(1), sel.Hash, byte(len(bits)), tpmutil.RawBytes(bits))
+}
+
 // Reseal is a shortcut to call Unseal() followed by Seal().
 // CertifyOpt(nillable) will be used in Unseal(), and SealOpt(nillable)
 // will be used in Seal()
@@ -0,0 +1,244 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// NVIndexOpts specifies how DefineNVIndex creates an NV index.
+type NVIndexOpts struct {
+	// Size of the index's data, in bytes.
+	Size uint16
+	// PCRs, if non-empty, restricts reading and writing the index to when these
+	// PCRs have their current values. Otherwise, the index can be read and
+	// written by the owner, or with the index's (empty) password.
+	PCRs tpm2.PCRSelection
+	// Attributes are added to the index's attributes. For example,
+	// tpm2.AttrWriteDefine allows the index to be made read-only with
+	// tpm2.NVWriteLock, which is useful for data like serial numbers.
+	Attributes tpm2.NVAttr
+}
+
+// NVIndex is an index in a TPM's NV storage, holding ordinary data. It can be
+// read, written and certified with a signing Key.
+type NVIndex struct {
+	rw    io.ReadWriter
+	index tpmutil.Handle
+	pub   tpm2.NVPublic
+	sel   tpm2.PCRSelection
+}
+
+// DefineNVIndex creates an NV index holding ordinary data, using the owner
+// hierarchy and an empty password. The index must be written before it can be
+// read or certified.
+func DefineNVIndex(rw io.ReadWriter, index uint32, opts NVIndexOpts) (*NVIndex, error) {
+	pub := tpm2.NVPublic{
+		NVIndex:    tpmutil.Handle(index),
+		NameAlg:    SessionHashAlgTpm,
+		Attributes: opts.Attributes | tpm2.AttrNoDA,
+		DataSize:   opts.Size,
+	}
+	if len(opts.PCRs.PCRs) > 0 {
+		pcrs, err := ReadPCRs(rw, opts.PCRs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PCRs: %w", err)
+		}
+		if pub.AuthPolicy, err = internal.PCRSessionAuth(pcrs, SessionHashAlg); err != nil {
+			return nil, err
+		}
+		pub.Attributes |= tpm2.AttrPolicyRead | tpm2.AttrPolicyWrite
+	} else {
+		pub.Attributes |= tpm2.AttrOwnerRead | tpm2.AttrOwnerWrite | tpm2.AttrAuthRead | tpm2.AttrAuthWrite
+	}
+	ownerAuth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+	if err := tpm2.NVDefineSpaceEx(rw, tpm2.HandleOwner, "", pub, ownerAuth); err != nil {
+		return nil, fmt.Errorf("failed to define NV index: %w", err)
+	}
+	return OpenNVIndex(rw, index, opts.PCRs)
+}
+
+// OpenNVIndex returns an existing NV index. If the index's policy requires
+// PCRs, they must be provided, so the index can be authorized using them.
+func OpenNVIndex(rw io.ReadWriter, index uint32, pcrs tpm2.PCRSelection) (*NVIndex, error) {
+	pub, err := tpm2.NVReadPublic(rw, tpmutil.Handle(index))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NV public area: %w", err)
+	}
+	return &NVIndex{rw, tpmutil.Handle(index), pub, pcrs}, nil
+}
+
+// Public returns the public area of the NV index, as it was when the index was
+// last opened, written or certified.
+func (n *NVIndex) Public() tpm2.NVPublic {
+	return n.pub
+}
+
+// Read returns the contents of the NV index.
+func (n *NVIndex) Read() ([]byte, error) {
+	authHandle, session, err := n.authorize(tpm2.AttrPolicyRead, tpm2.AttrAuthRead, tpm2.AttrOwnerRead)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	blockSize, err := nvBufferSize(n.rw)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for len(data) < int(n.pub.DataSize) {
+		size := int(n.pub.DataSize) - len(data)
+		if size > blockSize {
+			size = blockSize
+		}
+		auth, err := session.Auth()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := internal.RunCommand(n.rw, tpm2.CmdReadNV, []tpmutil.Handle{authHandle, n.index},
+			[]tpm2.AuthCommand{auth}, uint16(size), uint16(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NV index: %w", err)
+		}
+		var block tpmutil.U16Bytes
+		if _, err := tpmutil.Unpack(resp, &block); err != nil {
+			return nil, fmt.Errorf("failed to decode NV data: %w", err)
+		}
+		data = append(data, block...)
+	}
+	return data, nil
+}
+
+// Write replaces the contents of the NV index. The data must be the size of the
+// index.
+func (n *NVIndex) Write(data []byte) error {
+	if len(data) != int(n.pub.DataSize) {
+		return fmt.Errorf("NV index holds %d bytes, got %d bytes", n.pub.DataSize, len(data))
+	}
+	authHandle, session, err := n.authorize(tpm2.AttrPolicyWrite, tpm2.AttrAuthWrite, tpm2.AttrOwnerWrite)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	blockSize, err := nvBufferSize(n.rw)
+	if err != nil {
+		return err
+	}
+
+	for offset := 0; offset < len(data); offset += blockSize {
+		end := offset + blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		auth, err := session.Auth()
+		if err != nil {
+			return err
+		}
+		if err := tpm2.NVWriteEx(n.rw, authHandle, n.index, auth, data[offset:end], uint16(offset)); err != nil {
+			return fmt.Errorf("failed to write NV index: %w", err)
+		}
+	}
+	// The index now has the TPMA_NV_WRITTEN attribute, which changes its Name.
+	n.pub, err = tpm2.NVReadPublic(n.rw, n.index)
+	return err
+}
+
+// Delete removes the NV index, using the owner hierarchy and an empty password.
+func (n *NVIndex) Delete() error {
+	return tpm2.NVUndefineSpace(n.rw, "", tpm2.HandleOwner, n.index)
+}
+
+// authorize returns the handle and session used to authorize an operation on
+// the NV index: a PCR policy session (if PCRs were provided), the index's
+// empty password, or the owner's empty password.
+func (n *NVIndex) authorize(policy, auth, owner tpm2.NVAttr) (tpmutil.Handle, session, error) {
+	switch {
+	case len(n.sel.PCRs) > 0 && n.pub.Attributes&policy != 0:
+		s, err := newPCRSession(n.rw, n.sel)
+		return n.index, s, err
+	case n.pub.Attributes&auth != 0:
+		return n.index, nullSession{}, nil
+	case n.pub.Attributes&owner != 0:
+		return tpm2.HandleOwner, nullSession{}, nil
+	default:
+		return 0, nil, fmt.Errorf("NV index 0x%x has attributes %v, and cannot be authorized with PCRs or an empty password", uint32(n.index), n.pub.Attributes)
+	}
+}
+
+func nvBufferSize(rw io.ReadWriter) (int, error) {
+	size, err := tpmProperty(rw, tpm2.NVMaxBufferSize)
+	if err != nil {
+		return 0, err
+	}
+	if size == 0 {
+		return 0, fmt.Errorf("could not determine the NV buffer size")
+	}
+	return int(size), nil
+}
+
+// CertifyNV has the TPM sign the whole contents of an NV index with this key,
+// so a verifier trusting the key (usually an AK) can check the contents, for
+// example a device serial number or a configuration blob. The extraData
+// (typically a nonce) is included in the signed data. This function will
+// return an error if the key is not a restricted signing key, or if the index
+// is larger than the TPM can certify in one command.
+//
+// The certification can be checked with server.VerifyNVCertification.
+func (k *Key) CertifyNV(n *NVIndex, extraData []byte) (*pb.NVCertification, error) {
+	if _, err := internal.GetSigningHashAlg(k.pubArea); err != nil {
+		return nil, err
+	}
+	if !k.hasAttribute(tpm2.FlagRestricted) {
+		return nil, fmt.Errorf("unrestricted keys are insecure to use with CertifyNV")
+	}
+	// The index's Name (which is certified) changes when it is first written.
+	pub, err := tpm2.NVReadPublic(n.rw, n.index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NV public area: %w", err)
+	}
+	n.pub = pub
+	authHandle, session, err := n.authorize(tpm2.AttrPolicyRead, tpm2.AttrAuthRead, tpm2.AttrOwnerRead)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	nvAuth, err := session.Auth()
+	if err != nil {
+		return nil, err
+	}
+	keyAuth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+
+	resp, err := internal.RunCommand(k.rw, internal.CmdNVCertify,
+		[]tpmutil.Handle{k.Handle(), authHandle, n.index}, []tpm2.AuthCommand{keyAuth, nvAuth},
+		tpmutil.U16Bytes(extraData), tpm2.AlgNull, n.pub.DataSize, uint16(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to certify NV index: %w", err)
+	}
+	buf := bytes.NewBuffer(resp)
+	var certifyInfo tpmutil.U16Bytes
+	if err := tpmutil.UnpackBuf(buf, &certifyInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode NV certification: %w", err)
+	}
+	nvPublic, err := tpmutil.Pack(n.pub)
+	if err != nil {
+		return nil, err
+	}
+	certification := &pb.NVCertification{
+		CertifyInfo: certifyInfo,
+		RawSig:      buf.Bytes(),
+		NvPublic:    nvPublic,
+	}
+
+	// Verify the certification client-side to make sure we didn't mess things
+	// up. NOTE: it still must be verified server-side as well.
+	if _, _, err := internal.VerifyNVCertification(certification, k.PublicKey(), extraData); err != nil {
+		return nil, fmt.Errorf("failed to verify NV certification: %w", err)
+	}
+	return certification, nil
+}
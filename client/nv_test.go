@@ -0,0 +1,108 @@
+package client_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+const testNVIndex = 0x01500200
+
+func TestNVIndexReadWrite(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	pcrToChange := test.DebugPCR
+	testcases := []struct {
+		name string
+		opts client.NVIndexOpts
+	}{
+		{"Small", client.NVIndexOpts{Size: 16}},
+		// Larger than the simulator's NV buffer, so multiple commands are used.
+		{"Large", client.NVIndexOpts{Size: 2000}},
+		{"PCRPolicy", client.NVIndexOpts{
+			Size: 16,
+			PCRs: tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{pcrToChange}},
+		}},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			nv, err := client.DefineNVIndex(rwc, testNVIndex, testcase.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer nv.Delete()
+
+			data := bytes.Repeat([]byte{0x5A}, int(testcase.opts.Size))
+			if err := nv.Write(data); err != nil {
+				t.Fatalf("failed to write: %v", err)
+			}
+			if err := nv.Write(data[1:]); err == nil {
+				t.Error("writing data of the wrong size should fail")
+			}
+			got, err := nv.Read()
+			if err != nil {
+				t.Fatalf("failed to read: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("read %v, want %v", got, data)
+			}
+
+			if len(testcase.opts.PCRs.PCRs) == 0 {
+				return
+			}
+			extension := bytes.Repeat([]byte{0xAA}, 32)
+			if err := tpm2.PCRExtend(rwc, tpmutil.Handle(pcrToChange), tpm2.AlgSHA256, extension, ""); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := nv.Read(); err == nil {
+				t.Error("reading should fail after the PCR changes")
+			}
+			if err := nv.Write(data); err == nil {
+				t.Error("writing should fail after the PCR changes")
+			}
+		})
+	}
+}
+
+func TestCertifyNV(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	nv, err := client.DefineNVIndex(rwc, testNVIndex, client.NVIndexOpts{Size: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nv.Delete()
+
+	nonce := []byte("super secret nonce")
+	if _, err := ak.CertifyNV(nv, nonce); err == nil {
+		t.Error("certifying an index which was never written should fail")
+	}
+	if err := nv.Write([]byte("serial-1")); err != nil {
+		t.Fatal(err)
+	}
+	// CertifyNV verifies the certification itself.
+	if _, err := ak.CertifyNV(nv, nonce); err != nil {
+		t.Errorf("CertifyNV() failed: %v", err)
+	}
+
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	if _, err := srk.CertifyNV(nv, nonce); err == nil {
+		t.Error("certifying with a non-signing key should fail")
+	}
+}
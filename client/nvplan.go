@@ -0,0 +1,285 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// NV memory used by the reference implementation (and the simulator): the
+// size of an evicted (persistent) object, and the memory each NV index uses
+// in addition to its data.
+const (
+	DefaultNVObjectSize    = 1292
+	DefaultNVIndexOverhead = 152
+)
+
+// ErrNVSpace is returned (wrapped) by NVPlanReport.Err when the TPM does not
+// have enough NV memory for a planned index.
+var ErrNVSpace = errors.New("insufficient NV space")
+
+// NVUsage describes a TPM's NV memory, as reported by TPM2_GetCapability.
+// TPMs do not report their free NV memory in bytes, only an estimate of how
+// many more persistent objects will fit (PersistentAvail).
+type NVUsage struct {
+	// The defined NV indexes.
+	Indexes []tpm2.NVPublic
+	// The number of persistent objects, and the estimated number of
+	// additional persistent objects which will fit in NV memory.
+	PersistentObjects int
+	PersistentAvail   int
+	// The number of persistent objects the TPM keeps space for. The TPM
+	// will not define an NV index using this space.
+	PersistentMin int
+	// The number of counter indexes, and the maximum number (0 if only
+	// limited by NV memory).
+	Counters    int
+	CountersMax int
+	// The maximum data size of an NV index.
+	IndexMax int
+}
+
+// ReadNVUsage reads the NV indexes and NV capacity of the TPM.
+func ReadNVUsage(rw io.ReadWriter) (*NVUsage, error) {
+	var usage NVUsage
+	props := []struct {
+		prop  tpm2.TPMProp
+		value *int
+	}{
+		{tpm2.CurrentPersistent, &usage.PersistentObjects},
+		{tpm2.AvailPersistent, &usage.PersistentAvail},
+		{tpm2.PersistentObjectsMin, &usage.PersistentMin},
+		{tpm2.NVCounters, &usage.Counters},
+		{tpm2.NVCountersMax, &usage.CountersMax},
+		{tpm2.NVIndexMax, &usage.IndexMax},
+	}
+	for _, p := range props {
+		value, err := tpmProperty(rw, p.prop)
+		if err != nil {
+			return nil, err
+		}
+		*p.value = int(value)
+	}
+
+	handles, err := Handles(rw, tpm2.HandleTypeNVIndex)
+	if err != nil {
+		return nil, err
+	}
+	for _, handle := range handles {
+		pub, err := tpm2.NVReadPublic(rw, handle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NV index 0x%x: %w", handle, err)
+		}
+		usage.Indexes = append(usage.Indexes, pub)
+	}
+	return &usage, nil
+}
+
+// tpmProperty reads a TPM property with TPM2_GetCapability.
+func tpmProperty(rw io.ReadWriter, prop tpm2.TPMProp) (uint32, error) {
+	props, _, err := tpm2.GetCapability(rw, tpm2.CapabilityTPMProperties, 1, uint32(prop))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get TPM property 0x%x: %w", uint32(prop), err)
+	}
+	if len(props) != 1 {
+		return 0, fmt.Errorf("TPM did not report property 0x%x", uint32(prop))
+	}
+	tagged, ok := props[0].(tpm2.TaggedProperty)
+	if !ok || tagged.Tag != prop {
+		return 0, fmt.Errorf("TPM did not report property 0x%x", uint32(prop))
+	}
+	return tagged.Value, nil
+}
+
+// NVPlan is a set of NV index changes, which PlanNV checks against a TPM
+// before any are made.
+type NVPlan struct {
+	// The indexes to undefine, before any indexes are defined. Undefining an
+	// index which is not defined has no effect.
+	Undefine []tpmutil.Handle
+	// The indexes to define, in order.
+	Define []tpm2.NVPublic
+}
+
+// NVPlanOpts describes how a TPM uses its NV memory. The defaults match the
+// reference implementation; other TPMs differ, and their NV memory use can
+// only be estimated.
+type NVPlanOpts struct {
+	// The NV memory used by a persistent object, and by an NV index in
+	// addition to its data (defaults DefaultNVObjectSize and
+	// DefaultNVIndexOverhead).
+	ObjectSize    int
+	IndexOverhead int
+	// If non-zero, the NV memory used by an index is rounded up to a
+	// multiple of BlockSize.
+	BlockSize int
+	// If non-zero, the free NV memory in bytes, instead of the estimate
+	// from the TPM's PersistentAvail.
+	FreeBytes int
+	// Whether the TPM compacts its NV memory when an index is undefined.
+	// Otherwise, the memory of an undefined index is left as a hole, only
+	// reused by indexes fitting in it.
+	Compacting bool
+}
+
+// NVPlanResult is the result of checking one planned index.
+type NVPlanResult struct {
+	Index tpm2.NVPublic
+	// The estimated NV memory used by the index.
+	Footprint int
+	// Why the index cannot be defined, or nil if it fits.
+	Err error
+}
+
+// NVPlanReport is the result of PlanNV.
+type NVPlanReport struct {
+	Usage *NVUsage
+	// The estimated NV memory free for indexes before and after the plan,
+	// not counting holes left by undefined indexes.
+	FreeBytes      int
+	RemainingBytes int
+	// The results for each index in NVPlan.Define.
+	Results []NVPlanResult
+}
+
+// Err returns the first planned index which cannot be defined, or nil if
+// the whole plan fits.
+func (r *NVPlanReport) Err() error {
+	for _, result := range r.Results {
+		if result.Err != nil {
+			return fmt.Errorf("NV index 0x%x: %w", result.Index.NVIndex, result.Err)
+		}
+	}
+	return nil
+}
+
+// PlanNV checks whether the planned NV index changes can be made on the TPM,
+// without changing the TPM. This lets a provisioning process check that all
+// its indexes fit before defining any of them, rather than failing halfway
+// through.
+//
+// Each planned index is checked for a valid and unused handle, its size, the
+// TPM's limit on counters, and the TPM's free NV memory. As TPMs only report
+// the number of persistent objects which would fit in their free memory, the
+// free memory is estimated conservatively, so a plan may be rejected which
+// would fit. Use NVPlanOpts to describe a TPM which differs from the
+// reference implementation. An error is only returned if the TPM cannot be
+// read; use NVPlanReport.Err to check whether the plan fits.
+func PlanNV(rw io.ReadWriter, plan NVPlan, opts NVPlanOpts) (*NVPlanReport, error) {
+	usage, err := ReadNVUsage(rw)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ObjectSize == 0 {
+		opts.ObjectSize = DefaultNVObjectSize
+	}
+	if opts.IndexOverhead == 0 {
+		opts.IndexOverhead = DefaultNVIndexOverhead
+	}
+
+	report := &NVPlanReport{Usage: usage, FreeBytes: opts.FreeBytes}
+	if report.FreeBytes == 0 {
+		// PersistentAvail rounds down, and the TPM keeps space for
+		// PersistentMin persistent objects.
+		objects := usage.PersistentAvail
+		if reserved := usage.PersistentMin - usage.PersistentObjects; reserved > 0 {
+			objects -= reserved
+		}
+		if objects > 0 {
+			report.FreeBytes = objects * opts.ObjectSize
+		}
+	}
+	free := report.FreeBytes
+
+	defined := make(map[tpmutil.Handle]tpm2.NVPublic)
+	for _, pub := range usage.Indexes {
+		defined[pub.NVIndex] = pub
+	}
+	counters := usage.Counters
+	var holes []int
+	for _, handle := range plan.Undefine {
+		pub, ok := defined[handle]
+		if !ok {
+			continue
+		}
+		delete(defined, handle)
+		if isCounter(pub) {
+			counters--
+		}
+		if opts.Compacting {
+			free += opts.footprint(pub)
+		} else {
+			holes = append(holes, opts.footprint(pub))
+		}
+	}
+
+	for _, pub := range plan.Define {
+		result := NVPlanResult{Index: pub, Footprint: opts.footprint(pub)}
+		result.Err = checkNVIndex(pub, defined, usage)
+		if result.Err == nil && isCounter(pub) {
+			if usage.CountersMax != 0 && counters >= usage.CountersMax {
+				result.Err = fmt.Errorf("TPM supports at most %d counters", usage.CountersMax)
+			} else {
+				counters++
+			}
+		}
+		if result.Err == nil {
+			result.Err = allocate(&free, holes, result.Footprint)
+		}
+		if result.Err == nil {
+			defined[pub.NVIndex] = pub
+		}
+		report.Results = append(report.Results, result)
+	}
+	report.RemainingBytes = free
+	return report, nil
+}
+
+// footprint returns the estimated NV memory used by an index.
+func (opts NVPlanOpts) footprint(pub tpm2.NVPublic) int {
+	size := int(pub.DataSize) + opts.IndexOverhead
+	if opts.BlockSize > 1 {
+		size = (size + opts.BlockSize - 1) / opts.BlockSize * opts.BlockSize
+	}
+	return size
+}
+
+func isCounter(pub tpm2.NVPublic) bool {
+	return pub.Attributes&nvTypeMask == nvTypeCounter
+}
+
+// checkNVIndex checks a planned index, apart from the NV memory it uses.
+func checkNVIndex(pub tpm2.NVPublic, defined map[tpmutil.Handle]tpm2.NVPublic, usage *NVUsage) error {
+	if uint32(pub.NVIndex)>>24 != uint32(tpm2.HandleTypeNVIndex) {
+		return fmt.Errorf("handle 0x%x is not an NV index", pub.NVIndex)
+	}
+	if _, ok := defined[pub.NVIndex]; ok {
+		return errors.New("index is already defined")
+	}
+	if isCounter(pub) && pub.DataSize != counterSize {
+		return fmt.Errorf("counter indexes hold %d bytes, got %d bytes", counterSize, pub.DataSize)
+	}
+	if int(pub.DataSize) > usage.IndexMax {
+		return fmt.Errorf("index holds %d bytes, but the TPM supports at most %d bytes", pub.DataSize, usage.IndexMax)
+	}
+	return nil
+}
+
+// allocate takes size bytes from the first hole large enough, or from the
+// free memory.
+func allocate(free *int, holes []int, size int) error {
+	for i := range holes {
+		if holes[i] >= size {
+			holes[i] -= size
+			return nil
+		}
+	}
+	if *free < size {
+		return fmt.Errorf("%w: index needs about %d bytes, %d bytes free", ErrNVSpace, size, *free)
+	}
+	*free -= size
+	return nil
+}
@@ -0,0 +1,182 @@
+package client_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+const testPlanIndex = 0x01500300
+
+func planIndexes(n int, size uint16) []tpm2.NVPublic {
+	var indexes []tpm2.NVPublic
+	for i := 0; i < n; i++ {
+		indexes = append(indexes, tpm2.NVPublic{
+			NVIndex:    tpmutil.Handle(testPlanIndex + i),
+			NameAlg:    tpm2.AlgSHA256,
+			Attributes: tpm2.AttrAuthRead | tpm2.AttrAuthWrite | tpm2.AttrOwnerRead | tpm2.AttrOwnerWrite,
+			DataSize:   size,
+		})
+	}
+	return indexes
+}
+
+// defineIndexes defines the indexes in order, returning how many were
+// defined, and a function undefining them.
+func defineIndexes(rw io.ReadWriter, indexes []tpm2.NVPublic) (int, func()) {
+	defined := 0
+	for _, pub := range indexes {
+		if err := tpm2.NVDefineSpace(rw, tpm2.HandleOwner, pub.NVIndex, "", "", nil, pub.Attributes, pub.DataSize); err != nil {
+			break
+		}
+		defined++
+	}
+	return defined, func() {
+		for _, pub := range indexes[:defined] {
+			tpm2.NVUndefineSpace(rw, "", tpm2.HandleOwner, pub.NVIndex)
+		}
+	}
+}
+
+func TestReadNVUsage(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	before, err := client.ReadNVUsage(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.IndexMax == 0 || before.PersistentAvail == 0 {
+		t.Errorf("got usage %+v, want non-zero IndexMax and PersistentAvail", before)
+	}
+
+	defined, undefine := defineIndexes(rwc, planIndexes(1, 1000))
+	defer undefine()
+	if defined != 1 {
+		t.Fatal("failed to define an NV index")
+	}
+	after, err := client.ReadNVUsage(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after.Indexes) != len(before.Indexes)+1 {
+		t.Errorf("got %d indexes after defining one, want %d", len(after.Indexes), len(before.Indexes)+1)
+	}
+	if after.PersistentAvail >= before.PersistentAvail {
+		t.Errorf("PersistentAvail did not drop from %d after defining an index", before.PersistentAvail)
+	}
+}
+
+func TestPlanNVMatchesTPM(t *testing.T) {
+	subtests := []struct {
+		name     string
+		count    int
+		size     uint16
+		wantFits bool
+	}{
+		{"Small", 3, 1000, true},
+		{"ManySmall", 20, 1, true},
+		{"TooMany", 10, 1000, false},
+		{"TooManySmall", 100, 64, false},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			rwc := test.GetTPM(t)
+			defer client.CheckedClose(t, rwc)
+
+			indexes := planIndexes(subtest.count, subtest.size)
+			report, err := client.PlanNV(rwc, client.NVPlan{Define: indexes}, client.NVPlanOpts{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fits := report.Err() == nil; fits != subtest.wantFits {
+				t.Fatalf("PlanNV() fits = %v (%v), want %v", fits, report.Err(), subtest.wantFits)
+			}
+			if !subtest.wantFits && !errors.Is(report.Err(), client.ErrNVSpace) {
+				t.Errorf("PlanNV() error = %v, want ErrNVSpace", report.Err())
+			}
+
+			// The planner is conservative: every index it accepts can be
+			// defined, and the TPM accepts no more indexes than requested
+			// when the plan fits.
+			fitted := 0
+			for _, result := range report.Results {
+				if result.Err == nil {
+					fitted++
+				}
+			}
+			defined, undefine := defineIndexes(rwc, indexes)
+			defer undefine()
+			if defined < fitted {
+				t.Errorf("TPM defined %d indexes, but PlanNV() said %d fit", defined, fitted)
+			}
+			if subtest.wantFits != (defined == len(indexes)) {
+				t.Errorf("TPM defined %d of %d indexes, PlanNV() fits = %v", defined, len(indexes), subtest.wantFits)
+			}
+		})
+	}
+}
+
+func TestPlanNVChecks(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	existing := planIndexes(1, 500)
+	defined, undefine := defineIndexes(rwc, existing)
+	defer undefine()
+	if defined != 1 {
+		t.Fatal("failed to define an NV index")
+	}
+	counter := planIndexes(2, 8)[1]
+	counter.Attributes |= tpm2.NVAttr(0x1 << 4)
+	tooLarge := planIndexes(2, 4000)[1]
+	notNV := planIndexes(1, 8)[0]
+	notNV.NVIndex = 0x81000001
+
+	subtests := []struct {
+		name    string
+		plan    client.NVPlan
+		opts    client.NVPlanOpts
+		wantErr bool
+	}{
+		{"Defined", client.NVPlan{Define: existing}, client.NVPlanOpts{}, true},
+		{"Redefined", client.NVPlan{Undefine: []tpmutil.Handle{testPlanIndex}, Define: existing}, client.NVPlanOpts{}, false},
+		{"Duplicate", client.NVPlan{Define: []tpm2.NVPublic{counter, counter}}, client.NVPlanOpts{}, true},
+		{"Counter", client.NVPlan{Define: []tpm2.NVPublic{counter}}, client.NVPlanOpts{}, false},
+		{"TooLarge", client.NVPlan{Define: []tpm2.NVPublic{tooLarge}}, client.NVPlanOpts{}, true},
+		{"NotNV", client.NVPlan{Define: []tpm2.NVPublic{notNV}}, client.NVPlanOpts{}, true},
+		{"FreeBytes", client.NVPlan{Define: planIndexes(3, 100)[1:]}, client.NVPlanOpts{FreeBytes: 400}, true},
+		// Undefining the 500 byte index leaves a 652 byte hole, which a
+		// 1400 byte index does not fit in.
+		{"Fragmented", client.NVPlan{
+			Undefine: []tpmutil.Handle{testPlanIndex},
+			Define:   planIndexes(2, 1400)[1:],
+		}, client.NVPlanOpts{FreeBytes: 1000}, true},
+		{"Compacted", client.NVPlan{
+			Undefine: []tpmutil.Handle{testPlanIndex},
+			Define:   planIndexes(2, 1400)[1:],
+		}, client.NVPlanOpts{FreeBytes: 1000, Compacting: true}, false},
+		{"Hole", client.NVPlan{
+			Undefine: []tpmutil.Handle{testPlanIndex},
+			Define:   planIndexes(3, 400)[1:],
+		}, client.NVPlanOpts{FreeBytes: 600}, false},
+		{"BlockSize", client.NVPlan{Define: planIndexes(2, 100)[1:]}, client.NVPlanOpts{FreeBytes: 400, BlockSize: 512}, true},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			report, err := client.PlanNV(rwc, subtest.plan, subtest.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotErr := report.Err() != nil; gotErr != subtest.wantErr {
+				t.Errorf("PlanNV() error = %v, want error %v", report.Err(), subtest.wantErr)
+			}
+		})
+	}
+}
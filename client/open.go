@@ -0,0 +1,231 @@
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commands of the Microsoft simulator's TPM command interface, from Part 4 of
+// the spec, section D.3.2.
+const (
+	mssimSendCommand uint32 = 8
+	mssimSessionEnd  uint32 = 20
+)
+
+const (
+	defaultSimulatorHost = "localhost"
+	defaultSimulatorPort = 2321
+	// Larger than any TPM response, to reject corrupt sizes before allocating.
+	maxSocketResponseSize = 1 << 16
+	dialTimeout           = 10 * time.Second
+)
+
+// ErrTabrmdUnsupported is returned by OpenTPM for tabrmd configurations.
+// tpm2-abrmd is only reachable over D-Bus, and this module does not depend on
+// a D-Bus client. The in-kernel resource manager (device:/dev/tpmrm0) shares
+// the TPM between processes in the same way, and can be used alongside
+// tpm2-abrmd.
+var ErrTabrmdUnsupported = errors.New("tpm2-abrmd is not supported, use the in-kernel resource manager (device:/dev/tpmrm0) instead")
+
+// OpenTPM opens a TPM given a path, which is either a TPM device path, or a
+// TCTI-style configuration string like those used by tpm2-tools:
+//
+//	device:/dev/tpmrm0                 a TPM device (on Linux, the in-kernel
+//	                                   resource manager)
+//	swtpm:host=localhost,port=2321     swtpm's TCP data channel
+//	swtpm:path=/run/swtpm/sock         swtpm's Unix socket data channel
+//	mssim:host=localhost,port=2321     the Microsoft or IBM simulator
+//
+// Missing host and port options default to localhost and 2321. The simulators
+// are not initialized or reset, so they must have already been started up
+// (for example, with swtpm's --flags startup-clear), otherwise use OpenSwtpm.
+// swtpm's ctrl option (see OpenSwtpm) is accepted and ignored. tabrmd
+// configurations are rejected with ErrTabrmdUnsupported.
+//
+// If path is empty, DefaultTPMDescription describes the TPM which is opened.
+// If a TPM device cannot be opened, the error is a *DeviceError, reporting the
+// TPMs which are present and suggesting how to make them usable (see
+// DiscoverTPM).
+func OpenTPM(path string) (io.ReadWriteCloser, error) {
+	name, conf := path, ""
+	if i := strings.IndexByte(path, ':'); i >= 0 {
+		name, conf = path[:i], path[i+1:]
+	}
+	switch name {
+	case "device":
+		return openDeviceWithDiscovery(conf)
+	case "swtpm":
+		opts, err := parseTCTIConf(conf, "ctrl")
+		if err != nil {
+			return nil, fmt.Errorf("invalid swtpm configuration %q: %w", conf, err)
+		}
+		conn, err := dialSimulator(opts)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to swtpm: %w", err)
+		}
+		return &swtpmConn{conn: conn}, nil
+	case "mssim":
+		opts, err := parseTCTIConf(conf)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mssim configuration %q: %w", conf, err)
+		}
+		conn, err := dialSimulator(opts)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to mssim: %w", err)
+		}
+		return &mssimConn{conn: conn}, nil
+	case "tabrmd":
+		return nil, ErrTabrmdUnsupported
+	default:
+		// Device paths are accepted without a "device:" prefix.
+		return openDeviceWithDiscovery(path)
+	}
+}
+
+// parseTCTIConf parses the comma separated key=value options of a simulator
+// TCTI configuration, applying the defaults. Options other than host, path,
+// port and extraKeys are rejected.
+func parseTCTIConf(conf string, extraKeys ...string) (map[string]string, error) {
+	opts := map[string]string{
+		"host": defaultSimulatorHost,
+		"port": strconv.Itoa(defaultSimulatorPort),
+	}
+	if conf == "" {
+		return opts, nil
+	}
+	for _, opt := range strings.Split(conf, ",") {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("option %q is not of the form key=value", opt)
+		}
+		switch kv[0] {
+		case "host", "path":
+		case "port":
+			if _, err := strconv.ParseUint(kv[1], 10, 16); err != nil {
+				return nil, fmt.Errorf("invalid port %q", kv[1])
+			}
+		default:
+			if !containsString(extraKeys, kv[0]) {
+				return nil, fmt.Errorf("unknown option %q", kv[0])
+			}
+		}
+		opts[kv[0]] = kv[1]
+	}
+	return opts, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// dialSimulator connects to a simulator's data channel, at the path option if
+// there is one, or otherwise over TCP.
+func dialSimulator(opts map[string]string) (net.Conn, error) {
+	if opts["path"] != "" {
+		return net.DialTimeout("unix", opts["path"], dialTimeout)
+	}
+	return net.DialTimeout("tcp", net.JoinHostPort(opts["host"], opts["port"]), dialTimeout)
+}
+
+// socketResponse buffers the response to the last command, as tpmutil expects
+// each response to be returned by a single Read.
+type socketResponse struct {
+	resp []byte
+}
+
+func (s *socketResponse) Read(p []byte) (int, error) {
+	if len(s.resp) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, s.resp)
+	s.resp = s.resp[n:]
+	return n, nil
+}
+
+// swtpmConn sends raw TPM commands to swtpm's data channel.
+type swtpmConn struct {
+	socketResponse
+	conn net.Conn
+}
+
+func (s *swtpmConn) Write(cmd []byte) (int, error) {
+	if _, err := s.conn.Write(cmd); err != nil {
+		return 0, err
+	}
+	header := make([]byte, responseHeaderSize)
+	if _, err := io.ReadFull(s.conn, header); err != nil {
+		return 0, fmt.Errorf("reading response header: %w", err)
+	}
+	size := binary.BigEndian.Uint32(header[2:6])
+	if size < responseHeaderSize || size > maxSocketResponseSize {
+		return 0, fmt.Errorf("invalid response size %d", size)
+	}
+	resp := make([]byte, size)
+	copy(resp, header)
+	if _, err := io.ReadFull(s.conn, resp[responseHeaderSize:]); err != nil {
+		return 0, fmt.Errorf("reading response: %w", err)
+	}
+	s.resp = resp
+	return len(cmd), nil
+}
+
+func (s *swtpmConn) Close() error {
+	return s.conn.Close()
+}
+
+// mssimConn implements the Microsoft simulator's TPM command interface, which
+// frames each command and response, from Part 4 of the spec, section D.4.3.3.
+type mssimConn struct {
+	socketResponse
+	conn net.Conn
+}
+
+func (m *mssimConn) Write(cmd []byte) (int, error) {
+	frame := make([]byte, 9+len(cmd))
+	binary.BigEndian.PutUint32(frame[0:4], mssimSendCommand)
+	frame[4] = 0 // Locality
+	binary.BigEndian.PutUint32(frame[5:9], uint32(len(cmd)))
+	copy(frame[9:], cmd)
+	if _, err := m.conn.Write(frame); err != nil {
+		return 0, err
+	}
+
+	var size uint32
+	if err := binary.Read(m.conn, binary.BigEndian, &size); err != nil {
+		return 0, fmt.Errorf("reading response size: %w", err)
+	}
+	if size < responseHeaderSize || size > maxSocketResponseSize {
+		return 0, fmt.Errorf("invalid response size %d", size)
+	}
+	resp := make([]byte, size)
+	if _, err := io.ReadFull(m.conn, resp); err != nil {
+		return 0, fmt.Errorf("reading response: %w", err)
+	}
+	// Each response is followed by a zero acknowledgement.
+	var ack uint32
+	if err := binary.Read(m.conn, binary.BigEndian, &ack); err != nil {
+		return 0, fmt.Errorf("reading acknowledgement: %w", err)
+	}
+	if ack != 0 {
+		return 0, fmt.Errorf("simulator returned error 0x%x", ack)
+	}
+	m.resp = resp
+	return len(cmd), nil
+}
+
+func (m *mssimConn) Close() error {
+	// Ending the session lets the simulator accept another connection.
+	binary.Write(m.conn, binary.BigEndian, mssimSessionEnd)
+	return m.conn.Close()
+}
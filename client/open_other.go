@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package client
+
+import (
+	"io"
+	"os"
+
+	"github.com/google/go-tpm/tpm2"
+)
+
+// DefaultTPMDescription describes the TPM OpenTPM uses if no path is given.
+const DefaultTPMDescription = "/dev/tpmrm0, or /dev/tpm0 if it is not present"
+
+// openDevice opens the TPM character device at path. If path is empty, the
+// kernel resource manager (/dev/tpmrm0) is used if present, and /dev/tpm0
+// otherwise.
+func openDevice(path string) (io.ReadWriteCloser, error) {
+	if path != "" {
+		return tpm2.OpenTPM(path)
+	}
+	rwc, err := tpm2.OpenTPM("/dev/tpmrm0")
+	if os.IsNotExist(err) {
+		rwc, err = tpm2.OpenTPM("/dev/tpm0")
+	}
+	return rwc, err
+}
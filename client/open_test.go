@@ -0,0 +1,139 @@
+package client_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+func TestOpenTPMMissingDevice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tpm0")
+	for _, p := range []string{path, "device:" + path} {
+		if rwc, err := client.OpenTPM(p); err == nil {
+			rwc.Close()
+			t.Errorf("OpenTPM(%q) succeeded for a missing device", p)
+		}
+	}
+}
+
+func TestOpenTPMInvalidConfig(t *testing.T) {
+	for _, path := range []string{
+		"swtpm:host",
+		"swtpm:port=http",
+		"mssim:host=localhost,locality=3",
+	} {
+		if rwc, err := client.OpenTPM(path); err == nil {
+			rwc.Close()
+			t.Errorf("OpenTPM(%q) should fail", path)
+		}
+	}
+}
+
+func TestOpenTPMTabrmd(t *testing.T) {
+	for _, path := range []string{"tabrmd", "tabrmd:bus_type=system"} {
+		if _, err := client.OpenTPM(path); !errors.Is(err, client.ErrTabrmdUnsupported) {
+			t.Errorf("OpenTPM(%q) returned %v, want %v", path, err, client.ErrTabrmdUnsupported)
+		}
+	}
+}
+
+// serveSimulator serves the TPM over a TCP socket, using the swtpm or mssim
+// protocol, until the client disconnects.
+func serveSimulator(t *testing.T, rw io.ReadWriter, mssim bool) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveSimulatorOn(t, listener, rw, mssim)
+	return listener.Addr().String()
+}
+
+func serveSimulatorOn(t *testing.T, listener net.Listener, rw io.ReadWriter, mssim bool) {
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var cmd []byte
+			if mssim {
+				var frame struct {
+					Command  uint32
+					Locality uint8
+					Size     uint32
+				}
+				if err := binary.Read(conn, binary.BigEndian, &frame); err != nil || frame.Command != 8 {
+					return
+				}
+				cmd = make([]byte, frame.Size)
+				if _, err := io.ReadFull(conn, cmd); err != nil {
+					return
+				}
+			} else {
+				header := make([]byte, 10)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				cmd = make([]byte, binary.BigEndian.Uint32(header[2:6]))
+				copy(cmd, header)
+				if _, err := io.ReadFull(conn, cmd[10:]); err != nil {
+					return
+				}
+			}
+			if _, err := rw.Write(cmd); err != nil {
+				return
+			}
+			resp := make([]byte, 4096)
+			n, err := rw.Read(resp)
+			if err != nil {
+				return
+			}
+			if mssim {
+				binary.Write(conn, binary.BigEndian, uint32(n))
+			}
+			conn.Write(resp[:n])
+			if mssim {
+				binary.Write(conn, binary.BigEndian, uint32(0))
+			}
+		}
+	}()
+}
+
+func TestOpenTPMSocket(t *testing.T) {
+	for _, name := range []string{"swtpm", "mssim"} {
+		t.Run(name, func(t *testing.T) {
+			rwc := test.GetTPM(t)
+			defer client.CheckedClose(t, rwc)
+			host, port, err := net.SplitHostPort(serveSimulator(t, rwc, name == "mssim"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tpm, err := client.OpenTPM(name + ":host=" + host + ",port=" + port)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tpm.Close()
+			// Responses larger than one read from the socket.
+			ak, err := client.AttestationKeyRSA(tpm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ak.Close()
+			if _, err := ak.Quote(tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{0, 1, 2}}, []byte("nonce")); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
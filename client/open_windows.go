@@ -0,0 +1,21 @@
+package client
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/tpm2"
+)
+
+// DefaultTPMDescription describes the TPM OpenTPM uses if no path is given.
+const DefaultTPMDescription = "the TPM Base Services"
+
+// openDevice opens the TPM through the TPM Base Services (TBS), which share the
+// TPM between processes much like the Linux kernel resource manager. There is
+// no TPM device on Windows, so path must be empty.
+func openDevice(path string) (io.ReadWriteCloser, error) {
+	if path != "" {
+		return nil, fmt.Errorf("TPM device paths are not supported on Windows, got %q", path)
+	}
+	return tpm2.OpenTPM()
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"math"
 
+	"github.com/google/go-tpm-tools/policy"
 	pb "github.com/google/go-tpm-tools/proto/tpm"
 	"github.com/google/go-tpm/tpm2"
 )
@@ -104,6 +105,27 @@ type SealOpts struct {
 	Current tpm2.PCRSelection
 	// Target predictively seals data to the given specified PCR values.
 	Target *pb.PCRs
+	// TargetDigest predictively seals data to the PCRs in TargetSelection
+	// having values whose digest (see policy.PCRDigest, with SessionHashAlg)
+	// is TargetDigest. This allows sealing to values which are only known by
+	// their digest, such as those computed with the predict package on
+	// another machine. Current and Target must then be empty.
+	TargetDigest    []byte
+	TargetSelection tpm2.PCRSelection
+	// CounterIndex, if non-zero, is the NV index of a counter created with
+	// CreateNVCounter. The data can then only be unsealed while the counter has
+	// its current value, so incrementing the counter revokes the sealed data.
+	CounterIndex uint32
+	// Authority, if set, seals the data to PCR policies signed by an
+	// authority's key with policy.SignPCRPolicy, instead of to PCR values
+	// (so Current and Target must be empty). The data can then be unsealed
+	// with any approved policy (see UnsealOpts.SignedPolicies), and approving
+	// new PCR values does not need the data to be resealed. Authority is the
+	// public area of the key, such as one from policy.AuthorizingKey.
+	Authority *tpm2.Public
+	// AuthorityPolicyRef qualifies which of the Authority's policies apply,
+	// so one key can approve policies for different data.
+	AuthorityPolicyRef []byte
 }
 
 // UnsealOpts specifies the options that should be used for Unseal().
@@ -117,6 +139,10 @@ type UnsealOpts struct {
 	CertifyCurrent tpm2.PCRSelection
 	// CertifyExpected certifies that the TPM had a specific set of PCR values when sealing.
 	CertifyExpected *pb.PCRs
+	// SignedPolicies are the PCR policies approved by the authority of data
+	// sealed with SealOpts.Authority. The first one matching the current PCR
+	// values is used.
+	SignedPolicies []*pb.SignedPCRPolicy
 }
 
 // FullPcrSel will return a full PCR selection based on the total PCR number
@@ -164,3 +190,18 @@ func mergePCRSelAndProto(rw io.ReadWriter, sel tpm2.PCRSelection, proto *pb.PCRs
 	}
 	return currentPcrs, nil
 }
+
+// targetDigestAuth returns the auth policy of data sealed to PCR values with
+// the given digest.
+func targetDigestAuth(sel tpm2.PCRSelection, digest []byte) ([]byte, error) {
+	if len(sel.PCRs) == 0 {
+		return nil, fmt.Errorf("no PCRs selected for the target digest")
+	}
+	if _, err := sel.Hash.Hash(); err != nil {
+		return nil, fmt.Errorf("invalid target PCR bank: %w", err)
+	}
+	if len(digest) != SessionHashAlg.Size() {
+		return nil, fmt.Errorf("target digest has length %d, expected %d for %v", len(digest), SessionHashAlg.Size(), SessionHashAlg)
+	}
+	return policy.New(SessionHashAlg).PCR(sel, digest).Digest()
+}
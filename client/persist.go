@@ -0,0 +1,219 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// ErrKeyNotPersisted is returned by FindPersistentKey if no persistent handle
+// holds a key matching the template.
+var ErrKeyNotPersisted = errors.New("no persistent key matches the template")
+
+// TemplateDigest computes the SHA-256 digest of a template's encoding, ignoring
+// its unique field. Both a template and the public area of any key created from
+// that template have the same digest, so it can be used to find which
+// persistent handles hold a key created from a given template.
+func TemplateDigest(template tpm2.Public) ([]byte, error) {
+	if template.RSAParameters != nil {
+		params := *template.RSAParameters
+		params.ModulusRaw = nil
+		template.RSAParameters = &params
+	}
+	if template.ECCParameters != nil {
+		params := *template.ECCParameters
+		params.Point = tpm2.ECPoint{}
+		template.ECCParameters = &params
+	}
+	if template.SymCipherParameters != nil {
+		params := *template.SymCipherParameters
+		params.Unique = nil
+		template.SymCipherParameters = &params
+	}
+	if template.KeyedHashParameters != nil {
+		params := *template.KeyedHashParameters
+		params.Unique = nil
+		template.KeyedHashParameters = &params
+	}
+	encoded, err := template.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode template: %w", err)
+	}
+	digest := sha256.Sum256(encoded)
+	return digest[:], nil
+}
+
+// PersistentObject describes an object at a persistent handle.
+type PersistentObject struct {
+	Handle         tpmutil.Handle
+	Public         tpm2.Public
+	TemplateDigest []byte
+}
+
+// PersistentObjects lists the objects at all of the TPM's persistent handles.
+func PersistentObjects(rw io.ReadWriter) ([]PersistentObject, error) {
+	handles, err := Handles(rw, tpm2.HandleTypePersistent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistent handles: %w", err)
+	}
+	objects := make([]PersistentObject, 0, len(handles))
+	for _, handle := range handles {
+		pub, _, _, err := tpm2.ReadPublic(rw, handle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public area at 0x%x: %w", handle, err)
+		}
+		digest, err := TemplateDigest(pub)
+		if err != nil {
+			return nil, fmt.Errorf("object at 0x%x: %w", handle, err)
+		}
+		objects = append(objects, PersistentObject{handle, pub, digest})
+	}
+	return objects, nil
+}
+
+// FindPersistentKey searches the TPM's persistent handles for a key created from
+// the template, returning ErrKeyNotPersisted if there is none. Only the
+// template is compared, so callers using the same template in several
+// hierarchies should persist those keys at known handles instead, and use
+// LoadPersistentKey.
+func FindPersistentKey(rw io.ReadWriter, template tpm2.Public) (*Key, error) {
+	objects, err := PersistentObjects(rw)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		if object.Public.MatchesTemplate(template) {
+			k := &Key{rw: rw, handle: object.Handle, pubArea: object.Public}
+			return k, k.finish()
+		}
+	}
+	return nil, ErrKeyNotPersisted
+}
+
+// LoadPersistentKey returns the key at a persistent handle.
+func LoadPersistentKey(rw io.ReadWriter, handle tpmutil.Handle) (*Key, error) {
+	if !isOwnerPersistent(handle) {
+		return nil, fmt.Errorf("0x%x is not an owner hierarchy persistent handle", handle)
+	}
+	pub, _, _, err := tpm2.ReadPublic(rw, handle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public area at 0x%x: %w", handle, err)
+	}
+	k := &Key{rw: rw, handle: handle, pubArea: pub}
+	return k, k.finish()
+}
+
+// NewPersistentKey returns a persistent key created from the template, creating
+// the key with NewKey and persisting it at the first free handle between
+// FirstPersistentKeyHandle and LastPersistentKeyHandle if no persistent key
+// matches the template. Unlike NewCachedKey, existing persistent keys are never
+// evicted. This avoids the cost of generating the key (which can take seconds
+// for RSA primary keys) on every process start.
+func NewPersistentKey(rw io.ReadWriter, parent tpmutil.Handle, template tpm2.Public) (*Key, error) {
+	k, err := FindPersistentKey(rw, template)
+	if err == nil {
+		return k, nil
+	}
+	if !errors.Is(err, ErrKeyNotPersisted) {
+		return nil, err
+	}
+
+	handles, err := Handles(rw, tpm2.HandleTypePersistent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistent handles: %w", err)
+	}
+	used := make(map[tpmutil.Handle]bool, len(handles))
+	for _, handle := range handles {
+		used[handle] = true
+	}
+	free := FirstPersistentKeyHandle
+	for used[free] {
+		if free == LastPersistentKeyHandle {
+			return nil, fmt.Errorf("no free persistent handles in 0x%x-0x%x",
+				FirstPersistentKeyHandle, LastPersistentKeyHandle)
+		}
+		free++
+	}
+
+	if k, err = NewKey(rw, parent, template); err != nil {
+		return nil, err
+	}
+	if err = k.Persist(free); err != nil {
+		k.Close()
+		return nil, err
+	}
+	return k, nil
+}
+
+// PersistentKeyFromNvIndex is like KeyFromNvIndex, except that the key is
+// found or persisted as in NewPersistentKey.
+func PersistentKeyFromNvIndex(rw io.ReadWriter, parent tpmutil.Handle, idx uint32) (*Key, error) {
+	template, err := templateFromNvIndex(rw, idx)
+	if err != nil {
+		return nil, err
+	}
+	return NewPersistentKey(rw, parent, template)
+}
+
+// Persist moves a transient key to an unused owner hierarchy persistent handle,
+// so that it survives TPM restarts and can be loaded with LoadPersistentKey or
+// FindPersistentKey. Afterwards, k refers to the persistent handle, and Close
+// no longer frees the key; use Evict for that. Persist never overwrites an
+// existing persistent object.
+func (k *Key) Persist(handle tpmutil.Handle) error {
+	if !isOwnerPersistent(handle) {
+		return fmt.Errorf("0x%x is not an owner hierarchy persistent handle", handle)
+	}
+	if isOwnerPersistent(k.handle) {
+		return fmt.Errorf("key is already persisted at 0x%x", k.handle)
+	}
+	if _, _, _, err := tpm2.ReadPublic(k.rw, handle); err == nil {
+		return fmt.Errorf("persistent handle 0x%x is already in use", handle)
+	}
+	if err := tpm2.EvictControl(k.rw, "", tpm2.HandleOwner, k.handle, handle); err != nil {
+		return fmt.Errorf("failed to persist key at 0x%x: %w", handle, err)
+	}
+	tpm2.FlushContext(k.rw, k.handle)
+	k.handle = handle
+	return nil
+}
+
+// Evict removes a persisted key from the TPM. To avoid evicting an object that
+// replaced the key, Evict first checks the persistent handle still holds k.
+// The key cannot be used afterwards.
+func (k *Key) Evict() error {
+	if !isOwnerPersistent(k.handle) {
+		return fmt.Errorf("key at 0x%x is not persisted", k.handle)
+	}
+	pub, _, _, err := tpm2.ReadPublic(k.rw, k.handle)
+	if err != nil {
+		return fmt.Errorf("failed to read public area at 0x%x: %w", k.handle, err)
+	}
+	name, err := pub.Name()
+	if err != nil {
+		return err
+	}
+	if !sameName(name, k.name) {
+		return fmt.Errorf("persistent handle 0x%x no longer holds this key", k.handle)
+	}
+	if err = tpm2.EvictControl(k.rw, "", tpm2.HandleOwner, k.handle, k.handle); err != nil {
+		return fmt.Errorf("failed to evict key at 0x%x: %w", k.handle, err)
+	}
+	k.Close()
+	return nil
+}
+
+func isOwnerPersistent(h tpmutil.Handle) bool {
+	return h >= firstOwnerPersistentHandle && h <= lastOwnerPersistentHandle
+}
+
+func sameName(a, b tpm2.Name) bool {
+	encodedA, errA := a.Encode()
+	encodedB, errB := b.Encode()
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
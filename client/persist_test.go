@@ -0,0 +1,188 @@
+package client_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+func TestNewPersistentKeyReusesKey(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	template := client.SRKTemplateECC()
+
+	if _, err := client.FindPersistentKey(rwc, template); !errors.Is(err, client.ErrKeyNotPersisted) {
+		t.Fatalf("FindPersistentKey() = %v, want ErrKeyNotPersisted", err)
+	}
+	key, err := client.NewPersistentKey(rwc, tpm2.HandleOwner, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Evict()
+	if key.Handle() != client.FirstPersistentKeyHandle {
+		t.Errorf("key persisted at 0x%x, want 0x%x", key.Handle(), client.FirstPersistentKeyHandle)
+	}
+
+	again, err := client.NewPersistentKey(rwc, tpm2.HandleOwner, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Handle() != key.Handle() {
+		t.Errorf("NewPersistentKey() persisted a second key at 0x%x", again.Handle())
+	}
+	if !reflect.DeepEqual(again.PublicKey(), key.PublicKey()) {
+		t.Error("persisted key differs from the original key")
+	}
+
+	// A different template gets the next free handle.
+	other, err := client.NewPersistentKey(rwc, tpm2.HandleOwner, client.AKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Evict()
+	if other.Handle() != client.FirstPersistentKeyHandle+1 {
+		t.Errorf("second key persisted at 0x%x, want 0x%x", other.Handle(), client.FirstPersistentKeyHandle+1)
+	}
+
+	loaded, err := client.LoadPersistentKey(rwc, key.Handle())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.PublicKey(), key.PublicKey()) {
+		t.Error("loaded key differs from the original key")
+	}
+}
+
+func TestTemplateDigest(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	for _, template := range []tpm2.Public{client.DefaultEKTemplateRSA(), client.SRKTemplateECC()} {
+		key, err := client.NewKey(rwc, tpm2.HandleOwner, template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key.Close()
+
+		want, err := client.TemplateDigest(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := client.TemplateDigest(key.PublicArea())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("TemplateDigest() differs between the template and its key")
+		}
+	}
+	rsa, err := client.TemplateDigest(client.SRKTemplateRSA())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecc, err := client.TemplateDigest(client.SRKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(rsa, ecc) {
+		t.Error("different templates have the same digest")
+	}
+}
+
+func TestPersistentObjects(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	key, err := client.NewPersistentKey(rwc, tpm2.HandleOwner, client.AKTemplateRSA())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Evict()
+	digest, err := client.TemplateDigest(client.AKTemplateRSA())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := client.PersistentObjects(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, object := range objects {
+		if object.Handle == key.Handle() {
+			if !bytes.Equal(object.TemplateDigest, digest) {
+				t.Error("persistent key has the wrong template digest")
+			}
+			return
+		}
+	}
+	t.Errorf("key at 0x%x not listed in %v", key.Handle(), objects)
+}
+
+func TestPersistDoesNotOverwrite(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	first, err := client.NewKey(rwc, tpm2.HandleOwner, client.SRKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = first.Persist(client.LastPersistentKeyHandle); err != nil {
+		t.Fatal(err)
+	}
+	defer first.Evict()
+	if err = first.Persist(client.LastPersistentKeyHandle - 1); err == nil {
+		t.Error("persisting an already persisted key should fail")
+	}
+
+	second, err := client.NewKey(rwc, tpm2.HandleOwner, client.AKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if err = second.Persist(client.LastPersistentKeyHandle); err == nil {
+		t.Error("persisting over an existing key should fail")
+	}
+	if err = second.Persist(tpm2.HandleOwner); err == nil {
+		t.Error("persisting to a non-persistent handle should fail")
+	}
+}
+
+func TestEvictChecksKey(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	key, err := client.NewPersistentKey(rwc, tpm2.HandleOwner, client.SRKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := client.LoadPersistentKey(rwc, key.Handle())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = key.Evict(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.LoadPersistentKey(rwc, key.Handle()); err == nil {
+		t.Error("loading an evicted key should fail")
+	}
+
+	// Another key now occupies the handle, which must not be evicted through
+	// the stale Key.
+	replacement, err := client.NewPersistentKey(rwc, tpm2.HandleOwner, client.AKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replacement.Evict()
+	if replacement.Handle() != stale.Handle() {
+		t.Fatalf("replacement persisted at 0x%x, want 0x%x", replacement.Handle(), stale.Handle())
+	}
+	if err = stale.Evict(); err == nil {
+		t.Error("evicting a replaced key should fail")
+	}
+}
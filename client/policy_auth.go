@@ -0,0 +1,169 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/policy"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// A PolicyAssertion is a policy command which is run in a policy session to
+// satisfy (part of) the auth policy of a key, such as an AK which may only be
+// used with the approval of a controller. See Key.SetPolicy.
+type PolicyAssertion interface {
+	assert(rw io.ReadWriter, session tpmutil.Handle, nonceTPM []byte) error
+}
+
+// PolicySigned satisfies TPM2_PolicySigned (see policy.Policy.Signed) with an
+// authorization signed by the holder of the authorizing key, such as a
+// controller which approves each use of an AK.
+type PolicySigned struct {
+	// The public area of the authorizing key, whose Name is in the policy
+	// (see policy.AuthorizingKey).
+	AuthKey tpm2.Public
+	// The policyRef in the policy.
+	PolicyRef []byte
+	// If nonzero, the number of seconds after the session starts after which
+	// the authorization expires.
+	Expiration int32
+	// Authorize returns the authorizing key's signature of the authorization
+	// (see policy.SignedAuthorization.Sign), for example by sending it to the
+	// controller. It is called each time the key is used, with the nonceTPM of
+	// a new session, so a signature cannot be replayed.
+	Authorize func(auth policy.SignedAuthorization) (*tpm2.Signature, error)
+}
+
+func (p PolicySigned) assert(rw io.ReadWriter, session tpmutil.Handle, nonceTPM []byte) error {
+	if p.Authorize == nil {
+		return errors.New("PolicySigned has no Authorize function")
+	}
+	sig, err := p.Authorize(policy.SignedAuthorization{NonceTPM: nonceTPM, Expiration: p.Expiration, PolicyRef: p.PolicyRef})
+	if err != nil {
+		return fmt.Errorf("failed to get authorization: %w", err)
+	}
+	encodedSig, err := policy.EncodeSignature(sig)
+	if err != nil {
+		return err
+	}
+	authKey, _, err := tpm2.LoadExternal(rw, p.AuthKey, tpm2.Private{}, tpm2.HandleNull)
+	if err != nil {
+		return fmt.Errorf("failed to load authorizing key: %w", err)
+	}
+	defer tpm2.FlushContext(rw, authKey)
+	if _, err = internal.RunCommand(rw, internal.CmdPolicySigned, []tpmutil.Handle{authKey, session}, nil,
+		tpmutil.U16Bytes(nonceTPM), tpmutil.U16Bytes(nil), tpmutil.U16Bytes(p.PolicyRef), p.Expiration,
+		tpmutil.RawBytes(encodedSig)); err != nil {
+		return fmt.Errorf("PolicySigned failed: %w", err)
+	}
+	return nil
+}
+
+// PolicySecret satisfies TPM2_PolicySecret (see policy.Policy.Secret) with
+// the password of an entity, such as a hierarchy or an NV index.
+type PolicySecret struct {
+	// The handle of the entity, whose Name is in the policy.
+	AuthHandle tpmutil.Handle
+	// The entity's password, sent in the clear.
+	Auth []byte
+	// The policyRef in the policy.
+	PolicyRef []byte
+}
+
+func (p PolicySecret) assert(rw io.ReadWriter, session tpmutil.Handle, nonceTPM []byte) error {
+	auth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession, Auth: p.Auth}
+	if _, err := tpm2.PolicySecret(rw, p.AuthHandle, auth, session, nonceTPM, nil, p.PolicyRef, 0); err != nil {
+		return fmt.Errorf("PolicySecret failed: %w", err)
+	}
+	return nil
+}
+
+// PolicyPCR satisfies TPM2_PolicyPCR (see policy.Policy.PCR) with the current
+// values of the selected PCRs.
+type PolicyPCR struct {
+	Selection tpm2.PCRSelection
+}
+
+func (p PolicyPCR) assert(rw io.ReadWriter, session tpmutil.Handle, _ []byte) error {
+	if err := tpm2.PolicyPCR(rw, session, nil, p.Selection); err != nil {
+		return fmt.Errorf("PolicyPCR failed: %w", err)
+	}
+	return nil
+}
+
+// PolicyCommandCode satisfies TPM2_PolicyCommandCode (see
+// policy.Policy.CommandCode), for a key only usable with one command, such as
+// tpm2.CmdQuote.
+type PolicyCommandCode struct {
+	Command tpmutil.Command
+}
+
+func (p PolicyCommandCode) assert(rw io.ReadWriter, session tpmutil.Handle, _ []byte) error {
+	if err := tpm2.PolicyCommandCode(rw, session, p.Command); err != nil {
+		return fmt.Errorf("PolicyCommandCode failed: %w", err)
+	}
+	return nil
+}
+
+// SetPolicy sets the policy assertions which satisfy the key's auth policy, in
+// the order they appear in the policy. Each time the key is used (such as by
+// Quote, Attest, SignData or a Signer), they are run in a new policy session.
+// This allows using keys whose auth policy is not otherwise known to this
+// package, such as an AK which may only quote with a controller's approval.
+func (k *Key) SetPolicy(assertions ...PolicyAssertion) {
+	if k.session != nil {
+		k.session.Close()
+	}
+	k.session = &assertionSession{rw: k.rw, assertions: assertions}
+}
+
+// assertionSession satisfies a policy by running its assertions in a new
+// policy session for each authorization, so that signed authorizations are
+// bound to the session's nonce. The session lasts until the next
+// authorization, or until it is closed.
+type assertionSession struct {
+	rw         io.ReadWriter
+	assertions []PolicyAssertion
+	session    tpmutil.Handle
+}
+
+func (s *assertionSession) Auth() (auth tpm2.AuthCommand, err error) {
+	if err = s.Close(); err != nil {
+		return
+	}
+	session, nonceTPM, err := startPolicySession(s.rw)
+	if err != nil {
+		return auth, fmt.Errorf("failed to create session: %w", err)
+	}
+	s.session = session
+	for _, assertion := range s.assertions {
+		if err = assertion.assert(s.rw, session, nonceTPM); err != nil {
+			return
+		}
+	}
+	return tpm2.AuthCommand{Session: session, Attributes: tpm2.AttrContinueSession}, nil
+}
+
+func (s *assertionSession) Close() error {
+	if s.session == 0 {
+		return nil
+	}
+	session := s.session
+	s.session = 0
+	return tpm2.FlushContext(s.rw, session)
+}
+
+// unknownPolicySession is the session of a key whose auth policy is not known,
+// until the policy is set with Key.SetPolicy.
+type unknownPolicySession struct{}
+
+func (unknownPolicySession) Auth() (auth tpm2.AuthCommand, err error) {
+	return auth, errors.New("unknown auth policy for key, use Key.SetPolicy to satisfy it")
+}
+
+func (unknownPolicySession) Close() error {
+	return nil
+}
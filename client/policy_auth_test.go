@@ -0,0 +1,123 @@
+package client_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm-tools/policy"
+)
+
+func TestQuoteWithPolicySigned(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	controller, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authKey, err := policy.AuthorizingKey(controller.Public(), crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authKeyName, err := policy.ObjectName(authKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := []byte("quote")
+	authPolicy, err := policy.New(crypto.SHA256).Signed(authKeyName, ref).Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := client.AKTemplateECC()
+	template.Attributes &^= tpm2.FlagUserWithAuth
+	template.AuthPolicy = authPolicy
+	ak, err := client.NewKey(rwc, tpm2.HandleOwner, template)
+	if err != nil {
+		t.Fatalf("NewKey() with an unknown policy failed: %v", err)
+	}
+	defer ak.Close()
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{7}}
+	if _, err := ak.Quote(sel, []byte("nonce")); err == nil {
+		t.Error("Quote() before SetPolicy should fail")
+	}
+
+	var nonces [][]byte
+	ak.SetPolicy(client.PolicySigned{
+		AuthKey:   authKey,
+		PolicyRef: ref,
+		Authorize: func(auth policy.SignedAuthorization) (*tpm2.Signature, error) {
+			nonces = append(nonces, auth.NonceTPM)
+			return auth.Sign(controller, crypto.SHA256)
+		},
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := ak.Quote(sel, []byte("nonce")); err != nil {
+			t.Fatalf("Quote() with a signed authorization failed: %v", err)
+		}
+	}
+	if len(nonces) != 2 || string(nonces[0]) == string(nonces[1]) {
+		t.Errorf("each Quote() should be authorized with a new nonce, got %x", nonces)
+	}
+
+	// An authorization from another key does not satisfy the policy.
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ak.SetPolicy(client.PolicySigned{
+		AuthKey:   authKey,
+		PolicyRef: ref,
+		Authorize: func(auth policy.SignedAuthorization) (*tpm2.Signature, error) {
+			return auth.Sign(other, crypto.SHA256)
+		},
+	})
+	if _, err := ak.Quote(sel, []byte("nonce")); err == nil {
+		t.Error("Quote() authorized by another key should fail")
+	}
+
+	// A controller can refuse to authorize.
+	errRefused := errors.New("refused")
+	ak.SetPolicy(client.PolicySigned{
+		AuthKey:   authKey,
+		PolicyRef: ref,
+		Authorize: func(policy.SignedAuthorization) (*tpm2.Signature, error) {
+			return nil, errRefused
+		},
+	})
+	if _, err := ak.Quote(sel, []byte("nonce")); !errors.Is(err, errRefused) {
+		t.Errorf("Quote() refused by the controller = %v, want %v", err, errRefused)
+	}
+}
+
+func TestAttestWithPolicySecret(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	// The AK may only be used with the endorsement hierarchy's password.
+	authPolicy, err := policy.New(crypto.SHA256).Secret(policy.HandleName(tpm2.HandleEndorsement), nil).Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := client.AKTemplateRSA()
+	template.Attributes &^= tpm2.FlagUserWithAuth
+	template.AuthPolicy = authPolicy
+	ak, err := client.NewKey(rwc, tpm2.HandleOwner, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+
+	ak.SetPolicy(client.PolicySecret{AuthHandle: tpm2.HandleEndorsement})
+	if _, err := ak.Attest(client.AttestOpts{Nonce: []byte("nonce")}); err != nil {
+		t.Errorf("Attest() with PolicySecret failed: %v", err)
+	}
+}
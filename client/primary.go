@@ -0,0 +1,272 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// PrimaryKeyOpts customize a primary key created by NewPrimaryKey.
+type PrimaryKeyOpts struct {
+	// The hierarchy to create the key in: tpm2.HandleOwner (the default),
+	// tpm2.HandleEndorsement, tpm2.HandlePlatform or tpm2.HandleNull.
+	Hierarchy tpmutil.Handle
+	// The hierarchy's authorization value, if it has one.
+	HierarchyAuth []byte
+	// The key's template. If empty, SRKTemplateRSA is used.
+	Template tpm2.Public
+	// If set, replaces the template's unique field: the modulus of an RSA key,
+	// or the X and Y coordinates (concatenated, of equal length) of an ECC
+	// key. A primary key is derived from its hierarchy's seed and its
+	// template, so this creates a different key from the same template, such
+	// as an EK from a template with an EK nonce (see EKNonceNVIndexRSA).
+	Unique []byte
+	// The key's authorization value (password), required to use the key. A
+	// key with a password must have tpm2.FlagUserWithAuth in its template.
+	Auth []byte
+}
+
+// NewPrimaryKey creates a primary key in a hierarchy, from a custom template,
+// and loads it into the TPM. Unlike NewKey, the hierarchy and the key can have
+// passwords. As with NewKey, keys whose templates have an auth policy other
+// than those of the templates in this package must have
+// tpm2.FlagUserWithAuth, or have their policy set with Key.SetPolicy.
+func NewPrimaryKey(rw io.ReadWriter, opts PrimaryKeyOpts) (*Key, error) {
+	hierarchy := opts.Hierarchy
+	if hierarchy == 0 {
+		hierarchy = tpm2.HandleOwner
+	}
+	if !isHierarchy(hierarchy) {
+		return nil, fmt.Errorf("handle 0x%x is not a hierarchy", hierarchy)
+	}
+	template := opts.Template
+	if template.Type == tpm2.AlgUnknown {
+		template = SRKTemplateRSA()
+	}
+	if opts.Unique != nil {
+		var err error
+		if template, err = templateWithUnique(template, opts.Unique); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.Auth) != 0 && template.Attributes&tpm2.FlagUserWithAuth == 0 {
+		return nil, errors.New("a key with a password must have FlagUserWithAuth")
+	}
+	var s session
+	if len(opts.Auth) != 0 {
+		s = passwordSession{opts.Auth}
+	}
+	return createPrimary(rw, hierarchy, opts.HierarchyAuth, opts.Auth, template, s)
+}
+
+// createPrimary creates and loads a primary key, using the provided session
+// for authorization (or a session based on its auth policy if nil).
+func createPrimary(rw io.ReadWriter, hierarchy tpmutil.Handle, hierarchyAuth, auth []byte, template tpm2.Public, s session) (k *Key, err error) {
+	handle, pubArea, _, _, _, _, err :=
+		tpm2.CreatePrimaryEx(rw, hierarchy, tpm2.PCRSelection{}, string(hierarchyAuth), string(auth), template)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tpm2.FlushContext(rw, handle)
+		}
+	}()
+
+	k = &Key{rw: rw, handle: handle, session: s, hasPassword: len(auth) != 0}
+	if k.pubArea, err = tpm2.DecodePublic(pubArea); err != nil {
+		return
+	}
+	return k, k.finish()
+}
+
+// templateWithUnique returns a copy of the template with its unique field
+// replaced (see PrimaryKeyOpts.Unique).
+func templateWithUnique(template tpm2.Public, unique []byte) (tpm2.Public, error) {
+	switch template.Type {
+	case tpm2.AlgRSA:
+		if template.RSAParameters == nil {
+			return tpm2.Public{}, errors.New("RSA template has no parameters")
+		}
+		params := *template.RSAParameters
+		params.ModulusRaw = unique
+		template.RSAParameters = &params
+	case tpm2.AlgECC:
+		if template.ECCParameters == nil {
+			return tpm2.Public{}, errors.New("ECC template has no parameters")
+		}
+		if len(unique)%2 != 0 {
+			return tpm2.Public{}, fmt.Errorf("unique field of an ECC key has odd length %d", len(unique))
+		}
+		params := *template.ECCParameters
+		params.Point = tpm2.ECPoint{XRaw: unique[:len(unique)/2], YRaw: unique[len(unique)/2:]}
+		template.ECCParameters = &params
+	default:
+		return tpm2.Public{}, fmt.Errorf("unsupported key type %v", template.Type)
+	}
+	return template, nil
+}
+
+// EKTemplateRange selects between the two ranges of EK templates in the TCG
+// EK Credential Profile for TPM Family 2.0 (from version 2.3). TPMs may be
+// provisioned with EKs (and EK certificates) from either range, or both.
+type EKTemplateRange int
+
+const (
+	// The low range templates L-1 (RSA 2048) and L-2 (ECC NIST P-256), which
+	// are DefaultEKTemplateRSA and DefaultEKTemplateECC. Their certificates
+	// are at EKCertNVIndexRSA and EKCertNVIndexECC.
+	EKLowRange EKTemplateRange = iota
+	// The high range templates H-1 (RSA 2048) and H-2 (ECC NIST P-256), which
+	// are HighRangeEKTemplateRSA and HighRangeEKTemplateECC. Their
+	// certificates are at EKCertNVIndexRSAHighRange and
+	// EKCertNVIndexECCHighRange.
+	EKHighRange
+)
+
+func (r EKTemplateRange) String() string {
+	switch r {
+	case EKLowRange:
+		return "low"
+	case EKHighRange:
+		return "high"
+	default:
+		return fmt.Sprintf("EKTemplateRange(%d)", int(r))
+	}
+}
+
+// EKTemplate returns the EK template of the given key type (tpm2.AlgRSA or
+// tpm2.AlgECC) in the given range.
+func EKTemplate(keyType tpm2.Algorithm, r EKTemplateRange) (tpm2.Public, error) {
+	switch {
+	case keyType == tpm2.AlgRSA && r == EKLowRange:
+		return DefaultEKTemplateRSA(), nil
+	case keyType == tpm2.AlgECC && r == EKLowRange:
+		return DefaultEKTemplateECC(), nil
+	case keyType == tpm2.AlgRSA && r == EKHighRange:
+		return HighRangeEKTemplateRSA(), nil
+	case keyType == tpm2.AlgECC && r == EKHighRange:
+		return HighRangeEKTemplateECC(), nil
+	default:
+		return tpm2.Public{}, fmt.Errorf("no %v EK template for key type %v", r, keyType)
+	}
+}
+
+// EKCertNVIndex returns the NV index holding the certificate of the EK
+// created from EKTemplate(keyType, r).
+func EKCertNVIndex(keyType tpm2.Algorithm, r EKTemplateRange) (uint32, error) {
+	switch {
+	case keyType == tpm2.AlgRSA && r == EKLowRange:
+		return EKCertNVIndexRSA, nil
+	case keyType == tpm2.AlgECC && r == EKLowRange:
+		return EKCertNVIndexECC, nil
+	case keyType == tpm2.AlgRSA && r == EKHighRange:
+		return EKCertNVIndexRSAHighRange, nil
+	case keyType == tpm2.AlgECC && r == EKHighRange:
+		return EKCertNVIndexECCHighRange, nil
+	default:
+		return 0, fmt.Errorf("no %v EK certificate index for key type %v", r, keyType)
+	}
+}
+
+// EndorsementKey creates and loads the EK of the given key type from the
+// given range of EK templates. Low range EKs are cached at their reserved
+// handles, like EndorsementKeyRSA and EndorsementKeyECC. High range EKs have
+// no reserved handles, so they are created each time.
+func EndorsementKey(rw io.ReadWriter, keyType tpm2.Algorithm, r EKTemplateRange) (*Key, error) {
+	template, err := EKTemplate(keyType, r)
+	if err != nil {
+		return nil, err
+	}
+	if r == EKLowRange {
+		if keyType == tpm2.AlgRSA {
+			return EndorsementKeyRSA(rw)
+		}
+		return EndorsementKeyECC(rw)
+	}
+	return NewKey(rw, tpm2.HandleEndorsement, template)
+}
+
+// ProvisionedEKTemplate returns the template of the low range EK of the given
+// key type (tpm2.AlgRSA or tpm2.AlgECC) as provisioned by the TPM's
+// manufacturer, so the EK created from it matches the EK certificate. As in
+// the TCG EK Credential Profile, a manufacturer may store an EK Template
+// (at EKTemplateNVIndexRSA or EKTemplateNVIndexECC), which replaces
+// EKTemplate(keyType, EKLowRange), and an EK Nonce (at EKNonceNVIndexRSA or
+// EKNonceNVIndexECC), which is placed in the template's unique field. The
+// second result reports whether either index was present.
+func ProvisionedEKTemplate(rw io.ReadWriter, keyType tpm2.Algorithm) (tpm2.Public, bool, error) {
+	template, err := EKTemplate(keyType, EKLowRange)
+	if err != nil {
+		return tpm2.Public{}, false, err
+	}
+	nonceIndex, templateIndex := EKNonceNVIndexRSA, EKTemplateNVIndexRSA
+	if keyType == tpm2.AlgECC {
+		nonceIndex, templateIndex = EKNonceNVIndexECC, EKTemplateNVIndexECC
+	}
+	indexes, err := Handles(rw, tpm2.HandleTypeNVIndex)
+	if err != nil {
+		return tpm2.Public{}, false, fmt.Errorf("failed to list NV indexes: %w", err)
+	}
+	hasTemplate, hasNonce := false, false
+	for _, index := range indexes {
+		hasTemplate = hasTemplate || uint32(index) == templateIndex
+		hasNonce = hasNonce || uint32(index) == nonceIndex
+	}
+
+	if hasTemplate {
+		if template, err = templateFromNvIndex(rw, templateIndex); err != nil {
+			return tpm2.Public{}, false, fmt.Errorf("failed to read EK template: %w", err)
+		}
+		if template.Type != keyType {
+			return tpm2.Public{}, false, fmt.Errorf("EK template at index 0x%x is for a %v key, want %v", templateIndex, template.Type, keyType)
+		}
+	}
+	if hasNonce {
+		nonce, err := tpm2.NVReadEx(rw, tpmutil.Handle(nonceIndex), tpm2.HandleOwner, "", 0)
+		if err != nil {
+			return tpm2.Public{}, false, fmt.Errorf("failed to read EK nonce: %w", err)
+		}
+		if template, err = templateWithNonce(template, nonce); err != nil {
+			return tpm2.Public{}, false, err
+		}
+	}
+	return template, hasTemplate || hasNonce, nil
+}
+
+// templateWithNonce places an EK Nonce in a template's unique field: an RSA
+// modulus is the nonce padded with zeros to the key size, and an ECC point
+// has the nonce padded with zeros to the coordinate size as its X coordinate,
+// and zeros as its Y coordinate.
+func templateWithNonce(template tpm2.Public, nonce []byte) (tpm2.Public, error) {
+	var size int
+	switch {
+	case template.Type == tpm2.AlgRSA && template.RSAParameters != nil:
+		size = int(template.RSAParameters.KeyBits) / 8
+	case template.Type == tpm2.AlgECC && template.ECCParameters != nil:
+		switch template.ECCParameters.CurveID {
+		case tpm2.CurveNISTP256:
+			size = 32
+		case tpm2.CurveNISTP384:
+			size = 48
+		case tpm2.CurveNISTP521:
+			size = 66
+		default:
+			return tpm2.Public{}, fmt.Errorf("unsupported EK curve %v", template.ECCParameters.CurveID)
+		}
+	default:
+		return tpm2.Public{}, fmt.Errorf("unsupported EK template type %v", template.Type)
+	}
+	if len(nonce) > size {
+		return tpm2.Public{}, fmt.Errorf("EK nonce is %d bytes, longer than the key's %d bytes", len(nonce), size)
+	}
+	unique := make([]byte, size)
+	copy(unique, nonce)
+	if template.Type == tpm2.AlgECC {
+		unique = append(unique, make([]byte, size)...)
+	}
+	return templateWithUnique(template, unique)
+}
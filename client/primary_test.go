@@ -0,0 +1,260 @@
+package client_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+func TestNewPrimaryKey(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	for _, hierarchy := range []tpmutil.Handle{0, tpm2.HandleOwner, tpm2.HandleEndorsement, tpm2.HandleNull} {
+		key, err := client.NewPrimaryKey(rwc, client.PrimaryKeyOpts{Hierarchy: hierarchy})
+		if err != nil {
+			t.Fatalf("client.NewPrimaryKey() in hierarchy 0x%x failed: %v", hierarchy, err)
+		}
+		if !key.PublicArea().MatchesTemplate(client.SRKTemplateRSA()) {
+			t.Errorf("key in hierarchy 0x%x does not match the default template", hierarchy)
+		}
+		key.Close()
+	}
+
+	srk, err := client.StorageRootKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	unique := bytes.Repeat([]byte{0x5a}, 256)
+	customized, err := client.NewPrimaryKey(rwc, client.PrimaryKeyOpts{Unique: unique})
+	if err != nil {
+		t.Fatalf("client.NewPrimaryKey() with a unique field failed: %v", err)
+	}
+	defer customized.Close()
+	again, err := client.NewPrimaryKey(rwc, client.PrimaryKeyOpts{Unique: unique})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	if srk.PublicKey().(*rsa.PublicKey).Equal(customized.PublicKey()) {
+		t.Error("unique field did not change the key")
+	}
+	if !customized.PublicKey().(*rsa.PublicKey).Equal(again.PublicKey()) {
+		t.Error("keys from the same unique field differ")
+	}
+
+	ecc, err := client.NewPrimaryKey(rwc, client.PrimaryKeyOpts{Template: client.SRKTemplateECC(), Unique: bytes.Repeat([]byte{1}, 64)})
+	if err != nil {
+		t.Fatalf("client.NewPrimaryKey() of an ECC key with a unique field failed: %v", err)
+	}
+	defer ecc.Close()
+	if _, ok := ecc.PublicKey().(*ecdsa.PublicKey); !ok {
+		t.Errorf("got public key %T, want an ECC key", ecc.PublicKey())
+	}
+
+	for name, opts := range map[string]client.PrimaryKeyOpts{
+		"NotAHierarchy":       {Hierarchy: client.SRKReservedHandle},
+		"OddECCUnique":        {Template: client.SRKTemplateECC(), Unique: []byte{1, 2, 3}},
+		"PasswordWithoutAuth": {Template: client.DefaultEKTemplateRSA(), Auth: []byte("password")},
+		"WrongHierarchyAuth":  {HierarchyAuth: []byte("password")},
+	} {
+		if key, err := client.NewPrimaryKey(rwc, opts); err == nil {
+			key.Close()
+			t.Errorf("client.NewPrimaryKey() with %s should fail", name)
+		}
+	}
+}
+
+func TestPrimaryKeyPasswords(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ownerAuth := []byte("owner password")
+	noAuth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+	if err := tpm2.HierarchyChangeAuth(rwc, tpm2.HandleOwner, noAuth, string(ownerAuth)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		auth := noAuth
+		auth.Auth = ownerAuth
+		if err := tpm2.HierarchyChangeAuth(rwc, tpm2.HandleOwner, auth, ""); err != nil {
+			t.Errorf("resetting the owner password failed: %v", err)
+		}
+	}()
+	if key, err := client.NewPrimaryKey(rwc, client.PrimaryKeyOpts{Template: client.AKTemplateRSA()}); err == nil {
+		key.Close()
+		t.Error("client.NewPrimaryKey() without the owner password should fail")
+	}
+
+	template := client.AKTemplateRSA()
+	template.Attributes &^= tpm2.FlagRestricted
+	keyAuth := []byte("key password")
+	key, err := client.NewPrimaryKey(rwc, client.PrimaryKeyOpts{HierarchyAuth: ownerAuth, Template: template, Auth: keyAuth})
+	if err != nil {
+		t.Fatalf("client.NewPrimaryKey() with passwords failed: %v", err)
+	}
+	defer key.Close()
+	if _, err := key.SignData([]byte("data")); err != nil {
+		t.Errorf("signing with the key's password failed: %v", err)
+	}
+	digest := make([]byte, 32)
+	if _, err := tpm2.Sign(rwc, key.Handle(), "", digest, nil, nil); err == nil {
+		t.Error("signing without the key's password should fail")
+	}
+}
+
+func TestEKTemplateRanges(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	for _, keyType := range []tpm2.Algorithm{tpm2.AlgRSA, tpm2.AlgECC} {
+		low, err := client.EndorsementKey(rwc, keyType, client.EKLowRange)
+		if err != nil {
+			t.Fatalf("client.EndorsementKey(%v, %v) failed: %v", keyType, client.EKLowRange, err)
+		}
+		defer low.Close()
+		high, err := client.EndorsementKey(rwc, keyType, client.EKHighRange)
+		if err != nil {
+			t.Fatalf("client.EndorsementKey(%v, %v) failed: %v", keyType, client.EKHighRange, err)
+		}
+		defer high.Close()
+		if cmp.Equal(low.PublicKey(), high.PublicKey()) {
+			t.Errorf("low and high range %v EKs are the same key", keyType)
+		}
+		highTemplate, err := client.EKTemplate(keyType, client.EKHighRange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !high.PublicArea().MatchesTemplate(highTemplate) {
+			t.Errorf("high range %v EK does not match its template", keyType)
+		}
+
+		lowIndex, err := client.EKCertNVIndex(keyType, client.EKLowRange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		highIndex, err := client.EKCertNVIndex(keyType, client.EKHighRange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lowIndex == highIndex {
+			t.Errorf("low and high range %v EK certificates share index 0x%x", keyType, lowIndex)
+		}
+	}
+	if _, err := client.EKTemplate(tpm2.AlgSymCipher, client.EKLowRange); err == nil {
+		t.Error("client.EKTemplate() of a symmetric key should fail")
+	}
+	if _, err := client.EKCertNVIndex(tpm2.AlgRSA, client.EKTemplateRange(2)); err == nil {
+		t.Error("client.EKCertNVIndex() of an unknown range should fail")
+	}
+}
+
+// defineEKIndex defines an owner-writable NV index holding data, in place of
+// one provisioned by a TPM manufacturer, returning a function undefining it.
+func defineEKIndex(t *testing.T, rwc io.ReadWriter, index uint32, data []byte) func() {
+	t.Helper()
+	attrs := tpm2.AttrOwnerWrite | tpm2.AttrOwnerRead | tpm2.AttrAuthRead | tpm2.AttrNoDA
+	if err := tpm2.NVDefineSpace(rwc, tpm2.HandleOwner, tpmutil.Handle(index), "", "", nil, attrs, uint16(len(data))); err != nil {
+		t.Fatalf("failed to define NV index 0x%x: %v", index, err)
+	}
+	if err := tpm2.NVWrite(rwc, tpm2.HandleOwner, tpmutil.Handle(index), "", data, 0); err != nil {
+		t.Fatalf("failed to write NV index 0x%x: %v", index, err)
+	}
+	return func() {
+		if err := tpm2.NVUndefineSpace(rwc, "", tpm2.HandleOwner, tpmutil.Handle(index)); err != nil {
+			t.Errorf("failed to undefine NV index 0x%x: %v", index, err)
+		}
+	}
+}
+
+func TestProvisionedEKTemplate(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	for _, keyType := range []tpm2.Algorithm{tpm2.AlgRSA, tpm2.AlgECC} {
+		template, provisioned, err := client.ProvisionedEKTemplate(rwc, keyType)
+		if err != nil {
+			t.Fatalf("client.ProvisionedEKTemplate(%v) failed: %v", keyType, err)
+		}
+		defaultTemplate, err := client.EKTemplate(keyType, client.EKLowRange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if provisioned || !cmp.Equal(template, defaultTemplate) {
+			t.Errorf("without provisioned indexes, got template %+v (provisioned %v), want the default EK template", template, provisioned)
+		}
+	}
+	defaultEK, err := client.EndorsementKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultPub := defaultEK.PublicKey()
+	defaultEK.Close()
+
+	// An RSA EK Nonce is padded to the modulus size.
+	nonce := []byte("manufacturer EK nonce")
+	defer defineEKIndex(t, rwc, client.EKNonceNVIndexRSA, nonce)()
+	ek, err := client.EndorsementKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("client.EndorsementKeyRSA() with an EK nonce failed: %v", err)
+	}
+	unique := make([]byte, 256)
+	copy(unique, nonce)
+	want, err := client.NewPrimaryKey(rwc, client.PrimaryKeyOpts{Hierarchy: tpm2.HandleEndorsement, Template: client.DefaultEKTemplateRSA(), Unique: unique})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(ek.PublicKey(), want.PublicKey()) || cmp.Equal(ek.PublicKey(), defaultPub) {
+		t.Error("RSA EK was not created with the EK nonce")
+	}
+	ek.Close()
+	want.Close()
+
+	// An ECC EK Template replaces the default template, and the EK Nonce is
+	// the X coordinate of its unique field.
+	ekTemplate := client.DefaultEKTemplateECC()
+	ekTemplate.Attributes |= tpm2.FlagNoDA
+	encoded, err := ekTemplate.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer defineEKIndex(t, rwc, client.EKTemplateNVIndexECC, encoded)()
+	defer defineEKIndex(t, rwc, client.EKNonceNVIndexECC, nonce)()
+	template, provisioned, err := client.ProvisionedEKTemplate(rwc, tpm2.AlgECC)
+	if err != nil {
+		t.Fatalf("client.ProvisionedEKTemplate() with an EK template failed: %v", err)
+	}
+	if !provisioned || !template.MatchesTemplate(ekTemplate) {
+		t.Errorf("got template %+v (provisioned %v), want the provisioned template", template, provisioned)
+	}
+	x := make([]byte, 32)
+	copy(x, nonce)
+	if point := template.ECCParameters.Point; !bytes.Equal(point.XRaw, x) || !bytes.Equal(point.YRaw, make([]byte, 32)) {
+		t.Errorf("got unique field %x, %x, want the nonce as X", point.XRaw, point.YRaw)
+	}
+	eccEK, err := client.EndorsementKeyECC(rwc)
+	if err != nil {
+		t.Fatalf("client.EndorsementKeyECC() with an EK template failed: %v", err)
+	}
+	defer eccEK.Close()
+	if !eccEK.PublicArea().MatchesTemplate(ekTemplate) {
+		t.Error("ECC EK does not match the provisioned template")
+	}
+
+	// Templates for the wrong key type, and nonces larger than the key, are
+	// rejected.
+	defer defineEKIndex(t, rwc, client.EKTemplateNVIndexRSA, encoded)()
+	if _, _, err := client.ProvisionedEKTemplate(rwc, tpm2.AlgRSA); err == nil {
+		t.Error("client.ProvisionedEKTemplate() with an ECC template for the RSA EK should fail")
+	}
+}
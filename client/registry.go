@@ -0,0 +1,434 @@
+package client
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/google/go-tpm-tools/policy"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNameNotFound is returned by NameRegistry.Lookup for unregistered names.
+var ErrNameNotFound = errors.New("name is not registered")
+
+// ErrDrift is wrapped by the errors NameRegistry.CheckDrift returns when a
+// registered target no longer matches its fingerprint, which means it was
+// replaced or modified since it was registered.
+var ErrDrift = errors.New("registered target has changed")
+
+var validName = regexp.MustCompile(`^[a-z][a-z0-9._-]{0,63}$`)
+
+// RegistryStore is where a NameRegistry keeps its serialized entries.
+type RegistryStore interface {
+	// Load returns the stored data, or nil if nothing has been stored.
+	Load() ([]byte, error)
+	// Save replaces the stored data.
+	Save(data []byte) error
+}
+
+type nvRegistryStore struct {
+	rw    io.ReadWriter
+	index uint32
+}
+
+// NVRegistryStore stores a NameRegistry in an NV index, owned by the owner
+// hierarchy. The index is redefined with the registry's size on every save, so
+// it should not be used for anything else. Only the owner can write the index,
+// so other users cannot redirect names; loading fails if the index could be
+// written by anyone else.
+func NVRegistryStore(rw io.ReadWriter, index uint32) RegistryStore {
+	return &nvRegistryStore{rw, index}
+}
+
+func (s *nvRegistryStore) exists() (bool, error) {
+	handles, err := Handles(s.rw, tpm2.HandleTypeNVIndex)
+	if err != nil {
+		return false, fmt.Errorf("failed to list NV indices: %w", err)
+	}
+	for _, handle := range handles {
+		if handle == tpmutil.Handle(s.index) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// registryNVAttributes allow only the owner to write the registry's index,
+// and anyone to read it.
+const registryNVAttributes = tpm2.AttrOwnerWrite | tpm2.AttrOwnerRead | tpm2.AttrAuthRead | tpm2.AttrNoDA
+
+func (s *nvRegistryStore) Load() ([]byte, error) {
+	if exists, err := s.exists(); err != nil || !exists {
+		return nil, err
+	}
+	n, err := OpenNVIndex(s.rw, s.index, tpm2.PCRSelection{})
+	if err != nil {
+		return nil, err
+	}
+	if attrs := n.Public().Attributes; attrs&tpm2.AttrOwnerWrite == 0 || attrs&(tpm2.AttrAuthWrite|tpm2.AttrPolicyWrite) != 0 {
+		return nil, fmt.Errorf("NV index 0x%x has attributes %v, and can be written by others than the owner", s.index, attrs)
+	}
+	return n.Read()
+}
+
+func (s *nvRegistryStore) Save(data []byte) error {
+	exists, err := s.exists()
+	if err != nil {
+		return err
+	}
+	if exists {
+		if err := tpm2.NVUndefineSpace(s.rw, "", tpm2.HandleOwner, tpmutil.Handle(s.index)); err != nil {
+			return fmt.Errorf("failed to delete NV index 0x%x: %w", s.index, err)
+		}
+	}
+	// An empty registry is stored by not defining the index.
+	if len(data) == 0 {
+		return nil
+	}
+	if len(data) > 0xFFFF {
+		return fmt.Errorf("registry is too large for an NV index (%d bytes)", len(data))
+	}
+	pub := tpm2.NVPublic{
+		NVIndex:    tpmutil.Handle(s.index),
+		NameAlg:    SessionHashAlgTpm,
+		Attributes: registryNVAttributes,
+		DataSize:   uint16(len(data)),
+	}
+	ownerAuth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+	if err := tpm2.NVDefineSpaceEx(s.rw, tpm2.HandleOwner, "", pub, ownerAuth); err != nil {
+		return fmt.Errorf("failed to define NV index 0x%x: %w", s.index, err)
+	}
+	n, err := OpenNVIndex(s.rw, s.index, tpm2.PCRSelection{})
+	if err != nil {
+		return err
+	}
+	return n.Write(data)
+}
+
+type fileRegistryStore struct {
+	rw   io.ReadWriter
+	path string
+}
+
+// FileRegistryStore stores a NameRegistry in a local file, signed by a key
+// derived from the TPM's owner hierarchy. Loading fails if the file was
+// modified, or was signed by another TPM, so the file can be kept somewhere
+// other users can write to without them being able to redirect names. Like the
+// index of NVRegistryStore, only the owner can sign the file: the key can only
+// be used with the owner hierarchy's authorization (which is assumed to be
+// empty). However, an old version of the file could be restored undetected.
+func FileRegistryStore(rw io.ReadWriter, path string) RegistryStore {
+	return &fileRegistryStore{rw, path}
+}
+
+// registryKeyTemplate is the template of the unrestricted signing key used by
+// FileRegistryStore. It has no password, and its auth policy is
+// TPM2_PolicySecret with the owner hierarchy, so that anyone who can load it
+// cannot also sign with it. The unique value keeps the key separate from other
+// keys created with similar templates.
+func registryKeyTemplate() tpm2.Public {
+	return tpm2.Public{
+		Type:    tpm2.AlgECC,
+		NameAlg: tpm2.AlgSHA256,
+		Attributes: tpm2.FlagSign | tpm2.FlagFixedTPM | tpm2.FlagFixedParent |
+			tpm2.FlagSensitiveDataOrigin | tpm2.FlagAdminWithPolicy,
+		AuthPolicy: registryKeyAuthPolicy(),
+		ECCParameters: &tpm2.ECCParams{
+			Sign:    &tpm2.SigScheme{Alg: tpm2.AlgECDSA, Hash: tpm2.AlgSHA256},
+			CurveID: tpm2.CurveNISTP256,
+			Point:   tpm2.ECPoint{XRaw: []byte("go-tpm-tools name registry")},
+		},
+	}
+}
+
+// registryKeyAuthPolicy is the policy digest of TPM2_PolicySecret with the
+// owner hierarchy.
+func registryKeyAuthPolicy() []byte {
+	digest, err := policy.New(crypto.SHA256).Secret(policy.HandleName(tpm2.HandleOwner), nil).Digest()
+	if err != nil {
+		panic(err)
+	}
+	return digest
+}
+
+func (s *fileRegistryStore) Load() ([]byte, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var signed pb.SignedRegistry
+	if err := proto.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("failed to decode registry file: %w", err)
+	}
+	key, err := NewKey(s.rw, tpm2.HandleOwner, registryKeyTemplate())
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+	digest := sha256.Sum256(signed.GetRegistry())
+	pub, ok := key.PublicKey().(*ecdsa.PublicKey)
+	if !ok || !ecdsa.VerifyASN1(pub, digest[:], signed.GetSignature()) {
+		return nil, fmt.Errorf("registry file %s has an invalid signature", s.path)
+	}
+	return signed.GetRegistry(), nil
+}
+
+func (s *fileRegistryStore) Save(data []byte) error {
+	key, err := NewKey(s.rw, tpm2.HandleOwner, registryKeyTemplate())
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	key.SetPolicy(PolicySecret{AuthHandle: tpm2.HandleOwner})
+	sig, err := key.SignData(data)
+	if err != nil {
+		return fmt.Errorf("failed to sign registry: %w", err)
+	}
+	signed, err := proto.Marshal(&pb.SignedRegistry{Registry: data, Signature: sig})
+	if err != nil {
+		return err
+	}
+	// Replace the file atomically, so concurrent readers see either version.
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(signed); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// NameRegistry maps human-readable names (such as "disk-key") to persistent
+// handles, NV indices and files holding sealed blobs, so tooling can refer to
+// them by name rather than by handle. Each entry records a fingerprint of its
+// target, which CheckDrift compares against the target's current state.
+//
+// Every change is saved to the RegistryStore immediately. A NameRegistry is not
+// safe for concurrent use, and concurrent changes from different processes may
+// be lost.
+type NameRegistry struct {
+	rw      io.ReadWriter
+	store   RegistryStore
+	entries map[string]*pb.RegistryEntry
+}
+
+// OpenNameRegistry loads a NameRegistry from the store, or returns an empty
+// registry if nothing has been stored.
+func OpenNameRegistry(rw io.ReadWriter, store RegistryStore) (*NameRegistry, error) {
+	data, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registry: %w", err)
+	}
+	var registry pb.Registry
+	if err := proto.Unmarshal(data, &registry); err != nil {
+		return nil, fmt.Errorf("failed to decode registry: %w", err)
+	}
+	r := &NameRegistry{rw, store, make(map[string]*pb.RegistryEntry)}
+	for _, entry := range registry.GetEntries() {
+		r.entries[entry.GetName()] = entry
+	}
+	return r, nil
+}
+
+// AddPersistentHandle registers a name for the object at an owner hierarchy
+// persistent handle.
+func (r *NameRegistry) AddPersistentHandle(name string, handle tpmutil.Handle) error {
+	if !isOwnerPersistent(handle) {
+		return fmt.Errorf("0x%x is not an owner hierarchy persistent handle", handle)
+	}
+	return r.add(&pb.RegistryEntry{
+		Name:   name,
+		Target: &pb.RegistryEntry_PersistentHandle{PersistentHandle: uint32(handle)},
+	})
+}
+
+// AddNVIndex registers a name for an NV index.
+func (r *NameRegistry) AddNVIndex(name string, index uint32) error {
+	return r.add(&pb.RegistryEntry{
+		Name:   name,
+		Target: &pb.RegistryEntry_NvIndex{NvIndex: index},
+	})
+}
+
+// AddSealedBlob registers a name for a file holding sealed data. The path is
+// made absolute, so the name can be used from any directory.
+func (r *NameRegistry) AddSealedBlob(name string, path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	return r.add(&pb.RegistryEntry{
+		Name:   name,
+		Target: &pb.RegistryEntry_SealedBlobPath{SealedBlobPath: path},
+	})
+}
+
+func (r *NameRegistry) add(entry *pb.RegistryEntry) error {
+	if !validName.MatchString(entry.GetName()) {
+		return fmt.Errorf("invalid name %q: names must start with a letter, and contain only a-z, 0-9, '.', '_' and '-'", entry.GetName())
+	}
+	if _, ok := r.entries[entry.GetName()]; ok {
+		return fmt.Errorf("name %q is already registered", entry.GetName())
+	}
+	for _, other := range r.entries {
+		if sameTarget(entry, other) {
+			return fmt.Errorf("%s is already registered as %q", describeTarget(entry), other.GetName())
+		}
+	}
+	fingerprint, err := r.fingerprint(entry)
+	if err != nil {
+		return err
+	}
+	entry.Fingerprint = fingerprint
+	r.entries[entry.GetName()] = entry
+	if err := r.save(); err != nil {
+		delete(r.entries, entry.GetName())
+		return err
+	}
+	return nil
+}
+
+// Remove unregisters a name. The target itself is not modified.
+func (r *NameRegistry) Remove(name string) error {
+	entry, ok := r.entries[name]
+	if !ok {
+		return fmt.Errorf("%q: %w", name, ErrNameNotFound)
+	}
+	delete(r.entries, name)
+	if err := r.save(); err != nil {
+		r.entries[name] = entry
+		return err
+	}
+	return nil
+}
+
+// Lookup returns the entry for a name, or an error wrapping ErrNameNotFound.
+func (r *NameRegistry) Lookup(name string) (*pb.RegistryEntry, error) {
+	entry, ok := r.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", name, ErrNameNotFound)
+	}
+	return entry, nil
+}
+
+// Entries returns all the registry's entries, sorted by name.
+func (r *NameRegistry) Entries() []*pb.RegistryEntry {
+	entries := make([]*pb.RegistryEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].GetName() < entries[j].GetName()
+	})
+	return entries
+}
+
+// CheckDrift checks that a name's target still matches the fingerprint taken
+// when it was registered. It returns an error wrapping ErrDrift if the target
+// was modified or replaced, or another error if the target no longer exists.
+func (r *NameRegistry) CheckDrift(name string) error {
+	entry, err := r.Lookup(name)
+	if err != nil {
+		return err
+	}
+	fingerprint, err := r.fingerprint(entry)
+	if err != nil {
+		return fmt.Errorf("%q: %w", name, err)
+	}
+	if !bytes.Equal(fingerprint, entry.GetFingerprint()) {
+		return fmt.Errorf("%q (%s): %w", name, describeTarget(entry), ErrDrift)
+	}
+	return nil
+}
+
+func (r *NameRegistry) save() error {
+	data, err := proto.Marshal(&pb.Registry{Entries: r.Entries()})
+	if err != nil {
+		return err
+	}
+	if err := r.store.Save(data); err != nil {
+		return fmt.Errorf("failed to save registry: %w", err)
+	}
+	return nil
+}
+
+// fingerprint computes the SHA-256 digest identifying an entry's target: the
+// Name of a persistent object, the public area of an NV index (ignoring
+// whether it has been written), or the contents of a sealed blob.
+func (r *NameRegistry) fingerprint(entry *pb.RegistryEntry) ([]byte, error) {
+	var data []byte
+	switch target := entry.GetTarget().(type) {
+	case *pb.RegistryEntry_PersistentHandle:
+		_, name, _, err := tpm2.ReadPublic(r.rw, tpmutil.Handle(target.PersistentHandle))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public area at 0x%x: %w", target.PersistentHandle, err)
+		}
+		data = name
+	case *pb.RegistryEntry_NvIndex:
+		pub, err := tpm2.NVReadPublic(r.rw, tpmutil.Handle(target.NvIndex))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NV public area at 0x%x: %w", target.NvIndex, err)
+		}
+		pub.Attributes &^= tpm2.AttrWritten
+		if data, err = tpmutil.Pack(pub); err != nil {
+			return nil, err
+		}
+	case *pb.RegistryEntry_SealedBlobPath:
+		var err error
+		if data, err = ioutil.ReadFile(target.SealedBlobPath); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("entry %q has no target", entry.GetName())
+	}
+	digest := sha256.Sum256(data)
+	return digest[:], nil
+}
+
+func sameTarget(a, b *pb.RegistryEntry) bool {
+	switch target := a.GetTarget().(type) {
+	case *pb.RegistryEntry_PersistentHandle:
+		return b.GetPersistentHandle() == target.PersistentHandle && target.PersistentHandle != 0
+	case *pb.RegistryEntry_NvIndex:
+		return b.GetNvIndex() == target.NvIndex && target.NvIndex != 0
+	case *pb.RegistryEntry_SealedBlobPath:
+		return b.GetSealedBlobPath() == target.SealedBlobPath && target.SealedBlobPath != ""
+	default:
+		return false
+	}
+}
+
+func describeTarget(entry *pb.RegistryEntry) string {
+	switch target := entry.GetTarget().(type) {
+	case *pb.RegistryEntry_PersistentHandle:
+		return fmt.Sprintf("persistent handle 0x%x", target.PersistentHandle)
+	case *pb.RegistryEntry_NvIndex:
+		return fmt.Sprintf("NV index 0x%x", target.NvIndex)
+	case *pb.RegistryEntry_SealedBlobPath:
+		return fmt.Sprintf("sealed blob %s", target.SealedBlobPath)
+	default:
+		return "no target"
+	}
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+
+	"github.com/google/go-tpm-tools/policy"
+	"github.com/google/go-tpm/tpm2"
+)
+
+func TestRegistryKeyTemplate(t *testing.T) {
+	template := registryKeyTemplate()
+	// Anyone can load the key from the owner hierarchy, so it must not be
+	// usable with its (empty) password.
+	if template.Attributes&tpm2.FlagUserWithAuth != 0 {
+		t.Error("the registry key can be used with its password")
+	}
+	want, err := policy.New(crypto.SHA256).Secret(policy.HandleName(tpm2.HandleOwner), nil).Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(template.AuthPolicy, want) {
+		t.Errorf("registry key has auth policy %x, want PolicySecret(TPM_RH_OWNER) %x", template.AuthPolicy, want)
+	}
+}
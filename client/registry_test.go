@@ -0,0 +1,228 @@
+package client_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+const registryIndex = 0x1500100
+
+func TestNameRegistry(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	dir, err := ioutil.TempDir("", "registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stores := []struct {
+		name  string
+		store client.RegistryStore
+	}{
+		{"NV", client.NVRegistryStore(rwc, registryIndex)},
+		{"File", client.FileRegistryStore(rwc, filepath.Join(dir, "registry"))},
+	}
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			key, err := client.NewPersistentKey(rwc, tpm2.HandleOwner, client.AKTemplateECC())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer key.Evict()
+			nv, err := client.DefineNVIndex(rwc, 0x1500101, client.NVIndexOpts{Size: 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer nv.Delete()
+			blob := filepath.Join(dir, "blob")
+			if err := ioutil.WriteFile(blob, []byte("sealed"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			registry, err := client.OpenNameRegistry(rwc, s.store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := registry.AddPersistentHandle("signing-key", key.Handle()); err != nil {
+				t.Fatal(err)
+			}
+			if err := registry.AddNVIndex("serial", 0x1500101); err != nil {
+				t.Fatal(err)
+			}
+			if err := registry.AddSealedBlob("disk-key", blob); err != nil {
+				t.Fatal(err)
+			}
+
+			// Collisions are rejected.
+			if err := registry.AddNVIndex("serial", 0x1500101); err == nil {
+				t.Error("registering a name twice should fail")
+			}
+			if err := registry.AddPersistentHandle("other-key", key.Handle()); err == nil {
+				t.Error("registering a handle under two names should fail")
+			}
+			if err := registry.AddSealedBlob("Disk Key", blob); err == nil {
+				t.Error("registering an invalid name should fail")
+			}
+
+			// The entries are reloaded from the store.
+			registry, err = client.OpenNameRegistry(rwc, s.store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(registry.Entries()) != 3 {
+				t.Fatalf("got %d entries, want 3", len(registry.Entries()))
+			}
+			entry, err := registry.Lookup("signing-key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.GetPersistentHandle() != uint32(key.Handle()) {
+				t.Errorf("signing-key refers to 0x%x, want 0x%x", entry.GetPersistentHandle(), key.Handle())
+			}
+			if _, err := registry.Lookup("missing"); !errors.Is(err, client.ErrNameNotFound) {
+				t.Errorf("Lookup(missing) = %v, want ErrNameNotFound", err)
+			}
+			for _, entry := range registry.Entries() {
+				if err := registry.CheckDrift(entry.GetName()); err != nil {
+					t.Error(err)
+				}
+			}
+
+			// Writing the NV index does not count as drift, modifying the blob
+			// does.
+			if err := nv.Write([]byte("1234")); err != nil {
+				t.Fatal(err)
+			}
+			if err := registry.CheckDrift("serial"); err != nil {
+				t.Error(err)
+			}
+			if err := ioutil.WriteFile(blob, []byte("modified"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := registry.CheckDrift("disk-key"); !errors.Is(err, client.ErrDrift) {
+				t.Errorf("CheckDrift(disk-key) = %v, want ErrDrift", err)
+			}
+
+			for _, entry := range registry.Entries() {
+				if err := registry.Remove(entry.GetName()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if registry, err = client.OpenNameRegistry(rwc, s.store); err != nil {
+				t.Fatal(err)
+			}
+			if len(registry.Entries()) != 0 {
+				t.Errorf("got %d entries after removing them all", len(registry.Entries()))
+			}
+		})
+	}
+}
+
+func TestNVRegistryStoreOwnerWrite(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	store := client.NVRegistryStore(rwc, registryIndex)
+	if err := store.Save([]byte("registry")); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := tpm2.NVReadPublic(rwc, tpmutil.Handle(registryIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.Attributes&tpm2.AttrOwnerWrite == 0 || pub.Attributes&(tpm2.AttrAuthWrite|tpm2.AttrPolicyWrite) != 0 {
+		t.Errorf("registry NV index has attributes %v, want only the owner to write it", pub.Attributes)
+	}
+	if data, err := store.Load(); err != nil || string(data) != "registry" {
+		t.Errorf("Load() = %q, %v, want the saved registry", data, err)
+	}
+	if err := store.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// An index anyone can write is not trusted.
+	nv, err := client.DefineNVIndex(rwc, registryIndex, client.NVIndexOpts{Size: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nv.Delete()
+	if err := nv.Write([]byte("fake")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.OpenNameRegistry(rwc, store); err == nil {
+		t.Error("OpenNameRegistry() should fail for an NV index which anyone can write")
+	}
+}
+
+func TestNameRegistryDriftReplacedKey(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	registry, err := client.OpenNameRegistry(rwc, client.NVRegistryStore(rwc, registryIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := client.NewPersistentKey(rwc, tpm2.HandleOwner, client.AKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle := key.Handle()
+	if err := registry.AddPersistentHandle("key", handle); err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Evict(); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.CheckDrift("key"); err == nil {
+		t.Error("CheckDrift should fail for an evicted key")
+	}
+
+	// A different key at the same handle is drift.
+	other, err := client.NewKey(rwc, tpm2.HandleOwner, client.AKTemplateRSA())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Persist(handle); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Evict()
+	if err := registry.CheckDrift("key"); !errors.Is(err, client.ErrDrift) {
+		t.Errorf("CheckDrift(key) = %v, want ErrDrift", err)
+	}
+}
+
+func TestFileRegistryStoreTampering(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	file, err := ioutil.TempFile("", "registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store := client.FileRegistryStore(rwc, file.Name())
+	if err := store.Save([]byte("registry data")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 1
+	if err := ioutil.WriteFile(file.Name(), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.OpenNameRegistry(rwc, store); err == nil {
+		t.Error("loading a modified registry file should fail")
+	}
+}
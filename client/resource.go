@@ -0,0 +1,398 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// handleCounts is the number of handles in a command's handle area, and in its
+// response's handle area.
+type handleCounts struct {
+	in, out int
+}
+
+// The handle counts of TPM commands, from Part 3 of the spec. ResourceManager
+// only supports these commands.
+var commandHandles = map[tpmutil.Command]handleCounts{
+	0x0000011F: {2, 0}, // TPM2_NV_UndefineSpaceSpecial
+	0x00000120: {2, 0}, // TPM2_EvictControl
+	0x00000121: {1, 0}, // TPM2_HierarchyControl
+	0x00000122: {2, 0}, // TPM2_NV_UndefineSpace
+	0x00000126: {1, 0}, // TPM2_Clear
+	0x00000127: {1, 0}, // TPM2_ClearControl
+	0x00000128: {1, 0}, // TPM2_ClockSet
+	0x00000129: {1, 0}, // TPM2_HierarchyChangeAuth
+	0x0000012A: {1, 0}, // TPM2_NV_DefineSpace
+	0x0000012C: {1, 0}, // TPM2_PCR_SetAuthPolicy
+	0x00000131: {1, 1}, // TPM2_CreatePrimary
+	0x00000134: {2, 0}, // TPM2_NV_Increment
+	0x00000135: {2, 0}, // TPM2_NV_SetBits
+	0x00000136: {2, 0}, // TPM2_NV_Extend
+	0x00000137: {2, 0}, // TPM2_NV_Write
+	0x00000138: {2, 0}, // TPM2_NV_WriteLock
+	0x00000139: {1, 0}, // TPM2_DictionaryAttackLockReset
+	0x0000013A: {1, 0}, // TPM2_DictionaryAttackParameters
+	0x0000013B: {1, 0}, // TPM2_NV_ChangeAuth
+	0x0000013C: {1, 0}, // TPM2_PCR_Event
+	0x0000013D: {1, 0}, // TPM2_PCR_Reset
+	0x0000013E: {1, 0}, // TPM2_SequenceComplete
+	0x00000143: {0, 0}, // TPM2_SelfTest
+	0x00000144: {0, 0}, // TPM2_Startup
+	0x00000145: {0, 0}, // TPM2_Shutdown
+	0x00000146: {0, 0}, // TPM2_StirRandom
+	0x00000147: {2, 0}, // TPM2_ActivateCredential
+	0x00000148: {2, 0}, // TPM2_Certify
+	0x00000149: {3, 0}, // TPM2_PolicyNV
+	0x0000014A: {2, 0}, // TPM2_CertifyCreation
+	0x0000014B: {2, 0}, // TPM2_Duplicate
+	0x0000014C: {2, 0}, // TPM2_GetTime
+	0x0000014E: {2, 0}, // TPM2_NV_Read
+	0x0000014F: {2, 0}, // TPM2_NV_ReadLock
+	0x00000150: {2, 0}, // TPM2_ObjectChangeAuth
+	0x00000151: {2, 0}, // TPM2_PolicySecret
+	0x00000153: {1, 0}, // TPM2_Create
+	0x00000154: {1, 0}, // TPM2_ECDH_ZGen
+	0x00000155: {1, 0}, // TPM2_HMAC
+	0x00000156: {1, 0}, // TPM2_Import
+	0x00000157: {1, 1}, // TPM2_Load
+	0x00000158: {1, 0}, // TPM2_Quote
+	0x00000159: {1, 0}, // TPM2_RSA_Decrypt
+	0x0000015B: {1, 1}, // TPM2_HMAC_Start
+	0x0000015C: {1, 0}, // TPM2_SequenceUpdate
+	0x0000015D: {1, 0}, // TPM2_Sign
+	0x0000015E: {1, 0}, // TPM2_Unseal
+	0x00000160: {2, 0}, // TPM2_PolicySigned
+	0x00000161: {0, 1}, // TPM2_ContextLoad
+	0x00000162: {1, 0}, // TPM2_ContextSave
+	0x00000163: {1, 0}, // TPM2_ECDH_KeyGen
+	0x00000164: {1, 0}, // TPM2_EncryptDecrypt
+	0x00000165: {0, 0}, // TPM2_FlushContext
+	0x00000167: {0, 1}, // TPM2_LoadExternal
+	0x00000168: {1, 0}, // TPM2_MakeCredential
+	0x00000169: {1, 0}, // TPM2_NV_ReadPublic
+	0x0000016A: {1, 0}, // TPM2_PolicyAuthorize
+	0x0000016B: {1, 0}, // TPM2_PolicyAuthValue
+	0x0000016C: {1, 0}, // TPM2_PolicyCommandCode
+	0x0000016D: {1, 0}, // TPM2_PolicyCounterTimer
+	0x0000016E: {1, 0}, // TPM2_PolicyCpHash
+	0x0000016F: {1, 0}, // TPM2_PolicyLocality
+	0x00000170: {1, 0}, // TPM2_PolicyNameHash
+	0x00000171: {1, 0}, // TPM2_PolicyOR
+	0x00000172: {1, 0}, // TPM2_PolicyTicket
+	0x00000173: {1, 0}, // TPM2_ReadPublic
+	0x00000174: {1, 0}, // TPM2_RSA_Encrypt
+	0x00000176: {2, 1}, // TPM2_StartAuthSession
+	0x00000177: {1, 0}, // TPM2_VerifySignature
+	0x00000178: {0, 0}, // TPM2_ECC_Parameters
+	0x0000017A: {0, 0}, // TPM2_GetCapability
+	0x0000017B: {0, 0}, // TPM2_GetRandom
+	0x0000017C: {0, 0}, // TPM2_GetTestResult
+	0x0000017D: {0, 0}, // TPM2_Hash
+	0x0000017E: {0, 0}, // TPM2_PCR_Read
+	0x0000017F: {1, 0}, // TPM2_PolicyPCR
+	0x00000180: {1, 0}, // TPM2_PolicyRestart
+	0x00000181: {0, 0}, // TPM2_ReadClock
+	0x00000182: {1, 0}, // TPM2_PCR_Extend
+	0x00000184: {3, 0}, // TPM2_NV_Certify
+	0x00000185: {2, 0}, // TPM2_EventSequenceComplete
+	0x00000186: {0, 1}, // TPM2_HashSequenceStart
+	0x00000188: {1, 0}, // TPM2_PolicyDuplicationSelect
+	0x00000189: {1, 0}, // TPM2_PolicyGetDigest
+	0x0000018A: {0, 0}, // TPM2_TestParms
+	0x0000018C: {1, 0}, // TPM2_PolicyPassword
+	0x00000191: {1, 1}, // TPM2_CreateLoaded
+	0x00000193: {1, 0}, // TPM2_EncryptDecrypt2
+}
+
+const (
+	// First handle returned for transient objects loaded through a
+	// ResourceManager. Handles are not reused.
+	firstVirtualHandle = tpmutil.Handle(0x80FF0000)
+
+	// Warnings returned when the TPM has no free object slots.
+	rcObjectMemory  = 0x900 | tpm2.RCObjectMemory
+	rcObjectHandles = 0x900 | tpm2.RCObjectHandles
+)
+
+// ResourceManager allows a process to hold more transient objects (such as
+// loaded Keys) than the TPM has object slots for. It is a TPM transport, like
+// the resource managers built into Linux (/dev/tpmrm0) or tpm2-abrmd, which
+// gives each transient object a virtual handle. When the TPM runs out of
+// object slots, the least recently used objects are saved with
+// TPM2_ContextSave and flushed, and are loaded again with TPM2_ContextLoad the
+// next time a command uses them.
+//
+// Only transient objects are virtualized: the TPM's limit on sessions still
+// applies. Commands not supported by the ResourceManager fail. A
+// ResourceManager must not be used concurrently, but can be wrapped by
+// NewSharedTPM.
+type ResourceManager struct {
+	rwc     io.ReadWriteCloser
+	objects map[tpmutil.Handle]*managedObject
+	next    tpmutil.Handle
+	clock   uint64
+	resp    []byte
+}
+
+type managedObject struct {
+	// The object's handle in the TPM, or zero if the object is saved.
+	physical tpmutil.Handle
+	saved    []byte
+	lastUsed uint64
+	pinned   bool
+}
+
+// NewResourceManager wraps a TPM, virtualizing its transient object handles.
+// The TPM should not be used directly after this call.
+func NewResourceManager(rwc io.ReadWriteCloser) *ResourceManager {
+	return &ResourceManager{
+		rwc:     rwc,
+		objects: make(map[tpmutil.Handle]*managedObject),
+		next:    firstVirtualHandle,
+	}
+}
+
+// Write runs a TPM command, translating the virtual handles it uses, and
+// loading the corresponding objects if needed. The response can then be read
+// with Read.
+func (rm *ResourceManager) Write(cmd []byte) (int, error) {
+	rm.resp = nil
+	resp, err := rm.runCommand(cmd)
+	if err != nil {
+		return 0, err
+	}
+	rm.resp = resp
+	return len(cmd), nil
+}
+
+// Read returns the response to the last command written.
+func (rm *ResourceManager) Read(p []byte) (int, error) {
+	if rm.resp == nil {
+		return 0, fmt.Errorf("no TPM response to read")
+	}
+	if len(p) < len(rm.resp) {
+		return 0, io.ErrShortBuffer
+	}
+	n := copy(p, rm.resp)
+	rm.resp = nil
+	return n, nil
+}
+
+// Close flushes all transient objects loaded through the ResourceManager, and
+// closes the underlying TPM.
+func (rm *ResourceManager) Close() error {
+	for handle, object := range rm.objects {
+		if object.physical != 0 {
+			tpm2.FlushContext(rm.rwc, object.physical)
+		}
+		delete(rm.objects, handle)
+	}
+	return rm.rwc.Close()
+}
+
+func (rm *ResourceManager) runCommand(cmd []byte) ([]byte, error) {
+	if len(cmd) < commandHeaderSize {
+		return nil, fmt.Errorf("TPM command of %d bytes is too short", len(cmd))
+	}
+	code := tpmutil.Command(binary.BigEndian.Uint32(cmd[6:10]))
+	counts, ok := commandHandles[code]
+	if !ok {
+		return nil, fmt.Errorf("command 0x%x is not supported by the resource manager", uint32(code))
+	}
+	if len(cmd) < commandHeaderSize+4*counts.in {
+		return nil, fmt.Errorf("TPM command 0x%x is too short", uint32(code))
+	}
+
+	switch code {
+	case tpm2.CmdFlushContext:
+		if len(cmd) < commandHeaderSize+4 {
+			return nil, fmt.Errorf("TPM command 0x%x is too short", uint32(code))
+		}
+		if resp, ok := rm.flush(tpmutil.Handle(binary.BigEndian.Uint32(cmd[commandHeaderSize:]))); ok {
+			return resp, nil
+		}
+	case tpm2.CmdGetCapability:
+		if resp, ok := rm.transientHandles(cmd); ok {
+			return resp, nil
+		}
+	}
+
+	// Load the objects this command uses, and translate their handles.
+	translated := append([]byte(nil), cmd...)
+	var pinned []*managedObject
+	defer func() {
+		for _, object := range pinned {
+			object.pinned = false
+		}
+	}()
+	for i := 0; i < counts.in; i++ {
+		offset := commandHeaderSize + 4*i
+		object, ok := rm.objects[tpmutil.Handle(binary.BigEndian.Uint32(cmd[offset:]))]
+		if !ok {
+			continue
+		}
+		if err := rm.load(object); err != nil {
+			return nil, err
+		}
+		object.pinned = true
+		pinned = append(pinned, object)
+		binary.BigEndian.PutUint32(translated[offset:], uint32(object.physical))
+	}
+
+	resp, err := rm.runWithEviction(translated)
+	if err != nil {
+		return nil, err
+	}
+	if counts.out == 1 && responseCode(resp) == uint32(tpmutil.RCSuccess) && len(resp) >= responseHeaderSize+4 {
+		physical := tpmutil.Handle(binary.BigEndian.Uint32(resp[responseHeaderSize:]))
+		if physical>>24 == tpmutil.Handle(tpm2.HandleTypeTransient) {
+			virtual := rm.next
+			rm.next++
+			rm.clock++
+			rm.objects[virtual] = &managedObject{physical: physical, lastUsed: rm.clock}
+			binary.BigEndian.PutUint32(resp[responseHeaderSize:], uint32(virtual))
+		}
+	}
+	return resp, nil
+}
+
+// runWithEviction sends a command to the TPM, saving and flushing unused
+// objects while the TPM reports that it is out of object slots.
+func (rm *ResourceManager) runWithEviction(cmd []byte) ([]byte, error) {
+	for {
+		if _, err := rm.rwc.Write(cmd); err != nil {
+			return nil, err
+		}
+		resp := make([]byte, maxResponseSize)
+		n, err := rm.rwc.Read(resp)
+		if err != nil {
+			return nil, err
+		}
+		resp = resp[:n]
+		if len(resp) < responseHeaderSize {
+			return nil, fmt.Errorf("TPM response of %d bytes is too short", len(resp))
+		}
+		if rc := responseCode(resp); rc != rcObjectMemory && rc != rcObjectHandles {
+			return resp, nil
+		}
+		evicted, err := rm.evict()
+		if err != nil {
+			return nil, err
+		}
+		if !evicted {
+			return resp, nil
+		}
+	}
+}
+
+// load makes sure an object is loaded in the TPM.
+func (rm *ResourceManager) load(object *managedObject) error {
+	rm.clock++
+	object.lastUsed = rm.clock
+	for object.physical == 0 {
+		physical, err := tpm2.ContextLoad(rm.rwc, object.saved)
+		if err == nil {
+			object.physical = physical
+			object.saved = nil
+			return nil
+		}
+		if warning, ok := err.(tpm2.Warning); !ok ||
+			(warning.Code != tpm2.RCObjectMemory && warning.Code != tpm2.RCObjectHandles) {
+			return fmt.Errorf("failed to load saved object: %w", err)
+		}
+		evicted, evictErr := rm.evict()
+		if evictErr != nil {
+			return evictErr
+		}
+		if !evicted {
+			return fmt.Errorf("failed to load saved object: %w", err)
+		}
+	}
+	return nil
+}
+
+// evict saves and flushes the least recently used loaded object which is not
+// needed by the current command, returning false if there is none.
+func (rm *ResourceManager) evict() (bool, error) {
+	var lru *managedObject
+	for _, object := range rm.objects {
+		if object.physical != 0 && !object.pinned && (lru == nil || object.lastUsed < lru.lastUsed) {
+			lru = object
+		}
+	}
+	if lru == nil {
+		return false, nil
+	}
+	saved, err := tpm2.ContextSave(rm.rwc, lru.physical)
+	if err != nil {
+		return false, fmt.Errorf("failed to save object 0x%x: %w", lru.physical, err)
+	}
+	if err := tpm2.FlushContext(rm.rwc, lru.physical); err != nil {
+		return false, fmt.Errorf("failed to flush object 0x%x: %w", lru.physical, err)
+	}
+	lru.physical = 0
+	lru.saved = saved
+	return true, nil
+}
+
+// flush handles TPM2_FlushContext for a virtual handle, returning false if the
+// command should be sent to the TPM unchanged.
+func (rm *ResourceManager) flush(virtual tpmutil.Handle) ([]byte, bool) {
+	object, ok := rm.objects[virtual]
+	if !ok {
+		return nil, false
+	}
+	delete(rm.objects, virtual)
+	if object.physical != 0 {
+		tpm2.FlushContext(rm.rwc, object.physical)
+	}
+	return successResponse(nil), true
+}
+
+// transientHandles handles TPM2_GetCapability for transient handles, which
+// returns the virtual handles instead of the physical ones. It returns false
+// for other capabilities.
+func (rm *ResourceManager) transientHandles(cmd []byte) ([]byte, bool) {
+	var capability, property, count uint32
+	if _, err := tpmutil.Unpack(cmd[commandHeaderSize:], &capability, &property, &count); err != nil ||
+		tpm2.Capability(capability) != tpm2.CapabilityHandles ||
+		tpmutil.Handle(property)>>24 != tpmutil.Handle(tpm2.HandleTypeTransient) {
+		return nil, false
+	}
+	var handles []tpmutil.Handle
+	for handle := range rm.objects {
+		if handle >= tpmutil.Handle(property) {
+			handles = append(handles, handle)
+		}
+	}
+	sort.Slice(handles, func(i, j int) bool { return handles[i] < handles[j] })
+	moreData := byte(0)
+	if uint32(len(handles)) > count {
+		handles = handles[:count]
+		moreData = 1
+	}
+	body := make([]byte, 9+4*len(handles))
+	body[0] = moreData
+	binary.BigEndian.PutUint32(body[1:], capability)
+	binary.BigEndian.PutUint32(body[5:], uint32(len(handles)))
+	for i, handle := range handles {
+		binary.BigEndian.PutUint32(body[9+4*i:], uint32(handle))
+	}
+	return successResponse(body), true
+}
+
+func responseCode(resp []byte) uint32 {
+	return binary.BigEndian.Uint32(resp[6:10])
+}
+
+func successResponse(body []byte) []byte {
+	resp := make([]byte, responseHeaderSize, responseHeaderSize+len(body))
+	binary.BigEndian.PutUint16(resp[0:], uint16(tpm2.TagNoSessions))
+	binary.BigEndian.PutUint32(resp[2:], uint32(responseHeaderSize+len(body)))
+	return append(resp, body...)
+}
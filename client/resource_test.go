@@ -0,0 +1,161 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+type noClose struct {
+	io.ReadWriter
+}
+
+func (noClose) Close() error {
+	return nil
+}
+
+// Creates a distinct AK for each i.
+func loadManyKeys(t *testing.T, rw io.ReadWriter, count int) []*client.Key {
+	t.Helper()
+	keys := make([]*client.Key, count)
+	for i := range keys {
+		template := client.AKTemplateECC()
+		template.ECCParameters.Point = tpm2.ECPoint{XRaw: []byte{byte(i)}}
+		key, err := client.NewKey(rw, tpm2.HandleNull, template)
+		if err != nil {
+			t.Fatalf("loading key %d: %v", i, err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func TestResourceManagerManyKeys(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	rm := client.NewResourceManager(noClose{rwc})
+	defer rm.Close()
+
+	// Far more keys than the TPM has object slots for.
+	keys := loadManyKeys(t, rm, 16)
+	handles, err := client.Handles(rm, tpm2.HandleTypeTransient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handles) != len(keys) {
+		t.Errorf("got %d transient handles, want %d", len(handles), len(keys))
+	}
+
+	// Use the keys in a different order than they were loaded.
+	for i := len(keys) - 1; i >= 0; i-- {
+		key := keys[i]
+		pub, _, _, err := tpm2.ReadPublic(rm, key.Handle())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pub.MatchesTemplate(key.PublicArea()) || !reflect.DeepEqual(pub.ECCParameters.Point, key.PublicArea().ECCParameters.Point) {
+			t.Errorf("handle 0x%x refers to the wrong key", key.Handle())
+		}
+		nonce := []byte(fmt.Sprintf("nonce %d", i))
+		quote, err := key.Quote(tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{0}}, nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := internal.VerifyQuote(quote, key.PublicKey(), nonce); err != nil {
+			t.Errorf("quote from key %d: %v", i, err)
+		}
+	}
+
+	for _, key := range keys {
+		key.Close()
+	}
+	if handles, err = client.Handles(rm, tpm2.HandleTypeTransient); err != nil {
+		t.Fatal(err)
+	}
+	if len(handles) != 0 {
+		t.Errorf("handles not flushed: %v", handles)
+	}
+}
+
+func TestResourceManagerCloseFlushes(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	rm := client.NewResourceManager(noClose{rwc})
+
+	loadManyKeys(t, rm, 5)
+	if err := rm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	handles, err := client.Handles(rwc, tpm2.HandleTypeTransient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handles) != 0 {
+		t.Errorf("handles not flushed: %v", handles)
+	}
+}
+
+func TestResourceManagerUnsupportedCommand(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	rm := client.NewResourceManager(noClose{rwc})
+	defer rm.Close()
+
+	// TPM2_FieldUpgradeStart is not supported.
+	if _, _, err := tpmutil.RunCommand(rm, tpm2.TagNoSessions, 0x0000012F); err == nil {
+		t.Error("unsupported command should fail")
+	}
+}
+
+func TestResourceManagerWithSharedTPM(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	shared := client.NewSharedTPM(client.NewResourceManager(noClose{rwc}))
+	defer shared.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn := shared.Conn(context.Background())
+			defer conn.Close()
+			template := client.AKTemplateECC()
+			template.ECCParameters.Point = tpm2.ECPoint{XRaw: []byte{byte(i)}}
+			key, err := client.NewKey(conn, tpm2.HandleNull, template)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer key.Close()
+			nonce := []byte{byte(i)}
+			for j := 0; j < 4; j++ {
+				quote, err := key.Quote(tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{0}}, nonce)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if err := internal.VerifyQuote(quote, key.PublicKey(), nonce); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -2,6 +2,10 @@ package client_test
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
 	"io"
 	"reflect"
@@ -12,6 +16,7 @@ import (
 
 	"github.com/google/go-tpm-tools/client"
 	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm-tools/policy"
 	pb "github.com/google/go-tpm-tools/proto/tpm"
 )
 
@@ -458,3 +463,158 @@ func TestSealAndUnsealOptsFail(t *testing.T) {
 		})
 	}
 }
+
+func TestSealToAuthority(t *testing.T) {
+	for _, subtest := range []struct {
+		name  string
+		curve elliptic.Curve
+		hash  crypto.Hash
+	}{
+		{"P256", elliptic.P256(), crypto.SHA256},
+		{"P384", elliptic.P384(), crypto.SHA384},
+	} {
+		t.Run(subtest.name, func(t *testing.T) {
+			testSealToAuthority(t, subtest.curve, subtest.hash)
+		})
+	}
+}
+
+func testSealToAuthority(t *testing.T, curve elliptic.Curve, hash crypto.Hash) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+
+	newAuthority := func() (*ecdsa.PrivateKey, tpm2.Public) {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		public, err := policy.AuthorizingKey(key.Public(), hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, public
+	}
+	authority, authorityPub := newAuthority()
+	ref := []byte("disk")
+	secret := []byte("test")
+	sealed, err := srk.Seal(secret, client.SealOpts{Authority: &authorityPub, AuthorityPolicyRef: ref})
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{7, test.DebugPCR}}
+	signPolicy := func(signer crypto.Signer, public tpm2.Public, ref []byte) *pb.SignedPCRPolicy {
+		t.Helper()
+		pcrs, err := client.ReadPCRs(rwc, sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signed, err := policy.SignPCRPolicy(signer, public, pcrs, ref)
+		if err != nil {
+			t.Fatalf("SignPCRPolicy() failed: %v", err)
+		}
+		return signed
+	}
+	oldPolicy := signPolicy(authority, authorityPub, ref)
+	unsealed, err := srk.Unseal(sealed, client.UnsealOpts{SignedPolicies: []*pb.SignedPCRPolicy{oldPolicy}})
+	if err != nil {
+		t.Fatalf("failed to unseal: %v", err)
+	}
+	if !bytes.Equal(unsealed, secret) {
+		t.Fatalf("unsealed (%v) not equal to secret (%v)", unsealed, secret)
+	}
+	if _, err := srk.Unseal(sealed, client.UnsealOpts{}); err == nil {
+		t.Error("unseal without a signed policy should fail")
+	}
+
+	extension := bytes.Repeat([]byte{0xAA}, sha256.Size)
+	if err = tpm2.PCRExtend(rwc, tpmutil.Handle(test.DebugPCR), tpm2.AlgSHA256, extension, ""); err != nil {
+		t.Fatalf("failed to extend pcr: %v", err)
+	}
+	if _, err := srk.Unseal(sealed, client.UnsealOpts{SignedPolicies: []*pb.SignedPCRPolicy{oldPolicy}}); err == nil {
+		t.Error("unseal with a policy for the old PCR values should fail")
+	}
+
+	// New PCR values are approved without resealing.
+	other, otherPub := newAuthority()
+	for name, signed := range map[string]*pb.SignedPCRPolicy{
+		"OtherKey":       signPolicy(other, otherPub, ref),
+		"OtherPolicyRef": signPolicy(authority, authorityPub, []byte("other")),
+	} {
+		if _, err := srk.Unseal(sealed, client.UnsealOpts{SignedPolicies: []*pb.SignedPCRPolicy{signed}}); err == nil {
+			t.Errorf("unseal with a policy signed with %s should fail", name)
+		}
+	}
+	newPolicy := signPolicy(authority, authorityPub, ref)
+	unsealed, err = srk.Unseal(sealed, client.UnsealOpts{SignedPolicies: []*pb.SignedPCRPolicy{oldPolicy, newPolicy}})
+	if err != nil {
+		t.Fatalf("failed to unseal with the new policy: %v", err)
+	}
+	if !bytes.Equal(unsealed, secret) {
+		t.Fatalf("unsealed (%v) not equal to secret (%v)", unsealed, secret)
+	}
+
+	if _, err := srk.Seal(secret, client.SealOpts{Authority: &authorityPub, Current: sel}); err == nil {
+		t.Error("sealing to an Authority and to PCRs should fail")
+	}
+}
+
+func TestSealToTargetDigest(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{7, test.DebugPCR}}
+	pcrs, err := client.ReadPCRs(rwc, sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Predict the value of the debug PCR after it is extended.
+	extension := bytes.Repeat([]byte{0xAA}, sha256.Size)
+	predicted := sha256.Sum256(append(pcrs.GetPcrs()[uint32(test.DebugPCR)], extension...))
+	pcrs.Pcrs[uint32(test.DebugPCR)] = predicted[:]
+
+	secret := []byte("test")
+	sealed, err := srk.Seal(secret, client.SealOpts{
+		TargetDigest:    policy.PCRDigest(pcrs, client.SessionHashAlg),
+		TargetSelection: sel,
+	})
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	if _, err := srk.Unseal(sealed, client.UnsealOpts{}); err == nil {
+		t.Error("unseal before the PCR is extended should fail")
+	}
+	if err = tpm2.PCRExtend(rwc, tpmutil.Handle(test.DebugPCR), tpm2.AlgSHA256, extension, ""); err != nil {
+		t.Fatalf("failed to extend pcr: %v", err)
+	}
+	unsealed, err := srk.Unseal(sealed, client.UnsealOpts{})
+	if err != nil {
+		t.Fatalf("failed to unseal: %v", err)
+	}
+	if !bytes.Equal(unsealed, secret) {
+		t.Fatalf("unsealed (%v) not equal to secret (%v)", unsealed, secret)
+	}
+
+	digest := policy.PCRDigest(pcrs, client.SessionHashAlg)
+	for name, opts := range map[string]client.SealOpts{
+		"WithTarget":     {TargetDigest: digest, TargetSelection: sel, Target: pcrs},
+		"WithAuthority":  {TargetDigest: digest, TargetSelection: sel, Authority: &tpm2.Public{}},
+		"NoSelection":    {TargetDigest: digest},
+		"WrongDigestLen": {TargetDigest: digest[:20], TargetSelection: sel},
+	} {
+		if _, err := srk.Seal(secret, opts); err == nil {
+			t.Errorf("sealing to a TargetDigest %s should fail", name)
+		}
+	}
+}
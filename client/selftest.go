@@ -0,0 +1,93 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// rcFailure is the response code of commands sent to a TPM in failure mode,
+// from Part 2 of the spec, Table 16.
+const rcFailure tpmutil.ResponseCode = 0x00000101
+
+// selfTestTimeout bounds how long SelfTest waits for tests which the TPM runs
+// in the background.
+const selfTestTimeout = 30 * time.Second
+
+// ErrTPMFailureMode is returned (wrapped) by SelfTest when a self test failed,
+// and the TPM is in failure mode. In failure mode, the TPM only accepts
+// TPM2_GetTestResult and TPM2_GetCapability until it is reset.
+var ErrTPMFailureMode = errors.New("TPM is in failure mode")
+
+// SelfTestResult is the result of a TPM's self tests.
+type SelfTestResult struct {
+	// The testResult returned by TPM2_GetTestResult.
+	Code tpmutil.ResponseCode
+	// The manufacturer-specific outData returned by TPM2_GetTestResult, which
+	// may describe why the TPM failed.
+	Data []byte
+}
+
+// Passed reports whether the self tests passed.
+func (r *SelfTestResult) Passed() bool {
+	return r.Code == tpmutil.RCSuccess
+}
+
+// SelfTest runs the TPM's self tests with TPM2_SelfTest: every test if
+// fullTest is true, and otherwise only those not yet run since the TPM was
+// reset. It then waits for any tests run in the background, and returns their
+// result from TPM2_GetTestResult. If the tests failed, the result is returned
+// along with an error wrapping ErrTPMFailureMode.
+func SelfTest(rw io.ReadWriter, fullTest bool) (*SelfTestResult, error) {
+	var full byte
+	if fullTest {
+		full = 1
+	}
+	_, code, err := tpmutil.RunCommand(rw, tpm2.TagNoSessions, internal.CmdSelfTest, full)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run self test: %w", err)
+	}
+	// A TPM already in failure mode still reports its test result.
+	if code != tpmutil.RCSuccess && code != tpmutil.ResponseCode(rcTesting) && code != rcFailure {
+		return nil, fmt.Errorf("self test failed with response code 0x%x", uint32(code))
+	}
+
+	deadline := time.Now().Add(selfTestTimeout)
+	for {
+		result, err := getTestResult(rw)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case result.Passed():
+			return result, nil
+		case result.Code != tpmutil.ResponseCode(rcTesting):
+			return result, fmt.Errorf("%w: self test result 0x%x", ErrTPMFailureMode, uint32(result.Code))
+		case time.Now().After(deadline):
+			return result, fmt.Errorf("self test still running after %v", selfTestTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// getTestResult runs TPM2_GetTestResult.
+func getTestResult(rw io.ReadWriter) (*SelfTestResult, error) {
+	resp, code, err := tpmutil.RunCommand(rw, tpm2.TagNoSessions, internal.CmdGetTestResult)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get self test result: %w", err)
+	}
+	if code != tpmutil.RCSuccess {
+		return nil, fmt.Errorf("getting self test result failed with response code 0x%x", uint32(code))
+	}
+	var data tpmutil.U16Bytes
+	var testResult uint32
+	if _, err := tpmutil.Unpack(resp, &data, &testResult); err != nil {
+		return nil, fmt.Errorf("decoding self test result: %w", err)
+	}
+	return &SelfTestResult{Code: tpmutil.ResponseCode(testResult), Data: data}, nil
+}
@@ -0,0 +1,23 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+func TestSelfTest(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	for _, fullTest := range []bool{false, true} {
+		result, err := client.SelfTest(rwc, fullTest)
+		if err != nil {
+			t.Fatalf("SelfTest(fullTest=%v) failed: %v", fullTest, err)
+		}
+		if !result.Passed() {
+			t.Errorf("SelfTest(fullTest=%v) result = 0x%x, want success", fullTest, uint32(result.Code))
+		}
+	}
+}
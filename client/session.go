@@ -3,6 +3,7 @@ package client
 import (
 	"io"
 
+	pb "github.com/google/go-tpm-tools/proto/tpm"
 	"github.com/google/go-tpm/tpm2"
 	"github.com/google/go-tpm/tpmutil"
 )
@@ -13,13 +14,20 @@ type session interface {
 }
 
 func startAuthSession(rw io.ReadWriter) (session tpmutil.Handle, err error) {
+	session, _, err = startPolicySession(rw)
+	return
+}
+
+// startPolicySession starts a policy session, also returning its nonceTPM.
+func startPolicySession(rw io.ReadWriter) (session tpmutil.Handle, nonceTPM []byte, err error) {
 	// This session assumes the bus is trusted, so we:
 	// - use nil for tpmKey, encrypted salt, and symmetric
-	// - use and all-zeros caller nonce, and ignore the returned nonce
+	// - use and all-zeros caller nonce (the returned nonce is only needed to
+	//   bind authorizations to the session, as with PolicySigned)
 	// As we are creating a plain TPM session, we:
 	// - setup a policy session
 	// - don't bind the session to any particular key
-	session, _, err = tpm2.StartAuthSession(
+	return tpm2.StartAuthSession(
 		rw,
 		/*tpmKey=*/ tpm2.HandleNull,
 		/*bindKey=*/ tpm2.HandleNull,
@@ -28,7 +36,6 @@ func startAuthSession(rw io.ReadWriter) (session tpmutil.Handle, err error) {
 		/*sessionType=*/ tpm2.SessionPolicy,
 		/*symmetric=*/ tpm2.AlgNull,
 		/*authHash=*/ SessionHashAlgTpm)
-	return
 }
 
 type pcrSession struct {
@@ -56,6 +63,39 @@ func (p pcrSession) Close() error {
 	return tpm2.FlushContext(p.rw, p.session)
 }
 
+// sealedSession satisfies the policy of sealed data: the PCRs (if any) and
+// then the NV counter (if any), in the order used by Seal.
+type sealedSession struct {
+	rw      io.ReadWriter
+	session tpmutil.Handle
+	sel     tpm2.PCRSelection
+	counter *pb.NVCounter
+}
+
+func newSealedSession(rw io.ReadWriter, sel tpm2.PCRSelection, counter *pb.NVCounter) (session, error) {
+	if counter == nil {
+		return newPCRSession(rw, sel)
+	}
+	session, err := startAuthSession(rw)
+	return sealedSession{rw, session, sel, counter}, err
+}
+
+func (s sealedSession) Auth() (auth tpm2.AuthCommand, err error) {
+	if len(s.sel.PCRs) > 0 {
+		if err = tpm2.PolicyPCR(s.rw, s.session, nil, s.sel); err != nil {
+			return
+		}
+	}
+	if err = policyCounter(s.rw, s.session, s.counter); err != nil {
+		return
+	}
+	return tpm2.AuthCommand{Session: s.session, Attributes: tpm2.AttrContinueSession}, nil
+}
+
+func (s sealedSession) Close() error {
+	return tpm2.FlushContext(s.rw, s.session)
+}
+
 type ekSession struct {
 	rw      io.ReadWriter
 	session tpmutil.Handle
@@ -87,3 +127,16 @@ func (n nullSession) Auth() (auth tpm2.AuthCommand, err error) {
 func (n nullSession) Close() error {
 	return nil
 }
+
+// passwordSession authorizes with a password, sent in the clear.
+type passwordSession struct {
+	password []byte
+}
+
+func (p passwordSession) Auth() (auth tpm2.AuthCommand, err error) {
+	return tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession, Auth: p.password}, nil
+}
+
+func (p passwordSession) Close() error {
+	return nil
+}
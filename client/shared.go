@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+const (
+	// Size of the TPM command and response headers, from Part 1 of the spec.
+	commandHeaderSize  = 10
+	responseHeaderSize = 10
+	maxResponseSize    = 4096
+
+	// Warnings (Part 2 of the spec, Table 16) after which a command can be
+	// sent again unchanged.
+	rcYielded = 0x900 | tpm2.RCYielded
+	rcTesting = 0x900 | tpm2.RCTesting
+	rcRetry   = 0x900 | tpm2.RCRetry
+
+	maxRetries     = 8
+	initialBackoff = 10 * time.Millisecond
+)
+
+// ErrConnClosed is returned when using a SharedConn after it was closed.
+var ErrConnClosed = errors.New("shared TPM connection is closed")
+
+// SharedTPM allows a single TPM device handle to be used by many goroutines,
+// as is needed by services which serve multiple tenants from one TPM. Each
+// goroutine uses its own SharedConn, which can be passed to any function
+// taking an io.ReadWriter. Commands from different SharedConns are serialized,
+// and commands the TPM could not start (TPM_RC_RETRY, TPM_RC_YIELDED or
+// TPM_RC_TESTING) are retried with an exponential backoff.
+type SharedTPM struct {
+	rwc io.ReadWriteCloser
+	// A buffered channel of size one, used as a mutex which can be waited for
+	// with a context.
+	lock chan struct{}
+	// The SharedConn which created each transient handle.
+	owners map[tpmutil.Handle]*SharedConn
+}
+
+// NewSharedTPM wraps a TPM for concurrent use. The TPM should not be used
+// directly after this call.
+func NewSharedTPM(rwc io.ReadWriteCloser) *SharedTPM {
+	return &SharedTPM{
+		rwc:    rwc,
+		lock:   make(chan struct{}, 1),
+		owners: make(map[tpmutil.Handle]*SharedConn),
+	}
+}
+
+// Close closes the underlying TPM. Any SharedConns should be closed first, so
+// their transient handles are flushed.
+func (s *SharedTPM) Close() error {
+	s.lock <- struct{}{}
+	defer func() { <-s.lock }()
+	return s.rwc.Close()
+}
+
+// Conn returns a new connection to the TPM for use by a single goroutine.
+// When ctx is cancelled or the SharedConn is closed, all the transient objects
+// and sessions created through the SharedConn (and not yet flushed) are
+// flushed. This prevents a cancelled request from exhausting the TPM's
+// limited object and session slots.
+func (s *SharedTPM) Conn(ctx context.Context) *SharedConn {
+	c := &SharedConn{tpm: s, ctx: ctx, done: make(chan struct{})}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				c.Close()
+			case <-c.done:
+			}
+		}()
+	}
+	return c
+}
+
+// SharedConn is a connection to a SharedTPM. It implements io.ReadWriteCloser
+// by running each command written to it, and then returning that command's
+// response from the next Read. A SharedConn must not be used concurrently.
+type SharedConn struct {
+	tpm  *SharedTPM
+	ctx  context.Context
+	resp []byte
+
+	closeOnce sync.Once
+	done      chan struct{}
+	closed    bool // protected by tpm.lock
+}
+
+// Write runs a TPM command, waiting for commands from other SharedConns to
+// finish first. The response can then be read with Read.
+func (c *SharedConn) Write(cmd []byte) (int, error) {
+	if len(cmd) < commandHeaderSize {
+		return 0, fmt.Errorf("TPM command of %d bytes is too short", len(cmd))
+	}
+	c.resp = nil
+	backoff := initialBackoff
+	for retries := 0; ; retries++ {
+		resp, err := c.run(cmd)
+		if err != nil {
+			return 0, err
+		}
+		code := binary.BigEndian.Uint32(resp[6:10])
+		if (code != rcRetry && code != rcYielded && code != rcTesting) || retries == maxRetries {
+			c.resp = resp
+			return len(cmd), nil
+		}
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+			return 0, c.ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// Read returns the response to the last command written.
+func (c *SharedConn) Read(p []byte) (int, error) {
+	if c.resp == nil {
+		return 0, errors.New("no TPM response to read")
+	}
+	if len(p) < len(c.resp) {
+		return 0, io.ErrShortBuffer
+	}
+	n := copy(p, c.resp)
+	c.resp = nil
+	return n, nil
+}
+
+// Close flushes the transient objects and sessions created through this
+// SharedConn, but does not close the SharedTPM.
+func (c *SharedConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.tpm.lock <- struct{}{}
+		defer func() { <-c.tpm.lock }()
+		c.closed = true
+		for handle, owner := range c.tpm.owners {
+			if owner != c {
+				continue
+			}
+			delete(c.tpm.owners, handle)
+			// The TPM flushes sessions which were not continued, so some of
+			// these handles may already be gone.
+			tpm2.FlushContext(c.tpm.rwc, handle)
+		}
+	})
+	return nil
+}
+
+// run sends a single command to the TPM while holding the lock, and tracks
+// the handles it creates or flushes.
+func (c *SharedConn) run(cmd []byte) ([]byte, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case c.tpm.lock <- struct{}{}:
+	case <-c.ctx.Done():
+		return nil, c.ctx.Err()
+	}
+	defer func() { <-c.tpm.lock }()
+	if c.closed {
+		return nil, ErrConnClosed
+	}
+
+	if _, err := c.tpm.rwc.Write(cmd); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, maxResponseSize)
+	n, err := c.tpm.rwc.Read(resp)
+	if err != nil {
+		return nil, err
+	}
+	resp = resp[:n]
+	if len(resp) < responseHeaderSize {
+		return nil, fmt.Errorf("TPM response of %d bytes is too short", len(resp))
+	}
+	if binary.BigEndian.Uint32(resp[6:10]) != uint32(tpmutil.RCSuccess) {
+		return resp, nil
+	}
+
+	command := tpmutil.Command(binary.BigEndian.Uint32(cmd[6:10]))
+	switch {
+	case commandHandles[command].out == 1 && len(resp) >= responseHeaderSize+4:
+		// A reused handle number means its previous object was flushed.
+		handle := tpmutil.Handle(binary.BigEndian.Uint32(resp[responseHeaderSize:]))
+		c.tpm.owners[handle] = c
+	case command == tpm2.CmdFlushContext && len(cmd) >= commandHeaderSize+4:
+		delete(c.tpm.owners, tpmutil.Handle(binary.BigEndian.Uint32(cmd[commandHeaderSize:])))
+	}
+	return resp, nil
+}
@@ -0,0 +1,178 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+// retryTPM responds to the first retries commands with TPM_RC_RETRY.
+type retryTPM struct {
+	io.ReadWriteCloser
+	retries  int
+	retrying bool
+	commands int
+}
+
+func (r *retryTPM) Write(cmd []byte) (int, error) {
+	r.commands++
+	if r.retries > 0 {
+		r.retries--
+		r.retrying = true
+		return len(cmd), nil
+	}
+	return r.ReadWriteCloser.Write(cmd)
+}
+
+func (r *retryTPM) Read(p []byte) (int, error) {
+	if r.retrying {
+		r.retrying = false
+		resp := []byte{0x80, 0x01, 0, 0, 0, 10, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(resp[6:], 0x922)
+		return copy(p, resp), nil
+	}
+	return r.ReadWriteCloser.Read(p)
+}
+
+func TestSharedTPMConcurrentUse(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	shared := client.NewSharedTPM(rwc)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn := shared.Conn(context.Background())
+			defer conn.Close()
+			// Each goroutine hashes different data, so interleaved commands
+			// or responses would produce the wrong digests.
+			data := bytes.Repeat([]byte{byte(i)}, 64)
+			want := sha256.Sum256(data)
+			for j := 0; j < 16; j++ {
+				got, _, err := tpm2.Hash(conn, tpm2.AlgSHA256, data, tpm2.HandleNull)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(got, want[:]) {
+					errs <- fmt.Errorf("goroutine %d got the wrong digest", i)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestSharedTPMRetries(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	retrying := &retryTPM{ReadWriteCloser: rwc, retries: 3}
+	conn := client.NewSharedTPM(retrying).Conn(context.Background())
+	defer conn.Close()
+
+	if _, err := tpm2.GetRandom(conn, 16); err != nil {
+		t.Fatal(err)
+	}
+	if retrying.commands != 4 {
+		t.Errorf("TPM received %d commands, want 4", retrying.commands)
+	}
+}
+
+func TestSharedTPMCancelFlushesHandles(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	shared := client.NewSharedTPM(rwc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := shared.Conn(ctx)
+	// Leak a key and a session, as a request abandoned halfway would.
+	if _, err := client.NewKey(conn, tpm2.HandleNull, client.SRKTemplateECC()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tpm2.StartAuthSession(conn, tpm2.HandleNull, tpm2.HandleNull,
+		make([]byte, 16), nil, tpm2.SessionPolicy, tpm2.AlgNull, tpm2.AlgSHA256); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	if _, err := tpm2.GetRandom(conn, 16); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetRandom() after cancellation = %v, want context.Canceled", err)
+	}
+	// The handles are flushed in the background.
+	other := shared.Conn(context.Background())
+	defer other.Close()
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		objects, err := client.Handles(other, tpm2.HandleTypeTransient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions, err := client.Handles(other, tpm2.HandleTypeLoadedSession)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(objects) == 0 && len(sessions) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("handles not flushed: %v %v", objects, sessions)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSharedConnCloseKeepsReusedHandles(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	shared := client.NewSharedTPM(rwc)
+
+	first := shared.Conn(context.Background())
+	key, err := client.NewKey(first, tpm2.HandleNull, client.SRKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flush the key without the SharedConn noticing, as happens to sessions
+	// which are not continued.
+	if err := tpm2.FlushContext(rwc, key.Handle()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The flushed handle is reused for a key created by another SharedConn,
+	// which must survive the first SharedConn being closed.
+	second := shared.Conn(context.Background())
+	defer second.Close()
+	other, err := client.NewKey(second, tpm2.HandleNull, client.SRKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if other.Handle() != key.Handle() {
+		t.Skipf("TPM did not reuse handle 0x%x", key.Handle())
+	}
+	first.Close()
+	if _, _, _, err := tpm2.ReadPublic(second, other.Handle()); err != nil {
+		t.Errorf("key was flushed when another SharedConn closed: %v", err)
+	}
+	if _, err := tpm2.GetRandom(first, 16); !errors.Is(err, client.ErrConnClosed) {
+		t.Errorf("GetRandom() after Close = %v, want ErrConnClosed", err)
+	}
+}
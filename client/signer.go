@@ -62,7 +62,7 @@ func (signer *tpmSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts
 		return nil, err
 	}
 
-	sig, err := tpm2.SignWithSession(signer.Key.rw, auth.Session, signer.Key.handle, "", digest, nil, nil)
+	sig, err := tpm2.SignWithSession(signer.Key.rw, auth.Session, signer.Key.handle, string(auth.Auth), digest, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +124,7 @@ func (k *Key) SignData(data []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	sig, err := tpm2.SignWithSession(k.rw, auth.Session, k.handle, "", digest, ticket, nil)
+	sig, err := tpm2.SignWithSession(k.rw, auth.Session, k.handle, string(auth.Auth), digest, ticket, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/google/go-tpm/tpm2"
+)
+
+// Commands of swtpm's control channel, from swtpm's tpm_ioctl.h.
+const (
+	swtpmCmdInit     uint32 = 0x02
+	swtpmCmdShutdown uint32 = 0x03
+)
+
+// swtpmInitDeleteVolatile (PTM_INIT_FLAG_DELETE_VOLATILE) makes CMD_INIT
+// discard any saved volatile state, as happens on a real reboot.
+const swtpmInitDeleteVolatile uint32 = 1
+
+// Swtpm is a connection to swtpm's data channel, which carries TPM commands,
+// and its control channel, which carries the platform's commands to power the
+// TPM on and off. Unlike a TPM opened with OpenTPM, an Swtpm does not need to
+// have already been started up, and can be reset as if its host had rebooted.
+type Swtpm struct {
+	swtpmConn
+	ctrl net.Conn
+}
+
+// OpenSwtpm connects to swtpm, given the options of a swtpm TCTI configuration
+// (see OpenTPM), for example "host=localhost,port=2321". The control channel
+// is at the next port (as with the tpm2-tss swtpm TCTI), or at the Unix
+// socket given by the ctrl option:
+//
+//	host=localhost,port=2321           control channel on localhost:2322
+//	path=/run/swtpm/sock,ctrl=/run/swtpm/ctrl
+//
+// The TPM is initialized and started up with TPM2_Startup(CLEAR).
+func OpenSwtpm(conf string) (*Swtpm, error) {
+	opts, err := parseTCTIConf(conf, "ctrl")
+	if err != nil {
+		return nil, fmt.Errorf("invalid swtpm configuration %q: %w", conf, err)
+	}
+	network, ctrlAddress := "unix", opts["ctrl"]
+	if ctrlAddress == "" {
+		if opts["path"] != "" {
+			return nil, errors.New("swtpm configuration with a path must also have a ctrl path")
+		}
+		port, _ := strconv.Atoi(opts["port"])
+		network, ctrlAddress = "tcp", net.JoinHostPort(opts["host"], strconv.Itoa(port+1))
+	}
+
+	ctrl, err := net.DialTimeout(network, ctrlAddress, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to swtpm control channel: %w", err)
+	}
+	conn, err := dialSimulator(opts)
+	if err != nil {
+		ctrl.Close()
+		return nil, fmt.Errorf("connecting to swtpm: %w", err)
+	}
+	s := &Swtpm{swtpmConn: swtpmConn{conn: conn}, ctrl: ctrl}
+	if err := s.Init(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Init sends CMD_INIT, powering on (or power cycling) the TPM, and then starts
+// it up with TPM2_Startup(CLEAR).
+func (s *Swtpm) Init() error {
+	if err := s.control(swtpmCmdInit, swtpmInitDeleteVolatile); err != nil {
+		return err
+	}
+	// swtpm started with --flags startup-clear has already started up.
+	err := tpm2.Startup(s, tpm2.StartupClear)
+	if rcErr, ok := err.(tpm2.Error); ok && rcErr.Code == tpm2.RCInitialize {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("startup: %w", err)
+	}
+	return nil
+}
+
+// Reset resets the TPM as if its host had rebooted: the TPM is shut down with
+// TPM2_Shutdown(CLEAR), then reinitialized with Init.
+func (s *Swtpm) Reset() error {
+	if err := tpm2.Shutdown(s, tpm2.StartupClear); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return s.Init()
+}
+
+// Shutdown shuts down the TPM with TPM2_Shutdown(CLEAR), then sends
+// CMD_SHUTDOWN, which makes swtpm save its state and exit. The connections
+// are closed even if shutting down fails.
+func (s *Swtpm) Shutdown() error {
+	defer s.Close()
+	if err := tpm2.Shutdown(s, tpm2.StartupClear); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return s.control(swtpmCmdShutdown)
+}
+
+// Close closes the connections to swtpm, leaving the TPM running.
+func (s *Swtpm) Close() error {
+	ctrlErr := s.ctrl.Close()
+	if err := s.conn.Close(); err != nil {
+		return err
+	}
+	return ctrlErr
+}
+
+// control sends a command, with its big-endian parameters, on the control
+// channel, and checks the TPM result it returns.
+func (s *Swtpm) control(cmd uint32, params ...uint32) error {
+	req := make([]byte, 4*(1+len(params)))
+	binary.BigEndian.PutUint32(req, cmd)
+	for i, param := range params {
+		binary.BigEndian.PutUint32(req[4*(i+1):], param)
+	}
+	if _, err := s.ctrl.Write(req); err != nil {
+		return fmt.Errorf("sending swtpm control command 0x%x: %w", cmd, err)
+	}
+	var result uint32
+	if err := binary.Read(s.ctrl, binary.BigEndian, &result); err != nil {
+		return fmt.Errorf("reading swtpm control command 0x%x result: %w", cmd, err)
+	}
+	if result != 0 {
+		return fmt.Errorf("swtpm control command 0x%x failed with TPM result 0x%x", cmd, result)
+	}
+	return nil
+}
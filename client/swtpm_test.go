@@ -0,0 +1,127 @@
+package client_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm-tools/simulator"
+)
+
+// fakeSwtpm records the commands received on its control channel.
+type fakeSwtpm struct {
+	inits     chan uint32
+	shutdowns chan struct{}
+}
+
+// serveSwtpm serves the simulator on Unix sockets using swtpm's data and
+// control channel protocols, returning the swtpm configuration to connect.
+func serveSwtpm(t *testing.T, sim *simulator.Simulator) (string, *fakeSwtpm) {
+	dir := t.TempDir()
+	dataPath, ctrlPath := filepath.Join(dir, "data"), filepath.Join(dir, "ctrl")
+	data, err := net.Listen("unix", dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveSimulatorOn(t, data, sim, false)
+	ctrl, err := net.Listen("unix", ctrlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ctrl.Close() })
+
+	fake := &fakeSwtpm{inits: make(chan uint32, 10), shutdowns: make(chan struct{}, 10)}
+	go func() {
+		conn, err := ctrl.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var cmd uint32
+			if err := binary.Read(conn, binary.BigEndian, &cmd); err != nil {
+				return
+			}
+			result := uint32(0)
+			switch cmd {
+			case 0x02: // CMD_INIT
+				var flags uint32
+				if err := binary.Read(conn, binary.BigEndian, &flags); err != nil {
+					return
+				}
+				if err := sim.Reset(); err != nil {
+					result = 0x101 // TPM_FAIL
+				}
+				fake.inits <- flags
+			case 0x03: // CMD_SHUTDOWN
+				fake.shutdowns <- struct{}{}
+			default:
+				result = 0x0a // TPM_BAD_ORDINAL
+			}
+			if err := binary.Write(conn, binary.BigEndian, result); err != nil {
+				return
+			}
+		}
+	}()
+	return "path=" + dataPath + ",ctrl=" + ctrlPath, fake
+}
+
+func TestSwtpm(t *testing.T) {
+	sim, err := simulator.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+	conf, fake := serveSwtpm(t, sim)
+
+	swtpm, err := client.OpenSwtpm(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags := <-fake.inits; flags != 1 {
+		t.Errorf("CMD_INIT sent with flags %d, want PTM_INIT_FLAG_DELETE_VOLATILE", flags)
+	}
+
+	if err := tpm2.PCRExtend(swtpm, tpmutil.Handle(test.DebugPCR), tpm2.AlgSHA256, make([]byte, 32), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := swtpm.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	<-fake.inits
+	pcr, err := tpm2.ReadPCR(swtpm, test.DebugPCR, tpm2.AlgSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pcr, make([]byte, len(pcr))) {
+		t.Errorf("PCR%d was not reset: %x", test.DebugPCR, pcr)
+	}
+
+	if err := swtpm.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-fake.shutdowns:
+	default:
+		t.Error("CMD_SHUTDOWN was not sent")
+	}
+}
+
+func TestOpenSwtpmInvalidConfig(t *testing.T) {
+	for _, conf := range []string{
+		"path=/run/swtpm/sock",
+		"host=localhost,locality=3",
+	} {
+		if swtpm, err := client.OpenSwtpm(conf); err == nil {
+			swtpm.Close()
+			t.Errorf("OpenSwtpm(%q) should fail", conf)
+		}
+	}
+}
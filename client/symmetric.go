@@ -0,0 +1,156 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// maxBufferSize is the size of a TPM2B_MAX_BUFFER on most TPMs (the reference
+// implementation's MAX_DIGEST_BUFFER), which limits the data sent to the TPM in
+// a single HMAC or EncryptDecrypt command.
+const maxBufferSize = 1024
+
+// ErrHMACMismatch is returned by Key.VerifyHMAC when the HMAC does not match.
+var ErrHMACMismatch = errors.New("HMAC does not match")
+
+// HMAC computes the HMAC of data with a key created from HMACTemplate (or any
+// keyed hash key with the HMAC scheme), without the key ever leaving the TPM.
+// Data longer than a single TPM command can hold is sent in an HMAC sequence.
+func (k *Key) HMAC(data []byte) ([]byte, error) {
+	if k.pubArea.Type != tpm2.AlgKeyedHash || k.pubArea.KeyedHashParameters.Alg != tpm2.AlgHMAC {
+		return nil, errors.New("key is not an HMAC key")
+	}
+	auth, err := k.session.Auth()
+	if err != nil {
+		return nil, err
+	}
+	// The TPM_ALG_NULL hash algorithm uses the key's.
+	if len(data) <= maxBufferSize {
+		resp, err := internal.RunCommand(k.rw, internal.CmdHMAC, []tpmutil.Handle{k.handle},
+			[]tpm2.AuthCommand{auth}, tpmutil.U16Bytes(data), tpm2.AlgNull)
+		if err != nil {
+			return nil, fmt.Errorf("HMAC failed: %w", err)
+		}
+		var mac tpmutil.U16Bytes
+		if _, err := tpmutil.Unpack(resp, &mac); err != nil {
+			return nil, fmt.Errorf("decoding HMAC: %w", err)
+		}
+		return mac, nil
+	}
+
+	sequence, _, err := internal.RunHandleCommand(k.rw, internal.CmdHMACStart, []tpmutil.Handle{k.handle},
+		[]tpm2.AuthCommand{auth}, tpmutil.U16Bytes(nil), tpm2.AlgNull)
+	if err != nil {
+		return nil, fmt.Errorf("HMAC_Start failed: %w", err)
+	}
+	for ; len(data) > maxBufferSize; data = data[maxBufferSize:] {
+		if err := tpm2.SequenceUpdate(k.rw, "", sequence, data[:maxBufferSize]); err != nil {
+			tpm2.FlushContext(k.rw, sequence)
+			return nil, fmt.Errorf("SequenceUpdate failed: %w", err)
+		}
+	}
+	// Completing the sequence flushes it.
+	mac, _, err := tpm2.SequenceComplete(k.rw, "", sequence, tpm2.HandleNull, data)
+	if err != nil {
+		tpm2.FlushContext(k.rw, sequence)
+		return nil, fmt.Errorf("SequenceComplete failed: %w", err)
+	}
+	return mac, nil
+}
+
+// VerifyHMAC checks that mac is the HMAC of data with the key (see Key.HMAC),
+// returning ErrHMACMismatch if it is not.
+func (k *Key) VerifyHMAC(data, mac []byte) error {
+	want, err := k.HMAC(data)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(mac, want) != 1 {
+		return ErrHMACMismatch
+	}
+	return nil
+}
+
+// Encrypt encrypts the plaintext with a key created from AESTemplate (or any
+// AES key with the sign attribute), without the key ever leaving the TPM. A
+// random IV is generated, and prepended to the returned ciphertext.
+//
+// The ciphertext is not authenticated, so it should be stored where it cannot
+// be modified, or authenticated separately (for example, with Key.HMAC). The
+// TPM must implement TPM2_EncryptDecrypt2, which some TPMs omit.
+func (k *Key) Encrypt(plaintext []byte) ([]byte, error) {
+	if err := k.checkSymmetric(); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	ciphertext, err := k.encryptDecrypt(iv, plaintext, false)
+	if err != nil {
+		return nil, err
+	}
+	return append(iv, ciphertext...), nil
+}
+
+// Decrypt decrypts a ciphertext returned by Key.Encrypt with the same key,
+// which must have the decrypt attribute.
+func (k *Key) Decrypt(ciphertext []byte) ([]byte, error) {
+	if err := k.checkSymmetric(); err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aes.BlockSize {
+		return nil, errors.New("ciphertext is too short to contain an IV")
+	}
+	return k.encryptDecrypt(ciphertext[:aes.BlockSize], ciphertext[aes.BlockSize:], true)
+}
+
+func (k *Key) checkSymmetric() error {
+	if k.pubArea.Type != tpm2.AlgSymCipher || k.pubArea.SymCipherParameters.Symmetric.Alg != tpm2.AlgAES {
+		return errors.New("key is not an AES key")
+	}
+	switch mode := k.pubArea.SymCipherParameters.Symmetric.Mode; mode {
+	case tpm2.AlgCFB, tpm2.AlgCTR, tpm2.AlgOFB:
+		return nil
+	default:
+		return fmt.Errorf("unsupported AES mode %v, want a stream mode (CFB, CTR or OFB)", mode)
+	}
+}
+
+// encryptDecrypt runs TPM2_EncryptDecrypt2 in the key's mode, chaining the IV
+// through data longer than a single command can hold.
+func (k *Key) encryptDecrypt(iv, data []byte, decrypt bool) ([]byte, error) {
+	var out []byte
+	for len(data) > 0 {
+		block := data
+		if len(block) > maxBufferSize {
+			block = block[:maxBufferSize]
+		}
+		data = data[len(block):]
+
+		auth, err := k.session.Auth()
+		if err != nil {
+			return nil, err
+		}
+		// The TPM_ALG_NULL mode uses the key's.
+		resp, err := internal.RunCommand(k.rw, tpm2.CmdEncryptDecrypt2, []tpmutil.Handle{k.handle},
+			[]tpm2.AuthCommand{auth}, tpmutil.U16Bytes(block), decrypt, tpm2.AlgNull, tpmutil.U16Bytes(iv))
+		if err != nil {
+			return nil, fmt.Errorf("EncryptDecrypt2 failed: %w", err)
+		}
+		var outData, ivOut tpmutil.U16Bytes
+		if _, err := tpmutil.Unpack(resp, &outData, &ivOut); err != nil {
+			return nil, fmt.Errorf("decoding EncryptDecrypt2 response: %w", err)
+		}
+		out = append(out, outData...)
+		iv = ivOut
+	}
+	return out, nil
+}
@@ -0,0 +1,199 @@
+package client_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+// loadKeyWithSensitive loads a key from the template with the given key
+// material under the SRK, so that its results can be checked in software. The
+// key is made persistent at handle (and must be evicted by the caller), as
+// client can only wrap keys it created or persistent keys.
+func loadKeyWithSensitive(t *testing.T, rw io.ReadWriter, template tpm2.Public, sensitive []byte, handle tpmutil.Handle) *client.Key {
+	t.Helper()
+	srk, err := client.StorageRootKeyECC(rw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	template.Attributes &^= tpm2.FlagSensitiveDataOrigin
+	priv, pub, _, _, _, err := tpm2.CreateKeyWithSensitive(rw, srk.Handle(), tpm2.PCRSelection{}, "", "", template, sensitive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, _, err := tpm2.Load(rw, srk.Handle(), "", pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpm2.FlushContext(rw, loaded)
+	if err := tpm2.EvictControl(rw, "", tpm2.HandleOwner, loaded, handle); err != nil {
+		t.Fatal(err)
+	}
+	key, err := client.LoadPersistentKey(rw, handle)
+	if err != nil {
+		tpm2.EvictControl(rw, "", tpm2.HandleOwner, handle, handle)
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestHMAC(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	secret := []byte("an HMAC key known to the test")
+	const handle = tpmutil.Handle(0x81008F02)
+	key := loadKeyWithSensitive(t, rwc, client.HMACTemplate(tpm2.AlgSHA256), secret, handle)
+	defer tpm2.EvictControl(rwc, "", tpm2.HandleOwner, handle, handle)
+	if key.PublicKey() != nil {
+		t.Errorf("PublicKey() of an HMAC key = %v, want nil", key.PublicKey())
+	}
+
+	// Longer data is sent in an HMAC sequence.
+	for _, size := range []int{0, 100, 1024, 1025, 3000} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			data := bytes.Repeat([]byte{0x5a}, size)
+			got, err := key.HMAC(data)
+			if err != nil {
+				t.Fatalf("HMAC() failed: %v", err)
+			}
+			mac := hmac.New(sha256.New, secret)
+			mac.Write(data)
+			if want := mac.Sum(nil); !bytes.Equal(got, want) {
+				t.Errorf("HMAC() = %x, want %x", got, want)
+			}
+			if err := key.VerifyHMAC(data, got); err != nil {
+				t.Errorf("VerifyHMAC() failed: %v", err)
+			}
+			if err := key.VerifyHMAC(append(data, 0), got); !errors.Is(err, client.ErrHMACMismatch) {
+				t.Errorf("VerifyHMAC() of other data = %v, want ErrHMACMismatch", err)
+			}
+		})
+	}
+
+	if _, err := key.Encrypt([]byte("data")); err == nil {
+		t.Error("Encrypt() with an HMAC key should fail")
+	}
+}
+
+func TestHMACKeyGeneratedByTPM(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+
+	key, err := client.NewKey(rwc, srk.Handle(), client.HMACTemplate(tpm2.AlgSHA384))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+	mac, err := key.HMAC([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mac) != 48 {
+		t.Errorf("HMAC() with SHA-384 returned %d bytes, want 48", len(mac))
+	}
+	if err := key.VerifyHMAC([]byte("data"), mac); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	secret := bytes.Repeat([]byte{0x42}, 16)
+	const handle = tpmutil.Handle(0x81008F03)
+	key := loadKeyWithSensitive(t, rwc, client.AESTemplate(128), secret, handle)
+	defer tpm2.EvictControl(rwc, "", tpm2.HandleOwner, handle, handle)
+
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 15, 1024, 1025, 3000} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			plaintext := bytes.Repeat([]byte{0xa5}, size)
+			ciphertext, err := key.Encrypt(plaintext)
+			if err != nil {
+				t.Fatalf("Encrypt() failed: %v", err)
+			}
+			if len(ciphertext) != aes.BlockSize+size {
+				t.Fatalf("Encrypt() returned %d bytes, want %d", len(ciphertext), aes.BlockSize+size)
+			}
+			want := make([]byte, size)
+			cipher.NewCFBEncrypter(block, ciphertext[:aes.BlockSize]).XORKeyStream(want, plaintext)
+			if !bytes.Equal(ciphertext[aes.BlockSize:], want) {
+				t.Error("Encrypt() does not match AES-CFB in software")
+			}
+			decrypted, err := key.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("Decrypt() failed: %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Error("Decrypt() did not return the plaintext")
+			}
+		})
+	}
+
+	if _, err := key.Decrypt(make([]byte, aes.BlockSize-1)); err == nil {
+		t.Error("Decrypt() of a ciphertext without an IV should fail")
+	}
+	if _, err := key.HMAC([]byte("data")); err == nil {
+		t.Error("HMAC() with an AES key should fail")
+	}
+}
+
+func TestEncryptDecryptKeyGeneratedByTPM(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	srk, err := client.StorageRootKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+
+	key, err := client.NewKey(rwc, srk.Handle(), client.AESTemplate(256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+	plaintext := []byte("TPM-rooted data protection")
+	first, err := key.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := key.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("Encrypt() of the same plaintext twice returned the same ciphertext, want random IVs")
+	}
+	for _, ciphertext := range [][]byte{first, second} {
+		decrypted, err := key.Decrypt(ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("Decrypt() = %q, want %q", decrypted, plaintext)
+		}
+	}
+}
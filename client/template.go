@@ -1,23 +1,30 @@
 package client
 
 import (
-	"crypto/sha256"
+	"crypto"
 
 	"github.com/google/go-tpm/tpm2"
-	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/policy"
 )
 
 // Calculations from Credential_Profile_EK_V2.0, section 2.1.5.3 - authPolicy
 func defaultEKAuthPolicy() []byte {
-	buf, err := tpmutil.Pack(tpm2.CmdPolicySecret, tpm2.HandleEndorsement)
+	digest, err := policy.New(crypto.SHA256).Secret(policy.HandleName(tpm2.HandleEndorsement), nil).Digest()
 	if err != nil {
 		panic(err)
 	}
-	digest1 := sha256.Sum256(append(make([]byte, 32), buf...))
-	// We would normally append the policy buffer to digest1, but the
-	// policy buffer is empty for the default Auth Policy.
-	digest2 := sha256.Sum256(digest1[:])
-	return digest2[:]
+	return digest
+}
+
+// The policy digest of TPM2_PolicyCommandCode(TPM2_Certify), which limits the
+// admin role of DevID keys to being certified.
+func devIDAuthPolicy() []byte {
+	digest, err := policy.New(crypto.SHA256).CommandCode(tpm2.CmdCertify).Digest()
+	if err != nil {
+		panic(err)
+	}
+	return digest
 }
 
 func defaultEKAttributes() tpm2.KeyProp {
@@ -25,12 +32,40 @@ func defaultEKAttributes() tpm2.KeyProp {
 	return (tpm2.FlagStorageDefault | tpm2.FlagAdminWithPolicy) & ^tpm2.FlagUserWithAuth
 }
 
+// PolicyB (SHA-256) from Credential_Profile_EK_V2.3 onwards: the PolicyOR of
+// PolicyA (PolicySecret with the endorsement hierarchy, see
+// defaultEKAuthPolicy) and PolicyC (PolicyAuthorizeNV, delegating the EK's
+// authorization to a policy in an NV index).
+var highRangeEKAuthPolicy = []byte{
+	0xca, 0x3d, 0x0a, 0x99, 0xa2, 0xb9, 0x39, 0x06, 0xf7, 0xa3, 0x34, 0x24, 0x14, 0xef, 0xcf, 0xb3,
+	0xa3, 0x85, 0xd4, 0x4c, 0xd1, 0xfd, 0x45, 0x90, 0x89, 0xd1, 0x9b, 0x50, 0x71, 0xc0, 0xb7, 0xa0,
+}
+
+func highRangeEKAttributes() tpm2.KeyProp {
+	// Unlike low range EKs, high range EKs can be used with their (empty)
+	// password, as well as with their policy.
+	return tpm2.FlagStorageDefault | tpm2.FlagAdminWithPolicy
+}
+
 func defaultSRKAttributes() tpm2.KeyProp {
 	// FlagNoDA doesn't do anything (as the AuthPolicy is nil). However, this is
 	// what Windows does, and we don't want to conflict.
 	return tpm2.FlagStorageDefault | tpm2.FlagNoDA
 }
 
+func devIDAttributes() tpm2.KeyProp {
+	// DevIDs are unrestricted signing keys which never leave the TPM.
+	return tpm2.FlagFixedTPM | tpm2.FlagFixedParent | tpm2.FlagSensitiveDataOrigin |
+		tpm2.FlagUserWithAuth | tpm2.FlagAdminWithPolicy | tpm2.FlagSign
+}
+
+func symmetricKeyAttributes() tpm2.KeyProp {
+	// Like DevIDs, HMAC and symmetric keys never leave the TPM. For symmetric
+	// keys, FlagSign allows encryption, and FlagDecrypt decryption.
+	return tpm2.FlagFixedTPM | tpm2.FlagFixedParent | tpm2.FlagSensitiveDataOrigin |
+		tpm2.FlagUserWithAuth | tpm2.FlagSign
+}
+
 func defaultSymScheme() *tpm2.SymScheme {
 	return &tpm2.SymScheme{
 		Alg:     tpm2.AlgAES,
@@ -84,6 +119,36 @@ func DefaultEKTemplateECC() tpm2.Public {
 	}
 }
 
+// HighRangeEKTemplateRSA returns the high range RSA 2048 Endorsement Key (EK)
+// template, H-1 from Credential_Profile_EK_V2.3 onwards. It differs from
+// DefaultEKTemplateRSA in its attributes, its auth policy, and its empty
+// unique field, so it creates a different key.
+func HighRangeEKTemplateRSA() tpm2.Public {
+	params := defaultRSAParams()
+	params.ModulusRaw = nil
+	return tpm2.Public{
+		Type:          tpm2.AlgRSA,
+		NameAlg:       tpm2.AlgSHA256,
+		Attributes:    highRangeEKAttributes(),
+		AuthPolicy:    append([]byte(nil), highRangeEKAuthPolicy...),
+		RSAParameters: params,
+	}
+}
+
+// HighRangeEKTemplateECC returns the high range ECC NIST P-256 Endorsement Key
+// (EK) template, H-2 from Credential_Profile_EK_V2.3 onwards.
+func HighRangeEKTemplateECC() tpm2.Public {
+	params := defaultECCParams()
+	params.Point = tpm2.ECPoint{}
+	return tpm2.Public{
+		Type:          tpm2.AlgECC,
+		NameAlg:       tpm2.AlgSHA256,
+		Attributes:    highRangeEKAttributes(),
+		AuthPolicy:    append([]byte(nil), highRangeEKAuthPolicy...),
+		ECCParameters: params,
+	}
+}
+
 // AKTemplateRSA returns a potential Attestation Key (AK) template.
 // This is very similar to DefaultEKTemplateRSA, except that this will be a
 // signing key instead of an encrypting key.
@@ -120,6 +185,48 @@ func AKTemplateECC() tpm2.Public {
 	}
 }
 
+// DevIDTemplateRSA returns a Device Identity (DevID) key template, following
+// the TCG's TPM 2.0 Keys for Device Identity and Attestation. Unlike AKs,
+// DevIDs are unrestricted, so they can sign arbitrary data, such as TLS
+// handshakes during IEEE 802.1AR or BRSKI onboarding. Their admin role can
+// only be used to certify them (see Key.Certify).
+//
+// The TPM 2.0 specification has no Ed25519 keys, so DevIDs are RSA or ECC
+// (NIST P-256) keys.
+func DevIDTemplateRSA() tpm2.Public {
+	return tpm2.Public{
+		Type:       tpm2.AlgRSA,
+		NameAlg:    tpm2.AlgSHA256,
+		Attributes: devIDAttributes(),
+		AuthPolicy: devIDAuthPolicy(),
+		RSAParameters: &tpm2.RSAParams{
+			Sign: &tpm2.SigScheme{
+				Alg:  tpm2.AlgRSASSA,
+				Hash: tpm2.AlgSHA256,
+			},
+			KeyBits: 2048,
+		},
+	}
+}
+
+// DevIDTemplateECC returns a Device Identity (DevID) key template for a NIST
+// P-256 key, like DevIDTemplateRSA.
+func DevIDTemplateECC() tpm2.Public {
+	params := defaultECCParams()
+	params.Symmetric = nil
+	params.Sign = &tpm2.SigScheme{
+		Alg:  tpm2.AlgECDSA,
+		Hash: tpm2.AlgSHA256,
+	}
+	return tpm2.Public{
+		Type:          tpm2.AlgECC,
+		NameAlg:       tpm2.AlgSHA256,
+		Attributes:    devIDAttributes(),
+		AuthPolicy:    devIDAuthPolicy(),
+		ECCParameters: params,
+	}
+}
+
 // SRKTemplateRSA returns a standard Storage Root Key (SRK) template.
 // This is based upon the advice in the TCG's TPM v2.0 Provisioning Guidance.
 func SRKTemplateRSA() tpm2.Public {
@@ -141,3 +248,36 @@ func SRKTemplateECC() tpm2.Public {
 		ECCParameters: defaultECCParams(),
 	}
 }
+
+// HMACTemplate returns a template for a keyed hash key computing HMACs with the
+// given hash algorithm (see Key.HMAC). The key is generated by the TPM, and
+// never leaves it.
+func HMACTemplate(hashAlg tpm2.Algorithm) tpm2.Public {
+	return tpm2.Public{
+		Type:       tpm2.AlgKeyedHash,
+		NameAlg:    tpm2.AlgSHA256,
+		Attributes: symmetricKeyAttributes(),
+		KeyedHashParameters: &tpm2.KeyedHashParams{
+			Alg:  tpm2.AlgHMAC,
+			Hash: hashAlg,
+		},
+	}
+}
+
+// AESTemplate returns a template for an AES key in CFB mode, with the given
+// key size in bits, which can both encrypt and decrypt data (see Key.Encrypt).
+// The key is generated by the TPM, and never leaves it.
+func AESTemplate(keyBits uint16) tpm2.Public {
+	return tpm2.Public{
+		Type:       tpm2.AlgSymCipher,
+		NameAlg:    tpm2.AlgSHA256,
+		Attributes: symmetricKeyAttributes() | tpm2.FlagDecrypt,
+		SymCipherParameters: &tpm2.SymCipherParams{
+			Symmetric: &tpm2.SymScheme{
+				Alg:     tpm2.AlgAES,
+				KeyBits: keyBits,
+				Mode:    tpm2.AlgCFB,
+			},
+		},
+	}
+}
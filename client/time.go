@@ -0,0 +1,50 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// GetTime has the TPM sign its current time and clock state with this key. The
+// extraData (typically a nonce) is included in the signed data. The clock,
+// reset count and restart count let a verifier detect that the TPM was reset
+// or restarted (e.g. the machine rebooted) between two attestations, and the
+// nonce prevents an old attestation from being replayed. This function will
+// return an error if the key is not a restricted signing key.
+//
+// GetTime uses the endorsement hierarchy (as the privacy administrator) with
+// an empty password. The time attestation can be checked with
+// server.VerifyTime.
+func (k *Key) GetTime(extraData []byte) (*pb.TimeAttestation, error) {
+	if _, err := internal.GetSigningHashAlg(k.pubArea); err != nil {
+		return nil, err
+	}
+	if !k.hasAttribute(tpm2.FlagRestricted) {
+		return nil, fmt.Errorf("unrestricted keys are insecure to use with GetTime")
+	}
+	auth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+	resp, err := internal.RunCommand(k.rw, internal.CmdGetTime,
+		[]tpmutil.Handle{tpm2.HandleEndorsement, k.Handle()}, []tpm2.AuthCommand{auth, auth},
+		tpmutil.U16Bytes(extraData), tpm2.AlgNull)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time: %w", err)
+	}
+	buf := bytes.NewBuffer(resp)
+	var timeInfo tpmutil.U16Bytes
+	if err := tpmutil.UnpackBuf(buf, &timeInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode time attestation: %w", err)
+	}
+	attestation := &pb.TimeAttestation{TimeInfo: timeInfo, RawSig: buf.Bytes()}
+
+	// Verify the time attestation client-side to make sure we didn't mess
+	// things up. NOTE: it still must be verified server-side as well.
+	if _, err := internal.VerifyTimeAttestation(attestation, k.PublicKey(), extraData); err != nil {
+		return nil, fmt.Errorf("failed to verify time attestation: %w", err)
+	}
+	return attestation, nil
+}
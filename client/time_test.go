@@ -0,0 +1,47 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+func TestGetTime(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	keys := []struct {
+		name   string
+		getKey func() (*client.Key, error)
+	}{
+		{"AK-ECC", func() (*client.Key, error) { return client.AttestationKeyECC(rwc) }},
+		{"AK-RSA", func() (*client.Key, error) { return client.AttestationKeyRSA(rwc) }},
+	}
+	for _, key := range keys {
+		t.Run(key.name, func(t *testing.T) {
+			ak, err := key.getKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ak.Close()
+			if _, err := ak.GetTime([]byte("test")); err != nil {
+				t.Errorf("GetTime failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetTimeShouldFailWithNonSigningKey(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	srk, err := client.StorageRootKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	if _, err := srk.GetTime([]byte("test")); err == nil {
+		t.Error("GetTime with a non-signing key should fail")
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/tpm2"
+)
+
+// TLSCertificate returns a tls.Certificate which signs TLS handshakes with this
+// key, for use in a tls.Config of a server, or of a client using mutual TLS.
+// The chain holds the DER encoded certificates, leaf first; the leaf must be
+// for this key. The key must be an unrestricted signing key, such as a DevID
+// (see DevIDTemplateRSA and DevIDTemplateECC), as restricted keys only sign
+// data hashed by the TPM.
+//
+// As a TPM key only signs with the scheme and hash algorithm of its template,
+// the certificate only supports the matching TLS signature scheme, which both
+// sides of the connection must support:
+//   - ECDSA keys need the hash algorithm matching their curve (e.g. SHA-256
+//     for NIST P-256), and work with TLS 1.2 and 1.3.
+//   - RSASSA (PKCS#1 v1.5) keys only work with TLS 1.2, as TLS 1.3 does not
+//     allow PKCS#1 v1.5 handshake signatures. Set tls.Config.MaxVersion to
+//     tls.VersionTLS12 when using them.
+//   - RSAPSS keys work with TLS 1.2 and 1.3, if the TPM uses a salt as long
+//     as the digest, as required by TLS. TPMs implementing older versions of
+//     the TPM 2.0 specification may use a longer salt, which peers reject.
+//
+// The returned certificate can only be used while the key is loaded.
+func (k *Key) TLSCertificate(chain [][]byte) (tls.Certificate, error) {
+	if len(chain) == 0 {
+		return tls.Certificate{}, errors.New("no certificate provided")
+	}
+	if k.hasAttribute(tpm2.FlagRestricted) {
+		return tls.Certificate{}, errors.New("restricted keys cannot sign TLS handshakes")
+	}
+	scheme, err := tlsSignatureScheme(k.pubArea)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	signer, err := k.GetSigner()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to parse leaf certificate: %w", err)
+	}
+	if pub, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(k.PublicKey()) {
+		return tls.Certificate{}, errors.New("leaf certificate is not for this key")
+	}
+	return tls.Certificate{
+		Certificate:                  chain,
+		PrivateKey:                   tlsSigner{signer},
+		SupportedSignatureAlgorithms: []tls.SignatureScheme{scheme},
+		Leaf:                         leaf,
+	}, nil
+}
+
+// tlsSignatureScheme returns the TLS signature scheme matching the signing
+// scheme of a key's template.
+func tlsSignatureScheme(pub tpm2.Public) (tls.SignatureScheme, error) {
+	switch pub.Type {
+	case tpm2.AlgRSA:
+		if pub.RSAParameters == nil || pub.RSAParameters.Sign == nil {
+			break
+		}
+		schemes := map[tpm2.Algorithm]map[tpm2.Algorithm]tls.SignatureScheme{
+			tpm2.AlgRSASSA: {
+				tpm2.AlgSHA256: tls.PKCS1WithSHA256,
+				tpm2.AlgSHA384: tls.PKCS1WithSHA384,
+				tpm2.AlgSHA512: tls.PKCS1WithSHA512,
+			},
+			tpm2.AlgRSAPSS: {
+				tpm2.AlgSHA256: tls.PSSWithSHA256,
+				tpm2.AlgSHA384: tls.PSSWithSHA384,
+				tpm2.AlgSHA512: tls.PSSWithSHA512,
+			},
+		}
+		if scheme, ok := schemes[pub.RSAParameters.Sign.Alg][pub.RSAParameters.Sign.Hash]; ok {
+			return scheme, nil
+		}
+	case tpm2.AlgECC:
+		if pub.ECCParameters == nil || pub.ECCParameters.Sign == nil || pub.ECCParameters.Sign.Alg != tpm2.AlgECDSA {
+			break
+		}
+		params := pub.ECCParameters
+		switch {
+		case params.CurveID == tpm2.CurveNISTP256 && params.Sign.Hash == tpm2.AlgSHA256:
+			return tls.ECDSAWithP256AndSHA256, nil
+		case params.CurveID == tpm2.CurveNISTP384 && params.Sign.Hash == tpm2.AlgSHA384:
+			return tls.ECDSAWithP384AndSHA384, nil
+		case params.CurveID == tpm2.CurveNISTP521 && params.Sign.Hash == tpm2.AlgSHA512:
+			return tls.ECDSAWithP521AndSHA512, nil
+		}
+	}
+	return 0, errors.New("key's signing scheme has no matching TLS signature scheme")
+}
+
+// tlsSigner adapts a TPM signer to the options used by crypto/tls.
+type tlsSigner struct {
+	crypto.Signer
+}
+
+func (s tlsSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	// TLS requires the salt to be as long as the digest. The TPM chooses the
+	// salt length, which is checked by the peer.
+	if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: pssOpts.Hash}
+	}
+	return s.Signer.Sign(rand, digest, opts)
+}
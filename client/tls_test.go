@@ -0,0 +1,216 @@
+package client_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+// testCA issues certificates for TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert, key, pool}
+}
+
+func (ca *testCA) issue(t *testing.T, name string, pub crypto.PublicKey) []byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, pub, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// handshake runs a mutual TLS handshake between a server and client
+// certificate, returning the negotiated TLS version.
+func handshake(t *testing.T, ca *testCA, serverCert, clientCert tls.Certificate, maxVersion uint16) (uint16, error) {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	serverErr := make(chan error, 1)
+	go func() {
+		server := tls.Server(serverConn, &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    ca.pool,
+			MaxVersion:   maxVersion,
+		})
+		// Closing the TLS connections would block on the unbuffered pipe.
+		defer serverConn.Close()
+		err := server.Handshake()
+		if err == nil {
+			// Complete the client's handshake, which in TLS 1.3 ends after
+			// the server sends its first data.
+			_, err = server.Write([]byte("hello"))
+		}
+		serverErr <- err
+	}()
+
+	client := tls.Client(clientConn, &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      ca.pool,
+		ServerName:   "server",
+		MaxVersion:   maxVersion,
+	})
+	defer clientConn.Close()
+	err := client.Handshake()
+	if err == nil {
+		_, err = io.ReadFull(client, make([]byte, 5))
+	}
+	if err != nil {
+		clientConn.Close()
+		<-serverErr
+		return 0, err
+	}
+	if err := <-serverErr; err != nil {
+		return 0, err
+	}
+	return client.ConnectionState().Version, nil
+}
+
+func TestTLSCertificate(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ca := newTestCA(t)
+
+	pssTemplate := client.DevIDTemplateRSA()
+	pssTemplate.RSAParameters.Sign.Alg = tpm2.AlgRSAPSS
+	subtests := []struct {
+		name        string
+		newKey      func(rw io.ReadWriter) (*client.Key, error)
+		maxVersion  uint16
+		wantVersion uint16
+	}{
+		{"ECDSA", client.LDevIDKeyECC, 0, tls.VersionTLS13},
+		{"RSAPSS", func(rw io.ReadWriter) (*client.Key, error) {
+			return client.NewKey(rw, tpm2.HandleOwner, pssTemplate)
+		}, 0, tls.VersionTLS13},
+		{"RSASSA", client.LDevIDKeyRSA, tls.VersionTLS12, tls.VersionTLS12},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			serverKey, err := subtest.newKey(rwc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer serverKey.Close()
+			clientKey, err := client.IDevIDKeyECC(rwc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer clientKey.Close()
+
+			serverCert, err := serverKey.TLSCertificate([][]byte{ca.issue(t, "server", serverKey.PublicKey())})
+			if err != nil {
+				t.Fatalf("TLSCertificate() failed: %v", err)
+			}
+			clientCert, err := clientKey.TLSCertificate([][]byte{ca.issue(t, "client", clientKey.PublicKey())})
+			if err != nil {
+				t.Fatalf("TLSCertificate() failed: %v", err)
+			}
+			version, err := handshake(t, ca, serverCert, clientCert, subtest.maxVersion)
+			if err != nil {
+				t.Fatalf("mutual TLS handshake failed: %v", err)
+			}
+			if version != subtest.wantVersion {
+				t.Errorf("negotiated TLS version 0x%x, want 0x%x", version, subtest.wantVersion)
+			}
+		})
+	}
+}
+
+func TestTLSCertificateInvalid(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ca := newTestCA(t)
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	devID, err := client.LDevIDKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devID.Close()
+
+	if _, err := ak.TLSCertificate([][]byte{ca.issue(t, "ak", ak.PublicKey())}); err == nil {
+		t.Error("restricted keys should not be usable for TLS")
+	}
+	if _, err := devID.TLSCertificate([][]byte{ca.issue(t, "other", ak.PublicKey())}); err == nil {
+		t.Error("a certificate for another key should not be usable")
+	}
+	if _, err := devID.TLSCertificate(nil); err == nil {
+		t.Error("a certificate is required")
+	}
+
+	// RSASSA keys cannot sign TLS 1.3 handshakes.
+	rsaKey, err := client.LDevIDKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsaKey.Close()
+	serverCert, err := rsaKey.TLSCertificate([][]byte{ca.issue(t, "server", rsaKey.PublicKey())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := devID.TLSCertificate([][]byte{ca.issue(t, "client", devID.PublicKey())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handshake(t, ca, serverCert, clientCert, tls.VersionTLS13); err == nil {
+		t.Error("TLS 1.3 handshake with an RSASSA key should fail")
+	}
+}
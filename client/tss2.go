@@ -0,0 +1,326 @@
+package client
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/internal"
+)
+
+// The PEM block type of TPM key files, as used by the OpenSSL TPM2 engine and
+// provider, and by tpm2-tools (see "ASN.1 Specification for TPM 2.0 Key Files").
+const tss2PEMType = "TSS2 PRIVATE KEY"
+
+// id-loadablekey, for keys with a private area which can be loaded under their
+// parent.
+var oidLoadableKey = asn1.ObjectIdentifier{2, 23, 133, 10, 1, 3}
+
+// tss2Key is the TPMKey ASN.1 structure of a TPM key file.
+type tss2Key struct {
+	Type       asn1.ObjectIdentifier
+	EmptyAuth  bool            `asn1:"optional,explicit,tag:0"`
+	Policy     []tss2Policy    `asn1:"optional,explicit,tag:1"`
+	Secret     []byte          `asn1:"optional,explicit,tag:2"`
+	AuthPolicy []asn1.RawValue `asn1:"optional,explicit,tag:3"`
+	Parent     int64
+	PubKey     []byte
+	PrivKey    []byte
+}
+
+// tss2Policy is a policy command which authorizes the use of a key: its command
+// code and parameters, after the policy session handle.
+type tss2Policy struct {
+	CommandCode   int64  `asn1:"explicit,tag:0"`
+	CommandPolicy []byte `asn1:"explicit,tag:1"`
+}
+
+// The policy commands which can authorize keys loaded from TSS2 PEM files.
+// They only take the policy session handle, and do not need an HMAC session,
+// which go-tpm does not support (so TPM2_PolicyAuthValue cannot be used).
+var tss2PolicyCommands = map[tpmutil.Command]bool{
+	tpm2.CmdPolicyPCR:          true,
+	tpm2.CmdPolicyCommandCode:  true,
+	tpm2.CmdPolicyPassword:     true,
+	internal.CmdPolicyLocality: true,
+}
+
+// TSS2SRKTemplateECC returns the template of the storage primary key used as
+// the parent of TSS2 PEM files with a hierarchy (rather than a persistent key)
+// as their parent, by the OpenSSL TPM2 engine and provider. Unlike
+// SRKTemplateECC, its unique field is empty.
+func TSS2SRKTemplateECC() tpm2.Public {
+	template := SRKTemplateECC()
+	template.ECCParameters.Point = tpm2.ECPoint{}
+	return template
+}
+
+// TSS2SRKTemplateRSA is the RSA equivalent of TSS2SRKTemplateECC.
+func TSS2SRKTemplateRSA() tpm2.Public {
+	template := SRKTemplateRSA()
+	template.RSAParameters.ModulusRaw = nil
+	return template
+}
+
+// tss2ParentTemplates are the storage primary key templates which the parent of
+// a TSS2 PEM file with a hierarchy parent may have been created from.
+func tss2ParentTemplates() []tpm2.Public {
+	// The default primary key of tpm2_createprimary (as used with
+	// tpm2_encodeobject) does not have the noDA attribute.
+	createPrimaryRSA := TSS2SRKTemplateRSA()
+	createPrimaryRSA.Attributes &^= tpm2.FlagNoDA
+	return []tpm2.Public{
+		TSS2SRKTemplateECC(),
+		TSS2SRKTemplateRSA(),
+		createPrimaryRSA,
+		SRKTemplateECC(),
+		SRKTemplateRSA(),
+	}
+}
+
+// ExportTSS2PEM encodes the key as a "TSS2 PRIVATE KEY" PEM file, which other
+// TPM software (such as OpenSSL's tpm2 provider or tpm2-tools) can load with
+// the same TPM. The private area in the file is encrypted by the TPM, so can
+// only be used by loading it under the key's parent, which must be either:
+//   - a persistent key, such as the SRK from StorageRootKeyRSA, or
+//   - a storage primary key created from TSS2SRKTemplateECC or
+//     TSS2SRKTemplateRSA (or SRKTemplateECC or SRKTemplateRSA, though other
+//     software may not load keys under those), which is recorded as its
+//     hierarchy, so it is recreated when the key is loaded.
+//
+// Only keys created under such a parent (with NewKey) or loaded with
+// LoadTSS2PEM can be exported. Files for keys loaded with a password or policy
+// keep them.
+func (k *Key) ExportTSS2PEM() ([]byte, error) {
+	if k.privBlob == nil {
+		return nil, errors.New("only keys created under a parent key can be exported")
+	}
+	parent, err := k.tss2Parent()
+	if err != nil {
+		return nil, err
+	}
+	if k.policy == nil && !k.hasAttribute(tpm2.FlagUserWithAuth) {
+		return nil, errors.New("keys which require an unknown policy cannot be exported")
+	}
+	pub, err := tpmutil.Pack(tpmutil.U16Bytes(k.pubBlob))
+	if err != nil {
+		return nil, err
+	}
+	priv, err := tpmutil.Pack(tpmutil.U16Bytes(k.privBlob))
+	if err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(tss2Key{
+		Type:      oidLoadableKey,
+		EmptyAuth: !k.hasPassword,
+		Policy:    k.policy,
+		Parent:    int64(parent),
+		PubKey:    pub,
+		PrivKey:   priv,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: tss2PEMType, Bytes: der}), nil
+}
+
+// tss2Parent returns the parent handle to record in a TSS2 PEM file.
+func (k *Key) tss2Parent() (tpmutil.Handle, error) {
+	if isOwnerPersistent(k.parent) || isHierarchy(k.parent) {
+		return k.parent, nil
+	}
+	pub, name, qualifiedName, err := tpm2.ReadPublic(k.rw, k.parent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read parent public area: %w", err)
+	}
+	standard := false
+	for _, template := range tss2ParentTemplates() {
+		standard = standard || pub.MatchesTemplate(template)
+	}
+	if !standard {
+		return 0, fmt.Errorf("parent 0x%x is neither persistent nor a standard storage primary key", k.parent)
+	}
+	// A primary key's qualified name is the hash of its hierarchy's handle
+	// and its name.
+	hash, err := pub.NameAlg.Hash()
+	if err != nil {
+		return 0, err
+	}
+	if len(qualifiedName) < 2 {
+		return 0, errors.New("invalid parent qualified name")
+	}
+	for _, hierarchy := range []tpmutil.Handle{tpm2.HandleOwner, tpm2.HandleEndorsement, tpm2.HandlePlatform, tpm2.HandleNull} {
+		h := hash.New()
+		binary.Write(h, binary.BigEndian, uint32(hierarchy))
+		h.Write(name)
+		if bytes.Equal(qualifiedName[2:], h.Sum(nil)) {
+			return hierarchy, nil
+		}
+	}
+	return 0, fmt.Errorf("parent 0x%x is not a primary key", k.parent)
+}
+
+// LoadTSS2PEM loads a key from a "TSS2 PRIVATE KEY" PEM file, such as one
+// created by ExportTSS2PEM, OpenSSL's tpm2 provider or tpm2_encodeobject. The
+// key must have been created in this TPM, and its parent must be a persistent
+// key, or a hierarchy (see ExportTSS2PEM). If the file has a policy, it is
+// satisfied whenever the key is used; only TPM2_PolicyPCR,
+// TPM2_PolicyCommandCode, TPM2_PolicyLocality and TPM2_PolicyPassword are
+// supported. Keys which need a password must be loaded with
+// LoadTSS2PEMWithPassword.
+func LoadTSS2PEM(rw io.ReadWriter, data []byte) (*Key, error) {
+	return LoadTSS2PEMWithPassword(rw, data, nil)
+}
+
+// LoadTSS2PEMWithPassword is like LoadTSS2PEM, but for keys with a password
+// (whose files do not have the emptyAuth flag). The password is sent to the
+// TPM in the clear. Keys with a policy can only use their password through
+// TPM2_PolicyPassword.
+func LoadTSS2PEMWithPassword(rw io.ReadWriter, data []byte, password []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != tss2PEMType {
+		return nil, fmt.Errorf("no %q PEM block found", tss2PEMType)
+	}
+	var key tss2Key
+	rest, err := asn1.Unmarshal(block.Bytes, &key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after key")
+	}
+	if !key.Type.Equal(oidLoadableKey) {
+		return nil, fmt.Errorf("unsupported key type %v, want a loadable key", key.Type)
+	}
+	if key.Secret != nil || key.AuthPolicy != nil {
+		return nil, errors.New("keys with a secret or signed policies are not supported")
+	}
+	if key.EmptyAuth && len(password) != 0 {
+		return nil, errors.New("key does not have a password")
+	}
+	if !key.EmptyAuth && len(password) == 0 {
+		return nil, errors.New("key requires a password")
+	}
+	for _, policy := range key.Policy {
+		cmd := tpmutil.Command(policy.CommandCode)
+		if int64(cmd) != policy.CommandCode || !tss2PolicyCommands[cmd] {
+			return nil, fmt.Errorf("unsupported policy command 0x%x", policy.CommandCode)
+		}
+	}
+	parent := tpmutil.Handle(key.Parent)
+	if int64(parent) != key.Parent || !(isOwnerPersistent(parent) || isHierarchy(parent)) {
+		return nil, fmt.Errorf("parent 0x%x is neither a persistent key nor a hierarchy", key.Parent)
+	}
+
+	var pub, priv tpmutil.U16Bytes
+	if err := unpackTPM2B(key.PubKey, &pub); err != nil {
+		return nil, fmt.Errorf("invalid public area: %w", err)
+	}
+	if err := unpackTPM2B(key.PrivKey, &priv); err != nil {
+		return nil, fmt.Errorf("invalid private area: %w", err)
+	}
+	var handle tpmutil.Handle
+	if isHierarchy(parent) {
+		handle, err = loadUnderHierarchy(rw, parent, pub, priv)
+	} else if handle, _, err = tpm2.Load(rw, parent, "", pub, priv); err != nil {
+		err = fmt.Errorf("failed to load key under parent 0x%x: %w", parent, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s session = nullSession{}
+	if len(key.Policy) > 0 {
+		if s, err = newTSS2PolicySession(rw, key.Policy, password); err != nil {
+			tpm2.FlushContext(rw, handle)
+			return nil, err
+		}
+	} else if len(password) > 0 {
+		s = passwordSession{password}
+	}
+	k, err := childKey(rw, handle, parent, pub, priv, s)
+	if err != nil {
+		return nil, err
+	}
+	k.hasPassword = !key.EmptyAuth
+	k.policy = key.Policy
+	return k, nil
+}
+
+// loadUnderHierarchy loads a key under the standard storage primary key in a
+// hierarchy, trying each template it may have been created from. The primary
+// key is flushed after the key is loaded.
+func loadUnderHierarchy(rw io.ReadWriter, hierarchy tpmutil.Handle, pub, priv []byte) (tpmutil.Handle, error) {
+	var err error
+	for _, template := range tss2ParentTemplates() {
+		var parent, handle tpmutil.Handle
+		parent, _, err = tpm2.CreatePrimary(rw, hierarchy, tpm2.PCRSelection{}, "", "", template)
+		if err != nil {
+			// The TPM may not support the template's algorithms.
+			continue
+		}
+		handle, _, err = tpm2.Load(rw, parent, "", pub, priv)
+		tpm2.FlushContext(rw, parent)
+		if err == nil {
+			return handle, nil
+		}
+	}
+	return 0, fmt.Errorf("failed to load key under a storage primary key in hierarchy 0x%x: %w", hierarchy, err)
+}
+
+func unpackTPM2B(data []byte, out *tpmutil.U16Bytes) error {
+	read, err := tpmutil.Unpack(data, out)
+	if err != nil {
+		return err
+	}
+	if read != len(data) {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+// tss2PolicySession satisfies the policy of a key loaded from a TSS2 PEM file,
+// by running its policy commands.
+type tss2PolicySession struct {
+	rw       io.ReadWriter
+	session  tpmutil.Handle
+	policy   []tss2Policy
+	password []byte
+}
+
+func newTSS2PolicySession(rw io.ReadWriter, policy []tss2Policy, password []byte) (session, error) {
+	session, err := startAuthSession(rw)
+	return tss2PolicySession{rw, session, policy, password}, err
+}
+
+func (s tss2PolicySession) Auth() (auth tpm2.AuthCommand, err error) {
+	// Start from an empty policy, in case an earlier Auth was not used.
+	if _, err = internal.RunCommand(s.rw, internal.CmdPolicyRestart, []tpmutil.Handle{s.session}, nil); err != nil {
+		return
+	}
+	for _, policy := range s.policy {
+		if _, err = internal.RunCommand(s.rw, tpmutil.Command(policy.CommandCode), []tpmutil.Handle{s.session}, nil,
+			tpmutil.RawBytes(policy.CommandPolicy)); err != nil {
+			return auth, fmt.Errorf("policy command 0x%x failed: %w", policy.CommandCode, err)
+		}
+	}
+	auth = tpm2.AuthCommand{Session: s.session, Attributes: tpm2.AttrContinueSession}
+	for _, policy := range s.policy {
+		// With TPM2_PolicyPassword, the password is sent in the clear.
+		if tpmutil.Command(policy.CommandCode) == tpm2.CmdPolicyPassword {
+			auth.Auth = s.password
+		}
+	}
+	return auth, nil
+}
+
+func (s tss2PolicySession) Close() error {
+	return tpm2.FlushContext(s.rw, s.session)
+}
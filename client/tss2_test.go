@@ -0,0 +1,420 @@
+package client_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm-tools/policy"
+	"github.com/google/go-tpm-tools/simulator"
+)
+
+func TestTSS2PEM(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	srk, err := client.StorageRootKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+
+	key, err := client.NewKey(rwc, srk.Handle(), client.DevIDTemplateECC())
+	if err != nil {
+		t.Fatalf("NewKey() under the SRK failed: %v", err)
+	}
+	exported, err := key.ExportTSS2PEM()
+	key.Close()
+	if err != nil {
+		t.Fatalf("ExportTSS2PEM() failed: %v", err)
+	}
+	if block, _ := pem.Decode(exported); block == nil || block.Type != "TSS2 PRIVATE KEY" {
+		t.Fatalf("ExportTSS2PEM() = %q, want a TSS2 PRIVATE KEY PEM block", exported)
+	}
+
+	loaded, err := client.LoadTSS2PEM(rwc, exported)
+	if err != nil {
+		t.Fatalf("LoadTSS2PEM() failed: %v", err)
+	}
+	defer loaded.Close()
+	if loaded.PublicArea().Type != tpm2.AlgECC {
+		t.Errorf("loaded key has type %v, want ECC", loaded.PublicArea().Type)
+	}
+	if err := signAndVerify(loaded); err != nil {
+		t.Errorf("signing with the loaded key failed: %v", err)
+	}
+	reexported, err := loaded.ExportTSS2PEM()
+	if err != nil {
+		t.Fatalf("ExportTSS2PEM() of a loaded key failed: %v", err)
+	}
+	if !bytes.Equal(reexported, exported) {
+		t.Error("re-exporting a loaded key changed the file")
+	}
+}
+
+func TestTSS2PEMHierarchyParent(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	for _, template := range []tpm2.Public{client.TSS2SRKTemplateECC(), client.TSS2SRKTemplateRSA(), client.SRKTemplateECC()} {
+		srk, err := client.NewKey(rwc, tpm2.HandleOwner, template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := client.NewKey(rwc, srk.Handle(), client.DevIDTemplateRSA())
+		if err != nil {
+			t.Fatal(err)
+		}
+		exported, err := key.ExportTSS2PEM()
+		key.Close()
+		srk.Close()
+		if err != nil {
+			t.Fatalf("ExportTSS2PEM() failed: %v", err)
+		}
+
+		// The parent is recreated when loading the key.
+		loaded, err := client.LoadTSS2PEM(rwc, exported)
+		if err != nil {
+			t.Fatalf("LoadTSS2PEM() failed: %v", err)
+		}
+		reexported, err := loaded.ExportTSS2PEM()
+		loaded.Close()
+		if err != nil {
+			t.Fatalf("ExportTSS2PEM() of a loaded key failed: %v", err)
+		}
+		if !bytes.Equal(reexported, exported) {
+			t.Error("re-exporting a loaded key changed the file")
+		}
+	}
+}
+
+// The TSS2 PEM files in testdata/tss2 are not produced by other software, such
+// as tpm2-tools. TestGenerateTSS2Vectors encodes them directly, rather than
+// with ExportTSS2PEM, so they can have policies, as files from other TPM
+// software do. It uses a simulator (the ms-tpm-20-ref fork vendored in
+// simulator/ms-tpm-20-ref) with this seed, so the storage keys which are their
+// parents can be recreated. To regenerate them, run:
+//
+//	go test ./client -run TestGenerateTSS2Vectors -generate-tss2
+//
+// The simulator needs cgo and the OpenSSL headers (see simulator/README.md).
+const tss2VectorSeed = 1234
+
+var generateTSS2 = flag.Bool("generate-tss2", false, "regenerate the TSS2 PEM files in testdata/tss2")
+
+// The password of the vectors which need one.
+var tss2VectorPassword = []byte("passw0rd")
+
+func TestGenerateTSS2Vectors(t *testing.T) {
+	if !*generateTSS2 {
+		t.Skip("-generate-tss2 not set")
+	}
+	sim, err := simulator.GetWithFixedSeedInsecure(tss2VectorSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.CheckedClose(t, sim)
+	srk, err := client.StorageRootKeyRSA(sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	// Keys with the owner hierarchy as their parent are loaded under the first
+	// standard storage primary key.
+	ownerSRK, err := client.NewKey(sim, tpm2.HandleOwner, client.TSS2SRKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ownerSRK.Close()
+
+	// TPM2_PolicyPCR of the current value of the application PCR.
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{test.ApplicationPCR}}
+	pcrs, err := client.ReadPCRs(sim, sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcrDigest := sha256.Sum256(pcrs.GetPcrs()[uint32(test.ApplicationPCR)])
+	pcrSelect := make([]byte, 3)
+	pcrSelect[test.ApplicationPCR/8] |= 1 << (test.ApplicationPCR % 8)
+	pcrPolicy, err := tpmutil.Pack(tpmutil.U16Bytes(pcrDigest[:]), uint32(1), sel.Hash, uint8(len(pcrSelect)), tpmutil.RawBytes(pcrSelect))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vectors := []struct {
+		file     string
+		parent   tpmutil.Handle
+		template tpm2.Public
+		password []byte
+		policy   []tss2VectorPolicy
+	}{
+		{"ecc-owner.pem", tpm2.HandleOwner, client.DevIDTemplateECC(), nil, nil},
+		{"ecc-policy-password.pem", tpm2.HandleOwner, policyTemplate(t, policy.New(crypto.SHA256).Password()), tss2VectorPassword,
+			[]tss2VectorPolicy{{int64(tpm2.CmdPolicyPassword), nil}}},
+		{"ecc-policy-pcr.pem", client.SRKReservedHandle, policyTemplate(t, policy.New(crypto.SHA256).PCR(sel, pcrDigest[:])), nil,
+			[]tss2VectorPolicy{{int64(tpm2.CmdPolicyPCR), pcrPolicy}}},
+		{"rsa-persistent-password.pem", client.SRKReservedHandle, client.DevIDTemplateRSA(), tss2VectorPassword, nil},
+	}
+	for _, vector := range vectors {
+		parent := vector.parent
+		if parent == tpm2.HandleOwner {
+			parent = ownerSRK.Handle()
+		}
+		priv, pub, _, _, _, err := tpm2.CreateKey(sim, parent, tpm2.PCRSelection{}, "", string(vector.password), vector.template)
+		if err != nil {
+			t.Fatalf("creating %s: %v", vector.file, err)
+		}
+		pubKey, err := tpmutil.Pack(tpmutil.U16Bytes(pub))
+		if err != nil {
+			t.Fatal(err)
+		}
+		privKey, err := tpmutil.Pack(tpmutil.U16Bytes(priv))
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := asn1.Marshal(tss2VectorKey{
+			Type:      asn1.ObjectIdentifier{2, 23, 133, 10, 1, 3},
+			EmptyAuth: vector.password == nil,
+			Policy:    vector.policy,
+			Parent:    int64(vector.parent),
+			PubKey:    pubKey,
+			PrivKey:   privKey,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "TSS2 PRIVATE KEY", Bytes: der})
+		if err := ioutil.WriteFile(filepath.Join("testdata", "tss2", vector.file), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// tss2VectorKey is the TPMKey ASN.1 structure of a TSS2 PEM file.
+type tss2VectorKey struct {
+	Type      asn1.ObjectIdentifier
+	EmptyAuth bool               `asn1:"optional,explicit,tag:0"`
+	Policy    []tss2VectorPolicy `asn1:"optional,explicit,tag:1"`
+	Parent    int64
+	PubKey    []byte
+	PrivKey   []byte
+}
+
+type tss2VectorPolicy struct {
+	CommandCode   int64  `asn1:"explicit,tag:0"`
+	CommandPolicy []byte `asn1:"explicit,tag:1"`
+}
+
+// policyTemplate returns an ECC DevID template which can only be used with the
+// policy.
+func policyTemplate(t *testing.T, p *policy.Policy) tpm2.Public {
+	t.Helper()
+	digest, err := p.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := client.DevIDTemplateECC()
+	template.Attributes &^= tpm2.FlagUserWithAuth
+	template.AuthPolicy = digest
+	return template
+}
+
+func TestTSS2PEMVectors(t *testing.T) {
+	sim, err := simulator.GetWithFixedSeedInsecure(tss2VectorSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.CheckedClose(t, sim)
+	// Some of the keys' parent is the persistent SRK.
+	srk, err := client.StorageRootKeyRSA(sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srk.Close()
+
+	password := tss2VectorPassword
+	subtests := []struct {
+		file     string
+		password []byte
+	}{
+		{"ecc-owner.pem", nil},
+		{"ecc-policy-password.pem", password},
+		{"ecc-policy-pcr.pem", nil},
+		{"rsa-persistent-password.pem", password},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.file, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join("testdata", "tss2", subtest.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if subtest.password != nil {
+				if key, err := client.LoadTSS2PEM(sim, data); err == nil {
+					key.Close()
+					t.Error("LoadTSS2PEM() without the password succeeded")
+				}
+			}
+			key, err := client.LoadTSS2PEMWithPassword(sim, data, subtest.password)
+			if err != nil {
+				t.Fatalf("LoadTSS2PEMWithPassword() failed: %v", err)
+			}
+			defer key.Close()
+			if err := signAndVerify(key); err != nil {
+				t.Errorf("signing with the loaded key failed: %v", err)
+			}
+			exported, err := key.ExportTSS2PEM()
+			if err != nil {
+				t.Fatalf("ExportTSS2PEM() failed: %v", err)
+			}
+			if !bytes.Equal(exported, data) {
+				t.Error("re-exporting the key changed the file")
+			}
+
+			if subtest.password != nil {
+				wrong, err := client.LoadTSS2PEMWithPassword(sim, data, []byte("wrong"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer wrong.Close()
+				if err := signAndVerify(wrong); err == nil {
+					t.Error("signing with the wrong password succeeded")
+				}
+			}
+		})
+	}
+
+	// The key's policy requires PCR 23 to be unchanged.
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "tss2", "ecc-policy-pcr.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := client.LoadTSS2PEM(sim, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+	if err := tpm2.PCRExtend(sim, tpmutil.Handle(test.ApplicationPCR), tpm2.AlgSHA256, make([]byte, 32), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := signAndVerify(key); err == nil {
+		t.Error("signing after the PCR changed succeeded")
+	}
+}
+
+// signAndVerify signs a digest with a key, and verifies the signature.
+func signAndVerify(key *client.Key) error {
+	signer, err := key.GetSigner()
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte("data"))
+	sig, err := signer.Sign(nil, digest[:], crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	switch pub := key.PublicKey().(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+	}
+	return errors.New("unsupported key type")
+}
+
+func TestTSS2PEMInvalid(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	primary, err := client.LDevIDKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	if _, err := primary.ExportTSS2PEM(); err == nil {
+		t.Error("exporting a primary key should fail")
+	}
+
+	// The parent of a file's key must be persistent, or a standard storage
+	// primary key, which can be recreated.
+	template := client.SRKTemplateECC()
+	template.ECCParameters.Symmetric.KeyBits = 256
+	otherSRK, err := client.NewKey(rwc, tpm2.HandleOwner, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherSRK.Close()
+	child, err := client.NewKey(rwc, otherSRK.Handle(), client.DevIDTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer child.Close()
+	if _, err := child.ExportTSS2PEM(); err == nil {
+		t.Error("exporting a key under a non-standard transient parent should fail")
+	}
+
+	encode := func(v interface{}) []byte {
+		der, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "TSS2 PRIVATE KEY", Bytes: der})
+	}
+	loadable := asn1.ObjectIdentifier{2, 23, 133, 10, 1, 3}
+	type keyFile struct {
+		Type      asn1.ObjectIdentifier
+		EmptyAuth bool `asn1:"optional,explicit,tag:0"`
+		Parent    int64
+		PubKey    []byte
+		PrivKey   []byte
+	}
+	type policy struct {
+		CommandCode   int64  `asn1:"explicit,tag:0"`
+		CommandPolicy []byte `asn1:"explicit,tag:1"`
+	}
+	type policyKeyFile struct {
+		Type      asn1.ObjectIdentifier
+		EmptyAuth bool     `asn1:"optional,explicit,tag:0"`
+		Policy    []policy `asn1:"optional,explicit,tag:1"`
+		Parent    int64
+		PubKey    []byte
+		PrivKey   []byte
+	}
+	subtests := []struct {
+		name string
+		data []byte
+	}{
+		{"NotPEM", []byte("not a key")},
+		{"WrongPEMType", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{0}})},
+		{"NotASN1", pem.EncodeToMemory(&pem.Block{Type: "TSS2 PRIVATE KEY", Bytes: []byte{0}})},
+		{"ImportableKey", encode(keyFile{asn1.ObjectIdentifier{2, 23, 133, 10, 1, 4}, true, 0x81000001, []byte{0, 0}, []byte{0, 0}})},
+		{"MissingPassword", encode(keyFile{loadable, false, 0x81000001, []byte{0, 0}, []byte{0, 0}})},
+		{"TransientParent", encode(keyFile{loadable, true, 0x80000001, []byte{0, 0}, []byte{0, 0}})},
+		{"TruncatedPublic", encode(keyFile{loadable, true, 0x81000001, []byte{0, 5, 1}, []byte{0, 0}})},
+		{"PolicyAuthValue", encode(policyKeyFile{loadable, true, []policy{{0x16B, nil}}, 0x81000001, []byte{0, 0}, []byte{0, 0}})},
+		{"PolicySecret", encode(policyKeyFile{loadable, true, []policy{{0x151, nil}}, 0x81000001, []byte{0, 0}, []byte{0, 0}})},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if key, err := client.LoadTSS2PEM(rwc, subtest.data); err == nil {
+				key.Close()
+				t.Error("LoadTSS2PEM() succeeded, want error")
+			}
+		})
+	}
+}
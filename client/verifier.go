@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+)
+
+// AttestToVerifier performs remote attestation against a Verifier gRPC
+// service (such as server.VerifierService). It requests a nonce from the
+// verifier, generates an Attestation using that nonce, and sends the
+// Attestation back to be verified. The key must be trusted by the verifier.
+//
+// The returned response contains the verifier's signed claims token, which
+// can be passed on to relying parties:
+//
+//	conn, err := grpc.Dial(verifierAddress, ...)
+//	...
+//	resp, err := key.AttestToVerifier(ctx, verifier.NewVerifierClient(conn))
+//	token := resp.GetClaimsToken()
+func (k *Key) AttestToVerifier(ctx context.Context, verifier verifierpb.VerifierClient) (*verifierpb.VerifyAttestationResponse, error) {
+	return AttestToVerifier(ctx, k, verifier)
+}
+
+// AttestToVerifier behaves like Key.AttestToVerifier, but attests with any
+// Attester. If the Attester is a ContextAttester, attesting stops once ctx is
+// done.
+func AttestToVerifier(ctx context.Context, attester Attester, verifier verifierpb.VerifierClient) (*verifierpb.VerifyAttestationResponse, error) {
+	return AttestToVerifierWithOpts(ctx, attester, verifier, AttestOpts{})
+}
+
+// AttestToVerifierWithOpts behaves like AttestToVerifier, but attests with
+// opts (for example, to include a Canonical Event Log). The Nonce is set to the
+// verifier's nonce.
+func AttestToVerifierWithOpts(ctx context.Context, attester Attester, verifier verifierpb.VerifierClient, opts AttestOpts) (*verifierpb.VerifyAttestationResponse, error) {
+	nonceResp, err := verifier.GetNonce(ctx, &verifierpb.GetNonceRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce from verifier: %w", err)
+	}
+	opts.Nonce = nonceResp.GetNonce()
+	var attestation *pb.Attestation
+	if contextAttester, ok := attester.(ContextAttester); ok {
+		attestation, err = contextAttester.AttestContext(ctx, opts)
+	} else {
+		attestation, err = attester.Attest(opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to attest: %w", err)
+	}
+	resp, err := verifier.VerifyAttestation(ctx, &verifierpb.VerifyAttestationRequest{
+		Nonce:       nonceResp.GetNonce(),
+		Attestation: attestation,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("verifier rejected attestation: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/google/go-tpm-tools/agent"
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+)
+
+var (
+	agentConfig     string
+	agentConfigKeys []string
+	agentSerialFile string
+	agentSigningKey string
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Attest to remote verifiers at a regular interval",
+	Long: `Attest to remote verifiers at a regular interval, until interrupted.
+
+The verifiers, the CA certificates and pins their TLS certificates must match,
+and what is collected are read from the --config file, which must be signed
+by one of the --config-key keys (see "gotpm agent sign-config"). The config is
+reloaded on SIGHUP, or when the file changes, keeping the key loaded and
+letting attestations in progress finish with the previous config. A config
+which cannot be loaded is reported, and the previous config stays in use.
+
+The serial of each config loaded is written to the --serial-file, and configs
+with a lower serial are not loaded when the agent restarts, so an older config
+cannot be replayed by replacing the config file while the agent is stopped.
+
+The results of each attestation are written as messages.
+
+` + keyArgHelp + `
+
+For example:
+	gotpm agent --config /etc/gotpm/agent.conf --config-key fleet.pem --serial-file /var/lib/gotpm/agent.serial`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if agentConfig == "" {
+			return errors.New("--config is required")
+		}
+		if len(agentConfigKeys) == 0 {
+			return errors.New("--config-key is required")
+		}
+		if agentSerialFile == "" {
+			return errors.New("--serial-file is required")
+		}
+		minSerial, err := readConfigSerial(agentSerialFile)
+		if err != nil {
+			return err
+		}
+		a := &agent.Agent{ConfigPath: agentConfig, MinConfigSerial: minSerial}
+		for _, path := range agentConfigKeys {
+			key, err := readPublicKey(path)
+			if err != nil {
+				return err
+			}
+			a.ConfigKeys = append(a.ConfigKeys, key)
+		}
+
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+		key, err := loadKey(rwc, attestKey)
+		if err != nil {
+			return err
+		}
+		defer key.Close()
+		a.Attester = key
+		a.Results = func(results []agent.Result) {
+			for _, result := range results {
+				if result.Err != nil {
+					fmt.Fprintf(messageOutput(), "Attestation to %s failed: %v\n", result.Address, result.Err)
+				} else {
+					fmt.Fprintf(messageOutput(), "Attested to %s\n", result.Address)
+				}
+			}
+		}
+		a.ReloadErrors = func(err error) {
+			fmt.Fprintf(messageOutput(), "Keeping the previous config: %v\n", err)
+		}
+		a.ConfigLoaded = func(config *verifierpb.AgentConfig) {
+			if err := writeConfigSerial(agentSerialFile, config.GetSerial()); err != nil {
+				fmt.Fprintf(messageOutput(), "Failed to record the config serial: %v\n", err)
+			}
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+		go func() {
+			<-signals
+			cancel()
+		}()
+
+		fmt.Fprintf(messageOutput(), "Attesting with %s, as configured by %s\n", attestKey, agentConfig)
+		if err := a.Run(ctx); !errors.Is(err, context.Canceled) {
+			return err
+		}
+		return nil
+	},
+}
+
+var signConfigCmd = &cobra.Command{
+	Use:   "sign-config",
+	Short: "Sign an agent config",
+	Long: `Sign an AgentConfig protobuf (read in any wire format), writing a config
+file for "gotpm agent".
+
+The --signing-key is a PEM file holding a PKCS #8 ECDSA or RSA private key,
+whose public key is given to the agent with --config-key. Each config must have
+a greater serial than the last one signed, or agents already using that one
+will not load it.
+
+For example, to sign a config written as JSON:
+	gotpm agent sign-config --signing-key fleet-key.pem --input agent.json --output agent.conf`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if agentSigningKey == "" {
+			return errors.New("--signing-key is required")
+		}
+		signer, err := readSigningKey(agentSigningKey)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(dataInput())
+		if err != nil {
+			return err
+		}
+		config := &verifierpb.AgentConfig{}
+		if err := unmarshalMessage(data, config); err != nil {
+			return fmt.Errorf("reading agent config: %w", err)
+		}
+		signed, err := agent.SignConfig(config, signer)
+		if err != nil {
+			return err
+		}
+		_, err = dataOutput().Write(signed)
+		return err
+	},
+}
+
+// readSigningKey reads a PEM encoded PKCS #8 private key.
+func readSigningKey(path string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key in %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s does not hold a signing key", path)
+	}
+	return signer, nil
+}
+
+// readConfigSerial reads the serial of the last config loaded by the agent, or
+// 0 if the file does not exist yet.
+func readConfigSerial(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	serial, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid config serial in %s: %w", path, err)
+	}
+	return serial, nil
+}
+
+// writeConfigSerial records the serial of the config loaded by the agent. The
+// file is replaced atomically, so it is never left empty or truncated.
+func writeConfigSerial(path string, serial uint64) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := fmt.Fprintf(tmp, "%d\n", serial); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func init() {
+	RootCmd.AddCommand(agentCmd)
+	addPublicKeyAlgoFlag(agentCmd)
+	addRegistryFlags(agentCmd)
+	agentCmd.Flags().StringVar(&agentConfig, "config", "",
+		"path of the signed agent config file")
+	agentCmd.Flags().StringArrayVar(&agentConfigKeys, "config-key", nil,
+		"PEM file of a key trusted to sign the agent config (can be repeated)")
+	agentCmd.Flags().StringVar(&agentSerialFile, "serial-file", "",
+		"file recording the serial of the last config loaded")
+	agentCmd.Flags().StringVar(&attestKey, "key", "ak",
+		"the attesting key, given like the keys of \"gotpm certify\"")
+
+	agentCmd.AddCommand(signConfigCmd)
+	addInputFlag(signConfigCmd)
+	addOutputFlag(signConfigCmd)
+	signConfigCmd.Flags().StringVar(&agentSigningKey, "signing-key", "",
+		"PEM file of the private key signing the config")
+}
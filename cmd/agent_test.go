@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-tpm-tools/agent"
+)
+
+func TestAgentSignConfig(t *testing.T) {
+	defer func() { agentSigningKey, input, output = "", "", "" }()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := makeTempFile(t, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	defer os.Remove(keyFile)
+	configFile := makeTempFile(t, []byte(`{"verifiers": [{"address": "verifier.example.com:443"}], "collection": {"intervalSeconds": 60}}`))
+	defer os.Remove(configFile)
+	signedFile := makeTempFile(t, nil)
+	defer os.Remove(signedFile)
+
+	RootCmd.SetArgs([]string{"agent", "sign-config", "--signing-key", keyFile, "--input", configFile, "--output", signedFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatalf("gotpm agent sign-config failed: %v", err)
+	}
+	signed, err := ioutil.ReadFile(signedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := agent.ParseConfig(signed, []crypto.PublicKey{key.Public()})
+	if err != nil {
+		t.Fatalf("ParseConfig() of the signed config failed: %v", err)
+	}
+	if got := config.GetVerifiers()[0].GetAddress(); got != "verifier.example.com:443" {
+		t.Errorf("signed config has verifier %s, want verifier.example.com:443", got)
+	}
+	if got := config.GetCollection().GetIntervalSeconds(); got != 60 {
+		t.Errorf("signed config has interval %d, want 60", got)
+	}
+}
+
+func TestAgentConfigSerial(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.serial")
+	if serial, err := readConfigSerial(path); err != nil || serial != 0 {
+		t.Errorf("readConfigSerial() of a missing file = %d, %v, want 0", serial, err)
+	}
+	for _, want := range []uint64{7, 42} {
+		if err := writeConfigSerial(path, want); err != nil {
+			t.Fatal(err)
+		}
+		if serial, err := readConfigSerial(path); err != nil || serial != want {
+			t.Errorf("readConfigSerial() = %d, %v, want %d", serial, err, want)
+		}
+	}
+	if err := ioutil.WriteFile(path, []byte("not a serial\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readConfigSerial(path); err == nil {
+		t.Error("readConfigSerial() of an invalid file should fail")
+	}
+}
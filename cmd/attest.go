@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	attestKey   string
+	attestNonce []byte
+	trustedAKs  []string
+)
+
+var attestCmd = &cobra.Command{
+	Use:   "attest",
+	Short: "Attest to the state of this machine",
+	Long: `Generate an attestation of the state of this machine
+
+The attestation holds quotes of all the PCR banks, signed by the AK (or the key
+given by --key) over the --nonce, with the TCG event log and the TPM's
+capabilities. It is written as an Attestation protobuf, in the --wire-format,
+and can be checked with "gotpm verify" or server.VerifyAttestation by a
+verifier trusting the key.
+
+` + keyArgHelp + `
+
+For example, to attest with the ECC AK, writing a CBOR attestation:
+	gotpm attest --algo ecc --nonce 0123456789abcdef --wire-format cbor --output attestation.cbor`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(attestNonce) == 0 {
+			return errors.New("--nonce is required")
+		}
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+		key, err := loadKey(rwc, attestKey)
+		if err != nil {
+			return err
+		}
+		defer key.Close()
+
+		fmt.Fprintf(debugOutput(), "Attesting with %s\n", attestKey)
+		attestation, err := key.AttestContext(cmd.Context(), client.AttestOpts{Nonce: attestNonce})
+		if err != nil {
+			return err
+		}
+		output, err := marshalMessage(attestation)
+		if err != nil {
+			return err
+		}
+		_, err = dataOutput().Write(output)
+		return err
+	},
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify an attestation",
+	Long: `Verify an attestation written by "gotpm attest"
+
+The attestation (in any wire format) must be signed by one of the
+--trusted-ak keys over the --nonce, and its event log must replay to the quoted
+PCRs. The state of the machine parsed from the event log is written as a
+MachineState protobuf, in the --wire-format. The trusted keys are PEM files,
+such as those written by "gotpm pubkey ak".
+
+For example:
+	gotpm verify --nonce 0123456789abcdef --trusted-ak ak.pem --input attestation.cbor`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(attestNonce) == 0 {
+			return errors.New("--nonce is required")
+		}
+		if len(trustedAKs) == 0 {
+			return errors.New("--trusted-ak is required")
+		}
+		opts := server.VerifyOpts{Nonce: attestNonce}
+		for _, path := range trustedAKs {
+			ak, err := readPublicKey(path)
+			if err != nil {
+				return err
+			}
+			opts.TrustedAKs = append(opts.TrustedAKs, ak)
+		}
+
+		data, err := ioutil.ReadAll(dataInput())
+		if err != nil {
+			return err
+		}
+		attestation := &pb.Attestation{}
+		if err := unmarshalMessage(data, attestation); err != nil {
+			return fmt.Errorf("reading attestation: %w", err)
+		}
+		state, err := server.VerifyAttestationContext(cmd.Context(), attestation, opts)
+		if err != nil {
+			return err
+		}
+		output, err := marshalMessage(state)
+		if err != nil {
+			return err
+		}
+		_, err = dataOutput().Write(output)
+		return err
+	},
+}
+
+// readPublicKey reads a PEM encoded public key.
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key in %s: %w", path, err)
+	}
+	return pub, nil
+}
+
+func init() {
+	RootCmd.AddCommand(attestCmd)
+	addOutputFlag(attestCmd)
+	addPublicKeyAlgoFlag(attestCmd)
+	addRegistryFlags(attestCmd)
+	attestCmd.PersistentFlags().StringVar(&attestKey, "key", "ak",
+		"the attesting key, given like the keys of \"gotpm certify\"")
+	attestCmd.PersistentFlags().BytesHexVar(&attestNonce, "nonce", nil,
+		"hex-encoded nonce, chosen by the verifier")
+
+	RootCmd.AddCommand(verifyCmd)
+	addInputFlag(verifyCmd)
+	addOutputFlag(verifyCmd)
+	verifyCmd.PersistentFlags().BytesHexVar(&attestNonce, "nonce", nil,
+		"hex-encoded nonce the attestation must be signed over")
+	verifyCmd.PersistentFlags().StringArrayVar(&trustedAKs, "trusted-ak", nil,
+		"PEM file of a key trusted to sign attestations (can be repeated)")
+}
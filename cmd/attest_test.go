@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAttestVerify(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	defer func() { attestKey, attestNonce, trustedAKs, wireFormat, input, output = "ak", nil, nil, "", "", "" }()
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(ak.PublicKey())
+	ak.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	akFile := makeTempFile(t, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	defer os.Remove(akFile)
+
+	for _, format := range []string{wireText, wireJSON, wireBinary, wireCBOR} {
+		t.Run(format, func(t *testing.T) {
+			attestationFile := makeTempFile(t, nil)
+			defer os.Remove(attestationFile)
+			stateFile := makeTempFile(t, nil)
+			defer os.Remove(stateFile)
+
+			RootCmd.SetArgs([]string{"attest", "--nonce", "0123456789abcdef", "--wire-format", format, "--output", attestationFile})
+			if err := RootCmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadFile(attestationFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format == wireCBOR && !bytes.HasPrefix(data, cborMagic) {
+				t.Errorf("CBOR attestation starts with %x, want the self-described CBOR tag", data[:3])
+			}
+
+			// The attestation is read in any wire format, and the machine
+			// state is written in the default one.
+			wireFormat = ""
+			RootCmd.SetArgs([]string{"verify", "--nonce", "0123456789abcdef", "--trusted-ak", akFile, "--input", attestationFile, "--output", stateFile})
+			if err := RootCmd.Execute(); err != nil {
+				t.Fatalf("gotpm verify failed: %v", err)
+			}
+			if data, err = ioutil.ReadFile(stateFile); err != nil {
+				t.Fatal(err)
+			}
+			var state pb.MachineState
+			if err := unmarshalOptions.Unmarshal(data, &state); err != nil {
+				t.Fatalf("machine state is not a text protobuf: %v", err)
+			}
+			if state.GetPlatform() == nil {
+				t.Error("machine state is missing the platform state")
+			}
+		})
+	}
+
+	RootCmd.SetArgs([]string{"verify", "--nonce", "00", "--trusted-ak", akFile, "--input", akFile})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("gotpm verify of a file which is not an attestation should fail")
+	}
+	RootCmd.SetArgs([]string{"attest", "--nonce", "00", "--wire-format", "yaml"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("gotpm attest --wire-format yaml should fail")
+	}
+}
+
+func TestWireFormats(t *testing.T) {
+	defer func() { wireFormat = "" }()
+	state := &pb.MachineState{
+		AkName:    []byte{0, 0xb, 1, 2},
+		ClockInfo: &pb.ClockInfo{Clock: 1 << 40, ResetCount: 3, Safe: true},
+		Platform:  &pb.PlatformState{Firmware: &pb.PlatformState_ScrtmVersionId{ScrtmVersionId: []byte{1}}},
+		SecureBoot: &pb.SecureBootState{
+			Enabled: true,
+			Db:      &pb.Database{Hashes: [][]byte{{1}, {2}}},
+		},
+		TpmClear: &pb.TpmClearStatus{Verdict: pb.TpmClearVerdict_TPM_CLEARED, Indicators: []string{"SRK changed"}},
+	}
+	for _, format := range []string{wireText, wireJSON, wireBinary, wireCBOR} {
+		t.Run(format, func(t *testing.T) {
+			wireFormat = format
+			data, err := marshalMessage(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got pb.MachineState
+			if err := unmarshalMessage(data, &got); err != nil {
+				t.Fatalf("unmarshalMessage() failed: %v", err)
+			}
+			if !proto.Equal(&got, state) {
+				t.Errorf("unmarshalMessage() = %v, want %v", &got, state)
+			}
+		})
+	}
+
+	// CBOR protobufs can be edited, and typos are errors.
+	wireFormat = wireCBOR
+	data, err := marshalMessage(&pb.ClockInfo{Clock: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unmarshalMessage(bytes.Replace(data, []byte("clock"), []byte("clokc"), 1), &pb.ClockInfo{}); err == nil {
+		t.Error("unmarshalMessage() of CBOR with an unknown field should fail")
+	}
+}
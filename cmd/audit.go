@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/go-tpm-tools/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditAnchor string
+	auditHead   string
+	auditFrom   uint64
+	auditTo     uint64
+	auditJSON   bool
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check and export verifier audit logs",
+	Long: `Check and export the hash-chained audit logs written by a verifier (see
+server.AuditLog)
+
+Each record of an audit log holds one verification decision, and the hash of
+the record before it, so that records cannot be modified, removed or reordered
+without breaking the chain.`,
+	Args: cobra.NoArgs,
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify <log>",
+	Short: "Verify the hash chain of an audit log",
+	Long: `Verify the hash chain of an audit log, and print its head (the number of
+records and the hash of the last record)
+
+A part of a log, as written by "gotpm audit export", is verified with the hash
+of the record before it (--anchor). To detect records being removed from the
+end of the log, pass a head hash published earlier (--head), which must be the
+hash of one of the records.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		records, err := readAuditLog(args[0])
+		if err != nil {
+			return err
+		}
+		if auditHead != "" {
+			head, err := hex.DecodeString(auditHead)
+			if err != nil {
+				return fmt.Errorf("invalid --head: %w", err)
+			}
+			if !containsAuditHash(records, head) {
+				return errors.New("the log does not contain the record with the --head hash")
+			}
+		}
+		if len(records) == 0 {
+			return reportResult(auditReport{}, "Audit log is empty\n")
+		}
+		first, last := records[0], records[len(records)-1]
+		report := auditReport{Records: len(records), First: &first.Sequence, Last: &last.Sequence, Head: last.Hash}
+		return reportResult(report, "Audit log verified: records %d to %d, head %x\n",
+			first.Sequence, last.Sequence, last.Hash)
+	},
+}
+
+var auditExportCmd = &cobra.Command{
+	Use:   "export <log>",
+	Short: "Export verified records from an audit log",
+	Long: `Export the records from --from to --to (inclusive) of an audit log, after
+verifying the whole log
+
+The records are written unchanged, so the export can itself be checked with
+"gotpm audit verify --anchor", using the hash of the record before --from
+(which is printed). With --json (or --format=json), the records are instead
+written as a single JSON array, for reading by other tools.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if auditTo < auditFrom {
+			return errors.New("--to must not be less than --from")
+		}
+		records, err := readAuditLog(args[0])
+		if err != nil {
+			return err
+		}
+		var exported []*server.AuditRecord
+		var anchor []byte
+		for _, record := range records {
+			if record.Sequence < auditFrom {
+				anchor = record.Hash
+			} else if record.Sequence <= auditTo {
+				exported = append(exported, record)
+			}
+		}
+		if len(exported) == 0 {
+			return fmt.Errorf("the log has no records from %d to %d", auditFrom, auditTo)
+		}
+
+		out := dataOutput()
+		if auditJSON || jsonOutput() {
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(exported); err != nil {
+				return err
+			}
+		} else {
+			encoder := json.NewEncoder(out)
+			encoder.SetEscapeHTML(false)
+			for _, record := range exported {
+				if err := encoder.Encode(record); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Fprintf(messageOutput(), "Exported records %d to %d, anchor %x\n",
+			exported[0].Sequence, exported[len(exported)-1].Sequence, anchor)
+		return nil
+	},
+}
+
+// auditReport is the JSON output of "gotpm audit verify": the number of
+// records, the sequence numbers of the first and last records, and the hash of
+// the last record (the head).
+type auditReport struct {
+	Records int      `json:"records"`
+	First   *uint64  `json:"first,omitempty"`
+	Last    *uint64  `json:"last,omitempty"`
+	Head    hexBytes `json:"head,omitempty"`
+}
+
+func readAuditLog(path string) ([]*server.AuditRecord, error) {
+	var anchor []byte
+	if auditAnchor != "" {
+		var err error
+		if anchor, err = hex.DecodeString(auditAnchor); err != nil {
+			return nil, fmt.Errorf("invalid --anchor: %w", err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return server.ReadAuditLog(f, anchor)
+}
+
+func containsAuditHash(records []*server.AuditRecord, hash []byte) bool {
+	for _, record := range records {
+		if bytes.Equal(record.Hash, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	RootCmd.AddCommand(auditCmd)
+	hideHelp(auditCmd)
+	auditCmd.AddCommand(auditVerifyCmd)
+	auditCmd.AddCommand(auditExportCmd)
+	auditCmd.PersistentFlags().StringVar(&auditAnchor, "anchor", "",
+		"hex hash of the record before the first record, for a part of a log")
+	auditVerifyCmd.PersistentFlags().StringVar(&auditHead, "head", "",
+		"hex hash of a previously published record, which must be in the log")
+	auditExportCmd.PersistentFlags().Uint64Var(&auditFrom, "from", 0, "sequence number of the first record to export")
+	auditExportCmd.PersistentFlags().Uint64Var(&auditTo, "to", ^uint64(0), "sequence number of the last record to export")
+	auditExportCmd.PersistentFlags().BoolVar(&auditJSON, "json", false, "write the records as a JSON array")
+	addOutputFlag(auditExportCmd)
+}
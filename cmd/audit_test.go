@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/server"
+)
+
+func TestAudit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	log, err := server.OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashes [][]byte
+	for i := 0; i < 3; i++ {
+		record, err := log.Append(&pb.Attestation{}, server.VerifyOpts{Nonce: []byte{byte(i)}}, "v1", &pb.MachineState{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, record.Hash)
+	}
+	log.Close()
+	exported := filepath.Join(dir, "exported.log")
+
+	run := func(args ...string) error {
+		RootCmd.SetArgs(append(args, "--quiet"))
+		defer func() {
+			auditAnchor, auditHead, auditFrom, auditTo, output = "", "", 0, ^uint64(0), ""
+		}()
+		return RootCmd.Execute()
+	}
+	if err := run("audit", "verify", path, "--head", hex.EncodeToString(hashes[1])); err != nil {
+		t.Errorf("verifying the log failed: %v", err)
+	}
+	if err := run("audit", "verify", path, "--head", hex.EncodeToString(make([]byte, 32))); err == nil {
+		t.Error("verifying the log with an unknown head should fail")
+	}
+	if err := run("audit", "export", path, "--from", "1", "--to", "1", "--output", exported); err != nil {
+		t.Fatalf("exporting the log failed: %v", err)
+	}
+	if err := run("audit", "verify", exported, "--anchor", hex.EncodeToString(hashes[0])); err != nil {
+		t.Errorf("verifying the exported records failed: %v", err)
+	}
+	if err := run("audit", "verify", exported); err == nil {
+		t.Error("verifying part of a log without an anchor should fail")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := filepath.Join(dir, "tampered.log")
+	if err := ioutil.WriteFile(tampered, bytes.Replace(data, []byte(`"v1"`), []byte(`"v0"`), 1), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("audit", "verify", tampered); err == nil {
+		t.Error("verifying a tampered log should fail")
+	}
+}
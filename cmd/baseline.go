@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm-tools/server"
+	"github.com/spf13/cobra"
+)
+
+// Values of the baseline cluster --input-type flag.
+const (
+	inputAttestation  = "attestation"
+	inputMachineState = "machine-state"
+)
+
+var (
+	clusterInputType    = inputAttestation
+	clusterMaxAnomalies int
+)
+
+var baselineCmd = &cobra.Command{
+	Use:   "baseline",
+	Short: "Analyze event logs to build baselines",
+	Long: `Analyze the event logs of machines, to build the baselines used by
+server.Baseline to classify event log changes`,
+	Args: cobra.NoArgs,
+}
+
+var baselineClusterCmd = &cobra.Command{
+	Use:   "cluster <file>...",
+	Short: "Propose golden baselines for a fleet of machines",
+	Long: `Cluster a fleet's machines by their event logs, and propose golden machines
+whose baselines cover the fleet (see server.ClusterFleet)
+
+Each file holds the Attestation of one machine, or with
+--input-type=machine-state, its MachineState, as a binary, text or JSON
+protobuf. An attestation's event log is replayed against the PCRs of its
+SHA-256 quote (or its first quote), without checking the quote's signature:
+this is for analysis only, and the attestations of golden machines must still
+be verified before their baselines are trusted.
+
+Machines with the same events are clustered together. With --max-anomalies, a
+machine also joins the cluster of a golden machine when it has at most that
+many events not in the golden machine's baseline, so fewer baselines are
+proposed. For each proposed baseline, the golden machine's file is listed,
+followed by the files of its members and their number of anomalous events.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if clusterInputType != inputAttestation && clusterInputType != inputMachineState {
+			return fmt.Errorf("invalid --input-type %q", clusterInputType)
+		}
+		machines := make([]server.FleetMachine, len(args))
+		for i, path := range args {
+			state, err := readFleetMachine(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			machines[i] = server.FleetMachine{Name: path, State: state}
+		}
+
+		clusters, err := server.ClusterFleet(machines, server.ClusterOpts{MaxAnomalies: clusterMaxAnomalies})
+		if err != nil {
+			return err
+		}
+		report := make([]clusterReport, len(clusters))
+		for i, cluster := range clusters {
+			report[i] = clusterReport{Golden: cluster.Golden.Name, Members: []clusterMemberReport{}}
+			for _, member := range cluster.Members {
+				report[i].Members = append(report[i].Members, clusterMemberReport{member.Name, member.Anomalies})
+			}
+		}
+		if jsonOutput() {
+			return writeJSON(dataOutput(), report)
+		}
+		return writeClusters(dataOutput(), report)
+	},
+}
+
+// clusterReport is a proposed baseline in the output of "gotpm baseline
+// cluster".
+type clusterReport struct {
+	Golden  string                `json:"golden"`
+	Members []clusterMemberReport `json:"members"`
+}
+
+type clusterMemberReport struct {
+	Name      string `json:"name"`
+	Anomalies int    `json:"anomalies"`
+}
+
+func writeClusters(w io.Writer, clusters []clusterReport) error {
+	for i, cluster := range clusters {
+		fmt.Fprintf(w, "Baseline %d: %s (%d machines)\n", i+1, cluster.Golden, len(cluster.Members))
+		for _, member := range cluster.Members {
+			if _, err := fmt.Fprintf(w, "\t%s\t%d anomalies\n", member.Name, member.Anomalies); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readFleetMachine reads the MachineState of a machine from a file holding its
+// Attestation or MachineState, depending on --input-type.
+func readFleetMachine(path string) (*pb.MachineState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if clusterInputType == inputMachineState {
+		state := &pb.MachineState{}
+		if err := unmarshalMessage(data, state); err != nil {
+			return nil, err
+		}
+		return state, nil
+	}
+
+	attestation := &pb.Attestation{}
+	if err := unmarshalMessage(data, attestation); err != nil {
+		return nil, err
+	}
+	var pcrs *tpmpb.PCRs
+	for _, quote := range attestation.GetQuotes() {
+		if pcrs == nil || quote.GetPcrs().GetHash() == tpmpb.HashAlgo_SHA256 {
+			pcrs = quote.GetPcrs()
+		}
+	}
+	if pcrs == nil {
+		return nil, errors.New("attestation has no quotes")
+	}
+	return server.ParseMachineState(attestation.GetEventLog(), pcrs)
+}
+
+func init() {
+	RootCmd.AddCommand(baselineCmd)
+	baselineCmd.AddCommand(baselineClusterCmd)
+	baselineClusterCmd.Flags().StringVar(&clusterInputType, "input-type", inputAttestation,
+		"the type of the input files: attestation or machine-state")
+	baselineClusterCmd.Flags().IntVar(&clusterMaxAnomalies, "max-anomalies", 0,
+		"the number of anomalous events a machine may have in a cluster")
+	baselineClusterCmd.RegisterFlagCompletionFunc("input-type", completeValues(inputAttestation, inputMachineState))
+	addOutputFlag(baselineClusterCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm-tools/server"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBaselineCluster(t *testing.T) {
+	defer func() { clusterInputType, clusterMaxAnomalies = inputAttestation, 0 }()
+
+	attestationFile := func(platform test.Platform, marshal func(proto.Message) ([]byte, error)) string {
+		attestation := &pb.Attestation{
+			EventLog: platform.RawLog,
+			Quotes:   []*tpmpb.Quote{{Pcrs: platform.Banks[0]}},
+		}
+		data, err := marshal(attestation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return makeTempFile(t, data)
+	}
+	debian1 := attestationFile(test.Debian10GCE, proto.Marshal)
+	defer os.Remove(debian1)
+	rhel := attestationFile(test.Rhel8GCE, marshalOptions.Marshal)
+	defer os.Remove(rhel)
+	debian2 := attestationFile(test.Debian10GCE, protojson.Marshal)
+	defer os.Remove(debian2)
+
+	var report []clusterReport
+	runJSON(t, &report, "baseline", "cluster", debian1, rhel, debian2)
+	if len(report) != 2 {
+		t.Fatalf("got %d clusters, want 2: %+v", len(report), report)
+	}
+	if report[0].Golden != debian1 || len(report[0].Members) != 2 || report[0].Members[1].Name != debian2 {
+		t.Errorf("got first cluster %+v, want %s and %s", report[0], debian1, debian2)
+	}
+	if report[1].Golden != rhel || len(report[1].Members) != 1 {
+		t.Errorf("got second cluster %+v, want %s", report[1], rhel)
+	}
+
+	// A machine with a changed event joins the cluster with --max-anomalies.
+	state, err := server.ParseMachineState(test.Debian10GCE.RawLog, test.Debian10GCE.Banks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proto.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := makeTempFile(t, data)
+	defer os.Remove(golden)
+	for _, event := range state.GetRawEvents() {
+		if event.GetPcrIndex() == 4 && event.GetUntrustedType() == server.Separator {
+			event.Digest = make([]byte, len(event.GetDigest()))
+		}
+	}
+	if data, err = proto.Marshal(state); err != nil {
+		t.Fatal(err)
+	}
+	changed := makeTempFile(t, data)
+	defer os.Remove(changed)
+
+	runJSON(t, &report, "baseline", "cluster", "--input-type", inputMachineState, golden, changed)
+	if len(report) != 2 {
+		t.Errorf("got %d clusters without --max-anomalies, want 2: %+v", len(report), report)
+	}
+	runJSON(t, &report, "baseline", "cluster", "--input-type", inputMachineState, "--max-anomalies", "1", golden, changed)
+	if len(report) != 1 || len(report[0].Members) != 2 || report[0].Members[1].Anomalies != 1 {
+		t.Errorf("got clusters %+v with --max-anomalies=1, want one cluster with one anomaly", report)
+	}
+}
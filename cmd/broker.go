@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	"github.com/google/go-tpm-tools/broker"
+	brokerpb "github.com/google/go-tpm-tools/proto/broker"
+)
+
+var brokerSocket string
+
+var brokerCmd = &cobra.Command{
+	Use:   "broker",
+	Short: "Attest on behalf of unprivileged clients, such as containers",
+	Long: `Serve the TPM broker on a Unix socket, until interrupted.
+
+The broker lets clients without access to the TPM device (such as containers
+under a strict seccomp profile) attest and quote with the TPM's AK, which is
+all they can do with it. Clients using the broker package find the broker
+through its socket, which must be mounted into containers at the same path,
+or named by the GOTPM_BROKER environment variable.
+
+The socket is only accessible to the broker's user and group.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+		ak, err := broker.AttestationKey(rwc)
+		if err != nil {
+			return fmt.Errorf("failed to load AK: %w", err)
+		}
+		defer ak.Close()
+
+		// Remove the socket left by an earlier broker, if any.
+		if err := os.Remove(brokerSocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		lis, err := net.Listen("unix", brokerSocket)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(brokerSocket)
+		if err := os.Chmod(brokerSocket, 0660); err != nil {
+			lis.Close()
+			return err
+		}
+
+		grpcServer := grpc.NewServer()
+		brokerpb.RegisterBrokerServer(grpcServer, broker.NewService(ak))
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+		go func() {
+			<-signals
+			grpcServer.GracefulStop()
+		}()
+
+		fmt.Fprintf(messageOutput(), "Serving the TPM broker on %s\n", brokerSocket)
+		return grpcServer.Serve(lis)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(brokerCmd)
+	brokerCmd.Flags().StringVar(&brokerSocket, "socket", broker.DefaultSocket, "path of the Unix socket to listen on")
+}
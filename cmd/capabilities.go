@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-attestation/attest"
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/spf13/cobra"
+)
+
+var capabilitiesCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "Report the TPM's properties and supported algorithms",
+	Long: `Report the TPM's capabilities, from TPM2_GetCapability
+
+The TPM's manufacturer, vendor string, firmware version and specification
+revision are reported, along with the algorithms it implements, its active PCR
+banks, and the handles of its persistent objects and NV indexes. Attestations
+record the same capabilities.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		caps, err := client.GetCapabilities(rwc)
+		if err != nil {
+			return err
+		}
+		report := newCapabilitiesReport(caps)
+		if jsonOutput() {
+			return writeJSON(dataOutput(), report)
+		}
+		return report.writeText(dataOutput())
+	},
+}
+
+// capabilitiesReport is the output of "gotpm capabilities".
+type capabilitiesReport struct {
+	ManufacturerID    jsonHandle   `json:"manufacturer_id"`
+	Manufacturer      string       `json:"manufacturer,omitempty"`
+	VendorString      string       `json:"vendor_string"`
+	FirmwareVersion   string       `json:"firmware_version"`
+	SpecRevision      string       `json:"spec_revision"`
+	Algorithms        []string     `json:"algorithms"`
+	PCRBanks          []string     `json:"pcr_banks"`
+	PersistentHandles []jsonHandle `json:"persistent_handles"`
+	NVIndexes         []jsonHandle `json:"nv_indexes"`
+}
+
+func newCapabilitiesReport(caps *client.Capabilities) *capabilitiesReport {
+	report := &capabilitiesReport{
+		ManufacturerID:    jsonHandle(caps.ManufacturerID),
+		Manufacturer:      attest.TCGVendorID(caps.ManufacturerID).String(),
+		VendorString:      caps.VendorString,
+		FirmwareVersion:   fmt.Sprintf("%08x.%08x", caps.FirmwareVersion>>32, uint32(caps.FirmwareVersion)),
+		SpecRevision:      fmt.Sprintf("%d.%02d", caps.SpecRevision/100, caps.SpecRevision%100),
+		Algorithms:        []string{},
+		PCRBanks:          []string{},
+		PersistentHandles: []jsonHandle{},
+		NVIndexes:         []jsonHandle{},
+	}
+	for _, alg := range caps.Algorithms {
+		report.Algorithms = append(report.Algorithms, algorithmName(alg.ID))
+	}
+	for _, bank := range caps.PCRBanks {
+		if len(bank.PCRs) > 0 {
+			report.PCRBanks = append(report.PCRBanks, algorithmName(bank.Hash))
+		}
+	}
+	for _, handle := range caps.PersistentHandles {
+		report.PersistentHandles = append(report.PersistentHandles, jsonHandle(handle))
+	}
+	for _, index := range caps.NVIndexes {
+		report.NVIndexes = append(report.NVIndexes, jsonHandle(index))
+	}
+	return report
+}
+
+func (r *capabilitiesReport) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "Manufacturer: %s (0x%08x)\n", r.Manufacturer, uint32(r.ManufacturerID))
+	fmt.Fprintf(w, "Vendor:       %q\n", r.VendorString)
+	fmt.Fprintf(w, "Firmware:     %s\n", r.FirmwareVersion)
+	fmt.Fprintf(w, "Revision:     %s\n", r.SpecRevision)
+	fmt.Fprintf(w, "Algorithms:   %s\n", strings.Join(r.Algorithms, ", "))
+	fmt.Fprintf(w, "PCR banks:    %s\n", strings.Join(r.PCRBanks, ", "))
+	fmt.Fprintf(w, "Persistent:   %s\n", handleList(r.PersistentHandles))
+	_, err := fmt.Fprintf(w, "NV indexes:   %s\n", handleList(r.NVIndexes))
+	return err
+}
+
+func handleList(handles []jsonHandle) string {
+	if len(handles) == 0 {
+		return "none"
+	}
+	formatted := make([]string, len(handles))
+	for i, handle := range handles {
+		formatted[i] = fmt.Sprintf("0x%08x", uint32(handle))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// algorithmNames are the names of TPM_ALG_ID values, from Part 2 of the spec,
+// Table 9.
+var algorithmNames = map[tpm2.Algorithm]string{
+	0x0001: "rsa",
+	0x0003: "tdes",
+	0x0004: "sha1",
+	0x0005: "hmac",
+	0x0006: "aes",
+	0x0007: "mgf1",
+	0x0008: "keyedhash",
+	0x000A: "xor",
+	0x000B: "sha256",
+	0x000C: "sha384",
+	0x000D: "sha512",
+	0x0010: "null",
+	0x0012: "sm3_256",
+	0x0013: "sm4",
+	0x0014: "rsassa",
+	0x0015: "rsaes",
+	0x0016: "rsapss",
+	0x0017: "oaep",
+	0x0018: "ecdsa",
+	0x0019: "ecdh",
+	0x001A: "ecdaa",
+	0x001B: "sm2",
+	0x001C: "ecschnorr",
+	0x001D: "ecmqv",
+	0x0020: "kdf1_sp800_56a",
+	0x0021: "kdf2",
+	0x0022: "kdf1_sp800_108",
+	0x0023: "ecc",
+	0x0025: "symcipher",
+	0x0026: "camellia",
+	0x0027: "sha3_256",
+	0x0028: "sha3_384",
+	0x0029: "sha3_512",
+	0x0040: "ctr",
+	0x0041: "ofb",
+	0x0042: "cbc",
+	0x0043: "cfb",
+	0x0044: "ecb",
+}
+
+func algorithmName(alg tpm2.Algorithm) string {
+	if name, ok := algorithmNames[alg]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", uint16(alg))
+}
+
+func init() {
+	RootCmd.AddCommand(capabilitiesCmd)
+	addOutputFlag(capabilitiesCmd)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+func TestCapabilities(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+
+	var report struct {
+		Manufacturer string   `json:"manufacturer"`
+		SpecRevision string   `json:"spec_revision"`
+		PCRBanks     []string `json:"pcr_banks"`
+		NVIndexes    []string `json:"nv_indexes"`
+	}
+	runJSON(t, &report, "capabilities")
+	caps, err := client.GetCapabilities(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Manufacturer == "" || report.SpecRevision == "" || len(report.NVIndexes) != len(caps.NVIndexes) {
+		t.Errorf("got capabilities report %+v, want the TPM's capabilities %+v", report, caps)
+	}
+	if len(report.PCRBanks) != len(caps.Proto().GetPcrBanks()) {
+		t.Errorf("got PCR banks %v, want %v", report.PCRBanks, caps.Proto().GetPcrBanks())
+	}
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// cborMagic is the self-described CBOR tag (RFC 8949, Section 3.4.6), which
+// starts each CBOR protobuf written by gotpm, so it can be told apart from a
+// binary protobuf.
+var cborMagic = []byte{0xd9, 0xd9, 0xf7}
+
+const cborSelfDescribeTag = 55799
+
+// marshalCBOR encodes a protobuf as a CBOR map from the .proto field names to
+// the field values, like the protobuf JSON encoding, except that bytes fields
+// are CBOR byte strings, and 64-bit integers are CBOR integers. Enums are
+// encoded by name. Unset fields are omitted.
+func marshalCBOR(m proto.Message) ([]byte, error) {
+	encMode, err := cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		return nil, err
+	}
+	return encMode.Marshal(cbor.Tag{Number: cborSelfDescribeTag, Content: messageToCBOR(m.ProtoReflect())})
+}
+
+func messageToCBOR(m protoreflect.Message) map[string]interface{} {
+	fields := map[string]interface{}{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			list := v.List()
+			values := make([]interface{}, list.Len())
+			for i := range values {
+				values[i] = valueToCBOR(fd, list.Get(i))
+			}
+			fields[string(fd.Name())] = values
+		case fd.IsMap():
+			entries := map[interface{}]interface{}{}
+			v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				entries[k.Interface()] = valueToCBOR(fd.MapValue(), v)
+				return true
+			})
+			fields[string(fd.Name())] = entries
+		default:
+			fields[string(fd.Name())] = valueToCBOR(fd, v)
+		}
+		return true
+	})
+	return fields
+}
+
+func valueToCBOR(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageToCBOR(v.Message())
+	case protoreflect.EnumKind:
+		if value := fd.Enum().Values().ByNumber(v.Enum()); value != nil {
+			return string(value.Name())
+		}
+		return int64(v.Enum())
+	default:
+		return v.Interface()
+	}
+}
+
+// unmarshalCBOR decodes a protobuf encoded by marshalCBOR.
+func unmarshalCBOR(data []byte, m proto.Message) error {
+	var fields map[string]interface{}
+	if err := cbor.Unmarshal(bytes.TrimPrefix(data, cborMagic), &fields); err != nil {
+		return fmt.Errorf("invalid CBOR protobuf: %w", err)
+	}
+	proto.Reset(m)
+	return cborToMessage(fields, m.ProtoReflect())
+}
+
+func cborToMessage(fields map[string]interface{}, m protoreflect.Message) error {
+	descriptor := m.Descriptor()
+	for name, value := range fields {
+		fd := descriptor.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return fmt.Errorf("%s has no field %q", descriptor.FullName(), name)
+		}
+		if err := setCBORField(m, fd, value); err != nil {
+			return fmt.Errorf("%s: %w", fd.FullName(), err)
+		}
+	}
+	return nil
+}
+
+func setCBORField(m protoreflect.Message, fd protoreflect.FieldDescriptor, value interface{}) error {
+	switch {
+	case fd.IsList():
+		values, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("got %T, want an array", value)
+		}
+		list := m.Mutable(fd).List()
+		for _, element := range values {
+			v, err := cborToValue(fd, element, list.NewElement)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+	case fd.IsMap():
+		entries, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return fmt.Errorf("got %T, want a map", value)
+		}
+		protoMap := m.Mutable(fd).Map()
+		for k, element := range entries {
+			key, err := cborToValue(fd.MapKey(), k, nil)
+			if err != nil {
+				return err
+			}
+			v, err := cborToValue(fd.MapValue(), element, protoMap.NewValue)
+			if err != nil {
+				return err
+			}
+			protoMap.Set(key.MapKey(), v)
+		}
+	default:
+		v, err := cborToValue(fd, value, func() protoreflect.Value { return m.NewField(fd) })
+		if err != nil {
+			return err
+		}
+		m.Set(fd, v)
+	}
+	return nil
+}
+
+// cborToValue converts a decoded CBOR value to a value of a field (or of an
+// element of a repeated or map field), using newMessage for message values.
+func cborToValue(fd protoreflect.FieldDescriptor, value interface{}, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		fields, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("got %T, want a map", value)
+		}
+		message := newMessage()
+		stringFields := map[string]interface{}{}
+		for k, v := range fields {
+			name, ok := k.(string)
+			if !ok {
+				return protoreflect.Value{}, fmt.Errorf("got field name of type %T, want a string", k)
+			}
+			stringFields[name] = v
+		}
+		return message, cborToMessage(stringFields, message.Message())
+	case protoreflect.EnumKind:
+		if name, ok := value.(string); ok {
+			enumValue := fd.Enum().Values().ByName(protoreflect.Name(name))
+			if enumValue == nil {
+				return protoreflect.Value{}, fmt.Errorf("unknown %s value %q", fd.Enum().FullName(), name)
+			}
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		n, err := cborInt(value, math.MinInt32, math.MaxInt32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), err
+	case protoreflect.BoolKind:
+		b, ok := value.(bool)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("got %T, want a bool", value)
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.StringKind:
+		s, ok := value.(string)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("got %T, want a text string", value)
+		}
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		b, ok := value.([]byte)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("got %T, want a byte string", value)
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := cborInt(value, math.MinInt32, math.MaxInt32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := cborInt(value, math.MinInt64, math.MaxInt64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := cborUint(value, math.MaxUint32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := cborUint(value, math.MaxUint64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		f, ok := value.(float64)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("got %T, want a float", value)
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, ok := value.(float64)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("got %T, want a float", value)
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field kind %v", fd.Kind())
+}
+
+// cborInt returns a decoded CBOR integer, which must be in [min, max].
+func cborInt(value interface{}, min, max int64) (int64, error) {
+	switch n := value.(type) {
+	case uint64:
+		if n <= uint64(max) {
+			return int64(n), nil
+		}
+	case int64:
+		if n >= min && n <= max {
+			return n, nil
+		}
+	default:
+		return 0, fmt.Errorf("got %T, want an integer", value)
+	}
+	return 0, fmt.Errorf("integer %v is out of range", value)
+}
+
+// cborUint returns a decoded CBOR unsigned integer, which must be at most max.
+func cborUint(value interface{}, max uint64) (uint64, error) {
+	n, ok := value.(uint64)
+	if !ok {
+		return 0, fmt.Errorf("got %T, want an unsigned integer", value)
+	}
+	if n > max {
+		return 0, fmt.Errorf("integer %d is out of range", n)
+	}
+	return n, nil
+}
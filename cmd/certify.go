@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	certifySigner string
+	certifyNonce  []byte
+)
+
+var certifyCmd = &cobra.Command{
+	Use:   "certify <key>",
+	Short: "Certify that a key is in the TPM, by signing it with another key",
+	Long: `Certify one key with another key in the same TPM
+
+The TPM signs a TPMS_ATTEST structure holding the name of the certified key
+with the certifying key (--signer, by default the AK), so that a verifier
+trusting the certifying key knows that the certified key is in the same TPM.
+The certifying key must be a restricted signing key, such as an AK. The
+optional --nonce (hex-encoded) is included in the signed structure.
+
+The certification is written as a KeyCertification text protobuf, holding the
+TPMS_ATTEST structure (certify_info), its TPMT_SIGNATURE (raw_sig), and the
+certified key's TPMT_PUBLIC public area (public_area), or with --format=json
+as a JSON protobuf (see --wire-format for other encodings). It can be verified with server.VerifyKeyCertification, or
+server.VerifyDevIDCertification for DevID keys.
+
+` + keyArgHelp + `
+
+For example, to certify the LDevID key with the ECC AK:
+	gotpm certify ldevid --algo ecc --nonce 0123456789abcdef`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		key, err := loadKey(rwc, args[0])
+		if err != nil {
+			return err
+		}
+		defer key.Close()
+		signer, err := loadKey(rwc, certifySigner)
+		if err != nil {
+			return err
+		}
+		defer signer.Close()
+
+		fmt.Fprintf(debugOutput(), "Certifying %s with %s\n", args[0], certifySigner)
+		certification, err := signer.Certify(key, certifyNonce)
+		if err != nil {
+			return err
+		}
+		output, err := marshalMessage(certification)
+		if err != nil {
+			return err
+		}
+		_, err = dataOutput().Write(output)
+		return err
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(certifyCmd)
+	addOutputFlag(certifyCmd)
+	addPublicKeyAlgoFlag(certifyCmd)
+	addRegistryFlags(certifyCmd)
+	certifyCmd.PersistentFlags().StringVar(&certifySigner, "signer", "ak",
+		"the certifying key, given like the certified key")
+	certifyCmd.PersistentFlags().BytesHexVar(&certifyNonce, "nonce", nil,
+		"hex-encoded data to include in the certification, usually a nonce")
+}
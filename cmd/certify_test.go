@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm-tools/server"
+	"github.com/google/go-tpm/tpm2"
+)
+
+func TestPubkey(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	defer func() { pubkeyKeyFormat, keyAlgo = "pem", tpm2.AlgRSA }()
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	wantDER, err := x509.MarshalPKIXPublicKey(ak.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPublic, err := ak.PublicArea().Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subtests := []struct {
+		name   string
+		key    string
+		format string
+	}{
+		{"PEM", "ak", "pem"},
+		{"DER", "ak", "der"},
+		{"PublicArea", "ak", "tpm"},
+		{"Handle", "0x81008f00", "der"},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			outFile := makeTempFile(t, nil)
+			defer os.Remove(outFile)
+			RootCmd.SetArgs([]string{"pubkey", subtest.key, "--algo", "ecc", "--key-format", subtest.format, "--output", outFile})
+			if err := RootCmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			out, err := ioutil.ReadFile(outFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := wantDER
+			switch subtest.format {
+			case "pem":
+				block, _ := pem.Decode(out)
+				if block == nil || block.Type != "PUBLIC KEY" {
+					t.Fatalf("output is not a PEM public key: %q", out)
+				}
+				out = block.Bytes
+			case "tpm":
+				want = wantPublic
+			}
+			if !bytes.Equal(out, want) {
+				t.Errorf("got key %x, want %x", out, want)
+			}
+		})
+	}
+
+	RootCmd.SetArgs([]string{"pubkey", "ak", "--key-format", "jwk"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("pubkey with an unknown format should fail")
+	}
+	pubkeyKeyFormat = "pem"
+	RootCmd.SetArgs([]string{"pubkey", "not-a-key"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("pubkey of an unknown key should fail")
+	}
+}
+
+func TestPubkeyEKRange(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	defer func() { pubkeyKeyFormat, keyAlgo, ekRange = "pem", tpm2.AlgRSA, "low" }()
+
+	for _, r := range []client.EKTemplateRange{client.EKLowRange, client.EKHighRange} {
+		ek, err := client.EndorsementKey(rwc, tpm2.AlgECC, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := x509.MarshalPKIXPublicKey(ek.PublicKey())
+		ek.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		outFile := makeTempFile(t, nil)
+		defer os.Remove(outFile)
+		RootCmd.SetArgs([]string{"pubkey", "endorsement", "--algo", "ecc", "--ek-range", r.String(), "--key-format", "der", "--output", outFile})
+		if err := RootCmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadFile(outFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, want) {
+			t.Errorf("got %v range EK %x, want %x", r, out, want)
+		}
+	}
+
+	RootCmd.SetArgs([]string{"pubkey", "endorsement", "--ek-range", "middle"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("pubkey with an unknown EK range should fail")
+	}
+}
+
+func TestCertify(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	defer func() { certifySigner, certifyNonce, keyAlgo = "ak", nil, tpm2.AlgRSA }()
+
+	for _, algo := range []string{"rsa", "ecc"} {
+		t.Run(algo, func(t *testing.T) {
+			outFile := makeTempFile(t, nil)
+			defer os.Remove(outFile)
+			RootCmd.SetArgs([]string{"certify", "ldevid", "--algo", algo, "--nonce", "0102abcd", "--output", outFile})
+			if err := RootCmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadFile(outFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var certification pb.KeyCertification
+			if err := unmarshalOptions.Unmarshal(data, &certification); err != nil {
+				t.Fatal(err)
+			}
+
+			var ak, devID *client.Key
+			if algo == "rsa" {
+				ak, err = client.AttestationKeyRSA(rwc)
+			} else {
+				ak, err = client.AttestationKeyECC(rwc)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ak.Close()
+			if algo == "rsa" {
+				devID, err = client.LDevIDKeyRSA(rwc)
+			} else {
+				devID, err = client.LDevIDKeyECC(rwc)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer devID.Close()
+
+			pub, err := server.VerifyDevIDCertification(&certification, ak.PublicKey(), []byte{1, 2, 0xab, 0xcd})
+			if err != nil {
+				t.Fatalf("VerifyDevIDCertification() failed: %v", err)
+			}
+			if !devID.PublicKey().(interface{ Equal(crypto.PublicKey) bool }).Equal(pub) {
+				t.Error("certified key is not the LDevID key")
+			}
+		})
+	}
+
+	// Only restricted keys can certify.
+	RootCmd.SetArgs([]string{"certify", "ak", "--signer", "ldevid"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("certifying with an unrestricted key should fail")
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion <bash | zsh | fish | powershell>",
+	Short: "Write a shell completion script",
+	Long: `Write a shell completion script for gotpm
+
+The script completes gotpm's commands and flags, along with the values of
+flags such as --algo, --hash-algo, --format and --key-format. To enable it:
+	bash       - source <(gotpm completion bash)
+	zsh        - gotpm completion zsh > "${fpath[1]}/_gotpm"
+	fish       - gotpm completion fish > ~/.config/fish/completions/gotpm.fish
+	powershell - gotpm completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.ExactValidArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := dataOutput()
+		switch args[0] {
+		case "bash":
+			return RootCmd.GenBashCompletion(out)
+		case "zsh":
+			return RootCmd.GenZshCompletion(out)
+		case "fish":
+			return RootCmd.GenFishCompletion(out, true)
+		case "powershell":
+			return RootCmd.GenPowerShellCompletionWithDesc(out)
+		default:
+			return fmt.Errorf("unknown shell %q", args[0])
+		}
+	},
+}
+
+// completeValues returns a flag completion function completing the values.
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(completionCmd)
+	addOutputFlag(completionCmd)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestFlagCompletion(t *testing.T) {
+	subtests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"read", "pcr", "--format", ""}, []string{formatText, formatJSON}},
+		{[]string{"read", "pcr", "--wire-format", ""}, []string{wireText, wireJSON, wireBinary, wireCBOR}},
+		{[]string{"pubkey", "ak", "--key-format", ""}, []string{"pem", "der", "tpm", "ssh"}},
+		{[]string{"nv", "read", "ek-cert", "--data-format", ""}, []string{"raw", "pem", "text"}},
+	}
+	for _, subtest := range subtests {
+		t.Run(strings.Join(subtest.args, " "), func(t *testing.T) {
+			var out bytes.Buffer
+			RootCmd.SetOut(&out)
+			RootCmd.SetErr(ioutil.Discard)
+			defer RootCmd.SetOut(nil)
+			defer RootCmd.SetErr(nil)
+			RootCmd.SetArgs(append([]string{"__complete"}, subtest.args...))
+			if err := RootCmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			// The completions are followed by the completion directive.
+			got := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(got) != len(subtest.want)+1 || strings.Join(got[:len(subtest.want)], " ") != strings.Join(subtest.want, " ") {
+				t.Errorf("got completions %q, want %q", got, subtest.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/spf13/cobra"
+)
+
+var counterCmd = &cobra.Command{
+	Use:   "counter",
+	Short: "Manage NV counters used to revoke sealed data",
+	Long: `Manage monotonic counters stored in TPM NVRAM
+
+Data can be sealed to the current value of a counter (with "gotpm seal
+--counter-index"). Incrementing the counter then revokes the sealed data, as it
+can only be unsealed while the counter has the value it was sealed to.`,
+	Args: cobra.NoArgs,
+}
+
+var counterCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an NV counter",
+	Long: `Create a monotonic counter at the NV index given by --index
+
+The index is defined with the owner hierarchy and an empty password. Any user
+with access to the TPM can read or increment the counter.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		if err := client.CreateNVCounter(rwc, nvIndex); err != nil {
+			return err
+		}
+		return reportResult(counterReport{Index: jsonHandle(nvIndex)},
+			"Created NV counter at index 0x%x\n", nvIndex)
+	},
+}
+
+var counterIncrementCmd = &cobra.Command{
+	Use:   "increment",
+	Short: "Increment an NV counter",
+	Long: `Increment the counter at the NV index given by --index
+
+Any data sealed to the old value of the counter can no longer be unsealed. The
+new value is written to the output.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		value, err := client.IncrementNVCounter(rwc, nvIndex)
+		if err != nil {
+			return err
+		}
+		return writeCounter(value)
+	},
+}
+
+var counterReadCmd = &cobra.Command{
+	Use:   "read",
+	Short: "Read an NV counter",
+	Long:  `Write the value of the counter at the NV index given by --index to the output`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		value, err := client.ReadNVCounter(rwc, nvIndex)
+		if err != nil {
+			return err
+		}
+		return writeCounter(value)
+	},
+}
+
+// counterReport is the JSON output of "gotpm counter" commands.
+type counterReport struct {
+	Index jsonHandle `json:"index"`
+	Value *uint64    `json:"value,omitempty"`
+}
+
+func writeCounter(value uint64) error {
+	if jsonOutput() {
+		return writeJSON(dataOutput(), counterReport{Index: jsonHandle(nvIndex), Value: &value})
+	}
+	_, err := fmt.Fprintln(dataOutput(), value)
+	return err
+}
+
+func init() {
+	RootCmd.AddCommand(counterCmd)
+	hideHelp(counterCmd)
+	counterCmd.AddCommand(counterCreateCmd)
+	counterCmd.AddCommand(counterIncrementCmd)
+	counterCmd.AddCommand(counterReadCmd)
+	addIndexFlag(counterCmd)
+	counterCmd.MarkPersistentFlagRequired("index")
+	addOutputFlag(counterIncrementCmd)
+	addOutputFlag(counterReadCmd)
+}
@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/server"
+	"github.com/google/go-tpm-tools/simulator"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"github.com/spf13/cobra"
+)
+
+var demoCmd = &cobra.Command{
+	Use:   "demo",
+	Short: "Walk through attestation and sealing with a simulated TPM",
+	Long: `Walk through remote attestation and sealing, using a simulated TPM
+
+This command starts the TPM simulator (ignoring --tpm-path), measures a
+simulated boot into it, and then runs the same steps as a real deployment,
+explaining each one:
+  - provisioning an Endorsement Key (EK) and an Attestation Key (AK)
+  - proving to a verifier that the AK is in the same TPM as the EK
+  - attesting to the measured boot, and verifying the attestation
+  - sealing a secret to a PCR, and revoking it by changing the PCR
+
+No hardware TPM is used or modified. The simulator requires gotpm to be built
+with cgo.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDemo(messageOutput())
+	},
+}
+
+// The simulated boot, and the PCR the demo seals to.
+const (
+	demoKernelCmdline   = "root=/dev/sda1 ro lockdown=integrity"
+	demoFirmwareVersion = "gotpm demo firmware 1.0"
+	demoSealedPCR       = 7
+)
+
+// Values used in the simulated event log, from the TCG PC Client Platform
+// Firmware Profile Specification.
+const (
+	demoEventIPL            uint32 = 0x0000000D
+	demoSpecIDSignature            = "Spec ID Event03\x00"
+	demoSHA1DigestSize             = 20
+	demoPlatformClassClient        = 0
+	demoSpecVersionMajor           = 2
+	demoUINTNSize64Bit             = 2
+)
+
+// demoTPM is a simulator which returns the event log of the simulated boot.
+type demoTPM struct {
+	*simulator.Simulator
+	eventLog []byte
+}
+
+func (d *demoTPM) EventLog() ([]byte, error) {
+	return d.eventLog, nil
+}
+
+// demoEvent is an event measured during the simulated boot. The event digest
+// covers measured if it is set, and data otherwise.
+type demoEvent struct {
+	pcr       uint32
+	eventType uint32
+	data      []byte
+	measured  []byte
+}
+
+func demoBootEvents() []demoEvent {
+	events := []demoEvent{{pcr: 0, eventType: server.SCRTMVersion, data: encodeUTF16(demoFirmwareVersion + "\x00")}}
+	for pcr := uint32(0); pcr <= 7; pcr++ {
+		events = append(events, demoEvent{pcr: pcr, eventType: server.Separator, data: []byte{0, 0, 0, 0}})
+	}
+	// Measured like GRUB, where the event data is prefixed.
+	return append(events, demoEvent{
+		pcr:       8,
+		eventType: demoEventIPL,
+		data:      []byte("kernel_cmdline: " + demoKernelCmdline + "\x00"),
+		measured:  []byte(demoKernelCmdline),
+	})
+}
+
+// measureDemoBoot extends the events into every PCR bank of the TPM, and
+// returns them as a crypto-agile TCG event log.
+func measureDemoBoot(rw io.ReadWriter, events []demoEvent) ([]byte, error) {
+	banks, err := client.ReadAllPCRs(rw)
+	if err != nil {
+		return nil, err
+	}
+	var algs []tpm2.Algorithm
+	for _, bank := range banks {
+		algs = append(algs, tpm2.Algorithm(bank.GetHash()))
+	}
+
+	// The log starts with a TCG_EfiSpecIDEvent in the SHA-1 log format,
+	// listing the digest algorithms of the other events.
+	var specID bytes.Buffer
+	specID.WriteString(demoSpecIDSignature)
+	binary.Write(&specID, binary.LittleEndian, uint32(demoPlatformClassClient))
+	specID.Write([]byte{0, demoSpecVersionMajor, 0, demoUINTNSize64Bit})
+	binary.Write(&specID, binary.LittleEndian, uint32(len(algs)))
+	for _, alg := range algs {
+		hash, err := alg.Hash()
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(&specID, binary.LittleEndian, uint16(alg))
+		binary.Write(&specID, binary.LittleEndian, uint16(hash.Size()))
+	}
+	specID.WriteByte(0) // vendorInfoSize
+
+	var log bytes.Buffer
+	binary.Write(&log, binary.LittleEndian, uint32(0))
+	binary.Write(&log, binary.LittleEndian, server.NoAction)
+	log.Write(make([]byte, demoSHA1DigestSize))
+	binary.Write(&log, binary.LittleEndian, uint32(specID.Len()))
+	log.Write(specID.Bytes())
+
+	for _, event := range events {
+		measured := event.measured
+		if measured == nil {
+			measured = event.data
+		}
+		binary.Write(&log, binary.LittleEndian, event.pcr)
+		binary.Write(&log, binary.LittleEndian, event.eventType)
+		binary.Write(&log, binary.LittleEndian, uint32(len(algs)))
+		for _, alg := range algs {
+			hash, _ := alg.Hash()
+			hasher := hash.New()
+			hasher.Write(measured)
+			digest := hasher.Sum(nil)
+			if err := tpm2.PCRExtend(rw, tpmutil.Handle(event.pcr), alg, digest, ""); err != nil {
+				return nil, fmt.Errorf("extending PCR %d: %w", event.pcr, err)
+			}
+			binary.Write(&log, binary.LittleEndian, uint16(alg))
+			log.Write(digest)
+		}
+		binary.Write(&log, binary.LittleEndian, uint32(len(event.data)))
+		log.Write(event.data)
+	}
+	return log.Bytes(), nil
+}
+
+func encodeUTF16(s string) []byte {
+	var data []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		data = append(data, byte(c), byte(c>>8))
+	}
+	return data
+}
+
+func decodeUTF16(data []byte) string {
+	chars := make([]uint16, len(data)/2)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(chars))
+}
+
+func demoStep(out io.Writer, step int, title string, explanation ...string) {
+	fmt.Fprintf(out, "\nStep %d: %s\n", step, title)
+	for _, line := range explanation {
+		fmt.Fprintf(out, "  %s\n", line)
+	}
+}
+
+func runDemo(out io.Writer) error {
+	demoStep(out, 1, "Starting a simulated TPM and booting",
+		"The firmware and bootloader measure each boot component into the TPM's",
+		"Platform Configuration Registers (PCRs), and record it in an event log.")
+	sim, err := simulator.Get()
+	if err != nil {
+		return fmt.Errorf("starting the simulator (gotpm must be built with cgo): %w", err)
+	}
+	defer sim.Close()
+	rw := &demoTPM{Simulator: sim}
+	if rw.eventLog, err = measureDemoBoot(rw, demoBootEvents()); err != nil {
+		return fmt.Errorf("measuring the simulated boot: %w", err)
+	}
+	fmt.Fprintf(out, "  Measured %d events, including the kernel command line %q\n", len(demoBootEvents()), demoKernelCmdline)
+
+	demoStep(out, 2, "Provisioning the Endorsement Key (EK)",
+		"The EK is derived from a seed set when the TPM is manufactured. Real TPMs",
+		"come with an EK certificate from their manufacturer, identifying the TPM.")
+	ek, err := client.EndorsementKeyRSA(rw)
+	if err != nil {
+		return fmt.Errorf("creating the EK: %w", err)
+	}
+	defer ek.Close()
+	fmt.Fprintf(out, "  EK name: %x\n", ek.Name().Digest.Value)
+
+	demoStep(out, 3, "Creating an Attestation Key (AK)",
+		"The AK is a restricted signing key: it only signs data generated by the",
+		"TPM itself, such as quotes of the PCRs.")
+	ak, err := client.AttestationKeyRSA(rw)
+	if err != nil {
+		return fmt.Errorf("creating the AK: %w", err)
+	}
+	defer ak.Close()
+	fmt.Fprintf(out, "  AK name: %x\n", ak.Name().Digest.Value)
+
+	demoStep(out, 4, "Enrolling the AK with a credential challenge",
+		"The verifier encrypts a secret to the EK, bound to the AK's name. Only the",
+		"TPM holding the EK can decrypt it, and only if it also holds the AK.")
+	challenge, want, err := server.GenerateChallenge(ek.PublicKey(), ak.Name())
+	if err != nil {
+		return fmt.Errorf("generating the challenge: %w", err)
+	}
+	got, err := ek.ActivateCredential(ak, challenge)
+	if err != nil {
+		return fmt.Errorf("activating the credential: %w", err)
+	}
+	if !bytes.Equal(got, want) {
+		return errors.New("the TPM returned the wrong credential")
+	}
+	fmt.Fprintln(out, "  The TPM decrypted the challenge: the verifier can now trust the AK")
+
+	demoStep(out, 5, "Attesting to the boot",
+		"The verifier sends a fresh nonce, and the AK signs a quote of the PCRs",
+		"containing it, which is sent with the event log.")
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		return fmt.Errorf("attesting: %w", err)
+	}
+	fmt.Fprintf(out, "  Attestation has %d quotes (one per PCR bank) and a %d byte event log\n",
+		len(attestation.GetQuotes()), len(attestation.GetEventLog()))
+
+	demoStep(out, 6, "Verifying the attestation",
+		"The verifier checks the quote's signature and nonce, then replays the event",
+		"log against the quoted PCRs, so the parsed events can be trusted.")
+	opts := server.VerifyOpts{Nonce: nonce, TrustedAKs: []crypto.PublicKey{ak.PublicKey()}}
+	state, err := server.VerifyAttestation(attestation, opts)
+	if err != nil {
+		return fmt.Errorf("verifying the attestation: %w", err)
+	}
+	fmt.Fprintf(out, "  Verified with the %v PCR bank\n", state.GetHash())
+	fmt.Fprintf(out, "  Firmware version: %q\n",
+		strings.TrimRight(decodeUTF16(state.GetPlatform().GetScrtmVersionId()), "\x00"))
+	fmt.Fprintf(out, "  Kernel command line: %q (lockdown: %v)\n",
+		state.GetLinuxKernel().GetCommandLine(), state.GetLinuxKernel().GetLockdown())
+	opts.Nonce = []byte("an old nonce")
+	if _, err := server.VerifyAttestation(attestation, opts); err == nil {
+		return errors.New("verifying with the wrong nonce succeeded")
+	}
+	fmt.Fprintln(out, "  Replaying the attestation with another nonce fails, as expected")
+
+	demoStep(out, 7, "Sealing a secret to the boot state",
+		"Sealed data is encrypted by the TPM's Storage Root Key (SRK), and can only",
+		fmt.Sprintf("be unsealed while PCR %d (the Secure Boot configuration) is unchanged.", demoSealedPCR))
+	srk, err := client.StorageRootKeyRSA(rw)
+	if err != nil {
+		return fmt.Errorf("creating the SRK: %w", err)
+	}
+	defer srk.Close()
+	secret := []byte("disk encryption key")
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{demoSealedPCR}}
+	sealed, err := srk.Seal(secret, client.SealOpts{Current: sel})
+	if err != nil {
+		return fmt.Errorf("sealing: %w", err)
+	}
+	unsealed, err := srk.Unseal(sealed, client.UnsealOpts{})
+	if err != nil {
+		return fmt.Errorf("unsealing: %w", err)
+	}
+	fmt.Fprintf(out, "  Sealed and unsealed %q\n", unsealed)
+
+	demoStep(out, 8, "Changing the boot state revokes the secret",
+		fmt.Sprintf("Extending PCR %d, as if the Secure Boot configuration changed, makes", demoSealedPCR),
+		"unsealing fail.")
+	if err := tpm2.PCRExtend(rw, tpmutil.Handle(demoSealedPCR), tpm2.AlgSHA256, make([]byte, 32), ""); err != nil {
+		return fmt.Errorf("extending PCR %d: %w", demoSealedPCR, err)
+	}
+	if _, err := srk.Unseal(sealed, client.UnsealOpts{}); err == nil {
+		return errors.New("unsealing after the PCR changed succeeded")
+	}
+	fmt.Fprintln(out, "  Unsealing failed, as expected")
+
+	fmt.Fprintln(out, "\nDone. See \"gotpm --help\" to run these steps against a real TPM.")
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(demoCmd)
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDemo(t *testing.T) {
+	var out bytes.Buffer
+	if err := runDemo(&out); err != nil {
+		t.Fatalf("demo failed: %v\n%s", err, out.String())
+	}
+	for _, want := range []string{
+		"Step 8:",
+		`Firmware version: "` + demoFirmwareVersion + `"`,
+		`Kernel command line: "` + demoKernelCmdline + `" (lockdown: LOCKDOWN_INTEGRITY)`,
+		"Unsealing failed, as expected",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("demo output does not contain %q:\n%s", want, out.String())
+		}
+	}
+}
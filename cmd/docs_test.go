@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// The documentation generated by "go generate ./cmd/gotpm".
+const generatedDocs = "gotpm/docs"
+
+func TestGeneratedDocs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the documentation is generated on Linux, where the default TPM differs")
+	}
+	var want bytes.Buffer
+	if err := writeHelpJSON(&want, RootCmd); err != nil {
+		t.Fatal(err)
+	}
+	checkGeneratedDoc(t, filepath.Join(generatedDocs, "gotpm.json"), want.Bytes())
+
+	wantPages := map[string][]byte{}
+	var addPages func(cmd *cobra.Command)
+	addPages = func(cmd *cobra.Command) {
+		var page bytes.Buffer
+		if err := writeManPage(&page, cmd); err != nil {
+			t.Fatal(err)
+		}
+		wantPages[manPageName(cmd)+".1"] = page.Bytes()
+		for _, sub := range cmd.Commands() {
+			if sub.IsAvailableCommand() {
+				addPages(sub)
+			}
+		}
+	}
+	addPages(RootCmd)
+	files, err := ioutil.ReadDir(filepath.Join(generatedDocs, "man"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stale []string
+	for _, file := range files {
+		if _, ok := wantPages[file.Name()]; !ok {
+			stale = append(stale, file.Name())
+		}
+	}
+	if len(stale) != 0 {
+		t.Errorf("manual pages %v are for removed commands, run \"go generate ./cmd/gotpm\"", stale)
+	}
+	var names []string
+	for name := range wantPages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checkGeneratedDoc(t, filepath.Join(generatedDocs, "man", name), wantPages[name])
+	}
+}
+
+func checkGeneratedDoc(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Errorf("%v, run \"go generate ./cmd/gotpm\"", err)
+		return
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date, run \"go generate ./cmd/gotpm\"", path)
+	}
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/google/go-attestation/attest"
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/server"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/spf13/cobra"
+)
+
+var (
+	eventLogHashAlgo = tpm2.AlgSHA256
+	eventLogCheck    bool
+)
+
+// errReplayFailed is returned by "gotpm eventlog --check" when the log does
+// not replay to the TPM's PCRs.
+var errReplayFailed = errors.New("event log does not match the TPM's PCRs")
+
+var eventLogCmd = &cobra.Command{
+	Use:   "eventlog",
+	Short: "Show the TCG event log, checked against the TPM's PCRs",
+	Long: `Replay the TCG event log against the TPM's PCRs, and write its events
+
+The event log is read from the --input file, or otherwise from the system
+(/sys/kernel/security/tpm0/binary_bios_measurements on Linux). It is replayed
+against the current values of the TPM's PCRs in the --hash-algo bank, and
+each event extended into a PCR is written, followed by each PCR's live and
+replayed values.
+
+The events and PCRs are written as a table, or with --format=json as a JSON
+object with "events", "pcrs", and "replay_error" if the log does not replay.
+
+With --check, the command fails if the log does not replay to the PCRs, so it
+can be used in scripts and health checks:
+	gotpm eventlog --check --quiet --output /dev/null`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		var rawLog []byte
+		if input != "" {
+			fmt.Fprintf(debugOutput(), "Reading event log from %s\n", input)
+			rawLog, err = ioutil.ReadAll(dataInput())
+		} else {
+			fmt.Fprintln(debugOutput(), "Reading event log from the system")
+			rawLog, err = client.GetEventLog(rwc)
+		}
+		if err != nil {
+			return fmt.Errorf("reading event log: %w", err)
+		}
+		pcrs, err := client.ReadPCRs(rwc, client.FullPcrSel(eventLogHashAlgo))
+		if err != nil {
+			return err
+		}
+
+		var events []*pb.Event
+		replayErr := server.ReplayEventLog(bytes.NewReader(rawLog), pcrs, func(event *pb.Event) error {
+			events = append(events, event)
+			return nil
+		})
+		report := eventLogReport{Hash: algos[eventLogHashAlgo]}
+		var mismatch *server.ReplayError
+		if replayErr != nil && !errors.As(replayErr, &mismatch) {
+			// The log could not be parsed.
+			return replayErr
+		}
+		if replayErr != nil {
+			report.ReplayError = replayErr.Error()
+		}
+		report.addEvents(events)
+		report.addPCRs(pcrs.GetPcrs(), events, mismatch)
+
+		w := dataOutput()
+		if jsonOutput() {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(report)
+		} else {
+			err = report.writeTable(w)
+		}
+		if err != nil {
+			return err
+		}
+		if replayErr != nil {
+			fmt.Fprintf(messageOutput(), "The event log does not replay: %v\n", replayErr)
+			if eventLogCheck {
+				return errReplayFailed
+			}
+		}
+		return nil
+	},
+}
+
+// eventLogReport is the output of "gotpm eventlog".
+type eventLogReport struct {
+	Hash        string          `json:"hash"`
+	Events      []eventLogEvent `json:"events"`
+	PCRs        []eventLogPCR   `json:"pcrs"`
+	ReplayError string          `json:"replay_error,omitempty"`
+}
+
+type eventLogEvent struct {
+	PCR            uint32 `json:"pcr"`
+	Type           string `json:"type"`
+	Digest         string `json:"digest"`
+	DigestVerified bool   `json:"digest_verified"`
+	Data           string `json:"data"`
+}
+
+type eventLogPCR struct {
+	PCR      uint32 `json:"pcr"`
+	Live     string `json:"live"`
+	Replayed string `json:"replayed"`
+	Matches  bool   `json:"matches"`
+}
+
+func (r *eventLogReport) addEvents(events []*pb.Event) {
+	r.Events = []eventLogEvent{}
+	for _, event := range events {
+		r.Events = append(r.Events, eventLogEvent{
+			PCR:            event.GetPcrIndex(),
+			Type:           attest.EventType(event.GetUntrustedType()).String(),
+			Digest:         hex.EncodeToString(event.GetDigest()),
+			DigestVerified: event.GetDigestVerified(),
+			Data:           hex.EncodeToString(event.GetData()),
+		})
+	}
+}
+
+// addPCRs adds the PCRs which have events, or which failed to replay.
+func (r *eventLogReport) addPCRs(live map[uint32][]byte, events []*pb.Event, mismatch *server.ReplayError) {
+	indexes := map[uint32]bool{}
+	for _, event := range events {
+		indexes[event.GetPcrIndex()] = true
+	}
+	failed := map[uint32]bool{}
+	if mismatch != nil {
+		for _, index := range mismatch.PCRs {
+			indexes[uint32(index)] = true
+			failed[uint32(index)] = true
+		}
+	}
+	var sorted []uint32
+	for index := range indexes {
+		sorted = append(sorted, index)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	r.PCRs = []eventLogPCR{}
+	for _, index := range sorted {
+		pcr := eventLogPCR{PCR: index, Live: hex.EncodeToString(live[index])}
+		if failed[index] {
+			pcr.Replayed = hex.EncodeToString(mismatch.Replayed[index])
+		} else {
+			pcr.Replayed, pcr.Matches = pcr.Live, true
+		}
+		r.PCRs = append(r.PCRs, pcr)
+	}
+}
+
+func (r *eventLogReport) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PCR\tTYPE\tDIGEST\tVERIFIED\tDATA")
+	for _, event := range r.Events {
+		data, _ := hex.DecodeString(event.Data)
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%v\t%s\n", event.PCR, event.Type, event.Digest, event.DigestVerified, summarizeEventData(data))
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintf(tw, "PCR\tSTATUS\tLIVE (%s)\tREPLAYED\n", r.Hash)
+	for _, pcr := range r.PCRs {
+		status := "ok"
+		if !pcr.Matches {
+			status = "MISMATCH"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", pcr.PCR, status, pcr.Live, pcr.Replayed)
+	}
+	return tw.Flush()
+}
+
+// maxSummary is the length of the event data shown in a table.
+const maxSummary = 48
+
+// summarizeEventData returns a short, printable description of event data:
+// the text if it is an ASCII or UTF-16 string, and otherwise its start in hex.
+func summarizeEventData(data []byte) string {
+	text := string(bytes.TrimRight(data, "\x00"))
+	if len(data) >= 4 && len(data)%2 == 0 && data[1] == 0 && data[3] == 0 {
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+		}
+		text = string(bytes.TrimRight([]byte(string(utf16.Decode(units))), "\x00"))
+	}
+	printable := text != ""
+	for _, r := range text {
+		if !unicode.IsPrint(r) {
+			printable = false
+			break
+		}
+	}
+	if !printable {
+		text = hex.EncodeToString(data)
+	}
+	if runes := []rune(text); len(runes) > maxSummary {
+		text = string(runes[:maxSummary-3]) + "..."
+	}
+	return text
+}
+
+func init() {
+	RootCmd.AddCommand(eventLogCmd)
+	addInputFlag(eventLogCmd)
+	addOutputFlag(eventLogCmd)
+	addHashAlgoFlag(eventLogCmd, &eventLogHashAlgo)
+	eventLogCmd.PersistentFlags().BoolVar(&eventLogCheck, "check", false,
+		"fail if the event log does not replay to the TPM's PCRs")
+}
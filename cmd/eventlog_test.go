@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+func runEventLog(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	outFile := makeTempFile(t, nil)
+	defer os.Remove(outFile)
+	RootCmd.SetArgs(append([]string{"eventlog", "--quiet", "--output", outFile}, args...))
+	err := RootCmd.Execute()
+	out, readErr := ioutil.ReadFile(outFile)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return string(out), err
+}
+
+func TestEventLog(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	defer func() { outputFormat, eventLogCheck, input, output = formatText, false, "", "" }()
+
+	table, err := runEventLog(t, "--check")
+	if err != nil {
+		t.Fatalf("eventlog --check failed on a matching log: %v", err)
+	}
+	eventLogCheck = false
+	if !strings.Contains(table, "EV_SEPARATOR") || strings.Contains(table, "MISMATCH") {
+		t.Errorf("eventlog = %q, want matching PCRs and a separator event", table)
+	}
+
+	logFile := makeTempFile(t, test.Rhel8EventLog)
+	defer os.Remove(logFile)
+	out, err := runEventLog(t, "--format", "json", "--input", logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report eventLogReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("eventlog --format json output is not valid JSON: %v", err)
+	}
+	if len(report.Events) == 0 || len(report.PCRs) == 0 || report.ReplayError != "" {
+		t.Errorf("got report %+v, want events and PCRs which replay", report)
+	}
+	input = ""
+
+	// Changing a PCR makes the log fail to replay.
+	if err := tpm2.PCRExtend(rwc, tpmutil.Handle(4), tpm2.AlgSHA256, make([]byte, sha256.Size), ""); err != nil {
+		t.Fatal(err)
+	}
+	table, err = runEventLog(t, "--format", "text")
+	if err != nil {
+		t.Errorf("eventlog without --check should not fail: %v", err)
+	}
+	if !strings.Contains(table, "4    MISMATCH") {
+		t.Errorf("eventlog = %q, want PCR 4 to mismatch", table)
+	}
+	if _, err := runEventLog(t, "--check"); !errors.Is(err, errReplayFailed) {
+		t.Errorf("eventlog --check = %v, want errReplayFailed", err)
+	}
+}
+
+func TestSummarizeEventData(t *testing.T) {
+	subtests := []struct {
+		data []byte
+		want string
+	}{
+		{[]byte("grub_cmd: linux /vmlinuz\x00"), "grub_cmd: linux /vmlinuz"},
+		{[]byte{'G', 0, 'C', 0, 'E', 0, 0, 0}, "GCE"},
+		{[]byte{0, 0, 0, 0}, "00000000"},
+		{[]byte(strings.Repeat("a", 60)), strings.Repeat("a", 45) + "..."},
+	}
+	for _, subtest := range subtests {
+		if got := summarizeEventData(subtest.data); got != subtest.want {
+			t.Errorf("summarizeEventData(%q) = %q, want %q", subtest.data, got, subtest.want)
+		}
+	}
+}
@@ -19,6 +19,11 @@ var (
 	nvIndex uint32
 	keyAlgo = tpm2.AlgRSA
 	pcrs    []int
+	persist bool
+	ekRange = "low"
+
+	registryFile  string
+	registryIndex uint32
 )
 
 type pcrsFlag struct {
@@ -94,11 +99,16 @@ func (f *algoFlag) String() string {
 
 // Allowed gives a string list of the permitted algorithm values for this flag.
 func (f *algoFlag) Allowed() string {
+	return strings.Join(f.names(), ", ")
+}
+
+// names gives the permitted algorithm values for this flag.
+func (f *algoFlag) names() []string {
 	out := make([]string, len(f.allowed))
 	for i, a := range f.allowed {
 		out[i] = algos[a]
 	}
-	return strings.Join(out, ", ")
+	return out
 }
 
 // Disable the "help" subcommand (and just use the -h/--help flags).
@@ -126,6 +136,12 @@ func addIndexFlag(cmd *cobra.Command) {
 		"NVDATA index, cannot be 0")
 }
 
+// Lets this command persist keys created from NVDATA templates.
+func addPersistFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&persist, "persist", false,
+		"reuse a persistent key created from the --index template, or persist a new one")
+}
+
 // Lets this command specify some number of PCR arguments, check if in range.
 func addPCRsFlag(cmd *cobra.Command) {
 	cmd.PersistentFlags().Var(&pcrsFlag{&pcrs}, "pcrs", "comma separated list of PCR numbers")
@@ -135,11 +151,20 @@ func addPCRsFlag(cmd *cobra.Command) {
 func addPublicKeyAlgoFlag(cmd *cobra.Command) {
 	f := algoFlag{&keyAlgo, []tpm2.Algorithm{tpm2.AlgRSA, tpm2.AlgECC}}
 	cmd.PersistentFlags().Var(&f, "algo", "public key algorithm: "+f.Allowed())
+	cmd.RegisterFlagCompletionFunc("algo", completeValues(f.names()...))
+}
+
+// Lets this command select the range of the EK template, for use with getEK.
+func addEKRangeFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&ekRange, "ek-range", "low",
+		"range of the EK template in the TCG EK Credential Profile: low or high")
+	cmd.RegisterFlagCompletionFunc("ek-range", completeValues("low", "high"))
 }
 
 func addHashAlgoFlag(cmd *cobra.Command, hashAlgo *tpm2.Algorithm) {
 	f := algoFlag{hashAlgo, []tpm2.Algorithm{tpm2.AlgSHA1, tpm2.AlgSHA256, tpm2.AlgSHA384, tpm2.AlgSHA512}}
 	cmd.PersistentFlags().Var(&f, "hash-algo", "hash algorithm: "+f.Allowed())
+	cmd.RegisterFlagCompletionFunc("hash-algo", completeValues(f.names()...))
 }
 
 // alwaysError implements io.ReadWriter by always returning an error
@@ -195,14 +220,36 @@ func getSRK(rwc io.ReadWriter) (*client.Key, error) {
 	}
 }
 
-// Load EK based on tpm2.Algorithm set in the global flag vars.
+// Load EK based on tpm2.Algorithm and EK range set in the global flag vars.
 func getEK(rwc io.ReadWriter) (*client.Key, error) {
-	switch keyAlgo {
-	case tpm2.AlgRSA:
-		return client.EndorsementKeyRSA(rwc)
-	case tpm2.AlgECC:
-		return client.EndorsementKeyECC(rwc)
+	switch ekRange {
+	case "low":
+		return client.EndorsementKey(rwc, keyAlgo, client.EKLowRange)
+	case "high":
+		return client.EndorsementKey(rwc, keyAlgo, client.EKHighRange)
 	default:
-		panic("unexpected keyAlgo")
+		return nil, fmt.Errorf("unknown EK range %q", ekRange)
+	}
+}
+
+// Lets this command use a name registry, for use with openRegistry().
+func addRegistryFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&registryFile, "registry", "",
+		"signed file holding the name registry")
+	cmd.PersistentFlags().Uint32Var(&registryIndex, "registry-index", 0,
+		"NV index holding the name registry")
+}
+
+// Open the name registry given by --registry or --registry-index.
+func openRegistry(rw io.ReadWriter) (*client.NameRegistry, error) {
+	switch {
+	case registryFile != "" && registryIndex != 0:
+		return nil, errors.New("only one of --registry and --registry-index can be given")
+	case registryFile != "":
+		return client.OpenNameRegistry(rw, client.FileRegistryStore(rw, registryFile))
+	case registryIndex != 0:
+		return client.OpenNameRegistry(rw, client.NVRegistryStore(rw, registryIndex))
+	default:
+		return nil, errors.New("--registry or --registry-index must be given")
 	}
 }
@@ -55,7 +55,7 @@ Which handles are flushed depends on the argument passed:
 		}
 		defer rwc.Close()
 
-		totalHandles := 0
+		report := flushReport{Handles: []jsonHandle{}}
 		for _, handleType := range handleNames[args[0]] {
 			handles, err := client.Handles(rwc, handleType)
 			if err != nil {
@@ -73,15 +73,20 @@ Which handles are flushed depends on the argument passed:
 					}
 					fmt.Fprintf(debugOutput(), "Handle 0x%x flushed\n", handle)
 				}
-				totalHandles++
+				report.Handles = append(report.Handles, jsonHandle(handle))
 			}
 		}
 
-		fmt.Fprintf(messageOutput(), "%d handles flushed\n", totalHandles)
-		return nil
+		return reportResult(report, "%d handles flushed\n", len(report.Handles))
 	},
 }
 
+// flushReport is the JSON output of "gotpm flush": the flushed (or evicted)
+// handles.
+type flushReport struct {
+	Handles []jsonHandle `json:"handles"`
+}
+
 func init() {
 	RootCmd.AddCommand(flushCmd)
 }
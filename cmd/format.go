@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Values of the global --format flag.
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+var outputFormat = formatText
+
+// jsonOutput reports whether --format=json was given.
+func jsonOutput() bool {
+	return outputFormat == formatJSON
+}
+
+// writeJSON writes v to w as indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// reportResult reports the result of a command which does not write data,
+// such as a command which changes the TPM. With --format=json the result is
+// written as JSON (see writeResult), and otherwise the message is written to
+// messageOutput().
+func reportResult(result interface{}, format string, a ...interface{}) error {
+	if jsonOutput() {
+		return writeResult(result)
+	}
+	_, err := fmt.Fprintf(messageOutput(), format, a...)
+	return err
+}
+
+// writeResult writes the JSON result of a command which does not write data to
+// stdout, unless --quiet was given.
+func writeResult(result interface{}) error {
+	if quiet {
+		return nil
+	}
+	return writeJSON(os.Stdout, result)
+}
+
+// Values of the global --wire-format flag, the encoding of the protobufs
+// written by gotpm.
+const (
+	wireText   = "text"
+	wireJSON   = "json"
+	wireBinary = "binary"
+	wireCBOR   = "cbor"
+)
+
+// wireFormat is the --wire-format flag. If empty, protobufs are written in the
+// --format (text or JSON).
+var wireFormat string
+
+// marshalMessage encodes a protobuf written by gotpm, in the --wire-format:
+//   - text: the protobuf text format (the default)
+//   - json: the protobuf JSON format, with the field names of the .proto files
+//     like the JSON reports (the default with --format=json)
+//   - binary: the protobuf binary format
+//   - cbor: CBOR (see marshalCBOR)
+func marshalMessage(m proto.Message) ([]byte, error) {
+	format := wireFormat
+	if format == "" {
+		format = outputFormat
+	}
+	switch format {
+	case wireJSON:
+		out, err := protojson.MarshalOptions{Multiline: true, UseProtoNames: true}.Marshal(m)
+		return append(out, '\n'), err
+	case wireBinary:
+		return proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	case wireCBOR:
+		return marshalCBOR(m)
+	default:
+		return marshalOptions.Marshal(m)
+	}
+}
+
+// unmarshalMessage decodes a protobuf written by marshalMessage, in any wire
+// format, detecting the format from the data.
+func unmarshalMessage(data []byte, m proto.Message) error {
+	if bytes.HasPrefix(data, cborMagic) {
+		return unmarshalCBOR(data, m)
+	}
+	// A text protobuf starts with a field name (or a comment), never '{'.
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return protojson.Unmarshal(trimmed, m)
+	}
+	// A binary protobuf is rarely valid text, so try the text format first.
+	textErr := unmarshalOptions.Unmarshal(data, m)
+	if textErr == nil {
+		return nil
+	}
+	proto.Reset(m)
+	if err := proto.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("%T is not in any wire format: as text: %v; as binary: %w", m, textErr, err)
+	}
+	return nil
+}
+
+// hexBytes is binary data, hex-encoded in JSON reports.
+type hexBytes []byte
+
+func (h hexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%x", []byte(h)))
+}
+
+// jsonHandle is a TPM handle or NV index, written in hex in JSON reports.
+type jsonHandle uint32
+
+func (h jsonHandle) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("0x%08x", uint32(h)))
+}
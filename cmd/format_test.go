@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// runJSON runs gotpm with --format=json, writing to a temporary --output file,
+// and decodes the output into v.
+func runJSON(t *testing.T, v interface{}, args ...string) {
+	t.Helper()
+	outputFile := makeTempFile(t, nil)
+	defer os.Remove(outputFile)
+	defer func() { outputFormat, output = formatText, "" }()
+
+	RootCmd.SetArgs(append(args, "--format", "json", "--output", outputFile))
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatalf("gotpm %v failed: %v", args, err)
+	}
+	data, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("gotpm %v wrote invalid JSON: %v\n%s", args, err, data)
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+
+	t.Run("PCRs", func(t *testing.T) {
+		defer func() { pcrs = []int{} }()
+		var report struct {
+			Banks []struct {
+				Hash string            `json:"hash"`
+				PCRs map[string]string `json:"pcrs"`
+			} `json:"banks"`
+		}
+		runJSON(t, &report, "read", "pcr", "--hash-algo", "sha256", "--pcrs", "0,7")
+		want, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{0, 7}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Banks) != 1 || report.Banks[0].Hash != "sha256" || len(report.Banks[0].PCRs) != 2 {
+			t.Fatalf("got PCR report %+v, want PCRs 0 and 7 of the sha256 bank", report)
+		}
+		for _, index := range []uint32{0, 7} {
+			got := report.Banks[0].PCRs[fmt.Sprint(index)]
+			if got != hex.EncodeToString(want.GetPcrs()[index]) {
+				t.Errorf("PCR %d = %s, want %x", index, got, want.GetPcrs()[index])
+			}
+		}
+	})
+
+	t.Run("Counter", func(t *testing.T) {
+		const index = 0x01500310
+		if err := client.CreateNVCounter(rwc, index); err != nil {
+			t.Fatal(err)
+		}
+		defer tpm2.NVUndefineSpace(rwc, "", tpm2.HandleOwner, index)
+		defer func() { nvIndex = 0 }()
+		value, err := client.ReadNVCounter(rwc, index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report struct {
+			Index string `json:"index"`
+			Value uint64 `json:"value"`
+		}
+		runJSON(t, &report, "counter", "read", "--index", "0x01500310")
+		if report.Index != "0x01500310" || report.Value != value {
+			t.Errorf("got counter report %+v, want index 0x%x and value %d", report, index, value)
+		}
+	})
+
+	t.Run("NVList", func(t *testing.T) {
+		var report struct {
+			Indexes []struct {
+				Index      string   `json:"index"`
+				Size       int      `json:"size"`
+				Attributes []string `json:"attributes"`
+			} `json:"indexes"`
+			PersistentAvail int `json:"persistent_avail"`
+		}
+		runJSON(t, &report, "nv", "list")
+		if report.PersistentAvail == 0 {
+			t.Errorf("got NV report %+v, want room for persistent objects", report)
+		}
+	})
+
+	t.Run("Pubkey", func(t *testing.T) {
+		defer func() { pubkeyKeyFormat = "pem" }()
+		var report struct {
+			Type       string `json:"type"`
+			Name       string `json:"name"`
+			PublicArea string `json:"public_area"`
+			PEM        string `json:"pem"`
+		}
+		runJSON(t, &report, "pubkey", "owner", "--algo", "ecc")
+		srk, err := client.StorageRootKeyECC(rwc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer srk.Close()
+		name, err := srk.Name().Digest.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Type != "ecc" || report.Name != hex.EncodeToString(name) || report.PEM == "" {
+			t.Errorf("got pubkey report %+v, want the ECC SRK", report)
+		}
+	})
+}
+
+func TestSealJSON(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	defer func() { outputFormat, input, output, pcrs = formatText, "", "", []int{} }()
+
+	secretIn := []byte("Hello")
+	secretFile := makeTempFile(t, secretIn)
+	defer os.Remove(secretFile)
+	sealedFile := makeTempFile(t, nil)
+	defer os.Remove(sealedFile)
+	unsealedFile := makeTempFile(t, nil)
+	defer os.Remove(unsealedFile)
+
+	RootCmd.SetArgs([]string{"seal", "--format", "json", "--pcrs", "7", "--input", secretFile, "--output", sealedFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := ioutil.ReadFile(sealedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata struct {
+		Pcrs          []int `json:"pcrs"`
+		CertifiedPcrs struct {
+			Hash string `json:"hash"`
+		} `json:"certified_pcrs"`
+	}
+	if err := json.Unmarshal(sealed, &metadata); err != nil {
+		t.Fatalf("sealed data is not JSON: %v\n%s", err, sealed)
+	}
+	if len(metadata.Pcrs) != 1 || metadata.Pcrs[0] != 7 || metadata.CertifiedPcrs.Hash != "SHA256" {
+		t.Errorf("sealed data %s does not record PCR 7 and the certified PCRs", sealed)
+	}
+
+	// The JSON sealed data is read without --format=json.
+	outputFormat, pcrs = formatText, []int{}
+	RootCmd.SetArgs([]string{"unseal", "--input", sealedFile, "--output", unsealedFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	secretOut, err := ioutil.ReadFile(unsealedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secretIn, secretOut) {
+		t.Errorf("unsealed %q, want %q", secretOut, secretIn)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	defer func() { outputFormat = formatText }()
+	RootCmd.SetArgs([]string{"read", "pcr", "--format", "yaml"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("gotpm read pcr --format yaml should fail")
+	}
+}
@@ -6,6 +6,14 @@ import (
 	"github.com/google/go-tpm-tools/cmd"
 )
 
+// The JSON description and manual pages of all commands in docs are generated
+// from the commands, and TestGeneratedDocs checks that they are up to date.
+// After changing a command, regenerate them with "go generate ./cmd/gotpm".
+//go:generate rm -rf docs
+//go:generate mkdir -p docs/man
+//go:generate go run . help --json --output docs/gotpm.json
+//go:generate go run . help --man docs/man
+
 func main() {
 	if cmd.RootCmd.Execute() != nil {
 		os.Exit(1)
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	helpJSON   bool
+	helpManDir string
+)
+
+var helpCmd = &cobra.Command{
+	Use:   "help [command]",
+	Short: "Help about any command",
+	Long: `Help provides help for any command in the application
+
+Instead of the usual help text, the help can be written as JSON (using --json
+or --format=json), describing the command and all of its subcommands and
+flags. Manual pages for every command can also be written to a directory (using
+--man). These formats allow packagers and wrapper tools to stay in sync with
+the commands of gotpm.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, _, err := RootCmd.Find(args)
+		if err != nil {
+			return err
+		}
+		switch {
+		case helpJSON && helpManDir != "":
+			return fmt.Errorf("cannot specify both --json and --man")
+		case helpManDir != "":
+			return writeManPages(target, helpManDir)
+		case helpJSON || jsonOutput():
+			return writeHelpJSON(dataOutput(), target)
+		default:
+			return target.Help()
+		}
+	},
+}
+
+func init() {
+	RootCmd.SetHelpCommand(helpCmd)
+	helpCmd.Flags().BoolVar(&helpJSON, "json", false,
+		"write a JSON description of the command and its subcommands")
+	helpCmd.Flags().StringVar(&helpManDir, "man", "",
+		"write manual pages for the command and its subcommands to this directory")
+	addOutputFlag(helpCmd)
+}
+
+// commandSchema is the JSON description of a command written by "help --json".
+type commandSchema struct {
+	Name           string          `json:"name"`
+	Path           string          `json:"path"`
+	Usage          string          `json:"usage"`
+	Short          string          `json:"short"`
+	Long           string          `json:"long,omitempty"`
+	Flags          []flagSchema    `json:"flags,omitempty"`
+	InheritedFlags []flagSchema    `json:"inherited_flags,omitempty"`
+	Subcommands    []commandSchema `json:"subcommands,omitempty"`
+}
+
+type flagSchema struct {
+	Name      string `json:"name"`
+	Shorthand string `json:"shorthand,omitempty"`
+	Type      string `json:"type"`
+	Default   string `json:"default,omitempty"`
+	Usage     string `json:"usage"`
+	Required  bool   `json:"required,omitempty"`
+}
+
+// writeHelpJSON writes the JSON description of the command and its
+// subcommands.
+func writeHelpJSON(w io.Writer, cmd *cobra.Command) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(commandToSchema(cmd))
+}
+
+func commandToSchema(cmd *cobra.Command) commandSchema {
+	// Cobra only adds the --help flag to commands when they are executed, so
+	// add it here to describe each command the same whichever commands ran.
+	cmd.InitDefaultHelpFlag()
+	schema := commandSchema{
+		Name:           cmd.Name(),
+		Path:           cmd.CommandPath(),
+		Usage:          cmd.UseLine(),
+		Short:          cmd.Short,
+		Long:           cmd.Long,
+		Flags:          flagsToSchema(cmd.NonInheritedFlags()),
+		InheritedFlags: flagsToSchema(cmd.InheritedFlags()),
+	}
+	for _, sub := range cmd.Commands() {
+		if sub.IsAvailableCommand() {
+			schema.Subcommands = append(schema.Subcommands, commandToSchema(sub))
+		}
+	}
+	return schema
+}
+
+func flagsToSchema(flags *pflag.FlagSet) []flagSchema {
+	var schemas []flagSchema
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		required := f.Annotations[cobra.BashCompOneRequiredFlag]
+		schemas = append(schemas, flagSchema{
+			Name:      f.Name,
+			Shorthand: f.Shorthand,
+			Type:      f.Value.Type(),
+			Default:   f.DefValue,
+			Usage:     f.Usage,
+			Required:  len(required) > 0 && required[0] == "true",
+		})
+	})
+	return schemas
+}
+
+// writeManPages writes a section 1 manual page for the command and each of its
+// subcommands, named like "gotpm-read-pcr.1".
+func writeManPages(cmd *cobra.Command, dir string) error {
+	for _, sub := range cmd.Commands() {
+		if sub.IsAvailableCommand() {
+			if err := writeManPages(sub, dir); err != nil {
+				return err
+			}
+		}
+	}
+	name := manPageName(cmd)
+	file, err := os.Create(filepath.Join(dir, name+".1"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := writeManPage(file, cmd); err != nil {
+		return fmt.Errorf("writing manual page for %q: %w", cmd.CommandPath(), err)
+	}
+	return file.Close()
+}
+
+func manPageName(cmd *cobra.Command) string {
+	return strings.ReplaceAll(cmd.CommandPath(), " ", "-")
+}
+
+func writeManPage(w io.Writer, cmd *cobra.Command) error {
+	cmd.InitDefaultHelpFlag() // As in commandToSchema.
+	var b strings.Builder
+	name := manPageName(cmd)
+	fmt.Fprintf(&b, ".TH %q \"1\" \"\" %q \"%s Manual\"\n", strings.ToUpper(name), RootCmd.Name(), RootCmd.Name())
+	fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", name, roffEscape(cmd.Short))
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n\\fB%s\\fP\n", roffEscape(cmd.UseLine()))
+
+	description := cmd.Long
+	if description == "" {
+		description = cmd.Short
+	}
+	b.WriteString(".SH DESCRIPTION\n.nf\n")
+	b.WriteString(roffEscape(description))
+	b.WriteString("\n.fi\n")
+
+	writeManFlags(&b, "OPTIONS", cmd.NonInheritedFlags())
+	writeManFlags(&b, "OPTIONS INHERITED FROM PARENT COMMANDS", cmd.InheritedFlags())
+
+	var seeAlso []string
+	if cmd.HasParent() {
+		seeAlso = append(seeAlso, manPageName(cmd.Parent()))
+	}
+	for _, sub := range cmd.Commands() {
+		if sub.IsAvailableCommand() {
+			seeAlso = append(seeAlso, manPageName(sub))
+		}
+	}
+	if len(seeAlso) > 0 {
+		b.WriteString(".SH SEE ALSO\n")
+		for i, page := range seeAlso {
+			if i > 0 {
+				b.WriteString(",\n")
+			}
+			fmt.Fprintf(&b, "\\fB%s\\fP(1)", page)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeManFlags(b *strings.Builder, section string, flags *pflag.FlagSet) {
+	if !flags.HasAvailableFlags() {
+		return
+	}
+	fmt.Fprintf(b, ".SH %s\n", section)
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		b.WriteString(".TP\n")
+		if f.Shorthand != "" {
+			fmt.Fprintf(b, "\\fB\\-%s\\fP, ", f.Shorthand)
+		}
+		fmt.Fprintf(b, "\\fB\\-\\-%s\\fP", f.Name)
+		if f.Value.Type() != "bool" {
+			fmt.Fprintf(b, "=\\fI%s\\fP", roffEscape(f.Value.Type()))
+		}
+		fmt.Fprintf(b, "\n%s\n", roffEscape(f.Usage))
+	})
+}
+
+// roffEscape escapes text so it is displayed literally by roff.
+func roffEscape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		// Lines starting with a control character would be interpreted as
+		// requests.
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
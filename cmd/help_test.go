@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHelpJSON(t *testing.T) {
+	schemaFile := makeTempFile(t, nil)
+	defer os.Remove(schemaFile)
+
+	RootCmd.SetArgs([]string{"help", "--json", "--output", schemaFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	helpJSON, output = false, "" // "flush" the flag values from the last Execute() cmd
+
+	data, err := ioutil.ReadFile(schemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root commandSchema
+	if err := json.Unmarshal(data, &root); err != nil {
+		t.Fatalf("help --json output is not valid JSON: %v", err)
+	}
+	if root.Name != "gotpm" {
+		t.Errorf("got root command %q, want gotpm", root.Name)
+	}
+
+	var seal *commandSchema
+	for i, sub := range root.Subcommands {
+		if sub.Name == "help" {
+			t.Error("the help command should not be described")
+		}
+		if sub.Name == "seal" {
+			seal = &root.Subcommands[i]
+		}
+	}
+	if seal == nil {
+		t.Fatal("seal command not described")
+	}
+	if seal.Path != "gotpm seal" {
+		t.Errorf("got seal path %q, want \"gotpm seal\"", seal.Path)
+	}
+	if !hasFlag(seal.Flags, "pcrs") {
+		t.Error("seal command is missing the --pcrs flag")
+	}
+	if !hasFlag(seal.InheritedFlags, "quiet") {
+		t.Error("seal command is missing the inherited --quiet flag")
+	}
+}
+
+func hasFlag(flags []flagSchema, name string) bool {
+	for _, f := range flags {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHelpMan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotpm_man_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	RootCmd.SetArgs([]string{"help", "--man", dir})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	helpManDir = "" // "flush" the --man value from the last Execute() cmd
+
+	for _, page := range []string{"gotpm.1", "gotpm-seal.1", "gotpm-read-pcr.1"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, page))
+		if err != nil {
+			t.Errorf("manual page %s not written: %v", page, err)
+			continue
+		}
+		if !strings.HasPrefix(string(data), ".TH ") || !strings.Contains(string(data), ".SH NAME\n") {
+			t.Errorf("manual page %s is not a valid manual page:\n%s", page, data)
+		}
+	}
+}
+
+func TestRoffEscape(t *testing.T) {
+	got := roffEscape(".start\n'quote\nback\\slash")
+	want := "\\&.start\n\\&'quote\nback\\eslash"
+	if got != want {
+		t.Errorf("roffEscape() = %q, want %q", got, want)
+	}
+}
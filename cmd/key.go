@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// keyArgHelp documents the key arguments accepted by loadKey.
+const keyArgHelp = `A key is given as one of:
+	ek, srk, ak      the standard endorsement, storage root and attestation
+	                 keys, of the --algo type
+	gce-ak           the GCE AK (only on GCE VMs)
+	idevid, ldevid   the DevID keys in the endorsement and owner hierarchies
+	0x81000001       the key at a persistent handle
+	file:<path>      the key in a TSS2 PEM file (see client.LoadTSS2PEM)
+	<name>           the key at a persistent handle registered with
+	                 "gotpm names add", with --registry or --registry-index`
+
+// namedKeys are the keys loadKey accepts by name, with their RSA and ECC
+// variants.
+var namedKeys = map[string]struct {
+	rsa, ecc func(io.ReadWriter) (*client.Key, error)
+}{
+	"ek":     {client.EndorsementKeyRSA, client.EndorsementKeyECC},
+	"srk":    {client.StorageRootKeyRSA, client.StorageRootKeyECC},
+	"ak":     {client.AttestationKeyRSA, client.AttestationKeyECC},
+	"gce-ak": {client.GceAttestationKeyRSA, client.GceAttestationKeyECC},
+	"idevid": {client.IDevIDKeyRSA, client.IDevIDKeyECC},
+	"ldevid": {client.LDevIDKeyRSA, client.LDevIDKeyECC},
+}
+
+// loadKey loads the key given by a key argument (see keyArgHelp).
+func loadKey(rw io.ReadWriter, arg string) (*client.Key, error) {
+	if named, ok := namedKeys[arg]; ok {
+		fmt.Fprintf(debugOutput(), "Loading %s (%s)\n", arg, algos[keyAlgo])
+		switch keyAlgo {
+		case tpm2.AlgRSA:
+			return named.rsa(rw)
+		case tpm2.AlgECC:
+			return named.ecc(rw)
+		default:
+			panic("unexpected keyAlgo")
+		}
+	}
+	if strings.HasPrefix(arg, "file:") {
+		path := strings.TrimPrefix(arg, "file:")
+		fmt.Fprintf(debugOutput(), "Loading key from %s\n", path)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return client.LoadTSS2PEM(rw, data)
+	}
+
+	var handle tpmutil.Handle
+	if value, err := strconv.ParseUint(arg, 0, 32); err == nil {
+		handle = tpmutil.Handle(value)
+	} else if registryFile == "" && registryIndex == 0 {
+		return nil, fmt.Errorf("unknown key %q", arg)
+	} else {
+		registry, err := openRegistry(rw)
+		if err != nil {
+			return nil, err
+		}
+		if handle, err = resolveHandle(registry, arg); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintf(debugOutput(), "Loading key at handle 0x%x\n", handle)
+	return client.LoadPersistentKey(rw, handle)
+}
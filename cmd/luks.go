@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+
+	"github.com/google/go-tpm-tools/luks"
+	"github.com/google/go-tpm/tpm2"
+)
+
+var (
+	luksDevice     string
+	luksName       string
+	luksHashAlgo   = tpm2.AlgSHA256
+	luksCryptsetup string
+)
+
+var luksCmd = &cobra.Command{
+	Use:   "luks",
+	Short: "Bind LUKS2 volumes to the TPM",
+	Long: `Unlock LUKS2 (dm-crypt) volumes with passphrases sealed to the TPM
+
+"gotpm luks enroll" seals a new passphrase to the current values of the PCRs
+given by --pcrs, adds it to a keyslot of the volume, and records the sealed
+passphrase in a "systemd-tpm2" LUKS2 token. "gotpm luks unlock" unseals the
+passphrase and opens the volume, as can systemd-cryptsetup (with
+tpm2-device=auto in /etc/crypttab) in early boot. Volumes enrolled with
+systemd-cryptenroll --tpm2-device can also be unlocked by "gotpm luks unlock".
+
+Both commands run the cryptsetup tool, and so must run as root.`,
+	Args: cobra.NoArgs,
+}
+
+var luksEnrollCmd = &cobra.Command{
+	Use:   "enroll",
+	Short: "Add a keyslot unlocked by the TPM to a LUKS2 volume",
+	Long: `Seal a new passphrase to the PCRs given by --pcrs, and add it to the volume
+
+The volume given by --device is unlocked with an existing passphrase (such as a
+recovery key) read from the input, exactly as cryptsetup reads --key-file, so
+a trailing newline is part of the passphrase. The new passphrase is added to
+the first free keyslot, which is written to the output, and a token for it is
+imported into the volume's LUKS2 header.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		fmt.Fprintln(debugOutput(), "Reading existing passphrase")
+		existing, err := ioutil.ReadAll(dataInput())
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(debugOutput(), "Sealing passphrase to PCRs: %v\n", pcrs)
+		enrollment, err := luks.Enroll(rwc, tpm2.PCRSelection{Hash: luksHashAlgo, PCRs: pcrs})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(debugOutput(), "Adding keyslot to %s\n", luksDevice)
+		keyslot, err := luks.Cryptsetup{Path: luksCryptsetup}.AddKeyslot(cmd.Context(), luksDevice, existing, enrollment)
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			return writeJSON(dataOutput(), luksReport{Device: luksDevice, Keyslot: &keyslot, Token: &enrollment.Token})
+		}
+		_, err = fmt.Fprintln(dataOutput(), keyslot)
+		return err
+	},
+}
+
+var luksUnlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Open a LUKS2 volume with a passphrase sealed to the TPM",
+	Long: `Open the volume given by --device as /dev/mapper/<name>
+
+The passphrase of each "systemd-tpm2" token of the volume is unsealed in turn,
+until one opens the volume. This fails if the PCRs no longer have the values
+the passphrases were sealed to, in which case the volume must be opened with
+another passphrase, and enrolled again.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		fmt.Fprintf(debugOutput(), "Unlocking %s\n", luksDevice)
+		if err := (luks.Cryptsetup{Path: luksCryptsetup}).Unlock(cmd.Context(), rwc, luksDevice, luksName); err != nil {
+			return err
+		}
+		return reportResult(luksReport{Device: luksDevice, Name: luksName},
+			"Opened %s as /dev/mapper/%s\n", luksDevice, luksName)
+	},
+}
+
+// luksReport is the JSON output of "gotpm luks" commands.
+type luksReport struct {
+	Device  string      `json:"device"`
+	Keyslot *int        `json:"keyslot,omitempty"`
+	Token   *luks.Token `json:"token,omitempty"`
+	Name    string      `json:"name,omitempty"`
+}
+
+func init() {
+	RootCmd.AddCommand(luksCmd)
+	hideHelp(luksCmd)
+	luksCmd.AddCommand(luksEnrollCmd)
+	luksCmd.AddCommand(luksUnlockCmd)
+	luksCmd.PersistentFlags().StringVar(&luksDevice, "device", "",
+		"path of the LUKS2 volume, such as /dev/sda2")
+	luksCmd.MarkPersistentFlagRequired("device")
+	luksCmd.PersistentFlags().StringVar(&luksCryptsetup, "cryptsetup", "",
+		"path of the cryptsetup tool (by default, found in the PATH)")
+	addInputFlag(luksEnrollCmd)
+	addOutputFlag(luksEnrollCmd)
+	addPCRsFlag(luksEnrollCmd)
+	luksEnrollCmd.MarkPersistentFlagRequired("pcrs")
+	addHashAlgoFlag(luksEnrollCmd, &luksHashAlgo)
+	luksUnlockCmd.Flags().StringVar(&luksName, "name", "",
+		"name of the opened volume, under /dev/mapper")
+	luksUnlockCmd.MarkFlagRequired("name")
+}
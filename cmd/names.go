@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpmutil"
+	"github.com/spf13/cobra"
+)
+
+var (
+	nameHandle uint32
+	nameIndex  uint32
+	nameFile   string
+)
+
+var namesCmd = &cobra.Command{
+	Use:   "names",
+	Short: "Manage names for persistent handles, NV indices and sealed blobs",
+	Long: `Give human-readable names to persistent handles, NV indices and sealed blobs
+
+The names are kept in a registry, either in an NV index (--registry-index) or
+in a file signed by a TPM key (--registry). Each name records a fingerprint of
+its target, so "gotpm names check" can detect targets which were replaced or
+modified after they were named.`,
+	Args: cobra.NoArgs,
+}
+
+var namesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the registered names",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+		registry, err := openRegistry(rwc)
+		if err != nil {
+			return err
+		}
+
+		out := dataOutput()
+		if jsonOutput() {
+			report := namesReport{Names: []nameReport{}}
+			for _, entry := range registry.Entries() {
+				report.Names = append(report.Names, newNameReport(entry))
+			}
+			return writeJSON(out, report)
+		}
+		for _, entry := range registry.Entries() {
+			if _, err := fmt.Fprintf(out, "%s %s\n", entry.GetName(), targetString(entry)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var namesAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Register a name",
+	Long: `Register a name for a persistent handle (--handle), an NV index (--index) or a
+file holding sealed data (--file)
+
+Names must start with a letter, and contain only a-z, 0-9, '.', '_' and '-'.
+A name cannot be registered twice, and a target can only have one name.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		given := 0
+		for _, set := range []bool{nameHandle != 0, nameIndex != 0, nameFile != ""} {
+			if set {
+				given++
+			}
+		}
+		if given != 1 {
+			return errors.New("exactly one of --handle, --index and --file must be given")
+		}
+
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+		registry, err := openRegistry(rwc)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case nameHandle != 0:
+			err = registry.AddPersistentHandle(args[0], tpmutil.Handle(nameHandle))
+		case nameIndex != 0:
+			err = registry.AddNVIndex(args[0], nameIndex)
+		default:
+			err = registry.AddSealedBlob(args[0], nameFile)
+		}
+		if err != nil {
+			return err
+		}
+		entry, err := registry.Lookup(args[0])
+		if err != nil {
+			return err
+		}
+		return reportResult(newNameReport(entry), "Name %q registered\n", args[0])
+	},
+}
+
+var namesRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Unregister a name",
+	Long:  "Unregister a name, without modifying its target",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+		registry, err := openRegistry(rwc)
+		if err != nil {
+			return err
+		}
+		if err := registry.Remove(args[0]); err != nil {
+			return err
+		}
+		return reportResult(nameReport{Name: args[0]}, "Name %q removed\n", args[0])
+	},
+}
+
+var namesCheckCmd = &cobra.Command{
+	Use:   "check [name...]",
+	Short: "Check that named targets are unchanged",
+	Long: `Check that the targets of the given names (or of all names) still match the
+fingerprints recorded when they were registered
+
+Persistent objects must have the same Name, NV indices the same public area,
+and sealed blob files the same contents. Fails if any target is missing or has
+changed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+		registry, err := openRegistry(rwc)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			for _, entry := range registry.Entries() {
+				args = append(args, entry.GetName())
+			}
+		}
+		report := namesReport{Names: []nameReport{}}
+		failed := 0
+		for _, name := range args {
+			checked := nameReport{Name: name, OK: new(bool)}
+			if err := registry.CheckDrift(name); err != nil {
+				fmt.Fprintln(messageOutput(), err)
+				checked.Error = err.Error()
+				failed++
+			} else {
+				*checked.OK = true
+			}
+			report.Names = append(report.Names, checked)
+		}
+		if failed > 0 {
+			if jsonOutput() {
+				writeJSON(os.Stdout, report)
+			}
+			return fmt.Errorf("%d of %d names failed the check", failed, len(args))
+		}
+		return reportResult(report, "%d names checked\n", len(args))
+	},
+}
+
+// namesReport is the JSON output of "gotpm names list" and "gotpm names check".
+type namesReport struct {
+	Names []nameReport `json:"names"`
+}
+
+// nameReport describes a name, and for "gotpm names check", whether its target
+// is unchanged.
+type nameReport struct {
+	Name   string      `json:"name"`
+	Handle *jsonHandle `json:"handle,omitempty"`
+	Index  *jsonHandle `json:"index,omitempty"`
+	File   string      `json:"file,omitempty"`
+	OK     *bool       `json:"ok,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+func newNameReport(entry *pb.RegistryEntry) nameReport {
+	report := nameReport{Name: entry.GetName()}
+	switch target := entry.GetTarget().(type) {
+	case *pb.RegistryEntry_PersistentHandle:
+		handle := jsonHandle(target.PersistentHandle)
+		report.Handle = &handle
+	case *pb.RegistryEntry_NvIndex:
+		index := jsonHandle(target.NvIndex)
+		report.Index = &index
+	case *pb.RegistryEntry_SealedBlobPath:
+		report.File = target.SealedBlobPath
+	}
+	return report
+}
+
+func targetString(entry *pb.RegistryEntry) string {
+	switch target := entry.GetTarget().(type) {
+	case *pb.RegistryEntry_PersistentHandle:
+		return fmt.Sprintf("handle 0x%x", target.PersistentHandle)
+	case *pb.RegistryEntry_NvIndex:
+		return fmt.Sprintf("index 0x%x", target.NvIndex)
+	case *pb.RegistryEntry_SealedBlobPath:
+		return "file " + target.SealedBlobPath
+	default:
+		return "unknown"
+	}
+}
+
+// Resolve a persistent handle given as a number, or as a name registered for
+// a persistent handle. Registered handles must not have drifted.
+func resolveHandle(registry *client.NameRegistry, name string) (tpmutil.Handle, error) {
+	entry, err := registry.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := entry.GetTarget().(*pb.RegistryEntry_PersistentHandle); !ok {
+		return 0, fmt.Errorf("%q is not the name of a persistent handle", name)
+	}
+	if err := registry.CheckDrift(name); err != nil {
+		return 0, err
+	}
+	return tpmutil.Handle(entry.GetPersistentHandle()), nil
+}
+
+func init() {
+	RootCmd.AddCommand(namesCmd)
+	hideHelp(namesCmd)
+	namesCmd.AddCommand(namesListCmd)
+	namesCmd.AddCommand(namesAddCmd)
+	namesCmd.AddCommand(namesRemoveCmd)
+	namesCmd.AddCommand(namesCheckCmd)
+	addRegistryFlags(namesCmd)
+	addOutputFlag(namesListCmd)
+	namesAddCmd.PersistentFlags().Uint32Var(&nameHandle, "handle", 0, "persistent handle to name")
+	namesAddCmd.PersistentFlags().Uint32Var(&nameIndex, "index", 0, "NV index to name")
+	namesAddCmd.PersistentFlags().StringVar(&nameFile, "file", "", "sealed blob file to name")
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+)
+
+func TestNames(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	dir, err := ioutil.TempDir("", "gotpm_names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	registry := filepath.Join(dir, "registry")
+
+	key, err := client.NewPersistentKey(rwc, tpm2.HandleOwner, client.AKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Close()
+	blob := filepath.Join(dir, "sealed")
+	if err := ioutil.WriteFile(blob, []byte("sealed data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"names", "add", "signing-key", "--handle", fmt.Sprint(uint32(key.Handle()))},
+		{"names", "add", "disk-key", "--file", blob},
+		{"names", "check"},
+	} {
+		RootCmd.SetArgs(append(args, "--registry", registry, "--quiet"))
+		if err := RootCmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		nameHandle, nameFile = 0, ""
+	}
+
+	RootCmd.SetArgs([]string{"names", "add", "other-key", "--handle", fmt.Sprint(uint32(key.Handle())),
+		"--registry", registry, "--quiet"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("naming a handle twice should fail")
+	}
+	nameHandle = 0
+
+	listFile := filepath.Join(dir, "list")
+	RootCmd.SetArgs([]string{"names", "list", "--registry", registry, "--output", listFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := ioutil.ReadFile(listFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("disk-key file %s\nsigning-key handle 0x%x\n", blob, key.Handle())
+	if string(list) != want {
+		t.Errorf("names list wrote:\n%s\nwant:\n%s", list, want)
+	}
+	output = ""
+
+	if err := ioutil.WriteFile(blob, []byte("modified"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	RootCmd.SetArgs([]string{"names", "check", "disk-key", "--registry", registry, "--quiet"})
+	if err := RootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "failed the check") {
+		t.Errorf("checking a modified blob returned %v", err)
+	}
+
+	RootCmd.SetArgs([]string{"persistent", "evict", "signing-key", "--registry", registry, "--quiet"})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	registryFile = ""
+	if _, _, _, err := tpm2.ReadPublic(rwc, key.Handle()); err == nil {
+		t.Error("named key was not evicted")
+	}
+}
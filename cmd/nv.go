@@ -0,0 +1,508 @@
+package cmd
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/server"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/spf13/cobra"
+)
+
+var (
+	nvSize       uint16
+	nvAttributes []string
+	nvHashAlgo   = tpm2.AlgSHA256
+	nvDataFormat string
+)
+
+// nvIndexArgHelp documents the index arguments accepted by parseNVIndex.
+const nvIndexArgHelp = `An index is given as a handle (such as 0x01500000), or as one of the
+standard indexes, of the --algo type:
+	ek-cert          the EK certificate
+	ek-cert-high     the certificate of the high range EK (see "gotpm pubkey")
+	ek-nonce         the EK template's nonce
+	ek-template      the EK template
+	gce-ak-cert      the GCE AK certificate (only on GCE VMs)
+	gce-ak-template  the GCE AK template (only on GCE VMs)`
+
+// namedIndexes are the NV indexes parseNVIndex accepts by name, with their RSA
+// and ECC variants.
+var namedIndexes = map[string]struct {
+	rsa, ecc uint32
+	cert     bool
+}{
+	"ek-cert":         {client.EKCertNVIndexRSA, client.EKCertNVIndexECC, true},
+	"ek-cert-high":    {client.EKCertNVIndexRSAHighRange, client.EKCertNVIndexECCHighRange, true},
+	"ek-nonce":        {client.EKNonceNVIndexRSA, client.EKNonceNVIndexECC, false},
+	"ek-template":     {client.EKTemplateNVIndexRSA, client.EKTemplateNVIndexECC, false},
+	"gce-ak-cert":     {client.GceAKCertNVIndexRSA, client.GceAKCertNVIndexECC, true},
+	"gce-ak-template": {client.GceAKTemplateNVIndexRSA, client.GceAKTemplateNVIndexECC, false},
+}
+
+// parseNVIndex returns the handle of an index argument (see nvIndexArgHelp),
+// and whether the index holds a certificate.
+func parseNVIndex(arg string) (uint32, bool, error) {
+	if named, ok := namedIndexes[arg]; ok {
+		if keyAlgo == tpm2.AlgECC {
+			return named.ecc, named.cert, nil
+		}
+		return named.rsa, named.cert, nil
+	}
+	index, err := strconv.ParseUint(arg, 0, 32)
+	if err != nil || index>>24 != uint64(tpm2.HandleTypeNVIndex) {
+		return 0, false, fmt.Errorf("unknown NV index %q", arg)
+	}
+	for _, named := range namedIndexes {
+		if named.cert && (uint32(index) == named.rsa || uint32(index) == named.ecc) {
+			return uint32(index), true, nil
+		}
+	}
+	return uint32(index), false, nil
+}
+
+// nvAttrNames are the names of the NV attributes, as used in Part 2 of the
+// spec (without the TPMA_NV_ prefix) and by other TPM tools.
+var nvAttrNames = []struct {
+	attr tpm2.NVAttr
+	name string
+	// Whether the attribute can be given to "gotpm nv define"
+	settable bool
+}{
+	{tpm2.AttrPPWrite, "ppwrite", false},
+	{tpm2.AttrOwnerWrite, "ownerwrite", false},
+	{tpm2.AttrAuthWrite, "authwrite", false},
+	{tpm2.AttrPolicyWrite, "policywrite", false},
+	{tpm2.AttrPolicyDelete, "policy_delete", false},
+	{tpm2.AttrWriteLocked, "writelocked", false},
+	{tpm2.AttrWriteAll, "writeall", true},
+	{tpm2.AttrWriteDefine, "writedefine", true},
+	{tpm2.AttrWriteSTClear, "write_stclear", true},
+	{tpm2.AttrGlobalLock, "globallock", true},
+	{tpm2.AttrPPRead, "ppread", false},
+	{tpm2.AttrOwnerRead, "ownerread", false},
+	{tpm2.AttrAuthRead, "authread", false},
+	{tpm2.AttrPolicyRead, "policyread", false},
+	{tpm2.AttrNoDA, "no_da", false},
+	{tpm2.AttrOrderly, "orderly", true},
+	{tpm2.AttrClearSTClear, "clear_stclear", true},
+	{tpm2.AttrReadLocked, "readlocked", false},
+	{tpm2.AttrWritten, "written", false},
+	{tpm2.AttrPlatformCreate, "platformcreate", false},
+	{tpm2.AttrReadSTClear, "read_stclear", true},
+}
+
+// nvTypes are the names of the NV index types, in the TPM_NT field of the NV
+// attributes.
+var nvTypes = map[tpm2.NVAttr]string{
+	0x0: "ordinary",
+	0x1: "counter",
+	0x2: "bits",
+	0x4: "extend",
+	0x8: "pin_fail",
+	0x9: "pin_pass",
+}
+
+func formatNVAttributes(attrs tpm2.NVAttr) string {
+	return strings.Join(nvAttributeNames(attrs), "|")
+}
+
+// nvAttributeNames returns the names of an index's type and attributes.
+func nvAttributeNames(attrs tpm2.NVAttr) []string {
+	names := []string{nvTypes[attrs>>4&0xF]}
+	for _, a := range nvAttrNames {
+		if attrs&a.attr != 0 {
+			names = append(names, a.name)
+		}
+	}
+	return names
+}
+
+func parseNVAttributes(names []string) (tpm2.NVAttr, error) {
+	var attrs tpm2.NVAttr
+	for _, name := range names {
+		found := false
+		for _, a := range nvAttrNames {
+			if a.settable && a.name == name {
+				attrs |= a.attr
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown or unsupported NV attribute %q", name)
+		}
+	}
+	return attrs, nil
+}
+
+func settableNVAttributes() string {
+	var names []string
+	for _, a := range nvAttrNames {
+		if a.settable {
+			names = append(names, a.name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+var nvCmd = &cobra.Command{
+	Use:   "nv",
+	Short: "Manage NV indexes",
+	Long: `Define, write, read and undefine indexes in the TPM's NV memory
+
+Indexes are defined with the owner hierarchy and an empty password. An index
+can also be restricted to when PCRs have their current values, by defining
+it with --pcrs; the same --pcrs must then be given to read or write it.
+
+` + nvIndexArgHelp,
+	Args: cobra.NoArgs,
+}
+
+var nvDefineCmd = &cobra.Command{
+	Use:   "define <index>",
+	Short: "Define an NV index",
+	Long: `Define an NV index holding --size bytes of data
+
+Without --pcrs, the index can be read and written by the owner, or with the
+index's empty password. With --pcrs, the index can only be read and written
+when the PCRs (in the --hash-algo bank) have their current values.
+
+Additional attributes can be given with --attributes, for example writedefine
+to allow the index to be made read-only. The supported attributes are:
+` + settableNVAttributes() + `.
+
+For example, to define a 32 byte index which can only be used while PCRs 0
+and 7 are unchanged:
+	gotpm nv define 0x01500000 --size 32 --pcrs 0,7`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		index, _, err := parseNVIndex(args[0])
+		if err != nil {
+			return err
+		}
+		if nvSize == 0 {
+			return errors.New("--size must be given")
+		}
+		attrs, err := parseNVAttributes(nvAttributes)
+		if err != nil {
+			return err
+		}
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		fmt.Fprintf(debugOutput(), "Defining NV index 0x%x with PCRs %v\n", index, pcrs)
+		opts := client.NVIndexOpts{
+			Size:       nvSize,
+			PCRs:       tpm2.PCRSelection{Hash: nvHashAlgo, PCRs: pcrs},
+			Attributes: attrs,
+		}
+		nv, err := client.DefineNVIndex(rwc, index, opts)
+		if err != nil {
+			return err
+		}
+		return reportResult(newNVIndexReport(nv.Public()),
+			"Defined NV index 0x%x holding %d bytes\n", index, nvSize)
+	},
+}
+
+var nvWriteCmd = &cobra.Command{
+	Use:   "write <index>",
+	Short: "Write an NV index",
+	Long: `Write the input to an NV index, replacing its contents
+
+The input must be the size of the index.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		index, _, err := parseNVIndex(args[0])
+		if err != nil {
+			return err
+		}
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		fmt.Fprintln(debugOutput(), "Reading data to write")
+		data, err := ioutil.ReadAll(dataInput())
+		if err != nil {
+			return err
+		}
+		nv, err := client.OpenNVIndex(rwc, index, tpm2.PCRSelection{Hash: nvHashAlgo, PCRs: pcrs})
+		if err != nil {
+			return err
+		}
+		if err := nv.Write(data); err != nil {
+			return err
+		}
+		return reportResult(newNVIndexReport(nv.Public()),
+			"Wrote %d bytes to NV index 0x%x\n", len(data), index)
+	},
+}
+
+var nvReadCmd = &cobra.Command{
+	Use:   "read <index>",
+	Short: "Read an NV index",
+	Long: `Write the contents of an NV index to the output
+
+The --data-format flag selects the output:
+	raw   the contents of the index
+	pem   the certificate in the index, as PEM
+	text  a description of the index, and of the certificate in it
+By default, certificate indexes (ek-cert and gce-ak-cert) are written as pem,
+and other indexes as raw. With --format=json, the index, its hex-encoded
+contents, and the certificate in it are instead written as a JSON object.
+
+For example, to show the RSA and ECC EK certificates:
+	gotpm nv read ek-cert --data-format text
+	gotpm nv read ek-cert --algo ecc --data-format text`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		index, isCert, err := parseNVIndex(args[0])
+		if err != nil {
+			return err
+		}
+		format := nvDataFormat
+		if format == "" {
+			format = "raw"
+			if isCert {
+				format = "pem"
+			}
+		}
+		if format != "raw" && format != "pem" && format != "text" {
+			return fmt.Errorf("unknown data format %q", format)
+		}
+		if jsonOutput() {
+			format = formatJSON
+		}
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		fmt.Fprintf(debugOutput(), "Reading NV index 0x%x\n", index)
+		nv, err := client.OpenNVIndex(rwc, index, tpm2.PCRSelection{Hash: nvHashAlgo, PCRs: pcrs})
+		if err != nil {
+			return err
+		}
+		data, err := nv.Read()
+		if err != nil {
+			return err
+		}
+		switch format {
+		case "raw":
+			_, err = dataOutput().Write(data)
+			return err
+		case "pem":
+			cert, err := server.ParseEKCertificate(data)
+			if err != nil {
+				return fmt.Errorf("NV index 0x%x does not hold a certificate: %w", index, err)
+			}
+			return pem.Encode(dataOutput(), &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		case formatJSON:
+			report := newNVIndexReport(nv.Public())
+			report.Data = data
+			if cert, err := server.ParseEKCertificate(data); err == nil {
+				report.Certificate = newCertificateReport(cert)
+			} else if isCert {
+				return fmt.Errorf("NV index 0x%x does not hold a certificate: %w", index, err)
+			}
+			return writeJSON(dataOutput(), report)
+		default:
+			return describeNVIndex(dataOutput(), nv.Public(), data, isCert)
+		}
+	},
+}
+
+// describeNVIndex writes a description of an NV index, and of the certificate
+// it holds if any.
+func describeNVIndex(w io.Writer, pub tpm2.NVPublic, data []byte, isCert bool) error {
+	fmt.Fprintf(w, "Index:      0x%x\n", uint32(pub.NVIndex))
+	fmt.Fprintf(w, "Size:       %d bytes\n", pub.DataSize)
+	fmt.Fprintf(w, "Attributes: %s\n", formatNVAttributes(pub.Attributes))
+	cert, err := server.ParseEKCertificate(data)
+	if err != nil {
+		if isCert {
+			return fmt.Errorf("NV index 0x%x does not hold a certificate: %w", uint32(pub.NVIndex), err)
+		}
+		_, err = fmt.Fprintf(w, "Data:       %x\n", data)
+		return err
+	}
+	fmt.Fprintf(w, "Certificate:\n")
+	fmt.Fprintf(w, "  Subject:      %s\n", cert.Subject)
+	fmt.Fprintf(w, "  Issuer:       %s\n", cert.Issuer)
+	fmt.Fprintf(w, "  Serial:       %x\n", cert.SerialNumber)
+	fmt.Fprintf(w, "  Not before:   %s\n", cert.NotBefore)
+	fmt.Fprintf(w, "  Not after:    %s\n", cert.NotAfter)
+	fmt.Fprintf(w, "  Key:          %s\n", cert.PublicKeyAlgorithm)
+	if cert.TPM != nil {
+		fmt.Fprintf(w, "  Manufacturer: %s (0x%08x)\n", cert.TPM.GetManufacturer(), cert.TPM.GetManufacturerId())
+		fmt.Fprintf(w, "  Model:        %s\n", cert.TPM.GetModel())
+		fmt.Fprintf(w, "  Firmware:     0x%x\n", cert.TPM.GetFirmwareVersion())
+	}
+	if cert.Specification != nil {
+		fmt.Fprintf(w, "  Spec:         TPM %s level %d revision %d\n",
+			cert.Specification.Family, cert.Specification.Level, cert.Specification.Revision)
+	}
+	if cert.GCEInstance != nil {
+		fmt.Fprintf(w, "  GCE instance: %s (%s, project %s)\n", cert.GCEInstance.GetInstanceName(),
+			cert.GCEInstance.GetZone(), cert.GCEInstance.GetProjectId())
+	}
+	return nil
+}
+
+// nvIndexReport is the JSON description of an NV index, written by "gotpm nv"
+// commands.
+type nvIndexReport struct {
+	Index       jsonHandle         `json:"index"`
+	Size        uint16             `json:"size,omitempty"`
+	Attributes  []string           `json:"attributes,omitempty"`
+	Data        hexBytes           `json:"data,omitempty"`
+	Certificate *certificateReport `json:"certificate,omitempty"`
+}
+
+func newNVIndexReport(pub tpm2.NVPublic) nvIndexReport {
+	return nvIndexReport{
+		Index:      jsonHandle(pub.NVIndex),
+		Size:       pub.DataSize,
+		Attributes: nvAttributeNames(pub.Attributes),
+	}
+}
+
+// nvListReport is the JSON output of "gotpm nv list".
+type nvListReport struct {
+	Indexes         []nvIndexReport `json:"indexes"`
+	PersistentAvail int             `json:"persistent_avail"`
+}
+
+// certificateReport is the JSON description of an EK or AK certificate.
+type certificateReport struct {
+	Subject         string `json:"subject"`
+	Issuer          string `json:"issuer"`
+	Serial          string `json:"serial"`
+	NotBefore       string `json:"not_before"`
+	NotAfter        string `json:"not_after"`
+	KeyAlgorithm    string `json:"key_algorithm"`
+	Manufacturer    string `json:"manufacturer,omitempty"`
+	Model           string `json:"model,omitempty"`
+	FirmwareVersion uint32 `json:"firmware_version,omitempty"`
+	GCEInstance     string `json:"gce_instance,omitempty"`
+	PEM             string `json:"pem"`
+}
+
+func newCertificateReport(cert *server.EKCertificate) *certificateReport {
+	report := &certificateReport{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		Serial:       fmt.Sprintf("%x", cert.SerialNumber),
+		NotBefore:    cert.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:     cert.NotAfter.UTC().Format(time.RFC3339),
+		KeyAlgorithm: cert.PublicKeyAlgorithm.String(),
+		PEM:          string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+	}
+	if cert.TPM != nil {
+		report.Manufacturer = cert.TPM.GetManufacturer()
+		report.Model = cert.TPM.GetModel()
+		report.FirmwareVersion = cert.TPM.GetFirmwareVersion()
+	}
+	if cert.GCEInstance != nil {
+		report.GCEInstance = cert.GCEInstance.GetInstanceName()
+	}
+	return report
+}
+
+var nvUndefineCmd = &cobra.Command{
+	Use:   "undefine <index>",
+	Short: "Undefine an NV index",
+	Long: `Undefine an NV index, using the owner hierarchy and an empty password
+
+The index's contents are lost.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		index, _, err := parseNVIndex(args[0])
+		if err != nil {
+			return err
+		}
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		nv, err := client.OpenNVIndex(rwc, index, tpm2.PCRSelection{})
+		if err != nil {
+			return err
+		}
+		if err := nv.Delete(); err != nil {
+			return err
+		}
+		return reportResult(nvIndexReport{Index: jsonHandle(index)}, "Undefined NV index 0x%x\n", index)
+	},
+}
+
+var nvListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the NV indexes",
+	Long: `Write the defined NV indexes, their sizes and attributes to the output,
+followed by the TPM's estimate of its free NV memory`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		usage, err := client.ReadNVUsage(rwc)
+		if err != nil {
+			return err
+		}
+		w := dataOutput()
+		if jsonOutput() {
+			report := nvListReport{Indexes: []nvIndexReport{}, PersistentAvail: usage.PersistentAvail}
+			for _, pub := range usage.Indexes {
+				report.Indexes = append(report.Indexes, newNVIndexReport(pub))
+			}
+			return writeJSON(w, report)
+		}
+		for _, pub := range usage.Indexes {
+			fmt.Fprintf(w, "0x%08x %5d bytes %s\n", uint32(pub.NVIndex), pub.DataSize, formatNVAttributes(pub.Attributes))
+		}
+		_, err = fmt.Fprintf(w, "Room in NV memory for about %d more persistent objects of %d bytes\n",
+			usage.PersistentAvail, client.DefaultNVObjectSize)
+		return err
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(nvCmd)
+	hideHelp(nvCmd)
+	for _, cmd := range []*cobra.Command{nvDefineCmd, nvWriteCmd, nvReadCmd, nvUndefineCmd} {
+		nvCmd.AddCommand(cmd)
+		addPublicKeyAlgoFlag(cmd)
+	}
+	nvCmd.AddCommand(nvListCmd)
+	for _, cmd := range []*cobra.Command{nvDefineCmd, nvWriteCmd, nvReadCmd} {
+		addPCRsFlag(cmd)
+		addHashAlgoFlag(cmd, &nvHashAlgo)
+	}
+	addInputFlag(nvWriteCmd)
+	addOutputFlag(nvReadCmd)
+	addOutputFlag(nvListCmd)
+	nvDefineCmd.PersistentFlags().Uint16Var(&nvSize, "size", 0, "size of the index's data, in bytes")
+	nvDefineCmd.PersistentFlags().StringSliceVar(&nvAttributes, "attributes", nil,
+		"comma separated list of additional NV attributes")
+	nvReadCmd.PersistentFlags().StringVar(&nvDataFormat, "data-format", "", "output format of the index: raw, pem or text")
+	nvReadCmd.RegisterFlagCompletionFunc("data-format", completeValues("raw", "pem", "text"))
+}
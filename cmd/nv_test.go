@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm-tools/simulator"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// runNV runs a gotpm command.
+func runNV(t *testing.T, args ...string) error {
+	t.Helper()
+	RootCmd.SetArgs(append(args, "--quiet"))
+	return RootCmd.Execute()
+}
+
+// readNV runs a gotpm command, returning what it wrote to its output.
+func readNV(t *testing.T, args ...string) ([]byte, error) {
+	t.Helper()
+	outFile := makeTempFile(t, nil)
+	defer os.Remove(outFile)
+	if err := runNV(t, append(args, "--output", outFile)...); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(outFile)
+}
+
+func resetNVFlags() {
+	pcrs, nvSize, nvAttributes, nvDataFormat, keyAlgo = nil, 0, nil, "", tpm2.AlgRSA
+	input, output, outputFormat = "", "", formatText
+}
+
+func TestNVCommands(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	defer resetNVFlags()
+
+	subtests := []struct {
+		name  string
+		index string
+		pcrs  []string
+	}{
+		{"Password", "0x01500400", nil},
+		{"PCRs", "0x01500401", []string{"--pcrs", "16,23"}},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			defer resetNVFlags()
+			data := []byte("sixteen bytes!!!")
+			inFile := makeTempFile(t, data)
+			defer os.Remove(inFile)
+
+			args := append([]string{"nv", "define", subtest.index, "--size", "16", "--attributes", "writedefine"}, subtest.pcrs...)
+			if err := runNV(t, args...); err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if err := runNV(t, "nv", "undefine", subtest.index); err != nil {
+					t.Error(err)
+				}
+			}()
+
+			// The input must fill the index.
+			short := makeTempFile(t, data[:8])
+			defer os.Remove(short)
+			if err := runNV(t, append([]string{"nv", "write", subtest.index, "--input", short}, subtest.pcrs...)...); err == nil {
+				t.Error("writing less than the index's size should fail")
+			}
+			if err := runNV(t, append([]string{"nv", "write", subtest.index, "--input", inFile}, subtest.pcrs...)...); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readNV(t, append([]string{"nv", "read", subtest.index}, subtest.pcrs...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("nv read = %q, want %q", got, data)
+			}
+
+			text, err := readNV(t, append([]string{"nv", "read", subtest.index, "--data-format", "text"}, subtest.pcrs...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(text), "writedefine") || !strings.Contains(string(text), "written") {
+				t.Errorf("nv read --data-format text = %q, want the writedefine and written attributes", text)
+			}
+			list, err := readNV(t, "nv", "list")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(list), subtest.index) {
+				t.Errorf("nv list = %q, want it to include %s", list, subtest.index)
+			}
+
+			if subtest.pcrs == nil {
+				return
+			}
+			if err := tpm2.PCRExtend(rwc, 16, tpm2.AlgSHA256, make([]byte, sha256.Size), ""); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readNV(t, append([]string{"nv", "read", subtest.index}, subtest.pcrs...)...); err == nil {
+				t.Error("reading the index after changing its PCRs should fail")
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		{"nv", "define", "0x01500402"},
+		{"nv", "define", "0x81000001", "--size", "8"},
+		{"nv", "define", "0x01500402", "--size", "8", "--attributes", "ppwrite"},
+		{"nv", "read", "0x01500402"},
+		{"nv", "read", "ek-cert", "--format", "json"},
+	} {
+		if err := runNV(t, args...); err == nil {
+			t.Errorf("gotpm %v should fail", args)
+		}
+		resetNVFlags()
+	}
+}
+
+func TestNVReadEKCert(t *testing.T) {
+	sim, err := simulator.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.CheckedClose(t, sim)
+	ExternalTPM = sim
+	defer resetNVFlags()
+
+	block, _ := pem.Decode(test.GCEEKCertRSA)
+	if err := sim.SetEKCertificate(simulator.EKCertNVIndexRSA, block.Bytes); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readNV(t, "nv", "read", "ek-cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotBlock, _ := pem.Decode(got); gotBlock == nil || !bytes.Equal(gotBlock.Bytes, block.Bytes) {
+		t.Errorf("nv read ek-cert = %q, want the EK certificate as PEM", got)
+	}
+	raw, err := readNV(t, "nv", "read", "ek-cert", "--data-format", "raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, block.Bytes) {
+		t.Errorf("nv read ek-cert --data-format raw = %x, want %x", raw, block.Bytes)
+	}
+	text, err := readNV(t, "nv", "read", "ek-cert", "--data-format", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Issuer:", "GCE instance:"} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("nv read ek-cert --data-format text = %q, want it to include %q", text, want)
+		}
+	}
+
+	// There is no ECC EK certificate.
+	if _, err := readNV(t, "nv", "read", "ek-cert", "--algo", "ecc"); err == nil {
+		t.Error("reading a missing EK certificate should fail")
+	}
+}
@@ -3,6 +3,10 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"os"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/replay"
 )
 
 // ExternalTPM can be set to run tests against an TPM initialized by an
@@ -12,6 +16,21 @@ import (
 // by the external package.
 var ExternalTPM io.ReadWriter
 
+var (
+	tpmPath    string
+	recordPath string
+)
+
+func init() {
+	RootCmd.PersistentFlags().StringVar(&tpmPath, "tpm-path", "",
+		"TPM device path, or TCTI-style configuration such as device:/dev/tpmrm0,\n"+
+			"swtpm:host=localhost,port=2321 or mssim:host=localhost,port=2321\n"+
+			"(defaults to "+client.DefaultTPMDescription+")")
+	RootCmd.PersistentFlags().StringVar(&recordPath, "record", "",
+		"record all TPM commands and responses to this file, for reproducing bugs.\n"+
+			"The recording contains any secrets sent to the TPM")
+}
+
 type ignoreClose struct {
 	io.ReadWriter
 }
@@ -20,11 +39,51 @@ func (ic ignoreClose) Close() error {
 	return nil
 }
 
+// EventLog returns the event log of the wrapped TPM, which may implement
+// client.EventLogGetter.
+func (ic ignoreClose) EventLog() ([]byte, error) {
+	return client.GetEventLog(ic.ReadWriter)
+}
+
+// recordingTPM closes both the TPM and the recording file.
+type recordingTPM struct {
+	*replay.Recorder
+	file *os.File
+}
+
+func (r recordingTPM) Close() error {
+	err := r.Recorder.Close()
+	if fileErr := r.file.Close(); err == nil {
+		err = fileErr
+	}
+	return err
+}
+
 func openTpm() (io.ReadWriteCloser, error) {
+	rwc, err := openTpmImpl()
+	if err != nil || recordPath == "" {
+		return rwc, err
+	}
+	// The recording can contain secrets, so only the user may read it.
+	file, err := os.OpenFile(recordPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		rwc.Close()
+		return nil, fmt.Errorf("creating recording: %w", err)
+	}
+	recorder, err := replay.NewRecorder(rwc, file)
+	if err != nil {
+		rwc.Close()
+		file.Close()
+		return nil, err
+	}
+	return recordingTPM{recorder, file}, nil
+}
+
+func openTpmImpl() (io.ReadWriteCloser, error) {
 	if ExternalTPM != nil {
 		return ignoreClose{ExternalTPM}, nil
 	}
-	rwc, err := openImpl()
+	rwc, err := client.OpenTPM(tpmPath)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to TPM: %w", err)
 	}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm-tools/replay"
+)
+
+func TestRecord(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	defer func() { recordPath = "" }()
+
+	path := filepath.Join(t.TempDir(), "recording")
+	RootCmd.SetArgs([]string{"flush", "all", "--quiet", "--record", path})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		t.Errorf("recording has permissions %v, want no group or other access", info.Mode().Perm())
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	replayer, err := replay.NewReplayer(file)
+	if err != nil {
+		t.Fatalf("failed to parse recording: %v", err)
+	}
+	if replayer.Remaining() == 0 {
+		t.Error("recording does not contain any commands")
+	}
+}
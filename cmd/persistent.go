@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"github.com/spf13/cobra"
+)
+
+var forceEvict bool
+
+var persistentCmd = &cobra.Command{
+	Use:   "persistent",
+	Short: "Manage keys persisted in the TPM",
+	Long: `List and evict objects at the TPM's persistent handles
+
+Generating a key (especially an RSA primary key) can take several seconds, so
+gotpm persists keys it will use again: the default endorsement and owner keys
+at the handles reserved by the TCG, and keys created with "--persist" between
+0x81008F80 and 0x81008FFF.`,
+	Args: cobra.NoArgs,
+}
+
+var persistentListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the persistent objects",
+	Long: `Write the handle, type and template digest of each persistent object
+
+The template digest is the SHA-256 digest of the object's public area without
+its unique field, so keys created from the same template have the same digest.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		objects, err := client.PersistentObjects(rwc)
+		if err != nil {
+			return err
+		}
+		out := dataOutput()
+		if jsonOutput() {
+			report := persistentReport{Objects: []persistentObject{}}
+			for _, object := range objects {
+				report.Objects = append(report.Objects, persistentObject{
+					Handle:         jsonHandle(object.Handle),
+					Type:           objectTypeName(object.Public.Type),
+					TemplateDigest: object.TemplateDigest,
+				})
+			}
+			return writeJSON(out, report)
+		}
+		for _, object := range objects {
+			if _, err := fmt.Fprintf(out, "0x%x %s %x\n", object.Handle,
+				objectTypeName(object.Public.Type), object.TemplateDigest); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var persistentEvictCmd = &cobra.Command{
+	Use:   "evict <handle>",
+	Short: "Evict a persistent object",
+	Long: `Evict the object at a persistent handle, such as 0x81008F80
+
+With --registry or --registry-index, the handle can also be given by a name
+registered with "gotpm names add". The object must not have changed since the
+name was registered.
+
+To avoid evicting keys provisioned by other software, only handles from
+0x81008F00 to 0x81008FFF (used by go-tpm-tools) can be evicted, unless --force
+is given. Use "gotpm flush persistent" to evict all persistent objects.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		var handle tpmutil.Handle
+		if value, err := strconv.ParseUint(args[0], 0, 32); err == nil {
+			handle = tpmutil.Handle(value)
+		} else if registryFile == "" && registryIndex == 0 {
+			return fmt.Errorf("invalid handle %q: %w", args[0], err)
+		} else {
+			registry, err := openRegistry(rwc)
+			if err != nil {
+				return err
+			}
+			if handle, err = resolveHandle(registry, args[0]); err != nil {
+				return err
+			}
+		}
+		if !forceEvict && (handle < client.DefaultAKECCHandle || handle > client.LastPersistentKeyHandle) {
+			return fmt.Errorf("handle 0x%x is not used by go-tpm-tools, use --force to evict it", handle)
+		}
+
+		if err = tpm2.EvictControl(rwc, "", tpm2.HandleOwner, handle, handle); err != nil {
+			return fmt.Errorf("evicting handle 0x%x: %w", handle, err)
+		}
+		return reportResult(persistentObject{Handle: jsonHandle(handle)}, "Handle 0x%x evicted\n", handle)
+	},
+}
+
+// persistentReport is the JSON output of "gotpm persistent list".
+type persistentReport struct {
+	Objects []persistentObject `json:"objects"`
+}
+
+type persistentObject struct {
+	Handle         jsonHandle `json:"handle"`
+	Type           string     `json:"type,omitempty"`
+	TemplateDigest hexBytes   `json:"template_digest,omitempty"`
+}
+
+func objectTypeName(alg tpm2.Algorithm) string {
+	switch alg {
+	case tpm2.AlgRSA:
+		return "rsa"
+	case tpm2.AlgECC:
+		return "ecc"
+	case tpm2.AlgKeyedHash:
+		return "keyedhash"
+	case tpm2.AlgSymCipher:
+		return "symcipher"
+	default:
+		return fmt.Sprintf("0x%x", uint16(alg))
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(persistentCmd)
+	hideHelp(persistentCmd)
+	persistentCmd.AddCommand(persistentListCmd)
+	persistentCmd.AddCommand(persistentEvictCmd)
+	addOutputFlag(persistentListCmd)
+	persistentEvictCmd.PersistentFlags().BoolVar(&forceEvict, "force", false,
+		"evict handles outside of the go-tpm-tools range")
+	addRegistryFlags(persistentEvictCmd)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+)
+
+func TestPersistentListAndEvict(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srk.Close()
+	key, err := client.NewPersistentKey(rwc, tpm2.HandleOwner, client.AKTemplateECC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Close()
+
+	listFile := makeTempFile(t, nil)
+	defer os.Remove(listFile)
+	RootCmd.SetArgs([]string{"persistent", "list", "--output", listFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := ioutil.ReadFile(listFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, handle := range []string{
+		fmt.Sprintf("0x%x ecc", client.SRKECCReservedHandle),
+		fmt.Sprintf("0x%x ecc", key.Handle()),
+	} {
+		if !strings.Contains(string(list), handle) {
+			t.Errorf("%q not listed in:\n%s", handle, list)
+		}
+	}
+
+	srkHandle := fmt.Sprintf("0x%x", client.SRKECCReservedHandle)
+	RootCmd.SetArgs([]string{"persistent", "evict", srkHandle, "--quiet"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("evicting the SRK without --force should fail")
+	}
+	RootCmd.SetArgs([]string{"persistent", "evict", fmt.Sprintf("0x%x", key.Handle()), "--quiet"})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	RootCmd.SetArgs([]string{"persistent", "evict", srkHandle, "--force", "--quiet"})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	forceEvict = false
+
+	handles, err := client.Handles(rwc, tpm2.HandleTypePersistent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handles) != 0 {
+		t.Errorf("handles not evicted: %v", handles)
+	}
+}
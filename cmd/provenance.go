@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/provenance"
+	"github.com/spf13/cobra"
+)
+
+var (
+	provenanceBuilder string
+	provenanceParams  []string
+	provenanceSigner  string
+)
+
+var provenanceCmd = &cobra.Command{
+	Use:   "provenance <artifact>...",
+	Short: "Sign build artifacts with the TPM of a CI runner",
+	Long: `Sign build artifacts, attesting to the state of the runner which built them
+
+The artifacts' SHA-256 digests, the --builder-id and the build's --param
+values are signed by an attestation from the AK (or the key given by
+--signer), which also records the runner's PCRs and event log. The provenance
+is written as a BuildProvenance text protobuf (or with --format=json, a JSON
+protobuf, or in the --wire-format), which can be checked with provenance.Verify and
+provenance.CheckArtifact by anyone trusting the AK.
+
+For example, at the end of a CI build:
+	gotpm provenance out/server out/client --builder-id https://ci.example.com/runners/tpm \
+		--param commit=$COMMIT --param workflow=release --output provenance.textproto`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		statement := &pb.BuildStatement{BuilderId: provenanceBuilder}
+		for _, param := range provenanceParams {
+			parts := strings.SplitN(param, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("parameter %q is not of the form name=value", param)
+			}
+			statement.Parameters = append(statement.Parameters, &pb.BuildParameter{Name: parts[0], Value: parts[1]})
+		}
+		for _, path := range args {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			subject, err := provenance.DigestArtifact(path, file)
+			file.Close()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(debugOutput(), "%x  %s\n", subject.GetSha256(), path)
+			statement.Subjects = append(statement.Subjects, subject)
+		}
+
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+		signer, err := loadKey(rwc, provenanceSigner)
+		if err != nil {
+			return err
+		}
+		defer signer.Close()
+
+		signed, err := provenance.Sign(signer, statement)
+		if err != nil {
+			return err
+		}
+		output, err := marshalMessage(signed)
+		if err != nil {
+			return err
+		}
+		_, err = dataOutput().Write(output)
+		return err
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(provenanceCmd)
+	addOutputFlag(provenanceCmd)
+	addPublicKeyAlgoFlag(provenanceCmd)
+	addRegistryFlags(provenanceCmd)
+	provenanceCmd.PersistentFlags().StringVar(&provenanceBuilder, "builder-id", "",
+		"identifies the runner or CI system, such as the URL of the runner pool")
+	provenanceCmd.PersistentFlags().StringArrayVar(&provenanceParams, "param", nil,
+		"a build parameter, as name=value (can be repeated)")
+	provenanceCmd.PersistentFlags().StringVar(&provenanceSigner, "signer", "ak",
+		"the attesting key, given like the keys of \"gotpm certify\"")
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"crypto"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/provenance"
+	"github.com/google/go-tpm-tools/server"
+)
+
+func TestProvenance(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	defer func() { provenanceBuilder, provenanceParams, output = "", nil, "" }()
+
+	artifact := makeTempFile(t, []byte("artifact contents"))
+	defer os.Remove(artifact)
+	outFile := makeTempFile(t, nil)
+	defer os.Remove(outFile)
+
+	RootCmd.SetArgs([]string{"provenance", artifact, "--builder-id", "runner-1",
+		"--param", "commit=abc=def", "--output", outFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signed pb.BuildProvenance
+	if err := unmarshalOptions.Unmarshal(data, &signed); err != nil {
+		t.Fatal(err)
+	}
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	opts := provenance.VerifyOpts{
+		VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}},
+		BuilderIDs: []string{"runner-1"},
+	}
+	if _, err := provenance.Verify(&signed, opts); err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	params := signed.GetStatement().GetParameters()
+	if len(params) != 1 || params[0].GetName() != "commit" || params[0].GetValue() != "abc=def" {
+		t.Errorf("got parameters %v, want commit=abc=def", params)
+	}
+	file, err := os.Open(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := provenance.CheckArtifact(signed.GetStatement(), artifact, file); err != nil {
+		t.Errorf("CheckArtifact() failed: %v", err)
+	}
+
+	provenanceParams = nil
+	RootCmd.SetArgs([]string{"provenance", artifact, "--param", "commit"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("a parameter without a value should fail")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 
 	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/sshagent"
 	"github.com/google/go-tpm/tpmutil"
 
 	"github.com/google/go-tpm/tpm2"
@@ -21,10 +22,13 @@ var hierarchyNames = map[string]tpmutil.Handle{
 	"null":        tpm2.HandleNull,
 }
 
+var pubkeyKeyFormat = "pem"
+
 var pubkeyCmd = &cobra.Command{
-	Use:   "pubkey <endorsement | owner | platform | null>",
+	Use:   "pubkey <endorsement | owner | platform | null | key>",
 	Short: "Retrieve a public key from the TPM",
-	Long: `Get the PEM-formatted public component of a TPM's primary key
+	Long: `Get the PEM-formatted public component of a TPM's primary key, or of
+another key
 
 A TPM can create a primary asymmetric key in one of 4 hierarchies:
 	endorsement - used for remote attestation, privacy sensitive
@@ -35,44 +39,120 @@ A TPM can create a primary asymmetric key in one of 4 hierarchies:
 Furthermore, this key is based on a template containing parameters like
 algorithms and key sizes. By default, this command uses a standard template
 defined in the TPM2 spec. If --index is provided, the template is read from
-NVDATA instead (and --algo is ignored).`,
-	ValidArgs: func() []string {
-		// The keys from the hierarchyNames map are our valid arguments
-		keys := make([]string, len(hierarchyNames))
-		for k := range hierarchyNames {
-			keys = append(keys, k)
-		}
-		return keys
-	}(),
-	Args: cobra.ExactValidArgs(1),
+NVDATA instead (and --algo is ignored). For the endorsement hierarchy,
+--ek-range selects the low range (the default) or high range EK templates of
+the TCG EK Credential Profile, which some TPMs are provisioned with instead.
+
+The default endorsement (low range) and owner keys are persisted at their
+reserved handles, so they are only generated once. High range EKs, and keys
+created from an --index template, are generated on each invocation, unless
+--persist is given with --index, in which case they are persisted in the
+go-tpm-tools handle range (see "gotpm persistent").
+
+` + keyArgHelp + `
+
+With --key-format, the key is written as:
+	pem  a PEM-encoded PKIX public key (the default)
+	der  a DER-encoded PKIX public key
+	tpm  the key's TPMT_PUBLIC public area, as used by "gotpm certify"
+	ssh  an OpenSSH authorized_keys line (see "gotpm ssh-agent")
+With --format=json, the key's type, Name and (hex-encoded) public area, and
+the PEM public key, are instead written as a JSON object.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if pubkeyKeyFormat != "pem" && pubkeyKeyFormat != "der" && pubkeyKeyFormat != "tpm" && pubkeyKeyFormat != "ssh" {
+			return fmt.Errorf("unknown key format %q", pubkeyKeyFormat)
+		}
 		rwc, err := openTpm()
 		if err != nil {
 			return err
 		}
 		defer rwc.Close()
 
-		key, err := getKey(rwc, hierarchyNames[args[0]], keyAlgo)
+		var key *client.Key
+		if hierarchy, ok := hierarchyNames[args[0]]; ok {
+			key, err = getKey(rwc, hierarchy, keyAlgo)
+		} else {
+			key, err = loadKey(rwc, args[0])
+		}
 		if err != nil {
 			return err
 		}
 		defer key.Close()
 
-		return writeKey(key.PublicKey())
+		if jsonOutput() {
+			return writeKeyJSON(key)
+		}
+		switch pubkeyKeyFormat {
+		case "tpm":
+			public, err := key.PublicArea().Encode()
+			if err != nil {
+				return err
+			}
+			_, err = dataOutput().Write(public)
+			return err
+		case "ssh":
+			line, err := sshagent.AuthorizedKey(key, "")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(dataOutput(), "%s\n", line)
+			return err
+		default:
+			return writeKey(key.PublicKey())
+		}
 	},
 }
 
+// pubkeyReport is the JSON output of "gotpm pubkey".
+type pubkeyReport struct {
+	Type       string   `json:"type"`
+	Name       hexBytes `json:"name"`
+	PublicArea hexBytes `json:"public_area"`
+	PEM        string   `json:"pem"`
+}
+
+func writeKeyJSON(key *client.Key) error {
+	public, err := key.PublicArea().Encode()
+	if err != nil {
+		return err
+	}
+	name, err := key.Name().Digest.Encode()
+	if err != nil {
+		return err
+	}
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(key.PublicKey())
+	if err != nil {
+		return err
+	}
+	return writeJSON(dataOutput(), pubkeyReport{
+		Type:       objectTypeName(key.PublicArea().Type),
+		Name:       name,
+		PublicArea: public,
+		PEM:        string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: asn1Bytes})),
+	})
+}
+
 func init() {
 	RootCmd.AddCommand(pubkeyCmd)
 	addIndexFlag(pubkeyCmd)
+	addPersistFlag(pubkeyCmd)
 	addOutputFlag(pubkeyCmd)
 	addPublicKeyAlgoFlag(pubkeyCmd)
+	addEKRangeFlag(pubkeyCmd)
+	addRegistryFlags(pubkeyCmd)
+	pubkeyCmd.PersistentFlags().StringVar(&pubkeyKeyFormat, "key-format", "pem",
+		"output format of the key: pem, der, tpm or ssh")
+	pubkeyCmd.RegisterFlagCompletionFunc("key-format", completeValues("pem", "der", "tpm", "ssh"))
 }
 
 func getKey(rw io.ReadWriter, hierarchy tpmutil.Handle, algo tpm2.Algorithm) (*client.Key, error) {
 	fmt.Fprintf(debugOutput(), "Using hierarchy 0x%x\n", hierarchy)
 	if nvIndex != 0 {
 		fmt.Fprintf(debugOutput(), "Reading from NVDATA index %d\n", nvIndex)
+		if persist {
+			return client.PersistentKeyFromNvIndex(rw, hierarchy, nvIndex)
+		}
 		return client.KeyFromNvIndex(rw, hierarchy, nvIndex)
 	}
 
@@ -92,6 +172,10 @@ func writeKey(pubKey crypto.PublicKey) error {
 	if err != nil {
 		return err
 	}
+	if pubkeyKeyFormat == "der" {
+		_, err = dataOutput().Write(asn1Bytes)
+		return err
+	}
 
 	return pem.Encode(dataOutput(), &pem.Block{
 		Type:  "PUBLIC KEY",
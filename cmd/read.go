@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/go-tpm-tools/client"
 	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
 	"github.com/google/go-tpm/tpm2"
 	"github.com/google/go-tpm/tpmutil"
 	"github.com/spf13/cobra"
@@ -28,7 +29,10 @@ var pcrCmd = &cobra.Command{
 Based on --hash-algo and --pcrs flags, read the contents of the TPM's PCRs.
 
 If --hash-algo is not provided, all banks of PCRs will be read.
-If --pcrs is not provided, all PCRs are read for that hash algorithm.`,
+If --pcrs is not provided, all PCRs are read for that hash algorithm.
+
+With --format=json, the PCRs are written as {"banks": [{"hash": "sha256",
+"pcrs": {"0": "<hex>", ...}}, ...]}.`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		rwc, err := openTpm()
@@ -48,7 +52,7 @@ If --pcrs is not provided, all PCRs are read for that hash algorithm.`,
 			if err != nil {
 				return err
 			}
-			return internal.FormatPCRs(dataOutput(), pcrs)
+			return writePCRs([]*pb.PCRs{pcrs})
 		}
 		if len(pcrs) != 0 {
 			return errors.New("--hash-algo must be used with --pcrs")
@@ -59,23 +63,50 @@ If --pcrs is not provided, all PCRs are read for that hash algorithm.`,
 		if err != nil {
 			return err
 		}
+		return writePCRs(banks)
+	},
+}
+
+// pcrReport is the JSON output of "gotpm read pcr".
+type pcrReport struct {
+	Banks []pcrBank `json:"banks"`
+}
+
+type pcrBank struct {
+	Hash string              `json:"hash"`
+	PCRs map[uint32]hexBytes `json:"pcrs"`
+}
 
+func writePCRs(banks []*pb.PCRs) error {
+	out := dataOutput()
+	if !jsonOutput() {
 		for _, bank := range banks {
-			if err = internal.FormatPCRs(dataOutput(), bank); err != nil {
+			if err := internal.FormatPCRs(out, bank); err != nil {
 				return err
 			}
 		}
 		return nil
-	},
+	}
+	report := pcrReport{Banks: []pcrBank{}}
+	for _, bank := range banks {
+		values := make(map[uint32]hexBytes)
+		for index, value := range bank.GetPcrs() {
+			values[index] = value
+		}
+		report.Banks = append(report.Banks, pcrBank{Hash: algos[tpm2.Algorithm(bank.GetHash())], PCRs: values})
+	}
+	return writeJSON(out, report)
 }
 
-var nvReadCmd = &cobra.Command{
+var nvdataCmd = &cobra.Command{
 	Use:   "nvdata",
 	Short: "Read TPM NVData",
 	Long: `Read NVData at a particular NVIndex
 
 Based on the --index flag, this reads all of the NVData present at that NVIndex.
-The read is authenticated with the owner hierarchy and an empty password.`,
+The read is authenticated with the owner hierarchy and an empty password.
+With --format=json, the data is written hex-encoded, as {"index": "0x...",
+"data": "<hex>"}.`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		rwc, err := openTpm()
@@ -88,6 +119,9 @@ The read is authenticated with the owner hierarchy and an empty password.`,
 		if err != nil {
 			return err
 		}
+		if jsonOutput() {
+			return writeJSON(dataOutput(), nvDataReport{Index: jsonHandle(nvIndex), Data: data})
+		}
 		if _, err := dataOutput().Write(data); err != nil {
 			return fmt.Errorf("cannot output NVData: %w", err)
 		}
@@ -95,14 +129,20 @@ The read is authenticated with the owner hierarchy and an empty password.`,
 	},
 }
 
+// nvDataReport is the JSON output of "gotpm read nvdata".
+type nvDataReport struct {
+	Index jsonHandle `json:"index"`
+	Data  hexBytes   `json:"data"`
+}
+
 func init() {
 	RootCmd.AddCommand(readCmd)
 	readCmd.AddCommand(pcrCmd)
-	readCmd.AddCommand(nvReadCmd)
+	readCmd.AddCommand(nvdataCmd)
 	addOutputFlag(pcrCmd)
 	addPCRsFlag(pcrCmd)
 	addHashAlgoFlag(pcrCmd, &pcrHashAlgo)
-	addIndexFlag(nvReadCmd)
-	nvReadCmd.MarkPersistentFlagRequired("index")
-	addOutputFlag(nvReadCmd)
+	addIndexFlag(nvdataCmd)
+	nvdataCmd.MarkPersistentFlagRequired("index")
+	addOutputFlag(nvdataCmd)
 }
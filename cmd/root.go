@@ -17,11 +17,37 @@ var RootCmd = &cobra.Command{
 	Long: `Command line tool for the go-tpm TSS
 
 This tool allows performing TPM2 operations from the command line.
-See the per-command documentation for more information.`,
+See the per-command documentation for more information.
+
+With --format=json, commands write machine-readable JSON to stdout (or to
+--output), for use from provisioning tools such as Ansible and Terraform:
+  - reports, such as PCR values, NV indexes and persistent objects, are written
+    as JSON objects, with binary values hex-encoded
+  - protobufs, such as sealed data and certifications, are written in the
+    protobuf JSON encoding (with binary values base64-encoded), and are read
+    back in either encoding
+  - commands which change the TPM write a JSON object describing the change
+Messages then go to stderr. Raw data, such as an unsealed secret, is written
+unchanged.
+
+--wire-format chooses the encoding of the protobufs written, such as
+attestations and sealed data, independently of --format: text (the protobuf
+text format, for reading and editing), json, binary (the protobuf binary
+format) or cbor (a CBOR map of the .proto field names, with binary values as
+byte strings). Protobufs are read back in any of these formats, which is
+detected from the input.`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		if quiet && verbose {
 			return fmt.Errorf("cannot specify both --quiet and --verbose")
 		}
+		if outputFormat != formatText && outputFormat != formatJSON {
+			return fmt.Errorf("unknown format %q, must be %s or %s", outputFormat, formatText, formatJSON)
+		}
+		switch wireFormat {
+		case "", wireText, wireJSON, wireBinary, wireCBOR:
+		default:
+			return fmt.Errorf("unknown wire format %q, must be %s, %s, %s or %s", wireFormat, wireText, wireJSON, wireBinary, wireCBOR)
+		}
 		cmd.SilenceUsage = true
 		return nil
 	},
@@ -37,13 +63,22 @@ func init() {
 		"print nothing if command is successful")
 	RootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false,
 		"print additional info to stdout")
-	hideHelp(RootCmd)
+	RootCmd.PersistentFlags().StringVar(&outputFormat, "format", formatText,
+		"output format: text or json")
+	RootCmd.PersistentFlags().StringVar(&wireFormat, "wire-format", "",
+		"encoding of written protobufs: text, json, binary or cbor (default: the --format)")
+	RootCmd.RegisterFlagCompletionFunc("format", completeValues(formatText, formatJSON))
+	RootCmd.RegisterFlagCompletionFunc("wire-format", completeValues(wireText, wireJSON, wireBinary, wireCBOR))
 }
 
 func messageOutput() io.Writer {
 	if quiet {
 		return ioutil.Discard
 	}
+	// Keep stdout for the JSON output.
+	if jsonOutput() {
+		return os.Stderr
+	}
 	return os.Stdout
 }
 
@@ -6,12 +6,17 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/google/go-tpm-tools/atrest"
 	"github.com/google/go-tpm-tools/client"
 	pb "github.com/google/go-tpm-tools/proto/tpm"
 	"github.com/google/go-tpm/tpm2"
 )
 
-var sealHashAlgo = tpm2.AlgSHA256
+var (
+	sealHashAlgo     = tpm2.AlgSHA256
+	sealCounterIndex uint32
+	sealWrap         bool
+)
 
 var sealCmd = &cobra.Command{
 	Use:   "seal",
@@ -25,7 +30,20 @@ the encryption.
 Optionally (using the --pcrs flag), this decryption can be furthur restricted to
 only work if certain Platform Control Registers (PCRs) are in the correct state.
 This allows a key (i.e. a disk encryption key) to be bound to specific machine
-state (like Secure Boot).`,
+state (like Secure Boot).
+
+The sealed data can also be bound to the current value of an NV counter (using
+the --counter-index flag, see "gotpm counter"). Incrementing the counter then
+revokes the sealed data.
+
+With --wrap, the sealed data is also encrypted with a key sealed by the TPM's
+Storage Root Key (see atrest.WrapBlob), so that a copy of it reveals nothing,
+not even the PCRs it is sealed to, off this machine. "gotpm unseal" reads both
+wrapped and plaintext sealed data, and "gotpm wrap" migrates existing files.
+
+The sealed data is a SealedBytes text protobuf, or with --format=json a JSON
+protobuf (see --wire-format for other encodings), recording the SRK, the PCRs
+and the counter it is sealed to.`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		rwc, err := openTpm()
@@ -48,9 +66,12 @@ state (like Secure Boot).`,
 		}
 
 		fmt.Fprintf(debugOutput(), "Sealing to PCRs: %v\n", pcrs)
-		opts := client.SealOpts{Current: tpm2.PCRSelection{
-			Hash: sealHashAlgo,
-			PCRs: pcrs}}
+		opts := client.SealOpts{
+			Current: tpm2.PCRSelection{
+				Hash: sealHashAlgo,
+				PCRs: pcrs},
+			CounterIndex: sealCounterIndex,
+		}
 		sealed, err := srk.Seal(secret, opts)
 		if err != nil {
 			return fmt.Errorf("sealing data: %w", err)
@@ -58,9 +79,15 @@ state (like Secure Boot).`,
 
 		fmt.Fprintln(debugOutput(), "Writing sealed data")
 		var output []byte
-		if output, err = marshalOptions.Marshal(sealed); err != nil {
+		if output, err = marshalMessage(sealed); err != nil {
 			return err
 		}
+		if sealWrap {
+			fmt.Fprintln(debugOutput(), "Wrapping sealed data")
+			if output, err = atrest.WrapBlob(rwc, output); err != nil {
+				return fmt.Errorf("wrapping sealed data: %w", err)
+			}
+		}
 		if _, err = dataOutput().Write(output); err != nil {
 			return err
 		}
@@ -79,7 +106,8 @@ using the TPM. This operation will fail if used on a different TPM, or if the
 Platform Control Registers (PCRs) are in the incorrect state.
 
 All the necessary data to decrypt the sealed input is present in the input blob.
-We do not need to specify the PCRs used for unsealing.
+We do not need to specify the PCRs used for unsealing. Sealed data wrapped with
+"gotpm seal --wrap" (or "gotpm wrap") is unwrapped transparently.
 
 We do support an optional "certification" process. A list of PCRs may be
 provided with --pcrs, and the unwrapping will fail if the PCR values when
@@ -99,8 +127,11 @@ machine state when sealing took place.
 		if err != nil {
 			return err
 		}
+		if data, err = atrest.UnwrapBlob(rwc, data); err != nil {
+			return fmt.Errorf("unwrapping sealed data: %w", err)
+		}
 		var sealed pb.SealedBytes
-		if err := unmarshalOptions.Unmarshal(data, &sealed); err != nil {
+		if err := unmarshalMessage(data, &sealed); err != nil {
 			return err
 		}
 
@@ -143,4 +174,8 @@ func init() {
 	addHashAlgoFlag(sealCmd, &sealHashAlgo)
 	addPCRsFlag(unsealCmd)
 	addPublicKeyAlgoFlag(sealCmd)
+	sealCmd.PersistentFlags().Uint32Var(&sealCounterIndex, "counter-index", 0,
+		"NV index of a counter to bind the sealed data to (see \"gotpm counter\")")
+	sealCmd.PersistentFlags().BoolVar(&sealWrap, "wrap", false,
+		"encrypt the sealed data so it can only be read on this machine")
 }
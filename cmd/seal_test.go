@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/google/go-tpm-tools/atrest"
 	"github.com/google/go-tpm-tools/client"
 	"github.com/google/go-tpm-tools/internal/test"
 	"github.com/google/go-tpm/tpm2"
@@ -146,3 +147,97 @@ func TestUnsealFail(t *testing.T) {
 		})
 	}
 }
+
+func TestUnsealRevokedByCounter(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+
+	index := strconv.Itoa(0x01500100)
+	RootCmd.SetArgs([]string{"counter", "create", "--quiet", "--index", index})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.DeleteNVCounter(rwc, 0x01500100)
+
+	secretIn := []byte("Hello")
+	secretFile := makeTempFile(t, secretIn)
+	defer os.Remove(secretFile)
+	sealedFile := makeTempFile(t, nil)
+	defer os.Remove(sealedFile)
+	counterFile := makeTempFile(t, nil)
+	defer os.Remove(counterFile)
+
+	RootCmd.SetArgs([]string{"seal", "--quiet", "--input", secretFile, "--output", sealedFile, "--counter-index", index})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	sealCounterIndex = 0 // "flush" the counter index from the last Execute() cmd
+
+	RootCmd.SetArgs([]string{"unseal", "--quiet", "--input", sealedFile, "--output", secretFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatalf("unsealing before the counter is incremented failed: %v", err)
+	}
+
+	RootCmd.SetArgs([]string{"counter", "increment", "--quiet", "--index", index, "--output", counterFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	RootCmd.SetArgs([]string{"unseal", "--quiet", "--input", sealedFile, "--output", secretFile})
+	if RootCmd.Execute() == nil {
+		t.Error("Unsealing should have failed")
+	}
+}
+
+func TestSealWrap(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+
+	secretIn := []byte("Hello")
+	secretFile := makeTempFile(t, secretIn)
+	defer os.Remove(secretFile)
+	wrappedFile := makeTempFile(t, nil)
+	defer os.Remove(wrappedFile)
+	plainFile := makeTempFile(t, nil)
+	defer os.Remove(plainFile)
+	secretOutFile := makeTempFile(t, nil)
+	defer os.Remove(secretOutFile)
+
+	RootCmd.SetArgs([]string{"seal", "--quiet", "--wrap", "--input", secretFile, "--output", wrappedFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	sealWrap = false // "flush" the wrap flag from the last Execute() cmd
+	RootCmd.SetArgs([]string{"seal", "--quiet", "--input", secretFile, "--output", plainFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	// Migrate the plaintext file, and check that wrapping is idempotent.
+	RootCmd.SetArgs([]string{"wrap", "--quiet", plainFile, wrappedFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sealedFile := range []string{wrappedFile, plainFile} {
+		if !atrest.IsWrapped(readFile(t, sealedFile)) {
+			t.Errorf("%s is not wrapped", sealedFile)
+		}
+		RootCmd.SetArgs([]string{"unseal", "--quiet", "--input", sealedFile, "--output", secretOutFile})
+		if err := RootCmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if secretOut := readFile(t, secretOutFile); !bytes.Equal(secretIn, secretOut) {
+			t.Errorf("Expected %s, got %s", secretIn, secretOut)
+		}
+	}
+}
+
+func readFile(tb testing.TB, name string) []byte {
+	tb.Helper()
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
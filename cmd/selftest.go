@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/spf13/cobra"
+)
+
+var fullSelfTest bool
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Run the TPM's self tests and report its health",
+	Long: `Run the TPM's self tests and report its health
+
+The TPM's self tests are run (with TPM2_SelfTest) and their result is read
+(with TPM2_GetTestResult). By default, only the tests which have not already
+run since the TPM was reset are run; with --full, every test is run again,
+which can take several seconds.
+
+Along with the result, the TPM's dictionary attack lockout status and its
+capabilities (as reported by "gotpm capabilities"), such as its manufacturer,
+firmware version and supported algorithms, are reported, to triage a TPM's
+health before debugging attestation failures. A TPM which has failed its self
+tests is in failure mode, and rejects most commands until it is reset; the
+command then exits with an error. A TPM which is locked out
+rejects commands needing authorization with a password, until the lockout
+recovers or is reset with the lockout hierarchy.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		result, testErr := client.SelfTest(rwc, fullSelfTest)
+		if result == nil {
+			return testErr
+		}
+		report, err := newHealthReport(rwc, result)
+		if err != nil {
+			// A TPM in failure mode may not report its properties.
+			if testErr != nil {
+				return testErr
+			}
+			return err
+		}
+		if jsonOutput() {
+			err = writeJSON(dataOutput(), report)
+		} else {
+			err = report.writeText(dataOutput())
+		}
+		if err != nil {
+			return err
+		}
+		return testErr
+	},
+}
+
+// healthReport is the output of "gotpm selftest": the self test result and
+// lockout status, along with the TPM's capabilities.
+type healthReport struct {
+	Passed     bool          `json:"passed"`
+	TestResult jsonHandle    `json:"test_result"`
+	TestData   hexBytes      `json:"test_data,omitempty"`
+	Lockout    lockoutReport `json:"lockout"`
+	*capabilitiesReport
+}
+
+// lockoutReport is the dictionary attack lockout status of a TPM, from Part 2
+// of the spec, Section 6.13 TPM_PT.
+type lockoutReport struct {
+	InLockout bool `json:"in_lockout"`
+	// Authorization failures so far, and the number which locks out the TPM.
+	Failures    uint32 `json:"failures"`
+	MaxFailures uint32 `json:"max_failures"`
+	// Seconds after which a failure is forgotten, and after which lockoutAuth
+	// can be tried again after a failure.
+	IntervalSeconds uint32 `json:"interval_seconds"`
+	RecoverySeconds uint32 `json:"recovery_seconds"`
+}
+
+// The inLockout bit of TPMA_PERMANENT, from Part 2 of the spec, Table 38.
+const permanentInLockout = 1 << 9
+
+func newHealthReport(rw io.ReadWriter, result *client.SelfTestResult) (*healthReport, error) {
+	caps, err := client.GetCapabilities(rw)
+	if err != nil {
+		return nil, err
+	}
+	lockout, err := client.TPMProperties(rw, tpm2.TPMAPermanent, tpm2.LockoutRecovery)
+	if err != nil {
+		return nil, err
+	}
+	return &healthReport{
+		Passed:     result.Passed(),
+		TestResult: jsonHandle(result.Code),
+		TestData:   result.Data,
+		Lockout: lockoutReport{
+			InLockout:       lockout[tpm2.TPMAPermanent]&permanentInLockout != 0,
+			Failures:        lockout[tpm2.LockoutCounter],
+			MaxFailures:     lockout[tpm2.MaxAuthFail],
+			IntervalSeconds: lockout[tpm2.LockoutInterval],
+			RecoverySeconds: lockout[tpm2.LockoutRecovery],
+		},
+		capabilitiesReport: newCapabilitiesReport(caps),
+	}, nil
+}
+
+func (r *healthReport) writeText(w io.Writer) error {
+	status := "passed"
+	if !r.Passed {
+		status = fmt.Sprintf("FAILED (0x%x)", uint32(r.TestResult))
+	}
+	fmt.Fprintf(w, "Self test:    %s\n", status)
+	if len(r.TestData) > 0 {
+		fmt.Fprintf(w, "Test data:    %x\n", []byte(r.TestData))
+	}
+	locked := "not locked out"
+	if r.Lockout.InLockout {
+		locked = "LOCKED OUT"
+	}
+	fmt.Fprintf(w, "Lockout:      %s, %d of %d authorization failures\n", locked, r.Lockout.Failures, r.Lockout.MaxFailures)
+	fmt.Fprintf(w, "              failures are forgotten after %ds, lockoutAuth recovers after %ds\n",
+		r.Lockout.IntervalSeconds, r.Lockout.RecoverySeconds)
+	return r.capabilitiesReport.writeText(w)
+}
+
+func init() {
+	RootCmd.AddCommand(selftestCmd)
+	selftestCmd.Flags().BoolVar(&fullSelfTest, "full", false,
+		"run every self test, not only those which have not yet run")
+	addOutputFlag(selftestCmd)
+}
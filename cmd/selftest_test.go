@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+func TestSelftest(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ExternalTPM = rwc
+	defer func() { fullSelfTest = false }()
+
+	var report struct {
+		Passed       bool     `json:"passed"`
+		Manufacturer string   `json:"manufacturer"`
+		Algorithms   []string `json:"algorithms"`
+		Lockout      struct {
+			InLockout   bool   `json:"in_lockout"`
+			MaxFailures uint32 `json:"max_failures"`
+		} `json:"lockout"`
+	}
+	runJSON(t, &report, "selftest", "--full")
+	if !report.Passed {
+		t.Errorf("self test report %+v, want passed", report)
+	}
+	if report.Manufacturer == "" || report.Lockout.InLockout || report.Lockout.MaxFailures == 0 {
+		t.Errorf("self test report %+v, want a manufacturer and no lockout", report)
+	}
+	for _, alg := range []string{"rsa", "ecc", "sha256"} {
+		found := false
+		for _, got := range report.Algorithms {
+			found = found || got == alg
+		}
+		if !found {
+			t.Errorf("algorithms %v are missing %s", report.Algorithms, alg)
+		}
+	}
+
+	outputFile := makeTempFile(t, nil)
+	defer os.Remove(outputFile)
+	defer func() { output = "" }()
+	RootCmd.SetArgs([]string{"selftest", "--output", outputFile})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	text, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), "Self test:    passed") {
+		t.Errorf("self test output does not report passing:\n%s", text)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	defer func() { output = "" }()
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		outputFile := makeTempFile(t, nil)
+		defer os.Remove(outputFile)
+		RootCmd.SetArgs([]string{"completion", shell, "--output", outputFile})
+		if err := RootCmd.Execute(); err != nil {
+			t.Fatalf("gotpm completion %s failed: %v", shell, err)
+		}
+		script, err := ioutil.ReadFile(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(script, []byte("gotpm")) {
+			t.Errorf("%s completion script does not complete gotpm", shell)
+		}
+	}
+
+	// Flag values are completed by the scripts calling gotpm __complete.
+	var out bytes.Buffer
+	RootCmd.SetOut(&out)
+	defer RootCmd.SetOut(nil)
+	RootCmd.SetArgs([]string{"__complete", "read", "pcr", "--hash-algo", ""})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.HasPrefix(got, "sha1\nsha256\nsha384\nsha512\n") {
+		t.Errorf("--hash-algo completions = %q, want the hash algorithms", got)
+	}
+
+	RootCmd.SetArgs([]string{"completion", "tcsh"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("gotpm completion tcsh should fail")
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/google/go-tpm-tools/sshagent"
+)
+
+var (
+	sshAgentSocket string
+	sshAgentKeys   []string
+)
+
+var sshAgentCmd = &cobra.Command{
+	Use:   "ssh-agent",
+	Short: "Serve TPM keys to SSH clients",
+	Long: `Serve TPM keys over the ssh-agent protocol on a Unix socket, until
+interrupted.
+
+SSH clients find the agent through the SSH_AUTH_SOCK environment variable, and
+authenticate with the TPM keys, whose private keys never leave the TPM. By
+default, the agent serves the signing keys at the owner hierarchy's persistent
+handles (see "gotpm persistent"), commented with their handles. With --key, it
+serves the given keys instead. Keys cannot be added to or removed from the
+agent, but clients can lock and unlock it.
+
+` + keyArgHelp + `
+
+The keys' authorized_keys lines are written with "gotpm pubkey --key-format=ssh".
+The socket is only accessible to the agent's user.
+
+For example:
+	gotpm ssh-agent --socket $XDG_RUNTIME_DIR/gotpm-ssh.sock --key ldevid --algo ecc
+	SSH_AUTH_SOCK=$XDG_RUNTIME_DIR/gotpm-ssh.sock ssh host`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sshAgentSocket == "" {
+			return errors.New("--socket is required")
+		}
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		var ids []sshagent.Identity
+		if len(sshAgentKeys) == 0 {
+			if ids, err = sshagent.ResidentKeys(rwc); err != nil {
+				return err
+			}
+		}
+		defer func() {
+			for _, id := range ids {
+				id.Key.Close()
+			}
+		}()
+		for _, arg := range sshAgentKeys {
+			key, err := loadKey(rwc, arg)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, sshagent.Identity{Key: key, Comment: arg})
+		}
+		if len(ids) == 0 {
+			return errors.New("no persistent keys can be used with SSH, use --key")
+		}
+		a, err := sshagent.NewAgent(ids...)
+		if err != nil {
+			return err
+		}
+
+		// Remove the socket left by an earlier agent, if any.
+		if err := os.Remove(sshAgentSocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		lis, err := net.Listen("unix", sshAgentSocket)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(sshAgentSocket)
+		if err := os.Chmod(sshAgentSocket, 0600); err != nil {
+			lis.Close()
+			return err
+		}
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-signals:
+			case <-done:
+			}
+			lis.Close()
+		}()
+
+		fmt.Fprintf(messageOutput(), "Serving %d keys on %s\n", len(ids), sshAgentSocket)
+		for _, id := range ids {
+			fmt.Fprintf(debugOutput(), "Serving %s\n", id.Comment)
+		}
+		if err := a.Serve(lis); !errors.Is(err, net.ErrClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sshAgentCmd)
+	addPublicKeyAlgoFlag(sshAgentCmd)
+	addRegistryFlags(sshAgentCmd)
+	sshAgentCmd.Flags().StringVar(&sshAgentSocket, "socket", "", "path of the Unix socket to listen on")
+	sshAgentCmd.Flags().StringArrayVar(&sshAgentKeys, "key", nil,
+		"key to serve, instead of the persistent keys (can be repeated)")
+}
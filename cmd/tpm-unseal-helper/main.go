@@ -0,0 +1,293 @@
+// Command tpm-unseal-helper unseals a secret sealed with "gotpm seal", for use
+// by PAM modules and initramfs scripts which cannot embed the Go library.
+//
+// The protocol is deliberately minimal. The helper reads the sealed data (in
+// the text or JSON format written by "gotpm seal", optionally wrapped with
+// --wrap or "gotpm wrap") from stdin until EOF. On success,
+// it writes the secret, and nothing else, to stdout and exits with status 0.
+// On failure, it writes nothing to stdout, writes a single line of the form
+//
+//	tpm-unseal-helper: <CODE>: <message>
+//
+// to stderr, and exits with the status for CODE:
+//
+//	1 FAILURE  - any other failure
+//	2 USAGE    - invalid command line arguments
+//	3 INPUT    - the sealed data could not be decoded
+//	4 NO_TPM   - the TPM could not be opened
+//	5 POLICY   - the PCRs no longer match the values the data was sealed to
+//	6 LOCKOUT  - the TPM is in dictionary attack lockout, so unsealing was not
+//	             attempted; retrying before the lockout ends is pointless
+//	7 TIMEOUT  - the TPM did not respond within the timeout
+//	8 DENIED   - the helper is setuid, and the invoking user is not allowed to
+//	             use it
+//
+// The helper can be installed setuid, so that unprivileged callers can unseal
+// data sealed to the machine's PCRs without access to the TPM device. In that
+// case only the users listed in /etc/tpm-unseal-helper.allow may run it, as
+// anyone else could otherwise unseal any secret sealed to the machine's
+// current PCRs. The file lists one user name or numeric uid per line (lines
+// starting with '#' are ignored), and must be owned by the helper's owner and
+// not writable by anyone else; if it is missing, nobody is allowed. The
+// -tpm-path flag is also rejected, the sealed data is limited in size, and no
+// debug output is ever written.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-tpm-tools/atrest"
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+const (
+	exitFailure = 1 + iota
+	exitUsage
+	exitInput
+	exitNoTPM
+	exitPolicy
+	exitLockout
+	exitTimeout
+	exitDenied
+)
+
+var codeNames = map[int]string{
+	exitFailure: "FAILURE",
+	exitUsage:   "USAGE",
+	exitInput:   "INPUT",
+	exitNoTPM:   "NO_TPM",
+	exitPolicy:  "POLICY",
+	exitLockout: "LOCKOUT",
+	exitTimeout: "TIMEOUT",
+	exitDenied:  "DENIED",
+}
+
+const (
+	// Sealed data is a few kilobytes at most.
+	maxInputSize = 64 << 10
+	// inLockout bit of TPMA_PERMANENT, from Part 2 of the spec, Table 38.
+	permanentInLockout = 1 << 9
+)
+
+// allowFile lists the users allowed to run the helper when it is setuid.
+var allowFile = "/etc/tpm-unseal-helper.allow"
+
+// failure is an error with the exit status reported to the caller.
+type failure struct {
+	code int
+	err  error
+}
+
+func (f *failure) Error() string {
+	return fmt.Sprintf("%s: %v", codeNames[f.code], f.err)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Geteuid() != os.Getuid(), client.OpenTPM))
+}
+
+// run implements the helper, returning the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, setuid bool,
+	open func(path string) (io.ReadWriteCloser, error)) int {
+	secret, err := unsealWithArgs(args, stdin, setuid, open)
+	if err == nil {
+		if _, err = stdout.Write(secret); err == nil {
+			return 0
+		}
+		err = &failure{exitFailure, fmt.Errorf("writing secret: %w", err)}
+	}
+	var f *failure
+	if !errors.As(err, &f) {
+		f = &failure{exitFailure, err}
+	}
+	fmt.Fprintf(stderr, "tpm-unseal-helper: %v\n", f)
+	return f.code
+}
+
+func unsealWithArgs(args []string, stdin io.Reader, setuid bool,
+	open func(path string) (io.ReadWriteCloser, error)) ([]byte, error) {
+	flags := flag.NewFlagSet("tpm-unseal-helper", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	tpmPath := flags.String("tpm-path", "", "TPM device path or TCTI-style configuration (defaults to "+client.DefaultTPMDescription+")")
+	timeout := flags.Duration("timeout", 30*time.Second, "maximum time to wait for the TPM")
+	if err := flags.Parse(args); err != nil {
+		return nil, &failure{exitUsage, err}
+	}
+	if flags.NArg() != 0 {
+		return nil, &failure{exitUsage, fmt.Errorf("unexpected arguments %q", flags.Args())}
+	}
+	if *timeout <= 0 {
+		return nil, &failure{exitUsage, errors.New("-timeout must be positive")}
+	}
+	if setuid && *tpmPath != "" {
+		return nil, &failure{exitUsage, errors.New("-tpm-path cannot be used when running setuid")}
+	}
+	if setuid {
+		if err := authorizeCaller(allowFile, os.Getuid(), os.Geteuid()); err != nil {
+			return nil, &failure{exitDenied, err}
+		}
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(stdin, maxInputSize+1))
+	if err != nil {
+		return nil, &failure{exitInput, fmt.Errorf("reading sealed data: %w", err)}
+	}
+	if len(data) > maxInputSize {
+		return nil, &failure{exitInput, fmt.Errorf("sealed data is larger than %d bytes", maxInputSize)}
+	}
+	// Wrapped data is only decoded once the TPM has unwrapped it.
+	var sealed pb.SealedBytes
+	wrapped := atrest.IsWrapped(data)
+	if !wrapped {
+		if err := decodeSealed(data, &sealed); err != nil {
+			return nil, &failure{exitInput, fmt.Errorf("decoding sealed data: %w", err)}
+		}
+	}
+
+	rwc, err := open(*tpmPath)
+	if err != nil {
+		return nil, &failure{exitNoTPM, err}
+	}
+	// TPM commands cannot be interrupted, so on timeout the helper returns
+	// (and exits) without waiting for the TPM.
+	type result struct {
+		secret []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer rwc.Close()
+		if wrapped {
+			if err := unwrap(rwc, data, &sealed); err != nil {
+				done <- result{nil, err}
+				return
+			}
+		}
+		secret, err := unseal(rwc, &sealed)
+		done <- result{secret, err}
+	}()
+	select {
+	case r := <-done:
+		return r.secret, r.err
+	case <-time.After(*timeout):
+		return nil, &failure{exitTimeout, fmt.Errorf("no response from the TPM after %v", *timeout)}
+	}
+}
+
+// authorizeCaller checks that the invoking user (uid) is listed in the allow
+// file, which must be owned by the helper's owner (euid) and not writable by
+// anyone else, so that only they can allow users.
+func authorizeCaller(path string, uid, euid int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("uid %d is not allowed to unseal: %w", uid, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if owner, ok := fileOwner(info); !ok || owner != euid || info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s must be owned by uid %d and only writable by its owner", path, euid)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxInputSize))
+	if err != nil {
+		return err
+	}
+	name := ""
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		name = u.Username
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == strconv.Itoa(uid) || (name != "" && line == name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("uid %d is not listed in %s", uid, path)
+}
+
+func unwrap(rw io.ReadWriter, data []byte, sealed *pb.SealedBytes) error {
+	data, err := atrest.UnwrapBlob(rw, data)
+	if err != nil {
+		return fmt.Errorf("unwrapping sealed data: %w", err)
+	}
+	if err := decodeSealed(data, sealed); err != nil {
+		return &failure{exitInput, fmt.Errorf("decoding sealed data: %w", err)}
+	}
+	return nil
+}
+
+// decodeSealed decodes sealed data written by "gotpm seal", with or without
+// --format=json.
+func decodeSealed(data []byte, sealed *pb.SealedBytes) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return protojson.Unmarshal(trimmed, sealed)
+	}
+	return prototext.Unmarshal(data, sealed)
+}
+
+func unseal(rw io.ReadWriter, sealed *pb.SealedBytes) ([]byte, error) {
+	// Fail early during lockout, rather than risk extending it.
+	props, _, err := tpm2.GetCapability(rw, tpm2.CapabilityTPMProperties, 1, uint32(tpm2.TPMAPermanent))
+	if err != nil {
+		return nil, fmt.Errorf("reading TPM properties: %w", err)
+	}
+	if len(props) == 0 {
+		return nil, errors.New("TPM did not return TPMA_PERMANENT")
+	}
+	if prop, ok := props[0].(tpm2.TaggedProperty); !ok || prop.Tag != tpm2.TPMAPermanent {
+		return nil, errors.New("TPM did not return TPMA_PERMANENT")
+	} else if prop.Value&permanentInLockout != 0 {
+		return nil, &failure{exitLockout, errors.New("TPM is in dictionary attack lockout")}
+	}
+
+	var srk *client.Key
+	switch tpm2.Algorithm(sealed.GetSrk()) {
+	case tpm2.AlgRSA:
+		srk, err = client.StorageRootKeyRSA(rw)
+	case tpm2.AlgECC:
+		srk, err = client.StorageRootKeyECC(rw)
+	default:
+		return nil, &failure{exitInput, fmt.Errorf("unsupported SRK type %v", sealed.GetSrk())}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading SRK: %w", err)
+	}
+	defer srk.Close()
+
+	secret, err := srk.Unseal(sealed, client.UnsealOpts{})
+	if err != nil {
+		return nil, classifyUnsealError(err)
+	}
+	return secret, nil
+}
+
+func classifyUnsealError(err error) error {
+	var sessionErr tpm2.SessionError
+	if errors.As(err, &sessionErr) && sessionErr.Code == tpm2.RCPolicyFail {
+		return &failure{exitPolicy, err}
+	}
+	var warning tpm2.Warning
+	if errors.As(err, &warning) && warning.Code == tpm2.RCLockout {
+		return &failure{exitLockout, err}
+	}
+	return err
+}
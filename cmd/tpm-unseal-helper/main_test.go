@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/atrest"
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+type ignoreClose struct {
+	io.ReadWriter
+}
+
+func (ignoreClose) Close() error {
+	return nil
+}
+
+// blockingTPM never responds.
+type blockingTPM struct {
+	block chan struct{}
+}
+
+func (b blockingTPM) Read([]byte) (int, error) {
+	<-b.block
+	return 0, io.EOF
+}
+
+func (b blockingTPM) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (b blockingTPM) Close() error {
+	return nil
+}
+
+func sealToPCR7(t *testing.T, rw io.ReadWriter, secret []byte) []byte {
+	t.Helper()
+	srk, err := client.StorageRootKeyRSA(rw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	sealed, err := srk.Seal(secret, client.SealOpts{
+		Current: tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{7}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := prototext.Marshal(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func runHelper(t *testing.T, rw io.ReadWriter, stdin []byte, setuid bool, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, bytes.NewReader(stdin), &stdout, &stderr, setuid,
+		func(string) (io.ReadWriteCloser, error) {
+			if rw == nil {
+				return nil, errors.New("no such device")
+			}
+			return ignoreClose{rw}, nil
+		})
+	return code, stdout.String(), stderr.String()
+}
+
+// writeAllowFile points the helper at an allow file with the given contents
+// for the duration of the test.
+func writeAllowFile(t *testing.T, contents string, perm os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tpm-unseal-helper.allow")
+	if err := ioutil.WriteFile(path, []byte(contents), perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+	oldAllowFile := allowFile
+	t.Cleanup(func() { allowFile = oldAllowFile })
+	allowFile = path
+	return path
+}
+
+func TestUnsealHelper(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	sealed := sealToPCR7(t, rwc, []byte("disk passphrase"))
+	writeAllowFile(t, strconv.Itoa(os.Getuid())+"\n", 0644)
+
+	code, stdout, stderr := runHelper(t, rwc, sealed, true)
+	if code != 0 {
+		t.Fatalf("helper failed with status %d: %s", code, stderr)
+	}
+	if stdout != "disk passphrase" || stderr != "" {
+		t.Errorf("helper wrote %q to stdout and %q to stderr", stdout, stderr)
+	}
+
+	// Once PCR 7 changes, unsealing fails with a policy error.
+	if err := tpm2.PCRExtend(rwc, 7, tpm2.AlgSHA256, bytes.Repeat([]byte{1}, 32), ""); err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, stderr = runHelper(t, rwc, sealed, false)
+	if code != exitPolicy || stdout != "" || !strings.HasPrefix(stderr, "tpm-unseal-helper: POLICY: ") {
+		t.Errorf("helper returned %d, %q, %q; want a POLICY failure", code, stdout, stderr)
+	}
+}
+
+func TestUnsealHelperJSON(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	var sealed pb.SealedBytes
+	if err := prototext.Unmarshal(sealToPCR7(t, rwc, []byte("disk passphrase")), &sealed); err != nil {
+		t.Fatal(err)
+	}
+	// As written by "gotpm seal --format=json".
+	data, err := protojson.MarshalOptions{Multiline: true, UseProtoNames: true}.Marshal(&sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runHelper(t, rwc, data, true)
+	if code != 0 {
+		t.Fatalf("helper failed with status %d: %s", code, stderr)
+	}
+	if stdout != "disk passphrase" || stderr != "" {
+		t.Errorf("helper wrote %q to stdout and %q to stderr", stdout, stderr)
+	}
+}
+
+func TestUnsealHelperWrapped(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	wrapped, err := atrest.WrapBlob(rwc, sealToPCR7(t, rwc, []byte("disk passphrase")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runHelper(t, rwc, wrapped, true)
+	if code != 0 {
+		t.Fatalf("helper failed with status %d: %s", code, stderr)
+	}
+	if stdout != "disk passphrase" || stderr != "" {
+		t.Errorf("helper wrote %q to stdout and %q to stderr", stdout, stderr)
+	}
+
+	// Wrapped data which is not sealed data is still an input error.
+	wrapped, err = atrest.WrapBlob(rwc, []byte("not sealed data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _, _ := runHelper(t, rwc, wrapped, false); code != exitInput {
+		t.Errorf("helper returned %d for wrapped invalid input, want %d", code, exitInput)
+	}
+}
+
+func TestUnsealHelperFailures(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	sealed := sealToPCR7(t, rwc, []byte("secret"))
+	blocking := blockingTPM{make(chan struct{})}
+	defer close(blocking.block)
+	writeAllowFile(t, "# nobody\n", 0644)
+
+	testcases := []struct {
+		name   string
+		rw     io.ReadWriter
+		stdin  []byte
+		setuid bool
+		args   []string
+		code   int
+	}{
+		{"UnknownFlag", rwc, sealed, false, []string{"-verbose"}, exitUsage},
+		{"ExtraArgs", rwc, sealed, false, []string{"file"}, exitUsage},
+		{"BadTimeout", rwc, sealed, false, []string{"-timeout", "0s"}, exitUsage},
+		{"SetuidPath", rwc, sealed, true, []string{"-tpm-path", "/dev/null"}, exitUsage},
+		{"SetuidDenied", rwc, sealed, true, nil, exitDenied},
+		{"Garbage", rwc, []byte("not a proto"), false, nil, exitInput},
+		{"TooLarge", rwc, bytes.Repeat([]byte(" "), maxInputSize+1), false, nil, exitInput},
+		{"UnknownSRK", rwc, []byte("srk: 5"), false, nil, exitInput},
+		{"NoTPM", nil, sealed, false, nil, exitNoTPM},
+		{"Timeout", blocking, sealed, false, []string{"-timeout", "10ms"}, exitTimeout},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, stdout, stderr := runHelper(t, tc.rw, tc.stdin, tc.setuid, tc.args...)
+			if code != tc.code {
+				t.Errorf("helper returned %d (%s), want %d", code, stderr, tc.code)
+			}
+			if stdout != "" {
+				t.Errorf("helper wrote %q to stdout", stdout)
+			}
+			if want := "tpm-unseal-helper: " + codeNames[tc.code] + ": "; !strings.HasPrefix(stderr, want) ||
+				strings.Count(stderr, "\n") != 1 {
+				t.Errorf("stderr = %q, want a single line starting with %q", stderr, want)
+			}
+		})
+	}
+}
+
+func TestUnsealHelperLockout(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	sealed := sealToPCR7(t, rwc, []byte("secret"))
+
+	// Put the TPM into lockout with bad passwords for a DA-protected object.
+	srk, err := client.StorageRootKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	template := tpm2.Public{
+		Type:                tpm2.AlgKeyedHash,
+		NameAlg:             tpm2.AlgSHA256,
+		Attributes:          tpm2.FlagFixedTPM | tpm2.FlagFixedParent | tpm2.FlagUserWithAuth,
+		KeyedHashParameters: &tpm2.KeyedHashParams{Alg: tpm2.AlgNull},
+	}
+	priv, pub, _, _, _, err := tpm2.CreateKeyWithSensitive(rwc, srk.Handle(), tpm2.PCRSelection{},
+		"", "password", template, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	object, _, err := tpm2.Load(rwc, srk.Handle(), "", pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpm2.FlushContext(rwc, object)
+	for tries := 0; ; tries++ {
+		_, err := tpm2.Unseal(rwc, object, "wrong")
+		var warning tpm2.Warning
+		if errors.As(err, &warning) && warning.Code == tpm2.RCLockout {
+			break
+		}
+		if tries == 100 {
+			t.Fatalf("TPM not in lockout after %d tries: %v", tries, err)
+		}
+	}
+
+	code, stdout, stderr := runHelper(t, rwc, sealed, false)
+	if code != exitLockout || stdout != "" {
+		t.Errorf("helper returned %d, %q, %q; want a LOCKOUT failure", code, stdout, stderr)
+	}
+}
+
+func TestAuthorizeCaller(t *testing.T) {
+	uid, euid := os.Getuid(), os.Geteuid()
+	name := ""
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	testcases := []struct {
+		name     string
+		contents string
+		perm     os.FileMode
+		allowed  bool
+	}{
+		{"UID", "# users\n" + strconv.Itoa(uid) + "\n", 0644, true},
+		{"Name", name + "\n", 0600, name != ""},
+		{"NotListed", strconv.Itoa(uid+1) + "\n# " + strconv.Itoa(uid) + "\n", 0644, false},
+		{"Empty", "", 0644, false},
+		{"GroupWritable", strconv.Itoa(uid) + "\n", 0664, false},
+		{"WorldWritable", strconv.Itoa(uid) + "\n", 0646, false},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeAllowFile(t, tc.contents, tc.perm)
+			if err := authorizeCaller(path, uid, euid); (err == nil) != tc.allowed {
+				t.Errorf("authorizeCaller() = %v, want allowed: %v", err, tc.allowed)
+			}
+		})
+	}
+	if err := authorizeCaller(filepath.Join(t.TempDir(), "missing"), uid, euid); err == nil {
+		t.Error("authorizeCaller() without an allow file should fail")
+	}
+	// The file must be owned by the helper's owner.
+	path := writeAllowFile(t, strconv.Itoa(uid)+"\n", 0644)
+	if err := authorizeCaller(path, uid, euid+1); err == nil {
+		t.Error("authorizeCaller() with an allow file owned by another user should fail")
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+import "os"
+
+// fileOwner is not supported on Windows, which has no setuid executables.
+func fileOwner(os.FileInfo) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/google/go-tpm-tools/atrest"
+)
+
+var wrapCmd = &cobra.Command{
+	Use:   "wrap <file>...",
+	Short: "Encrypt existing sealed data files so they can only be read on this machine",
+	Long: `Wrap existing plaintext sealed data files in place, as if they were written
+by "gotpm seal --wrap"
+
+Each file is encrypted with a key sealed by the TPM's Storage Root Key, and
+atomically replaced, keeping its permissions. Files which are already wrapped
+are left unchanged, so this can be run repeatedly while migrating. Wrapped
+files are unwrapped transparently by "gotpm unseal" and tpm-unseal-helper.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rwc, err := openTpm()
+		if err != nil {
+			return err
+		}
+		defer rwc.Close()
+
+		report := wrapReport{Files: []wrappedFile{}}
+		for _, name := range args {
+			wrapped, err := atrest.WrapBlobFile(rwc, name)
+			if err != nil {
+				return err
+			}
+			report.Files = append(report.Files, wrappedFile{name, wrapped})
+			if jsonOutput() {
+				continue
+			}
+			if wrapped {
+				fmt.Fprintf(messageOutput(), "Wrapped %s\n", name)
+			} else {
+				fmt.Fprintf(messageOutput(), "%s is already wrapped\n", name)
+			}
+		}
+		if jsonOutput() {
+			return writeResult(report)
+		}
+		return nil
+	},
+}
+
+// wrapReport is the JSON output of "gotpm wrap": each file, and whether it was
+// wrapped (rather than already being wrapped).
+type wrapReport struct {
+	Files []wrappedFile `json:"files"`
+}
+
+type wrappedFile struct {
+	File    string `json:"file"`
+	Wrapped bool   `json:"wrapped"`
+}
+
+func init() {
+	RootCmd.AddCommand(wrapCmd)
+}
@@ -4,8 +4,14 @@ module github.com/google/go-tpm-tools
 go 1.16
 
 require (
+	github.com/fxamacker/cbor/v2 v2.4.0
 	github.com/google/go-attestation v0.3.2
+	github.com/google/go-cmp v0.5.5
 	github.com/google/go-tpm v0.3.2
+	github.com/miekg/pkcs11 v1.0.3
 	github.com/spf13/cobra v1.1.3
+	github.com/spf13/pflag v1.0.5
+	golang.org/x/crypto v0.0.0-20210314154223-e6e6c4f2bb5b
+	google.golang.org/grpc v1.43.0
 	google.golang.org/protobuf v1.27.1
 )
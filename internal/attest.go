@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// Attestation structure tags which go-tpm cannot decode, from Part 2 of the
+// spec, Table 19.
+const (
+	TagAttestNV   tpmutil.Tag = 0x8014
+	TagAttestTime tpmutil.Tag = 0x8019
+)
+
+// The TPM_GENERATED_VALUE at the start of all TPMS_ATTEST structures.
+const generatedValue uint32 = 0xff544347
+
+// Attest is a TPMS_ATTEST structure. Unlike tpm2.AttestationData, the
+// type-specific Attested field is left encoded, so attestations of any type
+// can be decoded.
+type Attest struct {
+	Type            tpmutil.Tag
+	QualifiedSigner tpmutil.U16Bytes
+	ExtraData       tpmutil.U16Bytes
+	ClockInfo       tpm2.ClockInfo
+	FirmwareVersion uint64
+	Attested        []byte
+}
+
+// DecodeAttest decodes a TPMS_ATTEST, checking that it starts with
+// TPM_GENERATED_VALUE.
+func DecodeAttest(data []byte) (*Attest, error) {
+	buf := bytes.NewBuffer(data)
+	var magic uint32
+	var attest Attest
+	if err := tpmutil.UnpackBuf(buf, &magic, &attest.Type, &attest.QualifiedSigner,
+		&attest.ExtraData, &attest.ClockInfo, &attest.FirmwareVersion); err != nil {
+		return nil, fmt.Errorf("decoding attestation data: %v", err)
+	}
+	if magic != generatedValue {
+		return nil, fmt.Errorf("incorrect magic value: %x", magic)
+	}
+	attest.Attested = buf.Bytes()
+	return &attest, nil
+}
+
+// VerifyAttestSignature checks that rawSig, a TPMT_SIGNATURE, is a signature
+// of the attestation data by the trusted public key. It returns the hash
+// algorithm used by the signature.
+//
+// VerifyAttestSignature supports ECDSA and RSASSA signature verification.
+func VerifyAttestSignature(attest []byte, rawSig []byte, trustedPub crypto.PublicKey) (crypto.Hash, error) {
+	sig, err := tpm2.DecodeSignature(bytes.NewBuffer(rawSig))
+	if err != nil {
+		return 0, fmt.Errorf("signature decoding failed: %v", err)
+	}
+
+	var hashAlg tpm2.Algorithm
+	switch pub := trustedPub.(type) {
+	case *ecdsa.PublicKey:
+		if sig.ECC == nil {
+			return 0, fmt.Errorf("signature algorithm 0x%x does not match ECC public key", sig.Alg)
+		}
+		hashAlg = sig.ECC.HashAlg
+	case *rsa.PublicKey:
+		if sig.RSA == nil {
+			return 0, fmt.Errorf("signature algorithm 0x%x does not match RSA public key", sig.Alg)
+		}
+		hashAlg = sig.RSA.HashAlg
+	default:
+		return 0, fmt.Errorf("only RSA and ECC public keys are currently supported, received type: %T", pub)
+	}
+	hash, err := hashAlg.Hash()
+	if err != nil {
+		return 0, err
+	}
+	// In FIPS mode, disallowed algorithms must not be used at all, even to
+	// verify signatures.
+	if FIPSMode {
+		if err := CheckFIPSPublicKey(trustedPub); err != nil {
+			return 0, err
+		}
+		if err := CheckFIPSHash(hash); err != nil {
+			return 0, err
+		}
+	}
+
+	switch pub := trustedPub.(type) {
+	case *ecdsa.PublicKey:
+		err = verifyECDSAQuoteSignature(pub, hash, attest, sig)
+	case *rsa.PublicKey:
+		err = verifyRSASSAQuoteSignature(pub, hash, attest, sig)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return hash, nil
+}
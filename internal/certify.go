@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// VerifyKeyCertification checks that a key certification was signed by
+// trustedPub over extraData, and that it certifies the provided public area,
+// which is returned.
+func VerifyKeyCertification(c *pb.KeyCertification, trustedPub crypto.PublicKey, extraData []byte) (tpm2.Public, error) {
+	var pub tpm2.Public
+	if _, err := VerifyAttestSignature(c.GetCertifyInfo(), c.GetRawSig(), trustedPub); err != nil {
+		return pub, err
+	}
+	attest, err := DecodeAttest(c.GetCertifyInfo())
+	if err != nil {
+		return pub, err
+	}
+	if attest.Type != tpm2.TagAttestCertify {
+		return pub, fmt.Errorf("expected certify tag, got: %v", attest.Type)
+	}
+	if subtle.ConstantTimeCompare(attest.ExtraData, extraData) == 0 {
+		return pub, fmt.Errorf("key certification %w", ErrExtraDataMismatch)
+	}
+
+	var info struct {
+		Name          tpmutil.U16Bytes
+		QualifiedName tpmutil.U16Bytes
+	}
+	if _, err := tpmutil.Unpack(attest.Attested, &info); err != nil {
+		return pub, fmt.Errorf("decoding certify info: %v", err)
+	}
+	if pub, err = tpm2.DecodePublic(c.GetPublicArea()); err != nil {
+		return pub, fmt.Errorf("decoding public area: %v", err)
+	}
+	name, err := pub.Name()
+	if err != nil {
+		return pub, err
+	}
+	encodedName, err := name.Digest.Encode()
+	if err != nil {
+		return pub, err
+	}
+	if !bytes.Equal(info.Name, encodedName) {
+		return pub, fmt.Errorf("certified key does not match the provided public area")
+	}
+	return pub, nil
+}
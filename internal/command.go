@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// TPM 2.0 commands which are not yet implemented by go-tpm, from Part 2 of the
+// spec, Table 12.
+const (
+	CmdNVExtend                tpmutil.Command = 0x00000136
+	CmdSelfTest                tpmutil.Command = 0x00000143
+	CmdPolicyNV                tpmutil.Command = 0x00000149
+	CmdDuplicate               tpmutil.Command = 0x0000014B
+	CmdGetTime                 tpmutil.Command = 0x0000014C
+	CmdHMAC                    tpmutil.Command = 0x00000155
+	CmdHMACStart               tpmutil.Command = 0x0000015B
+	CmdPolicySigned            tpmutil.Command = 0x00000160
+	CmdPolicyAuthorize         tpmutil.Command = 0x0000016A
+	CmdPolicyAuthValue         tpmutil.Command = 0x0000016B
+	CmdPolicyLocality          tpmutil.Command = 0x0000016F
+	CmdVerifySignature         tpmutil.Command = 0x00000177
+	CmdGetTestResult           tpmutil.Command = 0x0000017C
+	CmdPolicyRestart           tpmutil.Command = 0x00000180
+	CmdNVCertify               tpmutil.Command = 0x00000184
+	CmdPolicyDuplicationSelect tpmutil.Command = 0x00000188
+)
+
+// RunCommand runs a TPM command which go-tpm does not implement. The handles
+// are followed by the authorizations (if any) for the first len(auths)
+// handles, and then by the command parameters. The response parameters are
+// returned, without the authorization area. Commands which return handles are
+// not supported (see RunHandleCommand).
+func RunCommand(rw io.ReadWriter, cmd tpmutil.Command, handles []tpmutil.Handle, auths []tpm2.AuthCommand, params ...interface{}) ([]byte, error) {
+	_, resp, err := runCommand(rw, cmd, false, handles, auths, params...)
+	return resp, err
+}
+
+// RunHandleCommand runs a TPM command like RunCommand, for commands which
+// return a single handle (such as TPM2_HMAC_Start), which is returned before
+// the response parameters.
+func RunHandleCommand(rw io.ReadWriter, cmd tpmutil.Command, handles []tpmutil.Handle, auths []tpm2.AuthCommand, params ...interface{}) (tpmutil.Handle, []byte, error) {
+	return runCommand(rw, cmd, true, handles, auths, params...)
+}
+
+func runCommand(rw io.ReadWriter, cmd tpmutil.Command, outHandle bool, handles []tpmutil.Handle, auths []tpm2.AuthCommand, params ...interface{}) (tpmutil.Handle, []byte, error) {
+	in := make([]interface{}, 0, len(handles)+1+len(params))
+	for _, handle := range handles {
+		in = append(in, handle)
+	}
+	tag := tpm2.TagNoSessions
+	if len(auths) > 0 {
+		tag = tpm2.TagSessions
+		var authArea []byte
+		for _, auth := range auths {
+			encoded, err := tpmutil.Pack(auth)
+			if err != nil {
+				return 0, nil, err
+			}
+			authArea = append(authArea, encoded...)
+		}
+		in = append(in, uint32(len(authArea)), tpmutil.RawBytes(authArea))
+	}
+	in = append(in, params...)
+
+	resp, code, err := tpmutil.RunCommand(rw, tag, cmd, in...)
+	if err != nil {
+		return 0, nil, err
+	}
+	if code != tpmutil.RCSuccess {
+		return 0, nil, fmt.Errorf("command 0x%x failed with response code 0x%x", uint32(cmd), uint32(code))
+	}
+	var handle tpmutil.Handle
+	if outHandle {
+		if _, err := tpmutil.Unpack(resp, &handle); err != nil {
+			return 0, nil, fmt.Errorf("decoding response handle: %w", err)
+		}
+		resp = resp[4:]
+	}
+	if tag == tpm2.TagNoSessions {
+		return handle, resp, nil
+	}
+	// With sessions, the parameters are prefixed with their size, and are
+	// followed by the authorization responses.
+	var paramSize uint32
+	if _, err := tpmutil.Unpack(resp, &paramSize); err != nil {
+		return 0, nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if uint64(len(resp)) < 4+uint64(paramSize) {
+		return 0, nil, fmt.Errorf("decoding response: parameter size %d exceeds response size %d", paramSize, len(resp))
+	}
+	return handle, resp[4 : 4+paramSize], nil
+}
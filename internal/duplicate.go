@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"crypto"
+
+	"github.com/google/go-tpm-tools/policy"
+)
+
+// PolicyDuplicationSelect extends a policy digest with a
+// TPM2_PolicyDuplicationSelect assertion, which only allows an object to be
+// duplicated (with TPM2_Duplicate) to the new parent with the provided Name. If
+// includeObject is false, the objectName is not part of the policy, so the
+// policy can be an object's own authPolicy. Names are TPMU_NAME values (the
+// name algorithm followed by the digest). A nil oldDigest is treated as the
+// all-zero initial policy digest.
+func PolicyDuplicationSelect(oldDigest, objectName, newParentName []byte, includeObject bool, hashAlg crypto.Hash) ([]byte, error) {
+	p := policy.New(hashAlg)
+	if oldDigest != nil {
+		p = policy.From(hashAlg, oldDigest)
+	}
+	return p.DuplicationSelect(objectName, newParentName, includeObject).Digest()
+}
@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+)
+
+// TrimNVCertificate returns the DER encoding of a certificate, removing the
+// header and any padding present when the certificate is read from NVRAM (see
+// the TCG PC Client Platform TPM Profile, Section 7.3.2).
+func TrimNVCertificate(cert []byte) ([]byte, error) {
+	if len(cert) > 5 && bytes.Equal(cert[:3], []byte{0x10, 0x01, 0x00}) {
+		certLen := int(binary.BigEndian.Uint16(cert[3:5]))
+		if len(cert) < certLen+5 {
+			return nil, fmt.Errorf("NVRAM header specifies length %d, but only %d bytes present", certLen, len(cert)-5)
+		}
+		cert = cert[5 : 5+certLen]
+	}
+	var raw asn1.RawValue
+	if _, err := asn1.Unmarshal(cert, &raw); err != nil {
+		return nil, err
+	}
+	return raw.FullBytes, nil
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+// MinFIPSRSABits is the smallest RSA modulus allowed in FIPS mode.
+const MinFIPSRSABits = 2048
+
+// CheckFIPSHash returns an error if hash may not be used for signatures or PCR
+// banks in FIPS mode. SHA-1 is not allowed.
+func CheckFIPSHash(hash crypto.Hash) error {
+	switch hash {
+	case crypto.SHA256, crypto.SHA384, crypto.SHA512:
+		return nil
+	default:
+		return fmt.Errorf("hash algorithm %v is not allowed in FIPS mode", hash)
+	}
+}
+
+// CheckFIPSPublicKey returns an error if pub may not be used for signatures in
+// FIPS mode. Only RSA keys of at least MinFIPSRSABits bits, and ECDSA keys on
+// the NIST P-256, P-384 and P-521 curves, are allowed.
+func CheckFIPSPublicKey(pub crypto.PublicKey) error {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if bits := pub.N.BitLen(); bits < MinFIPSRSABits {
+			return fmt.Errorf("%d-bit RSA keys are not allowed in FIPS mode, the minimum is %d bits", bits, MinFIPSRSABits)
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+			return nil
+		}
+		return fmt.Errorf("ECDSA curve %s is not allowed in FIPS mode", pub.Curve.Params().Name)
+	default:
+		return fmt.Errorf("public keys of type %T are not allowed in FIPS mode", pub)
+	}
+}
+
+// CheckFIPSCertificate returns an error if cert's public key, or the algorithm
+// of its signature, is not allowed in FIPS mode.
+func CheckFIPSCertificate(cert *x509.Certificate) error {
+	if err := CheckFIPSPublicKey(cert.PublicKey); err != nil {
+		return fmt.Errorf("certificate %q: %w", cert.Subject, err)
+	}
+	switch cert.SignatureAlgorithm {
+	case x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+		x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS,
+		x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512:
+		return nil
+	default:
+		return fmt.Errorf("certificate %q: signature algorithm %v is not allowed in FIPS mode", cert.Subject, cert.SignatureAlgorithm)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !fips
+// +build !fips
+
+package internal
+
+// FIPSMode is true when built with the fips build tag. Verification then only
+// accepts algorithms and key sizes approved for FIPS 140 (see CheckFIPSHash
+// and CheckFIPSPublicKey), regardless of options such as
+// server.VerifyOpts.AllowSHA1.
+const FIPSMode = false
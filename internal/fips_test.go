@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestCheckFIPSHash(t *testing.T) {
+	for _, hash := range []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		if err := CheckFIPSHash(hash); err != nil {
+			t.Errorf("CheckFIPSHash(%v) failed: %v", hash, err)
+		}
+	}
+	if err := CheckFIPSHash(crypto.SHA1); err == nil {
+		t.Error("CheckFIPSHash(SHA1) should fail")
+	}
+}
+
+func TestCheckFIPSPublicKey(t *testing.T) {
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subtests := []struct {
+		name    string
+		pub     crypto.PublicKey
+		allowed bool
+	}{
+		{"RSA-1024", rsa1024.Public(), false},
+		{"RSA-2048", rsa2048.Public(), true},
+		{"ECDSA-P224", p224.Public(), false},
+		{"ECDSA-P256", p256.Public(), true},
+		{"Unknown", "not a key", false},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			err := CheckFIPSPublicKey(subtest.pub)
+			if subtest.allowed && err != nil {
+				t.Errorf("key should be allowed: %v", err)
+			}
+			if !subtest.allowed && err == nil {
+				t.Error("key should not be allowed")
+			}
+		})
+	}
+}
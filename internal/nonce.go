@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"crypto"
+	"fmt"
+)
+
+// NonceExtraData returns the extraData which binds a nonce to a TPM
+// attestation. If hash is zero, the nonce is used directly, so it must fit in
+// the TPM's extraData (a TPM2B_DATA, which is no larger than the TPM's largest
+// digest: 32 bytes on TPMs which only implement SHA-256, and 48 or 64 bytes on
+// those which also implement SHA-384 or SHA-512). Otherwise, the digest of the
+// nonce is used, which allows nonces of any length. Only SHA-256, SHA-384 and
+// SHA-512 can be used.
+func NonceExtraData(nonce []byte, hash crypto.Hash) ([]byte, error) {
+	switch hash {
+	case 0:
+		return nonce, nil
+	case crypto.SHA256, crypto.SHA384, crypto.SHA512:
+		hasher := hash.New()
+		hasher.Write(nonce)
+		return hasher.Sum(nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported nonce hash algorithm %v", hash)
+	}
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/google/go-tpm-tools/policy"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// Values of the TPM_NT field of the NV attributes, from Part 2 of the spec,
+// Table 204, which go-tpm does not define.
+const (
+	NVTypeMask   tpm2.NVAttr = 0xF << 4
+	NVTypeExtend tpm2.NVAttr = 0x4 << 4
+)
+
+// NVName computes the Name of an NV index from its public area, see Part 1 of
+// the spec, Section 16.
+func NVName(pub tpm2.NVPublic) ([]byte, error) {
+	return policy.NVName(pub)
+}
+
+// PolicyNVAuth extends a policy digest with a TPM2_PolicyNV assertion that
+// operandB compares to the contents of an NV index (starting at offset)
+// according to operation, a TPM_EO value. A nil oldDigest is treated as the
+// all-zero initial policy digest.
+func PolicyNVAuth(oldDigest []byte, nvName []byte, operandB []byte, offset uint16, operation uint16, hashAlg crypto.Hash) ([]byte, error) {
+	p := policy.New(hashAlg)
+	if oldDigest != nil {
+		p = policy.From(hashAlg, oldDigest)
+	}
+	return p.NV(nvName, operandB, offset, operation).Digest()
+}
+
+// VerifyNVCertification performs the following checks to validate an
+// NVCertification, and returns the certified NV public area and contents:
+//   - the provided signature is generated by the trusted public key
+//   - the signature signs the provided certify info
+//   - the certify info starts with TPM_GENERATED_VALUE
+//   - the certify info is a valid TPMS_NV_CERTIFY_INFO
+//   - the certified index has the Name of the provided NV public area
+//   - the whole of the index's contents are certified
+//   - the provided extraData matches that in the certify info
+// Note that the caller must have already established trust in the provided
+// public key before validating the NVCertification.
+func VerifyNVCertification(c *pb.NVCertification, trustedPub crypto.PublicKey, extraData []byte) (tpm2.NVPublic, []byte, error) {
+	var pub tpm2.NVPublic
+	if _, err := VerifyAttestSignature(c.GetCertifyInfo(), c.GetRawSig(), trustedPub); err != nil {
+		return pub, nil, err
+	}
+	attest, err := DecodeAttest(c.GetCertifyInfo())
+	if err != nil {
+		return pub, nil, err
+	}
+	if attest.Type != TagAttestNV {
+		return pub, nil, fmt.Errorf("expected NV certify tag, got: %v", attest.Type)
+	}
+	if subtle.ConstantTimeCompare(attest.ExtraData, extraData) == 0 {
+		return pub, nil, fmt.Errorf("NV certification %w", ErrExtraDataMismatch)
+	}
+
+	var info struct {
+		IndexName  tpmutil.U16Bytes
+		Offset     uint16
+		NVContents tpmutil.U16Bytes
+	}
+	if _, err := tpmutil.Unpack(attest.Attested, &info); err != nil {
+		return pub, nil, fmt.Errorf("decoding NV certify info: %v", err)
+	}
+	if _, err := tpmutil.Unpack(c.GetNvPublic(), &pub); err != nil {
+		return pub, nil, fmt.Errorf("decoding NV public area: %v", err)
+	}
+	name, err := NVName(pub)
+	if err != nil {
+		return pub, nil, err
+	}
+	if !bytes.Equal(info.IndexName, name) {
+		return pub, nil, fmt.Errorf("certified NV index does not match the provided NV public area")
+	}
+	if info.Offset != 0 || len(info.NVContents) != int(pub.DataSize) {
+		return pub, nil, fmt.Errorf("NV certification covers %d bytes at offset %d, not the whole %d byte index",
+			len(info.NVContents), info.Offset, pub.DataSize)
+	}
+	return pub, info.NVContents, nil
+}
@@ -7,9 +7,9 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/google/go-tpm-tools/policy"
 	pb "github.com/google/go-tpm-tools/proto/tpm"
 	"github.com/google/go-tpm/tpm2"
-	"github.com/google/go-tpm/tpmutil"
 )
 
 const minPCRIndex = uint32(0)
@@ -87,44 +87,13 @@ func SamePCRSelection(p *pb.PCRs, sel tpm2.PCRSelection) bool {
 }
 
 // PCRSessionAuth calculates the authorization value for the given PCRs.
-func PCRSessionAuth(p *pb.PCRs, hashAlg crypto.Hash) []byte {
-	// Start with all zeros, we only use a single policy command on our session.
-	oldDigest := make([]byte, hashAlg.Size())
-	ccPolicyPCR, _ := tpmutil.Pack(tpm2.CmdPolicyPCR)
-
-	// Extend the policy digest, see TPM2_PolicyPCR in Part 3 of the spec.
-	hash := hashAlg.New()
-	hash.Write(oldDigest)
-	hash.Write(ccPolicyPCR)
-	hash.Write(encodePCRSelection(PCRSelection(p)))
-	hash.Write(PCRDigest(p, hashAlg))
-	newDigest := hash.Sum(nil)
-	return newDigest[:]
+func PCRSessionAuth(p *pb.PCRs, hashAlg crypto.Hash) ([]byte, error) {
+	// We only use a single policy command on our session.
+	return policy.New(hashAlg).PCRValues(p).Digest()
 }
 
 // PCRDigest computes the digest of the Pcrs. Note that the digest hash
 // algorithm may differ from the PCRs' hash (which denotes the PCR bank).
 func PCRDigest(p *pb.PCRs, hashAlg crypto.Hash) []byte {
-	hash := hashAlg.New()
-	for i := uint32(0); i < 24; i++ {
-		if pcrValue, exists := p.GetPcrs()[i]; exists {
-			hash.Write(pcrValue)
-		}
-	}
-	return hash.Sum(nil)
-}
-
-// Encode a tpm2.PCRSelection as if it were a TPML_PCR_SELECTION
-func encodePCRSelection(sel tpm2.PCRSelection) []byte {
-	// Encode count, pcrSelections.hash and pcrSelections.sizeofSelect fields
-	buf, _ := tpmutil.Pack(uint32(1), sel.Hash, byte(3))
-	// Encode pcrSelect bitmask
-	pcrBits := make([]byte, 3)
-	for _, pcr := range sel.PCRs {
-		byteNum := pcr / 8
-		bytePos := 1 << uint(pcr%8)
-		pcrBits[byteNum] |= byte(bytePos)
-	}
-
-	return append(buf, pcrBits...)
+	return policy.PCRDigest(p, hashAlg)
 }
@@ -1,17 +1,21 @@
 package internal
 
 import (
-	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/subtle"
+	"errors"
 	"fmt"
 
 	pb "github.com/google/go-tpm-tools/proto/tpm"
 	"github.com/google/go-tpm/tpm2"
 )
 
+// ErrExtraDataMismatch is wrapped by the errors returned when the extraData in
+// a quote, certification or time attestation is not the expected one.
+var ErrExtraDataMismatch = errors.New("extraData did not match expected extraData")
+
 // VerifyQuote performs the following checks to validate a Quote:
 //    - the provided signature is generated by the trusted AK public key
 //    - the signature signs the provided quote data
@@ -23,34 +27,12 @@ import (
 // Note that the caller must have already established trust in the provided
 // public key before validating the Quote.
 //
-// VerifyQuote supports ECDSA and RSASSA signature verification.
+// VerifyQuote supports ECDSA and RSASSA signature verification. The quote
+// package provides a stable public API for it, with more checks.
 func VerifyQuote(q *pb.Quote, trustedPub crypto.PublicKey, extraData []byte) error {
-	sig, err := tpm2.DecodeSignature(bytes.NewBuffer(q.GetRawSig()))
+	hash, err := VerifyAttestSignature(q.GetQuote(), q.GetRawSig(), trustedPub)
 	if err != nil {
-		return fmt.Errorf("signature decoding failed: %v", err)
-	}
-
-	var hash crypto.Hash
-	switch pub := trustedPub.(type) {
-	case *ecdsa.PublicKey:
-		hash, err = sig.ECC.HashAlg.Hash()
-		if err != nil {
-			return err
-		}
-		if err = verifyECDSAQuoteSignature(pub, hash, q.GetQuote(), sig); err != nil {
-			return err
-		}
-	case *rsa.PublicKey:
-		hash, err = sig.RSA.HashAlg.Hash()
-		if err != nil {
-			return err
-		}
-		if err = verifyRSASSAQuoteSignature(pub, hash, q.GetQuote(), sig); err != nil {
-			return err
-		}
-	default:
-		return fmt.Errorf("only RSA and ECC public keys are currently supported, received type: %T", pub)
-
+		return err
 	}
 
 	// Decode and check for magic TPMS_GENERATED_VALUE.
@@ -66,7 +48,7 @@ func VerifyQuote(q *pb.Quote, trustedPub crypto.PublicKey, extraData []byte) err
 		return fmt.Errorf("attestation data does not contain quote info")
 	}
 	if subtle.ConstantTimeCompare(attestationData.ExtraData, extraData) == 0 {
-		return fmt.Errorf("quote extraData did not match expected extraData")
+		return fmt.Errorf("quote %w", ErrExtraDataMismatch)
 	}
 	return validatePCRDigest(attestedQuoteInfo, q.GetPcrs(), hash)
 }
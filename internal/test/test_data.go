@@ -19,3 +19,10 @@ var (
 	//go:embed eventlogs/ubuntu-2104-no-secure-boot.bin
 	Ubuntu2104NoSecureBootEventLog []byte
 )
+
+// Real EK certificates, for testing against the bundled EK roots
+var (
+	// An RSA EK certificate from a GCE Shielded VM's vTPM
+	//go:embed certificates/gce-ek-rsa.pem
+	GCEEKCertRSA []byte
+)
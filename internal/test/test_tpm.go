@@ -1,21 +1,21 @@
 package test
 
 import (
+	"bytes"
 	"io"
-	"sync"
 	"testing"
 
 	"github.com/google/go-attestation/attest"
-	"github.com/google/go-tpm-tools/simulator"
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/testutil/tpmtest"
 	"github.com/google/go-tpm/tpm2"
 	"github.com/google/go-tpm/tpmutil"
 )
 
-// Only open the TPM device once. Reopening the device causes issues on Linux.
-var (
-	tpm  io.ReadWriteCloser
-	lock sync.Mutex
-)
+// EV_NO_ACTION events are informational, and are not extended into PCRs.
+const eventTypeNoAction = 0x03
+
+const startupLocalitySignature = "StartupLocality\x00"
 
 // PCR registers that are OK to use in tests (can be reset without reboot)
 var (
@@ -23,14 +23,6 @@ var (
 	ApplicationPCR = 23
 )
 
-type noClose struct {
-	io.ReadWriter
-}
-
-func (n noClose) Close() error {
-	return nil
-}
-
 type simulatedTpm struct {
 	io.ReadWriteCloser
 	eventLog []byte
@@ -43,63 +35,77 @@ func (s simulatedTpm) EventLog() ([]byte, error) {
 // SkipOnUnsupportedAlg skips the test if the algorithm is not found in the TPM
 // capability.
 func SkipOnUnsupportedAlg(t testing.TB, rw io.ReadWriter, alg tpm2.Algorithm) {
-	moreData := true
-	for i := uint32(0); moreData; i++ {
-		var err error
-		var descs []interface{}
-		descs, moreData, err = tpm2.GetCapability(rw, tpm2.CapabilityAlgs, 1, i)
-		if err != nil {
-			t.Fatalf("Could not get TPM algorithm capability: %v", err)
-		}
-		for _, desc := range descs {
-			if desc.(tpm2.AlgorithmDescription).ID == alg {
-				return
-			}
-		}
-		if !moreData {
-			break
-		}
-	}
-	t.Skipf("Algorithm %v is not supported by the TPM", alg)
+	t.Helper()
+	tpmtest.RequireAlgorithm(t, rw, alg)
 }
 
 // GetTPM is a cross-platform testing helper function that retrives the
-// appropriate TPM device from the flags passed into "go test".
+// appropriate TPM device from the flags passed into "go test", or from the
+// environment (see the tpmtest package).
 //
 // If using a test TPM, this will also retrieve a test eventlog. In this case,
 // GetTPM extends the test event log's events into the test TPM.
 func GetTPM(tb testing.TB) io.ReadWriteCloser {
 	tb.Helper()
 	if useRealTPM() {
-		lock.Lock()
-		defer lock.Unlock()
-		if tpm == nil {
-			var err error
-			if tpm, err = getRealTPM(); err != nil {
-				tb.Fatalf("Failed to open TPM: %v", err)
-			}
-		}
-		return noClose{tpm}
+		return tpmtest.GetTPM(tb)
 	}
+	return getTestTPM(tb, Rhel8EventLog)
+}
 
-	simulator, err := simulator.Get()
-	if err != nil {
-		tb.Fatalf("Simulator initialization failed: %v", err)
+// GetPlatformTPM is like GetTPM, but measures the events of a recorded
+// platform's event log instead of the default test event log, so the TPM's PCRs
+// have the values recorded on the platform. It fails the test if they do not.
+// Attestations by the returned TPM contain the platform's event log, so
+// verifiers can be tested against real firmware and bootloaders without the
+// hardware.
+//
+// The test is skipped if it is using a real TPM, as its PCRs cannot be set, or
+// if the platform's TPM was started up in a locality other than 0, which the
+// simulator does not support.
+func GetPlatformTPM(tb testing.TB, platform Platform) io.ReadWriteCloser {
+	tb.Helper()
+	if useRealTPM() {
+		tb.Skipf("Cannot simulate platform %s on a real TPM", platform.Name)
+	}
+	if locality := startupLocality(tb, platform.RawLog); locality != 0 {
+		tb.Skipf("Cannot simulate platform %s, which started up its TPM in locality %d", platform.Name, locality)
 	}
-	// Make sure that whatever happens, we close the simulator
-	tb.Cleanup(func() {
-		if !simulator.IsClosed() {
-			tb.Error("simulator was not properly closed")
-			if err := simulator.Close(); err != nil {
-				tb.Errorf("when closing simulator: %v", err)
+	rwc := getTestTPM(tb, platform.RawLog)
+	for _, bank := range platform.Banks {
+		sel := tpm2.PCRSelection{Hash: tpm2.Algorithm(bank.GetHash())}
+		for pcr := range bank.GetPcrs() {
+			sel.PCRs = append(sel.PCRs, int(pcr))
+		}
+		pcrs, err := client.ReadPCRs(rwc, sel)
+		if err != nil {
+			rwc.Close()
+			tb.Fatalf("Failed to read PCRs: %v", err)
+		}
+		for pcr, want := range bank.GetPcrs() {
+			if got := pcrs.GetPcrs()[pcr]; !bytes.Equal(got, want) {
+				rwc.Close()
+				tb.Fatalf("Simulating platform %s: %v PCR %d is %x, recorded %x", platform.Name, bank.GetHash(), pcr, got, want)
 			}
 		}
-	})
-	eventLog := Rhel8EventLog
+	}
+	return rwc
+}
+
+// getTestTPM returns the simulator or swtpm, with the events of eventLog
+// extended into its PCRs.
+func getTestTPM(tb testing.TB, eventLog []byte) io.ReadWriteCloser {
+	tb.Helper()
+	rwc := tpmtest.GetTPM(tb)
+	simulateEventLogEvents(tb, rwc, eventLog)
+	return simulatedTpm{rwc, eventLog}
+}
 
-	// Extend event log events on simulator TPM.
-	simulateEventLogEvents(tb, simulator, eventLog)
-	return simulatedTpm{simulator, eventLog}
+// useRealTPM returns whether the tests run against a TPM whose PCRs cannot be
+// set.
+func useRealTPM() bool {
+	kind, _ := tpmtest.Selected()
+	return kind == tpmtest.Device
 }
 
 // simulateEventLogEvents simulates the events in the test event log
@@ -121,11 +127,33 @@ func simulateEventLogEvents(tb testing.TB, rw io.ReadWriter, eventLog []byte) {
 	for tpm2Alg, attestAlg := range hashAlgs {
 		events := attestEventLog.Events(attestAlg)
 		for _, event := range events {
+			// Legacy (SHA-1 only) event logs have no SHA-256 digests.
+			if event.Type == eventTypeNoAction || len(event.Digest) == 0 {
+				continue
+			}
 			extendOnePcr(tb, rw, event.Index, tpm2Alg, event.Digest)
 		}
 	}
 }
 
+// startupLocality returns the locality the TPM was started up in, as recorded
+// by the StartupLocality event of an event log. The locality determines the
+// initial value of PCR 0.
+func startupLocality(tb testing.TB, eventLog []byte) uint8 {
+	attestEventLog, err := attest.ParseEventLog(eventLog)
+	if err != nil {
+		tb.Fatalf("Failed to parse test event log: %v", err)
+	}
+	for _, event := range attestEventLog.Events(attest.HashSHA1) {
+		if event.Type == eventTypeNoAction && event.Index == 0 &&
+			len(event.Data) == len(startupLocalitySignature)+1 &&
+			bytes.HasPrefix(event.Data, []byte(startupLocalitySignature)) {
+			return event.Data[len(startupLocalitySignature)]
+		}
+	}
+	return 0
+}
+
 func extendOnePcr(tb testing.TB, rw io.ReadWriter, pcr int, hashAlg tpm2.Algorithm, hash []byte) {
 	err := tpm2.PCRExtend(rw, tpmutil.Handle(pcr), hashAlg, hash, "")
 	if err != nil {
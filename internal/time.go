@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// TimeInfo is a TPMS_TIME_ATTEST_INFO structure.
+type TimeInfo struct {
+	// Milliseconds since the TPM was last reset or restarted.
+	Time            uint64
+	ClockInfo       tpm2.ClockInfo
+	FirmwareVersion uint64
+}
+
+// VerifyTimeAttestation performs the following checks to validate a
+// TimeAttestation, and returns the attested time:
+//   - the provided signature is generated by the trusted public key
+//   - the signature signs the provided time info
+//   - the time info starts with TPM_GENERATED_VALUE
+//   - the time info is a valid TPMS_TIME_ATTEST_INFO
+//   - the provided extraData matches that in the time info
+//
+// Note that the caller must have already established trust in the provided
+// public key before validating the TimeAttestation.
+func VerifyTimeAttestation(t *pb.TimeAttestation, trustedPub crypto.PublicKey, extraData []byte) (*TimeInfo, error) {
+	if _, err := VerifyAttestSignature(t.GetTimeInfo(), t.GetRawSig(), trustedPub); err != nil {
+		return nil, err
+	}
+	attest, err := DecodeAttest(t.GetTimeInfo())
+	if err != nil {
+		return nil, err
+	}
+	if attest.Type != TagAttestTime {
+		return nil, fmt.Errorf("expected time tag, got: %v", attest.Type)
+	}
+	if subtle.ConstantTimeCompare(attest.ExtraData, extraData) == 0 {
+		return nil, fmt.Errorf("time attestation %w", ErrExtraDataMismatch)
+	}
+	var info TimeInfo
+	if _, err := tpmutil.Unpack(attest.Attested, &info.Time, &info.ClockInfo, &info.FirmwareVersion); err != nil {
+		return nil, fmt.Errorf("decoding time info: %v", err)
+	}
+	// Only the reset and restart counts in the header are obfuscated, so the
+	// clocks must agree.
+	if info.ClockInfo.Clock != attest.ClockInfo.Clock || info.ClockInfo.Safe != attest.ClockInfo.Safe {
+		return nil, fmt.Errorf("attested clock does not match the clock in the attestation header")
+	}
+	return &info, nil
+}
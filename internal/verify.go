@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-tpm/tpm2"
+
+	attestpb "github.com/google/go-tpm-tools/proto/attest"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// ErrUntrustedAK is wrapped by the errors returned when the AK, or the key of
+// an additional quote, is not one of the trusted keys.
+var ErrUntrustedAK = errors.New("AK public key is not trusted")
+
+// SupportedHashAlgs are the hash algorithms of the quotes which can be
+// verified, in their preferred order of use.
+var SupportedHashAlgs = []tpm2.Algorithm{
+	tpm2.AlgSHA512, tpm2.AlgSHA384, tpm2.AlgSHA256, tpm2.AlgSHA1,
+}
+
+// SupportedQuotes returns the quotes of supported banks, in order of hash
+// preference.
+func SupportedQuotes(quotes []*pb.Quote) []*pb.Quote {
+	out := make([]*pb.Quote, 0, len(quotes))
+	for _, alg := range SupportedHashAlgs {
+		for _, quote := range quotes {
+			if tpm2.Algorithm(quote.GetPcrs().GetHash()) == alg {
+				out = append(out, quote)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// CheckHashAlgSupported checks that a hash algorithm can be used for
+// verification. SHA-1 can only be used if allowSHA1 is set, and never in FIPS
+// mode.
+func CheckHashAlgSupported(hash tpm2.Algorithm, allowSHA1 bool) error {
+	if hash == tpm2.AlgSHA1 && FIPSMode {
+		return fmt.Errorf("SHA-1 is not allowed for verification in FIPS mode")
+	}
+	if hash == tpm2.AlgSHA1 && !allowSHA1 {
+		return fmt.Errorf("SHA-1 is not allowed for verification (set VerifyOpts.AllowSHA1 to true to allow)")
+	}
+	for _, alg := range SupportedHashAlgs {
+		if hash == alg {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported hash algorithm: %v", hash)
+}
+
+// CheckKeyTrusted checks that a public key, such as an AK, is one of the
+// trusted keys.
+func CheckKeyTrusted(key crypto.PublicKey, trusted []crypto.PublicKey) error {
+	if len(trusted) == 0 {
+		return fmt.Errorf("%w: no mechanism for AK verification provided", ErrUntrustedAK)
+	}
+	for _, t := range trusted {
+		if pubKeysEqual(key, t) {
+			return nil
+		}
+	}
+	return ErrUntrustedAK
+}
+
+// AttestationExtraData checks that the attestation binds the nonce with the
+// expected hash algorithm (none if zero), and returns the extraData its quotes
+// must contain.
+func AttestationExtraData(attestation *attestpb.Attestation, nonce []byte, nonceHash crypto.Hash) ([]byte, error) {
+	want := pb.HashAlgo_HASH_INVALID
+	if nonceHash != 0 {
+		alg, err := tpm2.HashToAlgorithm(nonceHash)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported nonce hash algorithm %v", nonceHash)
+		}
+		want = pb.HashAlgo(alg)
+	}
+	if got := attestation.GetNonceHash(); got != want {
+		return nil, fmt.Errorf("%w: attestation binds the nonce with hash algorithm %v, but %v is expected", ErrExtraDataMismatch, got, want)
+	}
+	return NonceExtraData(nonce, nonceHash)
+}
+
+// AdditionalQuoteKeys returns the trusted public keys which signed the
+// attestation's additional quotes. Each must be distinct from the AK and from
+// the other keys, so that every PCR is only quoted by one key.
+func AdditionalQuoteKeys(attestation *attestpb.Attestation, akPubKey crypto.PublicKey, trusted []crypto.PublicKey) ([]crypto.PublicKey, error) {
+	keys := []crypto.PublicKey{akPubKey}
+	for i, additional := range attestation.GetAdditionalQuotes() {
+		pubArea, err := tpm2.DecodePublic(additional.GetKeyPub())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode public area of additional quote key %d: %w", i, err)
+		}
+		key, err := pubArea.Key()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get additional quote key %d: %w", i, err)
+		}
+		for _, other := range keys {
+			if pubKeysEqual(key, other) {
+				return nil, fmt.Errorf("additional quote key %d is not a distinct signer", i)
+			}
+		}
+		if err = CheckKeyTrusted(key, trusted); err != nil {
+			return nil, fmt.Errorf("additional quote key %d: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys[1:], nil
+}
+
+// MergeAdditionalQuotes verifies the additional quotes in the same bank as the
+// AK's verified quote, and returns all the quoted PCRs. The quotes must bind the
+// same extraData, and quote disjoint sets of PCRs. They must also have been
+// made in the same boot of the same TPM firmware as the AK's quote, so their
+// reset and restart counts, and firmware versions, must match it. The TPM
+// obfuscates these fields in quotes by keys outside the endorsement and
+// platform hierarchies, so such keys cannot sign additional quotes.
+func MergeAdditionalQuotes(akQuote *pb.Quote, additionalQuotes []*attestpb.AdditionalQuotes, keys []crypto.PublicKey, extraData []byte) (*pb.PCRs, error) {
+	pcrs := akQuote.GetPcrs()
+	if len(additionalQuotes) == 0 {
+		return pcrs, nil
+	}
+	akAttest, err := DecodeAttest(akQuote.GetQuote())
+	if err != nil {
+		return nil, err
+	}
+	merged := &pb.PCRs{Hash: pcrs.GetHash(), Pcrs: make(map[uint32][]byte)}
+	for index, value := range pcrs.GetPcrs() {
+		merged.Pcrs[index] = value
+	}
+	for i, additional := range additionalQuotes {
+		var quote *pb.Quote
+		for _, q := range additional.GetQuotes() {
+			if q.GetPcrs().GetHash() == pcrs.GetHash() {
+				quote = q
+				break
+			}
+		}
+		if quote == nil {
+			return nil, fmt.Errorf("additional quote key %d did not quote the %v bank", i, pcrs.GetHash())
+		}
+		if err := VerifyQuote(quote, keys[i], extraData); err != nil {
+			return nil, fmt.Errorf("failed to verify additional quote %d: %w", i, err)
+		}
+		attest, err := DecodeAttest(quote.GetQuote())
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSameBoot(akAttest, attest); err != nil {
+			return nil, fmt.Errorf("additional quote %d: %w", i, err)
+		}
+		for index, value := range quote.GetPcrs().GetPcrs() {
+			if _, ok := merged.Pcrs[index]; ok {
+				return nil, fmt.Errorf("PCR %d is quoted by more than one key", index)
+			}
+			merged.Pcrs[index] = value
+		}
+	}
+	return merged, nil
+}
+
+// checkSameBoot checks that an additional quote was made in the same boot of
+// the same TPM firmware as the AK's quote.
+func checkSameBoot(akAttest, attest *Attest) error {
+	if attest.ClockInfo.ResetCount != akAttest.ClockInfo.ResetCount || attest.ClockInfo.RestartCount != akAttest.ClockInfo.RestartCount {
+		return fmt.Errorf("reset and restart counts (%d, %d) differ from the AK quote's (%d, %d)",
+			attest.ClockInfo.ResetCount, attest.ClockInfo.RestartCount, akAttest.ClockInfo.ResetCount, akAttest.ClockInfo.RestartCount)
+	}
+	if attest.FirmwareVersion != akAttest.FirmwareVersion {
+		return fmt.Errorf("firmware version %#x differs from the AK quote's %#x", attest.FirmwareVersion, akAttest.FirmwareVersion)
+	}
+	return nil
+}
+
+func pubKeysEqual(k1 crypto.PublicKey, k2 crypto.PublicKey) bool {
+	switch key := k1.(type) {
+	case *rsa.PublicKey:
+		return key.Equal(k2)
+	case *ecdsa.PublicKey:
+		return key.Equal(k2)
+	default:
+		return false
+	}
+}
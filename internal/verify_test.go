@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+)
+
+func TestCheckSameBoot(t *testing.T) {
+	akAttest := &Attest{ClockInfo: tpm2.ClockInfo{Clock: 1000, ResetCount: 3, RestartCount: 1}, FirmwareVersion: 0x20191023}
+	subtests := []struct {
+		name    string
+		attest  *Attest
+		wantErr bool
+	}{
+		{"Same", &Attest{ClockInfo: tpm2.ClockInfo{Clock: 1010, ResetCount: 3, RestartCount: 1}, FirmwareVersion: 0x20191023}, false},
+		{"Reset", &Attest{ClockInfo: tpm2.ClockInfo{Clock: 10, ResetCount: 4}, FirmwareVersion: 0x20191023}, true},
+		{"Restarted", &Attest{ClockInfo: tpm2.ClockInfo{Clock: 1010, ResetCount: 3, RestartCount: 2}, FirmwareVersion: 0x20191023}, true},
+		{"OtherFirmware", &Attest{ClockInfo: tpm2.ClockInfo{Clock: 1010, ResetCount: 3, RestartCount: 1}, FirmwareVersion: 0x20221231}, true},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			err := checkSameBoot(akAttest, subtest.attest)
+			if gotErr := err != nil; gotErr != subtest.wantErr {
+				t.Errorf("checkSameBoot() = %v, want error: %v", err, subtest.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package luks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxKeyslots is the number of keyslots of a LUKS2 volume.
+const maxKeyslots = 32
+
+// ErrNoToken is returned by Cryptsetup.Unlock when no token of the volume
+// unseals a passphrase which unlocks it.
+var ErrNoToken = errors.New("no TPM token unlocks the volume")
+
+// Cryptsetup manages the keyslots and tokens of LUKS2 volumes by running the
+// cryptsetup tool (version 2.4 or later), which must run as root.
+type Cryptsetup struct {
+	// The path of the cryptsetup tool. If empty, cryptsetup is found in the
+	// PATH.
+	Path string
+}
+
+func (c Cryptsetup) command(ctx context.Context, stdin io.Reader, args ...string) *exec.Cmd {
+	path := c.Path
+	if path == "" {
+		path = "cryptsetup"
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = stdin
+	return cmd
+}
+
+func (c Cryptsetup) run(cmd *exec.Cmd) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("cryptsetup %s failed: %w: %s", cmd.Args[1], err, msg)
+		}
+		return nil, fmt.Errorf("cryptsetup %s failed: %w", cmd.Args[1], err)
+	}
+	return out, nil
+}
+
+// metadata is the part of a volume's LUKS2 JSON metadata used by this package.
+type metadata struct {
+	Keyslots map[string]json.RawMessage `json:"keyslots"`
+	Tokens   map[string]json.RawMessage `json:"tokens"`
+}
+
+func (c Cryptsetup) metadata(ctx context.Context, device string) (*metadata, error) {
+	out, err := c.run(c.command(ctx, nil, "luksDump", "--dump-json-metadata", device))
+	if err != nil {
+		return nil, err
+	}
+	var m metadata
+	if err := json.Unmarshal(out, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode LUKS2 metadata: %w", err)
+	}
+	return &m, nil
+}
+
+// AddKeyslot adds the enrolled passphrase to the first free keyslot of a
+// volume, authorized by an existing passphrase (such as a recovery key), and
+// imports the enrollment's token for that keyslot. It returns the keyslot.
+func (c Cryptsetup) AddKeyslot(ctx context.Context, device string, existingPassphrase []byte, enrollment *Enrollment) (int, error) {
+	m, err := c.metadata(ctx, device)
+	if err != nil {
+		return 0, err
+	}
+	keyslot := -1
+	for i := 0; i < maxKeyslots; i++ {
+		if _, ok := m.Keyslots[strconv.Itoa(i)]; !ok {
+			keyslot = i
+			break
+		}
+	}
+	if keyslot < 0 {
+		return 0, errors.New("volume has no free keyslot")
+	}
+
+	// The existing passphrase is read from stdin, and the new one from a pipe,
+	// so neither is written to disk.
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	cmd := c.command(ctx, bytes.NewReader(existingPassphrase), "luksAddKey",
+		"--key-file=-", "--key-slot="+strconv.Itoa(keyslot), device, "/dev/fd/3")
+	cmd.ExtraFiles = []*os.File{r}
+	go func() {
+		w.Write(enrollment.Passphrase)
+		w.Close()
+	}()
+	if _, err := c.run(cmd); err != nil {
+		return 0, err
+	}
+
+	token := enrollment.Token
+	token.Keyslots = []string{strconv.Itoa(keyslot)}
+	encoded, err := json.Marshal(token)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.run(c.command(ctx, bytes.NewReader(encoded), "token", "import", "--json-file=-", device)); err != nil {
+		return 0, fmt.Errorf("keyslot %d was added, but its token was not imported: %w", keyslot, err)
+	}
+	return keyslot, nil
+}
+
+// Tokens returns the "systemd-tpm2" tokens of a volume, in order of their IDs.
+func (c Cryptsetup) Tokens(ctx context.Context, device string) ([]*Token, error) {
+	m, err := c.metadata(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(m.Tokens))
+	for id := range m.Tokens {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token ID %q", id)
+		}
+		ids = append(ids, n)
+	}
+	sort.Ints(ids)
+	var tokens []*Token
+	for _, id := range ids {
+		token, err := parseToken(m.Tokens[strconv.Itoa(id)])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode token %d: %w", id, err)
+		}
+		if token != nil {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// Unlock opens a volume as /dev/mapper/<name>, with the passphrase of the
+// first of its tokens which the TPM unseals, for use in early boot. It
+// returns an error wrapping ErrNoToken if no token unlocks the volume, such as
+// when the PCRs have changed.
+func (c Cryptsetup) Unlock(ctx context.Context, rw io.ReadWriter, device, name string) error {
+	tokens, err := c.Tokens(ctx, device)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, token := range tokens {
+		passphrase, err := Unseal(rw, token)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if _, err := c.run(c.command(ctx, bytes.NewReader(passphrase), "open", "--key-file=-", device, name)); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("%w: volume has no %s tokens", ErrNoToken, TokenType)
+	}
+	return fmt.Errorf("%w: %s", ErrNoToken, strings.Join(errs, "; "))
+}
@@ -0,0 +1,192 @@
+// Package luks binds LUKS2 volumes (dm-crypt full disk encryption) to the TPM,
+// so they are unlocked automatically while the machine's PCRs have their
+// expected values.
+//
+// Enroll seals a new random passphrase to the selected PCRs, and describes the
+// sealed passphrase with a "systemd-tpm2" LUKS2 token, in the format written
+// by systemd-cryptenroll. A volume enrolled by this package can therefore be
+// unlocked in early boot by systemd-cryptsetup (with tpm2-device=auto in
+// /etc/crypttab), and one enrolled by systemd-cryptenroll can be unlocked by
+// Unlock. Cryptsetup adds the passphrase and the token to a volume, and finds
+// and uses the tokens of a volume, by running the cryptsetup tool:
+//
+//	enrollment, err := luks.Enroll(rw, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{7}})
+//	keyslot, err := luks.Cryptsetup{}.AddKeyslot(ctx, "/dev/sda2", recoveryPassphrase, enrollment)
+//	...
+//	err = luks.Cryptsetup{}.Unlock(ctx, rw, "/dev/sda2", "root")
+package luks
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// TokenType is the type of the LUKS2 tokens of TPM-sealed passphrases.
+const TokenType = "systemd-tpm2"
+
+// SecretSize is the size (in bytes) of the random secrets sealed by Enroll.
+const SecretSize = 32
+
+// Token is a "systemd-tpm2" LUKS2 token, describing a passphrase sealed to the
+// TPM's PCRs. Its JSON encoding is the token's LUKS2 metadata.
+type Token struct {
+	Type string `json:"type"`
+	// The keyslots the passphrase unlocks, as decimal strings.
+	Keyslots []string `json:"keyslots"`
+	// The sealed object: its TPM2B_PRIVATE followed by its TPM2B_PUBLIC.
+	Blob []byte `json:"tpm2-blob"`
+	// The PCRs the passphrase is sealed to, and their bank.
+	PCRs    []int  `json:"tpm2-pcrs"`
+	PCRBank string `json:"tpm2-pcr-bank"`
+	// The algorithm of the primary key the object is sealed under.
+	PrimaryAlg string `json:"tpm2-primary-alg"`
+	// The hex encoded policy digest of the sealed object.
+	PolicyHash string `json:"tpm2-policy-hash"`
+	// Whether the object also needs a PIN, which this package does not support.
+	PIN bool `json:"tpm2-pin"`
+}
+
+// Enrollment is a new passphrase sealed to the TPM by Enroll, to be added to
+// a keyslot of a volume (see Cryptsetup.AddKeyslot).
+type Enrollment struct {
+	// The passphrase, which unlocks the keyslot.
+	Passphrase []byte
+	// The volume's token for the keyslot, whose Keyslots are not yet set.
+	Token Token
+}
+
+var pcrBanks = map[tpm2.Algorithm]string{
+	tpm2.AlgSHA1:   "sha1",
+	tpm2.AlgSHA256: "sha256",
+	tpm2.AlgSHA384: "sha384",
+	tpm2.AlgSHA512: "sha512",
+}
+
+// PrimaryTemplate returns the template of the primary key sealed passphrases
+// are sealed under, as created by systemd-cryptenroll: TSS2SRKTemplateECC
+// without the noDA attribute.
+func PrimaryTemplate() tpm2.Public {
+	template := client.TSS2SRKTemplateECC()
+	template.Attributes &^= tpm2.FlagNoDA
+	return template
+}
+
+// Enroll generates a random secret and seals it to the current values of the
+// selected PCRs, returning the passphrase derived from it and its token.
+func Enroll(rw io.ReadWriter, sel tpm2.PCRSelection) (*Enrollment, error) {
+	bank, ok := pcrBanks[sel.Hash]
+	if !ok {
+		return nil, fmt.Errorf("unsupported PCR bank %v", sel.Hash)
+	}
+	if len(sel.PCRs) == 0 {
+		return nil, errors.New("no PCRs selected")
+	}
+	secret := make([]byte, SecretSize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	primary, err := client.NewKey(rw, tpm2.HandleOwner, PrimaryTemplate())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create primary key: %w", err)
+	}
+	defer primary.Close()
+	sealed, err := primary.Seal(secret, client.SealOpts{Current: sel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal secret: %w", err)
+	}
+	pub, err := tpm2.DecodePublic(sealed.GetPub())
+	if err != nil {
+		return nil, err
+	}
+	blob, err := tpmutil.Pack(tpmutil.U16Bytes(sealed.GetPriv()), tpmutil.U16Bytes(sealed.GetPub()))
+	if err != nil {
+		return nil, err
+	}
+	return &Enrollment{
+		Passphrase: passphrase(secret),
+		Token: Token{
+			Type:       TokenType,
+			Keyslots:   []string{},
+			Blob:       blob,
+			PCRs:       append([]int(nil), sel.PCRs...),
+			PCRBank:    bank,
+			PrimaryAlg: "ecc",
+			PolicyHash: hex.EncodeToString(pub.AuthPolicy),
+		},
+	}, nil
+}
+
+// Unseal unseals the passphrase described by a token, which only succeeds
+// while the token's PCRs have the values they were sealed to.
+func Unseal(rw io.ReadWriter, token *Token) ([]byte, error) {
+	if token.Type != TokenType {
+		return nil, fmt.Errorf("token has type %q, want %q", token.Type, TokenType)
+	}
+	if token.PIN {
+		return nil, errors.New("tokens with a PIN are not supported")
+	}
+	if token.PrimaryAlg != "ecc" {
+		return nil, fmt.Errorf("unsupported primary key algorithm %q", token.PrimaryAlg)
+	}
+	sealed := &pb.SealedBytes{Srk: pb.ObjectType_ECC}
+	for alg, name := range pcrBanks {
+		if name == token.PCRBank {
+			sealed.Hash = pb.HashAlgo(alg)
+		}
+	}
+	if sealed.Hash == pb.HashAlgo_HASH_INVALID {
+		return nil, fmt.Errorf("unsupported PCR bank %q", token.PCRBank)
+	}
+	for _, pcr := range token.PCRs {
+		sealed.Pcrs = append(sealed.Pcrs, uint32(pcr))
+	}
+	var priv, pub tpmutil.U16Bytes
+	if _, err := tpmutil.Unpack(token.Blob, &priv, &pub); err != nil {
+		return nil, fmt.Errorf("failed to decode token blob: %w", err)
+	}
+	sealed.Priv, sealed.Pub = priv, pub
+
+	primary, err := client.NewKey(rw, tpm2.HandleOwner, PrimaryTemplate())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create primary key: %w", err)
+	}
+	defer primary.Close()
+	secret, err := primary.Unseal(sealed, client.UnsealOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal secret: %w", err)
+	}
+	return passphrase(secret), nil
+}
+
+// passphrase returns the passphrase derived from a secret: like
+// systemd-cryptenroll, its base64 encoding.
+func passphrase(secret []byte) []byte {
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(secret)))
+	base64.StdEncoding.Encode(encoded, secret)
+	return encoded
+}
+
+// parseToken decodes a token from its LUKS2 metadata, returning nil if it is
+// not a "systemd-tpm2" token.
+func parseToken(data json.RawMessage) (*Token, error) {
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	if token.Type != TokenType {
+		return nil, nil
+	}
+	return &token, nil
+}
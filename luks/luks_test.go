@@ -0,0 +1,209 @@
+package luks_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm-tools/luks"
+)
+
+func extendPCR(t *testing.T, rw io.ReadWriter) {
+	t.Helper()
+	if err := tpm2.PCRExtend(rw, tpmutil.Handle(test.DebugPCR), tpm2.AlgSHA256, bytes.Repeat([]byte{0xAA}, 32), ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnrollUnseal(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{7, test.DebugPCR}}
+	enrollment, err := luks.Enroll(rwc, sel)
+	if err != nil {
+		t.Fatalf("Enroll() failed: %v", err)
+	}
+	if len(enrollment.Passphrase) != 44 {
+		t.Errorf("got passphrase of %d bytes, want the base64 encoding of %d bytes", len(enrollment.Passphrase), luks.SecretSize)
+	}
+	if enrollment.Token.PCRBank != "sha256" || enrollment.Token.PrimaryAlg != "ecc" {
+		t.Errorf("got token %+v, want a sha256 bank and an ecc primary", enrollment.Token)
+	}
+
+	// The token survives a round trip through the LUKS2 metadata.
+	encoded, err := json.Marshal(enrollment.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var token luks.Token
+	if err := json.Unmarshal(encoded, &token); err != nil {
+		t.Fatal(err)
+	}
+	passphrase, err := luks.Unseal(rwc, &token)
+	if err != nil {
+		t.Fatalf("Unseal() failed: %v", err)
+	}
+	if !bytes.Equal(passphrase, enrollment.Passphrase) {
+		t.Errorf("Unseal() = %q, want %q", passphrase, enrollment.Passphrase)
+	}
+
+	extendPCR(t, rwc)
+	if _, err := luks.Unseal(rwc, &token); err == nil {
+		t.Error("Unseal() should fail after the PCRs change")
+	}
+}
+
+// fakeCryptsetup writes a script which acts like cryptsetup on a volume with
+// the given LUKS2 metadata, recording the passphrases and tokens it is given
+// in dir.
+func fakeCryptsetup(t *testing.T, metadata string) (luks.Cryptsetup, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake cryptsetup is a shell script")
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "metadata.json"), []byte(metadata), 0600); err != nil {
+		t.Fatal(err)
+	}
+	script := `#!/bin/sh
+dir=` + dir + `
+case "$1" in
+luksDump) cat "$dir/metadata.json" ;;
+luksAddKey) echo "$3" > "$dir/keyslot"; cat > "$dir/existing"; cat "$5" > "$dir/new" ;;
+token) cat > "$dir/token" ;;
+open) cat > "$dir/opened"; [ "$(cat "$dir/opened")" = "$(cat "$dir/new")" ] || { echo "No key available" >&2; exit 2; } ;;
+*) exit 1 ;;
+esac
+`
+	path := filepath.Join(dir, "cryptsetup")
+	if err := ioutil.WriteFile(path, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return luks.Cryptsetup{Path: path}, dir
+}
+
+func readFile(t *testing.T, dir, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestAddKeyslot(t *testing.T) {
+	cryptsetup, dir := fakeCryptsetup(t, `{"keyslots": {"0": {}, "2": {}}, "tokens": {}}`)
+	enrollment := &luks.Enrollment{
+		Passphrase: []byte("new passphrase"),
+		Token:      luks.Token{Type: luks.TokenType, Keyslots: []string{}, Blob: []byte{1, 2, 3}, PCRs: []int{7}, PCRBank: "sha256", PrimaryAlg: "ecc"},
+	}
+	keyslot, err := cryptsetup.AddKeyslot(context.Background(), "/dev/fake", []byte("recovery"), enrollment)
+	if err != nil {
+		t.Fatalf("AddKeyslot() failed: %v", err)
+	}
+	if keyslot != 1 {
+		t.Errorf("AddKeyslot() = %d, want the first free keyslot 1", keyslot)
+	}
+	if got := string(readFile(t, dir, "keyslot")); got != "--key-slot=1\n" {
+		t.Errorf("cryptsetup luksAddKey got %q, want --key-slot=1", got)
+	}
+	if got := readFile(t, dir, "existing"); string(got) != "recovery" {
+		t.Errorf("cryptsetup luksAddKey got existing passphrase %q, want %q", got, "recovery")
+	}
+	if got := readFile(t, dir, "new"); string(got) != "new passphrase" {
+		t.Errorf("cryptsetup luksAddKey got new passphrase %q, want %q", got, "new passphrase")
+	}
+
+	var token luks.Token
+	if err := json.Unmarshal(readFile(t, dir, "token"), &token); err != nil {
+		t.Fatalf("cryptsetup token import got invalid JSON: %v", err)
+	}
+	if len(token.Keyslots) != 1 || token.Keyslots[0] != "1" {
+		t.Errorf("imported token has keyslots %q, want [1]", token.Keyslots)
+	}
+	if len(enrollment.Token.Keyslots) != 0 {
+		t.Error("AddKeyslot() should not modify the enrollment")
+	}
+}
+
+func TestAddKeyslotFull(t *testing.T) {
+	keyslots := make([]string, 32)
+	for i := range keyslots {
+		keyslots[i] = fmt.Sprintf(`"%d": {}`, i)
+	}
+	metadata := `{"keyslots": {` + strings.Join(keyslots, ",") + `}}`
+	cryptsetup, _ := fakeCryptsetup(t, metadata)
+	enrollment := &luks.Enrollment{Passphrase: []byte("new passphrase")}
+	if _, err := cryptsetup.AddKeyslot(context.Background(), "/dev/fake", []byte("recovery"), enrollment); err == nil {
+		t.Error("AddKeyslot() on a volume without free keyslots should fail")
+	}
+}
+
+func TestTokens(t *testing.T) {
+	cryptsetup, _ := fakeCryptsetup(t, `{"keyslots": {}, "tokens": {
+		"10": {"type": "systemd-tpm2", "keyslots": ["3"], "tpm2-blob": "AQID", "tpm2-pcrs": [7], "tpm2-pcr-bank": "sha256", "tpm2-primary-alg": "ecc"},
+		"1": {"type": "systemd-fido2", "keyslots": ["2"]},
+		"2": {"type": "systemd-tpm2", "keyslots": ["1"], "tpm2-blob": "BAUG", "tpm2-pcrs": [0, 7], "tpm2-pcr-bank": "sha1", "tpm2-primary-alg": "ecc"}
+	}}`)
+	tokens, err := cryptsetup.Tokens(context.Background(), "/dev/fake")
+	if err != nil {
+		t.Fatalf("Tokens() failed: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("Tokens() returned %d tokens, want the 2 systemd-tpm2 tokens", len(tokens))
+	}
+	if tokens[0].Keyslots[0] != "1" || !bytes.Equal(tokens[0].Blob, []byte{4, 5, 6}) {
+		t.Errorf("got first token %+v, want token 2", tokens[0])
+	}
+	if tokens[1].Keyslots[0] != "3" || tokens[1].PCRBank != "sha256" {
+		t.Errorf("got second token %+v, want token 10", tokens[1])
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	cryptsetup, dir := fakeCryptsetup(t, `{"keyslots": {"0": {}}, "tokens": {}}`)
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{test.DebugPCR}}
+	enrollment, err := luks.Enroll(rwc, sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cryptsetup.AddKeyslot(context.Background(), "/dev/fake", []byte("recovery"), enrollment); err != nil {
+		t.Fatal(err)
+	}
+	token := readFile(t, dir, "token")
+	metadata := `{"keyslots": {"0": {}, "1": {}}, "tokens": {"0": ` + string(token) + `}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "metadata.json"), []byte(metadata), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cryptsetup.Unlock(context.Background(), rwc, "/dev/fake", "root"); err != nil {
+		t.Fatalf("Unlock() failed: %v", err)
+	}
+	if got := readFile(t, dir, "opened"); !bytes.Equal(got, enrollment.Passphrase) {
+		t.Errorf("cryptsetup open got passphrase %q, want %q", got, enrollment.Passphrase)
+	}
+
+	extendPCR(t, rwc)
+	os.Remove(filepath.Join(dir, "opened"))
+	if err := cryptsetup.Unlock(context.Background(), rwc, "/dev/fake", "root"); !errors.Is(err, luks.ErrNoToken) {
+		t.Errorf("Unlock() after the PCRs change = %v, want %v", err, luks.ErrNoToken)
+	}
+}
@@ -0,0 +1,335 @@
+// Package mqtt attests machines over MQTT, for IoT fleets whose only
+// northbound channel is an MQTT broker.
+//
+// A Verifier challenges a device by publishing to topics under a prefix
+// shared by the fleet, with the device's ID as the next topic level:
+//  1. <prefix>/<device>/challenge: a nonce from a server.VerifierService,
+//     published by the Verifier.
+//  2. <prefix>/<device>/evidence: the device's Attestation, bound to the nonce
+//     and published by its Agent.
+//  3. <prefix>/<device>/verdict: the result of verifying the Attestation,
+//     published by the Verifier: the VerifierService's claims token and
+//     redacted MachineState, or why the Attestation was rejected.
+//
+// The payloads are CBOR maps with integer keys (see challengeMessage,
+// evidenceMessage and verdictMessage), so they are compact, and can be
+// produced by constrained devices which do not use this package. Every
+// message is published with QoS 1 (at least once), and carries the random ID
+// of its challenge, so that duplicates can be ignored. The broker must only
+// allow each device to publish to its own evidence topic, and only the
+// Verifier to publish challenges and verdicts.
+package mqtt
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+	"github.com/google/go-tpm-tools/server"
+)
+
+// The last topic level of the attestation messages.
+const (
+	ChallengeTopic = "challenge"
+	EvidenceTopic  = "evidence"
+	VerdictTopic   = "verdict"
+)
+
+const challengeIDSize = 16
+
+// ErrRejected is matched (with errors.Is) by the errors reported to an Agent
+// when the Verifier rejected its Attestation.
+var ErrRejected = errors.New("verifier rejected attestation")
+
+// challengeMessage is the payload of a challenge: the CBOR map
+// {1: id, 2: nonce}.
+type challengeMessage struct {
+	ID    []byte `cbor:"1,keyasint"`
+	Nonce []byte `cbor:"2,keyasint"`
+}
+
+// evidenceMessage is the payload of an evidence message: the CBOR map
+// {1: id, 2: Attestation protobuf}, or {1: id, 3: error} if the device could
+// not attest.
+type evidenceMessage struct {
+	ID          []byte `cbor:"1,keyasint"`
+	Attestation []byte `cbor:"2,keyasint,omitempty"`
+	Error       string `cbor:"3,keyasint,omitempty"`
+}
+
+// verdictMessage is the payload of a verdict: the CBOR map {1: id, 2: claims
+// token, 3: MachineState protobuf} if the Attestation was verified, or
+// {1: id, 4: error} if it was rejected.
+type verdictMessage struct {
+	ID           []byte `cbor:"1,keyasint"`
+	ClaimsToken  []byte `cbor:"2,keyasint,omitempty"`
+	MachineState []byte `cbor:"3,keyasint,omitempty"`
+	Error        string `cbor:"4,keyasint,omitempty"`
+}
+
+// Topic returns the topic of a device's attestation messages of one kind
+// (ChallengeTopic, EvidenceTopic or VerdictTopic).
+func Topic(prefix, device, kind string) string {
+	return prefix + "/" + device + "/" + kind
+}
+
+// deviceFromTopic returns the device ID of an attestation topic.
+func deviceFromTopic(prefix, topic, kind string) (string, bool) {
+	if !strings.HasPrefix(topic, prefix+"/") || !strings.HasSuffix(topic, "/"+kind) {
+		return "", false
+	}
+	device := topic[len(prefix)+1 : len(topic)-len(kind)-1]
+	return device, device != "" && !strings.Contains(device, "/")
+}
+
+func checkDevice(device string) error {
+	if device == "" || strings.ContainsAny(device, "/+#\x00") {
+		return fmt.Errorf("invalid device ID %q", device)
+	}
+	return nil
+}
+
+// Agent answers a Verifier's challenges for one device, attesting with the
+// device's TPM.
+type Agent struct {
+	Client   *Client
+	Attester client.Attester
+	// The topic prefix of the fleet, and the ID of this device.
+	Prefix string
+	Device string
+	// If not nil, OnVerdict is called with each verdict for the device: the
+	// verified (and possibly redacted) MachineState and its claims token, or
+	// an error matching ErrRejected.
+	OnVerdict func(*verifierpb.VerifyAttestationResponse, error)
+
+	mu           sync.Mutex
+	lastID       []byte
+	lastEvidence []byte
+}
+
+// Start subscribes to the device's challenges and verdicts. Challenges are
+// answered until the Client is closed.
+func (a *Agent) Start(ctx context.Context) error {
+	if err := checkDevice(a.Device); err != nil {
+		return err
+	}
+	if err := a.Client.Subscribe(ctx, Topic(a.Prefix, a.Device, VerdictTopic), AtLeastOnce, a.handleVerdict); err != nil {
+		return err
+	}
+	return a.Client.Subscribe(ctx, Topic(a.Prefix, a.Device, ChallengeTopic), AtLeastOnce, a.handleChallenge)
+}
+
+func (a *Agent) handleChallenge(topic string, payload []byte) {
+	var challenge challengeMessage
+	if err := cbor.Unmarshal(payload, &challenge); err != nil || len(challenge.ID) != challengeIDSize {
+		return
+	}
+	evidence, err := a.evidence(&challenge)
+	if err != nil {
+		return
+	}
+	// The Verifier waits for the evidence until its challenge times out, so
+	// it is only resent until the Client is closed.
+	a.Client.Publish(context.Background(), Topic(a.Prefix, a.Device, EvidenceTopic), evidence, AtLeastOnce)
+}
+
+// evidence returns the payload answering a challenge. A challenge which is
+// delivered again is answered with the same evidence, rather than attesting
+// again.
+func (a *Agent) evidence(challenge *challengeMessage) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.lastID != nil && string(a.lastID) == string(challenge.ID) {
+		return a.lastEvidence, nil
+	}
+	msg := evidenceMessage{ID: challenge.ID}
+	attestation, err := a.Attester.Attest(client.AttestOpts{Nonce: challenge.Nonce})
+	if err == nil {
+		msg.Attestation, err = proto.Marshal(attestation)
+	}
+	if err != nil {
+		msg.Attestation = nil
+		msg.Error = fmt.Sprintf("failed to attest: %v", err)
+	}
+	evidence, err := cbor.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	a.lastID, a.lastEvidence = challenge.ID, evidence
+	return evidence, nil
+}
+
+func (a *Agent) handleVerdict(topic string, payload []byte) {
+	var verdict verdictMessage
+	if err := cbor.Unmarshal(payload, &verdict); err != nil {
+		return
+	}
+	a.mu.Lock()
+	current := a.lastID != nil && string(a.lastID) == string(verdict.ID)
+	a.mu.Unlock()
+	if !current || a.OnVerdict == nil {
+		return
+	}
+	if verdict.Error != "" {
+		a.OnVerdict(nil, fmt.Errorf("%w: %s", ErrRejected, verdict.Error))
+		return
+	}
+	state := &pb.MachineState{}
+	if err := proto.Unmarshal(verdict.MachineState, state); err != nil {
+		a.OnVerdict(nil, fmt.Errorf("invalid machine state in verdict: %w", err))
+		return
+	}
+	a.OnVerdict(&verifierpb.VerifyAttestationResponse{ClaimsToken: verdict.ClaimsToken, MachineState: state}, nil)
+}
+
+// Verifier challenges devices over MQTT, and verifies their evidence with a
+// VerifierService. Nonces are limited per device by the VerifierService's
+// MaxNoncesPerClient.
+type Verifier struct {
+	client  *Client
+	service *server.VerifierService
+	prefix  string
+
+	mu      sync.Mutex
+	pending map[string]*pendingChallenge
+}
+
+// pendingChallenge is a challenge waiting for its evidence.
+type pendingChallenge struct {
+	ctx    context.Context
+	device string
+	nonce  []byte
+	result chan challengeResult
+}
+
+type challengeResult struct {
+	resp *verifierpb.VerifyAttestationResponse
+	err  error
+}
+
+// NewVerifier subscribes to the evidence of every device under the topic
+// prefix, which is verified by service.
+func NewVerifier(ctx context.Context, c *Client, service *server.VerifierService, prefix string) (*Verifier, error) {
+	v := &Verifier{
+		client:  c,
+		service: service,
+		prefix:  prefix,
+		pending: make(map[string]*pendingChallenge),
+	}
+	if err := c.Subscribe(ctx, Topic(prefix, "+", EvidenceTopic), AtLeastOnce, v.handleEvidence); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Challenge asks a device to attest, and waits until its Attestation has been
+// verified, or ctx is done. The verdict is also published to the device.
+// Verification errors are those of the VerifierService.
+func (v *Verifier) Challenge(ctx context.Context, device string) (*verifierpb.VerifyAttestationResponse, error) {
+	if err := checkDevice(device); err != nil {
+		return nil, err
+	}
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: deviceAddr(device)})
+	nonceResp, err := v.service.GetNonce(ctx, &verifierpb.GetNonceRequest{})
+	if err != nil {
+		return nil, err
+	}
+	challenge := challengeMessage{ID: make([]byte, challengeIDSize), Nonce: nonceResp.GetNonce()}
+	if _, err := rand.Read(challenge.ID); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge ID: %w", err)
+	}
+	payload, err := cbor.Marshal(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &pendingChallenge{ctx: ctx, device: device, nonce: challenge.Nonce, result: make(chan challengeResult, 1)}
+	v.mu.Lock()
+	v.pending[string(challenge.ID)] = pending
+	v.mu.Unlock()
+	defer func() {
+		v.mu.Lock()
+		delete(v.pending, string(challenge.ID))
+		v.mu.Unlock()
+	}()
+
+	if err := v.client.Publish(ctx, Topic(v.prefix, device, ChallengeTopic), payload, AtLeastOnce); err != nil {
+		return nil, fmt.Errorf("failed to publish challenge: %w", err)
+	}
+	select {
+	case result := <-pending.result:
+		return result.resp, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-v.client.Done():
+		return nil, v.client.Err()
+	}
+}
+
+func (v *Verifier) handleEvidence(topic string, payload []byte) {
+	device, ok := deviceFromTopic(v.prefix, topic, EvidenceTopic)
+	if !ok {
+		return
+	}
+	var evidence evidenceMessage
+	if err := cbor.Unmarshal(payload, &evidence); err != nil {
+		return
+	}
+	// Only the first evidence for a challenge is verified, so duplicates are
+	// ignored.
+	v.mu.Lock()
+	pending, ok := v.pending[string(evidence.ID)]
+	if ok && pending.device == device {
+		delete(v.pending, string(evidence.ID))
+	}
+	v.mu.Unlock()
+	if !ok || pending.device != device {
+		return
+	}
+
+	resp, err := v.verify(pending, &evidence)
+	verdict := verdictMessage{ID: evidence.ID}
+	if err == nil {
+		verdict.ClaimsToken = resp.GetClaimsToken()
+		verdict.MachineState, err = proto.Marshal(resp.GetMachineState())
+	}
+	if err != nil {
+		verdict = verdictMessage{ID: evidence.ID, Error: status.Convert(err).Message()}
+	}
+	if data, marshalErr := cbor.Marshal(verdict); marshalErr == nil {
+		v.client.Publish(pending.ctx, Topic(v.prefix, device, VerdictTopic), data, AtLeastOnce)
+	}
+	pending.result <- challengeResult{resp, err}
+}
+
+func (v *Verifier) verify(pending *pendingChallenge, evidence *evidenceMessage) (*verifierpb.VerifyAttestationResponse, error) {
+	if evidence.Error != "" {
+		return nil, fmt.Errorf("device %q did not attest: %s", pending.device, evidence.Error)
+	}
+	attestation := &pb.Attestation{}
+	if err := proto.Unmarshal(evidence.Attestation, attestation); err != nil {
+		return nil, fmt.Errorf("invalid attestation from device %q: %w", pending.device, err)
+	}
+	return v.service.VerifyAttestation(pending.ctx, &verifierpb.VerifyAttestationRequest{
+		Nonce:       pending.nonce,
+		Attestation: attestation,
+	})
+}
+
+// deviceAddr identifies a device to the VerifierService, which limits the
+// nonces used by each client address.
+type deviceAddr string
+
+func (d deviceAddr) Network() string { return "mqtt" }
+func (d deviceAddr) String() string  { return string(d) }
@@ -0,0 +1,179 @@
+package mqtt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+	"github.com/google/go-tpm-tools/server"
+)
+
+type verdict struct {
+	resp *verifierpb.VerifyAttestationResponse
+	err  error
+}
+
+func startAgent(t *testing.T, broker *testBroker, attester client.Attester, device string) chan verdict {
+	t.Helper()
+	verdicts := make(chan verdict, 10)
+	agent := &Agent{
+		Client:   broker.mustConnect(Options{ClientID: device}),
+		Attester: attester,
+		Prefix:   "fleet",
+		Device:   device,
+		OnVerdict: func(resp *verifierpb.VerifyAttestationResponse, err error) {
+			verdicts <- verdict{resp, err}
+		},
+	}
+	if err := agent.Start(context.Background()); err != nil {
+		t.Fatalf("Agent.Start() failed: %v", err)
+	}
+	return verdicts
+}
+
+func TestAttestOverMQTT(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	trusted, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trusted.Close()
+	untrusted, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer untrusted.Close()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := server.NewVerifierService(server.VerifierServiceOpts{
+		Signer:     signer,
+		VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{trusted.PublicKey()}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker := newTestBroker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	verifier, err := NewVerifier(ctx, broker.mustConnect(Options{ClientID: "verifier"}), service, "fleet")
+	if err != nil {
+		t.Fatalf("NewVerifier() failed: %v", err)
+	}
+	trustedVerdicts := startAgent(t, broker, trusted, "trusted-device")
+	untrustedVerdicts := startAgent(t, broker, untrusted, "untrusted-device")
+
+	resp, err := verifier.Challenge(ctx, "trusted-device")
+	if err != nil {
+		t.Fatalf("Challenge() of a trusted device failed: %v", err)
+	}
+	if len(resp.GetClaimsToken()) == 0 || resp.GetMachineState() == nil {
+		t.Errorf("Challenge() returned an incomplete response: %v", resp)
+	}
+	select {
+	case v := <-trustedVerdicts:
+		if v.err != nil {
+			t.Errorf("trusted device received a rejection: %v", v.err)
+		} else if string(v.resp.GetClaimsToken()) != string(resp.GetClaimsToken()) {
+			t.Error("trusted device received a different claims token")
+		}
+	case <-ctx.Done():
+		t.Fatal("trusted device did not receive its verdict")
+	}
+
+	if _, err := verifier.Challenge(ctx, "untrusted-device"); err == nil {
+		t.Error("Challenge() of an untrusted device should fail")
+	}
+	select {
+	case v := <-untrustedVerdicts:
+		if !errors.Is(v.err, ErrRejected) {
+			t.Errorf("untrusted device received verdict %v, want %v", v.err, ErrRejected)
+		}
+	case <-ctx.Done():
+		t.Fatal("untrusted device did not receive its verdict")
+	}
+
+	// A device which does not answer times out.
+	timeoutCtx, cancelTimeout := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelTimeout()
+	if _, err := verifier.Challenge(timeoutCtx, "absent-device"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Challenge() of an absent device returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if _, err := verifier.Challenge(ctx, "fleet/+"); err == nil {
+		t.Error("Challenge() of an invalid device ID should fail")
+	}
+}
+
+func TestDuplicateEvidence(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := server.NewVerifierService(server.VerifierServiceOpts{
+		Signer:     signer,
+		VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker := newTestBroker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	verifier, err := NewVerifier(ctx, broker.mustConnect(Options{ClientID: "verifier"}), service, "fleet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A device whose evidence is delivered twice, as can happen with QoS 1.
+	device := broker.mustConnect(Options{ClientID: "device"})
+	agent := &Agent{Client: device, Attester: ak, Prefix: "fleet", Device: "device"}
+	err = device.Subscribe(ctx, Topic("fleet", "device", ChallengeTopic), AtLeastOnce, func(topic string, payload []byte) {
+		var challenge challengeMessage
+		if err := cbor.Unmarshal(payload, &challenge); err != nil {
+			t.Error(err)
+			return
+		}
+		evidence, err := agent.evidence(&challenge)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for i := 0; i < 2; i++ {
+			device.Publish(ctx, Topic("fleet", "device", EvidenceTopic), evidence, AtLeastOnce)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verdicts := collect(t, device, Topic("fleet", "device", VerdictTopic), AtLeastOnce)
+
+	if _, err := verifier.Challenge(ctx, "device"); err != nil {
+		t.Fatalf("Challenge() failed: %v", err)
+	}
+	receive(t, verdicts)
+	select {
+	case m := <-verdicts:
+		t.Errorf("duplicate evidence produced a second verdict: %v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
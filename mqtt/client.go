@@ -0,0 +1,476 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QoS is the quality of service of an MQTT message.
+type QoS byte
+
+// The supported qualities of service. Exactly-once delivery (QoS 2) is not
+// supported: the attestation messages are idempotent, so they are sent at
+// least once, and duplicates are ignored by their ID.
+const (
+	AtMostOnce  QoS = 0
+	AtLeastOnce QoS = 1
+)
+
+const (
+	defaultRetryInterval = 10 * time.Second
+	defaultMaxPacketSize = 16 << 20
+)
+
+// ErrClosed is returned by the methods of a Client after it is closed, or
+// after its connection is lost.
+var ErrClosed = errors.New("MQTT connection closed")
+
+// Options configures an MQTT connection.
+type Options struct {
+	// The client identifier, which must be unique per broker. Brokers may
+	// assign an identifier when it is empty, if CleanSession is set.
+	ClientID string
+	// Credentials sent to the broker, if not empty.
+	Username string
+	Password string
+	// Whether the broker should discard any session state (subscriptions and
+	// undelivered QoS 1 messages) from the client's previous connections.
+	CleanSession bool
+	// How often the client pings the broker when idle, and how long it waits
+	// for a response before closing the connection. If zero, the client does
+	// not ping the broker.
+	KeepAlive time.Duration
+	// How long the client waits for a QoS 1 message to be acknowledged before
+	// sending it again. If zero, messages are resent every ten seconds.
+	RetryInterval time.Duration
+	// The largest packet the client accepts from the broker, in bytes. If
+	// zero, the limit is 16 MiB.
+	MaxPacketSize int
+}
+
+// Handler is called with each message received on a subscription. Handlers
+// are called concurrently, so messages may be handled out of order. A QoS 1
+// message is acknowledged after its handlers return.
+type Handler func(topic string, payload []byte)
+
+type subscription struct {
+	filter  string
+	handler Handler
+}
+
+// Client is a minimal MQTT 3.1.1 client, which publishes and subscribes with
+// QoS 0 and 1. It does not reconnect: once its connection is lost (see Done),
+// a new Client must be connected, and its subscriptions made again (unless
+// the broker kept the session).
+type Client struct {
+	conn net.Conn
+	opts Options
+
+	writeMu sync.Mutex
+
+	mu              sync.Mutex
+	nextID          uint16
+	pending         map[uint16]chan *packet
+	subs            []*subscription
+	pingOutstanding bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// Connect starts an MQTT session with the broker over conn, which is usually
+// a TLS connection. The client takes ownership of conn, closing it when the
+// client is closed.
+func Connect(ctx context.Context, conn net.Conn, opts Options) (*Client, error) {
+	if opts.KeepAlive < 0 || opts.KeepAlive/time.Second > 0xFFFF || opts.RetryInterval < 0 || opts.MaxPacketSize < 0 {
+		return nil, errors.New("invalid MQTT options")
+	}
+	if opts.ClientID == "" && !opts.CleanSession {
+		return nil, errors.New("a client ID is required without a clean session")
+	}
+	if opts.RetryInterval == 0 {
+		opts.RetryInterval = defaultRetryInterval
+	}
+	if opts.MaxPacketSize == 0 {
+		opts.MaxPacketSize = defaultMaxPacketSize
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Interrupt the handshake.
+			conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	r := bufio.NewReader(conn)
+	if err := connectHandshake(conn, r, opts); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &Client{
+		conn:    conn,
+		opts:    opts,
+		pending: make(map[uint16]chan *packet),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(r)
+	if opts.KeepAlive > 0 {
+		go c.keepAlive()
+	}
+	return c, nil
+}
+
+// connackErrors are the reasons for the CONNACK return codes, from Section
+// 3.2.2.3 of the specification.
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+func connectHandshake(conn net.Conn, r *bufio.Reader, opts Options) error {
+	var flags byte
+	if opts.Username != "" {
+		flags |= 0x80
+	}
+	if opts.Password != "" {
+		flags |= 0x40
+	}
+	if opts.CleanSession {
+		flags |= 0x02
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = appendUint16(body, uint16(opts.KeepAlive/time.Second))
+	body = appendString(body, opts.ClientID)
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+	}
+	if opts.Password != "" {
+		body = appendString(body, opts.Password)
+	}
+	if err := writePacket(conn, &packet{typ: packetConnect, body: body}); err != nil {
+		return err
+	}
+
+	p, err := readPacket(r, opts.MaxPacketSize)
+	if err != nil {
+		return fmt.Errorf("reading CONNACK: %w", err)
+	}
+	if p.typ != packetConnack || len(p.body) != 2 {
+		return errors.New("broker did not acknowledge the connection")
+	}
+	if code := p.body[1]; code != 0 {
+		if reason, ok := connackErrors[code]; ok {
+			return fmt.Errorf("broker refused the connection: %s", reason)
+		}
+		return fmt.Errorf("broker refused the connection with code %d", code)
+	}
+	return nil
+}
+
+func writePacket(conn net.Conn, p *packet) error {
+	data, err := p.encode()
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
+
+func (c *Client) write(p *packet) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := writePacket(c.conn, p); err != nil {
+		c.shutdown(err)
+		return c.Err()
+	}
+	return nil
+}
+
+// Publish sends a message to a topic. With AtLeastOnce, it waits for the
+// broker to acknowledge the message, resending it every RetryInterval, until
+// ctx is done.
+func (c *Client) Publish(ctx context.Context, topic string, payload []byte, qos QoS) error {
+	if err := checkTopic(topic); err != nil {
+		return err
+	}
+	pub := &publish{topic: topic, qos: qos, payload: payload}
+	switch qos {
+	case AtMostOnce:
+		return c.write(pub.packet())
+	case AtLeastOnce:
+	default:
+		return fmt.Errorf("unsupported QoS %d", qos)
+	}
+
+	id, acked, err := c.allocate()
+	if err != nil {
+		return err
+	}
+	defer c.release(id)
+	pub.packetID = id
+	retry := time.NewTicker(c.opts.RetryInterval)
+	defer retry.Stop()
+	for {
+		if err := c.write(pub.packet()); err != nil {
+			return err
+		}
+		select {
+		case p := <-acked:
+			if p.typ != packetPuback {
+				return fmt.Errorf("broker acknowledged PUBLISH with packet type %d", p.typ)
+			}
+			return nil
+		case <-retry.C:
+			pub.dup = true
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.done:
+			return c.Err()
+		}
+	}
+}
+
+// Subscribe subscribes to a topic filter, which may contain the '+' and '#'
+// wildcards, calling handler with each message on a matching topic. Messages
+// are delivered with at most the requested QoS.
+func (c *Client) Subscribe(ctx context.Context, filter string, qos QoS, handler Handler) error {
+	if filter == "" || qos > AtLeastOnce {
+		return errors.New("invalid MQTT subscription")
+	}
+	id, acked, err := c.allocate()
+	if err != nil {
+		return err
+	}
+	defer c.release(id)
+	sub := &subscription{filter, handler}
+	// Handle messages sent before the SUBACK.
+	c.mu.Lock()
+	c.subs = append(c.subs, sub)
+	c.mu.Unlock()
+
+	body := appendUint16(nil, id)
+	body = appendString(body, filter)
+	body = append(body, byte(qos))
+	err = c.write(&packet{typ: packetSubscribe, flags: 0x02, body: body})
+	if err == nil {
+		select {
+		case p := <-acked:
+			if p.typ != packetSuback || len(p.body) != 3 || p.body[2] == subackFailure {
+				err = fmt.Errorf("broker rejected subscription to %q", filter)
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-c.done:
+			err = c.Err()
+		}
+	}
+	if err != nil {
+		c.mu.Lock()
+		for i := range c.subs {
+			if c.subs[i] == sub {
+				c.subs = append(c.subs[:i], c.subs[i+1:]...)
+				break
+			}
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// allocate returns an unused packet identifier, and the channel its
+// acknowledgement is sent to.
+func (c *Client) allocate() (uint16, chan *packet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) >= 0xFFFF {
+		return 0, nil, errors.New("too many unacknowledged MQTT packets")
+	}
+	for {
+		c.nextID++
+		if _, ok := c.pending[c.nextID]; c.nextID != 0 && !ok {
+			break
+		}
+	}
+	acked := make(chan *packet, 1)
+	c.pending[c.nextID] = acked
+	return c.nextID, acked, nil
+}
+
+func (c *Client) release(id uint16) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *Client) readLoop(r *bufio.Reader) {
+	for {
+		p, err := readPacket(r, c.opts.MaxPacketSize)
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+		switch p.typ {
+		case packetPublish:
+			pub, err := parsePublish(p)
+			if err != nil {
+				c.shutdown(err)
+				return
+			}
+			c.dispatch(pub)
+		case packetPuback, packetSuback:
+			if len(p.body) < 2 {
+				c.shutdown(errMalformed)
+				return
+			}
+			id := uint16(p.body[0])<<8 | uint16(p.body[1])
+			c.mu.Lock()
+			if acked, ok := c.pending[id]; ok {
+				select {
+				case acked <- p:
+				default:
+				}
+			}
+			c.mu.Unlock()
+		case packetPingresp:
+			c.mu.Lock()
+			c.pingOutstanding = false
+			c.mu.Unlock()
+		default:
+			c.shutdown(fmt.Errorf("unexpected MQTT packet type %d", p.typ))
+			return
+		}
+	}
+}
+
+// dispatch calls the handlers of the subscriptions matching a message, then
+// acknowledges it.
+func (c *Client) dispatch(pub *publish) {
+	var handlers []Handler
+	c.mu.Lock()
+	for _, sub := range c.subs {
+		if matchTopic(sub.filter, pub.topic) {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	c.mu.Unlock()
+	go func() {
+		for _, handler := range handlers {
+			handler(pub.topic, pub.payload)
+		}
+		if pub.qos == AtLeastOnce {
+			c.write(&packet{typ: packetPuback, body: appendUint16(nil, pub.packetID)})
+		}
+	}()
+}
+
+func (c *Client) keepAlive() {
+	ticker := time.NewTicker(c.opts.KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+		c.mu.Lock()
+		timedOut := c.pingOutstanding
+		c.pingOutstanding = true
+		c.mu.Unlock()
+		if timedOut {
+			c.shutdown(errors.New("MQTT broker did not respond to ping"))
+			return
+		}
+		if c.write(&packet{typ: packetPingreq}) != nil {
+			return
+		}
+	}
+}
+
+// shutdown closes the connection, recording why.
+func (c *Client) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		c.err = fmt.Errorf("%w: %v", ErrClosed, err)
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// Done returns a channel which is closed once the client's connection is
+// closed or lost.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client's connection was closed, or nil if it is open.
+// The error matches ErrClosed.
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Close disconnects from the broker.
+func (c *Client) Close() error {
+	if c.Err() != nil {
+		return nil
+	}
+	err := c.write(&packet{typ: packetDisconnect})
+	c.shutdown(errors.New("client closed"))
+	return err
+}
+
+// checkTopic checks that a topic name can be published to.
+func checkTopic(topic string) error {
+	if topic == "" || strings.ContainsAny(topic, "+#\x00") {
+		return fmt.Errorf("invalid MQTT topic %q", topic)
+	}
+	return nil
+}
+
+// matchTopic reports whether a topic name matches a topic filter, from
+// Section 4.7 of the specification.
+func matchTopic(filter, topic string) bool {
+	// Wildcards do not match topics starting with '$', such as $SYS.
+	if strings.HasPrefix(topic, "$") && !strings.HasPrefix(filter, "$") {
+		return false
+	}
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i == len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
@@ -0,0 +1,355 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBroker is an in-memory MQTT broker, supporting enough of MQTT 3.1.1 to
+// test a Client.
+type testBroker struct {
+	t        *testing.T
+	password string
+	// Whether PINGREQs are ignored.
+	ignorePings bool
+
+	mu     sync.Mutex
+	subs   []brokerSub
+	nextID uint16
+	// The number of QoS 1 messages to drop (without acknowledging them), by
+	// topic.
+	drop map[string]int
+	// The QoS 1 messages received with the DUP flag, by topic.
+	dups map[string]int
+}
+
+type brokerSub struct {
+	filter string
+	qos    QoS
+	conn   *brokerConn
+}
+
+type brokerConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (c *brokerConn) write(p *packet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return writePacket(c.conn, p)
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	return &testBroker{t: t, drop: make(map[string]int), dups: make(map[string]int)}
+}
+
+// connect connects a new Client to the broker.
+func (b *testBroker) connect(opts Options) (*Client, error) {
+	server, conn := net.Pipe()
+	go b.serve(&brokerConn{conn: server})
+	return Connect(context.Background(), conn, opts)
+}
+
+func (b *testBroker) mustConnect(opts Options) *Client {
+	b.t.Helper()
+	if opts.ClientID == "" {
+		opts.CleanSession = true
+	}
+	c, err := b.connect(opts)
+	if err != nil {
+		b.t.Fatalf("Connect() failed: %v", err)
+	}
+	b.t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func (b *testBroker) serve(c *brokerConn) {
+	defer c.conn.Close()
+	defer b.unsubscribeAll(c)
+	b.mu.Lock()
+	ignorePings := b.ignorePings
+	b.mu.Unlock()
+	r := bufio.NewReader(c.conn)
+	p, err := readPacket(r, defaultMaxPacketSize)
+	if err != nil || p.typ != packetConnect {
+		return
+	}
+	code := byte(0)
+	if b.password != "" && !strings.HasSuffix(string(p.body), b.password) {
+		code = 4
+	}
+	if c.write(&packet{typ: packetConnack, body: []byte{0, code}}) != nil || code != 0 {
+		return
+	}
+	for {
+		p, err := readPacket(r, defaultMaxPacketSize)
+		if err != nil {
+			return
+		}
+		switch p.typ {
+		case packetPublish:
+			pub, err := parsePublish(p)
+			if err != nil {
+				return
+			}
+			if pub.qos == AtLeastOnce {
+				b.mu.Lock()
+				drop := b.drop[pub.topic] > 0
+				if drop {
+					b.drop[pub.topic]--
+				}
+				if pub.dup {
+					b.dups[pub.topic]++
+				}
+				b.mu.Unlock()
+				if drop {
+					continue
+				}
+				c.write(&packet{typ: packetPuback, body: appendUint16(nil, pub.packetID)})
+			}
+			b.forward(pub)
+		case packetSubscribe:
+			sr := &reader{data: p.body}
+			id := sr.uint16()
+			filter := sr.string()
+			if sr.err != nil || len(sr.data) != 1 {
+				return
+			}
+			granted := QoS(sr.data[0])
+			b.mu.Lock()
+			b.subs = append(b.subs, brokerSub{filter, granted, c})
+			b.mu.Unlock()
+			code := byte(granted)
+			if strings.HasPrefix(filter, "forbidden/") {
+				code = subackFailure
+			}
+			c.write(&packet{typ: packetSuback, body: append(appendUint16(nil, id), code)})
+		case packetPingreq:
+			if !ignorePings {
+				c.write(&packet{typ: packetPingresp})
+			}
+		case packetPuback:
+		case packetDisconnect:
+			return
+		default:
+			b.t.Errorf("broker received unexpected packet type %d", p.typ)
+			return
+		}
+	}
+}
+
+// forward sends a message to the matching subscriptions.
+func (b *testBroker) forward(pub *publish) {
+	b.mu.Lock()
+	var subs []brokerSub
+	for _, sub := range b.subs {
+		if matchTopic(sub.filter, pub.topic) {
+			subs = append(subs, sub)
+		}
+	}
+	b.mu.Unlock()
+	for _, sub := range subs {
+		out := &publish{topic: pub.topic, qos: pub.qos, payload: pub.payload}
+		if sub.qos < out.qos {
+			out.qos = sub.qos
+		}
+		if out.qos == AtLeastOnce {
+			b.mu.Lock()
+			b.nextID++
+			out.packetID = b.nextID
+			b.mu.Unlock()
+		}
+		go sub.conn.write(out.packet())
+	}
+}
+
+func (b *testBroker) unsubscribeAll(c *brokerConn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs := b.subs[:0]
+	for _, sub := range b.subs {
+		if sub.conn != c {
+			subs = append(subs, sub)
+		}
+	}
+	b.subs = subs
+}
+
+type message struct {
+	topic   string
+	payload string
+}
+
+// collect subscribes to a filter, returning a channel of the messages.
+func collect(t *testing.T, c *Client, filter string, qos QoS) chan message {
+	t.Helper()
+	messages := make(chan message, 10)
+	err := c.Subscribe(context.Background(), filter, qos, func(topic string, payload []byte) {
+		messages <- message{topic, string(payload)}
+	})
+	if err != nil {
+		t.Fatalf("Subscribe(%q) failed: %v", filter, err)
+	}
+	return messages
+}
+
+func receive(t *testing.T, messages chan message) message {
+	t.Helper()
+	select {
+	case m := <-messages:
+		return m
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return message{}
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	broker := newTestBroker(t)
+	subscriber := broker.mustConnect(Options{ClientID: "subscriber"})
+	publisher := broker.mustConnect(Options{})
+	messages := collect(t, subscriber, "fleet/+/evidence", AtLeastOnce)
+
+	ctx := context.Background()
+	for _, qos := range []QoS{AtMostOnce, AtLeastOnce} {
+		if err := publisher.Publish(ctx, "fleet/device-1/evidence", []byte("hello"), qos); err != nil {
+			t.Fatalf("Publish() with QoS %d failed: %v", qos, err)
+		}
+		if got := receive(t, messages); got != (message{"fleet/device-1/evidence", "hello"}) {
+			t.Errorf("got message %v with QoS %d", got, qos)
+		}
+	}
+	if err := publisher.Publish(ctx, "fleet/device-1/challenge", nil, AtLeastOnce); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-messages:
+		t.Errorf("got message %v on a topic not subscribed to", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := publisher.Publish(ctx, "fleet/+/evidence", nil, AtMostOnce); err == nil {
+		t.Error("Publish() to a wildcard topic should fail")
+	}
+	if err := subscriber.Subscribe(ctx, "forbidden/#", AtLeastOnce, func(string, []byte) {}); err == nil {
+		t.Error("Subscribe() should fail when the broker rejects the subscription")
+	}
+}
+
+func TestPublishRetry(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.drop["topic"] = 2
+	subscriber := broker.mustConnect(Options{})
+	publisher := broker.mustConnect(Options{RetryInterval: 10 * time.Millisecond})
+	messages := collect(t, subscriber, "topic", AtLeastOnce)
+
+	if err := publisher.Publish(context.Background(), "topic", []byte("retried"), AtLeastOnce); err != nil {
+		t.Fatalf("Publish() failed: %v", err)
+	}
+	if got := receive(t, messages); got.payload != "retried" {
+		t.Errorf("got message %v", got)
+	}
+	broker.mu.Lock()
+	if broker.dups["topic"] != 2 {
+		t.Errorf("broker received %d messages marked as duplicates, want 2", broker.dups["topic"])
+	}
+	// An unacknowledged message is resent until the context is done.
+	broker.drop["unacknowledged"] = 1 << 30
+	broker.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := publisher.Publish(ctx, "unacknowledged", nil, AtLeastOnce); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish() of an unacknowledged message returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.password = "secret"
+	if _, err := broker.connect(Options{CleanSession: true, Username: "device", Password: "wrong"}); err == nil || !strings.Contains(err.Error(), "bad user name or password") {
+		t.Errorf("Connect() with the wrong password returned %v", err)
+	}
+	c, err := broker.connect(Options{CleanSession: true, Username: "device", Password: "secret"})
+	if err != nil {
+		t.Fatalf("Connect() with the right password failed: %v", err)
+	}
+	c.Close()
+	if _, err := broker.connect(Options{}); err == nil {
+		t.Error("Connect() without a client ID or clean session should fail")
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	broker := newTestBroker(t)
+	c := broker.mustConnect(Options{KeepAlive: 10 * time.Millisecond})
+	time.Sleep(50 * time.Millisecond)
+	if err := c.Err(); err != nil {
+		t.Fatalf("connection closed when the broker answered pings: %v", err)
+	}
+
+	broker.mu.Lock()
+	broker.ignorePings = true
+	broker.mu.Unlock()
+	c = broker.mustConnect(Options{KeepAlive: 10 * time.Millisecond})
+	select {
+	case <-c.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("connection not closed when the broker ignored pings")
+	}
+	if err := c.Publish(context.Background(), "topic", nil, AtLeastOnce); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish() after the connection was lost returned %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	subtests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"fleet/device/evidence", "fleet/device/evidence", true},
+		{"fleet/+/evidence", "fleet/device/evidence", true},
+		{"fleet/+/evidence", "fleet/device/verdict", false},
+		{"fleet/+/evidence", "fleet/a/b/evidence", false},
+		{"fleet/#", "fleet/device/evidence", true},
+		{"fleet/#", "fleet", true},
+		{"#", "$SYS/broker", false},
+		{"$SYS/#", "$SYS/broker", true},
+		{"fleet/device", "fleet/device/evidence", false},
+		{"fleet/device/evidence", "fleet/device", false},
+	}
+	for _, subtest := range subtests {
+		if got := matchTopic(subtest.filter, subtest.topic); got != subtest.want {
+			t.Errorf("matchTopic(%q, %q) = %v, want %v", subtest.filter, subtest.topic, got, subtest.want)
+		}
+	}
+}
+
+func TestPacketLengths(t *testing.T) {
+	for _, length := range []int{0, 127, 128, 16383, 16384, 2097152} {
+		p := &packet{typ: packetPublish, flags: 0x02, body: bytes.Repeat([]byte{0xAB}, length)}
+		data, err := p.encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := readPacket(bufio.NewReader(bytes.NewReader(data)), length)
+		if err != nil {
+			t.Fatalf("readPacket() of a %d byte packet failed: %v", length, err)
+		}
+		if got.typ != p.typ || got.flags != p.flags || !bytes.Equal(got.body, p.body) {
+			t.Errorf("packet of %d bytes changed when encoded", length)
+		}
+		if length > 0 {
+			if _, err := readPacket(bufio.NewReader(bytes.NewReader(data)), length-1); err == nil {
+				t.Errorf("readPacket() of a %d byte packet should exceed the limit", length)
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types, from Section 2.2.1 of the specification.
+const (
+	packetConnect     byte = 1
+	packetConnack     byte = 2
+	packetPublish     byte = 3
+	packetPuback      byte = 4
+	packetSubscribe   byte = 8
+	packetSuback      byte = 9
+	packetPingreq     byte = 12
+	packetPingresp    byte = 13
+	packetDisconnect  byte = 14
+	maxRemainingBytes      = 4
+	// The largest remaining length which can be encoded, from Section 2.2.3.
+	maxRemainingLength = 268435455
+)
+
+// Flags of a PUBLISH packet.
+const (
+	publishDup    = 0x08
+	publishRetain = 0x01
+)
+
+// subackFailure is the SUBACK return code of a rejected subscription.
+const subackFailure = 0x80
+
+var errMalformed = errors.New("malformed MQTT packet")
+
+// packet is an MQTT control packet: its type, the flags in the low bits of
+// its first byte, and the rest of the packet after the remaining length.
+type packet struct {
+	typ   byte
+	flags byte
+	body  []byte
+}
+
+// readPacket reads a packet, rejecting packets longer than maxSize.
+func readPacket(r *bufio.Reader, maxSize int) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == maxRemainingBytes {
+			return nil, errMalformed
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length += int(b&0x7F) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	if length > maxSize {
+		return nil, fmt.Errorf("MQTT packet of %d bytes exceeds limit of %d bytes", length, maxSize)
+	}
+	p := &packet{typ: header >> 4, flags: header & 0x0F, body: make([]byte, length)}
+	if _, err := io.ReadFull(r, p.body); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// encode returns the packet with its fixed header.
+func (p *packet) encode() ([]byte, error) {
+	length := len(p.body)
+	if length > maxRemainingLength {
+		return nil, fmt.Errorf("MQTT packet of %d bytes is too large", length)
+	}
+	out := make([]byte, 0, 1+maxRemainingBytes+length)
+	out = append(out, p.typ<<4|p.flags)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(out, p.body...), nil
+}
+
+// appendString appends an MQTT UTF-8 string (or binary data), prefixed by its
+// length.
+func appendString(b []byte, s string) []byte {
+	b = appendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// reader decodes the fields of a packet's body.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) uint16() uint16 {
+	if len(r.data) < 2 {
+		r.err = errMalformed
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.data)
+	r.data = r.data[2:]
+	return v
+}
+
+func (r *reader) string() string {
+	n := int(r.uint16())
+	if len(r.data) < n {
+		r.err = errMalformed
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+// publish is a PUBLISH packet.
+type publish struct {
+	topic    string
+	qos      QoS
+	dup      bool
+	retain   bool
+	packetID uint16
+	payload  []byte
+}
+
+func (p *publish) packet() *packet {
+	flags := byte(p.qos) << 1
+	if p.dup {
+		flags |= publishDup
+	}
+	if p.retain {
+		flags |= publishRetain
+	}
+	body := appendString(nil, p.topic)
+	if p.qos > AtMostOnce {
+		body = appendUint16(body, p.packetID)
+	}
+	return &packet{typ: packetPublish, flags: flags, body: append(body, p.payload...)}
+}
+
+func parsePublish(p *packet) (*publish, error) {
+	pub := &publish{
+		qos:    QoS(p.flags>>1) & 0x03,
+		dup:    p.flags&publishDup != 0,
+		retain: p.flags&publishRetain != 0,
+	}
+	if pub.qos > AtLeastOnce {
+		return nil, fmt.Errorf("unsupported QoS %d", pub.qos)
+	}
+	r := &reader{data: p.body}
+	pub.topic = r.string()
+	if pub.qos > AtMostOnce {
+		pub.packetID = r.uint16()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	pub.payload = r.data
+	return pub, nil
+}
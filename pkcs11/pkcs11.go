@@ -0,0 +1,296 @@
+//go:build cgo
+// +build cgo
+
+// Package pkcs11 looks up public keys in PKCS#11 tokens, such as HSMs, by
+// their RFC 7512 URIs. It implements server.PKCS11KeySource, so AKs enrolled
+// into a token can be trusted by the server package:
+//
+//	module, err := pkcs11.Open("/usr/lib/softhsm/libsofthsm2.so")
+//	if err != nil {
+//		return err
+//	}
+//	defer module.Close()
+//	aks, err := server.PKCS11PublicKeys(module, "pkcs11:token=aks;object=my-ak")
+//
+// This package requires cgo, as it loads the PKCS#11 module with dlopen.
+package pkcs11
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+
+	p11 "github.com/miekg/pkcs11"
+
+	"github.com/google/go-tpm-tools/server"
+)
+
+// Module is a loaded PKCS#11 module.
+type Module struct {
+	path string
+	ctx  *p11.Ctx
+}
+
+// Open loads and initializes the PKCS#11 module at path. Callers should call
+// Close when the module is no longer needed.
+func Open(path string) (*Module, error) {
+	ctx := p11.New(path)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %q", path)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module %q: %w", path, err)
+	}
+	return &Module{path, ctx}, nil
+}
+
+// Close finalizes and unloads the module.
+func (m *Module) Close() error {
+	defer m.ctx.Destroy()
+	return m.ctx.Finalize()
+}
+
+// PublicKey returns the RSA or ECDSA public key of the only public key or
+// certificate object matching the URI. If the URI does not specify a type,
+// certificates are only considered if no public key object matches. The
+// token is only logged into if the URI contains a pin-value or pin-source.
+func (m *Module) PublicKey(uri *server.PKCS11URI) (crypto.PublicKey, error) {
+	if err := m.checkModule(uri); err != nil {
+		return nil, err
+	}
+	var classes []uint
+	switch uri.Type {
+	case "":
+		classes = []uint{p11.CKO_PUBLIC_KEY, p11.CKO_CERTIFICATE}
+	case "public":
+		classes = []uint{p11.CKO_PUBLIC_KEY}
+	case "cert":
+		classes = []uint{p11.CKO_CERTIFICATE}
+	default:
+		return nil, fmt.Errorf("cannot get a public key from %q objects", uri.Type)
+	}
+	pin, err := uriPIN(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	slots, err := m.ctx.GetSlotList(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list slots: %w", err)
+	}
+	for _, class := range classes {
+		var keys []crypto.PublicKey
+		for _, slot := range slots {
+			if ok, err := m.matchesToken(slot, uri); err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+			slotKeys, err := m.findKeys(slot, pin, class, uri)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, slotKeys...)
+		}
+		if len(keys) > 1 {
+			return nil, fmt.Errorf("%d objects match the URI, want exactly one", len(keys))
+		}
+		if len(keys) == 1 {
+			return keys[0], nil
+		}
+	}
+	return nil, errors.New("no public key or certificate matches the URI")
+}
+
+// checkModule checks the URI's module-path and module-name match this module.
+func (m *Module) checkModule(uri *server.PKCS11URI) error {
+	if uri.ModulePath != "" && filepath.Clean(uri.ModulePath) != filepath.Clean(m.path) {
+		return fmt.Errorf("URI is for module %q, not %q", uri.ModulePath, m.path)
+	}
+	if uri.ModuleName != "" {
+		base := strings.TrimPrefix(filepath.Base(m.path), "lib")
+		if strings.SplitN(base, ".", 2)[0] != uri.ModuleName {
+			return fmt.Errorf("URI is for module %q, not %q", uri.ModuleName, m.path)
+		}
+	}
+	return nil
+}
+
+func uriPIN(uri *server.PKCS11URI) (string, error) {
+	if uri.PinSource == "" {
+		return uri.PinValue, nil
+	}
+	if uri.PinValue != "" {
+		return "", errors.New("URI has both a pin-value and a pin-source")
+	}
+	pin, err := ioutil.ReadFile(strings.TrimPrefix(uri.PinSource, "file:"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read PIN: %w", err)
+	}
+	return strings.TrimRight(string(pin), "\r\n"), nil
+}
+
+func (m *Module) matchesToken(slot uint, uri *server.PKCS11URI) (bool, error) {
+	if uri.SlotID != nil && *uri.SlotID != slot {
+		return false, nil
+	}
+	info, err := m.ctx.GetTokenInfo(slot)
+	if err != nil {
+		return false, fmt.Errorf("failed to get token info for slot %d: %w", slot, err)
+	}
+	matches := func(want, got string) bool {
+		return want == "" || want == strings.TrimRight(got, " \x00")
+	}
+	return matches(uri.Token, info.Label) &&
+		matches(uri.Manufacturer, info.ManufacturerID) &&
+		matches(uri.Model, info.Model) &&
+		matches(uri.Serial, info.SerialNumber), nil
+}
+
+func (m *Module) findKeys(slot uint, pin string, class uint, uri *server.PKCS11URI) ([]crypto.PublicKey, error) {
+	session, err := m.ctx.OpenSession(slot, p11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session on slot %d: %w", slot, err)
+	}
+	defer m.ctx.CloseSession(session)
+	if pin != "" {
+		if err := m.ctx.Login(session, p11.CKU_USER, pin); err != nil && !errors.Is(err, p11.Error(p11.CKR_USER_ALREADY_LOGGED_IN)) {
+			return nil, fmt.Errorf("failed to log into slot %d: %w", slot, err)
+		}
+		defer m.ctx.Logout(session)
+	}
+
+	template := []*p11.Attribute{p11.NewAttribute(p11.CKA_CLASS, class)}
+	if uri.Object != "" {
+		template = append(template, p11.NewAttribute(p11.CKA_LABEL, uri.Object))
+	}
+	if uri.ID != nil {
+		template = append(template, p11.NewAttribute(p11.CKA_ID, uri.ID))
+	}
+	if err := m.ctx.FindObjectsInit(session, template); err != nil {
+		return nil, fmt.Errorf("failed to find objects: %w", err)
+	}
+	// Two objects are enough to know the URI is ambiguous.
+	objects, _, err := m.ctx.FindObjects(session, 2)
+	m.ctx.FindObjectsFinal(session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find objects: %w", err)
+	}
+
+	var keys []crypto.PublicKey
+	for _, object := range objects {
+		var key crypto.PublicKey
+		if class == p11.CKO_CERTIFICATE {
+			key, err = m.certificateKey(session, object)
+		} else {
+			key, err = m.publicKey(session, object)
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *Module) attributes(session p11.SessionHandle, object p11.ObjectHandle, types ...uint) ([][]byte, error) {
+	template := make([]*p11.Attribute, len(types))
+	for i, typ := range types {
+		template[i] = p11.NewAttribute(typ, nil)
+	}
+	attrs, err := m.ctx.GetAttributeValue(session, object, template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object attributes: %w", err)
+	}
+	values := make([][]byte, len(attrs))
+	for i, attr := range attrs {
+		values[i] = attr.Value
+	}
+	return values, nil
+}
+
+func (m *Module) certificateKey(session p11.SessionHandle, object p11.ObjectHandle) (crypto.PublicKey, error) {
+	values, err := m.attributes(session, object, p11.CKA_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(values[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return cert.PublicKey, nil
+}
+
+func (m *Module) publicKey(session p11.SessionHandle, object p11.ObjectHandle) (crypto.PublicKey, error) {
+	values, err := m.attributes(session, object, p11.CKA_KEY_TYPE)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(values[0], p11.NewAttribute(p11.CKA_KEY_TYPE, p11.CKK_RSA).Value):
+		if values, err = m.attributes(session, object, p11.CKA_MODULUS, p11.CKA_PUBLIC_EXPONENT); err != nil {
+			return nil, err
+		}
+		return rsaPublicKey(values[0], values[1])
+	case bytes.Equal(values[0], p11.NewAttribute(p11.CKA_KEY_TYPE, p11.CKK_EC).Value):
+		if values, err = m.attributes(session, object, p11.CKA_EC_PARAMS, p11.CKA_EC_POINT); err != nil {
+			return nil, err
+		}
+		return ecdsaPublicKey(values[0], values[1])
+	}
+	return nil, errors.New("unsupported public key type, want RSA or EC")
+}
+
+func rsaPublicKey(modulus, exponent []byte) (*rsa.PublicKey, error) {
+	e := new(big.Int).SetBytes(exponent)
+	if !e.IsInt64() || e.Int64() > 1<<31-1 || e.Int64() < 3 {
+		return nil, errors.New("invalid RSA public exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(e.Int64())}, nil
+}
+
+var curves = []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()}
+
+var curveOIDs = map[string]asn1.ObjectIdentifier{
+	"P-256": {1, 2, 840, 10045, 3, 1, 7},
+	"P-384": {1, 3, 132, 0, 34},
+	"P-521": {1, 3, 132, 0, 35},
+}
+
+func ecdsaPublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if rest, err := asn1.Unmarshal(params, &oid); err != nil || len(rest) != 0 {
+		return nil, errors.New("EC parameters are not a named curve")
+	}
+	var curve elliptic.Curve
+	for _, c := range curves {
+		if curveOIDs[c.Params().Name].Equal(oid) {
+			curve = c
+		}
+	}
+	if curve == nil {
+		return nil, fmt.Errorf("unsupported curve %v", oid)
+	}
+	// CKA_EC_POINT is a DER OCTET STRING, though some modules omit the
+	// encoding.
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) != 0 {
+		raw = point
+	}
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, errors.New("invalid EC point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
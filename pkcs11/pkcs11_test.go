@@ -0,0 +1,117 @@
+//go:build cgo
+// +build cgo
+
+package pkcs11
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"os"
+	"testing"
+
+	"github.com/google/go-tpm-tools/server"
+)
+
+// testModule returns the PKCS#11 module in $PKCS11_MODULE, or p11-kit's proxy
+// module, which provides the tokens configured on the system (usually none).
+func testModule(t *testing.T) string {
+	t.Helper()
+	paths := []string{
+		"/usr/lib/x86_64-linux-gnu/p11-kit-proxy.so",
+		"/usr/lib/x86_64-linux-gnu/libp11-kit.so.0",
+		"/usr/lib64/p11-kit-proxy.so",
+	}
+	if path := os.Getenv("PKCS11_MODULE"); path != "" {
+		paths = []string{path}
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	t.Skip("no PKCS#11 module available, set PKCS11_MODULE")
+	return ""
+}
+
+func TestModule(t *testing.T) {
+	module, err := Open(testModule(t))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer module.Close()
+
+	subtests := []struct {
+		name string
+		uri  string
+	}{
+		{"NoToken", "pkcs11:token=go-tpm-tools-test-token;object=ak"},
+		{"OtherModulePath", "pkcs11:object=ak?module-path=/usr/lib/other.so"},
+		{"OtherModuleName", "pkcs11:object=ak?module-name=other"},
+		{"PrivateKey", "pkcs11:object=ak;type=private"},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, err := server.PKCS11PublicKeys(module, subtest.uri); err == nil {
+				t.Error("PKCS11PublicKeys() succeeded, want error")
+			}
+		})
+	}
+	if _, err := Open("/nonexistent/module.so"); err == nil {
+		t.Error("Open() of a missing module succeeded")
+	}
+}
+
+func TestRSAPublicKey(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsaPublicKey(priv.N.Bytes(), []byte{1, 0, 1})
+	if err != nil {
+		t.Fatalf("rsaPublicKey() failed: %v", err)
+	}
+	if !priv.PublicKey.Equal(key) {
+		t.Error("rsaPublicKey() returned the wrong key")
+	}
+	if _, err := rsaPublicKey(priv.N.Bytes(), []byte{1}); err == nil {
+		t.Error("rsaPublicKey() accepted an exponent of 1")
+	}
+}
+
+func TestECDSAPublicKey(t *testing.T) {
+	for _, curve := range curves {
+		t.Run(curve.Params().Name, func(t *testing.T) {
+			priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params, err := asn1.Marshal(curveOIDs[curve.Params().Name])
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw := elliptic.Marshal(curve, priv.X, priv.Y)
+			encoded, err := asn1.Marshal(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, point := range [][]byte{encoded, raw} {
+				key, err := ecdsaPublicKey(params, point)
+				if err != nil {
+					t.Fatalf("ecdsaPublicKey() failed: %v", err)
+				}
+				if !priv.PublicKey.Equal(key) {
+					t.Error("ecdsaPublicKey() returned the wrong key")
+				}
+			}
+			if _, err := ecdsaPublicKey(params, raw[:len(raw)-1]); err == nil {
+				t.Error("ecdsaPublicKey() accepted a truncated point")
+			}
+		})
+	}
+	if _, err := ecdsaPublicKey([]byte{0x05, 0x00}, nil); err == nil {
+		t.Error("ecdsaPublicKey() accepted non-OID parameters")
+	}
+}
@@ -0,0 +1,231 @@
+package policy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// AuthorizingKey returns the public area of a key (which need not be a TPM
+// key) signing authorizations for TPM2_PolicySigned, or policies for
+// TPM2_PolicyAuthorize, with the given hash algorithm. The hash algorithm is
+// also its name algorithm. Its Name (see ObjectName) is the keyName of
+// Policy.Signed, and the public area is loaded into the TPM to check the
+// authorizations.
+func AuthorizingKey(pub crypto.PublicKey, hash crypto.Hash) (tpm2.Public, error) {
+	alg, err := tpm2.HashToAlgorithm(hash)
+	if err != nil {
+		return tpm2.Public{}, err
+	}
+	public := tpm2.Public{
+		NameAlg:    alg,
+		Attributes: tpm2.FlagSign | tpm2.FlagUserWithAuth,
+	}
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		public.Type = tpm2.AlgRSA
+		public.RSAParameters = &tpm2.RSAParams{
+			Sign:       &tpm2.SigScheme{Alg: tpm2.AlgRSASSA, Hash: alg},
+			KeyBits:    uint16(pub.N.BitLen()),
+			ModulusRaw: pub.N.Bytes(),
+		}
+		// The default exponent is encoded as zero.
+		if pub.E != 65537 {
+			public.RSAParameters.ExponentRaw = uint32(pub.E)
+		}
+	case *ecdsa.PublicKey:
+		var curve tpm2.EllipticCurve
+		switch pub.Curve {
+		case elliptic.P256():
+			curve = tpm2.CurveNISTP256
+		case elliptic.P384():
+			curve = tpm2.CurveNISTP384
+		case elliptic.P521():
+			curve = tpm2.CurveNISTP521
+		default:
+			return tpm2.Public{}, fmt.Errorf("unsupported curve %v", pub.Curve.Params().Name)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		public.Type = tpm2.AlgECC
+		public.ECCParameters = &tpm2.ECCParams{
+			Sign:    &tpm2.SigScheme{Alg: tpm2.AlgECDSA, Hash: alg},
+			CurveID: curve,
+			Point: tpm2.ECPoint{
+				XRaw: pub.X.FillBytes(make([]byte, size)),
+				YRaw: pub.Y.FillBytes(make([]byte, size)),
+			},
+		}
+	default:
+		return tpm2.Public{}, fmt.Errorf("unsupported authorizing key type %T", pub)
+	}
+	return public, nil
+}
+
+// SignedAuthorization is what the holder of an authorizing key signs to
+// satisfy TPM2_PolicySigned (see Signed), such as a controller approving a
+// single use of an AK. See Part 3 of the spec, Section 23.3.
+type SignedAuthorization struct {
+	// The nonceTPM of the policy session being authorized. If set, the
+	// authorization is only valid in that session, so it cannot be replayed.
+	NonceTPM []byte
+	// If nonzero, the number of seconds (from when the session was started,
+	// if NonceTPM is set) after which the authorization expires.
+	Expiration int32
+	// If set, limits the authorization to the command with these command
+	// parameters (cpHashA).
+	CpHash []byte
+	// The policyRef in the policy, which qualifies what is being authorized.
+	PolicyRef []byte
+}
+
+// Digest returns the digest the authorizing key signs, aHash, with the hash
+// algorithm of its signing scheme: H(nonceTPM || expiration || cpHashA ||
+// policyRef).
+func (a SignedAuthorization) Digest(hash crypto.Hash) []byte {
+	h := hash.New()
+	h.Write(a.NonceTPM)
+	binary.Write(h, binary.BigEndian, a.Expiration)
+	h.Write(a.CpHash)
+	h.Write(a.PolicyRef)
+	return h.Sum(nil)
+}
+
+// Sign signs the authorization with the authorizing key, an RSA key (with
+// RSASSA) or an ECDSA key, using the given hash algorithm. The key need not be
+// a TPM key: its public area, with the same signing scheme, is loaded into the
+// TPM to check the signature.
+func (a SignedAuthorization) Sign(signer crypto.Signer, hash crypto.Hash) (*tpm2.Signature, error) {
+	sig, err := sign(signer, hash, hash, a.Digest(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign authorization: %w", err)
+	}
+	return sig, nil
+}
+
+// ApprovalDigest returns the digest an authority signs to approve a policy for
+// TPM2_PolicyAuthorize (see Authorize), aHash, with the name algorithm of the
+// authority: H(approvedPolicy || policyRef). Unlike for TPM2_PolicySigned,
+// this is not the hash algorithm of its signing scheme.
+func ApprovalDigest(nameAlg crypto.Hash, approvedPolicy, policyRef []byte) []byte {
+	h := nameAlg.New()
+	h.Write(approvedPolicy)
+	h.Write(policyRef)
+	return h.Sum(nil)
+}
+
+// SignPCRPolicy approves PCR values for data sealed to an authority (see
+// client.SealOpts.Authority), by signing the SHA-256 digest of the
+// TPM2_PolicyPCR policy requiring them with the authority's key. The authority
+// is the public area of the signer (see AuthorizingKey) the data was sealed
+// to. The data can then be unsealed while the PCRs have any of the approved
+// values, so new values (such as those after an upgrade) are approved by
+// signing another policy, without resealing the data.
+func SignPCRPolicy(signer crypto.Signer, authority tpm2.Public, pcrs *tpmpb.PCRs, policyRef []byte) (*tpmpb.SignedPCRPolicy, error) {
+	pub, err := authority.Key()
+	if err != nil {
+		return nil, fmt.Errorf("invalid authority: %w", err)
+	}
+	if key, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !key.Equal(pub) {
+		return nil, fmt.Errorf("signer is not the authority's key")
+	}
+	nameAlg, err := authority.NameAlg.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("invalid authority name algorithm: %w", err)
+	}
+	hash, err := signingHash(authority)
+	if err != nil {
+		return nil, err
+	}
+	// An RSASSA signature identifies the hash algorithm of the signed digest,
+	// which the TPM checks is that of the signing scheme.
+	if _, ok := pub.(*rsa.PublicKey); ok && nameAlg != hash {
+		return nil, fmt.Errorf("RSA authority has name algorithm %v, but signs with %v", nameAlg, hash)
+	}
+	approved, err := New(crypto.SHA256).PCRValues(pcrs).Digest()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sign(signer, hash, nameAlg, ApprovalDigest(nameAlg, approved, policyRef))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign policy: %w", err)
+	}
+	encoded, err := EncodeSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	return &tpmpb.SignedPCRPolicy{Pcrs: pcrs, Signature: encoded}, nil
+}
+
+// signingHash returns the hash algorithm of an authorizing key's signing
+// scheme.
+func signingHash(public tpm2.Public) (crypto.Hash, error) {
+	var scheme *tpm2.SigScheme
+	switch {
+	case public.Type == tpm2.AlgRSA && public.RSAParameters != nil:
+		scheme = public.RSAParameters.Sign
+	case public.Type == tpm2.AlgECC && public.ECCParameters != nil:
+		scheme = public.ECCParameters.Sign
+	}
+	if scheme == nil {
+		return 0, fmt.Errorf("authority has no signing scheme")
+	}
+	return scheme.Hash.Hash()
+}
+
+// sign signs a digest, computed with digestHash, with an RSA (RSASSA) or ECDSA
+// key whose signing scheme uses the given hash algorithm.
+func sign(signer crypto.Signer, hash, digestHash crypto.Hash, digest []byte) (*tpm2.Signature, error) {
+	alg, err := tpm2.HashToAlgorithm(hash)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.Sign(rand.Reader, digest, digestHash)
+	if err != nil {
+		return nil, err
+	}
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		return &tpm2.Signature{
+			Alg: tpm2.AlgRSASSA,
+			RSA: &tpm2.SignatureRSA{HashAlg: alg, Signature: sig},
+		}, nil
+	case *ecdsa.PublicKey:
+		var ecdsaSig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &ecdsaSig); err != nil {
+			return nil, fmt.Errorf("failed to decode ECDSA signature: %w", err)
+		}
+		return &tpm2.Signature{
+			Alg: tpm2.AlgECDSA,
+			ECC: &tpm2.SignatureECC{HashAlg: alg, R: ecdsaSig.R, S: ecdsaSig.S},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", signer.Public())
+	}
+}
+
+// EncodeSignature encodes an RSASSA, RSAPSS or ECDSA signature as a
+// TPMT_SIGNATURE, as passed to TPM2_PolicySigned.
+func EncodeSignature(sig *tpm2.Signature) ([]byte, error) {
+	switch {
+	case sig == nil:
+		return nil, errors.New("missing signature")
+	case (sig.Alg == tpm2.AlgRSASSA || sig.Alg == tpm2.AlgRSAPSS) && sig.RSA != nil:
+		return tpmutil.Pack(sig.Alg, sig.RSA.HashAlg, sig.RSA.Signature)
+	case sig.Alg == tpm2.AlgECDSA && sig.ECC != nil:
+		return tpmutil.Pack(sig.Alg, sig.ECC.HashAlg, tpmutil.U16Bytes(sig.ECC.R.Bytes()), tpmutil.U16Bytes(sig.ECC.S.Bytes()))
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm %v", sig.Alg)
+	}
+}
@@ -0,0 +1,57 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// Names are encoded as TPMU_NAME values: the contents of a TPM2B_NAME, as used
+// in policy digests and in the Names of objects and NV indexes. See Part 1 of
+// the spec, Section 16.
+
+// ErrUnsupportedName is returned when computing the Name of an object whose
+// name algorithm is not available.
+var ErrUnsupportedName = errors.New("unsupported name algorithm")
+
+// ObjectName returns the Name of an object (such as a key or sealed data)
+// from its public area: its name algorithm followed by the digest of the
+// encoded public area.
+func ObjectName(pub tpm2.Public) ([]byte, error) {
+	if _, err := pub.NameAlg.Hash(); err != nil {
+		return nil, fmt.Errorf("%w %v: %v", ErrUnsupportedName, pub.NameAlg, err)
+	}
+	name, err := pub.Name()
+	if err != nil {
+		return nil, err
+	}
+	return name.Digest.Encode()
+}
+
+// NVName returns the Name of an NV index from its public area: its name
+// algorithm followed by the digest of the encoded public area. As the public
+// area includes the index's attributes, the Name changes once the index is
+// written (when TPMA_NV_WRITTEN is set).
+func NVName(pub tpm2.NVPublic) ([]byte, error) {
+	hash, err := pub.NameAlg.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("%w %v: %v", ErrUnsupportedName, pub.NameAlg, err)
+	}
+	encoded, err := tpmutil.Pack(pub)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(encoded)
+	return tpmutil.Pack(pub.NameAlg, tpmutil.RawBytes(h.Sum(nil)))
+}
+
+// HandleName returns the Name of an entity without a public area, such as a
+// hierarchy (e.g. tpm2.HandleEndorsement), a PCR or a session, which is its
+// handle.
+func HandleName(handle tpmutil.Handle) []byte {
+	name, _ := tpmutil.Pack(handle)
+	return name
+}
@@ -0,0 +1,361 @@
+// Package policy computes TPM object Names and policy digests without a TPM,
+// as needed to seal data to future PCR values, to create objects for import
+// into another TPM, or to audit the auth policies of existing objects.
+//
+// A Policy computes the digest a policy session would have after running a
+// sequence of policy commands, which is the authPolicy of objects and NV
+// indexes usable with that sequence:
+//
+//	digest, err := policy.New(crypto.SHA256).
+//		PCR(sel, pcrDigest).
+//		CommandCode(tpm2.CmdUnseal).
+//		Digest()
+//
+// Policy trees are composed with OR, whose branches are themselves Policies.
+//
+// Like the quote package, this package only depends on go-tpm and the proto
+// packages, and its exported API will not change incompatibly within a major
+// version of this module.
+package policy
+
+import (
+	"crypto"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// Policy command codes from Part 2 of the spec, Section 6.5.2, which go-tpm
+// does not define.
+const (
+	ccPolicySigned            tpmutil.Command = 0x00000160
+	ccPolicyNV                tpmutil.Command = 0x00000149
+	ccPolicyCpHash            tpmutil.Command = 0x00000163
+	ccPolicyAuthorize         tpmutil.Command = 0x0000016A
+	ccPolicyAuthValue         tpmutil.Command = 0x0000016B
+	ccPolicyCounterTimer      tpmutil.Command = 0x0000016D
+	ccPolicyLocality          tpmutil.Command = 0x0000016F
+	ccPolicyNameHash          tpmutil.Command = 0x00000170
+	ccPolicyPhysicalPresence  tpmutil.Command = 0x00000187
+	ccPolicyDuplicationSelect tpmutil.Command = 0x00000188
+	ccPolicyNvWritten         tpmutil.Command = 0x0000018F
+	ccPolicyTemplate          tpmutil.Command = 0x00000190
+	ccPolicyAuthorizeNV       tpmutil.Command = 0x00000192
+)
+
+// The number of PCRs in a PCR selection.
+const maxPCR = 24
+
+// The number of branches TPM2_PolicyOR accepts.
+const (
+	minORBranches = 2
+	maxORBranches = 8
+)
+
+// A Policy is the digest of a sequence of policy commands, computed as a TPM
+// would in a trial policy session. Its methods extend the digest with a
+// policy command and return the Policy, so they can be chained. The first
+// error (such as invalid arguments) is returned by Digest, and later commands
+// are ignored.
+type Policy struct {
+	hash   crypto.Hash
+	digest []byte
+	err    error
+}
+
+// New returns an empty Policy using the given hash algorithm, which must be
+// the name algorithm of the objects using the policy. Its digest is all zeros.
+func New(hash crypto.Hash) *Policy {
+	p := &Policy{hash: hash}
+	if !hash.Available() {
+		p.err = fmt.Errorf("hash algorithm %v is not available", hash)
+		return p
+	}
+	p.digest = make([]byte, hash.Size())
+	return p
+}
+
+// From returns a Policy continuing from an existing policy digest, such as the
+// authPolicy of an object, using the given hash algorithm.
+func From(hash crypto.Hash, digest []byte) *Policy {
+	p := New(hash)
+	if p.err == nil && len(digest) != hash.Size() {
+		p.err = fmt.Errorf("policy digest is %d bytes, want %d for %v", len(digest), hash.Size(), hash)
+	}
+	if p.err == nil {
+		p.digest = append([]byte(nil), digest...)
+	}
+	return p
+}
+
+// Digest returns the policy digest, or the first error in computing it.
+func (p *Policy) Digest() ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return append([]byte(nil), p.digest...), nil
+}
+
+// Hash returns the Policy's hash algorithm.
+func (p *Policy) Hash() crypto.Hash {
+	return p.hash
+}
+
+// extend sets the digest to H(digest || cc || args...), as most policy
+// commands do.
+func (p *Policy) extend(cc tpmutil.Command, args ...[]byte) *Policy {
+	if p.err != nil {
+		return p
+	}
+	h := p.hash.New()
+	h.Write(p.digest)
+	binary.Write(h, binary.BigEndian, cc)
+	for _, arg := range args {
+		h.Write(arg)
+	}
+	p.digest = h.Sum(nil)
+	return p
+}
+
+// update extends the digest as PolicyUpdate() in Part 3 of the spec, Section
+// 23.2.3, does for commands taking a policyRef: with the Name, then with the
+// policyRef.
+func (p *Policy) update(cc tpmutil.Command, name, policyRef []byte) *Policy {
+	return p.extend(cc, name).extend0(policyRef)
+}
+
+// extend0 sets the digest to H(digest || data).
+func (p *Policy) extend0(data []byte) *Policy {
+	if p.err != nil {
+		return p
+	}
+	h := p.hash.New()
+	h.Write(p.digest)
+	h.Write(data)
+	p.digest = h.Sum(nil)
+	return p
+}
+
+func (p *Policy) reset() {
+	if p.err == nil {
+		p.digest = make([]byte, p.hash.Size())
+	}
+}
+
+func (p *Policy) fail(err error) *Policy {
+	if p.err == nil {
+		p.err = err
+	}
+	return p
+}
+
+// PCR adds TPM2_PolicyPCR, requiring the selected PCRs to have values whose
+// digest (with the Policy's hash algorithm) is pcrDigest.
+func (p *Policy) PCR(sel tpm2.PCRSelection, pcrDigest []byte) *Policy {
+	encoded, err := encodePCRSelection(sel)
+	if err != nil {
+		return p.fail(err)
+	}
+	return p.extend(tpm2.CmdPolicyPCR, encoded, pcrDigest)
+}
+
+// PCRValues adds TPM2_PolicyPCR, requiring the PCRs to have the given values.
+func (p *Policy) PCRValues(pcrs *tpmpb.PCRs) *Policy {
+	sel := tpm2.PCRSelection{Hash: tpm2.Algorithm(pcrs.GetHash())}
+	for pcr := range pcrs.GetPcrs() {
+		sel.PCRs = append(sel.PCRs, int(pcr))
+	}
+	if p.err != nil {
+		return p
+	}
+	return p.PCR(sel, PCRDigest(pcrs, p.hash))
+}
+
+// PCRDigest computes the digest of PCR values as TPM2_PolicyPCR and
+// TPM2_Quote do, in order of PCR number. The hash algorithm may differ from
+// the PCR bank.
+func PCRDigest(pcrs *tpmpb.PCRs, hash crypto.Hash) []byte {
+	h := hash.New()
+	for i := uint32(0); i < maxPCR; i++ {
+		if value, ok := pcrs.GetPcrs()[i]; ok {
+			h.Write(value)
+		}
+	}
+	return h.Sum(nil)
+}
+
+// encodePCRSelection encodes a PCR selection as a TPML_PCR_SELECTION, with a
+// single bank of 24 PCRs.
+func encodePCRSelection(sel tpm2.PCRSelection) ([]byte, error) {
+	bits := make([]byte, maxPCR/8)
+	for _, pcr := range sel.PCRs {
+		if pcr < 0 || pcr >= maxPCR {
+			return nil, fmt.Errorf("invalid PCR %d", pcr)
+		}
+		bits[pcr/8] |= 1 << uint(pcr%8)
+	}
+	return tpmutil.Pack(uint32(1), sel.Hash, byte(len(bits)), tpmutil.RawBytes(bits))
+}
+
+// CommandCode adds TPM2_PolicyCommandCode, limiting the policy to authorizing
+// the given command.
+func (p *Policy) CommandCode(cc tpmutil.Command) *Policy {
+	encoded, _ := tpmutil.Pack(cc)
+	return p.extend(tpm2.CmdPolicyCommandCode, encoded)
+}
+
+// AuthValue adds TPM2_PolicyAuthValue, requiring the object's authValue to be
+// used in an HMAC.
+func (p *Policy) AuthValue() *Policy {
+	return p.extend(ccPolicyAuthValue)
+}
+
+// Password adds TPM2_PolicyPassword, requiring the object's authValue to be
+// provided in the clear. It has the same digest as AuthValue.
+func (p *Policy) Password() *Policy {
+	return p.extend(ccPolicyAuthValue)
+}
+
+// PhysicalPresence adds TPM2_PolicyPhysicalPresence.
+func (p *Policy) PhysicalPresence() *Policy {
+	return p.extend(ccPolicyPhysicalPresence)
+}
+
+// Locality adds TPM2_PolicyLocality, limiting the policy to the localities
+// in the TPMA_LOCALITY bitmask (e.g. 1 for locality 0, or values above 31
+// for extended localities).
+func (p *Policy) Locality(localities uint8) *Policy {
+	return p.extend(ccPolicyLocality, []byte{localities})
+}
+
+// CpHash adds TPM2_PolicyCpHash, limiting the policy to a single command
+// with the given command parameter hash.
+func (p *Policy) CpHash(cpHash []byte) *Policy {
+	return p.extend(ccPolicyCpHash, cpHash)
+}
+
+// NameHash adds TPM2_PolicyNameHash, limiting the policy to commands on the
+// objects whose Names have the given digest.
+func (p *Policy) NameHash(nameHash []byte) *Policy {
+	return p.extend(ccPolicyNameHash, nameHash)
+}
+
+// Template adds TPM2_PolicyTemplate, limiting the policy to creating objects
+// whose public area has the given digest.
+func (p *Policy) Template(templateHash []byte) *Policy {
+	return p.extend(ccPolicyTemplate, templateHash)
+}
+
+// NvWritten adds TPM2_PolicyNvWritten, requiring an NV index to have been
+// written (or not).
+func (p *Policy) NvWritten(written bool) *Policy {
+	if written {
+		return p.extend(ccPolicyNvWritten, []byte{1})
+	}
+	return p.extend(ccPolicyNvWritten, []byte{0})
+}
+
+// Secret adds TPM2_PolicySecret, requiring authorization with the authValue
+// (or policy) of the entity with the given Name, such as a hierarchy (see
+// HandleName).
+func (p *Policy) Secret(authName, policyRef []byte) *Policy {
+	return p.update(tpm2.CmdPolicySecret, authName, policyRef)
+}
+
+// Signed adds TPM2_PolicySigned, requiring authorization signed by the key
+// with the given Name.
+func (p *Policy) Signed(keyName, policyRef []byte) *Policy {
+	return p.update(ccPolicySigned, keyName, policyRef)
+}
+
+// Authorize adds TPM2_PolicyAuthorize, allowing any policy approved by the
+// key with the given Name (for the policyRef) to replace the commands so far.
+// The digest is reset before the approval is added, so the commands so far
+// only make up the approved policy.
+func (p *Policy) Authorize(keyName, policyRef []byte) *Policy {
+	p.reset()
+	return p.update(ccPolicyAuthorize, keyName, policyRef)
+}
+
+// AuthorizeNV adds TPM2_PolicyAuthorizeNV, allowing the policy stored in the
+// NV index with the given Name to replace the commands so far. As with
+// Authorize, the digest is reset first.
+func (p *Policy) AuthorizeNV(nvName []byte) *Policy {
+	p.reset()
+	return p.extend(ccPolicyAuthorizeNV, nvName)
+}
+
+// NV adds TPM2_PolicyNV, requiring the contents of the NV index with the given
+// Name (starting at offset) to compare to operandB according to operation, a
+// TPM_EO value.
+func (p *Policy) NV(nvName, operandB []byte, offset uint16, operation uint16) *Policy {
+	return p.extend(ccPolicyNV, p.argsHash(operandB, offset, operation), nvName)
+}
+
+// CounterTimer adds TPM2_PolicyCounterTimer, requiring the TPMS_TIME_INFO of
+// the TPM (starting at offset) to compare to operandB according to operation.
+func (p *Policy) CounterTimer(operandB []byte, offset uint16, operation uint16) *Policy {
+	return p.extend(ccPolicyCounterTimer, p.argsHash(operandB, offset, operation))
+}
+
+// argsHash is the hash of the comparison arguments of TPM2_PolicyNV and
+// TPM2_PolicyCounterTimer.
+func (p *Policy) argsHash(operandB []byte, offset uint16, operation uint16) []byte {
+	if p.err != nil {
+		return nil
+	}
+	h := p.hash.New()
+	h.Write(operandB)
+	binary.Write(h, binary.BigEndian, offset)
+	binary.Write(h, binary.BigEndian, operation)
+	return h.Sum(nil)
+}
+
+// DuplicationSelect adds TPM2_PolicyDuplicationSelect, only allowing an
+// object to be duplicated to the new parent with the given Name. If
+// includeObject is false, the objectName is not part of the policy, so the
+// policy can be in the object's own authPolicy.
+func (p *Policy) DuplicationSelect(objectName, newParentName []byte, includeObject bool) *Policy {
+	if includeObject {
+		return p.extend(ccPolicyDuplicationSelect, objectName, newParentName, []byte{1})
+	}
+	return p.extend(ccPolicyDuplicationSelect, newParentName, []byte{0})
+}
+
+// OR adds TPM2_PolicyOR, allowing any of the branches to be satisfied
+// instead. The digest is reset first, so the commands so far must be the
+// start of every branch (usually, there are none). There must be between 2
+// and 8 branches, using the Policy's hash algorithm; larger trees are built
+// by using the OR of up to 8 branches as a branch.
+func (p *Policy) OR(branches ...*Policy) *Policy {
+	digests := make([][]byte, len(branches))
+	for i, branch := range branches {
+		if branch.hash != p.hash {
+			return p.fail(fmt.Errorf("branch %d uses hash algorithm %v, not %v", i, branch.hash, p.hash))
+		}
+		digest, err := branch.Digest()
+		if err != nil {
+			return p.fail(fmt.Errorf("branch %d: %w", i, err))
+		}
+		digests[i] = digest
+	}
+	return p.ORDigests(digests...)
+}
+
+// ORDigests adds TPM2_PolicyOR of branches given by their digests, as OR.
+func (p *Policy) ORDigests(digests ...[]byte) *Policy {
+	if len(digests) < minORBranches || len(digests) > maxORBranches {
+		return p.fail(fmt.Errorf("PolicyOR takes between %d and %d branches, got %d", minORBranches, maxORBranches, len(digests)))
+	}
+	for i, digest := range digests {
+		if p.err == nil && len(digest) != p.hash.Size() {
+			return p.fail(fmt.Errorf("branch %d digest is %d bytes, want %d", i, len(digest), p.hash.Size()))
+		}
+	}
+	p.reset()
+	return p.extend(tpm2.CmdPolicyOr, digests...)
+}
@@ -0,0 +1,421 @@
+package policy_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm-tools/policy"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// trialDigest runs policy commands in a trial session, returning its digest.
+func trialDigest(t *testing.T, rw io.ReadWriter, run func(session tpmutil.Handle) error) []byte {
+	t.Helper()
+	session, _, err := tpm2.StartAuthSession(rw, tpm2.HandleNull, tpm2.HandleNull,
+		make([]byte, sha256.Size), nil, tpm2.SessionTrial, tpm2.AlgNull, tpm2.AlgSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpm2.FlushContext(rw, session)
+	if err := run(session); err != nil {
+		t.Fatalf("policy command failed: %v", err)
+	}
+	digest, err := tpm2.PolicyGetDigest(rw, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
+
+// runPolicyCommand runs a policy command which go-tpm does not implement.
+func runPolicyCommand(rw io.ReadWriter, cc tpmutil.Command, session tpmutil.Handle, params ...interface{}) error {
+	_, err := internal.RunCommand(rw, cc, []tpmutil.Handle{session}, nil, params...)
+	return err
+}
+
+func mustDigest(t *testing.T, p *policy.Policy) []byte {
+	t.Helper()
+	digest, err := p.Digest()
+	if err != nil {
+		t.Fatalf("Digest() failed: %v", err)
+	}
+	return digest
+}
+
+func TestPolicyMatchesTPM(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+	keyName := srk.Name().Digest
+	encodedKeyName, err := keyName.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcrs, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA1, PCRs: []int{0, 4, 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA1, PCRs: []int{0, 4, 7}}
+	pcrDigest := policy.PCRDigest(pcrs, crypto.SHA256)
+	ref := []byte("policy-ref")
+	digest32 := bytes.Repeat([]byte{0xab}, sha256.Size)
+
+	tests := []struct {
+		name   string
+		policy *policy.Policy
+		run    func(session tpmutil.Handle) error
+	}{
+		{"PCR", policy.New(crypto.SHA256).PCR(sel, pcrDigest), func(s tpmutil.Handle) error {
+			return tpm2.PolicyPCR(rwc, s, pcrDigest, sel)
+		}},
+		{"PCRValues", policy.New(crypto.SHA256).PCRValues(pcrs), func(s tpmutil.Handle) error {
+			return tpm2.PolicyPCR(rwc, s, pcrDigest, sel)
+		}},
+		{"CommandCode", policy.New(crypto.SHA256).CommandCode(tpm2.CmdUnseal), func(s tpmutil.Handle) error {
+			return tpm2.PolicyCommandCode(rwc, s, tpm2.CmdUnseal)
+		}},
+		{"Password", policy.New(crypto.SHA256).Password(), func(s tpmutil.Handle) error {
+			return tpm2.PolicyPassword(rwc, s)
+		}},
+		{"AuthValue", policy.New(crypto.SHA256).AuthValue(), func(s tpmutil.Handle) error {
+			return runPolicyCommand(rwc, 0x16B, s)
+		}},
+		{"Locality", policy.New(crypto.SHA256).Locality(0x3), func(s tpmutil.Handle) error {
+			return runPolicyCommand(rwc, 0x16F, s, byte(0x3))
+		}},
+		{"NameHash", policy.New(crypto.SHA256).NameHash(digest32), func(s tpmutil.Handle) error {
+			return runPolicyCommand(rwc, 0x170, s, tpmutil.U16Bytes(digest32))
+		}},
+		{"NvWritten", policy.New(crypto.SHA256).NvWritten(true), func(s tpmutil.Handle) error {
+			return runPolicyCommand(rwc, 0x18F, s, byte(1))
+		}},
+		{"Secret", policy.New(crypto.SHA256).Secret(policy.HandleName(tpm2.HandleEndorsement), ref), func(s tpmutil.Handle) error {
+			_, err := tpm2.PolicySecret(rwc, tpm2.HandleEndorsement, tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}, s, nil, nil, ref, 0)
+			return err
+		}},
+		{"CounterTimer", policy.New(crypto.SHA256).CounterTimer([]byte{0, 0, 0, 1}, 8, 2), func(s tpmutil.Handle) error {
+			// TPM_EO_UNSIGNED_GT on the low half of the clock.
+			return runPolicyCommand(rwc, 0x16D, s, tpmutil.U16Bytes([]byte{0, 0, 0, 1}), uint16(8), uint16(2))
+		}},
+		{"Authorize", policy.New(crypto.SHA256).CommandCode(tpm2.CmdUnseal).Authorize(encodedKeyName, ref), func(s tpmutil.Handle) error {
+			if err := tpm2.PolicyCommandCode(rwc, s, tpm2.CmdUnseal); err != nil {
+				return err
+			}
+			// In a trial session, the ticket is not checked.
+			nullTicket := tpm2.Ticket{Type: 0x8022 /* TPM_ST_VERIFIED */, Hierarchy: tpm2.HandleNull}
+			return runPolicyCommand(rwc, 0x16A, s, tpmutil.U16Bytes(digest32), tpmutil.U16Bytes(ref), tpmutil.U16Bytes(encodedKeyName), nullTicket)
+		}},
+		{"Chained", policy.New(crypto.SHA256).PCR(sel, pcrDigest).AuthValue().CommandCode(tpm2.CmdUnseal), func(s tpmutil.Handle) error {
+			if err := tpm2.PolicyPCR(rwc, s, pcrDigest, sel); err != nil {
+				return err
+			}
+			if err := runPolicyCommand(rwc, 0x16B, s); err != nil {
+				return err
+			}
+			return tpm2.PolicyCommandCode(rwc, s, tpm2.CmdUnseal)
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := mustDigest(t, tc.policy)
+			if want := trialDigest(t, rwc, tc.run); !bytes.Equal(got, want) {
+				t.Errorf("digest is %x, TPM computed %x", got, want)
+			}
+		})
+	}
+
+	t.Run("OR", func(t *testing.T) {
+		a := policy.New(crypto.SHA256).CommandCode(tpm2.CmdUnseal)
+		b := policy.New(crypto.SHA256).PCR(sel, pcrDigest)
+		got := mustDigest(t, policy.New(crypto.SHA256).OR(a, b))
+		want := trialDigest(t, rwc, func(s tpmutil.Handle) error {
+			if err := tpm2.PolicyCommandCode(rwc, s, tpm2.CmdUnseal); err != nil {
+				return err
+			}
+			return tpm2.PolicyOr(rwc, s, tpm2.TPMLDigest{Digests: []tpmutil.U16Bytes{mustDigest(t, a), mustDigest(t, b)}})
+		})
+		if !bytes.Equal(got, want) {
+			t.Errorf("digest is %x, TPM computed %x", got, want)
+		}
+	})
+}
+
+func TestEKPolicy(t *testing.T) {
+	// The authPolicy of the default EK templates, from the TCG EK Credential
+	// Profile, Section B.3.2.
+	want, _ := hex.DecodeString("837197674484b3f81a90cc8d46a5d724fd52d76e06520b64f2a1da1b331469aa")
+	got := mustDigest(t, policy.New(crypto.SHA256).Secret(policy.HandleName(tpm2.HandleEndorsement), nil))
+	if !bytes.Equal(got, want) {
+		t.Errorf("EK policy is %x, want %x", got, want)
+	}
+}
+
+func TestPolicyErrors(t *testing.T) {
+	sha1Branch := policy.New(crypto.SHA1).Password()
+	branch := policy.New(crypto.SHA256).Password()
+	tests := []struct {
+		name   string
+		policy *policy.Policy
+	}{
+		{"InvalidPCR", policy.New(crypto.SHA256).PCR(tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{24}}, nil)},
+		{"OneBranch", policy.New(crypto.SHA256).OR(branch)},
+		{"NineBranches", policy.New(crypto.SHA256).OR(branch, branch, branch, branch, branch, branch, branch, branch, branch)},
+		{"BranchHashMismatch", policy.New(crypto.SHA256).OR(branch, sha1Branch)},
+		{"BranchDigestSize", policy.New(crypto.SHA256).ORDigests(make([]byte, 32), make([]byte, 20))},
+		{"BranchError", policy.New(crypto.SHA256).OR(branch, policy.New(crypto.SHA256).OR(branch))},
+		{"UnavailableHash", policy.New(crypto.MD4).Password()},
+		{"FromWrongSize", policy.From(crypto.SHA256, make([]byte, 20))},
+		// Commands after an error are ignored.
+		{"ErrorThenCommand", policy.New(crypto.SHA256).OR(branch).Password()},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.policy.Digest(); err == nil {
+				t.Error("Digest() should fail")
+			}
+		})
+	}
+
+	start := mustDigest(t, policy.New(crypto.SHA256).PCRValues(&tpmpb.PCRs{Hash: tpmpb.HashAlgo_SHA256}))
+	if got := mustDigest(t, policy.From(crypto.SHA256, start).CommandCode(tpm2.CmdUnseal)); !bytes.Equal(got,
+		mustDigest(t, policy.New(crypto.SHA256).PCRValues(&tpmpb.PCRs{Hash: tpmpb.HashAlgo_SHA256}).CommandCode(tpm2.CmdUnseal))) {
+		t.Error("From() does not continue the policy")
+	}
+}
+
+func TestEncodeSignatureErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		sig  *tpm2.Signature
+	}{
+		{"Nil", nil},
+		{"MissingRSA", &tpm2.Signature{Alg: tpm2.AlgRSASSA}},
+		{"MissingECC", &tpm2.Signature{Alg: tpm2.AlgECDSA}},
+		{"Unsupported", &tpm2.Signature{Alg: tpm2.AlgHMAC}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := policy.EncodeSignature(tc.sig); err == nil {
+				t.Error("EncodeSignature() should fail")
+			}
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	for _, template := range []tpm2.Public{client.SRKTemplateRSA(), client.AKTemplateECC()} {
+		key, err := client.NewKey(rwc, tpm2.HandleOwner, template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, want, _, err := tpm2.ReadPublic(rwc, key.Handle())
+		key.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := policy.ObjectName(key.PublicArea())
+		if err != nil {
+			t.Fatalf("ObjectName() failed: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("ObjectName() = %x, TPM returned %x", got, want)
+		}
+	}
+
+	const index = tpmutil.Handle(0x01500042)
+	attrs := tpm2.AttrOwnerWrite | tpm2.AttrOwnerRead | tpm2.AttrAuthRead
+	if err := tpm2.NVDefineSpace(rwc, tpm2.HandleOwner, index, "", "", nil, attrs, 8); err != nil {
+		t.Fatal(err)
+	}
+	defer tpm2.NVUndefineSpace(rwc, "", tpm2.HandleOwner, index)
+	for _, write := range []bool{false, true} {
+		if write {
+			if err := tpm2.NVWrite(rwc, tpm2.HandleOwner, index, "", make([]byte, 8), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		resp, err := internal.RunCommand(rwc, tpm2.CmdReadPublicNV, []tpmutil.Handle{index}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var encodedPub, want tpmutil.U16Bytes
+		if _, err := tpmutil.Unpack(resp, &encodedPub, &want); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := tpm2.NVReadPublic(rwc, index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := policy.NVName(pub)
+		if err != nil {
+			t.Fatalf("NVName() failed: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("NVName() after write=%v = %x, TPM returned %x", write, got, []byte(want))
+		}
+	}
+
+	if got, want := policy.HandleName(tpm2.HandleEndorsement), []byte{0x40, 0x00, 0x00, 0x0b}; !bytes.Equal(got, want) {
+		t.Errorf("HandleName() = %x, want %x", got, want)
+	}
+	pub := client.SRKTemplateRSA()
+	pub.NameAlg = tpm2.AlgNull
+	if _, err := policy.ObjectName(pub); err == nil {
+		t.Error("ObjectName() without a name algorithm should fail")
+	}
+}
+
+func TestSignedAuthorization(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := policy.SignedAuthorization{
+		NonceTPM:   bytes.Repeat([]byte{0x11}, 32),
+		Expiration: -60,
+		PolicyRef:  []byte("quote"),
+	}
+
+	// aHash = H(nonceTPM || expiration || cpHashA || policyRef)
+	h := sha256.New()
+	h.Write(auth.NonceTPM)
+	h.Write([]byte{0xff, 0xff, 0xff, 0xc4})
+	h.Write(auth.PolicyRef)
+	digest := h.Sum(nil)
+	if got := auth.Digest(crypto.SHA256); !bytes.Equal(got, digest) {
+		t.Errorf("Digest() = %x, want %x", got, digest)
+	}
+
+	for _, signer := range []crypto.Signer{rsaKey, ecdsaKey} {
+		t.Run(fmt.Sprintf("%T", signer), func(t *testing.T) {
+			public, err := policy.AuthorizingKey(signer.Public(), crypto.SHA256)
+			if err != nil {
+				t.Fatalf("AuthorizingKey() failed: %v", err)
+			}
+			pub, err := public.Key()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(signer.Public()) {
+				t.Error("AuthorizingKey() has a different public key")
+			}
+
+			sig, err := auth.Sign(signer, crypto.SHA256)
+			if err != nil {
+				t.Fatalf("Sign() failed: %v", err)
+			}
+			switch pub := signer.Public().(type) {
+			case *rsa.PublicKey:
+				if sig.Alg != tpm2.AlgRSASSA || rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig.RSA.Signature) != nil {
+					t.Error("Sign() returned an invalid RSASSA signature")
+				}
+			case *ecdsa.PublicKey:
+				if sig.Alg != tpm2.AlgECDSA || !ecdsa.Verify(pub, digest, sig.ECC.R, sig.ECC.S) {
+					t.Error("Sign() returned an invalid ECDSA signature")
+				}
+			}
+		})
+	}
+}
+
+func TestSignPCRPolicy(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The name algorithm of an authority need not be its signing hash.
+	sha256Name, err := policy.AuthorizingKey(p384.Public(), crypto.SHA384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha256Name.NameAlg = tpm2.AlgSHA256
+
+	pcrs := &tpmpb.PCRs{Hash: tpmpb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{7: make([]byte, sha256.Size)}}
+	ref := []byte("disk")
+	approved := mustDigest(t, policy.New(crypto.SHA256).PCRValues(pcrs))
+	subtests := []struct {
+		name      string
+		authority *ecdsa.PrivateKey
+		public    tpm2.Public
+		nameAlg   crypto.Hash
+	}{
+		{"P256", p256, mustAuthorizingKey(t, p256, crypto.SHA256), crypto.SHA256},
+		{"P384", p384, mustAuthorizingKey(t, p384, crypto.SHA384), crypto.SHA384},
+		{"P384WithSHA256Name", p384, sha256Name, crypto.SHA256},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			signed, err := policy.SignPCRPolicy(subtest.authority, subtest.public, pcrs, ref)
+			if err != nil {
+				t.Fatalf("SignPCRPolicy() failed: %v", err)
+			}
+			if signed.GetPcrs() != pcrs {
+				t.Error("SignPCRPolicy() did not record the PCRs")
+			}
+			sig, err := tpm2.DecodeSignature(bytes.NewBuffer(signed.GetSignature()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			digest := policy.ApprovalDigest(subtest.nameAlg, approved, ref)
+			if sig.Alg != tpm2.AlgECDSA || !ecdsa.Verify(&subtest.authority.PublicKey, digest, sig.ECC.R, sig.ECC.S) {
+				t.Error("SignPCRPolicy() did not sign aHash computed with the authority's name algorithm")
+			}
+		})
+	}
+
+	if _, err := policy.SignPCRPolicy(p256, subtests[1].public, pcrs, ref); err == nil {
+		t.Error("SignPCRPolicy() with a signer which is not the authority should fail")
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaAuthority := mustAuthorizingKey(t, rsaKey, crypto.SHA384)
+	rsaAuthority.NameAlg = tpm2.AlgSHA256
+	if _, err := policy.SignPCRPolicy(rsaKey, rsaAuthority, pcrs, ref); err == nil {
+		t.Error("SignPCRPolicy() with an RSA authority whose name algorithm is not its signing hash should fail")
+	}
+}
+
+func mustAuthorizingKey(t *testing.T, signer crypto.Signer, hash crypto.Hash) tpm2.Public {
+	t.Helper()
+	public, err := policy.AuthorizingKey(signer.Public(), hash)
+	if err != nil {
+		t.Fatalf("AuthorizingKey() failed: %v", err)
+	}
+	if alg, _ := tpm2.HashToAlgorithm(hash); public.NameAlg != alg {
+		t.Errorf("AuthorizingKey() has name algorithm %v, want %v", public.NameAlg, alg)
+	}
+	return public
+}
@@ -0,0 +1,273 @@
+// Package predict computes the PCR values a machine will have after its next
+// boot, so that secrets can be resealed to them before an upgrade reboots the
+// machine into a new kernel or bootloader configuration.
+//
+// A Boot is the sequence of events measured during a boot. It usually starts
+// as the current boot, read from the machine's event log with FromEventLog,
+// whose events are then replaced by those the upgrade will change:
+//
+//	rawLog, err := client.GetEventLog(rwc)
+//	pcrs, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{4, 8, 9}})
+//	boot, err := predict.FromEventLog(rawLog, pcrs)
+//	_, err = boot.ReplaceFile("/boot/vmlinuz", newKernel)
+//	_, err = boot.ReplaceFile("/boot/grub/grub.cfg", newGrubCfg)
+//	err = boot.ReplaceGrubCommands(newCommands)
+//	predicted, err := boot.PCRs()
+//	sealed, err := srk.Seal(secret, client.SealOpts{Target: predicted})
+//
+// Data sealed to the predicted values cannot be unsealed until the reboot, so
+// the data sealed to the current values should be kept until then. If the
+// prediction is made elsewhere, such as by the server building the upgrade,
+// only the digest of the predicted values (see policy.PCRDigest) needs to be
+// sent to the machine, which seals to it with client.SealOpts.TargetDigest.
+package predict
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"strings"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm-tools/server"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// Event types and PCRs used by GRUB, from the TCG PC Client Platform Firmware
+// Profile Specification, Table 14 Events.
+const (
+	ipl            uint32 = 0x0000000D
+	grubCommandPCR        = 8
+	grubFilePCR           = 9
+)
+
+// The localities TPM2_Startup() can be issued from, which is the last byte of
+// the initial value of PCR0. Locality 4 is used by an H-CRTM.
+var startupLocalities = []byte{0, 3, 4}
+
+// Boot is the sequence of events measured into a bank of PCRs during a boot.
+type Boot struct {
+	// The PCR bank, and the PCRs whose events are in Events. The PCRs which
+	// have no events keep their reset value.
+	Selection tpm2.PCRSelection
+	// The locality TPM2_Startup() is issued from, which is the last byte of
+	// the initial value of PCR0.
+	StartupLocality byte
+	// The events extended into the PCRs, in order. The value of each PCR
+	// only depends on the order of its own events.
+	Events []*pb.Event
+}
+
+// FromEventLog returns the boot measured by a raw TCG event log, which must
+// replay to the provided PCR values (see server.ReplayEventLog). The boot has
+// the events of those PCRs. As with server.ParseMachineState, it is the
+// caller's responsibility to ensure the PCR values can be trusted, such as by
+// reading them from the TPM of the machine which will reboot.
+func FromEventLog(rawLog []byte, pcrs *tpmpb.PCRs) (*Boot, error) {
+	boot := &Boot{Selection: tpm2.PCRSelection{Hash: tpm2.Algorithm(pcrs.GetHash())}}
+	for pcr := range pcrs.GetPcrs() {
+		boot.Selection.PCRs = append(boot.Selection.PCRs, int(pcr))
+	}
+	err := server.ReplayEventLog(bytes.NewReader(rawLog), pcrs, func(event *pb.Event) error {
+		boot.Events = append(boot.Events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The StartupLocality event is not extended, so the locality is found
+	// from the replayed value of PCR0.
+	pcr0, ok := pcrs.GetPcrs()[0]
+	if !ok {
+		return boot, nil
+	}
+	for _, locality := range startupLocalities {
+		boot.StartupLocality = locality
+		predicted, err := boot.PCRs()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(predicted.GetPcrs()[0], pcr0) {
+			return boot, nil
+		}
+	}
+	return nil, fmt.Errorf("PCR0 does not replay from any startup locality")
+}
+
+// PCRs computes the values of the PCRs in the Selection after the events of
+// the boot are extended into them, starting from their reset values.
+func (b *Boot) PCRs() (*tpmpb.PCRs, error) {
+	hash, err := b.hash()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[uint32][]byte, len(b.Selection.PCRs))
+	for _, pcr := range b.Selection.PCRs {
+		values[uint32(pcr)] = make([]byte, hash.Size())
+	}
+	if value, ok := values[0]; ok {
+		value[len(value)-1] = b.StartupLocality
+	}
+	h := hash.New()
+	for i, event := range b.Events {
+		value, ok := values[event.GetPcrIndex()]
+		if !ok {
+			return nil, fmt.Errorf("event %d extends PCR%d, which is not selected", i, event.GetPcrIndex())
+		}
+		if len(event.GetDigest()) != hash.Size() {
+			return nil, fmt.Errorf("event %d has a digest of length %d, expected %d for %v", i, len(event.GetDigest()), hash.Size(), hash)
+		}
+		h.Reset()
+		h.Write(value)
+		h.Write(event.GetDigest())
+		values[event.GetPcrIndex()] = h.Sum(nil)
+	}
+	return &tpmpb.PCRs{Hash: tpmpb.HashAlgo(b.Selection.Hash), Pcrs: values}, nil
+}
+
+// Replace replaces the events for which match returns true: they are removed,
+// and the provided events are inserted in place of the first of them. It
+// returns the number of events removed; if it is zero, nothing is inserted.
+func (b *Boot) Replace(match func(*pb.Event) bool, events ...*pb.Event) int {
+	var kept []*pb.Event
+	removed := 0
+	for _, event := range b.Events {
+		if !match(event) {
+			kept = append(kept, event)
+			continue
+		}
+		if removed == 0 {
+			kept = append(kept, events...)
+		}
+		removed++
+	}
+	b.Events = kept
+	return removed
+}
+
+// ReplaceDigest sets the digest of the events for which match returns true,
+// such as the EV_EFI_BOOT_SERVICES_APPLICATION event (see
+// server.EFIBootServicesApplication) of a kernel loaded by the firmware, whose
+// digest is the Authenticode digest of the new kernel. It returns the number
+// of events changed.
+func (b *Boot) ReplaceDigest(match func(*pb.Event) bool, digest []byte) int {
+	changed := 0
+	for i, event := range b.Events {
+		if match(event) {
+			b.Events[i] = &pb.Event{
+				PcrIndex:      event.GetPcrIndex(),
+				UntrustedType: event.GetUntrustedType(),
+				Data:          event.GetData(),
+				Digest:        digest,
+			}
+			changed++
+		}
+	}
+	return changed
+}
+
+// ReplaceFile sets the contents of a file read by GRUB, such as a kernel
+// image, initrd or grub.cfg. GRUB measures each file it reads into PCR9 as an
+// EV_IPL event, with the file's path as the event data (Fedora and RHEL GRUB
+// use "grub_linuxefi Kernel" and "grub_linuxefi Initrd" for the kernel and
+// initrd instead). It returns the number of events changed.
+func (b *Boot) ReplaceFile(path string, contents []byte) (int, error) {
+	hash, err := b.hash()
+	if err != nil {
+		return 0, err
+	}
+	h := hash.New()
+	h.Write(contents)
+	return b.ReplaceDigest(func(event *pb.Event) bool {
+		return isGrubEvent(event, grubFilePCR) && string(bytes.TrimRight(event.GetData(), "\x00")) == path
+	}, h.Sum(nil)), nil
+}
+
+// ReplaceGrubCommands replaces the commands GRUB measures into PCR8 as EV_IPL
+// events, such as after its grub.cfg is changed. Each command is the event
+// data GRUB will log, as found in the current event log: a tag, followed by
+// ": " (for upstream GRUB) or " " (for Fedora and RHEL GRUB), followed by the
+// measured string, such as
+//
+//	grub_cmd: linux /vmlinuz-6.1.0 root=/dev/sda1 ro
+//	kernel_cmdline: /vmlinuz-6.1.0 root=/dev/sda1 ro
+//
+// Only the measured string is digested. Fedora and RHEL GRUB also digest the
+// NUL terminator of commands (but not of command lines), so each new string is
+// digested the way the replaced ones with the same tag were. The boot must
+// have GRUB commands to replace.
+func (b *Boot) ReplaceGrubCommands(commands []string) error {
+	hash, err := b.hash()
+	if err != nil {
+		return err
+	}
+	// The terminators of each tag, and of the first command for new tags.
+	terminators := make(map[string][]byte)
+	var defaultTerminator []byte
+	for _, event := range b.Events {
+		if !isGrubEvent(event, grubCommandPCR) {
+			continue
+		}
+		tag, measured := splitGrubEvent(string(bytes.TrimRight(event.GetData(), "\x00")))
+		if _, ok := terminators[tag]; ok {
+			continue
+		}
+		terminators[tag] = nil
+		if bytes.Equal(event.GetDigest(), digest(hash, measured, []byte{0})) {
+			terminators[tag] = []byte{0}
+		}
+		if len(terminators) == 1 {
+			defaultTerminator = terminators[tag]
+		}
+	}
+	if len(terminators) == 0 {
+		return fmt.Errorf("no GRUB commands to replace")
+	}
+
+	events := make([]*pb.Event, len(commands))
+	for i, command := range commands {
+		tag, measured := splitGrubEvent(command)
+		terminator, ok := terminators[tag]
+		if !ok {
+			terminator = defaultTerminator
+		}
+		events[i] = &pb.Event{
+			PcrIndex:      grubCommandPCR,
+			UntrustedType: ipl,
+			Data:          []byte(command),
+			Digest:        digest(hash, measured, terminator),
+		}
+	}
+	b.Replace(func(event *pb.Event) bool { return isGrubEvent(event, grubCommandPCR) }, events...)
+	return nil
+}
+
+func (b *Boot) hash() (crypto.Hash, error) {
+	hash, err := b.Selection.Hash.Hash()
+	if err != nil {
+		return 0, fmt.Errorf("invalid PCR bank: %w", err)
+	}
+	return hash, nil
+}
+
+func isGrubEvent(event *pb.Event, pcr uint32) bool {
+	return event.GetPcrIndex() == pcr && event.GetUntrustedType() == ipl
+}
+
+// splitGrubEvent splits the data of a PCR8 event into its tag and the string
+// measured by GRUB, which follows the first ": " or " ".
+func splitGrubEvent(data string) (tag, measured string) {
+	i := strings.IndexByte(data, ' ')
+	if i < 0 {
+		return data, ""
+	}
+	return strings.TrimSuffix(data[:i], ":"), data[i+1:]
+}
+
+func digest(hash crypto.Hash, s string, terminator []byte) []byte {
+	h := hash.New()
+	h.Write([]byte(s))
+	h.Write(terminator)
+	return h.Sum(nil)
+}
@@ -0,0 +1,192 @@
+package predict
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFromEventLog(t *testing.T) {
+	for _, platform := range test.Platforms {
+		for _, bank := range platform.Banks {
+			t.Run(platform.Name+"/"+bank.GetHash().String(), func(t *testing.T) {
+				boot, err := FromEventLog(platform.RawLog, bank)
+				if err != nil {
+					t.Fatalf("FromEventLog() failed: %v", err)
+				}
+				predicted, err := boot.PCRs()
+				if err != nil {
+					t.Fatalf("PCRs() failed: %v", err)
+				}
+				if !proto.Equal(predicted, bank) {
+					t.Errorf("got PCRs %v, want the replayed %v", predicted, bank)
+				}
+			})
+		}
+	}
+
+	bank := proto.Clone(test.UbuntuAmdSevGCE.Banks[1]).(*tpmpb.PCRs)
+	bank.Pcrs[8] = make([]byte, sha256.Size)
+	if _, err := FromEventLog(test.UbuntuAmdSevGCE.RawLog, bank); err == nil {
+		t.Error("FromEventLog() with PCRs the log does not replay to should fail")
+	}
+}
+
+func TestStartupLocality(t *testing.T) {
+	digest := sha256.Sum256([]byte("CRTM"))
+	boot := &Boot{
+		Selection:       tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{0}},
+		StartupLocality: 3,
+		Events:          []*pb.Event{{PcrIndex: 0, Digest: digest[:]}},
+	}
+	predicted, err := boot.PCRs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial := make([]byte, sha256.Size)
+	initial[len(initial)-1] = 3
+	want := sha256.Sum256(append(initial, digest[:]...))
+	if !bytes.Equal(predicted.GetPcrs()[0], want[:]) {
+		t.Errorf("got PCR0 %x, want %x", predicted.GetPcrs()[0], want)
+	}
+
+	for name, event := range map[string]*pb.Event{
+		"UnselectedPCR": {PcrIndex: 1, Digest: digest[:]},
+		"WrongDigest":   {PcrIndex: 0, Digest: digest[:20]},
+	} {
+		boot.Events = []*pb.Event{event}
+		if _, err := boot.PCRs(); err == nil {
+			t.Errorf("PCRs() with an event with %s should fail", name)
+		}
+	}
+}
+
+// changedPCRs returns the PCRs whose values differ.
+func changedPCRs(before, after *tpmpb.PCRs) []uint32 {
+	var changed []uint32
+	for pcr := uint32(0); pcr < 24; pcr++ {
+		if !bytes.Equal(before.GetPcrs()[pcr], after.GetPcrs()[pcr]) {
+			changed = append(changed, pcr)
+		}
+	}
+	return changed
+}
+
+func TestReplaceFile(t *testing.T) {
+	bank := test.UbuntuAmdSevGCE.Banks[1]
+	boot, err := FromEventLog(test.UbuntuAmdSevGCE.RawLog, bank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKernel := []byte("new kernel image")
+	if n, err := boot.ReplaceFile("/boot/vmlinuz-5.4.0-1046-gcp", newKernel); err != nil || n != 1 {
+		t.Fatalf("ReplaceFile() = %d, %v, want 1 event replaced", n, err)
+	}
+	predicted, err := boot.PCRs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed := changedPCRs(bank, predicted); len(changed) != 1 || changed[0] != grubFilePCR {
+		t.Errorf("replacing the kernel changed PCRs %v, want only PCR9", changed)
+	}
+
+	kernelDigest := sha256.Sum256(newKernel)
+	found := false
+	for _, event := range boot.Events {
+		if bytes.Equal(event.GetDigest(), kernelDigest[:]) {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("no event measures the new kernel")
+	}
+
+	if n, err := boot.ReplaceFile("/boot/vmlinuz-unknown", newKernel); err != nil || n != 0 {
+		t.Errorf("ReplaceFile() of an unread file = %d, %v, want no events replaced", n, err)
+	}
+}
+
+func TestReplaceGrubCommands(t *testing.T) {
+	for _, platform := range []test.Platform{test.Rhel8GCE, test.UbuntuAmdSevGCE} {
+		for _, bank := range platform.Banks {
+			t.Run(platform.Name+"/"+bank.GetHash().String(), func(t *testing.T) {
+				boot, err := FromEventLog(platform.RawLog, bank)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var commands []string
+				for _, event := range boot.Events {
+					if isGrubEvent(event, grubCommandPCR) {
+						commands = append(commands, string(bytes.TrimRight(event.GetData(), "\x00")))
+					}
+				}
+
+				// The same commands are measured as GRUB did.
+				if err := boot.ReplaceGrubCommands(commands); err != nil {
+					t.Fatalf("ReplaceGrubCommands() failed: %v", err)
+				}
+				predicted, err := boot.PCRs()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !proto.Equal(predicted, bank) {
+					t.Errorf("measuring the same commands changed PCRs %v", changedPCRs(bank, predicted))
+				}
+
+				last := len(commands) - 1
+				commands[last] += " quiet"
+				if err := boot.ReplaceGrubCommands(commands); err != nil {
+					t.Fatalf("ReplaceGrubCommands() failed: %v", err)
+				}
+				if predicted, err = boot.PCRs(); err != nil {
+					t.Fatal(err)
+				}
+				if changed := changedPCRs(bank, predicted); len(changed) != 1 || changed[0] != grubCommandPCR {
+					t.Errorf("changing a command changed PCRs %v, want only PCR8", changed)
+				}
+			})
+		}
+	}
+
+	boot := &Boot{Selection: tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{8}}}
+	if err := boot.ReplaceGrubCommands([]string{"grub_cmd: linux /vmlinuz"}); err == nil {
+		t.Error("ReplaceGrubCommands() without GRUB commands should fail")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	first := &pb.Event{PcrIndex: 4, Data: []byte("first")}
+	second := &pb.Event{PcrIndex: 4, Data: []byte("second")}
+	other := &pb.Event{PcrIndex: 7}
+	boot := &Boot{Events: []*pb.Event{other, first, other, second}}
+	replacement := &pb.Event{PcrIndex: 4, Data: []byte("replacement")}
+	if n := boot.Replace(func(event *pb.Event) bool { return event.GetPcrIndex() == 4 }, replacement); n != 2 {
+		t.Errorf("Replace() = %d, want 2 events replaced", n)
+	}
+	want := []*pb.Event{other, replacement, other}
+	if len(boot.Events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(boot.Events), len(want))
+	}
+	for i := range want {
+		if boot.Events[i] != want[i] {
+			t.Errorf("event %d: got %v, want %v", i, boot.Events[i], want[i])
+		}
+	}
+
+	if n := boot.Replace(func(event *pb.Event) bool { return false }, replacement); n != 0 || len(boot.Events) != len(want) {
+		t.Errorf("Replace() matching no events = %d, inserted %d events", n, len(boot.Events)-len(want))
+	}
+	digest := []byte("digest")
+	if n := boot.ReplaceDigest(func(event *pb.Event) bool { return event == replacement }, digest); n != 1 {
+		t.Errorf("ReplaceDigest() = %d, want 1 event changed", n)
+	}
+	if !bytes.Equal(boot.Events[1].GetDigest(), digest) || replacement.GetDigest() != nil {
+		t.Error("ReplaceDigest() should replace the event with a copy having the digest")
+	}
+}
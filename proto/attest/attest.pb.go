@@ -10,6 +10,7 @@ import (
 	tpm "github.com/google/go-tpm-tools/proto/tpm"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 )
@@ -21,6 +22,65 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Whether a TPM was cleared between two attestations (see
+// server.DetectTPMClear)
+type TpmClearVerdict int32
+
+const (
+	// There was no earlier attestation, or no indicators could be compared
+	TpmClearVerdict_TPM_CLEAR_UNKNOWN TpmClearVerdict = 0
+	TpmClearVerdict_TPM_NOT_CLEARED   TpmClearVerdict = 1
+	// The TPM was cleared, so keys and NV indexes in its owner hierarchy were
+	// lost, and its AKs and SRK were replaced
+	TpmClearVerdict_TPM_CLEARED TpmClearVerdict = 2
+	// The EK changed: the attestation comes from a different TPM, or the TPM's
+	// endorsement seed was changed
+	TpmClearVerdict_TPM_REPLACED TpmClearVerdict = 3
+)
+
+// Enum value maps for TpmClearVerdict.
+var (
+	TpmClearVerdict_name = map[int32]string{
+		0: "TPM_CLEAR_UNKNOWN",
+		1: "TPM_NOT_CLEARED",
+		2: "TPM_CLEARED",
+		3: "TPM_REPLACED",
+	}
+	TpmClearVerdict_value = map[string]int32{
+		"TPM_CLEAR_UNKNOWN": 0,
+		"TPM_NOT_CLEARED":   1,
+		"TPM_CLEARED":       2,
+		"TPM_REPLACED":      3,
+	}
+)
+
+func (x TpmClearVerdict) Enum() *TpmClearVerdict {
+	p := new(TpmClearVerdict)
+	*p = x
+	return p
+}
+
+func (x TpmClearVerdict) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TpmClearVerdict) Descriptor() protoreflect.EnumDescriptor {
+	return file_attest_proto_enumTypes[0].Descriptor()
+}
+
+func (TpmClearVerdict) Type() protoreflect.EnumType {
+	return &file_attest_proto_enumTypes[0]
+}
+
+func (x TpmClearVerdict) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TpmClearVerdict.Descriptor instead.
+func (TpmClearVerdict) EnumDescriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{0}
+}
+
 // Type of hardware technology used to protect this instance
 type GCEConfidentialTechnology int32
 
@@ -55,11 +115,11 @@ func (x GCEConfidentialTechnology) String() string {
 }
 
 func (GCEConfidentialTechnology) Descriptor() protoreflect.EnumDescriptor {
-	return file_attest_proto_enumTypes[0].Descriptor()
+	return file_attest_proto_enumTypes[1].Descriptor()
 }
 
 func (GCEConfidentialTechnology) Type() protoreflect.EnumType {
-	return &file_attest_proto_enumTypes[0]
+	return &file_attest_proto_enumTypes[1]
 }
 
 func (x GCEConfidentialTechnology) Number() protoreflect.EnumNumber {
@@ -68,7 +128,352 @@ func (x GCEConfidentialTechnology) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use GCEConfidentialTechnology.Descriptor instead.
 func (GCEConfidentialTechnology) EnumDescriptor() ([]byte, []int) {
-	return file_attest_proto_rawDescGZIP(), []int{0}
+	return file_attest_proto_rawDescGZIP(), []int{1}
+}
+
+// Whether a kernel feature which writes memory contents to disk protects that
+// data at rest
+type DataAtRestProtection int32
+
+const (
+	// The protection could not be determined from the measured boot state
+	DataAtRestProtection_PROTECTION_UNKNOWN DataAtRestProtection = 0
+	// The feature is disabled, so no memory contents are written to disk
+	DataAtRestProtection_PROTECTION_DISABLED DataAtRestProtection = 1
+	// Memory contents are only written to an encrypted device
+	DataAtRestProtection_PROTECTION_ENCRYPTED DataAtRestProtection = 2
+	// Memory contents are written to an unencrypted device
+	DataAtRestProtection_PROTECTION_UNENCRYPTED DataAtRestProtection = 3
+)
+
+// Enum value maps for DataAtRestProtection.
+var (
+	DataAtRestProtection_name = map[int32]string{
+		0: "PROTECTION_UNKNOWN",
+		1: "PROTECTION_DISABLED",
+		2: "PROTECTION_ENCRYPTED",
+		3: "PROTECTION_UNENCRYPTED",
+	}
+	DataAtRestProtection_value = map[string]int32{
+		"PROTECTION_UNKNOWN":     0,
+		"PROTECTION_DISABLED":    1,
+		"PROTECTION_ENCRYPTED":   2,
+		"PROTECTION_UNENCRYPTED": 3,
+	}
+)
+
+func (x DataAtRestProtection) Enum() *DataAtRestProtection {
+	p := new(DataAtRestProtection)
+	*p = x
+	return p
+}
+
+func (x DataAtRestProtection) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DataAtRestProtection) Descriptor() protoreflect.EnumDescriptor {
+	return file_attest_proto_enumTypes[2].Descriptor()
+}
+
+func (DataAtRestProtection) Type() protoreflect.EnumType {
+	return &file_attest_proto_enumTypes[2]
+}
+
+func (x DataAtRestProtection) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DataAtRestProtection.Descriptor instead.
+func (DataAtRestProtection) EnumDescriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{2}
+}
+
+// Kernel lockdown modes, in increasing order of restriction. See the Linux
+// kernel_lockdown(7) man page.
+type LockdownMode int32
+
+const (
+	LockdownMode_LOCKDOWN_UNKNOWN LockdownMode = 0
+	LockdownMode_LOCKDOWN_NONE    LockdownMode = 1
+	// Features allowing userspace to modify the running kernel are disabled
+	LockdownMode_LOCKDOWN_INTEGRITY LockdownMode = 2
+	// Features allowing userspace to also read kernel memory are disabled
+	LockdownMode_LOCKDOWN_CONFIDENTIALITY LockdownMode = 3
+)
+
+// Enum value maps for LockdownMode.
+var (
+	LockdownMode_name = map[int32]string{
+		0: "LOCKDOWN_UNKNOWN",
+		1: "LOCKDOWN_NONE",
+		2: "LOCKDOWN_INTEGRITY",
+		3: "LOCKDOWN_CONFIDENTIALITY",
+	}
+	LockdownMode_value = map[string]int32{
+		"LOCKDOWN_UNKNOWN":         0,
+		"LOCKDOWN_NONE":            1,
+		"LOCKDOWN_INTEGRITY":       2,
+		"LOCKDOWN_CONFIDENTIALITY": 3,
+	}
+)
+
+func (x LockdownMode) Enum() *LockdownMode {
+	p := new(LockdownMode)
+	*p = x
+	return p
+}
+
+func (x LockdownMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LockdownMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_attest_proto_enumTypes[3].Descriptor()
+}
+
+func (LockdownMode) Type() protoreflect.EnumType {
+	return &file_attest_proto_enumTypes[3]
+}
+
+func (x LockdownMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LockdownMode.Descriptor instead.
+func (LockdownMode) EnumDescriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{3}
+}
+
+// Whether a kernel restriction is enforced
+type Enforcement int32
+
+const (
+	Enforcement_ENFORCEMENT_UNKNOWN Enforcement = 0
+	Enforcement_NOT_ENFORCED        Enforcement = 1
+	Enforcement_ENFORCED            Enforcement = 2
+)
+
+// Enum value maps for Enforcement.
+var (
+	Enforcement_name = map[int32]string{
+		0: "ENFORCEMENT_UNKNOWN",
+		1: "NOT_ENFORCED",
+		2: "ENFORCED",
+	}
+	Enforcement_value = map[string]int32{
+		"ENFORCEMENT_UNKNOWN": 0,
+		"NOT_ENFORCED":        1,
+		"ENFORCED":            2,
+	}
+)
+
+func (x Enforcement) Enum() *Enforcement {
+	p := new(Enforcement)
+	*p = x
+	return p
+}
+
+func (x Enforcement) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Enforcement) Descriptor() protoreflect.EnumDescriptor {
+	return file_attest_proto_enumTypes[4].Descriptor()
+}
+
+func (Enforcement) Type() protoreflect.EnumType {
+	return &file_attest_proto_enumTypes[4]
+}
+
+func (x Enforcement) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Enforcement.Descriptor instead.
+func (Enforcement) EnumDescriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{4}
+}
+
+type KeyAction int32
+
+const (
+	KeyAction_KEY_ACTION_UNSPECIFIED KeyAction = 0
+	KeyAction_KEY_CREATED            KeyAction = 1
+	// Made persistent at a handle with TPM2_EvictControl
+	KeyAction_KEY_PERSISTED KeyAction = 2
+	// Removed from a persistent handle with TPM2_EvictControl
+	KeyAction_KEY_EVICTED KeyAction = 3
+	// Replaced by a new key, whose Name is in the event's name
+	KeyAction_KEY_ROTATED KeyAction = 4
+)
+
+// Enum value maps for KeyAction.
+var (
+	KeyAction_name = map[int32]string{
+		0: "KEY_ACTION_UNSPECIFIED",
+		1: "KEY_CREATED",
+		2: "KEY_PERSISTED",
+		3: "KEY_EVICTED",
+		4: "KEY_ROTATED",
+	}
+	KeyAction_value = map[string]int32{
+		"KEY_ACTION_UNSPECIFIED": 0,
+		"KEY_CREATED":            1,
+		"KEY_PERSISTED":          2,
+		"KEY_EVICTED":            3,
+		"KEY_ROTATED":            4,
+	}
+)
+
+func (x KeyAction) Enum() *KeyAction {
+	p := new(KeyAction)
+	*p = x
+	return p
+}
+
+func (x KeyAction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (KeyAction) Descriptor() protoreflect.EnumDescriptor {
+	return file_attest_proto_enumTypes[5].Descriptor()
+}
+
+func (KeyAction) Type() protoreflect.EnumType {
+	return &file_attest_proto_enumTypes[5]
+}
+
+func (x KeyAction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use KeyAction.Descriptor instead.
+func (KeyAction) EnumDescriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{5}
+}
+
+// Events which make a verified MachineState stale before its validity window
+// ends, so relying parties caching it know when to request a new attestation
+type RevalidationTrigger int32
+
+const (
+	RevalidationTrigger_REVALIDATION_TRIGGER_UNSPECIFIED RevalidationTrigger = 0
+	// The machine rebooted: a later attestation's ClockInfo has a different
+	// reset_count
+	RevalidationTrigger_REVALIDATE_ON_REBOOT RevalidationTrigger = 1
+	// The measurements can change at runtime (e.g. executions or configuration
+	// files measured into the Canonical Event Log) without a reboot
+	RevalidationTrigger_REVALIDATE_ON_RUNTIME_CHANGE RevalidationTrigger = 2
+	// A policy waiver the result depends on expires
+	RevalidationTrigger_REVALIDATE_ON_WAIVER_EXPIRY RevalidationTrigger = 3
+	// The TPM's clock may have been rolled back, so reboots cannot be reliably
+	// detected from the reset_count
+	RevalidationTrigger_REVALIDATE_ON_UNSAFE_CLOCK RevalidationTrigger = 4
+)
+
+// Enum value maps for RevalidationTrigger.
+var (
+	RevalidationTrigger_name = map[int32]string{
+		0: "REVALIDATION_TRIGGER_UNSPECIFIED",
+		1: "REVALIDATE_ON_REBOOT",
+		2: "REVALIDATE_ON_RUNTIME_CHANGE",
+		3: "REVALIDATE_ON_WAIVER_EXPIRY",
+		4: "REVALIDATE_ON_UNSAFE_CLOCK",
+	}
+	RevalidationTrigger_value = map[string]int32{
+		"REVALIDATION_TRIGGER_UNSPECIFIED": 0,
+		"REVALIDATE_ON_REBOOT":             1,
+		"REVALIDATE_ON_RUNTIME_CHANGE":     2,
+		"REVALIDATE_ON_WAIVER_EXPIRY":      3,
+		"REVALIDATE_ON_UNSAFE_CLOCK":       4,
+	}
+)
+
+func (x RevalidationTrigger) Enum() *RevalidationTrigger {
+	p := new(RevalidationTrigger)
+	*p = x
+	return p
+}
+
+func (x RevalidationTrigger) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RevalidationTrigger) Descriptor() protoreflect.EnumDescriptor {
+	return file_attest_proto_enumTypes[6].Descriptor()
+}
+
+func (RevalidationTrigger) Type() protoreflect.EnumType {
+	return &file_attest_proto_enumTypes[6]
+}
+
+func (x RevalidationTrigger) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RevalidationTrigger.Descriptor instead.
+func (RevalidationTrigger) EnumDescriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{6}
+}
+
+// The tier of a trustworthiness claim value, from the IETF RATS Attestation
+// Results for Secure Interactions (AR4SI) draft
+type TrustTier int32
+
+const (
+	// No claim was made (values -128 to 1)
+	TrustTier_TRUST_TIER_NONE TrustTier = 0
+	// The verifier affirms the machine's trustworthiness (values 2 to 31)
+	TrustTier_TRUST_TIER_AFFIRMING TrustTier = 1
+	// The verifier has concerns which a relying party may accept (values 32 to
+	// 95)
+	TrustTier_TRUST_TIER_WARNING TrustTier = 2
+	// The verifier has found the machine untrustworthy (values 96 to 127)
+	TrustTier_TRUST_TIER_CONTRAINDICATED TrustTier = 3
+)
+
+// Enum value maps for TrustTier.
+var (
+	TrustTier_name = map[int32]string{
+		0: "TRUST_TIER_NONE",
+		1: "TRUST_TIER_AFFIRMING",
+		2: "TRUST_TIER_WARNING",
+		3: "TRUST_TIER_CONTRAINDICATED",
+	}
+	TrustTier_value = map[string]int32{
+		"TRUST_TIER_NONE":            0,
+		"TRUST_TIER_AFFIRMING":       1,
+		"TRUST_TIER_WARNING":         2,
+		"TRUST_TIER_CONTRAINDICATED": 3,
+	}
+)
+
+func (x TrustTier) Enum() *TrustTier {
+	p := new(TrustTier)
+	*p = x
+	return p
+}
+
+func (x TrustTier) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TrustTier) Descriptor() protoreflect.EnumDescriptor {
+	return file_attest_proto_enumTypes[7].Descriptor()
+}
+
+func (TrustTier) Type() protoreflect.EnumType {
+	return &file_attest_proto_enumTypes[7]
+}
+
+func (x TrustTier) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TrustTier.Descriptor instead.
+func (TrustTier) EnumDescriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{7}
 }
 
 // Information uniquely identifying a GCE instance. Can be used to create an
@@ -166,6 +571,35 @@ type Attestation struct {
 	EventLog []byte `protobuf:"bytes,3,opt,name=event_log,json=eventLog,proto3" json:"event_log,omitempty"`
 	// Optional information about a GCE instance, unused outside of GCE
 	InstanceInfo *GCEInstanceInfo `protobuf:"bytes,4,opt,name=instance_info,json=instanceInfo,proto3" json:"instance_info,omitempty"`
+	// Optional Canonical Event Log of measurements made after boot, such as the
+	// kernel's security settings, encoded as in the cel package
+	CanonicalEventLog []byte `protobuf:"bytes,5,opt,name=canonical_event_log,json=canonicalEventLog,proto3" json:"canonical_event_log,omitempty"`
+	// The hash algorithm used to bind the nonce to the quotes: their extraData
+	// is the digest of the nonce with this algorithm. If unset (HASH_INVALID),
+	// their extraData is the nonce itself.
+	NonceHash tpm.HashAlgo `protobuf:"varint,6,opt,name=nonce_hash,json=nonceHash,proto3,enum=tpm.HashAlgo" json:"nonce_hash,omitempty"`
+	// Optional quotes by keys other than the AK, over PCRs which the AK did not
+	// quote, such as an IMA key quoting the PCRs measured after boot. They bind
+	// the nonce like the AK's quotes.
+	AdditionalQuotes []*AdditionalQuotes `protobuf:"bytes,7,rep,name=additional_quotes,json=additionalQuotes,proto3" json:"additional_quotes,omitempty"`
+	// The TPM's capabilities, as reported by the TPM when it attested
+	Capabilities *TpmCapabilities `protobuf:"bytes,8,opt,name=capabilities,proto3" json:"capabilities,omitempty"`
+	// Optional EK certificate of the TPM, DER encoded
+	EkCert []byte `protobuf:"bytes,9,opt,name=ek_cert,json=ekCert,proto3" json:"ek_cert,omitempty"`
+	// Certificates issuing ek_cert, DER encoded, starting with its issuer and
+	// excluding the manufacturer's root, so a verifier can build the chain to a
+	// trusted root without fetching them
+	IntermediateCerts [][]byte `protobuf:"bytes,10,rep,name=intermediate_certs,json=intermediateCerts,proto3" json:"intermediate_certs,omitempty"`
+	// The URLs (from Authority Information Access extensions) from which the
+	// attester fetched the last intermediate_certs, in the same order. Empty if
+	// all the intermediate_certs were provided to the attester.
+	ChainFetchedFrom []string `protobuf:"bytes,11,rep,name=chain_fetched_from,json=chainFetchedFrom,proto3" json:"chain_fetched_from,omitempty"`
+	// Optional values which change when the TPM is cleared, reported by the
+	// attester (see client.GetClearIndicators)
+	ClearIndicators *ClearIndicators `protobuf:"bytes,12,opt,name=clear_indicators,json=clearIndicators,proto3" json:"clear_indicators,omitempty"`
+	// Optional journal of the key lifecycle events recorded in an NV extend
+	// index (see cel.KeyJournal)
+	KeyJournal *KeyJournal `protobuf:"bytes,13,opt,name=key_journal,json=keyJournal,proto3" json:"key_journal,omitempty"`
 }
 
 func (x *Attestation) Reset() {
@@ -228,24 +662,84 @@ func (x *Attestation) GetInstanceInfo() *GCEInstanceInfo {
 	return nil
 }
 
-// The platform/firmware state for this instance
-type PlatformState struct {
+func (x *Attestation) GetCanonicalEventLog() []byte {
+	if x != nil {
+		return x.CanonicalEventLog
+	}
+	return nil
+}
+
+func (x *Attestation) GetNonceHash() tpm.HashAlgo {
+	if x != nil {
+		return x.NonceHash
+	}
+	return tpm.HashAlgo(0)
+}
+
+func (x *Attestation) GetAdditionalQuotes() []*AdditionalQuotes {
+	if x != nil {
+		return x.AdditionalQuotes
+	}
+	return nil
+}
+
+func (x *Attestation) GetCapabilities() *TpmCapabilities {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *Attestation) GetEkCert() []byte {
+	if x != nil {
+		return x.EkCert
+	}
+	return nil
+}
+
+func (x *Attestation) GetIntermediateCerts() [][]byte {
+	if x != nil {
+		return x.IntermediateCerts
+	}
+	return nil
+}
+
+func (x *Attestation) GetChainFetchedFrom() []string {
+	if x != nil {
+		return x.ChainFetchedFrom
+	}
+	return nil
+}
+
+func (x *Attestation) GetClearIndicators() *ClearIndicators {
+	if x != nil {
+		return x.ClearIndicators
+	}
+	return nil
+}
+
+func (x *Attestation) GetKeyJournal() *KeyJournal {
+	if x != nil {
+		return x.KeyJournal
+	}
+	return nil
+}
+
+// A journal of key lifecycle events: a Canonical Event Log whose records were
+// extended into an NV extend index, and a certification of the index's
+// contents by the AK, over the attestation's nonce.
+type KeyJournal struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// Types that are assignable to Firmware:
-	//	*PlatformState_ScrtmVersionId
-	//	*PlatformState_GceVersion
-	Firmware isPlatformState_Firmware `protobuf_oneof:"firmware"`
-	// Set to NONE on non-GCE instances or non-Confidential Shielded GCE instances
-	Technology GCEConfidentialTechnology `protobuf:"varint,3,opt,name=technology,proto3,enum=attest.GCEConfidentialTechnology" json:"technology,omitempty"`
-	// Only set for GCE instances
-	InstanceInfo *GCEInstanceInfo `protobuf:"bytes,4,opt,name=instance_info,json=instanceInfo,proto3" json:"instance_info,omitempty"`
+	Certification *tpm.NVCertification `protobuf:"bytes,1,opt,name=certification,proto3" json:"certification,omitempty"`
+	// The Canonical Event Log of cel.KeyEvent records
+	Log []byte `protobuf:"bytes,2,opt,name=log,proto3" json:"log,omitempty"`
 }
 
-func (x *PlatformState) Reset() {
-	*x = PlatformState{}
+func (x *KeyJournal) Reset() {
+	*x = KeyJournal{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_attest_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -253,13 +747,13 @@ func (x *PlatformState) Reset() {
 	}
 }
 
-func (x *PlatformState) String() string {
+func (x *KeyJournal) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PlatformState) ProtoMessage() {}
+func (*KeyJournal) ProtoMessage() {}
 
-func (x *PlatformState) ProtoReflect() protoreflect.Message {
+func (x *KeyJournal) ProtoReflect() protoreflect.Message {
 	mi := &file_attest_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -271,87 +765,46 @@ func (x *PlatformState) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PlatformState.ProtoReflect.Descriptor instead.
-func (*PlatformState) Descriptor() ([]byte, []int) {
+// Deprecated: Use KeyJournal.ProtoReflect.Descriptor instead.
+func (*KeyJournal) Descriptor() ([]byte, []int) {
 	return file_attest_proto_rawDescGZIP(), []int{2}
 }
 
-func (m *PlatformState) GetFirmware() isPlatformState_Firmware {
-	if m != nil {
-		return m.Firmware
+func (x *KeyJournal) GetCertification() *tpm.NVCertification {
+	if x != nil {
+		return x.Certification
 	}
 	return nil
 }
 
-func (x *PlatformState) GetScrtmVersionId() []byte {
-	if x, ok := x.GetFirmware().(*PlatformState_ScrtmVersionId); ok {
-		return x.ScrtmVersionId
-	}
-	return nil
-}
-
-func (x *PlatformState) GetGceVersion() uint32 {
-	if x, ok := x.GetFirmware().(*PlatformState_GceVersion); ok {
-		return x.GceVersion
-	}
-	return 0
-}
-
-func (x *PlatformState) GetTechnology() GCEConfidentialTechnology {
-	if x != nil {
-		return x.Technology
-	}
-	return GCEConfidentialTechnology_NONE
-}
-
-func (x *PlatformState) GetInstanceInfo() *GCEInstanceInfo {
+func (x *KeyJournal) GetLog() []byte {
 	if x != nil {
-		return x.InstanceInfo
+		return x.Log
 	}
 	return nil
 }
 
-type isPlatformState_Firmware interface {
-	isPlatformState_Firmware()
-}
-
-type PlatformState_ScrtmVersionId struct {
-	// Raw S-CRTM version identifier (EV_S_CRTM_VERSION)
-	ScrtmVersionId []byte `protobuf:"bytes,1,opt,name=scrtm_version_id,json=scrtmVersionId,proto3,oneof"`
-}
-
-type PlatformState_GceVersion struct {
-	// Virtual GCE firmware version (parsed from S-CRTM version id)
-	GceVersion uint32 `protobuf:"varint,2,opt,name=gce_version,json=gceVersion,proto3,oneof"`
-}
-
-func (*PlatformState_ScrtmVersionId) isPlatformState_Firmware() {}
-
-func (*PlatformState_GceVersion) isPlatformState_Firmware() {}
-
-// A parsed event from the TCG event log
-type Event struct {
+// Values which change when a TPM is cleared (TPM2_Clear), which replaces the
+// owner hierarchy's seed and deletes owner-defined NV indexes, as reported by
+// the attester. They are not covered by the quotes.
+type ClearIndicators struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// The Platform Control Register (PCR) this event was extended into.
-	PcrIndex uint32 `protobuf:"varint,1,opt,name=pcr_index,json=pcrIndex,proto3" json:"pcr_index,omitempty"`
-	// The type of this event. Note that this value is not verified, so it should
-	// only be used as a hint during event parsing.
-	UntrustedType uint32 `protobuf:"varint,2,opt,name=untrusted_type,json=untrustedType,proto3" json:"untrusted_type,omitempty"`
-	// The raw data associated to this event. The meaning of this data is
-	// specific to the type of the event.
-	Data []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
-	// The event digest actually extended into the TPM. This is often the hash of
-	// the data field, but in some cases it may have a type-specific calculation.
-	Digest []byte `protobuf:"bytes,4,opt,name=digest,proto3" json:"digest,omitempty"`
-	// This is true if hash(data) == digest.
-	DigestVerified bool `protobuf:"varint,5,opt,name=digest_verified,json=digestVerified,proto3" json:"digest_verified,omitempty"`
+	// Name of the EK from the default template, which TPM2_Clear does not
+	// change, identifying the TPM. Encoded as a TPMT_HA.
+	EkName []byte `protobuf:"bytes,1,opt,name=ek_name,json=ekName,proto3" json:"ek_name,omitempty"`
+	// Name of the SRK from the default template, which changes when the TPM is
+	// cleared. Encoded as a TPMT_HA.
+	SrkName []byte `protobuf:"bytes,2,opt,name=srk_name,json=srkName,proto3" json:"srk_name,omitempty"`
+	// Contents of the clear marker NV index (see client.WriteClearMarker), or
+	// empty if the index is not defined
+	NvMarker []byte `protobuf:"bytes,3,opt,name=nv_marker,json=nvMarker,proto3" json:"nv_marker,omitempty"`
 }
 
-func (x *Event) Reset() {
-	*x = Event{}
+func (x *ClearIndicators) Reset() {
+	*x = ClearIndicators{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_attest_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -359,13 +812,13 @@ func (x *Event) Reset() {
 	}
 }
 
-func (x *Event) String() string {
+func (x *ClearIndicators) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Event) ProtoMessage() {}
+func (*ClearIndicators) ProtoMessage() {}
 
-func (x *Event) ProtoReflect() protoreflect.Message {
+func (x *ClearIndicators) ProtoReflect() protoreflect.Message {
 	mi := &file_attest_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -377,64 +830,44 @@ func (x *Event) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Event.ProtoReflect.Descriptor instead.
-func (*Event) Descriptor() ([]byte, []int) {
+// Deprecated: Use ClearIndicators.ProtoReflect.Descriptor instead.
+func (*ClearIndicators) Descriptor() ([]byte, []int) {
 	return file_attest_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *Event) GetPcrIndex() uint32 {
-	if x != nil {
-		return x.PcrIndex
-	}
-	return 0
-}
-
-func (x *Event) GetUntrustedType() uint32 {
-	if x != nil {
-		return x.UntrustedType
-	}
-	return 0
-}
-
-func (x *Event) GetData() []byte {
+func (x *ClearIndicators) GetEkName() []byte {
 	if x != nil {
-		return x.Data
+		return x.EkName
 	}
 	return nil
 }
 
-func (x *Event) GetDigest() []byte {
+func (x *ClearIndicators) GetSrkName() []byte {
 	if x != nil {
-		return x.Digest
+		return x.SrkName
 	}
 	return nil
 }
 
-func (x *Event) GetDigestVerified() bool {
+func (x *ClearIndicators) GetNvMarker() []byte {
 	if x != nil {
-		return x.DigestVerified
+		return x.NvMarker
 	}
-	return false
+	return nil
 }
 
-// The verified state of a booted machine, obtained from an Attestation
-type MachineState struct {
+type TpmClearStatus struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Platform *PlatformState `protobuf:"bytes,1,opt,name=platform,proto3" json:"platform,omitempty"`
-	// The complete parsed TCG Event Log, including those events used to
-	// create the PlatformState.
-	RawEvents []*Event `protobuf:"bytes,3,rep,name=raw_events,json=rawEvents,proto3" json:"raw_events,omitempty"`
-	// The hash algorithm used when verifying the Attestation. This indicates:
-	//   - which PCR bank was used for for quote validation and event log replay
-	//   - the hash algorithm used to calculate event digests
-	Hash tpm.HashAlgo `protobuf:"varint,4,opt,name=hash,proto3,enum=tpm.HashAlgo" json:"hash,omitempty"`
+	Verdict TpmClearVerdict `protobuf:"varint,1,opt,name=verdict,proto3,enum=attest.TpmClearVerdict" json:"verdict,omitempty"`
+	// Descriptions of the indicators the verdict is based on
+	Indicators []string `protobuf:"bytes,2,rep,name=indicators,proto3" json:"indicators,omitempty"`
 }
 
-func (x *MachineState) Reset() {
-	*x = MachineState{}
+func (x *TpmClearStatus) Reset() {
+	*x = TpmClearStatus{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_attest_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -442,13 +875,13 @@ func (x *MachineState) Reset() {
 	}
 }
 
-func (x *MachineState) String() string {
+func (x *TpmClearStatus) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*MachineState) ProtoMessage() {}
+func (*TpmClearStatus) ProtoMessage() {}
 
-func (x *MachineState) ProtoReflect() protoreflect.Message {
+func (x *TpmClearStatus) ProtoReflect() protoreflect.Message {
 	mi := &file_attest_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -460,52 +893,39 @@ func (x *MachineState) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use MachineState.ProtoReflect.Descriptor instead.
-func (*MachineState) Descriptor() ([]byte, []int) {
+// Deprecated: Use TpmClearStatus.ProtoReflect.Descriptor instead.
+func (*TpmClearStatus) Descriptor() ([]byte, []int) {
 	return file_attest_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *MachineState) GetPlatform() *PlatformState {
+func (x *TpmClearStatus) GetVerdict() TpmClearVerdict {
 	if x != nil {
-		return x.Platform
+		return x.Verdict
 	}
-	return nil
+	return TpmClearVerdict_TPM_CLEAR_UNKNOWN
 }
 
-func (x *MachineState) GetRawEvents() []*Event {
+func (x *TpmClearStatus) GetIndicators() []string {
 	if x != nil {
-		return x.RawEvents
+		return x.Indicators
 	}
 	return nil
 }
 
-func (x *MachineState) GetHash() tpm.HashAlgo {
-	if x != nil {
-		return x.Hash
-	}
-	return tpm.HashAlgo(0)
-}
-
-// A policy dictating which values of PlatformState to allow
-type PlatformPolicy struct {
+// Quotes signed by a key other than an Attestation's AK
+type AdditionalQuotes struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// If PlatformState.firmware contains a scrtm_version_id, it must appear
-	// in this list. For use with a GCE VM, minimum_gce_firmware_version is
-	// often a better alternative.
-	AllowedScrtmVersionIds [][]byte `protobuf:"bytes,1,rep,name=allowed_scrtm_version_ids,json=allowedScrtmVersionIds,proto3" json:"allowed_scrtm_version_ids,omitempty"`
-	// If PlatformState.firmware contains a minimum_gce_firmware_version, it must
-	// be greater than or equal to this value. Currently, the max version is 1.
-	MinimumGceFirmwareVersion uint32 `protobuf:"varint,2,opt,name=minimum_gce_firmware_version,json=minimumGceFirmwareVersion,proto3" json:"minimum_gce_firmware_version,omitempty"`
-	// The PlatformState's technology must be at least as secure as
-	// the specified minimum_technology (i.e. AMD_SEV_ES > AMD_SEV > NONE).
-	MinimumTechnology GCEConfidentialTechnology `protobuf:"varint,3,opt,name=minimum_technology,json=minimumTechnology,proto3,enum=attest.GCEConfidentialTechnology" json:"minimum_technology,omitempty"`
+	// Public area of the signing key, encoded as a TPMT_PUBLIC
+	KeyPub []byte `protobuf:"bytes,1,opt,name=key_pub,json=keyPub,proto3" json:"key_pub,omitempty"`
+	// Quotes over the key's PCRs in each supported bank
+	Quotes []*tpm.Quote `protobuf:"bytes,2,rep,name=quotes,proto3" json:"quotes,omitempty"`
 }
 
-func (x *PlatformPolicy) Reset() {
-	*x = PlatformPolicy{}
+func (x *AdditionalQuotes) Reset() {
+	*x = AdditionalQuotes{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_attest_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -513,13 +933,13 @@ func (x *PlatformPolicy) Reset() {
 	}
 }
 
-func (x *PlatformPolicy) String() string {
+func (x *AdditionalQuotes) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PlatformPolicy) ProtoMessage() {}
+func (*AdditionalQuotes) ProtoMessage() {}
 
-func (x *PlatformPolicy) ProtoReflect() protoreflect.Message {
+func (x *AdditionalQuotes) ProtoReflect() protoreflect.Message {
 	mi := &file_attest_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -531,43 +951,43 @@ func (x *PlatformPolicy) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PlatformPolicy.ProtoReflect.Descriptor instead.
-func (*PlatformPolicy) Descriptor() ([]byte, []int) {
+// Deprecated: Use AdditionalQuotes.ProtoReflect.Descriptor instead.
+func (*AdditionalQuotes) Descriptor() ([]byte, []int) {
 	return file_attest_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *PlatformPolicy) GetAllowedScrtmVersionIds() [][]byte {
+func (x *AdditionalQuotes) GetKeyPub() []byte {
 	if x != nil {
-		return x.AllowedScrtmVersionIds
+		return x.KeyPub
 	}
 	return nil
 }
 
-func (x *PlatformPolicy) GetMinimumGceFirmwareVersion() uint32 {
-	if x != nil {
-		return x.MinimumGceFirmwareVersion
-	}
-	return 0
-}
-
-func (x *PlatformPolicy) GetMinimumTechnology() GCEConfidentialTechnology {
+func (x *AdditionalQuotes) GetQuotes() []*tpm.Quote {
 	if x != nil {
-		return x.MinimumTechnology
+		return x.Quotes
 	}
-	return GCEConfidentialTechnology_NONE
+	return nil
 }
 
-// A policy dictating which type of MachineStates to allow
-type Policy struct {
+// The platform/firmware state for this instance
+type PlatformState struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Platform *PlatformPolicy `protobuf:"bytes,1,opt,name=platform,proto3" json:"platform,omitempty"`
+	// Types that are assignable to Firmware:
+	//	*PlatformState_ScrtmVersionId
+	//	*PlatformState_GceVersion
+	Firmware isPlatformState_Firmware `protobuf_oneof:"firmware"`
+	// Set to NONE on non-GCE instances or non-Confidential Shielded GCE instances
+	Technology GCEConfidentialTechnology `protobuf:"varint,3,opt,name=technology,proto3,enum=attest.GCEConfidentialTechnology" json:"technology,omitempty"`
+	// Only set for GCE instances
+	InstanceInfo *GCEInstanceInfo `protobuf:"bytes,4,opt,name=instance_info,json=instanceInfo,proto3" json:"instance_info,omitempty"`
 }
 
-func (x *Policy) Reset() {
-	*x = Policy{}
+func (x *PlatformState) Reset() {
+	*x = PlatformState{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_attest_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -575,13 +995,13 @@ func (x *Policy) Reset() {
 	}
 }
 
-func (x *Policy) String() string {
+func (x *PlatformState) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Policy) ProtoMessage() {}
+func (*PlatformState) ProtoMessage() {}
 
-func (x *Policy) ProtoReflect() protoreflect.Message {
+func (x *PlatformState) ProtoReflect() protoreflect.Message {
 	mi := &file_attest_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -593,158 +1013,3693 @@ func (x *Policy) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Policy.ProtoReflect.Descriptor instead.
-func (*Policy) Descriptor() ([]byte, []int) {
+// Deprecated: Use PlatformState.ProtoReflect.Descriptor instead.
+func (*PlatformState) Descriptor() ([]byte, []int) {
 	return file_attest_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *Policy) GetPlatform() *PlatformPolicy {
+func (m *PlatformState) GetFirmware() isPlatformState_Firmware {
+	if m != nil {
+		return m.Firmware
+	}
+	return nil
+}
+
+func (x *PlatformState) GetScrtmVersionId() []byte {
+	if x, ok := x.GetFirmware().(*PlatformState_ScrtmVersionId); ok {
+		return x.ScrtmVersionId
+	}
+	return nil
+}
+
+func (x *PlatformState) GetGceVersion() uint32 {
+	if x, ok := x.GetFirmware().(*PlatformState_GceVersion); ok {
+		return x.GceVersion
+	}
+	return 0
+}
+
+func (x *PlatformState) GetTechnology() GCEConfidentialTechnology {
 	if x != nil {
-		return x.Platform
+		return x.Technology
+	}
+	return GCEConfidentialTechnology_NONE
+}
+
+func (x *PlatformState) GetInstanceInfo() *GCEInstanceInfo {
+	if x != nil {
+		return x.InstanceInfo
 	}
 	return nil
 }
 
-var File_attest_proto protoreflect.FileDescriptor
+type isPlatformState_Firmware interface {
+	isPlatformState_Firmware()
+}
 
-var file_attest_proto_rawDesc = []byte{
-	0x0a, 0x0c, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06,
-	0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x1a, 0x09, 0x74, 0x70, 0x6d, 0x2e, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x22, 0xb1, 0x01, 0x0a, 0x0f, 0x47, 0x43, 0x45, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63,
-	0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x7a, 0x6f, 0x6e, 0x65, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x04, 0x7a, 0x6f, 0x6e, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x72, 0x6f,
-	0x6a, 0x65, 0x63, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70,
-	0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x49, 0x64, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x72, 0x6f, 0x6a,
-	0x65, 0x63, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04,
-	0x52, 0x0d, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12,
-	0x23, 0x0a, 0x0d, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65,
-	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65,
-	0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65,
-	0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x69, 0x6e, 0x73, 0x74, 0x61,
-	0x6e, 0x63, 0x65, 0x49, 0x64, 0x22, 0xa3, 0x01, 0x0a, 0x0b, 0x41, 0x74, 0x74, 0x65, 0x73, 0x74,
-	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x15, 0x0a, 0x06, 0x61, 0x6b, 0x5f, 0x70, 0x75, 0x62, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x61, 0x6b, 0x50, 0x75, 0x62, 0x12, 0x22, 0x0a, 0x06,
-	0x71, 0x75, 0x6f, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x74,
-	0x70, 0x6d, 0x2e, 0x51, 0x75, 0x6f, 0x74, 0x65, 0x52, 0x06, 0x71, 0x75, 0x6f, 0x74, 0x65, 0x73,
-	0x12, 0x1b, 0x0a, 0x09, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x5f, 0x6c, 0x6f, 0x67, 0x18, 0x03, 0x20,
-	0x01, 0x28, 0x0c, 0x52, 0x08, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x4c, 0x6f, 0x67, 0x12, 0x3c, 0x0a,
-	0x0d, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x47, 0x43,
-	0x45, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0c, 0x69,
-	0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x22, 0xeb, 0x01, 0x0a, 0x0d,
-	0x50, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x2a, 0x0a,
-	0x10, 0x73, 0x63, 0x72, 0x74, 0x6d, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x69,
-	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x00, 0x52, 0x0e, 0x73, 0x63, 0x72, 0x74, 0x6d,
-	0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x21, 0x0a, 0x0b, 0x67, 0x63, 0x65,
-	0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x00,
-	0x52, 0x0a, 0x67, 0x63, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x41, 0x0a, 0x0a,
-	0x74, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e,
-	0x32, 0x21, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x47, 0x43, 0x45, 0x43, 0x6f, 0x6e,
-	0x66, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x54, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c,
-	0x6f, 0x67, 0x79, 0x52, 0x0a, 0x74, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x12,
-	0x3c, 0x0a, 0x0d, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x5f, 0x69, 0x6e, 0x66, 0x6f,
-	0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e,
-	0x47, 0x43, 0x45, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52,
-	0x0c, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x42, 0x0a, 0x0a,
-	0x08, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x22, 0xa0, 0x01, 0x0a, 0x05, 0x45, 0x76,
-	0x65, 0x6e, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x70, 0x63, 0x72, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x70, 0x63, 0x72, 0x49, 0x6e, 0x64, 0x65, 0x78,
-	0x12, 0x25, 0x0a, 0x0e, 0x75, 0x6e, 0x74, 0x72, 0x75, 0x73, 0x74, 0x65, 0x64, 0x5f, 0x74, 0x79,
-	0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0d, 0x75, 0x6e, 0x74, 0x72, 0x75, 0x73,
-	0x74, 0x65, 0x64, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x16, 0x0a, 0x06, 0x64,
-	0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67,
-	0x65, 0x73, 0x74, 0x12, 0x27, 0x0a, 0x0f, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x5f, 0x76, 0x65,
-	0x72, 0x69, 0x66, 0x69, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x64, 0x69,
-	0x67, 0x65, 0x73, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x64, 0x22, 0x92, 0x01, 0x0a,
-	0x0c, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x31, 0x0a,
-	0x08, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x15, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x50, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72,
-	0x6d, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x08, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d,
-	0x12, 0x2c, 0x0a, 0x0a, 0x72, 0x61, 0x77, 0x5f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x03,
-	0x20, 0x03, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x45, 0x76,
-	0x65, 0x6e, 0x74, 0x52, 0x09, 0x72, 0x61, 0x77, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x21,
-	0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0d, 0x2e, 0x74,
-	0x70, 0x6d, 0x2e, 0x48, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f, 0x52, 0x04, 0x68, 0x61, 0x73,
-	0x68, 0x22, 0xde, 0x01, 0x0a, 0x0e, 0x50, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x50, 0x6f,
-	0x6c, 0x69, 0x63, 0x79, 0x12, 0x39, 0x0a, 0x19, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x5f,
-	0x73, 0x63, 0x72, 0x74, 0x6d, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64,
-	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x16, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64,
-	0x53, 0x63, 0x72, 0x74, 0x6d, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x73, 0x12,
-	0x3f, 0x0a, 0x1c, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x5f, 0x67, 0x63, 0x65, 0x5f, 0x66,
-	0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x19, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x47, 0x63,
-	0x65, 0x46, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
-	0x12, 0x50, 0x0a, 0x12, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x5f, 0x74, 0x65, 0x63, 0x68,
-	0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x21, 0x2e, 0x61,
-	0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x47, 0x43, 0x45, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65,
-	0x6e, 0x74, 0x69, 0x61, 0x6c, 0x54, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x52,
-	0x11, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x54, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f,
-	0x67, 0x79, 0x22, 0x3c, 0x0a, 0x06, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x32, 0x0a, 0x08,
-	0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16,
-	0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x50, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d,
-	0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x08, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d,
-	0x2a, 0x42, 0x0a, 0x19, 0x47, 0x43, 0x45, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x74,
-	0x69, 0x61, 0x6c, 0x54, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x12, 0x08, 0x0a,
-	0x04, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x4d, 0x44, 0x5f, 0x53,
-	0x45, 0x56, 0x10, 0x01, 0x12, 0x0e, 0x0a, 0x0a, 0x41, 0x4d, 0x44, 0x5f, 0x53, 0x45, 0x56, 0x5f,
-	0x45, 0x53, 0x10, 0x02, 0x42, 0x2d, 0x5a, 0x2b, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x67, 0x6f, 0x2d, 0x74, 0x70, 0x6d,
-	0x2d, 0x74, 0x6f, 0x6f, 0x6c, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x61, 0x74, 0x74,
-	0x65, 0x73, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+type PlatformState_ScrtmVersionId struct {
+	// Raw S-CRTM version identifier (EV_S_CRTM_VERSION)
+	ScrtmVersionId []byte `protobuf:"bytes,1,opt,name=scrtm_version_id,json=scrtmVersionId,proto3,oneof"`
 }
 
-var (
-	file_attest_proto_rawDescOnce sync.Once
-	file_attest_proto_rawDescData = file_attest_proto_rawDesc
-)
+type PlatformState_GceVersion struct {
+	// Virtual GCE firmware version (parsed from S-CRTM version id)
+	GceVersion uint32 `protobuf:"varint,2,opt,name=gce_version,json=gceVersion,proto3,oneof"`
+}
 
-func file_attest_proto_rawDescGZIP() []byte {
-	file_attest_proto_rawDescOnce.Do(func() {
-		file_attest_proto_rawDescData = protoimpl.X.CompressGZIP(file_attest_proto_rawDescData)
-	})
-	return file_attest_proto_rawDescData
+func (*PlatformState_ScrtmVersionId) isPlatformState_Firmware() {}
+
+func (*PlatformState_GceVersion) isPlatformState_Firmware() {}
+
+// A PE section of a Unified Kernel Image (UKI), measured into PCR 11 by
+// systemd-stub
+type UKISection struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The section name, such as ".linux", ".cmdline" or ".initrd"
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The digest of the section contents
+	Digest []byte `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
 }
 
-var file_attest_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_attest_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
-var file_attest_proto_goTypes = []interface{}{
-	(GCEConfidentialTechnology)(0), // 0: attest.GCEConfidentialTechnology
-	(*GCEInstanceInfo)(nil),        // 1: attest.GCEInstanceInfo
-	(*Attestation)(nil),            // 2: attest.Attestation
-	(*PlatformState)(nil),          // 3: attest.PlatformState
-	(*Event)(nil),                  // 4: attest.Event
-	(*MachineState)(nil),           // 5: attest.MachineState
-	(*PlatformPolicy)(nil),         // 6: attest.PlatformPolicy
-	(*Policy)(nil),                 // 7: attest.Policy
-	(*tpm.Quote)(nil),              // 8: tpm.Quote
-	(tpm.HashAlgo)(0),              // 9: tpm.HashAlgo
+func (x *UKISection) Reset() {
+	*x = UKISection{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
 }
-var file_attest_proto_depIdxs = []int32{
-	8, // 0: attest.Attestation.quotes:type_name -> tpm.Quote
-	1, // 1: attest.Attestation.instance_info:type_name -> attest.GCEInstanceInfo
-	0, // 2: attest.PlatformState.technology:type_name -> attest.GCEConfidentialTechnology
-	1, // 3: attest.PlatformState.instance_info:type_name -> attest.GCEInstanceInfo
-	3, // 4: attest.MachineState.platform:type_name -> attest.PlatformState
-	4, // 5: attest.MachineState.raw_events:type_name -> attest.Event
-	9, // 6: attest.MachineState.hash:type_name -> tpm.HashAlgo
-	0, // 7: attest.PlatformPolicy.minimum_technology:type_name -> attest.GCEConfidentialTechnology
-	6, // 8: attest.Policy.platform:type_name -> attest.PlatformPolicy
-	9, // [9:9] is the sub-list for method output_type
-	9, // [9:9] is the sub-list for method input_type
-	9, // [9:9] is the sub-list for extension type_name
-	9, // [9:9] is the sub-list for extension extendee
-	0, // [0:9] is the sub-list for field type_name
+
+func (x *UKISection) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func init() { file_attest_proto_init() }
-func file_attest_proto_init() {
-	if File_attest_proto != nil {
-		return
+func (*UKISection) ProtoMessage() {}
+
+func (x *UKISection) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_attest_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GCEInstanceInfo); i {
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UKISection.ProtoReflect.Descriptor instead.
+func (*UKISection) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UKISection) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UKISection) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+// The measurements of systemd-stub, the EFI stub of Unified Kernel Images
+type SystemdStubState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The UKI sections, in the order they were measured
+	Sections []*UKISection `protobuf:"bytes,1,rep,name=sections,proto3" json:"sections,omitempty"`
+	// The digests of the cpio archives of credentials passed to the initrd,
+	// measured into PCR 12 (both global and kernel-specific credentials)
+	CredentialDigests [][]byte `protobuf:"bytes,2,rep,name=credential_digests,json=credentialDigests,proto3" json:"credential_digests,omitempty"`
+	// The digests of the cpio archives of configuration extension images,
+	// measured into PCR 12
+	ConfextDigests [][]byte `protobuf:"bytes,3,rep,name=confext_digests,json=confextDigests,proto3" json:"confext_digests,omitempty"`
+	// The digests of the cpio archives of system extension images, measured
+	// into PCR 13
+	SysextDigests [][]byte `protobuf:"bytes,4,rep,name=sysext_digests,json=sysextDigests,proto3" json:"sysext_digests,omitempty"`
+}
+
+func (x *SystemdStubState) Reset() {
+	*x = SystemdStubState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SystemdStubState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SystemdStubState) ProtoMessage() {}
+
+func (x *SystemdStubState) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SystemdStubState.ProtoReflect.Descriptor instead.
+func (*SystemdStubState) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SystemdStubState) GetSections() []*UKISection {
+	if x != nil {
+		return x.Sections
+	}
+	return nil
+}
+
+func (x *SystemdStubState) GetCredentialDigests() [][]byte {
+	if x != nil {
+		return x.CredentialDigests
+	}
+	return nil
+}
+
+func (x *SystemdStubState) GetConfextDigests() [][]byte {
+	if x != nil {
+		return x.ConfextDigests
+	}
+	return nil
+}
+
+func (x *SystemdStubState) GetSysextDigests() [][]byte {
+	if x != nil {
+		return x.SysextDigests
+	}
+	return nil
+}
+
+// A file read by GRUB, measured into PCR 9
+type GrubFile struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The path of the file, such as "(hd0,gpt1)/boot/grub/grub.cfg". Fedora and
+	// RHEL GRUB describe the kernel and initrd as "grub_linuxefi Kernel" and
+	// "grub_linuxefi Initrd" instead.
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// The digest of the file contents. This is not verified against the path.
+	Digest []byte `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+}
+
+func (x *GrubFile) Reset() {
+	*x = GrubFile{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GrubFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GrubFile) ProtoMessage() {}
+
+func (x *GrubFile) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GrubFile.ProtoReflect.Descriptor instead.
+func (*GrubFile) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GrubFile) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *GrubFile) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+// The measurements of GRUB: the commands it executed, including the entries
+// of grub.cfg, and the files it read, such as grub.cfg, modules and the kernel
+type GrubState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The commands, in the order they were measured into PCR 8. The digest of
+	// each command was verified against its text.
+	Commands []string `protobuf:"bytes,1,rep,name=commands,proto3" json:"commands,omitempty"`
+	// The files, in the order they were measured into PCR 9
+	Files []*GrubFile `protobuf:"bytes,2,rep,name=files,proto3" json:"files,omitempty"`
+}
+
+func (x *GrubState) Reset() {
+	*x = GrubState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GrubState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GrubState) ProtoMessage() {}
+
+func (x *GrubState) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GrubState.ProtoReflect.Descriptor instead.
+func (*GrubState) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GrubState) GetCommands() []string {
+	if x != nil {
+		return x.Commands
+	}
+	return nil
+}
+
+func (x *GrubState) GetFiles() []*GrubFile {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+// The state of the Linux kernel, as determined from the kernel image, initrd
+// and command line measured by the bootloader. The kernel configuration is
+// not measured, so
+// kernels built without swap or hibernation support are still reported as
+// PROTECTION_UNKNOWN (unless the command line says otherwise). Upstream kernels
+// do not sign or authenticate hibernation images, so signed images cannot be
+// attested.
+type LinuxKernelState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The measured kernel command line
+	CommandLine string `protobuf:"bytes,1,opt,name=command_line,json=commandLine,proto3" json:"command_line,omitempty"`
+	// Protection of memory contents written to swap
+	Swap DataAtRestProtection `protobuf:"varint,2,opt,name=swap,proto3,enum=attest.DataAtRestProtection" json:"swap,omitempty"`
+	// Protection of hibernation images
+	Hibernation DataAtRestProtection `protobuf:"varint,3,opt,name=hibernation,proto3,enum=attest.DataAtRestProtection" json:"hibernation,omitempty"`
+	// The kernel lockdown mode. Lockdown can only be made stricter while the
+	// kernel runs, so this is the least strict mode of the running kernel.
+	Lockdown LockdownMode `protobuf:"varint,4,opt,name=lockdown,proto3,enum=attest.LockdownMode" json:"lockdown,omitempty"`
+	// Whether only modules with valid signatures can be loaded
+	ModuleSignatures Enforcement `protobuf:"varint,5,opt,name=module_signatures,json=moduleSignatures,proto3,enum=attest.Enforcement" json:"module_signatures,omitempty"`
+	// Whether the kexec_load system call, which loads unsigned kernels, is
+	// disabled
+	KexecLoadDisabled Enforcement `protobuf:"varint,6,opt,name=kexec_load_disabled,json=kexecLoadDisabled,proto3,enum=attest.Enforcement" json:"kexec_load_disabled,omitempty"`
+	// The digest of the kernel image file, measured into PCR 9 by GRUB
+	KernelDigest []byte `protobuf:"bytes,7,opt,name=kernel_digest,json=kernelDigest,proto3" json:"kernel_digest,omitempty"`
+	// The PE/COFF Authenticode digest of the kernel image, measured into PCR 4
+	// when the kernel is loaded as a UEFI application (by systemd-boot, or by
+	// shim and GRUB with Secure Boot)
+	KernelAuthenticodeDigest []byte `protobuf:"bytes,8,opt,name=kernel_authenticode_digest,json=kernelAuthenticodeDigest,proto3" json:"kernel_authenticode_digest,omitempty"`
+	// The digests of the initrd files, in the order they were measured into
+	// PCR 9 by GRUB or the kernel's EFI stub
+	InitrdDigests [][]byte `protobuf:"bytes,9,rep,name=initrd_digests,json=initrdDigests,proto3" json:"initrd_digests,omitempty"`
+}
+
+func (x *LinuxKernelState) Reset() {
+	*x = LinuxKernelState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LinuxKernelState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinuxKernelState) ProtoMessage() {}
+
+func (x *LinuxKernelState) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinuxKernelState.ProtoReflect.Descriptor instead.
+func (*LinuxKernelState) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *LinuxKernelState) GetCommandLine() string {
+	if x != nil {
+		return x.CommandLine
+	}
+	return ""
+}
+
+func (x *LinuxKernelState) GetSwap() DataAtRestProtection {
+	if x != nil {
+		return x.Swap
+	}
+	return DataAtRestProtection_PROTECTION_UNKNOWN
+}
+
+func (x *LinuxKernelState) GetHibernation() DataAtRestProtection {
+	if x != nil {
+		return x.Hibernation
+	}
+	return DataAtRestProtection_PROTECTION_UNKNOWN
+}
+
+func (x *LinuxKernelState) GetLockdown() LockdownMode {
+	if x != nil {
+		return x.Lockdown
+	}
+	return LockdownMode_LOCKDOWN_UNKNOWN
+}
+
+func (x *LinuxKernelState) GetModuleSignatures() Enforcement {
+	if x != nil {
+		return x.ModuleSignatures
+	}
+	return Enforcement_ENFORCEMENT_UNKNOWN
+}
+
+func (x *LinuxKernelState) GetKexecLoadDisabled() Enforcement {
+	if x != nil {
+		return x.KexecLoadDisabled
+	}
+	return Enforcement_ENFORCEMENT_UNKNOWN
+}
+
+func (x *LinuxKernelState) GetKernelDigest() []byte {
+	if x != nil {
+		return x.KernelDigest
+	}
+	return nil
+}
+
+func (x *LinuxKernelState) GetKernelAuthenticodeDigest() []byte {
+	if x != nil {
+		return x.KernelAuthenticodeDigest
+	}
+	return nil
+}
+
+func (x *LinuxKernelState) GetInitrdDigests() [][]byte {
+	if x != nil {
+		return x.InitrdDigests
+	}
+	return nil
+}
+
+// A parsed event from the TCG event log
+type Event struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The Platform Control Register (PCR) this event was extended into.
+	PcrIndex uint32 `protobuf:"varint,1,opt,name=pcr_index,json=pcrIndex,proto3" json:"pcr_index,omitempty"`
+	// The type of this event. Note that this value is not verified, so it should
+	// only be used as a hint during event parsing.
+	UntrustedType uint32 `protobuf:"varint,2,opt,name=untrusted_type,json=untrustedType,proto3" json:"untrusted_type,omitempty"`
+	// The raw data associated to this event. The meaning of this data is
+	// specific to the type of the event.
+	Data []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	// The event digest actually extended into the TPM. This is often the hash of
+	// the data field, but in some cases it may have a type-specific calculation.
+	Digest []byte `protobuf:"bytes,4,opt,name=digest,proto3" json:"digest,omitempty"`
+	// This is true if hash(data) == digest.
+	DigestVerified bool `protobuf:"varint,5,opt,name=digest_verified,json=digestVerified,proto3" json:"digest_verified,omitempty"`
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Event) GetPcrIndex() uint32 {
+	if x != nil {
+		return x.PcrIndex
+	}
+	return 0
+}
+
+func (x *Event) GetUntrustedType() uint32 {
+	if x != nil {
+		return x.UntrustedType
+	}
+	return 0
+}
+
+func (x *Event) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Event) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+func (x *Event) GetDigestVerified() bool {
+	if x != nil {
+		return x.DigestVerified
+	}
+	return false
+}
+
+// Information about a TPM, obtained from its verified EK certificate
+type TpmInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// TCG vendor ID of the TPM manufacturer (e.g. 0x49465800 for Infineon)
+	ManufacturerId uint32 `protobuf:"varint,1,opt,name=manufacturer_id,json=manufacturerId,proto3" json:"manufacturer_id,omitempty"`
+	// Name of the TPM manufacturer, empty if the vendor ID is unknown
+	Manufacturer string `protobuf:"bytes,2,opt,name=manufacturer,proto3" json:"manufacturer,omitempty"`
+	// The TPM model, from the tcg-at-tpmModel attribute
+	Model string `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	// The TPM firmware version, from the tcg-at-tpmVersion attribute
+	FirmwareVersion uint32 `protobuf:"varint,4,opt,name=firmware_version,json=firmwareVersion,proto3" json:"firmware_version,omitempty"`
+}
+
+func (x *TpmInfo) Reset() {
+	*x = TpmInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TpmInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TpmInfo) ProtoMessage() {}
+
+func (x *TpmInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TpmInfo.ProtoReflect.Descriptor instead.
+func (*TpmInfo) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *TpmInfo) GetManufacturerId() uint32 {
+	if x != nil {
+		return x.ManufacturerId
+	}
+	return 0
+}
+
+func (x *TpmInfo) GetManufacturer() string {
+	if x != nil {
+		return x.Manufacturer
+	}
+	return ""
+}
+
+func (x *TpmInfo) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *TpmInfo) GetFirmwareVersion() uint32 {
+	if x != nil {
+		return x.FirmwareVersion
+	}
+	return 0
+}
+
+// The capabilities of a TPM, from TPM2_GetCapability (see
+// client.GetCapabilities)
+type TpmCapabilities struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// TCG vendor ID of the TPM manufacturer (TPM_PT_MANUFACTURER)
+	ManufacturerId uint32 `protobuf:"varint,1,opt,name=manufacturer_id,json=manufacturerId,proto3" json:"manufacturer_id,omitempty"`
+	// Vendor-specific string identifying the TPM model (TPM_PT_VENDOR_STRING_*)
+	VendorString string `protobuf:"bytes,2,opt,name=vendor_string,json=vendorString,proto3" json:"vendor_string,omitempty"`
+	// Vendor-specific firmware version (TPM_PT_FIRMWARE_VERSION_1 and _2)
+	FirmwareVersion uint64 `protobuf:"varint,3,opt,name=firmware_version,json=firmwareVersion,proto3" json:"firmware_version,omitempty"`
+	// Revision of the TPM specification, multiplied by 100 (TPM_PT_REVISION)
+	SpecRevision uint32 `protobuf:"varint,4,opt,name=spec_revision,json=specRevision,proto3" json:"spec_revision,omitempty"`
+	// The TPM_ALG_IDs of the algorithms the TPM implements
+	Algorithms []uint32 `protobuf:"varint,5,rep,packed,name=algorithms,proto3" json:"algorithms,omitempty"`
+	// The PCR banks with at least one PCR allocated
+	PcrBanks []tpm.HashAlgo `protobuf:"varint,6,rep,packed,name=pcr_banks,json=pcrBanks,proto3,enum=tpm.HashAlgo" json:"pcr_banks,omitempty"`
+	// Handles of the persistent objects in the TPM
+	PersistentHandles []uint32 `protobuf:"varint,7,rep,packed,name=persistent_handles,json=persistentHandles,proto3" json:"persistent_handles,omitempty"`
+	// The defined NV indexes
+	NvIndexes []uint32 `protobuf:"varint,8,rep,packed,name=nv_indexes,json=nvIndexes,proto3" json:"nv_indexes,omitempty"`
+}
+
+func (x *TpmCapabilities) Reset() {
+	*x = TpmCapabilities{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TpmCapabilities) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TpmCapabilities) ProtoMessage() {}
+
+func (x *TpmCapabilities) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TpmCapabilities.ProtoReflect.Descriptor instead.
+func (*TpmCapabilities) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *TpmCapabilities) GetManufacturerId() uint32 {
+	if x != nil {
+		return x.ManufacturerId
+	}
+	return 0
+}
+
+func (x *TpmCapabilities) GetVendorString() string {
+	if x != nil {
+		return x.VendorString
+	}
+	return ""
+}
+
+func (x *TpmCapabilities) GetFirmwareVersion() uint64 {
+	if x != nil {
+		return x.FirmwareVersion
+	}
+	return 0
+}
+
+func (x *TpmCapabilities) GetSpecRevision() uint32 {
+	if x != nil {
+		return x.SpecRevision
+	}
+	return 0
+}
+
+func (x *TpmCapabilities) GetAlgorithms() []uint32 {
+	if x != nil {
+		return x.Algorithms
+	}
+	return nil
+}
+
+func (x *TpmCapabilities) GetPcrBanks() []tpm.HashAlgo {
+	if x != nil {
+		return x.PcrBanks
+	}
+	return nil
+}
+
+func (x *TpmCapabilities) GetPersistentHandles() []uint32 {
+	if x != nil {
+		return x.PersistentHandles
+	}
+	return nil
+}
+
+func (x *TpmCapabilities) GetNvIndexes() []uint32 {
+	if x != nil {
+		return x.NvIndexes
+	}
+	return nil
+}
+
+// A UEFI Secure Boot signature database: the contents of one of the PK, KEK,
+// db or dbx variables, which are arrays of EFI_SIGNATURE_LISTs.
+type Database struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// DER-encoded X.509 certificates (EFI_CERT_X509_GUID entries)
+	Certs [][]byte `protobuf:"bytes,1,rep,name=certs,proto3" json:"certs,omitempty"`
+	// Digests of EFI binaries (EFI_CERT_SHA256_GUID entries, or the SHA-1,
+	// SHA-224, SHA-384 or SHA-512 equivalents). The algorithm is implied by the
+	// length of the digest.
+	Hashes [][]byte `protobuf:"bytes,2,rep,name=hashes,proto3" json:"hashes,omitempty"`
+	// Digests of the TBSCertificate of X.509 certificates
+	// (EFI_CERT_X509_SHA256_GUID entries, or the SHA-384 or SHA-512
+	// equivalents). These are only used in dbx, to revoke certificates without
+	// including them. The entries' revocation times are not included.
+	CertHashes [][]byte `protobuf:"bytes,3,rep,name=cert_hashes,json=certHashes,proto3" json:"cert_hashes,omitempty"`
+}
+
+func (x *Database) Reset() {
+	*x = Database{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Database) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Database) ProtoMessage() {}
+
+func (x *Database) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Database.ProtoReflect.Descriptor instead.
+func (*Database) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Database) GetCerts() [][]byte {
+	if x != nil {
+		return x.Certs
+	}
+	return nil
+}
+
+func (x *Database) GetHashes() [][]byte {
+	if x != nil {
+		return x.Hashes
+	}
+	return nil
+}
+
+func (x *Database) GetCertHashes() [][]byte {
+	if x != nil {
+		return x.CertHashes
+	}
+	return nil
+}
+
+// The UEFI Secure Boot configuration, from the EV_EFI_VARIABLE_DRIVER_CONFIG
+// events measured into PCR 7 before the separator.
+type SecureBootState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Whether Secure Boot was enabled (the SecureBoot variable was 1)
+	Enabled bool `protobuf:"varint,1,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	// The Platform Key, which can update the KEK
+	Pk *Database `protobuf:"bytes,2,opt,name=pk,proto3" json:"pk,omitempty"`
+	// The Key Exchange Keys, which can update db and dbx
+	Kek *Database `protobuf:"bytes,3,opt,name=kek,proto3" json:"kek,omitempty"`
+	// The signature database of certificates and binaries allowed to boot
+	Db *Database `protobuf:"bytes,4,opt,name=db,proto3" json:"db,omitempty"`
+	// The forbidden signature database of revoked certificates and binaries
+	Dbx *Database `protobuf:"bytes,5,opt,name=dbx,proto3" json:"dbx,omitempty"`
+}
+
+func (x *SecureBootState) Reset() {
+	*x = SecureBootState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SecureBootState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SecureBootState) ProtoMessage() {}
+
+func (x *SecureBootState) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SecureBootState.ProtoReflect.Descriptor instead.
+func (*SecureBootState) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *SecureBootState) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *SecureBootState) GetPk() *Database {
+	if x != nil {
+		return x.Pk
+	}
+	return nil
+}
+
+func (x *SecureBootState) GetKek() *Database {
+	if x != nil {
+		return x.Kek
+	}
+	return nil
+}
+
+func (x *SecureBootState) GetDb() *Database {
+	if x != nil {
+		return x.Db
+	}
+	return nil
+}
+
+func (x *SecureBootState) GetDbx() *Database {
+	if x != nil {
+		return x.Dbx
+	}
+	return nil
+}
+
+// The verified state of a booted machine, obtained from an Attestation
+type MachineState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Platform *PlatformState `protobuf:"bytes,1,opt,name=platform,proto3" json:"platform,omitempty"`
+	// Only set if the Secure Boot variables were measured, and were valid
+	SecureBoot *SecureBootState `protobuf:"bytes,2,opt,name=secure_boot,json=secureBoot,proto3" json:"secure_boot,omitempty"`
+	// The complete parsed TCG Event Log, including those events used to
+	// create the PlatformState.
+	RawEvents []*Event `protobuf:"bytes,3,rep,name=raw_events,json=rawEvents,proto3" json:"raw_events,omitempty"`
+	// The hash algorithm used when verifying the Attestation. This indicates:
+	//   - which PCR bank was used for for quote validation and event log replay
+	//   - the hash algorithm used to calculate event digests
+	Hash tpm.HashAlgo `protobuf:"varint,4,opt,name=hash,proto3,enum=tpm.HashAlgo" json:"hash,omitempty"`
+	// Only set if the TPM's EK certificate was provided and verified
+	TpmInfo *TpmInfo `protobuf:"bytes,5,opt,name=tpm_info,json=tpmInfo,proto3" json:"tpm_info,omitempty"`
+	// Only set if a kernel command line, kernel image or initrd measurement was
+	// found
+	LinuxKernel *LinuxKernelState `protobuf:"bytes,6,opt,name=linux_kernel,json=linuxKernel,proto3" json:"linux_kernel,omitempty"`
+	// The TPM name of the AK which signed the verified quote, identifying the
+	// machine. Encoded as a TPMT_HA: the name algorithm followed by the digest
+	// of the AK's public area.
+	AkName []byte `protobuf:"bytes,7,opt,name=ak_name,json=akName,proto3" json:"ak_name,omitempty"`
+	// Policy failures which were accepted because of a waiver, recorded by
+	// server.PolicyResult.Record to keep an audit trail of the waivers in use.
+	PolicyWarnings []*PolicyWarning `protobuf:"bytes,8,rep,name=policy_warnings,json=policyWarnings,proto3" json:"policy_warnings,omitempty"`
+	// Only set if systemd-stub measurements were found, and PCR 11 was verified
+	// with the event log (not with a signed PCR policy)
+	SystemdStub *SystemdStubState `protobuf:"bytes,9,opt,name=systemd_stub,json=systemdStub,proto3" json:"systemd_stub,omitempty"`
+	// Only set if GRUB measurements were found, and all the measured commands
+	// matched their digests
+	Grub *GrubState `protobuf:"bytes,10,opt,name=grub,proto3" json:"grub,omitempty"`
+	// The clock state of the TPM when it signed the verified quote, identifying
+	// the boot session the event log was replayed for
+	ClockInfo *ClockInfo `protobuf:"bytes,11,opt,name=clock_info,json=clockInfo,proto3" json:"clock_info,omitempty"`
+	// The configuration files measured into the Canonical Event Log (see
+	// cel.MeasureConfigFiles), in the order they were measured. A file measured
+	// several times (e.g. each time an agent starts) appears once per
+	// measurement.
+	ConfigFiles []*ConfigFile `protobuf:"bytes,12,rep,name=config_files,json=configFiles,proto3" json:"config_files,omitempty"`
+	// The capabilities the TPM reported when it attested, if the Attestation
+	// included them. They are not covered by the quotes, so they are as
+	// reported by the attested machine, and are not verified. (The quotes'
+	// firmwareVersion is obfuscated unless the AK is in the endorsement or
+	// platform hierarchy, so it cannot be compared.)
+	TpmCapabilities *TpmCapabilities `protobuf:"bytes,13,opt,name=tpm_capabilities,json=tpmCapabilities,proto3" json:"tpm_capabilities,omitempty"`
+	// Whether the AK (or a key which signed additional quotes), or the EK in
+	// the verified EK certificate, is an RSA key vulnerable to ROCA
+	// (CVE-2017-15361). Such keys fail verification, unless
+	// server.VerifyOpts.AllowROCAVulnerableKeys is set.
+	RocaVulnerableAk bool `protobuf:"varint,14,opt,name=roca_vulnerable_ak,json=rocaVulnerableAk,proto3" json:"roca_vulnerable_ak,omitempty"`
+	RocaVulnerableEk bool `protobuf:"varint,15,opt,name=roca_vulnerable_ek,json=rocaVulnerableEk,proto3" json:"roca_vulnerable_ek,omitempty"`
+	// The executions outside the attested machine's allowlist, measured into
+	// the Canonical Event Log as they happened (see cel.ExecCollector), in the
+	// order they were measured. Each executable is listed once for each
+	// distinct digest it was executed with.
+	Executions []*Execution `protobuf:"bytes,16,rep,name=executions,proto3" json:"executions,omitempty"`
+	// The attestation's clear indicators, as reported by the attester (they
+	// are not verified)
+	ClearIndicators *ClearIndicators `protobuf:"bytes,17,opt,name=clear_indicators,json=clearIndicators,proto3" json:"clear_indicators,omitempty"`
+	// Whether the TPM was cleared since an earlier attestation of the same
+	// machine, if one was provided (see server.VerifyOpts.PreviousState)
+	TpmClear *TpmClearStatus `protobuf:"bytes,18,opt,name=tpm_clear,json=tpmClear,proto3" json:"tpm_clear,omitempty"`
+	// The key lifecycle events in the attestation's key journal, in the order
+	// they were recorded. Their digests were extended into the certified NV
+	// index, so they cannot be changed or removed without the index changing.
+	KeyEvents []*KeyLifecycleEvent `protobuf:"bytes,19,rep,name=key_events,json=keyEvents,proto3" json:"key_events,omitempty"`
+	// The purposes keys were derived for from TPM master keys, recorded in the
+	// Canonical Event Log (see client.KeyDeriver), in the order they were first
+	// used.
+	KeyDerivations []*KeyDerivation `protobuf:"bytes,20,rep,name=key_derivations,json=keyDerivations,proto3" json:"key_derivations,omitempty"`
+}
+
+func (x *MachineState) Reset() {
+	*x = MachineState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MachineState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MachineState) ProtoMessage() {}
+
+func (x *MachineState) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MachineState.ProtoReflect.Descriptor instead.
+func (*MachineState) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *MachineState) GetPlatform() *PlatformState {
+	if x != nil {
+		return x.Platform
+	}
+	return nil
+}
+
+func (x *MachineState) GetSecureBoot() *SecureBootState {
+	if x != nil {
+		return x.SecureBoot
+	}
+	return nil
+}
+
+func (x *MachineState) GetRawEvents() []*Event {
+	if x != nil {
+		return x.RawEvents
+	}
+	return nil
+}
+
+func (x *MachineState) GetHash() tpm.HashAlgo {
+	if x != nil {
+		return x.Hash
+	}
+	return tpm.HashAlgo(0)
+}
+
+func (x *MachineState) GetTpmInfo() *TpmInfo {
+	if x != nil {
+		return x.TpmInfo
+	}
+	return nil
+}
+
+func (x *MachineState) GetLinuxKernel() *LinuxKernelState {
+	if x != nil {
+		return x.LinuxKernel
+	}
+	return nil
+}
+
+func (x *MachineState) GetAkName() []byte {
+	if x != nil {
+		return x.AkName
+	}
+	return nil
+}
+
+func (x *MachineState) GetPolicyWarnings() []*PolicyWarning {
+	if x != nil {
+		return x.PolicyWarnings
+	}
+	return nil
+}
+
+func (x *MachineState) GetSystemdStub() *SystemdStubState {
+	if x != nil {
+		return x.SystemdStub
+	}
+	return nil
+}
+
+func (x *MachineState) GetGrub() *GrubState {
+	if x != nil {
+		return x.Grub
+	}
+	return nil
+}
+
+func (x *MachineState) GetClockInfo() *ClockInfo {
+	if x != nil {
+		return x.ClockInfo
+	}
+	return nil
+}
+
+func (x *MachineState) GetConfigFiles() []*ConfigFile {
+	if x != nil {
+		return x.ConfigFiles
+	}
+	return nil
+}
+
+func (x *MachineState) GetTpmCapabilities() *TpmCapabilities {
+	if x != nil {
+		return x.TpmCapabilities
+	}
+	return nil
+}
+
+func (x *MachineState) GetRocaVulnerableAk() bool {
+	if x != nil {
+		return x.RocaVulnerableAk
+	}
+	return false
+}
+
+func (x *MachineState) GetRocaVulnerableEk() bool {
+	if x != nil {
+		return x.RocaVulnerableEk
+	}
+	return false
+}
+
+func (x *MachineState) GetExecutions() []*Execution {
+	if x != nil {
+		return x.Executions
+	}
+	return nil
+}
+
+func (x *MachineState) GetClearIndicators() *ClearIndicators {
+	if x != nil {
+		return x.ClearIndicators
+	}
+	return nil
+}
+
+func (x *MachineState) GetTpmClear() *TpmClearStatus {
+	if x != nil {
+		return x.TpmClear
+	}
+	return nil
+}
+
+func (x *MachineState) GetKeyEvents() []*KeyLifecycleEvent {
+	if x != nil {
+		return x.KeyEvents
+	}
+	return nil
+}
+
+func (x *MachineState) GetKeyDerivations() []*KeyDerivation {
+	if x != nil {
+		return x.KeyDerivations
+	}
+	return nil
+}
+
+// A key lifecycle event recorded in a key journal (see cel.KeyEvent)
+type KeyLifecycleEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Action KeyAction `protobuf:"varint,1,opt,name=action,proto3,enum=attest.KeyAction" json:"action,omitempty"`
+	// The Name of the key, encoded as a TPMT_HA
+	Name []byte `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// The persistent handle, for KEY_PERSISTED and KEY_EVICTED events
+	Handle uint32 `protobuf:"varint,3,opt,name=handle,proto3" json:"handle,omitempty"`
+	// A description of the key's purpose, such as "ak" or "tls"
+	Label string `protobuf:"bytes,4,opt,name=label,proto3" json:"label,omitempty"`
+	// The Name of the key replaced by a KEY_ROTATED event
+	PreviousName []byte `protobuf:"bytes,5,opt,name=previous_name,json=previousName,proto3" json:"previous_name,omitempty"`
+}
+
+func (x *KeyLifecycleEvent) Reset() {
+	*x = KeyLifecycleEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KeyLifecycleEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyLifecycleEvent) ProtoMessage() {}
+
+func (x *KeyLifecycleEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyLifecycleEvent.ProtoReflect.Descriptor instead.
+func (*KeyLifecycleEvent) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *KeyLifecycleEvent) GetAction() KeyAction {
+	if x != nil {
+		return x.Action
+	}
+	return KeyAction_KEY_ACTION_UNSPECIFIED
+}
+
+func (x *KeyLifecycleEvent) GetName() []byte {
+	if x != nil {
+		return x.Name
+	}
+	return nil
+}
+
+func (x *KeyLifecycleEvent) GetHandle() uint32 {
+	if x != nil {
+		return x.Handle
+	}
+	return 0
+}
+
+func (x *KeyLifecycleEvent) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *KeyLifecycleEvent) GetPreviousName() []byte {
+	if x != nil {
+		return x.PreviousName
+	}
+	return nil
+}
+
+// The first derivation of keys for a purpose, recorded in the Canonical Event
+// Log (see cel.KeyDerivationEvent)
+type KeyDerivation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The Name of the master key, encoded as a TPMT_HA
+	KeyName []byte `protobuf:"bytes,1,opt,name=key_name,json=keyName,proto3" json:"key_name,omitempty"`
+	// The label of the derived keys' purpose
+	Label string `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
+}
+
+func (x *KeyDerivation) Reset() {
+	*x = KeyDerivation{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KeyDerivation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyDerivation) ProtoMessage() {}
+
+func (x *KeyDerivation) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyDerivation.ProtoReflect.Descriptor instead.
+func (*KeyDerivation) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *KeyDerivation) GetKeyName() []byte {
+	if x != nil {
+		return x.KeyName
+	}
+	return nil
+}
+
+func (x *KeyDerivation) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+// An execution measured into the Canonical Event Log
+type Execution struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The absolute path of the executed file
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// The SHA-256 digest of the file's contents when it was executed, empty if
+	// the file could not be read
+	Digest []byte `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+}
+
+func (x *Execution) Reset() {
+	*x = Execution{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Execution) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Execution) ProtoMessage() {}
+
+func (x *Execution) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Execution.ProtoReflect.Descriptor instead.
+func (*Execution) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *Execution) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Execution) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+// A configuration file measured into the Canonical Event Log
+type ConfigFile struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The absolute path of the file
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// The SHA-256 digest of the file's contents, empty if the file did not
+	// exist
+	Digest []byte `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+}
+
+func (x *ConfigFile) Reset() {
+	*x = ConfigFile{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConfigFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigFile) ProtoMessage() {}
+
+func (x *ConfigFile) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigFile.ProtoReflect.Descriptor instead.
+func (*ConfigFile) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ConfigFile) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ConfigFile) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+// The TPM's clock state when it signed a quote, from the quote's
+// TPMS_CLOCK_INFO. The reset and restart counts are obfuscated by the TPM when
+// the AK is not in the endorsement or platform hierarchy, but the obfuscation
+// only depends on the AK, so the counts can be compared between quotes signed
+// by the same AK.
+type ClockInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Milliseconds the TPM has been powered on since it was last cleared
+	Clock uint64 `protobuf:"varint,1,opt,name=clock,proto3" json:"clock,omitempty"`
+	// Incremented each time the TPM is reset (e.g. the machine reboots)
+	ResetCount uint32 `protobuf:"varint,2,opt,name=reset_count,json=resetCount,proto3" json:"reset_count,omitempty"`
+	// Incremented each time the TPM is restarted (e.g. the machine resumes from
+	// hibernation), and cleared on reset
+	RestartCount uint32 `protobuf:"varint,3,opt,name=restart_count,json=restartCount,proto3" json:"restart_count,omitempty"`
+	// False if the TPM may have reported a larger clock before
+	Safe bool `protobuf:"varint,4,opt,name=safe,proto3" json:"safe,omitempty"`
+}
+
+func (x *ClockInfo) Reset() {
+	*x = ClockInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClockInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClockInfo) ProtoMessage() {}
+
+func (x *ClockInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClockInfo.ProtoReflect.Descriptor instead.
+func (*ClockInfo) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ClockInfo) GetClock() uint64 {
+	if x != nil {
+		return x.Clock
+	}
+	return 0
+}
+
+func (x *ClockInfo) GetResetCount() uint32 {
+	if x != nil {
+		return x.ResetCount
+	}
+	return 0
+}
+
+func (x *ClockInfo) GetRestartCount() uint32 {
+	if x != nil {
+		return x.RestartCount
+	}
+	return 0
+}
+
+func (x *ClockInfo) GetSafe() bool {
+	if x != nil {
+		return x.Safe
+	}
+	return false
+}
+
+// Why and when a verified MachineState must be revalidated
+type RevalidationHint struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Trigger RevalidationTrigger `protobuf:"varint,1,opt,name=trigger,proto3,enum=attest.RevalidationTrigger" json:"trigger,omitempty"`
+	// A human-readable description, such as the waived rule
+	Detail string `protobuf:"bytes,2,opt,name=detail,proto3" json:"detail,omitempty"`
+	// When the hint applies, if known (e.g. the waiver's expiry time)
+	Time *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+}
+
+func (x *RevalidationHint) Reset() {
+	*x = RevalidationHint{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RevalidationHint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevalidationHint) ProtoMessage() {}
+
+func (x *RevalidationHint) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevalidationHint.ProtoReflect.Descriptor instead.
+func (*RevalidationHint) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *RevalidationHint) GetTrigger() RevalidationTrigger {
+	if x != nil {
+		return x.Trigger
+	}
+	return RevalidationTrigger_REVALIDATION_TRIGGER_UNSPECIFIED
+}
+
+func (x *RevalidationHint) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+func (x *RevalidationHint) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+// How long a verified MachineState may be cached, computed by
+// server.ComputeValidity from the evidence and policy it was verified with
+type ResultValidity struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	NotBefore *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	// The result must not be used after this time, even if no hint applies
+	NotAfter *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`
+	Hints    []*RevalidationHint    `protobuf:"bytes,3,rep,name=hints,proto3" json:"hints,omitempty"`
+}
+
+func (x *ResultValidity) Reset() {
+	*x = ResultValidity{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResultValidity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResultValidity) ProtoMessage() {}
+
+func (x *ResultValidity) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResultValidity.ProtoReflect.Descriptor instead.
+func (*ResultValidity) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ResultValidity) GetNotBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotBefore
+	}
+	return nil
+}
+
+func (x *ResultValidity) GetNotAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotAfter
+	}
+	return nil
+}
+
+func (x *ResultValidity) GetHints() []*RevalidationHint {
+	if x != nil {
+		return x.Hints
+	}
+	return nil
+}
+
+// One dimension of a TrustworthinessVector
+type TrustClaim struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The AR4SI claim value, such as server.TrustApprovedConfig
+	Value int32     `protobuf:"varint,1,opt,name=value,proto3" json:"value,omitempty"`
+	Tier  TrustTier `protobuf:"varint,2,opt,name=tier,proto3,enum=attest.TrustTier" json:"tier,omitempty"`
+	// The findings which led to the value, if any
+	Reasons []string `protobuf:"bytes,3,rep,name=reasons,proto3" json:"reasons,omitempty"`
+}
+
+func (x *TrustClaim) Reset() {
+	*x = TrustClaim{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TrustClaim) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrustClaim) ProtoMessage() {}
+
+func (x *TrustClaim) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrustClaim.ProtoReflect.Descriptor instead.
+func (*TrustClaim) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *TrustClaim) GetValue() int32 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *TrustClaim) GetTier() TrustTier {
+	if x != nil {
+		return x.Tier
+	}
+	return TrustTier_TRUST_TIER_NONE
+}
+
+func (x *TrustClaim) GetReasons() []string {
+	if x != nil {
+		return x.Reasons
+	}
+	return nil
+}
+
+// A graded appraisal of an attestation, computed by
+// server.AppraiseAttestation, with one AR4SI trustworthiness claim for each
+// dimension of the machine's state. Relying parties can authorize on the
+// dimensions they care about, rather than on a single pass or fail.
+type TrustworthinessVector struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Whether the machine's TPM and AK are recognized and not compromised
+	InstanceIdentity *TrustClaim `protobuf:"bytes,1,opt,name=instance_identity,json=instanceIdentity,proto3" json:"instance_identity,omitempty"`
+	// Whether the machine's Secure Boot, kernel and config file configuration
+	// is approved
+	Configuration *TrustClaim `protobuf:"bytes,2,opt,name=configuration,proto3" json:"configuration,omitempty"`
+	// Whether the machine booted approved executables
+	Executables *TrustClaim `protobuf:"bytes,3,opt,name=executables,proto3" json:"executables,omitempty"`
+	// Whether the TPM and platform firmware are genuine and not vulnerable
+	Hardware *TrustClaim `protobuf:"bytes,4,opt,name=hardware,proto3" json:"hardware,omitempty"`
+}
+
+func (x *TrustworthinessVector) Reset() {
+	*x = TrustworthinessVector{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TrustworthinessVector) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrustworthinessVector) ProtoMessage() {}
+
+func (x *TrustworthinessVector) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrustworthinessVector.ProtoReflect.Descriptor instead.
+func (*TrustworthinessVector) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *TrustworthinessVector) GetInstanceIdentity() *TrustClaim {
+	if x != nil {
+		return x.InstanceIdentity
+	}
+	return nil
+}
+
+func (x *TrustworthinessVector) GetConfiguration() *TrustClaim {
+	if x != nil {
+		return x.Configuration
+	}
+	return nil
+}
+
+func (x *TrustworthinessVector) GetExecutables() *TrustClaim {
+	if x != nil {
+		return x.Executables
+	}
+	return nil
+}
+
+func (x *TrustworthinessVector) GetHardware() *TrustClaim {
+	if x != nil {
+		return x.Hardware
+	}
+	return nil
+}
+
+// A policy dictating which values of PlatformState to allow
+type PlatformPolicy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// If PlatformState.firmware contains a scrtm_version_id, it must appear
+	// in this list. For use with a GCE VM, minimum_gce_firmware_version is
+	// often a better alternative.
+	AllowedScrtmVersionIds [][]byte `protobuf:"bytes,1,rep,name=allowed_scrtm_version_ids,json=allowedScrtmVersionIds,proto3" json:"allowed_scrtm_version_ids,omitempty"`
+	// If PlatformState.firmware contains a minimum_gce_firmware_version, it must
+	// be greater than or equal to this value. Currently, the max version is 1.
+	MinimumGceFirmwareVersion uint32 `protobuf:"varint,2,opt,name=minimum_gce_firmware_version,json=minimumGceFirmwareVersion,proto3" json:"minimum_gce_firmware_version,omitempty"`
+	// The PlatformState's technology must be at least as secure as
+	// the specified minimum_technology (i.e. AMD_SEV_ES > AMD_SEV > NONE).
+	MinimumTechnology GCEConfidentialTechnology `protobuf:"varint,3,opt,name=minimum_technology,json=minimumTechnology,proto3,enum=attest.GCEConfidentialTechnology" json:"minimum_technology,omitempty"`
+}
+
+func (x *PlatformPolicy) Reset() {
+	*x = PlatformPolicy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PlatformPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlatformPolicy) ProtoMessage() {}
+
+func (x *PlatformPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlatformPolicy.ProtoReflect.Descriptor instead.
+func (*PlatformPolicy) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *PlatformPolicy) GetAllowedScrtmVersionIds() [][]byte {
+	if x != nil {
+		return x.AllowedScrtmVersionIds
+	}
+	return nil
+}
+
+func (x *PlatformPolicy) GetMinimumGceFirmwareVersion() uint32 {
+	if x != nil {
+		return x.MinimumGceFirmwareVersion
+	}
+	return 0
+}
+
+func (x *PlatformPolicy) GetMinimumTechnology() GCEConfidentialTechnology {
+	if x != nil {
+		return x.MinimumTechnology
+	}
+	return GCEConfidentialTechnology_NONE
+}
+
+// A temporary exception to a single Policy rule. While a waiver is in effect,
+// a MachineState failing the waived rule is still accepted, but the failure is
+// reported as a warning in the policy evaluation result.
+type PolicyWaiver struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The rule being waived, named by its field path within the Policy
+	// (e.g. "platform.minimum_gce_firmware_version").
+	Rule string `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
+	// If set, the waiver only applies to the machine whose AK has this TPM name
+	// (see MachineState.ak_name). Otherwise, the waiver applies to all machines.
+	AkName []byte `protobuf:"bytes,2,opt,name=ak_name,json=akName,proto3" json:"ak_name,omitempty"`
+	// The waiver has no effect at or after this time. Must be set.
+	ExpireTime *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expire_time,json=expireTime,proto3" json:"expire_time,omitempty"`
+	// Why the exception was granted. Must be non-empty.
+	Justification string `protobuf:"bytes,4,opt,name=justification,proto3" json:"justification,omitempty"`
+	// Who approved the exception. Must be non-empty.
+	Approver string `protobuf:"bytes,5,opt,name=approver,proto3" json:"approver,omitempty"`
+}
+
+func (x *PolicyWaiver) Reset() {
+	*x = PolicyWaiver{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PolicyWaiver) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PolicyWaiver) ProtoMessage() {}
+
+func (x *PolicyWaiver) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PolicyWaiver.ProtoReflect.Descriptor instead.
+func (*PolicyWaiver) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *PolicyWaiver) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *PolicyWaiver) GetAkName() []byte {
+	if x != nil {
+		return x.AkName
+	}
+	return nil
+}
+
+func (x *PolicyWaiver) GetExpireTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpireTime
+	}
+	return nil
+}
+
+func (x *PolicyWaiver) GetJustification() string {
+	if x != nil {
+		return x.Justification
+	}
+	return ""
+}
+
+func (x *PolicyWaiver) GetApprover() string {
+	if x != nil {
+		return x.Approver
+	}
+	return ""
+}
+
+// A Policy rule which a MachineState failed, but which was waived
+type PolicyWarning struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The rule which the MachineState failed
+	Rule string `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
+	// Why the MachineState failed the rule
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	// The waiver which allowed the failure
+	Waiver *PolicyWaiver `protobuf:"bytes,3,opt,name=waiver,proto3" json:"waiver,omitempty"`
+}
+
+func (x *PolicyWarning) Reset() {
+	*x = PolicyWarning{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PolicyWarning) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PolicyWarning) ProtoMessage() {}
+
+func (x *PolicyWarning) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PolicyWarning.ProtoReflect.Descriptor instead.
+func (*PolicyWarning) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *PolicyWarning) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *PolicyWarning) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *PolicyWarning) GetWaiver() *PolicyWaiver {
+	if x != nil {
+		return x.Waiver
+	}
+	return nil
+}
+
+// A policy dictating which LinuxKernelState values to allow. Unknown values
+// never satisfy the policy.
+type KernelPolicy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The kernel must be locked down in at least this mode
+	MinimumLockdown LockdownMode `protobuf:"varint,1,opt,name=minimum_lockdown,json=minimumLockdown,proto3,enum=attest.LockdownMode" json:"minimum_lockdown,omitempty"`
+	// The kernel must only load modules with valid signatures
+	RequireModuleSignatures bool `protobuf:"varint,2,opt,name=require_module_signatures,json=requireModuleSignatures,proto3" json:"require_module_signatures,omitempty"`
+	// The kernel must have disabled the kexec_load system call
+	RequireKexecLoadDisabled bool `protobuf:"varint,3,opt,name=require_kexec_load_disabled,json=requireKexecLoadDisabled,proto3" json:"require_kexec_load_disabled,omitempty"`
+}
+
+func (x *KernelPolicy) Reset() {
+	*x = KernelPolicy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[30]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KernelPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KernelPolicy) ProtoMessage() {}
+
+func (x *KernelPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[30]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KernelPolicy.ProtoReflect.Descriptor instead.
+func (*KernelPolicy) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *KernelPolicy) GetMinimumLockdown() LockdownMode {
+	if x != nil {
+		return x.MinimumLockdown
+	}
+	return LockdownMode_LOCKDOWN_UNKNOWN
+}
+
+func (x *KernelPolicy) GetRequireModuleSignatures() bool {
+	if x != nil {
+		return x.RequireModuleSignatures
+	}
+	return false
+}
+
+func (x *KernelPolicy) GetRequireKexecLoadDisabled() bool {
+	if x != nil {
+		return x.RequireKexecLoadDisabled
+	}
+	return false
+}
+
+// A range of firmware versions of one TPM manufacturer
+type TpmFirmwareRange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// TCG vendor ID of the TPM manufacturer (e.g. 0x49465800 for Infineon)
+	ManufacturerId uint32 `protobuf:"varint,1,opt,name=manufacturer_id,json=manufacturerId,proto3" json:"manufacturer_id,omitempty"`
+	// The first and last firmware versions in the range, compared with
+	// TPM_PT_FIRMWARE_VERSION_1 (the upper 32 bits of
+	// TpmCapabilities.firmware_version). For Infineon TPMs, the major version
+	// is in the upper 16 bits, and the minor version in the lower 16 bits
+	// (e.g. 0x00070055 for 7.85).
+	MinimumFirmwareVersion uint32 `protobuf:"varint,2,opt,name=minimum_firmware_version,json=minimumFirmwareVersion,proto3" json:"minimum_firmware_version,omitempty"`
+	MaximumFirmwareVersion uint32 `protobuf:"varint,3,opt,name=maximum_firmware_version,json=maximumFirmwareVersion,proto3" json:"maximum_firmware_version,omitempty"`
+	// Why the range is denied (e.g. "ROCA, CVE-2017-15361"), for error messages
+	Reason string `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+}
+
+func (x *TpmFirmwareRange) Reset() {
+	*x = TpmFirmwareRange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[31]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TpmFirmwareRange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TpmFirmwareRange) ProtoMessage() {}
+
+func (x *TpmFirmwareRange) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[31]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TpmFirmwareRange.ProtoReflect.Descriptor instead.
+func (*TpmFirmwareRange) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *TpmFirmwareRange) GetManufacturerId() uint32 {
+	if x != nil {
+		return x.ManufacturerId
+	}
+	return 0
+}
+
+func (x *TpmFirmwareRange) GetMinimumFirmwareVersion() uint32 {
+	if x != nil {
+		return x.MinimumFirmwareVersion
+	}
+	return 0
+}
+
+func (x *TpmFirmwareRange) GetMaximumFirmwareVersion() uint32 {
+	if x != nil {
+		return x.MaximumFirmwareVersion
+	}
+	return 0
+}
+
+func (x *TpmFirmwareRange) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// A policy dictating which TPMs to allow
+type TpmPolicy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The TPM's firmware version must not be in any of these ranges. Both the
+	// version in MachineState.tpm_info (verified, from when the EK certificate
+	// was issued) and the one in MachineState.tpm_capabilities (as reported by
+	// the machine, and not verified) are checked, if present, so a firmware
+	// update reported by the machine cannot override its EK certificate.
+	// Machines whose TPM version is unknown do not satisfy the policy.
+	DeniedFirmware []*TpmFirmwareRange `protobuf:"bytes,1,rep,name=denied_firmware,json=deniedFirmware,proto3" json:"denied_firmware,omitempty"`
+}
+
+func (x *TpmPolicy) Reset() {
+	*x = TpmPolicy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[32]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TpmPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TpmPolicy) ProtoMessage() {}
+
+func (x *TpmPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[32]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TpmPolicy.ProtoReflect.Descriptor instead.
+func (*TpmPolicy) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *TpmPolicy) GetDeniedFirmware() []*TpmFirmwareRange {
+	if x != nil {
+		return x.DeniedFirmware
+	}
+	return nil
+}
+
+// The allowed contents of a measured configuration file
+type ConfigFilePolicy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The absolute path of the file
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// The SHA-256 digests of the allowed contents of the file. To allow the
+	// file to be missing, include an empty digest.
+	AllowedDigests [][]byte `protobuf:"bytes,2,rep,name=allowed_digests,json=allowedDigests,proto3" json:"allowed_digests,omitempty"`
+}
+
+func (x *ConfigFilePolicy) Reset() {
+	*x = ConfigFilePolicy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[33]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConfigFilePolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigFilePolicy) ProtoMessage() {}
+
+func (x *ConfigFilePolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[33]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigFilePolicy.ProtoReflect.Descriptor instead.
+func (*ConfigFilePolicy) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *ConfigFilePolicy) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ConfigFilePolicy) GetAllowedDigests() [][]byte {
+	if x != nil {
+		return x.AllowedDigests
+	}
+	return nil
+}
+
+// A policy dictating which type of MachineStates to allow
+type Policy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Platform *PlatformPolicy `protobuf:"bytes,1,opt,name=platform,proto3" json:"platform,omitempty"`
+	// Exceptions to the rules above, see PolicyWaiver.
+	Waivers []*PolicyWaiver `protobuf:"bytes,3,rep,name=waivers,proto3" json:"waivers,omitempty"`
+	Kernel  *KernelPolicy   `protobuf:"bytes,4,opt,name=kernel,proto3" json:"kernel,omitempty"`
+	// Every file listed must have been measured, and every measurement of it
+	// must have one of its allowed digests. Unlisted files are not checked.
+	ConfigFiles []*ConfigFilePolicy `protobuf:"bytes,5,rep,name=config_files,json=configFiles,proto3" json:"config_files,omitempty"`
+	Tpm         *TpmPolicy          `protobuf:"bytes,6,opt,name=tpm,proto3" json:"tpm,omitempty"`
+}
+
+func (x *Policy) Reset() {
+	*x = Policy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[34]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Policy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Policy) ProtoMessage() {}
+
+func (x *Policy) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[34]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Policy.ProtoReflect.Descriptor instead.
+func (*Policy) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *Policy) GetPlatform() *PlatformPolicy {
+	if x != nil {
+		return x.Platform
+	}
+	return nil
+}
+
+func (x *Policy) GetWaivers() []*PolicyWaiver {
+	if x != nil {
+		return x.Waivers
+	}
+	return nil
+}
+
+func (x *Policy) GetKernel() *KernelPolicy {
+	if x != nil {
+		return x.Kernel
+	}
+	return nil
+}
+
+func (x *Policy) GetConfigFiles() []*ConfigFilePolicy {
+	if x != nil {
+		return x.ConfigFiles
+	}
+	return nil
+}
+
+func (x *Policy) GetTpm() *TpmPolicy {
+	if x != nil {
+		return x.Tpm
+	}
+	return nil
+}
+
+// The first message sent by each peer when establishing an attested channel
+// (see the channel package). Both peers then send an Attestation, followed by
+// an EncryptedCredential.
+type ChannelHello struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// A fresh random nonce, to which the other peer's attestation is bound
+	Nonce []byte `protobuf:"bytes,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	// The sending peer's EK public key, in PKIX, ASN.1 DER format
+	EkPub []byte `protobuf:"bytes,2,opt,name=ek_pub,json=ekPub,proto3" json:"ek_pub,omitempty"`
+	// The sending peer's EK certificate, DER encoded (optional)
+	EkCert []byte `protobuf:"bytes,3,opt,name=ek_cert,json=ekCert,proto3" json:"ek_cert,omitempty"`
+}
+
+func (x *ChannelHello) Reset() {
+	*x = ChannelHello{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[35]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ChannelHello) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChannelHello) ProtoMessage() {}
+
+func (x *ChannelHello) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[35]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChannelHello.ProtoReflect.Descriptor instead.
+func (*ChannelHello) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *ChannelHello) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *ChannelHello) GetEkPub() []byte {
+	if x != nil {
+		return x.EkPub
+	}
+	return nil
+}
+
+func (x *ChannelHello) GetEkCert() []byte {
+	if x != nil {
+		return x.EkCert
+	}
+	return nil
+}
+
+// An AK certificate enrollment which is waiting for the client to recover the
+// secret from its credential challenge (see server.AKCertIssuer).
+type AKEnrollment struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The AK being certified, encoded as a TPMT_PUBLIC
+	AkPub []byte `protobuf:"bytes,1,opt,name=ak_pub,json=akPub,proto3" json:"ak_pub,omitempty"`
+	// The attributes of the TPM containing the AK, if known
+	TpmInfo *TpmInfo `protobuf:"bytes,2,opt,name=tpm_info,json=tpmInfo,proto3" json:"tpm_info,omitempty"`
+	// SHA-256 digest of the challenge secret
+	SecretDigest []byte `protobuf:"bytes,3,opt,name=secret_digest,json=secretDigest,proto3" json:"secret_digest,omitempty"`
+	// The enrollment must be completed before this time
+	ExpireTime *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=expire_time,json=expireTime,proto3" json:"expire_time,omitempty"`
+	// The EK of the TPM containing the AK, in PKIX, ASN.1 DER format
+	EkPub []byte `protobuf:"bytes,5,opt,name=ek_pub,json=ekPub,proto3" json:"ek_pub,omitempty"`
+}
+
+func (x *AKEnrollment) Reset() {
+	*x = AKEnrollment{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[36]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AKEnrollment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AKEnrollment) ProtoMessage() {}
+
+func (x *AKEnrollment) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[36]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AKEnrollment.ProtoReflect.Descriptor instead.
+func (*AKEnrollment) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *AKEnrollment) GetAkPub() []byte {
+	if x != nil {
+		return x.AkPub
+	}
+	return nil
+}
+
+func (x *AKEnrollment) GetTpmInfo() *TpmInfo {
+	if x != nil {
+		return x.TpmInfo
+	}
+	return nil
+}
+
+func (x *AKEnrollment) GetSecretDigest() []byte {
+	if x != nil {
+		return x.SecretDigest
+	}
+	return nil
+}
+
+func (x *AKEnrollment) GetExpireTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpireTime
+	}
+	return nil
+}
+
+func (x *AKEnrollment) GetEkPub() []byte {
+	if x != nil {
+		return x.EkPub
+	}
+	return nil
+}
+
+// A WireGuard key pair, with the private key sealed to the TPM's PCRs (see the
+// wireguard package)
+type WireGuardKey struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The Curve25519 public key
+	PublicKey []byte `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	// The Curve25519 private key, sealed to the PCRs of the machine's TPM
+	SealedPrivateKey *tpm.SealedBytes `protobuf:"bytes,2,opt,name=sealed_private_key,json=sealedPrivateKey,proto3" json:"sealed_private_key,omitempty"`
+}
+
+func (x *WireGuardKey) Reset() {
+	*x = WireGuardKey{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[37]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WireGuardKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WireGuardKey) ProtoMessage() {}
+
+func (x *WireGuardKey) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[37]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WireGuardKey.ProtoReflect.Descriptor instead.
+func (*WireGuardKey) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *WireGuardKey) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *WireGuardKey) GetSealedPrivateKey() *tpm.SealedBytes {
+	if x != nil {
+		return x.SealedPrivateKey
+	}
+	return nil
+}
+
+// A request to register a machine's WireGuard public key with a server, which
+// only accepts it if the machine's Attestation verifies
+type WireGuardRegistration struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The Curve25519 public key
+	PublicKey []byte `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	// An Attestation whose nonce is bound to the server's nonce and the public
+	// key
+	Attestation *Attestation `protobuf:"bytes,2,opt,name=attestation,proto3" json:"attestation,omitempty"`
+}
+
+func (x *WireGuardRegistration) Reset() {
+	*x = WireGuardRegistration{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[38]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WireGuardRegistration) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WireGuardRegistration) ProtoMessage() {}
+
+func (x *WireGuardRegistration) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[38]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WireGuardRegistration.ProtoReflect.Descriptor instead.
+func (*WireGuardRegistration) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *WireGuardRegistration) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *WireGuardRegistration) GetAttestation() *Attestation {
+	if x != nil {
+		return x.Attestation
+	}
+	return nil
+}
+
+// A build artifact (see the provenance package)
+type BuildSubject struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The artifact's name, such as its path relative to the build directory
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// SHA-256 digest of the artifact's contents
+	Sha256 []byte `protobuf:"bytes,2,opt,name=sha256,proto3" json:"sha256,omitempty"`
+}
+
+func (x *BuildSubject) Reset() {
+	*x = BuildSubject{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[39]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BuildSubject) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildSubject) ProtoMessage() {}
+
+func (x *BuildSubject) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[39]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildSubject.ProtoReflect.Descriptor instead.
+func (*BuildSubject) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *BuildSubject) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *BuildSubject) GetSha256() []byte {
+	if x != nil {
+		return x.Sha256
+	}
+	return nil
+}
+
+// A name and value describing a build, such as the source commit or the CI
+// workflow which ran it
+type BuildParameter struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *BuildParameter) Reset() {
+	*x = BuildParameter{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[40]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BuildParameter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildParameter) ProtoMessage() {}
+
+func (x *BuildParameter) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[40]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildParameter.ProtoReflect.Descriptor instead.
+func (*BuildParameter) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *BuildParameter) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *BuildParameter) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+// A statement by a CI runner about the artifacts it built
+type BuildStatement struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Identifies the runner or CI system, such as the URL of the runner pool
+	BuilderId string `protobuf:"bytes,1,opt,name=builder_id,json=builderId,proto3" json:"builder_id,omitempty"`
+	// The artifacts built
+	Subjects []*BuildSubject `protobuf:"bytes,2,rep,name=subjects,proto3" json:"subjects,omitempty"`
+	// Parameters of the build, in the order they were given
+	Parameters []*BuildParameter `protobuf:"bytes,3,rep,name=parameters,proto3" json:"parameters,omitempty"`
+}
+
+func (x *BuildStatement) Reset() {
+	*x = BuildStatement{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[41]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BuildStatement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildStatement) ProtoMessage() {}
+
+func (x *BuildStatement) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[41]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildStatement.ProtoReflect.Descriptor instead.
+func (*BuildStatement) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *BuildStatement) GetBuilderId() string {
+	if x != nil {
+		return x.BuilderId
+	}
+	return ""
+}
+
+func (x *BuildStatement) GetSubjects() []*BuildSubject {
+	if x != nil {
+		return x.Subjects
+	}
+	return nil
+}
+
+func (x *BuildStatement) GetParameters() []*BuildParameter {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+// Provenance of build artifacts, signed by the TPM of the runner which built
+// them: the Attestation's nonce is bound to the statement, so the quotes sign
+// the statement along with the runner's state (see the provenance package).
+type BuildProvenance struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Statement   *BuildStatement `protobuf:"bytes,1,opt,name=statement,proto3" json:"statement,omitempty"`
+	Attestation *Attestation    `protobuf:"bytes,2,opt,name=attestation,proto3" json:"attestation,omitempty"`
+}
+
+func (x *BuildProvenance) Reset() {
+	*x = BuildProvenance{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_attest_proto_msgTypes[42]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BuildProvenance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildProvenance) ProtoMessage() {}
+
+func (x *BuildProvenance) ProtoReflect() protoreflect.Message {
+	mi := &file_attest_proto_msgTypes[42]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildProvenance.ProtoReflect.Descriptor instead.
+func (*BuildProvenance) Descriptor() ([]byte, []int) {
+	return file_attest_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *BuildProvenance) GetStatement() *BuildStatement {
+	if x != nil {
+		return x.Statement
+	}
+	return nil
+}
+
+func (x *BuildProvenance) GetAttestation() *Attestation {
+	if x != nil {
+		return x.Attestation
+	}
+	return nil
+}
+
+var File_attest_proto protoreflect.FileDescriptor
+
+var file_attest_proto_rawDesc = []byte{
+	0x0a, 0x0c, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06,
+	0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x09, 0x74, 0x70, 0x6d, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x22, 0xb1, 0x01, 0x0a, 0x0f, 0x47, 0x43, 0x45, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e,
+	0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x7a, 0x6f, 0x6e, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x7a, 0x6f, 0x6e, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x72,
+	0x6f, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x49, 0x64, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x72, 0x6f,
+	0x6a, 0x65, 0x63, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0d, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72,
+	0x12, 0x23, 0x0a, 0x0d, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x5f, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63,
+	0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63,
+	0x65, 0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x69, 0x6e, 0x73, 0x74,
+	0x61, 0x6e, 0x63, 0x65, 0x49, 0x64, 0x22, 0xf4, 0x04, 0x0a, 0x0b, 0x41, 0x74, 0x74, 0x65, 0x73,
+	0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x15, 0x0a, 0x06, 0x61, 0x6b, 0x5f, 0x70, 0x75, 0x62,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x61, 0x6b, 0x50, 0x75, 0x62, 0x12, 0x22, 0x0a,
+	0x06, 0x71, 0x75, 0x6f, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e,
+	0x74, 0x70, 0x6d, 0x2e, 0x51, 0x75, 0x6f, 0x74, 0x65, 0x52, 0x06, 0x71, 0x75, 0x6f, 0x74, 0x65,
+	0x73, 0x12, 0x1b, 0x0a, 0x09, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x5f, 0x6c, 0x6f, 0x67, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x4c, 0x6f, 0x67, 0x12, 0x3c,
+	0x0a, 0x0d, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x47,
+	0x43, 0x45, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0c,
+	0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x2e, 0x0a, 0x13,
+	0x63, 0x61, 0x6e, 0x6f, 0x6e, 0x69, 0x63, 0x61, 0x6c, 0x5f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x5f,
+	0x6c, 0x6f, 0x67, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x11, 0x63, 0x61, 0x6e, 0x6f, 0x6e,
+	0x69, 0x63, 0x61, 0x6c, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x4c, 0x6f, 0x67, 0x12, 0x2c, 0x0a, 0x0a,
+	0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x0d, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x48, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f, 0x52,
+	0x09, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x48, 0x61, 0x73, 0x68, 0x12, 0x45, 0x0a, 0x11, 0x61, 0x64,
+	0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x5f, 0x71, 0x75, 0x6f, 0x74, 0x65, 0x73, 0x18,
+	0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x41,
+	0x64, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x51, 0x75, 0x6f, 0x74, 0x65, 0x73, 0x52,
+	0x10, 0x61, 0x64, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x51, 0x75, 0x6f, 0x74, 0x65,
+	0x73, 0x12, 0x3b, 0x0a, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65,
+	0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74,
+	0x2e, 0x54, 0x70, 0x6d, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73,
+	0x52, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x12, 0x17,
+	0x0a, 0x07, 0x65, 0x6b, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x06, 0x65, 0x6b, 0x43, 0x65, 0x72, 0x74, 0x12, 0x2d, 0x0a, 0x12, 0x69, 0x6e, 0x74, 0x65, 0x72,
+	0x6d, 0x65, 0x64, 0x69, 0x61, 0x74, 0x65, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x73, 0x18, 0x0a, 0x20,
+	0x03, 0x28, 0x0c, 0x52, 0x11, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x74,
+	0x65, 0x43, 0x65, 0x72, 0x74, 0x73, 0x12, 0x2c, 0x0a, 0x12, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x5f,
+	0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x64, 0x5f, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x0b, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x10, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x46, 0x65, 0x74, 0x63, 0x68, 0x65, 0x64,
+	0x46, 0x72, 0x6f, 0x6d, 0x12, 0x42, 0x0a, 0x10, 0x63, 0x6c, 0x65, 0x61, 0x72, 0x5f, 0x69, 0x6e,
+	0x64, 0x69, 0x63, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
+	0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x43, 0x6c, 0x65, 0x61, 0x72, 0x49, 0x6e, 0x64,
+	0x69, 0x63, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x52, 0x0f, 0x63, 0x6c, 0x65, 0x61, 0x72, 0x49, 0x6e,
+	0x64, 0x69, 0x63, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x12, 0x33, 0x0a, 0x0b, 0x6b, 0x65, 0x79, 0x5f,
+	0x6a, 0x6f, 0x75, 0x72, 0x6e, 0x61, 0x6c, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e,
+	0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x4b, 0x65, 0x79, 0x4a, 0x6f, 0x75, 0x72, 0x6e, 0x61,
+	0x6c, 0x52, 0x0a, 0x6b, 0x65, 0x79, 0x4a, 0x6f, 0x75, 0x72, 0x6e, 0x61, 0x6c, 0x22, 0x5a, 0x0a,
+	0x0a, 0x4b, 0x65, 0x79, 0x4a, 0x6f, 0x75, 0x72, 0x6e, 0x61, 0x6c, 0x12, 0x3a, 0x0a, 0x0d, 0x63,
+	0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x14, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x4e, 0x56, 0x43, 0x65, 0x72, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66,
+	0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x67, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x6c, 0x6f, 0x67, 0x22, 0x62, 0x0a, 0x0f, 0x43, 0x6c, 0x65,
+	0x61, 0x72, 0x49, 0x6e, 0x64, 0x69, 0x63, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x12, 0x17, 0x0a, 0x07,
+	0x65, 0x6b, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x65,
+	0x6b, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x73, 0x72, 0x6b, 0x5f, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x73, 0x72, 0x6b, 0x4e, 0x61, 0x6d, 0x65,
+	0x12, 0x1b, 0x0a, 0x09, 0x6e, 0x76, 0x5f, 0x6d, 0x61, 0x72, 0x6b, 0x65, 0x72, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x08, 0x6e, 0x76, 0x4d, 0x61, 0x72, 0x6b, 0x65, 0x72, 0x22, 0x63, 0x0a,
+	0x0e, 0x54, 0x70, 0x6d, 0x43, 0x6c, 0x65, 0x61, 0x72, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12,
+	0x31, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x64, 0x69, 0x63, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x17, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x70, 0x6d, 0x43, 0x6c, 0x65,
+	0x61, 0x72, 0x56, 0x65, 0x72, 0x64, 0x69, 0x63, 0x74, 0x52, 0x07, 0x76, 0x65, 0x72, 0x64, 0x69,
+	0x63, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x69, 0x6e, 0x64, 0x69, 0x63, 0x61, 0x74, 0x6f, 0x72, 0x73,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x69, 0x6e, 0x64, 0x69, 0x63, 0x61, 0x74, 0x6f,
+	0x72, 0x73, 0x22, 0x4f, 0x0a, 0x10, 0x41, 0x64, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c,
+	0x51, 0x75, 0x6f, 0x74, 0x65, 0x73, 0x12, 0x17, 0x0a, 0x07, 0x6b, 0x65, 0x79, 0x5f, 0x70, 0x75,
+	0x62, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x6b, 0x65, 0x79, 0x50, 0x75, 0x62, 0x12,
+	0x22, 0x0a, 0x06, 0x71, 0x75, 0x6f, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x0a, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x51, 0x75, 0x6f, 0x74, 0x65, 0x52, 0x06, 0x71, 0x75, 0x6f,
+	0x74, 0x65, 0x73, 0x22, 0xeb, 0x01, 0x0a, 0x0d, 0x50, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x2a, 0x0a, 0x10, 0x73, 0x63, 0x72, 0x74, 0x6d, 0x5f, 0x76,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x48,
+	0x00, 0x52, 0x0e, 0x73, 0x63, 0x72, 0x74, 0x6d, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x49,
+	0x64, 0x12, 0x21, 0x0a, 0x0b, 0x67, 0x63, 0x65, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x00, 0x52, 0x0a, 0x67, 0x63, 0x65, 0x56, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x41, 0x0a, 0x0a, 0x74, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f,
+	0x67, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x21, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73,
+	0x74, 0x2e, 0x47, 0x43, 0x45, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x61,
+	0x6c, 0x54, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x52, 0x0a, 0x74, 0x65, 0x63,
+	0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x12, 0x3c, 0x0a, 0x0d, 0x69, 0x6e, 0x73, 0x74, 0x61,
+	0x6e, 0x63, 0x65, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
+	0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x47, 0x43, 0x45, 0x49, 0x6e, 0x73, 0x74, 0x61,
+	0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0c, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63,
+	0x65, 0x49, 0x6e, 0x66, 0x6f, 0x42, 0x0a, 0x0a, 0x08, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72,
+	0x65, 0x22, 0x38, 0x0a, 0x0a, 0x55, 0x4b, 0x49, 0x53, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x22, 0xc1, 0x01, 0x0a, 0x10,
+	0x53, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x64, 0x53, 0x74, 0x75, 0x62, 0x53, 0x74, 0x61, 0x74, 0x65,
+	0x12, 0x2e, 0x0a, 0x08, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x12, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x55, 0x4b, 0x49, 0x53,
+	0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x12, 0x2d, 0x0a, 0x12, 0x63, 0x72, 0x65, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x64,
+	0x69, 0x67, 0x65, 0x73, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x11, 0x63, 0x72,
+	0x65, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x44, 0x69, 0x67, 0x65, 0x73, 0x74, 0x73, 0x12,
+	0x27, 0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x66, 0x65, 0x78, 0x74, 0x5f, 0x64, 0x69, 0x67, 0x65, 0x73,
+	0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x66, 0x65, 0x78,
+	0x74, 0x44, 0x69, 0x67, 0x65, 0x73, 0x74, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x73, 0x79, 0x73, 0x65,
+	0x78, 0x74, 0x5f, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0c,
+	0x52, 0x0d, 0x73, 0x79, 0x73, 0x65, 0x78, 0x74, 0x44, 0x69, 0x67, 0x65, 0x73, 0x74, 0x73, 0x22,
+	0x36, 0x0a, 0x08, 0x47, 0x72, 0x75, 0x62, 0x46, 0x69, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x70,
+	0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12,
+	0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x22, 0x4f, 0x0a, 0x09, 0x47, 0x72, 0x75, 0x62, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73,
+	0x12, 0x26, 0x0a, 0x05, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x10, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x47, 0x72, 0x75, 0x62, 0x46, 0x69, 0x6c,
+	0x65, 0x52, 0x05, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x22, 0xea, 0x03, 0x0a, 0x10, 0x4c, 0x69, 0x6e,
+	0x75, 0x78, 0x4b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x21, 0x0a,
+	0x0c, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x5f, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x4c, 0x69, 0x6e, 0x65,
+	0x12, 0x30, 0x0a, 0x04, 0x73, 0x77, 0x61, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1c,
+	0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x41, 0x74, 0x52, 0x65,
+	0x73, 0x74, 0x50, 0x72, 0x6f, 0x74, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x04, 0x73, 0x77,
+	0x61, 0x70, 0x12, 0x3e, 0x0a, 0x0b, 0x68, 0x69, 0x62, 0x65, 0x72, 0x6e, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1c, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74,
+	0x2e, 0x44, 0x61, 0x74, 0x61, 0x41, 0x74, 0x52, 0x65, 0x73, 0x74, 0x50, 0x72, 0x6f, 0x74, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x68, 0x69, 0x62, 0x65, 0x72, 0x6e, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x30, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x6b, 0x64, 0x6f, 0x77, 0x6e, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x14, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x4c, 0x6f,
+	0x63, 0x6b, 0x64, 0x6f, 0x77, 0x6e, 0x4d, 0x6f, 0x64, 0x65, 0x52, 0x08, 0x6c, 0x6f, 0x63, 0x6b,
+	0x64, 0x6f, 0x77, 0x6e, 0x12, 0x40, 0x0a, 0x11, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x73,
+	0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x13, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x45, 0x6e, 0x66, 0x6f, 0x72, 0x63, 0x65,
+	0x6d, 0x65, 0x6e, 0x74, 0x52, 0x10, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x53, 0x69, 0x67, 0x6e,
+	0x61, 0x74, 0x75, 0x72, 0x65, 0x73, 0x12, 0x43, 0x0a, 0x13, 0x6b, 0x65, 0x78, 0x65, 0x63, 0x5f,
+	0x6c, 0x6f, 0x61, 0x64, 0x5f, 0x64, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x45, 0x6e, 0x66,
+	0x6f, 0x72, 0x63, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x11, 0x6b, 0x65, 0x78, 0x65, 0x63, 0x4c,
+	0x6f, 0x61, 0x64, 0x44, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x12, 0x23, 0x0a, 0x0d, 0x6b,
+	0x65, 0x72, 0x6e, 0x65, 0x6c, 0x5f, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x0c, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x44, 0x69, 0x67, 0x65, 0x73, 0x74,
+	0x12, 0x3c, 0x0a, 0x1a, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x5f, 0x61, 0x75, 0x74, 0x68, 0x65,
+	0x6e, 0x74, 0x69, 0x63, 0x6f, 0x64, 0x65, 0x5f, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x18, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x41, 0x75, 0x74, 0x68,
+	0x65, 0x6e, 0x74, 0x69, 0x63, 0x6f, 0x64, 0x65, 0x44, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12, 0x25,
+	0x0a, 0x0e, 0x69, 0x6e, 0x69, 0x74, 0x72, 0x64, 0x5f, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x73,
+	0x18, 0x09, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x0d, 0x69, 0x6e, 0x69, 0x74, 0x72, 0x64, 0x44, 0x69,
+	0x67, 0x65, 0x73, 0x74, 0x73, 0x22, 0xa0, 0x01, 0x0a, 0x05, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12,
+	0x1b, 0x0a, 0x09, 0x70, 0x63, 0x72, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x08, 0x70, 0x63, 0x72, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x25, 0x0a, 0x0e,
+	0x75, 0x6e, 0x74, 0x72, 0x75, 0x73, 0x74, 0x65, 0x64, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x0d, 0x75, 0x6e, 0x74, 0x72, 0x75, 0x73, 0x74, 0x65, 0x64, 0x54,
+	0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73,
+	0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12,
+	0x27, 0x0a, 0x0f, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69,
+	0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74,
+	0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x64, 0x22, 0x97, 0x01, 0x0a, 0x07, 0x54, 0x70, 0x6d,
+	0x49, 0x6e, 0x66, 0x6f, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x61, 0x6e, 0x75, 0x66, 0x61, 0x63, 0x74,
+	0x75, 0x72, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x6d,
+	0x61, 0x6e, 0x75, 0x66, 0x61, 0x63, 0x74, 0x75, 0x72, 0x65, 0x72, 0x49, 0x64, 0x12, 0x22, 0x0a,
+	0x0c, 0x6d, 0x61, 0x6e, 0x75, 0x66, 0x61, 0x63, 0x74, 0x75, 0x72, 0x65, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0c, 0x6d, 0x61, 0x6e, 0x75, 0x66, 0x61, 0x63, 0x74, 0x75, 0x72, 0x65,
+	0x72, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x12, 0x29, 0x0a, 0x10, 0x66, 0x69, 0x72, 0x6d, 0x77,
+	0x61, 0x72, 0x65, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x0f, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x22, 0xc9, 0x02, 0x0a, 0x0f, 0x54, 0x70, 0x6d, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69,
+	0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x61, 0x6e, 0x75, 0x66, 0x61,
+	0x63, 0x74, 0x75, 0x72, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x0e, 0x6d, 0x61, 0x6e, 0x75, 0x66, 0x61, 0x63, 0x74, 0x75, 0x72, 0x65, 0x72, 0x49, 0x64, 0x12,
+	0x23, 0x0a, 0x0d, 0x76, 0x65, 0x6e, 0x64, 0x6f, 0x72, 0x5f, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x76, 0x65, 0x6e, 0x64, 0x6f, 0x72, 0x53, 0x74,
+	0x72, 0x69, 0x6e, 0x67, 0x12, 0x29, 0x0a, 0x10, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65,
+	0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f,
+	0x66, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x23, 0x0a, 0x0d, 0x73, 0x70, 0x65, 0x63, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x73, 0x70, 0x65, 0x63, 0x52, 0x65, 0x76, 0x69,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1e, 0x0a, 0x0a, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68,
+	0x6d, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0d, 0x52, 0x0a, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69,
+	0x74, 0x68, 0x6d, 0x73, 0x12, 0x2a, 0x0a, 0x09, 0x70, 0x63, 0x72, 0x5f, 0x62, 0x61, 0x6e, 0x6b,
+	0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x0d, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x48, 0x61,
+	0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f, 0x52, 0x08, 0x70, 0x63, 0x72, 0x42, 0x61, 0x6e, 0x6b, 0x73,
+	0x12, 0x2d, 0x0a, 0x12, 0x70, 0x65, 0x72, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x68,
+	0x61, 0x6e, 0x64, 0x6c, 0x65, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0d, 0x52, 0x11, 0x70, 0x65,
+	0x72, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x74, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x73, 0x12,
+	0x1d, 0x0a, 0x0a, 0x6e, 0x76, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x65, 0x73, 0x18, 0x08, 0x20,
+	0x03, 0x28, 0x0d, 0x52, 0x09, 0x6e, 0x76, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x65, 0x73, 0x22, 0x59,
+	0x0a, 0x08, 0x44, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x65,
+	0x72, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x05, 0x63, 0x65, 0x72, 0x74, 0x73,
+	0x12, 0x16, 0x0a, 0x06, 0x68, 0x61, 0x73, 0x68, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0c,
+	0x52, 0x06, 0x68, 0x61, 0x73, 0x68, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x65, 0x72, 0x74,
+	0x5f, 0x68, 0x61, 0x73, 0x68, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x0a, 0x63,
+	0x65, 0x72, 0x74, 0x48, 0x61, 0x73, 0x68, 0x65, 0x73, 0x22, 0xb7, 0x01, 0x0a, 0x0f, 0x53, 0x65,
+	0x63, 0x75, 0x72, 0x65, 0x42, 0x6f, 0x6f, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a,
+	0x07, 0x65, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x65, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x12, 0x20, 0x0a, 0x02, 0x70, 0x6b, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x44, 0x61, 0x74,
+	0x61, 0x62, 0x61, 0x73, 0x65, 0x52, 0x02, 0x70, 0x6b, 0x12, 0x22, 0x0a, 0x03, 0x6b, 0x65, 0x6b,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e,
+	0x44, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x52, 0x03, 0x6b, 0x65, 0x6b, 0x12, 0x20, 0x0a,
+	0x02, 0x64, 0x62, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x61, 0x74, 0x74, 0x65,
+	0x73, 0x74, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x52, 0x02, 0x64, 0x62, 0x12,
+	0x22, 0x0a, 0x03, 0x64, 0x62, 0x78, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x61,
+	0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x52, 0x03,
+	0x64, 0x62, 0x78, 0x22, 0xa1, 0x08, 0x0a, 0x0c, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x12, 0x31, 0x0a, 0x08, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e,
+	0x50, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x08, 0x70,
+	0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x12, 0x38, 0x0a, 0x0b, 0x73, 0x65, 0x63, 0x75, 0x72,
+	0x65, 0x5f, 0x62, 0x6f, 0x6f, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61,
+	0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x53, 0x65, 0x63, 0x75, 0x72, 0x65, 0x42, 0x6f, 0x6f, 0x74,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x0a, 0x73, 0x65, 0x63, 0x75, 0x72, 0x65, 0x42, 0x6f, 0x6f,
+	0x74, 0x12, 0x2c, 0x0a, 0x0a, 0x72, 0x61, 0x77, 0x5f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x45,
+	0x76, 0x65, 0x6e, 0x74, 0x52, 0x09, 0x72, 0x61, 0x77, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12,
+	0x21, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0d, 0x2e,
+	0x74, 0x70, 0x6d, 0x2e, 0x48, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f, 0x52, 0x04, 0x68, 0x61,
+	0x73, 0x68, 0x12, 0x2a, 0x0a, 0x08, 0x74, 0x70, 0x6d, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x70,
+	0x6d, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x07, 0x74, 0x70, 0x6d, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x3b,
+	0x0a, 0x0c, 0x6c, 0x69, 0x6e, 0x75, 0x78, 0x5f, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x4c, 0x69,
+	0x6e, 0x75, 0x78, 0x4b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x0b,
+	0x6c, 0x69, 0x6e, 0x75, 0x78, 0x4b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x12, 0x17, 0x0a, 0x07, 0x61,
+	0x6b, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x61, 0x6b,
+	0x4e, 0x61, 0x6d, 0x65, 0x12, 0x3e, 0x0a, 0x0f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x5f, 0x77,
+	0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e,
+	0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x57, 0x61, 0x72,
+	0x6e, 0x69, 0x6e, 0x67, 0x52, 0x0e, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x57, 0x61, 0x72, 0x6e,
+	0x69, 0x6e, 0x67, 0x73, 0x12, 0x3b, 0x0a, 0x0c, 0x73, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x64, 0x5f,
+	0x73, 0x74, 0x75, 0x62, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x61, 0x74, 0x74,
+	0x65, 0x73, 0x74, 0x2e, 0x53, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x64, 0x53, 0x74, 0x75, 0x62, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x52, 0x0b, 0x73, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x64, 0x53, 0x74, 0x75,
+	0x62, 0x12, 0x25, 0x0a, 0x04, 0x67, 0x72, 0x75, 0x62, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x11, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x47, 0x72, 0x75, 0x62, 0x53, 0x74, 0x61,
+	0x74, 0x65, 0x52, 0x04, 0x67, 0x72, 0x75, 0x62, 0x12, 0x30, 0x0a, 0x0a, 0x63, 0x6c, 0x6f, 0x63,
+	0x6b, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x61,
+	0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x49, 0x6e, 0x66, 0x6f, 0x52,
+	0x09, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x35, 0x0a, 0x0c, 0x63, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x12, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67,
+	0x46, 0x69, 0x6c, 0x65, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x46, 0x69, 0x6c, 0x65,
+	0x73, 0x12, 0x42, 0x0a, 0x10, 0x74, 0x70, 0x6d, 0x5f, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c,
+	0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x61, 0x74,
+	0x74, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x70, 0x6d, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69,
+	0x74, 0x69, 0x65, 0x73, 0x52, 0x0f, 0x74, 0x70, 0x6d, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c,
+	0x69, 0x74, 0x69, 0x65, 0x73, 0x12, 0x2c, 0x0a, 0x12, 0x72, 0x6f, 0x63, 0x61, 0x5f, 0x76, 0x75,
+	0x6c, 0x6e, 0x65, 0x72, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x61, 0x6b, 0x18, 0x0e, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x10, 0x72, 0x6f, 0x63, 0x61, 0x56, 0x75, 0x6c, 0x6e, 0x65, 0x72, 0x61, 0x62, 0x6c,
+	0x65, 0x41, 0x6b, 0x12, 0x2c, 0x0a, 0x12, 0x72, 0x6f, 0x63, 0x61, 0x5f, 0x76, 0x75, 0x6c, 0x6e,
+	0x65, 0x72, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x65, 0x6b, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x10, 0x72, 0x6f, 0x63, 0x61, 0x56, 0x75, 0x6c, 0x6e, 0x65, 0x72, 0x61, 0x62, 0x6c, 0x65, 0x45,
+	0x6b, 0x12, 0x31, 0x0a, 0x0a, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18,
+	0x10, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x45,
+	0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0a, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x12, 0x42, 0x0a, 0x10, 0x63, 0x6c, 0x65, 0x61, 0x72, 0x5f, 0x69, 0x6e,
+	0x64, 0x69, 0x63, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x18, 0x11, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
+	0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x43, 0x6c, 0x65, 0x61, 0x72, 0x49, 0x6e, 0x64,
+	0x69, 0x63, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x52, 0x0f, 0x63, 0x6c, 0x65, 0x61, 0x72, 0x49, 0x6e,
+	0x64, 0x69, 0x63, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x12, 0x33, 0x0a, 0x09, 0x74, 0x70, 0x6d, 0x5f,
+	0x63, 0x6c, 0x65, 0x61, 0x72, 0x18, 0x12, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x61, 0x74,
+	0x74, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x70, 0x6d, 0x43, 0x6c, 0x65, 0x61, 0x72, 0x53, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x52, 0x08, 0x74, 0x70, 0x6d, 0x43, 0x6c, 0x65, 0x61, 0x72, 0x12, 0x38, 0x0a,
+	0x0a, 0x6b, 0x65, 0x79, 0x5f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x13, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x19, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x4b, 0x65, 0x79, 0x4c, 0x69,
+	0x66, 0x65, 0x63, 0x79, 0x63, 0x6c, 0x65, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x09, 0x6b, 0x65,
+	0x79, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x3e, 0x0a, 0x0f, 0x6b, 0x65, 0x79, 0x5f, 0x64,
+	0x65, 0x72, 0x69, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x14, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x15, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x4b, 0x65, 0x79, 0x44, 0x65, 0x72,
+	0x69, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0e, 0x6b, 0x65, 0x79, 0x44, 0x65, 0x72, 0x69,
+	0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x22, 0xa5, 0x01, 0x0a, 0x11, 0x4b, 0x65, 0x79, 0x4c,
+	0x69, 0x66, 0x65, 0x63, 0x79, 0x63, 0x6c, 0x65, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x29, 0x0a,
+	0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x11, 0x2e,
+	0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x4b, 0x65, 0x79, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x16, 0x0a, 0x06,
+	0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x68, 0x61,
+	0x6e, 0x64, 0x6c, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x12, 0x23, 0x0a, 0x0d, 0x70, 0x72,
+	0x65, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x0c, 0x70, 0x72, 0x65, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x4e, 0x61, 0x6d, 0x65, 0x22,
+	0x40, 0x0a, 0x0d, 0x4b, 0x65, 0x79, 0x44, 0x65, 0x72, 0x69, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x19, 0x0a, 0x08, 0x6b, 0x65, 0x79, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x07, 0x6b, 0x65, 0x79, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x6c,
+	0x61, 0x62, 0x65, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x61, 0x62, 0x65,
+	0x6c, 0x22, 0x37, 0x0a, 0x09, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12,
+	0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61,
+	0x74, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x22, 0x38, 0x0a, 0x0a, 0x43, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x46, 0x69, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x16, 0x0a, 0x06,
+	0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69,
+	0x67, 0x65, 0x73, 0x74, 0x22, 0x7b, 0x0a, 0x09, 0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x49, 0x6e, 0x66,
+	0x6f, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65, 0x73, 0x65, 0x74,
+	0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x72, 0x65,
+	0x73, 0x65, 0x74, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65, 0x73, 0x74,
+	0x61, 0x72, 0x74, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x0c, 0x72, 0x65, 0x73, 0x74, 0x61, 0x72, 0x74, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x12, 0x0a,
+	0x04, 0x73, 0x61, 0x66, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x73, 0x61, 0x66,
+	0x65, 0x22, 0x91, 0x01, 0x0a, 0x10, 0x52, 0x65, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x48, 0x69, 0x6e, 0x74, 0x12, 0x35, 0x0a, 0x07, 0x74, 0x72, 0x69, 0x67, 0x67, 0x65,
+	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74,
+	0x2e, 0x52, 0x65, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x72, 0x69,
+	0x67, 0x67, 0x65, 0x72, 0x52, 0x07, 0x74, 0x72, 0x69, 0x67, 0x67, 0x65, 0x72, 0x12, 0x16, 0x0a,
+	0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64,
+	0x65, 0x74, 0x61, 0x69, 0x6c, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
+	0x04, 0x74, 0x69, 0x6d, 0x65, 0x22, 0xb4, 0x01, 0x0a, 0x0e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x56, 0x61, 0x6c, 0x69, 0x64, 0x69, 0x74, 0x79, 0x12, 0x39, 0x0a, 0x0a, 0x6e, 0x6f, 0x74, 0x5f,
+	0x62, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x6e, 0x6f, 0x74, 0x42, 0x65, 0x66,
+	0x6f, 0x72, 0x65, 0x12, 0x37, 0x0a, 0x09, 0x6e, 0x6f, 0x74, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x52, 0x08, 0x6e, 0x6f, 0x74, 0x41, 0x66, 0x74, 0x65, 0x72, 0x12, 0x2e, 0x0a, 0x05,
+	0x68, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x61, 0x74,
+	0x74, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x48, 0x69, 0x6e, 0x74, 0x52, 0x05, 0x68, 0x69, 0x6e, 0x74, 0x73, 0x22, 0x63, 0x0a, 0x0a,
+	0x54, 0x72, 0x75, 0x73, 0x74, 0x43, 0x6c, 0x61, 0x69, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x12, 0x25, 0x0a, 0x04, 0x74, 0x69, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x11,
+	0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x72, 0x75, 0x73, 0x74, 0x54, 0x69, 0x65,
+	0x72, 0x52, 0x04, 0x74, 0x69, 0x65, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x61, 0x73, 0x6f,
+	0x6e, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e,
+	0x73, 0x22, 0xf8, 0x01, 0x0a, 0x15, 0x54, 0x72, 0x75, 0x73, 0x74, 0x77, 0x6f, 0x72, 0x74, 0x68,
+	0x69, 0x6e, 0x65, 0x73, 0x73, 0x56, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x3f, 0x0a, 0x11, 0x69,
+	0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e,
+	0x54, 0x72, 0x75, 0x73, 0x74, 0x43, 0x6c, 0x61, 0x69, 0x6d, 0x52, 0x10, 0x69, 0x6e, 0x73, 0x74,
+	0x61, 0x6e, 0x63, 0x65, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x38, 0x0a, 0x0d,
+	0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x72, 0x75,
+	0x73, 0x74, 0x43, 0x6c, 0x61, 0x69, 0x6d, 0x52, 0x0d, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x34, 0x0a, 0x0b, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74,
+	0x61, 0x62, 0x6c, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x61, 0x74,
+	0x74, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x72, 0x75, 0x73, 0x74, 0x43, 0x6c, 0x61, 0x69, 0x6d, 0x52,
+	0x0b, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x12, 0x2e, 0x0a, 0x08,
+	0x68, 0x61, 0x72, 0x64, 0x77, 0x61, 0x72, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12,
+	0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x72, 0x75, 0x73, 0x74, 0x43, 0x6c, 0x61,
+	0x69, 0x6d, 0x52, 0x08, 0x68, 0x61, 0x72, 0x64, 0x77, 0x61, 0x72, 0x65, 0x22, 0xde, 0x01, 0x0a,
+	0x0e, 0x50, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12,
+	0x39, 0x0a, 0x19, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x5f, 0x73, 0x63, 0x72, 0x74, 0x6d,
+	0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0c, 0x52, 0x16, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x53, 0x63, 0x72, 0x74, 0x6d,
+	0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x73, 0x12, 0x3f, 0x0a, 0x1c, 0x6d, 0x69,
+	0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x5f, 0x67, 0x63, 0x65, 0x5f, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61,
+	0x72, 0x65, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x19, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x47, 0x63, 0x65, 0x46, 0x69, 0x72, 0x6d,
+	0x77, 0x61, 0x72, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x50, 0x0a, 0x12, 0x6d,
+	0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x5f, 0x74, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67,
+	0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x21, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74,
+	0x2e, 0x47, 0x43, 0x45, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c,
+	0x54, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x52, 0x11, 0x6d, 0x69, 0x6e, 0x69,
+	0x6d, 0x75, 0x6d, 0x54, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x22, 0xba, 0x01,
+	0x0a, 0x0c, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x57, 0x61, 0x69, 0x76, 0x65, 0x72, 0x12, 0x12,
+	0x0a, 0x04, 0x72, 0x75, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x75,
+	0x6c, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x61, 0x6b, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x06, 0x61, 0x6b, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x3b, 0x0a, 0x0b, 0x65,
+	0x78, 0x70, 0x69, 0x72, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x65, 0x78,
+	0x70, 0x69, 0x72, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x24, 0x0a, 0x0d, 0x6a, 0x75, 0x73, 0x74,
+	0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0d, 0x6a, 0x75, 0x73, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a,
+	0x0a, 0x08, 0x61, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x08, 0x61, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x65, 0x72, 0x22, 0x67, 0x0a, 0x0d, 0x50, 0x6f,
+	0x6c, 0x69, 0x63, 0x79, 0x57, 0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x72,
+	0x75, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x75, 0x6c, 0x65, 0x12,
+	0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x2c, 0x0a, 0x06, 0x77, 0x61, 0x69, 0x76, 0x65, 0x72, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x50,
+	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x57, 0x61, 0x69, 0x76, 0x65, 0x72, 0x52, 0x06, 0x77, 0x61, 0x69,
+	0x76, 0x65, 0x72, 0x22, 0xca, 0x01, 0x0a, 0x0c, 0x4b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x50, 0x6f,
+	0x6c, 0x69, 0x63, 0x79, 0x12, 0x3f, 0x0a, 0x10, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x5f,
+	0x6c, 0x6f, 0x63, 0x6b, 0x64, 0x6f, 0x77, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x14,
+	0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x4c, 0x6f, 0x63, 0x6b, 0x64, 0x6f, 0x77, 0x6e,
+	0x4d, 0x6f, 0x64, 0x65, 0x52, 0x0f, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x4c, 0x6f, 0x63,
+	0x6b, 0x64, 0x6f, 0x77, 0x6e, 0x12, 0x3a, 0x0a, 0x19, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65,
+	0x5f, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72,
+	0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x17, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72,
+	0x65, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65,
+	0x73, 0x12, 0x3d, 0x0a, 0x1b, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x5f, 0x6b, 0x65, 0x78,
+	0x65, 0x63, 0x5f, 0x6c, 0x6f, 0x61, 0x64, 0x5f, 0x64, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65, 0x64,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x18, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x4b,
+	0x65, 0x78, 0x65, 0x63, 0x4c, 0x6f, 0x61, 0x64, 0x44, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65, 0x64,
+	0x22, 0xc7, 0x01, 0x0a, 0x10, 0x54, 0x70, 0x6d, 0x46, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65,
+	0x52, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x61, 0x6e, 0x75, 0x66, 0x61, 0x63,
+	0x74, 0x75, 0x72, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e,
+	0x6d, 0x61, 0x6e, 0x75, 0x66, 0x61, 0x63, 0x74, 0x75, 0x72, 0x65, 0x72, 0x49, 0x64, 0x12, 0x38,
+	0x0a, 0x18, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x5f, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61,
+	0x72, 0x65, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x16, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x46, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72,
+	0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x38, 0x0a, 0x18, 0x6d, 0x61, 0x78, 0x69,
+	0x6d, 0x75, 0x6d, 0x5f, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x5f, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x16, 0x6d, 0x61, 0x78, 0x69,
+	0x6d, 0x75, 0x6d, 0x46, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x22, 0x4e, 0x0a, 0x09, 0x54, 0x70,
+	0x6d, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x41, 0x0a, 0x0f, 0x64, 0x65, 0x6e, 0x69, 0x65,
+	0x64, 0x5f, 0x66, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x18, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x70, 0x6d, 0x46, 0x69, 0x72,
+	0x6d, 0x77, 0x61, 0x72, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x0e, 0x64, 0x65, 0x6e, 0x69,
+	0x65, 0x64, 0x46, 0x69, 0x72, 0x6d, 0x77, 0x61, 0x72, 0x65, 0x22, 0x4f, 0x0a, 0x10, 0x43, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x46, 0x69, 0x6c, 0x65, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x12,
+	0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61,
+	0x74, 0x68, 0x12, 0x27, 0x0a, 0x0f, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x5f, 0x64, 0x69,
+	0x67, 0x65, 0x73, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x0e, 0x61, 0x6c, 0x6c,
+	0x6f, 0x77, 0x65, 0x64, 0x44, 0x69, 0x67, 0x65, 0x73, 0x74, 0x73, 0x22, 0xfc, 0x01, 0x0a, 0x06,
+	0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x32, 0x0a, 0x08, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f,
+	0x72, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73,
+	0x74, 0x2e, 0x50, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79,
+	0x52, 0x08, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x12, 0x2e, 0x0a, 0x07, 0x77, 0x61,
+	0x69, 0x76, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x61, 0x74,
+	0x74, 0x65, 0x73, 0x74, 0x2e, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x57, 0x61, 0x69, 0x76, 0x65,
+	0x72, 0x52, 0x07, 0x77, 0x61, 0x69, 0x76, 0x65, 0x72, 0x73, 0x12, 0x2c, 0x0a, 0x06, 0x6b, 0x65,
+	0x72, 0x6e, 0x65, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x61, 0x74, 0x74,
+	0x65, 0x73, 0x74, 0x2e, 0x4b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79,
+	0x52, 0x06, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x12, 0x3b, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18,
+	0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x46, 0x69,
+	0x6c, 0x65, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67,
+	0x46, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x23, 0x0a, 0x03, 0x74, 0x70, 0x6d, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x11, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x70, 0x6d, 0x50,
+	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x03, 0x74, 0x70, 0x6d, 0x22, 0x54, 0x0a, 0x0c, 0x43, 0x68,
+	0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x6f,
+	0x6e, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65,
+	0x12, 0x15, 0x0a, 0x06, 0x65, 0x6b, 0x5f, 0x70, 0x75, 0x62, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x05, 0x65, 0x6b, 0x50, 0x75, 0x62, 0x12, 0x17, 0x0a, 0x07, 0x65, 0x6b, 0x5f, 0x63, 0x65,
+	0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x65, 0x6b, 0x43, 0x65, 0x72, 0x74,
+	0x22, 0xca, 0x01, 0x0a, 0x0c, 0x41, 0x4b, 0x45, 0x6e, 0x72, 0x6f, 0x6c, 0x6c, 0x6d, 0x65, 0x6e,
+	0x74, 0x12, 0x15, 0x0a, 0x06, 0x61, 0x6b, 0x5f, 0x70, 0x75, 0x62, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x05, 0x61, 0x6b, 0x50, 0x75, 0x62, 0x12, 0x2a, 0x0a, 0x08, 0x74, 0x70, 0x6d, 0x5f,
+	0x69, 0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x61, 0x74, 0x74,
+	0x65, 0x73, 0x74, 0x2e, 0x54, 0x70, 0x6d, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x07, 0x74, 0x70, 0x6d,
+	0x49, 0x6e, 0x66, 0x6f, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x5f, 0x64,
+	0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0c, 0x73, 0x65, 0x63,
+	0x72, 0x65, 0x74, 0x44, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12, 0x3b, 0x0a, 0x0b, 0x65, 0x78, 0x70,
+	0x69, 0x72, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x65, 0x78, 0x70, 0x69,
+	0x72, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x15, 0x0a, 0x06, 0x65, 0x6b, 0x5f, 0x70, 0x75, 0x62,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x65, 0x6b, 0x50, 0x75, 0x62, 0x22, 0x6d, 0x0a,
+	0x0c, 0x57, 0x69, 0x72, 0x65, 0x47, 0x75, 0x61, 0x72, 0x64, 0x4b, 0x65, 0x79, 0x12, 0x1d, 0x0a,
+	0x0a, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x12, 0x3e, 0x0a, 0x12,
+	0x73, 0x65, 0x61, 0x6c, 0x65, 0x64, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x74, 0x65, 0x5f, 0x6b,
+	0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x53,
+	0x65, 0x61, 0x6c, 0x65, 0x64, 0x42, 0x79, 0x74, 0x65, 0x73, 0x52, 0x10, 0x73, 0x65, 0x61, 0x6c,
+	0x65, 0x64, 0x50, 0x72, 0x69, 0x76, 0x61, 0x74, 0x65, 0x4b, 0x65, 0x79, 0x22, 0x6d, 0x0a, 0x15,
+	0x57, 0x69, 0x72, 0x65, 0x47, 0x75, 0x61, 0x72, 0x64, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69,
+	0x63, 0x4b, 0x65, 0x79, 0x12, 0x35, 0x0a, 0x0b, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x61, 0x74, 0x74, 0x65,
+	0x73, 0x74, 0x2e, 0x41, 0x74, 0x74, 0x65, 0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b,
+	0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x3a, 0x0a, 0x0c, 0x42,
+	0x75, 0x69, 0x6c, 0x64, 0x53, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x16, 0x0a, 0x06, 0x73, 0x68, 0x61, 0x32, 0x35, 0x36, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x06, 0x73, 0x68, 0x61, 0x32, 0x35, 0x36, 0x22, 0x3a, 0x0a, 0x0e, 0x42, 0x75, 0x69, 0x6c, 0x64,
+	0x50, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x22, 0x99, 0x01, 0x0a, 0x0e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x53, 0x74, 0x61,
+	0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x65,
+	0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x62, 0x75, 0x69, 0x6c,
+	0x64, 0x65, 0x72, 0x49, 0x64, 0x12, 0x30, 0x0a, 0x08, 0x73, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74,
+	0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74,
+	0x2e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x53, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x52, 0x08, 0x73,
+	0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x12, 0x36, 0x0a, 0x0a, 0x70, 0x61, 0x72, 0x61, 0x6d,
+	0x65, 0x74, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x61, 0x74,
+	0x74, 0x65, 0x73, 0x74, 0x2e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x65,
+	0x74, 0x65, 0x72, 0x52, 0x0a, 0x70, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x22,
+	0x7e, 0x0a, 0x0f, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x50, 0x72, 0x6f, 0x76, 0x65, 0x6e, 0x61, 0x6e,
+	0x63, 0x65, 0x12, 0x34, 0x0a, 0x09, 0x73, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x42,
+	0x75, 0x69, 0x6c, 0x64, 0x53, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x09, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x35, 0x0a, 0x0b, 0x61, 0x74, 0x74, 0x65,
+	0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e,
+	0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x41, 0x74, 0x74, 0x65, 0x73, 0x74, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x0b, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2a,
+	0x60, 0x0a, 0x0f, 0x54, 0x70, 0x6d, 0x43, 0x6c, 0x65, 0x61, 0x72, 0x56, 0x65, 0x72, 0x64, 0x69,
+	0x63, 0x74, 0x12, 0x15, 0x0a, 0x11, 0x54, 0x50, 0x4d, 0x5f, 0x43, 0x4c, 0x45, 0x41, 0x52, 0x5f,
+	0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x13, 0x0a, 0x0f, 0x54, 0x50, 0x4d,
+	0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x43, 0x4c, 0x45, 0x41, 0x52, 0x45, 0x44, 0x10, 0x01, 0x12, 0x0f,
+	0x0a, 0x0b, 0x54, 0x50, 0x4d, 0x5f, 0x43, 0x4c, 0x45, 0x41, 0x52, 0x45, 0x44, 0x10, 0x02, 0x12,
+	0x10, 0x0a, 0x0c, 0x54, 0x50, 0x4d, 0x5f, 0x52, 0x45, 0x50, 0x4c, 0x41, 0x43, 0x45, 0x44, 0x10,
+	0x03, 0x2a, 0x42, 0x0a, 0x19, 0x47, 0x43, 0x45, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e,
+	0x74, 0x69, 0x61, 0x6c, 0x54, 0x65, 0x63, 0x68, 0x6e, 0x6f, 0x6c, 0x6f, 0x67, 0x79, 0x12, 0x08,
+	0x0a, 0x04, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x4d, 0x44, 0x5f,
+	0x53, 0x45, 0x56, 0x10, 0x01, 0x12, 0x0e, 0x0a, 0x0a, 0x41, 0x4d, 0x44, 0x5f, 0x53, 0x45, 0x56,
+	0x5f, 0x45, 0x53, 0x10, 0x02, 0x2a, 0x7d, 0x0a, 0x14, 0x44, 0x61, 0x74, 0x61, 0x41, 0x74, 0x52,
+	0x65, 0x73, 0x74, 0x50, 0x72, 0x6f, 0x74, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a,
+	0x12, 0x50, 0x52, 0x4f, 0x54, 0x45, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x4b, 0x4e,
+	0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x17, 0x0a, 0x13, 0x50, 0x52, 0x4f, 0x54, 0x45, 0x43, 0x54,
+	0x49, 0x4f, 0x4e, 0x5f, 0x44, 0x49, 0x53, 0x41, 0x42, 0x4c, 0x45, 0x44, 0x10, 0x01, 0x12, 0x18,
+	0x0a, 0x14, 0x50, 0x52, 0x4f, 0x54, 0x45, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x45, 0x4e, 0x43,
+	0x52, 0x59, 0x50, 0x54, 0x45, 0x44, 0x10, 0x02, 0x12, 0x1a, 0x0a, 0x16, 0x50, 0x52, 0x4f, 0x54,
+	0x45, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x45, 0x4e, 0x43, 0x52, 0x59, 0x50, 0x54,
+	0x45, 0x44, 0x10, 0x03, 0x2a, 0x6d, 0x0a, 0x0c, 0x4c, 0x6f, 0x63, 0x6b, 0x64, 0x6f, 0x77, 0x6e,
+	0x4d, 0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a, 0x10, 0x4c, 0x4f, 0x43, 0x4b, 0x44, 0x4f, 0x57, 0x4e,
+	0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x11, 0x0a, 0x0d, 0x4c, 0x4f,
+	0x43, 0x4b, 0x44, 0x4f, 0x57, 0x4e, 0x5f, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x01, 0x12, 0x16, 0x0a,
+	0x12, 0x4c, 0x4f, 0x43, 0x4b, 0x44, 0x4f, 0x57, 0x4e, 0x5f, 0x49, 0x4e, 0x54, 0x45, 0x47, 0x52,
+	0x49, 0x54, 0x59, 0x10, 0x02, 0x12, 0x1c, 0x0a, 0x18, 0x4c, 0x4f, 0x43, 0x4b, 0x44, 0x4f, 0x57,
+	0x4e, 0x5f, 0x43, 0x4f, 0x4e, 0x46, 0x49, 0x44, 0x45, 0x4e, 0x54, 0x49, 0x41, 0x4c, 0x49, 0x54,
+	0x59, 0x10, 0x03, 0x2a, 0x46, 0x0a, 0x0b, 0x45, 0x6e, 0x66, 0x6f, 0x72, 0x63, 0x65, 0x6d, 0x65,
+	0x6e, 0x74, 0x12, 0x17, 0x0a, 0x13, 0x45, 0x4e, 0x46, 0x4f, 0x52, 0x43, 0x45, 0x4d, 0x45, 0x4e,
+	0x54, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x10, 0x0a, 0x0c, 0x4e,
+	0x4f, 0x54, 0x5f, 0x45, 0x4e, 0x46, 0x4f, 0x52, 0x43, 0x45, 0x44, 0x10, 0x01, 0x12, 0x0c, 0x0a,
+	0x08, 0x45, 0x4e, 0x46, 0x4f, 0x52, 0x43, 0x45, 0x44, 0x10, 0x02, 0x2a, 0x6d, 0x0a, 0x09, 0x4b,
+	0x65, 0x79, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x16, 0x4b, 0x45, 0x59, 0x5f,
+	0x41, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49,
+	0x45, 0x44, 0x10, 0x00, 0x12, 0x0f, 0x0a, 0x0b, 0x4b, 0x45, 0x59, 0x5f, 0x43, 0x52, 0x45, 0x41,
+	0x54, 0x45, 0x44, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d, 0x4b, 0x45, 0x59, 0x5f, 0x50, 0x45, 0x52,
+	0x53, 0x49, 0x53, 0x54, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0f, 0x0a, 0x0b, 0x4b, 0x45, 0x59, 0x5f,
+	0x45, 0x56, 0x49, 0x43, 0x54, 0x45, 0x44, 0x10, 0x03, 0x12, 0x0f, 0x0a, 0x0b, 0x4b, 0x45, 0x59,
+	0x5f, 0x52, 0x4f, 0x54, 0x41, 0x54, 0x45, 0x44, 0x10, 0x04, 0x2a, 0xb8, 0x01, 0x0a, 0x13, 0x52,
+	0x65, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x72, 0x69, 0x67, 0x67,
+	0x65, 0x72, 0x12, 0x24, 0x0a, 0x20, 0x52, 0x45, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x41, 0x54, 0x49,
+	0x4f, 0x4e, 0x5f, 0x54, 0x52, 0x49, 0x47, 0x47, 0x45, 0x52, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45,
+	0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x18, 0x0a, 0x14, 0x52, 0x45, 0x56, 0x41,
+	0x4c, 0x49, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x4f, 0x4e, 0x5f, 0x52, 0x45, 0x42, 0x4f, 0x4f, 0x54,
+	0x10, 0x01, 0x12, 0x20, 0x0a, 0x1c, 0x52, 0x45, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x41, 0x54, 0x45,
+	0x5f, 0x4f, 0x4e, 0x5f, 0x52, 0x55, 0x4e, 0x54, 0x49, 0x4d, 0x45, 0x5f, 0x43, 0x48, 0x41, 0x4e,
+	0x47, 0x45, 0x10, 0x02, 0x12, 0x1f, 0x0a, 0x1b, 0x52, 0x45, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x41,
+	0x54, 0x45, 0x5f, 0x4f, 0x4e, 0x5f, 0x57, 0x41, 0x49, 0x56, 0x45, 0x52, 0x5f, 0x45, 0x58, 0x50,
+	0x49, 0x52, 0x59, 0x10, 0x03, 0x12, 0x1e, 0x0a, 0x1a, 0x52, 0x45, 0x56, 0x41, 0x4c, 0x49, 0x44,
+	0x41, 0x54, 0x45, 0x5f, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x53, 0x41, 0x46, 0x45, 0x5f, 0x43, 0x4c,
+	0x4f, 0x43, 0x4b, 0x10, 0x04, 0x2a, 0x72, 0x0a, 0x09, 0x54, 0x72, 0x75, 0x73, 0x74, 0x54, 0x69,
+	0x65, 0x72, 0x12, 0x13, 0x0a, 0x0f, 0x54, 0x52, 0x55, 0x53, 0x54, 0x5f, 0x54, 0x49, 0x45, 0x52,
+	0x5f, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x18, 0x0a, 0x14, 0x54, 0x52, 0x55, 0x53, 0x54,
+	0x5f, 0x54, 0x49, 0x45, 0x52, 0x5f, 0x41, 0x46, 0x46, 0x49, 0x52, 0x4d, 0x49, 0x4e, 0x47, 0x10,
+	0x01, 0x12, 0x16, 0x0a, 0x12, 0x54, 0x52, 0x55, 0x53, 0x54, 0x5f, 0x54, 0x49, 0x45, 0x52, 0x5f,
+	0x57, 0x41, 0x52, 0x4e, 0x49, 0x4e, 0x47, 0x10, 0x02, 0x12, 0x1e, 0x0a, 0x1a, 0x54, 0x52, 0x55,
+	0x53, 0x54, 0x5f, 0x54, 0x49, 0x45, 0x52, 0x5f, 0x43, 0x4f, 0x4e, 0x54, 0x52, 0x41, 0x49, 0x4e,
+	0x44, 0x49, 0x43, 0x41, 0x54, 0x45, 0x44, 0x10, 0x03, 0x42, 0x2d, 0x5a, 0x2b, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x67,
+	0x6f, 0x2d, 0x74, 0x70, 0x6d, 0x2d, 0x74, 0x6f, 0x6f, 0x6c, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x2f, 0x61, 0x74, 0x74, 0x65, 0x73, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_attest_proto_rawDescOnce sync.Once
+	file_attest_proto_rawDescData = file_attest_proto_rawDesc
+)
+
+func file_attest_proto_rawDescGZIP() []byte {
+	file_attest_proto_rawDescOnce.Do(func() {
+		file_attest_proto_rawDescData = protoimpl.X.CompressGZIP(file_attest_proto_rawDescData)
+	})
+	return file_attest_proto_rawDescData
+}
+
+var file_attest_proto_enumTypes = make([]protoimpl.EnumInfo, 8)
+var file_attest_proto_msgTypes = make([]protoimpl.MessageInfo, 43)
+var file_attest_proto_goTypes = []interface{}{
+	(TpmClearVerdict)(0),           // 0: attest.TpmClearVerdict
+	(GCEConfidentialTechnology)(0), // 1: attest.GCEConfidentialTechnology
+	(DataAtRestProtection)(0),      // 2: attest.DataAtRestProtection
+	(LockdownMode)(0),              // 3: attest.LockdownMode
+	(Enforcement)(0),               // 4: attest.Enforcement
+	(KeyAction)(0),                 // 5: attest.KeyAction
+	(RevalidationTrigger)(0),       // 6: attest.RevalidationTrigger
+	(TrustTier)(0),                 // 7: attest.TrustTier
+	(*GCEInstanceInfo)(nil),        // 8: attest.GCEInstanceInfo
+	(*Attestation)(nil),            // 9: attest.Attestation
+	(*KeyJournal)(nil),             // 10: attest.KeyJournal
+	(*ClearIndicators)(nil),        // 11: attest.ClearIndicators
+	(*TpmClearStatus)(nil),         // 12: attest.TpmClearStatus
+	(*AdditionalQuotes)(nil),       // 13: attest.AdditionalQuotes
+	(*PlatformState)(nil),          // 14: attest.PlatformState
+	(*UKISection)(nil),             // 15: attest.UKISection
+	(*SystemdStubState)(nil),       // 16: attest.SystemdStubState
+	(*GrubFile)(nil),               // 17: attest.GrubFile
+	(*GrubState)(nil),              // 18: attest.GrubState
+	(*LinuxKernelState)(nil),       // 19: attest.LinuxKernelState
+	(*Event)(nil),                  // 20: attest.Event
+	(*TpmInfo)(nil),                // 21: attest.TpmInfo
+	(*TpmCapabilities)(nil),        // 22: attest.TpmCapabilities
+	(*Database)(nil),               // 23: attest.Database
+	(*SecureBootState)(nil),        // 24: attest.SecureBootState
+	(*MachineState)(nil),           // 25: attest.MachineState
+	(*KeyLifecycleEvent)(nil),      // 26: attest.KeyLifecycleEvent
+	(*KeyDerivation)(nil),          // 27: attest.KeyDerivation
+	(*Execution)(nil),              // 28: attest.Execution
+	(*ConfigFile)(nil),             // 29: attest.ConfigFile
+	(*ClockInfo)(nil),              // 30: attest.ClockInfo
+	(*RevalidationHint)(nil),       // 31: attest.RevalidationHint
+	(*ResultValidity)(nil),         // 32: attest.ResultValidity
+	(*TrustClaim)(nil),             // 33: attest.TrustClaim
+	(*TrustworthinessVector)(nil),  // 34: attest.TrustworthinessVector
+	(*PlatformPolicy)(nil),         // 35: attest.PlatformPolicy
+	(*PolicyWaiver)(nil),           // 36: attest.PolicyWaiver
+	(*PolicyWarning)(nil),          // 37: attest.PolicyWarning
+	(*KernelPolicy)(nil),           // 38: attest.KernelPolicy
+	(*TpmFirmwareRange)(nil),       // 39: attest.TpmFirmwareRange
+	(*TpmPolicy)(nil),              // 40: attest.TpmPolicy
+	(*ConfigFilePolicy)(nil),       // 41: attest.ConfigFilePolicy
+	(*Policy)(nil),                 // 42: attest.Policy
+	(*ChannelHello)(nil),           // 43: attest.ChannelHello
+	(*AKEnrollment)(nil),           // 44: attest.AKEnrollment
+	(*WireGuardKey)(nil),           // 45: attest.WireGuardKey
+	(*WireGuardRegistration)(nil),  // 46: attest.WireGuardRegistration
+	(*BuildSubject)(nil),           // 47: attest.BuildSubject
+	(*BuildParameter)(nil),         // 48: attest.BuildParameter
+	(*BuildStatement)(nil),         // 49: attest.BuildStatement
+	(*BuildProvenance)(nil),        // 50: attest.BuildProvenance
+	(*tpm.Quote)(nil),              // 51: tpm.Quote
+	(tpm.HashAlgo)(0),              // 52: tpm.HashAlgo
+	(*tpm.NVCertification)(nil),    // 53: tpm.NVCertification
+	(*timestamppb.Timestamp)(nil),  // 54: google.protobuf.Timestamp
+	(*tpm.SealedBytes)(nil),        // 55: tpm.SealedBytes
+}
+var file_attest_proto_depIdxs = []int32{
+	51, // 0: attest.Attestation.quotes:type_name -> tpm.Quote
+	8,  // 1: attest.Attestation.instance_info:type_name -> attest.GCEInstanceInfo
+	52, // 2: attest.Attestation.nonce_hash:type_name -> tpm.HashAlgo
+	13, // 3: attest.Attestation.additional_quotes:type_name -> attest.AdditionalQuotes
+	22, // 4: attest.Attestation.capabilities:type_name -> attest.TpmCapabilities
+	11, // 5: attest.Attestation.clear_indicators:type_name -> attest.ClearIndicators
+	10, // 6: attest.Attestation.key_journal:type_name -> attest.KeyJournal
+	53, // 7: attest.KeyJournal.certification:type_name -> tpm.NVCertification
+	0,  // 8: attest.TpmClearStatus.verdict:type_name -> attest.TpmClearVerdict
+	51, // 9: attest.AdditionalQuotes.quotes:type_name -> tpm.Quote
+	1,  // 10: attest.PlatformState.technology:type_name -> attest.GCEConfidentialTechnology
+	8,  // 11: attest.PlatformState.instance_info:type_name -> attest.GCEInstanceInfo
+	15, // 12: attest.SystemdStubState.sections:type_name -> attest.UKISection
+	17, // 13: attest.GrubState.files:type_name -> attest.GrubFile
+	2,  // 14: attest.LinuxKernelState.swap:type_name -> attest.DataAtRestProtection
+	2,  // 15: attest.LinuxKernelState.hibernation:type_name -> attest.DataAtRestProtection
+	3,  // 16: attest.LinuxKernelState.lockdown:type_name -> attest.LockdownMode
+	4,  // 17: attest.LinuxKernelState.module_signatures:type_name -> attest.Enforcement
+	4,  // 18: attest.LinuxKernelState.kexec_load_disabled:type_name -> attest.Enforcement
+	52, // 19: attest.TpmCapabilities.pcr_banks:type_name -> tpm.HashAlgo
+	23, // 20: attest.SecureBootState.pk:type_name -> attest.Database
+	23, // 21: attest.SecureBootState.kek:type_name -> attest.Database
+	23, // 22: attest.SecureBootState.db:type_name -> attest.Database
+	23, // 23: attest.SecureBootState.dbx:type_name -> attest.Database
+	14, // 24: attest.MachineState.platform:type_name -> attest.PlatformState
+	24, // 25: attest.MachineState.secure_boot:type_name -> attest.SecureBootState
+	20, // 26: attest.MachineState.raw_events:type_name -> attest.Event
+	52, // 27: attest.MachineState.hash:type_name -> tpm.HashAlgo
+	21, // 28: attest.MachineState.tpm_info:type_name -> attest.TpmInfo
+	19, // 29: attest.MachineState.linux_kernel:type_name -> attest.LinuxKernelState
+	37, // 30: attest.MachineState.policy_warnings:type_name -> attest.PolicyWarning
+	16, // 31: attest.MachineState.systemd_stub:type_name -> attest.SystemdStubState
+	18, // 32: attest.MachineState.grub:type_name -> attest.GrubState
+	30, // 33: attest.MachineState.clock_info:type_name -> attest.ClockInfo
+	29, // 34: attest.MachineState.config_files:type_name -> attest.ConfigFile
+	22, // 35: attest.MachineState.tpm_capabilities:type_name -> attest.TpmCapabilities
+	28, // 36: attest.MachineState.executions:type_name -> attest.Execution
+	11, // 37: attest.MachineState.clear_indicators:type_name -> attest.ClearIndicators
+	12, // 38: attest.MachineState.tpm_clear:type_name -> attest.TpmClearStatus
+	26, // 39: attest.MachineState.key_events:type_name -> attest.KeyLifecycleEvent
+	27, // 40: attest.MachineState.key_derivations:type_name -> attest.KeyDerivation
+	5,  // 41: attest.KeyLifecycleEvent.action:type_name -> attest.KeyAction
+	6,  // 42: attest.RevalidationHint.trigger:type_name -> attest.RevalidationTrigger
+	54, // 43: attest.RevalidationHint.time:type_name -> google.protobuf.Timestamp
+	54, // 44: attest.ResultValidity.not_before:type_name -> google.protobuf.Timestamp
+	54, // 45: attest.ResultValidity.not_after:type_name -> google.protobuf.Timestamp
+	31, // 46: attest.ResultValidity.hints:type_name -> attest.RevalidationHint
+	7,  // 47: attest.TrustClaim.tier:type_name -> attest.TrustTier
+	33, // 48: attest.TrustworthinessVector.instance_identity:type_name -> attest.TrustClaim
+	33, // 49: attest.TrustworthinessVector.configuration:type_name -> attest.TrustClaim
+	33, // 50: attest.TrustworthinessVector.executables:type_name -> attest.TrustClaim
+	33, // 51: attest.TrustworthinessVector.hardware:type_name -> attest.TrustClaim
+	1,  // 52: attest.PlatformPolicy.minimum_technology:type_name -> attest.GCEConfidentialTechnology
+	54, // 53: attest.PolicyWaiver.expire_time:type_name -> google.protobuf.Timestamp
+	36, // 54: attest.PolicyWarning.waiver:type_name -> attest.PolicyWaiver
+	3,  // 55: attest.KernelPolicy.minimum_lockdown:type_name -> attest.LockdownMode
+	39, // 56: attest.TpmPolicy.denied_firmware:type_name -> attest.TpmFirmwareRange
+	35, // 57: attest.Policy.platform:type_name -> attest.PlatformPolicy
+	36, // 58: attest.Policy.waivers:type_name -> attest.PolicyWaiver
+	38, // 59: attest.Policy.kernel:type_name -> attest.KernelPolicy
+	41, // 60: attest.Policy.config_files:type_name -> attest.ConfigFilePolicy
+	40, // 61: attest.Policy.tpm:type_name -> attest.TpmPolicy
+	21, // 62: attest.AKEnrollment.tpm_info:type_name -> attest.TpmInfo
+	54, // 63: attest.AKEnrollment.expire_time:type_name -> google.protobuf.Timestamp
+	55, // 64: attest.WireGuardKey.sealed_private_key:type_name -> tpm.SealedBytes
+	9,  // 65: attest.WireGuardRegistration.attestation:type_name -> attest.Attestation
+	47, // 66: attest.BuildStatement.subjects:type_name -> attest.BuildSubject
+	48, // 67: attest.BuildStatement.parameters:type_name -> attest.BuildParameter
+	49, // 68: attest.BuildProvenance.statement:type_name -> attest.BuildStatement
+	9,  // 69: attest.BuildProvenance.attestation:type_name -> attest.Attestation
+	70, // [70:70] is the sub-list for method output_type
+	70, // [70:70] is the sub-list for method input_type
+	70, // [70:70] is the sub-list for extension type_name
+	70, // [70:70] is the sub-list for extension extendee
+	0,  // [0:70] is the sub-list for field type_name
+}
+
+func init() { file_attest_proto_init() }
+func file_attest_proto_init() {
+	if File_attest_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_attest_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GCEInstanceInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Attestation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*KeyJournal); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClearIndicators); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TpmClearStatus); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AdditionalQuotes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PlatformState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UKISection); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SystemdStubState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GrubFile); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GrubState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LinuxKernelState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Event); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -755,8 +4710,8 @@ func file_attest_proto_init() {
 				return nil
 			}
 		}
-		file_attest_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Attestation); i {
+		file_attest_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TpmInfo); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -767,8 +4722,8 @@ func file_attest_proto_init() {
 				return nil
 			}
 		}
-		file_attest_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PlatformState); i {
+		file_attest_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TpmCapabilities); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -779,8 +4734,8 @@ func file_attest_proto_init() {
 				return nil
 			}
 		}
-		file_attest_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Event); i {
+		file_attest_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Database); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -791,7 +4746,19 @@ func file_attest_proto_init() {
 				return nil
 			}
 		}
-		file_attest_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+		file_attest_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SecureBootState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*MachineState); i {
 			case 0:
 				return &v.state
@@ -803,7 +4770,115 @@ func file_attest_proto_init() {
 				return nil
 			}
 		}
-		file_attest_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+		file_attest_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*KeyLifecycleEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*KeyDerivation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Execution); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConfigFile); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClockInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RevalidationHint); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ResultValidity); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TrustClaim); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TrustworthinessVector); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*PlatformPolicy); i {
 			case 0:
 				return &v.state
@@ -815,7 +4890,79 @@ func file_attest_proto_init() {
 				return nil
 			}
 		}
-		file_attest_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+		file_attest_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PolicyWaiver); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[29].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PolicyWarning); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[30].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*KernelPolicy); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[31].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TpmFirmwareRange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[32].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TpmPolicy); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[33].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConfigFilePolicy); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[34].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Policy); i {
 			case 0:
 				return &v.state
@@ -827,8 +4974,104 @@ func file_attest_proto_init() {
 				return nil
 			}
 		}
+		file_attest_proto_msgTypes[35].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChannelHello); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[36].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AKEnrollment); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[37].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WireGuardKey); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[38].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WireGuardRegistration); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[39].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BuildSubject); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[40].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BuildParameter); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[41].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BuildStatement); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_attest_proto_msgTypes[42].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BuildProvenance); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
-	file_attest_proto_msgTypes[2].OneofWrappers = []interface{}{
+	file_attest_proto_msgTypes[6].OneofWrappers = []interface{}{
 		(*PlatformState_ScrtmVersionId)(nil),
 		(*PlatformState_GceVersion)(nil),
 	}
@@ -837,8 +5080,8 @@ func file_attest_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_attest_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   7,
+			NumEnums:      8,
+			NumMessages:   43,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
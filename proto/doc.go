@@ -15,8 +15,11 @@
 // To generate the Go code, your system must have "protoc" installed. See:
 // https://github.com/protocolbuffers/protobuf#protocol-compiler-installation
 //
-// The "protoc-gen-go" tool must also be installed. To install it, run:
+// The "protoc-gen-go" and "protoc-gen-go-grpc" tools must also be installed.
+// To install them, run:
 //   go install google.golang.org/protobuf/cmd/protoc-gen-go
+//   go install google.golang.org/grpc/cmd/protoc-gen-go-grpc
 package proto
 
 //go:generate protoc --go_out=. --go_opt=module=github.com/google/go-tpm-tools/proto tpm.proto attest.proto
+//go:generate protoc --go_out=. --go_opt=module=github.com/google/go-tpm-tools/proto --go-grpc_out=. --go-grpc_opt=module=github.com/google/go-tpm-tools/proto verifier.proto broker.proto
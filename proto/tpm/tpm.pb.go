@@ -141,6 +141,11 @@ type SealedBytes struct {
 	CertifiedPcrs *PCRs      `protobuf:"bytes,6,opt,name=certified_pcrs,json=certifiedPcrs,proto3" json:"certified_pcrs,omitempty"`
 	CreationData  []byte     `protobuf:"bytes,7,opt,name=creation_data,json=creationData,proto3" json:"creation_data,omitempty"`
 	Ticket        []byte     `protobuf:"bytes,8,opt,name=ticket,proto3" json:"ticket,omitempty"`
+	// If set, the data can only be unsealed while this NV counter has this value.
+	Counter *NVCounter `protobuf:"bytes,9,opt,name=counter,proto3" json:"counter,omitempty"`
+	// If set, the data can only be unsealed with a PCR policy signed by this
+	// authority (see SignedPCRPolicy), rather than with fixed PCR values.
+	AuthorizedPolicy *AuthorizedPolicy `protobuf:"bytes,10,opt,name=authorized_policy,json=authorizedPolicy,proto3" json:"authorized_policy,omitempty"`
 }
 
 func (x *SealedBytes) Reset() {
@@ -231,34 +236,672 @@ func (x *SealedBytes) GetTicket() []byte {
 	return nil
 }
 
+func (x *SealedBytes) GetCounter() *NVCounter {
+	if x != nil {
+		return x.Counter
+	}
+	return nil
+}
+
+func (x *SealedBytes) GetAuthorizedPolicy() *AuthorizedPolicy {
+	if x != nil {
+		return x.AuthorizedPolicy
+	}
+	return nil
+}
+
+// An authority approving the policies of sealed data, with
+// TPM2_PolicyAuthorize.
+type AuthorizedPolicy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The authority's public key, encoded as a TPMT_PUBLIC
+	Authority []byte `protobuf:"bytes,1,opt,name=authority,proto3" json:"authority,omitempty"`
+	// Qualifies which of the authority's policies apply to the data
+	PolicyRef []byte `protobuf:"bytes,2,opt,name=policy_ref,json=policyRef,proto3" json:"policy_ref,omitempty"`
+}
+
+func (x *AuthorizedPolicy) Reset() {
+	*x = AuthorizedPolicy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthorizedPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthorizedPolicy) ProtoMessage() {}
+
+func (x *AuthorizedPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthorizedPolicy.ProtoReflect.Descriptor instead.
+func (*AuthorizedPolicy) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AuthorizedPolicy) GetAuthority() []byte {
+	if x != nil {
+		return x.Authority
+	}
+	return nil
+}
+
+func (x *AuthorizedPolicy) GetPolicyRef() []byte {
+	if x != nil {
+		return x.PolicyRef
+	}
+	return nil
+}
+
+// PCR values approved by an authority for unsealing data sealed to it, so the
+// data can be unsealed after the PCRs change without being resealed.
+type SignedPCRPolicy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Pcrs *PCRs `protobuf:"bytes,1,opt,name=pcrs,proto3" json:"pcrs,omitempty"`
+	// The authority's signature of the digest of the approved TPM2_PolicyPCR
+	// policy and the policy_ref, encoded as a TPMT_SIGNATURE
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *SignedPCRPolicy) Reset() {
+	*x = SignedPCRPolicy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SignedPCRPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignedPCRPolicy) ProtoMessage() {}
+
+func (x *SignedPCRPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignedPCRPolicy.ProtoReflect.Descriptor instead.
+func (*SignedPCRPolicy) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SignedPCRPolicy) GetPcrs() *PCRs {
+	if x != nil {
+		return x.Pcrs
+	}
+	return nil
+}
+
+func (x *SignedPCRPolicy) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// The value of a monotonic counter in a TPM NV index
+type NVCounter struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Index uint32 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Value uint64 `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *NVCounter) Reset() {
+	*x = NVCounter{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NVCounter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NVCounter) ProtoMessage() {}
+
+func (x *NVCounter) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NVCounter.ProtoReflect.Descriptor instead.
+func (*NVCounter) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *NVCounter) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *NVCounter) GetValue() uint64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
 type ImportBlob struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Duplicate     []byte `protobuf:"bytes,1,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
-	EncryptedSeed []byte `protobuf:"bytes,2,opt,name=encrypted_seed,json=encryptedSeed,proto3" json:"encrypted_seed,omitempty"`
-	PublicArea    []byte `protobuf:"bytes,3,opt,name=public_area,json=publicArea,proto3" json:"public_area,omitempty"`
-	Pcrs          *PCRs  `protobuf:"bytes,4,opt,name=pcrs,proto3" json:"pcrs,omitempty"`
+	Duplicate     []byte `protobuf:"bytes,1,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	EncryptedSeed []byte `protobuf:"bytes,2,opt,name=encrypted_seed,json=encryptedSeed,proto3" json:"encrypted_seed,omitempty"`
+	PublicArea    []byte `protobuf:"bytes,3,opt,name=public_area,json=publicArea,proto3" json:"public_area,omitempty"`
+	Pcrs          *PCRs  `protobuf:"bytes,4,opt,name=pcrs,proto3" json:"pcrs,omitempty"`
+}
+
+func (x *ImportBlob) Reset() {
+	*x = ImportBlob{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImportBlob) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportBlob) ProtoMessage() {}
+
+func (x *ImportBlob) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportBlob.ProtoReflect.Descriptor instead.
+func (*ImportBlob) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ImportBlob) GetDuplicate() []byte {
+	if x != nil {
+		return x.Duplicate
+	}
+	return nil
+}
+
+func (x *ImportBlob) GetEncryptedSeed() []byte {
+	if x != nil {
+		return x.EncryptedSeed
+	}
+	return nil
+}
+
+func (x *ImportBlob) GetPublicArea() []byte {
+	if x != nil {
+		return x.PublicArea
+	}
+	return nil
+}
+
+func (x *ImportBlob) GetPcrs() *PCRs {
+	if x != nil {
+		return x.Pcrs
+	}
+	return nil
+}
+
+// A credential encrypted to an EK, which can only be recovered if a specific
+// key is loaded in the same TPM. Used for TPM2_ActivateCredential.
+type EncryptedCredential struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The encrypted credential, encoded as a TPM2B_ID_OBJECT
+	CredentialBlob []byte `protobuf:"bytes,1,opt,name=credential_blob,json=credentialBlob,proto3" json:"credential_blob,omitempty"`
+	// The secret used to protect the credential, encrypted to the EK
+	EncryptedSecret []byte `protobuf:"bytes,2,opt,name=encrypted_secret,json=encryptedSecret,proto3" json:"encrypted_secret,omitempty"`
+}
+
+func (x *EncryptedCredential) Reset() {
+	*x = EncryptedCredential{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EncryptedCredential) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncryptedCredential) ProtoMessage() {}
+
+func (x *EncryptedCredential) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncryptedCredential.ProtoReflect.Descriptor instead.
+func (*EncryptedCredential) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EncryptedCredential) GetCredentialBlob() []byte {
+	if x != nil {
+		return x.CredentialBlob
+	}
+	return nil
+}
+
+func (x *EncryptedCredential) GetEncryptedSecret() []byte {
+	if x != nil {
+		return x.EncryptedSecret
+	}
+	return nil
+}
+
+type Quote struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// TPM2 quote, encoded as a TPMS_ATTEST
+	Quote []byte `protobuf:"bytes,1,opt,name=quote,proto3" json:"quote,omitempty"`
+	// TPM2 signature, encoded as a TPMT_SIGNATURE
+	RawSig []byte `protobuf:"bytes,2,opt,name=raw_sig,json=rawSig,proto3" json:"raw_sig,omitempty"`
+	// PCR values of the bank being quoted
+	Pcrs *PCRs `protobuf:"bytes,3,opt,name=pcrs,proto3" json:"pcrs,omitempty"`
+}
+
+func (x *Quote) Reset() {
+	*x = Quote{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Quote) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Quote) ProtoMessage() {}
+
+func (x *Quote) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Quote.ProtoReflect.Descriptor instead.
+func (*Quote) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Quote) GetQuote() []byte {
+	if x != nil {
+		return x.Quote
+	}
+	return nil
+}
+
+func (x *Quote) GetRawSig() []byte {
+	if x != nil {
+		return x.RawSig
+	}
+	return nil
+}
+
+func (x *Quote) GetPcrs() *PCRs {
+	if x != nil {
+		return x.Pcrs
+	}
+	return nil
+}
+
+// The contents of an NV index, certified by a signing key (usually an AK)
+type NVCertification struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// TPM2_NV_Certify output, encoded as a TPMS_ATTEST
+	CertifyInfo []byte `protobuf:"bytes,1,opt,name=certify_info,json=certifyInfo,proto3" json:"certify_info,omitempty"`
+	// TPM2 signature, encoded as a TPMT_SIGNATURE
+	RawSig []byte `protobuf:"bytes,2,opt,name=raw_sig,json=rawSig,proto3" json:"raw_sig,omitempty"`
+	// Public area of the certified NV index, encoded as a TPMS_NV_PUBLIC
+	NvPublic []byte `protobuf:"bytes,3,opt,name=nv_public,json=nvPublic,proto3" json:"nv_public,omitempty"`
+}
+
+func (x *NVCertification) Reset() {
+	*x = NVCertification{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NVCertification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NVCertification) ProtoMessage() {}
+
+func (x *NVCertification) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NVCertification.ProtoReflect.Descriptor instead.
+func (*NVCertification) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *NVCertification) GetCertifyInfo() []byte {
+	if x != nil {
+		return x.CertifyInfo
+	}
+	return nil
+}
+
+func (x *NVCertification) GetRawSig() []byte {
+	if x != nil {
+		return x.RawSig
+	}
+	return nil
+}
+
+func (x *NVCertification) GetNvPublic() []byte {
+	if x != nil {
+		return x.NvPublic
+	}
+	return nil
+}
+
+// The public area of a key, certified by a signing key (usually an AK)
+type KeyCertification struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// TPM2_Certify output, encoded as a TPMS_ATTEST
+	CertifyInfo []byte `protobuf:"bytes,1,opt,name=certify_info,json=certifyInfo,proto3" json:"certify_info,omitempty"`
+	// TPM2 signature, encoded as a TPMT_SIGNATURE
+	RawSig []byte `protobuf:"bytes,2,opt,name=raw_sig,json=rawSig,proto3" json:"raw_sig,omitempty"`
+	// Public area of the certified key, encoded as a TPMT_PUBLIC
+	PublicArea []byte `protobuf:"bytes,3,opt,name=public_area,json=publicArea,proto3" json:"public_area,omitempty"`
+}
+
+func (x *KeyCertification) Reset() {
+	*x = KeyCertification{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KeyCertification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyCertification) ProtoMessage() {}
+
+func (x *KeyCertification) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyCertification.ProtoReflect.Descriptor instead.
+func (*KeyCertification) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *KeyCertification) GetCertifyInfo() []byte {
+	if x != nil {
+		return x.CertifyInfo
+	}
+	return nil
+}
+
+func (x *KeyCertification) GetRawSig() []byte {
+	if x != nil {
+		return x.RawSig
+	}
+	return nil
+}
+
+func (x *KeyCertification) GetPublicArea() []byte {
+	if x != nil {
+		return x.PublicArea
+	}
+	return nil
+}
+
+// The TPM's time and clock, signed by a signing key (usually an AK)
+type TimeAttestation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// TPM2_GetTime output, encoded as a TPMS_ATTEST
+	TimeInfo []byte `protobuf:"bytes,1,opt,name=time_info,json=timeInfo,proto3" json:"time_info,omitempty"`
+	// TPM2 signature, encoded as a TPMT_SIGNATURE
+	RawSig []byte `protobuf:"bytes,2,opt,name=raw_sig,json=rawSig,proto3" json:"raw_sig,omitempty"`
+}
+
+func (x *TimeAttestation) Reset() {
+	*x = TimeAttestation{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TimeAttestation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimeAttestation) ProtoMessage() {}
+
+func (x *TimeAttestation) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimeAttestation.ProtoReflect.Descriptor instead.
+func (*TimeAttestation) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TimeAttestation) GetTimeInfo() []byte {
+	if x != nil {
+		return x.TimeInfo
+	}
+	return nil
+}
+
+func (x *TimeAttestation) GetRawSig() []byte {
+	if x != nil {
+		return x.RawSig
+	}
+	return nil
+}
+
+type PCRs struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hash HashAlgo          `protobuf:"varint,1,opt,name=hash,proto3,enum=tpm.HashAlgo" json:"hash,omitempty"`
+	Pcrs map[uint32][]byte `protobuf:"bytes,2,rep,name=pcrs,proto3" json:"pcrs,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *PCRs) Reset() {
+	*x = PCRs{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PCRs) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PCRs) ProtoMessage() {}
+
+func (x *PCRs) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PCRs.ProtoReflect.Descriptor instead.
+func (*PCRs) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *PCRs) GetHash() HashAlgo {
+	if x != nil {
+		return x.Hash
+	}
+	return HashAlgo_HASH_INVALID
+}
+
+func (x *PCRs) GetPcrs() map[uint32][]byte {
+	if x != nil {
+		return x.Pcrs
+	}
+	return nil
+}
+
+// A name given to a TPM object or sealed blob in a NameRegistry
+type RegistryEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Types that are assignable to Target:
+	//	*RegistryEntry_PersistentHandle
+	//	*RegistryEntry_NvIndex
+	//	*RegistryEntry_SealedBlobPath
+	Target isRegistryEntry_Target `protobuf_oneof:"target"`
+	// SHA-256 digest identifying the target when it was registered, computed
+	// over the object's Name, the NV index's public area (without the
+	// TPMA_NV_WRITTEN attribute), or the file's contents.
+	Fingerprint []byte `protobuf:"bytes,5,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
 }
 
-func (x *ImportBlob) Reset() {
-	*x = ImportBlob{}
+func (x *RegistryEntry) Reset() {
+	*x = RegistryEntry{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tpm_proto_msgTypes[1]
+		mi := &file_tpm_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ImportBlob) String() string {
+func (x *RegistryEntry) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ImportBlob) ProtoMessage() {}
+func (*RegistryEntry) ProtoMessage() {}
 
-func (x *ImportBlob) ProtoReflect() protoreflect.Message {
-	mi := &file_tpm_proto_msgTypes[1]
+func (x *RegistryEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -269,69 +912,103 @@ func (x *ImportBlob) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ImportBlob.ProtoReflect.Descriptor instead.
-func (*ImportBlob) Descriptor() ([]byte, []int) {
-	return file_tpm_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use RegistryEntry.ProtoReflect.Descriptor instead.
+func (*RegistryEntry) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *ImportBlob) GetDuplicate() []byte {
+func (x *RegistryEntry) GetName() string {
 	if x != nil {
-		return x.Duplicate
+		return x.Name
 	}
-	return nil
+	return ""
 }
 
-func (x *ImportBlob) GetEncryptedSeed() []byte {
-	if x != nil {
-		return x.EncryptedSeed
+func (m *RegistryEntry) GetTarget() isRegistryEntry_Target {
+	if m != nil {
+		return m.Target
 	}
 	return nil
 }
 
-func (x *ImportBlob) GetPublicArea() []byte {
-	if x != nil {
-		return x.PublicArea
+func (x *RegistryEntry) GetPersistentHandle() uint32 {
+	if x, ok := x.GetTarget().(*RegistryEntry_PersistentHandle); ok {
+		return x.PersistentHandle
 	}
-	return nil
+	return 0
 }
 
-func (x *ImportBlob) GetPcrs() *PCRs {
+func (x *RegistryEntry) GetNvIndex() uint32 {
+	if x, ok := x.GetTarget().(*RegistryEntry_NvIndex); ok {
+		return x.NvIndex
+	}
+	return 0
+}
+
+func (x *RegistryEntry) GetSealedBlobPath() string {
+	if x, ok := x.GetTarget().(*RegistryEntry_SealedBlobPath); ok {
+		return x.SealedBlobPath
+	}
+	return ""
+}
+
+func (x *RegistryEntry) GetFingerprint() []byte {
 	if x != nil {
-		return x.Pcrs
+		return x.Fingerprint
 	}
 	return nil
 }
 
-type Quote struct {
+type isRegistryEntry_Target interface {
+	isRegistryEntry_Target()
+}
+
+type RegistryEntry_PersistentHandle struct {
+	// An owner hierarchy persistent handle
+	PersistentHandle uint32 `protobuf:"varint,2,opt,name=persistent_handle,json=persistentHandle,proto3,oneof"`
+}
+
+type RegistryEntry_NvIndex struct {
+	// An NV index
+	NvIndex uint32 `protobuf:"varint,3,opt,name=nv_index,json=nvIndex,proto3,oneof"`
+}
+
+type RegistryEntry_SealedBlobPath struct {
+	// The absolute path of a file holding SealedBytes
+	SealedBlobPath string `protobuf:"bytes,4,opt,name=sealed_blob_path,json=sealedBlobPath,proto3,oneof"`
+}
+
+func (*RegistryEntry_PersistentHandle) isRegistryEntry_Target() {}
+
+func (*RegistryEntry_NvIndex) isRegistryEntry_Target() {}
+
+func (*RegistryEntry_SealedBlobPath) isRegistryEntry_Target() {}
+
+type Registry struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// TPM2 quote, encoded as a TPMS_ATTEST
-	Quote []byte `protobuf:"bytes,1,opt,name=quote,proto3" json:"quote,omitempty"`
-	// TPM2 signature, encoded as a TPMT_SIGNATURE
-	RawSig []byte `protobuf:"bytes,2,opt,name=raw_sig,json=rawSig,proto3" json:"raw_sig,omitempty"`
-	// PCR values of the bank being quoted
-	Pcrs *PCRs `protobuf:"bytes,3,opt,name=pcrs,proto3" json:"pcrs,omitempty"`
+	Entries []*RegistryEntry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
 }
 
-func (x *Quote) Reset() {
-	*x = Quote{}
+func (x *Registry) Reset() {
+	*x = Registry{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tpm_proto_msgTypes[2]
+		mi := &file_tpm_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Quote) String() string {
+func (x *Registry) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Quote) ProtoMessage() {}
+func (*Registry) ProtoMessage() {}
 
-func (x *Quote) ProtoReflect() protoreflect.Message {
-	mi := &file_tpm_proto_msgTypes[2]
+func (x *Registry) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -342,58 +1019,107 @@ func (x *Quote) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Quote.ProtoReflect.Descriptor instead.
-func (*Quote) Descriptor() ([]byte, []int) {
-	return file_tpm_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use Registry.ProtoReflect.Descriptor instead.
+func (*Registry) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *Quote) GetQuote() []byte {
+func (x *Registry) GetEntries() []*RegistryEntry {
 	if x != nil {
-		return x.Quote
+		return x.Entries
 	}
 	return nil
 }
 
-func (x *Quote) GetRawSig() []byte {
+// A Registry signed by a TPM key, for storage outside the TPM
+type SignedRegistry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The serialized Registry
+	Registry []byte `protobuf:"bytes,1,opt,name=registry,proto3" json:"registry,omitempty"`
+	// ASN.1 DER encoded ECDSA signature over the SHA-256 digest of registry
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *SignedRegistry) Reset() {
+	*x = SignedRegistry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tpm_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SignedRegistry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignedRegistry) ProtoMessage() {}
+
+func (x *SignedRegistry) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignedRegistry.ProtoReflect.Descriptor instead.
+func (*SignedRegistry) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SignedRegistry) GetRegistry() []byte {
 	if x != nil {
-		return x.RawSig
+		return x.Registry
 	}
 	return nil
 }
 
-func (x *Quote) GetPcrs() *PCRs {
+func (x *SignedRegistry) GetSignature() []byte {
 	if x != nil {
-		return x.Pcrs
+		return x.Signature
 	}
 	return nil
 }
 
-type PCRs struct {
+// Data encrypted at rest, for storage outside the TPM (see the atrest package)
+type EncryptedData struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Hash HashAlgo          `protobuf:"varint,1,opt,name=hash,proto3,enum=tpm.HashAlgo" json:"hash,omitempty"`
-	Pcrs map[uint32][]byte `protobuf:"bytes,2,rep,name=pcrs,proto3" json:"pcrs,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// The AES-256 data key, sealed by the TPM's Storage Root Key
+	SealedKey *SealedBytes `protobuf:"bytes,1,opt,name=sealed_key,json=sealedKey,proto3" json:"sealed_key,omitempty"`
+	// The AES-GCM nonce
+	Nonce []byte `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	// The AES-GCM ciphertext, including the authentication tag
+	Ciphertext []byte `protobuf:"bytes,3,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
 }
 
-func (x *PCRs) Reset() {
-	*x = PCRs{}
+func (x *EncryptedData) Reset() {
+	*x = EncryptedData{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tpm_proto_msgTypes[3]
+		mi := &file_tpm_proto_msgTypes[14]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *PCRs) String() string {
+func (x *EncryptedData) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PCRs) ProtoMessage() {}
+func (*EncryptedData) ProtoMessage() {}
 
-func (x *PCRs) ProtoReflect() protoreflect.Message {
-	mi := &file_tpm_proto_msgTypes[3]
+func (x *EncryptedData) ProtoReflect() protoreflect.Message {
+	mi := &file_tpm_proto_msgTypes[14]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -404,21 +1130,28 @@ func (x *PCRs) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PCRs.ProtoReflect.Descriptor instead.
-func (*PCRs) Descriptor() ([]byte, []int) {
-	return file_tpm_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use EncryptedData.ProtoReflect.Descriptor instead.
+func (*EncryptedData) Descriptor() ([]byte, []int) {
+	return file_tpm_proto_rawDescGZIP(), []int{14}
 }
 
-func (x *PCRs) GetHash() HashAlgo {
+func (x *EncryptedData) GetSealedKey() *SealedBytes {
 	if x != nil {
-		return x.Hash
+		return x.SealedKey
 	}
-	return HashAlgo_HASH_INVALID
+	return nil
 }
 
-func (x *PCRs) GetPcrs() map[uint32][]byte {
+func (x *EncryptedData) GetNonce() []byte {
 	if x != nil {
-		return x.Pcrs
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *EncryptedData) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
 	}
 	return nil
 }
@@ -427,7 +1160,7 @@ var File_tpm_proto protoreflect.FileDescriptor
 
 var file_tpm_proto_rawDesc = []byte{
 	0x0a, 0x09, 0x74, 0x70, 0x6d, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x03, 0x74, 0x70, 0x6d,
-	0x22, 0xfc, 0x01, 0x0a, 0x0b, 0x53, 0x65, 0x61, 0x6c, 0x65, 0x64, 0x42, 0x79, 0x74, 0x65, 0x73,
+	0x22, 0xea, 0x02, 0x0a, 0x0b, 0x53, 0x65, 0x61, 0x6c, 0x65, 0x64, 0x42, 0x79, 0x74, 0x65, 0x73,
 	0x12, 0x12, 0x0a, 0x04, 0x70, 0x72, 0x69, 0x76, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04,
 	0x70, 0x72, 0x69, 0x76, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x75, 0x62, 0x18, 0x02, 0x20, 0x01, 0x28,
 	0x0c, 0x52, 0x03, 0x70, 0x75, 0x62, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x63, 0x72, 0x73, 0x18, 0x03,
@@ -442,42 +1175,116 @@ var file_tpm_proto_rawDesc = []byte{
 	0x72, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x72, 0x65, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x64,
 	0x61, 0x74, 0x61, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0c, 0x63, 0x72, 0x65, 0x61, 0x74,
 	0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x61, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x69, 0x63, 0x6b, 0x65,
-	0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x74, 0x69, 0x63, 0x6b, 0x65, 0x74, 0x22,
-	0x91, 0x01, 0x0a, 0x0a, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x62, 0x12, 0x1c,
-	0x0a, 0x09, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0c, 0x52, 0x09, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x25, 0x0a, 0x0e,
-	0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x73, 0x65, 0x65, 0x64, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x53,
-	0x65, 0x65, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f, 0x61, 0x72,
-	0x65, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63,
-	0x41, 0x72, 0x65, 0x61, 0x12, 0x1d, 0x0a, 0x04, 0x70, 0x63, 0x72, 0x73, 0x18, 0x04, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x09, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x50, 0x43, 0x52, 0x73, 0x52, 0x04, 0x70,
-	0x63, 0x72, 0x73, 0x22, 0x55, 0x0a, 0x05, 0x51, 0x75, 0x6f, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05,
-	0x71, 0x75, 0x6f, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x71, 0x75, 0x6f,
-	0x74, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x72, 0x61, 0x77, 0x5f, 0x73, 0x69, 0x67, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x0c, 0x52, 0x06, 0x72, 0x61, 0x77, 0x53, 0x69, 0x67, 0x12, 0x1d, 0x0a, 0x04, 0x70,
-	0x63, 0x72, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x74, 0x70, 0x6d, 0x2e,
-	0x50, 0x43, 0x52, 0x73, 0x52, 0x04, 0x70, 0x63, 0x72, 0x73, 0x22, 0x8b, 0x01, 0x0a, 0x04, 0x50,
-	0x43, 0x52, 0x73, 0x12, 0x21, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0e, 0x32, 0x0d, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x48, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f,
-	0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x27, 0x0a, 0x04, 0x70, 0x63, 0x72, 0x73, 0x18, 0x02,
-	0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x50, 0x43, 0x52, 0x73, 0x2e,
-	0x50, 0x63, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x04, 0x70, 0x63, 0x72, 0x73, 0x1a,
-	0x37, 0x0a, 0x09, 0x50, 0x63, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
-	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
-	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76,
-	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x2a, 0x32, 0x0a, 0x0a, 0x4f, 0x62, 0x6a, 0x65,
-	0x63, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x0e, 0x4f, 0x42, 0x4a, 0x45, 0x43, 0x54,
-	0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x52, 0x53,
-	0x41, 0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x45, 0x43, 0x43, 0x10, 0x23, 0x2a, 0x4a, 0x0a, 0x08,
-	0x48, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f, 0x12, 0x10, 0x0a, 0x0c, 0x48, 0x41, 0x53, 0x48,
-	0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x53, 0x48,
-	0x41, 0x31, 0x10, 0x04, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36, 0x10, 0x0b,
-	0x12, 0x0a, 0x0a, 0x06, 0x53, 0x48, 0x41, 0x33, 0x38, 0x34, 0x10, 0x0c, 0x12, 0x0a, 0x0a, 0x06,
-	0x53, 0x48, 0x41, 0x35, 0x31, 0x32, 0x10, 0x0d, 0x42, 0x2a, 0x5a, 0x28, 0x67, 0x69, 0x74, 0x68,
-	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x67, 0x6f,
-	0x2d, 0x74, 0x70, 0x6d, 0x2d, 0x74, 0x6f, 0x6f, 0x6c, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x2f, 0x74, 0x70, 0x6d, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x74, 0x69, 0x63, 0x6b, 0x65, 0x74, 0x12,
+	0x28, 0x0a, 0x07, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0e, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x4e, 0x56, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72,
+	0x52, 0x07, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x12, 0x42, 0x0a, 0x11, 0x61, 0x75, 0x74,
+	0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x64, 0x5f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x0a,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x6f,
+	0x72, 0x69, 0x7a, 0x65, 0x64, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x10, 0x61, 0x75, 0x74,
+	0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x64, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x22, 0x4f, 0x0a,
+	0x10, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x64, 0x50, 0x6f, 0x6c, 0x69, 0x63,
+	0x79, 0x12, 0x1c, 0x0a, 0x09, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12,
+	0x1d, 0x0a, 0x0a, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x5f, 0x72, 0x65, 0x66, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x65, 0x66, 0x22, 0x4e,
+	0x0a, 0x0f, 0x53, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x50, 0x43, 0x52, 0x50, 0x6f, 0x6c, 0x69, 0x63,
+	0x79, 0x12, 0x1d, 0x0a, 0x04, 0x70, 0x63, 0x72, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x09, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x50, 0x43, 0x52, 0x73, 0x52, 0x04, 0x70, 0x63, 0x72, 0x73,
+	0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22, 0x37,
+	0x0a, 0x09, 0x4e, 0x56, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x69,
+	0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65,
+	0x78, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x91, 0x01, 0x0a, 0x0a, 0x49, 0x6d, 0x70, 0x6f,
+	0x72, 0x74, 0x42, 0x6c, 0x6f, 0x62, 0x12, 0x1c, 0x0a, 0x09, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x64, 0x75, 0x70, 0x6c, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65,
+	0x64, 0x5f, 0x73, 0x65, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x65, 0x6e,
+	0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x53, 0x65, 0x65, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x70,
+	0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f, 0x61, 0x72, 0x65, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x0a, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x41, 0x72, 0x65, 0x61, 0x12, 0x1d, 0x0a, 0x04,
+	0x70, 0x63, 0x72, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x74, 0x70, 0x6d,
+	0x2e, 0x50, 0x43, 0x52, 0x73, 0x52, 0x04, 0x70, 0x63, 0x72, 0x73, 0x22, 0x69, 0x0a, 0x13, 0x45,
+	0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x43, 0x72, 0x65, 0x64, 0x65, 0x6e, 0x74, 0x69,
+	0x61, 0x6c, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x72, 0x65, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c,
+	0x5f, 0x62, 0x6c, 0x6f, 0x62, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0e, 0x63, 0x72, 0x65,
+	0x64, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x42, 0x6c, 0x6f, 0x62, 0x12, 0x29, 0x0a, 0x10, 0x65,
+	0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0f, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64,
+	0x53, 0x65, 0x63, 0x72, 0x65, 0x74, 0x22, 0x55, 0x0a, 0x05, 0x51, 0x75, 0x6f, 0x74, 0x65, 0x12,
+	0x14, 0x0a, 0x05, 0x71, 0x75, 0x6f, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05,
+	0x71, 0x75, 0x6f, 0x74, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x72, 0x61, 0x77, 0x5f, 0x73, 0x69, 0x67,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x72, 0x61, 0x77, 0x53, 0x69, 0x67, 0x12, 0x1d,
+	0x0a, 0x04, 0x70, 0x63, 0x72, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x74,
+	0x70, 0x6d, 0x2e, 0x50, 0x43, 0x52, 0x73, 0x52, 0x04, 0x70, 0x63, 0x72, 0x73, 0x22, 0x6a, 0x0a,
+	0x0f, 0x4e, 0x56, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x21, 0x0a, 0x0c, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x79, 0x5f, 0x69, 0x6e, 0x66, 0x6f,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x79, 0x49,
+	0x6e, 0x66, 0x6f, 0x12, 0x17, 0x0a, 0x07, 0x72, 0x61, 0x77, 0x5f, 0x73, 0x69, 0x67, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x72, 0x61, 0x77, 0x53, 0x69, 0x67, 0x12, 0x1b, 0x0a, 0x09,
+	0x6e, 0x76, 0x5f, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x08, 0x6e, 0x76, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x22, 0x6f, 0x0a, 0x10, 0x4b, 0x65, 0x79,
+	0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a,
+	0x0c, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x79, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x79, 0x49, 0x6e, 0x66, 0x6f,
+	0x12, 0x17, 0x0a, 0x07, 0x72, 0x61, 0x77, 0x5f, 0x73, 0x69, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x06, 0x72, 0x61, 0x77, 0x53, 0x69, 0x67, 0x12, 0x1f, 0x0a, 0x0b, 0x70, 0x75, 0x62,
+	0x6c, 0x69, 0x63, 0x5f, 0x61, 0x72, 0x65, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a,
+	0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x41, 0x72, 0x65, 0x61, 0x22, 0x47, 0x0a, 0x0f, 0x54, 0x69,
+	0x6d, 0x65, 0x41, 0x74, 0x74, 0x65, 0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1b, 0x0a,
+	0x09, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x08, 0x74, 0x69, 0x6d, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x17, 0x0a, 0x07, 0x72, 0x61,
+	0x77, 0x5f, 0x73, 0x69, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x72, 0x61, 0x77,
+	0x53, 0x69, 0x67, 0x22, 0x8b, 0x01, 0x0a, 0x04, 0x50, 0x43, 0x52, 0x73, 0x12, 0x21, 0x0a, 0x04,
+	0x68, 0x61, 0x73, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0d, 0x2e, 0x74, 0x70, 0x6d,
+	0x2e, 0x48, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12,
+	0x27, 0x0a, 0x04, 0x70, 0x63, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e,
+	0x74, 0x70, 0x6d, 0x2e, 0x50, 0x43, 0x52, 0x73, 0x2e, 0x50, 0x63, 0x72, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x52, 0x04, 0x70, 0x63, 0x72, 0x73, 0x1a, 0x37, 0x0a, 0x09, 0x50, 0x63, 0x72, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x22, 0xc7, 0x01, 0x0a, 0x0d, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x72, 0x79, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x2d, 0x0a, 0x11, 0x70, 0x65, 0x72, 0x73, 0x69,
+	0x73, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x48, 0x00, 0x52, 0x10, 0x70, 0x65, 0x72, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x74,
+	0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x12, 0x1b, 0x0a, 0x08, 0x6e, 0x76, 0x5f, 0x69, 0x6e, 0x64,
+	0x65, 0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x00, 0x52, 0x07, 0x6e, 0x76, 0x49, 0x6e,
+	0x64, 0x65, 0x78, 0x12, 0x2a, 0x0a, 0x10, 0x73, 0x65, 0x61, 0x6c, 0x65, 0x64, 0x5f, 0x62, 0x6c,
+	0x6f, 0x62, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52,
+	0x0e, 0x73, 0x65, 0x61, 0x6c, 0x65, 0x64, 0x42, 0x6c, 0x6f, 0x62, 0x50, 0x61, 0x74, 0x68, 0x12,
+	0x20, 0x0a, 0x0b, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e,
+	0x74, 0x42, 0x08, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x22, 0x38, 0x0a, 0x08, 0x52,
+	0x65, 0x67, 0x69, 0x73, 0x74, 0x72, 0x79, 0x12, 0x2c, 0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69,
+	0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x52,
+	0x65, 0x67, 0x69, 0x73, 0x74, 0x72, 0x79, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x65, 0x6e,
+	0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0x4a, 0x0a, 0x0e, 0x53, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x52,
+	0x65, 0x67, 0x69, 0x73, 0x74, 0x72, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x67, 0x69, 0x73,
+	0x74, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x72, 0x65, 0x67, 0x69, 0x73,
+	0x74, 0x72, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72,
+	0x65, 0x22, 0x76, 0x0a, 0x0d, 0x45, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x44, 0x61,
+	0x74, 0x61, 0x12, 0x2f, 0x0a, 0x0a, 0x73, 0x65, 0x61, 0x6c, 0x65, 0x64, 0x5f, 0x6b, 0x65, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x74, 0x70, 0x6d, 0x2e, 0x53, 0x65, 0x61,
+	0x6c, 0x65, 0x64, 0x42, 0x79, 0x74, 0x65, 0x73, 0x52, 0x09, 0x73, 0x65, 0x61, 0x6c, 0x65, 0x64,
+	0x4b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x69, 0x70,
+	0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x63,
+	0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x2a, 0x32, 0x0a, 0x0a, 0x4f, 0x62, 0x6a,
+	0x65, 0x63, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x0e, 0x4f, 0x42, 0x4a, 0x45, 0x43,
+	0x54, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x52,
+	0x53, 0x41, 0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x45, 0x43, 0x43, 0x10, 0x23, 0x2a, 0x4a, 0x0a,
+	0x08, 0x48, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f, 0x12, 0x10, 0x0a, 0x0c, 0x48, 0x41, 0x53,
+	0x48, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x53,
+	0x48, 0x41, 0x31, 0x10, 0x04, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36, 0x10,
+	0x0b, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x48, 0x41, 0x33, 0x38, 0x34, 0x10, 0x0c, 0x12, 0x0a, 0x0a,
+	0x06, 0x53, 0x48, 0x41, 0x35, 0x31, 0x32, 0x10, 0x0d, 0x42, 0x2a, 0x5a, 0x28, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x67,
+	0x6f, 0x2d, 0x74, 0x70, 0x6d, 0x2d, 0x74, 0x6f, 0x6f, 0x6c, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x2f, 0x74, 0x70, 0x6d, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -493,29 +1300,45 @@ func file_tpm_proto_rawDescGZIP() []byte {
 }
 
 var file_tpm_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_tpm_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_tpm_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
 var file_tpm_proto_goTypes = []interface{}{
-	(ObjectType)(0),     // 0: tpm.ObjectType
-	(HashAlgo)(0),       // 1: tpm.HashAlgo
-	(*SealedBytes)(nil), // 2: tpm.SealedBytes
-	(*ImportBlob)(nil),  // 3: tpm.ImportBlob
-	(*Quote)(nil),       // 4: tpm.Quote
-	(*PCRs)(nil),        // 5: tpm.PCRs
-	nil,                 // 6: tpm.PCRs.PcrsEntry
+	(ObjectType)(0),             // 0: tpm.ObjectType
+	(HashAlgo)(0),               // 1: tpm.HashAlgo
+	(*SealedBytes)(nil),         // 2: tpm.SealedBytes
+	(*AuthorizedPolicy)(nil),    // 3: tpm.AuthorizedPolicy
+	(*SignedPCRPolicy)(nil),     // 4: tpm.SignedPCRPolicy
+	(*NVCounter)(nil),           // 5: tpm.NVCounter
+	(*ImportBlob)(nil),          // 6: tpm.ImportBlob
+	(*EncryptedCredential)(nil), // 7: tpm.EncryptedCredential
+	(*Quote)(nil),               // 8: tpm.Quote
+	(*NVCertification)(nil),     // 9: tpm.NVCertification
+	(*KeyCertification)(nil),    // 10: tpm.KeyCertification
+	(*TimeAttestation)(nil),     // 11: tpm.TimeAttestation
+	(*PCRs)(nil),                // 12: tpm.PCRs
+	(*RegistryEntry)(nil),       // 13: tpm.RegistryEntry
+	(*Registry)(nil),            // 14: tpm.Registry
+	(*SignedRegistry)(nil),      // 15: tpm.SignedRegistry
+	(*EncryptedData)(nil),       // 16: tpm.EncryptedData
+	nil,                         // 17: tpm.PCRs.PcrsEntry
 }
 var file_tpm_proto_depIdxs = []int32{
-	1, // 0: tpm.SealedBytes.hash:type_name -> tpm.HashAlgo
-	0, // 1: tpm.SealedBytes.srk:type_name -> tpm.ObjectType
-	5, // 2: tpm.SealedBytes.certified_pcrs:type_name -> tpm.PCRs
-	5, // 3: tpm.ImportBlob.pcrs:type_name -> tpm.PCRs
-	5, // 4: tpm.Quote.pcrs:type_name -> tpm.PCRs
-	1, // 5: tpm.PCRs.hash:type_name -> tpm.HashAlgo
-	6, // 6: tpm.PCRs.pcrs:type_name -> tpm.PCRs.PcrsEntry
-	7, // [7:7] is the sub-list for method output_type
-	7, // [7:7] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	7, // [7:7] is the sub-list for extension extendee
-	0, // [0:7] is the sub-list for field type_name
+	1,  // 0: tpm.SealedBytes.hash:type_name -> tpm.HashAlgo
+	0,  // 1: tpm.SealedBytes.srk:type_name -> tpm.ObjectType
+	12, // 2: tpm.SealedBytes.certified_pcrs:type_name -> tpm.PCRs
+	5,  // 3: tpm.SealedBytes.counter:type_name -> tpm.NVCounter
+	3,  // 4: tpm.SealedBytes.authorized_policy:type_name -> tpm.AuthorizedPolicy
+	12, // 5: tpm.SignedPCRPolicy.pcrs:type_name -> tpm.PCRs
+	12, // 6: tpm.ImportBlob.pcrs:type_name -> tpm.PCRs
+	12, // 7: tpm.Quote.pcrs:type_name -> tpm.PCRs
+	1,  // 8: tpm.PCRs.hash:type_name -> tpm.HashAlgo
+	17, // 9: tpm.PCRs.pcrs:type_name -> tpm.PCRs.PcrsEntry
+	13, // 10: tpm.Registry.entries:type_name -> tpm.RegistryEntry
+	2,  // 11: tpm.EncryptedData.sealed_key:type_name -> tpm.SealedBytes
+	12, // [12:12] is the sub-list for method output_type
+	12, // [12:12] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
 }
 
 func init() { file_tpm_proto_init() }
@@ -537,7 +1360,7 @@ func file_tpm_proto_init() {
 			}
 		}
 		file_tpm_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ImportBlob); i {
+			switch v := v.(*AuthorizedPolicy); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -549,7 +1372,7 @@ func file_tpm_proto_init() {
 			}
 		}
 		file_tpm_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Quote); i {
+			switch v := v.(*SignedPCRPolicy); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -561,6 +1384,90 @@ func file_tpm_proto_init() {
 			}
 		}
 		file_tpm_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NVCounter); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tpm_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImportBlob); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tpm_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EncryptedCredential); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tpm_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Quote); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tpm_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NVCertification); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tpm_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*KeyCertification); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tpm_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TimeAttestation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tpm_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*PCRs); i {
 			case 0:
 				return &v.state
@@ -572,6 +1479,59 @@ func file_tpm_proto_init() {
 				return nil
 			}
 		}
+		file_tpm_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RegistryEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tpm_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Registry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tpm_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SignedRegistry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tpm_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EncryptedData); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_tpm_proto_msgTypes[11].OneofWrappers = []interface{}{
+		(*RegistryEntry_PersistentHandle)(nil),
+		(*RegistryEntry_NvIndex)(nil),
+		(*RegistryEntry_SealedBlobPath)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -579,7 +1539,7 @@ func file_tpm_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_tpm_proto_rawDesc,
 			NumEnums:      2,
-			NumMessages:   5,
+			NumMessages:   16,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
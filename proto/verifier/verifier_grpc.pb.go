@@ -0,0 +1,141 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.17.3
+// source: verifier.proto
+
+package verifier
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// VerifierClient is the client API for Verifier service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VerifierClient interface {
+	GetNonce(ctx context.Context, in *GetNonceRequest, opts ...grpc.CallOption) (*GetNonceResponse, error)
+	VerifyAttestation(ctx context.Context, in *VerifyAttestationRequest, opts ...grpc.CallOption) (*VerifyAttestationResponse, error)
+}
+
+type verifierClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVerifierClient(cc grpc.ClientConnInterface) VerifierClient {
+	return &verifierClient{cc}
+}
+
+func (c *verifierClient) GetNonce(ctx context.Context, in *GetNonceRequest, opts ...grpc.CallOption) (*GetNonceResponse, error) {
+	out := new(GetNonceResponse)
+	err := c.cc.Invoke(ctx, "/verifier.Verifier/GetNonce", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *verifierClient) VerifyAttestation(ctx context.Context, in *VerifyAttestationRequest, opts ...grpc.CallOption) (*VerifyAttestationResponse, error) {
+	out := new(VerifyAttestationResponse)
+	err := c.cc.Invoke(ctx, "/verifier.Verifier/VerifyAttestation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VerifierServer is the server API for Verifier service.
+// All implementations must embed UnimplementedVerifierServer
+// for forward compatibility
+type VerifierServer interface {
+	GetNonce(context.Context, *GetNonceRequest) (*GetNonceResponse, error)
+	VerifyAttestation(context.Context, *VerifyAttestationRequest) (*VerifyAttestationResponse, error)
+	mustEmbedUnimplementedVerifierServer()
+}
+
+// UnimplementedVerifierServer must be embedded to have forward compatible implementations.
+type UnimplementedVerifierServer struct {
+}
+
+func (UnimplementedVerifierServer) GetNonce(context.Context, *GetNonceRequest) (*GetNonceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNonce not implemented")
+}
+func (UnimplementedVerifierServer) VerifyAttestation(context.Context, *VerifyAttestationRequest) (*VerifyAttestationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyAttestation not implemented")
+}
+func (UnimplementedVerifierServer) mustEmbedUnimplementedVerifierServer() {}
+
+// UnsafeVerifierServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VerifierServer will
+// result in compilation errors.
+type UnsafeVerifierServer interface {
+	mustEmbedUnimplementedVerifierServer()
+}
+
+func RegisterVerifierServer(s grpc.ServiceRegistrar, srv VerifierServer) {
+	s.RegisterService(&Verifier_ServiceDesc, srv)
+}
+
+func _Verifier_GetNonce_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNonceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VerifierServer).GetNonce(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/verifier.Verifier/GetNonce",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VerifierServer).GetNonce(ctx, req.(*GetNonceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Verifier_VerifyAttestation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyAttestationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VerifierServer).VerifyAttestation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/verifier.Verifier/VerifyAttestation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VerifierServer).VerifyAttestation(ctx, req.(*VerifyAttestationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Verifier_ServiceDesc is the grpc.ServiceDesc for Verifier service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Verifier_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "verifier.Verifier",
+	HandlerType: (*VerifierServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetNonce",
+			Handler:    _Verifier_GetNonce_Handler,
+		},
+		{
+			MethodName: "VerifyAttestation",
+			Handler:    _Verifier_VerifyAttestation_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "verifier.proto",
+}
@@ -0,0 +1,94 @@
+// Package provenance signs build artifacts with the TPM of the CI runner which
+// built them, so that "built on an attested runner" can be checked by anyone
+// trusting the runner's AK.
+//
+// At the end of a build, the runner:
+//  1. Digests its artifacts with DigestArtifact, and describes the build in a
+//     BuildStatement (the builder, the artifacts, and parameters such as the
+//     source commit).
+//  2. Signs the statement with Sign, producing a BuildProvenance. Its
+//     Attestation's nonce is bound to the statement, so the AK's quotes sign
+//     both the statement and the runner's state (its PCRs and event logs).
+//
+// A consumer verifies the BuildProvenance with Verify, which checks the
+// Attestation (and optionally a Policy for the runner's state), and then
+// checks its copies of the artifacts with CheckArtifact.
+//
+// The Attestation records the runner's boot state and any later measurements
+// (such as a Canonical Event Log), not the build itself: a runner which is
+// compromised after it booted can still sign artifacts. Runners should be
+// ephemeral, measured machines, with a fresh boot for each build.
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+const statementLabel = "GOTPM BUILD PROVENANCE\x00"
+
+// DigestArtifact returns the BuildSubject of an artifact, read from r.
+func DigestArtifact(name string, r io.Reader) (*pb.BuildSubject, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, fmt.Errorf("failed to read artifact %q: %w", name, err)
+	}
+	return &pb.BuildSubject{Name: name, Sha256: h.Sum(nil)}, nil
+}
+
+// Sign attests to the runner's state with the attester (usually the AK, see
+// client.Key), binding the attestation to the statement.
+func Sign(attester client.Attester, statement *pb.BuildStatement) (*pb.BuildProvenance, error) {
+	nonce, err := statementNonce(statement)
+	if err != nil {
+		return nil, err
+	}
+	attestation, err := attester.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attest: %w", err)
+	}
+	return &pb.BuildProvenance{Statement: statement, Attestation: attestation}, nil
+}
+
+// statementNonce checks a statement, and returns the nonce binding an
+// attestation to it: the digest of an unambiguous encoding of its fields.
+func statementNonce(statement *pb.BuildStatement) ([]byte, error) {
+	if len(statement.GetSubjects()) == 0 {
+		return nil, errors.New("build statement has no subjects")
+	}
+	h := sha256.New()
+	h.Write([]byte(statementLabel))
+	writeField(h, []byte(statement.GetBuilderId()))
+	binary.Write(h, binary.BigEndian, uint32(len(statement.GetSubjects())))
+	names := make(map[string]bool)
+	for _, subject := range statement.GetSubjects() {
+		if names[subject.GetName()] {
+			return nil, fmt.Errorf("build statement has more than one subject named %q", subject.GetName())
+		}
+		names[subject.GetName()] = true
+		if len(subject.GetSha256()) != sha256.Size {
+			return nil, fmt.Errorf("subject %q has a %d byte digest, want %d bytes", subject.GetName(), len(subject.GetSha256()), sha256.Size)
+		}
+		writeField(h, []byte(subject.GetName()))
+		writeField(h, subject.GetSha256())
+	}
+	binary.Write(h, binary.BigEndian, uint32(len(statement.GetParameters())))
+	for _, param := range statement.GetParameters() {
+		writeField(h, []byte(param.GetName()))
+		writeField(h, []byte(param.GetValue()))
+	}
+	return h.Sum(nil), nil
+}
+
+// writeField writes a length-prefixed field.
+func writeField(h hash.Hash, field []byte) {
+	binary.Write(h, binary.BigEndian, uint32(len(field)))
+	h.Write(field)
+}
@@ -0,0 +1,152 @@
+package provenance
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/server"
+	"google.golang.org/protobuf/proto"
+)
+
+var artifacts = map[string]string{
+	"bin/server":  "server binary",
+	"bin/client":  "client binary",
+	"image.tar":   "container image",
+	"checksums":   "checksums",
+	"release.txt": "release notes",
+}
+
+func testStatement(t *testing.T) *pb.BuildStatement {
+	t.Helper()
+	statement := &pb.BuildStatement{
+		BuilderId: "https://ci.example.com/runners/tpm",
+		Parameters: []*pb.BuildParameter{
+			{Name: "commit", Value: "0123456789abcdef"},
+			{Name: "workflow", Value: "release"},
+		},
+	}
+	for name, contents := range artifacts {
+		subject, err := DigestArtifact(name, strings.NewReader(contents))
+		if err != nil {
+			t.Fatal(err)
+		}
+		statement.Subjects = append(statement.Subjects, subject)
+	}
+	return statement
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	otherAK, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherAK.Close()
+
+	provenance, err := Sign(ak, testStatement(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := VerifyOpts{
+		VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}},
+		BuilderIDs: []string{"https://ci.example.com/runners/tpm"},
+	}
+	state, err := Verify(provenance, opts)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if len(state.GetAkName()) == 0 {
+		t.Error("Verify() returned a MachineState without the AK name")
+	}
+	for name, contents := range artifacts {
+		if err := CheckArtifact(provenance.GetStatement(), name, strings.NewReader(contents)); err != nil {
+			t.Errorf("CheckArtifact(%q) failed: %v", name, err)
+		}
+	}
+	if err := CheckArtifact(provenance.GetStatement(), "bin/server", strings.NewReader("backdoored")); !errors.Is(err, ErrArtifactModified) {
+		t.Errorf("CheckArtifact() of a modified artifact = %v, want ErrArtifactModified", err)
+	}
+	if err := CheckArtifact(provenance.GetStatement(), "bin/other", strings.NewReader("server binary")); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("CheckArtifact() of an unknown artifact = %v, want ErrArtifactNotFound", err)
+	}
+
+	otherProvenance, err := Sign(otherAK, testStatement(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subtests := []struct {
+		name   string
+		modify func(*pb.BuildProvenance)
+		opts   VerifyOpts
+	}{
+		{"UntrustedAK", func(p *pb.BuildProvenance) {
+			p.Attestation = otherProvenance.GetAttestation()
+		}, opts},
+		{"ModifiedSubject", func(p *pb.BuildProvenance) {
+			p.Statement.Subjects[0].Sha256 = bytes.Repeat([]byte{1}, 32)
+		}, opts},
+		{"AddedSubject", func(p *pb.BuildProvenance) {
+			p.Statement.Subjects = append(p.Statement.Subjects, &pb.BuildSubject{Name: "extra", Sha256: make([]byte, 32)})
+		}, opts},
+		{"ModifiedParameter", func(p *pb.BuildProvenance) {
+			p.Statement.Parameters[0].Value = "fedcba9876543210"
+		}, opts},
+		{"MovedParameter", func(p *pb.BuildProvenance) {
+			// The encoding of the statement is unambiguous.
+			p.Statement.Parameters[0].Name += p.Statement.Parameters[0].Value[:1]
+			p.Statement.Parameters[0].Value = p.Statement.Parameters[0].Value[1:]
+		}, opts},
+		{"UntrustedBuilder", func(p *pb.BuildProvenance) {}, VerifyOpts{
+			VerifyOpts: opts.VerifyOpts,
+			BuilderIDs: []string{"https://ci.example.com/runners/other"},
+		}},
+		{"Policy", func(p *pb.BuildProvenance) {}, VerifyOpts{
+			VerifyOpts: opts.VerifyOpts,
+			Policy:     &pb.Policy{Platform: &pb.PlatformPolicy{MinimumTechnology: pb.GCEConfidentialTechnology_AMD_SEV}},
+		}},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			modified := proto.Clone(provenance).(*pb.BuildProvenance)
+			subtest.modify(modified)
+			if _, err := Verify(modified, subtest.opts); err == nil {
+				t.Error("Verify() should fail")
+			}
+		})
+	}
+}
+
+func TestSignInvalidStatement(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+
+	subject := &pb.BuildSubject{Name: "bin/server", Sha256: make([]byte, 32)}
+	for name, statement := range map[string]*pb.BuildStatement{
+		"NoSubjects":       {BuilderId: "runner"},
+		"ShortDigest":      {Subjects: []*pb.BuildSubject{{Name: "bin/server", Sha256: make([]byte, 20)}}},
+		"DuplicateSubject": {Subjects: []*pb.BuildSubject{subject, subject}},
+	} {
+		if _, err := Sign(ak, statement); err == nil {
+			t.Errorf("%s: Sign() should fail", name)
+		}
+	}
+}
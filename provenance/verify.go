@@ -0,0 +1,86 @@
+package provenance
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/server"
+)
+
+// Errors returned (wrapped) by CheckArtifact.
+var (
+	ErrArtifactNotFound = errors.New("artifact is not in the build statement")
+	ErrArtifactModified = errors.New("artifact does not match its digest in the build statement")
+)
+
+// VerifyOpts configures how Verify checks a BuildProvenance.
+type VerifyOpts struct {
+	// Options for verifying the provenance's Attestation. The Nonce field is
+	// set by Verify. All other fields (such as TrustedAKs) are used as
+	// provided.
+	VerifyOpts server.VerifyOpts
+	// If non-nil, the runner's MachineState must satisfy this Policy.
+	Policy *pb.Policy
+	// If non-empty, the statement's builder_id must be one of these.
+	BuilderIDs []string
+}
+
+// Verify checks that a BuildProvenance's statement was signed by a trusted
+// runner, returning the runner's verified MachineState. The artifacts can
+// then be checked against the statement with CheckArtifact.
+func Verify(provenance *pb.BuildProvenance, opts VerifyOpts) (*pb.MachineState, error) {
+	statement := provenance.GetStatement()
+	if len(opts.BuilderIDs) > 0 && !containsString(opts.BuilderIDs, statement.GetBuilderId()) {
+		return nil, fmt.Errorf("builder %q is not trusted", statement.GetBuilderId())
+	}
+	nonce, err := statementNonce(statement)
+	if err != nil {
+		return nil, err
+	}
+	verifyOpts := opts.VerifyOpts
+	verifyOpts.Nonce = nonce
+	state, err := server.VerifyAttestation(provenance.GetAttestation(), verifyOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify attestation: %w", err)
+	}
+	if opts.Policy != nil {
+		result, err := server.EvaluatePolicy(state, opts.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("runner does not satisfy policy: %w", err)
+		}
+		result.Record(state)
+	}
+	return state, nil
+}
+
+// CheckArtifact checks that an artifact, read from r, is one of the statement's
+// subjects. The statement must come from a BuildProvenance checked with
+// Verify.
+func CheckArtifact(statement *pb.BuildStatement, name string, r io.Reader) error {
+	digest, err := DigestArtifact(name, r)
+	if err != nil {
+		return err
+	}
+	for _, subject := range statement.GetSubjects() {
+		if subject.GetName() != name {
+			continue
+		}
+		if !bytes.Equal(subject.GetSha256(), digest.GetSha256()) {
+			return fmt.Errorf("%q: %w", name, ErrArtifactModified)
+		}
+		return nil
+	}
+	return fmt.Errorf("%q: %w", name, ErrArtifactNotFound)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+// Package quote verifies TPM2 quotes on their own, outside of a full
+// Attestation (for which see server.VerifyAttestation).
+//
+// This package is a supported, stable API: it only depends on go-tpm and the
+// proto packages, and its exported API will not change incompatibly within a
+// major version of this module. Code which copied the quote verification from
+// this module's internal package should use Verify instead.
+//
+//	info, err := quote.Verify(q, trustedAK, quote.Opts{ExtraData: nonce})
+//	if err != nil {
+//		return err
+//	}
+//	pcrs := info.PCRs // The verified PCR values
+package quote
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/google/go-tpm-tools/internal"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// Opts allows for customizing the checks of Verify.
+type Opts struct {
+	// The extraData which the quote must contain, usually a nonce (or its
+	// digest, see client.AttestOpts.NonceHash).
+	ExtraData []byte
+	// The signature schemes which are accepted. If empty, both
+	// tpm2.AlgRSASSA and tpm2.AlgECDSA are accepted, which are the only
+	// supported schemes.
+	AllowedSchemes []tpm2.Algorithm
+	// The weakest hash algorithm accepted for the signature and the quoted
+	// PCR bank. If zero, SHA-256 is the weakest; set it to crypto.SHA1 to
+	// accept quotes of the SHA-1 bank, or SHA-1 signatures.
+	MinimumHash crypto.Hash
+	// If set, the TPM's clock must have been safe when it signed the quote:
+	// its clock value had been saved to NV since it last advanced, so the
+	// clock cannot have been rolled back by an unorderly shutdown.
+	RequireSafeClock bool
+	// If non-zero, the TPM's clock (in milliseconds) must be at least this,
+	// for example the clock of an earlier quote from the same TPM.
+	MinimumClock uint64
+}
+
+// Info is the data signed by a verified quote.
+type Info struct {
+	// The quoted PCR values.
+	PCRs *tpmpb.PCRs
+	// The extraData in the quote.
+	ExtraData []byte
+	// The hash algorithm used by the signature.
+	SignatureHash crypto.Hash
+	// The TPM's clock state when it signed the quote. Note that the reset and
+	// restart counts are obfuscated for keys outside the Endorsement and
+	// Platform hierarchies, such as the AKs created by the client package.
+	Clock tpm2.ClockInfo
+	// The TPM's firmware version, which is obfuscated like the reset count.
+	FirmwareVersion uint64
+}
+
+// Verify checks that a quote was signed by the trusted public key, over the
+// provided PCR values and with the expected extraData, and that it satisfies
+// the requirements in opts. The caller must have already established trust in
+// the public key, usually an AK, before verifying the quote. It returns the
+// verified contents of the quote.
+//
+// Verify supports ECDSA and RSASSA signatures, with RSA and ECC public keys.
+func Verify(q *tpmpb.Quote, trustedPub crypto.PublicKey, opts Opts) (*Info, error) {
+	minimumHash := opts.MinimumHash
+	if minimumHash == 0 {
+		minimumHash = crypto.SHA256
+	}
+	if !minimumHash.Available() {
+		return nil, fmt.Errorf("unsupported minimum hash algorithm %v", minimumHash)
+	}
+
+	sig, err := tpm2.DecodeSignature(bytes.NewBuffer(q.GetRawSig()))
+	if err != nil {
+		return nil, fmt.Errorf("signature decoding failed: %w", err)
+	}
+	if len(opts.AllowedSchemes) != 0 && !containsAlgorithm(opts.AllowedSchemes, sig.Alg) {
+		return nil, fmt.Errorf("signature scheme %v is not allowed", sig.Alg)
+	}
+	if err := internal.VerifyQuote(q, trustedPub, opts.ExtraData); err != nil {
+		return nil, err
+	}
+
+	attest, err := internal.DecodeAttest(q.GetQuote())
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{
+		PCRs:            q.GetPcrs(),
+		ExtraData:       attest.ExtraData,
+		Clock:           attest.ClockInfo,
+		FirmwareVersion: attest.FirmwareVersion,
+	}
+	if info.SignatureHash, err = signatureHash(sig); err != nil {
+		return nil, err
+	}
+	if info.SignatureHash.Size() < minimumHash.Size() {
+		return nil, fmt.Errorf("signature hash algorithm %v is weaker than %v", info.SignatureHash, minimumHash)
+	}
+	pcrHash, err := tpm2.Algorithm(info.PCRs.GetHash()).Hash()
+	if err != nil {
+		return nil, err
+	}
+	if pcrHash.Size() < minimumHash.Size() {
+		return nil, fmt.Errorf("quoted PCR bank %v is weaker than %v", pcrHash, minimumHash)
+	}
+
+	if opts.RequireSafeClock && info.Clock.Safe == 0 {
+		return nil, fmt.Errorf("the TPM's clock was not safe when it signed the quote")
+	}
+	if info.Clock.Clock < opts.MinimumClock {
+		return nil, fmt.Errorf("the TPM's clock %d is before the minimum clock %d", info.Clock.Clock, opts.MinimumClock)
+	}
+	return info, nil
+}
+
+func signatureHash(sig *tpm2.Signature) (crypto.Hash, error) {
+	switch {
+	case sig.RSA != nil:
+		return sig.RSA.HashAlg.Hash()
+	case sig.ECC != nil:
+		return sig.ECC.HashAlg.Hash()
+	}
+	return 0, fmt.Errorf("unsupported signature scheme %v", sig.Alg)
+}
+
+func containsAlgorithm(algs []tpm2.Algorithm, alg tpm2.Algorithm) bool {
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package quote
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+func TestVerify(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	rsaAK, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsaAK.Close()
+	eccAK, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eccAK.Close()
+
+	nonce := []byte("quote nonce")
+	quote := func(ak *client.Key, hash tpm2.Algorithm) *tpmpb.Quote {
+		t.Helper()
+		q, err := ak.Quote(tpm2.PCRSelection{Hash: hash, PCRs: []int{0, 7}}, nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+	rsaQuote := quote(rsaAK, tpm2.AlgSHA256)
+	eccQuote := quote(eccAK, tpm2.AlgSHA256)
+	sha1Quote := quote(eccAK, tpm2.AlgSHA1)
+
+	info, err := Verify(eccQuote, eccAK.PublicKey(), Opts{ExtraData: nonce})
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if info.SignatureHash != crypto.SHA256 || len(info.PCRs.GetPcrs()) != 2 || string(info.ExtraData) != string(nonce) {
+		t.Errorf("Verify() = %+v, want the quoted SHA-256 PCRs 0 and 7 with the nonce", info)
+	}
+	safe := info.Clock.Safe != 0
+
+	subtests := []struct {
+		name    string
+		quote   *tpmpb.Quote
+		ak      *client.Key
+		opts    Opts
+		wantErr bool
+	}{
+		{"RSA", rsaQuote, rsaAK, Opts{ExtraData: nonce}, false},
+		{"WrongAK", rsaQuote, eccAK, Opts{ExtraData: nonce}, true},
+		{"WrongExtraData", eccQuote, eccAK, Opts{ExtraData: []byte("other nonce")}, true},
+		{"SchemeAllowed", eccQuote, eccAK, Opts{ExtraData: nonce, AllowedSchemes: []tpm2.Algorithm{tpm2.AlgECDSA}}, false},
+		{"SchemeNotAllowed", rsaQuote, rsaAK, Opts{ExtraData: nonce, AllowedSchemes: []tpm2.Algorithm{tpm2.AlgECDSA}}, true},
+		{"SHA1BankByDefault", sha1Quote, eccAK, Opts{ExtraData: nonce}, true},
+		{"SHA1BankAllowed", sha1Quote, eccAK, Opts{ExtraData: nonce, MinimumHash: crypto.SHA1}, false},
+		{"SHA384Floor", eccQuote, eccAK, Opts{ExtraData: nonce, MinimumHash: crypto.SHA384}, true},
+		{"MinimumClock", eccQuote, eccAK, Opts{ExtraData: nonce, MinimumClock: info.Clock.Clock}, false},
+		{"ClockTooEarly", eccQuote, eccAK, Opts{ExtraData: nonce, MinimumClock: info.Clock.Clock + 1}, true},
+		{"SafeClock", eccQuote, eccAK, Opts{ExtraData: nonce, RequireSafeClock: true}, !safe},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			_, err := Verify(subtest.quote, subtest.ak.PublicKey(), subtest.opts)
+			if gotErr := err != nil; gotErr != subtest.wantErr {
+				t.Errorf("Verify() = %v, want error: %v", err, subtest.wantErr)
+			}
+		})
+	}
+}
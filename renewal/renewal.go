@@ -0,0 +1,148 @@
+// Package renewal gates the renewal of a machine's certificates (such as ACME
+// certificates identifying the machine) on the machine's attested state, so a
+// compromised machine which is still running cannot silently keep renewing
+// its identity.
+//
+// Before each renewal, a Gate attests the machine with a fresh nonce, and
+// checks the attestation with a Checker: either locally, against a cached
+// Policy (PolicyChecker), or with a remote verifier service
+// (VerifierChecker). The renewal only proceeds if the check passes:
+//
+//	gate := &renewal.Gate{Attester: ak, Checker: &renewal.PolicyChecker{
+//		VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}},
+//		Policy:     cachedPolicy,
+//	}}
+//	// After the ACME order's authorizations are fulfilled:
+//	der, certURL, err := gate.CreateOrderCert(ctx, acmeClient, order.FinalizeURL, csr, true)
+package renewal
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/acme"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+	"github.com/google/go-tpm-tools/server"
+)
+
+// NonceSize is the size (in bytes) of the nonces used by PolicyChecker.
+const NonceSize = 32
+
+// ErrRenewalDenied is matched (with errors.Is) by the errors of a Gate when
+// the machine's attestation did not pass its Checker, and so the renewal did
+// not happen. The errors also wrap the Checker's error.
+var ErrRenewalDenied = errors.New("renewal denied by attestation check")
+
+type deniedError struct {
+	err error
+}
+
+func (e deniedError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrRenewalDenied, e.err)
+}
+
+func (e deniedError) Unwrap() error {
+	return e.err
+}
+
+func (e deniedError) Is(target error) bool {
+	return target == ErrRenewalDenied
+}
+
+// Checker checks whether a machine may renew its certificates, using
+// attestations from the machine's Attester.
+type Checker interface {
+	Check(ctx context.Context, attester client.Attester) error
+}
+
+// PolicyChecker verifies an attestation locally, with a fresh random nonce,
+// and then checks the machine's state against a Policy (for example, one
+// cached from the fleet's configuration).
+type PolicyChecker struct {
+	// Options for verifying the attestation. The Nonce field is set by the
+	// PolicyChecker. All other fields (such as TrustedAKs) are used as
+	// provided.
+	VerifyOpts server.VerifyOpts
+	// If non-nil, the machine's MachineState must satisfy this Policy.
+	Policy *pb.Policy
+}
+
+// Check attests, verifies the attestation and evaluates the Policy.
+func (c *PolicyChecker) Check(ctx context.Context, attester client.Attester) error {
+	nonce := make([]byte, NonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	attestation, err := attester.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		return fmt.Errorf("failed to attest: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	opts := c.VerifyOpts
+	opts.Nonce = nonce
+	state, err := server.VerifyAttestation(attestation, opts)
+	if err != nil {
+		return fmt.Errorf("failed to verify attestation: %w", err)
+	}
+	if c.Policy != nil {
+		if _, err := server.EvaluatePolicy(state, c.Policy); err != nil {
+			return fmt.Errorf("machine does not satisfy policy: %w", err)
+		}
+	}
+	return nil
+}
+
+// VerifierChecker checks attestations with a remote verifier service (see
+// client.AttestToVerifier), which rejects machines it does not trust.
+type VerifierChecker struct {
+	Client verifierpb.VerifierClient
+}
+
+// Check attests to the remote verifier.
+func (c *VerifierChecker) Check(ctx context.Context, attester client.Attester) error {
+	_, err := client.AttestToVerifier(ctx, attester, c.Client)
+	return err
+}
+
+// Gate only allows renewals while the machine's attestations pass a Checker.
+// Each renewal is checked with a fresh attestation, as the machine's state may
+// have changed since the last renewal.
+type Gate struct {
+	Attester client.Attester
+	Checker  Checker
+}
+
+// Check attests the machine and checks the attestation. The returned error
+// matches ErrRenewalDenied if the check failed.
+func (g *Gate) Check(ctx context.Context) error {
+	if err := g.Checker.Check(ctx, g.Attester); err != nil {
+		return deniedError{err}
+	}
+	return nil
+}
+
+// Renew calls renew only if Check passes.
+func (g *Gate) Renew(ctx context.Context, renew func(context.Context) error) error {
+	if err := g.Check(ctx); err != nil {
+		return err
+	}
+	return renew(ctx)
+}
+
+// CreateOrderCert is acme.Client.CreateOrderCert, which submits the CSR to
+// finalize an ACME order, but only if Check passes. Without the CSR, the CA
+// does not issue the renewed certificate.
+func (g *Gate) CreateOrderCert(ctx context.Context, c *acme.Client, url string, csr []byte, bundle bool) (der [][]byte, certURL string, err error) {
+	if err := g.Check(ctx); err != nil {
+		return nil, "", err
+	}
+	return c.CreateOrderCert(ctx, url, csr, bundle)
+}
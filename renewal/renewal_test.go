@@ -0,0 +1,98 @@
+package renewal
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/acme"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/server"
+	"github.com/google/go-tpm-tools/testutil"
+)
+
+func TestPolicyChecker(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	otherAK, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherAK.Close()
+
+	subtests := []struct {
+		name    string
+		checker *PolicyChecker
+		allowed bool
+	}{
+		{"TrustedAK", &PolicyChecker{VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}}}, true},
+		{"UntrustedAK", &PolicyChecker{VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{otherAK.PublicKey()}}}, false},
+		{"PolicySatisfied", &PolicyChecker{
+			VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}},
+			Policy:     &pb.Policy{Platform: &pb.PlatformPolicy{MinimumGceFirmwareVersion: 1}},
+		}, true},
+		{"PolicyViolated", &PolicyChecker{
+			VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}},
+			Policy:     &pb.Policy{Platform: &pb.PlatformPolicy{MinimumGceFirmwareVersion: 2}},
+		}, false},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			gate := &Gate{Attester: ak, Checker: subtest.checker}
+			renewed := false
+			err := gate.Renew(context.Background(), func(context.Context) error {
+				renewed = true
+				return nil
+			})
+			if subtest.allowed && (err != nil || !renewed) {
+				t.Errorf("Renew() should renew, got error %v", err)
+			}
+			if !subtest.allowed && (!errors.Is(err, ErrRenewalDenied) || renewed) {
+				t.Errorf("Renew() should be denied, got error %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifierChecker(t *testing.T) {
+	nonce := []byte("verifier nonce")
+	verifier := &testutil.VerifierClient{Nonce: nonce}
+	gate := &Gate{Attester: &testutil.Attester{}, Checker: &VerifierChecker{verifier}}
+	if err := gate.Check(context.Background()); err != nil {
+		t.Errorf("Check() failed: %v", err)
+	}
+
+	verifier.VerifyErr = testutil.ErrInjected
+	err := gate.Check(context.Background())
+	if !errors.Is(err, ErrRenewalDenied) || !errors.Is(err, testutil.ErrInjected) {
+		t.Errorf("Check() got error %v, want %v wrapping %v", err, ErrRenewalDenied, testutil.ErrInjected)
+	}
+}
+
+func TestCreateOrderCertDenied(t *testing.T) {
+	ca := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("CA received request %s %s from a denied machine", r.Method, r.URL)
+		http.Error(w, "unexpected request", http.StatusInternalServerError)
+	}))
+	defer ca.Close()
+
+	gate := &Gate{
+		Attester: &testutil.Attester{Err: testutil.ErrInjected},
+		Checker:  &VerifierChecker{&testutil.VerifierClient{}},
+	}
+	acmeClient := &acme.Client{DirectoryURL: ca.URL}
+	if _, _, err := gate.CreateOrderCert(context.Background(), acmeClient, ca.URL+"/finalize", []byte("csr"), true); !errors.Is(err, ErrRenewalDenied) {
+		t.Errorf("CreateOrderCert() got error %v, want %v", err, ErrRenewalDenied)
+	}
+}
@@ -0,0 +1,208 @@
+// Package replay records the raw byte streams exchanged with a TPM, and
+// replays them without a TPM. This allows bugs which only occur with a
+// particular TPM to be reproduced by anyone with a recording.
+//
+// Recordings contain every command sent to the TPM, including any
+// authorization values and unencrypted sensitive data. They should only be
+// shared if the recorded operations did not involve secrets.
+package replay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Recordings start with this header, followed by a sequence of entries. Each
+// entry is a one byte type (entryCommand or entryResponse), a four byte
+// big-endian length, and then that many bytes of data.
+var header = []byte("gotpm-replay\x00v1\n")
+
+const (
+	entryCommand  byte = 'C'
+	entryResponse byte = 'R'
+
+	// No TPM command or response comes close to this size.
+	maxEntrySize = 1 << 20
+)
+
+// ErrMismatch is returned by a Replayer when the library sends a command
+// different from the one in the recording.
+var ErrMismatch = errors.New("command does not match recording")
+
+// Recorder is a TPM transport which records all commands and responses
+// exchanged with an underlying TPM.
+type Recorder struct {
+	tpm io.ReadWriteCloser
+
+	mu  sync.Mutex
+	out io.Writer
+	err error
+}
+
+// NewRecorder wraps a TPM, writing a recording of all the traffic sent over
+// the returned Recorder to out. The Recorder can be used anywhere the TPM
+// would have been used.
+func NewRecorder(tpm io.ReadWriteCloser, out io.Writer) (*Recorder, error) {
+	if _, err := out.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write recording header: %w", err)
+	}
+	return &Recorder{tpm: tpm, out: out}, nil
+}
+
+// Write sends a command to the TPM, recording it.
+func (r *Recorder) Write(cmd []byte) (int, error) {
+	if err := r.record(entryCommand, cmd); err != nil {
+		return 0, err
+	}
+	return r.tpm.Write(cmd)
+}
+
+// Read reads a response from the TPM, recording it.
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.tpm.Read(p)
+	if err != nil {
+		return n, err
+	}
+	if err := r.record(entryResponse, p[:n]); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Close closes the underlying TPM. It does not close the recording's writer.
+func (r *Recorder) Close() error {
+	return r.tpm.Close()
+}
+
+func (r *Recorder) record(entryType byte, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Once a write fails, the recording is corrupt, so stop using the TPM.
+	if r.err != nil {
+		return r.err
+	}
+	var entry bytes.Buffer
+	entry.WriteByte(entryType)
+	binary.Write(&entry, binary.BigEndian, uint32(len(data)))
+	entry.Write(data)
+	if _, err := r.out.Write(entry.Bytes()); err != nil {
+		r.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+	return r.err
+}
+
+type entry struct {
+	entryType byte
+	data      []byte
+}
+
+// Replayer is a TPM transport which replays a recording made by a Recorder.
+// Each command written to the Replayer must exactly match the next command in
+// the recording, and causes the corresponding response to be returned from
+// the next call to Read. Operations which build commands from random data
+// cannot be replayed, as their commands differ each time.
+type Replayer struct {
+	mu      sync.Mutex
+	entries []entry
+	next    int
+}
+
+// NewReplayer parses a recording made by a Recorder.
+func NewReplayer(recording io.Reader) (*Replayer, error) {
+	got := make([]byte, len(header))
+	if _, err := io.ReadFull(recording, got); err != nil || !bytes.Equal(got, header) {
+		return nil, fmt.Errorf("not a TPM recording")
+	}
+
+	var entries []entry
+	for {
+		var prefix [5]byte
+		if _, err := io.ReadFull(recording, prefix[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", len(entries), err)
+		}
+		entryType := prefix[0]
+		if entryType != entryCommand && entryType != entryResponse {
+			return nil, fmt.Errorf("entry %d: unknown type %q", len(entries), entryType)
+		}
+		size := binary.BigEndian.Uint32(prefix[1:])
+		if size > maxEntrySize {
+			return nil, fmt.Errorf("entry %d: size %d is too large", len(entries), size)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(recording, data); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", len(entries), unexpectedEOF(err))
+		}
+		entries = append(entries, entry{entryType, data})
+	}
+	return &Replayer{entries: entries}, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Write checks that cmd matches the next command in the recording. If it does
+// not, ErrMismatch is returned.
+func (r *Replayer) Write(cmd []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, err := r.nextEntry(entryCommand)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(e.data, cmd) {
+		r.next--
+		return 0, fmt.Errorf("%w: entry %d is %x, got %x", ErrMismatch, r.next, e.data, cmd)
+	}
+	return len(cmd), nil
+}
+
+// Read returns the next response in the recording.
+func (r *Replayer) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, err := r.nextEntry(entryResponse)
+	if err != nil {
+		return 0, err
+	}
+	if len(p) < len(e.data) {
+		r.next--
+		return 0, io.ErrShortBuffer
+	}
+	return copy(p, e.data), nil
+}
+
+func (r *Replayer) nextEntry(entryType byte) (entry, error) {
+	if r.next >= len(r.entries) {
+		return entry{}, io.EOF
+	}
+	e := r.entries[r.next]
+	if e.entryType != entryType {
+		return entry{}, fmt.Errorf("%w: entry %d is a %q entry, expected %q", ErrMismatch, r.next, e.entryType, entryType)
+	}
+	r.next++
+	return e, nil
+}
+
+// Remaining returns the number of recorded entries which have not yet been
+// replayed. A complete reproduction leaves no entries remaining.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries) - r.next
+}
+
+// Close does nothing, allowing the Replayer to be used as an
+// io.ReadWriteCloser.
+func (r *Replayer) Close() error {
+	return nil
+}
@@ -0,0 +1,124 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/proto"
+)
+
+// sealAndReadPCRs is a sample workload which issues a variety of commands.
+func sealAndReadPCRs(t *testing.T, rw io.ReadWriter) ([]byte, []proto.Message) {
+	t.Helper()
+	srk, err := client.StorageRootKeyRSA(rw)
+	if err != nil {
+		t.Fatalf("failed to create SRK: %v", err)
+	}
+	defer srk.Close()
+
+	sealed, err := srk.Seal([]byte("secret"), client.SealOpts{})
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	unsealed, err := srk.Unseal(sealed, client.UnsealOpts{})
+	if err != nil {
+		t.Fatalf("failed to unseal: %v", err)
+	}
+
+	pcrs, err := client.ReadPCRs(rw, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{0, test.DebugPCR}})
+	if err != nil {
+		t.Fatalf("failed to read PCRs: %v", err)
+	}
+	return unsealed, []proto.Message{sealed, pcrs}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	var recording bytes.Buffer
+	recorder, err := NewRecorder(rwc, &recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSecret, wantOutputs := sealAndReadPCRs(t, recorder)
+
+	replayer, err := NewReplayer(bytes.NewReader(recording.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplayer() failed: %v", err)
+	}
+	gotSecret, gotOutputs := sealAndReadPCRs(t, replayer)
+	if !bytes.Equal(gotSecret, wantSecret) {
+		t.Errorf("replayed unseal returned %q, want %q", gotSecret, wantSecret)
+	}
+	for i := range wantOutputs {
+		if !proto.Equal(gotOutputs[i], wantOutputs[i]) {
+			t.Errorf("replayed output %d is %v, want %v", i, gotOutputs[i], wantOutputs[i])
+		}
+	}
+	if n := replayer.Remaining(); n != 0 {
+		t.Errorf("%d recorded entries were not replayed", n)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	var recording bytes.Buffer
+	recorder, err := NewRecorder(rwc, &recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpm2.ReadPCR(recorder, test.DebugPCR, tpm2.AlgSHA256); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := NewReplayer(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A response cannot be read before its command is sent.
+	if _, err := replayer.Read(make([]byte, 4096)); !errors.Is(err, ErrMismatch) {
+		t.Errorf("reading before writing: got error %v, want ErrMismatch", err)
+	}
+	if _, err := replayer.Write([]byte("some other command")); !errors.Is(err, ErrMismatch) {
+		t.Errorf("writing a different command: got error %v, want ErrMismatch", err)
+	}
+	// Failed operations do not consume the recording.
+	if _, err := tpm2.ReadPCR(replayer, test.DebugPCR, tpm2.AlgSHA256); err != nil {
+		t.Errorf("reading the recorded PCR failed: %v", err)
+	}
+	if _, err := replayer.Write([]byte("one command too many")); err != io.EOF {
+		t.Errorf("writing past the end of the recording: got error %v, want io.EOF", err)
+	}
+}
+
+func TestNewReplayerFailures(t *testing.T) {
+	valid := append(append([]byte{}, header...), entryCommand, 0, 0, 0, 2, 0xAB, 0xCD)
+	if _, err := NewReplayer(bytes.NewReader(valid)); err != nil {
+		t.Fatalf("NewReplayer() failed on a valid recording: %v", err)
+	}
+
+	subtests := []struct {
+		name      string
+		recording []byte
+	}{
+		{"Empty", nil},
+		{"BadHeader", []byte("not a recording at all")},
+		{"TruncatedPrefix", valid[:len(header)+3]},
+		{"TruncatedData", valid[:len(valid)-1]},
+		{"UnknownType", append(append([]byte{}, header...), 'X', 0, 0, 0, 0)},
+		{"TooLarge", append(append([]byte{}, header...), entryResponse, 0xFF, 0xFF, 0xFF, 0xFF)},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, err := NewReplayer(bytes.NewReader(subtest.recording)); err == nil {
+				t.Error("NewReplayer() should have failed")
+			}
+		})
+	}
+}
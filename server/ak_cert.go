@@ -0,0 +1,336 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultAKCertValidity          = 365 * 24 * time.Hour
+	defaultAKEnrollmentLifetime    = 5 * time.Minute
+	akEnrollmentIDSize             = 16
+	maxOutstandingEnrollments      = 1 << 16
+	maxOutstandingEnrollmentsPerEK = 16
+)
+
+// tcg-kp-AIKCertificate, from the TCG EK Credential Profile.
+var oidAKCertificateUsage = asn1.ObjectIdentifier{2, 23, 133, 8, 3}
+
+// AKCertTemplate returns a template for an AK certificate, suitable for use
+// with x509.CreateCertificate. The certificate uses the tcg-kp-AIKCertificate
+// extended key usage and, if tpmInfo is non-nil, identifies the TPM by its
+// manufacturer, model, and version in the Subject Alternative Name (as in an
+// EK certificate). The caller must set the validity period.
+func AKCertTemplate(akPub tpm2.Public, tpmInfo *pb.TpmInfo) (*x509.Certificate, error) {
+	name, err := akPub.Name()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute AK name: %w", err)
+	}
+	nameEncoded, err := name.Digest.Encode()
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hex.EncodeToString(nameEncoded)},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		UnknownExtKeyUsage:    []asn1.ObjectIdentifier{oidAKCertificateUsage},
+		BasicConstraintsValid: true,
+	}
+	if tpmInfo != nil {
+		san, err := tpmInfoSAN(tpmInfo)
+		if err != nil {
+			return nil, err
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, san)
+	}
+	return template, nil
+}
+
+// tpmInfoSAN encodes a TpmInfo as a Subject Alternative Name containing
+// a directoryName with the TCG TPM attributes.
+func tpmInfoSAN(tpmInfo *pb.TpmInfo) (pkix.Extension, error) {
+	dirName := pkix.RDNSequence{
+		{{Type: oidTPMManufacturer, Value: fmt.Sprintf("id:%08X", tpmInfo.GetManufacturerId())}},
+		{{Type: oidTPMModel, Value: tpmInfo.GetModel()}},
+		{{Type: oidTPMVersion, Value: fmt.Sprintf("id:%08X", tpmInfo.GetFirmwareVersion())}},
+	}
+	dirNameBytes, err := asn1.Marshal(dirName)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	san, err := asn1.Marshal([]asn1.RawValue{
+		{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: dirNameBytes},
+	})
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oidSubjectAltName, Value: san}, nil
+}
+
+// EnrollmentStore holds AKEnrollments between AKCertIssuer.StartEnrollment
+// and AKCertIssuer.FinishEnrollment. Implementations can use any storage, so
+// that enrollments can be completed by a different server instance than the
+// one which started them.
+type EnrollmentStore interface {
+	// Put stores an enrollment under the provided ID.
+	Put(id string, enrollment *pb.AKEnrollment) error
+	// Take removes and returns the enrollment with the provided ID. An error
+	// is returned if no such enrollment exists.
+	Take(id string) (*pb.AKEnrollment, error)
+}
+
+type memoryEnrollmentStore struct {
+	enrollments *expiringSet
+}
+
+// NewMemoryEnrollmentStore returns an EnrollmentStore which keeps enrollments
+// in memory until they expire. To bound memory use, the number of outstanding
+// enrollments for each EK (and in total) is limited.
+func NewMemoryEnrollmentStore() EnrollmentStore {
+	return &memoryEnrollmentStore{
+		enrollments: newExpiringSet(maxOutstandingEnrollments, maxOutstandingEnrollmentsPerEK),
+	}
+}
+
+func (s *memoryEnrollmentStore) Put(id string, enrollment *pb.AKEnrollment) error {
+	expiry := enrollment.GetExpireTime().AsTime()
+	if err := s.enrollments.add(id, string(enrollment.GetEkPub()), enrollment, expiry); err != nil {
+		return fmt.Errorf("cannot store enrollment: %w", err)
+	}
+	return nil
+}
+
+func (s *memoryEnrollmentStore) Take(id string) (*pb.AKEnrollment, error) {
+	enrollment, ok := s.enrollments.take(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown or expired enrollment %q", id)
+	}
+	return enrollment.(*pb.AKEnrollment), nil
+}
+
+// AKEnrollmentRequest describes an AK to be certified by an AKCertIssuer.
+type AKEnrollmentRequest struct {
+	// The AK's public area, encoded as a TPMT_PUBLIC. The AK must be a
+	// restricted signing key which cannot leave its TPM.
+	AKPub []byte
+	// The EK certificate of the TPM containing the AK (DER or NVRAM format).
+	// If set, it is verified against AKCertIssuerOpts.EKRoots, the EK is taken
+	// from it, and the TPM's attributes are included in the AK certificate.
+	EKCert []byte
+	// The EK of the TPM containing the AK, used if EKCert is not set.
+	// AKCertIssuerOpts.Authorize must then establish that this EK is trusted.
+	EKPub crypto.PublicKey
+}
+
+// AKCertIssuerOpts allows for customizing the functionality of an
+// AKCertIssuer.
+type AKCertIssuerOpts struct {
+	// The CA certificate and key used to sign AK certificates. These must not
+	// be nil.
+	CACert   *x509.Certificate
+	CASigner crypto.Signer
+	// How long issued certificates are valid for. If zero, certificates are
+	// valid for a year.
+	Validity time.Duration
+	// The manufacturer certificates used to verify EK certificates. If nil,
+	// the certificates from DefaultEKRoots are used.
+	EKRoots *EKRootStore
+	// Where pending enrollments are kept. If nil, they are kept in memory.
+	Store EnrollmentStore
+	// How long a client has to complete an enrollment. If zero, enrollments
+	// expire after five minutes.
+	EnrollmentLifetime time.Duration
+	// Authorize, if non-nil, is called before a challenge is issued. The
+	// TpmInfo is nil if the request has no EK certificate. Returning an error
+	// rejects the request. This must be set to accept requests without an EK
+	// certificate.
+	Authorize func(req AKEnrollmentRequest, tpmInfo *pb.TpmInfo) error
+	// Template, if non-nil, is called to customize the certificate template
+	// (from AKCertTemplate) before it is signed. The validity period has
+	// already been set.
+	Template func(template *x509.Certificate, enrollment *pb.AKEnrollment) error
+	// OnIssue, if non-nil, is called with each certificate before it is
+	// returned, for example to record it. Returning an error fails the
+	// enrollment.
+	OnIssue func(cert *x509.Certificate) error
+}
+
+// AKCertIssuer issues AK certificates to clients which prove, using
+// TPM2_ActivateCredential, that their AK resides in the same TPM as a trusted
+// EK. This does not require the AK to sign a CSR. The protocol is:
+//
+//	id, challenge, err := issuer.StartEnrollment(req)
+//	// On the client, with the EK and AK loaded:
+//	secret, err := ek.ActivateCredential(ak, challenge)
+//	// Back on the server:
+//	certDER, err := issuer.FinishEnrollment(id, secret)
+type AKCertIssuer struct {
+	opts AKCertIssuerOpts
+}
+
+// NewAKCertIssuer creates an AKCertIssuer with the provided options.
+func NewAKCertIssuer(opts AKCertIssuerOpts) (*AKCertIssuer, error) {
+	if opts.CACert == nil || opts.CASigner == nil {
+		return nil, fmt.Errorf("a CA certificate and signer must be provided")
+	}
+	if opts.Validity == 0 {
+		opts.Validity = defaultAKCertValidity
+	}
+	if opts.EKRoots == nil {
+		roots, err := DefaultEKRoots()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load bundled EK roots: %w", err)
+		}
+		opts.EKRoots = roots
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryEnrollmentStore()
+	}
+	if opts.EnrollmentLifetime == 0 {
+		opts.EnrollmentLifetime = defaultAKEnrollmentLifetime
+	}
+	return &AKCertIssuer{opts}, nil
+}
+
+// StartEnrollment checks the request, and returns a credential challenge for
+// the client along with the ID of the pending enrollment. The client must
+// recover the challenge's secret (with client.Key.ActivateCredential) and
+// pass it to FinishEnrollment before the enrollment expires.
+func (i *AKCertIssuer) StartEnrollment(req AKEnrollmentRequest) (string, *tpmpb.EncryptedCredential, error) {
+	akPubArea, err := tpm2.DecodePublic(req.AKPub)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to decode AK public area: %w", err)
+	}
+	if err := CheckAKPublicArea(akPubArea); err != nil {
+		return "", nil, err
+	}
+	akName, err := akPubArea.Name()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to compute AK name: %w", err)
+	}
+
+	ekPub := req.EKPub
+	var tpmInfo *pb.TpmInfo
+	if len(req.EKCert) != 0 {
+		if tpmInfo, err = VerifyEKCertificate(req.EKCert, i.opts.EKRoots); err != nil {
+			return "", nil, err
+		}
+		cert, err := ParseEKCertificate(req.EKCert)
+		if err != nil {
+			return "", nil, err
+		}
+		if ekPub != nil && !pubKeysEqual(cert.PublicKey, ekPub) {
+			return "", nil, fmt.Errorf("EK does not match EK certificate: %w", cert.CheckEK(ekPub))
+		}
+		ekPub = cert.PublicKey
+	} else if ekPub == nil {
+		return "", nil, fmt.Errorf("either an EK certificate or an EK must be provided")
+	} else if i.opts.Authorize == nil {
+		return "", nil, fmt.Errorf("an Authorize function is required to enroll without an EK certificate")
+	} else if err := checkROCA(ekPub); err != nil {
+		return "", nil, fmt.Errorf("EK: %w", err)
+	}
+	if i.opts.Authorize != nil {
+		if err := i.opts.Authorize(req, tpmInfo); err != nil {
+			return "", nil, fmt.Errorf("enrollment not authorized: %w", err)
+		}
+	}
+
+	ekPubDER, err := x509.MarshalPKIXPublicKey(ekPub)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode EK: %w", err)
+	}
+	challenge, secret, err := GenerateChallenge(ekPub, akName)
+	if err != nil {
+		return "", nil, err
+	}
+	idBytes := make([]byte, akEnrollmentIDSize)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", nil, err
+	}
+	id := hex.EncodeToString(idBytes)
+	secretDigest := sha256.Sum256(secret)
+	enrollment := &pb.AKEnrollment{
+		AkPub:        req.AKPub,
+		TpmInfo:      tpmInfo,
+		SecretDigest: secretDigest[:],
+		ExpireTime:   timestamppb.New(time.Now().Add(i.opts.EnrollmentLifetime)),
+		EkPub:        ekPubDER,
+	}
+	if err := i.opts.Store.Put(id, enrollment); err != nil {
+		return "", nil, fmt.Errorf("failed to store enrollment: %w", err)
+	}
+	return id, challenge, nil
+}
+
+// FinishEnrollment checks that the secret recovered by the client matches the
+// challenge issued by StartEnrollment, and returns the DER encoded AK
+// certificate. Each enrollment can only be finished once.
+func (i *AKCertIssuer) FinishEnrollment(id string, secret []byte) ([]byte, error) {
+	enrollment, err := i.opts.Store.Take(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve enrollment: %w", err)
+	}
+	now := time.Now()
+	if !now.Before(enrollment.GetExpireTime().AsTime()) {
+		return nil, fmt.Errorf("enrollment has expired")
+	}
+	secretDigest := sha256.Sum256(secret)
+	if subtle.ConstantTimeCompare(secretDigest[:], enrollment.GetSecretDigest()) != 1 {
+		return nil, fmt.Errorf("incorrect challenge secret")
+	}
+
+	akPubArea, err := tpm2.DecodePublic(enrollment.GetAkPub())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode AK public area: %w", err)
+	}
+	akPub, err := akPubArea.Key()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AK public key: %w", err)
+	}
+	template, err := AKCertTemplate(akPubArea, enrollment.GetTpmInfo())
+	if err != nil {
+		return nil, err
+	}
+	template.NotBefore = now
+	template.NotAfter = now.Add(i.opts.Validity)
+	if i.opts.Template != nil {
+		if err := i.opts.Template(template, enrollment); err != nil {
+			return nil, fmt.Errorf("failed to customize certificate: %w", err)
+		}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, i.opts.CACert, akPub, i.opts.CASigner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AK certificate: %w", err)
+	}
+	if i.opts.OnIssue != nil {
+		cert, err := x509.ParseCertificate(certDER)
+		if err != nil {
+			return nil, err
+		}
+		if err := i.opts.OnIssue(cert); err != nil {
+			return nil, fmt.Errorf("failed to record AK certificate: %w", err)
+		}
+	}
+	return certDER, nil
+}
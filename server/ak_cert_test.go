@@ -0,0 +1,254 @@
+package server
+
+import (
+	"crypto/x509"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+)
+
+func newTestAKCertIssuer(t *testing.T, opts AKCertIssuerOpts) (*AKCertIssuer, *testCA, *testCA) {
+	t.Helper()
+	ekRoot := createTestCA(t, "Test TPM Root CA", nil)
+	akCA := createTestCA(t, "Test AK CA", nil)
+	opts.CACert = akCA.cert
+	opts.CASigner = akCA.key
+	opts.EKRoots = NewEKRootStore()
+	opts.EKRoots.AddCertificate(ekRoot.cert)
+	issuer, err := NewAKCertIssuer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issuer, ekRoot, akCA
+}
+
+func encodedPublicArea(t *testing.T, k *client.Key) []byte {
+	t.Helper()
+	pub, err := k.PublicArea().Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func TestAKCertEnrollment(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ek, err := client.EndorsementKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ek.Close()
+
+	var issued []*x509.Certificate
+	issuer, ekRoot, akCA := newTestAKCertIssuer(t, AKCertIssuerOpts{
+		OnIssue: func(cert *x509.Certificate) error {
+			issued = append(issued, cert)
+			return nil
+		},
+	})
+	ekCert := createTestEKCertForKey(t, ekRoot, infineonAttrs, oidEKCertificateUsage, ek.PublicKey())
+
+	keys := []struct {
+		name     string
+		template tpm2.Public
+	}{
+		{"RSA", client.AKTemplateRSA()},
+		{"ECC", client.AKTemplateECC()},
+	}
+	for _, k := range keys {
+		t.Run(k.name, func(t *testing.T) {
+			ak, err := client.NewKey(rwc, tpm2.HandleEndorsement, k.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ak.Close()
+
+			id, challenge, err := issuer.StartEnrollment(AKEnrollmentRequest{
+				AKPub:  encodedPublicArea(t, ak),
+				EKCert: ekCert,
+			})
+			if err != nil {
+				t.Fatalf("StartEnrollment() failed: %v", err)
+			}
+			secret, err := ek.ActivateCredential(ak, challenge)
+			if err != nil {
+				t.Fatalf("ActivateCredential() failed: %v", err)
+			}
+			certDER, err := issuer.FinishEnrollment(id, secret)
+			if err != nil {
+				t.Fatalf("FinishEnrollment() failed: %v", err)
+			}
+			if _, err := issuer.FinishEnrollment(id, secret); err == nil {
+				t.Error("FinishEnrollment() should fail when reusing an enrollment")
+			}
+
+			cert, err := x509.ParseCertificate(certDER)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !pubKeysEqual(cert.PublicKey, ak.PublicKey()) {
+				t.Error("certificate does not certify the AK")
+			}
+			if !hasOID(cert.UnknownExtKeyUsage, oidAKCertificateUsage) {
+				t.Error("certificate is missing the tcg-kp-AIKCertificate extended key usage")
+			}
+			if err := cert.CheckSignatureFrom(akCA.cert); err != nil {
+				t.Errorf("certificate is not signed by the CA: %v", err)
+			}
+			var attrs []byte
+			for _, ext := range cert.Extensions {
+				if ext.Id.Equal(oidSubjectAltName) {
+					attrs = ext.Value
+				}
+			}
+			tpmAttrs, err := parseTPMAttributes(attrs)
+			if err != nil {
+				t.Fatalf("failed to parse certificate SAN: %v", err)
+			}
+			info, err := getTPMInfo(tpmAttrs)
+			if err != nil {
+				t.Fatalf("certificate does not identify the TPM: %v", err)
+			}
+			if info.GetManufacturer() != "Infineon" || info.GetModel() != "SLB9670" || info.GetFirmwareVersion() != 0x00070055 {
+				t.Errorf("certificate has TPM attributes %v", info)
+			}
+		})
+	}
+	if len(issued) != len(keys) {
+		t.Errorf("OnIssue called %d times, want %d", len(issued), len(keys))
+	}
+}
+
+func TestAKCertEnrollmentWithoutEKCert(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ek, err := client.EndorsementKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ek.Close()
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	req := AKEnrollmentRequest{AKPub: encodedPublicArea(t, ak), EKPub: ek.PublicKey()}
+
+	issuer, _, _ := newTestAKCertIssuer(t, AKCertIssuerOpts{})
+	if _, _, err := issuer.StartEnrollment(req); err == nil {
+		t.Error("StartEnrollment() should fail without an EK certificate or Authorize function")
+	}
+
+	issuer, _, _ = newTestAKCertIssuer(t, AKCertIssuerOpts{
+		Authorize: func(req AKEnrollmentRequest, tpmInfo *pb.TpmInfo) error {
+			if !pubKeysEqual(req.EKPub, ek.PublicKey()) {
+				return fmt.Errorf("unknown EK")
+			}
+			return nil
+		},
+	})
+	id, challenge, err := issuer.StartEnrollment(req)
+	if err != nil {
+		t.Fatalf("StartEnrollment() failed: %v", err)
+	}
+	secret, err := ek.ActivateCredential(ak, challenge)
+	if err != nil {
+		t.Fatalf("ActivateCredential() failed: %v", err)
+	}
+	if _, err := issuer.FinishEnrollment(id, secret); err != nil {
+		t.Errorf("FinishEnrollment() failed: %v", err)
+	}
+}
+
+func TestAKCertEnrollmentFailures(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ek, err := client.EndorsementKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ek.Close()
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	srk, err := client.StorageRootKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+
+	issuer, ekRoot, _ := newTestAKCertIssuer(t, AKCertIssuerOpts{})
+	ekCert := createTestEKCertForKey(t, ekRoot, infineonAttrs, oidEKCertificateUsage, ek.PublicKey())
+	untrustedCert := createTestEKCertForKey(t, createTestCA(t, "Other Root CA", nil), infineonAttrs, oidEKCertificateUsage, ek.PublicKey())
+	otherEKCert := createTestEKCert(t, ekRoot, infineonAttrs, oidEKCertificateUsage)
+
+	startSubtests := []struct {
+		name string
+		req  AKEnrollmentRequest
+	}{
+		{"NotAnAK", AKEnrollmentRequest{AKPub: encodedPublicArea(t, srk), EKCert: ekCert}},
+		{"BadAKPub", AKEnrollmentRequest{AKPub: []byte("not a public area"), EKCert: ekCert}},
+		{"UntrustedEKCert", AKEnrollmentRequest{AKPub: encodedPublicArea(t, ak), EKCert: untrustedCert}},
+		{"MismatchedEK", AKEnrollmentRequest{AKPub: encodedPublicArea(t, ak), EKCert: otherEKCert, EKPub: ek.PublicKey()}},
+		{"NoEK", AKEnrollmentRequest{AKPub: encodedPublicArea(t, ak)}},
+	}
+	for _, subtest := range startSubtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, _, err := issuer.StartEnrollment(subtest.req); err == nil {
+				t.Error("StartEnrollment() should have failed")
+			}
+		})
+	}
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		id, _, err := issuer.StartEnrollment(AKEnrollmentRequest{AKPub: encodedPublicArea(t, ak), EKCert: ekCert})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := issuer.FinishEnrollment(id, []byte("a guess at the secret")); err == nil {
+			t.Error("FinishEnrollment() should fail with the wrong secret")
+		}
+	})
+	t.Run("UnknownEnrollment", func(t *testing.T) {
+		if _, err := issuer.FinishEnrollment("unknown", nil); err == nil {
+			t.Error("FinishEnrollment() should fail for an unknown enrollment")
+		}
+	})
+	t.Run("TooManyEnrollmentsForEK", func(t *testing.T) {
+		issuer, ekRoot, _ := newTestAKCertIssuer(t, AKCertIssuerOpts{})
+		ekCert := createTestEKCertForKey(t, ekRoot, infineonAttrs, oidEKCertificateUsage, ek.PublicKey())
+		req := AKEnrollmentRequest{AKPub: encodedPublicArea(t, ak), EKCert: ekCert}
+		for i := 0; i < maxOutstandingEnrollmentsPerEK; i++ {
+			if _, _, err := issuer.StartEnrollment(req); err != nil {
+				t.Fatalf("StartEnrollment() %d failed: %v", i, err)
+			}
+		}
+		if _, _, err := issuer.StartEnrollment(req); err == nil {
+			t.Error("StartEnrollment() should fail with too many outstanding enrollments for an EK")
+		}
+	})
+	t.Run("OnIssueFails", func(t *testing.T) {
+		failing, ekRoot, _ := newTestAKCertIssuer(t, AKCertIssuerOpts{
+			OnIssue: func(*x509.Certificate) error { return fmt.Errorf("storage unavailable") },
+		})
+		ekCert := createTestEKCertForKey(t, ekRoot, infineonAttrs, oidEKCertificateUsage, ek.PublicKey())
+		id, challenge, err := failing.StartEnrollment(AKEnrollmentRequest{AKPub: encodedPublicArea(t, ak), EKCert: ekCert})
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret, err := ek.ActivateCredential(ak, challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := failing.FinishEnrollment(id, secret); err == nil {
+			t.Error("FinishEnrollment() should fail if OnIssue fails")
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"google.golang.org/protobuf/proto"
+)
+
+// Trustworthiness claim values from the IETF RATS Attestation Results for
+// Secure Interactions (AR4SI) draft, as used in a TrustworthinessVector. Values
+// from 2 to 31 affirm the machine's trustworthiness, values from 32 to 95 are
+// warnings, and values from 96 to 127 contraindicate it.
+const (
+	// Any dimension
+	TrustNoClaim                = 0
+	TrustVerifierMalfunction    = 1
+	TrustCryptoValidationFailed = 99
+
+	// Instance identity
+	TrustTrustworthyInstance   = 2
+	TrustUntrustworthyInstance = 96
+	TrustUnrecognizedInstance  = 97
+
+	// Configuration
+	TrustApprovedConfig        = 2
+	TrustNoConfigVulns         = 3
+	TrustUnsafeConfig          = 32
+	TrustUnsupportableConfig   = 36
+	TrustContraindicatedConfig = 96
+
+	// Executables
+	TrustApprovedRuntime        = 2
+	TrustApprovedBoot           = 3
+	TrustUnsafeRuntime          = 32
+	TrustUnrecognizedRuntime    = 33
+	TrustContraindicatedRuntime = 96
+
+	// Hardware
+	TrustGenuineHardware         = 2
+	TrustUnsafeHardware          = 32
+	TrustContraindicatedHardware = 96
+	TrustUnrecognizedHardware    = 97
+)
+
+// TrustTierOf returns the tier of an AR4SI trustworthiness claim value.
+func TrustTierOf(value int32) pb.TrustTier {
+	switch {
+	case value >= 96:
+		return pb.TrustTier_TRUST_TIER_CONTRAINDICATED
+	case value >= 32:
+		return pb.TrustTier_TRUST_TIER_WARNING
+	case value >= 2:
+		return pb.TrustTier_TRUST_TIER_AFFIRMING
+	default:
+		return pb.TrustTier_TRUST_TIER_NONE
+	}
+}
+
+// AppraisalOpts configures AppraiseAttestation.
+type AppraisalOpts struct {
+	// The attestation is verified with these options. Instead of failing
+	// verification, the firmware, Secure Boot and kernel requirements (such as
+	// RequireSecureBoot), RejectTPMClear, and EK certificate and ROCA checks
+	// lower the claim of the dimension they appraise.
+	VerifyOpts
+	// If set, the verified MachineState is evaluated against the policy (see
+	// EvaluatePolicy). Failed platform and TPM rules lower the hardware claim,
+	// and failed kernel and config file rules the configuration claim; waived
+	// failures are warnings.
+	Policy *pb.Policy
+}
+
+// Appraisal is the result of AppraiseAttestation.
+type Appraisal struct {
+	// The verified MachineState, or nil if the attestation's event log (or
+	// anything before it) could not be verified
+	State           *pb.MachineState
+	Trustworthiness *pb.TrustworthinessVector
+}
+
+// String summarizes the appraisal, one dimension per line.
+func (a *Appraisal) String() string {
+	var b strings.Builder
+	v := a.Trustworthiness
+	for _, dimension := range []struct {
+		name  string
+		claim *pb.TrustClaim
+	}{
+		{"instance identity", v.GetInstanceIdentity()},
+		{"configuration", v.GetConfiguration()},
+		{"executables", v.GetExecutables()},
+		{"hardware", v.GetHardware()},
+	} {
+		tier := strings.ToLower(strings.TrimPrefix(dimension.claim.GetTier().String(), "TRUST_TIER_"))
+		fmt.Fprintf(&b, "%s: %d (%s)", dimension.name, dimension.claim.GetValue(), tier)
+		if reasons := dimension.claim.GetReasons(); len(reasons) != 0 {
+			fmt.Fprintf(&b, ": %s", strings.Join(reasons, "; "))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// AppraiseAttestation verifies an attestation like VerifyAttestation, but
+// instead of a single pass or fail, grades the machine's trustworthiness in
+// each dimension of an AR4SI trustworthiness vector: its instance identity
+// (the TPM and AK), configuration, executables and hardware. Relying parties
+// can then make their own authorization decisions from a single attestation,
+// for example granting a machine whose configuration has warnings access to
+// less sensitive resources only.
+//
+// Evidence which only partly verifies is still appraised: an untrusted AK
+// gives an unrecognized instance identity (and no other claims), an event log
+// which does not replay to the quoted PCRs gives contraindicated executables
+// (and no configuration claim), and an EK certificate which does not verify
+// gives unrecognized hardware. Any other verification failure, such as a
+// quote over another nonce, is a failed cryptographic validation of the
+// instance identity. An error is only returned for an invalid Policy.
+//
+// Each claim is the worst of the findings in its dimension, each of which is
+// recorded in the claim's reasons. Dimensions the options give no reference
+// values for have no claim (or for configuration, no known vulnerabilities if
+// Secure Boot is enabled, and the firmware is not in debug mode).
+func AppraiseAttestation(attestation *pb.Attestation, opts AppraisalOpts) (*Appraisal, error) {
+	a := &appraiser{vector: &pb.TrustworthinessVector{
+		InstanceIdentity: &pb.TrustClaim{},
+		Configuration:    &pb.TrustClaim{},
+		Executables:      &pb.TrustClaim{},
+		Hardware:         &pb.TrustClaim{},
+	}}
+
+	// Verify the evidence without the checks which are graded below.
+	verifyOpts := opts.VerifyOpts
+	verifyOpts.RequireSecureBoot, verifyOpts.ForbidDebugMode = false, false
+	verifyOpts.MinimumFirmwareVersion = 0
+	verifyOpts.AllowedDBCerts, verifyOpts.RequiredDBXCerts = nil, nil
+	verifyOpts.AllowedKernelDigests, verifyOpts.AllowedInitrdDigests, verifyOpts.AllowedKernelCmdlines = nil, nil, nil
+	verifyOpts.RejectTPMClear = false
+	verifyOpts.AllowROCAVulnerableKeys = true
+	verifyOpts.EKCert = nil
+	evidence := proto.Clone(attestation).(*pb.Attestation)
+	evidence.EkCert = nil
+
+	state, err := VerifyAttestation(evidence, verifyOpts)
+	if err != nil {
+		var replayErr *LogReplayError
+		switch {
+		case errors.Is(err, ErrUntrustedAK):
+			a.add(a.vector.InstanceIdentity, TrustUnrecognizedInstance, err.Error())
+		case errors.As(err, &replayErr):
+			// The quotes were signed by a trusted AK, but the events cannot
+			// be trusted.
+			a.add(a.vector.InstanceIdentity, TrustTrustworthyInstance, "")
+			a.add(a.vector.Executables, TrustContraindicatedRuntime, err.Error())
+			a.add(a.vector.Configuration, TrustUnsupportableConfig, "the event log does not replay to the quoted PCRs")
+			a.appraiseEKCert(attestation, opts, nil)
+		default:
+			a.add(a.vector.InstanceIdentity, TrustCryptoValidationFailed, err.Error())
+		}
+		return &Appraisal{Trustworthiness: a.vector}, nil
+	}
+
+	// Instance identity
+	a.add(a.vector.InstanceIdentity, TrustTrustworthyInstance, "")
+	if state.GetRocaVulnerableAk() {
+		a.add(a.vector.InstanceIdentity, TrustUntrustworthyInstance, "an attestation key is vulnerable to ROCA (CVE-2017-15361)")
+	}
+	if verdict := state.GetTpmClear().GetVerdict(); verdict == pb.TpmClearVerdict_TPM_REPLACED ||
+		(opts.RejectTPMClear && verdict == pb.TpmClearVerdict_TPM_CLEARED) {
+		a.add(a.vector.InstanceIdentity, TrustUntrustworthyInstance, fmt.Sprintf("%v since the previous attestation", verdict))
+	}
+
+	// Hardware
+	a.appraiseEKCert(attestation, opts, state)
+	a.posture(a.vector.Hardware, state, VerifyOpts{MinimumFirmwareVersion: opts.MinimumFirmwareVersion}, TrustUnsafeHardware)
+
+	// Executables
+	a.posture(a.vector.Executables, state, VerifyOpts{AllowedKernelDigests: opts.AllowedKernelDigests}, TrustUnrecognizedRuntime)
+	a.posture(a.vector.Executables, state, VerifyOpts{AllowedInitrdDigests: opts.AllowedInitrdDigests}, TrustUnrecognizedRuntime)
+	if a.vector.Executables.GetValue() == TrustNoClaim && (len(opts.AllowedKernelDigests) != 0 || len(opts.AllowedInitrdDigests) != 0) {
+		// Only the boot is appraised, not executables run since.
+		a.add(a.vector.Executables, TrustApprovedBoot, "")
+	}
+
+	// Configuration
+	for _, check := range []VerifyOpts{
+		{RequireSecureBoot: opts.RequireSecureBoot},
+		{ForbidDebugMode: opts.ForbidDebugMode},
+		{AllowedDBCerts: opts.AllowedDBCerts},
+		{RequiredDBXCerts: opts.RequiredDBXCerts},
+		{AllowedKernelCmdlines: opts.AllowedKernelCmdlines},
+	} {
+		a.posture(a.vector.Configuration, state, check, TrustContraindicatedConfig)
+	}
+	if !opts.RequireSecureBoot && !state.GetSecureBoot().GetEnabled() {
+		a.add(a.vector.Configuration, TrustUnsafeConfig, "Secure Boot is disabled")
+	}
+	if !opts.ForbidDebugMode && uefiDebugMode(state) {
+		a.add(a.vector.Configuration, TrustUnsafeConfig, "the firmware is in UEFI debug mode")
+	}
+	if opts.Policy != nil {
+		failures, err := evaluatePolicyRules(state, opts.Policy)
+		if err != nil {
+			return nil, err
+		}
+		for _, failure := range failures {
+			claim, unsafe, contraindicated := a.vector.Configuration, int32(TrustUnsafeConfig), int32(TrustContraindicatedConfig)
+			if strings.HasPrefix(failure.Rule, "platform.") || strings.HasPrefix(failure.Rule, "tpm.") {
+				claim, unsafe, contraindicated = a.vector.Hardware, TrustUnsafeHardware, TrustContraindicatedHardware
+			}
+			if failure.Waiver != nil {
+				a.add(claim, unsafe, failure.String())
+			} else {
+				a.add(claim, contraindicated, fmt.Sprintf("%s: %v", failure.Rule, failure.Err))
+			}
+		}
+	}
+	if a.vector.Configuration.GetValue() == TrustNoClaim {
+		configured := opts.RequireSecureBoot || opts.ForbidDebugMode || len(opts.AllowedDBCerts) != 0 ||
+			len(opts.RequiredDBXCerts) != 0 || len(opts.AllowedKernelCmdlines) != 0 ||
+			opts.Policy.GetKernel() != nil || len(opts.Policy.GetConfigFiles()) != 0
+		if configured {
+			a.add(a.vector.Configuration, TrustApprovedConfig, "")
+		} else {
+			a.add(a.vector.Configuration, TrustNoConfigVulns, "")
+		}
+	}
+	return &Appraisal{State: state, Trustworthiness: a.vector}, nil
+}
+
+// appraiser accumulates the findings of AppraiseAttestation.
+type appraiser struct {
+	vector *pb.TrustworthinessVector
+}
+
+// add records a finding in a claim, which keeps the worst value found.
+func (a *appraiser) add(claim *pb.TrustClaim, value int32, reason string) {
+	if value > claim.Value {
+		claim.Value = value
+		claim.Tier = TrustTierOf(value)
+	}
+	if reason != "" {
+		claim.Reasons = append(claim.Reasons, reason)
+	}
+}
+
+// posture checks the posture requirements of opts as VerifyAttestation does,
+// lowering the claim to fail if they are not met.
+func (a *appraiser) posture(claim *pb.TrustClaim, state *pb.MachineState, opts VerifyOpts, fail int32) {
+	if err := checkPosture(state, opts); err != nil {
+		a.add(claim, fail, err.Error())
+	}
+}
+
+// appraiseEKCert grades the hardware claim with opts.EKCert (or, if trusted,
+// the attestation's EK certificate), recording the TPM's attributes in the state,
+// if any.
+func (a *appraiser) appraiseEKCert(attestation *pb.Attestation, opts AppraisalOpts, state *pb.MachineState) {
+	ekCertDER := ekCertToVerify(attestation, opts.VerifyOpts)
+	if len(ekCertDER) == 0 {
+		return
+	}
+	ekCert, err := verifyEKCertWithOpts(ekCertDER, attestation.GetIntermediateCerts(), opts.VerifyOpts)
+	if err != nil {
+		a.add(a.vector.Hardware, TrustUnrecognizedHardware, err.Error())
+		return
+	}
+	a.add(a.vector.Hardware, TrustGenuineHardware, "")
+	rocaErr := checkROCA(ekCert.PublicKey)
+	if rocaErr != nil {
+		a.add(a.vector.Hardware, TrustUnsafeHardware, fmt.Sprintf("EK certificate: %v", rocaErr))
+	}
+	if state != nil {
+		state.TpmInfo = ekCert.TPM
+		state.RocaVulnerableEk = rocaErr != nil
+	}
+}
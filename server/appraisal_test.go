@@ -0,0 +1,160 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestAppraiseAttestation(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("super secret nonce")
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted := VerifyOpts{Nonce: nonce, TrustedAKs: []crypto.PublicKey{ak.PublicKey()}}
+	with := func(modify func(*AppraisalOpts)) AppraisalOpts {
+		opts := AppraisalOpts{VerifyOpts: trusted}
+		modify(&opts)
+		return opts
+	}
+	modulePolicy := &pb.Policy{Kernel: &pb.KernelPolicy{RequireModuleSignatures: true}}
+	waiver := &pb.PolicyWaiver{
+		Rule:          RuleRequireModuleSignatures,
+		ExpireTime:    timestamppb.New(time.Now().Add(time.Hour)),
+		Justification: "kernel upgrade in progress",
+		Approver:      "security@example.com",
+	}
+	waivedPolicy := &pb.Policy{Kernel: modulePolicy.Kernel, Waivers: []*pb.PolicyWaiver{waiver}}
+
+	tests := []struct {
+		name string
+		opts AppraisalOpts
+		// Instance identity, configuration, executables and hardware
+		want [4]int32
+		// Whether VerifyAttestation with the same options succeeds
+		verifies bool
+	}{
+		{"Trusted", with(func(*AppraisalOpts) {}), [4]int32{TrustTrustworthyInstance, TrustNoConfigVulns, TrustNoClaim, TrustNoClaim}, true},
+		{"UntrustedAK", with(func(o *AppraisalOpts) { o.TrustedAKs = []crypto.PublicKey{otherKey.Public()} }),
+			[4]int32{TrustUnrecognizedInstance, TrustNoClaim, TrustNoClaim, TrustNoClaim}, false},
+		{"WrongNonce", with(func(o *AppraisalOpts) { o.Nonce = []byte("another nonce") }),
+			[4]int32{TrustCryptoValidationFailed, TrustNoClaim, TrustNoClaim, TrustNoClaim}, false},
+		{"RequiredSecureBoot", with(func(o *AppraisalOpts) { o.RequireSecureBoot = true }),
+			[4]int32{TrustTrustworthyInstance, TrustApprovedConfig, TrustNoClaim, TrustNoClaim}, true},
+		{"UnrecognizedKernel", with(func(o *AppraisalOpts) { o.AllowedKernelDigests = [][]byte{make([]byte, 32)} }),
+			[4]int32{TrustTrustworthyInstance, TrustNoConfigVulns, TrustUnrecognizedRuntime, TrustNoClaim}, false},
+		{"DBCertNotAllowed", with(func(o *AppraisalOpts) { o.AllowedDBCerts = [][]byte{[]byte("another certificate")} }),
+			[4]int32{TrustTrustworthyInstance, TrustContraindicatedConfig, TrustNoClaim, TrustNoClaim}, false},
+		{"InvalidEKCert", with(func(o *AppraisalOpts) { o.EKCert = []byte("not a certificate") }),
+			[4]int32{TrustTrustworthyInstance, TrustNoConfigVulns, TrustNoClaim, TrustUnrecognizedHardware}, false},
+		{"FailedPolicy", with(func(o *AppraisalOpts) { o.Policy = modulePolicy }),
+			[4]int32{TrustTrustworthyInstance, TrustContraindicatedConfig, TrustNoClaim, TrustNoClaim}, true},
+		{"WaivedPolicy", with(func(o *AppraisalOpts) { o.Policy = waivedPolicy }),
+			[4]int32{TrustTrustworthyInstance, TrustUnsafeConfig, TrustNoClaim, TrustNoClaim}, true},
+	}
+	for _, subtest := range tests {
+		t.Run(subtest.name, func(t *testing.T) {
+			appraisal, err := AppraiseAttestation(attestation, subtest.opts)
+			if err != nil {
+				t.Fatalf("AppraiseAttestation() failed: %v", err)
+			}
+			checkTrustworthiness(t, appraisal, subtest.want)
+			if _, err := VerifyAttestation(attestation, subtest.opts.VerifyOpts); (err == nil) != subtest.verifies {
+				t.Errorf("VerifyAttestation() = %v, want success %v", err, subtest.verifies)
+			}
+			if (appraisal.State != nil) != (subtest.want[0] == TrustTrustworthyInstance) {
+				t.Errorf("AppraiseAttestation() state = %v, want a state only for a trustworthy instance", appraisal.State)
+			}
+		})
+	}
+
+	waivedAppraisal, err := AppraiseAttestation(attestation, with(func(o *AppraisalOpts) { o.Policy = waivedPolicy }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reasons := waivedAppraisal.Trustworthiness.GetConfiguration().GetReasons(); len(reasons) != 1 || !strings.Contains(reasons[0], "waived by") {
+		t.Errorf("configuration reasons = %q, want the waived rule", reasons)
+	}
+	invalidPolicy := &pb.Policy{Waivers: []*pb.PolicyWaiver{{Rule: RuleRequireModuleSignatures}}}
+	if _, err := AppraiseAttestation(attestation, with(func(o *AppraisalOpts) { o.Policy = invalidPolicy })); err == nil {
+		t.Error("AppraiseAttestation() with an invalid policy succeeded, want error")
+	}
+
+	// An event log which does not replay is appraised as far as it can be.
+	for _, hash := range []tpm2.Algorithm{tpm2.AlgSHA1, tpm2.AlgSHA256} {
+		if err := extendPCRsRandomly(rwc, tpm2.PCRSelection{Hash: hash, PCRs: []int{4}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if attestation, err = ak.Attest(client.AttestOpts{Nonce: nonce}); err != nil {
+		t.Fatal(err)
+	}
+	appraisal, err := AppraiseAttestation(attestation, with(func(*AppraisalOpts) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTrustworthiness(t, appraisal, [4]int32{TrustTrustworthyInstance, TrustUnsupportableConfig, TrustContraindicatedRuntime, TrustNoClaim})
+	if text := appraisal.String(); !strings.Contains(text, "executables: 96 (contraindicated)") {
+		t.Errorf("appraisal summary does not contain the executables claim:\n%s", text)
+	}
+}
+
+func checkTrustworthiness(t *testing.T, appraisal *Appraisal, want [4]int32) {
+	t.Helper()
+	v := appraisal.Trustworthiness
+	got := [4]int32{
+		v.GetInstanceIdentity().GetValue(),
+		v.GetConfiguration().GetValue(),
+		v.GetExecutables().GetValue(),
+		v.GetHardware().GetValue(),
+	}
+	if got != want {
+		t.Errorf("trustworthiness = %v, want %v:\n%s", got, want, appraisal)
+	}
+	for _, claim := range []*pb.TrustClaim{v.GetInstanceIdentity(), v.GetConfiguration(), v.GetExecutables(), v.GetHardware()} {
+		if claim.GetTier() != TrustTierOf(claim.GetValue()) {
+			t.Errorf("claim %d has tier %v, want %v", claim.GetValue(), claim.GetTier(), TrustTierOf(claim.GetValue()))
+		}
+	}
+}
+
+func TestTrustTierOf(t *testing.T) {
+	for value, want := range map[int32]pb.TrustTier{
+		-1:  pb.TrustTier_TRUST_TIER_NONE,
+		0:   pb.TrustTier_TRUST_TIER_NONE,
+		1:   pb.TrustTier_TRUST_TIER_NONE,
+		2:   pb.TrustTier_TRUST_TIER_AFFIRMING,
+		31:  pb.TrustTier_TRUST_TIER_AFFIRMING,
+		32:  pb.TrustTier_TRUST_TIER_WARNING,
+		95:  pb.TrustTier_TRUST_TIER_WARNING,
+		96:  pb.TrustTier_TRUST_TIER_CONTRAINDICATED,
+		127: pb.TrustTier_TRUST_TIER_CONTRAINDICATED,
+	} {
+		if got := TrustTierOf(value); got != want {
+			t.Errorf("TrustTierOf(%d) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/google/go-tpm-tools/cel"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// maxAuditRecordSize limits the size of a single encoded AuditRecord when
+// reading an audit log.
+const maxAuditRecordSize = 1 << 20
+
+// AuditRecord is an entry in an AuditLog, recording a single verification
+// decision. Attestations, options and MachineStates are identified by their
+// SHA-256 digests, so that records stay small and do not contain the (possibly
+// sensitive) machine state itself.
+//
+// Records are chained: Hash is the SHA-256 digest of PrevHash followed by the
+// record's JSON encoding without Hash, and PrevHash is the Hash of the
+// previous record (empty for the first record). Modifying, removing or
+// reordering any record changes the Hash of every record after it.
+type AuditRecord struct {
+	Sequence      uint64    `json:"sequence"`
+	Time          time.Time `json:"time"`
+	PolicyVersion string    `json:"policy_version,omitempty"`
+	// The SHA-256 digest of the deterministically encoded Attestation.
+	AttestationDigest []byte       `json:"attestation_digest"`
+	Nonce             []byte       `json:"nonce,omitempty"`
+	Options           AuditOptions `json:"options"`
+	Verified          bool         `json:"verified"`
+	Error             string       `json:"error,omitempty"`
+	// The SHA-256 digest of the deterministically encoded MachineState, if
+	// the attestation was verified.
+	MachineStateDigest []byte `json:"machine_state_digest,omitempty"`
+	PrevHash           []byte `json:"prev_hash,omitempty"`
+	Hash               []byte `json:"hash"`
+}
+
+// AuditOptions records the VerifyOpts used for a verification, other than the
+// nonce. Keys, certificates and digests are identified by their SHA-256
+// digests (of the PKIX or DER encoding for keys and certificates). NonceManager
+// records whether the nonce was checked by a NonceManager (VerifyOpts.Nonces).
+type AuditOptions struct {
+	NonceHash               string   `json:"nonce_hash,omitempty"`
+	NonceManager            bool     `json:"nonce_manager,omitempty"`
+	TrustedAKs              [][]byte `json:"trusted_aks,omitempty"`
+	AllowSHA1               bool     `json:"allow_sha1,omitempty"`
+	EKCert                  []byte   `json:"ek_cert,omitempty"`
+	CustomEKRoots           bool     `json:"custom_ek_roots,omitempty"`
+	TrustAttestationEKCert  bool     `json:"trust_attestation_ek_cert,omitempty"`
+	AllowROCAVulnerableKeys bool     `json:"allow_roca_vulnerable_keys,omitempty"`
+	RequireSecureBoot       bool     `json:"require_secure_boot,omitempty"`
+	ForbidDebugMode         bool     `json:"forbid_debug_mode,omitempty"`
+	MinimumFirmwareVersion  uint32   `json:"minimum_firmware_version,omitempty"`
+	AllowedDBCerts          [][]byte `json:"allowed_db_certs,omitempty"`
+	RequiredDBXCerts        [][]byte `json:"required_dbx_certs,omitempty"`
+	AllowedKernelDigests    [][]byte `json:"allowed_kernel_digests,omitempty"`
+	AllowedInitrdDigests    [][]byte `json:"allowed_initrd_digests,omitempty"`
+	AllowedKernelCmdlines   []string `json:"allowed_kernel_cmdlines,omitempty"`
+	SystemdPCRSignature     []byte   `json:"systemd_pcr_signature,omitempty"`
+	SystemdPCRKeys          [][]byte `json:"systemd_pcr_keys,omitempty"`
+	SameBootAs              []byte   `json:"same_boot_as,omitempty"`
+	PreviousState           []byte   `json:"previous_state,omitempty"`
+	RejectTPMClear          bool     `json:"reject_tpm_clear,omitempty"`
+	KeyJournalIndex         uint32   `json:"key_journal_index,omitempty"`
+}
+
+// NewAuditOptions returns the AuditOptions recorded for opts.
+func NewAuditOptions(opts VerifyOpts) (AuditOptions, error) {
+	audit := AuditOptions{
+		NonceManager:            opts.Nonces != nil,
+		AllowSHA1:               opts.AllowSHA1,
+		CustomEKRoots:           opts.EKRoots != nil,
+		TrustAttestationEKCert:  opts.TrustAttestationEKCert,
+		AllowROCAVulnerableKeys: opts.AllowROCAVulnerableKeys,
+		RequireSecureBoot:       opts.RequireSecureBoot,
+		ForbidDebugMode:         opts.ForbidDebugMode,
+		MinimumFirmwareVersion:  opts.MinimumFirmwareVersion,
+		AllowedDBCerts:          sha256All(opts.AllowedDBCerts),
+		RequiredDBXCerts:        sha256All(opts.RequiredDBXCerts),
+		AllowedKernelDigests:    opts.AllowedKernelDigests,
+		AllowedInitrdDigests:    opts.AllowedInitrdDigests,
+		AllowedKernelCmdlines:   opts.AllowedKernelCmdlines,
+	}
+	if opts.NonceHash != 0 {
+		audit.NonceHash = opts.NonceHash.String()
+	}
+	var err error
+	if audit.TrustedAKs, err = keyDigests(opts.TrustedAKs); err != nil {
+		return AuditOptions{}, fmt.Errorf("failed to encode trusted AK: %w", err)
+	}
+	if audit.SystemdPCRKeys, err = keyDigests(opts.SystemdPCRKeys); err != nil {
+		return AuditOptions{}, fmt.Errorf("failed to encode systemd PCR key: %w", err)
+	}
+	if opts.EKCert != nil {
+		audit.EKCert = sha256Sum(opts.EKCert)
+	}
+	if opts.SystemdPCRSignature != nil {
+		audit.SystemdPCRSignature = sha256Sum(opts.SystemdPCRSignature)
+	}
+	if opts.SameBootAs != nil {
+		if audit.SameBootAs, err = deterministicDigest(opts.SameBootAs); err != nil {
+			return AuditOptions{}, fmt.Errorf("failed to encode machine state: %w", err)
+		}
+	}
+	if opts.PreviousState != nil {
+		if audit.PreviousState, err = deterministicDigest(opts.PreviousState); err != nil {
+			return AuditOptions{}, fmt.Errorf("failed to encode machine state: %w", err)
+		}
+	}
+	audit.RejectTPMClear = opts.RejectTPMClear
+	// Key journals in attestations are always checked, against this index.
+	audit.KeyJournalIndex = opts.KeyJournalIndex
+	if audit.KeyJournalIndex == 0 {
+		audit.KeyJournalIndex = cel.KeyJournalIndex
+	}
+	return audit, nil
+}
+
+func keyDigests(keys []crypto.PublicKey) ([][]byte, error) {
+	var digests [][]byte
+	for _, key := range keys {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, sha256Sum(der))
+	}
+	return digests, nil
+}
+
+func sha256Sum(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
+
+func sha256All(data [][]byte) [][]byte {
+	var digests [][]byte
+	for _, d := range data {
+		digests = append(digests, sha256Sum(d))
+	}
+	return digests
+}
+
+// encode returns the JSON encoding of the record, without its trailing
+// newline.
+func (r *AuditRecord) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// chainHash computes the Hash of the record from its other fields.
+func (r *AuditRecord) chainHash() ([]byte, error) {
+	unhashed := *r
+	unhashed.Hash = nil
+	data, err := unhashed.encode()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	h.Write(r.PrevHash)
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// AuditLog is an append-only, hash-chained log of verification decisions,
+// written as one JSON encoded AuditRecord per line. Use ReadAuditLog (or
+// "gotpm audit verify") to check that a log has not been tampered with.
+//
+// The chain only shows that records were not changed after later records were
+// appended. To detect the last records being removed or the whole log being
+// rewritten, operators should periodically publish or countersign the Head of
+// the log somewhere the verifier cannot modify.
+//
+// An AuditLog is safe for concurrent use.
+type AuditLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	next   uint64
+	head   []byte
+	// Set if a write failed, as the log could end with a partial record.
+	err error
+	// For testing.
+	now func() time.Time
+}
+
+// NewAuditLog starts a new audit log, written to w. If w has a Sync method
+// (like *os.File), it is called after each record is written.
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w, now: time.Now}
+}
+
+// OpenAuditLog opens the audit log in the file at path, creating it if it does
+// not exist. An existing log must verify with ReadAuditLog, and new records
+// are appended to its chain.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	records, err := ReadAuditLog(f, nil)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("invalid audit log %q: %w", path, err)
+	}
+	log := NewAuditLog(f)
+	log.closer = f
+	if len(records) > 0 {
+		last := records[len(records)-1]
+		log.next = last.Sequence + 1
+		log.head = last.Hash
+	}
+	return log, nil
+}
+
+// Head returns the number of records in the log, and the Hash of the last
+// record (nil if the log is empty).
+func (l *AuditLog) Head() (uint64, []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.next, l.head
+}
+
+// Close closes the file of a log opened with OpenAuditLog.
+func (l *AuditLog) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// Append records the decision to verify an attestation with opts, using the
+// given version of the verifier's policy. state and verifyErr are the result
+// of the verification. The record is only returned once it has been written.
+func (l *AuditLog) Append(attestation *pb.Attestation, opts VerifyOpts, policyVersion string, state *pb.MachineState, verifyErr error) (*AuditRecord, error) {
+	options, err := NewAuditOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	attestationDigest, err := deterministicDigest(attestation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode attestation: %w", err)
+	}
+	record := &AuditRecord{
+		PolicyVersion:     policyVersion,
+		AttestationDigest: attestationDigest,
+		Nonce:             opts.Nonce,
+		Options:           options,
+		Verified:          verifyErr == nil,
+	}
+	if verifyErr != nil {
+		record.Error = verifyErr.Error()
+	} else if record.MachineStateDigest, err = deterministicDigest(state); err != nil {
+		return nil, fmt.Errorf("failed to encode machine state: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return nil, fmt.Errorf("audit log is unusable after an earlier failure: %w", l.err)
+	}
+	record.Sequence = l.next
+	record.Time = l.now().UTC()
+	record.PrevHash = l.head
+	if record.Hash, err = record.chainHash(); err != nil {
+		return nil, err
+	}
+	data, err := record.encode()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		l.err = err
+		return nil, fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if syncer, ok := l.w.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			l.err = err
+			return nil, fmt.Errorf("failed to sync audit log: %w", err)
+		}
+	}
+	l.next++
+	l.head = record.Hash
+	return record, nil
+}
+
+func deterministicDigest(m proto.Message) ([]byte, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return sha256Sum(data), nil
+}
+
+// ReadAuditLog reads and verifies the records of an audit log, or of a
+// contiguous part of one (such as exported by "gotpm audit export"). The first
+// record's PrevHash must be prevHash, which is nil for a complete log. Every
+// record must be encoded exactly as written by AuditLog, with consecutive
+// sequence numbers and a valid chain of hashes.
+func ReadAuditLog(r io.Reader, prevHash []byte) ([]*AuditRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxAuditRecordSize)
+	var records []*AuditRecord
+	for scanner.Scan() {
+		record, err := readAuditRecord(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records), err)
+		}
+		if len(records) == 0 {
+			if prevHash == nil && record.Sequence != 0 {
+				return nil, errors.New("log does not start with the first record")
+			}
+		} else if record.Sequence != records[len(records)-1].Sequence+1 {
+			return nil, fmt.Errorf("record %d has sequence number %d, expected %d",
+				len(records), record.Sequence, records[len(records)-1].Sequence+1)
+		}
+		if !bytes.Equal(record.PrevHash, prevHash) {
+			return nil, fmt.Errorf("record %d (sequence number %d) does not chain to the previous record", len(records), record.Sequence)
+		}
+		records = append(records, record)
+		prevHash = record.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// readAuditRecord decodes a single record, checking its encoding and hash.
+func readAuditRecord(line []byte) (*AuditRecord, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+	record := &AuditRecord{}
+	if err := dec.Decode(record); err != nil {
+		return nil, fmt.Errorf("invalid audit record: %w", err)
+	}
+	encoded, err := record.encode()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(encoded, line) {
+		return nil, errors.New("audit record is not canonically encoded")
+	}
+	hash, err := record.chainHash()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hash, record.Hash) {
+		return nil, fmt.Errorf("audit record (sequence number %d) hash does not match its contents", record.Sequence)
+	}
+	return record, nil
+}
+
+// auditingVerifier records every decision of a Verifier in an AuditLog.
+type auditingVerifier struct {
+	verifier      Verifier
+	log           *AuditLog
+	policyVersion string
+}
+
+// NewAuditingVerifier returns a Verifier which records the result of every
+// call to verifier in log, along with the given version of the verifier's
+// policy. If the record cannot be written, verification fails, so that no
+// decision goes unrecorded.
+func NewAuditingVerifier(verifier Verifier, log *AuditLog, policyVersion string) Verifier {
+	return auditingVerifier{verifier, log, policyVersion}
+}
+
+func (v auditingVerifier) VerifyAttestation(attestation *pb.Attestation, opts VerifyOpts) (*pb.MachineState, error) {
+	state, err := v.verifier.VerifyAttestation(attestation, opts)
+	if _, auditErr := v.log.Append(attestation, opts, v.policyVersion, state, err); auditErr != nil {
+		return nil, fmt.Errorf("failed to record verification in audit log: %w", auditErr)
+	}
+	return state, err
+}
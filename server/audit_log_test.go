@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm-tools/cel"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// writeTestAuditLog appends a verified and a failed decision to a new log.
+func writeTestAuditLog(t *testing.T) (*AuditLog, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	log := NewAuditLog(&buf)
+	log.now = func() time.Time { return time.Date(2021, 6, 1, 12, 0, 0, 0, time.FixedZone("PDT", -7*3600)) }
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := VerifyOpts{
+		Nonce:                 []byte("nonce"),
+		TrustedAKs:            []crypto.PublicKey{key.Public()},
+		RequireSecureBoot:     true,
+		AllowedKernelCmdlines: []string{"root=/dev/sda1 <quoted> & escaped"},
+	}
+	attestation := &pb.Attestation{AkPub: []byte("ak")}
+	if _, err := log.Append(attestation, opts, "v1", &pb.MachineState{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.Append(attestation, opts, "v2", nil, ErrSecureBootDisabled); err != nil {
+		t.Fatal(err)
+	}
+	return log, &buf
+}
+
+func TestAuditLog(t *testing.T) {
+	log, buf := writeTestAuditLog(t)
+	records, err := ReadAuditLog(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("ReadAuditLog() failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	verified, denied := records[0], records[1]
+	if !verified.Verified || verified.MachineStateDigest == nil || verified.PolicyVersion != "v1" {
+		t.Errorf("got first record %+v, want a verified decision under policy v1", verified)
+	}
+	if denied.Verified || denied.Error != ErrSecureBootDisabled.Error() || denied.MachineStateDigest != nil {
+		t.Errorf("got second record %+v, want a failed decision", denied)
+	}
+	if !bytes.Equal(verified.AttestationDigest, denied.AttestationDigest) {
+		t.Error("the same attestation should have the same digest")
+	}
+	if len(verified.Options.TrustedAKs) != 1 || !verified.Options.RequireSecureBoot {
+		t.Errorf("got options %+v", verified.Options)
+	}
+	if verified.Options.KeyJournalIndex != cel.KeyJournalIndex {
+		t.Errorf("got key journal index 0x%x, want the default 0x%x", verified.Options.KeyJournalIndex, cel.KeyJournalIndex)
+	}
+	if verified.Options.AllowROCAVulnerableKeys || verified.Options.NonceManager {
+		t.Errorf("got options %+v, want ROCA-vulnerable keys rejected and no NonceManager", verified.Options)
+	}
+	if !verified.Time.Equal(log.now()) || verified.Time.Location() != time.UTC {
+		t.Errorf("got time %v, want %v in UTC", verified.Time, log.now())
+	}
+	if count, head := log.Head(); count != 2 || !bytes.Equal(head, denied.Hash) {
+		t.Errorf("got head (%d, %x), want (2, %x)", count, head, denied.Hash)
+	}
+
+	// Parts of a log verify against the hash of the record before them.
+	lines := strings.SplitAfter(buf.String(), "\n")
+	if _, err := ReadAuditLog(strings.NewReader(lines[1]), verified.Hash); err != nil {
+		t.Errorf("ReadAuditLog() of the second record failed: %v", err)
+	}
+}
+
+func TestNewAuditOptions(t *testing.T) {
+	nonces, err := NewNonceManager(NonceManagerOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, err := NewAuditOptions(VerifyOpts{Nonces: nonces, AllowROCAVulnerableKeys: true, TrustAttestationEKCert: true, KeyJournalIndex: 0x01c10200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !audit.NonceManager || !audit.AllowROCAVulnerableKeys || !audit.TrustAttestationEKCert || audit.KeyJournalIndex != 0x01c10200 {
+		t.Errorf("got options %+v, want the NonceManager, allowed ROCA-vulnerable keys and trusted attestation EK certificates recorded", audit)
+	}
+	encoded, err := json.Marshal(audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"nonce_manager":true`, `"allow_roca_vulnerable_keys":true`, `"trust_attestation_ek_cert":true`, `"key_journal_index":29426176`} {
+		if !strings.Contains(string(encoded), field) {
+			t.Errorf("encoded options %s do not contain %s", encoded, field)
+		}
+	}
+}
+
+func TestAuditLogTampering(t *testing.T) {
+	_, buf := writeTestAuditLog(t)
+	lines := strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	lines[0] = strings.TrimSuffix(lines[0], "\n")
+
+	subtests := []struct {
+		name     string
+		log      string
+		prevHash []byte
+	}{
+		{"ModifiedResult", strings.Replace(buf.String(), `"verified":false`, `"verified":true`, 1), nil},
+		{"ModifiedPolicy", strings.Replace(buf.String(), `"policy_version":"v1"`, `"policy_version":"v0"`, 1), nil},
+		{"RemovedFirstRecord", lines[1], nil},
+		{"WrongPrevHash", lines[1], []byte("wrong")},
+		{"Reordered", lines[1] + "\n" + lines[0] + "\n", nil},
+		{"Duplicated", lines[0] + "\n" + buf.String(), nil},
+		{"Reformatted", strings.Replace(buf.String(), `{"sequence":0,`, `{"sequence": 0,`, 1), nil},
+		{"UnknownField", strings.Replace(buf.String(), `{"sequence":0,`, `{"sequence":0,"note":"x",`, 1), nil},
+		{"Truncated", buf.String()[:buf.Len()-10], nil},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if records, err := ReadAuditLog(strings.NewReader(subtest.log), subtest.prevHash); err == nil {
+				t.Errorf("ReadAuditLog() = %d records, expected an error", len(records))
+			}
+		})
+	}
+}
+
+func TestOpenAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for i := 0; i < 2; i++ {
+		log, err := OpenAuditLog(path)
+		if err != nil {
+			t.Fatalf("OpenAuditLog() failed: %v", err)
+		}
+		if _, err := log.Append(&pb.Attestation{}, VerifyOpts{}, "", &pb.MachineState{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := log.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog() failed: %v", err)
+	}
+	defer log.Close()
+	if count, _ := log.Head(); count != 2 {
+		t.Errorf("reopened log has %d records, want 2", count)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+type verifierFunc func(*pb.Attestation, VerifyOpts) (*pb.MachineState, error)
+
+func (f verifierFunc) VerifyAttestation(attestation *pb.Attestation, opts VerifyOpts) (*pb.MachineState, error) {
+	return f(attestation, opts)
+}
+
+func TestAuditingVerifier(t *testing.T) {
+	var buf bytes.Buffer
+	verifier := NewAuditingVerifier(verifierFunc(func(*pb.Attestation, VerifyOpts) (*pb.MachineState, error) {
+		return nil, ErrDebugMode
+	}), NewAuditLog(&buf), "v3")
+	if _, err := verifier.VerifyAttestation(&pb.Attestation{}, VerifyOpts{}); !errors.Is(err, ErrDebugMode) {
+		t.Errorf("got error %v, want %v", err, ErrDebugMode)
+	}
+	records, err := ReadAuditLog(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Verified || records[0].PolicyVersion != "v3" {
+		t.Errorf("got records %+v, want one failed decision", records)
+	}
+
+	// Decisions which cannot be recorded fail, even if verification succeeded.
+	log := NewAuditLog(failingWriter{})
+	verifier = NewAuditingVerifier(verifierFunc(func(*pb.Attestation, VerifyOpts) (*pb.MachineState, error) {
+		return &pb.MachineState{}, nil
+	}), log, "")
+	for i := 0; i < 2; i++ {
+		if state, err := verifier.VerifyAttestation(&pb.Attestation{}, VerifyOpts{}); err == nil {
+			t.Errorf("VerifyAttestation() = %v, expected an error", state)
+		}
+	}
+}
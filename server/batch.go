@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"sync"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/proto"
+)
+
+// BatchItem is an attestation to be verified by VerifyAttestations.
+type BatchItem struct {
+	Attestation *pb.Attestation
+	// The nonce used when calling client.Attest. If nil, the Nonce in
+	// BatchOpts.VerifyOpts is used.
+	Nonce []byte
+}
+
+// BatchOpts allows for customizing the functionality of VerifyAttestations.
+type BatchOpts struct {
+	// VerifyOpts are used when verifying each attestation, with the nonce of
+	// its BatchItem. The options about a single machine, such as EKCert,
+	// SameBootAs and PreviousState, apply to every attestation, so they are
+	// usually unset.
+	VerifyOpts VerifyOpts
+	// Workers is the number of attestations verified at once. Defaults to
+	// runtime.GOMAXPROCS(0).
+	Workers int
+}
+
+// BatchResult is the result of verifying a BatchItem.
+type BatchResult struct {
+	// The verified MachineState, or nil if verification failed.
+	State *pb.MachineState
+	// The error returned by VerifyAttestation, if any.
+	Err error
+}
+
+// VerifyAttestations verifies many attestations, such as those collected from
+// a fleet, as VerifyAttestation does. It returns the result of each item, in
+// the same order. Compared to calling VerifyAttestation for each item:
+//   - the attestations are verified in parallel by a pool of workers
+//   - the trust anchors are only prepared once: the EK roots are loaded once,
+//     and the TrustedAKs are indexed, so each attestation's AK is found
+//     without comparing it to every trusted key
+//   - the intermediate certificates of the attestations are parsed once for
+//     each distinct set of them, and each distinct EK certificate (with its
+//     intermediates) is only verified once
+func VerifyAttestations(items []BatchItem, opts BatchOpts) []BatchResult {
+	return VerifyAttestationsContext(context.Background(), items, opts)
+}
+
+// VerifyAttestationsContext is like VerifyAttestations, but stops once ctx is
+// done: the attestations which were not verified by then fail with ctx.Err().
+func VerifyAttestationsContext(ctx context.Context, items []BatchItem, opts BatchOpts) []BatchResult {
+	results := make([]BatchResult, len(items))
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+	trustedAKs := newTrustedKeyIndex(opts.VerifyOpts.TrustedAKs)
+	ekCerts := newEKCertCache(opts.VerifyOpts.EKRoots)
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				item := items[i]
+				verifyOpts := opts.VerifyOpts
+				if item.Nonce != nil {
+					verifyOpts.Nonce = item.Nonce
+				}
+				verifyOpts.TrustedAKs = trustedAKs.forAttestation(item.Attestation)
+				results[i].State, results[i].Err = verifyAttestation(ctx, item.Attestation, verifyOpts, ekCerts)
+			}
+		}()
+	}
+	// Once ctx is done, the remaining items fail as soon as they are taken.
+	for i := range items {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	return results
+}
+
+// trustedKeyIndex finds trusted keys by their PKIX encoding.
+type trustedKeyIndex struct {
+	all  []crypto.PublicKey
+	keys map[string]crypto.PublicKey
+}
+
+func newTrustedKeyIndex(keys []crypto.PublicKey) *trustedKeyIndex {
+	index := &trustedKeyIndex{all: keys, keys: make(map[string]crypto.PublicKey, len(keys))}
+	for _, key := range keys {
+		// Only RSA and ECDSA keys can be trusted (see pubKeysEqual), which
+		// can always be encoded.
+		if der, err := x509.MarshalPKIXPublicKey(key); err == nil {
+			index.keys[string(der)] = key
+		}
+	}
+	return index
+}
+
+// forAttestation returns the trusted keys among the AK and the keys of the
+// additional quotes of an attestation. If the AK is not trusted (or cannot be
+// decoded), all the trusted keys are returned, so that verification fails as
+// it would without the index.
+func (i *trustedKeyIndex) forAttestation(attestation *pb.Attestation) []crypto.PublicKey {
+	ak, ok := i.find(attestation.GetAkPub())
+	if !ok {
+		return i.all
+	}
+	keys := []crypto.PublicKey{ak}
+	for _, additional := range attestation.GetAdditionalQuotes() {
+		if key, ok := i.find(additional.GetKeyPub()); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (i *trustedKeyIndex) find(encodedPublic []byte) (crypto.PublicKey, bool) {
+	pubArea, err := tpm2.DecodePublic(encodedPublic)
+	if err != nil {
+		return nil, false
+	}
+	key, err := pubArea.Key()
+	if err != nil {
+		return nil, false
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, false
+	}
+	trusted, ok := i.keys[string(der)]
+	return trusted, ok
+}
+
+// ekCertCache verifies the EK certificates of a batch of attestations. The
+// intermediates are parsed once for each distinct set of them, and each EK
+// certificate is verified once for each set of intermediates.
+type ekCertCache struct {
+	rootsOnce sync.Once
+	roots     *EKRootStore
+	rootsErr  error
+
+	mu    sync.Mutex
+	pools map[string]*cachedPool
+	certs map[string]*cachedEKCert
+}
+
+type cachedPool struct {
+	once sync.Once
+	pool *x509.CertPool
+	err  error
+}
+
+type cachedEKCert struct {
+	once sync.Once
+	cert *EKCertificate
+	err  error
+}
+
+// newEKCertCache returns an ekCertCache verifying EK certificates with the
+// roots, or with those from DefaultEKRoots if nil.
+func newEKCertCache(roots *EKRootStore) *ekCertCache {
+	return &ekCertCache{
+		roots: roots,
+		pools: make(map[string]*cachedPool),
+		certs: make(map[string]*cachedEKCert),
+	}
+}
+
+func (c *ekCertCache) verify(ekCert []byte, intermediates [][]byte) (*EKCertificate, error) {
+	c.rootsOnce.Do(func() {
+		if c.roots == nil {
+			if c.roots, c.rootsErr = loadBundledEKRoots(); c.rootsErr != nil {
+				c.rootsErr = fmt.Errorf("failed to load bundled EK roots: %w", c.rootsErr)
+			}
+		}
+	})
+	if c.rootsErr != nil {
+		return nil, c.rootsErr
+	}
+
+	poolID := digestAll(intermediates)
+	certID := digestAll(append([][]byte{ekCert}, intermediates...))
+	c.mu.Lock()
+	pool, ok := c.pools[poolID]
+	if !ok {
+		pool = &cachedPool{}
+		c.pools[poolID] = pool
+	}
+	cert, ok := c.certs[certID]
+	if !ok {
+		cert = &cachedEKCert{}
+		c.certs[certID] = cert
+	}
+	c.mu.Unlock()
+
+	cert.once.Do(func() {
+		parsed, err := ParseEKCertificate(ekCert)
+		if err != nil {
+			cert.err = fmt.Errorf("failed to parse EK certificate: %w", err)
+			return
+		}
+		pool.once.Do(func() {
+			pool.pool, pool.err = c.roots.intermediatePool(intermediates)
+		})
+		if pool.err != nil {
+			cert.err = pool.err
+			return
+		}
+		cert.cert, cert.err = checkEKCertificate(parsed, c.roots, pool.pool)
+	})
+	if cert.err != nil {
+		return nil, cert.err
+	}
+	// Each MachineState gets its own TpmInfo.
+	verified := *cert.cert
+	verified.TPM = proto.Clone(verified.TPM).(*pb.TpmInfo)
+	return &verified, nil
+}
+
+// digestAll returns a digest identifying a sequence of byte strings.
+func digestAll(data [][]byte) string {
+	h := sha256.New()
+	for _, d := range data {
+		binary.Write(h, binary.BigEndian, uint64(len(d)))
+		h.Write(d)
+	}
+	return string(h.Sum(nil))
+}
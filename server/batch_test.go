@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/proto"
+)
+
+// loadCompatAttestations returns the attestations of the current release's
+// compatibility artifacts, and their AKs.
+func loadCompatAttestations(tb testing.TB) ([]*pb.Attestation, []crypto.PublicKey) {
+	tb.Helper()
+	var attestations []*pb.Attestation
+	var aks []crypto.PublicKey
+	for _, name := range []string{"rsa.pb", "ecc.pb"} {
+		data, err := ioutil.ReadFile(filepath.Join(compatDir, "v0.3.0-alpha", name))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		attestation, err := UnmarshalAttestation(data)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		akPubArea, err := tpm2.DecodePublic(attestation.GetAkPub())
+		if err != nil {
+			tb.Fatal(err)
+		}
+		ak, err := akPubArea.Key()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		attestations = append(attestations, attestation)
+		aks = append(aks, ak)
+	}
+	return attestations, aks
+}
+
+func TestVerifyAttestations(t *testing.T) {
+	attestations, aks := loadCompatAttestations(t)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := BatchOpts{VerifyOpts: VerifyOpts{
+		Nonce:      compatNonce,
+		TrustedAKs: append([]crypto.PublicKey{other.Public()}, aks...),
+	}}
+
+	var items []BatchItem
+	for i := 0; i < 4; i++ {
+		items = append(items, BatchItem{Attestation: attestations[i%2]})
+	}
+	items = append(items,
+		BatchItem{Attestation: attestations[0], Nonce: []byte("wrong nonce")},
+		BatchItem{Attestation: &pb.Attestation{AkPub: attestations[1].GetAkPub()}},
+	)
+	results := VerifyAttestations(items, opts)
+	if len(results) != len(items) {
+		t.Fatalf("got %d results, want %d", len(results), len(items))
+	}
+	for i, result := range results[:4] {
+		if result.Err != nil {
+			t.Errorf("attestation %d failed to verify: %v", i, result.Err)
+			continue
+		}
+		want, err := VerifyAttestation(items[i].Attestation, opts.VerifyOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(result.State, want) {
+			t.Errorf("attestation %d: got a different MachineState than VerifyAttestation", i)
+		}
+	}
+	if !errors.Is(results[4].Err, ErrNonceMismatch) {
+		t.Errorf("attestation with the wrong nonce: got %v, want ErrNonceMismatch", results[4].Err)
+	}
+	if results[5].Err == nil || results[5].State != nil {
+		t.Errorf("attestation without quotes: got %v, want an error", results[5])
+	}
+
+	// Only trusted AKs are found in the index.
+	opts.VerifyOpts.TrustedAKs = aks[:1]
+	results = VerifyAttestations(items[:2], opts)
+	if results[0].Err != nil {
+		t.Errorf("attestation with a trusted AK failed to verify: %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrUntrustedAK) {
+		t.Errorf("attestation with an untrusted AK: got %v, want ErrUntrustedAK", results[1].Err)
+	}
+	opts.VerifyOpts.TrustedAKs = nil
+	if results = VerifyAttestations(items[:1], opts); !errors.Is(results[0].Err, ErrUntrustedAK) {
+		t.Errorf("attestation without trusted AKs: got %v, want ErrUntrustedAK", results[0].Err)
+	}
+}
+
+func TestVerifyAttestationsContext(t *testing.T) {
+	attestations, aks := loadCompatAttestations(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := VerifyAttestationsContext(ctx, []BatchItem{{Attestation: attestations[0]}, {Attestation: attestations[1]}}, BatchOpts{
+		VerifyOpts: VerifyOpts{Nonce: compatNonce, TrustedAKs: aks},
+	})
+	for i, result := range results {
+		if result.Err != context.Canceled {
+			t.Errorf("attestation %d: got %v, want Canceled", i, result.Err)
+		}
+	}
+	if results := VerifyAttestations(nil, BatchOpts{}); len(results) != 0 {
+		t.Errorf("got %d results for no attestations", len(results))
+	}
+}
+
+func TestVerifyAttestationsEKCerts(t *testing.T) {
+	attestations, aks := loadCompatAttestations(t)
+	root := createTestCA(t, "Test TPM Root CA", nil)
+	intermediate := createTestCA(t, "Test TPM Intermediate CA", root)
+	roots := NewEKRootStore()
+	if err := roots.AddCertificates(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.cert.Raw})); err != nil {
+		t.Fatal(err)
+	}
+	ekCert := createTestEKCert(t, intermediate, infineonAttrs, oidEKCertificateUsage)
+	withEKCert := func(attestation *pb.Attestation, ekCert []byte, intermediates ...[]byte) *pb.Attestation {
+		attestation = proto.Clone(attestation).(*pb.Attestation)
+		attestation.EkCert = ekCert
+		attestation.IntermediateCerts = intermediates
+		return attestation
+	}
+
+	items := []BatchItem{
+		{Attestation: withEKCert(attestations[0], ekCert, intermediate.cert.Raw)},
+		{Attestation: withEKCert(attestations[1], ekCert, intermediate.cert.Raw)},
+		{Attestation: withEKCert(attestations[0], createTestEKCert(t, intermediate, infineonAttrs, oidEKCertificateUsage), intermediate.cert.Raw)},
+		// Without the intermediate, the same EK certificate does not verify.
+		{Attestation: withEKCert(attestations[1], ekCert)},
+		{Attestation: withEKCert(attestations[0], ekCert, []byte("not a certificate"))},
+	}
+	results := VerifyAttestations(items, BatchOpts{VerifyOpts: VerifyOpts{
+		Nonce:                  compatNonce,
+		TrustedAKs:             aks,
+		EKRoots:                roots,
+		TrustAttestationEKCert: true,
+	}})
+	for i, result := range results[:3] {
+		if result.Err != nil {
+			t.Fatalf("attestation %d failed to verify: %v", i, result.Err)
+		}
+		if result.State.GetTpmInfo().GetModel() != "SLB9670" {
+			t.Errorf("attestation %d: got TPM info %v, want the EK certificate's", i, result.State.GetTpmInfo())
+		}
+	}
+	if results[0].State.GetTpmInfo() == results[1].State.GetTpmInfo() {
+		t.Error("MachineStates with the same EK certificate share their TpmInfo")
+	}
+	for i, result := range results[3:] {
+		if result.Err == nil {
+			t.Errorf("attestation %d with a bad EK certificate chain should fail", i+3)
+		}
+	}
+
+	// Without TrustAttestationEKCert, the embedded certificates are ignored.
+	results = VerifyAttestations(items[3:], BatchOpts{VerifyOpts: VerifyOpts{
+		Nonce:      compatNonce,
+		TrustedAKs: aks,
+		EKRoots:    roots,
+	}})
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("attestation %d failed to verify: %v", i+3, result.Err)
+		} else if result.State.GetTpmInfo() != nil {
+			t.Errorf("attestation %d: got TPM info %v from an untrusted EK certificate", i+3, result.State.GetTpmInfo())
+		}
+	}
+}
+
+// BenchmarkVerifyAttestations compares verifying a fleet's attestations with
+// VerifyAttestation, one at a time, with VerifyAttestations. The speedup of
+// the worker pool needs several CPUs; that of the shared trust anchors grows
+// with the number of TrustedAKs.
+func BenchmarkVerifyAttestations(b *testing.B) {
+	attestations, aks := loadCompatAttestations(b)
+	// The AKs of the rest of the fleet.
+	trusted := append([]crypto.PublicKey(nil), aks...)
+	for i := 0; i < 1000; i++ {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			b.Fatal(err)
+		}
+		trusted = append(trusted, key.Public())
+	}
+	opts := VerifyOpts{Nonce: compatNonce, TrustedAKs: trusted}
+	items := make([]BatchItem, 64)
+	for i := range items {
+		items[i] = BatchItem{Attestation: attestations[i%len(attestations)]}
+	}
+
+	b.Run("Serial", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			for _, item := range items {
+				if _, err := VerifyAttestation(item.Attestation, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("OneWorker", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			for _, result := range VerifyAttestations(items, BatchOpts{VerifyOpts: opts, Workers: 1}) {
+				if result.Err != nil {
+					b.Fatal(result.Err)
+				}
+			}
+		}
+	})
+	b.Run("Batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			for _, result := range VerifyAttestations(items, BatchOpts{VerifyOpts: opts}) {
+				if result.Err != nil {
+					b.Fatal(result.Err)
+				}
+			}
+		}
+	})
+}
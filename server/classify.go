@@ -0,0 +1,294 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// EventClass is the classification of an event relative to a Baseline.
+type EventClass int
+
+// Event classes, in increasing order of concern.
+const (
+	// EventExpected events were recorded (with the same digest, in the same
+	// PCR) by at least one of the baseline's golden machines, or differ from
+	// such an event only in ways removed by the baseline's normalization
+	// rules.
+	EventExpected EventClass = iota
+	// EventBenignVariable events were not in the baseline, but are of a kind
+	// which legitimately differs between boots or machines, such as the boot
+	// order or the SMBIOS tables.
+	EventBenignVariable
+	// EventAnomalous events were not in the baseline, and are not expected to
+	// vary. They indicate that different code or configuration was measured.
+	EventAnomalous
+)
+
+func (c EventClass) String() string {
+	switch c {
+	case EventExpected:
+		return "expected"
+	case EventBenignVariable:
+		return "benign-variable"
+	case EventAnomalous:
+		return "anomalous"
+	default:
+		return fmt.Sprintf("EventClass(%d)", int(c))
+	}
+}
+
+// AlertSeverity is the severity of the alert a classified event log should
+// raise.
+type AlertSeverity int
+
+// Alert severities, in increasing order.
+const (
+	// SeverityNone means every event was expected.
+	SeverityNone AlertSeverity = iota
+	// SeverityInfo means only benign-variable events differed from the
+	// baseline.
+	SeverityInfo
+	// SeverityWarning means there were anomalous events, but only in PCRs
+	// which are not critical.
+	SeverityWarning
+	// SeverityCritical means there were anomalous events in critical PCRs.
+	SeverityCritical
+)
+
+func (s AlertSeverity) String() string {
+	switch s {
+	case SeverityNone:
+		return "none"
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("AlertSeverity(%d)", int(s))
+	}
+}
+
+// VariableEvent matches events of a type, in a PCR, whose contents
+// legitimately vary between boots or between machines.
+type VariableEvent struct {
+	PCR  uint32
+	Type uint32
+}
+
+// DefaultVariableEvents are the events classified as benign-variable if
+// ClassifierOpts.VariableEvents is nil. The event types are from the TCG PC
+// Client Platform Firmware Profile Specification, Table 9.
+var DefaultVariableEvents = []VariableEvent{
+	// The BootOrder and Boot#### variables, which change whenever boot
+	// entries are added, removed or reordered.
+	{PCR: 1, Type: 0x80000002}, // EV_EFI_VARIABLE_BOOT
+	{PCR: 1, Type: 0x8000000C}, // EV_EFI_VARIABLE_BOOT2
+	// SMBIOS and other tables, containing serial numbers and UUIDs.
+	{PCR: 1, Type: 0x80000009}, // EV_EFI_HANDOFF_TABLES
+	{PCR: 1, Type: 0x8000000B}, // EV_EFI_HANDOFF_TABLES2
+	{PCR: 1, Type: 0x0000000A}, // EV_PLATFORM_CONFIG_FLAGS
+	// The GPT header and partition entries, containing per-disk GUIDs.
+	{PCR: 5, Type: 0x80000006}, // EV_EFI_GPT_EVENT
+}
+
+// DefaultCriticalPCRs are the PCRs in which anomalous events raise a critical
+// alert if ClassifierOpts.CriticalPCRs is nil: the platform firmware (0),
+// option ROMs (2), boot loaders (4), Secure Boot policy (7), and the boot
+// loader's measurements of the kernel and its command line (8 and 9).
+var DefaultCriticalPCRs = []uint32{0, 2, 4, 7, 8, 9}
+
+// ClassifierOpts configures how NewBaseline classifies events.
+type ClassifierOpts struct {
+	// Events which are benign-variable when not in the baseline. Defaults to
+	// DefaultVariableEvents.
+	VariableEvents []VariableEvent
+	// PCRs in which anomalous events raise a critical alert. Defaults to
+	// DefaultCriticalPCRs.
+	CriticalPCRs []uint32
+	// Rules for normalizing the data of events, so that events recorded
+	// differently by different firmware vendors for the same configuration
+	// match the baseline. For each event, the first rule for its type is used.
+	// Defaults to DefaultNormalizationRules; add AliasRules before them for
+	// OEM-specific quirks.
+	NormalizationRules []NormalizationRule
+}
+
+// Baseline is the set of events recorded by known-good ("golden") machines,
+// against which the events of other machines are classified. Rather than
+// alerting on every PCR mismatch, which differing boot orders or hardware
+// cause routinely, alerts can be driven by the classification of the events
+// which caused the mismatch.
+type Baseline struct {
+	digests    map[eventKey]bool
+	normalized map[normalizedKey]bool
+	rules      map[uint32]NormalizationRule
+	variable   map[VariableEvent]bool
+	critical   map[uint32]bool
+}
+
+type eventKey struct {
+	pcr    uint32
+	digest string
+}
+
+type normalizedKey struct {
+	pcr  uint32
+	typ  uint32
+	data string
+}
+
+// ClassifiedEvent is an event along with its classification.
+type ClassifiedEvent struct {
+	// The index of the event in the MachineState's raw_events.
+	Index int
+	Event *pb.Event
+	Class EventClass
+	// The name of the NormalizationRule by which an expected event matched
+	// the baseline, or empty if its digest matched.
+	Normalization string
+}
+
+// EventReport is the result of classifying a MachineState's events.
+type EventReport struct {
+	Events   []ClassifiedEvent
+	Severity AlertSeverity
+}
+
+// Anomalies returns the anomalous events, which should be included in any
+// alert.
+func (r *EventReport) Anomalies() []ClassifiedEvent {
+	var anomalies []ClassifiedEvent
+	for _, event := range r.Events {
+		if event.Class == EventAnomalous {
+			anomalies = append(anomalies, event)
+		}
+	}
+	return anomalies
+}
+
+// NewBaseline creates a Baseline from the events of golden MachineStates,
+// which should come from VerifyAttestation or ParseMachineState.
+func NewBaseline(golden []*pb.MachineState, opts ClassifierOpts) (*Baseline, error) {
+	if len(golden) == 0 {
+		return nil, errors.New("no golden machine states provided")
+	}
+	b := newBaseline(opts)
+	for i, state := range golden {
+		if len(state.GetRawEvents()) == 0 {
+			return nil, fmt.Errorf("golden machine state %d has no events", i)
+		}
+		for _, event := range state.GetRawEvents() {
+			b.digests[eventKey{event.GetPcrIndex(), string(event.GetDigest())}] = true
+			if key, _, ok := b.normalize(event); ok {
+				b.normalized[key] = true
+			}
+		}
+	}
+	return b, nil
+}
+
+// newBaseline returns a Baseline without any golden events.
+func newBaseline(opts ClassifierOpts) *Baseline {
+	b := &Baseline{
+		digests:    make(map[eventKey]bool),
+		normalized: make(map[normalizedKey]bool),
+		rules:      make(map[uint32]NormalizationRule),
+		variable:   make(map[VariableEvent]bool),
+		critical:   make(map[uint32]bool),
+	}
+	rules := opts.NormalizationRules
+	if rules == nil {
+		rules = DefaultNormalizationRules
+	}
+	for _, rule := range rules {
+		for _, typ := range rule.Types {
+			if _, ok := b.rules[typ]; !ok {
+				b.rules[typ] = rule
+			}
+		}
+	}
+	variable := opts.VariableEvents
+	if variable == nil {
+		variable = DefaultVariableEvents
+	}
+	for _, v := range variable {
+		b.variable[v] = true
+	}
+	critical := opts.CriticalPCRs
+	if critical == nil {
+		critical = DefaultCriticalPCRs
+	}
+	for _, pcr := range critical {
+		b.critical[pcr] = true
+	}
+	return b
+}
+
+// isVariable reports whether an event is benign-variable when it is not in
+// the baseline.
+func (b *Baseline) isVariable(event *pb.Event) bool {
+	return event.GetUntrustedType() == NoAction || b.variable[VariableEvent{event.GetPcrIndex(), event.GetUntrustedType()}]
+}
+
+// normalize returns the normalized key of an event, and the rule used, if a
+// normalization rule applies to it. Only events whose digest is of their data
+// are normalized, as the data of other events (such as the path of a UEFI
+// application, whose digest is of the application) does not determine what
+// was measured.
+func (b *Baseline) normalize(event *pb.Event) (normalizedKey, string, bool) {
+	rule, ok := b.rules[event.GetUntrustedType()]
+	if !ok || !event.GetDigestVerified() {
+		return normalizedKey{}, "", false
+	}
+	data, ok := rule.Normalize(event.GetData())
+	if !ok {
+		return normalizedKey{}, "", false
+	}
+	return normalizedKey{event.GetPcrIndex(), event.GetUntrustedType(), string(data)}, rule.Name, true
+}
+
+// Classify labels each of a MachineState's events, and determines the
+// severity of the alert they should raise. Events of type EV_NO_ACTION are
+// never extended into PCRs, so they are always benign-variable if not in the
+// baseline. Events which are in the baseline more than once, such as the
+// separators some firmware measures twice, match it each time.
+//
+// Event types are not verified, so a compromised component could give its
+// measurements a variable type. The classification is therefore a triage aid
+// for alerts, and does not replace policy evaluation.
+func (b *Baseline) Classify(state *pb.MachineState) *EventReport {
+	report := &EventReport{}
+	for i, event := range state.GetRawEvents() {
+		class := EventExpected
+		normalization := ""
+		pcr := event.GetPcrIndex()
+		if !b.digests[eventKey{pcr, string(event.GetDigest())}] {
+			if key, rule, ok := b.normalize(event); ok && b.normalized[key] {
+				normalization = rule
+			} else if b.isVariable(event) {
+				class = EventBenignVariable
+			} else {
+				class = EventAnomalous
+			}
+		}
+		report.Events = append(report.Events, ClassifiedEvent{i, event, class, normalization})
+
+		severity := SeverityNone
+		switch {
+		case class == EventBenignVariable:
+			severity = SeverityInfo
+		case class == EventAnomalous && b.critical[pcr]:
+			severity = SeverityCritical
+		case class == EventAnomalous:
+			severity = SeverityWarning
+		}
+		if severity > report.Severity {
+			report.Severity = severity
+		}
+	}
+	return report
+}
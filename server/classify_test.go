@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"google.golang.org/protobuf/proto"
+)
+
+func parseTestMachineState(t *testing.T, log test.Platform) *pb.MachineState {
+	t.Helper()
+	state, err := ParseMachineState(log.RawLog, log.Banks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+// Returns a copy of the state with an event's digest changed, or with a new
+// event if index is past the end of the events.
+func withChangedEvent(state *pb.MachineState, index int, pcr uint32, typ uint32) *pb.MachineState {
+	changed := proto.Clone(state).(*pb.MachineState)
+	digest := bytes.Repeat([]byte{0xAB}, 32)
+	if index < len(changed.RawEvents) {
+		changed.RawEvents[index].Digest = digest
+		return changed
+	}
+	changed.RawEvents = append(changed.RawEvents, &pb.Event{PcrIndex: pcr, UntrustedType: typ, Digest: digest})
+	return changed
+}
+
+func findEvent(t *testing.T, state *pb.MachineState, pcr uint32, typ uint32) int {
+	t.Helper()
+	for i, event := range state.GetRawEvents() {
+		if event.GetPcrIndex() == pcr && event.GetUntrustedType() == typ {
+			return i
+		}
+	}
+	t.Fatalf("no event of type 0x%x in PCR %d", typ, pcr)
+	return 0
+}
+
+func TestClassifyEvents(t *testing.T) {
+	golden := parseTestMachineState(t, test.Debian10GCE)
+	baseline, err := NewBaseline([]*pb.MachineState{golden}, ClassifierOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bootOrder := findEvent(t, golden, 1, 0x80000002)
+	separator := findEvent(t, golden, 4, Separator)
+	end := len(golden.GetRawEvents())
+
+	testcases := []struct {
+		name     string
+		state    *pb.MachineState
+		severity AlertSeverity
+		changed  int
+		class    EventClass
+	}{
+		{"Golden", golden, SeverityNone, 0, EventExpected},
+		{"BootOrder", withChangedEvent(golden, bootOrder, 0, 0), SeverityInfo, bootOrder, EventBenignVariable},
+		{"NoAction", withChangedEvent(golden, end, 2, NoAction), SeverityInfo, end, EventBenignVariable},
+		{"NonCriticalPCR", withChangedEvent(golden, end, 14, 0xD), SeverityWarning, end, EventAnomalous},
+		{"BootLoader", withChangedEvent(golden, separator, 0, 0), SeverityCritical, separator, EventAnomalous},
+		{"VariableTypeInCriticalPCR", withChangedEvent(golden, end, 4, 0x80000002), SeverityCritical, end, EventAnomalous},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := baseline.Classify(tc.state)
+			if report.Severity != tc.severity {
+				t.Errorf("Severity = %v, want %v", report.Severity, tc.severity)
+			}
+			if len(report.Events) != len(tc.state.GetRawEvents()) {
+				t.Fatalf("got %d classified events, want %d", len(report.Events), len(tc.state.GetRawEvents()))
+			}
+			for i, event := range report.Events {
+				want := EventExpected
+				if i == tc.changed {
+					want = tc.class
+				}
+				if event.Index != i || event.Class != want {
+					t.Errorf("event %d classified as %v, want %v", i, event.Class, want)
+				}
+			}
+			wantAnomalies := 0
+			if tc.class == EventAnomalous {
+				wantAnomalies = 1
+			}
+			if got := len(report.Anomalies()); got != wantAnomalies {
+				t.Errorf("got %d anomalies, want %d", got, wantAnomalies)
+			}
+		})
+	}
+}
+
+func TestClassifyDifferentOS(t *testing.T) {
+	debian := parseTestMachineState(t, test.Debian10GCE)
+	rhel := parseTestMachineState(t, test.Rhel8GCE)
+
+	baseline, err := NewBaseline([]*pb.MachineState{debian}, ClassifierOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := baseline.Classify(rhel); report.Severity != SeverityCritical {
+		t.Errorf("booting a different OS raised a %v alert", report.Severity)
+	}
+
+	// Both machines are known good when both are in the baseline.
+	baseline, err = NewBaseline([]*pb.MachineState{debian, rhel}, ClassifierOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range []*pb.MachineState{debian, rhel} {
+		if report := baseline.Classify(state); report.Severity != SeverityNone {
+			t.Errorf("golden machine raised a %v alert: %v", report.Severity, report.Anomalies())
+		}
+	}
+}
+
+func TestClassifierOpts(t *testing.T) {
+	golden := parseTestMachineState(t, test.Debian10GCE)
+	baseline, err := NewBaseline([]*pb.MachineState{golden}, ClassifierOpts{
+		VariableEvents: []VariableEvent{{PCR: 14, Type: 0xD}},
+		CriticalPCRs:   []uint32{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bootOrder := findEvent(t, golden, 1, 0x80000002)
+	separator := findEvent(t, golden, 4, Separator)
+	end := len(golden.GetRawEvents())
+
+	if report := baseline.Classify(withChangedEvent(golden, end, 14, 0xD)); report.Severity != SeverityInfo {
+		t.Errorf("custom variable event raised a %v alert", report.Severity)
+	}
+	if report := baseline.Classify(withChangedEvent(golden, bootOrder, 0, 0)); report.Severity != SeverityWarning {
+		t.Errorf("default variable event raised a %v alert", report.Severity)
+	}
+	if report := baseline.Classify(withChangedEvent(golden, separator, 0, 0)); report.Severity != SeverityWarning {
+		t.Errorf("non-critical PCR 4 raised a %v alert", report.Severity)
+	}
+}
+
+func TestNewBaselineFailures(t *testing.T) {
+	if _, err := NewBaseline(nil, ClassifierOpts{}); err == nil {
+		t.Error("NewBaseline() with no golden states should fail")
+	}
+	if _, err := NewBaseline([]*pb.MachineState{{}}, ClassifierOpts{}); err == nil {
+		t.Error("NewBaseline() with a state without events should fail")
+	}
+}
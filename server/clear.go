@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// ErrTPMCleared is wrapped by the error VerifyAttestation returns when
+// VerifyOpts.RejectTPMClear is set, and the TPM was cleared or replaced since
+// VerifyOpts.PreviousState.
+var ErrTPMCleared = errors.New("VerifyOpts.RejectTPMClear: TPM was cleared since the previous attestation")
+
+// DetectTPMClear compares the MachineStates of two attestations of the same
+// machine, reporting whether its TPM was cleared in between. A clear replaces
+// the owner hierarchy's seed, so keys in it (such as most AKs) are lost, and
+// the relying party should re-enroll the machine. The indicators are:
+//   - the EK Name, which a clear does not change: if it differs, the TPM was
+//     replaced
+//   - the SRK Name, which a clear changes
+//   - the clear marker NV index, which a clear deletes
+//   - the TPM clock (covered by the quotes), which a clear resets: it must not
+//     go backwards while the TPM reports it as safe
+//   - the reset count, which a clear sets to zero: it must not go down if the
+//     quotes were signed by the same AK (see pb.ClockInfo)
+//
+// The EK and SRK Names and the marker are reported by the attester (see
+// client.GetClearIndicators), and are not covered by the quotes. The clock is
+// only compared if the EK Names or the AKs match, showing it is the same TPM.
+func DetectTPMClear(previous, current *pb.MachineState) *pb.TpmClearStatus {
+	status := &pb.TpmClearStatus{}
+	if previous == nil {
+		return status
+	}
+	was, is := previous.GetClearIndicators(), current.GetClearIndicators()
+	var cleared, unchanged []string
+
+	sameTPM := false
+	if len(was.GetEkName()) != 0 && len(is.GetEkName()) != 0 {
+		if !bytes.Equal(was.GetEkName(), is.GetEkName()) {
+			status.Verdict = pb.TpmClearVerdict_TPM_REPLACED
+			status.Indicators = []string{fmt.Sprintf("EK changed (name %x, previously %x)", is.GetEkName(), was.GetEkName())}
+			return status
+		}
+		sameTPM = true
+	}
+	if len(was.GetSrkName()) != 0 && len(is.GetSrkName()) != 0 {
+		if bytes.Equal(was.GetSrkName(), is.GetSrkName()) {
+			unchanged = append(unchanged, "SRK unchanged")
+		} else {
+			cleared = append(cleared, fmt.Sprintf("SRK changed (name %x, previously %x)", is.GetSrkName(), was.GetSrkName()))
+		}
+	}
+	// An attestation without indicators does not show the marker is missing.
+	if len(was.GetNvMarker()) != 0 && is != nil {
+		switch {
+		case len(is.GetNvMarker()) == 0:
+			cleared = append(cleared, "clear marker NV index was deleted")
+		case !bytes.Equal(was.GetNvMarker(), is.GetNvMarker()):
+			cleared = append(cleared, "clear marker NV index was replaced")
+		default:
+			unchanged = append(unchanged, "clear marker NV index unchanged")
+		}
+	}
+
+	sameAK := len(previous.GetAkName()) != 0 && bytes.Equal(previous.GetAkName(), current.GetAkName())
+	before, after := previous.GetClockInfo(), current.GetClockInfo()
+	if (sameTPM || sameAK) && before != nil && after != nil {
+		if after.GetClock() < before.GetClock() && after.GetSafe() {
+			cleared = append(cleared, fmt.Sprintf("TPM clock was reset (clock %d, previously %d)", after.GetClock(), before.GetClock()))
+		} else if after.GetClock() >= before.GetClock() {
+			unchanged = append(unchanged, "TPM clock advanced")
+		}
+		if sameAK && after.GetResetCount() < before.GetResetCount() {
+			cleared = append(cleared, fmt.Sprintf("reset count went down (%d, previously %d)", after.GetResetCount(), before.GetResetCount()))
+		}
+	}
+
+	switch {
+	case len(cleared) != 0:
+		status.Verdict = pb.TpmClearVerdict_TPM_CLEARED
+		status.Indicators = cleared
+	case len(unchanged) != 0:
+		status.Verdict = pb.TpmClearVerdict_TPM_NOT_CLEARED
+		status.Indicators = unchanged
+	}
+	return status
+}
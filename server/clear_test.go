@@ -0,0 +1,135 @@
+package server
+
+import (
+	"crypto"
+	"errors"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/testutil/tpmtest"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDetectTPMClear(t *testing.T) {
+	previous := &pb.MachineState{
+		AkName:    []byte{0, 0xb, 1},
+		ClockInfo: &pb.ClockInfo{Clock: 1000, ResetCount: 5, Safe: true},
+		ClearIndicators: &pb.ClearIndicators{
+			EkName:   []byte{0, 0xb, 2},
+			SrkName:  []byte{0, 0xb, 3},
+			NvMarker: []byte("marker"),
+		},
+	}
+	modified := func(modify func(*pb.MachineState)) *pb.MachineState {
+		state := proto.Clone(previous).(*pb.MachineState)
+		modify(state)
+		return state
+	}
+
+	tests := []struct {
+		name     string
+		previous *pb.MachineState
+		current  *pb.MachineState
+		verdict  pb.TpmClearVerdict
+	}{
+		{"NoPrevious", nil, previous, pb.TpmClearVerdict_TPM_CLEAR_UNKNOWN},
+		{"Unchanged", previous, modified(func(s *pb.MachineState) { s.ClockInfo.Clock = 2000 }), pb.TpmClearVerdict_TPM_NOT_CLEARED},
+		{"NoIndicators", &pb.MachineState{}, &pb.MachineState{}, pb.TpmClearVerdict_TPM_CLEAR_UNKNOWN},
+		{"EKChanged", previous, modified(func(s *pb.MachineState) { s.ClearIndicators.EkName = []byte{0, 0xb, 4} }), pb.TpmClearVerdict_TPM_REPLACED},
+		{"SRKChanged", previous, modified(func(s *pb.MachineState) { s.ClearIndicators.SrkName = []byte{0, 0xb, 4} }), pb.TpmClearVerdict_TPM_CLEARED},
+		{"MarkerDeleted", previous, modified(func(s *pb.MachineState) { s.ClearIndicators.NvMarker = nil }), pb.TpmClearVerdict_TPM_CLEARED},
+		{"MarkerReplaced", previous, modified(func(s *pb.MachineState) { s.ClearIndicators.NvMarker = []byte("other") }), pb.TpmClearVerdict_TPM_CLEARED},
+		{"ClockReset", previous, modified(func(s *pb.MachineState) { s.ClockInfo.Clock = 10 }), pb.TpmClearVerdict_TPM_CLEARED},
+		{"ClockUnsafe", previous, modified(func(s *pb.MachineState) {
+			s.ClockInfo.Clock = 10
+			s.ClockInfo.Safe = false
+		}), pb.TpmClearVerdict_TPM_NOT_CLEARED},
+		{"ResetCountDown", previous, modified(func(s *pb.MachineState) {
+			s.ClearIndicators = nil
+			s.ClockInfo.ResetCount = 0
+			s.ClockInfo.Clock = 2000
+		}), pb.TpmClearVerdict_TPM_CLEARED},
+		{"OtherAKResetCount", previous, modified(func(s *pb.MachineState) {
+			s.ClearIndicators = nil
+			s.AkName = []byte{0, 0xb, 4}
+			s.ClockInfo.ResetCount = 0
+		}), pb.TpmClearVerdict_TPM_CLEAR_UNKNOWN},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status := DetectTPMClear(tc.previous, tc.current)
+			if status.GetVerdict() != tc.verdict {
+				t.Errorf("DetectTPMClear() = %v %v, want %v", status.GetVerdict(), status.GetIndicators(), tc.verdict)
+			}
+			if tc.verdict != pb.TpmClearVerdict_TPM_CLEAR_UNKNOWN && len(status.GetIndicators()) == 0 {
+				t.Error("DetectTPMClear() returned no indicators")
+			}
+		})
+	}
+}
+
+func TestVerifyAttestationAfterTPMClear(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	tpmtest.RequireSimulated(t)
+
+	if _, err := client.WriteClearMarker(rwc); err != nil {
+		t.Fatal(err)
+	}
+	verify := func(opts VerifyOpts) (*pb.MachineState, error) {
+		t.Helper()
+		ak, err := client.AttestationKeyRSA(rwc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ak.Close()
+		nonce := []byte("super secret nonce")
+		attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce, ClearIndicators: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Nonce = nonce
+		opts.TrustedAKs = []crypto.PublicKey{ak.PublicKey()}
+		return VerifyAttestation(attestation, opts)
+	}
+
+	earlier, err := verify(VerifyOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	later, err := verify(VerifyOpts{PreviousState: earlier, RejectTPMClear: true})
+	if err != nil {
+		t.Fatalf("verifying an attestation of an uncleared TPM failed: %v", err)
+	}
+	if verdict := later.GetTpmClear().GetVerdict(); verdict != pb.TpmClearVerdict_TPM_NOT_CLEARED {
+		t.Errorf("TPM clear verdict before clearing is %v, want TPM_NOT_CLEARED", verdict)
+	}
+
+	auth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+	if err := tpm2.Clear(rwc, tpm2.HandleLockout, auth); err != nil {
+		t.Fatalf("TPM2_Clear failed: %v", err)
+	}
+	cleared, err := verify(VerifyOpts{PreviousState: earlier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := cleared.GetTpmClear()
+	if status.GetVerdict() != pb.TpmClearVerdict_TPM_CLEARED {
+		t.Errorf("TPM clear verdict after clearing is %v %v, want TPM_CLEARED", status.GetVerdict(), status.GetIndicators())
+	}
+	if _, err := verify(VerifyOpts{PreviousState: earlier, RejectTPMClear: true}); !errors.Is(err, ErrTPMCleared) {
+		t.Errorf("VerifyAttestation() of a cleared TPM = %v, want ErrTPMCleared", err)
+	}
+	// The clear deleted the marker, so there is nothing to undefine.
+	if indexes, err := client.Handles(rwc, tpm2.HandleTypeNVIndex); err == nil {
+		for _, index := range indexes {
+			if index == tpmutil.Handle(client.ClearMarkerNVIndex) {
+				t.Error("TPM2_Clear did not delete the clear marker")
+			}
+		}
+	}
+}
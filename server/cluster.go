@@ -0,0 +1,199 @@
+package server
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// FleetMachine is a machine whose state is clustered by ClusterFleet.
+type FleetMachine struct {
+	// Identifies the machine in the clusters, such as its host name or the
+	// file its state was read from.
+	Name string
+	// The machine's state, which should come from VerifyAttestation or
+	// ParseMachineState, replayed with the same hash algorithm as the other
+	// machines'.
+	State *pb.MachineState
+}
+
+// ClusterOpts configures ClusterFleet.
+type ClusterOpts struct {
+	// How events are matched against a baseline, and which events vary
+	// benignly between machines. The same options should be used with the
+	// proposed baselines.
+	ClassifierOpts
+	// The number of anomalous events a machine may have when classified
+	// against the baseline of its cluster's golden machine. Defaults to 0, so
+	// every member's events are expected by its cluster's baseline.
+	MaxAnomalies int
+}
+
+// BaselineCluster is a proposed baseline: a golden machine, and the machines
+// which its baseline classifies with at most ClusterOpts.MaxAnomalies
+// anomalous events.
+type BaselineCluster struct {
+	Golden   FleetMachine
+	Baseline *Baseline
+	// The machines in the cluster (including the golden machine), in the
+	// order they were given to ClusterFleet.
+	Members []ClusterMember
+}
+
+// ClusterMember is a machine in a BaselineCluster.
+type ClusterMember struct {
+	Name string
+	// The number of distinct anomalous events of the machine, when classified
+	// against its cluster's baseline.
+	Anomalies int
+}
+
+// fleetConfig is a set of machines with the same significant events.
+type fleetConfig struct {
+	events   map[string]bool
+	machines []int
+}
+
+// ClusterFleet groups a fleet's machines by the events in their event logs,
+// and proposes a small set of golden machines whose baselines (see
+// NewBaseline) cover every machine. This makes it tractable to roll out
+// event log policies to a heterogeneous fleet: rather than choosing golden
+// machines by hand, each proposed golden machine can be reviewed, along with
+// the machines which would be classified against it.
+//
+// Only the events which Baseline.Classify could classify as anomalous are
+// compared: benign-variable events are ignored, and events are matched by
+// their digest or normalized data. Machines with the same events form one
+// configuration; the golden machines are then chosen greedily, each covering
+// the most machines not yet covered. Finding the smallest set of golden
+// machines is NP-hard, so the set proposed may not be the smallest, but it is
+// within a logarithmic factor of it.
+func ClusterFleet(machines []FleetMachine, opts ClusterOpts) ([]*BaselineCluster, error) {
+	if len(machines) == 0 {
+		return nil, errors.New("no machines provided")
+	}
+	if opts.MaxAnomalies < 0 {
+		return nil, fmt.Errorf("invalid MaxAnomalies %d", opts.MaxAnomalies)
+	}
+	matcher := newBaseline(opts.ClassifierOpts)
+
+	// Group the machines with the same events, as fleets are mostly made of
+	// identical machines.
+	var configs []*fleetConfig
+	byFingerprint := make(map[[sha256.Size]byte]*fleetConfig)
+	for i, machine := range machines {
+		if len(machine.State.GetRawEvents()) == 0 {
+			return nil, fmt.Errorf("machine %q has no events", machine.Name)
+		}
+		events := matcher.significantEvents(machine.State)
+		fingerprint := eventsFingerprint(events)
+		config, ok := byFingerprint[fingerprint]
+		if !ok {
+			config = &fleetConfig{events: events}
+			byFingerprint[fingerprint] = config
+			configs = append(configs, config)
+		}
+		config.machines = append(config.machines, i)
+	}
+
+	// covers[i] lists the configurations covered by configuration i.
+	covers := make([][]int, len(configs))
+	for i, golden := range configs {
+		for j, config := range configs {
+			if missingEvents(config.events, golden.events) <= opts.MaxAnomalies {
+				covers[i] = append(covers[i], j)
+			}
+		}
+	}
+
+	var clusters []*BaselineCluster
+	covered := make([]bool, len(configs))
+	for remaining := len(machines); remaining > 0; {
+		best, bestCount := -1, 0
+		for i := range configs {
+			count := 0
+			for _, j := range covers[i] {
+				if !covered[j] {
+					count += len(configs[j].machines)
+				}
+			}
+			if count > bestCount {
+				best, bestCount = i, count
+			}
+		}
+
+		golden := machines[configs[best].machines[0]]
+		baseline, err := NewBaseline([]*pb.MachineState{golden.State}, opts.ClassifierOpts)
+		if err != nil {
+			return nil, err
+		}
+		anomalies := make(map[int]int)
+		for _, j := range covers[best] {
+			if covered[j] {
+				continue
+			}
+			covered[j] = true
+			for _, i := range configs[j].machines {
+				anomalies[i] = missingEvents(configs[j].events, configs[best].events)
+			}
+		}
+		cluster := &BaselineCluster{Golden: golden, Baseline: baseline}
+		for i := range machines {
+			if count, ok := anomalies[i]; ok {
+				cluster.Members = append(cluster.Members, ClusterMember{machines[i].Name, count})
+			}
+		}
+		clusters = append(clusters, cluster)
+		remaining -= bestCount
+	}
+	return clusters, nil
+}
+
+// significantEvents returns the keys of a machine's events which Classify
+// could classify as anomalous: events which are normalized are keyed by their
+// normalized data, and others by their digest.
+func (b *Baseline) significantEvents(state *pb.MachineState) map[string]bool {
+	events := make(map[string]bool)
+	for _, event := range state.GetRawEvents() {
+		if b.isVariable(event) {
+			continue
+		}
+		if key, _, ok := b.normalize(event); ok {
+			events[fmt.Sprintf("n/%d/%d/%x", key.pcr, key.typ, key.data)] = true
+		} else {
+			events[fmt.Sprintf("d/%d/%x", event.GetPcrIndex(), event.GetDigest())] = true
+		}
+	}
+	return events
+}
+
+// eventsFingerprint identifies a set of events.
+func eventsFingerprint(events map[string]bool) [sha256.Size]byte {
+	keys := make([]string, 0, len(events))
+	for key := range events {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+	}
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], h.Sum(nil))
+	return fingerprint
+}
+
+// missingEvents returns the number of events not in the baseline's events.
+func missingEvents(events, baseline map[string]bool) int {
+	missing := 0
+	for key := range events {
+		if !baseline[key] {
+			missing++
+		}
+	}
+	return missing
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+func TestClusterFleet(t *testing.T) {
+	debian := parseTestMachineState(t, test.Debian10GCE)
+	rhel := parseTestMachineState(t, test.Rhel8GCE)
+	bootOrder := findEvent(t, debian, 1, 0x80000002)
+	separator := findEvent(t, debian, 4, Separator)
+	fleet := []FleetMachine{
+		{"debian-1", debian},
+		{"rhel-1", rhel},
+		{"debian-2", debian},
+		{"debian-boot-order", withChangedEvent(debian, bootOrder, 0, 0)},
+		{"debian-changed", withChangedEvent(debian, separator, 0, 0)},
+		{"rhel-2", rhel},
+	}
+
+	subtests := []struct {
+		name         string
+		maxAnomalies int
+		want         map[string][]ClusterMember
+	}{
+		{"Exact", 0, map[string][]ClusterMember{
+			"debian-1":       {{"debian-1", 0}, {"debian-2", 0}, {"debian-boot-order", 0}},
+			"rhel-1":         {{"rhel-1", 0}, {"rhel-2", 0}},
+			"debian-changed": {{"debian-changed", 0}},
+		}},
+		{"OneAnomaly", 1, map[string][]ClusterMember{
+			"debian-1": {{"debian-1", 0}, {"debian-2", 0}, {"debian-boot-order", 0}, {"debian-changed", 1}},
+			"rhel-1":   {{"rhel-1", 0}, {"rhel-2", 0}},
+		}},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			clusters, err := ClusterFleet(fleet, ClusterOpts{MaxAnomalies: subtest.maxAnomalies})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(clusters) != len(subtest.want) {
+				t.Fatalf("got %d clusters, want %d", len(clusters), len(subtest.want))
+			}
+			// The largest cluster is proposed first.
+			if clusters[0].Golden.Name != "debian-1" {
+				t.Errorf("first golden machine is %q, want debian-1", clusters[0].Golden.Name)
+			}
+			for _, cluster := range clusters {
+				want := subtest.want[cluster.Golden.Name]
+				if len(cluster.Members) != len(want) {
+					t.Fatalf("cluster of %q has members %v, want %v", cluster.Golden.Name, cluster.Members, want)
+				}
+				for i, member := range cluster.Members {
+					if member != want[i] {
+						t.Errorf("cluster of %q has members %v, want %v", cluster.Golden.Name, cluster.Members, want)
+						break
+					}
+				}
+				// Every member is classified against the proposed baseline
+				// with at most maxAnomalies anomalies.
+				for _, member := range cluster.Members {
+					state := fleetState(fleet, member.Name)
+					if got := len(cluster.Baseline.Classify(state).Anomalies()); got != member.Anomalies {
+						t.Errorf("%q has %d anomalies against its baseline, want %d", member.Name, got, member.Anomalies)
+					}
+				}
+			}
+		})
+	}
+
+	if _, err := ClusterFleet(nil, ClusterOpts{}); err == nil {
+		t.Error("ClusterFleet() with no machines should fail")
+	}
+	if _, err := ClusterFleet([]FleetMachine{{"empty", &pb.MachineState{}}}, ClusterOpts{}); err == nil {
+		t.Error("ClusterFleet() with a machine without events should fail")
+	}
+}
+
+func fleetState(fleet []FleetMachine, name string) *pb.MachineState {
+	for _, machine := range fleet {
+		if machine.Name == name {
+			return machine.State
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// UnmarshalAttestation decodes an Attestation produced by any release of this
+// library, so that verifiers can appraise evidence from agents several
+// versions behind (or ahead). Both the binary protobuf encoding and the JSON
+// encoding (as produced by protojson) are accepted.
+//
+// Attestations from older releases lack the fields added since, which
+// VerifyAttestation treats as absent: for example, an Attestation without
+// instance_info produces a MachineState without GCE instance information.
+// Fields added by newer releases are ignored by VerifyAttestation. JSON field
+// names are accepted in both their original (e.g. "ak_pub") and camel case
+// (e.g. "akPub") forms, as different releases and protobuf libraries have
+// produced either.
+func UnmarshalAttestation(data []byte) (*pb.Attestation, error) {
+	// The binary encoding is tried first: a binary Attestation can look like
+	// JSON (its ak_pub field starts with '\n', and a 123 byte AK with '{'), but
+	// JSON is never a binary Attestation with an AK and quotes.
+	attestation := &pb.Attestation{}
+	binaryErr := proto.Unmarshal(data, attestation)
+	if binaryErr == nil && hasAKAndQuotes(attestation) {
+		return attestation, nil
+	}
+	jsonAttestation := &pb.Attestation{}
+	jsonErr := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, jsonAttestation)
+	switch {
+	case jsonErr == nil:
+		attestation = jsonAttestation
+	case binaryErr != nil:
+		return nil, fmt.Errorf("failed to decode attestation as binary (%v) or JSON (%v)", binaryErr, jsonErr)
+	}
+	if !hasAKAndQuotes(attestation) {
+		return nil, fmt.Errorf("attestation is missing its AK or quotes")
+	}
+	return attestation, nil
+}
+
+func hasAKAndQuotes(attestation *pb.Attestation) bool {
+	return len(attestation.GetAkPub()) != 0 && len(attestation.GetQuotes()) != 0
+}
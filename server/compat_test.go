@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Attestations produced by each release of this library are stored in
+// testdata/compat/<release>, so that verifiers can keep appraising evidence
+// from agents several versions behind. To add the artifacts for a release,
+// run (on the release's tree):
+//
+//	go test ./server -run TestGenerateCompatArtifacts -generate-compat=<release>
+var generateCompat = flag.String("generate-compat", "", "write attestation artifacts for this release to testdata/compat")
+
+const compatDir = "testdata/compat"
+
+var compatNonce = []byte("go-tpm-tools compatibility nonce")
+
+func TestGenerateCompatArtifacts(t *testing.T) {
+	if *generateCompat == "" {
+		t.Skip("-generate-compat not set")
+	}
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	dir := filepath.Join(compatDir, *generateCompat)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	keys := []struct {
+		name   string
+		getKey func() (*client.Key, error)
+	}{
+		{"rsa", func() (*client.Key, error) { return client.AttestationKeyRSA(rwc) }},
+		{"ecc", func() (*client.Key, error) { return client.AttestationKeyECC(rwc) }},
+	}
+	for _, key := range keys {
+		ak, err := key.getKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestation, err := ak.Attest(client.AttestOpts{Nonce: compatNonce})
+		ak.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := proto.Marshal(attestation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, key.name+".pb"), encoded, 0644); err != nil {
+			t.Fatal(err)
+		}
+		encoded, err = protojson.Marshal(attestation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, key.name+".json"), encoded, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestCompatArtifacts verifies the attestations produced by every release.
+func TestCompatArtifacts(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(compatDir, "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no compatibility artifacts found")
+	}
+	for _, path := range paths {
+		release := filepath.Base(filepath.Dir(path))
+		t.Run(release+"/"+filepath.Base(path), func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			attestation, err := UnmarshalAttestation(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			akPubArea, err := tpm2.DecodePublic(attestation.GetAkPub())
+			if err != nil {
+				t.Fatal(err)
+			}
+			ak, err := akPubArea.Key()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ms, err := VerifyAttestation(attestation, VerifyOpts{
+				Nonce:      compatNonce,
+				TrustedAKs: []crypto.PublicKey{ak},
+			})
+			if err != nil {
+				t.Fatalf("failed to verify attestation from %s: %v", release, err)
+			}
+			if len(ms.GetRawEvents()) == 0 || len(ms.GetAkName()) == 0 {
+				t.Errorf("incomplete MachineState from %s: %v", release, ms)
+			}
+			if _, err := VerifyAttestation(attestation, VerifyOpts{
+				Nonce:      []byte("wrong nonce"),
+				TrustedAKs: []crypto.PublicKey{ak},
+			}); err == nil {
+				t.Error("verification with the wrong nonce should fail")
+			}
+		})
+	}
+}
+
+func TestUnmarshalAttestationJSONNames(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join(compatDir, "v0.3.0-alpha", "rsa.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same attestation, with the original field names and a field from a
+	// newer release.
+	legacy := strings.Replace(string(data), `"akPub"`, `"ak_pub"`, 1)
+	legacy = strings.Replace(legacy, `"eventLog"`, `"event_log"`, 1)
+	legacy = strings.Replace(legacy, "{", `{"field_from_the_future": 1, `, 1)
+	got, err := UnmarshalAttestation([]byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, want) {
+		t.Error("attestation with the original field names decoded differently")
+	}
+}
+
+// A binary Attestation starting with what looks like JSON after whitespace.
+func TestUnmarshalAttestationBinaryLikeJSON(t *testing.T) {
+	want := &pb.Attestation{
+		AkPub:  bytes.Repeat([]byte{'}'}, '{'),
+		Quotes: []*tpmpb.Quote{{Quote: []byte("quote")}},
+	}
+	data, err := proto.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("\n{")) {
+		t.Fatalf("encoded attestation starts with %q, want \"\\n{\"", data[:2])
+	}
+	got, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, want) {
+		t.Error("binary attestation decoded differently")
+	}
+}
+
+func TestUnmarshalAttestationFailures(t *testing.T) {
+	testcases := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", nil},
+		{"NoQuotes", []byte{0x0a, 0x01, 0x00}},
+		{"BadJSON", []byte(`{"akPub": 1}`)},
+		{"Garbage", []byte{0xff, 0xff, 0xff}},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			if _, err := UnmarshalAttestation(testcase.data); err == nil {
+				t.Error("UnmarshalAttestation() should have failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// The attributes a TPM key must have to be trusted as an AK.
+const akRequiredAttributes = tpm2.FlagFixedTPM | tpm2.FlagFixedParent |
+	tpm2.FlagSensitiveDataOrigin | tpm2.FlagRestricted | tpm2.FlagSign
+
+// GenerateChallenge creates a random secret, and encrypts it to the provided
+// public EK so that it can only be recovered by a TPM which has both that EK
+// and a key with the provided name (usually an AK) loaded. This is the server
+// side of TPM2_MakeCredential, but does not require a TPM.
+//
+// The returned EncryptedCredential can be decrypted using the client
+// Key.ActivateCredential() method. A client which returns the secret has
+// proven that the key with akName resides in the same TPM as the EK.
+//
+// The secret does not prove anything about the key itself. Before trusting
+// the key as an AK, callers must check its public area (which has akName)
+// with CheckAKPublicArea, as TPM2_ActivateCredential accepts any loaded key.
+func GenerateChallenge(ekPub crypto.PublicKey, akName tpm2.Name) (*pb.EncryptedCredential, []byte, error) {
+	ek, err := CreateEKPublicAreaFromKey(ekPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	if akName.Digest == nil {
+		return nil, nil, fmt.Errorf("AK name must contain a digest")
+	}
+	nameEncoded, err := akName.Digest.Encode()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secret := make([]byte, getHash(ek.NameAlg).Size())
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, nil, err
+	}
+	seed, encryptedSeed, err := createSeed(ek, "IDENTITY")
+	if err != nil {
+		return nil, nil, err
+	}
+	credentialBlob, err := createIDObject(secret, seed, nameEncoded, ek)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &pb.EncryptedCredential{
+		CredentialBlob:  credentialBlob,
+		EncryptedSecret: encryptedSeed,
+	}, secret, nil
+}
+
+// CheckAKPublicArea checks that a TPM key's public area is suitable for an AK.
+// The key must be a restricted signing key (so it only signs data generated by
+// the TPM, such as quotes) which was created in, and cannot leave, its TPM.
+func CheckAKPublicArea(akPub tpm2.Public) error {
+	if akPub.Attributes&akRequiredAttributes != akRequiredAttributes {
+		return fmt.Errorf("key is not a TPM-resident restricted signing key")
+	}
+	if akPub.Attributes&tpm2.FlagDecrypt != 0 {
+		return fmt.Errorf("restricted signing key must not allow decryption")
+	}
+	return nil
+}
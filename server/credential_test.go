@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+)
+
+func TestActivateCredential(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	keys := []struct {
+		name       string
+		ekTemplate tpm2.Public
+		akTemplate tpm2.Public
+	}{
+		{"RSA", client.DefaultEKTemplateRSA(), client.AKTemplateRSA()},
+		{"ECC", client.DefaultEKTemplateECC(), client.AKTemplateECC()},
+		{"RSA-EK-ECC-AK", client.DefaultEKTemplateRSA(), client.AKTemplateECC()},
+		{"HighRangeRSA", client.HighRangeEKTemplateRSA(), client.AKTemplateRSA()},
+		{"HighRangeECC", client.HighRangeEKTemplateECC(), client.AKTemplateECC()},
+	}
+	for _, k := range keys {
+		t.Run(k.name, func(t *testing.T) {
+			ek, err := client.NewKey(rwc, tpm2.HandleEndorsement, k.ekTemplate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ek.Close()
+			ak, err := client.NewKey(rwc, tpm2.HandleEndorsement, k.akTemplate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ak.Close()
+
+			challenge, secret, err := GenerateChallenge(ek.PublicKey(), ak.Name())
+			if err != nil {
+				t.Fatalf("generating challenge failed: %v", err)
+			}
+			output, err := ek.ActivateCredential(ak, challenge)
+			if err != nil {
+				t.Fatalf("activate credential failed: %v", err)
+			}
+			if !bytes.Equal(output, secret) {
+				t.Errorf("got %X, expected %X", output, secret)
+			}
+		})
+	}
+}
+
+func TestActivateCredentialWrongAK(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ek, err := client.EndorsementKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ek.Close()
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	otherAK, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherAK.Close()
+
+	challenge, _, err := GenerateChallenge(ek.PublicKey(), otherAK.Name())
+	if err != nil {
+		t.Fatalf("generating challenge failed: %v", err)
+	}
+	if _, err := ek.ActivateCredential(ak, challenge); err == nil {
+		t.Error("activating a credential bound to a different AK should fail")
+	}
+}
+
+func TestCheckAKPublicArea(t *testing.T) {
+	unrestricted := client.AKTemplateRSA()
+	unrestricted.Attributes &^= tpm2.FlagRestricted
+	decrypt := client.AKTemplateECC()
+	decrypt.Attributes |= tpm2.FlagDecrypt
+	notFixed := client.AKTemplateRSA()
+	notFixed.Attributes &^= tpm2.FlagFixedTPM
+
+	subtests := []struct {
+		name    string
+		akPub   tpm2.Public
+		wantErr bool
+	}{
+		{"AKTemplateRSA", client.AKTemplateRSA(), false},
+		{"AKTemplateECC", client.AKTemplateECC(), false},
+		{"SRKTemplate", client.SRKTemplateRSA(), true},
+		{"UnrestrictedSigningKey", unrestricted, true},
+		{"DecryptionAllowed", decrypt, true},
+		{"NotFixedTPM", notFixed, true},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			err := CheckAKPublicArea(subtest.akPub)
+			if gotErr := err != nil; gotErr != subtest.wantErr {
+				t.Errorf("CheckAKPublicArea() returned error %v, want error: %v", err, subtest.wantErr)
+			}
+		})
+	}
+}
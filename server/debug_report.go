@@ -0,0 +1,378 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-attestation/attest"
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// DebugOpts configures DebugAttestation.
+type DebugOpts struct {
+	// The options the attestation failed to verify with
+	VerifyOpts
+	// Optionally, the MachineState of a machine which verifies, with the same
+	// firmware and boot chain as the attesting machine. If set, the events of
+	// each mismatched PCR are compared with its events in the same bank.
+	Reference *pb.MachineState
+}
+
+// DebugReport describes why an attestation does (or does not) verify. Its
+// String method gives a human-readable report for support workflows.
+type DebugReport struct {
+	// The error of VerifyAttestation, or nil if the attestation verified
+	VerifyError error
+	// The quoted banks, in the order VerifyAttestation tries them
+	Banks []*BankReport
+}
+
+// BankReport compares the quoted PCRs of one bank with the event log.
+type BankReport struct {
+	Hash tpmpb.HashAlgo
+	// The error verifying the quote. If set, the quoted PCR values cannot be
+	// trusted, but are still compared with the event log.
+	QuoteError error
+	// The error parsing the event log, in which case PCRs is empty.
+	ParseError error
+	// Set if every mismatched PCR is zero: the firmware does not measure into
+	// this bank, so its mismatches do not need to be explained.
+	Unextended bool
+	// The quoted PCRs which the event log does not replay to, in increasing
+	// order
+	PCRs []*PCRReport
+}
+
+// PCRReport describes a quoted PCR which the event log does not replay to.
+type PCRReport struct {
+	Index  uint32
+	Quoted []byte
+	// The value replayed from the log, or nil if one of the PCR's events has
+	// no digest in the bank
+	Replayed []byte
+	// The first event where the log diverges from the quoted PCR (or from
+	// DebugOpts.Reference), or nil if no event explains the difference, for
+	// example because the PCR was extended with an event missing from the log.
+	Divergence *EventDivergence
+}
+
+// EventDivergence describes the first divergent event of a PCR.
+type EventDivergence struct {
+	// The event in the log, or nil if the log is missing an event of
+	// DebugOpts.Reference
+	Event *pb.Event
+	// The position of Event among the replayed events of the bank's PCRs, in
+	// log order, or -1 if Event is nil.
+	Position int
+	// The digest the event was expected to have, or nil if no event was
+	// expected
+	Expected []byte
+	// The digest in the log, or nil if the event is missing
+	Actual []byte
+	// Which boot phase the event belongs to, such as "firmware, Secure Boot
+	// policy", from its PCR and whether it follows the PCR's separator
+	Phase string
+	// Why the event diverges
+	Reason string
+}
+
+// Types of events whose digest is the hash of their data, from the TCG PC
+// Client Platform Firmware Profile Specification, Section 10.4.
+var hashedEventTypes = map[uint32]bool{
+	Separator:               true,
+	action:                  true,
+	EFIAction:               true,
+	EFIVariableDriverConfig: true,
+}
+
+// The use of each PCR, from the TCG PC Client Platform Firmware Profile
+// Specification, Section 3.3.4, and the Linux TPM PCR Registry.
+var pcrPhases = map[uint32]string{
+	0:  "platform firmware",
+	1:  "platform configuration",
+	2:  "option ROMs and UEFI drivers",
+	3:  "option ROM configuration",
+	4:  "boot manager and boot applications",
+	5:  "boot manager configuration",
+	6:  "platform manufacturer events",
+	7:  "Secure Boot policy",
+	8:  "boot loader commands and kernel command line",
+	9:  "boot loader files",
+	10: "operating system (IMA)",
+	11: "unified kernel image",
+	12: "kernel command line and credentials",
+	13: "system extensions",
+	14: "shim (MOK)",
+}
+
+// DebugAttestation explains why an attestation fails VerifyAttestation with
+// opts.VerifyOpts. For each quoted bank, it replays the TCG event log against
+// the quoted PCRs and, for each PCR which does not match, reports the first
+// divergent event, with its expected and actual digests, and the boot phase it
+// belongs to. The divergent event is the first one which:
+//   - has a digest that is not the hash of its data, for event types whose
+//     digest must be (such as EV_SEPARATOR), or
+//   - differs from the PCR's events in opts.Reference, or
+//   - follows the quoted value, which the log's events reached early.
+//
+// The report must only be used to diagnose failures: unlike VerifyAttestation,
+// it parses unverified attestations. The PCRs of additional quotes and
+// canonical event logs are not replayed, and PCR 0 is assumed to start at
+// locality 0 when looking for the quoted value.
+func DebugAttestation(attestation *pb.Attestation, opts DebugOpts) *DebugReport {
+	report := &DebugReport{}
+	// The nonce was consumed when the attestation was first verified.
+	verifyOpts := opts.VerifyOpts
+	verifyOpts.Nonces = nil
+	_, report.VerifyError = VerifyAttestation(attestation, verifyOpts)
+
+	var akPubKey crypto.PublicKey
+	akPubArea, err := tpm2.DecodePublic(attestation.GetAkPub())
+	if err == nil {
+		akPubKey, err = akPubArea.Key()
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to decode AK public area: %w", err)
+	}
+	extraData, extraDataErr := nonceExtraData(attestation, opts.VerifyOpts)
+
+	for _, quote := range internal.SupportedQuotes(attestation.GetQuotes()) {
+		bank := &BankReport{Hash: quote.GetPcrs().GetHash(), QuoteError: err}
+		if bank.QuoteError == nil {
+			bank.QuoteError = extraDataErr
+		}
+		if bank.QuoteError == nil {
+			bank.QuoteError = internal.VerifyQuote(quote, akPubKey, extraData)
+		}
+		pcrs := quote.GetPcrs()
+		if len(opts.SystemdPCRSignature) != 0 {
+			pcrs = withoutPCR(pcrs, systemdStubPCR)
+		}
+		bank.PCRs, bank.ParseError = debugReplay(attestation.GetEventLog(), pcrs, opts.Reference)
+		bank.Unextended = len(bank.PCRs) != 0
+		for _, pcr := range bank.PCRs {
+			bank.Unextended = bank.Unextended && bytes.Count(pcr.Quoted, []byte{0}) == len(pcr.Quoted)
+		}
+		report.Banks = append(report.Banks, bank)
+	}
+	return report
+}
+
+// debugReplay replays an event log against PCRs, and reports the PCRs which
+// do not match. An error is returned if the log cannot be parsed.
+func debugReplay(rawEventLog []byte, pcrs *tpmpb.PCRs, reference *pb.MachineState) ([]*PCRReport, error) {
+	var events []*pb.Event
+	err := ReplayEventLog(bytes.NewReader(rawEventLog), pcrs, func(event *pb.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	if err == nil {
+		return nil, nil
+	}
+	replayErr, ok := err.(*ReplayError)
+	if !ok {
+		return nil, err
+	}
+	cryptoHash, err := tpm2.Algorithm(pcrs.GetHash()).Hash()
+	if err != nil {
+		return nil, err
+	}
+	var referenceEvents []*pb.Event
+	if reference.GetHash() == pcrs.GetHash() {
+		referenceEvents = reference.GetRawEvents()
+	}
+
+	var reports []*PCRReport
+	for _, index := range replayErr.PCRs {
+		pcr := &PCRReport{
+			Index:    uint32(index),
+			Quoted:   pcrs.GetPcrs()[uint32(index)],
+			Replayed: replayErr.Replayed[uint32(index)],
+		}
+		var positions []int
+		for i, event := range events {
+			if event.GetPcrIndex() == pcr.Index {
+				positions = append(positions, i)
+			}
+		}
+		if pcr.Replayed != nil {
+			pcr.Divergence = findDivergence(cryptoHash, pcr, events, positions, referenceEvents)
+		}
+		reports = append(reports, pcr)
+	}
+	return reports, nil
+}
+
+// findDivergence returns the first divergent event of a mismatched PCR, whose
+// events are at positions in events.
+func findDivergence(hash crypto.Hash, pcr *PCRReport, events []*pb.Event, positions []int, referenceEvents []*pb.Event) *EventDivergence {
+	divergence := func(position int, expected []byte, reason string) *EventDivergence {
+		d := &EventDivergence{Position: position, Expected: expected, Reason: reason}
+		if position >= 0 {
+			d.Event = events[position]
+			d.Actual = d.Event.GetDigest()
+		}
+		return d
+	}
+
+	var divergent *EventDivergence
+	for _, position := range positions {
+		event := events[position]
+		if hashedEventTypes[event.GetUntrustedType()] && !event.GetDigestVerified() {
+			h := hash.New()
+			h.Write(event.GetData())
+			divergent = divergence(position, h.Sum(nil), "the digest is not the hash of the event data")
+			break
+		}
+	}
+
+	if divergent == nil && referenceEvents != nil {
+		var expected []*pb.Event
+		for _, event := range referenceEvents {
+			if event.GetPcrIndex() == pcr.Index {
+				expected = append(expected, event)
+			}
+		}
+		for i := 0; i < len(positions) || i < len(expected); i++ {
+			switch {
+			case i >= len(positions):
+				divergent = divergence(-1, expected[i].GetDigest(), fmt.Sprintf("the log is missing a %v event of the reference", attest.EventType(expected[i].GetUntrustedType())))
+			case i >= len(expected):
+				divergent = divergence(positions[i], nil, "the reference has no such event")
+			case !bytes.Equal(events[positions[i]].GetDigest(), expected[i].GetDigest()):
+				divergent = divergence(positions[i], expected[i].GetDigest(), "the digest differs from the reference")
+			}
+			if divergent != nil {
+				break
+			}
+		}
+	}
+
+	if divergent == nil {
+		value := make([]byte, hash.Size())
+		for _, position := range positions {
+			if bytes.Equal(value, pcr.Quoted) {
+				divergent = divergence(position, nil, "the quoted value was reached before this event, which was not extended into the PCR")
+				break
+			}
+			h := hash.New()
+			h.Write(value)
+			h.Write(events[position].GetDigest())
+			value = h.Sum(nil)
+		}
+	}
+
+	if divergent != nil {
+		divergent.Phase = eventPhase(pcr.Index, events, positions, divergent.Position)
+	}
+	return divergent
+}
+
+// eventPhase describes the boot phase of the event at a position, or of an
+// event missing after the PCR's events if position is -1.
+func eventPhase(index uint32, events []*pb.Event, positions []int, position int) string {
+	phase, ok := pcrPhases[index]
+	if !ok {
+		phase = fmt.Sprintf("PCR %d", index)
+	}
+	if index > 7 {
+		return phase
+	}
+	if position >= 0 && events[position].GetUntrustedType() == Separator {
+		return "end of firmware (separator), " + phase
+	}
+	for _, p := range positions {
+		if position >= 0 && p >= position {
+			break
+		}
+		if events[p].GetUntrustedType() == Separator {
+			return "after firmware, " + phase
+		}
+	}
+	return "firmware, " + phase
+}
+
+// FirstDivergence returns the first divergent event, and the PCR it was
+// extended into, in the bank with the fewest mismatched PCRs (preferring the
+// banks VerifyAttestation tries first), which is the bank the log most likely
+// failed to replay to. Unextended banks are ignored.
+func (r *DebugReport) FirstDivergence() (*PCRReport, *EventDivergence) {
+	var closest *BankReport
+	for _, bank := range r.Banks {
+		if bank.ParseError == nil && !bank.Unextended && (closest == nil || len(bank.PCRs) < len(closest.PCRs)) {
+			closest = bank
+		}
+	}
+	if closest == nil {
+		return nil, nil
+	}
+	var first *PCRReport
+	for _, pcr := range closest.PCRs {
+		if pcr.Divergence == nil {
+			continue
+		}
+		if first == nil || (pcr.Divergence.Position >= 0 &&
+			(first.Divergence.Position < 0 || pcr.Divergence.Position < first.Divergence.Position)) {
+			first = pcr
+		}
+	}
+	if first == nil {
+		return nil, nil
+	}
+	return first, first.Divergence
+}
+
+func (r *DebugReport) String() string {
+	var b strings.Builder
+	if r.VerifyError == nil {
+		b.WriteString("Attestation verified\n")
+	} else {
+		fmt.Fprintf(&b, "Attestation failed to verify: %v\n", r.VerifyError)
+	}
+	for _, bank := range r.Banks {
+		fmt.Fprintf(&b, "\n%v bank:\n", bank.Hash)
+		if bank.QuoteError != nil {
+			fmt.Fprintf(&b, "  quote failed to verify, so its PCRs cannot be trusted: %v\n", bank.QuoteError)
+		}
+		if bank.ParseError != nil {
+			fmt.Fprintf(&b, "  event log failed to parse: %v\n", bank.ParseError)
+			continue
+		}
+		if len(bank.PCRs) == 0 {
+			b.WriteString("  event log replays to the quoted PCRs\n")
+		}
+		if bank.Unextended {
+			b.WriteString("  quoted PCRs were never extended: the firmware does not measure into this bank\n")
+			continue
+		}
+		for _, pcr := range bank.PCRs {
+			fmt.Fprintf(&b, "  PCR %d: quoted %x, replayed %s\n", pcr.Index, pcr.Quoted, hexOrNone(pcr.Replayed, "(an event has no digest in this bank)"))
+			d := pcr.Divergence
+			if d == nil {
+				b.WriteString("    no event in the log explains the difference: the PCR was extended by an event which is not in the log\n")
+				continue
+			}
+			if d.Event != nil {
+				fmt.Fprintf(&b, "    first divergent event: #%d %v (%s)\n", d.Position, attest.EventType(d.Event.GetUntrustedType()), d.Phase)
+			} else {
+				fmt.Fprintf(&b, "    first divergent event: missing (%s)\n", d.Phase)
+			}
+			fmt.Fprintf(&b, "      expected: %s\n", hexOrNone(d.Expected, "(no event)"))
+			fmt.Fprintf(&b, "      actual:   %s\n", hexOrNone(d.Actual, "(no event)"))
+			fmt.Fprintf(&b, "      %s\n", d.Reason)
+		}
+	}
+	return b.String()
+}
+
+func hexOrNone(data []byte, none string) string {
+	if data == nil {
+		return none
+	}
+	return fmt.Sprintf("%x", data)
+}
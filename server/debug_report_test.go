@@ -0,0 +1,176 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFindDivergence(t *testing.T) {
+	digest := func(data string) []byte {
+		d := sha256.Sum256([]byte(data))
+		return d[:]
+	}
+	extend := func(digests ...[]byte) []byte {
+		value := make([]byte, sha256.Size)
+		for _, d := range digests {
+			next := sha256.Sum256(append(value, d...))
+			value = next[:]
+		}
+		return value
+	}
+	separator := []byte{0, 0, 0, 0}
+	events := []*pb.Event{
+		{PcrIndex: 4, UntrustedType: EFIAction, Data: []byte("Calling EFI Application from Boot Option"), Digest: digest("Calling EFI Application from Boot Option"), DigestVerified: true},
+		{PcrIndex: 7, UntrustedType: Separator, Data: separator, Digest: digest(string(separator)), DigestVerified: true},
+		{PcrIndex: 4, UntrustedType: Separator, Data: separator, Digest: digest(string(separator)), DigestVerified: true},
+		{PcrIndex: 4, UntrustedType: EFIBootServicesApplication, Data: []byte("shim"), Digest: digest("shim image")},
+		{PcrIndex: 4, UntrustedType: EFIBootServicesApplication, Data: []byte("grub"), Digest: digest("grub image")},
+	}
+	positions := []int{0, 2, 3, 4}
+	modified := func(position int, modify func(*pb.Event)) []*pb.Event {
+		var copied []*pb.Event
+		for _, event := range events {
+			copied = append(copied, proto.Clone(event).(*pb.Event))
+		}
+		modify(copied[position])
+		return copied
+	}
+
+	tests := []struct {
+		name      string
+		events    []*pb.Event
+		quoted    []byte
+		reference []*pb.Event
+		position  int
+		expected  []byte
+		phase     string
+	}{
+		{"TamperedSeparator", modified(2, func(e *pb.Event) { e.Digest, e.DigestVerified = digest("other"), false }), extend(), nil,
+			2, digest(string(separator)), "end of firmware (separator), boot manager and boot applications"},
+		{"ExtraEvent", events, extend(events[0].Digest, events[2].Digest, events[3].Digest), nil,
+			4, nil, "after firmware, boot manager and boot applications"},
+		{"ChangedEvent", modified(3, func(e *pb.Event) { e.Digest = digest("evil shim") }), extend(), events,
+			3, digest("shim image"), "after firmware, boot manager and boot applications"},
+		{"MissingEvent", events, extend(), append(append([]*pb.Event{}, events...), &pb.Event{PcrIndex: 4, Digest: digest("kernel")}),
+			-1, digest("kernel"), "after firmware, boot manager and boot applications"},
+		{"Unexplained", events, digest("unlogged"), nil, 0, nil, ""},
+	}
+	for _, subtest := range tests {
+		t.Run(subtest.name, func(t *testing.T) {
+			pcr := &PCRReport{Index: 4, Quoted: subtest.quoted}
+			d := findDivergence(crypto.SHA256, pcr, subtest.events, positions, subtest.reference)
+			if subtest.phase == "" {
+				if d != nil {
+					t.Errorf("findDivergence() = %+v, want nil", d)
+				}
+				return
+			}
+			if d == nil {
+				t.Fatal("findDivergence() = nil, want a divergent event")
+			}
+			if d.Position != subtest.position || !bytes.Equal(d.Expected, subtest.expected) || d.Phase != subtest.phase {
+				t.Errorf("findDivergence() = event %d, expected %x, phase %q; want event %d, expected %x, phase %q",
+					d.Position, d.Expected, d.Phase, subtest.position, subtest.expected, subtest.phase)
+			}
+			if d.Position >= 0 && !bytes.Equal(d.Actual, subtest.events[d.Position].GetDigest()) {
+				t.Errorf("findDivergence() actual digest = %x, want %x", d.Actual, subtest.events[d.Position].GetDigest())
+			}
+		})
+	}
+}
+
+func TestDebugAttestation(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+	nonce := []byte("super secret nonce")
+	opts := DebugOpts{VerifyOpts: VerifyOpts{Nonce: nonce, TrustedAKs: []crypto.PublicKey{ak.PublicKey()}}}
+
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := DebugAttestation(attestation, opts)
+	if report.VerifyError != nil {
+		t.Fatalf("DebugAttestation() of a valid attestation has error %v", report.VerifyError)
+	}
+	// The simulator's SHA-384 and SHA-512 banks are not in its event log.
+	replayed := false
+	for _, bank := range report.Banks {
+		if bank.QuoteError != nil {
+			t.Errorf("%v quote of a valid attestation failed to verify: %v", bank.Hash, bank.QuoteError)
+		}
+		if len(bank.PCRs) != 0 && !bank.Unextended {
+			t.Errorf("%v bank of a valid attestation has mismatched PCRs %v", bank.Hash, bank.PCRs)
+		}
+		replayed = replayed || len(bank.PCRs) == 0
+	}
+	if !replayed {
+		t.Error("no bank of a valid attestation replays")
+	}
+	reference, err := VerifyAttestation(attestation, opts.VerifyOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A reference whose first PCR 4 event differs points at that event, after
+	// PCR 4 is extended outside the log.
+	for _, hash := range []tpm2.Algorithm{tpm2.AlgSHA1, tpm2.AlgSHA256} {
+		if err := extendPCRsRandomly(rwc, tpm2.PCRSelection{Hash: hash, PCRs: []int{4}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if attestation, err = ak.Attest(client.AttestOpts{Nonce: nonce}); err != nil {
+		t.Fatal(err)
+	}
+	var changed []byte
+	for _, event := range reference.GetRawEvents() {
+		if event.GetPcrIndex() == 4 {
+			changed = bytes.Repeat([]byte{0xaa}, len(event.GetDigest()))
+			event.Digest = changed
+			break
+		}
+	}
+	opts.Reference = reference
+	report = DebugAttestation(attestation, opts)
+	if report.VerifyError == nil {
+		t.Fatal("DebugAttestation() after extending PCR 4 has no error")
+	}
+	pcr, divergence := report.FirstDivergence()
+	if pcr == nil || pcr.Index != 4 {
+		t.Fatalf("FirstDivergence() = %+v, want PCR 4", pcr)
+	}
+	if !bytes.Equal(divergence.Expected, changed) || divergence.Event == nil {
+		t.Errorf("FirstDivergence() = %+v, want the changed reference event", divergence)
+	}
+	text := report.String()
+	for _, want := range []string{"Attestation failed to verify", "PCR 4:", "first divergent event", "the digest differs from the reference"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("report does not contain %q:\n%s", want, text)
+		}
+	}
+
+	// Without a reference, no event explains the extension.
+	opts.Reference = nil
+	report = DebugAttestation(attestation, opts)
+	if pcr, _ := report.FirstDivergence(); pcr != nil {
+		t.Errorf("FirstDivergence() without a reference = PCR %d, want none", pcr.Index)
+	}
+	if text := report.String(); !strings.Contains(text, "no event in the log explains the difference") {
+		t.Errorf("report does not explain the unlogged extension:\n%s", text)
+	}
+}
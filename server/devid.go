@@ -0,0 +1,78 @@
+package server
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/google/go-tpm-tools/internal"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// The attributes which a DevID key must have, so its private key was generated
+// by, and can never leave, the TPM.
+const devIDRequiredAttributes = tpm2.FlagFixedTPM | tpm2.FlagFixedParent | tpm2.FlagSensitiveDataOrigin | tpm2.FlagSign
+
+// VerifyKeyCertification checks that the public area of a key was certified
+// (by client.Key.Certify) with a trusted key, and returns the certified public
+// area. The trustedAK should be an AK the caller has already established trust
+// in (for example, with VerifyAttestation or an AK certificate), and extraData
+// must match the data passed to Certify.
+func VerifyKeyCertification(certification *tpmpb.KeyCertification, trustedAK crypto.PublicKey, extraData []byte) (tpm2.Public, error) {
+	return internal.VerifyKeyCertification(certification, trustedAK, extraData)
+}
+
+// VerifyDevIDCertification is like VerifyKeyCertification, but also checks
+// that the certified key meets the requirements for IDevID and LDevID keys in
+// the TCG's TPM 2.0 Keys for Device Identity and Attestation, before it is
+// trusted as a device identity (for example, by issuing it an IEEE 802.1AR
+// certificate). It returns the DevID public key. The key must:
+//   - have been generated by the TPM, and be unable to leave it
+//   - be an unrestricted signing key, which cannot decrypt
+//   - use SHA-256 or a stronger name algorithm
+//   - be an RSA key of at least 2048 bits, or a NIST P-256, P-384 or P-521 key
+//
+// Keys created from client.DevIDTemplateRSA and client.DevIDTemplateECC meet
+// these requirements.
+func VerifyDevIDCertification(certification *tpmpb.KeyCertification, trustedAK crypto.PublicKey, extraData []byte) (crypto.PublicKey, error) {
+	pub, err := VerifyKeyCertification(certification, trustedAK, extraData)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDevIDPublic(pub); err != nil {
+		return nil, fmt.Errorf("certified key is not a valid DevID: %w", err)
+	}
+	return pub.Key()
+}
+
+func checkDevIDPublic(pub tpm2.Public) error {
+	if missing := devIDRequiredAttributes &^ pub.Attributes; missing != 0 {
+		return fmt.Errorf("missing attributes 0x%x", uint32(missing))
+	}
+	if pub.Attributes&(tpm2.FlagRestricted|tpm2.FlagDecrypt) != 0 {
+		return fmt.Errorf("must be an unrestricted signing key which cannot decrypt")
+	}
+	switch pub.NameAlg {
+	case tpm2.AlgSHA256, tpm2.AlgSHA384, tpm2.AlgSHA512:
+	default:
+		return fmt.Errorf("unsupported name algorithm %v", pub.NameAlg)
+	}
+	switch pub.Type {
+	case tpm2.AlgRSA:
+		if pub.RSAParameters == nil || pub.RSAParameters.KeyBits < 2048 {
+			return fmt.Errorf("RSA keys must have at least 2048 bits")
+		}
+	case tpm2.AlgECC:
+		if pub.ECCParameters == nil {
+			return fmt.Errorf("missing ECC parameters")
+		}
+		switch pub.ECCParameters.CurveID {
+		case tpm2.CurveNISTP256, tpm2.CurveNISTP384, tpm2.CurveNISTP521:
+		default:
+			return fmt.Errorf("unsupported curve %v", pub.ECCParameters.CurveID)
+		}
+	default:
+		return fmt.Errorf("unsupported key type %v", pub.Type)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestVerifyDevIDCertification(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	otherAK, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherAK.Close()
+	ldevID, err := client.LDevIDKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldevID.Close()
+
+	nonce := []byte("super secret nonce")
+	certification, err := ak.Certify(ldevID, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := VerifyDevIDCertification(certification, ak.PublicKey(), nonce)
+	if err != nil {
+		t.Fatalf("VerifyDevIDCertification() failed: %v", err)
+	}
+	if !pubKeysEqual(pub, ldevID.PublicKey()) {
+		t.Errorf("got DevID public key %v, want %v", pub, ldevID.PublicKey())
+	}
+
+	// The AK itself is certifiable, but is restricted, so it is not a DevID.
+	akCertification, err := otherAK.Certify(ak, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyKeyCertification(akCertification, otherAK.PublicKey(), nonce); err != nil {
+		t.Errorf("VerifyKeyCertification() failed: %v", err)
+	}
+
+	// A public area with different attributes has a different Name.
+	modifiedPub := ldevID.PublicArea()
+	modifiedPub.Attributes |= tpm2.FlagNoDA
+	modifiedPublic, err := modifiedPub.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := proto.Clone(certification).(*tpmpb.KeyCertification)
+	modified.PublicArea = modifiedPublic
+
+	subtests := []struct {
+		name          string
+		certification *tpmpb.KeyCertification
+		ak            *client.Key
+		extraData     []byte
+	}{
+		{"WrongNonce", certification, ak, []byte("wrong nonce")},
+		{"WrongAK", certification, otherAK, nonce},
+		{"ModifiedPublic", modified, ak, nonce},
+		{"NotDevID", akCertification, otherAK, nonce},
+		{"Empty", &tpmpb.KeyCertification{}, ak, nonce},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, err := VerifyDevIDCertification(subtest.certification, subtest.ak.PublicKey(), subtest.extraData); err == nil {
+				t.Error("VerifyDevIDCertification() should have failed")
+			}
+		})
+	}
+}
+
+func TestCheckDevIDPublic(t *testing.T) {
+	rsa1024 := client.DevIDTemplateRSA()
+	rsa1024.RSAParameters.KeyBits = 1024
+	sha1 := client.DevIDTemplateECC()
+	sha1.NameAlg = tpm2.AlgSHA1
+	decrypt := client.DevIDTemplateECC()
+	decrypt.Attributes |= tpm2.FlagDecrypt
+	notFixed := client.DevIDTemplateRSA()
+	notFixed.Attributes &^= tpm2.FlagFixedTPM
+
+	subtests := []struct {
+		name    string
+		pub     tpm2.Public
+		wantErr bool
+	}{
+		{"RSA", client.DevIDTemplateRSA(), false},
+		{"ECC", client.DevIDTemplateECC(), false},
+		{"RSA1024", rsa1024, true},
+		{"SHA1", sha1, true},
+		{"Decrypt", decrypt, true},
+		{"NotFixedTPM", notFixed, true},
+		{"AK", client.AKTemplateRSA(), true},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			err := checkDevIDPublic(subtest.pub)
+			if gotErr := err != nil; gotErr != subtest.wantErr {
+				t.Errorf("checkDevIDPublic() = %v, want error: %v", err, subtest.wantErr)
+			}
+		})
+	}
+}
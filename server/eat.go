@@ -0,0 +1,326 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/go-attestation/attest"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// EATFormat selects how IssueEAT encodes an Entity Attestation Token.
+type EATFormat int
+
+// Supported EAT encodings. CWT is the default, as it is the encoding most
+// commonly expected by RATS relying parties.
+const (
+	// EATFormatCWT encodes the token as a COSE_Sign1 (tag 18) signed CBOR Web
+	// Token, see RFC 8392.
+	EATFormatCWT EATFormat = iota
+	// EATFormatJWT encodes the token as a JWS Compact Serialization signed
+	// JSON Web Token, see RFC 7519.
+	EATFormatJWT
+)
+
+// EATDebugStatus is the value of the EAT "dbgstat" claim.
+type EATDebugStatus int
+
+// Debug status values from the EAT specification (RFC 9711, Section 4.2.9).
+// Only the values which can be determined from an event log are listed. In
+// particular, an event log cannot show that debugging is disabled: firmware
+// which does not record its debug state looks the same as firmware without a
+// debugger.
+const (
+	EATDebugEnabled EATDebugStatus = 0
+)
+
+// Claim keys from the CWT registry (RFC 8392) and the EAT specification
+// (RFC 9711). CWTs use the integer labels, JWTs use the string names.
+const (
+	cwtIssuer   = 1
+	cwtExpiry   = 4
+	cwtIssuedAt = 6
+	eatNonce    = 10
+	eatUEID     = 256
+	eatOEMBoot  = 262
+	eatDbgStat  = 263
+	eatBootSeed = 268
+)
+
+var eatClaimNames = map[int]string{
+	cwtIssuer:   "iss",
+	cwtExpiry:   "exp",
+	cwtIssuedAt: "iat",
+	eatNonce:    "eat_nonce",
+	eatUEID:     "ueid",
+	eatOEMBoot:  "oemboot",
+	eatDbgStat:  "dbgstat",
+	eatBootSeed: "bootseed",
+}
+
+// The data of the EV_EFI_ACTION event measured into PCR7 when a UEFI debugger
+// is enabled, from the TCG PC Client Platform Firmware Profile.
+var uefiDebugModeData = []byte("UEFI Debug Mode")
+
+// EATOpts allows for customizing the token produced by IssueEAT.
+type EATOpts struct {
+	// Format selects between the CWT (default) and JWT encodings.
+	Format EATFormat
+	// Nonce is emitted as the eat_nonce claim. This should generally be the
+	// same nonce which was used to verify the attestation. If set, it must be
+	// between 8 and 64 bytes long.
+	Nonce []byte
+	// Issuer is emitted as the iss claim, if non-empty.
+	Issuer string
+	// IssuedAt is emitted as the iat claim. Defaults to the current time.
+	IssuedAt time.Time
+	// Lifetime, if non-zero, is added to IssuedAt to produce the exp claim.
+	Lifetime time.Duration
+	// UEID is emitted as the ueid claim, if non-empty. It must be a valid
+	// Universal Entity ID (starting with a type byte).
+	UEID []byte
+	// BootSeed is emitted as the bootseed claim, if non-empty. Callers should
+	// use a value unique to the attested boot (for example, derived from the
+	// AK name and MachineState.ClockInfo.ResetCount), as the event log alone
+	// cannot distinguish boots.
+	BootSeed []byte
+	// KeyID, if non-empty, is emitted as the kid header parameter (in the
+	// protected header of a CWT), so relying parties can select the signing
+	// key from the issuer's published keys (see SigningKeyRing).
+	KeyID string
+}
+
+// IssueEAT encodes a verified MachineState as a signed Entity Attestation Token
+// (EAT), allowing the results of VerifyAttestation to be consumed by relying
+// parties which do not understand the MachineState protobuf. The token is
+// signed by signer, which may be a software key or a TPM-backed key from
+// client.Key.GetSigner. ECDSA (P-256, P-384, P-521), RSA (PKCS#1 v1.5 with
+// SHA-256), and Ed25519 signers are supported.
+//
+// In addition to the claims provided by opts, IssueEAT computes:
+//   - oemboot: if Secure Boot was enabled, when this can be determined from
+//     the MachineState's PCR7 events. Secure Boot only runs boot components
+//     signed by a key in the db, so this is true when the boot was authorized
+//     by the platform's Secure Boot keys. Whether those keys belong to the OEM
+//     (rather than, say, a cloud provider or the machine owner) is not
+//     checked; use a Policy on the MachineState's SecureBoot field for that.
+//   - dbgstat: EATDebugEnabled, if the PCR7 events record that a UEFI debugger
+//     was enabled during boot. Otherwise the claim is omitted, as the absence
+//     of this event does not prove that debugging was disabled.
+//
+// The MachineState should only come from VerifyAttestation or
+// ParseMachineState, as IssueEAT does not perform any verification itself.
+func IssueEAT(ms *pb.MachineState, signer crypto.Signer, opts EATOpts) ([]byte, error) {
+	claims, err := eatClaims(ms, opts)
+	if err != nil {
+		return nil, err
+	}
+	alg, err := getEATAlgorithm(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Format {
+	case EATFormatCWT:
+		return issueCWT(claims, signer, alg, opts.KeyID)
+	case EATFormatJWT:
+		return issueJWT(claims, signer, alg, opts.KeyID)
+	default:
+		return nil, fmt.Errorf("unknown EAT format: %d", opts.Format)
+	}
+}
+
+func eatClaims(ms *pb.MachineState, opts EATOpts) (map[int]interface{}, error) {
+	if ms == nil {
+		return nil, fmt.Errorf("cannot issue an EAT for a nil MachineState")
+	}
+	claims := map[int]interface{}{}
+	if len(opts.Nonce) != 0 {
+		if len(opts.Nonce) < 8 || len(opts.Nonce) > 64 {
+			return nil, fmt.Errorf("EAT nonce must be between 8 and 64 bytes, got %d", len(opts.Nonce))
+		}
+		claims[eatNonce] = opts.Nonce
+	}
+	if opts.Issuer != "" {
+		claims[cwtIssuer] = opts.Issuer
+	}
+	issuedAt := opts.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+	claims[cwtIssuedAt] = issuedAt.Unix()
+	if opts.Lifetime != 0 {
+		claims[cwtExpiry] = issuedAt.Add(opts.Lifetime).Unix()
+	}
+	if len(opts.UEID) != 0 {
+		claims[eatUEID] = opts.UEID
+	}
+	if len(opts.BootSeed) != 0 {
+		claims[eatBootSeed] = opts.BootSeed
+	}
+
+	if uefiDebugMode(ms) {
+		claims[eatDbgStat] = EATDebugEnabled
+	}
+	if enabled, err := secureBootEnabled(ms.GetRawEvents()); err == nil {
+		claims[eatOEMBoot] = enabled
+	}
+	return claims, nil
+}
+
+func secureBootEnabled(events []*pb.Event) (bool, error) {
+	attestEvents := make([]attest.Event, len(events))
+	for i, event := range events {
+		attestEvents[i] = attest.Event{
+			Index:  int(event.GetPcrIndex()),
+			Type:   attest.EventType(event.GetUntrustedType()),
+			Data:   event.GetData(),
+			Digest: event.GetDigest(),
+		}
+	}
+	state, err := attest.ParseSecurebootState(attestEvents)
+	if err != nil {
+		return false, err
+	}
+	return state.Enabled, nil
+}
+
+// eatAlgorithm describes how a particular public key is used to sign a token.
+type eatAlgorithm struct {
+	cose int
+	jose string
+	hash crypto.Hash
+	// For ECDSA keys, the size of the R and S values in the signature.
+	ecdsaSize int
+}
+
+func getEATAlgorithm(pub crypto.PublicKey) (eatAlgorithm, error) {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return eatAlgorithm{-7, "ES256", crypto.SHA256, size}, nil
+		case 384:
+			return eatAlgorithm{-35, "ES384", crypto.SHA384, size}, nil
+		case 521:
+			return eatAlgorithm{-36, "ES512", crypto.SHA512, size}, nil
+		default:
+			return eatAlgorithm{}, fmt.Errorf("unsupported ECDSA curve: %v", key.Curve.Params().Name)
+		}
+	case *rsa.PublicKey:
+		return eatAlgorithm{-257, "RS256", crypto.SHA256, 0}, nil
+	case ed25519.PublicKey:
+		return eatAlgorithm{-8, "EdDSA", crypto.Hash(0), 0}, nil
+	default:
+		return eatAlgorithm{}, fmt.Errorf("unsupported EAT signing key type: %T", pub)
+	}
+}
+
+// sign produces a signature over msg in the format expected by both COSE and
+// JOSE (i.e. ECDSA signatures are the concatenation of R and S).
+func (alg eatAlgorithm) sign(signer crypto.Signer, msg []byte) ([]byte, error) {
+	digest := msg
+	if alg.hash != crypto.Hash(0) {
+		h := alg.hash.New()
+		h.Write(msg)
+		digest = h.Sum(nil)
+	}
+	sig, err := signer.Sign(rand.Reader, digest, alg.hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign EAT: %w", err)
+	}
+	if alg.ecdsaSize == 0 {
+		return sig, nil
+	}
+
+	var ecdsaSig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &ecdsaSig); err != nil {
+		return nil, fmt.Errorf("failed to decode ECDSA signature: %w", err)
+	}
+	out := make([]byte, 2*alg.ecdsaSize)
+	ecdsaSig.R.FillBytes(out[:alg.ecdsaSize])
+	ecdsaSig.S.FillBytes(out[alg.ecdsaSize:])
+	return out, nil
+}
+
+// COSE_Sign1 message tag and header labels from RFC 8152.
+const (
+	coseSign1Tag    = 18
+	coseHeaderAlg   = 1
+	coseHeaderKeyID = 4
+	coseSign1Label  = "Signature1"
+)
+
+func issueCWT(claims map[int]interface{}, signer crypto.Signer, alg eatAlgorithm, keyID string) ([]byte, error) {
+	encMode, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := encMode.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode EAT claims: %w", err)
+	}
+	headers := map[int]interface{}{coseHeaderAlg: alg.cose}
+	if keyID != "" {
+		headers[coseHeaderKeyID] = []byte(keyID)
+	}
+	protected, err := encMode.Marshal(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sig_structure from RFC 8152, Section 4.4, with an empty external_aad.
+	toBeSigned, err := encMode.Marshal([]interface{}{coseSign1Label, protected, []byte{}, payload})
+	if err != nil {
+		return nil, err
+	}
+	sig, err := alg.sign(signer, toBeSigned)
+	if err != nil {
+		return nil, err
+	}
+	return encMode.Marshal(cbor.Tag{
+		Number:  coseSign1Tag,
+		Content: []interface{}{protected, map[int]interface{}{}, payload, sig},
+	})
+}
+
+func issueJWT(claims map[int]interface{}, signer crypto.Signer, alg eatAlgorithm, keyID string) ([]byte, error) {
+	jsonClaims := make(map[string]interface{}, len(claims))
+	for label, value := range claims {
+		// Binary claims are base64url encoded in the JSON serialization.
+		if b, ok := value.([]byte); ok {
+			value = base64.RawURLEncoding.EncodeToString(b)
+		}
+		jsonClaims[eatClaimNames[label]] = value
+	}
+	headers := map[string]string{"alg": alg.jose, "typ": "JWT"}
+	if keyID != "" {
+		headers["kid"] = keyID
+	}
+	header, err := json.Marshal(headers)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(jsonClaims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode EAT claims: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := alg.sign(signer, []byte(signingInput))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)), nil
+}
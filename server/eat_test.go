@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+func getRhel8MachineState(t *testing.T) *pb.MachineState {
+	t.Helper()
+	ms, err := ParseMachineState(test.Rhel8GCE.RawLog, test.Rhel8GCE.Banks[1])
+	if err != nil {
+		t.Fatalf("failed to parse machine state: %v", err)
+	}
+	return ms
+}
+
+func decodeCWT(t *testing.T, token []byte, pub *ecdsa.PublicKey) map[int]interface{} {
+	t.Helper()
+	var msg cbor.Tag
+	if err := cbor.Unmarshal(token, &msg); err != nil {
+		t.Fatalf("failed to decode COSE_Sign1: %v", err)
+	}
+	if msg.Number != coseSign1Tag {
+		t.Fatalf("got CBOR tag %d, want %d", msg.Number, coseSign1Tag)
+	}
+	parts, ok := msg.Content.([]interface{})
+	if !ok || len(parts) != 4 {
+		t.Fatalf("COSE_Sign1 content is not a 4 element array: %v", msg.Content)
+	}
+	protected, payload, sig := parts[0].([]byte), parts[2].([]byte), parts[3].([]byte)
+
+	toBeSigned, err := cbor.Marshal([]interface{}{coseSign1Label, protected, []byte{}, payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(toBeSigned)
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(pub, digest[:], r, s) {
+		t.Fatal("CWT signature verification failed")
+	}
+
+	var claims map[int]interface{}
+	if err := cbor.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("failed to decode CWT claims: %v", err)
+	}
+	return claims
+}
+
+func TestIssueEATCWT(t *testing.T) {
+	ms := getRhel8MachineState(t)
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("super secret nonce")
+	issuedAt := time.Unix(1600000000, 0)
+
+	token, err := IssueEAT(ms, priv, EATOpts{
+		Nonce:    nonce,
+		Issuer:   "test-verifier",
+		IssuedAt: issuedAt,
+		Lifetime: time.Hour,
+		BootSeed: []byte{1, 2, 3, 4},
+	})
+	if err != nil {
+		t.Fatalf("IssueEAT() failed: %v", err)
+	}
+	claims := decodeCWT(t, token, &priv.PublicKey)
+
+	if got := claims[eatNonce]; !bytes.Equal(got.([]byte), nonce) {
+		t.Errorf("eat_nonce = %v, want %v", got, nonce)
+	}
+	if got := claims[cwtIssuer]; got != "test-verifier" {
+		t.Errorf("iss = %v, want test-verifier", got)
+	}
+	if got := claims[cwtIssuedAt]; got != uint64(issuedAt.Unix()) {
+		t.Errorf("iat = %v, want %v", got, issuedAt.Unix())
+	}
+	if got := claims[cwtExpiry]; got != uint64(issuedAt.Add(time.Hour).Unix()) {
+		t.Errorf("exp = %v, want %v", got, issuedAt.Add(time.Hour).Unix())
+	}
+	if got := claims[eatBootSeed]; !bytes.Equal(got.([]byte), []byte{1, 2, 3, 4}) {
+		t.Errorf("bootseed = %v, want [1 2 3 4]", got)
+	}
+	// The RHEL 8 GCE image is booted with Secure Boot and no debugger.
+	if got := claims[eatOEMBoot]; got != true {
+		t.Errorf("oemboot = %v, want true", got)
+	}
+	if got, ok := claims[eatDbgStat]; ok {
+		t.Errorf("dbgstat = %v, want no claim", got)
+	}
+	if _, ok := claims[eatUEID]; ok {
+		t.Error("ueid claim should not be present")
+	}
+}
+
+func TestIssueEATJWT(t *testing.T) {
+	ms := getRhel8MachineState(t)
+	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("super secret nonce")
+
+	token, err := IssueEAT(ms, priv, EATOpts{Format: EATFormatJWT, Nonce: nonce})
+	if err != nil {
+		t.Fatalf("IssueEAT() failed: %v", err)
+	}
+	parts := strings.Split(string(token), ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT has %d parts, want 3", len(parts))
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(header) != `{"alg":"ES384","typ":"JWT"}` {
+		t.Errorf("unexpected JWT header: %s", header)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := crypto.SHA384.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(sig[:48])
+	s := new(big.Int).SetBytes(sig[48:])
+	if !ecdsa.Verify(&priv.PublicKey, h.Sum(nil), r, s) {
+		t.Fatal("JWT signature verification failed")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if got := claims["eat_nonce"]; got != base64.RawURLEncoding.EncodeToString(nonce) {
+		t.Errorf("eat_nonce = %v, want base64url of %q", got, nonce)
+	}
+	if got := claims["oemboot"]; got != true {
+		t.Errorf("oemboot = %v, want true", got)
+	}
+	if got, ok := claims["dbgstat"]; ok {
+		t.Errorf("dbgstat = %v, want no claim", got)
+	}
+}
+
+func TestEATDebugStatus(t *testing.T) {
+	ms := withDebugMode(t, getRhel8MachineState(t), uefiDebugModeData)
+
+	claims, err := eatClaims(ms, EATOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := claims[eatDbgStat]; got != EATDebugEnabled {
+		t.Errorf("dbgstat = %v, want %v", got, EATDebugEnabled)
+	}
+}
+
+func TestIssueEATSigners(t *testing.T) {
+	ms := getRhel8MachineState(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eccKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, signer := range []crypto.Signer{rsaKey, edKey, eccKey} {
+		for _, format := range []EATFormat{EATFormatCWT, EATFormatJWT} {
+			if _, err := IssueEAT(ms, signer, EATOpts{Format: format}); err != nil {
+				t.Errorf("IssueEAT(%T, %v) failed: %v", signer, format, err)
+			}
+		}
+	}
+}
+
+func TestIssueEATFailures(t *testing.T) {
+	ms := getRhel8MachineState(t)
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subtests := []struct {
+		name   string
+		ms     *pb.MachineState
+		signer crypto.Signer
+		opts   EATOpts
+	}{
+		{"NilMachineState", nil, priv, EATOpts{}},
+		{"ShortNonce", ms, priv, EATOpts{Nonce: []byte("short")}},
+		{"LongNonce", ms, priv, EATOpts{Nonce: make([]byte, 65)}},
+		{"UnknownFormat", ms, priv, EATOpts{Format: EATFormat(42)}},
+		{"UnsupportedCurve", ms, p224, EATOpts{}},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, err := IssueEAT(subtest.ms, subtest.signer, subtest.opts); err == nil {
+				t.Error("IssueEAT() should have failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,642 @@
+package server
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"embed"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-attestation/attest"
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// TPM manufacturer root and intermediate certificates (PEM or DER encoded).
+// See ek-roots/README.md for how this directory is maintained.
+//
+//go:embed ek-roots
+var ekRootFiles embed.FS
+
+// Object identifiers from the TCG EK Credential Profile for TPM Family 2.0.
+var (
+	oidSubjectAltName             = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidSubjectDirectoryAttributes = asn1.ObjectIdentifier{2, 5, 29, 9}
+	oidTPMManufacturer            = asn1.ObjectIdentifier{2, 23, 133, 2, 1}
+	oidTPMModel                   = asn1.ObjectIdentifier{2, 23, 133, 2, 2}
+	oidTPMVersion                 = asn1.ObjectIdentifier{2, 23, 133, 2, 3}
+	oidTPMSpecification           = asn1.ObjectIdentifier{2, 23, 133, 2, 16}
+	oidTPMSecurityAssertions      = asn1.ObjectIdentifier{2, 23, 133, 2, 18}
+	oidEKCertificateUsage         = asn1.ObjectIdentifier{2, 23, 133, 8, 1}
+	oidGCEInstanceInfo            = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 1, 21}
+)
+
+// EKRootStore is a set of trusted TPM manufacturer certificates, used to
+// verify Endorsement Key (EK) certificates.
+type EKRootStore struct {
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	// The certificates in intermediates, which are added to the certificates
+	// embedded in an attestation.
+	intermediateCerts []*x509.Certificate
+}
+
+// NewEKRootStore returns an empty EKRootStore.
+func NewEKRootStore() *EKRootStore {
+	return &EKRootStore{roots: x509.NewCertPool(), intermediates: x509.NewCertPool()}
+}
+
+// The bundled manufacturer certificates are only parsed once.
+var (
+	bundledEKRootsOnce sync.Once
+	bundledEKCerts     []*x509.Certificate
+	bundledEKRoots     *EKRootStore
+	bundledEKRootsErr  error
+)
+
+// DefaultEKRoots returns a new EKRootStore containing the manufacturer
+// certificates bundled with this package. Only the GCE vTPM certificates are
+// bundled (see ek-roots/README.md), so callers verifying the EK certificates
+// of other TPMs must add their manufacturers' certificates to the returned
+// store.
+func DefaultEKRoots() (*EKRootStore, error) {
+	if _, err := loadBundledEKRoots(); err != nil {
+		return nil, err
+	}
+	store := NewEKRootStore()
+	for _, cert := range bundledEKCerts {
+		store.AddCertificate(cert)
+	}
+	return store, nil
+}
+
+// loadBundledEKRoots returns a shared store of the bundled manufacturer
+// certificates, which must not be modified.
+func loadBundledEKRoots() (*EKRootStore, error) {
+	bundledEKRootsOnce.Do(func() {
+		bundledEKCerts, bundledEKRootsErr = parseBundledEKCerts()
+		bundledEKRoots = NewEKRootStore()
+		for _, cert := range bundledEKCerts {
+			bundledEKRoots.AddCertificate(cert)
+		}
+	})
+	return bundledEKRoots, bundledEKRootsErr
+}
+
+func parseBundledEKCerts() ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	err := fs.WalkDir(ekRootFiles, "ek-roots", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch path.Ext(name) {
+		case ".pem", ".crt", ".cer", ".der":
+		default:
+			return nil
+		}
+		data, err := ekRootFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		parsed, err := parseCertificates(data)
+		if err != nil {
+			return fmt.Errorf("bundled EK root %s: %w", name, err)
+		}
+		certs = append(certs, parsed...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// AddCertificate adds a manufacturer certificate to the store. Self-signed
+// certificates are trusted as roots, all others are only used as
+// intermediates when building a chain to a root.
+func (s *EKRootStore) AddCertificate(cert *x509.Certificate) {
+	if cert.CheckSignatureFrom(cert) == nil {
+		s.roots.AddCert(cert)
+	} else {
+		s.intermediates.AddCert(cert)
+		s.intermediateCerts = append(s.intermediateCerts, cert)
+	}
+}
+
+// AddCertificates parses and adds either a single DER encoded certificate or
+// any number of PEM encoded certificates to the store.
+func (s *EKRootStore) AddCertificates(data []byte) error {
+	certs, err := parseCertificates(data)
+	if err != nil {
+		return err
+	}
+	for _, cert := range certs {
+		s.AddCertificate(cert)
+	}
+	return nil
+}
+
+// parseCertificates parses either a single DER encoded certificate or any
+// number of PEM encoded certificates.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block == nil {
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DER certificate: %w", err)
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PEM certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// VerifyEKCertificate checks that an EK certificate chains to a manufacturer
+// certificate in the provided EKRootStore, and that it contains the TPM
+// manufacturer, model, and version attributes required by the TCG EK
+// Credential Profile. The certificate can be DER encoded, or in the format
+// used to store EK certificates in TPM NVRAM. Certificates for RSA EKs
+// vulnerable to ROCA are rejected with an error matching ErrROCAVulnerable (see
+// IsROCAVulnerable). If verification succeeds, the TPM's attributes are
+// returned.
+func VerifyEKCertificate(ekCert []byte, roots *EKRootStore) (*pb.TpmInfo, error) {
+	cert, err := verifyEKCertificate(ekCert, roots, nil)
+	if err != nil {
+		return nil, err
+	}
+	// A vulnerable EK's private key can be computed, so the EK no longer
+	// identifies the TPM, whatever its certificate says.
+	if err := checkROCA(cert.PublicKey); err != nil {
+		return nil, fmt.Errorf("EK certificate: %w", err)
+	}
+	return cert.TPM, nil
+}
+
+// verifyEKCertificate implements VerifyEKCertificate, without checking the EK
+// for ROCA, returning the verified certificate. The DER encoded intermediates,
+// such as those embedded in an attestation, are used along with the store's
+// intermediate certificates to build the chain; they are not trusted as roots.
+func verifyEKCertificate(ekCert []byte, roots *EKRootStore, intermediates [][]byte) (*EKCertificate, error) {
+	if roots == nil {
+		return nil, fmt.Errorf("no EK roots provided")
+	}
+	cert, err := ParseEKCertificate(ekCert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse EK certificate: %w", err)
+	}
+	pool, err := roots.intermediatePool(intermediates)
+	if err != nil {
+		return nil, err
+	}
+	return checkEKCertificate(cert, roots, pool)
+}
+
+// intermediatePool returns the pool of the store's intermediates, along with
+// the provided DER encoded intermediates (such as those of an attestation).
+func (s *EKRootStore) intermediatePool(intermediates [][]byte) (*x509.CertPool, error) {
+	if len(intermediates) == 0 {
+		return s.intermediates, nil
+	}
+	pool := x509.NewCertPool()
+	for _, intermediate := range s.intermediateCerts {
+		pool.AddCert(intermediate)
+	}
+	for i, der := range intermediates {
+		intermediate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse intermediate certificate %d: %w", i, err)
+		}
+		pool.AddCert(intermediate)
+	}
+	return pool, nil
+}
+
+// checkEKCertificate verifies a parsed EK certificate's chain to the roots,
+// through the pool of intermediates.
+func checkEKCertificate(cert *EKCertificate, roots *EKRootStore, pool *x509.CertPool) (*EKCertificate, error) {
+	if cert.TPM == nil {
+		return nil, fmt.Errorf("EK certificate is missing the TPM manufacturer, model and version attributes")
+	}
+	chains, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots.roots,
+		Intermediates: pool,
+		// EK certificates use the tcg-kp-EKCertificate extended key usage
+		// (if any), which Go's verifier does not know about.
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify EK certificate: %w", err)
+	}
+	if internal.FIPSMode {
+		if err := checkFIPSChain(chains[0]); err != nil {
+			return nil, fmt.Errorf("failed to verify EK certificate: %w", err)
+		}
+	}
+	if len(cert.UnknownExtKeyUsage) != 0 && !hasOID(cert.UnknownExtKeyUsage, oidEKCertificateUsage) {
+		return nil, fmt.Errorf("EK certificate is missing the tcg-kp-EKCertificate extended key usage")
+	}
+	return cert, nil
+}
+
+// checkFIPSChain checks that a verified certificate chain only uses algorithms
+// allowed in FIPS mode. Self-signed roots are trusted without checking their
+// signatures, so only their keys are checked.
+func checkFIPSChain(chain []*x509.Certificate) error {
+	for i, cert := range chain {
+		if i == len(chain)-1 {
+			if err := internal.CheckFIPSPublicKey(cert.PublicKey); err != nil {
+				return fmt.Errorf("root %q: %w", cert.Subject, err)
+			}
+			continue
+		}
+		if err := internal.CheckFIPSCertificate(cert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EKCertificate is a parsed EK certificate, with the attributes of its TPM
+// which are defined by the TCG EK Credential Profile or by TPM vendors. The
+// certificate's policies (such as those asserting the TPM's conformance) are
+// in the embedded certificate's PolicyIdentifiers.
+type EKCertificate struct {
+	*x509.Certificate
+	// The TPM manufacturer, model and firmware version, from the Subject
+	// Alternative Name. Nil if the certificate does not have these attributes.
+	TPM *pb.TpmInfo
+	// The TPM specification implemented by the TPM, from the Subject Directory
+	// Attributes. Nil if the certificate does not have this attribute.
+	Specification *TPMSpecification
+	// The security evaluations of the TPM, from the Subject Directory
+	// Attributes. Nil if the certificate does not have this attribute.
+	SecurityAssertions *TPMSecurityAssertions
+	// The GCE VM to which a GCE vTPM belongs, from Google's instance identity
+	// extension. Nil for other TPMs.
+	GCEInstance *pb.GCEInstanceInfo
+}
+
+// TPMSpecification is the version of the TPM specification implemented by a
+// TPM, from the tcg-at-tpmSpecification attribute.
+type TPMSpecification struct {
+	// The specification family, such as "2.0"
+	Family string `asn1:"utf8"`
+	// The specification level
+	Level int
+	// The specification revision, such as 138 for revision 1.38
+	Revision int
+}
+
+// TPMSecurityAssertions are the security evaluations of a TPM, from the
+// tcg-at-tpmSecurityAssertions attribute.
+type TPMSecurityAssertions struct {
+	// Whether the TPM firmware can be upgraded in the field
+	FieldUpgradable bool
+	// The FIPS 140 validation of the TPM, nil if it was not validated
+	FIPS *FIPSLevel
+	// The Common Criteria evaluation of the TPM, nil if it was not evaluated
+	CommonCriteria *CommonCriteriaMeasures
+	// Whether the TPM was manufactured in an ISO 9000 certified facility,
+	// and the URI of the certification (if any)
+	ISO9000Certified bool
+	ISO9000URI       string
+}
+
+// FIPSLevel is the FIPS 140 validation of a TPM.
+type FIPSLevel struct {
+	// The FIPS 140 version, such as "140-2"
+	Version string
+	// The security level, from 1 to 4
+	Level int
+	// Whether the TPM exceeds the requirements of the security level
+	Plus bool
+}
+
+// EvaluationStatus is the status of a Common Criteria evaluation.
+type EvaluationStatus int
+
+// Common Criteria evaluation statuses, from the TCG EK Credential Profile.
+const (
+	DesignedToMeet EvaluationStatus = iota
+	EvaluationInProgress
+	EvaluationCompleted
+)
+
+// CommonCriteriaMeasures is the Common Criteria evaluation of a TPM.
+type CommonCriteriaMeasures struct {
+	// The Common Criteria version, such as "3.1"
+	Version string
+	// The Evaluation Assurance Level (EAL), from 1 to 7
+	AssuranceLevel int
+	// Whether the evaluation is completed, or only in progress or planned
+	EvaluationStatus EvaluationStatus
+	// Whether the TPM exceeds the requirements of the assurance level
+	Plus bool
+	// The protection profile the TPM was evaluated against, if any
+	ProfileOID asn1.ObjectIdentifier
+	// The security target of the evaluation, if any
+	TargetOID asn1.ObjectIdentifier
+}
+
+// ASN.1 structures of the tcg-at-tpmSecurityAssertions attribute.
+type tpmSecurityAssertions struct {
+	Version                         int                    `asn1:"optional,default:0"`
+	FieldUpgradable                 bool                   `asn1:"optional"`
+	EKGenerationType                asn1.Enumerated        `asn1:"optional,tag:0"`
+	EKGenerationLocation            asn1.Enumerated        `asn1:"optional,tag:1"`
+	EKCertificateGenerationLocation asn1.Enumerated        `asn1:"optional,tag:2"`
+	CCInfo                          commonCriteriaMeasures `asn1:"optional,tag:3"`
+	FIPSLevel                       fipsLevel              `asn1:"optional,tag:4"`
+	ISO9000Certified                bool                   `asn1:"optional,tag:5"`
+	ISO9000URI                      string                 `asn1:"optional,ia5"`
+}
+
+type commonCriteriaMeasures struct {
+	Version            string `asn1:"ia5"`
+	AssuranceLevel     asn1.Enumerated
+	EvaluationStatus   asn1.Enumerated
+	Plus               bool                  `asn1:"optional"`
+	StrengthOfFunction asn1.Enumerated       `asn1:"optional,tag:0"`
+	ProfileOID         asn1.ObjectIdentifier `asn1:"optional,tag:1"`
+	ProfileURI         uriReference          `asn1:"optional,tag:2"`
+	TargetOID          asn1.ObjectIdentifier `asn1:"optional,tag:3"`
+	TargetURI          uriReference          `asn1:"optional,tag:4"`
+}
+
+type fipsLevel struct {
+	Version string `asn1:"ia5"`
+	Level   asn1.Enumerated
+	Plus    bool `asn1:"optional"`
+}
+
+// uriReference is followed by an optional hash of the referenced document,
+// which is not needed.
+type uriReference struct {
+	URI string `asn1:"ia5"`
+}
+
+// gceInstanceInfo is the ASN.1 structure of Google's instance identity
+// extension, which is followed by the VM's security properties.
+type gceInstanceInfo struct {
+	Zone          string `asn1:"utf8"`
+	ProjectNumber *big.Int
+	ProjectID     string `asn1:"utf8"`
+	InstanceID    *big.Int
+	InstanceName  string `asn1:"utf8"`
+}
+
+// ParseEKCertificate parses a DER encoded EK certificate, removing the header
+// and any padding present when the certificate is read from NVRAM (see the TCG
+// PC Client Platform TPM Profile, Section 7.3.2), along with the TPM attributes
+// in its extensions. The certificate is not verified; use VerifyEKCertificate
+// for that.
+func ParseEKCertificate(ekCert []byte) (*EKCertificate, error) {
+	der, err := internal.TrimNVCertificate(ekCert)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	ek := &EKCertificate{Certificate: cert}
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidSubjectAltName):
+			// EK certificates often have an empty subject, and so mark the
+			// Subject Alternative Name as critical. Go only understands some
+			// kinds of SANs, so we handle the extension ourselves.
+			tpmAttrs, err := parseTPMAttributes(ext.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EK certificate SAN: %w", err)
+			}
+			if len(tpmAttrs) == 0 {
+				continue
+			}
+			if ek.TPM, err = getTPMInfo(tpmAttrs); err != nil {
+				return nil, err
+			}
+		case ext.Id.Equal(oidSubjectDirectoryAttributes):
+			if err := ek.parseSubjectDirectoryAttributes(ext.Value); err != nil {
+				return nil, fmt.Errorf("invalid EK certificate subject directory attributes: %w", err)
+			}
+		case ext.Id.Equal(oidGCEInstanceInfo):
+			if ek.GCEInstance, err = parseGCEInstanceInfo(ext.Value); err != nil {
+				return nil, fmt.Errorf("invalid EK certificate GCE instance information: %w", err)
+			}
+		}
+	}
+	unhandled := cert.UnhandledCriticalExtensions[:0]
+	for _, oid := range cert.UnhandledCriticalExtensions {
+		if !oid.Equal(oidSubjectAltName) {
+			unhandled = append(unhandled, oid)
+		}
+	}
+	cert.UnhandledCriticalExtensions = unhandled
+	return ek, nil
+}
+
+// CheckEK compares an EK, such as one sent by a client along with this
+// certificate, byte-for-byte with the certificate's public key. If they do
+// not match, the returned *client.EKMismatchError describes the difference.
+// Clients can find which EK template matches their certificate with
+// client.CheckEKCertificate.
+func (ek *EKCertificate) CheckEK(pub crypto.PublicKey) error {
+	return client.CompareEKPublicKey(ek.Certificate, pub)
+}
+
+// parseSubjectDirectoryAttributes parses the tcg-at-tpmSpecification and
+// tcg-at-tpmSecurityAssertions attributes, ignoring any others.
+func (ek *EKCertificate) parseSubjectDirectoryAttributes(ext []byte) error {
+	var attrs []struct {
+		Type   asn1.ObjectIdentifier
+		Values []asn1.RawValue `asn1:"set"`
+	}
+	if rest, err := asn1.Unmarshal(ext, &attrs); err != nil {
+		return err
+	} else if len(rest) != 0 {
+		return fmt.Errorf("trailing data after subject directory attributes")
+	}
+	for _, attr := range attrs {
+		if len(attr.Values) != 1 {
+			continue
+		}
+		value := attr.Values[0].FullBytes
+		switch {
+		case attr.Type.Equal(oidTPMSpecification):
+			ek.Specification = &TPMSpecification{}
+			if err := unmarshalAll(value, ek.Specification); err != nil {
+				return fmt.Errorf("invalid TPM specification: %w", err)
+			}
+		case attr.Type.Equal(oidTPMSecurityAssertions):
+			var assertions tpmSecurityAssertions
+			if err := unmarshalAll(value, &assertions); err != nil {
+				return fmt.Errorf("invalid TPM security assertions: %w", err)
+			}
+			ek.SecurityAssertions = assertions.convert()
+		}
+	}
+	return nil
+}
+
+func (a *tpmSecurityAssertions) convert() *TPMSecurityAssertions {
+	out := &TPMSecurityAssertions{
+		FieldUpgradable:  a.FieldUpgradable,
+		ISO9000Certified: a.ISO9000Certified,
+		ISO9000URI:       a.ISO9000URI,
+	}
+	// Both have a required version, so are only present if it is set.
+	if cc := a.CCInfo; cc.Version != "" {
+		out.CommonCriteria = &CommonCriteriaMeasures{
+			Version:          cc.Version,
+			AssuranceLevel:   int(cc.AssuranceLevel),
+			EvaluationStatus: EvaluationStatus(cc.EvaluationStatus),
+			Plus:             cc.Plus,
+			ProfileOID:       cc.ProfileOID,
+			TargetOID:        cc.TargetOID,
+		}
+	}
+	if fips := a.FIPSLevel; fips.Version != "" {
+		out.FIPS = &FIPSLevel{Version: fips.Version, Level: int(fips.Level), Plus: fips.Plus}
+	}
+	return out
+}
+
+func parseGCEInstanceInfo(ext []byte) (*pb.GCEInstanceInfo, error) {
+	var info gceInstanceInfo
+	if err := unmarshalAll(ext, &info); err != nil {
+		return nil, err
+	}
+	if !info.ProjectNumber.IsUint64() || !info.InstanceID.IsUint64() {
+		return nil, fmt.Errorf("project number or instance ID is out of range")
+	}
+	return &pb.GCEInstanceInfo{
+		Zone:          info.Zone,
+		ProjectId:     info.ProjectID,
+		ProjectNumber: info.ProjectNumber.Uint64(),
+		InstanceName:  info.InstanceName,
+		InstanceId:    info.InstanceID.Uint64(),
+	}, nil
+}
+
+// unmarshalAll unmarshals DER data, which must not have trailing data.
+func unmarshalAll(data []byte, out interface{}) error {
+	if rest, err := asn1.Unmarshal(data, out); err != nil {
+		return err
+	} else if len(rest) != 0 {
+		return fmt.Errorf("%d bytes of trailing data", len(rest))
+	}
+	return nil
+}
+
+// parseTPMAttributes returns the directoryName entries of a SAN extension.
+func parseTPMAttributes(san []byte) (pkix.RDNSequence, error) {
+	var names asn1.RawValue
+	if rest, err := asn1.Unmarshal(san, &names); err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("trailing data after SAN")
+	}
+	if !names.IsCompound || names.Tag != asn1.TagSequence {
+		return nil, fmt.Errorf("SAN is not a sequence")
+	}
+
+	var attrs pkix.RDNSequence
+	for rest := names.Bytes; len(rest) > 0; {
+		var name asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &name); err != nil {
+			return nil, err
+		}
+		// directoryName [4] Name
+		if name.Class != asn1.ClassContextSpecific || name.Tag != 4 {
+			continue
+		}
+		var dirName pkix.RDNSequence
+		if _, err := asn1.Unmarshal(name.Bytes, &dirName); err != nil {
+			return nil, fmt.Errorf("invalid directoryName: %w", err)
+		}
+		attrs = append(attrs, dirName...)
+	}
+	return attrs, nil
+}
+
+func getTPMInfo(attrs pkix.RDNSequence) (*pb.TpmInfo, error) {
+	values := make(map[string]string)
+	for _, rdn := range attrs {
+		for _, attr := range rdn {
+			if value, ok := attr.Value.(string); ok {
+				values[attr.Type.String()] = value
+			}
+		}
+	}
+	manufacturer, ok := values[oidTPMManufacturer.String()]
+	if !ok {
+		return nil, fmt.Errorf("EK certificate is missing the TPM manufacturer attribute")
+	}
+	model, ok := values[oidTPMModel.String()]
+	if !ok {
+		return nil, fmt.Errorf("EK certificate is missing the TPM model attribute")
+	}
+	version, ok := values[oidTPMVersion.String()]
+	if !ok {
+		return nil, fmt.Errorf("EK certificate is missing the TPM version attribute")
+	}
+
+	manufacturerID, err := parseTCGID(manufacturer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TPM manufacturer attribute: %w", err)
+	}
+	firmwareVersion, err := parseTCGID(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TPM version attribute: %w", err)
+	}
+	return &pb.TpmInfo{
+		ManufacturerId:  manufacturerID,
+		Manufacturer:    attest.TCGVendorID(manufacturerID).String(),
+		Model:           model,
+		FirmwareVersion: firmwareVersion,
+	}, nil
+}
+
+// parseTCGID parses attribute values of the form "id:XXXXXXXX", where X is a
+// hex digit.
+func parseTCGID(value string) (uint32, error) {
+	hexValue := strings.TrimPrefix(value, "id:")
+	if len(hexValue) != 8 || hexValue == value {
+		return 0, fmt.Errorf("%q is not of the form id:XXXXXXXX", value)
+	}
+	id, err := strconv.ParseUint(hexValue, 16, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(id), nil
+}
+
+func hasOID(oids []asn1.ObjectIdentifier, oid asn1.ObjectIdentifier) bool {
+	for _, o := range oids {
+		if o.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,505 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"io/fs"
+	"math/big"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"google.golang.org/protobuf/proto"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func createTestCA(t *testing.T, name string, parent *testCA) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	parentCert, parentKey := template, crypto.Signer(key)
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert, key}
+}
+
+func tpmAttributesSAN(t *testing.T, attrs map[string]string) pkix.Extension {
+	t.Helper()
+	var dirName pkix.RDNSequence
+	for _, oid := range []asn1.ObjectIdentifier{oidTPMManufacturer, oidTPMModel, oidTPMVersion} {
+		if value, ok := attrs[oid.String()]; ok {
+			dirName = append(dirName, pkix.RelativeDistinguishedNameSET{{Type: oid, Value: value}})
+		}
+	}
+	dirNameBytes, err := asn1.Marshal(dirName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	san, err := asn1.Marshal([]asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: dirNameBytes}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: oidSubjectAltName, Critical: true, Value: san}
+}
+
+var infineonAttrs = map[string]string{
+	oidTPMManufacturer.String(): "id:49465800",
+	oidTPMModel.String():        "SLB9670",
+	oidTPMVersion.String():      "id:00070055",
+}
+
+func createTestEKCert(t *testing.T, issuer *testCA, attrs map[string]string, usage asn1.ObjectIdentifier, extensions ...pkix.Extension) []byte {
+	t.Helper()
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return createTestEKCertForKey(t, issuer, attrs, usage, ek.Public(), extensions...)
+}
+
+func createTestEKCertForKey(t *testing.T, issuer *testCA, attrs map[string]string, usage asn1.ObjectIdentifier, ekPub crypto.PublicKey, extensions ...pkix.Extension) []byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:       big.NewInt(2),
+		NotBefore:          time.Now().Add(-time.Hour),
+		NotAfter:           time.Now().Add(time.Hour),
+		KeyUsage:           x509.KeyUsageKeyEncipherment,
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{usage},
+		ExtraExtensions:    append([]pkix.Extension{tpmAttributesSAN(t, attrs)}, extensions...),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer.cert, ekPub, issuer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestVerifyEKCertificate(t *testing.T) {
+	root := createTestCA(t, "Test TPM Root CA", nil)
+	intermediate := createTestCA(t, "Test TPM Intermediate CA", root)
+	ekCert := createTestEKCert(t, intermediate, infineonAttrs, oidEKCertificateUsage)
+
+	roots := NewEKRootStore()
+	pemCerts := append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.cert.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate.cert.Raw})...)
+	if err := roots.AddCertificates(pemCerts); err != nil {
+		t.Fatal(err)
+	}
+
+	// EK certificates read from NVRAM are prefixed with a header.
+	nvCert := []byte{0x10, 0x01, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(nvCert[3:], uint16(len(ekCert)))
+	nvCert = append(nvCert, ekCert...)
+
+	for _, cert := range [][]byte{ekCert, nvCert} {
+		info, err := VerifyEKCertificate(cert, roots)
+		if err != nil {
+			t.Fatalf("VerifyEKCertificate() failed: %v", err)
+		}
+		if info.GetManufacturerId() != 0x49465800 || info.GetManufacturer() != "Infineon" {
+			t.Errorf("got manufacturer %x (%q), want 49465800 (Infineon)", info.GetManufacturerId(), info.GetManufacturer())
+		}
+		if info.GetModel() != "SLB9670" {
+			t.Errorf("got model %q, want SLB9670", info.GetModel())
+		}
+		if info.GetFirmwareVersion() != 0x00070055 {
+			t.Errorf("got firmware version %x, want 70055", info.GetFirmwareVersion())
+		}
+	}
+}
+
+func TestVerifyEKCertificateFailures(t *testing.T) {
+	root := createTestCA(t, "Test TPM Root CA", nil)
+	otherRoot := createTestCA(t, "Other Root CA", nil)
+	intermediate := createTestCA(t, "Test TPM Intermediate CA", root)
+
+	roots := NewEKRootStore()
+	roots.AddCertificate(root.cert)
+
+	missingModel := map[string]string{
+		oidTPMManufacturer.String(): "id:49465800",
+		oidTPMVersion.String():      "id:00070055",
+	}
+	badVersion := map[string]string{
+		oidTPMManufacturer.String(): "id:49465800",
+		oidTPMModel.String():        "SLB9670",
+		oidTPMVersion.String():      "7.85",
+	}
+	subtests := []struct {
+		name   string
+		ekCert []byte
+	}{
+		{"UntrustedRoot", createTestEKCert(t, otherRoot, infineonAttrs, oidEKCertificateUsage)},
+		{"MissingIntermediate", createTestEKCert(t, intermediate, infineonAttrs, oidEKCertificateUsage)},
+		{"MissingAttribute", createTestEKCert(t, root, missingModel, oidEKCertificateUsage)},
+		{"BadAttribute", createTestEKCert(t, root, badVersion, oidEKCertificateUsage)},
+		{"WrongKeyUsage", createTestEKCert(t, root, infineonAttrs, asn1.ObjectIdentifier{1, 2, 3, 4})},
+		{"NotACertificate", []byte("not a certificate")},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, err := VerifyEKCertificate(subtest.ekCert, roots); err == nil {
+				t.Error("VerifyEKCertificate() should have failed")
+			}
+		})
+	}
+}
+
+func subjectDirectoryAttribute(t *testing.T, oid asn1.ObjectIdentifier, value interface{}) pkix.Extension {
+	t.Helper()
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs, err := asn1.Marshal([]struct {
+		Type   asn1.ObjectIdentifier
+		Values []asn1.RawValue `asn1:"set"`
+	}{{oid, []asn1.RawValue{{FullBytes: der}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: oidSubjectDirectoryAttributes, Value: attrs}
+}
+
+func TestParseEKCertificate(t *testing.T) {
+	root := createTestCA(t, "Test TPM Root CA", nil)
+	profile := asn1.ObjectIdentifier{1, 2, 3, 4}
+	assertions := tpmSecurityAssertions{
+		FieldUpgradable: true,
+		CCInfo: commonCriteriaMeasures{
+			Version:          "3.1",
+			AssuranceLevel:   4,
+			EvaluationStatus: asn1.Enumerated(EvaluationCompleted),
+			Plus:             true,
+			ProfileOID:       profile,
+		},
+		FIPSLevel: fipsLevel{Version: "140-2", Level: 2},
+	}
+	ekCert := createTestEKCert(t, root, infineonAttrs, oidEKCertificateUsage,
+		subjectDirectoryAttribute(t, oidTPMSecurityAssertions, assertions))
+
+	cert, err := ParseEKCertificate(ekCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.TPM.GetManufacturer() != "Infineon" || cert.TPM.GetModel() != "SLB9670" {
+		t.Errorf("got TPM info %v, want an Infineon SLB9670", cert.TPM)
+	}
+	if cert.Specification != nil || cert.GCEInstance != nil {
+		t.Error("got attributes which are not in the certificate")
+	}
+	got := cert.SecurityAssertions
+	if got == nil || !got.FieldUpgradable || got.ISO9000Certified {
+		t.Fatalf("got security assertions %+v", got)
+	}
+	if cc := got.CommonCriteria; cc == nil || cc.Version != "3.1" || cc.AssuranceLevel != 4 ||
+		cc.EvaluationStatus != EvaluationCompleted || !cc.Plus || !cc.ProfileOID.Equal(profile) || cc.TargetOID != nil {
+		t.Errorf("got Common Criteria measures %+v", cc)
+	}
+	if fips := got.FIPS; fips == nil || *fips != (FIPSLevel{Version: "140-2", Level: 2}) {
+		t.Errorf("got FIPS level %+v", fips)
+	}
+
+	// Assertions without a Common Criteria or FIPS evaluation
+	ekCert = createTestEKCert(t, root, infineonAttrs, oidEKCertificateUsage,
+		subjectDirectoryAttribute(t, oidTPMSecurityAssertions, tpmSecurityAssertions{ISO9000Certified: true}))
+	if cert, err = ParseEKCertificate(ekCert); err != nil {
+		t.Fatal(err)
+	}
+	if got := cert.SecurityAssertions; got == nil || got.CommonCriteria != nil || got.FIPS != nil || !got.ISO9000Certified {
+		t.Errorf("got security assertions %+v", got)
+	}
+}
+
+func TestParseRealEKCertificate(t *testing.T) {
+	block, _ := pem.Decode(test.GCEEKCertRSA)
+	if block == nil {
+		t.Fatal("failed to decode GCE EK certificate")
+	}
+	cert, err := ParseEKCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.TPM.GetManufacturer() != "Google" || cert.TPM.GetModel() != "vTPM" {
+		t.Errorf("got TPM info %v, want a Google vTPM", cert.TPM)
+	}
+	if spec := cert.Specification; spec == nil || *spec != (TPMSpecification{Family: "2.0", Level: 0, Revision: 142}) {
+		t.Errorf("got TPM specification %+v, want 2.0 level 0 revision 142", spec)
+	}
+	want := &pb.GCEInstanceInfo{
+		Zone:          "us-central1-a",
+		ProjectId:     "google.com:wuale-gcp-testing",
+		ProjectNumber: 117478743145,
+		InstanceName:  "cs-debug",
+		InstanceId:    5780156274199835632,
+	}
+	if !proto.Equal(cert.GCEInstance, want) {
+		t.Errorf("got GCE instance %v, want %v", cert.GCEInstance, want)
+	}
+}
+
+func TestParseEKCertificateFailures(t *testing.T) {
+	root := createTestCA(t, "Test TPM Root CA", nil)
+	badAssertions := subjectDirectoryAttribute(t, oidTPMSecurityAssertions, "not assertions")
+	badSpec := subjectDirectoryAttribute(t, oidTPMSpecification, struct{ Family int }{2})
+	badGCEInstance := pkix.Extension{Id: oidGCEInstanceInfo, Value: []byte{0x30, 0x03, 0x02, 0x01, 0x01}}
+	subtests := []struct {
+		name   string
+		ekCert []byte
+	}{
+		{"InvalidSecurityAssertions", createTestEKCert(t, root, infineonAttrs, oidEKCertificateUsage, badAssertions)},
+		{"InvalidSpecification", createTestEKCert(t, root, infineonAttrs, oidEKCertificateUsage, badSpec)},
+		{"InvalidGCEInstance", createTestEKCert(t, root, infineonAttrs, oidEKCertificateUsage, badGCEInstance)},
+		{"InvalidTPMAttribute", createTestEKCert(t, root, map[string]string{oidTPMManufacturer.String(): "Infineon"}, oidEKCertificateUsage)},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, err := ParseEKCertificate(subtest.ekCert); err == nil {
+				t.Error("ParseEKCertificate() should have failed")
+			}
+		})
+	}
+}
+
+func TestDefaultEKRoots(t *testing.T) {
+	roots, err := DefaultEKRoots()
+	if err != nil {
+		t.Fatalf("failed to load bundled EK roots: %v", err)
+	}
+	// Callers can add to the returned store without affecting the bundled
+	// roots used by VerifyAttestation.
+	if bundled, _ := loadBundledEKRoots(); bundled.roots == roots.roots || bundled.intermediates == roots.intermediates {
+		t.Error("DefaultEKRoots() should return a new store")
+	}
+}
+
+func TestBundledEKRoots(t *testing.T) {
+	roots, err := loadBundledEKRoots()
+	if err != nil {
+		t.Fatalf("failed to load bundled EK roots: %v", err)
+	}
+	entries, err := fs.ReadDir(ekRootFiles, "ek-roots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readme, err := ekRootFiles.ReadFile("ek-roots/README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Bundled files are named after their manufacturer, and recorded in
+	// ek-roots/README.md. Only the GCE vTPM certificates are bundled so far.
+	manufacturers := map[string]int{"gce": 0, "infineon": 0, "nuvoton": 0, "stmicro": 0, "amd": 0}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch path.Ext(name) {
+		case ".pem", ".crt", ".cer", ".der":
+		default:
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			manufacturer, _, _ := strings.Cut(name, "-")
+			if _, ok := manufacturers[manufacturer]; !ok {
+				t.Errorf("%s is not named after a known manufacturer", name)
+			}
+			manufacturers[manufacturer]++
+			if !strings.Contains(string(readme), "| `"+name+"` |") {
+				t.Errorf("%s is not recorded in ek-roots/README.md", name)
+			}
+			data, err := ekRootFiles.ReadFile(path.Join("ek-roots", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			certs, err := parseCertificates(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, cert := range certs {
+				opts := x509.VerifyOptions{
+					Roots:         roots.roots,
+					Intermediates: roots.intermediates,
+					KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+				}
+				if _, err := cert.Verify(opts); err != nil {
+					t.Errorf("%q does not chain to a bundled root: %v", cert.Subject, err)
+				}
+			}
+		})
+	}
+	if manufacturers["gce"] == 0 {
+		t.Error("no GCE vTPM certificates are bundled")
+	}
+}
+
+func TestVerifyRealEKCertificate(t *testing.T) {
+	block, _ := pem.Decode(test.GCEEKCertRSA)
+	if block == nil {
+		t.Fatal("failed to decode GCE EK certificate")
+	}
+	roots, err := DefaultEKRoots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := VerifyEKCertificate(block.Bytes, roots)
+	if err != nil {
+		t.Fatalf("VerifyEKCertificate() failed for a GCE vTPM EK certificate: %v", err)
+	}
+	if info.GetManufacturerId() != 0x474F4F47 || info.GetModel() != "vTPM" || info.GetFirmwareVersion() != 0x20160511 {
+		t.Errorf("got TPM info %v, want GOOG vTPM version 20160511", info)
+	}
+	if _, err := VerifyEKCertificate(block.Bytes, NewEKRootStore()); err == nil {
+		t.Error("VerifyEKCertificate() should fail without the GCE EK roots")
+	}
+}
+
+func TestVerifyAttestationWithEKCert(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+
+	nonce := []byte("super secret nonce")
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+
+	root := createTestCA(t, "Test TPM Root CA", nil)
+	roots := NewEKRootStore()
+	roots.AddCertificate(root.cert)
+	opts := VerifyOpts{
+		Nonce:      nonce,
+		TrustedAKs: []crypto.PublicKey{ak.PublicKey()},
+		EKCert:     createTestEKCert(t, root, infineonAttrs, oidEKCertificateUsage),
+		EKRoots:    roots,
+	}
+	ms, err := VerifyAttestation(attestation, opts)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if ms.GetTpmInfo().GetManufacturer() != "Infineon" {
+		t.Errorf("MachineState has TpmInfo %v, want an Infineon TPM", ms.GetTpmInfo())
+	}
+
+	opts.EKRoots = NewEKRootStore()
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("using an untrusted EK certificate should make verification fail")
+	}
+
+	opts.EKCert = nil
+	ms, err = VerifyAttestation(attestation, opts)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if ms.GetTpmInfo() != (*pb.TpmInfo)(nil) {
+		t.Errorf("MachineState has TpmInfo %v without an EK certificate", ms.GetTpmInfo())
+	}
+}
+
+func TestVerifyAttestationWithEmbeddedEKCertChain(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+
+	root := createTestCA(t, "Test TPM Root CA", nil)
+	intermediate := createTestCA(t, "Test TPM Intermediate CA", root)
+	nonce := []byte("super secret nonce")
+	attestation, err := ak.Attest(client.AttestOpts{
+		Nonce:             nonce,
+		EKCert:            createTestEKCert(t, intermediate, infineonAttrs, oidEKCertificateUsage),
+		IntermediateCerts: [][]byte{intermediate.cert.Raw},
+	})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+
+	// Only the root is trusted, so the chain is built with the embedded
+	// intermediate.
+	roots := NewEKRootStore()
+	roots.AddCertificate(root.cert)
+	opts := VerifyOpts{
+		Nonce:      nonce,
+		TrustedAKs: []crypto.PublicKey{ak.PublicKey()},
+		EKRoots:    roots,
+	}
+	// The embedded certificate is not bound to the AK, so it is ignored
+	// unless the caller trusts it.
+	ms, err := VerifyAttestation(attestation, opts)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if ms.GetTpmInfo() != (*pb.TpmInfo)(nil) {
+		t.Errorf("MachineState has TpmInfo %v from an untrusted EK certificate", ms.GetTpmInfo())
+	}
+
+	opts.TrustAttestationEKCert = true
+	ms, err = VerifyAttestation(attestation, opts)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if ms.GetTpmInfo().GetManufacturer() != "Infineon" {
+		t.Errorf("MachineState has TpmInfo %v, want an Infineon TPM", ms.GetTpmInfo())
+	}
+
+	// An embedded certificate is never trusted as a root.
+	attestation.IntermediateCerts = append(attestation.IntermediateCerts, root.cert.Raw)
+	opts.EKRoots = NewEKRootStore()
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("verification should fail without a trusted root")
+	}
+
+	attestation.IntermediateCerts = nil
+	opts.EKRoots = roots
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("verification should fail without the intermediate")
+	}
+
+	attestation.IntermediateCerts = [][]byte{[]byte("not a certificate")}
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("verification should fail with an invalid intermediate")
+	}
+}
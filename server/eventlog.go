@@ -27,8 +27,13 @@ func ParseMachineState(rawEventLog []byte, pcrs *tpmpb.PCRs) (*pb.MachineState,
 	}
 	// error is already checked in convertToAttestPcrs
 	cryptoHash, _ := tpm2.Algorithm(pcrs.GetHash()).Hash()
+	return machineStateFromEvents(pcrs.GetHash(), convertToPbEvents(cryptoHash, events)), nil
+}
 
-	rawEvents := convertToPbEvents(cryptoHash, events)
+// machineStateFromEvents creates a MachineState from the replayed events.
+func machineStateFromEvents(hash tpmpb.HashAlgo, rawEvents []*pb.Event) *pb.MachineState {
+	// error is already checked when replaying the events
+	cryptoHash, _ := tpm2.Algorithm(hash).Hash()
 	platform, err := getPlatfromState(cryptoHash, rawEvents)
 	if err != nil {
 		// If we had an error parsing the platform state, we don't want to fail
@@ -36,11 +41,21 @@ func ParseMachineState(rawEventLog []byte, pcrs *tpmpb.PCRs) (*pb.MachineState,
 		platform = &pb.PlatformState{}
 	}
 
+	// As with the platform state, a Secure Boot configuration which cannot be
+	// parsed does not fail the attestation, but is not included.
+	secureBoot, _ := getSecureBootState(rawEvents)
+	systemdStub, _ := getSystemdStubState(cryptoHash, rawEvents)
+	grub, _ := getGrubState(cryptoHash, rawEvents)
+
 	return &pb.MachineState{
-		Platform:  platform,
-		RawEvents: rawEvents,
-		Hash:      pcrs.GetHash(),
-	}, nil
+		Platform:    platform,
+		SecureBoot:  secureBoot,
+		RawEvents:   rawEvents,
+		Hash:        hash,
+		LinuxKernel: getLinuxKernelState(cryptoHash, rawEvents),
+		SystemdStub: systemdStub,
+		Grub:        grub,
+	}
 }
 
 func getPlatfromState(hash crypto.Hash, events []*pb.Event) (*pb.PlatformState, error) {
@@ -113,15 +128,87 @@ func parseReplayHelper(rawEventLog []byte, pcrs *tpmpb.PCRs) ([]attest.Event, er
 	}
 	eventLog, err := attest.ParseEventLog(rawEventLog)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse event log: %v", err)
+		return nil, &LogReplayError{Bank: pcrs.GetHash(), Err: fmt.Errorf("failed to parse event log: %v", err)}
 	}
 	events, err := eventLog.Verify(attestPcrs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to replay event log: %v", err)
+		replayErr := &LogReplayError{Bank: pcrs.GetHash(), Err: err}
+		if errors.As(err, &attest.ReplayError{}) {
+			replayErr.Mismatches = replayMismatches(rawEventLog, pcrs)
+		}
+		return nil, replayErr
 	}
 	return events, nil
 }
 
+// PCRMismatchError describes a PCR whose value replayed from an event log is
+// not the quoted value.
+type PCRMismatchError struct {
+	Index uint32
+	// The value replayed from the event log, or nil if one of the PCR's
+	// events has no digest in the bank.
+	Got []byte
+	// The quoted value
+	Want []byte
+}
+
+func (e *PCRMismatchError) Error() string {
+	return fmt.Sprintf("PCR %d replayed to %x, but %x was quoted", e.Index, e.Got, e.Want)
+}
+
+// LogReplayError is returned (possibly wrapped) by ParseMachineState and
+// VerifyAttestation when an event log cannot be parsed, or does not replay to
+// the PCR values in one of the quoted banks.
+type LogReplayError struct {
+	// The PCR bank the log was replayed against
+	Bank tpmpb.HashAlgo
+	// The PCRs which failed to replay, in increasing order. It is empty if the
+	// log could not be parsed.
+	Mismatches []*PCRMismatchError
+	// The underlying error
+	Err error
+}
+
+func (e *LogReplayError) Error() string {
+	if len(e.Mismatches) == 0 {
+		return fmt.Sprintf("failed to replay event log against the %v PCRs: %v", e.Bank, e.Err)
+	}
+	indexes := make([]uint32, len(e.Mismatches))
+	for i, mismatch := range e.Mismatches {
+		indexes[i] = mismatch.Index
+	}
+	return fmt.Sprintf("failed to replay event log against the %v PCRs: PCRs %v do not match", e.Bank, indexes)
+}
+
+// Unwrap returns the first PCR mismatch, if there is one, so errors.As can
+// find it, and the underlying error otherwise.
+func (e *LogReplayError) Unwrap() error {
+	if len(e.Mismatches) != 0 {
+		return e.Mismatches[0]
+	}
+	return e.Err
+}
+
+// replayMismatches replays an event log which go-attestation failed to
+// verify, which does not report the replayed values, and returns the PCRs
+// which do not match.
+func replayMismatches(rawEventLog []byte, pcrs *tpmpb.PCRs) []*PCRMismatchError {
+	err := ReplayEventLog(bytes.NewReader(rawEventLog), pcrs, func(*pb.Event) error { return nil })
+	var replayErr *ReplayError
+	if !errors.As(err, &replayErr) {
+		return nil
+	}
+	mismatches := make([]*PCRMismatchError, len(replayErr.PCRs))
+	for i, index := range replayErr.PCRs {
+		mismatches[i] = &PCRMismatchError{
+			Index: uint32(index),
+			Got:   replayErr.Replayed[uint32(index)],
+			Want:  pcrs.GetPcrs()[uint32(index)],
+		}
+	}
+	return mismatches
+}
+
 func convertToAttestPcrs(pcrProto *tpmpb.PCRs) ([]attest.PCR, error) {
 	if len(pcrProto.GetPcrs()) == 0 {
 		return nil, errors.New("no PCRs to convert")
@@ -0,0 +1,601 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// maxStreamedEventSize limits the data of a single event read by
+// ReplayEventLog, as the log's total size is not known in advance.
+const maxStreamedEventSize = 1 << 24
+
+// Events which some firmware extends into PCR5 without logging them. If PCR5
+// does not replay, each of these sequences is tried in turn, matching the
+// workarounds used by ParseMachineState.
+var missingPCR5Events = [][]string{
+	{"Exit Boot Services Invocation", "Exit Boot Services Returned with Success"},
+	{"Exit Boot Services Invocation", "Exit Boot Services Returned with Failure"},
+	{"Exit Boot Services Invocation", "Exit Boot Services Returned with Failure", "Exit Boot Services Returned with Success"},
+}
+
+// ReplayOpts configures ReplayEventLogWithOpts.
+type ReplayOpts struct {
+	// Workers is the number of goroutines parsing events and verifying their
+	// digests. Defaults to runtime.GOMAXPROCS(0).
+	Workers int
+}
+
+// ReplayError is returned by ReplayEventLog and ReplayEventLogWithOpts when the
+// event log does not replay to the given PCR values.
+type ReplayError struct {
+	// The PCRs which failed to replay, in increasing order.
+	PCRs []int
+	// The values of these PCRs obtained by replaying the log. A PCR is
+	// missing if one of its events has no digest in the replayed bank.
+	Replayed map[uint32][]byte
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("failed to replay event log: event log failed to verify: the following registers failed to replay: %v", e.PCRs)
+}
+
+// ReplayEventLog is ReplayEventLogWithOpts with the default options.
+func ReplayEventLog(r io.Reader, pcrs *tpmpb.PCRs, handle func(*pb.Event) error) error {
+	return ReplayEventLogWithOpts(r, pcrs, ReplayOpts{}, handle)
+}
+
+// ReplayEventLogWithOpts reads a raw event log from r, and replays it against
+// the given PCR values, without holding the whole log in memory. Each event
+// extended into one of the PCRs is passed to handle, in the order of the log,
+// soon after it is read, so large logs (with many option ROMs or IMA entries)
+// can be processed with bounded memory. If handle returns an error, replay
+// stops and that error is returned. handle is only called from the calling
+// goroutine.
+//
+// The log is read in segments of events, whose digests are checked by a pool
+// of workers while the events of earlier segments are extended into the PCRs,
+// so the throughput on large logs scales with the number of workers. At most
+// two segments per worker are held in memory.
+//
+// The events passed to handle are not verified until ReplayEventLogWithOpts
+// returns nil. If an error is returned, the caller must discard any events it
+// was given. Like ParseMachineState, an error (a *ReplayError) is returned if
+// the replay for any PCR index does not match the provided value, and it is
+// the caller's responsibility to ensure the PCR values can be trusted.
+func ReplayEventLogWithOpts(r io.Reader, pcrs *tpmpb.PCRs, opts ReplayOpts, handle func(*pb.Event) error) error {
+	if len(pcrs.GetPcrs()) == 0 {
+		return fmt.Errorf("received bad PCR proto: no PCRs to replay")
+	}
+	alg := tpm2.Algorithm(pcrs.GetHash())
+	cryptoHash, err := alg.Hash()
+	if err != nil {
+		return fmt.Errorf("received bad PCR proto: %v", err)
+	}
+	replays := make(map[uint32]*pcrReplay, len(pcrs.GetPcrs()))
+	for index, digest := range pcrs.GetPcrs() {
+		replays[index] = &pcrReplay{expected: digest}
+	}
+	h := cryptoHash.New()
+
+	parser := &eventLogParser{r: bufio.NewReader(r)}
+	first, err := parser.first()
+	if err != nil {
+		return fmt.Errorf("failed to parse event log: %v", err)
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	segments, stop := parseSegments(parser, first, workers, cryptoHash, func(event *logEvent, h hash.Hash) *pb.Event {
+		return checkEvent(replays, alg, h, event)
+	})
+	defer stop()
+	for seg := range segments {
+		<-seg.ready
+		for _, event := range seg.events {
+			if err := replayEvent(replays, h, event, handle); err != nil {
+				return err
+			}
+		}
+		if seg.err != nil {
+			return fmt.Errorf("failed to parse event log: %v", seg.err)
+		}
+	}
+
+	var invalid []int
+	for index, replay := range replays {
+		if !replay.matches() {
+			invalid = append(invalid, int(index))
+		}
+	}
+	sort.Ints(invalid)
+	// Only PCR5 can be fixed by adding the missing events, and only if it
+	// is the only PCR which does not replay.
+	var missing []*pb.Event
+	if len(invalid) == 1 && invalid[0] == 5 {
+		if missing = replays[5].missingEvents(h); missing != nil {
+			invalid = nil
+		}
+	}
+	if len(invalid) != 0 {
+		replayed := make(map[uint32][]byte, len(invalid))
+		for _, index := range invalid {
+			if replay := replays[uint32(index)]; !replay.failed {
+				replayed[uint32(index)] = replay.current
+			}
+		}
+		return &ReplayError{PCRs: invalid, Replayed: replayed}
+	}
+	for _, event := range missing {
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segmentEvents is the number of events in each segment of the log, which is
+// large enough to amortize the cost of passing segments between goroutines.
+const segmentEvents = 512
+
+// segment is a run of consecutive events from the log.
+type segment struct {
+	raw []*logEvent
+	// The checked events, set before ready is closed. Events which are not
+	// replayed are nil.
+	events []*pb.Event
+	ready  chan struct{}
+	// Set if parsing the log failed after the segment's events.
+	err error
+}
+
+// parseSegments reads the events of the log after first, sending segments of
+// them (in order) on the returned channel, which is closed after the last
+// segment. Each segment's events are checked by one of the workers, which
+// closes the segment's ready channel when done. The stop function must be
+// called once the caller has stopped receiving segments, and waits for the
+// goroutines to exit.
+func parseSegments(parser *eventLogParser, first *logEvent, workers int, cryptoHash crypto.Hash,
+	check func(*logEvent, hash.Hash) *pb.Event) (<-chan *segment, func()) {
+	ordered := make(chan *segment, 2*workers)
+	work := make(chan *segment)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(ordered)
+		defer close(work)
+		event := first
+		for event != nil {
+			seg := &segment{ready: make(chan struct{})}
+			for event != nil && len(seg.raw) < segmentEvents {
+				seg.raw = append(seg.raw, event)
+				if event, seg.err = parser.next(); seg.err != nil {
+					event = nil
+				}
+			}
+			// Segments are queued for the caller before the workers, so the
+			// caller receives them in order.
+			select {
+			case ordered <- seg:
+			case <-done:
+				return
+			}
+			select {
+			case work <- seg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			h := cryptoHash.New()
+			for seg := range work {
+				seg.events = make([]*pb.Event, len(seg.raw))
+				for i, event := range seg.raw {
+					seg.events[i] = check(event, h)
+				}
+				seg.raw = nil
+				close(seg.ready)
+			}
+		}()
+	}
+
+	return ordered, func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// ParseMachineStateFromReader is like ParseMachineState, but reads the raw
+// event log from r using ReplayEventLog. It avoids the intermediate copies of
+// the log made by ParseMachineState, though the returned MachineState still
+// contains every replayed event.
+func ParseMachineStateFromReader(r io.Reader, pcrs *tpmpb.PCRs) (*pb.MachineState, error) {
+	var rawEvents []*pb.Event
+	err := ReplayEventLog(r, pcrs, func(event *pb.Event) error {
+		rawEvents = append(rawEvents, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return machineStateFromEvents(pcrs.GetHash(), rawEvents), nil
+}
+
+// pcrReplay is the state of replaying the events of a single PCR.
+type pcrReplay struct {
+	expected []byte
+	// Value of the PCR after replaying the events so far, nil if no events
+	// have been replayed.
+	current  []byte
+	locality byte
+	// Set if an event could not be extended into the PCR.
+	failed bool
+}
+
+func (p *pcrReplay) extend(h hash.Hash, digest []byte) {
+	h.Reset()
+	if p.current != nil {
+		h.Write(p.current)
+	} else {
+		initial := make([]byte, h.Size())
+		initial[len(initial)-1] = p.locality
+		h.Write(initial)
+	}
+	h.Write(digest)
+	p.current = h.Sum(p.current[:0])
+}
+
+func (p *pcrReplay) matches() bool {
+	return !p.failed && (p.current == nil || bytes.Equal(p.current, p.expected))
+}
+
+// missingEvents returns the events which must be appended for the PCR to
+// replay, or nil if there are none.
+func (p *pcrReplay) missingEvents(h hash.Hash) []*pb.Event {
+	if p.failed {
+		return nil
+	}
+	for _, datas := range missingPCR5Events {
+		replay := *p
+		replay.current = append([]byte(nil), p.current...)
+		var events []*pb.Event
+		for _, data := range datas {
+			h.Reset()
+			h.Write([]byte(data))
+			digest := h.Sum(nil)
+			replay.extend(h, digest)
+			events = append(events, &pb.Event{PcrIndex: 5, Data: []byte(data), Digest: digest, DigestVerified: true})
+		}
+		if replay.matches() {
+			return events
+		}
+	}
+	return nil
+}
+
+// checkEvent converts an event to be replayed, verifying its digest matches its
+// data. It returns nil if the event's PCR is not replayed, and an event
+// without a digest if it has no digest of the right size.
+func checkEvent(replays map[uint32]*pcrReplay, alg tpm2.Algorithm, h hash.Hash, event *logEvent) *pb.Event {
+	replay, ok := replays[event.index]
+	if !ok {
+		return nil
+	}
+	checked := &pb.Event{
+		PcrIndex:      event.index,
+		UntrustedType: event.typ,
+		Data:          event.data,
+	}
+	if event.typ == NoAction {
+		return checked
+	}
+	digest := event.digest(alg)
+	if digest == nil || len(digest) != len(replay.expected) {
+		return checked
+	}
+	checked.Digest = digest
+	h.Reset()
+	h.Write(event.data)
+	var dataDigest [sha512.Size]byte
+	checked.DigestVerified = bytes.Equal(h.Sum(dataDigest[:0]), digest)
+	return checked
+}
+
+// replayEvent extends a checked event into its PCR, and passes it to handle.
+func replayEvent(replays map[uint32]*pcrReplay, h hash.Hash, event *pb.Event, handle func(*pb.Event) error) error {
+	if event == nil {
+		return nil
+	}
+	replay := replays[event.GetPcrIndex()]
+	// EV_NO_ACTION events are not extended. If TXT is enabled, the first
+	// event for PCR0 is a StartupLocality event, whose final byte is the
+	// locality TPM2_Startup() was issued from (the initial value of PCR0).
+	if event.GetUntrustedType() == NoAction {
+		data := event.GetData()
+		if event.GetPcrIndex() == 0 && len(data) == 17 && strings.HasPrefix(string(data), "StartupLocality") {
+			replay.locality = data[len(data)-1]
+		}
+		return nil
+	}
+	if replay.failed {
+		return nil
+	}
+	if event.GetDigest() == nil {
+		replay.failed = true
+		return nil
+	}
+	replay.extend(h, event.GetDigest())
+	return handle(event)
+}
+
+// logEvent is an unverified event read from a raw event log.
+type logEvent struct {
+	index   uint32
+	typ     uint32
+	data    []byte
+	digests []eventDigest
+}
+
+type eventDigest struct {
+	alg    tpm2.Algorithm
+	digest []byte
+}
+
+// digest returns the event's digest for the algorithm, or nil if it has none.
+func (e *logEvent) digest(alg tpm2.Algorithm) []byte {
+	for _, d := range e.digests {
+		if d.alg == alg {
+			return d.digest
+		}
+	}
+	return nil
+}
+
+// eventLogParser reads the events of a raw event log one at a time. The log
+// is either in the SHA-1 format, or in the crypto agile format, which starts
+// with a Spec ID event in the SHA-1 format. See "5 Event Logging" in the TCG
+// EFI Protocol Specification.
+type eventLogParser struct {
+	r       *bufio.Reader
+	scratch [8]byte
+	// Sizes of the digests of each algorithm, if the log is crypto agile.
+	digestSizes map[tpm2.Algorithm]uint16
+}
+
+// specIDHeader is the start of a TCG_EfiSpecIDEventStruct.
+type specIDHeader struct {
+	Signature     [16]byte
+	PlatformClass uint32
+	VersionMinor  uint8
+	VersionMajor  uint8
+	Errata        uint8
+	UintnSize     uint8
+	NumAlgs       uint32
+}
+
+var specIDSignature = [16]byte{'S', 'p', 'e', 'c', ' ', 'I', 'D', ' ', 'E', 'v', 'e', 'n', 't', '0', '3', 0}
+
+// first returns the first event of the log, switching to the crypto agile
+// format if it is a Spec ID event. In that case, the Spec ID event is not
+// returned, and the first crypto agile event is returned instead (or nil if
+// there are none).
+func (p *eventLogParser) first() (*logEvent, error) {
+	event, err := p.sha1Event()
+	if err == io.EOF {
+		return nil, fmt.Errorf("parse first event: %v", io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse first event: %v", err)
+	}
+	if event.typ != NoAction || len(event.data) < binary.Size(specIDHeader{}) {
+		return event, nil
+	}
+	if p.digestSizes, err = parseSpecID(event.data); err != nil {
+		return nil, fmt.Errorf("failed to parse spec ID event: %v", err)
+	}
+	return p.next()
+}
+
+// next returns the next event of the log, or nil at the end of the log.
+func (p *eventLogParser) next() (*logEvent, error) {
+	var event *logEvent
+	var err error
+	if p.digestSizes == nil {
+		event, err = p.sha1Event()
+	} else {
+		event, err = p.agileEvent()
+	}
+	if err == io.EOF {
+		return nil, nil
+	}
+	return event, err
+}
+
+// atEnd returns io.EOF if there is no more data, without consuming any.
+func (p *eventLogParser) atEnd() error {
+	if _, err := p.r.Peek(1); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readFull reads exactly len(data) bytes.
+func (p *eventLogParser) readFull(data []byte) error {
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *eventLogParser) readUint32() (uint32, error) {
+	if err := p.readFull(p.scratch[:4]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(p.scratch[:4]), nil
+}
+
+func (p *eventLogParser) readUint16() (uint16, error) {
+	if err := p.readFull(p.scratch[:2]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(p.scratch[:2]), nil
+}
+
+func (p *eventLogParser) readData(size uint32) ([]byte, error) {
+	if size > maxStreamedEventSize {
+		return nil, fmt.Errorf("event data size (%d bytes) exceeds the limit of %d bytes", size, maxStreamedEventSize)
+	}
+	data := make([]byte, size)
+	if err := p.readFull(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// sha1Event reads a TCG_PCR_EVENT.
+func (p *eventLogParser) sha1Event() (*logEvent, error) {
+	if err := p.atEnd(); err != nil {
+		return nil, err
+	}
+	event := &logEvent{}
+	var err error
+	if event.index, err = p.readUint32(); err != nil {
+		return nil, fmt.Errorf("header deserialization error: %w", err)
+	}
+	if event.typ, err = p.readUint32(); err != nil {
+		return nil, fmt.Errorf("header deserialization error: %w", err)
+	}
+	digest := make([]byte, sha1.Size)
+	if err := p.readFull(digest); err != nil {
+		return nil, fmt.Errorf("header deserialization error: %w", err)
+	}
+	event.digests = []eventDigest{{tpm2.AlgSHA1, digest}}
+	size, err := p.readUint32()
+	if err != nil {
+		return nil, fmt.Errorf("header deserialization error: %w", err)
+	}
+	if event.data, err = p.readData(size); err != nil {
+		return nil, fmt.Errorf("reading data error: %w", err)
+	}
+	return event, nil
+}
+
+// agileEvent reads a TCG_PCR_EVENT2.
+func (p *eventLogParser) agileEvent() (*logEvent, error) {
+	if err := p.atEnd(); err != nil {
+		return nil, err
+	}
+	event := &logEvent{}
+	var err error
+	if event.index, err = p.readUint32(); err != nil {
+		return nil, err
+	}
+	if event.typ, err = p.readUint32(); err != nil {
+		return nil, err
+	}
+	numDigests, err := p.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	// Each event has (at most) one digest for each of the log's algorithms,
+	// which also bounds the memory used for the digests.
+	if numDigests > uint32(len(p.digestSizes)) {
+		return nil, fmt.Errorf("event has %d digests, but the log only uses %d algorithms", numDigests, len(p.digestSizes))
+	}
+	event.digests = make([]eventDigest, numDigests)
+	for i := range event.digests {
+		algID, err := p.readUint16()
+		if err != nil {
+			return nil, err
+		}
+		size, ok := p.digestSizes[tpm2.Algorithm(algID)]
+		if !ok {
+			return nil, fmt.Errorf("unknown algorithm ID %x", algID)
+		}
+		digest, err := p.readData(uint32(size))
+		if err != nil {
+			return nil, err
+		}
+		event.digests[i] = eventDigest{tpm2.Algorithm(algID), digest}
+	}
+	eventSize, err := p.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if eventSize == 0 {
+		return nil, errors.New("event data size is 0")
+	}
+	if event.data, err = p.readData(eventSize); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// parseSpecID parses a TCG_EfiSpecIDEventStruct, returning the sizes of the
+// digests of each algorithm used by the log.
+func parseSpecID(data []byte) (map[tpm2.Algorithm]uint16, error) {
+	r := bytes.NewReader(data)
+	var header specIDHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("reading event header: %w", err)
+	}
+	if header.Signature != specIDSignature {
+		return nil, fmt.Errorf("invalid spec id signature: %x", header.Signature)
+	}
+	if header.VersionMajor != 2 || header.VersionMinor != 0 {
+		return nil, fmt.Errorf("invalid spec version %d.%d, wanted 2.0", header.VersionMajor, header.VersionMinor)
+	}
+	sizes := map[tpm2.Algorithm]uint16{}
+	usable := false
+	for i := uint32(0); i < header.NumAlgs; i++ {
+		var alg struct {
+			ID   uint16
+			Size uint16
+		}
+		if err := binary.Read(r, binary.LittleEndian, &alg); err != nil {
+			return nil, fmt.Errorf("reading algorithm: %v", err)
+		}
+		sizes[tpm2.Algorithm(alg.ID)] = alg.Size
+		if tpm2.Algorithm(alg.ID) == tpm2.AlgSHA1 || tpm2.Algorithm(alg.ID) == tpm2.AlgSHA256 {
+			usable = true
+		}
+	}
+	if !usable {
+		return nil, fmt.Errorf("measurement log didn't use sha1 or sha256 digests")
+	}
+	var vendorInfoSize uint8
+	if err := binary.Read(r, binary.LittleEndian, &vendorInfoSize); err != nil {
+		return nil, fmt.Errorf("reading vendor info size: %v", err)
+	}
+	if r.Len() != int(vendorInfoSize) {
+		return nil, fmt.Errorf("reading vendor info, expected %d remaining bytes, got %d", vendorInfoSize, r.Len())
+	}
+	return sizes, nil
+}
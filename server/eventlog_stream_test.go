@@ -0,0 +1,277 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-tpm-tools/internal/test"
+	attestpb "github.com/google/go-tpm-tools/proto/attest"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseMachineStateFromReader(t *testing.T) {
+	for _, log := range test.Platforms {
+		rawLog := log.RawLog
+		for _, bank := range log.Banks {
+			hashName := pb.HashAlgo_name[int32(bank.Hash)]
+			t.Run(fmt.Sprintf("%s-%s", log.Name, hashName), func(t *testing.T) {
+				want, err := ParseMachineState(rawLog, bank)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := ParseMachineStateFromReader(bytes.NewReader(rawLog), bank)
+				if err != nil {
+					t.Fatalf("failed to stream and replay log: %v", err)
+				}
+				if !proto.Equal(got, want) {
+					t.Errorf("ParseMachineStateFromReader() = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestReplayEventLogFailures(t *testing.T) {
+	rawLog := test.UbuntuAmdSevGCE.RawLog
+	bank := test.UbuntuAmdSevGCE.Banks[0]
+	wrongPCR := proto.Clone(bank).(*pb.PCRs)
+	for index := range wrongPCR.Pcrs {
+		wrongPCR.Pcrs[index] = make([]byte, len(wrongPCR.Pcrs[index]))
+		break
+	}
+	truncated := rawLog[:len(rawLog)-1]
+
+	testcases := []struct {
+		name   string
+		rawLog []byte
+		pcrs   *pb.PCRs
+	}{
+		{"WrongPCR", rawLog, wrongPCR},
+		{"NoPCRs", rawLog, &pb.PCRs{Hash: bank.Hash}},
+		{"Truncated", truncated, bank},
+		{"Empty", nil, bank},
+		{"Garbage", bytes.Repeat([]byte{0xff}, 64), bank},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			if _, err := ParseMachineStateFromReader(bytes.NewReader(testcase.rawLog), testcase.pcrs); err == nil {
+				t.Error("ParseMachineStateFromReader() should have failed")
+			}
+			if _, err := ParseMachineState(testcase.rawLog, testcase.pcrs); err == nil {
+				t.Error("ParseMachineState() should also have failed")
+			}
+		})
+	}
+}
+
+func TestReplayEventLogReplayError(t *testing.T) {
+	rawLog := test.UbuntuAmdSevGCE.RawLog
+	bank := test.UbuntuAmdSevGCE.Banks[0]
+	wrongPCRs := proto.Clone(bank).(*pb.PCRs)
+	wrongPCRs.Pcrs[4] = make([]byte, len(bank.Pcrs[4]))
+
+	err := ReplayEventLog(bytes.NewReader(rawLog), wrongPCRs, func(*attestpb.Event) error { return nil })
+	var replayErr *ReplayError
+	if !errors.As(err, &replayErr) {
+		t.Fatalf("ReplayEventLog() = %v, want a ReplayError", err)
+	}
+	if len(replayErr.PCRs) != 1 || replayErr.PCRs[0] != 4 {
+		t.Errorf("got mismatched PCRs %v, want [4]", replayErr.PCRs)
+	}
+	if !bytes.Equal(replayErr.Replayed[4], bank.Pcrs[4]) {
+		t.Errorf("got replayed PCR4 %x, want %x", replayErr.Replayed[4], bank.Pcrs[4])
+	}
+}
+
+func TestReplayEventLogHandlerError(t *testing.T) {
+	errStop := errors.New("stop")
+	events := 0
+	err := ReplayEventLog(bytes.NewReader(test.Rhel8GCE.RawLog), test.Rhel8GCE.Banks[0], func(*attestpb.Event) error {
+		events++
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("ReplayEventLog() = %v, want the handler's error", err)
+	}
+	if events != 1 {
+		t.Errorf("handler was called %d times after returning an error", events)
+	}
+}
+
+func TestReplayEventLogWorkers(t *testing.T) {
+	// Several segments, the last of them partial.
+	rawLog, pcrs := buildEventLog(t, 10, 3*segmentEvents+7, 64)
+	want, err := ParseMachineState(rawLog, pcrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 2, 8} {
+		t.Run(fmt.Sprintf("%dWorkers", workers), func(t *testing.T) {
+			var events []*attestpb.Event
+			err := ReplayEventLogWithOpts(bytes.NewReader(rawLog), pcrs, ReplayOpts{Workers: workers},
+				func(event *attestpb.Event) error {
+					events = append(events, event)
+					return nil
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := machineStateFromEvents(pcrs.GetHash(), events); !proto.Equal(got, want) {
+				t.Error("events were not replayed in order")
+			}
+
+			// Events before a parse error are handled, as when parsing
+			// sequentially.
+			handled := 0
+			truncated := rawLog[:len(rawLog)-1]
+			err = ReplayEventLogWithOpts(bytes.NewReader(truncated), pcrs, ReplayOpts{Workers: workers},
+				func(*attestpb.Event) error {
+					handled++
+					return nil
+				})
+			if err == nil {
+				t.Error("replaying a truncated log should fail")
+			}
+			if handled != len(events)-1 {
+				t.Errorf("handled %d events before the parse error, want %d", handled, len(events)-1)
+			}
+
+			// Stopping early does not leave the workers blocked.
+			errStop := errors.New("stop")
+			err = ReplayEventLogWithOpts(bytes.NewReader(rawLog), pcrs, ReplayOpts{Workers: workers},
+				func(*attestpb.Event) error { return errStop })
+			if err != errStop {
+				t.Errorf("ReplayEventLogWithOpts() = %v, want the handler's error", err)
+			}
+		})
+	}
+}
+
+// BenchmarkParseMachineState compares parsing a whole event log in memory with
+// streaming it, on a multi-megabyte log like those of servers with many option
+// ROMs or IMA entries. Run with -benchmem to see the difference in
+// allocations.
+func BenchmarkParseMachineState(b *testing.B) {
+	rawLog, pcrs := buildEventLog(b, 10, 10000, 512)
+
+	b.Run("Bytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := ParseMachineState(rawLog, pcrs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Reader", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := ParseMachineStateFromReader(bytes.NewReader(rawLog), pcrs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// Compare with ReplayOnly to see the speedup from the worker pool, which
+	// needs several CPUs.
+	b.Run("OneWorker", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := ReplayEventLogWithOpts(bytes.NewReader(rawLog), pcrs, ReplayOpts{Workers: 1},
+				func(*attestpb.Event) error { return nil }); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ReplayOnly", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := ReplayEventLog(bytes.NewReader(rawLog), pcrs, func(*attestpb.Event) error { return nil }); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestReplayEventLogLarge(t *testing.T) {
+	rawLog, pcrs := buildEventLog(t, 10, 1000, 512)
+	want, err := ParseMachineState(rawLog, pcrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseMachineStateFromReader(bytes.NewReader(rawLog), pcrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, want) {
+		t.Error("ParseMachineStateFromReader() and ParseMachineState() returned different states")
+	}
+}
+
+func TestReplayEventLogMissingExitBootServices(t *testing.T) {
+	for _, unlogged := range missingPCR5Events {
+		rawLog, pcrs := buildEventLog(t, 5, 3, 16, unlogged...)
+		want, err := ParseMachineState(rawLog, pcrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseMachineStateFromReader(bytes.NewReader(rawLog), pcrs)
+		if err != nil {
+			t.Fatalf("failed to stream and replay log missing %q: %v", unlogged, err)
+		}
+		if !proto.Equal(got, want) {
+			t.Errorf("ParseMachineStateFromReader() = %v, want %v", got, want)
+		}
+	}
+
+	// Only PCR5 is fixed up.
+	rawLog, pcrs := buildEventLog(t, 4, 3, 16, missingPCR5Events[0]...)
+	if _, err := ParseMachineStateFromReader(bytes.NewReader(rawLog), pcrs); err == nil {
+		t.Error("ParseMachineStateFromReader() should fail for events missing from PCR4")
+	}
+}
+
+// buildEventLog creates a crypto agile event log, with count events of
+// dataSize bytes extended into the PCR. The SHA-256 PCR value it replays to is
+// also returned, after extending the unlogged events.
+func buildEventLog(tb testing.TB, index uint32, count int, dataSize int, unlogged ...string) ([]byte, *pb.PCRs) {
+	tb.Helper()
+	var log bytes.Buffer
+	write := func(data ...interface{}) {
+		for _, d := range data {
+			if err := binary.Write(&log, binary.LittleEndian, d); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+
+	var specID bytes.Buffer
+	binary.Write(&specID, binary.LittleEndian, specIDHeader{Signature: specIDSignature, VersionMajor: 2, UintnSize: 2, NumAlgs: 2})
+	binary.Write(&specID, binary.LittleEndian, []uint16{uint16(tpm2.AlgSHA1), sha1.Size, uint16(tpm2.AlgSHA256), sha256.Size})
+	specID.WriteByte(0)
+	write(uint32(0), NoAction, [sha1.Size]byte{}, uint32(specID.Len()), specID.Bytes())
+
+	pcr := make([]byte, sha256.Size)
+	data := make([]byte, dataSize)
+	for i := 0; i < count; i++ {
+		binary.BigEndian.PutUint32(data, uint32(i))
+		sha1Digest := sha1.Sum(data)
+		sha256Digest := sha256.Sum256(data)
+		write(index, uint32(0xd), uint32(2),
+			uint16(tpm2.AlgSHA1), sha1Digest, uint16(tpm2.AlgSHA256), sha256Digest,
+			uint32(len(data)), data)
+		extended := sha256.Sum256(append(pcr, sha256Digest[:]...))
+		pcr = extended[:]
+	}
+	for _, data := range unlogged {
+		digest := sha256.Sum256([]byte(data))
+		extended := sha256.Sum256(append(pcr, digest[:]...))
+		pcr = extended[:]
+	}
+	return log.Bytes(), &pb.PCRs{Hash: pb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{index: pcr}}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// GRUB measures each command it executes into PCR8 as an EV_IPL event, with
+// the event data set to the command prefixed with one of grubCommandPrefixes,
+// and the digest only covering the command itself (with its NUL terminator for
+// Fedora and RHEL GRUB). The kernel and module command lines (see
+// grubCmdlinePrefixes) are measured the same way, but are not commands.
+var grubCommandPrefixes = [][]byte{
+	[]byte("grub_cmd: "), // Upstream GRUB
+	[]byte("grub_cmd "),  // Fedora and RHEL GRUB
+}
+
+// getGrubState returns the commands and files measured by GRUB, or nil if
+// there are none. It returns an error if a command does not match its digest,
+// as the command which was actually executed is then unknown.
+func getGrubState(hash crypto.Hash, events []*pb.Event) (*pb.GrubState, error) {
+	state := &pb.GrubState{}
+	for _, event := range events {
+		if event.GetUntrustedType() != ipl {
+			continue
+		}
+		data := bytes.TrimRight(event.GetData(), "\x00")
+		switch event.GetPcrIndex() {
+		case grubCmdlinePCR:
+			command, ok := trimAnyBytesPrefix(data, grubCommandPrefixes)
+			if !ok {
+				continue
+			}
+			if !grubCommandVerified(hash, command, event.GetDigest()) {
+				return nil, fmt.Errorf("GRUB command %q does not match its digest", command)
+			}
+			state.Commands = append(state.Commands, string(command))
+		case grubFilePCR:
+			state.Files = append(state.Files, &pb.GrubFile{Path: string(data), Digest: event.GetDigest()})
+		}
+	}
+	if len(state.Commands) == 0 && len(state.Files) == 0 {
+		return nil, nil
+	}
+	return state, nil
+}
+
+func grubCommandVerified(hash crypto.Hash, command, digest []byte) bool {
+	for _, terminator := range [][]byte{nil, {0}} {
+		hasher := hash.New()
+		hasher.Write(command)
+		hasher.Write(terminator)
+		if bytes.Equal(hasher.Sum(nil), digest) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimAnyBytesPrefix(data []byte, prefixes [][]byte) ([]byte, bool) {
+	for _, prefix := range prefixes {
+		if bytes.HasPrefix(data, prefix) {
+			return data[len(prefix):], true
+		}
+	}
+	return nil, false
+}
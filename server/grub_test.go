@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+func TestGetGrubState(t *testing.T) {
+	subtests := []struct {
+		platform    test.Platform
+		numCommands int
+		numFiles    int
+		lastCommand string
+		lastFile    string
+	}{
+		{test.Rhel8GCE, 49, 2, "initrd (hd0,gpt2)/boot/initramfs-4.18.0-240.22.1.el8_3.x86_64.img", rhelGrubInitrd},
+		{test.UbuntuAmdSevGCE, 51, 10, "initrd /boot/initrd.img-5.4.0-1046-gcp", "/boot/initrd.img-5.4.0-1046-gcp"},
+		{test.Ubuntu2104NoDbxGCE, 72, 9, "save_env initrdfail", "/boot/vmlinuz-5.11.0-1008-gcp"},
+		{test.Ubuntu2104NoSecureBootGCE, 66, 9, "save_env initrdfail", "/boot/vmlinuz-5.11.0-1006-gcp"},
+		{test.GlinuxNoSecureBootLaptop, 0, 0, "", ""},
+		{test.ArchLinuxWorkstation, 0, 0, "", ""},
+		{test.Debian10GCE, 0, 0, "", ""},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.platform.Name, func(t *testing.T) {
+			grub := parseTestMachineState(t, subtest.platform).GetGrub()
+			if subtest.numCommands == 0 && subtest.numFiles == 0 {
+				if grub != nil {
+					t.Errorf("got GRUB state %v, want nil", grub)
+				}
+				return
+			}
+			commands, files := grub.GetCommands(), grub.GetFiles()
+			if len(commands) != subtest.numCommands || len(files) != subtest.numFiles {
+				t.Fatalf("got %d commands and %d files, want %d and %d", len(commands), len(files), subtest.numCommands, subtest.numFiles)
+			}
+			if got := commands[len(commands)-1]; got != subtest.lastCommand {
+				t.Errorf("got last command %q, want %q", got, subtest.lastCommand)
+			}
+			if got := files[len(files)-1].GetPath(); got != subtest.lastFile {
+				t.Errorf("got last file %q, want %q", got, subtest.lastFile)
+			}
+		})
+	}
+}
+
+func TestGetGrubStateInvalidCommand(t *testing.T) {
+	digest := sha256.Sum256([]byte("insmod tpm"))
+	events := []*pb.Event{{
+		PcrIndex:      grubCmdlinePCR,
+		UntrustedType: ipl,
+		Data:          []byte("grub_cmd: insmod evil\x00"),
+		Digest:        digest[:],
+	}}
+	if grub, err := getGrubState(crypto.SHA256, events); err == nil {
+		t.Errorf("getGrubState() = %v, expected an error", grub)
+	}
+
+	events[0].Data = []byte("grub_cmd: insmod tpm\x00")
+	grub, err := getGrubState(crypto.SHA256, events)
+	if err != nil {
+		t.Fatalf("getGrubState() failed: %v", err)
+	}
+	if got := grub.GetCommands(); len(got) != 1 || got[0] != "insmod tpm" {
+		t.Errorf("got commands %q, want [\"insmod tpm\"]", got)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
@@ -18,6 +19,7 @@ import (
 
 	"github.com/google/go-tpm-tools/client"
 	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/policy"
 	pb "github.com/google/go-tpm-tools/proto/tpm"
 )
 
@@ -55,24 +57,89 @@ func CreateSigningKeyImportBlob(ekPub crypto.PublicKey, signingKey crypto.Privat
 	return createImportBlobHelper(ek, public, private, pcrs)
 }
 
-func createImportBlobHelper(ek, public tpm2.Public, private tpm2.Private, pcrs *pb.PCRs) (*pb.ImportBlob, error) {
-	setPublicAuth(&public, pcrs)
+// CreateImportBlobForParent is like CreateImportBlob, but encrypts the
+// sensitive data to any storage key of a remote TPM, given its public area
+// (such as an SRK's, from client.Key.PublicArea, or an EK's with a non-default
+// template). This lets a server provision a secret for a machine it does not
+// trust, without the secret ever being on that machine outside of its TPM:
+// the returned ImportBlob can only be imported (with the parent's
+// client.Key.Import) by the TPM holding the parent's private key, and only
+// while its PCRs have the values in pcrs, if non-nil.
+//
+// The parent must be a restricted decryption key using AES in CFB mode, as
+// storage keys do. The server should only trust a parent which it knows to be
+// resident in the remote TPM, for example one certified by a trusted AK (see
+// VerifyStorageKeyCertification), or the EK of a verified EK certificate.
+func CreateImportBlobForParent(parent tpm2.Public, sensitive []byte, pcrs *pb.PCRs) (*pb.ImportBlob, error) {
+	if err := checkImportParent(parent); err != nil {
+		return nil, err
+	}
+	private := createPrivate(sensitive)
+	public := createPublic(private)
 
-	var seed, encryptedSeed []byte
-	var err error
-	switch ek.Type {
+	return createImportBlobHelper(parent, public, private, pcrs)
+}
+
+// VerifyStorageKeyCertification is like VerifyKeyCertification, but also
+// checks that the certified key is a storage key which was generated by, and
+// can never leave, the TPM, so that blobs created for it by
+// CreateImportBlobForParent can only be imported by that TPM. It returns the
+// certified public area, to pass to CreateImportBlobForParent.
+func VerifyStorageKeyCertification(certification *pb.KeyCertification, trustedAK crypto.PublicKey, extraData []byte) (tpm2.Public, error) {
+	pub, err := VerifyKeyCertification(certification, trustedAK, extraData)
+	if err != nil {
+		return tpm2.Public{}, err
+	}
+	if pub.Attributes&storageKeyRequiredAttributes != storageKeyRequiredAttributes {
+		return tpm2.Public{}, fmt.Errorf("certified key is not a TPM-resident storage key: attributes %#x do not include %#x",
+			uint32(pub.Attributes), uint32(storageKeyRequiredAttributes))
+	}
+	if err := checkImportParent(pub); err != nil {
+		return tpm2.Public{}, err
+	}
+	return pub, nil
+}
+
+// The attributes which a storage key must have, so its private key was
+// generated by, and can never leave, the TPM.
+const storageKeyRequiredAttributes = tpm2.FlagFixedTPM | tpm2.FlagFixedParent | tpm2.FlagSensitiveDataOrigin |
+	tpm2.FlagRestricted | tpm2.FlagDecrypt
+
+// checkImportParent checks that objects can be imported under a key.
+func checkImportParent(parent tpm2.Public) error {
+	if parent.Attributes&(tpm2.FlagRestricted|tpm2.FlagDecrypt|tpm2.FlagSign) != tpm2.FlagRestricted|tpm2.FlagDecrypt {
+		return fmt.Errorf("parent is not a storage key: attributes %#x", uint32(parent.Attributes))
+	}
+	if _, err := parent.NameAlg.Hash(); err != nil {
+		return fmt.Errorf("unsupported parent name algorithm: %w", err)
+	}
+	var symmetric *tpm2.SymScheme
+	switch parent.Type {
 	case tpm2.AlgRSA:
-		seed, encryptedSeed, err = createRSASeed(ek)
-		if err != nil {
-			return nil, err
+		if parent.RSAParameters != nil {
+			symmetric = parent.RSAParameters.Symmetric
 		}
 	case tpm2.AlgECC:
-		seed, encryptedSeed, err = createECCSeed(ek)
-		if err != nil {
-			return nil, err
+		if parent.ECCParameters != nil {
+			symmetric = parent.ECCParameters.Symmetric
 		}
 	default:
-		return nil, fmt.Errorf("unsupported EK type: %v", ek.Type)
+		return fmt.Errorf("unsupported parent type: %v", parent.Type)
+	}
+	if symmetric == nil || symmetric.Alg != tpm2.AlgAES || symmetric.Mode != tpm2.AlgCFB {
+		return errors.New("parent's symmetric algorithm must be AES in CFB mode")
+	}
+	return nil
+}
+
+func createImportBlobHelper(ek, public tpm2.Public, private tpm2.Private, pcrs *pb.PCRs) (*pb.ImportBlob, error) {
+	if err := setPublicAuth(&public, pcrs); err != nil {
+		return nil, err
+	}
+
+	seed, encryptedSeed, err := createSeed(ek, "DUPLICATE")
+	if err != nil {
+		return nil, err
 	}
 	duplicate, err := createDuplicate(private, seed, public, ek)
 	if err != nil {
@@ -91,18 +158,36 @@ func createImportBlobHelper(ek, public tpm2.Public, private tpm2.Private, pcrs *
 	}, nil
 }
 
-func setPublicAuth(public *tpm2.Public, pcrs *pb.PCRs) {
+func setPublicAuth(public *tpm2.Public, pcrs *pb.PCRs) error {
 	if len(pcrs.GetPcrs()) == 0 {
 		// Allow password authorization so we can use a nil AuthPolicy.
 		public.AuthPolicy = nil
 		public.Attributes |= tpm2.FlagUserWithAuth
-	} else {
-		public.AuthPolicy = internal.PCRSessionAuth(pcrs, client.SessionHashAlg)
-		public.Attributes |= tpm2.FlagAdminWithPolicy
+		return nil
+	}
+	auth, err := internal.PCRSessionAuth(pcrs, client.SessionHashAlg)
+	if err != nil {
+		return err
+	}
+	public.AuthPolicy = auth
+	public.Attributes |= tpm2.FlagAdminWithPolicy
+	return nil
+}
+
+func createSeed(ek tpm2.Public, label string) (seed, encryptedSeed []byte, err error) {
+	switch ek.Type {
+	case tpm2.AlgRSA:
+		return createRSASeed(ek, label)
+	case tpm2.AlgECC:
+		return createECCSeed(ek, label)
+	default:
+		return nil, nil, fmt.Errorf("unsupported EK type: %v", ek.Type)
 	}
 }
 
-func createRSASeed(ek tpm2.Public) (seed, encryptedSeed []byte, err error) {
+// createRSASeed creates a random seed, and encrypts it to the EK using the
+// provided label ("DUPLICATE" for imports, "IDENTITY" for credentials).
+func createRSASeed(ek tpm2.Public, label string) (seed, encryptedSeed []byte, err error) {
 	seedSize := ek.RSAParameters.Symmetric.KeyBits / 8
 	seed = make([]byte, seedSize)
 	if _, err := io.ReadFull(rand.Reader, seed); err != nil {
@@ -118,7 +203,7 @@ func createRSASeed(ek tpm2.Public) (seed, encryptedSeed []byte, err error) {
 		rand.Reader,
 		ekPub.(*rsa.PublicKey),
 		seed,
-		[]byte("DUPLICATE\x00"))
+		append([]byte(label), 0))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -126,7 +211,10 @@ func createRSASeed(ek tpm2.Public) (seed, encryptedSeed []byte, err error) {
 	return seed, encryptedSeed, err
 }
 
-func createECCSeed(ek tpm2.Public) (seed, encryptedSeed []byte, err error) {
+// createECCSeed derives a seed from an ephemeral ECDH exchange with the EK,
+// using the provided label ("DUPLICATE" for imports, "IDENTITY" for
+// credentials).
+func createECCSeed(ek tpm2.Public, label string) (seed, encryptedSeed []byte, err error) {
 	curve, err := curveIDToGoCurve(ek.ECCParameters.CurveID)
 	if err != nil {
 		return nil, nil, err
@@ -142,7 +230,7 @@ func createECCSeed(ek tpm2.Public) (seed, encryptedSeed []byte, err error) {
 	seed, err = tpm2.KDFe(
 		ek.NameAlg,
 		eccIntToBytes(curve, z),
-		"DUPLICATE",
+		label,
 		xBytes,
 		eccIntToBytes(curve, ekPoint.X()),
 		getHash(ek.NameAlg).Size()*8)
@@ -154,7 +242,7 @@ func createECCSeed(ek tpm2.Public) (seed, encryptedSeed []byte, err error) {
 }
 
 func createDuplicate(private tpm2.Private, seed []byte, public, ek tpm2.Public) ([]byte, error) {
-	nameEncoded, err := getEncodedName(public)
+	nameEncoded, err := policy.ObjectName(public)
 	if err != nil {
 		return nil, err
 	}
@@ -162,6 +250,12 @@ func createDuplicate(private tpm2.Private, seed []byte, public, ek tpm2.Public)
 	if err != nil {
 		return nil, err
 	}
+	return createIDObject(secret, seed, nameEncoded, ek)
+}
+
+// createIDObject encrypts and integrity protects a secret, binding it to the
+// object with the provided name. The result is encoded as a TPMS_ID_OBJECT.
+func createIDObject(secret, seed, nameEncoded []byte, ek tpm2.Public) ([]byte, error) {
 	packedSecret, err := tpmutil.Pack(tpmutil.U16Bytes(secret))
 	if err != nil {
 		return nil, err
@@ -180,14 +274,6 @@ func createDuplicate(private tpm2.Private, seed []byte, public, ek tpm2.Public)
 	})
 }
 
-func getEncodedName(public tpm2.Public) ([]byte, error) {
-	name, err := public.Name()
-	if err != nil {
-		return nil, err
-	}
-	return name.Digest.Encode()
-}
-
 func encryptSecret(secret, seed, nameEncoded []byte, ek tpm2.Public) ([]byte, error) {
 	var symSize int
 	switch ek.Type {
@@ -215,7 +301,7 @@ func encryptSecret(secret, seed, nameEncoded []byte, ek tpm2.Public) ([]byte, er
 	}
 	encSecret := make([]byte, len(secret))
 	// The TPM spec requires an all-zero IV.
-	iv := make([]byte, len(symmetricKey))
+	iv := make([]byte, c.BlockSize())
 	cipher.NewCFBEncrypter(c, iv).XORKeyStream(encSecret, secret)
 	return encSecret, nil
 }
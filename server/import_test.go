@@ -12,6 +12,7 @@ import (
 	"github.com/google/go-tpm-tools/internal/test"
 	pb "github.com/google/go-tpm-tools/proto/tpm"
 	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
 )
 
 func TestImport(t *testing.T) {
@@ -247,3 +248,119 @@ func TestSigningKeyImport(t *testing.T) {
 		})
 	}
 }
+
+func TestImportForParent(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	// A storage key with a stronger name and symmetric algorithm than the
+	// default templates, whose parameters are only known from its public area.
+	strongSRK := client.SRKTemplateECC()
+	strongSRK.NameAlg = tpm2.AlgSHA384
+	strongSRK.ECCParameters.CurveID = tpm2.CurveNISTP384
+	strongSRK.ECCParameters.Symmetric = &tpm2.SymScheme{Alg: tpm2.AlgAES, KeyBits: 256, Mode: tpm2.AlgCFB}
+	parents := []struct {
+		name      string
+		hierarchy tpmutil.Handle
+		template  tpm2.Public
+	}{
+		{"SRK-RSA", tpm2.HandleOwner, client.SRKTemplateRSA()},
+		{"SRK-ECC-P384-AES256", tpm2.HandleOwner, strongSRK},
+		{"HighRangeEK-RSA", tpm2.HandleEndorsement, client.HighRangeEKTemplateRSA()},
+	}
+	pcr0, err := tpm2.ReadPCR(rwc, 0, tpm2.AlgSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcrs := &pb.PCRs{Hash: pb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{0: pcr0}}
+	for _, p := range parents {
+		t.Run(p.name, func(t *testing.T) {
+			parent, err := client.NewKey(rwc, p.hierarchy, p.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer parent.Close()
+			secret := []byte("super secret code")
+			blob, err := CreateImportBlobForParent(parent.PublicArea(), secret, pcrs)
+			if err != nil {
+				t.Fatalf("CreateImportBlobForParent() failed: %v", err)
+			}
+			output, err := parent.Import(blob)
+			if err != nil {
+				t.Fatalf("import failed: %v", err)
+			}
+			if !bytes.Equal(output, secret) {
+				t.Errorf("got %X, expected %X", output, secret)
+			}
+		})
+	}
+
+	if _, err := CreateImportBlobForParent(client.AKTemplateRSA(), []byte("secret"), nil); err == nil {
+		t.Error("CreateImportBlobForParent() with a signing key succeeded, want error")
+	}
+	unrestricted := client.SRKTemplateRSA()
+	unrestricted.Attributes &^= tpm2.FlagRestricted
+	if _, err := CreateImportBlobForParent(unrestricted, []byte("secret"), nil); err == nil {
+		t.Error("CreateImportBlobForParent() with an unrestricted key succeeded, want error")
+	}
+}
+
+func TestSealToCertifiedSRK(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	srk, err := client.StorageRootKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srk.Close()
+
+	// The server only learns the SRK from its certification by a trusted AK.
+	nonce := []byte("super secret nonce")
+	certification, err := ak.Certify(srk, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := VerifyStorageKeyCertification(certification, ak.PublicKey(), nonce)
+	if err != nil {
+		t.Fatalf("VerifyStorageKeyCertification() of the SRK failed: %v", err)
+	}
+	pcr, err := tpm2.ReadPCR(rwc, test.DebugPCR, tpm2.AlgSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("provisioned secret")
+	blob, err := CreateImportBlobForParent(parent, secret, &pb.PCRs{Hash: pb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{uint32(test.DebugPCR): pcr}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := srk.Import(blob)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if !bytes.Equal(output, secret) {
+		t.Errorf("got %X, expected %X", output, secret)
+	}
+
+	// Once the PCR changes, the blob can no longer be imported.
+	if err := tpm2.PCRExtend(rwc, tpmutil.Handle(test.DebugPCR), tpm2.AlgSHA256, bytes.Repeat([]byte{1}, 32), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srk.Import(blob); err == nil {
+		t.Error("import after the PCR changed succeeded, want error")
+	}
+
+	// A key which is not a TPM-resident storage key is rejected.
+	certification, err = ak.Certify(ak, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyStorageKeyCertification(certification, ak.PublicKey(), nonce); err == nil {
+		t.Error("VerifyStorageKeyCertification() of an AK succeeded, want error")
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-tpm-tools/cel"
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrKeyJournalRewritten is returned by KeyJournalExtends when a machine's key
+// history does not extend the history in an earlier attestation.
+var ErrKeyJournalRewritten = errors.New("key journal does not extend the previous attestation's history")
+
+// verifyKeyJournal checks that a key journal's NV index was certified by the
+// AK over the attestation's extraData, that it is the expected index (or
+// cel.KeyJournalIndex if zero) and can only be written by the owner, and that
+// its log replays to the certified contents, returning the journal's events.
+// Each record's content is checked against its digest by the replay, so the
+// events cannot be forged without changing the contents.
+func verifyKeyJournal(journal *pb.KeyJournal, ak crypto.PublicKey, extraData []byte, expectedIndex uint32) ([]*pb.KeyLifecycleEvent, error) {
+	certified, err := VerifyNVCertification(journal.GetCertification(), ak, extraData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key journal certification: %w", err)
+	}
+	if err := checkKeyJournalIndex(certified.Public, expectedIndex); err != nil {
+		return nil, err
+	}
+	index := uint32(certified.Public.NVIndex)
+	hashAlgo, err := certified.Public.NameAlg.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("key journal NV index 0x%x: %w", index, err)
+	}
+	log, err := cel.DecodeToCEL(bytes.NewBuffer(journal.GetLog()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key journal log: %w", err)
+	}
+	if err := log.ReplayNV(index, hashAlgo, certified.Data); err != nil {
+		return nil, err
+	}
+
+	var events []*pb.KeyLifecycleEvent
+	for _, record := range log.Records {
+		if record.NVIndex != index {
+			return nil, fmt.Errorf("key journal record %d was not extended into NV index 0x%x", record.RecNum, index)
+		}
+		if record.Content.Type != cel.KeyEventType {
+			continue
+		}
+		event, err := cel.ParseKeyEvent(record.Content)
+		if err != nil {
+			return nil, fmt.Errorf("key journal record %d: %w", record.RecNum, err)
+		}
+		events = append(events, &pb.KeyLifecycleEvent{
+			Action:       pb.KeyAction(event.Action),
+			Name:         event.Name,
+			Handle:       event.Handle,
+			Label:        event.Label,
+			PreviousName: event.PreviousName,
+		})
+	}
+	return events, nil
+}
+
+// checkKeyJournalIndex checks that a certified NV index can hold a trusted key
+// journal. Any user could extend a made-up history into an index with an
+// authValue or policy they chose, so only the owner must be able to write it
+// (as with the indexes defined by cel.LoadKeyJournal).
+func checkKeyJournalIndex(public tpm2.NVPublic, expectedIndex uint32) error {
+	if expectedIndex == 0 {
+		expectedIndex = cel.KeyJournalIndex
+	}
+	index := uint32(public.NVIndex)
+	if index != expectedIndex {
+		return fmt.Errorf("key journal NV index 0x%x is not the expected index 0x%x", index, expectedIndex)
+	}
+	if public.Attributes&internal.NVTypeMask != internal.NVTypeExtend {
+		return fmt.Errorf("key journal NV index 0x%x is not an extend index", index)
+	}
+	if public.Attributes&tpm2.AttrOwnerWrite == 0 || public.Attributes&(tpm2.AttrAuthWrite|tpm2.AttrPolicyWrite) != 0 {
+		return fmt.Errorf("key journal NV index 0x%x can be written by others than the TPM owner (attributes 0x%x)", index, uint32(public.Attributes))
+	}
+	return nil
+}
+
+// KeyJournalExtends checks that the key history in a machine's current
+// MachineState starts with the history in an earlier one, returning an error
+// wrapping ErrKeyJournalRewritten if it does not. The TPM owner can delete and
+// redefine a key journal's NV index, restarting its history, so verifiers
+// should keep the last history seen for each machine, and check each new one
+// against it. A TPM clear (see DetectTPMClear) also deletes the journal.
+func KeyJournalExtends(previous, current *pb.MachineState) error {
+	was, is := previous.GetKeyEvents(), current.GetKeyEvents()
+	if len(is) < len(was) {
+		return fmt.Errorf("%w: it has %d events, previously %d", ErrKeyJournalRewritten, len(is), len(was))
+	}
+	for i, event := range was {
+		if !proto.Equal(event, is[i]) {
+			return fmt.Errorf("%w: event %d changed", ErrKeyJournalRewritten, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+
+	"github.com/google/go-tpm-tools/cel"
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestVerifyKeyJournal(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	defer tpm2.NVUndefineSpace(rwc, "", tpm2.HandleOwner, tpmutil.Handle(cel.KeyJournalIndex))
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	akName, err := ak.Name().Digest.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal, err := cel.LoadKeyJournal(rwc, cel.KeyJournalIndex, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Record(cel.KeyEvent{Action: cel.KeyCreated, Name: akName, Label: "ak"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Record(cel.KeyEvent{Action: cel.KeyPersisted, Name: akName, Handle: 0x81000001, Label: "ak"}); err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := []byte("super secret nonce")
+	opts := VerifyOpts{Nonce: nonce, TrustedAKs: []crypto.PublicKey{ak.PublicKey()}}
+	attest := func() *pb.Attestation {
+		t.Helper()
+		var attestation *pb.Attestation
+		if err := journal.WithLog(func(log *cel.CEL) error {
+			var buf bytes.Buffer
+			if err := log.EncodeCEL(&buf); err != nil {
+				return err
+			}
+			var err error
+			attestation, err = ak.Attest(client.AttestOpts{Nonce: nonce, KeyJournalIndex: cel.KeyJournalIndex, KeyJournalLog: buf.Bytes()})
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return attestation
+	}
+
+	earlier, err := VerifyAttestation(attest(), opts)
+	if err != nil {
+		t.Fatalf("VerifyAttestation() with a key journal failed: %v", err)
+	}
+	want := []*pb.KeyLifecycleEvent{
+		{Action: pb.KeyAction_KEY_CREATED, Name: akName, Label: "ak"},
+		{Action: pb.KeyAction_KEY_PERSISTED, Name: akName, Handle: 0x81000001, Label: "ak"},
+	}
+	if got := earlier.GetKeyEvents(); !proto.Equal(&pb.MachineState{KeyEvents: got}, &pb.MachineState{KeyEvents: want}) {
+		t.Errorf("key events = %v, want %v", got, want)
+	}
+
+	if err := journal.Record(cel.KeyEvent{Action: cel.KeyEvicted, Name: akName, Handle: 0x81000001}); err != nil {
+		t.Fatal(err)
+	}
+	attestation := attest()
+	later, err := VerifyAttestation(attestation, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := KeyJournalExtends(earlier, later); err != nil {
+		t.Errorf("KeyJournalExtends() of a longer history failed: %v", err)
+	}
+	if err := KeyJournalExtends(later, earlier); !errors.Is(err, ErrKeyJournalRewritten) {
+		t.Errorf("KeyJournalExtends() of a shorter history = %v, want ErrKeyJournalRewritten", err)
+	}
+	rewritten := proto.Clone(later).(*pb.MachineState)
+	rewritten.KeyEvents[0].Label = "tls"
+	if err := KeyJournalExtends(earlier, rewritten); !errors.Is(err, ErrKeyJournalRewritten) {
+		t.Errorf("KeyJournalExtends() of a changed history = %v, want ErrKeyJournalRewritten", err)
+	}
+
+	// Dropping the last record of the log no longer replays to the index.
+	log, err := cel.DecodeToCEL(bytes.NewBuffer(attestation.GetKeyJournal().GetLog()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Forging a record's content, while keeping the digests which replay,
+	// is detected.
+	forged, err := cel.KeyEvent{Action: cel.KeyCreated, Name: akName, Label: "tls"}.GetTLV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	honest := log.Records[0].Content
+	log.Records[0].Content = forged
+	var forgedLog bytes.Buffer
+	if err := log.EncodeCEL(&forgedLog); err != nil {
+		t.Fatal(err)
+	}
+	honestLog := attestation.KeyJournal.Log
+	attestation.KeyJournal.Log = forgedLog.Bytes()
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("VerifyAttestation() with a forged key journal record succeeded, want error")
+	}
+	attestation.KeyJournal.Log = honestLog
+	log.Records[0].Content = honest
+
+	log.Records = log.Records[:len(log.Records)-1]
+	var truncated bytes.Buffer
+	if err := log.EncodeCEL(&truncated); err != nil {
+		t.Fatal(err)
+	}
+	attestation.KeyJournal.Log = truncated.Bytes()
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("VerifyAttestation() with a truncated key journal succeeded, want error")
+	}
+	// The certification is bound to the nonce.
+	var buf bytes.Buffer
+	if err := journal.Log.EncodeCEL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := ak.Attest(client.AttestOpts{Nonce: []byte("another nonce"), KeyJournalIndex: cel.KeyJournalIndex, KeyJournalLog: buf.Bytes()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation = attest()
+	attestation.KeyJournal = replayed.GetKeyJournal()
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("VerifyAttestation() with a key journal certified over another nonce succeeded, want error")
+	}
+}
+
+func TestCheckKeyJournalIndex(t *testing.T) {
+	const other = cel.KeyJournalIndex + 1
+	ownerWrite := internal.NVTypeExtend | tpm2.AttrOwnerWrite | tpm2.AttrOwnerRead | tpm2.AttrAuthRead | tpm2.AttrNoDA
+	for _, subtest := range []struct {
+		name          string
+		index         uint32
+		attributes    tpm2.NVAttr
+		expectedIndex uint32
+		wantErr       bool
+	}{
+		{"DefaultIndex", cel.KeyJournalIndex, ownerWrite, 0, false},
+		{"ExpectedIndex", other, ownerWrite, other, false},
+		{"UnexpectedIndex", other, ownerWrite, 0, true},
+		{"NotExtend", cel.KeyJournalIndex, ownerWrite &^ internal.NVTypeMask, 0, true},
+		{"NoOwnerWrite", cel.KeyJournalIndex, ownerWrite &^ tpm2.AttrOwnerWrite, 0, true},
+		{"AuthWrite", cel.KeyJournalIndex, ownerWrite | tpm2.AttrAuthWrite, 0, true},
+		{"PolicyWrite", cel.KeyJournalIndex, ownerWrite | tpm2.AttrPolicyWrite, 0, true},
+	} {
+		t.Run(subtest.name, func(t *testing.T) {
+			public := tpm2.NVPublic{NVIndex: tpmutil.Handle(subtest.index), NameAlg: tpm2.AlgSHA256, Attributes: subtest.attributes}
+			if err := checkKeyJournalIndex(public, subtest.expectedIndex); (err != nil) != subtest.wantErr {
+				t.Errorf("checkKeyJournalIndex() = %v, want error: %v", err, subtest.wantErr)
+			}
+		})
+	}
+}
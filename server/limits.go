@@ -0,0 +1,212 @@
+package server
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// semaphore is a weighted semaphore, used to limit the resources (such as
+// verification slots or event log bytes) used by concurrent requests. Waiters
+// acquire the semaphore in FIFO order, so large requests are not starved by
+// small ones. A nil semaphore has no limit.
+type semaphore struct {
+	size    int64
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List // of semaphoreWaiter
+}
+
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+func newSemaphore(size int64) *semaphore {
+	return &semaphore{size: size}
+}
+
+// acquire blocks until n units are available or ctx is done. The caller must
+// ensure that n does not exceed the size of the semaphore.
+func (s *semaphore) acquire(ctx context.Context, n int64) error {
+	if s == nil {
+		return ctx.Err()
+	}
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(semaphoreWaiter{n, ready})
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-ready:
+			// Acquired just as ctx was done, so give the units back.
+			s.cur -= n
+		default:
+			s.waiters.Remove(elem)
+		}
+		s.notifyWaiters()
+		return ctx.Err()
+	}
+}
+
+// release returns n units acquired with acquire.
+func (s *semaphore) release(n int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	s.notifyWaiters()
+}
+
+func (s *semaphore) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+		w := next.Value.(semaphoreWaiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
+
+var (
+	errEntryExists      = errors.New("entry already present")
+	errTooManyEntries   = errors.New("too many outstanding entries")
+	errTooManyForClient = errors.New("too many outstanding entries for this client")
+)
+
+// expiringSet holds entries (such as used nonces or pending enrollments) until
+// they expire. To bound its memory, the number of entries (in total, and for
+// each client adding them) is limited. Expired entries are removed in order of
+// expiry, so adding an entry never requires a scan of the whole set.
+type expiringSet struct {
+	maxEntries   int
+	maxPerClient int // zero if unlimited
+
+	mu      sync.Mutex
+	entries map[string]*expiringEntry
+	queue   expiryQueue
+	clients map[string]int
+}
+
+type expiringEntry struct {
+	id     string
+	client string
+	value  interface{}
+	expiry time.Time
+	index  int // in the expiryQueue
+}
+
+func newExpiringSet(maxEntries, maxPerClient int) *expiringSet {
+	return &expiringSet{
+		maxEntries:   maxEntries,
+		maxPerClient: maxPerClient,
+		entries:      make(map[string]*expiringEntry),
+		clients:      make(map[string]int),
+	}
+}
+
+// add inserts an entry which expires at the provided time. It fails if an
+// unexpired entry with the same ID is present, or if the limits are reached.
+func (s *expiringSet) add(id, client string, value interface{}, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeExpired(time.Now())
+	if _, ok := s.entries[id]; ok {
+		return errEntryExists
+	}
+	if len(s.entries) >= s.maxEntries {
+		return errTooManyEntries
+	}
+	if s.maxPerClient > 0 && s.clients[client] >= s.maxPerClient {
+		return errTooManyForClient
+	}
+	entry := &expiringEntry{id: id, client: client, value: value, expiry: expiry}
+	s.entries[id] = entry
+	s.clients[client]++
+	heap.Push(&s.queue, entry)
+	return nil
+}
+
+// contains reports whether an unexpired entry with the ID is present.
+func (s *expiringSet) contains(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	return ok && time.Now().Before(entry.expiry)
+}
+
+// take removes and returns the value of an unexpired entry.
+func (s *expiringSet) take(id string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, false
+	}
+	s.remove(entry)
+	if !time.Now().Before(entry.expiry) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (s *expiringSet) removeExpired(now time.Time) {
+	for len(s.queue) > 0 && !now.Before(s.queue[0].expiry) {
+		s.remove(s.queue[0])
+	}
+}
+
+func (s *expiringSet) remove(entry *expiringEntry) {
+	heap.Remove(&s.queue, entry.index)
+	delete(s.entries, entry.id)
+	if s.clients[entry.client]--; s.clients[entry.client] == 0 {
+		delete(s.clients, entry.client)
+	}
+}
+
+// expiryQueue is a min-heap of entries ordered by expiry time.
+type expiryQueue []*expiringEntry
+
+func (q expiryQueue) Len() int           { return len(q) }
+func (q expiryQueue) Less(i, j int) bool { return q[i].expiry.Before(q[j].expiry) }
+func (q expiryQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *expiryQueue) Push(x interface{}) {
+	entry := x.(*expiringEntry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return entry
+}
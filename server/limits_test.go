@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	ctx := context.Background()
+	sem := newSemaphore(2)
+	if err := sem.acquire(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// A waiter which gives up must not block those behind it.
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := sem.acquire(short, 2); err != context.DeadlineExceeded {
+		t.Errorf("acquire() on a full semaphore: got %v, want DeadlineExceeded", err)
+	}
+	acquired := make(chan error)
+	go func() { acquired <- sem.acquire(ctx, 1) }()
+	sem.release(1)
+	if err := <-acquired; err != nil {
+		t.Errorf("acquire() after release failed: %v", err)
+	}
+
+	// The semaphore is full again, so a canceled acquire fails immediately.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := sem.acquire(canceled, 1); err != context.Canceled {
+		t.Errorf("canceled acquire(): got %v, want Canceled", err)
+	}
+	sem.release(2)
+	if err := sem.acquire(ctx, 2); err != nil {
+		t.Errorf("acquire() of the whole semaphore failed: %v", err)
+	}
+}
+
+func TestExpiringSet(t *testing.T) {
+	set := newExpiringSet(3, 2)
+	future := time.Now().Add(time.Hour)
+	soon := time.Now().Add(50 * time.Millisecond)
+
+	if err := set.add("a", "client1", 1, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.add("a", "client2", 2, future); err != errEntryExists {
+		t.Errorf("adding a duplicate: got %v, want errEntryExists", err)
+	}
+	if err := set.add("b", "client1", 2, soon); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.add("c", "client1", 3, future); err != errTooManyForClient {
+		t.Errorf("exceeding the client limit: got %v, want errTooManyForClient", err)
+	}
+	if err := set.add("c", "client2", 3, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.add("d", "client3", 4, future); err != errTooManyEntries {
+		t.Errorf("exceeding the total limit: got %v, want errTooManyEntries", err)
+	}
+
+	// Expired entries are not returned, and no longer count towards limits.
+	time.Sleep(100 * time.Millisecond)
+	if set.contains("b") {
+		t.Error("contains() returned true for an expired entry")
+	}
+	if err := set.add("d", "client1", 4, future); err != nil {
+		t.Errorf("add() after an entry expired failed: %v", err)
+	}
+
+	if value, ok := set.take("a"); !ok || value != 1 {
+		t.Errorf("take() got (%v, %v), want (1, true)", value, ok)
+	}
+	if _, ok := set.take("a"); ok {
+		t.Error("take() should fail for a removed entry")
+	}
+	if !set.contains("c") || !set.contains("d") {
+		t.Error("contains() returned false for a present entry")
+	}
+}
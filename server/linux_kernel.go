@@ -0,0 +1,483 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/google/go-tpm-tools/cel"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// Bootloader event type and PCRs used to measure the kernel command line.
+//
+// GRUB measures the command line into PCR8, with the event data set to the
+// command line prefixed with one of grubCmdlinePrefixes, but the digest only
+// covering the command line itself. The systemd EFI stub measures the UTF-16
+// encoded command line into PCR12, and older versions of systemd-boot measure
+// it into PCR8 (see parseLoadOptionsEvent).
+const (
+	ipl               uint32 = 0x0000000D
+	grubCmdlinePCR           = 8
+	systemdCmdlinePCR        = 12
+)
+
+var grubCmdlinePrefixes = [][]byte{
+	[]byte("kernel_cmdline: "),     // Upstream GRUB
+	[]byte("grub_kernel_cmdline "), // Fedora and RHEL GRUB
+}
+
+// EFIBootServicesApplication is the type of EV_EFI_BOOT_SERVICES_APPLICATION
+// events, which measure the PE/COFF Authenticode digest of each UEFI
+// application loaded by the firmware (or by shim) into PCR 4, from the TCG PC
+// Client Platform Firmware Profile Specification, Table 14 Events.
+const EFIBootServicesApplication uint32 = 0x80000003
+
+// Event type and PCRs used to measure the kernel and initrd images.
+//
+// GRUB measures each file it reads into PCR9 as an EV_IPL event, after
+// measuring the command which reads it into PCR8. Upstream GRUB uses the file
+// path as the event data, while Fedora and RHEL GRUB use a fixed description.
+// The Linux EFI stub measures the initrd it loads into PCR9 as an
+// EV_EVENT_TAG event.
+const (
+	eventTag       uint32 = 0x00000006
+	bootAppPCR            = 4
+	grubFilePCR           = 9
+	linuxInitrdTag        = "Linux initrd"
+	rhelGrubKernel        = "grub_linuxefi Kernel"
+	rhelGrubInitrd        = "grub_linuxefi Initrd"
+)
+
+// The EV_EFI_ACTION measured into PCR5 when ExitBootServices is called, from
+// the TCG PC Client Platform Firmware Profile Specification, Section 10.4.4.
+// The kernel is loaded before this, so later measurements are not part of the
+// boot.
+const (
+	exitBootServicesPCR    = 5
+	exitBootServicesAction = "Exit Boot Services Invocation"
+)
+
+// Sizes and device path node types of a UEFI_IMAGE_LOAD_EVENT, from the UEFI
+// Specification, Section 10.3 Device Path Nodes.
+const (
+	uefiImageLoadHeaderSize = 32
+	efiDevicePathMediaType  = 0x04
+	efiDevicePathFileType   = 0x04
+	efiDevicePathEndType    = 0x7f
+)
+
+var (
+	grubKernelCommands = []string{"grub_cmd: linux ", "grub_cmd linux ", "grub_cmd: linuxefi ", "grub_cmd linuxefi "}
+	grubInitrdCommands = []string{"grub_cmd: initrd ", "grub_cmd initrd ", "grub_cmd: initrdefi ", "grub_cmd initrdefi "}
+)
+
+// getLinuxKernelState returns the state of the kernel from the last kernel
+// command line, kernel image and initrd images measured before
+// ExitBootServices in the event log, or nil if there are no such events.
+//
+// These are measured by the bootloader, so they identify the kernel that was
+// booted only if the bootloader itself is trusted (for example, by Secure Boot
+// or by its digest in PCR4).
+func getLinuxKernelState(hash crypto.Hash, events []*pb.Event) *pb.LinuxKernelState {
+	state := &pb.LinuxKernelState{}
+	found := false
+	for _, event := range events {
+		if isExitBootServices(hash, event) {
+			break
+		}
+		if event.GetUntrustedType() != ipl {
+			continue
+		}
+		if c, ok := parseCmdlineEvent(hash, event); ok {
+			state.CommandLine, found = c, true
+		}
+	}
+	if found {
+		state.Swap, state.Hibernation = parseDataAtRestProtection(state.CommandLine)
+		parseKernelRestrictions(state.CommandLine, state)
+	}
+	getKernelImages(hash, events, state)
+	if !found && state.KernelDigest == nil && state.KernelAuthenticodeDigest == nil && len(state.InitrdDigests) == 0 {
+		return nil
+	}
+	return state
+}
+
+// parseCmdlineEvent returns the kernel command line from an event, if it is a
+// command line measurement whose digest matches its data.
+func parseCmdlineEvent(hash crypto.Hash, event *pb.Event) (string, bool) {
+	switch event.GetPcrIndex() {
+	case grubCmdlinePCR:
+		data := bytes.TrimRight(event.GetData(), "\x00")
+		for _, prefix := range grubCmdlinePrefixes {
+			if !bytes.HasPrefix(data, prefix) {
+				continue
+			}
+			cmdline := data[len(prefix):]
+			hasher := hash.New()
+			hasher.Write(cmdline)
+			if bytes.Equal(hasher.Sum(nil), event.GetDigest()) {
+				return string(cmdline), true
+			}
+		}
+		return parseLoadOptionsEvent(hash, event)
+	case systemdCmdlinePCR:
+		return decodeUTF16Cmdline(event)
+	default:
+		return "", false
+	}
+}
+
+// decodeUTF16Cmdline decodes a UTF-16 command line measured by systemd, whose
+// digest covers the whole event data.
+func decodeUTF16Cmdline(event *pb.Event) (string, bool) {
+	data := event.GetData()
+	if !event.GetDigestVerified() || len(data)%2 != 0 {
+		return "", false
+	}
+	return strings.TrimRight(decodeUTF16(data), "\x00"), true
+}
+
+// parseLoadOptionsEvent decodes the UTF-16 load options measured into PCR8 by
+// older versions of systemd-boot, which log the options without the last byte
+// of their NUL terminator, but measure all of it.
+func parseLoadOptionsEvent(hash crypto.Hash, event *pb.Event) (string, bool) {
+	data := event.GetData()
+	if len(data)%2 != 1 {
+		return "", false
+	}
+	hasher := hash.New()
+	hasher.Write(data)
+	hasher.Write([]byte{0})
+	if !bytes.Equal(hasher.Sum(nil), event.GetDigest()) {
+		return "", false
+	}
+	return strings.TrimRight(decodeUTF16(data[:len(data)-1]), "\x00"), true
+}
+
+func decodeUTF16(data []byte) string {
+	utf16Data := make([]uint16, len(data)/2)
+	for i := range utf16Data {
+		utf16Data[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+	}
+	return string(utf16.Decode(utf16Data))
+}
+
+// getKernelImages records the digests of the last kernel and initrd images
+// loaded in the event log before ExitBootServices. Later events, such as those
+// measured by the running kernel, cannot replace the images which were booted.
+//
+// The kernel and initrd files read by GRUB are identified by the paths in the
+// preceding linux and initrd commands. Commands which do not match their
+// digest are ignored, as their paths could point at any other measured file.
+// The kernel's Authenticode digest is taken from the UEFI application loaded
+// after GRUB read the kernel, or otherwise from the last UEFI application
+// (other than the first) whose path does not end in ".efi", which is how
+// systemd-boot loads kernels.
+//
+// Neither the digests nor the paths in PCR9 are verified against the event
+// data, as GRUB only measures the file contents. The digests are only as
+// trustworthy as the replayed PCRs.
+func getKernelImages(hash crypto.Hash, events []*pb.Event, state *pb.LinuxKernelState) {
+	var kernelPath string
+	initrdPaths := make(map[string]bool)
+	kernelLoaded := false
+	bootApps := 0
+	for _, event := range events {
+		if isExitBootServices(hash, event) {
+			return
+		}
+		data := string(bytes.TrimRight(event.GetData(), "\x00"))
+		switch {
+		case event.GetPcrIndex() == grubCmdlinePCR && event.GetUntrustedType() == ipl:
+			command, ok := trimAnyBytesPrefix([]byte(data), grubCommandPrefixes)
+			if !ok || !grubCommandVerified(hash, command, event.GetDigest()) {
+				continue
+			}
+			if args, ok := trimAnyPrefix(data, grubKernelCommands); ok {
+				// A new kernel replaces the previously loaded images.
+				kernelPath = firstField(args)
+				initrdPaths = make(map[string]bool)
+				kernelLoaded = false
+				state.KernelDigest = nil
+				state.KernelAuthenticodeDigest = nil
+				state.InitrdDigests = nil
+			} else if args, ok := trimAnyPrefix(data, grubInitrdCommands); ok {
+				initrdPaths = make(map[string]bool)
+				for _, path := range strings.Fields(args) {
+					initrdPaths[path] = true
+				}
+				state.InitrdDigests = nil
+			}
+		case event.GetPcrIndex() == grubFilePCR && event.GetUntrustedType() == ipl:
+			switch {
+			case data == rhelGrubKernel || (kernelPath != "" && data == kernelPath):
+				state.KernelDigest = event.GetDigest()
+				kernelLoaded = true
+			case data == rhelGrubInitrd || initrdPaths[data]:
+				state.InitrdDigests = append(state.InitrdDigests, event.GetDigest())
+			}
+		case event.GetPcrIndex() == grubFilePCR && event.GetUntrustedType() == eventTag:
+			if parseTaggedEventData(event.GetData()) == linuxInitrdTag {
+				state.InitrdDigests = append(state.InitrdDigests, event.GetDigest())
+			}
+		case event.GetPcrIndex() == bootAppPCR && event.GetUntrustedType() == EFIBootServicesApplication:
+			bootApps++
+			path := parseImageLoadPath(event.GetData())
+			if kernelLoaded || (bootApps > 1 && path != "" && !strings.HasSuffix(strings.ToLower(path), ".efi")) {
+				state.KernelAuthenticodeDigest = event.GetDigest()
+			}
+		}
+	}
+}
+
+// isExitBootServices returns whether the event is the EV_EFI_ACTION measured
+// into PCR5 when the boot loader (or kernel) calls ExitBootServices, and
+// matches its digest.
+func isExitBootServices(hash crypto.Hash, event *pb.Event) bool {
+	if event.GetPcrIndex() != exitBootServicesPCR || event.GetUntrustedType() != EFIAction {
+		return false
+	}
+	if string(event.GetData()) != exitBootServicesAction {
+		return false
+	}
+	hasher := hash.New()
+	hasher.Write(event.GetData())
+	return bytes.Equal(hasher.Sum(nil), event.GetDigest())
+}
+
+func trimAnyPrefix(s string, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return s[len(prefix):], true
+		}
+	}
+	return "", false
+}
+
+func firstField(s string) string {
+	if fields := strings.Fields(s); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// parseTaggedEventData returns the NUL-terminated description in the data of
+// a TCG_PCClientTaggedEvent, or "" if the event data is malformed.
+func parseTaggedEventData(data []byte) string {
+	if len(data) < 8 {
+		return ""
+	}
+	size := binary.LittleEndian.Uint32(data[4:8])
+	if uint64(size) != uint64(len(data)-8) {
+		return ""
+	}
+	return string(bytes.TrimRight(data[8:], "\x00"))
+}
+
+// parseImageLoadPath returns the file path in the device path of a
+// UEFI_IMAGE_LOAD_EVENT, or "" if there is no file path or the event data is
+// malformed. A path split across several file path nodes is joined.
+func parseImageLoadPath(data []byte) string {
+	if len(data) < uefiImageLoadHeaderSize {
+		return ""
+	}
+	// ImageLocationInMemory, ImageLengthInMemory, ImageLinkTimeAddress and
+	// LengthOfDevicePath.
+	devicePathSize := binary.LittleEndian.Uint64(data[24:32])
+	devicePath := data[uefiImageLoadHeaderSize:]
+	if devicePathSize > uint64(len(devicePath)) {
+		return ""
+	}
+	devicePath = devicePath[:devicePathSize]
+
+	var path strings.Builder
+	for len(devicePath) >= 4 {
+		nodeType, subType := devicePath[0], devicePath[1]
+		nodeSize := int(binary.LittleEndian.Uint16(devicePath[2:4]))
+		if nodeType == efiDevicePathEndType || nodeSize < 4 || nodeSize > len(devicePath) {
+			break
+		}
+		if nodeType == efiDevicePathMediaType && subType == efiDevicePathFileType && nodeSize%2 == 0 {
+			path.WriteString(strings.TrimRight(decodeUTF16(devicePath[4:nodeSize]), "\x00"))
+		}
+		devicePath = devicePath[nodeSize:]
+	}
+	return path.String()
+}
+
+// parseDataAtRestProtection determines how swap and hibernation images are
+// protected from the kernel command line. Swap and hibernation can be disabled
+// on the command line, and the command line names the device used for
+// hibernation (which is also a swap device). Any other swap devices, and how
+// they are encrypted, are configured by userspace, which is not measured.
+//
+// Devices are considered encrypted if they are dm-crypt LUKS mappings set up
+// by the initramfs, either named "luks-<UUID>" or with rd.luks.name.
+//
+// Kernels built without CONFIG_SWAP or CONFIG_HIBERNATION cannot be detected,
+// as the kernel configuration is not measured. Such kernels are reported as
+// PROTECTION_UNKNOWN unless the command line says otherwise. Hibernation images
+// are never reported as signed: upstream kernels do not authenticate them,
+// which is why kernel lockdown disables hibernation.
+//
+// systemd.swap=0 only stops systemd from activating the swap devices in
+// /etc/fstab; userspace can still enable swap, so it is ignored.
+func parseDataAtRestProtection(cmdline string) (swap, hibernation pb.DataAtRestProtection) {
+	params := make(map[string]string)
+	luksNames := make(map[string]bool)
+	for _, field := range strings.Fields(cmdline) {
+		key, value := field, ""
+		if i := strings.IndexByte(field, '='); i >= 0 {
+			key, value = field[:i], field[i+1:]
+		}
+		params[key] = value
+		if key == "rd.luks.name" || key == "luks.name" {
+			// rd.luks.name=<UUID>=<name>
+			if i := strings.IndexByte(value, '='); i >= 0 {
+				luksNames[value[i+1:]] = true
+			}
+		}
+	}
+
+	// The hibernation device is also a swap device.
+	resumeDevice := pb.DataAtRestProtection_PROTECTION_UNKNOWN
+	if resume, ok := params["resume"]; ok {
+		resumeDevice = pb.DataAtRestProtection_PROTECTION_UNENCRYPTED
+		mapping := strings.TrimPrefix(resume, "/dev/mapper/")
+		if mapping != resume && (strings.HasPrefix(mapping, "luks-") || luksNames[mapping]) {
+			resumeDevice = pb.DataAtRestProtection_PROTECTION_ENCRYPTED
+		}
+	}
+
+	hibernation = resumeDevice
+	_, noHibernate := params["nohibernate"]
+	lockdown := params["lockdown"]
+	// Kernel lockdown prevents hibernation, as the image is not authenticated.
+	if noHibernate || params["hibernate"] == "no" || lockdown == "integrity" || lockdown == "confidentiality" {
+		hibernation = pb.DataAtRestProtection_PROTECTION_DISABLED
+	}
+
+	swap = pb.DataAtRestProtection_PROTECTION_UNKNOWN
+	if resumeDevice == pb.DataAtRestProtection_PROTECTION_UNENCRYPTED {
+		swap = pb.DataAtRestProtection_PROTECTION_UNENCRYPTED
+	}
+	return swap, hibernation
+}
+
+// parseKernelRestrictions records the lockdown mode and restrictions enabled
+// by the kernel command line. Restrictions can also be enabled by the kernel
+// configuration or by userspace, neither of which is measured, so a command
+// line which does not enable a restriction leaves it unknown.
+func parseKernelRestrictions(cmdline string, state *pb.LinuxKernelState) {
+	for _, field := range strings.Fields(cmdline) {
+		switch field {
+		case "lockdown=integrity":
+			raiseLockdown(state, pb.LockdownMode_LOCKDOWN_INTEGRITY)
+		case "lockdown=confidentiality":
+			raiseLockdown(state, pb.LockdownMode_LOCKDOWN_CONFIDENTIALITY)
+		case "module.sig_enforce=1", "module.sig_enforce=y", "module.sig_enforce=Y":
+			raiseEnforcement(&state.ModuleSignatures, pb.Enforcement_ENFORCED)
+		}
+	}
+}
+
+// The kernel only allows its lockdown mode and restrictions to be made
+// stricter, so the strictest value from any source is a lower bound on the
+// running kernel's state.
+func raiseLockdown(state *pb.LinuxKernelState, mode pb.LockdownMode) {
+	if mode > state.Lockdown {
+		state.Lockdown = mode
+	}
+	// Lockdown requires signed modules and blocks kexec_load.
+	if mode >= pb.LockdownMode_LOCKDOWN_INTEGRITY {
+		raiseEnforcement(&state.ModuleSignatures, pb.Enforcement_ENFORCED)
+		raiseEnforcement(&state.KexecLoadDisabled, pb.Enforcement_ENFORCED)
+	}
+}
+
+func raiseEnforcement(enforcement *pb.Enforcement, value pb.Enforcement) {
+	if value > *enforcement {
+		*enforcement = value
+	}
+}
+
+// applyCanonicalEventLog replays a Canonical Event Log against the PCRs, and
+// records the kernel security settings, configuration files, executions and key
+// derivations it contains in the MachineState.
+func applyCanonicalEventLog(state *pb.MachineState, data []byte, pcrs *tpmpb.PCRs) error {
+	log, err := cel.DecodeToCEL(bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	if err := log.Replay(pcrs); err != nil {
+		return &LogReplayError{Bank: pcrs.GetHash(), Err: err}
+	}
+	for _, record := range log.Records {
+		switch record.Content.Type {
+		case cel.KernelSecurityType:
+			event, err := cel.ParseKernelSecurityEvent(record.Content)
+			if err != nil {
+				return fmt.Errorf("record %d: %w", record.RecNum, err)
+			}
+			if state.LinuxKernel == nil {
+				state.LinuxKernel = &pb.LinuxKernelState{}
+			}
+			applyKernelSecurityEvent(state.LinuxKernel, event)
+		case cel.ConfigFileType:
+			event, err := cel.ParseConfigFileEvent(record.Content)
+			if err != nil {
+				return fmt.Errorf("record %d: %w", record.RecNum, err)
+			}
+			state.ConfigFiles = append(state.ConfigFiles, &pb.ConfigFile{Path: event.Path, Digest: event.Digest})
+		case cel.ExecType:
+			event, err := cel.ParseExecEvent(record.Content)
+			if err != nil {
+				return fmt.Errorf("record %d: %w", record.RecNum, err)
+			}
+			state.Executions = append(state.Executions, &pb.Execution{Path: event.Path, Digest: event.Digest})
+		case cel.KeyDerivationType:
+			event, err := cel.ParseKeyDerivationEvent(record.Content)
+			if err != nil {
+				return fmt.Errorf("record %d: %w", record.RecNum, err)
+			}
+			state.KeyDerivations = append(state.KeyDerivations, &pb.KeyDerivation{KeyName: event.KeyName, Label: event.Label})
+		}
+	}
+	return nil
+}
+
+// applyKernelSecurityEvent records a setting reported by the kernel. Empty or
+// unrecognized values leave the setting unchanged.
+func applyKernelSecurityEvent(state *pb.LinuxKernelState, event cel.KernelSecurityEvent) {
+	switch event.Setting {
+	case cel.KernelLockdown:
+		switch event.Value {
+		case "none":
+			raiseLockdown(state, pb.LockdownMode_LOCKDOWN_NONE)
+		case "integrity":
+			raiseLockdown(state, pb.LockdownMode_LOCKDOWN_INTEGRITY)
+		case "confidentiality":
+			raiseLockdown(state, pb.LockdownMode_LOCKDOWN_CONFIDENTIALITY)
+		}
+	case cel.KernelModuleSigEnforce:
+		switch event.Value {
+		case "N":
+			raiseEnforcement(&state.ModuleSignatures, pb.Enforcement_NOT_ENFORCED)
+		case "Y":
+			raiseEnforcement(&state.ModuleSignatures, pb.Enforcement_ENFORCED)
+		}
+	case cel.KernelKexecLoadDisabled:
+		switch event.Value {
+		case "0":
+			raiseEnforcement(&state.KexecLoadDisabled, pb.Enforcement_NOT_ENFORCED)
+		case "1":
+			raiseEnforcement(&state.KexecLoadDisabled, pb.Enforcement_ENFORCED)
+		}
+	}
+}
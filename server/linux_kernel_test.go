@@ -0,0 +1,392 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/google/go-tpm-tools/cel"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+func TestParseDataAtRestProtection(t *testing.T) {
+	const (
+		unknown     = pb.DataAtRestProtection_PROTECTION_UNKNOWN
+		disabled    = pb.DataAtRestProtection_PROTECTION_DISABLED
+		encrypted   = pb.DataAtRestProtection_PROTECTION_ENCRYPTED
+		unencrypted = pb.DataAtRestProtection_PROTECTION_UNENCRYPTED
+	)
+	subtests := []struct {
+		name            string
+		cmdline         string
+		wantSwap        pb.DataAtRestProtection
+		wantHibernation pb.DataAtRestProtection
+	}{
+		{"Default", "/boot/vmlinuz root=/dev/sda1 ro", unknown, unknown},
+		{"UnencryptedResume", "root=/dev/sda1 resume=/dev/sda2", unencrypted, unencrypted},
+		{"UnencryptedResumeUUID", "root=/dev/sda1 resume=UUID=1234", unencrypted, unencrypted},
+		{"LVMResume", "root=/dev/sda1 resume=/dev/mapper/vg-swap", unencrypted, unencrypted},
+		{"LUKSResume", "root=/dev/sda1 resume=/dev/mapper/luks-1234", unknown, encrypted},
+		{"NamedLUKSResume", "rd.luks.name=1234=cryptswap resume=/dev/mapper/cryptswap", unknown, encrypted},
+		{"NoHibernate", "root=/dev/sda1 nohibernate", unknown, disabled},
+		{"HibernateNo", "root=/dev/sda1 hibernate=no", unknown, disabled},
+		{"Lockdown", "root=/dev/sda1 lockdown=integrity resume=/dev/sda2", unencrypted, disabled},
+		{"LockdownNone", "root=/dev/sda1 lockdown=none resume=/dev/sda2", unencrypted, unencrypted},
+		{"SystemdSwapDisabled", "root=/dev/sda1 systemd.swap=0 nohibernate", unknown, disabled},
+		{"SystemdSwapDisabledResume", "systemd.swap=false resume=/dev/sda2", unencrypted, unencrypted},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			swap, hibernation := parseDataAtRestProtection(subtest.cmdline)
+			if swap != subtest.wantSwap {
+				t.Errorf("got swap protection %v, want %v", swap, subtest.wantSwap)
+			}
+			if hibernation != subtest.wantHibernation {
+				t.Errorf("got hibernation protection %v, want %v", hibernation, subtest.wantHibernation)
+			}
+		})
+	}
+}
+
+func TestLinuxKernelStateFromEventLogs(t *testing.T) {
+	logs := []struct {
+		test.Platform
+		wantCmdline string
+	}{
+		{test.Rhel8GCE, "(hd0,gpt2)/boot/vmlinuz-4.18.0-240.22.1.el8_3.x86_64 root=UUID=f3948fb4-cce7-4193-940a-c50052e93bf3 ro net.ifnames=0 biosdevname=0 scsi_mod.use_blk_mq=Y crashkernel=auto console=ttyS0,38400n8"},
+		{test.Ubuntu2104NoSecureBootGCE, "/boot/vmlinuz-5.11.0-1006-gcp root=PARTUUID=6443a6ae-e5e9-4df7-9a06-d1329e50f33c ro console=ttyS0 panic=-1"},
+		{test.ArchLinuxWorkstation, `initrd=\intel-ucode.img initrd=\initramfs-linux-lts.img cryptdevice=UUID=5465369a-996d-42ca-9ad4-91d0082e0b34:cryptroot root=/dev/mapper/cryptroot rw intel_iommu=on iommu=pt l1tf=off`},
+	}
+	for _, log := range logs {
+		for _, bank := range log.Banks {
+			t.Run(log.Name+"-"+bank.Hash.String(), func(t *testing.T) {
+				ms, err := ParseMachineState(log.RawLog, bank)
+				if err != nil {
+					t.Fatalf("failed to parse and replay log: %v", err)
+				}
+				if got := ms.GetLinuxKernel().GetCommandLine(); got != log.wantCmdline {
+					t.Errorf("got command line %q, want %q", got, log.wantCmdline)
+				}
+			})
+		}
+	}
+}
+
+func TestKernelImagesFromEventLogs(t *testing.T) {
+	subtests := []struct {
+		platform         test.Platform
+		wantKernel       bool
+		wantAuthenticode bool
+		wantInitrds      int
+	}{
+		{test.Rhel8GCE, true, true, 1},
+		{test.UbuntuAmdSevGCE, true, false, 1},
+		{test.Ubuntu2104NoDbxGCE, true, false, 0},
+		{test.Ubuntu2104NoSecureBootGCE, true, false, 0},
+		{test.ArchLinuxWorkstation, false, true, 0},
+		{test.Debian10GCE, false, false, 0},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.platform.Name, func(t *testing.T) {
+			state := parseTestMachineState(t, subtest.platform)
+			kernel := state.GetLinuxKernel()
+			if got := kernel.GetKernelDigest() != nil; got != subtest.wantKernel {
+				t.Errorf("got kernel digest %x, want digest: %v", kernel.GetKernelDigest(), subtest.wantKernel)
+			}
+			if got := kernel.GetKernelAuthenticodeDigest() != nil; got != subtest.wantAuthenticode {
+				t.Errorf("got kernel Authenticode digest %x, want digest: %v", kernel.GetKernelAuthenticodeDigest(), subtest.wantAuthenticode)
+			}
+			if got := len(kernel.GetInitrdDigests()); got != subtest.wantInitrds {
+				t.Errorf("got %d initrd digests, want %d", got, subtest.wantInitrds)
+			}
+		})
+	}
+}
+
+// imageLoadEvent returns the UEFI_IMAGE_LOAD_EVENT of an application loaded
+// from path.
+func imageLoadEvent(path string, digest []byte) *pb.Event {
+	var filePath []byte
+	for _, c := range utf16.Encode([]rune(path + "\x00")) {
+		filePath = append(filePath, byte(c), byte(c>>8))
+	}
+	var devicePath bytes.Buffer
+	// A hard drive media node, which is skipped, then the file path.
+	devicePath.Write([]byte{efiDevicePathMediaType, 0x01, 6, 0, 0, 0})
+	devicePath.Write([]byte{efiDevicePathMediaType, efiDevicePathFileType, byte(4 + len(filePath)), 0})
+	devicePath.Write(filePath)
+	devicePath.Write([]byte{efiDevicePathEndType, 0xff, 4, 0})
+
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, [3]uint64{0x1000, 0x2000, 0})
+	binary.Write(&data, binary.LittleEndian, uint64(devicePath.Len()))
+	data.Write(devicePath.Bytes())
+	return &pb.Event{PcrIndex: bootAppPCR, UntrustedType: EFIBootServicesApplication, Data: data.Bytes(), Digest: digest}
+}
+
+func TestGetKernelImages(t *testing.T) {
+	grub := func(pcr uint32, data string, digest []byte) *pb.Event {
+		return &pb.Event{PcrIndex: pcr, UntrustedType: ipl, Data: []byte(data + "\x00"), Digest: digest}
+	}
+	// GRUB commands, whose digest only covers the command (with a NUL
+	// terminator for RHEL GRUB).
+	command := func(data string) *pb.Event {
+		measured, _ := trimAnyBytesPrefix([]byte(data), grubCommandPrefixes)
+		if strings.HasPrefix(data, "grub_cmd ") {
+			measured = append(measured, 0)
+		}
+		digest := sha256.Sum256(measured)
+		return grub(grubCmdlinePCR, data, digest[:])
+	}
+	forged := command("grub_cmd: linux /vmlinuz root=/dev/sda1")
+	forged.Data = []byte("grub_cmd: linux /ucode.img root=/dev/sda1\x00")
+	var tagged bytes.Buffer
+	binary.Write(&tagged, binary.LittleEndian, uint32(0x8F3B22ED))
+	binary.Write(&tagged, binary.LittleEndian, uint32(len(linuxInitrdTag)))
+	tagged.WriteString(linuxInitrdTag)
+	stubInitrd := &pb.Event{PcrIndex: grubFilePCR, UntrustedType: eventTag, Data: tagged.Bytes(), Digest: []byte("stub initrd")}
+	shim := imageLoadEvent(`\EFI\BOOT\BOOTX64.EFI`, []byte("shim"))
+	exitDigest := sha256.Sum256([]byte(exitBootServicesAction))
+	exitBootServices := &pb.Event{PcrIndex: exitBootServicesPCR, UntrustedType: EFIAction, Data: []byte(exitBootServicesAction), Digest: exitDigest[:]}
+	forgedExitBootServices := &pb.Event{PcrIndex: exitBootServicesPCR, UntrustedType: EFIAction, Data: []byte(exitBootServicesAction), Digest: []byte("forged")}
+	grubApp := imageLoadEvent(`\EFI\ubuntu\grubx64.efi`, []byte("grub"))
+
+	subtests := []struct {
+		name             string
+		events           []*pb.Event
+		wantKernel       string
+		wantAuthenticode string
+		wantInitrds      []string
+	}{
+		{"UpstreamGRUB", []*pb.Event{
+			shim, grubApp,
+			command("grub_cmd: linux /vmlinuz root=/dev/sda1"),
+			grub(grubFilePCR, "/vmlinuz", []byte("kernel")),
+			command("grub_cmd: initrd /ucode.img /initrd.img"),
+			grub(grubFilePCR, "/ucode.img", []byte("ucode")),
+			grub(grubFilePCR, "/initrd.img", []byte("initrd")),
+			grub(grubFilePCR, "/boot/grub/grub.cfg", []byte("config")),
+		}, "kernel", "", []string{"ucode", "initrd"}},
+		{"RHELGRUB", []*pb.Event{
+			shim, grubApp,
+			command("grub_cmd linux (hd0,gpt2)/vmlinuz root=/dev/sda1"),
+			grub(grubFilePCR, rhelGrubKernel, []byte("kernel")),
+			imageLoadEvent("", []byte("kernel PE")),
+			command("grub_cmd initrd (hd0,gpt2)/initrd.img"),
+			grub(grubFilePCR, rhelGrubInitrd, []byte("initrd")),
+		}, "kernel", "kernel PE", []string{"initrd"}},
+		{"LastKernelUsed", []*pb.Event{
+			command("grub_cmd: linux /old"),
+			grub(grubFilePCR, "/old", []byte("old kernel")),
+			command("grub_cmd: initrd /old.img"),
+			grub(grubFilePCR, "/old.img", []byte("old initrd")),
+			command("grub_cmd: linux /new"),
+			grub(grubFilePCR, "/new", []byte("new kernel")),
+		}, "new kernel", "", nil},
+		{"SystemdBoot", []*pb.Event{
+			imageLoadEvent(`\EFI\systemd\systemd-bootx64.efi`, []byte("sd-boot")),
+			imageLoadEvent(`\vmlinuz-linux`, []byte("kernel PE")),
+			stubInitrd,
+		}, "", "kernel PE", []string{"stub initrd"}},
+		{"OnlyBootloaders", []*pb.Event{shim, grubApp}, "", "", nil},
+		{"ForgedCommand", []*pb.Event{
+			forged,
+			grub(grubFilePCR, "/vmlinuz", []byte("kernel")),
+			grub(grubFilePCR, "/ucode.img", []byte("ucode")),
+		}, "", "", nil},
+		{"UnmeasuredFile", []*pb.Event{grub(grubFilePCR, "/vmlinuz", []byte("kernel"))}, "", "", nil},
+		{"AfterExitBootServices", []*pb.Event{
+			command("grub_cmd: linux /vmlinuz"),
+			grub(grubFilePCR, "/vmlinuz", []byte("kernel")),
+			exitBootServices,
+			command("grub_cmd: linux /other"),
+			grub(grubFilePCR, "/other", []byte("other kernel")),
+			imageLoadEvent(`\other`, []byte("other kernel PE")),
+			stubInitrd,
+		}, "kernel", "", nil},
+		{"ForgedExitBootServices", []*pb.Event{
+			forgedExitBootServices,
+			command("grub_cmd: linux /vmlinuz"),
+			grub(grubFilePCR, "/vmlinuz", []byte("kernel")),
+		}, "kernel", "", nil},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			state := &pb.LinuxKernelState{}
+			getKernelImages(crypto.SHA256, subtest.events, state)
+			if got := string(state.GetKernelDigest()); got != subtest.wantKernel {
+				t.Errorf("got kernel digest %q, want %q", got, subtest.wantKernel)
+			}
+			if got := string(state.GetKernelAuthenticodeDigest()); got != subtest.wantAuthenticode {
+				t.Errorf("got kernel Authenticode digest %q, want %q", got, subtest.wantAuthenticode)
+			}
+			var initrds []string
+			for _, digest := range state.GetInitrdDigests() {
+				initrds = append(initrds, string(digest))
+			}
+			if !reflect.DeepEqual(initrds, subtest.wantInitrds) {
+				t.Errorf("got initrd digests %q, want %q", initrds, subtest.wantInitrds)
+			}
+		})
+	}
+}
+
+func TestParseImageLoadPath(t *testing.T) {
+	data := imageLoadEvent(`\EFI\BOOT\BOOTX64.EFI`, nil).GetData()
+	if got := parseImageLoadPath(data); got != `\EFI\BOOT\BOOTX64.EFI` {
+		t.Errorf("got path %q", got)
+	}
+	for _, truncated := range [][]byte{nil, data[:uefiImageLoadHeaderSize], data[:len(data)-1]} {
+		if got := parseImageLoadPath(truncated); got != "" {
+			t.Errorf("got path %q from truncated data", got)
+		}
+	}
+}
+
+func TestGetLinuxKernelState(t *testing.T) {
+	cmdline := "root=/dev/sda1 resume=/dev/sda2"
+	digest := func(data []byte) []byte {
+		h := crypto.SHA256.New()
+		h.Write(data)
+		return h.Sum(nil)
+	}
+	var utf16Cmdline []byte
+	for _, c := range utf16.Encode([]rune(cmdline + "\x00")) {
+		utf16Cmdline = append(utf16Cmdline, byte(c), byte(c>>8))
+	}
+	grubEvent := &pb.Event{
+		PcrIndex:      grubCmdlinePCR,
+		UntrustedType: ipl,
+		Data:          []byte("kernel_cmdline: " + cmdline + "\x00"),
+		Digest:        digest([]byte(cmdline)),
+	}
+	tamperedEvent := &pb.Event{
+		PcrIndex:      grubCmdlinePCR,
+		UntrustedType: ipl,
+		Data:          []byte("kernel_cmdline: " + cmdline + " nohibernate\x00"),
+		Digest:        digest([]byte(cmdline)),
+	}
+	systemdEvent := &pb.Event{
+		PcrIndex:       systemdCmdlinePCR,
+		UntrustedType:  ipl,
+		Data:           utf16Cmdline,
+		Digest:         digest(utf16Cmdline),
+		DigestVerified: true,
+	}
+	loadOptionsEvent := &pb.Event{
+		PcrIndex:      grubCmdlinePCR,
+		UntrustedType: ipl,
+		Data:          utf16Cmdline[:len(utf16Cmdline)-1],
+		Digest:        digest(utf16Cmdline),
+	}
+	exitBootServices := &pb.Event{
+		PcrIndex:      exitBootServicesPCR,
+		UntrustedType: EFIAction,
+		Data:          []byte(exitBootServicesAction),
+		Digest:        digest([]byte(exitBootServicesAction)),
+	}
+	laterCmdline := "root=/dev/sda1 nohibernate"
+	laterEvent := &pb.Event{
+		PcrIndex:      grubCmdlinePCR,
+		UntrustedType: ipl,
+		Data:          []byte("kernel_cmdline: " + laterCmdline + "\x00"),
+		Digest:        digest([]byte(laterCmdline)),
+	}
+
+	subtests := []struct {
+		name        string
+		events      []*pb.Event
+		wantCmdline string
+	}{
+		{"GRUB", []*pb.Event{grubEvent}, cmdline},
+		{"SystemdStub", []*pb.Event{systemdEvent}, cmdline},
+		{"SystemdBootLoadOptions", []*pb.Event{loadOptionsEvent}, cmdline},
+		{"TamperedData", []*pb.Event{tamperedEvent}, ""},
+		{"LastEventUsed", []*pb.Event{grubEvent, tamperedEvent}, cmdline},
+		{"AfterExitBootServices", []*pb.Event{grubEvent, exitBootServices, laterEvent}, cmdline},
+		{"NoEvents", nil, ""},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			state := getLinuxKernelState(crypto.SHA256, subtest.events)
+			if subtest.wantCmdline == "" {
+				if state != nil {
+					t.Errorf("got state %v, want nil", state)
+				}
+				return
+			}
+			if state.GetCommandLine() != subtest.wantCmdline {
+				t.Errorf("got command line %q, want %q", state.GetCommandLine(), subtest.wantCmdline)
+			}
+			if state.GetHibernation() != pb.DataAtRestProtection_PROTECTION_UNENCRYPTED {
+				t.Errorf("got hibernation protection %v, want PROTECTION_UNENCRYPTED", state.GetHibernation())
+			}
+		})
+	}
+}
+
+func TestKernelRestrictions(t *testing.T) {
+	const (
+		unknown     = pb.Enforcement_ENFORCEMENT_UNKNOWN
+		notEnforced = pb.Enforcement_NOT_ENFORCED
+		enforced    = pb.Enforcement_ENFORCED
+	)
+	subtests := []struct {
+		name         string
+		cmdline      string
+		events       []cel.KernelSecurityEvent
+		wantLockdown pb.LockdownMode
+		wantModules  pb.Enforcement
+		wantKexec    pb.Enforcement
+	}{
+		{"Default", "root=/dev/sda1", nil, pb.LockdownMode_LOCKDOWN_UNKNOWN, unknown, unknown},
+		{"CmdlineLockdown", "root=/dev/sda1 lockdown=integrity", nil, pb.LockdownMode_LOCKDOWN_INTEGRITY, enforced, enforced},
+		{"CmdlineLockdownNone", "root=/dev/sda1 lockdown=none", nil, pb.LockdownMode_LOCKDOWN_UNKNOWN, unknown, unknown},
+		{"CmdlineSigEnforce", "module.sig_enforce=1", nil, pb.LockdownMode_LOCKDOWN_UNKNOWN, enforced, unknown},
+		{"KernelUnrestricted", "", []cel.KernelSecurityEvent{
+			{Setting: cel.KernelLockdown, Value: "none"},
+			{Setting: cel.KernelModuleSigEnforce, Value: "N"},
+			{Setting: cel.KernelKexecLoadDisabled, Value: "0"},
+		}, pb.LockdownMode_LOCKDOWN_NONE, notEnforced, notEnforced},
+		{"KernelRestricted", "", []cel.KernelSecurityEvent{
+			{Setting: cel.KernelModuleSigEnforce, Value: "Y"},
+			{Setting: cel.KernelKexecLoadDisabled, Value: "1"},
+		}, pb.LockdownMode_LOCKDOWN_UNKNOWN, enforced, enforced},
+		{"KernelConfidentiality", "", []cel.KernelSecurityEvent{
+			{Setting: cel.KernelLockdown, Value: "confidentiality"},
+		}, pb.LockdownMode_LOCKDOWN_CONFIDENTIALITY, enforced, enforced},
+		{"KernelUnsupported", "", []cel.KernelSecurityEvent{
+			{Setting: cel.KernelLockdown, Value: ""},
+			{Setting: cel.KernelKexecLoadDisabled, Value: "2"},
+		}, pb.LockdownMode_LOCKDOWN_UNKNOWN, unknown, unknown},
+		{"StrictestWins", "lockdown=integrity", []cel.KernelSecurityEvent{
+			{Setting: cel.KernelLockdown, Value: "none"},
+			{Setting: cel.KernelModuleSigEnforce, Value: "N"},
+			{Setting: cel.KernelLockdown, Value: "confidentiality"},
+		}, pb.LockdownMode_LOCKDOWN_CONFIDENTIALITY, enforced, enforced},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			state := &pb.LinuxKernelState{}
+			parseKernelRestrictions(subtest.cmdline, state)
+			for _, event := range subtest.events {
+				applyKernelSecurityEvent(state, event)
+			}
+			if state.GetLockdown() != subtest.wantLockdown {
+				t.Errorf("got lockdown %v, want %v", state.GetLockdown(), subtest.wantLockdown)
+			}
+			if state.GetModuleSignatures() != subtest.wantModules {
+				t.Errorf("got module signatures %v, want %v", state.GetModuleSignatures(), subtest.wantModules)
+			}
+			if state.GetKexecLoadDisabled() != subtest.wantKexec {
+				t.Errorf("got kexec_load disabled %v, want %v", state.GetKexecLoadDisabled(), subtest.wantKexec)
+			}
+		})
+	}
+}
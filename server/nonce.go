@@ -0,0 +1,133 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultNonceLifetime = 5 * time.Minute
+	// Fits in the extraData of TPMs which only implement SHA-256, so the
+	// nonce can be quoted without client.AttestOpts.NonceHash.
+	defaultNonceSize     = 32
+	minNonceSize         = 16
+	maxOutstandingNonces = 1 << 20
+)
+
+// ErrUnknownNonce is wrapped by the errors of NonceManager.Consume, and of
+// VerifyAttestation when VerifyOpts.Nonces is set, when a nonce was not issued
+// by the NonceManager, has expired, or was already used.
+var ErrUnknownNonce = errors.New("nonce was not issued, has expired or was already used")
+
+// NonceStore holds the nonces issued by a NonceManager until they are used or
+// expire. Implementations can use any storage, so that a nonce issued by one
+// server instance can be used with another.
+type NonceStore interface {
+	// Put stores a nonce until the provided expiry time.
+	Put(nonce []byte, expiry time.Time) error
+	// Take removes a nonce. The error wraps ErrUnknownNonce if the nonce is
+	// not present or has expired. Each nonce must only be taken once, even by
+	// concurrent calls.
+	Take(nonce []byte) error
+}
+
+type memoryNonceStore struct {
+	nonces *expiringSet
+}
+
+// NewMemoryNonceStore returns a NonceStore which keeps nonces in memory until
+// they expire. To bound memory use, the number of outstanding nonces is
+// limited.
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{nonces: newExpiringSet(maxOutstandingNonces, 0)}
+}
+
+func (s *memoryNonceStore) Put(nonce []byte, expiry time.Time) error {
+	if err := s.nonces.add(string(nonce), "", nil, expiry); err != nil {
+		return fmt.Errorf("cannot store nonce: %w", err)
+	}
+	return nil
+}
+
+func (s *memoryNonceStore) Take(nonce []byte) error {
+	if _, ok := s.nonces.take(string(nonce)); !ok {
+		return ErrUnknownNonce
+	}
+	return nil
+}
+
+// NonceManagerOpts allows for customizing the functionality of a NonceManager.
+type NonceManagerOpts struct {
+	// Where issued nonces are kept. If nil, they are kept in memory.
+	Store NonceStore
+	// How long a nonce can be used for. If zero, nonces expire after five
+	// minutes.
+	Lifetime time.Duration
+	// The size of the nonces in bytes, at least 16. If zero, nonces are 32
+	// bytes, which can be quoted by any TPM without hashing them.
+	Size int
+}
+
+// NonceManager issues random nonces for attestations, and makes sure that
+// each one is only used once, before it expires. This stops attestations from
+// being replayed. Pass it in VerifyOpts.Nonces to have VerifyAttestation
+// consume the attestation's nonce:
+//
+//	nonce, err := nonces.Issue()
+//	// Send the nonce to the client, and receive its attestation.
+//	state, err := server.VerifyAttestation(attestation, server.VerifyOpts{
+//		Nonce:  nonce,
+//		Nonces: nonces,
+//		...
+//	})
+type NonceManager struct {
+	opts NonceManagerOpts
+}
+
+// NewNonceManager creates a NonceManager with the provided options.
+func NewNonceManager(opts NonceManagerOpts) (*NonceManager, error) {
+	if opts.Lifetime < 0 {
+		return nil, fmt.Errorf("nonce lifetime must not be negative")
+	}
+	if opts.Size != 0 && opts.Size < minNonceSize {
+		return nil, fmt.Errorf("nonces must be at least %d bytes", minNonceSize)
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryNonceStore()
+	}
+	if opts.Lifetime == 0 {
+		opts.Lifetime = defaultNonceLifetime
+	}
+	if opts.Size == 0 {
+		opts.Size = defaultNonceSize
+	}
+	return &NonceManager{opts}, nil
+}
+
+// Issue returns a new random nonce, which can be used once (see Consume)
+// until it expires.
+func (m *NonceManager) Issue() ([]byte, error) {
+	nonce := make([]byte, m.opts.Size)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	if err := m.opts.Store.Put(nonce, time.Now().Add(m.opts.Lifetime)); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// Consume uses up a nonce returned by Issue. It fails with an error wrapping
+// ErrUnknownNonce if the nonce was not issued, has expired, or was already
+// consumed.
+func (m *NonceManager) Consume(nonce []byte) error {
+	if err := m.opts.Store.Take(nonce); err != nil {
+		if errors.Is(err, ErrUnknownNonce) {
+			return err
+		}
+		return fmt.Errorf("failed to consume nonce: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"crypto"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+func TestNonceManager(t *testing.T) {
+	nonces, err := NewNonceManager(NonceManagerOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nonce) != defaultNonceSize {
+		t.Errorf("got a %d byte nonce, want %d bytes", len(nonce), defaultNonceSize)
+	}
+	other, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(other) == string(nonce) {
+		t.Error("Issue() returned the same nonce twice")
+	}
+
+	if err := nonces.Consume(nonce); err != nil {
+		t.Errorf("Consume() failed: %v", err)
+	}
+	if err := nonces.Consume(nonce); !errors.Is(err, ErrUnknownNonce) {
+		t.Errorf("consuming a nonce twice: got %v, want ErrUnknownNonce", err)
+	}
+	if err := nonces.Consume([]byte("not issued")); !errors.Is(err, ErrUnknownNonce) {
+		t.Errorf("consuming an unknown nonce: got %v, want ErrUnknownNonce", err)
+	}
+	if err := nonces.Consume(other); err != nil {
+		t.Errorf("Consume() of another nonce failed: %v", err)
+	}
+}
+
+func TestNonceManagerExpiry(t *testing.T) {
+	nonces, err := NewNonceManager(NonceManagerOpts{Lifetime: 50 * time.Millisecond, Size: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nonce) != 16 {
+		t.Errorf("got a %d byte nonce, want 16 bytes", len(nonce))
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := nonces.Consume(nonce); !errors.Is(err, ErrUnknownNonce) {
+		t.Errorf("consuming an expired nonce: got %v, want ErrUnknownNonce", err)
+	}
+
+	for _, opts := range []NonceManagerOpts{{Lifetime: -time.Second}, {Size: 8}} {
+		if _, err := NewNonceManager(opts); err == nil {
+			t.Errorf("NewNonceManager(%+v) should fail", opts)
+		}
+	}
+}
+
+type failingNonceStore struct{}
+
+func (failingNonceStore) Put([]byte, time.Time) error { return nil }
+func (failingNonceStore) Take([]byte) error           { return errors.New("storage unavailable") }
+
+func TestNonceManagerStoreFailure(t *testing.T) {
+	nonces, err := NewNonceManager(NonceManagerOpts{Store: failingNonceStore{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Storage failures must not be mistaken for replays.
+	if err := nonces.Consume(nonce); err == nil || errors.Is(err, ErrUnknownNonce) {
+		t.Errorf("Consume() with a failing store: got %v, want a storage error", err)
+	}
+}
+
+func TestVerifyAttestationConsumesNonce(t *testing.T) {
+	nonces, err := NewNonceManager(NonceManagerOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := VerifyOpts{Nonce: nonce, Nonces: nonces}
+	// The nonce is used up even though the attestation does not verify.
+	if _, err := VerifyAttestation(&pb.Attestation{}, opts); err == nil || errors.Is(err, ErrUnknownNonce) {
+		t.Fatalf("verifying an empty attestation: got %v, want a verification error", err)
+	}
+	if _, err := VerifyAttestation(&pb.Attestation{}, opts); !errors.Is(err, ErrUnknownNonce) {
+		t.Errorf("reusing the nonce: got %v, want ErrUnknownNonce", err)
+	}
+	// Debugging a failed verification does not need the nonce again.
+	report := DebugAttestation(&pb.Attestation{}, DebugOpts{VerifyOpts: opts})
+	if errors.Is(report.VerifyError, ErrUnknownNonce) {
+		t.Errorf("DebugAttestation() reported %v, want the verification error", report.VerifyError)
+	}
+}
+
+func TestVerifyAttestationRejectsReplay(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+
+	nonces, err := NewNonceManager(NonceManagerOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+	opts := VerifyOpts{
+		Nonce:      nonce,
+		Nonces:     nonces,
+		TrustedAKs: []crypto.PublicKey{ak.PublicKey()},
+	}
+	if _, err := VerifyAttestation(attestation, opts); err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if _, err := VerifyAttestation(attestation, opts); !errors.Is(err, ErrUnknownNonce) {
+		t.Errorf("replaying the attestation: got %v, want ErrUnknownNonce", err)
+	}
+}
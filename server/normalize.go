@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Event types whose data is normalized by DefaultNormalizationRules, from the
+// TCG PC Client Platform Firmware Profile Specification, Table 9.
+const (
+	postCode             uint32 = 0x00000001
+	action               uint32 = 0x00000005
+	efiVariableBoot      uint32 = 0x80000002
+	efiVariableBoot2     uint32 = 0x8000000C
+	efiVariableAuthority uint32 = 0x800000E0
+)
+
+// UEFI device path node types, from the UEFI Specification, Section 10.3.
+const (
+	mediaDevicePath         byte = 0x04
+	filePathMediaDevicePath byte = 0x04
+	endDevicePath           byte = 0x7F
+)
+
+const (
+	efiLoadOptionHeaderSize  = 6
+	devicePathNodeHeaderSize = 4
+)
+
+// NormalizationRule rewrites the data of events which different firmware
+// vendors record differently for the same logical configuration, such as a
+// version string in UCS-2 rather than ASCII, or padded with NULs. Events whose
+// normalized data is equal are treated as the same event by a Baseline, even
+// though their digests differ.
+type NormalizationRule struct {
+	// Name identifies the rule in ClassifiedEvent.Normalization.
+	Name string
+	// The event types the rule applies to.
+	Types []uint32
+	// Normalize returns the normalized form of an event's data, or false if
+	// the data is not in a form the rule understands.
+	Normalize func(data []byte) ([]byte, bool)
+}
+
+// DefaultNormalizationRules are the rules used if
+// ClassifierOpts.NormalizationRules is nil. They cover the variations between
+// OEM firmware builds which are common in mixed fleets:
+//   - strings in UCS-2 or ASCII, with or without NUL terminators, and padded
+//     with NULs, spaces or 0xFF to a fixed size
+//   - UEFI variable names whose length includes their NUL terminator
+//   - boot entries whose file paths differ only in case, in the use of '/' or
+//     '\', or in duplicated separators (as written by some boot managers),
+//     and whose disk (which is specific to each machine) is ignored
+var DefaultNormalizationRules = []NormalizationRule{
+	{
+		Name:      "string",
+		Types:     []uint32{postCode, action, SCRTMVersion, EFIAction},
+		Normalize: normalizeString,
+	},
+	{
+		Name:      "uefi-variable",
+		Types:     []uint32{EFIVariableDriverConfig, efiVariableBoot, efiVariableBoot2, efiVariableAuthority},
+		Normalize: normalizeVariable,
+	},
+}
+
+// AliasRule returns a rule for an OEM quirk table: the data of events of the
+// given type which (after normalizeString) is a key of aliases is replaced by
+// the corresponding value. This maps vendor-specific spellings of the same
+// logical event, such as different descriptions of the same firmware volume,
+// onto one canonical spelling. Use it along with DefaultNormalizationRules,
+// as ClassifierOpts uses the first rule which applies to an event.
+func AliasRule(name string, eventType uint32, aliases map[string]string) NormalizationRule {
+	return NormalizationRule{
+		Name:  name,
+		Types: []uint32{eventType},
+		Normalize: func(data []byte) ([]byte, bool) {
+			normalized, ok := normalizeString(data)
+			if !ok {
+				return nil, false
+			}
+			if canonical, ok := aliases[string(normalized)]; ok {
+				return []byte(canonical), true
+			}
+			return normalized, true
+		},
+	}
+}
+
+// normalizeString decodes a UCS-2 or ASCII string, removes its terminator and
+// padding, and collapses runs of whitespace.
+func normalizeString(data []byte) ([]byte, bool) {
+	// bytes.TrimRight would treat the invalid UTF-8 "\xff" as U+FFFD.
+	for len(data) > 0 && data[len(data)-1] == 0xFF {
+		data = data[:len(data)-1]
+	}
+	var s string
+	if isUCS2(data) {
+		s = decodeUTF16(data)
+	} else if utf8.Valid(data) {
+		s = string(data)
+	} else {
+		return nil, false
+	}
+	s = strings.TrimRightFunc(s, func(r rune) bool { return r == 0 || unicode.IsSpace(r) })
+	if strings.ContainsRune(s, 0) {
+		return nil, false
+	}
+	return []byte(strings.Join(strings.Fields(s), " ")), true
+}
+
+// isUCS2 reports whether data looks like a UCS-2 string of ASCII characters:
+// every second byte is zero.
+func isUCS2(data []byte) bool {
+	if len(data) == 0 || len(data)%2 != 0 {
+		return false
+	}
+	for i := 1; i < len(data); i += 2 {
+		if data[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeVariable normalizes the UEFI_VARIABLE_DATA of a variable event:
+// the variable's name loses any NUL terminator, and Boot#### variables are
+// normalized with normalizeLoadOption.
+func normalizeVariable(data []byte) ([]byte, bool) {
+	guid, name, value, err := parseUEFIVariableData(data)
+	if err != nil {
+		return nil, false
+	}
+	name = strings.TrimRight(name, "\x00")
+	if isBootOptionName(name) {
+		if value, err = normalizeLoadOption(value); err != nil {
+			return nil, false
+		}
+	}
+	var normalized bytes.Buffer
+	binary.Write(&normalized, binary.LittleEndian, guid)
+	normalized.WriteString(name)
+	normalized.WriteByte(0)
+	normalized.Write(value)
+	return normalized.Bytes(), true
+}
+
+// isBootOptionName reports whether name is that of a Boot#### variable.
+func isBootOptionName(name string) bool {
+	if len(name) != 8 || !strings.HasPrefix(name, "Boot") {
+		return false
+	}
+	for _, c := range name[4:] {
+		if !strings.ContainsRune("0123456789ABCDEFabcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLoadOption normalizes an EFI_LOAD_OPTION (from the UEFI
+// Specification, Section 3.1.3 Load Options). Only its attributes,
+// description, file paths and optional data are kept: other device path
+// nodes, such as the hard drive partition, identify this machine's disk. File
+// paths are case-folded, use '\' as the separator, and have duplicated
+// separators removed.
+func normalizeLoadOption(option []byte) ([]byte, error) {
+	if len(option) < efiLoadOptionHeaderSize {
+		return nil, errLoadOption
+	}
+	attributes := option[:4]
+	pathListLength := int(binary.LittleEndian.Uint16(option[4:6]))
+	rest := option[efiLoadOptionHeaderSize:]
+
+	// The description is a NUL-terminated UCS-2 string.
+	descriptionEnd := -1
+	for i := 0; i+1 < len(rest); i += 2 {
+		if rest[i] == 0 && rest[i+1] == 0 {
+			descriptionEnd = i
+			break
+		}
+	}
+	if descriptionEnd < 0 || len(rest) < descriptionEnd+2+pathListLength {
+		return nil, errLoadOption
+	}
+	description := strings.TrimSpace(decodeUTF16(rest[:descriptionEnd]))
+	pathList := rest[descriptionEnd+2 : descriptionEnd+2+pathListLength]
+	optionalData := rest[descriptionEnd+2+pathListLength:]
+
+	var normalized bytes.Buffer
+	normalized.Write(attributes)
+	normalized.WriteString(description)
+	normalized.WriteByte(0)
+	for len(pathList) > 0 {
+		if len(pathList) < devicePathNodeHeaderSize {
+			return nil, errLoadOption
+		}
+		nodeType, subType := pathList[0], pathList[1]
+		length := int(binary.LittleEndian.Uint16(pathList[2:4]))
+		if length < devicePathNodeHeaderSize || length > len(pathList) {
+			return nil, errLoadOption
+		}
+		if nodeType == mediaDevicePath && subType == filePathMediaDevicePath {
+			normalized.WriteString(normalizeFilePath(decodeUTF16(pathList[devicePathNodeHeaderSize:length])))
+			normalized.WriteByte(0)
+		} else if nodeType == endDevicePath {
+			normalized.WriteByte(0)
+		}
+		pathList = pathList[length:]
+	}
+	normalized.Write(optionalData)
+	return normalized.Bytes(), nil
+}
+
+var errLoadOption = errors.New("invalid EFI_LOAD_OPTION")
+
+// normalizeFilePath normalizes a UEFI file path, which is on a FAT file system
+// and so case-insensitive.
+func normalizeFilePath(path string) string {
+	path = strings.TrimRight(path, "\x00")
+	path = strings.ReplaceAll(path, "/", `\`)
+	for strings.Contains(path, `\\`) {
+		path = strings.ReplaceAll(path, `\\`, `\`)
+	}
+	return strings.ToUpper(path)
+}
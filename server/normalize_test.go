@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"google.golang.org/protobuf/proto"
+)
+
+func encodeUCS2(s string) []byte {
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, utf16.Encode([]rune(s)))
+	return data.Bytes()
+}
+
+func TestNormalizeString(t *testing.T) {
+	testcases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"ASCII", []byte("ACPI DATA"), "ACPI DATA"},
+		{"ASCIITerminated", []byte("ACPI DATA\x00"), "ACPI DATA"},
+		{"UCS2", encodeUCS2("ACPI DATA"), "ACPI DATA"},
+		{"UCS2Terminated", encodeUCS2("ACPI DATA\x00"), "ACPI DATA"},
+		{"NULPadding", []byte("ACPI DATA\x00\x00\x00\x00"), "ACPI DATA"},
+		{"FFPadding", []byte("ACPI DATA\xff\xff\xff"), "ACPI DATA"},
+		{"SpacePadding", encodeUCS2("ACPI DATA   \x00"), "ACPI DATA"},
+		{"DuplicatedSpaces", []byte("ACPI  DATA"), "ACPI DATA"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := normalizeString(tc.data)
+			if !ok || string(got) != tc.want {
+				t.Errorf("normalizeString(%q) = %q, %v, want %q", tc.data, got, ok, tc.want)
+			}
+		})
+	}
+
+	for _, data := range [][]byte{[]byte("ACPI\x00DATA"), {0x80, 0x81, 0x82}} {
+		if got, ok := normalizeString(data); ok {
+			t.Errorf("normalizeString(%q) = %q, want failure", data, got)
+		}
+	}
+}
+
+// loadOption encodes an EFI_LOAD_OPTION booting path from the partition with
+// the given GUID.
+func loadOption(description string, partition byte, path string) []byte {
+	var pathList bytes.Buffer
+	// A hard drive media device path node, identifying the partition.
+	hardDrive := make([]byte, 42)
+	hardDrive[0], hardDrive[1] = mediaDevicePath, 0x01
+	binary.LittleEndian.PutUint16(hardDrive[2:], uint16(len(hardDrive)))
+	hardDrive[24] = partition
+	pathList.Write(hardDrive)
+	filePath := encodeUCS2(path + "\x00")
+	pathList.Write([]byte{mediaDevicePath, filePathMediaDevicePath})
+	binary.Write(&pathList, binary.LittleEndian, uint16(devicePathNodeHeaderSize+len(filePath)))
+	pathList.Write(filePath)
+	pathList.Write([]byte{endDevicePath, 0xFF, 0x04, 0x00})
+
+	var option bytes.Buffer
+	binary.Write(&option, binary.LittleEndian, uint32(1)) // LOAD_OPTION_ACTIVE
+	binary.Write(&option, binary.LittleEndian, uint16(pathList.Len()))
+	option.Write(encodeUCS2(description + "\x00"))
+	option.Write(pathList.Bytes())
+	return option.Bytes()
+}
+
+// withEvent returns a copy of the state with an event added, with a verified
+// digest of its data.
+func withEvent(state *pb.MachineState, event *pb.Event, hash crypto.Hash) *pb.MachineState {
+	changed := proto.Clone(state).(*pb.MachineState)
+	h := hash.New()
+	h.Write(event.GetData())
+	event.Digest = h.Sum(nil)
+	event.DigestVerified = true
+	changed.RawEvents = append(changed.RawEvents, event)
+	return changed
+}
+
+func bootEvent(name string, option []byte) *pb.Event {
+	event := uefiVariableEvent(efiGlobalVariable, name, option)
+	event.PcrIndex = 1
+	event.UntrustedType = efiVariableBoot
+	return event
+}
+
+func TestClassifyNormalizedEvents(t *testing.T) {
+	golden := parseTestMachineState(t, test.Debian10GCE)
+	crtmVersion := golden.GetRawEvents()[findEvent(t, golden, 0, SCRTMVersion)]
+	hash := crypto.SHA1
+	if len(crtmVersion.GetDigest()) == crypto.SHA256.Size() {
+		hash = crypto.SHA256
+	}
+	version, ok := normalizeString(crtmVersion.GetData())
+	if !ok {
+		t.Fatalf("failed to normalize the S-CRTM version %q", crtmVersion.GetData())
+	}
+
+	// The golden machine has a boot entry for shim, and another vendor's
+	// firmware measures the same S-CRTM version in ASCII.
+	golden = withEvent(golden, bootEvent("Boot0001", loadOption("debian", 1, `\EFI\debian\shimx64.efi`)), hash)
+	baseline, err := NewBaseline([]*pb.MachineState{golden}, ClassifierOpts{VariableEvents: []VariableEvent{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := len(golden.GetRawEvents())
+
+	testcases := []struct {
+		name          string
+		event         *pb.Event
+		class         EventClass
+		normalization string
+	}{
+		{"CRTMVersionASCII", &pb.Event{UntrustedType: SCRTMVersion, Data: append(version, 0)}, EventExpected, "string"},
+		{"CRTMVersionPadded", &pb.Event{UntrustedType: SCRTMVersion, Data: append(encodeUCS2(string(version)), 0, 0, 0, 0)}, EventExpected, "string"},
+		{"CRTMVersionDifferent", &pb.Event{UntrustedType: SCRTMVersion, Data: []byte("Other Firmware v1")}, EventAnomalous, ""},
+		{"BootEntryOtherDisk", bootEvent("Boot0001", loadOption("debian", 2, `\EFI\debian\shimx64.efi`)), EventExpected, "uefi-variable"},
+		{"BootEntryPathSpelling", bootEvent("Boot0001\x00", loadOption("debian ", 1, `/efi//debian\\SHIMX64.EFI`)), EventExpected, "uefi-variable"},
+		{"BootEntryOtherPath", bootEvent("Boot0001", loadOption("debian", 1, `\EFI\debian\grubx64.efi`)), EventAnomalous, ""},
+		{"BootEntryOtherVariable", bootEvent("Boot0002", loadOption("debian", 1, `\EFI\debian\shimx64.efi`)), EventAnomalous, ""},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := withEvent(golden, tc.event, hash)
+			report := baseline.Classify(state)
+			got := report.Events[end]
+			if got.Class != tc.class || got.Normalization != tc.normalization {
+				t.Errorf("event classified as %v (normalized by %q), want %v (normalized by %q)",
+					got.Class, got.Normalization, tc.class, tc.normalization)
+			}
+		})
+	}
+
+	// Events whose digest is not of their data are never normalized.
+	state := withEvent(golden, &pb.Event{UntrustedType: SCRTMVersion, Data: append(version, 0)}, hash)
+	state.RawEvents[end].DigestVerified = false
+	if got := baseline.Classify(state).Events[end]; got.Class != EventAnomalous {
+		t.Errorf("event with an unverified digest classified as %v, want %v", got.Class, EventAnomalous)
+	}
+
+	// Without normalization rules, only digests are matched.
+	baseline, err = NewBaseline([]*pb.MachineState{golden}, ClassifierOpts{NormalizationRules: []NormalizationRule{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state = withEvent(golden, &pb.Event{UntrustedType: SCRTMVersion, Data: append(version, 0)}, hash)
+	if got := baseline.Classify(state).Events[end]; got.Class != EventAnomalous {
+		t.Errorf("event classified as %v without normalization rules, want %v", got.Class, EventAnomalous)
+	}
+}
+
+func TestAliasRule(t *testing.T) {
+	golden := parseTestMachineState(t, test.Debian10GCE)
+	hash := crypto.SHA1
+	if len(golden.GetRawEvents()[0].GetDigest()) == crypto.SHA256.Size() {
+		hash = crypto.SHA256
+	}
+	golden = withEvent(golden, &pb.Event{UntrustedType: postCode, Data: []byte("Embedded UEFI Driver")}, hash)
+	end := len(golden.GetRawEvents())
+
+	rules := append([]NormalizationRule{
+		AliasRule("oem-post-code", postCode, map[string]string{"EMBEDDED DRIVER": "Embedded UEFI Driver"}),
+	}, DefaultNormalizationRules...)
+	baseline, err := NewBaseline([]*pb.MachineState{golden}, ClassifierOpts{NormalizationRules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := withEvent(golden, &pb.Event{UntrustedType: postCode, Data: encodeUCS2("EMBEDDED DRIVER\x00")}, hash)
+	if got := baseline.Classify(state).Events[end]; got.Class != EventExpected || got.Normalization != "oem-post-code" {
+		t.Errorf("aliased event classified as %v (normalized by %q), want expected", got.Class, got.Normalization)
+	}
+	state = withEvent(golden, &pb.Event{UntrustedType: postCode, Data: []byte("OTHER DRIVER")}, hash)
+	if got := baseline.Classify(state).Events[end]; got.Class != EventAnomalous {
+		t.Errorf("unknown event classified as %v, want %v", got.Class, EventAnomalous)
+	}
+}
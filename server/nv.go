@@ -0,0 +1,30 @@
+package server
+
+import (
+	"crypto"
+
+	"github.com/google/go-tpm-tools/internal"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// CertifiedNV is the contents of an NV index, certified by a trusted key.
+type CertifiedNV struct {
+	// Public is the public area of the index. Its attributes show how the
+	// contents could have been written (e.g. whether the index is write-locked).
+	Public tpm2.NVPublic
+	Data   []byte
+}
+
+// VerifyNVCertification checks that the whole contents of an NV index were
+// certified (by client.Key.CertifyNV) with a trusted key, and returns the
+// certified public area and contents. The trustedAK should be an AK the caller
+// has already established trust in (for example, with VerifyAttestation or an
+// AK certificate), and extraData must match the data passed to CertifyNV.
+func VerifyNVCertification(certification *tpmpb.NVCertification, trustedAK crypto.PublicKey, extraData []byte) (*CertifiedNV, error) {
+	pub, data, err := internal.VerifyNVCertification(certification, trustedAK, extraData)
+	if err != nil {
+		return nil, err
+	}
+	return &CertifiedNV{Public: pub, Data: data}, nil
+}
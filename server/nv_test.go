@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestVerifyNVCertification(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	otherAK, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherAK.Close()
+
+	serial := []byte("serial-1234")
+	nv, err := client.DefineNVIndex(rwc, 0x01500200, client.NVIndexOpts{Size: uint16(len(serial))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nv.Delete()
+	if err := nv.Write(serial); err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("super secret nonce")
+	certification, err := ak.CertifyNV(nv, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certified, err := VerifyNVCertification(certification, ak.PublicKey(), nonce)
+	if err != nil {
+		t.Fatalf("VerifyNVCertification() failed: %v", err)
+	}
+	if !bytes.Equal(certified.Data, serial) {
+		t.Errorf("got certified data %q, want %q", certified.Data, serial)
+	}
+	if certified.Public.NVIndex != 0x01500200 {
+		t.Errorf("got certified index 0x%x, want 0x01500200", certified.Public.NVIndex)
+	}
+
+	// A public area with different attributes has a different Name.
+	pub := nv.Public()
+	pub.Attributes |= tpm2.AttrWriteDefine
+	modifiedPublic, err := tpmutil.Pack(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := proto.Clone(certification).(*tpmpb.NVCertification)
+	modified.NvPublic = modifiedPublic
+
+	subtests := []struct {
+		name          string
+		certification *tpmpb.NVCertification
+		ak            *client.Key
+		extraData     []byte
+	}{
+		{"WrongNonce", certification, ak, []byte("wrong nonce")},
+		{"WrongAK", certification, otherAK, nonce},
+		{"ModifiedPublic", modified, ak, nonce},
+		{"Empty", &tpmpb.NVCertification{}, ak, nonce},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, err := VerifyNVCertification(subtest.certification, subtest.ak.PublicKey(), subtest.extraData); err == nil {
+				t.Error("VerifyNVCertification() should have failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"crypto"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PKCS11URI identifies an object in a PKCS#11 token, as in an RFC 7512
+// "pkcs11:" URI. Empty fields match any value.
+type PKCS11URI struct {
+	// Attributes of the token holding the object.
+	Token        string
+	Manufacturer string
+	Model        string
+	Serial       string
+	// The slot holding the token, if SlotID is non-nil.
+	SlotID *uint
+	// Attributes of the object: its label (CKA_LABEL), ID (CKA_ID) and type
+	// ("public", "cert", "private", "secret-key" or "data").
+	Object string
+	ID     []byte
+	Type   string
+	// The query attributes, which select the PKCS#11 module and provide the
+	// token's PIN.
+	ModulePath string
+	ModuleName string
+	PinValue   string
+	PinSource  string
+}
+
+// ParsePKCS11URI parses an RFC 7512 PKCS#11 URI, such as
+// "pkcs11:token=my-token;object=my-ak;type=public". Vendor-specific ("x-")
+// attributes are ignored. As a key selected with fewer attributes than the URI
+// specifies may not be the intended one, URIs with other attributes (such as
+// the library-* attributes) are rejected.
+func ParsePKCS11URI(uri string) (*PKCS11URI, error) {
+	rest := strings.TrimPrefix(uri, "pkcs11:")
+	if rest == uri {
+		return nil, fmt.Errorf("PKCS#11 URI %q does not start with \"pkcs11:\"", uri)
+	}
+	path, query := rest, ""
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		path, query = rest[:i], rest[i+1:]
+	}
+
+	parsed := &PKCS11URI{}
+	seen := make(map[string]bool)
+	parse := func(attrs, sep string, set func(name, value string) (bool, error)) error {
+		if attrs == "" {
+			return nil
+		}
+		for _, attr := range strings.Split(attrs, sep) {
+			parts := strings.SplitN(attr, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return fmt.Errorf("invalid PKCS#11 URI attribute %q", attr)
+			}
+			name := parts[0]
+			if seen[name] {
+				return fmt.Errorf("duplicate PKCS#11 URI attribute %q", name)
+			}
+			seen[name] = true
+			value, err := url.PathUnescape(parts[1])
+			if err != nil {
+				return fmt.Errorf("invalid PKCS#11 URI attribute %q: %w", name, err)
+			}
+			if strings.HasPrefix(name, "x-") {
+				continue
+			}
+			known, err := set(name, value)
+			if err != nil {
+				return fmt.Errorf("invalid PKCS#11 URI attribute %q: %w", name, err)
+			}
+			if !known {
+				return fmt.Errorf("unsupported PKCS#11 URI attribute %q", name)
+			}
+		}
+		return nil
+	}
+
+	err := parse(path, ";", func(name, value string) (bool, error) {
+		switch name {
+		case "token":
+			parsed.Token = value
+		case "manufacturer":
+			parsed.Manufacturer = value
+		case "model":
+			parsed.Model = value
+		case "serial":
+			parsed.Serial = value
+		case "slot-id":
+			id, err := strconv.ParseUint(value, 10, 0)
+			if err != nil {
+				return true, err
+			}
+			slotID := uint(id)
+			parsed.SlotID = &slotID
+		case "object":
+			parsed.Object = value
+		case "id":
+			parsed.ID = []byte(value)
+		case "type":
+			switch value {
+			case "public", "cert", "private", "secret-key", "data":
+			default:
+				return true, fmt.Errorf("unknown object type %q", value)
+			}
+			parsed.Type = value
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = parse(query, "&", func(name, value string) (bool, error) {
+		switch name {
+		case "module-path":
+			parsed.ModulePath = value
+		case "module-name":
+			parsed.ModuleName = value
+		case "pin-value":
+			parsed.PinValue = value
+		case "pin-source":
+			parsed.PinSource = value
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// PKCS11KeySource looks up public keys in PKCS#11 tokens. The pkcs11 package
+// provides an implementation using a PKCS#11 module.
+type PKCS11KeySource interface {
+	// PublicKey returns the public key of the only key or certificate object
+	// matching the URI, or an error if there is not exactly one.
+	PublicKey(uri *PKCS11URI) (crypto.PublicKey, error)
+}
+
+// PKCS11PublicKeys returns the public keys referenced by PKCS#11 URIs, for use
+// as VerifyOpts.TrustedAKs. This allows AKs which were enrolled into an HSM or
+// a key management system to be trusted without exporting them.
+func PKCS11PublicKeys(source PKCS11KeySource, uris ...string) ([]crypto.PublicKey, error) {
+	keys := make([]crypto.PublicKey, 0, len(uris))
+	for _, uri := range uris {
+		parsed, err := ParsePKCS11URI(uri)
+		if err != nil {
+			return nil, err
+		}
+		if parsed.Type != "" && parsed.Type != "public" && parsed.Type != "cert" {
+			return nil, fmt.Errorf("PKCS#11 URI %q does not reference a public key or certificate", uri)
+		}
+		key, err := source.PublicKey(parsed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get public key for %q: %w", uri, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePKCS11URI(t *testing.T) {
+	slot := uint(3)
+	subtests := []struct {
+		name string
+		uri  string
+		want *PKCS11URI
+	}{
+		{"Empty", "pkcs11:", &PKCS11URI{}},
+		{"Object", "pkcs11:token=My%20Token;object=ak;type=public", &PKCS11URI{Token: "My Token", Object: "ak", Type: "public"}},
+		{"Token", "pkcs11:manufacturer=ACME;model=HSM;serial=1234;slot-id=3", &PKCS11URI{Manufacturer: "ACME", Model: "HSM", Serial: "1234", SlotID: &slot}},
+		{"ID", "pkcs11:id=%01%02%ff", &PKCS11URI{ID: []byte{1, 2, 0xff}}},
+		{"Query", "pkcs11:object=ak?module-path=/usr/lib/p11.so&pin-value=1234", &PKCS11URI{Object: "ak", ModulePath: "/usr/lib/p11.so", PinValue: "1234"}},
+		{"Vendor", "pkcs11:object=ak;x-vendor=1?x-other=2", &PKCS11URI{Object: "ak"}},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			got, err := ParsePKCS11URI(subtest.uri)
+			if err != nil {
+				t.Fatalf("ParsePKCS11URI() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, subtest.want) {
+				t.Errorf("ParsePKCS11URI() = %+v, want %+v", got, subtest.want)
+			}
+		})
+	}
+}
+
+func TestParsePKCS11URIInvalid(t *testing.T) {
+	for _, uri := range []string{
+		"",
+		"pkcs12:object=ak",
+		"pkcs11:object",
+		"pkcs11:object=ak;object=ek",
+		"pkcs11:object=%zz",
+		"pkcs11:slot-id=first",
+		"pkcs11:type=key",
+		"pkcs11:library-manufacturer=ACME",
+		"pkcs11:?module=p11.so",
+	} {
+		if _, err := ParsePKCS11URI(uri); err == nil {
+			t.Errorf("ParsePKCS11URI(%q) succeeded, want error", uri)
+		}
+	}
+}
+
+// fakeKeySource holds keys by object label.
+type fakeKeySource map[string]crypto.PublicKey
+
+func (s fakeKeySource) PublicKey(uri *PKCS11URI) (crypto.PublicKey, error) {
+	key, ok := s[uri.Object]
+	if !ok {
+		return nil, errors.New("no matching object")
+	}
+	return key, nil
+}
+
+func TestPKCS11PublicKeys(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := fakeKeySource{"ak": priv.Public()}
+
+	keys, err := PKCS11PublicKeys(source, "pkcs11:object=ak;type=public", "pkcs11:object=ak")
+	if err != nil {
+		t.Fatalf("PKCS11PublicKeys() failed: %v", err)
+	}
+	if len(keys) != 2 || !priv.PublicKey.Equal(keys[0]) || !priv.PublicKey.Equal(keys[1]) {
+		t.Errorf("PKCS11PublicKeys() = %v, want the AK twice", keys)
+	}
+
+	for _, uri := range []string{"pkcs11:object=ek", "pkcs11:object=ak;type=private", "object=ak"} {
+		if _, err := PKCS11PublicKeys(source, uri); err == nil {
+			t.Errorf("PKCS11PublicKeys(%q) succeeded, want error", uri)
+		}
+	}
+}
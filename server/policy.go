@@ -0,0 +1,298 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-attestation/attest"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// Names of the rules which can be checked by EvaluatePolicy. These are the
+// valid values for the rule field of a PolicyWaiver.
+const (
+	RuleAllowedSCRTMVersionIDs    = "platform.allowed_scrtm_version_ids"
+	RuleMinimumGCEFirmwareVersion = "platform.minimum_gce_firmware_version"
+	RuleMinimumTechnology         = "platform.minimum_technology"
+	RuleMinimumLockdown           = "kernel.minimum_lockdown"
+	RuleRequireModuleSignatures   = "kernel.require_module_signatures"
+	RuleRequireKexecLoadDisabled  = "kernel.require_kexec_load_disabled"
+	RuleConfigFiles               = "config_files"
+	RuleDeniedTPMFirmware         = "tpm.denied_firmware"
+)
+
+var policyRules = map[string]bool{
+	RuleAllowedSCRTMVersionIDs:    true,
+	RuleMinimumGCEFirmwareVersion: true,
+	RuleMinimumTechnology:         true,
+	RuleMinimumLockdown:           true,
+	RuleRequireModuleSignatures:   true,
+	RuleRequireKexecLoadDisabled:  true,
+	RuleConfigFiles:               true,
+	RuleDeniedTPMFirmware:         true,
+}
+
+// PolicyWarning is a policy failure which was accepted because of a waiver.
+type PolicyWarning struct {
+	// The rule which the MachineState failed
+	Rule string
+	// Why the MachineState failed the rule
+	Err error
+	// The waiver which allowed the failure
+	Waiver *pb.PolicyWaiver
+}
+
+func (w PolicyWarning) String() string {
+	return fmt.Sprintf("%s: %v (waived by %q until %v: %s)", w.Rule, w.Err,
+		w.Waiver.GetApprover(), w.Waiver.GetExpireTime().AsTime(), w.Waiver.GetJustification())
+}
+
+// Proto returns the PolicyWarning in its protobuf form.
+func (w PolicyWarning) Proto() *pb.PolicyWarning {
+	return &pb.PolicyWarning{Rule: w.Rule, Error: w.Err.Error(), Waiver: w.Waiver}
+}
+
+// PolicyResult records the outcome of EvaluatePolicy.
+type PolicyResult struct {
+	// Failures which were converted to warnings by a PolicyWaiver. To keep an
+	// audit trail of the exceptions in use, these should be logged or stored
+	// along with the MachineState (see Record).
+	Warnings []PolicyWarning
+}
+
+// Record stores the result's warnings in the MachineState's policy_warnings,
+// replacing any previously recorded warnings.
+func (r *PolicyResult) Record(state *pb.MachineState) {
+	state.PolicyWarnings = nil
+	for _, warning := range r.Warnings {
+		state.PolicyWarnings = append(state.PolicyWarnings, warning.Proto())
+	}
+}
+
+// EvaluatePolicy checks that a MachineState satisfies a Policy, returning an
+// error describing every rule the MachineState fails.
+//
+// A failed rule does not cause an error if the Policy contains a matching
+// PolicyWaiver which has not expired. Instead, the failure is recorded in the
+// returned PolicyResult (which is returned even if evaluation fails). Every
+// waiver must name a known rule and have an expiry time, justification, and
+// approver, otherwise the entire Policy is rejected.
+//
+// The MachineState should only come from VerifyAttestation or
+// ParseMachineState, as EvaluatePolicy does not perform any verification.
+func EvaluatePolicy(state *pb.MachineState, policy *pb.Policy) (*PolicyResult, error) {
+	ruleFailures, err := evaluatePolicyRules(state, policy)
+	if err != nil {
+		return nil, err
+	}
+	result := &PolicyResult{}
+	var failures []string
+	for _, failure := range ruleFailures {
+		if failure.Waiver != nil {
+			result.Warnings = append(result.Warnings, failure)
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", failure.Rule, failure.Err))
+	}
+	if len(failures) != 0 {
+		return result, fmt.Errorf("policy evaluation failed: %s", strings.Join(failures, "; "))
+	}
+	return result, nil
+}
+
+// evaluatePolicyRules returns every rule of the Policy which the MachineState
+// fails. Failures allowed by a waiver have their Waiver set.
+func evaluatePolicyRules(state *pb.MachineState, policy *pb.Policy) ([]PolicyWarning, error) {
+	if err := validateWaivers(policy.GetWaivers()); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	for i, denied := range policy.GetTpm().GetDeniedFirmware() {
+		if denied.GetMinimumFirmwareVersion() > denied.GetMaximumFirmwareVersion() {
+			return nil, fmt.Errorf("invalid policy: denied TPM firmware range %d is empty", i)
+		}
+	}
+
+	ruleFailures := evaluatePlatformPolicy(state.GetPlatform(), policy.GetPlatform())
+	ruleFailures = append(ruleFailures, evaluateKernelPolicy(state.GetLinuxKernel(), policy.GetKernel())...)
+	ruleFailures = append(ruleFailures, evaluateConfigFilePolicy(state.GetConfigFiles(), policy.GetConfigFiles())...)
+	ruleFailures = append(ruleFailures, evaluateTPMPolicy(state, policy.GetTpm())...)
+	for i := range ruleFailures {
+		ruleFailures[i].Waiver = findWaiver(policy.GetWaivers(), ruleFailures[i].Rule, state, time.Now())
+	}
+	return ruleFailures, nil
+}
+
+// evaluatePlatformPolicy returns the rules failed by the PlatformState. The
+// returned PolicyWarnings do not have a Waiver set.
+func evaluatePlatformPolicy(state *pb.PlatformState, policy *pb.PlatformPolicy) []PolicyWarning {
+	var failures []PolicyWarning
+	fail := func(rule string, format string, a ...interface{}) {
+		failures = append(failures, PolicyWarning{Rule: rule, Err: fmt.Errorf(format, a...)})
+	}
+
+	if allowed := policy.GetAllowedScrtmVersionIds(); len(allowed) != 0 {
+		if versionID, ok := state.GetFirmware().(*pb.PlatformState_ScrtmVersionId); ok {
+			found := false
+			for _, allowedID := range allowed {
+				if bytes.Equal(versionID.ScrtmVersionId, allowedID) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				fail(RuleAllowedSCRTMVersionIDs, "S-CRTM version ID %x is not allowed", versionID.ScrtmVersionId)
+			}
+		}
+	}
+
+	if minVersion := policy.GetMinimumGceFirmwareVersion(); minVersion != 0 {
+		if version, ok := state.GetFirmware().(*pb.PlatformState_GceVersion); ok && version.GceVersion < minVersion {
+			fail(RuleMinimumGCEFirmwareVersion, "GCE firmware version %d is less than %d", version.GceVersion, minVersion)
+		}
+	}
+
+	if minTech := policy.GetMinimumTechnology(); state.GetTechnology() < minTech {
+		fail(RuleMinimumTechnology, "technology %v is less secure than %v", state.GetTechnology(), minTech)
+	}
+	return failures
+}
+
+// evaluateKernelPolicy returns the rules failed by the LinuxKernelState. The
+// returned PolicyWarnings do not have a Waiver set.
+func evaluateKernelPolicy(state *pb.LinuxKernelState, policy *pb.KernelPolicy) []PolicyWarning {
+	var failures []PolicyWarning
+	fail := func(rule string, format string, a ...interface{}) {
+		failures = append(failures, PolicyWarning{Rule: rule, Err: fmt.Errorf(format, a...)})
+	}
+
+	if minLockdown := policy.GetMinimumLockdown(); state.GetLockdown() < minLockdown {
+		fail(RuleMinimumLockdown, "lockdown mode %v is less strict than %v", state.GetLockdown(), minLockdown)
+	}
+	if policy.GetRequireModuleSignatures() && state.GetModuleSignatures() != pb.Enforcement_ENFORCED {
+		fail(RuleRequireModuleSignatures, "module signatures are %v", state.GetModuleSignatures())
+	}
+	if policy.GetRequireKexecLoadDisabled() && state.GetKexecLoadDisabled() != pb.Enforcement_ENFORCED {
+		fail(RuleRequireKexecLoadDisabled, "disabling kexec_load is %v", state.GetKexecLoadDisabled())
+	}
+	return failures
+}
+
+// evaluateConfigFilePolicy returns the rules failed by the measured
+// configuration files, detecting files which have drifted from their expected
+// contents. The returned PolicyWarnings do not have a Waiver set.
+func evaluateConfigFilePolicy(files []*pb.ConfigFile, policies []*pb.ConfigFilePolicy) []PolicyWarning {
+	var failures []PolicyWarning
+	fail := func(format string, a ...interface{}) {
+		failures = append(failures, PolicyWarning{Rule: RuleConfigFiles, Err: fmt.Errorf(format, a...)})
+	}
+
+	for _, policy := range policies {
+		measured := false
+		for _, file := range files {
+			if file.GetPath() != policy.GetPath() {
+				continue
+			}
+			measured = true
+			if !containsBytes(policy.GetAllowedDigests(), file.GetDigest()) {
+				if len(file.GetDigest()) == 0 {
+					fail("%s is missing", policy.GetPath())
+				} else {
+					fail("%s has unexpected digest %x", policy.GetPath(), file.GetDigest())
+				}
+			}
+		}
+		if !measured {
+			fail("%s was not measured", policy.GetPath())
+		}
+	}
+	return failures
+}
+
+// evaluateTPMPolicy returns the rules failed by the machine's TPM. The returned
+// PolicyWarnings do not have a Waiver set.
+func evaluateTPMPolicy(state *pb.MachineState, policy *pb.TpmPolicy) []PolicyWarning {
+	denied := policy.GetDeniedFirmware()
+	if len(denied) == 0 {
+		return nil
+	}
+	firmwares := tpmFirmwareVersions(state)
+	if len(firmwares) == 0 {
+		return []PolicyWarning{{Rule: RuleDeniedTPMFirmware, Err: errors.New("TPM firmware version is unknown")}}
+	}
+	for _, firmware := range firmwares {
+		for _, r := range denied {
+			if r.GetManufacturerId() == firmware.manufacturer &&
+				firmware.version >= r.GetMinimumFirmwareVersion() && firmware.version <= r.GetMaximumFirmwareVersion() {
+				return []PolicyWarning{{Rule: RuleDeniedTPMFirmware, Err: fmt.Errorf("%v TPM firmware version 0x%08x (%s) is denied: %s",
+					attest.TCGVendorID(firmware.manufacturer), firmware.version, firmware.source, r.GetReason())}}
+			}
+		}
+	}
+	return nil
+}
+
+// tpmFirmware is a TPM's manufacturer and TPM_PT_FIRMWARE_VERSION_1, and
+// where they were found.
+type tpmFirmware struct {
+	manufacturer uint32
+	version      uint32
+	source       string
+}
+
+// tpmFirmwareVersions returns the TPM's firmware from the attributes of its
+// verified EK certificate (its firmware when it was manufactured) and from the
+// capabilities it reported (its current firmware), if present. The reported
+// capabilities are not verified, so they are only advisory: they cannot
+// override the EK certificate, and the TPM must pass the policy with both.
+func tpmFirmwareVersions(state *pb.MachineState) []tpmFirmware {
+	var firmwares []tpmFirmware
+	if info := state.GetTpmInfo(); info != nil {
+		firmwares = append(firmwares, tpmFirmware{info.GetManufacturerId(), info.GetFirmwareVersion(), "from the EK certificate"})
+	}
+	if caps := state.GetTpmCapabilities(); caps != nil {
+		firmwares = append(firmwares, tpmFirmware{caps.GetManufacturerId(), uint32(caps.GetFirmwareVersion() >> 32), "reported by the machine"})
+	}
+	return firmwares
+}
+
+func validateWaivers(waivers []*pb.PolicyWaiver) error {
+	for i, waiver := range waivers {
+		if !policyRules[waiver.GetRule()] {
+			return fmt.Errorf("waiver %d: unknown rule %q", i, waiver.GetRule())
+		}
+		if waiver.GetExpireTime() == nil {
+			return fmt.Errorf("waiver %d: missing expire_time", i)
+		}
+		if err := waiver.GetExpireTime().CheckValid(); err != nil {
+			return fmt.Errorf("waiver %d: %w", i, err)
+		}
+		if waiver.GetJustification() == "" {
+			return fmt.Errorf("waiver %d: missing justification", i)
+		}
+		if waiver.GetApprover() == "" {
+			return fmt.Errorf("waiver %d: missing approver", i)
+		}
+	}
+	return nil
+}
+
+// findWaiver returns the first waiver in effect for the rule and MachineState
+// at the provided time, or nil if there is none.
+func findWaiver(waivers []*pb.PolicyWaiver, rule string, state *pb.MachineState, now time.Time) *pb.PolicyWaiver {
+	for _, waiver := range waivers {
+		if waiver.GetRule() != rule {
+			continue
+		}
+		if !now.Before(waiver.GetExpireTime().AsTime()) {
+			continue
+		}
+		if name := waiver.GetAkName(); len(name) != 0 && !bytes.Equal(name, state.GetAkName()) {
+			continue
+		}
+		return waiver
+	}
+	return nil
+}
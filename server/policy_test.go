@@ -0,0 +1,342 @@
+package server
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var gceMachineState = &pb.MachineState{
+	Platform: &pb.PlatformState{
+		Firmware:   &pb.PlatformState_GceVersion{GceVersion: 1},
+		Technology: pb.GCEConfidentialTechnology_AMD_SEV,
+	},
+	AkName: []byte{0x00, 0x0b, 1, 2, 3, 4},
+}
+
+var strictPolicy = &pb.PlatformPolicy{
+	MinimumGceFirmwareVersion: 2,
+	MinimumTechnology:         pb.GCEConfidentialTechnology_AMD_SEV_ES,
+}
+
+func newTestWaiver(rule string, expire time.Time) *pb.PolicyWaiver {
+	return &pb.PolicyWaiver{
+		Rule:          rule,
+		ExpireTime:    timestamppb.New(expire),
+		Justification: "firmware rollout in progress",
+		Approver:      "security-team@example.com",
+	}
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	scrtmState := &pb.MachineState{Platform: &pb.PlatformState{
+		Firmware: &pb.PlatformState_ScrtmVersionId{ScrtmVersionId: []byte{1, 2}},
+	}}
+	subtests := []struct {
+		name    string
+		state   *pb.MachineState
+		policy  *pb.PlatformPolicy
+		wantErr bool
+	}{
+		{"EmptyPolicy", gceMachineState, nil, false},
+		{"SatisfiedPolicy", gceMachineState, &pb.PlatformPolicy{
+			MinimumGceFirmwareVersion: 1,
+			MinimumTechnology:         pb.GCEConfidentialTechnology_AMD_SEV,
+		}, false},
+		{"OldFirmware", gceMachineState, &pb.PlatformPolicy{MinimumGceFirmwareVersion: 2}, true},
+		{"WeakTechnology", gceMachineState, &pb.PlatformPolicy{MinimumTechnology: pb.GCEConfidentialTechnology_AMD_SEV_ES}, true},
+		{"AllowedSCRTM", scrtmState, &pb.PlatformPolicy{AllowedScrtmVersionIds: [][]byte{{3}, {1, 2}}}, false},
+		{"DisallowedSCRTM", scrtmState, &pb.PlatformPolicy{AllowedScrtmVersionIds: [][]byte{{3}}}, true},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			result, err := EvaluatePolicy(subtest.state, &pb.Policy{Platform: subtest.policy})
+			if (err != nil) != subtest.wantErr {
+				t.Errorf("EvaluatePolicy() got error %v, want error: %v", err, subtest.wantErr)
+			}
+			if len(result.Warnings) != 0 {
+				t.Errorf("EvaluatePolicy() without waivers got warnings: %v", result.Warnings)
+			}
+		})
+	}
+}
+
+func TestEvaluatePolicyWaivers(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	scopedWaiver := newTestWaiver(RuleMinimumTechnology, future)
+	scopedWaiver.AkName = []byte{0x00, 0x0b, 1, 2, 3, 4}
+	otherMachineWaiver := newTestWaiver(RuleMinimumTechnology, future)
+	otherMachineWaiver.AkName = []byte{0x00, 0x0b, 5, 6, 7, 8}
+
+	subtests := []struct {
+		name         string
+		waivers      []*pb.PolicyWaiver
+		wantErr      bool
+		wantWarnings []string
+	}{
+		{"NoWaivers", nil, true, nil},
+		{"AllRulesWaived", []*pb.PolicyWaiver{
+			newTestWaiver(RuleMinimumGCEFirmwareVersion, future),
+			newTestWaiver(RuleMinimumTechnology, future),
+		}, false, []string{RuleMinimumGCEFirmwareVersion, RuleMinimumTechnology}},
+		{"OneRuleWaived", []*pb.PolicyWaiver{
+			newTestWaiver(RuleMinimumGCEFirmwareVersion, future),
+		}, true, []string{RuleMinimumGCEFirmwareVersion}},
+		{"ExpiredWaiver", []*pb.PolicyWaiver{
+			newTestWaiver(RuleMinimumGCEFirmwareVersion, future),
+			newTestWaiver(RuleMinimumTechnology, past),
+		}, true, []string{RuleMinimumGCEFirmwareVersion}},
+		{"MachineScopedWaiver", []*pb.PolicyWaiver{
+			newTestWaiver(RuleMinimumGCEFirmwareVersion, future),
+			scopedWaiver,
+		}, false, []string{RuleMinimumGCEFirmwareVersion, RuleMinimumTechnology}},
+		{"OtherMachineWaiver", []*pb.PolicyWaiver{
+			newTestWaiver(RuleMinimumGCEFirmwareVersion, future),
+			otherMachineWaiver,
+		}, true, []string{RuleMinimumGCEFirmwareVersion}},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			policy := &pb.Policy{Platform: strictPolicy, Waivers: subtest.waivers}
+			result, err := EvaluatePolicy(gceMachineState, policy)
+			if (err != nil) != subtest.wantErr {
+				t.Errorf("EvaluatePolicy() got error %v, want error: %v", err, subtest.wantErr)
+			}
+			if len(result.Warnings) != len(subtest.wantWarnings) {
+				t.Fatalf("EvaluatePolicy() got warnings %v, want warnings for %v", result.Warnings, subtest.wantWarnings)
+			}
+			for i, warning := range result.Warnings {
+				if warning.Rule != subtest.wantWarnings[i] {
+					t.Errorf("warning %d is for rule %q, want %q", i, warning.Rule, subtest.wantWarnings[i])
+				}
+				if warning.Err == nil || warning.Waiver == nil {
+					t.Errorf("warning %d does not record the failure and waiver: %v", i, warning)
+				}
+			}
+
+			state := proto.Clone(gceMachineState).(*pb.MachineState)
+			result.Record(state)
+			if len(state.GetPolicyWarnings()) != len(subtest.wantWarnings) {
+				t.Fatalf("Record() stored warnings %v, want warnings for %v", state.GetPolicyWarnings(), subtest.wantWarnings)
+			}
+			for i, warning := range state.GetPolicyWarnings() {
+				if warning.GetRule() != subtest.wantWarnings[i] || warning.GetError() == "" || warning.GetWaiver() == nil {
+					t.Errorf("recorded warning %d is %v, want a warning for %q", i, warning, subtest.wantWarnings[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluatePolicyInvalidWaivers(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	noExpiry := newTestWaiver(RuleMinimumTechnology, future)
+	noExpiry.ExpireTime = nil
+	noJustification := newTestWaiver(RuleMinimumTechnology, future)
+	noJustification.Justification = ""
+	noApprover := newTestWaiver(RuleMinimumTechnology, future)
+	noApprover.Approver = ""
+
+	subtests := []struct {
+		name   string
+		waiver *pb.PolicyWaiver
+	}{
+		{"UnknownRule", newTestWaiver("platform.unknown", future)},
+		{"MissingExpiry", noExpiry},
+		{"MissingJustification", noJustification},
+		{"MissingApprover", noApprover},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			// Invalid waivers are rejected even if the policy would pass.
+			policy := &pb.Policy{Waivers: []*pb.PolicyWaiver{subtest.waiver}}
+			if _, err := EvaluatePolicy(gceMachineState, policy); err == nil {
+				t.Error("EvaluatePolicy() should have failed")
+			}
+		})
+	}
+}
+
+func TestEvaluateKernelPolicy(t *testing.T) {
+	lockedDown := &pb.MachineState{LinuxKernel: &pb.LinuxKernelState{
+		Lockdown:          pb.LockdownMode_LOCKDOWN_INTEGRITY,
+		ModuleSignatures:  pb.Enforcement_ENFORCED,
+		KexecLoadDisabled: pb.Enforcement_ENFORCED,
+	}}
+	unrestricted := &pb.MachineState{LinuxKernel: &pb.LinuxKernelState{
+		Lockdown:          pb.LockdownMode_LOCKDOWN_NONE,
+		ModuleSignatures:  pb.Enforcement_NOT_ENFORCED,
+		KexecLoadDisabled: pb.Enforcement_NOT_ENFORCED,
+	}}
+	strictKernelPolicy := &pb.KernelPolicy{
+		MinimumLockdown:          pb.LockdownMode_LOCKDOWN_INTEGRITY,
+		RequireModuleSignatures:  true,
+		RequireKexecLoadDisabled: true,
+	}
+	subtests := []struct {
+		name         string
+		state        *pb.MachineState
+		policy       *pb.KernelPolicy
+		wantFailures []string
+	}{
+		{"EmptyPolicy", unrestricted, nil, nil},
+		{"SatisfiedPolicy", lockedDown, strictKernelPolicy, nil},
+		{"StricterLockdown", lockedDown, &pb.KernelPolicy{MinimumLockdown: pb.LockdownMode_LOCKDOWN_CONFIDENTIALITY},
+			[]string{RuleMinimumLockdown}},
+		{"Unrestricted", unrestricted, strictKernelPolicy,
+			[]string{RuleMinimumLockdown, RuleRequireModuleSignatures, RuleRequireKexecLoadDisabled}},
+		{"UnknownState", &pb.MachineState{}, strictKernelPolicy,
+			[]string{RuleMinimumLockdown, RuleRequireModuleSignatures, RuleRequireKexecLoadDisabled}},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			failures := evaluateKernelPolicy(subtest.state.GetLinuxKernel(), subtest.policy)
+			if len(failures) != len(subtest.wantFailures) {
+				t.Fatalf("got failures %v, want failures of %v", failures, subtest.wantFailures)
+			}
+			for i, failure := range failures {
+				if failure.Rule != subtest.wantFailures[i] {
+					t.Errorf("failure %d is for rule %q, want %q", i, failure.Rule, subtest.wantFailures[i])
+				}
+			}
+
+			_, err := EvaluatePolicy(subtest.state, &pb.Policy{Kernel: subtest.policy})
+			if (err != nil) != (len(subtest.wantFailures) != 0) {
+				t.Errorf("EvaluatePolicy() got error %v, want error: %v", err, len(subtest.wantFailures) != 0)
+			}
+		})
+	}
+
+	// Kernel rules can be waived like any other rule.
+	policy := &pb.Policy{
+		Kernel:  &pb.KernelPolicy{RequireKexecLoadDisabled: true},
+		Waivers: []*pb.PolicyWaiver{newTestWaiver(RuleRequireKexecLoadDisabled, time.Now().Add(time.Hour))},
+	}
+	result, err := EvaluatePolicy(unrestricted, policy)
+	if err != nil {
+		t.Fatalf("EvaluatePolicy() with a waiver failed: %v", err)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Rule != RuleRequireKexecLoadDisabled {
+		t.Errorf("got warnings %v, want a warning for %q", result.Warnings, RuleRequireKexecLoadDisabled)
+	}
+}
+
+func TestEvaluateConfigFilePolicy(t *testing.T) {
+	sshdConfig := sha256.Sum256([]byte("PermitRootLogin no\n"))
+	driftedConfig := sha256.Sum256([]byte("PermitRootLogin yes\n"))
+	state := &pb.MachineState{ConfigFiles: []*pb.ConfigFile{
+		{Path: "/etc/ssh/sshd_config", Digest: sshdConfig[:]},
+		{Path: "/etc/sudoers"},
+	}}
+	drifted := &pb.MachineState{ConfigFiles: []*pb.ConfigFile{
+		{Path: "/etc/ssh/sshd_config", Digest: sshdConfig[:]},
+		{Path: "/etc/ssh/sshd_config", Digest: driftedConfig[:]},
+	}}
+	sshdPolicy := &pb.ConfigFilePolicy{Path: "/etc/ssh/sshd_config", AllowedDigests: [][]byte{sshdConfig[:]}}
+	subtests := []struct {
+		name         string
+		state        *pb.MachineState
+		policy       []*pb.ConfigFilePolicy
+		wantFailures int
+	}{
+		{"EmptyPolicy", drifted, nil, 0},
+		{"Allowed", state, []*pb.ConfigFilePolicy{sshdPolicy}, 0},
+		{"OneOfSeveral", state, []*pb.ConfigFilePolicy{
+			{Path: "/etc/ssh/sshd_config", AllowedDigests: [][]byte{driftedConfig[:], sshdConfig[:]}},
+		}, 0},
+		{"AllowedMissing", state, []*pb.ConfigFilePolicy{{Path: "/etc/sudoers", AllowedDigests: [][]byte{nil}}}, 0},
+		{"Missing", state, []*pb.ConfigFilePolicy{{Path: "/etc/sudoers", AllowedDigests: [][]byte{sshdConfig[:]}}}, 1},
+		{"NotMeasured", state, []*pb.ConfigFilePolicy{{Path: "/etc/docker/daemon.json"}}, 1},
+		{"Drifted", drifted, []*pb.ConfigFilePolicy{sshdPolicy}, 1},
+		{"Unmeasured", &pb.MachineState{}, []*pb.ConfigFilePolicy{sshdPolicy}, 1},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			failures := evaluateConfigFilePolicy(subtest.state.GetConfigFiles(), subtest.policy)
+			if len(failures) != subtest.wantFailures {
+				t.Fatalf("got failures %v, want %d failures", failures, subtest.wantFailures)
+			}
+			for _, failure := range failures {
+				if failure.Rule != RuleConfigFiles {
+					t.Errorf("failure is for rule %q, want %q", failure.Rule, RuleConfigFiles)
+				}
+			}
+
+			_, err := EvaluatePolicy(subtest.state, &pb.Policy{ConfigFiles: subtest.policy})
+			if (err != nil) != (subtest.wantFailures != 0) {
+				t.Errorf("EvaluatePolicy() got error %v, want error: %v", err, subtest.wantFailures != 0)
+			}
+		})
+	}
+}
+
+func TestEvaluateTPMPolicy(t *testing.T) {
+	const infineon = 0x49465800
+	rocaPolicy := &pb.TpmPolicy{DeniedFirmware: []*pb.TpmFirmwareRange{
+		{ManufacturerId: infineon, MinimumFirmwareVersion: 0x00050000, MaximumFirmwareVersion: 0x0005003D, Reason: "ROCA, CVE-2017-15361"},
+		{ManufacturerId: infineon, MinimumFirmwareVersion: 0x00070000, MaximumFirmwareVersion: 0x0007003D, Reason: "ROCA, CVE-2017-15361"},
+	}}
+	reported := func(manufacturer uint32, version uint64) *pb.MachineState {
+		return &pb.MachineState{TpmCapabilities: &pb.TpmCapabilities{ManufacturerId: manufacturer, FirmwareVersion: version}}
+	}
+	subtests := []struct {
+		name     string
+		state    *pb.MachineState
+		policy   *pb.TpmPolicy
+		wantFail bool
+	}{
+		{"EmptyPolicy", &pb.MachineState{}, nil, false},
+		{"Vulnerable", reported(infineon, 0x0007003C_00000000), rocaPolicy, true},
+		{"Patched", reported(infineon, 0x0007003E_00000000), rocaPolicy, false},
+		{"OtherManufacturer", reported(0x4E544300, 0x0007003C_00000000), rocaPolicy, false},
+		{"EKCertificate", &pb.MachineState{TpmInfo: &pb.TpmInfo{ManufacturerId: infineon, FirmwareVersion: 0x00050020}}, rocaPolicy, true},
+		// The reported firmware is not verified, so it cannot override the
+		// EK certificate's: both must pass.
+		{"ReportedUpdate", &pb.MachineState{
+			TpmInfo:         &pb.TpmInfo{ManufacturerId: infineon, FirmwareVersion: 0x00050020},
+			TpmCapabilities: &pb.TpmCapabilities{ManufacturerId: infineon, FirmwareVersion: 0x0005003F_00000000},
+		}, rocaPolicy, true},
+		{"ReportedOtherManufacturer", &pb.MachineState{
+			TpmInfo:         &pb.TpmInfo{ManufacturerId: infineon, FirmwareVersion: 0x00050020},
+			TpmCapabilities: &pb.TpmCapabilities{ManufacturerId: 0x4E544300, FirmwareVersion: 0x0007003C_00000000},
+		}, rocaPolicy, true},
+		{"ReportedVulnerable", &pb.MachineState{
+			TpmInfo:         &pb.TpmInfo{ManufacturerId: infineon, FirmwareVersion: 0x0005003F},
+			TpmCapabilities: &pb.TpmCapabilities{ManufacturerId: infineon, FirmwareVersion: 0x00050020_00000000},
+		}, rocaPolicy, true},
+		{"BothPatched", &pb.MachineState{
+			TpmInfo:         &pb.TpmInfo{ManufacturerId: infineon, FirmwareVersion: 0x0005003F},
+			TpmCapabilities: &pb.TpmCapabilities{ManufacturerId: infineon, FirmwareVersion: 0x0005003F_00000000},
+		}, rocaPolicy, false},
+		{"Unknown", &pb.MachineState{}, rocaPolicy, true},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			failures := evaluateTPMPolicy(subtest.state, subtest.policy)
+			if (len(failures) != 0) != subtest.wantFail {
+				t.Fatalf("got failures %v, want failure: %v", failures, subtest.wantFail)
+			}
+			for _, failure := range failures {
+				if failure.Rule != RuleDeniedTPMFirmware {
+					t.Errorf("failure is for rule %q, want %q", failure.Rule, RuleDeniedTPMFirmware)
+				}
+			}
+
+			_, err := EvaluatePolicy(subtest.state, &pb.Policy{Tpm: subtest.policy})
+			if (err != nil) != subtest.wantFail {
+				t.Errorf("EvaluatePolicy() got error %v, want error: %v", err, subtest.wantFail)
+			}
+		})
+	}
+
+	empty := &pb.TpmPolicy{DeniedFirmware: []*pb.TpmFirmwareRange{{MinimumFirmwareVersion: 2, MaximumFirmwareVersion: 1}}}
+	if _, err := EvaluatePolicy(&pb.MachineState{}, &pb.Policy{Tpm: empty}); err == nil {
+		t.Error("EvaluatePolicy() with an empty firmware range should fail")
+	}
+}
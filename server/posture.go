@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+)
+
+// EFIAction is the type of EV_EFI_ACTION events, from the TCG PC Client
+// Platform Firmware Profile Specification, Table 14 Events.
+const EFIAction uint32 = 0x80000007
+
+// Errors wrapped by VerifyAttestation when the machine's verified firmware,
+// Secure Boot or kernel state does not satisfy a requirement of its VerifyOpts. Each
+// names the violated VerifyOpts field.
+var (
+	ErrSecureBootDisabled = errors.New("VerifyOpts.RequireSecureBoot: Secure Boot was disabled")
+	ErrDebugMode          = errors.New("VerifyOpts.ForbidDebugMode: firmware was in UEFI debug mode")
+	ErrFirmwareTooOld     = errors.New("VerifyOpts.MinimumFirmwareVersion: firmware is too old")
+	ErrDBCertNotAllowed   = errors.New("VerifyOpts.AllowedDBCerts: Secure Boot db contains a certificate which is not allowed")
+	ErrDBXCertMissing     = errors.New("VerifyOpts.RequiredDBXCerts: Secure Boot dbx is missing a required certificate")
+	ErrKernelNotAllowed   = errors.New("VerifyOpts.AllowedKernelDigests: kernel image is not allowed")
+	ErrInitrdNotAllowed   = errors.New("VerifyOpts.AllowedInitrdDigests: initrd image is not allowed")
+	ErrCmdlineNotAllowed  = errors.New("VerifyOpts.AllowedKernelCmdlines: kernel command line is not allowed")
+)
+
+// checkPosture checks the firmware, Secure Boot and kernel requirements of
+// opts against a verified MachineState.
+func checkPosture(state *pb.MachineState, opts VerifyOpts) error {
+	if err := checkKernel(state.GetLinuxKernel(), opts); err != nil {
+		return err
+	}
+	if opts.ForbidDebugMode && uefiDebugMode(state) {
+		return ErrDebugMode
+	}
+	if opts.MinimumFirmwareVersion != 0 {
+		gce, ok := state.GetPlatform().GetFirmware().(*pb.PlatformState_GceVersion)
+		if !ok {
+			return fmt.Errorf("%w: firmware version is unknown (only GCE firmware versions are known)", ErrFirmwareTooOld)
+		}
+		if gce.GceVersion < opts.MinimumFirmwareVersion {
+			return fmt.Errorf("%w: version %d is less than %d", ErrFirmwareTooOld, gce.GceVersion, opts.MinimumFirmwareVersion)
+		}
+	}
+	if !opts.RequireSecureBoot && len(opts.AllowedDBCerts) == 0 && len(opts.RequiredDBXCerts) == 0 {
+		return nil
+	}
+
+	secureBoot := state.GetSecureBoot()
+	if secureBoot == nil {
+		if opts.RequireSecureBoot {
+			return fmt.Errorf("%w: Secure Boot state is unknown", ErrSecureBootDisabled)
+		}
+		return errors.New("Secure Boot db and dbx are unknown")
+	}
+	if opts.RequireSecureBoot && !secureBoot.GetEnabled() {
+		return ErrSecureBootDisabled
+	}
+	if len(opts.AllowedDBCerts) != 0 {
+		for _, der := range secureBoot.GetDb().GetCerts() {
+			if !containsBytes(opts.AllowedDBCerts, der) {
+				return fmt.Errorf("%w: %q", ErrDBCertNotAllowed, certName(der))
+			}
+		}
+	}
+	for _, der := range opts.RequiredDBXCerts {
+		if !containsBytes(secureBoot.GetDbx().GetCerts(), der) {
+			return fmt.Errorf("%w: %q", ErrDBXCertMissing, certName(der))
+		}
+	}
+	return nil
+}
+
+// checkKernel checks the kernel, initrd and command line pinned by opts.
+func checkKernel(kernel *pb.LinuxKernelState, opts VerifyOpts) error {
+	if len(opts.AllowedKernelDigests) != 0 {
+		if kernel.GetKernelDigest() == nil && kernel.GetKernelAuthenticodeDigest() == nil {
+			return fmt.Errorf("%w: no kernel image was measured", ErrKernelNotAllowed)
+		}
+		if !containsBytes(opts.AllowedKernelDigests, kernel.GetKernelDigest()) &&
+			!containsBytes(opts.AllowedKernelDigests, kernel.GetKernelAuthenticodeDigest()) {
+			return fmt.Errorf("%w: digest %x, Authenticode digest %x", ErrKernelNotAllowed,
+				kernel.GetKernelDigest(), kernel.GetKernelAuthenticodeDigest())
+		}
+	}
+	if len(opts.AllowedInitrdDigests) != 0 {
+		if len(kernel.GetInitrdDigests()) == 0 {
+			return fmt.Errorf("%w: no initrd image was measured", ErrInitrdNotAllowed)
+		}
+		for _, digest := range kernel.GetInitrdDigests() {
+			if !containsBytes(opts.AllowedInitrdDigests, digest) {
+				return fmt.Errorf("%w: digest %x", ErrInitrdNotAllowed, digest)
+			}
+		}
+	}
+	if len(opts.AllowedKernelCmdlines) != 0 {
+		if kernel == nil {
+			return fmt.Errorf("%w: no kernel command line was measured", ErrCmdlineNotAllowed)
+		}
+		allowed := false
+		for _, cmdline := range opts.AllowedKernelCmdlines {
+			allowed = allowed || cmdline == kernel.GetCommandLine()
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %q", ErrCmdlineNotAllowed, kernel.GetCommandLine())
+		}
+	}
+	return nil
+}
+
+// certName returns the subject of a DER-encoded certificate, for errors.
+func certName(der []byte) string {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "unparsable certificate"
+	}
+	return cert.Subject.String()
+}
+
+// uefiDebugMode reports whether the verified events show the firmware was in
+// debug mode. Any PCR7 event with the digest of uefiDebugModeData counts,
+// whatever its type or data, as only the digest is covered by the PCRs.
+func uefiDebugMode(state *pb.MachineState) bool {
+	hash, err := tpm2.Algorithm(state.GetHash()).Hash()
+	if err != nil {
+		return false
+	}
+	h := hash.New()
+	h.Write(uefiDebugModeData)
+	digest := h.Sum(nil)
+	for _, event := range state.GetRawEvents() {
+		if event.GetPcrIndex() == 7 && bytes.Equal(event.GetDigest(), digest) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsBytes reports whether values contains value, such as a DER
+// certificate or a digest.
+func containsBytes(values [][]byte, value []byte) bool {
+	for _, v := range values {
+		if bytes.Equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+)
+
+var bootholeCerts = [][]byte{RevokedCanonicalBootholeCert, RevokedDebianBootholeCert, RevokedCiscoCert}
+
+// withDebugMode returns the state with the PCR7 event measured when the
+// firmware is in debug mode appended, logged with the provided data.
+func withDebugMode(t *testing.T, state *pb.MachineState, data []byte) *pb.MachineState {
+	t.Helper()
+	hash, err := tpm2.Algorithm(state.GetHash()).Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := hash.New()
+	h.Write(uefiDebugModeData)
+	state.RawEvents = append(state.RawEvents, &pb.Event{
+		PcrIndex:       7,
+		UntrustedType:  EFIAction,
+		Data:           data,
+		Digest:         h.Sum(nil),
+		DigestVerified: bytes.Equal(data, uefiDebugModeData),
+	})
+	return state
+}
+
+func TestCheckPosture(t *testing.T) {
+	debugMode := withDebugMode(t, parseTestMachineState(t, test.Rhel8GCE), uefiDebugModeData)
+	// Rewriting the logged data does not hide the measurement.
+	rewrittenDebugMode := withDebugMode(t, parseTestMachineState(t, test.Rhel8GCE), []byte("Something Else"))
+	notDebugMode := parseTestMachineState(t, test.Rhel8GCE)
+	notDebugMode.RawEvents = append(notDebugMode.RawEvents, &pb.Event{
+		PcrIndex:      7,
+		UntrustedType: EFIAction,
+		Data:          uefiDebugModeData,
+		Digest:        make([]byte, 20),
+	})
+	rhel8 := parseTestMachineState(t, test.Rhel8GCE).GetLinuxKernel()
+	arch := parseTestMachineState(t, test.ArchLinuxWorkstation).GetLinuxKernel()
+
+	subtests := []struct {
+		name    string
+		state   *pb.MachineState
+		opts    VerifyOpts
+		wantErr error
+	}{
+		{"NoRequirements", parseTestMachineState(t, test.UbuntuAmdSevGCE), VerifyOpts{}, nil},
+		{"SecureBootEnabled", parseTestMachineState(t, test.Rhel8GCE), VerifyOpts{RequireSecureBoot: true}, nil},
+		{"SecureBootDisabled", parseTestMachineState(t, test.UbuntuAmdSevGCE), VerifyOpts{RequireSecureBoot: true}, ErrSecureBootDisabled},
+		{"DebugModeAllowed", debugMode, VerifyOpts{}, nil},
+		{"DebugModeForbidden", debugMode, VerifyOpts{ForbidDebugMode: true}, ErrDebugMode},
+		{"DebugModeDataRewritten", rewrittenDebugMode, VerifyOpts{ForbidDebugMode: true}, ErrDebugMode},
+		{"DebugModeDataOnly", notDebugMode, VerifyOpts{ForbidDebugMode: true}, nil},
+		{"NoDebugMode", parseTestMachineState(t, test.Rhel8GCE), VerifyOpts{ForbidDebugMode: true}, nil},
+		{"FirmwareVersion", parseTestMachineState(t, test.Rhel8GCE), VerifyOpts{MinimumFirmwareVersion: 1}, nil},
+		{"FirmwareTooOld", parseTestMachineState(t, test.Rhel8GCE), VerifyOpts{MinimumFirmwareVersion: 2}, ErrFirmwareTooOld},
+		{"FirmwareVersionUnknown", parseTestMachineState(t, test.GlinuxNoSecureBootLaptop), VerifyOpts{MinimumFirmwareVersion: 1}, ErrFirmwareTooOld},
+		{"DBCertsAllowed", parseTestMachineState(t, test.Rhel8GCE), VerifyOpts{
+			AllowedDBCerts: [][]byte{MicrosoftUEFICA2011Cert, WindowsProductionPCA2011Cert},
+		}, nil},
+		{"DBCertNotAllowed", parseTestMachineState(t, test.GlinuxNoSecureBootLaptop), VerifyOpts{
+			AllowedDBCerts: [][]byte{MicrosoftUEFICA2011Cert, WindowsProductionPCA2011Cert},
+		}, ErrDBCertNotAllowed},
+		{"DBXCertsPresent", parseTestMachineState(t, test.Rhel8GCE), VerifyOpts{RequiredDBXCerts: bootholeCerts}, nil},
+		{"DBXCertMissing", parseTestMachineState(t, test.UbuntuAmdSevGCE), VerifyOpts{RequiredDBXCerts: bootholeCerts}, ErrDBXCertMissing},
+		{"KernelAllowed", parseTestMachineState(t, test.Rhel8GCE), VerifyOpts{
+			AllowedKernelDigests:  [][]byte{rhel8.GetKernelDigest()},
+			AllowedInitrdDigests:  rhel8.GetInitrdDigests(),
+			AllowedKernelCmdlines: []string{rhel8.GetCommandLine()},
+		}, nil},
+		{"KernelAuthenticodeAllowed", parseTestMachineState(t, test.ArchLinuxWorkstation), VerifyOpts{
+			AllowedKernelDigests: [][]byte{rhel8.GetKernelDigest(), arch.GetKernelAuthenticodeDigest()},
+		}, nil},
+		{"KernelNotAllowed", parseTestMachineState(t, test.UbuntuAmdSevGCE), VerifyOpts{AllowedKernelDigests: [][]byte{rhel8.GetKernelDigest()}}, ErrKernelNotAllowed},
+		{"KernelNotMeasured", parseTestMachineState(t, test.Debian10GCE), VerifyOpts{AllowedKernelDigests: [][]byte{rhel8.GetKernelDigest()}}, ErrKernelNotAllowed},
+		{"InitrdNotAllowed", parseTestMachineState(t, test.UbuntuAmdSevGCE), VerifyOpts{AllowedInitrdDigests: rhel8.GetInitrdDigests()}, ErrInitrdNotAllowed},
+		{"InitrdNotMeasured", parseTestMachineState(t, test.ArchLinuxWorkstation), VerifyOpts{AllowedInitrdDigests: rhel8.GetInitrdDigests()}, ErrInitrdNotAllowed},
+		{"CmdlineNotAllowed", parseTestMachineState(t, test.ArchLinuxWorkstation), VerifyOpts{AllowedKernelCmdlines: []string{rhel8.GetCommandLine()}}, ErrCmdlineNotAllowed},
+		{"CmdlineNotMeasured", parseTestMachineState(t, test.Debian10GCE), VerifyOpts{AllowedKernelCmdlines: []string{""}}, ErrCmdlineNotAllowed},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			err := checkPosture(subtest.state, subtest.opts)
+			if subtest.wantErr == nil && err != nil {
+				t.Errorf("checkPosture() failed: %v", err)
+			}
+			if subtest.wantErr != nil && !errors.Is(err, subtest.wantErr) {
+				t.Errorf("checkPosture() got error %v, want %v", err, subtest.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyPosture(t *testing.T) {
+	rwc := test.GetPlatformTPM(t, test.UbuntuAmdSevGCE)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+	nonce := []byte("posture nonce")
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+
+	opts := VerifyOpts{
+		Nonce:                  nonce,
+		TrustedAKs:             []crypto.PublicKey{ak.PublicKey()},
+		MinimumFirmwareVersion: 1,
+	}
+	if _, err := VerifyAttestation(attestation, opts); err != nil {
+		t.Errorf("failed to verify: %v", err)
+	}
+	opts.RequireSecureBoot = true
+	if _, err := VerifyAttestation(attestation, opts); !errors.Is(err, ErrSecureBootDisabled) {
+		t.Errorf("got error %v, want %v", err, ErrSecureBootDisabled)
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"google.golang.org/protobuf/proto"
+)
+
+// Audience selects which parts of a verified MachineState RedactMachineState
+// keeps, so the result of a single verification can be shared with parties
+// who should not see the machine's internal details.
+type Audience int
+
+// Supported audiences, from the most to the least trusted.
+const (
+	// AudienceOperator is the operator of the attested machines, who sees the
+	// complete MachineState.
+	AudienceOperator Audience = iota
+	// AudienceAuditor reviews verification results and the policy waivers in
+	// use. The kernel command line, the GRUB commands, the paths of GRUB
+	// files, executions and configuration files, the labels of key events and
+	// key derivations, and the data of each event are removed, as they can
+	// contain secrets or internal configuration (e.g. device names, boot
+	// parameters and service names). The event and file digests are kept, so
+	// they can still be compared against known good values.
+	AudienceAuditor
+	// AudienceRelyingParty is an external party that only needs the security
+	// posture of the machine. In addition to what is removed for auditors, the
+	// events, the GCE instance information, the AK name, and the policy
+	// warnings (including the waivers' justifications and approvers) are
+	// removed.
+	AudienceRelyingParty
+)
+
+// RedactMachineState returns a copy of a verified MachineState, with the
+// details which the audience should not see removed. The copy is only meant to
+// be shared with the audience: it can no longer be used with functions such as
+// IssueEAT, which compute their results from the removed events.
+func RedactMachineState(ms *pb.MachineState, audience Audience) (*pb.MachineState, error) {
+	if err := checkAudience(audience); err != nil {
+		return nil, err
+	}
+	redacted := proto.Clone(ms).(*pb.MachineState)
+	if audience == AudienceOperator {
+		return redacted, nil
+	}
+
+	if kernel := redacted.GetLinuxKernel(); kernel != nil {
+		kernel.CommandLine = ""
+	}
+	if grub := redacted.GetGrub(); grub != nil {
+		grub.Commands = nil
+		for _, file := range grub.GetFiles() {
+			file.Path = ""
+		}
+	}
+	for _, execution := range redacted.GetExecutions() {
+		execution.Path = ""
+	}
+	for _, file := range redacted.GetConfigFiles() {
+		file.Path = ""
+	}
+	for _, event := range redacted.GetKeyEvents() {
+		event.Label = ""
+	}
+	for _, derivation := range redacted.GetKeyDerivations() {
+		derivation.Label = ""
+	}
+	for _, event := range redacted.GetRawEvents() {
+		event.Data = nil
+	}
+	if audience == AudienceAuditor {
+		return redacted, nil
+	}
+
+	redacted.RawEvents = nil
+	if platform := redacted.GetPlatform(); platform != nil {
+		platform.InstanceInfo = nil
+	}
+	redacted.AkName = nil
+	redacted.PolicyWarnings = nil
+	return redacted, nil
+}
+
+func checkAudience(audience Audience) error {
+	if audience < AudienceOperator || audience > AudienceRelyingParty {
+		return fmt.Errorf("unknown audience: %d", audience)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"testing"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"google.golang.org/protobuf/proto"
+)
+
+func testMachineState() *pb.MachineState {
+	return &pb.MachineState{
+		Platform: &pb.PlatformState{
+			Firmware:     &pb.PlatformState_GceVersion{GceVersion: 1},
+			Technology:   pb.GCEConfidentialTechnology_AMD_SEV,
+			InstanceInfo: &pb.GCEInstanceInfo{ProjectId: "internal-project", InstanceName: "db-1"},
+		},
+		RawEvents: []*pb.Event{{PcrIndex: 8, Data: []byte("root=/dev/sda1 secret=hunter2"), Digest: []byte{1, 2, 3}}},
+		Hash:      tpmpb.HashAlgo_SHA256,
+		TpmInfo:   &pb.TpmInfo{Manufacturer: "Google"},
+		LinuxKernel: &pb.LinuxKernelState{
+			CommandLine: "root=/dev/sda1 secret=hunter2",
+			Swap:        pb.DataAtRestProtection_PROTECTION_DISABLED,
+		},
+		Grub: &pb.GrubState{
+			Commands: []string{"linux /vmlinuz root=/dev/sda1 secret=hunter2"},
+			Files:    []*pb.GrubFile{{Path: "/vmlinuz", Digest: []byte{4, 5, 6}}},
+		},
+		Executions:     []*pb.Execution{{Path: "/opt/billing/bin/export", Digest: []byte{7, 8, 9}}},
+		ConfigFiles:    []*pb.ConfigFile{{Path: "/etc/billing/db.conf", Digest: []byte{10, 11, 12}}},
+		KeyEvents:      []*pb.KeyLifecycleEvent{{Action: pb.KeyAction_KEY_CREATED, Name: []byte{0, 0xb, 3}, Label: "billing-db"}},
+		KeyDerivations: []*pb.KeyDerivation{{KeyName: []byte{0, 0xb, 4}, Label: "billing-tls"}},
+		AkName:         []byte{0, 0xb, 1, 2},
+		PolicyWarnings: []*pb.PolicyWarning{{Rule: "platform.minimum_technology", Waiver: &pb.PolicyWaiver{Approver: "alice"}}},
+	}
+}
+
+func TestRedactMachineState(t *testing.T) {
+	auditor := testMachineState()
+	auditor.LinuxKernel.CommandLine = ""
+	auditor.RawEvents[0].Data = nil
+	auditor.Grub.Commands = nil
+	auditor.Grub.Files[0].Path = ""
+	auditor.Executions[0].Path = ""
+	auditor.ConfigFiles[0].Path = ""
+	auditor.KeyEvents[0].Label = ""
+	auditor.KeyDerivations[0].Label = ""
+
+	relyingParty := proto.Clone(auditor).(*pb.MachineState)
+	relyingParty.RawEvents = nil
+	relyingParty.Platform.InstanceInfo = nil
+	relyingParty.AkName = nil
+	relyingParty.PolicyWarnings = nil
+
+	testcases := []struct {
+		name     string
+		audience Audience
+		want     *pb.MachineState
+	}{
+		{"Operator", AudienceOperator, testMachineState()},
+		{"Auditor", AudienceAuditor, auditor},
+		{"RelyingParty", AudienceRelyingParty, relyingParty},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			ms := testMachineState()
+			got, err := RedactMachineState(ms, testcase.audience)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(got, testcase.want) {
+				t.Errorf("RedactMachineState() = %v, want %v", got, testcase.want)
+			}
+			if !proto.Equal(ms, testMachineState()) {
+				t.Error("RedactMachineState() modified its input")
+			}
+		})
+	}
+}
+
+func TestRedactMachineStateUnknownAudience(t *testing.T) {
+	if _, err := RedactMachineState(testMachineState(), AudienceRelyingParty+1); err == nil {
+		t.Error("RedactMachineState() with an unknown audience should have failed")
+	}
+}
+
+func TestRedactEmptyMachineState(t *testing.T) {
+	got, err := RedactMachineState(&pb.MachineState{}, AudienceRelyingParty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, &pb.MachineState{}) {
+		t.Errorf("RedactMachineState() = %v, want an empty MachineState", got)
+	}
+}
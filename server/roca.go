@@ -0,0 +1,90 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrROCAVulnerable is matched (with errors.Is) by the errors for RSA keys
+// generated by Infineon firmware with the ROCA vulnerability (CVE-2017-15361),
+// whose private keys can be computed from their public keys.
+var ErrROCAVulnerable = errors.New("RSA key is vulnerable to ROCA (CVE-2017-15361)")
+
+// rocaGenerator is the generator of the primes of vulnerable keys.
+const rocaGenerator = 65537
+
+// rocaPrimes are the small primes used to fingerprint vulnerable keys, from
+// "The Return of Coppersmith's Attack" (Nemec et al., CCS 2017), Section 2.3.
+var rocaPrimes = []int64{
+	3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71,
+	73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131, 137, 139, 149, 151,
+	157, 163, 167,
+}
+
+// rocaResidues[i] is the set of powers of the generator modulo rocaPrimes[i].
+var rocaResidues = func() []map[int64]bool {
+	residues := make([]map[int64]bool, len(rocaPrimes))
+	for i, p := range rocaPrimes {
+		residues[i] = make(map[int64]bool)
+		for r := int64(1); !residues[i][r]; r = r * rocaGenerator % p {
+			residues[i][r] = true
+		}
+	}
+	return residues
+}()
+
+// IsROCAVulnerable reports whether an RSA key has the structure of the keys
+// generated by Infineon firmware with the ROCA vulnerability: the primes of
+// such keys are of the form k*M + (65537^a mod M), so the modulus is a power
+// of 65537 modulo each small prime dividing M. Other keys have this
+// fingerprint with negligible probability.
+func IsROCAVulnerable(pub *rsa.PublicKey) bool {
+	if pub == nil || pub.N == nil || pub.N.Sign() <= 0 {
+		return false
+	}
+	var mod big.Int
+	for i, p := range rocaPrimes {
+		if !rocaResidues[i][mod.Mod(pub.N, big.NewInt(p)).Int64()] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkROCA returns an error matching ErrROCAVulnerable if the key is an RSA
+// key vulnerable to ROCA. Other keys pass.
+func checkROCA(pub crypto.PublicKey) error {
+	if rsaPub, ok := pub.(*rsa.PublicKey); ok && IsROCAVulnerable(rsaPub) {
+		return ErrROCAVulnerable
+	}
+	return nil
+}
+
+// checkAttestationROCA checks an attestation's signing keys and (if known) its
+// EK for ROCA, returning whether any signing key or the EK is vulnerable. A
+// vulnerable key fails verification unless opts.AllowROCAVulnerableKeys is set.
+func checkAttestationROCA(signingKeys []crypto.PublicKey, ekPub crypto.PublicKey, opts VerifyOpts) (bool, bool, error) {
+	akVulnerable := false
+	for i, key := range signingKeys {
+		if err := checkROCA(key); err != nil {
+			if !opts.AllowROCAVulnerableKeys {
+				if i == 0 {
+					return false, false, fmt.Errorf("AK: %w", err)
+				}
+				return false, false, fmt.Errorf("additional quote key %d: %w", i-1, err)
+			}
+			akVulnerable = true
+		}
+	}
+	ekVulnerable := false
+	if err := checkROCA(ekPub); err != nil {
+		if !opts.AllowROCAVulnerableKeys {
+			return false, false, fmt.Errorf("EK certificate: %w", err)
+		}
+		ekVulnerable = true
+	}
+	return akVulnerable, ekVulnerable, nil
+}
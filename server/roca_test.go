@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+)
+
+// rocaTestPrime returns a prime of the form k*M + (65537^a mod M), where M is
+// the product of rocaPrimes, like the primes of keys generated by vulnerable
+// Infineon firmware.
+func rocaTestPrime(t *testing.T, bits int) *big.Int {
+	t.Helper()
+	m := big.NewInt(1)
+	for _, p := range rocaPrimes {
+		m.Mul(m, big.NewInt(p))
+	}
+	kMax := new(big.Int).Lsh(big.NewInt(1), uint(bits-m.BitLen()))
+	for {
+		k, err := rand.Int(rand.Reader, kMax)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := rand.Int(rand.Reader, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := new(big.Int).Exp(big.NewInt(rocaGenerator), a, m)
+		p.Add(p, k.Mul(k, m))
+		if p.ProbablyPrime(20) {
+			return p
+		}
+	}
+}
+
+func rocaTestKey(t *testing.T) *rsa.PublicKey {
+	t.Helper()
+	n := new(big.Int).Mul(rocaTestPrime(t, 1024), rocaTestPrime(t, 1024))
+	return &rsa.PublicKey{N: n, E: rocaGenerator}
+}
+
+func TestIsROCAVulnerable(t *testing.T) {
+	if !IsROCAVulnerable(rocaTestKey(t)) {
+		t.Error("IsROCAVulnerable() = false for a key with vulnerable primes")
+	}
+	for i := 0; i < 5; i++ {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if IsROCAVulnerable(&key.PublicKey) {
+			t.Error("IsROCAVulnerable() = true for a key generated by Go")
+		}
+	}
+	if IsROCAVulnerable(&rsa.PublicKey{}) {
+		t.Error("IsROCAVulnerable() = true for an empty key")
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkROCA(ecKey.Public()); err != nil {
+		t.Errorf("checkROCA() of an ECC key returned %v", err)
+	}
+}
+
+func TestVerifyROCAVulnerableEKCertificate(t *testing.T) {
+	root := createTestCA(t, "Test TPM Root CA", nil)
+	roots := NewEKRootStore()
+	roots.AddCertificate(root.cert)
+	ekCert := createTestEKCertForKey(t, root, infineonAttrs, oidEKCertificateUsage, rocaTestKey(t))
+	if _, err := VerifyEKCertificate(ekCert, roots); !errors.Is(err, ErrROCAVulnerable) {
+		t.Errorf("VerifyEKCertificate() of a vulnerable EK returned %v, want %v", err, ErrROCAVulnerable)
+	}
+}
+
+func TestVerifyROCAVulnerableEK(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+	nonce := []byte("super secret nonce")
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+
+	root := createTestCA(t, "Test TPM Root CA", nil)
+	roots := NewEKRootStore()
+	roots.AddCertificate(root.cert)
+	opts := VerifyOpts{
+		Nonce:      nonce,
+		TrustedAKs: []crypto.PublicKey{ak.PublicKey()},
+		EKCert:     createTestEKCertForKey(t, root, infineonAttrs, oidEKCertificateUsage, rocaTestKey(t)),
+		EKRoots:    roots,
+	}
+	if _, err := VerifyAttestation(attestation, opts); !errors.Is(err, ErrROCAVulnerable) {
+		t.Errorf("VerifyAttestation() with a vulnerable EK returned %v, want %v", err, ErrROCAVulnerable)
+	}
+
+	opts.AllowROCAVulnerableKeys = true
+	ms, err := VerifyAttestation(attestation, opts)
+	if err != nil {
+		t.Fatalf("VerifyAttestation() allowing vulnerable keys failed: %v", err)
+	}
+	if !ms.GetRocaVulnerableEk() || ms.GetRocaVulnerableAk() {
+		t.Errorf("got RocaVulnerableEk %v and RocaVulnerableAk %v, want true and false", ms.GetRocaVulnerableEk(), ms.GetRocaVulnerableAk())
+	}
+	if ms.GetTpmInfo().GetManufacturer() != "Infineon" {
+		t.Errorf("MachineState has TpmInfo %v, want an Infineon TPM", ms.GetTpmInfo())
+	}
+}
+
+// Keys generated by a simulated TPM are never vulnerable, so the checks of the
+// signing keys are tested directly.
+func TestCheckAttestationROCA(t *testing.T) {
+	vulnerable := rocaTestKey(t)
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	safe := priv.Public()
+
+	subtests := []struct {
+		name   string
+		keys   []crypto.PublicKey
+		ekPub  crypto.PublicKey
+		wantAK bool
+		wantEK bool
+		errMsg string
+	}{
+		{"Safe", []crypto.PublicKey{safe, safe}, safe, false, false, ""},
+		{"NoEK", []crypto.PublicKey{safe}, nil, false, false, ""},
+		{"AK", []crypto.PublicKey{vulnerable}, safe, true, false, "AK: "},
+		{"AdditionalQuoteKey", []crypto.PublicKey{safe, safe, vulnerable}, nil, true, false, "additional quote key 1: "},
+		{"EK", []crypto.PublicKey{safe}, vulnerable, false, true, "EK certificate: "},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			_, _, err := checkAttestationROCA(subtest.keys, subtest.ekPub, VerifyOpts{})
+			if subtest.errMsg == "" {
+				if err != nil {
+					t.Errorf("checkAttestationROCA() failed: %v", err)
+				}
+			} else if !errors.Is(err, ErrROCAVulnerable) || !strings.HasPrefix(err.Error(), subtest.errMsg) {
+				t.Errorf("checkAttestationROCA() returned %v, want an error starting with %q", err, subtest.errMsg)
+			}
+
+			gotAK, gotEK, err := checkAttestationROCA(subtest.keys, subtest.ekPub, VerifyOpts{AllowROCAVulnerableKeys: true})
+			if err != nil {
+				t.Fatalf("checkAttestationROCA() allowing vulnerable keys failed: %v", err)
+			}
+			if gotAK != subtest.wantAK || gotEK != subtest.wantEK {
+				t.Errorf("checkAttestationROCA() = (%v, %v), want (%v, %v)", gotAK, gotEK, subtest.wantAK, subtest.wantEK)
+			}
+		})
+	}
+}
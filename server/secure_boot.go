@@ -0,0 +1,265 @@
+package server
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// EFIVariableDriverConfig is the type of EV_EFI_VARIABLE_DRIVER_CONFIG events,
+// which measure the Secure Boot variables into PCR 7, from the TCG PC Client
+// Platform Firmware Profile Specification, Table 14 Events.
+const EFIVariableDriverConfig uint32 = 0x80000001
+
+// Sizes of the fixed-size headers of UEFI_VARIABLE_DATA, EFI_SIGNATURE_LIST and
+// EFI_SIGNATURE_DATA.
+const (
+	uefiVariableDataHeaderSize = 32
+	efiSignatureListHeaderSize = 28
+	efiSignatureOwnerSize      = 16
+	efiTimeSize                = 16
+)
+
+// efiGUID is an EFI_GUID, in its little-endian encoding.
+type efiGUID struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+func (g efiGUID) String() string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", g.Data1, g.Data2, g.Data3, g.Data4[:2], g.Data4[2:])
+}
+
+// Vendor GUIDs of the Secure Boot variables, from the UEFI Specification,
+// Section 3.3 Globally Defined Variables and Section 32.6.1 UEFI Image
+// Variable GUID & Variable Name.
+var (
+	efiGlobalVariable        = efiGUID{0x8be4df61, 0x93ca, 0x11d2, [8]byte{0xaa, 0x0d, 0x00, 0xe0, 0x98, 0x03, 0x2b, 0x8c}}
+	efiImageSecurityDatabase = efiGUID{0xd719b2cb, 0x3d3a, 0x4596, [8]byte{0xa3, 0xbc, 0xda, 0xd0, 0x0e, 0x67, 0x65, 0x6f}}
+)
+
+// secureBootVariables maps the name of each Secure Boot variable to its
+// vendor GUID.
+var secureBootVariables = map[string]efiGUID{
+	"SecureBoot": efiGlobalVariable,
+	"PK":         efiGlobalVariable,
+	"KEK":        efiGlobalVariable,
+	"db":         efiImageSecurityDatabase,
+	"dbx":        efiImageSecurityDatabase,
+}
+
+// Signature types of EFI_SIGNATURE_LISTs, from the UEFI Specification,
+// Section 32.4.1 Signature Database.
+var (
+	efiCertX509       = efiGUID{0xa5c059a1, 0x94e4, 0x4aa7, [8]byte{0x87, 0xb5, 0xab, 0x15, 0x5c, 0x2b, 0xf0, 0x72}}
+	efiCertSHA1       = efiGUID{0x826ca512, 0xcf10, 0x4ac9, [8]byte{0xb1, 0x87, 0xbe, 0x01, 0x49, 0x66, 0x31, 0xbd}}
+	efiCertSHA224     = efiGUID{0x0b6e5233, 0xa65c, 0x44c9, [8]byte{0x94, 0x07, 0xd9, 0xab, 0x83, 0xbf, 0xc8, 0xbd}}
+	efiCertSHA256     = efiGUID{0xc1c41626, 0x504c, 0x4092, [8]byte{0xac, 0xa9, 0x41, 0xf9, 0x36, 0x93, 0x43, 0x28}}
+	efiCertSHA384     = efiGUID{0xff3e5307, 0x9fd0, 0x48c9, [8]byte{0x85, 0xf1, 0x8a, 0xd5, 0x6c, 0x70, 0x1e, 0x01}}
+	efiCertSHA512     = efiGUID{0x093e0fae, 0xa6c4, 0x4f50, [8]byte{0x9f, 0x1b, 0xd4, 0x1e, 0x2b, 0x89, 0xc1, 0x9a}}
+	efiCertX509SHA256 = efiGUID{0x3bd2a492, 0x96c0, 0x4079, [8]byte{0xb4, 0x20, 0xfc, 0xf9, 0x8e, 0xf1, 0x03, 0xed}}
+	efiCertX509SHA384 = efiGUID{0x7076876e, 0x80c2, 0x4ee6, [8]byte{0xaa, 0xd2, 0x28, 0xb3, 0x49, 0xa6, 0x86, 0x5b}}
+	efiCertX509SHA512 = efiGUID{0x446dbf63, 0x2502, 0x4cda, [8]byte{0xbc, 0xfa, 0x24, 0x65, 0xd2, 0xb0, 0xfe, 0x9d}}
+)
+
+// efiHashSizes are the digest sizes of the signature types holding digests of
+// EFI binaries.
+var efiHashSizes = map[efiGUID]int{efiCertSHA1: 20, efiCertSHA224: 28, efiCertSHA256: 32, efiCertSHA384: 48, efiCertSHA512: 64}
+
+// efiCertHashSizes are the digest sizes of the signature types holding
+// digests of certificates.
+var efiCertHashSizes = map[efiGUID]int{efiCertX509SHA256: 32, efiCertX509SHA384: 48, efiCertX509SHA512: 64}
+
+// getSecureBootState parses the Secure Boot variables measured into PCR 7
+// before the separator. Each variable must be measured at most once, with a
+// verified digest and the variable's vendor GUID, and no variables can be
+// measured after the separator. If Secure Boot is enabled, PK, KEK and db must
+// have been measured.
+func getSecureBootState(events []*pb.Event) (*pb.SecureBootState, error) {
+	state := &pb.SecureBootState{}
+	seen := make(map[string]bool)
+	seenSeparator := false
+	for _, event := range events {
+		if event.GetPcrIndex() != 7 {
+			continue
+		}
+		switch event.GetUntrustedType() {
+		case Separator:
+			if !event.GetDigestVerified() {
+				return nil, errors.New("unverified separator digest for PCR7")
+			}
+			seenSeparator = true
+		case EFIVariableDriverConfig:
+			if seenSeparator {
+				return nil, errors.New("Secure Boot variable measured after the separator")
+			}
+			if !event.GetDigestVerified() {
+				return nil, errors.New("unverified Secure Boot variable digest")
+			}
+			guid, name, value, err := parseUEFIVariableData(event.GetData())
+			if err != nil {
+				return nil, err
+			}
+			wantGUID, ok := secureBootVariables[name]
+			if !ok {
+				continue
+			}
+			if guid != wantGUID {
+				return nil, fmt.Errorf("variable %q has vendor GUID %v, expected %v", name, guid, wantGUID)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("variable %q measured more than once", name)
+			}
+			seen[name] = true
+			if err := setSecureBootVariable(state, name, value); err != nil {
+				return nil, fmt.Errorf("invalid %s variable: %w", name, err)
+			}
+		}
+	}
+
+	if !seen["SecureBoot"] {
+		return nil, errors.New("the SecureBoot variable was not measured")
+	}
+	if state.GetEnabled() {
+		for _, name := range []string{"PK", "KEK", "db"} {
+			if !seen[name] {
+				return nil, fmt.Errorf("Secure Boot was enabled, but the %s variable was not measured", name)
+			}
+		}
+	}
+	return state, nil
+}
+
+func setSecureBootVariable(state *pb.SecureBootState, name string, value []byte) error {
+	if name == "SecureBoot" {
+		// Firmware which does not support Secure Boot measures the missing
+		// variable with an empty value.
+		if len(value) == 0 {
+			return nil
+		}
+		if len(value) != 1 || value[0] > 1 {
+			return fmt.Errorf("value %x is not 0 or 1", value)
+		}
+		state.Enabled = value[0] == 1
+		return nil
+	}
+	db, err := parseSignatureDatabase(value)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "PK":
+		state.Pk = db
+	case "KEK":
+		state.Kek = db
+	case "db":
+		state.Db = db
+	case "dbx":
+		state.Dbx = db
+	}
+	return nil
+}
+
+// parseUEFIVariableData parses the UEFI_VARIABLE_DATA of a variable event,
+// from the TCG PC Client Platform Firmware Profile Specification, Section
+// 9.2.6 Measuring UEFI Variables.
+func parseUEFIVariableData(data []byte) (efiGUID, string, []byte, error) {
+	var header struct {
+		VariableName       efiGUID
+		UnicodeNameLength  uint64
+		VariableDataLength uint64
+	}
+	r := bytes.NewReader(data)
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return efiGUID{}, "", nil, fmt.Errorf("invalid UEFI_VARIABLE_DATA header: %w", err)
+	}
+	remaining := uint64(r.Len())
+	if header.UnicodeNameLength > remaining/2 || header.VariableDataLength != remaining-2*header.UnicodeNameLength {
+		return efiGUID{}, "", nil, fmt.Errorf("UEFI_VARIABLE_DATA lengths (%d, %d) do not match its size (%d)",
+			header.UnicodeNameLength, header.VariableDataLength, len(data))
+	}
+	name := make([]uint16, header.UnicodeNameLength)
+	if err := binary.Read(r, binary.LittleEndian, name); err != nil {
+		return efiGUID{}, "", nil, err
+	}
+	value := data[uefiVariableDataHeaderSize+2*len(name):]
+	return header.VariableName, string(utf16.Decode(name)), value, nil
+}
+
+// parseSignatureDatabase parses an array of EFI_SIGNATURE_LISTs. Certificates
+// must be valid X.509 certificates, and digests must have the size of their
+// signature type's hash. Signature types other than certificates and digests
+// (such as raw RSA-2048 keys) are not supported.
+func parseSignatureDatabase(data []byte) (*pb.Database, error) {
+	db := &pb.Database{}
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		var header struct {
+			SignatureType       efiGUID
+			SignatureListSize   uint32
+			SignatureHeaderSize uint32
+			SignatureSize       uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+			return nil, fmt.Errorf("invalid EFI_SIGNATURE_LIST header: %w", err)
+		}
+		bodySize := int64(header.SignatureListSize) - efiSignatureListHeaderSize - int64(header.SignatureHeaderSize)
+		if bodySize < 0 || bodySize > int64(r.Len()) {
+			return nil, fmt.Errorf("EFI_SIGNATURE_LIST size %d is invalid", header.SignatureListSize)
+		}
+		if header.SignatureSize <= efiSignatureOwnerSize || bodySize%int64(header.SignatureSize) != 0 {
+			return nil, fmt.Errorf("EFI_SIGNATURE_LIST signature size %d is invalid", header.SignatureSize)
+		}
+		// The signature header is empty for all the supported types.
+		if _, err := r.Seek(int64(header.SignatureHeaderSize), io.SeekCurrent); err != nil {
+			return nil, err
+		}
+
+		for i := int64(0); i < bodySize/int64(header.SignatureSize); i++ {
+			signature := make([]byte, header.SignatureSize)
+			if _, err := io.ReadFull(r, signature); err != nil {
+				return nil, err
+			}
+			if err := addSignature(db, header.SignatureType, signature[efiSignatureOwnerSize:]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return db, nil
+}
+
+// addSignature adds the data of an EFI_SIGNATURE_DATA, with the given
+// signature type, to the database.
+func addSignature(db *pb.Database, signatureType efiGUID, data []byte) error {
+	if signatureType == efiCertX509 {
+		if _, err := x509.ParseCertificate(data); err != nil {
+			return fmt.Errorf("invalid certificate in signature list: %w", err)
+		}
+		db.Certs = append(db.Certs, data)
+		return nil
+	}
+	if size, ok := efiHashSizes[signatureType]; ok {
+		if len(data) != size {
+			return fmt.Errorf("digest of signature type %v has size %d, expected %d", signatureType, len(data), size)
+		}
+		db.Hashes = append(db.Hashes, data)
+		return nil
+	}
+	if size, ok := efiCertHashSizes[signatureType]; ok {
+		// The digest of the TBSCertificate, followed by the revocation time.
+		if len(data) != size+efiTimeSize {
+			return fmt.Errorf("certificate digest of signature type %v has size %d, expected %d", signatureType, len(data), size+efiTimeSize)
+		}
+		db.CertHashes = append(db.CertHashes, data[:size])
+		return nil
+	}
+	return fmt.Errorf("unsupported signature type %v", signatureType)
+}
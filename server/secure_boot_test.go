@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+func TestGetSecureBootStatePlatforms(t *testing.T) {
+	subtests := []struct {
+		platform  test.Platform
+		enabled   bool
+		dbCerts   int
+		dbxCerts  int
+		dbxHashes int
+	}{
+		{test.Rhel8GCE, true, 2, 3, 183},
+		{test.UbuntuAmdSevGCE, false, 2, 0, 77},
+		{test.Ubuntu2104NoDbxGCE, false, 2, 0, 0},
+		{test.Ubuntu2104NoSecureBootGCE, false, 2, 3, 183},
+		{test.GlinuxNoSecureBootLaptop, false, 4, 0, 77},
+		{test.ArchLinuxWorkstation, false, 3, 0, 77},
+		{test.Debian10GCE, true, 2, 3, 183},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.platform.Name, func(t *testing.T) {
+			state := parseTestMachineState(t, subtest.platform).GetSecureBoot()
+			if state == nil {
+				t.Fatal("MachineState is missing the Secure Boot state")
+			}
+			if state.GetEnabled() != subtest.enabled {
+				t.Errorf("got enabled %v, want %v", state.GetEnabled(), subtest.enabled)
+			}
+			if got := len(state.GetDb().GetCerts()); got != subtest.dbCerts {
+				t.Errorf("got %d db certificates, want %d", got, subtest.dbCerts)
+			}
+			if got := len(state.GetDbx().GetCerts()); got != subtest.dbxCerts {
+				t.Errorf("got %d dbx certificates, want %d", got, subtest.dbxCerts)
+			}
+			if got := len(state.GetDbx().GetHashes()); got != subtest.dbxHashes {
+				t.Errorf("got %d dbx hashes, want %d", got, subtest.dbxHashes)
+			}
+		})
+	}
+
+	state := parseTestMachineState(t, test.Rhel8GCE).GetSecureBoot()
+	if pk := state.GetPk().GetCerts(); len(pk) != 1 || !bytes.Equal(pk[0], GceDefaultPKCert) {
+		t.Error("Rhel8GCE PK is not the GCE default PK")
+	}
+	if !containsBytes(state.GetKek().GetCerts(), MicrosoftKEKCA2011Cert) {
+		t.Error("Rhel8GCE KEK is missing the Microsoft KEK CA")
+	}
+	for _, cert := range [][]byte{MicrosoftUEFICA2011Cert, WindowsProductionPCA2011Cert} {
+		if !containsBytes(state.GetDb().GetCerts(), cert) {
+			t.Error("Rhel8GCE db is missing a Microsoft CA")
+		}
+	}
+	for _, cert := range bootholeCerts {
+		if !containsBytes(state.GetDbx().GetCerts(), cert) {
+			t.Error("Rhel8GCE dbx is missing a revoked Boot Hole certificate")
+		}
+	}
+}
+
+func uefiVariableEvent(guid efiGUID, name string, value []byte) *pb.Event {
+	var data bytes.Buffer
+	unicodeName := utf16.Encode([]rune(name))
+	binary.Write(&data, binary.LittleEndian, guid)
+	binary.Write(&data, binary.LittleEndian, uint64(len(unicodeName)))
+	binary.Write(&data, binary.LittleEndian, uint64(len(value)))
+	binary.Write(&data, binary.LittleEndian, unicodeName)
+	data.Write(value)
+	return &pb.Event{
+		PcrIndex:       7,
+		UntrustedType:  EFIVariableDriverConfig,
+		Data:           data.Bytes(),
+		DigestVerified: true,
+	}
+}
+
+// signatureList encodes an EFI_SIGNATURE_LIST of signatures of the same size.
+func signatureList(signatureType efiGUID, signatures ...[]byte) []byte {
+	size := efiSignatureOwnerSize + len(signatures[0])
+	var list bytes.Buffer
+	binary.Write(&list, binary.LittleEndian, signatureType)
+	binary.Write(&list, binary.LittleEndian, uint32(efiSignatureListHeaderSize+size*len(signatures)))
+	binary.Write(&list, binary.LittleEndian, uint32(0))
+	binary.Write(&list, binary.LittleEndian, uint32(size))
+	for _, signature := range signatures {
+		list.Write(make([]byte, efiSignatureOwnerSize))
+		list.Write(signature)
+	}
+	return list.Bytes()
+}
+
+func secureBootEvents(changes map[string][]byte) []*pb.Event {
+	variables := []struct {
+		guid  efiGUID
+		name  string
+		value []byte
+	}{
+		{efiGlobalVariable, "SecureBoot", []byte{1}},
+		{efiGlobalVariable, "PK", signatureList(efiCertX509, GceDefaultPKCert)},
+		{efiGlobalVariable, "KEK", signatureList(efiCertX509, MicrosoftKEKCA2011Cert)},
+		{efiImageSecurityDatabase, "db", signatureList(efiCertX509, WindowsProductionPCA2011Cert)},
+		{efiImageSecurityDatabase, "dbx", append(
+			signatureList(efiCertSHA256, bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)),
+			signatureList(efiCertX509SHA384, bytes.Repeat([]byte{3}, 48+efiTimeSize))...)},
+	}
+	var events []*pb.Event
+	for _, v := range variables {
+		value := v.value
+		if changed, ok := changes[v.name]; ok {
+			if changed == nil {
+				continue
+			}
+			value = changed
+		}
+		events = append(events, uefiVariableEvent(v.guid, v.name, value))
+	}
+	return append(events, &pb.Event{PcrIndex: 7, UntrustedType: Separator, Data: []byte{0, 0, 0, 0}, DigestVerified: true})
+}
+
+func TestGetSecureBootState(t *testing.T) {
+	state, err := getSecureBootState(secureBootEvents(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.GetEnabled() {
+		t.Error("Secure Boot should be enabled")
+	}
+	if certs := state.GetDb().GetCerts(); len(certs) != 1 || !bytes.Equal(certs[0], WindowsProductionPCA2011Cert) {
+		t.Error("db should only contain the Windows Production PCA")
+	}
+	if hashes := state.GetDbx().GetHashes(); len(hashes) != 2 || !bytes.Equal(hashes[1], bytes.Repeat([]byte{2}, 32)) {
+		t.Errorf("got dbx hashes %x", hashes)
+	}
+	if certHashes := state.GetDbx().GetCertHashes(); len(certHashes) != 1 || !bytes.Equal(certHashes[0], bytes.Repeat([]byte{3}, 48)) {
+		t.Errorf("got dbx certificate hashes %x", certHashes)
+	}
+
+	// Disabled Secure Boot does not need any keys.
+	state, err = getSecureBootState(secureBootEvents(map[string][]byte{"SecureBoot": {0}, "PK": nil, "KEK": nil, "db": nil}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.GetEnabled() || state.GetPk() != nil {
+		t.Errorf("got unexpected state %v", state)
+	}
+}
+
+func TestGetSecureBootStateInvalid(t *testing.T) {
+	afterSeparator := append(secureBootEvents(map[string][]byte{"dbx": nil}),
+		uefiVariableEvent(efiImageSecurityDatabase, "dbx", nil))
+	wrongGUID := append(secureBootEvents(map[string][]byte{"db": nil}),
+		uefiVariableEvent(efiGlobalVariable, "db", nil))
+	wrongGUID[len(wrongGUID)-1], wrongGUID[len(wrongGUID)-2] = wrongGUID[len(wrongGUID)-2], wrongGUID[len(wrongGUID)-1]
+	duplicate := secureBootEvents(nil)
+	duplicate = append([]*pb.Event{duplicate[3]}, duplicate...)
+	unverified := secureBootEvents(nil)
+	unverified[2].DigestVerified = false
+	truncated := secureBootEvents(nil)
+	truncated[1].Data = truncated[1].Data[:len(truncated[1].Data)-1]
+	badList := signatureList(efiCertSHA256, make([]byte, 32))
+	badList[16]++
+
+	subtests := []struct {
+		name   string
+		events []*pb.Event
+	}{
+		{"NoVariables", nil},
+		{"NoSecureBootVariable", secureBootEvents(map[string][]byte{"SecureBoot": nil})},
+		{"InvalidSecureBootValue", secureBootEvents(map[string][]byte{"SecureBoot": {2}})},
+		{"EnabledWithoutPK", secureBootEvents(map[string][]byte{"PK": nil})},
+		{"EnabledWithoutDB", secureBootEvents(map[string][]byte{"db": nil})},
+		{"VariableAfterSeparator", afterSeparator},
+		{"WrongVendorGUID", wrongGUID},
+		{"DuplicateVariable", duplicate},
+		{"UnverifiedDigest", unverified},
+		{"TruncatedVariable", truncated},
+		{"InvalidCertificate", secureBootEvents(map[string][]byte{"db": signatureList(efiCertX509, []byte("not a certificate"))})},
+		{"WrongHashSize", secureBootEvents(map[string][]byte{"dbx": signatureList(efiCertSHA256, make([]byte, 20))})},
+		{"WrongCertHashSize", secureBootEvents(map[string][]byte{"dbx": signatureList(efiCertX509SHA256, make([]byte, 32))})},
+		{"InvalidListSize", secureBootEvents(map[string][]byte{"dbx": badList})},
+		{"UnsupportedSignatureType", secureBootEvents(map[string][]byte{"dbx": signatureList(efiGlobalVariable, make([]byte, 32))})},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if state, err := getSecureBootState(subtest.events); err == nil {
+				t.Errorf("getSecureBootState() = %v, expected an error", state)
+			}
+		})
+	}
+}
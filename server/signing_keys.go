@@ -0,0 +1,324 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+// jwksMaxAge is how long relying parties may cache a JWKS served by a
+// SigningKeyRing. A new key is only used once this has passed (see Rotate).
+const jwksMaxAge = 5 * time.Minute
+
+// SigningKey is a key a verifier signs claims tokens with. The Signer can be
+// any crypto.Signer: a software key, a TPM-backed key from
+// client.Key.GetSigner, a key in an HSM, or a cloud KMS key. Its public key
+// must be supported by IssueEAT.
+type SigningKey struct {
+	// ID identifies the key to relying parties, as the kid of its tokens and
+	// of its JWK. If empty, the key's JWK thumbprint (RFC 7638) is used.
+	ID     string
+	Signer crypto.Signer
+}
+
+type signingKeyEntry struct {
+	SigningKey
+	pub crypto.PublicKey
+	alg eatAlgorithm
+	// When the key is first used to sign, or is no longer published.
+	activeAt  time.Time
+	retiredAt time.Time
+}
+
+// SigningKeyRing holds a verifier's signing keys, allowing them to be rotated
+// while relying parties fetch the public keys as a JSON Web Key Set (RFC 7517,
+// Section 5). Tokens are signed by the active key, and carry its ID. Retired
+// keys are published until the tokens they signed have expired.
+//
+// A SigningKeyRing is an http.Handler serving its JWKS, for example at
+// "/.well-known/jwks.json". It is safe for concurrent use.
+type SigningKeyRing struct {
+	mu   sync.RWMutex
+	keys []*signingKeyEntry
+	now  func() time.Time
+}
+
+// NewSigningKeyRing returns a SigningKeyRing whose active key is key.
+func NewSigningKeyRing(key SigningKey) (*SigningKeyRing, error) {
+	entry, err := newSigningKeyEntry(key)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKeyRing{keys: []*signingKeyEntry{entry}, now: time.Now}, nil
+}
+
+func newSigningKeyEntry(key SigningKey) (*signingKeyEntry, error) {
+	if key.Signer == nil {
+		return nil, fmt.Errorf("signing key %q has no signer", key.ID)
+	}
+	// Remote signers may fetch the public key on each call, so only do so once.
+	pub := key.Signer.Public()
+	alg, err := getEATAlgorithm(pub)
+	if err != nil {
+		return nil, err
+	}
+	if key.ID == "" {
+		if key.ID, err = JWKThumbprint(pub); err != nil {
+			return nil, err
+		}
+	}
+	return &signingKeyEntry{SigningKey: key, pub: pub, alg: alg}, nil
+}
+
+// Rotate adds a new signing key. So that relying parties caching the JWKS can
+// verify its tokens, the new key is published immediately, but only becomes
+// the active key once cached copies of the previous JWKS have expired. The
+// previously active key keeps being published for retireAfter once it stops
+// being used, which should be at least the lifetime of the tokens it signed.
+func (r *SigningKeyRing) Rotate(key SigningKey, retireAfter time.Duration) error {
+	if retireAfter < 0 {
+		return fmt.Errorf("key retirement delay must not be negative")
+	}
+	entry, err := newSigningKeyEntry(key)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.prune(now)
+	for _, published := range r.keys {
+		if published.ID == entry.ID {
+			return fmt.Errorf("signing key %q is already published", entry.ID)
+		}
+	}
+	entry.activeAt = now.Add(jwksMaxAge)
+	// Keys which have not yet become active are replaced without a delay, as
+	// they have not signed anything.
+	current := r.activeAt(now)
+	for _, published := range r.keys {
+		switch {
+		case published.activeAt.After(now):
+			published.retiredAt = now
+		case published == current:
+			published.retiredAt = entry.activeAt.Add(retireAfter)
+		}
+	}
+	r.keys = append(r.keys, entry)
+	r.prune(now)
+	return nil
+}
+
+// prune removes retired keys which are no longer published.
+func (r *SigningKeyRing) prune(now time.Time) {
+	keys := r.keys[:0]
+	for _, key := range r.keys {
+		if key.retiredAt.IsZero() || key.retiredAt.After(now) {
+			keys = append(keys, key)
+		}
+	}
+	r.keys = keys
+}
+
+// active returns the key used to sign tokens: the most recently added key
+// which has become active.
+func (r *SigningKeyRing) active() *signingKeyEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.activeAt(r.now())
+}
+
+func (r *SigningKeyRing) activeAt(now time.Time) *signingKeyEntry {
+	for i := len(r.keys) - 1; i > 0; i-- {
+		if !r.keys[i].activeAt.After(now) {
+			return r.keys[i]
+		}
+	}
+	return r.keys[0]
+}
+
+// Active returns the key currently used to sign tokens, with its ID.
+func (r *SigningKeyRing) Active() SigningKey {
+	return r.active().SigningKey
+}
+
+// IssueEAT issues a token with the active key, as IssueEAT does. The KeyID
+// field of opts is replaced with the key's ID.
+func (r *SigningKeyRing) IssueEAT(ms *pb.MachineState, opts EATOpts) ([]byte, error) {
+	key := r.active()
+	opts.KeyID = key.ID
+	return IssueEAT(ms, key.Signer, opts)
+}
+
+// JWKS returns the published public keys as a JSON Web Key Set.
+func (r *SigningKeyRing) JWKS() ([]byte, error) {
+	r.mu.Lock()
+	r.prune(r.now())
+	keys := make([]*signingKeyEntry, len(r.keys))
+	copy(keys, r.keys)
+	r.mu.Unlock()
+
+	set := struct {
+		Keys []*jwk `json:"keys"`
+	}{}
+	for _, key := range keys {
+		encoded, err := newJWK(key.pub)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Kid = key.ID
+		encoded.Alg = key.alg.jose
+		encoded.Use = "sig"
+		set.Keys = append(set.Keys, encoded)
+	}
+	return json.Marshal(set)
+}
+
+// ServeHTTP serves the JWKS, allowing relying parties to cache it for five
+// minutes.
+func (r *SigningKeyRing) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	jwks, err := r.JWKS()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge/time.Second)))
+	w.Write(jwks)
+}
+
+// jwk is a public JSON Web Key (RFC 7517 and RFC 7518, Section 6, and RFC 8037
+// for Ed25519 keys).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+var jwkCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+func newJWK(pub crypto.PublicKey) (*jwk, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		name := key.Curve.Params().Name
+		if _, ok := jwkCurves[name]; !ok {
+			return nil, fmt.Errorf("unsupported ECDSA curve: %v", name)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return &jwk{Kty: "EC", Crv: name, X: b64(key.X.FillBytes(make([]byte, size))), Y: b64(key.Y.FillBytes(make([]byte, size)))}, nil
+	case *rsa.PublicKey:
+		return &jwk{Kty: "RSA", N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())}, nil
+	case ed25519.PublicKey:
+		return &jwk{Kty: "OKP", Crv: "Ed25519", X: b64(key)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWK key type: %T", pub)
+	}
+}
+
+// JWKThumbprint returns the base64url encoded SHA-256 JWK thumbprint of a
+// public key (RFC 7638), which identifies the key independently of how it is
+// stored.
+func JWKThumbprint(pub crypto.PublicKey) (string, error) {
+	key, err := newJWK(pub)
+	if err != nil {
+		return "", err
+	}
+	// The required members of each key type, in lexicographic order.
+	var members string
+	switch key.Kty {
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, key.Crv, key.X, key.Y)
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, key.E, key.N)
+	case "OKP":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, key.Crv, key.X)
+	}
+	digest := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(digest[:]), nil
+}
+
+// ParseJWKS parses a JSON Web Key Set, such as one served by a
+// SigningKeyRing, returning its public keys by key ID. Relying parties can
+// use it to verify claims tokens by their kid. Keys without an ID, and keys
+// for other uses than signing, are skipped.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	b64 := base64.RawURLEncoding.DecodeString
+	keys := make(map[string]crypto.PublicKey)
+	for _, key := range set.Keys {
+		if key.Kid == "" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		if _, ok := keys[key.Kid]; ok {
+			return nil, fmt.Errorf("JWKS has several keys with ID %q", key.Kid)
+		}
+		var pub crypto.PublicKey
+		switch key.Kty {
+		case "EC":
+			curve, ok := jwkCurves[key.Crv]
+			if !ok {
+				return nil, fmt.Errorf("JWK %q: unsupported curve %q", key.Kid, key.Crv)
+			}
+			x, errX := b64(key.X)
+			y, errY := b64(key.Y)
+			if errX != nil || errY != nil {
+				return nil, fmt.Errorf("JWK %q: invalid coordinates", key.Kid)
+			}
+			ecPub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !curve.IsOnCurve(ecPub.X, ecPub.Y) {
+				return nil, fmt.Errorf("JWK %q: point is not on curve %s", key.Kid, key.Crv)
+			}
+			pub = ecPub
+		case "RSA":
+			n, errN := b64(key.N)
+			e, errE := b64(key.E)
+			if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("JWK %q: invalid RSA parameters", key.Kid)
+			}
+			pub = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "OKP":
+			x, err := b64(key.X)
+			if key.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("JWK %q: invalid or unsupported OKP key", key.Kid)
+			}
+			pub = ed25519.PublicKey(x)
+		default:
+			return nil, fmt.Errorf("JWK %q: unsupported key type %q", key.Kid, key.Kty)
+		}
+		keys[key.Kid] = pub
+	}
+	return keys, nil
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm/tpm2"
+)
+
+func newTestSigningKey(t *testing.T, id string) SigningKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return SigningKey{ID: id, Signer: priv}
+}
+
+// publishedKeyIDs returns the IDs of the keys in a ring's JWKS.
+func publishedKeyIDs(t *testing.T, ring *SigningKeyRing) []string {
+	t.Helper()
+	jwks, err := ring.JWKS()
+	if err != nil {
+		t.Fatalf("JWKS() failed: %v", err)
+	}
+	keys, err := ParseJWKS(jwks)
+	if err != nil {
+		t.Fatalf("ParseJWKS() failed: %v", err)
+	}
+	var ids []string
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if _, ok := keys[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) != len(keys) {
+		t.Fatalf("JWKS has unexpected keys: %v", keys)
+	}
+	return ids
+}
+
+func TestSigningKeyRingRotation(t *testing.T) {
+	ms := getRhel8MachineState(t)
+	now := time.Unix(1700000000, 0)
+	ring, err := NewSigningKeyRing(newTestSigningKey(t, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring.now = func() time.Time { return now }
+	check := func(step string, active string, published []string) {
+		t.Helper()
+		if got := ring.Active().ID; got != active {
+			t.Errorf("%s: active key is %q, want %q", step, got, active)
+		}
+		if got := publishedKeyIDs(t, ring); !cmp.Equal(got, published) {
+			t.Errorf("%s: published keys %v, want %v", step, got, published)
+		}
+		token, err := ring.IssueEAT(ms, EATOpts{Format: EATFormatJWT})
+		if err != nil {
+			t.Fatalf("%s: IssueEAT() failed: %v", step, err)
+		}
+		header, err := base64.RawURLEncoding.DecodeString(strings.Split(string(token), ".")[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields map[string]string
+		if err := json.Unmarshal(header, &fields); err != nil {
+			t.Fatal(err)
+		}
+		if fields["kid"] != active {
+			t.Errorf("%s: token kid is %q, want %q", step, fields["kid"], active)
+		}
+	}
+	check("initial", "a", []string{"a"})
+
+	if err := ring.Rotate(newTestSigningKey(t, "b"), time.Hour); err != nil {
+		t.Fatalf("Rotate() failed: %v", err)
+	}
+	check("rotated", "a", []string{"a", "b"})
+	now = now.Add(jwksMaxAge)
+	check("new key active", "b", []string{"a", "b"})
+	now = now.Add(time.Hour)
+	check("old key retired", "b", []string{"b"})
+
+	// A key which never became active is replaced immediately, and the active
+	// key stays published until the key replacing it has been active for
+	// retireAfter.
+	if err := ring.Rotate(newTestSigningKey(t, "c"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if err := ring.Rotate(newTestSigningKey(t, "d"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	check("replaced pending key", "b", []string{"b", "d"})
+	now = now.Add(jwksMaxAge + 59*time.Minute)
+	check("replacement active", "d", []string{"b", "d"})
+	now = now.Add(time.Minute)
+	check("replacement retired", "d", []string{"d"})
+
+	if err := ring.Rotate(newTestSigningKey(t, "d"), time.Hour); err == nil {
+		t.Error("Rotate() with a published key ID should fail")
+	}
+	if err := ring.Rotate(newTestSigningKey(t, "e"), -time.Second); err == nil {
+		t.Error("Rotate() with a negative retirement delay should fail")
+	}
+	if err := ring.Rotate(SigningKey{ID: "e"}, time.Hour); err == nil {
+		t.Error("Rotate() without a signer should fail")
+	}
+}
+
+func TestSigningKeyRingCWTKeyID(t *testing.T) {
+	key := newTestSigningKey(t, "")
+	ring, err := NewSigningKeyRing(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbprint, err := JWKThumbprint(key.Signer.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ring.Active().ID; got != thumbprint {
+		t.Errorf("key without an ID has ID %q, want its thumbprint %q", got, thumbprint)
+	}
+
+	token, err := ring.IssueEAT(getRhel8MachineState(t), EATOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodeCWT(t, token, key.Signer.Public().(*ecdsa.PublicKey))
+	var msg cbor.Tag
+	if err := cbor.Unmarshal(token, &msg); err != nil {
+		t.Fatal(err)
+	}
+	var headers map[int]interface{}
+	if err := cbor.Unmarshal(msg.Content.([]interface{})[0].([]byte), &headers); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := headers[coseHeaderKeyID].([]byte); string(got) != thumbprint {
+		t.Errorf("CWT kid is %q, want %q", got, thumbprint)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	template := client.AKTemplateECC()
+	template.Attributes &^= tpm2.FlagRestricted
+	tpmKey, err := client.NewKey(rwc, tpm2.HandleOwner, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpmKey.Close()
+	tpmSigner, err := tpmKey.GetSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var signers []crypto.Signer
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers = append(signers, priv)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers = append(signers, rsaKey, edKey, tpmSigner)
+
+	ring, err := NewSigningKeyRing(SigningKey{Signer: signers[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ring.now = func() time.Time { return now }
+	want := map[string]crypto.PublicKey{ring.Active().ID: signers[0].Public()}
+	for _, signer := range signers[1:] {
+		if err := ring.Rotate(SigningKey{Signer: signer}, 24*time.Hour); err != nil {
+			t.Fatalf("Rotate(%T) failed: %v", signer, err)
+		}
+		now = now.Add(jwksMaxAge)
+		thumbprint, err := JWKThumbprint(signer.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[thumbprint] = signer.Public()
+	}
+	if _, err := ring.IssueEAT(getRhel8MachineState(t), EATOpts{}); err != nil {
+		t.Errorf("IssueEAT() with a TPM key failed: %v", err)
+	}
+
+	server := httptest.NewServer(ring)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "application/jwk-set+json" {
+		t.Errorf("Content-Type is %q", got)
+	}
+	var jwks json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseJWKS(jwks)
+	if err != nil {
+		t.Fatalf("ParseJWKS() failed: %v", err)
+	}
+	for id, pub := range want {
+		if _, ok := got[id]; !ok {
+			t.Errorf("key %q (%T) is not published", id, pub)
+		} else if !got[id].(interface{ Equal(crypto.PublicKey) bool }).Equal(pub) {
+			t.Errorf("key %q is published as %v, want %v", id, got[id], pub)
+		}
+	}
+
+	if resp, err := http.Post(server.URL, "text/plain", nil); err != nil {
+		t.Fatal(err)
+	} else if resp.Body.Close(); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST returned status %d", resp.StatusCode)
+	}
+
+	for name, bad := range map[string]string{
+		"NotJSON":      "{",
+		"DuplicateID":  `{"keys":[{"kty":"OKP","kid":"a","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},{"kty":"OKP","kid":"a","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}]}`,
+		"UnknownType":  `{"keys":[{"kty":"oct","kid":"a","k":"AAAA"}]}`,
+		"UnknownCurve": `{"keys":[{"kty":"EC","kid":"a","crv":"P-224","x":"AA","y":"AA"}]}`,
+		"NotOnCurve":   `{"keys":[{"kty":"EC","kid":"a","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+		"ShortOKP":     `{"keys":[{"kty":"OKP","kid":"a","crv":"Ed25519","x":"AQ"}]}`,
+	} {
+		if _, err := ParseJWKS([]byte(bad)); err == nil {
+			t.Errorf("ParseJWKS() of %s should fail", name)
+		}
+	}
+	skipped, err := ParseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"enc","use":"enc","n":"AQ","e":"AQAB"},{"kty":"RSA","n":"AQ","e":"AQAB"}]}`))
+	if err != nil || len(skipped) != 0 {
+		t.Errorf("ParseJWKS() of encryption keys and keys without IDs returned (%v, %v), want no keys", skipped, err)
+	}
+}
+
+func TestVerifierServiceSigningKeyRing(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+
+	key := newTestSigningKey(t, "verifier-key")
+	ring, err := NewSigningKeyRing(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewVerifierService(VerifierServiceOpts{Signer: key.Signer, Keys: ring}); err == nil {
+		t.Error("NewVerifierService() with both a signer and signing keys should fail")
+	}
+	verifier := startVerifier(t, VerifierServiceOpts{
+		Keys:       ring,
+		VerifyOpts: VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}},
+	})
+	resp, err := ak.AttestToVerifier(context.Background(), verifier)
+	if err != nil {
+		t.Fatalf("AttestToVerifier() failed: %v", err)
+	}
+	decodeCWT(t, resp.GetClaimsToken(), key.Signer.Public().(*ecdsa.PublicKey))
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// PCRs used by systemd-stub, from the systemd TPM2 PCR Measurements
+// documentation. PCR 11 holds the sections of the Unified Kernel Image (and
+// the boot phases measured later by systemd-pcrphase), PCR 12 the kernel
+// command line, credentials and configuration extensions, and PCR 13 the
+// system extensions.
+const (
+	systemdStubPCR   = 11
+	systemdConfigPCR = 12
+	systemdSysextPCR = 13
+)
+
+// Descriptions of the cpio archives measured by systemd-stub.
+const (
+	credentialsDescription       = "Credentials initrd"
+	globalCredentialsDescription = "Global credentials initrd"
+	confextDescription           = "Configuration extension initrd"
+	sysextDescription            = "System extension initrd"
+)
+
+// getSystemdStubState parses the measurements of systemd-stub, returning nil
+// if there are none.
+//
+// systemd-stub measures each UKI section as two EV_IPL events into PCR 11,
+// both described by the UTF-16 section name: the first measures the ASCII
+// section name (with its NUL terminator), and the second the section
+// contents. Every section must be measured this way.
+func getSystemdStubState(hash crypto.Hash, events []*pb.Event) (*pb.SystemdStubState, error) {
+	state := &pb.SystemdStubState{}
+	found := false
+	var pendingName string
+	for _, event := range events {
+		if event.GetUntrustedType() != ipl {
+			continue
+		}
+		switch event.GetPcrIndex() {
+		case systemdStubPCR:
+			found = true
+			name, ok := decodeUTF16Description(event.GetData())
+			if !ok {
+				return nil, errors.New("PCR 11 event is not described by a UKI section name")
+			}
+			if pendingName == "" {
+				hasher := hash.New()
+				hasher.Write([]byte(name + "\x00"))
+				if !bytes.Equal(hasher.Sum(nil), event.GetDigest()) {
+					return nil, fmt.Errorf("UKI section name %q does not match its digest", name)
+				}
+				pendingName = name
+				continue
+			}
+			if name != pendingName {
+				return nil, fmt.Errorf("contents of UKI section %q are described as %q", pendingName, name)
+			}
+			state.Sections = append(state.Sections, &pb.UKISection{Name: name, Digest: event.GetDigest()})
+			pendingName = ""
+		case systemdConfigPCR, systemdSysextPCR:
+			description, _ := decodeUTF16Description(event.GetData())
+			if digests := stubArchiveDigests(state, event.GetPcrIndex(), description); digests != nil {
+				*digests = append(*digests, event.GetDigest())
+				found = true
+			}
+		}
+	}
+	if pendingName != "" {
+		return nil, fmt.Errorf("contents of UKI section %q were not measured", pendingName)
+	}
+	if !found {
+		return nil, nil
+	}
+	return state, nil
+}
+
+// stubArchiveDigests returns the digests in state for a cpio archive measured
+// by systemd-stub, or nil if the event is not such a measurement.
+func stubArchiveDigests(state *pb.SystemdStubState, pcr uint32, description string) *[][]byte {
+	switch {
+	case pcr == systemdSysextPCR && description == sysextDescription:
+		return &state.SysextDigests
+	case pcr == systemdConfigPCR && (description == credentialsDescription || description == globalCredentialsDescription):
+		return &state.CredentialDigests
+	case pcr == systemdConfigPCR && description == confextDescription:
+		return &state.ConfextDigests
+	default:
+		return nil
+	}
+}
+
+// decodeUTF16Description decodes a NUL-terminated UTF-16 event description,
+// as used by systemd-stub.
+func decodeUTF16Description(data []byte) (string, bool) {
+	if len(data) < 4 || len(data)%2 != 0 || data[len(data)-1] != 0 || data[len(data)-2] != 0 {
+		return "", false
+	}
+	description := decodeUTF16(data[:len(data)-2])
+	if strings.ContainsRune(description, 0) {
+		return "", false
+	}
+	return description, true
+}
+
+// systemdPCRSignature is the JSON output of "systemd-measure sign", mapping
+// each PCR bank name (such as "sha256") to its signed policies.
+type systemdPCRSignature map[string][]struct {
+	PCRs []uint32 `json:"pcrs"`
+	// SHA-256 fingerprint of the signing key's SubjectPublicKeyInfo
+	KeyFingerprint string `json:"pkfp"`
+	// TPM2_PolicyPCR digest, in a SHA-256 policy session
+	Policy string `json:"pol"`
+	// Signature of the SHA-256 digest of the policy, as used by
+	// TPM2_PolicyAuthorize with an empty policyRef
+	Signature []byte `json:"sig"`
+}
+
+var systemdBankNames = map[tpmpb.HashAlgo]string{
+	tpmpb.HashAlgo_SHA1:   "sha1",
+	tpmpb.HashAlgo_SHA256: "sha256",
+	tpmpb.HashAlgo_SHA384: "sha384",
+	tpmpb.HashAlgo_SHA512: "sha512",
+}
+
+// VerifySystemdPCRSignature checks that the PCR values match a PCR policy in a
+// signature produced by "systemd-measure sign", which is signed by one of the
+// trusted keys (RSA or ECDSA). Such signatures are shipped with Unified Kernel
+// Images (in their .pcrsig section), and cover the expected values of PCR 11
+// in each boot phase.
+func VerifySystemdPCRSignature(signature []byte, pcrs *tpmpb.PCRs, trustedKeys []crypto.PublicKey) error {
+	_, err := verifySystemdPCRSignature(signature, pcrs, trustedKeys)
+	return err
+}
+
+// verifySystemdPCRSignature is like VerifySystemdPCRSignature, but also returns
+// the PCRs covered by the policy which matched.
+func verifySystemdPCRSignature(signature []byte, pcrs *tpmpb.PCRs, trustedKeys []crypto.PublicKey) ([]uint32, error) {
+	var sig systemdPCRSignature
+	if err := json.Unmarshal(signature, &sig); err != nil {
+		return nil, fmt.Errorf("invalid systemd PCR signature: %w", err)
+	}
+	bank, ok := systemdBankNames[pcrs.GetHash()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported PCR bank %v", pcrs.GetHash())
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, key := range trustedKeys {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted key: %w", err)
+		}
+		fingerprint := sha256.Sum256(der)
+		keys[hex.EncodeToString(fingerprint[:])] = key
+	}
+
+	for _, entry := range sig[bank] {
+		key, ok := keys[strings.ToLower(entry.KeyFingerprint)]
+		if !ok {
+			continue
+		}
+		selected := &tpmpb.PCRs{Hash: pcrs.GetHash(), Pcrs: make(map[uint32][]byte)}
+		for _, index := range entry.PCRs {
+			value, ok := pcrs.GetPcrs()[index]
+			if !ok {
+				return nil, fmt.Errorf("PCR %d is not in the quote", index)
+			}
+			selected.Pcrs[index] = value
+		}
+		if len(selected.Pcrs) == 0 {
+			continue
+		}
+		policy, err := internal.PCRSessionAuth(selected, crypto.SHA256)
+		if err != nil {
+			return nil, err
+		}
+		if hex.EncodeToString(policy) != strings.ToLower(entry.Policy) {
+			continue
+		}
+		if err := verifyPolicySignature(key, policy, entry.Signature); err != nil {
+			return nil, fmt.Errorf("PCR policy with key %s: %w", entry.KeyFingerprint, err)
+		}
+		return entry.PCRs, nil
+	}
+	return nil, fmt.Errorf("the %s PCR values do not match a policy signed by a trusted key", bank)
+}
+
+func verifyPolicySignature(key crypto.PublicKey, policy, signature []byte) error {
+	digest := sha256.Sum256(policy)
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// withoutPCR returns a copy of the PCRs without the given PCR.
+func withoutPCR(pcrs *tpmpb.PCRs, index uint32) *tpmpb.PCRs {
+	filtered := &tpmpb.PCRs{Hash: pcrs.GetHash(), Pcrs: make(map[uint32][]byte)}
+	for i, value := range pcrs.GetPcrs() {
+		if i != index {
+			filtered.Pcrs[i] = value
+		}
+	}
+	return filtered
+}
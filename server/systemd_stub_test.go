@@ -0,0 +1,259 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+func utf16Description(description string) []byte {
+	var data []byte
+	for _, c := range utf16.Encode([]rune(description + "\x00")) {
+		data = append(data, byte(c), byte(c>>8))
+	}
+	return data
+}
+
+// ukiSectionEvents returns the two events measuring a UKI section.
+func ukiSectionEvents(name string, contents []byte) []*pb.Event {
+	nameDigest := sha256.Sum256([]byte(name + "\x00"))
+	contentsDigest := sha256.Sum256(contents)
+	return []*pb.Event{
+		{PcrIndex: systemdStubPCR, UntrustedType: ipl, Data: utf16Description(name), Digest: nameDigest[:]},
+		{PcrIndex: systemdStubPCR, UntrustedType: ipl, Data: utf16Description(name), Digest: contentsDigest[:]},
+	}
+}
+
+func TestGetSystemdStubState(t *testing.T) {
+	var events []*pb.Event
+	events = append(events, ukiSectionEvents(".linux", []byte("kernel"))...)
+	events = append(events, ukiSectionEvents(".cmdline", []byte("root=/dev/sda1"))...)
+	events = append(events,
+		&pb.Event{PcrIndex: systemdConfigPCR, UntrustedType: ipl, Data: utf16Description("root=/dev/sda1"), Digest: []byte("cmdline")},
+		&pb.Event{PcrIndex: systemdConfigPCR, UntrustedType: ipl, Data: utf16Description(credentialsDescription), Digest: []byte("credentials")},
+		&pb.Event{PcrIndex: systemdConfigPCR, UntrustedType: ipl, Data: utf16Description(globalCredentialsDescription), Digest: []byte("global credentials")},
+		&pb.Event{PcrIndex: systemdConfigPCR, UntrustedType: ipl, Data: utf16Description(confextDescription), Digest: []byte("confext")},
+		&pb.Event{PcrIndex: systemdSysextPCR, UntrustedType: ipl, Data: utf16Description(sysextDescription), Digest: []byte("sysext")},
+		// Only systemd-stub measures the system extensions into PCR 13.
+		&pb.Event{PcrIndex: systemdSysextPCR, UntrustedType: ipl, Data: utf16Description(confextDescription), Digest: []byte("other")},
+	)
+
+	state, err := getSystemdStubState(crypto.SHA256, events)
+	if err != nil {
+		t.Fatalf("getSystemdStubState() failed: %v", err)
+	}
+	sections := state.GetSections()
+	if len(sections) != 2 || sections[0].GetName() != ".linux" || sections[1].GetName() != ".cmdline" {
+		t.Fatalf("got sections %v, want .linux and .cmdline", sections)
+	}
+	if want := sha256.Sum256([]byte("kernel")); string(sections[0].GetDigest()) != string(want[:]) {
+		t.Errorf("got .linux digest %x, want %x", sections[0].GetDigest(), want)
+	}
+	if got := fmt.Sprintf("%s", state.GetCredentialDigests()); got != "[credentials global credentials]" {
+		t.Errorf("got credential digests %s", got)
+	}
+	if got := fmt.Sprintf("%s %s", state.GetConfextDigests(), state.GetSysextDigests()); got != "[confext] [sysext]" {
+		t.Errorf("got extension digests %s", got)
+	}
+
+	// Event logs without systemd-stub have no state.
+	for _, platform := range test.Platforms {
+		if state := parseTestMachineState(t, platform).GetSystemdStub(); state != nil {
+			t.Errorf("%s: got systemd-stub state %v, want nil", platform.Name, state)
+		}
+	}
+}
+
+func TestGetSystemdStubStateInvalid(t *testing.T) {
+	linux := ukiSectionEvents(".linux", []byte("kernel"))
+	wrongName := ukiSectionEvents(".linux", []byte("kernel"))
+	wrongName[0].Digest = wrongName[1].Digest
+	mismatched := append(ukiSectionEvents(".linux", nil)[:1], ukiSectionEvents(".initrd", nil)[1])
+	notUTF16 := ukiSectionEvents(".linux", nil)
+	notUTF16[0].Data = []byte(".linux\x00")
+
+	subtests := []struct {
+		name   string
+		events []*pb.Event
+	}{
+		{"WrongNameDigest", wrongName},
+		{"MismatchedContents", mismatched},
+		{"MissingContents", linux[:1]},
+		{"NotUTF16", notUTF16},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if state, err := getSystemdStubState(crypto.SHA256, subtest.events); err == nil {
+				t.Errorf("getSystemdStubState() = %v, expected an error", state)
+			}
+		})
+	}
+}
+
+// signPCRPolicy returns the output of "systemd-measure sign" for the PCRs.
+func signPCRPolicy(t *testing.T, signer crypto.Signer, pcrs *tpmpb.PCRs) []byte {
+	t.Helper()
+	policy, err := internal.PCRSessionAuth(pcrs, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(policy)
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := sha256.Sum256(der)
+	var indexes []uint32
+	for index := range pcrs.GetPcrs() {
+		indexes = append(indexes, index)
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		systemdBankNames[pcrs.GetHash()]: []map[string]interface{}{{
+			"pcrs": indexes,
+			"pkfp": hex.EncodeToString(fingerprint[:]),
+			"pol":  hex.EncodeToString(policy),
+			"sig":  sig,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerifySystemdPCRSignature(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcr11 := sha256.Sum256([]byte("enter-initrd"))
+	pcrs := &tpmpb.PCRs{Hash: tpmpb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{0: make([]byte, 32), 11: pcr11[:]}}
+	signed := &tpmpb.PCRs{Hash: tpmpb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{11: pcr11[:]}}
+	otherPCRs := &tpmpb.PCRs{Hash: tpmpb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{11: make([]byte, 32)}}
+	sha1PCRs := &tpmpb.PCRs{Hash: tpmpb.HashAlgo_SHA1, Pcrs: map[uint32][]byte{11: make([]byte, 20)}}
+	badSignature := signPCRPolicy(t, rsaKey, signed)
+	badSignature[len(badSignature)-5] ^= 1
+
+	subtests := []struct {
+		name      string
+		signature []byte
+		pcrs      *tpmpb.PCRs
+		keys      []crypto.PublicKey
+		wantErr   bool
+	}{
+		{"RSA", signPCRPolicy(t, rsaKey, signed), pcrs, []crypto.PublicKey{ecKey.Public(), rsaKey.Public()}, false},
+		{"ECDSA", signPCRPolicy(t, ecKey, signed), pcrs, []crypto.PublicKey{ecKey.Public()}, false},
+		{"WrongPCRValue", signPCRPolicy(t, rsaKey, signed), otherPCRs, []crypto.PublicKey{rsaKey.Public()}, true},
+		{"UntrustedKey", signPCRPolicy(t, rsaKey, signed), pcrs, []crypto.PublicKey{ecKey.Public()}, true},
+		{"NoTrustedKeys", signPCRPolicy(t, rsaKey, signed), pcrs, nil, true},
+		{"BadSignature", badSignature, pcrs, []crypto.PublicKey{rsaKey.Public()}, true},
+		{"OtherBank", signPCRPolicy(t, rsaKey, signed), sha1PCRs, []crypto.PublicKey{rsaKey.Public()}, true},
+		{"PCRNotQuoted", signPCRPolicy(t, rsaKey, pcrs), signed, []crypto.PublicKey{rsaKey.Public()}, true},
+		{"InvalidJSON", []byte("{"), pcrs, []crypto.PublicKey{rsaKey.Public()}, true},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			covered, err := verifySystemdPCRSignature(subtest.signature, subtest.pcrs, subtest.keys)
+			if gotErr := err != nil; gotErr != subtest.wantErr {
+				t.Errorf("VerifySystemdPCRSignature() = %v, want error: %v", err, subtest.wantErr)
+			}
+			if err == nil && (len(covered) != 1 || covered[0] != systemdStubPCR) {
+				t.Errorf("the policy covers PCRs %v, want [%d]", covered, systemdStubPCR)
+			}
+		})
+	}
+}
+
+// PCR 11 is extended after boot by systemd-pcrphase, so it is verified with a
+// signed PCR policy instead of the event log.
+func TestVerifySystemdPCRSignatureAttestation(t *testing.T) {
+	rwc := test.GetPlatformTPM(t, test.UbuntuAmdSevGCE)
+	defer client.CheckedClose(t, rwc)
+	phase := sha256.Sum256([]byte("enter-initrd"))
+	for _, alg := range []tpm2.Algorithm{tpm2.AlgSHA1, tpm2.AlgSHA256} {
+		h, _ := alg.Hash()
+		if err := tpm2.PCRExtend(rwc, tpmutil.Handle(systemdStubPCR), alg, phase[:h.Size()], ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pcrs, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{systemdStubPCR}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+	nonce := []byte("pcrphase nonce")
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+	signer, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := VerifyOpts{
+		Nonce:               nonce,
+		TrustedAKs:          []crypto.PublicKey{ak.PublicKey()},
+		SystemdPCRSignature: signPCRPolicy(t, signer, pcrs),
+		SystemdPCRKeys:      []crypto.PublicKey{signer.Public()},
+	}
+	state, err := VerifyAttestation(attestation, opts)
+	if err != nil {
+		t.Fatalf("failed to verify with the signed PCR policy: %v", err)
+	}
+	if state.GetHash() != tpmpb.HashAlgo_SHA256 {
+		t.Errorf("got state for the %v bank, want SHA256 (the signed bank)", state.GetHash())
+	}
+	if state.GetPlatform().GetFirmware() == nil {
+		t.Error("the other PCRs should still be replayed from the event log")
+	}
+
+	otherPCRs := &tpmpb.PCRs{Hash: tpmpb.HashAlgo_SHA256, Pcrs: map[uint32][]byte{systemdStubPCR: phase[:]}}
+	opts.SystemdPCRSignature = signPCRPolicy(t, signer, otherPCRs)
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("verification should fail with a policy for other PCR values")
+	}
+	// The event log cannot replay PCR 11 if the policy does not cover it.
+	pcr0, err := client.ReadPCRs(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.SystemdPCRSignature = signPCRPolicy(t, signer, pcr0)
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("verification should fail with a policy which does not cover PCR 11")
+	}
+	opts.SystemdPCRSignature = signPCRPolicy(t, signer, pcrs)
+	opts.SystemdPCRKeys = nil
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("verification should fail without a trusted signing key")
+	}
+}
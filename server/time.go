@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+// ErrDifferentBootSession is returned by SameBootSession, and by
+// VerifyAttestation with VerifyOpts.SameBootAs, when two attestations do not
+// come from the same boot session of the same machine.
+var ErrDifferentBootSession = errors.New("attestations are not from the same boot session")
+
+// TPMTime is the time and clock state of a TPM, signed by a trusted key.
+//
+// Unlike other attestations (e.g. quotes), where the TPM obfuscates the reset
+// count, restart count and firmware version unless the signing key is in the
+// endorsement or platform hierarchy, a time attestation is authorized by the
+// privacy administrator and contains the real values. So they can be compared
+// between attestations signed by different keys.
+type TPMTime struct {
+	// Time is the number of milliseconds since the TPM was last reset or
+	// restarted.
+	Time uint64
+	// Clock is the number of milliseconds the TPM has been powered on, since
+	// it was last cleared. It never goes backwards while Safe is true.
+	Clock uint64
+	// ResetCount is incremented each time the TPM is reset (e.g. the machine
+	// reboots).
+	ResetCount uint32
+	// RestartCount is incremented each time the TPM is restarted (e.g. the
+	// machine resumes from hibernation), and cleared on reset.
+	RestartCount uint32
+	// Safe is false if the TPM may have reported a larger Clock before (e.g.
+	// it lost power before the clock was saved to NV storage).
+	Safe            bool
+	FirmwareVersion uint64
+}
+
+// TimeOpts allows for customizing how a time attestation is checked against
+// an earlier one.
+type TimeOpts struct {
+	// Previous is the TPMTime returned by an earlier call to VerifyTime for
+	// the same TPM. If nil, only the signature and extraData are checked.
+	// Otherwise, VerifyTime fails if the TPM was reset since then, or if its
+	// Clock went backwards, which happens if the TPM was cleared, or if either
+	// attestation was replayed.
+	Previous *TPMTime
+	// AllowRestart allows the TPM to have been restarted (but not reset) since
+	// the Previous attestation.
+	AllowRestart bool
+}
+
+// VerifyTime checks that a time attestation (from client.Key.GetTime) was
+// signed by a trusted key, and returns the attested time. The trustedAK should
+// be an AK the caller has already established trust in (for example, with
+// VerifyAttestation or an AK certificate), and extraData must match the data
+// passed to GetTime. If opts.Previous is set, the attested time is also
+// checked against it.
+func VerifyTime(attestation *tpmpb.TimeAttestation, trustedAK crypto.PublicKey, extraData []byte, opts TimeOpts) (*TPMTime, error) {
+	info, err := internal.VerifyTimeAttestation(attestation, trustedAK, extraData)
+	if err != nil {
+		return nil, err
+	}
+	t := &TPMTime{
+		Time:            info.Time,
+		Clock:           info.ClockInfo.Clock,
+		ResetCount:      info.ClockInfo.ResetCount,
+		RestartCount:    info.ClockInfo.RestartCount,
+		Safe:            info.ClockInfo.Safe == 1,
+		FirmwareVersion: info.FirmwareVersion,
+	}
+	if opts.Previous == nil {
+		return t, nil
+	}
+	prev := opts.Previous
+	if t.ResetCount != prev.ResetCount {
+		return nil, fmt.Errorf("TPM was reset since the previous attestation (reset count %d, previously %d)", t.ResetCount, prev.ResetCount)
+	}
+	if t.RestartCount != prev.RestartCount && !opts.AllowRestart {
+		return nil, fmt.Errorf("TPM was restarted since the previous attestation (restart count %d, previously %d)", t.RestartCount, prev.RestartCount)
+	}
+	if t.Clock < prev.Clock {
+		return nil, fmt.Errorf("TPM clock went backwards since the previous attestation (clock %d, previously %d)", t.Clock, prev.Clock)
+	}
+	return t, nil
+}
+
+// SameBootSession checks that two verified MachineStates come from the same
+// boot session of the same machine: that their quotes were signed by the same
+// AK, with the same reset and restart counts, and that the TPM clock did not
+// go backwards from the earlier to the later one. This prevents an attestation
+// from another boot (whose event log may differ) being presented as part of a
+// session which was verified earlier. The returned error wraps
+// ErrDifferentBootSession.
+//
+// The reset and restart counts in quotes are only comparable between quotes
+// signed by the same AK (see pb.ClockInfo), which is why the AKs must match.
+func SameBootSession(earlier, later *pb.MachineState) error {
+	if len(earlier.GetAkName()) == 0 || !bytes.Equal(earlier.GetAkName(), later.GetAkName()) {
+		return fmt.Errorf("%w: signed by different AKs", ErrDifferentBootSession)
+	}
+	before, after := earlier.GetClockInfo(), later.GetClockInfo()
+	if before == nil || after == nil {
+		return fmt.Errorf("%w: missing clock info", ErrDifferentBootSession)
+	}
+	if after.GetResetCount() != before.GetResetCount() {
+		return fmt.Errorf("%w: TPM was reset (reset count %d, previously %d)",
+			ErrDifferentBootSession, after.GetResetCount(), before.GetResetCount())
+	}
+	if after.GetRestartCount() != before.GetRestartCount() {
+		return fmt.Errorf("%w: TPM was restarted (restart count %d, previously %d)",
+			ErrDifferentBootSession, after.GetRestartCount(), before.GetRestartCount())
+	}
+	if after.GetClock() < before.GetClock() {
+		return fmt.Errorf("%w: TPM clock went backwards (clock %d, previously %d)",
+			ErrDifferentBootSession, after.GetClock(), before.GetClock())
+	}
+	return nil
+}
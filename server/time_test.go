@@ -0,0 +1,198 @@
+package server
+
+import (
+	"crypto"
+	"errors"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/simulator"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestVerifyTime(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	otherAK, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherAK.Close()
+
+	nonce := []byte("super secret nonce")
+	first, err := ak.GetTime(nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstTime, err := VerifyTime(first, ak.PublicKey(), nonce, TimeOpts{})
+	if err != nil {
+		t.Fatalf("VerifyTime() failed: %v", err)
+	}
+	if !firstTime.Safe {
+		t.Error("expected the simulator's clock to be safe")
+	}
+
+	second, err := ak.GetTime(nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondTime, err := VerifyTime(second, ak.PublicKey(), nonce, TimeOpts{Previous: firstTime})
+	if err != nil {
+		t.Fatalf("VerifyTime() with the previous time failed: %v", err)
+	}
+	if secondTime.Clock < firstTime.Clock {
+		t.Errorf("clock went from %d to %d", firstTime.Clock, secondTime.Clock)
+	}
+
+	rolledBack := *secondTime
+	rolledBack.Clock++
+	if _, err := VerifyTime(second, ak.PublicKey(), nonce, TimeOpts{Previous: &rolledBack}); err == nil {
+		t.Error("expected a clock older than the previous clock to fail")
+	}
+	restarted := *firstTime
+	restarted.RestartCount++
+	if _, err := VerifyTime(second, ak.PublicKey(), nonce, TimeOpts{Previous: &restarted}); err == nil {
+		t.Error("expected a restart to fail")
+	}
+	if _, err := VerifyTime(second, ak.PublicKey(), nonce, TimeOpts{Previous: &restarted, AllowRestart: true}); err != nil {
+		t.Errorf("expected a restart to succeed with AllowRestart: %v", err)
+	}
+
+	if _, err := VerifyTime(second, otherAK.PublicKey(), nonce, TimeOpts{}); err == nil {
+		t.Error("expected verification with the wrong AK to fail")
+	}
+	if _, err := VerifyTime(second, ak.PublicKey(), []byte("wrong nonce"), TimeOpts{}); err == nil {
+		t.Error("expected verification with the wrong nonce to fail")
+	}
+	if _, err := VerifyTime(second, ak.PublicKey(), nonce, TimeOpts{}); err != nil {
+		t.Errorf("verification should not modify the attestation: %v", err)
+	}
+}
+
+func TestVerifyTimeDetectsReset(t *testing.T) {
+	sim, err := simulator.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.CheckedClose(t, sim)
+
+	nonce := []byte("super secret nonce")
+	getTime := func() *TPMTime {
+		t.Helper()
+		ak, err := client.AttestationKeyRSA(sim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ak.Close()
+		attestation, err := ak.GetTime(nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tpmTime, err := VerifyTime(attestation, ak.PublicKey(), nonce, TimeOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tpmTime
+	}
+
+	before := getTime()
+	if err := sim.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	after := getTime()
+	if after.ResetCount == before.ResetCount {
+		t.Fatalf("reset count %d did not change after a reset", after.ResetCount)
+	}
+
+	ak, err := client.AttestationKeyRSA(sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	attestation, err := ak.GetTime(nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyTime(attestation, ak.PublicKey(), nonce, TimeOpts{Previous: before, AllowRestart: true}); err == nil {
+		t.Error("expected a reset to fail")
+	}
+	if _, err := VerifyTime(attestation, ak.PublicKey(), nonce, TimeOpts{Previous: after}); err != nil {
+		t.Errorf("VerifyTime() after the reset failed: %v", err)
+	}
+}
+
+func TestSameBootSession(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	verify := func(opts VerifyOpts) (*pb.MachineState, error) {
+		t.Helper()
+		nonce := []byte("super secret nonce")
+		attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Nonce = nonce
+		opts.TrustedAKs = []crypto.PublicKey{ak.PublicKey()}
+		return VerifyAttestation(attestation, opts)
+	}
+
+	earlier, err := verify(VerifyOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if earlier.GetClockInfo() == nil {
+		t.Fatal("MachineState is missing the quote's clock info")
+	}
+	later, err := verify(VerifyOpts{SameBootAs: earlier})
+	if err != nil {
+		t.Fatalf("verifying an attestation from the same boot failed: %v", err)
+	}
+	if err := SameBootSession(earlier, later); err != nil {
+		t.Errorf("SameBootSession() failed: %v", err)
+	}
+
+	reset := proto.Clone(earlier).(*pb.MachineState)
+	reset.ClockInfo.ResetCount++
+	restarted := proto.Clone(earlier).(*pb.MachineState)
+	restarted.ClockInfo.RestartCount++
+	otherAK := proto.Clone(earlier).(*pb.MachineState)
+	otherAK.AkName = []byte{0, 0xb, 1, 2}
+	noClock := proto.Clone(earlier).(*pb.MachineState)
+	noClock.ClockInfo = nil
+
+	subtests := []struct {
+		name    string
+		earlier *pb.MachineState
+		later   *pb.MachineState
+	}{
+		{"Reset", reset, later},
+		{"Restarted", restarted, later},
+		{"OtherAK", otherAK, later},
+		{"NoClockInfo", noClock, later},
+		{"ClockWentBackwards", later, earlier},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if err := SameBootSession(subtest.earlier, subtest.later); !errors.Is(err, ErrDifferentBootSession) {
+				t.Errorf("SameBootSession() = %v, want ErrDifferentBootSession", err)
+			}
+		})
+	}
+	if _, err := verify(VerifyOpts{SameBootAs: reset}); !errors.Is(err, ErrDifferentBootSession) {
+		t.Errorf("VerifyAttestation() with SameBootAs from another boot = %v, want ErrDifferentBootSession", err)
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultMaxResultLifetime     = time.Hour
+	defaultRuntimeResultLifetime = 5 * time.Minute
+)
+
+// ValidityOpts allows for customizing how long verified MachineStates may be
+// cached (see ComputeValidity).
+type ValidityOpts struct {
+	// MaxLifetime is how long a result may be cached if it only depends on
+	// measurements which cannot change until the machine reboots. If zero,
+	// results are valid for an hour.
+	MaxLifetime time.Duration
+	// RuntimeLifetime is how long a result may be cached if its measurements
+	// can change without a reboot, or if reboots cannot be detected because
+	// the TPM's clock is unknown or unsafe. If zero, such results are valid for
+	// five minutes. It is capped at MaxLifetime.
+	RuntimeLifetime time.Duration
+	// PolicyResult, if not nil, is the result of evaluating a Policy against
+	// the MachineState. Results depending on a waiver expire with it.
+	PolicyResult *PolicyResult
+}
+
+// ComputeValidity returns how long a MachineState verified at verifiedAt may
+// be cached, along with the events which make it stale sooner, so that every
+// relying party expires cached results in the same way. A result is always
+// stale once the machine reboots, as the boot measurements change. Results
+// with runtime measurements (executions, configuration files or key
+// derivations measured into a Canonical Event Log, or key lifecycle events in
+// a key journal) are only valid for the shorter RuntimeLifetime, as they may
+// change at any time.
+func ComputeValidity(state *pb.MachineState, verifiedAt time.Time, opts ValidityOpts) (*pb.ResultValidity, error) {
+	if opts.MaxLifetime < 0 || opts.RuntimeLifetime < 0 {
+		return nil, fmt.Errorf("result lifetimes must not be negative")
+	}
+	if opts.MaxLifetime == 0 {
+		opts.MaxLifetime = defaultMaxResultLifetime
+	}
+	if opts.RuntimeLifetime == 0 {
+		opts.RuntimeLifetime = defaultRuntimeResultLifetime
+	}
+	if opts.RuntimeLifetime > opts.MaxLifetime {
+		opts.RuntimeLifetime = opts.MaxLifetime
+	}
+
+	notAfter := verifiedAt.Add(opts.MaxLifetime)
+	var hints []*pb.RevalidationHint
+	shortLived := false
+
+	clock := state.GetClockInfo()
+	if clock != nil {
+		hints = append(hints, &pb.RevalidationHint{
+			Trigger: pb.RevalidationTrigger_REVALIDATE_ON_REBOOT,
+			Detail:  fmt.Sprintf("TPM reset count %d", clock.GetResetCount()),
+		})
+	}
+	if clock == nil || !clock.GetSafe() {
+		hints = append(hints, &pb.RevalidationHint{
+			Trigger: pb.RevalidationTrigger_REVALIDATE_ON_UNSAFE_CLOCK,
+			Detail:  "reboots cannot be detected from the TPM's reset count",
+		})
+		shortLived = true
+	}
+
+	if n := len(state.GetExecutions()); n > 0 {
+		hints = append(hints, &pb.RevalidationHint{
+			Trigger: pb.RevalidationTrigger_REVALIDATE_ON_RUNTIME_CHANGE,
+			Detail:  fmt.Sprintf("%d measured executions", n),
+		})
+		shortLived = true
+	}
+	if n := len(state.GetConfigFiles()); n > 0 {
+		hints = append(hints, &pb.RevalidationHint{
+			Trigger: pb.RevalidationTrigger_REVALIDATE_ON_RUNTIME_CHANGE,
+			Detail:  fmt.Sprintf("%d measured configuration files", n),
+		})
+		shortLived = true
+	}
+	if n := len(state.GetKeyEvents()); n > 0 {
+		hints = append(hints, &pb.RevalidationHint{
+			Trigger: pb.RevalidationTrigger_REVALIDATE_ON_RUNTIME_CHANGE,
+			Detail:  fmt.Sprintf("%d key lifecycle events", n),
+		})
+		shortLived = true
+	}
+	if n := len(state.GetKeyDerivations()); n > 0 {
+		hints = append(hints, &pb.RevalidationHint{
+			Trigger: pb.RevalidationTrigger_REVALIDATE_ON_RUNTIME_CHANGE,
+			Detail:  fmt.Sprintf("%d measured key derivations", n),
+		})
+		shortLived = true
+	}
+	if shortLived {
+		notAfter = verifiedAt.Add(opts.RuntimeLifetime)
+	}
+
+	if opts.PolicyResult != nil {
+		for _, warning := range opts.PolicyResult.Warnings {
+			expiry := warning.Waiver.GetExpireTime()
+			if expiry == nil {
+				continue
+			}
+			hints = append(hints, &pb.RevalidationHint{
+				Trigger: pb.RevalidationTrigger_REVALIDATE_ON_WAIVER_EXPIRY,
+				Detail:  warning.Rule,
+				Time:    expiry,
+			})
+			if t := expiry.AsTime(); t.Before(notAfter) {
+				notAfter = t
+			}
+		}
+	}
+
+	return &pb.ResultValidity{
+		NotBefore: timestamppb.New(verifiedAt),
+		NotAfter:  timestamppb.New(notAfter),
+		Hints:     hints,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+)
+
+func TestComputeValidity(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	safeClock := &pb.ClockInfo{ResetCount: 3, Safe: true}
+	waiverExpiry := now.Add(20 * time.Minute)
+	policyResult := &PolicyResult{Warnings: []PolicyWarning{{
+		Rule:   RuleMinimumTechnology,
+		Err:    errors.New("failed"),
+		Waiver: newTestWaiver(RuleMinimumTechnology, waiverExpiry),
+	}}}
+
+	tests := []struct {
+		name     string
+		state    *pb.MachineState
+		opts     ValidityOpts
+		lifetime time.Duration
+		triggers []pb.RevalidationTrigger
+	}{
+		{"BootOnly", &pb.MachineState{ClockInfo: safeClock}, ValidityOpts{}, time.Hour,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_REBOOT}},
+		{"CustomLifetime", &pb.MachineState{ClockInfo: safeClock}, ValidityOpts{MaxLifetime: 2 * time.Hour}, 2 * time.Hour,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_REBOOT}},
+		{"NoClock", &pb.MachineState{}, ValidityOpts{}, 5 * time.Minute,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_UNSAFE_CLOCK}},
+		{"UnsafeClock", &pb.MachineState{ClockInfo: &pb.ClockInfo{ResetCount: 3}}, ValidityOpts{}, 5 * time.Minute,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_REBOOT, pb.RevalidationTrigger_REVALIDATE_ON_UNSAFE_CLOCK}},
+		{"Executions", &pb.MachineState{ClockInfo: safeClock, Executions: []*pb.Execution{{Path: "/tmp/a"}}}, ValidityOpts{RuntimeLifetime: time.Minute}, time.Minute,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_REBOOT, pb.RevalidationTrigger_REVALIDATE_ON_RUNTIME_CHANGE}},
+		{"ConfigFiles", &pb.MachineState{ClockInfo: safeClock, ConfigFiles: []*pb.ConfigFile{{Path: "/etc/a"}}}, ValidityOpts{}, 5 * time.Minute,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_REBOOT, pb.RevalidationTrigger_REVALIDATE_ON_RUNTIME_CHANGE}},
+		{"KeyEvents", &pb.MachineState{ClockInfo: safeClock, KeyEvents: []*pb.KeyLifecycleEvent{{Action: pb.KeyAction_KEY_CREATED}}}, ValidityOpts{}, 5 * time.Minute,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_REBOOT, pb.RevalidationTrigger_REVALIDATE_ON_RUNTIME_CHANGE}},
+		{"KeyDerivations", &pb.MachineState{ClockInfo: safeClock, KeyDerivations: []*pb.KeyDerivation{{Label: "tls"}}}, ValidityOpts{}, 5 * time.Minute,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_REBOOT, pb.RevalidationTrigger_REVALIDATE_ON_RUNTIME_CHANGE}},
+		{"RuntimeLifetimeCapped", &pb.MachineState{}, ValidityOpts{MaxLifetime: time.Minute, RuntimeLifetime: time.Hour}, time.Minute,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_UNSAFE_CLOCK}},
+		{"Waiver", &pb.MachineState{ClockInfo: safeClock}, ValidityOpts{PolicyResult: policyResult}, 20 * time.Minute,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_REBOOT, pb.RevalidationTrigger_REVALIDATE_ON_WAIVER_EXPIRY}},
+		{"WaiverOutlivesResult", &pb.MachineState{}, ValidityOpts{PolicyResult: policyResult}, 5 * time.Minute,
+			[]pb.RevalidationTrigger{pb.RevalidationTrigger_REVALIDATE_ON_UNSAFE_CLOCK, pb.RevalidationTrigger_REVALIDATE_ON_WAIVER_EXPIRY}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			validity, err := ComputeValidity(tc.state, now, tc.opts)
+			if err != nil {
+				t.Fatalf("ComputeValidity() failed: %v", err)
+			}
+			if got := validity.GetNotBefore().AsTime(); !got.Equal(now) {
+				t.Errorf("not_before = %v, want %v", got, now)
+			}
+			if got := validity.GetNotAfter().AsTime().Sub(now); got != tc.lifetime {
+				t.Errorf("result is valid for %v, want %v", got, tc.lifetime)
+			}
+			var triggers []pb.RevalidationTrigger
+			for _, hint := range validity.GetHints() {
+				triggers = append(triggers, hint.GetTrigger())
+				if hint.GetTrigger() == pb.RevalidationTrigger_REVALIDATE_ON_WAIVER_EXPIRY && !hint.GetTime().AsTime().Equal(waiverExpiry) {
+					t.Errorf("waiver hint time = %v, want %v", hint.GetTime().AsTime(), waiverExpiry)
+				}
+			}
+			if len(triggers) != len(tc.triggers) {
+				t.Fatalf("got triggers %v, want %v", triggers, tc.triggers)
+			}
+			for i := range triggers {
+				if triggers[i] != tc.triggers[i] {
+					t.Errorf("got triggers %v, want %v", triggers, tc.triggers)
+					break
+				}
+			}
+		})
+	}
+
+	if _, err := ComputeValidity(&pb.MachineState{}, now, ValidityOpts{MaxLifetime: -time.Second}); err == nil {
+		t.Error("ComputeValidity() with a negative lifetime should fail")
+	}
+}
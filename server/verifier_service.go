@@ -0,0 +1,359 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Nonces are stateless: each one contains its expiry time (in milliseconds
+// since the Unix epoch) and a random value, authenticated with a (truncated)
+// HMAC under a per-service key. Unlike a NonceManager, issued nonces are not
+// stored, so anyone can request them without using up memory. Only used nonces
+// are stored, until they expire. Nonces are 32 bytes, so they fit in the
+// extraData of TPMs which only implement SHA-256, and can be quoted without
+// client.AttestOpts.NonceHash. The 16 byte random value keeps nonces issued
+// within the same millisecond from colliding, and the 80 bit MAC cannot be
+// forged by guessing online.
+const (
+	nonceExpirySize              = 6
+	nonceRandomSize              = 16
+	nonceMACSize                 = 10
+	verifierNonceSize            = nonceExpirySize + nonceRandomSize + nonceMACSize
+	defaultVerifierNonceLifetime = 5 * time.Minute
+	defaultVerifierTokenLifetime = time.Hour
+	defaultMaxNoncesPerClient    = 1 << 10
+	maxUsedVerifierNonces        = 1 << 20
+	// Allows for the nonce and the encoding of a VerifyAttestationRequest.
+	maxVerifyRequestOverhead = 1 << 10
+)
+
+// VerifierServiceOpts allows for customizing the functionality of a
+// VerifierService.
+type VerifierServiceOpts struct {
+	// Signer is used to sign the claims tokens returned from
+	// VerifyAttestation. Exactly one of Signer and Keys must be set.
+	Signer crypto.Signer
+	// Keys, if not nil, signs the claims tokens instead of Signer, allowing
+	// the signing key to be rotated while the service runs. Relying parties
+	// can fetch the public keys from Keys, which is an http.Handler.
+	Keys *SigningKeyRing
+	// VerifyOpts are used when verifying each Attestation. The Nonce field is
+	// ignored, as it is replaced by the nonce from the request.
+	VerifyOpts VerifyOpts
+	// EATOpts are used when issuing each claims token. The Nonce and IssuedAt
+	// fields are ignored. If Lifetime is zero, tokens are valid for an hour.
+	// Tokens expire sooner if the verified result's validity ends sooner.
+	EATOpts EATOpts
+	// ValidityOpts are used to compute how long each verified result may be
+	// cached (see ComputeValidity). The PolicyResult field is ignored.
+	ValidityOpts ValidityOpts
+	// Audience is who the MachineState returned from VerifyAttestation is
+	// redacted for (see RedactMachineState). The claims token is always issued
+	// from the complete MachineState. If zero, nothing is redacted.
+	Audience Audience
+	// NonceLifetime is how long a nonce from GetNonce can be used for. If zero,
+	// nonces are valid for five minutes.
+	NonceLifetime time.Duration
+	// MaxNoncesPerClient limits how many nonces a single client (identified
+	// by its IP address) can use within NonceLifetime, as used nonces must be
+	// remembered until they expire. If zero, the limit is 1024.
+	MaxNoncesPerClient int
+	// MaxConcurrentVerifications limits how many Attestations are verified at
+	// once. Further requests wait for a verification to finish, or for their
+	// context to be done. If zero, concurrent verifications are not limited.
+	MaxConcurrentVerifications int
+	// MaxAttestationSize limits the encoded size (in bytes) of each
+	// Attestation. Larger Attestations are rejected without being verified.
+	// To reject them before they are parsed, the gRPC server must be created
+	// with the options from ServerOptions. If zero, the size is not limited
+	// (beyond the gRPC server's maximum message size).
+	MaxAttestationSize int
+	// EventLogBudget limits the total size (in bytes) of the event logs being
+	// verified at once, bounding the memory used to parse them. Requests wait
+	// for enough of the budget to be free, or for their context to be done.
+	// Attestations with an event log larger than the budget are rejected. If
+	// zero, the event logs being verified are not limited.
+	EventLogBudget int
+	// VerificationTimeout limits how long a request waits for its Attestation
+	// to be verified, including any time spent waiting for a verification
+	// slot or event log budget. Verification stops before the next event log
+	// is parsed once the timeout expires. If zero, only the request's own
+	// deadline applies.
+	VerificationTimeout time.Duration
+	// AuditLog, if not nil, records every verification decision, along with
+	// PolicyVersion. Requests whose decision cannot be recorded fail.
+	// Requests which are canceled before a decision is made are not recorded.
+	AuditLog      *AuditLog
+	PolicyVersion string
+}
+
+// VerifierService is a reference implementation of the Verifier gRPC service.
+// It hands out single-use nonces, verifies Attestations with
+// VerifyAttestation, and returns the resulting MachineState as a signed Entity
+// Attestation Token (see IssueEAT). To serve it:
+//
+//	service, err := server.NewVerifierService(opts)
+//	...
+//	grpcServer := grpc.NewServer()
+//	verifier.RegisterVerifierServer(grpcServer, service)
+//	grpcServer.Serve(listener)
+//
+// The MaxConcurrentVerifications, MaxAttestationSize, EventLogBudget, and
+// VerificationTimeout options allow a VerifierService to be shared between
+// tenants, without one tenant's Attestations starving others of CPU or memory.
+// When using them, pass ServerOptions to grpc.NewServer.
+type VerifierService struct {
+	verifierpb.UnimplementedVerifierServer
+	opts VerifierServiceOpts
+	// Limits on in-progress verifications, nil if unlimited.
+	slots          *semaphore
+	eventLogBudget *semaphore
+
+	nonceKey   []byte
+	usedNonces *expiringSet
+}
+
+// NewVerifierService creates a VerifierService with the provided options.
+func NewVerifierService(opts VerifierServiceOpts) (*VerifierService, error) {
+	if (opts.Signer == nil) == (opts.Keys == nil) {
+		return nil, fmt.Errorf("exactly one of a signer or signing keys must be provided to issue claims tokens")
+	}
+	if opts.Signer != nil {
+		if _, err := getEATAlgorithm(opts.Signer.Public()); err != nil {
+			return nil, err
+		}
+	}
+	if opts.NonceLifetime < 0 || opts.MaxNoncesPerClient < 0 {
+		return nil, fmt.Errorf("nonce lifetime and limits must not be negative")
+	}
+	if opts.NonceLifetime == 0 {
+		opts.NonceLifetime = defaultVerifierNonceLifetime
+	}
+	if opts.MaxNoncesPerClient == 0 {
+		opts.MaxNoncesPerClient = defaultMaxNoncesPerClient
+	}
+	if opts.EATOpts.Lifetime == 0 {
+		opts.EATOpts.Lifetime = defaultVerifierTokenLifetime
+	}
+	if opts.ValidityOpts.MaxLifetime < 0 || opts.ValidityOpts.RuntimeLifetime < 0 {
+		return nil, fmt.Errorf("result lifetimes must not be negative")
+	}
+	opts.ValidityOpts.PolicyResult = nil
+	if opts.MaxConcurrentVerifications < 0 || opts.MaxAttestationSize < 0 ||
+		opts.EventLogBudget < 0 || opts.VerificationTimeout < 0 {
+		return nil, fmt.Errorf("verification limits must not be negative")
+	}
+	if err := checkAudience(opts.Audience); err != nil {
+		return nil, err
+	}
+	nonceKey := make([]byte, sha256.Size)
+	if _, err := rand.Read(nonceKey); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce key: %w", err)
+	}
+	service := &VerifierService{
+		opts:       opts,
+		nonceKey:   nonceKey,
+		usedNonces: newExpiringSet(maxUsedVerifierNonces, opts.MaxNoncesPerClient),
+	}
+	if opts.MaxConcurrentVerifications > 0 {
+		service.slots = newSemaphore(int64(opts.MaxConcurrentVerifications))
+	}
+	if opts.EventLogBudget > 0 {
+		service.eventLogBudget = newSemaphore(int64(opts.EventLogBudget))
+	}
+	return service, nil
+}
+
+// ServerOptions returns the options to pass to grpc.NewServer, so that
+// requests larger than MaxAttestationSize are rejected before they are parsed.
+func (s *VerifierService) ServerOptions() []grpc.ServerOption {
+	if s.opts.MaxAttestationSize == 0 {
+		return nil
+	}
+	return []grpc.ServerOption{grpc.MaxRecvMsgSize(s.opts.MaxAttestationSize + maxVerifyRequestOverhead)}
+}
+
+// GetNonce returns a new random nonce, which must be used in a call to
+// VerifyAttestation before it expires. No state is kept for issued nonces.
+func (s *VerifierService) GetNonce(ctx context.Context, req *verifierpb.GetNonceRequest) (*verifierpb.GetNonceResponse, error) {
+	nonce := make([]byte, nonceExpirySize+nonceRandomSize, verifierNonceSize)
+	expiry := time.Now().Add(s.opts.NonceLifetime)
+	putNonceExpiry(nonce, expiry)
+	if _, err := rand.Read(nonce[nonceExpirySize:]); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate nonce: %v", err)
+	}
+	nonce = append(nonce, s.nonceMAC(nonce)...)
+
+	return &verifierpb.GetNonceResponse{
+		Nonce:            nonce,
+		ExpiresInSeconds: uint64(s.opts.NonceLifetime / time.Second),
+	}, nil
+}
+
+// VerifyAttestation verifies an Attestation generated with a nonce from
+// GetNonce. The nonce is consumed once verification starts, even if it fails.
+// Requests which are canceled (or time out) while waiting for a verification
+// slot or event log budget do not consume their nonce.
+func (s *VerifierService) VerifyAttestation(ctx context.Context, req *verifierpb.VerifyAttestationRequest) (*verifierpb.VerifyAttestationResponse, error) {
+	if req.GetAttestation() == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing attestation")
+	}
+	if max := s.opts.MaxAttestationSize; max > 0 {
+		if size := proto.Size(req.GetAttestation()); size > max {
+			return nil, status.Errorf(codes.ResourceExhausted, "attestation size %d exceeds limit of %d bytes", size, max)
+		}
+	}
+	logSize := int64(len(req.GetAttestation().GetEventLog()))
+	if max := s.opts.EventLogBudget; max > 0 && logSize > int64(max) {
+		return nil, status.Errorf(codes.ResourceExhausted, "event log size %d exceeds budget of %d bytes", logSize, max)
+	}
+	// Reject unknown nonces before waiting, so they cannot hold up others.
+	if _, err := s.checkNonce(req.GetNonce()); err != nil {
+		return nil, err
+	}
+
+	if s.opts.VerificationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.VerificationTimeout)
+		defer cancel()
+	}
+	if err := s.slots.acquire(ctx, 1); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	defer s.slots.release(1)
+	if err := s.eventLogBudget.acquire(ctx, logSize); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	defer s.eventLogBudget.release(logSize)
+
+	if err := s.consumeNonce(ctx, req.GetNonce()); err != nil {
+		return nil, err
+	}
+	verifyOpts := s.opts.VerifyOpts
+	verifyOpts.Nonce = req.GetNonce()
+	ms, err := VerifyAttestationContext(ctx, req.GetAttestation(), verifyOpts)
+	if err != nil && ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if s.opts.AuditLog != nil {
+		if _, auditErr := s.opts.AuditLog.Append(req.GetAttestation(), verifyOpts, s.opts.PolicyVersion, ms, err); auditErr != nil {
+			return nil, status.Errorf(codes.Internal, "failed to record verification: %v", auditErr)
+		}
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "failed to verify attestation: %v", err)
+	}
+
+	verifiedAt := time.Now()
+	validity, err := ComputeValidity(ms, verifiedAt, s.opts.ValidityOpts)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to compute result validity: %v", err)
+	}
+	// The token and the returned validity expire together.
+	eatOpts := s.opts.EATOpts
+	if tokenExpiry := verifiedAt.Add(eatOpts.Lifetime); tokenExpiry.Before(validity.GetNotAfter().AsTime()) {
+		validity.NotAfter = timestamppb.New(tokenExpiry)
+	}
+	eatOpts.Nonce = req.GetNonce()
+	eatOpts.IssuedAt = verifiedAt
+	eatOpts.Lifetime = validity.GetNotAfter().AsTime().Sub(verifiedAt)
+	var token []byte
+	if s.opts.Keys != nil {
+		token, err = s.opts.Keys.IssueEAT(ms, eatOpts)
+	} else {
+		token, err = IssueEAT(ms, s.opts.Signer, eatOpts)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to issue claims token: %v", err)
+	}
+	redacted, err := RedactMachineState(ms, s.opts.Audience)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to redact machine state: %v", err)
+	}
+	return &verifierpb.VerifyAttestationResponse{ClaimsToken: token, MachineState: redacted, Validity: validity}, nil
+}
+
+func (s *VerifierService) nonceMAC(data []byte) []byte {
+	mac := hmac.New(sha256.New, s.nonceKey)
+	mac.Write(data)
+	return mac.Sum(nil)[:nonceMACSize]
+}
+
+// putNonceExpiry encodes the expiry time at the start of a nonce.
+func putNonceExpiry(nonce []byte, expiry time.Time) {
+	var millis [8]byte
+	binary.BigEndian.PutUint64(millis[:], uint64(expiry.UnixNano()/int64(time.Millisecond)))
+	copy(nonce, millis[8-nonceExpirySize:])
+}
+
+// nonceExpiry decodes the expiry time at the start of a nonce.
+func nonceExpiry(nonce []byte) time.Time {
+	var millis [8]byte
+	copy(millis[8-nonceExpirySize:], nonce[:nonceExpirySize])
+	return time.Unix(0, int64(binary.BigEndian.Uint64(millis[:]))*int64(time.Millisecond))
+}
+
+// checkNonce checks that a nonce was issued by GetNonce, and has not expired
+// or been used, returning its expiry time.
+func (s *VerifierService) checkNonce(nonce []byte) (time.Time, error) {
+	if len(nonce) != verifierNonceSize {
+		return time.Time{}, status.Error(codes.FailedPrecondition, "unknown nonce")
+	}
+	data, mac := nonce[:nonceExpirySize+nonceRandomSize], nonce[nonceExpirySize+nonceRandomSize:]
+	if !hmac.Equal(mac, s.nonceMAC(data)) {
+		return time.Time{}, status.Error(codes.FailedPrecondition, "unknown nonce")
+	}
+	expiry := nonceExpiry(data)
+	if !time.Now().Before(expiry) {
+		return time.Time{}, status.Error(codes.FailedPrecondition, "nonce has expired")
+	}
+	if s.usedNonces.contains(string(nonce)) {
+		return time.Time{}, status.Error(codes.FailedPrecondition, "nonce has already been used")
+	}
+	return expiry, nil
+}
+
+// consumeNonce marks a nonce as used by the requesting client.
+func (s *VerifierService) consumeNonce(ctx context.Context, nonce []byte) error {
+	expiry, err := s.checkNonce(nonce)
+	if err != nil {
+		return err
+	}
+	switch err := s.usedNonces.add(string(nonce), clientAddress(ctx), nil, expiry); err {
+	case nil:
+		return nil
+	case errEntryExists:
+		return status.Error(codes.FailedPrecondition, "nonce has already been used")
+	default:
+		return status.Errorf(codes.ResourceExhausted, "cannot use nonce: %v", err)
+	}
+}
+
+// clientAddress returns the IP address of the gRPC client making a request,
+// or an empty string if it is unknown.
+func clientAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,454 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startVerifier serves a VerifierService over an in-memory connection,
+// returning a client for it.
+func startVerifier(t *testing.T, opts VerifierServiceOpts) verifierpb.VerifierClient {
+	t.Helper()
+	service, err := NewVerifierService(opts)
+	if err != nil {
+		t.Fatalf("NewVerifierService() failed: %v", err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(service.ServerOptions()...)
+	verifierpb.RegisterVerifierServer(grpcServer, service)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial verifier: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return verifierpb.NewVerifierClient(conn)
+}
+
+func TestVerifierService(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := startVerifier(t, VerifierServiceOpts{
+		Signer:       signer,
+		VerifyOpts:   VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}},
+		EATOpts:      EATOpts{Issuer: "test-verifier"},
+		ValidityOpts: ValidityOpts{MaxLifetime: 10 * time.Minute},
+	})
+	resp, err := ak.AttestToVerifier(context.Background(), verifier)
+	if err != nil {
+		t.Fatalf("AttestToVerifier() failed: %v", err)
+	}
+	if resp.GetMachineState() == nil {
+		t.Error("response is missing the MachineState")
+	}
+	claims := decodeCWT(t, resp.GetClaimsToken(), &signer.PublicKey)
+	if got := claims[cwtIssuer]; got != "test-verifier" {
+		t.Errorf("iss = %v, want test-verifier", got)
+	}
+	exp, ok := claims[cwtExpiry]
+	if !ok {
+		t.Fatal("claims token should expire by default")
+	}
+	validity := resp.GetValidity()
+	if got, want := validity.GetNotAfter().AsTime().Sub(validity.GetNotBefore().AsTime()), 10*time.Minute; got != want {
+		t.Errorf("result is valid for %v, want %v", got, want)
+	}
+	if want := uint64(validity.GetNotAfter().AsTime().Unix()); exp != want {
+		t.Errorf("exp = %v, want the end of the result's validity %v", exp, want)
+	}
+	if len(validity.GetHints()) == 0 || validity.GetHints()[0].GetTrigger() != pb.RevalidationTrigger_REVALIDATE_ON_REBOOT {
+		t.Errorf("got hints %v, want revalidation on reboot", validity.GetHints())
+	}
+}
+
+func TestVerifierServiceNonces(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := startVerifier(t, VerifierServiceOpts{
+		Signer:        signer,
+		VerifyOpts:    VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}},
+		NonceLifetime: time.Second,
+	})
+	ctx := context.Background()
+
+	attestWithNewNonce := func() ([]byte, *verifierpb.VerifyAttestationRequest) {
+		nonceResp, err := verifier.GetNonce(ctx, &verifierpb.GetNonceRequest{})
+		if err != nil {
+			t.Fatalf("GetNonce() failed: %v", err)
+		}
+		attestation, err := ak.Attest(client.AttestOpts{Nonce: nonceResp.GetNonce()})
+		if err != nil {
+			t.Fatalf("failed to attest: %v", err)
+		}
+		return nonceResp.GetNonce(), &verifierpb.VerifyAttestationRequest{
+			Nonce:       nonceResp.GetNonce(),
+			Attestation: attestation,
+		}
+	}
+
+	// Nonces are single use.
+	_, req := attestWithNewNonce()
+	if _, err := verifier.VerifyAttestation(ctx, req); err != nil {
+		t.Fatalf("VerifyAttestation() failed: %v", err)
+	}
+	if _, err := verifier.VerifyAttestation(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("reusing a nonce: got error %v, want FailedPrecondition", err)
+	}
+
+	// Nonces not issued by the verifier are rejected.
+	nonce, req := attestWithNewNonce()
+	req.Nonce = append(nonce, 0)
+	if _, err := verifier.VerifyAttestation(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("unknown nonce: got error %v, want FailedPrecondition", err)
+	}
+	req.Nonce = append([]byte{}, nonce...)
+	req.Nonce[0] ^= 1 // Extend the expiry time
+	if _, err := verifier.VerifyAttestation(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("modified nonce: got error %v, want FailedPrecondition", err)
+	}
+
+	// The attestation must be generated with the provided nonce.
+	_, req = attestWithNewNonce()
+	otherNonce, _ := attestWithNewNonce()
+	req.Nonce = otherNonce
+	if _, err := verifier.VerifyAttestation(ctx, req); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("mismatched nonce: got error %v, want PermissionDenied", err)
+	}
+
+	// Nonces expire.
+	_, req = attestWithNewNonce()
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := verifier.VerifyAttestation(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expired nonce: got error %v, want FailedPrecondition", err)
+	}
+
+	if _, err := verifier.VerifyAttestation(ctx, &verifierpb.VerifyAttestationRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("missing attestation: got error %v, want InvalidArgument", err)
+	}
+}
+
+func TestVerifierServiceUntrustedAK(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := startVerifier(t, VerifierServiceOpts{Signer: signer})
+	if _, err := ak.AttestToVerifier(context.Background(), verifier); err == nil {
+		t.Error("attesting with an untrusted AK should have failed")
+	}
+}
+
+func TestVerifierServiceAuditLog(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trusted, untrusted bytes.Buffer
+	verifier := startVerifier(t, VerifierServiceOpts{
+		Signer:        signer,
+		VerifyOpts:    VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}},
+		AuditLog:      NewAuditLog(&trusted),
+		PolicyVersion: "test-policy",
+	})
+	if _, err := ak.AttestToVerifier(context.Background(), verifier); err != nil {
+		t.Fatalf("AttestToVerifier() failed: %v", err)
+	}
+	// Unknown nonces are rejected before a decision is made.
+	if _, err := verifier.VerifyAttestation(context.Background(), &verifierpb.VerifyAttestationRequest{
+		Nonce:       []byte("unknown"),
+		Attestation: &pb.Attestation{},
+	}); err == nil {
+		t.Fatal("VerifyAttestation() with an unknown nonce should fail")
+	}
+	if _, err := ak.AttestToVerifier(context.Background(), startVerifier(t, VerifierServiceOpts{
+		Signer:   signer,
+		AuditLog: NewAuditLog(&untrusted),
+	})); err == nil {
+		t.Fatal("attesting with an untrusted AK should have failed")
+	}
+
+	records, err := ReadAuditLog(&trusted, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !records[0].Verified || records[0].PolicyVersion != "test-policy" {
+		t.Errorf("got records %+v, want one verified decision", records)
+	}
+	records, err = ReadAuditLog(&untrusted, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Verified || records[0].Error == "" {
+		t.Errorf("got records %+v, want one failed decision", records)
+	}
+}
+
+func TestNewVerifierServiceFailures(t *testing.T) {
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewVerifierService(VerifierServiceOpts{}); err == nil {
+		t.Error("NewVerifierService() without a signer should have failed")
+	}
+	if _, err := NewVerifierService(VerifierServiceOpts{Signer: p224}); err == nil {
+		t.Error("NewVerifierService() with an unsupported signer should have failed")
+	}
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewVerifierService(VerifierServiceOpts{Signer: signer, MaxConcurrentVerifications: -1}); err == nil {
+		t.Error("NewVerifierService() with a negative limit should have failed")
+	}
+	if _, err := NewVerifierService(VerifierServiceOpts{Signer: signer, NonceLifetime: -time.Second}); err == nil {
+		t.Error("NewVerifierService() with a negative nonce lifetime should have failed")
+	}
+	if _, err := NewVerifierService(VerifierServiceOpts{Signer: signer, ValidityOpts: ValidityOpts{RuntimeLifetime: -time.Second}}); err == nil {
+		t.Error("NewVerifierService() with a negative result lifetime should have failed")
+	}
+	if _, err := NewVerifierService(VerifierServiceOpts{Signer: signer, Audience: AudienceRelyingParty + 1}); err == nil {
+		t.Error("NewVerifierService() with an unknown audience should have failed")
+	}
+}
+
+// Nonces must fit in the extraData of TPMs which only implement SHA-256
+// (a TPM2B_DATA of at most 34 bytes), as clients quote them unhashed.
+func TestVerifierServiceNonceSize(t *testing.T) {
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifierService(VerifierServiceOpts{Signer: signer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := verifier.GetNonce(context.Background(), &verifierpb.GetNonceRequest{})
+	if err != nil {
+		t.Fatalf("GetNonce() failed: %v", err)
+	}
+	nonce := resp.GetNonce()
+	if len(nonce) != 32 {
+		t.Errorf("got a %d byte nonce, want 32 bytes", len(nonce))
+	}
+	if _, err := verifier.checkNonce(nonce); err != nil {
+		t.Errorf("checkNonce() failed: %v", err)
+	}
+	if expiry, want := nonceExpiry(nonce), time.Now().Add(defaultVerifierNonceLifetime); expiry.After(want) || want.Sub(expiry) > time.Second {
+		t.Errorf("nonce expires at %v, want %v", expiry, want)
+	}
+	nonce[len(nonce)-1] ^= 1
+	if _, err := verifier.checkNonce(nonce); err == nil {
+		t.Error("checkNonce() with a modified MAC should fail")
+	}
+}
+
+func TestVerifierServiceLimits(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	newRequest := func(service *VerifierService) *verifierpb.VerifyAttestationRequest {
+		resp, err := service.GetNonce(ctx, &verifierpb.GetNonceRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestation, err := ak.Attest(client.AttestOpts{Nonce: resp.GetNonce()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &verifierpb.VerifyAttestationRequest{Nonce: resp.GetNonce(), Attestation: attestation}
+	}
+
+	trusted := VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey()}}
+
+	t.Run("MaxAttestationSize", func(t *testing.T) {
+		opts := VerifierServiceOpts{Signer: signer, VerifyOpts: trusted, MaxAttestationSize: 64}
+		service, err := NewVerifierService(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.VerifyAttestation(ctx, newRequest(service)); status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("oversized attestation: got error %v, want ResourceExhausted", err)
+		}
+
+		// The gRPC server rejects oversized requests before parsing them.
+		verifier := startVerifier(t, opts)
+		nonceResp, err := verifier.GetNonce(ctx, &verifierpb.GetNonceRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestation, err := ak.Attest(client.AttestOpts{Nonce: nonceResp.GetNonce()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestation.EventLog = make([]byte, 1<<12)
+		req := &verifierpb.VerifyAttestationRequest{Nonce: nonceResp.GetNonce(), Attestation: attestation}
+		if _, err := verifier.VerifyAttestation(ctx, req); status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("oversized request: got error %v, want ResourceExhausted", err)
+		}
+	})
+
+	t.Run("MaxConcurrentVerifications", func(t *testing.T) {
+		service, err := NewVerifierService(VerifierServiceOpts{
+			Signer:                     signer,
+			VerifyOpts:                 trusted,
+			MaxConcurrentVerifications: 1,
+			VerificationTimeout:        100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Occupy the only verification slot.
+		if err := service.slots.acquire(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+		req := newRequest(service)
+		if _, err := service.VerifyAttestation(ctx, req); status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("no free slot: got error %v, want DeadlineExceeded", err)
+		}
+		service.slots.release(1)
+		// The nonce is not consumed while waiting for a slot.
+		if _, err := service.VerifyAttestation(ctx, req); err != nil {
+			t.Errorf("free slot: VerifyAttestation() failed: %v", err)
+		}
+	})
+
+	t.Run("EventLogBudget", func(t *testing.T) {
+		service, err := NewVerifierService(VerifierServiceOpts{
+			Signer:              signer,
+			VerifyOpts:          trusted,
+			EventLogBudget:      64,
+			VerificationTimeout: 100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := newRequest(service)
+		req.Attestation.EventLog = make([]byte, 65)
+		if _, err := service.VerifyAttestation(ctx, req); status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("event log over budget: got error %v, want ResourceExhausted", err)
+		}
+
+		// Use up the budget, so the request has to wait.
+		req.Attestation.EventLog = []byte("not an event log")
+		if err := service.eventLogBudget.acquire(ctx, 64); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.VerifyAttestation(ctx, req); status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("no free budget: got error %v, want DeadlineExceeded", err)
+		}
+		service.eventLogBudget.release(64)
+		if _, err := service.VerifyAttestation(ctx, req); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("free budget: got error %v, want PermissionDenied", err)
+		}
+	})
+
+	t.Run("MaxNoncesPerClient", func(t *testing.T) {
+		service, err := NewVerifierService(VerifierServiceOpts{
+			Signer:             signer,
+			VerifyOpts:         trusted,
+			MaxNoncesPerClient: 1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.VerifyAttestation(ctx, newRequest(service)); err != nil {
+			t.Fatalf("VerifyAttestation() failed: %v", err)
+		}
+		if _, err := service.VerifyAttestation(ctx, newRequest(service)); status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("second nonce from the client: got error %v, want ResourceExhausted", err)
+		}
+	})
+
+	t.Run("CanceledRequest", func(t *testing.T) {
+		service, err := NewVerifierService(VerifierServiceOpts{
+			Signer:                     signer,
+			VerifyOpts:                 trusted,
+			MaxConcurrentVerifications: 1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := service.slots.acquire(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+		req := newRequest(service)
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := service.VerifyAttestation(canceled, req); status.Code(err) != codes.Canceled {
+			t.Errorf("canceled request: got error %v, want Canceled", err)
+		}
+		service.slots.release(1)
+		if _, err := service.VerifyAttestation(ctx, req); err != nil {
+			t.Errorf("VerifyAttestation() after canceled request failed: %v", err)
+		}
+	})
+}
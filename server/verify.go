@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
@@ -12,24 +13,153 @@ import (
 	"github.com/google/go-tpm/tpm2"
 )
 
-// The hash algorithms we support, in their preferred order of use.
-var supportedHashAlgs = []tpm2.Algorithm{
-	tpm2.AlgSHA512, tpm2.AlgSHA384, tpm2.AlgSHA256, tpm2.AlgSHA1,
-}
+var (
+	// ErrNonceMismatch is wrapped by the errors of VerifyAttestation (and the
+	// other verification functions) when a quote or certification was not
+	// made over the verifier's nonce, for example because the attestation is
+	// a replay of an earlier one.
+	ErrNonceMismatch = internal.ErrExtraDataMismatch
+	// ErrUntrustedAK is wrapped by the errors of VerifyAttestation when the
+	// AK, or the key of an additional quote, is not in VerifyOpts.TrustedAKs.
+	ErrUntrustedAK = internal.ErrUntrustedAK
+)
 
 // VerifyOpts allows for customizing the functionality of VerifyAttestation.
 type VerifyOpts struct {
 	// The nonce used when calling client.Attest
 	Nonce []byte
+	// The hash algorithm used to bind the Nonce to the quotes, which must be
+	// the client.AttestOpts.NonceHash used when calling client.Attest. An
+	// attestation which binds the nonce with a different algorithm (or without
+	// one, if this is zero) fails verification, so a client and a verifier
+	// which disagree are detected, rather than failing with mismatched quote
+	// extraData.
+	NonceHash crypto.Hash
+	// If set, the Nonce must have been issued by this NonceManager, and not
+	// used before. It is consumed before the attestation is verified, so it
+	// cannot be used again even if verification fails. Otherwise, the caller
+	// must make sure that the Nonce is fresh.
+	Nonces *NonceManager
 	// Trusted public keys that can be used to directly verify the key used for
 	// attestation. This option should be used if you already know the AK.
+	// The keys which signed an attestation's additional_quotes must also be
+	// trusted, and should be resident in the same TPM as the AK. Keys held in
+	// a PKCS#11 token can be referenced with PKCS11PublicKeys.
 	TrustedAKs []crypto.PublicKey
 	// Allow attestations to be verified using SHA-1. This defaults to false
 	// because SHA-1 is a weak hash algorithm with known collision attacks.
 	// However, setting this to true may be necessary if the client only
 	// supports the legacy event log format. This is the case on older Linux
-	// distributions (such as Debian 10).
+	// distributions (such as Debian 10). It is ignored when built with the
+	// fips build tag (go build -tags fips), which never allows SHA-1.
 	AllowSHA1 bool
+	// The Endorsement Key (EK) certificate of the TPM which generated the
+	// attestation. If set, it is verified with VerifyEKCertificate and the
+	// TPM's attributes are reported in MachineState.TpmInfo. Note that this
+	// does not check that the AK is resident in the same TPM as the EK; the
+	// TrustedAKs should come from an enrollment process which checks this.
+	// The attestation's intermediate_certs are used to build the chain to
+	// EKRoots, so no certificates need to be fetched.
+	EKCert []byte
+	// The manufacturer certificates used to verify EKCert. If nil, the
+	// certificates from DefaultEKRoots are used.
+	EKRoots *EKRootStore
+	// Use the attestation's ek_cert if EKCert is unset. Nothing in the
+	// attestation binds its AK to that EK, so any genuine EK certificate could
+	// be copied into it. Only set this if the TrustedAKs were bound to the EK
+	// of the same TPM, for example by activating a credential (see
+	// GenerateChallenge) when they were enrolled. Otherwise, the attestation's
+	// ek_cert is ignored.
+	TrustAttestationEKCert bool
+	// Allow the AK, the keys which signed additional quotes, and the EK in
+	// EKCert to be RSA keys generated by TPM firmware with the ROCA
+	// vulnerability (see IsROCAVulnerable). By default, such keys fail
+	// verification with an error wrapping ErrROCAVulnerable, as their private
+	// keys can be computed from their public keys, so their quotes could be
+	// forged. If set, they are only reported in MachineState.RocaVulnerableAk
+	// and RocaVulnerableEk, for example while a fleet's TPM firmware is being
+	// updated and its keys regenerated.
+	AllowROCAVulnerableKeys bool
+	// If set, the attestation must come from the same boot session as this
+	// earlier verified MachineState (see SameBootSession), or verification
+	// fails with an error wrapping ErrDifferentBootSession. This binds the
+	// attestation to the boot whose event log was verified before.
+	SameBootAs *pb.MachineState
+	// An earlier verified MachineState of the same machine. If set, whether
+	// the TPM was cleared since is reported in MachineState.TpmClear (see
+	// DetectTPMClear).
+	PreviousState *pb.MachineState
+	// If set, verification fails with an error wrapping ErrTPMCleared if the
+	// TPM was cleared or replaced since PreviousState.
+	RejectTPMClear bool
+	// The NV index of the attestation's key journal, if it has one. The index
+	// must also only be writable by the TPM owner. If zero, it must be
+	// cel.KeyJournalIndex.
+	KeyJournalIndex uint32
+
+	// The following requirements are checked against the verified event log.
+	// A machine which does not satisfy one fails verification with an error
+	// wrapping ErrSecureBootDisabled, ErrDebugMode, ErrFirmwareTooOld,
+	// ErrDBCertNotAllowed, ErrDBXCertMissing, ErrKernelNotAllowed,
+	// ErrInitrdNotAllowed or ErrCmdlineNotAllowed respectively.
+
+	// If set, Secure Boot must have been enabled.
+	RequireSecureBoot bool
+	// If set, the firmware must not have been in UEFI debug mode.
+	ForbidDebugMode bool
+	// If non-zero, the machine must be a GCE VM whose virtual firmware version
+	// is at least this version.
+	MinimumFirmwareVersion uint32
+	// If non-empty, every certificate in the Secure Boot signature database
+	// (db) must be one of these DER encoded certificates, such as
+	// MicrosoftUEFICA2011Cert.
+	AllowedDBCerts [][]byte
+	// Every one of these DER encoded certificates, such as
+	// RevokedCanonicalBootholeCert, must be in the Secure Boot forbidden
+	// signature database (dbx).
+	RequiredDBXCerts [][]byte
+	// If non-empty, the kernel image must have one of these digests, computed
+	// with the hash algorithm of the verified PCR bank (normally SHA-256).
+	// Either the digest of the kernel file or its Authenticode digest can
+	// match (see LinuxKernelState).
+	AllowedKernelDigests [][]byte
+	// If non-empty, at least one initrd must have been measured, and every
+	// initrd must have one of these digests, computed like
+	// AllowedKernelDigests.
+	AllowedInitrdDigests [][]byte
+	// If non-empty, the kernel command line must be one of these.
+	AllowedKernelCmdlines []string
+
+	// A PCR signature of a Unified Kernel Image, as produced by
+	// "systemd-measure sign". If set, the quoted PCR values must match one of
+	// its policies signed by a key in SystemdPCRKeys (see
+	// VerifySystemdPCRSignature). If the matching policy covers PCR 11, it is
+	// then trusted because of the signature, rather than replayed from the
+	// event log, so it can include the boot phases measured by
+	// systemd-pcrphase, but its measurements (the UKI sections in
+	// MachineState.SystemdStub) are not reported. Otherwise PCR 11 is still
+	// replayed from the event log.
+	SystemdPCRSignature []byte
+	// The public keys trusted to sign SystemdPCRSignature, such as the key in
+	// the UKI's .pcrpkey section (if the UKI is itself trusted).
+	SystemdPCRKeys []crypto.PublicKey
+}
+
+// Verifier verifies Attestations, returning the verified MachineState.
+// DefaultVerifier implements it using VerifyAttestation. Code which verifies
+// attestations can depend on a Verifier, so that it can be tested with a fake
+// (such as testutil.Verifier) instead of real attestations.
+type Verifier interface {
+	VerifyAttestation(attestation *pb.Attestation, opts VerifyOpts) (*pb.MachineState, error)
+}
+
+// DefaultVerifier is the Verifier which calls VerifyAttestation.
+var DefaultVerifier Verifier = defaultVerifier{}
+
+type defaultVerifier struct{}
+
+func (defaultVerifier) VerifyAttestation(attestation *pb.Attestation, opts VerifyOpts) (*pb.MachineState, error) {
+	return VerifyAttestation(attestation, opts)
 }
 
 // VerifyAttestation performs the following checks on an Attestation:
@@ -40,12 +170,65 @@ type VerifyOpts struct {
 //    - the quote data is a valid TPMS_QUOTE_INFO
 //    - the quote data was taken over the provided PCRs
 //    - the provided PCR values match the quote data internal digest
-//    - the provided opts.Nonce matches that in the quote data
+//    - any additional quotes are signed by distinct keys in opts.TrustedAKs,
+//      bind the same nonce, and quote PCRs which no other key quoted
+//    - if opts.Nonces is set, opts.Nonce was issued by it, has not expired
+//      and was not used before (it is used up even if verification fails)
+//    - the attestation binds the nonce with opts.NonceHash
+//    - the provided opts.Nonce (or its opts.NonceHash digest) matches that in
+//      the quote data
 //    - the provided eventlog matches the provided PCR values
+//    - if present, the canonical_event_log matches the provided PCR values
+//    - if opts.SystemdPCRSignature is set, the PCRs match a policy signed by
+//      one of opts.SystemdPCRKeys (see VerifySystemdPCRSignature)
+//    - if opts.SameBootAs is set, the quote was signed by the same AK in the
+//      same boot session (see SameBootSession)
+//    - if opts.RejectTPMClear is set, the TPM was not cleared or replaced
+//      since opts.PreviousState (see DetectTPMClear)
+//    - if present, the key_journal's NV index was certified by the AK over the
+//      nonce, is opts.KeyJournalIndex and only writable by the TPM owner, and
+//      its log replays to the certified contents
+//    - if opts.EKCert (or, with opts.TrustAttestationEKCert, the
+//      attestation's ek_cert) is set, the EK certificate chains to a manufacturer certificate in opts.EKRoots (see
+//      VerifyEKCertificate), possibly through the attestation's
+//      intermediate_certs
+//    - unless opts.AllowROCAVulnerableKeys is set, neither the AK, the
+//      additional quote keys nor the EK are vulnerable to ROCA
+//    - the firmware, Secure Boot and kernel state satisfy the requirements in
+//      opts (such as opts.RequireSecureBoot)
 //
 // After this, the eventlog is parsed and the corresponding MachineState is
 // returned. This design prevents unverified MachineStates from being used.
+//
+// Callers can tell the common failures apart with errors.Is and errors.As: an
+// untrusted AK wraps ErrUntrustedAK, a quote over another nonce wraps
+// ErrNonceMismatch, a replayed or expired nonce wraps ErrUnknownNonce, and an
+// event log which does not replay to the quoted PCRs gives a *LogReplayError,
+// naming the bank and each mismatched PCR (the first of which errors.As also
+// finds as a *PCRMismatchError).
 func VerifyAttestation(attestation *pb.Attestation, opts VerifyOpts) (*pb.MachineState, error) {
+	return VerifyAttestationContext(context.Background(), attestation, opts)
+}
+
+// VerifyAttestationContext is like VerifyAttestation, but stops with ctx.Err()
+// once ctx is done. Verification does not use the network, but replaying large
+// event logs against each quote can take a while, so servers should bound it
+// with the request's deadline.
+func VerifyAttestationContext(ctx context.Context, attestation *pb.Attestation, opts VerifyOpts) (*pb.MachineState, error) {
+	return verifyAttestation(ctx, attestation, opts, nil)
+}
+
+// verifyAttestation implements VerifyAttestationContext. If ekCerts is not
+// nil, the EK certificate is verified with it, instead of with opts.EKRoots.
+func verifyAttestation(ctx context.Context, attestation *pb.Attestation, opts VerifyOpts, ekCerts *ekCertCache) (*pb.MachineState, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.Nonces != nil {
+		if err := opts.Nonces.Consume(opts.Nonce); err != nil {
+			return nil, err
+		}
+	}
 	// Verify the AK
 	akPubArea, err := tpm2.DecodePublic(attestation.GetAkPub())
 	if err != nil {
@@ -59,6 +242,30 @@ func VerifyAttestation(attestation *pb.Attestation, opts VerifyOpts) (*pb.Machin
 		return nil, err
 	}
 
+	akName, err := akPubArea.Name()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute AK name: %w", err)
+	}
+	akNameEncoded, err := akName.Digest.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode AK name: %w", err)
+	}
+
+	var tpmInfo *pb.TpmInfo
+	var ekPub crypto.PublicKey
+	if ekCertDER := ekCertToVerify(attestation, opts); len(ekCertDER) != 0 {
+		var ekCert *EKCertificate
+		if ekCerts != nil {
+			ekCert, err = ekCerts.verify(ekCertDER, attestation.GetIntermediateCerts())
+		} else {
+			ekCert, err = verifyEKCertWithOpts(ekCertDER, attestation.GetIntermediateCerts(), opts)
+		}
+		if err != nil {
+			return nil, err
+		}
+		tpmInfo, ekPub = ekCert.TPM, ekCert.PublicKey
+	}
+
 	// Verify the signing hash algorithm
 	signHashAlg, err := internal.GetSigningHashAlg(akPubArea)
 	if err != nil {
@@ -68,22 +275,64 @@ func VerifyAttestation(attestation *pb.Attestation, opts VerifyOpts) (*pb.Machin
 		return nil, fmt.Errorf("in AK public area: %w", err)
 	}
 
+	extraData, err := nonceExtraData(attestation, opts)
+	if err != nil {
+		return nil, err
+	}
+	additionalKeys, err := additionalQuoteKeys(attestation, akPubKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	rocaAK, rocaEK, err := checkAttestationROCA(append([]crypto.PublicKey{akPubKey}, additionalKeys...), ekPub, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	// Attempt to replay the log against our PCRs in order of hash preference
 	var lastErr error
-	for _, quote := range supportedQuotes(attestation.GetQuotes()) {
+	for _, quote := range internal.SupportedQuotes(attestation.GetQuotes()) {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
 		// Verify the Quote
-		if err = internal.VerifyQuote(quote, akPubKey, opts.Nonce); err != nil {
+		if err = internal.VerifyQuote(quote, akPubKey, extraData); err != nil {
 			lastErr = fmt.Errorf("failed to verify quote: %w", err)
 			continue
 		}
+		pcrs, err := internal.MergeAdditionalQuotes(quote, attestation.GetAdditionalQuotes(), additionalKeys, extraData)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// PCR 11 is authenticated by its signed policy instead of the event
+		// log, as systemd-pcrphase measurements are not in the event log. If
+		// the policy does not cover it, it is still replayed.
+		if len(opts.SystemdPCRSignature) != 0 {
+			covered, err := verifySystemdPCRSignature(opts.SystemdPCRSignature, pcrs, opts.SystemdPCRKeys)
+			if err != nil {
+				lastErr = fmt.Errorf("failed to verify systemd PCR signature: %w", err)
+				continue
+			}
+			for _, index := range covered {
+				if index == systemdStubPCR {
+					pcrs = withoutPCR(pcrs, systemdStubPCR)
+				}
+			}
+		}
 
 		// Parse the event log and replay the events against the provided PCRs
-		pcrs := quote.GetPcrs()
 		state, err := ParseMachineState(attestation.GetEventLog(), pcrs)
 		if err != nil {
 			lastErr = fmt.Errorf("failed to validate the event log: %w", err)
 			continue
 		}
+		if celData := attestation.GetCanonicalEventLog(); len(celData) != 0 {
+			if err = applyCanonicalEventLog(state, celData, pcrs); err != nil {
+				lastErr = fmt.Errorf("failed to validate the canonical event log: %w", err)
+				continue
+			}
+		}
 
 		// Verify the PCR hash algorithm. We have this check here (instead of at
 		// the start of the loop) so that the user gets a "SHA-1 not supported"
@@ -94,7 +343,35 @@ func VerifyAttestation(attestation *pb.Attestation, opts VerifyOpts) (*pb.Machin
 			lastErr = fmt.Errorf("when verifying PCRs: %w", err)
 			continue
 		}
+		if err = checkPosture(state, opts); err != nil {
+			return nil, err
+		}
 
+		state.TpmInfo = tpmInfo
+		state.AkName = akNameEncoded
+		state.RocaVulnerableAk, state.RocaVulnerableEk = rocaAK, rocaEK
+		if state.ClockInfo, err = quoteClockInfo(quote); err != nil {
+			return nil, err
+		}
+		state.TpmCapabilities = attestation.GetCapabilities()
+		if attestation.GetKeyJournal() != nil {
+			if state.KeyEvents, err = verifyKeyJournal(attestation.GetKeyJournal(), akPubKey, extraData, opts.KeyJournalIndex); err != nil {
+				return nil, err
+			}
+		}
+		if opts.SameBootAs != nil {
+			if err = SameBootSession(opts.SameBootAs, state); err != nil {
+				return nil, err
+			}
+		}
+		state.ClearIndicators = attestation.GetClearIndicators()
+		if opts.PreviousState != nil {
+			state.TpmClear = DetectTPMClear(opts.PreviousState, state)
+			if verdict := state.TpmClear.GetVerdict(); opts.RejectTPMClear &&
+				(verdict == pb.TpmClearVerdict_TPM_CLEARED || verdict == pb.TpmClearVerdict_TPM_REPLACED) {
+				return nil, fmt.Errorf("%w: %v", ErrTPMCleared, state.TpmClear.GetIndicators())
+			}
+		}
 		return state, nil
 	}
 
@@ -104,6 +381,53 @@ func VerifyAttestation(attestation *pb.Attestation, opts VerifyOpts) (*pb.Machin
 	return nil, fmt.Errorf("attestation does not contain a supported quote")
 }
 
+// additionalQuoteKeys returns the trusted public keys which signed the
+// attestation's additional quotes.
+func additionalQuoteKeys(attestation *pb.Attestation, akPubKey crypto.PublicKey, opts VerifyOpts) ([]crypto.PublicKey, error) {
+	return internal.AdditionalQuoteKeys(attestation, akPubKey, opts.TrustedAKs)
+}
+
+// quoteClockInfo returns the clock state from a verified quote.
+func quoteClockInfo(quote *tpmpb.Quote) (*pb.ClockInfo, error) {
+	attest, err := internal.DecodeAttest(quote.GetQuote())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ClockInfo{
+		Clock:        attest.ClockInfo.Clock,
+		ResetCount:   attest.ClockInfo.ResetCount,
+		RestartCount: attest.ClockInfo.RestartCount,
+		Safe:         attest.ClockInfo.Safe == 1,
+	}, nil
+}
+
+// nonceExtraData checks that the attestation binds the nonce with the
+// expected algorithm, and returns the extraData its quotes must contain.
+func nonceExtraData(attestation *pb.Attestation, opts VerifyOpts) ([]byte, error) {
+	return internal.AttestationExtraData(attestation, opts.Nonce, opts.NonceHash)
+}
+
+// ekCertToVerify returns the EK certificate to verify for the attestation:
+// opts.EKCert, or the attestation's ek_cert if opts.TrustAttestationEKCert is
+// set.
+func ekCertToVerify(attestation *pb.Attestation, opts VerifyOpts) []byte {
+	if len(opts.EKCert) != 0 || !opts.TrustAttestationEKCert {
+		return opts.EKCert
+	}
+	return attestation.GetEkCert()
+}
+
+func verifyEKCertWithOpts(ekCert []byte, intermediates [][]byte, opts VerifyOpts) (*EKCertificate, error) {
+	roots := opts.EKRoots
+	if roots == nil {
+		var err error
+		if roots, err = loadBundledEKRoots(); err != nil {
+			return nil, fmt.Errorf("failed to load bundled EK roots: %w", err)
+		}
+	}
+	return verifyEKCertificate(ekCert, roots, intermediates)
+}
+
 func pubKeysEqual(k1 crypto.PublicKey, k2 crypto.PublicKey) bool {
 	switch key := k1.(type) {
 	case *rsa.PublicKey:
@@ -117,41 +441,9 @@ func pubKeysEqual(k1 crypto.PublicKey, k2 crypto.PublicKey) bool {
 
 // Checks if the provided AK public key can be trusted
 func checkAkTrusted(ak crypto.PublicKey, opts VerifyOpts) error {
-	if len(opts.TrustedAKs) == 0 {
-		return fmt.Errorf("no mechanism for AK verification provided")
-	}
-
-	// Check against known AKs
-	for _, trusted := range opts.TrustedAKs {
-		if pubKeysEqual(ak, trusted) {
-			return nil
-		}
-	}
-	return fmt.Errorf("AK public key is not trusted")
+	return internal.CheckKeyTrusted(ak, opts.TrustedAKs)
 }
 
 func checkHashAlgSupported(hash tpm2.Algorithm, opts VerifyOpts) error {
-	if hash == tpm2.AlgSHA1 && !opts.AllowSHA1 {
-		return fmt.Errorf("SHA-1 is not allowed for verification (set VerifyOpts.AllowSHA1 to true to allow)")
-	}
-	for _, alg := range supportedHashAlgs {
-		if hash == alg {
-			return nil
-		}
-	}
-	return fmt.Errorf("unsupported hash algorithm: %v", hash)
-}
-
-// Retrieve the supported quotes in order of hash preference
-func supportedQuotes(quotes []*tpmpb.Quote) []*tpmpb.Quote {
-	out := make([]*tpmpb.Quote, 0, len(quotes))
-	for _, alg := range supportedHashAlgs {
-		for _, quote := range quotes {
-			if tpm2.Algorithm(quote.GetPcrs().GetHash()) == alg {
-				out = append(out, quote)
-				break
-			}
-		}
-	}
-	return out
+	return internal.CheckHashAlgSupported(hash, opts.AllowSHA1)
 }
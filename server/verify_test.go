@@ -1,19 +1,26 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"testing"
 
+	"github.com/google/go-tpm-tools/cel"
 	"github.com/google/go-tpm-tools/client"
 	"github.com/google/go-tpm-tools/internal"
 	"github.com/google/go-tpm-tools/internal/test"
+	attestpb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
 	"github.com/google/go-tpm/tpm2"
 	"github.com/google/go-tpm/tpmutil"
+	"google.golang.org/protobuf/proto"
 )
 
 func getDigestHash(input string) []byte {
@@ -196,24 +203,32 @@ func TestVerifyBasicAttestation(t *testing.T) {
 		t.Fatalf("failed to attest: %v", err)
 	}
 
-	if _, err := VerifyAttestation(attestation, VerifyOpts{
+	state, err := VerifyAttestation(attestation, VerifyOpts{
 		Nonce:      nonce,
 		TrustedAKs: []crypto.PublicKey{ak.PublicKey()},
-	}); err != nil {
+	})
+	if err != nil {
 		t.Errorf("failed to verify: %v", err)
 	}
+	akName, err := ak.Name().Digest.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(state.GetAkName(), akName) {
+		t.Errorf("got AK name %x, want %x", state.GetAkName(), akName)
+	}
 
 	if _, err := VerifyAttestation(attestation, VerifyOpts{
 		Nonce:      append(nonce, 0),
 		TrustedAKs: []crypto.PublicKey{ak.PublicKey()},
-	}); err == nil {
-		t.Error("using the wrong nonce should make verification fail")
+	}); !errors.Is(err, ErrNonceMismatch) {
+		t.Errorf("using the wrong nonce should make verification fail with ErrNonceMismatch, got %v", err)
 	}
 
 	if _, err := VerifyAttestation(attestation, VerifyOpts{
 		Nonce: nonce,
-	}); err == nil {
-		t.Error("using no trusted AKs should make verification fail")
+	}); !errors.Is(err, ErrUntrustedAK) {
+		t.Errorf("using no trusted AKs should make verification fail with ErrUntrustedAK, got %v", err)
 	}
 
 	priv, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -223,8 +238,229 @@ func TestVerifyBasicAttestation(t *testing.T) {
 	if _, err := VerifyAttestation(attestation, VerifyOpts{
 		Nonce:      nonce,
 		TrustedAKs: []crypto.PublicKey{priv.Public()},
-	}); err == nil {
-		t.Error("using a random trusted AKs should make verification fail")
+	}); !errors.Is(err, ErrUntrustedAK) {
+		t.Errorf("using a random trusted AKs should make verification fail with ErrUntrustedAK, got %v", err)
+	}
+}
+
+func TestVerifyLogReplayError(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+
+	// PCR 0 no longer matches the event log once it is extended.
+	logged := map[tpmpb.HashAlgo][]byte{}
+	for _, hash := range []tpm2.Algorithm{tpm2.AlgSHA1, tpm2.AlgSHA256} {
+		if logged[tpmpb.HashAlgo(hash)], err = tpm2.ReadPCR(rwc, 0, hash); err != nil {
+			t.Fatal(err)
+		}
+		if err := extendPCRsRandomly(rwc, tpm2.PCRSelection{Hash: hash, PCRs: []int{0}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nonce := []byte("super secret nonce")
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+
+	_, err = VerifyAttestation(attestation, VerifyOpts{
+		Nonce:      nonce,
+		TrustedAKs: []crypto.PublicKey{ak.PublicKey()},
+		AllowSHA1:  true,
+	})
+	var replayErr *LogReplayError
+	if !errors.As(err, &replayErr) {
+		t.Fatalf("VerifyAttestation() = %v, want a LogReplayError", err)
+	}
+	var mismatch *PCRMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("VerifyAttestation() = %v, want a PCRMismatchError", err)
+	}
+	if len(replayErr.Mismatches) != 1 || mismatch.Index != 0 {
+		t.Fatalf("replay of the %v bank failed for %v, want only PCR 0", replayErr.Bank, replayErr.Mismatches)
+	}
+	if !bytes.Equal(mismatch.Got, logged[replayErr.Bank]) {
+		t.Errorf("PCR 0 replayed to %x, want %x", mismatch.Got, logged[replayErr.Bank])
+	}
+	for _, quote := range attestation.GetQuotes() {
+		if quote.GetPcrs().GetHash() == replayErr.Bank && !bytes.Equal(mismatch.Want, quote.GetPcrs().GetPcrs()[0]) {
+			t.Errorf("quoted PCR 0 is %x, want %x", mismatch.Want, quote.GetPcrs().GetPcrs()[0])
+		}
+	}
+}
+
+func TestVerifyAttestationContext(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+
+	nonce := []byte("super secret nonce")
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+	opts := VerifyOpts{Nonce: nonce, TrustedAKs: []crypto.PublicKey{ak.PublicKey()}}
+	if _, err := VerifyAttestationContext(context.Background(), attestation, opts); err != nil {
+		t.Errorf("failed to verify: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := VerifyAttestationContext(ctx, attestation, opts); !errors.Is(err, context.Canceled) {
+		t.Errorf("VerifyAttestationContext() with a cancelled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestVerifyCapabilities(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+
+	nonce := []byte("super secret nonce")
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+	caps, err := client.GetCapabilities(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := VerifyOpts{Nonce: nonce, TrustedAKs: []crypto.PublicKey{ak.PublicKey()}}
+	state, err := VerifyAttestation(attestation, opts)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if !proto.Equal(state.GetTpmCapabilities(), caps.Proto()) {
+		t.Errorf("got TPM capabilities %v, want %v", state.GetTpmCapabilities(), caps.Proto())
+	}
+
+	// Capabilities are optional.
+	changed := proto.Clone(attestation).(*attestpb.Attestation)
+	changed.Capabilities = nil
+	if state, err := VerifyAttestation(changed, opts); err != nil || state.GetTpmCapabilities() != nil {
+		t.Errorf("VerifyAttestation() without capabilities = %v, %v, want no capabilities", state.GetTpmCapabilities(), err)
+	}
+}
+
+func TestVerifyNonceHash(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+
+	// Longer than the TPM's largest digest, so it cannot be quoted directly.
+	longNonce := bytes.Repeat([]byte("long nonce"), 20)
+	if _, err := ak.Attest(client.AttestOpts{Nonce: longNonce}); err == nil {
+		t.Error("attesting with a long nonce should fail without NonceHash")
+	}
+	if _, err := ak.Attest(client.AttestOpts{Nonce: longNonce, NonceHash: crypto.SHA1}); err == nil {
+		t.Error("attesting with a SHA-1 NonceHash should fail")
+	}
+
+	subtests := []struct {
+		name       string
+		attestHash crypto.Hash
+		verifyHash crypto.Hash
+		wantErr    bool
+	}{
+		{"SHA256", crypto.SHA256, crypto.SHA256, false},
+		{"SHA384", crypto.SHA384, crypto.SHA384, false},
+		{"SHA512", crypto.SHA512, crypto.SHA512, false},
+		{"Mismatch", crypto.SHA256, crypto.SHA512, true},
+		{"UnexpectedHash", crypto.SHA256, 0, true},
+		{"MissingHash", 0, crypto.SHA256, true},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			nonce := longNonce
+			if subtest.attestHash == 0 {
+				nonce = []byte("short nonce")
+			}
+			attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce, NonceHash: subtest.attestHash})
+			if err != nil {
+				t.Fatalf("failed to attest: %v", err)
+			}
+			_, err = VerifyAttestation(attestation, VerifyOpts{
+				Nonce:      nonce,
+				NonceHash:  subtest.verifyHash,
+				TrustedAKs: []crypto.PublicKey{ak.PublicKey()},
+			})
+			if gotErr := err != nil; gotErr != subtest.wantErr {
+				t.Errorf("VerifyAttestation() = %v, want error: %v", err, subtest.wantErr)
+			}
+		})
+	}
+}
+
+// Attestations from simulated real platforms must verify, and give the same
+// machine state as their recorded event logs and PCRs.
+func TestVerifyPlatformAttestations(t *testing.T) {
+	for _, platform := range test.Platforms {
+		t.Run(platform.Name, func(t *testing.T) {
+			if internal.FIPSMode && len(platform.Banks) == 1 {
+				t.Skip("Legacy (SHA-1 only) event logs cannot be verified in FIPS mode")
+			}
+			rwc := test.GetPlatformTPM(t, platform)
+			defer client.CheckedClose(t, rwc)
+
+			ak, err := client.AttestationKeyECC(rwc)
+			if err != nil {
+				t.Fatalf("failed to generate AK: %v", err)
+			}
+			defer ak.Close()
+			nonce := []byte("platform nonce")
+			attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+			if err != nil {
+				t.Fatalf("failed to attest: %v", err)
+			}
+			state, err := VerifyAttestation(attestation, VerifyOpts{
+				Nonce:      nonce,
+				TrustedAKs: []crypto.PublicKey{ak.PublicKey()},
+				// Legacy event logs only have SHA-1 digests.
+				AllowSHA1: len(platform.Banks) == 1,
+			})
+			if err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+
+			// The kernel and initrd digests depend on the verified bank.
+			bank := platform.Banks[0]
+			for _, b := range platform.Banks {
+				if b.GetHash() == state.GetHash() {
+					bank = b
+				}
+			}
+			recorded, err := ParseMachineState(platform.RawLog, bank)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(state.GetPlatform(), recorded.GetPlatform()) {
+				t.Errorf("got platform state %v, recorded %v", state.GetPlatform(), recorded.GetPlatform())
+			}
+			if !proto.Equal(state.GetLinuxKernel(), recorded.GetLinuxKernel()) {
+				t.Errorf("got secure boot state %v, recorded %v", state.GetLinuxKernel(), recorded.GetLinuxKernel())
+			}
+		})
 	}
 }
 
@@ -266,6 +502,12 @@ func TestVerifySHA1Attestation(t *testing.T) {
 		}
 	}
 	state, err = VerifyAttestation(attestation, opts)
+	if internal.FIPSMode {
+		if err == nil {
+			t.Error("SHA-1 should never be allowed in FIPS mode")
+		}
+		return
+	}
 	if err != nil {
 		t.Errorf("failed to verify: %v", err)
 	}
@@ -280,3 +522,165 @@ func TestVerifySHA1Attestation(t *testing.T) {
 		t.Error("expected attestation to fail with only SHA-1")
 	}
 }
+
+func TestVerifyCanonicalEventLog(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	log := &cel.CEL{}
+	sshdDigest := sha256.Sum256([]byte("PermitRootLogin no\n"))
+	payloadDigest := sha256.Sum256([]byte("payload"))
+	for _, event := range []cel.Content{
+		cel.KernelSecurityEvent{Setting: cel.KernelLockdown, Value: "integrity"},
+		cel.ConfigFileEvent{Path: "/etc/ssh/sshd_config", Digest: sshdDigest[:]},
+		cel.KernelSecurityEvent{Setting: cel.KernelKexecLoadDisabled, Value: "1"},
+		cel.ExecEvent{Path: "/tmp/payload", Digest: payloadDigest[:]},
+		cel.KeyDerivationEvent{KeyName: []byte{0, 0xb, 1}, Label: "disk-encryption"},
+	} {
+		if err := log.AppendEvent(rwc, test.DebugPCR, []crypto.Hash{crypto.SHA1, crypto.SHA256}, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := log.EncodeCEL(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+
+	nonce := []byte("super secret nonce")
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce, CanonicalEventLog: buf.Bytes()})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+	opts := VerifyOpts{Nonce: nonce, TrustedAKs: []crypto.PublicKey{ak.PublicKey()}}
+	state, err := VerifyAttestation(attestation, opts)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	kernel := state.GetLinuxKernel()
+	if kernel.GetLockdown() != attestpb.LockdownMode_LOCKDOWN_INTEGRITY ||
+		kernel.GetModuleSignatures() != attestpb.Enforcement_ENFORCED ||
+		kernel.GetKexecLoadDisabled() != attestpb.Enforcement_ENFORCED {
+		t.Errorf("got kernel state %v, want lockdown with signed modules and kexec_load disabled", kernel)
+	}
+	files := state.GetConfigFiles()
+	if len(files) != 1 || files[0].GetPath() != "/etc/ssh/sshd_config" || !bytes.Equal(files[0].GetDigest(), sshdDigest[:]) {
+		t.Errorf("got config files %v, want the measured sshd_config", files)
+	}
+	executions := state.GetExecutions()
+	if len(executions) != 1 || executions[0].GetPath() != "/tmp/payload" || !bytes.Equal(executions[0].GetDigest(), payloadDigest[:]) {
+		t.Errorf("got executions %v, want the measured payload", executions)
+	}
+	derivations := state.GetKeyDerivations()
+	if len(derivations) != 1 || derivations[0].GetLabel() != "disk-encryption" || !bytes.Equal(derivations[0].GetKeyName(), []byte{0, 0xb, 1}) {
+		t.Errorf("got key derivations %v, want the recorded disk-encryption derivation", derivations)
+	}
+
+	// Forge the lockdown record's content, keeping the digests which replay.
+	forged, err := cel.KernelSecurityEvent{Setting: cel.KernelLockdown, Value: "confidentiality"}.GetTLV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	honest := log.Records[0].Content
+	log.Records[0].Content = forged
+	buf.Reset()
+	if err := log.EncodeCEL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	attestation.CanonicalEventLog = buf.Bytes()
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("verification should fail with a CEL record whose content does not match its digest")
+	}
+	log.Records[0].Content = honest
+
+	// Drop the last record, so the CEL no longer matches the PCRs.
+	log.Records = log.Records[:1]
+	buf.Reset()
+	if err := log.EncodeCEL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	attestation.CanonicalEventLog = buf.Bytes()
+	if _, err := VerifyAttestation(attestation, opts); err == nil {
+		t.Error("verification should fail with a truncated CEL")
+	}
+}
+
+func TestVerifyAdditionalQuotes(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+	imaKey, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate IMA key: %v", err)
+	}
+	defer imaKey.Close()
+
+	nonce := []byte("multi-quote nonce")
+	quote := func(key *client.Key, hash tpm2.Algorithm, pcrs []int, nonce []byte) *tpmpb.Quote {
+		t.Helper()
+		q, err := key.Quote(tpm2.PCRSelection{Hash: hash, PCRs: pcrs}, nonce)
+		if err != nil {
+			t.Fatalf("failed to quote: %v", err)
+		}
+		return q
+	}
+	additional := func(key *client.Key, quotes ...*tpmpb.Quote) *attestpb.AdditionalQuotes {
+		t.Helper()
+		pub, err := key.PublicArea().Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &attestpb.AdditionalQuotes{KeyPub: pub, Quotes: quotes}
+	}
+	bootPCRs := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	imaPCRs := []int{8, 9, 10, 11, 12, 13, 14, 15}
+
+	subtests := []struct {
+		name       string
+		additional []*attestpb.AdditionalQuotes
+		trusted    []crypto.PublicKey
+		wantErr    bool
+	}{
+		{"Disjoint", []*attestpb.AdditionalQuotes{additional(imaKey, quote(imaKey, tpm2.AlgSHA256, imaPCRs, nonce))}, nil, false},
+		{"Overlapping", []*attestpb.AdditionalQuotes{additional(imaKey, quote(imaKey, tpm2.AlgSHA256, append([]int{7}, imaPCRs...), nonce))}, nil, true},
+		{"WrongNonce", []*attestpb.AdditionalQuotes{additional(imaKey, quote(imaKey, tpm2.AlgSHA256, imaPCRs, []byte("other nonce")))}, nil, true},
+		{"OtherBank", []*attestpb.AdditionalQuotes{additional(imaKey, quote(imaKey, tpm2.AlgSHA1, imaPCRs, nonce))}, nil, true},
+		{"SameSigner", []*attestpb.AdditionalQuotes{additional(ak, quote(ak, tpm2.AlgSHA256, imaPCRs, nonce))}, nil, true},
+		{"UntrustedKey", []*attestpb.AdditionalQuotes{additional(imaKey, quote(imaKey, tpm2.AlgSHA256, imaPCRs, nonce))}, []crypto.PublicKey{ak.PublicKey()}, true},
+		{"InvalidKey", []*attestpb.AdditionalQuotes{{KeyPub: []byte("key"), Quotes: []*tpmpb.Quote{quote(imaKey, tpm2.AlgSHA256, imaPCRs, nonce)}}}, nil, true},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce})
+			if err != nil {
+				t.Fatalf("failed to attest: %v", err)
+			}
+			attestation.Quotes = []*tpmpb.Quote{quote(ak, tpm2.AlgSHA256, bootPCRs, nonce)}
+			attestation.AdditionalQuotes = subtest.additional
+			trusted := subtest.trusted
+			if trusted == nil {
+				trusted = []crypto.PublicKey{ak.PublicKey(), imaKey.PublicKey()}
+			}
+
+			state, err := VerifyAttestation(attestation, VerifyOpts{Nonce: nonce, TrustedAKs: trusted})
+			if gotErr := err != nil; gotErr != subtest.wantErr {
+				t.Fatalf("VerifyAttestation() = %v, want error: %v", err, subtest.wantErr)
+			}
+			// GRUB's measurements in PCRs 8 and 9 are only verified with the
+			// IMA key's quote.
+			if err == nil && state.GetGrub() == nil {
+				t.Error("the PCRs quoted by the additional key were not replayed")
+			}
+		})
+	}
+}
@@ -40,7 +40,9 @@ package internal
 // #cgo LDFLAGS: -lcrypto
 //
 // #include <stdlib.h>
+// #include <string.h>
 // #include "Platform.h"
+// #include "PlatformData.h"
 // #include "Tpm.h"
 //
 // void sync_seeds() {
@@ -48,9 +50,13 @@ package internal
 //     NV_SYNC_PERSISTENT(SPSeed);
 //     NV_SYNC_PERSISTENT(PPSeed);
 // }
+//
+// void read_nv(void *nv) { memcpy(nv, s_NV, NV_MEMORY_SIZE); }
+// void write_nv(const void *nv) { memcpy(s_NV, nv, NV_MEMORY_SIZE); }
 import "C"
 import (
 	"errors"
+	"fmt"
 	"io"
 	"unsafe"
 )
@@ -61,6 +67,8 @@ func SetSeeds(r io.Reader) {
 	r.Read(C.gp.EPSeed[2:])
 	r.Read(C.gp.SPSeed[2:])
 	r.Read(C.gp.PPSeed[2:])
+	// Write the seeds to NV, so they survive resets and are saved in the state.
+	C.sync_seeds()
 }
 
 // Reset simulates toggling the power the the TPM. If forceManufacture is true,
@@ -69,6 +77,24 @@ func Reset(forceManufacture bool) {
 	C._plat__Reset(C.bool(forceManufacture))
 }
 
+// NVMemory returns a copy of the simulator's NV memory, which holds all of the
+// TPM's persistent state.
+func NVMemory() []byte {
+	nv := make([]byte, C.NV_MEMORY_SIZE)
+	C.read_nv(unsafe.Pointer(&nv[0]))
+	return nv
+}
+
+// SetNVMemory replaces the simulator's NV memory. The TPM must be reset for it
+// to use the new contents.
+func SetNVMemory(nv []byte) error {
+	if len(nv) != C.NV_MEMORY_SIZE {
+		return fmt.Errorf("NV memory is %d bytes, want %d", len(nv), C.NV_MEMORY_SIZE)
+	}
+	C.write_nv(unsafe.Pointer(&nv[0]))
+	return nil
+}
+
 // RunCommand passes cmd to the simulator and returns the simulator's response.
 func RunCommand(cmd []byte) ([]byte, error) {
 	responseSize := C.uint32_t(C.MAX_RESPONSE_SIZE)
@@ -15,6 +15,14 @@ func SetSeeds(r io.Reader) {}
 // Reset does nothing
 func Reset(forceManufacture bool) {}
 
+// NVMemory returns nil
+func NVMemory() []byte { return nil }
+
+// SetNVMemory always returns an error, as we need CGO to use the simulator.
+func SetNVMemory(nv []byte) error {
+	return errors.New("using the simulator requires building with CGO")
+}
+
 // RunCommand always returns an error, as we need CGO to use the simulator.
 func RunCommand(cmd []byte) ([]byte, error) {
 	return nil, errors.New("using the simulator requires building with CGO")
@@ -19,13 +19,30 @@ package simulator
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/rand"
 	"sync"
 
 	"github.com/google/go-tpm-tools/simulator/internal"
 	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// NV indices holding EK certificates, from the TCG EK Credential Profile.
+const (
+	EKCertNVIndexRSA uint32 = 0x01c00002
+	EKCertNVIndexECC uint32 = 0x01c0000a
+)
+
+// stateMagic starts the state written by SaveState, followed by the version
+// of the format and the size of the simulator's NV memory.
+const (
+	stateMagic   = "go-tpm-tools simulator state\n"
+	stateVersion = 1
 )
 
 // Simulator represents a go-tpm compatible interface to the IBM TPM2 simulator.
@@ -61,6 +78,22 @@ func Get() (*Simulator, error) {
 	return simulator, nil
 }
 
+// GetWithState behaves like Get() except that the simulator's persistent state
+// is read from r, which must have been written by SaveState. This allows tests
+// to start from a previously provisioned TPM, with the same EK (and EK
+// certificate), persistent keys and NV indices.
+func GetWithState(r io.Reader) (*Simulator, error) {
+	s, err := Get()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.RestoreState(r); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
 // GetWithFixedSeedInsecure behaves like Get() expect that all of the internal
 // hierarchy seeds are derived from the input seed. Note that this function
 // compromises the security of the keys/seeds and should only be used for tests.
@@ -74,7 +107,8 @@ func GetWithFixedSeedInsecure(seed int64) (*Simulator, error) {
 	return s, nil
 }
 
-// Reset the TPM as if the host computer had rebooted.
+// Reset the TPM as if the host computer had rebooted (TPM2_Shutdown(CLEAR)
+// followed by TPM2_Startup(CLEAR)).
 func (s *Simulator) Reset() error {
 	if s.IsClosed() {
 		return ErrUsingClosedSimulator
@@ -86,6 +120,148 @@ func (s *Simulator) Reset() error {
 	return s.on(false)
 }
 
+// Restart the TPM as if the host computer had rebooted after hibernating
+// (TPM2_Shutdown(STATE) followed by TPM2_Startup(CLEAR)). Unlike Reset, the
+// TPM's restart count is incremented instead of its reset count.
+func (s *Simulator) Restart() error {
+	return s.cycle(tpm2.StartupClear)
+}
+
+// Resume the TPM as if the host computer had resumed from suspend
+// (TPM2_Shutdown(STATE) followed by TPM2_Startup(STATE)), preserving the
+// PCRs which are not reset on resume, and any saved session contexts.
+func (s *Simulator) Resume() error {
+	return s.cycle(tpm2.StartupState)
+}
+
+func (s *Simulator) cycle(startup tpm2.StartupType) error {
+	if s.IsClosed() {
+		return ErrUsingClosedSimulator
+	}
+	if err := tpm2.Shutdown(s, tpm2.StartupState); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	internal.Reset(false)
+	if err := tpm2.Startup(s, startup); err != nil {
+		return fmt.Errorf("startup: %w", err)
+	}
+	return nil
+}
+
+// SaveState writes the TPM's persistent state (its seeds, persistent objects,
+// NV indices and counters) to w, for example to a file, so that it can be
+// restored by RestoreState or GetWithState.
+//
+// To save a consistent state, the TPM is shut down. It is then started up
+// again as if the host computer had rebooted, just like Reset.
+func (s *Simulator) SaveState(w io.Writer) error {
+	if s.IsClosed() {
+		return ErrUsingClosedSimulator
+	}
+	if err := s.off(); err != nil {
+		return err
+	}
+	nv := internal.NVMemory()
+	internal.Reset(false)
+	if err := s.on(false); err != nil {
+		return err
+	}
+
+	header := make([]byte, len(stateMagic)+8)
+	copy(header, stateMagic)
+	binary.BigEndian.PutUint32(header[len(stateMagic):], stateVersion)
+	binary.BigEndian.PutUint32(header[len(stateMagic)+4:], uint32(len(nv)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(nv)
+	return err
+}
+
+// RestoreState replaces the TPM's persistent state with the state read from r,
+// which must have been written by SaveState. The TPM is then started up as if
+// the host computer had rebooted, so PCRs are reset and no objects or sessions
+// are loaded.
+func (s *Simulator) RestoreState(r io.Reader) error {
+	if s.IsClosed() {
+		return ErrUsingClosedSimulator
+	}
+	nv, err := readState(r)
+	if err != nil {
+		return err
+	}
+	if err := s.off(); err != nil {
+		return err
+	}
+	if err := internal.SetNVMemory(nv); err != nil {
+		return err
+	}
+	internal.Reset(false)
+	return s.on(false)
+}
+
+func readState(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(stateMagic)) || len(data) < len(stateMagic)+8 {
+		return nil, errors.New("not a simulator state")
+	}
+	data = data[len(stateMagic):]
+	if version := binary.BigEndian.Uint32(data); version != stateVersion {
+		return nil, fmt.Errorf("unsupported simulator state version %d", version)
+	}
+	if size := binary.BigEndian.Uint32(data[4:]); int(size) != len(data)-8 {
+		return nil, fmt.Errorf("simulator state has %d bytes of NV memory, want %d", len(data)-8, size)
+	}
+	return data[8:], nil
+}
+
+// SetEKCertificate stores a DER encoded EK certificate in an NV index (either
+// EKCertNVIndexRSA or EKCertNVIndexECC), in the same way as a TPM
+// manufacturer: the index is defined and written by the platform hierarchy,
+// and can be read by the owner. Any existing certificate is replaced.
+//
+// Certificates for the simulator's EKs can be issued by a test CA, so that
+// tests can verify the EK certificate chain. Save the simulator's state
+// afterwards (see SaveState) to reuse the EK and its certificate.
+func (s *Simulator) SetEKCertificate(index uint32, cert []byte) error {
+	if s.IsClosed() {
+		return ErrUsingClosedSimulator
+	}
+	handle := tpmutil.Handle(index)
+	platformAuth := tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}
+	if _, err := tpm2.NVReadPublic(s, handle); err == nil {
+		if err := tpm2.NVUndefineSpaceEx(s, tpm2.HandlePlatform, handle, platformAuth); err != nil {
+			return fmt.Errorf("removing old certificate: %w", err)
+		}
+	}
+	attrs := tpm2.AttrPPWrite | tpm2.AttrWriteDefine | tpm2.AttrPPRead | tpm2.AttrOwnerRead |
+		tpm2.AttrAuthRead | tpm2.AttrNoDA | tpm2.AttrPlatformCreate
+	if err := tpm2.NVDefineSpaceEx(s, tpm2.HandlePlatform, "", tpm2.NVPublic{
+		NVIndex:    handle,
+		NameAlg:    tpm2.AlgSHA256,
+		Attributes: attrs,
+		DataSize:   uint16(len(cert)),
+	}, platformAuth); err != nil {
+		return fmt.Errorf("defining certificate index: %w", err)
+	}
+	for offset := 0; offset < len(cert); offset += maxNVWrite {
+		end := offset + maxNVWrite
+		if end > len(cert) {
+			end = len(cert)
+		}
+		if err := tpm2.NVWriteEx(s, tpm2.HandlePlatform, handle, platformAuth, cert[offset:end], uint16(offset)); err != nil {
+			return fmt.Errorf("writing certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+// maxNVWrite is no larger than the simulator's MAX_NV_BUFFER_SIZE.
+const maxNVWrite = 512
+
 // ManufactureReset behaves like Reset() except that the TPM is complete wiped.
 // All data (NVData, Hierarchy seeds, etc...) is cleared or reset.
 func (s *Simulator) ManufactureReset() error {
@@ -17,13 +17,24 @@
 package simulator
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"io"
+	"io/ioutil"
 	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/go-tpm-tools/client"
 	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
 )
 
 func getSimulator(t *testing.T) *Simulator {
@@ -117,3 +128,169 @@ func TestDifferentSeedDifferentModulus(t *testing.T) {
 		t.Fatalf("Moduli should not be equal when using different seeds")
 	}
 }
+
+func TestSaveRestoreState(t *testing.T) {
+	s, err := GetWithFixedSeedInsecure(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nvIndex := tpmutil.Handle(0x01500000)
+	if err := tpm2.NVDefineSpace(s, tpm2.HandleOwner, nvIndex, "", "", nil,
+		tpm2.AttrOwnerWrite|tpm2.AttrOwnerRead, 8); err != nil {
+		t.Fatal(err)
+	}
+	if err := tpm2.NVWrite(s, tpm2.HandleOwner, nvIndex, "", []byte("provisn!"), 0); err != nil {
+		t.Fatal(err)
+	}
+	var state bytes.Buffer
+	if err := s.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	// The simulator keeps running after saving its state.
+	if modulus := getEKModulus(t, s); modulus.Cmp(zeroSeedModulus()) != 0 {
+		t.Errorf("SaveState() changed the EK")
+	}
+	client.CheckedClose(t, s)
+
+	path := filepath.Join(t.TempDir(), "state")
+	if err := ioutil.WriteFile(path, state.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	restored, err := GetWithState(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.CheckedClose(t, restored)
+	if modulus := getEKModulus(t, restored); modulus.Cmp(zeroSeedModulus()) != 0 {
+		t.Errorf("restored simulator has a different EK")
+	}
+	data, err := tpm2.NVReadEx(restored, nvIndex, tpm2.HandleOwner, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "provisn!" {
+		t.Errorf("restored NV index contains %q", data)
+	}
+
+	if err := restored.ManufactureReset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.RestoreState(bytes.NewReader(state.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if modulus := getEKModulus(t, restored); modulus.Cmp(zeroSeedModulus()) != 0 {
+		t.Errorf("RestoreState() did not restore the EK")
+	}
+}
+
+func TestRestoreInvalidState(t *testing.T) {
+	s := getSimulator(t)
+	defer client.CheckedClose(t, s)
+	var state bytes.Buffer
+	if err := s.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	valid := state.Bytes()
+	badVersion := append([]byte(nil), valid...)
+	badVersion[len(stateMagic)+3]++
+
+	for _, data := range [][]byte{nil, []byte("not a state"), valid[:len(valid)-1], badVersion} {
+		if err := s.RestoreState(bytes.NewReader(data)); err == nil {
+			t.Errorf("RestoreState() should fail for a %d byte state", len(data))
+		}
+	}
+	// The simulator is still usable.
+	if _, err := tpm2.GetRandom(s, 10); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRestartAndResume(t *testing.T) {
+	s := getSimulator(t)
+	defer client.CheckedClose(t, s)
+	extended := func() bool {
+		t.Helper()
+		pcr, err := tpm2.ReadPCR(s, 0, tpm2.AlgSHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return !bytes.Equal(pcr, make([]byte, len(pcr)))
+	}
+	if err := tpm2.PCRExtend(s, 0, tpm2.AlgSHA256, make([]byte, 32), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if !extended() {
+		t.Error("Resume() should preserve PCR0")
+	}
+	if err := s.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if extended() {
+		t.Error("Restart() should reset PCR0")
+	}
+}
+
+func TestSetEKCertificate(t *testing.T) {
+	s := getSimulator(t)
+	defer client.CheckedClose(t, s)
+	ek, err := client.EndorsementKeyRSA(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ek.Close()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test EK CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	ca, err := x509.CreateCertificate(rand.Reader, template, template, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write a certificate larger than a single NV write, twice.
+	for serial := int64(2); serial <= 3; serial++ {
+		ekTemplate := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageKeyEncipherment,
+		}
+		cert, err := x509.CreateCertificate(rand.Reader, ekTemplate, caCert, ek.PublicKey(), caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetEKCertificate(EKCertNVIndexRSA, cert); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := tpm2.NVReadEx(s, tpmutil.Handle(EKCertNVIndexRSA), tpm2.HandleOwner, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(stored, cert) {
+			t.Fatalf("NV index contains %x, want certificate %x", stored, cert)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package sshagent
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/google/go-tpm-tools/client"
+)
+
+// ErrReadOnly is returned when adding or removing keys from an Agent, whose
+// keys are fixed when it is created.
+var ErrReadOnly = errors.New("TPM agent keys cannot be added or removed")
+
+// errLocked is returned by a locked Agent, as by agent.NewKeyring.
+var errLocked = errors.New("agent: locked")
+
+// Identity is a TPM key served by an Agent, with the comment listed with it.
+type Identity struct {
+	Key     *client.Key
+	Comment string
+}
+
+type agentKey struct {
+	signer  ssh.AlgorithmSigner
+	comment string
+}
+
+// Agent is an ssh-agent backed by TPM keys. Clients can list the keys and sign
+// with them, and lock and unlock the agent with a passphrase, but cannot add or
+// remove keys. An Agent is safe for concurrent use by several connections, but
+// it is not safe to access the TPM from other sources while it is signing.
+type Agent struct {
+	mu         sync.Mutex
+	keys       []agentKey
+	passphrase []byte
+	locked     bool
+}
+
+var _ agent.ExtendedAgent = (*Agent)(nil)
+
+// NewAgent returns an Agent serving the given keys (see NewSigner), which must
+// stay loaded while it is in use.
+func NewAgent(ids ...Identity) (*Agent, error) {
+	a := &Agent{}
+	for _, id := range ids {
+		s, err := NewSigner(id.Key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id.Comment, err)
+		}
+		a.keys = append(a.keys, agentKey{s, id.Comment})
+	}
+	return a, nil
+}
+
+// Serve accepts connections on the listener, usually a Unix socket named by
+// SSH_AUTH_SOCK, and serves the ssh-agent protocol on each of them until the
+// listener is closed.
+func (a *Agent) Serve(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			agent.ServeAgent(a, conn)
+		}()
+	}
+}
+
+// List returns the public keys of the TPM keys, or none if the agent is locked.
+func (a *Agent) List() ([]*agent.Key, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.locked {
+		return nil, nil
+	}
+	keys := make([]*agent.Key, 0, len(a.keys))
+	for _, k := range a.keys {
+		pub := k.signer.PublicKey()
+		keys = append(keys, &agent.Key{Format: pub.Type(), Blob: pub.Marshal(), Comment: k.comment})
+	}
+	return keys, nil
+}
+
+// Sign signs data with the TPM key whose public key is given, with the key's
+// signature algorithm.
+func (a *Agent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return a.SignWithFlags(key, data, 0)
+}
+
+// SignWithFlags signs like Sign, but the flags select the signature algorithm
+// of RSA keys: ssh-rsa by default, or rsa-sha2-256 or rsa-sha2-512. It fails
+// if it is not the key's algorithm.
+func (a *Agent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.locked {
+		return nil, errLocked
+	}
+	wanted := key.Marshal()
+	for _, k := range a.keys {
+		pub := k.signer.PublicKey()
+		if !bytes.Equal(pub.Marshal(), wanted) {
+			continue
+		}
+		algorithm := pub.Type()
+		if algorithm == ssh.KeyAlgoRSA {
+			switch {
+			case flags&agent.SignatureFlagRsaSha256 != 0:
+				algorithm = ssh.SigAlgoRSASHA2256
+			case flags&agent.SignatureFlagRsaSha512 != 0:
+				algorithm = ssh.SigAlgoRSASHA2512
+			}
+		}
+		return k.signer.SignWithAlgorithm(rand.Reader, data, algorithm)
+	}
+	return nil, errors.New("agent: key not found")
+}
+
+// Signers returns signers for the TPM keys, or none if the agent is locked.
+func (a *Agent) Signers() ([]ssh.Signer, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.locked {
+		return nil, errLocked
+	}
+	signers := make([]ssh.Signer, 0, len(a.keys))
+	for _, k := range a.keys {
+		signers = append(signers, k.signer)
+	}
+	return signers, nil
+}
+
+// Add returns ErrReadOnly.
+func (a *Agent) Add(agent.AddedKey) error {
+	return ErrReadOnly
+}
+
+// Remove returns ErrReadOnly.
+func (a *Agent) Remove(ssh.PublicKey) error {
+	return ErrReadOnly
+}
+
+// RemoveAll returns ErrReadOnly.
+func (a *Agent) RemoveAll() error {
+	return ErrReadOnly
+}
+
+// Lock locks the agent with a passphrase. Until it is unlocked, it lists no
+// keys and cannot sign.
+func (a *Agent) Lock(passphrase []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.locked {
+		return errLocked
+	}
+	a.locked = true
+	a.passphrase = passphrase
+	return nil
+}
+
+// Unlock unlocks an agent locked with the same passphrase.
+func (a *Agent) Unlock(passphrase []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.locked {
+		return errors.New("agent: not locked")
+	}
+	if subtle.ConstantTimeCompare(passphrase, a.passphrase) != 1 {
+		return errors.New("agent: incorrect passphrase")
+	}
+	a.locked = false
+	a.passphrase = nil
+	return nil
+}
+
+// Extension returns agent.ErrExtensionUnsupported, as the agent supports no
+// extensions.
+func (a *Agent) Extension(string, []byte) ([]byte, error) {
+	return nil, agent.ErrExtensionUnsupported
+}
@@ -0,0 +1,194 @@
+// Package sshagent uses TPM keys for SSH authentication, so a user's SSH
+// private key never leaves the TPM.
+//
+// AuthorizedKey writes a key's public key in the OpenSSH authorized_keys
+// format, and NewSigner returns an ssh.Signer for use with ssh.PublicKeys. An
+// Agent serves TPM keys over the ssh-agent protocol, for OpenSSH and other
+// clients using SSH_AUTH_SOCK:
+//
+//	keys, err := sshagent.ResidentKeys(rw)
+//	...
+//	a, err := sshagent.NewAgent(keys...)
+//	...
+//	lis, err := net.Listen("unix", socket)
+//	...
+//	err = a.Serve(lis)
+//
+// The TPM signs with the hash algorithm of a key's signing scheme, so an RSA
+// key only makes one type of SSH signature: ssh-rsa (SHA-1), rsa-sha2-256 or
+// rsa-sha2-512. ECDSA keys must use the hash algorithm SSH requires for their
+// curve, such as SHA-256 for NIST P-256 (as in client.DevIDTemplateECC).
+package sshagent
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+)
+
+// PublicKey returns the SSH public key of a TPM key.
+func PublicKey(k *client.Key) (ssh.PublicKey, error) {
+	pub, err := ssh.NewPublicKey(k.PublicKey())
+	if err != nil {
+		return nil, fmt.Errorf("key cannot be used with SSH: %w", err)
+	}
+	return pub, nil
+}
+
+// AuthorizedKey returns a line of an OpenSSH authorized_keys file (without a
+// trailing newline) for the public key of a TPM key, with an optional comment.
+func AuthorizedKey(k *client.Key, comment string) ([]byte, error) {
+	pub, err := PublicKey(k)
+	if err != nil {
+		return nil, err
+	}
+	line := ssh.MarshalAuthorizedKey(pub)
+	line = line[:len(line)-1]
+	if comment != "" {
+		line = append(line, ' ')
+		line = append(line, comment...)
+	}
+	return line, nil
+}
+
+// sshHashes are the hash algorithms of SSH's ECDSA signatures.
+var sshHashes = map[elliptic.Curve]crypto.Hash{
+	elliptic.P256(): crypto.SHA256,
+	elliptic.P384(): crypto.SHA384,
+	elliptic.P521(): crypto.SHA512,
+}
+
+// rsaAlgorithms are SSH's RSA signature algorithms.
+var rsaAlgorithms = map[crypto.Hash]string{
+	crypto.SHA1:   ssh.SigAlgoRSA,
+	crypto.SHA256: ssh.SigAlgoRSASHA2256,
+	crypto.SHA512: ssh.SigAlgoRSASHA2512,
+}
+
+type signer struct {
+	pub       ssh.PublicKey
+	signer    crypto.Signer
+	hash      crypto.Hash
+	algorithm string
+}
+
+// NewSigner returns an SSH signer for an unrestricted TPM signing key, an RSA
+// key with the RSASSA scheme or an ECDSA key. The signer lasts the lifetime of
+// the key. Its only signature algorithm is the key's (see the package
+// documentation), which Sign uses and SignWithAlgorithm requires.
+func NewSigner(k *client.Key) (ssh.AlgorithmSigner, error) {
+	pub, err := PublicKey(k)
+	if err != nil {
+		return nil, err
+	}
+	cryptoSigner, err := k.GetSigner()
+	if err != nil {
+		return nil, fmt.Errorf("key cannot be used with SSH: %w", err)
+	}
+	hashAlg, err := internal.GetSigningHashAlg(k.PublicArea())
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hashAlg.Hash()
+	if err != nil {
+		return nil, err
+	}
+	s := &signer{pub: pub, signer: cryptoSigner, hash: hash}
+	switch key := k.PublicKey().(type) {
+	case *ecdsa.PublicKey:
+		if want := sshHashes[key.Curve]; hash != want {
+			return nil, fmt.Errorf("SSH signatures with %s keys use %v, but the key's scheme uses %v", pub.Type(), want, hash)
+		}
+		s.algorithm = pub.Type()
+	default:
+		if k.PublicArea().RSAParameters.Sign.Alg != tpm2.AlgRSASSA {
+			return nil, errors.New("SSH signatures with RSA keys use RSASSA, not the key's scheme")
+		}
+		var ok bool
+		if s.algorithm, ok = rsaAlgorithms[hash]; !ok {
+			return nil, fmt.Errorf("SSH has no RSA signatures with %v", hash)
+		}
+	}
+	return s, nil
+}
+
+func (s *signer) PublicKey() ssh.PublicKey {
+	return s.pub
+}
+
+func (s *signer) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	return s.SignWithAlgorithm(rand, data, s.algorithm)
+}
+
+func (s *signer) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	if algorithm != s.algorithm {
+		return nil, fmt.Errorf("key makes %s signatures, not %s", s.algorithm, algorithm)
+	}
+	h := s.hash.New()
+	h.Write(data)
+	sig, err := s.signer.Sign(rand, h.Sum(nil), s.hash)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.signer.Public().(*ecdsa.PublicKey); ok {
+		// SSH encodes ECDSA signatures as two mpints, not in ASN.1.
+		var ecdsaSig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &ecdsaSig); err != nil {
+			return nil, fmt.Errorf("failed to decode ECDSA signature: %w", err)
+		}
+		sig = ssh.Marshal(ecdsaSig)
+	}
+	return &ssh.Signature{Format: algorithm, Blob: sig}, nil
+}
+
+// lastOwnerPersistentHandle is the end of the owner hierarchy's persistent
+// handles. Keys after it are in the endorsement and platform hierarchies.
+const lastOwnerPersistentHandle = tpmutil.Handle(0x817FFFFF)
+
+// ResidentKeys loads the keys at the TPM's owner hierarchy persistent handles
+// which can be used with SSH (see NewSigner), commented with their handles,
+// such as "tpm:0x81008f80". Only keys which can be used with their (empty)
+// password are included. The caller closes the keys.
+func ResidentKeys(rw io.ReadWriter) ([]Identity, error) {
+	objects, err := client.PersistentObjects(rw)
+	if err != nil {
+		return nil, err
+	}
+	var ids []Identity
+	for _, object := range objects {
+		attrs := object.Public.Attributes
+		if object.Handle > lastOwnerPersistentHandle || attrs&tpm2.FlagSign == 0 ||
+			attrs&tpm2.FlagRestricted != 0 || attrs&tpm2.FlagUserWithAuth == 0 {
+			continue
+		}
+		k, err := client.LoadPersistentKey(rw, object.Handle)
+		if err != nil {
+			closeIdentities(ids)
+			return nil, err
+		}
+		if _, err := NewSigner(k); err != nil {
+			k.Close()
+			continue
+		}
+		ids = append(ids, Identity{Key: k, Comment: fmt.Sprintf("tpm:0x%x", object.Handle)})
+	}
+	return ids, nil
+}
+
+func closeIdentities(ids []Identity) {
+	for _, id := range ids {
+		id.Key.Close()
+	}
+}
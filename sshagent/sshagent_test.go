@@ -0,0 +1,278 @@
+package sshagent_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm-tools/sshagent"
+)
+
+func TestAuthorizedKey(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	key, err := client.LDevIDKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+
+	line, err := sshagent.AuthorizedKey(key, "tpm-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(line), "ecdsa-sha2-nistp256 ") {
+		t.Errorf("AuthorizedKey() = %q, want an ecdsa-sha2-nistp256 key", line)
+	}
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey(line)
+	if err != nil {
+		t.Fatalf("ParseAuthorizedKey() failed: %v", err)
+	}
+	if comment != "tpm-key" {
+		t.Errorf("got comment %q, want %q", comment, "tpm-key")
+	}
+	want, err := sshagent.PublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pub.Marshal(), want.Marshal()) {
+		t.Error("authorized key does not match the key's public key")
+	}
+}
+
+func TestSigner(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	for _, tc := range []struct {
+		name      string
+		newKey    func(io.ReadWriter) (*client.Key, error)
+		algorithm string
+	}{
+		{"RSA", client.LDevIDKeyRSA, ssh.SigAlgoRSASHA2256},
+		{"ECC", client.LDevIDKeyECC, ssh.KeyAlgoECDSA256},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := tc.newKey(rwc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer key.Close()
+			signer, err := sshagent.NewSigner(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := []byte("session data")
+			sig, err := signer.Sign(nil, data)
+			if err != nil {
+				t.Fatalf("Sign() failed: %v", err)
+			}
+			if sig.Format != tc.algorithm {
+				t.Errorf("got %s signature, want %s", sig.Format, tc.algorithm)
+			}
+			if err := signer.PublicKey().Verify(data, sig); err != nil {
+				t.Errorf("signature does not verify: %v", err)
+			}
+			if _, err := signer.SignWithAlgorithm(nil, data, ssh.SigAlgoRSASHA2512); err == nil {
+				t.Error("SignWithAlgorithm() with another algorithm should fail")
+			}
+		})
+	}
+}
+
+func TestSignerRejectsUnsupportedKeys(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	// AKs are restricted, so they cannot sign SSH sessions.
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	if _, err := sshagent.NewSigner(ak); err == nil {
+		t.Error("NewSigner() with a restricted key should fail")
+	}
+
+	// SSH signatures with P-256 keys use SHA-256.
+	template := client.DevIDTemplateECC()
+	template.ECCParameters.Sign.Hash = tpm2.AlgSHA384
+	key, err := client.NewKey(rwc, tpm2.HandleOwner, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+	if _, err := sshagent.NewSigner(key); err == nil {
+		t.Error("NewSigner() with a P-256 SHA-384 key should fail")
+	}
+}
+
+// agentClient serves the agent on one end of a pipe, and returns a client for
+// the other end.
+func agentClient(t *testing.T, a *sshagent.Agent) agent.ExtendedAgent {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close() })
+	go agent.ServeAgent(a, serverConn)
+	return agent.NewClient(clientConn)
+}
+
+func TestAgent(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	rsaKey, err := client.LDevIDKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsaKey.Close()
+	eccKey, err := client.LDevIDKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eccKey.Close()
+
+	a, err := sshagent.NewAgent(sshagent.Identity{Key: rsaKey, Comment: "rsa"}, sshagent.Identity{Key: eccKey, Comment: "ecc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := agentClient(t, a)
+	keys, err := c.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0].Comment != "rsa" || keys[1].Comment != "ecc" {
+		t.Fatalf("List() = %v, want the rsa and ecc keys", keys)
+	}
+
+	data := []byte("session data")
+	sig, err := c.SignWithFlags(keys[0], data, agent.SignatureFlagRsaSha256)
+	if err != nil {
+		t.Fatalf("SignWithFlags() with rsa-sha2-256 failed: %v", err)
+	}
+	if err := keys[0].Verify(data, sig); err != nil {
+		t.Errorf("RSA signature does not verify: %v", err)
+	}
+	if _, err := c.Sign(keys[0], data); err == nil {
+		t.Error("Sign() with ssh-rsa should fail for a SHA-256 key")
+	}
+	sig, err = c.Sign(keys[1], data)
+	if err != nil {
+		t.Fatalf("Sign() with the ECDSA key failed: %v", err)
+	}
+	if err := keys[1].Verify(data, sig); err != nil {
+		t.Errorf("ECDSA signature does not verify: %v", err)
+	}
+
+	if err := c.RemoveAll(); err == nil {
+		t.Error("RemoveAll() should fail")
+	}
+
+	if err := c.Lock([]byte("passphrase")); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := c.List(); err != nil || len(keys) != 0 {
+		t.Errorf("List() while locked = %v, %v, want no keys", keys, err)
+	}
+	if _, err := c.Sign(keys[1], data); err == nil {
+		t.Error("Sign() while locked should fail")
+	}
+	if err := c.Unlock([]byte("wrong")); err == nil {
+		t.Error("Unlock() with the wrong passphrase should fail")
+	}
+	if err := c.Unlock([]byte("passphrase")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Sign(keys[1], data); err != nil {
+		t.Errorf("Sign() after unlocking failed: %v", err)
+	}
+}
+
+func TestAgentHandshake(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+	key, err := client.LDevIDKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+	a, err := sshagent.NewAgent(sshagent.Identity{Key: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := sshagent.PublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostKey, err := sshagent.NewSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, pub ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(pub.Marshal(), authorized.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(hostKey)
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		defer serverConn.Close()
+		ssh.NewServerConn(serverConn, serverConfig)
+	}()
+
+	clientConfig := &ssh.ClientConfig{
+		User:            "user",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeysCallback(agentClient(t, a).Signers)},
+		HostKeyCallback: ssh.FixedHostKey(authorized),
+	}
+	conn, _, _, err := ssh.NewClientConn(clientConn, "tpm", clientConfig)
+	if err != nil {
+		t.Fatalf("SSH handshake with a TPM key from the agent failed: %v", err)
+	}
+	conn.Close()
+}
+
+func TestResidentKeys(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	key, err := client.LDevIDKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Persist(client.FirstPersistentKeyHandle); err != nil {
+		t.Fatal(err)
+	}
+	defer key.Evict()
+	// The SRK is persisted, but is not a signing key.
+	srk, err := client.StorageRootKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srk.Close()
+
+	ids, err := sshagent.ResidentKeys(rwc)
+	if err != nil {
+		t.Fatalf("ResidentKeys() failed: %v", err)
+	}
+	if len(ids) != 1 {
+		t.Fatalf("ResidentKeys() returned %d keys, want 1", len(ids))
+	}
+	defer ids[0].Key.Close()
+	if ids[0].Key.Handle() != client.FirstPersistentKeyHandle || ids[0].Comment != "tpm:0x81008f80" {
+		t.Errorf("got key 0x%x (%q), want the persisted key", ids[0].Key.Handle(), ids[0].Comment)
+	}
+}
@@ -0,0 +1,160 @@
+// Package testutil provides fakes of the attestation and verification
+// interfaces in this module, with canned results and injectable failures, so
+// that applications can unit test their logic without a TPM, the simulator, or
+// cgo.
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+	"github.com/google/go-tpm-tools/server"
+)
+
+var (
+	// ErrInjected is a generic failure for injecting into the fakes.
+	ErrInjected = errors.New("injected failure")
+	// ErrWrongNonce is returned when a nonce does not match the expected one.
+	ErrWrongNonce = errors.New("attestation used the wrong nonce")
+)
+
+var (
+	_ client.Attester           = (*Attester)(nil)
+	_ server.Verifier           = (*Verifier)(nil)
+	_ verifierpb.VerifierClient = (*VerifierClient)(nil)
+)
+
+// Attester is a fake client.Attester. It returns a copy of Attestation (or an
+// empty Attestation if it is nil), unless Err is set. The fields must not be
+// changed while Attest is being called.
+type Attester struct {
+	Attestation *pb.Attestation
+	Err         error
+
+	mu    sync.Mutex
+	calls []client.AttestOpts
+}
+
+// Attest records opts, and returns the canned Attestation or error.
+func (a *Attester) Attest(opts client.AttestOpts) (*pb.Attestation, error) {
+	a.mu.Lock()
+	a.calls = append(a.calls, opts)
+	a.mu.Unlock()
+	if a.Err != nil {
+		return nil, a.Err
+	}
+	if a.Attestation == nil {
+		return &pb.Attestation{}, nil
+	}
+	return proto.Clone(a.Attestation).(*pb.Attestation), nil
+}
+
+// Calls returns the options of every call to Attest, in order.
+func (a *Attester) Calls() []client.AttestOpts {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]client.AttestOpts(nil), a.calls...)
+}
+
+// Verifier is a fake server.Verifier. It returns a copy of MachineState (or an
+// empty MachineState if it is nil), unless Err is set. If Nonce is set, it
+// also fails unless it matches the VerifyOpts' nonce, like a real Verifier
+// would when an attestation is replayed. The fields must not be changed while
+// VerifyAttestation is being called.
+type Verifier struct {
+	MachineState *pb.MachineState
+	Nonce        []byte
+	Err          error
+
+	mu    sync.Mutex
+	calls []*pb.Attestation
+}
+
+// VerifyAttestation records the attestation, and returns the canned
+// MachineState or error.
+func (v *Verifier) VerifyAttestation(attestation *pb.Attestation, opts server.VerifyOpts) (*pb.MachineState, error) {
+	v.mu.Lock()
+	v.calls = append(v.calls, attestation)
+	v.mu.Unlock()
+	if v.Err != nil {
+		return nil, v.Err
+	}
+	if v.Nonce != nil && !bytes.Equal(v.Nonce, opts.Nonce) {
+		return nil, ErrWrongNonce
+	}
+	if v.MachineState == nil {
+		return &pb.MachineState{}, nil
+	}
+	return proto.Clone(v.MachineState).(*pb.MachineState), nil
+}
+
+// Calls returns the attestation of every call to VerifyAttestation, in order.
+func (v *Verifier) Calls() []*pb.Attestation {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]*pb.Attestation(nil), v.calls...)
+}
+
+// VerifierClient is a fake verifierpb.VerifierClient, for testing code which
+// attests to a remote verifier (such as client.AttestToVerifier) without a
+// gRPC connection. GetNonce returns Nonce unless NonceErr is set.
+// VerifyAttestation returns a copy of Response (or an empty response if it is
+// nil) unless VerifyErr is set, and fails with ErrWrongNonce if the request's
+// nonce is not Nonce. The fields must not be changed while the methods are
+// being called.
+type VerifierClient struct {
+	Nonce     []byte
+	NonceErr  error
+	Response  *verifierpb.VerifyAttestationResponse
+	VerifyErr error
+
+	mu       sync.Mutex
+	requests []*verifierpb.VerifyAttestationRequest
+}
+
+// GetNonce returns the canned nonce or error.
+func (c *VerifierClient) GetNonce(ctx context.Context, in *verifierpb.GetNonceRequest, opts ...grpc.CallOption) (*verifierpb.GetNonceResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.NonceErr != nil {
+		return nil, c.NonceErr
+	}
+	return &verifierpb.GetNonceResponse{Nonce: c.Nonce}, nil
+}
+
+// VerifyAttestation records the request, and returns the canned response or
+// error.
+func (c *VerifierClient) VerifyAttestation(ctx context.Context, in *verifierpb.VerifyAttestationRequest, opts ...grpc.CallOption) (*verifierpb.VerifyAttestationResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, in)
+	c.mu.Unlock()
+	if c.VerifyErr != nil {
+		return nil, c.VerifyErr
+	}
+	if !bytes.Equal(in.GetNonce(), c.Nonce) {
+		return nil, ErrWrongNonce
+	}
+	if c.Response == nil {
+		return &verifierpb.VerifyAttestationResponse{}, nil
+	}
+	return proto.Clone(c.Response).(*verifierpb.VerifyAttestationResponse), nil
+}
+
+// Requests returns every request passed to VerifyAttestation, in order.
+func (c *VerifierClient) Requests() []*verifierpb.VerifyAttestationRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*verifierpb.VerifyAttestationRequest(nil), c.requests...)
+}
@@ -0,0 +1,81 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	verifierpb "github.com/google/go-tpm-tools/proto/verifier"
+	"github.com/google/go-tpm-tools/server"
+)
+
+func TestAttestToFakeVerifier(t *testing.T) {
+	nonce := []byte("fake nonce")
+	attester := &Attester{Attestation: &pb.Attestation{EventLog: []byte("log")}}
+	verifier := &VerifierClient{
+		Nonce:    nonce,
+		Response: &verifierpb.VerifyAttestationResponse{ClaimsToken: []byte("token")},
+	}
+
+	resp, err := client.AttestToVerifier(context.Background(), attester, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.GetClaimsToken()) != "token" {
+		t.Errorf("got claims token %q, want the canned token", resp.GetClaimsToken())
+	}
+	if calls := attester.Calls(); len(calls) != 1 || string(calls[0].Nonce) != string(nonce) {
+		t.Errorf("Attest() calls %v, want one call with the verifier's nonce", calls)
+	}
+	if reqs := verifier.Requests(); len(reqs) != 1 || string(reqs[0].GetAttestation().GetEventLog()) != "log" {
+		t.Errorf("VerifyAttestation() requests %v, want one request with the canned attestation", reqs)
+	}
+}
+
+func TestInjectedFailures(t *testing.T) {
+	subtests := []struct {
+		name     string
+		attester *Attester
+		verifier *VerifierClient
+	}{
+		{"NonceFailure", &Attester{}, &VerifierClient{NonceErr: ErrInjected}},
+		{"AttestFailure", &Attester{Err: ErrInjected}, &VerifierClient{}},
+		{"VerifyFailure", &Attester{}, &VerifierClient{VerifyErr: ErrInjected}},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			_, err := client.AttestToVerifier(context.Background(), subtest.attester, subtest.verifier)
+			if !errors.Is(err, ErrInjected) {
+				t.Errorf("got error %v, want %v", err, ErrInjected)
+			}
+		})
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	var verifier server.Verifier = &Verifier{
+		MachineState: &pb.MachineState{AkName: []byte("ak")},
+		Nonce:        []byte("nonce"),
+	}
+	state, err := verifier.VerifyAttestation(&pb.Attestation{}, server.VerifyOpts{Nonce: []byte("nonce")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(state.GetAkName()) != "ak" {
+		t.Errorf("got MachineState %v, want the canned MachineState", state)
+	}
+	// Callers cannot modify the canned MachineState.
+	state.AkName = nil
+	if state, _ := verifier.VerifyAttestation(&pb.Attestation{}, server.VerifyOpts{Nonce: []byte("nonce")}); state.GetAkName() == nil {
+		t.Error("modifying a returned MachineState changed the canned MachineState")
+	}
+
+	if _, err := verifier.VerifyAttestation(&pb.Attestation{}, server.VerifyOpts{Nonce: []byte("other")}); !errors.Is(err, ErrWrongNonce) {
+		t.Errorf("got error %v, want %v", err, ErrWrongNonce)
+	}
+	if calls := verifier.(*Verifier).Calls(); len(calls) != 3 {
+		t.Errorf("got %d calls, want 3", len(calls))
+	}
+}
@@ -0,0 +1,339 @@
+// Package tpmtest opens the TPM which tests run against, so test suites which
+// use the simulator by default can also be run against swtpm or real TPMs.
+// It should only be imported by tests, and requires cgo for the simulator.
+//
+// The TPM is chosen by flags passed to "go test", or by environment variables:
+//
+//	--tpm-path, TPM_PATH   a TPM device, or a TCTI-style configuration (see
+//	                       client.OpenTPM). On Windows, --use-tbs or
+//	                       TPM_PATH=tbs selects the TPM Base Services.
+//	--swtpm, SWTPM_ADDR    swtpm's TCP data channel as host:port, or a
+//	                       client.OpenSwtpm configuration. swtpm is reset and
+//	                       cleared before each test, like a new simulator.
+//	SIMULATOR              if "0" or "false", tests are skipped instead of
+//	                       using the simulator when no TPM is chosen.
+//
+// Flags take precedence over environment variables. A TPM device is opened
+// once, and shared by the tests of a package: each test gets a connection
+// which retries commands the TPM is too busy to run, and whose transient
+// objects and sessions are flushed when it is closed. Opening a TPM which is
+// busy (such as a device or swtpm used by the tests of another package) is
+// retried for up to a minute, though running packages one at a time with
+// "go test -p 1" avoids waiting.
+package tpmtest
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+	"github.com/google/go-tpm-tools/simulator"
+)
+
+// Environment variables which choose the TPM, if the flags are not set.
+const (
+	TPMPathEnv   = "TPM_PATH"
+	SwtpmAddrEnv = "SWTPM_ADDR"
+	SimulatorEnv = "SIMULATOR"
+)
+
+const (
+	openTimeout    = time.Minute
+	initialBackoff = 100 * time.Millisecond
+)
+
+var swtpmFlag = flag.String("swtpm", "", "swtpm configuration (i.e. host=localhost,port=2321, see client.OpenSwtpm) to run the tests against, resetting it for each test. Empty value (default) will run tests against the simulator.")
+
+// Kind is a kind of TPM which tests run against.
+type Kind int
+
+// The kinds of TPM. Only the state of the Simulator and Swtpm can be reset.
+const (
+	Simulator Kind = iota
+	Swtpm
+	Device
+	// No TPM was chosen, and the simulator is disabled.
+	None
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Simulator:
+		return "simulator"
+	case Swtpm:
+		return "swtpm"
+	case Device:
+		return "TPM device"
+	case None:
+		return "no TPM"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// target is the TPM chosen by the flags and environment.
+type target struct {
+	kind Kind
+	// The device path (for Device) or client.OpenSwtpm configuration (for
+	// Swtpm).
+	conf string
+}
+
+var (
+	selectOnce sync.Once
+	selected   target
+	selectErr  error
+)
+
+// Selected returns the kind of TPM which GetTPM returns, or an error if the
+// flags or environment variables are invalid. It must be called after the
+// flags are parsed, such as from a test.
+func Selected() (Kind, error) {
+	selectOnce.Do(func() {
+		selected, selectErr = selectTarget(devicePath(), *swtpmFlag, os.Getenv)
+	})
+	return selected.kind, selectErr
+}
+
+// selectTarget chooses the TPM from the device and swtpm flags, then the
+// environment.
+func selectTarget(deviceFlag, swtpmFlag string, getenv func(string) string) (target, error) {
+	device, swtpm := deviceFlag, swtpmFlag
+	if device == "" && swtpm == "" {
+		device, swtpm = getenv(TPMPathEnv), getenv(SwtpmAddrEnv)
+	}
+	switch {
+	case device != "" && swtpm != "":
+		return target{}, fmt.Errorf("only one of a TPM device (%q) and swtpm (%q) can be used", device, swtpm)
+	case device != "":
+		return target{Device, device}, nil
+	case swtpm != "":
+		return target{Swtpm, swtpmConf(swtpm)}, nil
+	}
+	switch strings.ToLower(getenv(SimulatorEnv)) {
+	case "", "1", "true":
+		return target{kind: Simulator}, nil
+	case "0", "false":
+		return target{kind: None}, nil
+	default:
+		return target{}, fmt.Errorf("invalid %s value %q, want true or false", SimulatorEnv, getenv(SimulatorEnv))
+	}
+}
+
+// swtpmConf converts a host:port address to a client.OpenSwtpm configuration.
+func swtpmConf(addr string) string {
+	if strings.Contains(addr, "=") {
+		return addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// Let client.OpenSwtpm report the invalid configuration.
+		return addr
+	}
+	return fmt.Sprintf("host=%s,port=%s", host, port)
+}
+
+// Only open the TPM device and swtpm once, and only let one test use swtpm at
+// a time.
+var (
+	deviceLock sync.Mutex
+	device     *client.SharedTPM
+	swtpmLock  sync.Mutex
+	swtpm      *client.Swtpm
+)
+
+// GetTPM returns the TPM chosen by the flags and environment variables (the
+// simulator by default), skipping the test if no TPM was chosen and the
+// simulator is disabled. The test must close the TPM, for example with
+// client.CheckedClose.
+func GetTPM(tb testing.TB) io.ReadWriteCloser {
+	tb.Helper()
+	kind, err := Selected()
+	if err != nil {
+		tb.Fatalf("Invalid TPM selection: %v", err)
+	}
+	switch kind {
+	case Device:
+		return getDevice(tb)
+	case Swtpm:
+		return getSwtpm(tb)
+	case None:
+		tb.Skipf("No TPM chosen with %s or %s, and %s is disabled", TPMPathEnv, SwtpmAddrEnv, SimulatorEnv)
+	}
+	return getSimulator(tb)
+}
+
+// RequireSimulated skips the test unless it runs against the simulator or
+// swtpm, for tests which need to reset the TPM, or set its PCRs to arbitrary
+// values.
+func RequireSimulated(tb testing.TB) {
+	tb.Helper()
+	if kind, _ := Selected(); kind != Simulator && kind != Swtpm {
+		tb.Skipf("Test needs a simulated TPM, not a %v", kind)
+	}
+}
+
+// RequireAlgorithm skips the test if the TPM does not implement the algorithm.
+func RequireAlgorithm(tb testing.TB, rw io.ReadWriter, alg tpm2.Algorithm) {
+	tb.Helper()
+	moreData := true
+	for property := uint32(0); moreData; {
+		var err error
+		var descs []interface{}
+		descs, moreData, err = tpm2.GetCapability(rw, tpm2.CapabilityAlgs, 16, property)
+		if err != nil {
+			tb.Fatalf("Could not get TPM algorithm capability: %v", err)
+		}
+		for _, desc := range descs {
+			if desc.(tpm2.AlgorithmDescription).ID == alg {
+				return
+			}
+		}
+		if len(descs) == 0 {
+			break
+		}
+		property = uint32(descs[len(descs)-1].(tpm2.AlgorithmDescription).ID) + 1
+	}
+	tb.Skipf("Algorithm %v is not supported by the TPM", alg)
+}
+
+// RequireCommand skips the test if the TPM does not implement the command.
+func RequireCommand(tb testing.TB, rw io.ReadWriter, cmd tpmutil.Command) {
+	tb.Helper()
+	// go-tpm cannot decode the commands capability, whose values are
+	// TPMA_CC attributes with the command code in the low 16 bits.
+	resp, err := internal.RunCommand(rw, tpm2.CmdGetCapability, nil, nil,
+		tpm2.CapabilityCommands, uint32(cmd), uint32(1))
+	if err != nil {
+		tb.Fatalf("Could not get TPM command capability: %v", err)
+	}
+	var moreData uint8
+	var capability tpm2.Capability
+	var count, attrs uint32
+	if _, err := tpmutil.Unpack(resp, &moreData, &capability, &count); err != nil {
+		tb.Fatalf("Could not decode TPM command capability: %v", err)
+	}
+	if count != 0 {
+		if _, err := tpmutil.Unpack(resp[9:], &attrs); err != nil {
+			tb.Fatalf("Could not decode TPM command capability: %v", err)
+		}
+		if attrs&0xffff == uint32(cmd) {
+			return
+		}
+	}
+	tb.Skipf("Command 0x%x is not supported by the TPM", uint32(cmd))
+}
+
+func getDevice(tb testing.TB) io.ReadWriteCloser {
+	tb.Helper()
+	deviceLock.Lock()
+	defer deviceLock.Unlock()
+	if device == nil {
+		rwc, err := openWithRetry(func() (io.ReadWriteCloser, error) { return openDevice(selected.conf) })
+		if err != nil {
+			tb.Fatalf("Failed to open TPM: %v", err)
+		}
+		device = client.NewSharedTPM(rwc)
+	}
+	return device.Conn(context.Background())
+}
+
+// swtpmSession is a test's use of the shared swtpm connection. Closing it lets
+// the next test use swtpm.
+type swtpmSession struct {
+	io.ReadWriter
+	closed bool
+}
+
+func (s *swtpmSession) Close() error {
+	if s.closed {
+		return errors.New("swtpm session already closed")
+	}
+	s.closed = true
+	swtpmLock.Unlock()
+	return nil
+}
+
+// getSwtpm resets swtpm, so each test starts with freshly reset PCRs and no
+// loaded objects or sessions, and clears it, so that (like a new simulator) no
+// persistent objects or owner NV indexes are left from earlier tests.
+func getSwtpm(tb testing.TB) io.ReadWriteCloser {
+	tb.Helper()
+	swtpmLock.Lock()
+	var err error
+	if swtpm == nil {
+		var rwc io.ReadWriteCloser
+		rwc, err = openWithRetry(func() (io.ReadWriteCloser, error) { return client.OpenSwtpm(selected.conf) })
+		if err == nil {
+			swtpm = rwc.(*client.Swtpm)
+		}
+	} else {
+		err = swtpm.Reset()
+	}
+	if err == nil {
+		err = tpm2.Clear(swtpm, tpm2.HandleLockout, tpm2.AuthCommand{Session: tpm2.HandlePasswordSession})
+	}
+	if err != nil {
+		swtpmLock.Unlock()
+		tb.Fatalf("swtpm initialization failed: %v", err)
+	}
+	session := &swtpmSession{ReadWriter: swtpm}
+	tb.Cleanup(func() {
+		if !session.closed {
+			tb.Error("swtpm session was not properly closed")
+			session.Close()
+		}
+	})
+	return session
+}
+
+func getSimulator(tb testing.TB) io.ReadWriteCloser {
+	tb.Helper()
+	simulator, err := simulator.Get()
+	if err != nil {
+		tb.Fatalf("Simulator initialization failed: %v", err)
+	}
+	// Make sure that whatever happens, we close the simulator
+	tb.Cleanup(func() {
+		if !simulator.IsClosed() {
+			tb.Error("simulator was not properly closed")
+			if err := simulator.Close(); err != nil {
+				tb.Errorf("when closing simulator: %v", err)
+			}
+		}
+	})
+	return simulator
+}
+
+// openWithRetry opens a TPM, retrying while it is busy or not yet listening.
+func openWithRetry(open func() (io.ReadWriteCloser, error)) (io.ReadWriteCloser, error) {
+	deadline := time.Now().Add(openTimeout)
+	backoff := initialBackoff
+	for {
+		rwc, err := open()
+		if err == nil || !isBusy(err) || time.Now().Add(backoff).After(deadline) {
+			return rwc, err
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > 5*time.Second {
+			backoff = 5 * time.Second
+		}
+	}
+}
+
+func isBusy(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.ECONNREFUSED)
+}
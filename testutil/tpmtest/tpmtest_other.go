@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package tpmtest
+
+import (
+	"flag"
+	"io"
+
+	"github.com/google/go-tpm-tools/client"
+)
+
+// As this package is only included in tests, this flag will not conflict with
+// the --tpm-path flag in gotpm/cmd
+var tpmPath = flag.String("tpm-path", "", "Path to Linux TPM character device (i.e. /dev/tpm0 or /dev/tpmrm0), or a TCTI-style configuration (see client.OpenTPM). Empty value (default) will run tests against the simulator.")
+
+func devicePath() string {
+	return *tpmPath
+}
+
+func openDevice(path string) (io.ReadWriteCloser, error) {
+	return client.OpenTPM(path)
+}
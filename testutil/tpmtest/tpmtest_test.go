@@ -0,0 +1,99 @@
+package tpmtest
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+
+	"github.com/google/go-tpm-tools/client"
+)
+
+func TestSelectTarget(t *testing.T) {
+	subtests := []struct {
+		name       string
+		deviceFlag string
+		swtpmFlag  string
+		env        map[string]string
+		want       target
+		wantErr    bool
+	}{
+		{"Default", "", "", nil, target{kind: Simulator}, false},
+		{"SimulatorEnabled", "", "", map[string]string{SimulatorEnv: "true"}, target{kind: Simulator}, false},
+		{"SimulatorDisabled", "", "", map[string]string{SimulatorEnv: "0"}, target{kind: None}, false},
+		{"InvalidSimulator", "", "", map[string]string{SimulatorEnv: "maybe"}, target{}, true},
+		{"DeviceFlag", "/dev/tpmrm0", "", map[string]string{SwtpmAddrEnv: "localhost:2321"}, target{Device, "/dev/tpmrm0"}, false},
+		{"SwtpmFlag", "", "host=localhost,port=2321", map[string]string{TPMPathEnv: "/dev/tpm0"}, target{Swtpm, "host=localhost,port=2321"}, false},
+		{"BothFlags", "/dev/tpmrm0", "port=2321", nil, target{}, true},
+		{"DeviceEnv", "", "", map[string]string{TPMPathEnv: "device:/dev/tpmrm0", SimulatorEnv: "0"}, target{Device, "device:/dev/tpmrm0"}, false},
+		{"SwtpmAddrEnv", "", "", map[string]string{SwtpmAddrEnv: "127.0.0.1:2321"}, target{Swtpm, "host=127.0.0.1,port=2321"}, false},
+		{"SwtpmConfEnv", "", "", map[string]string{SwtpmAddrEnv: "path=/run/swtpm/sock,ctrl=/run/swtpm/ctrl"}, target{Swtpm, "path=/run/swtpm/sock,ctrl=/run/swtpm/ctrl"}, false},
+		{"BothEnv", "", "", map[string]string{TPMPathEnv: "/dev/tpm0", SwtpmAddrEnv: "localhost:2321"}, target{}, true},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			got, err := selectTarget(subtest.deviceFlag, subtest.swtpmFlag, func(key string) string {
+				return subtest.env[key]
+			})
+			if gotErr := err != nil; gotErr != subtest.wantErr {
+				t.Fatalf("selectTarget() = %v, want error: %v", err, subtest.wantErr)
+			}
+			if got != subtest.want {
+				t.Errorf("selectTarget() = %+v, want %+v", got, subtest.want)
+			}
+		})
+	}
+}
+
+func TestOpenWithRetry(t *testing.T) {
+	busy := &os.PathError{Op: "open", Path: "/dev/tpm0", Err: syscall.EBUSY}
+	attempts := 0
+	_, err := openWithRetry(func() (io.ReadWriteCloser, error) {
+		if attempts++; attempts < 3 {
+			return nil, busy
+		}
+		return nil, nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("openWithRetry() = %v after %d attempts, want success after 3", err, attempts)
+	}
+
+	attempts = 0
+	missing := &os.PathError{Op: "open", Path: "/dev/tpm0", Err: syscall.ENOENT}
+	if _, err := openWithRetry(func() (io.ReadWriteCloser, error) {
+		attempts++
+		return nil, missing
+	}); !errors.Is(err, syscall.ENOENT) || attempts != 1 {
+		t.Errorf("openWithRetry() = %v after %d attempts, want the error without retrying", err, attempts)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	rwc := GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	subtests := []struct {
+		name        string
+		require     func(t *testing.T)
+		wantSkipped bool
+	}{
+		{"SupportedAlgorithm", func(t *testing.T) { RequireAlgorithm(t, rwc, tpm2.AlgSHA256) }, false},
+		{"UnsupportedAlgorithm", func(t *testing.T) { RequireAlgorithm(t, rwc, tpm2.Algorithm(0x7fff)) }, true},
+		{"SupportedCommand", func(t *testing.T) { RequireCommand(t, rwc, tpm2.CmdCertify) }, false},
+		{"UnsupportedCommand", func(t *testing.T) { RequireCommand(t, rwc, tpmutil.Command(0x1ff)) }, true},
+	}
+	for _, subtest := range subtests {
+		var skipped bool
+		t.Run(subtest.name, func(t *testing.T) {
+			defer func() { skipped = t.Skipped() }()
+			subtest.require(t)
+		})
+		if skipped != subtest.wantSkipped {
+			t.Errorf("%s: skipped = %v, want %v", subtest.name, skipped, subtest.wantSkipped)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package tpmtest
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/client"
+)
+
+var useTBS = flag.Bool("use-tbs", false, "Run the tests against the Windows TBS. Value of false (default) will run tests against the simulator.")
+
+func devicePath() string {
+	if *useTBS {
+		return "tbs"
+	}
+	return ""
+}
+
+// openDevice opens the TBS, which is the only TPM device on Windows.
+func openDevice(path string) (io.ReadWriteCloser, error) {
+	if path != "tbs" {
+		return nil, fmt.Errorf("TPM device paths are not supported on Windows, got %q (use tbs)", path)
+	}
+	return client.OpenTPM("")
+}
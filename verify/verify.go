@@ -0,0 +1,130 @@
+// Package verify checks the quotes of an Attestation, without its event logs.
+//
+// Unlike the server package, it only depends on go-tpm and the proto packages:
+// it does not use cgo, the TPM simulator or go-attestation. So it can be built
+// for GOOS=js and GOOS=wasip1, to verify attestations in a browser or at the
+// edge (see the wasm directory for a JavaScript binding):
+//
+//	GOOS=js GOARCH=wasm go build -o verify.wasm ./verify/wasm
+//
+// Attestation checks that the attestation was signed by a trusted AK over the
+// verifier's nonce, and returns the verified PCR values. The PCRs are not
+// replayed against the event log, so the state of the machine (such as its
+// Secure Boot state) can only be trusted by comparing them to known good
+// values. Use server.VerifyAttestation to also verify the event logs.
+package verify
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	tpmpb "github.com/google/go-tpm-tools/proto/tpm"
+)
+
+var (
+	// ErrNonceMismatch is wrapped by the errors of Attestation when a quote
+	// was not made over the verifier's nonce. It is server.ErrNonceMismatch.
+	ErrNonceMismatch = internal.ErrExtraDataMismatch
+	// ErrUntrustedAK is wrapped by the errors of Attestation when the AK, or
+	// the key of an additional quote, is not in Opts.TrustedAKs. It is
+	// server.ErrUntrustedAK.
+	ErrUntrustedAK = internal.ErrUntrustedAK
+)
+
+// Opts allows for customizing the checks of Attestation. Its fields have the
+// same meaning as those of server.VerifyOpts.
+type Opts struct {
+	// The nonce used when calling client.Attest.
+	Nonce []byte
+	// The hash algorithm used to bind the Nonce to the quotes, which must be
+	// the client.AttestOpts.NonceHash used when calling client.Attest.
+	NonceHash crypto.Hash
+	// Trusted public keys of the AK, and of the keys which signed the
+	// attestation's additional quotes.
+	TrustedAKs []crypto.PublicKey
+	// Allow attestations to be verified using SHA-1. It is ignored in FIPS
+	// mode, which never allows SHA-1.
+	AllowSHA1 bool
+}
+
+// Result is the verified content of an Attestation.
+type Result struct {
+	// The public area and public key of the AK.
+	AKPublic tpm2.Public
+	AK       crypto.PublicKey
+	// The verified PCR values of the preferred bank, including those quoted
+	// by the keys of additional quotes.
+	PCRs *tpmpb.PCRs
+	// The TPM's clock state when it signed the AK's quote.
+	Clock tpm2.ClockInfo
+}
+
+// Attestation performs the following checks on an Attestation:
+//   - the AK used to generate the attestation is in opts.TrustedAKs
+//   - the attestation binds the nonce with opts.NonceHash
+//   - a quote is signed by the AK, over the provided PCR values and
+//     opts.Nonce (or its opts.NonceHash digest)
+//   - any additional quotes are signed by distinct keys in opts.TrustedAKs,
+//     bind the same nonce, and quote PCRs which no other key quoted
+//
+// The quotes are tried in order of hash preference (SHA-512 first), and the
+// PCRs of the first one which verifies are returned. The event logs and the
+// EK certificate of the attestation are ignored.
+func Attestation(attestation *pb.Attestation, opts Opts) (*Result, error) {
+	akPubArea, err := tpm2.DecodePublic(attestation.GetAkPub())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode AK public area: %w", err)
+	}
+	akPubKey, err := akPubArea.Key()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AK public key: %w", err)
+	}
+	if err = internal.CheckKeyTrusted(akPubKey, opts.TrustedAKs); err != nil {
+		return nil, err
+	}
+	signHashAlg, err := internal.GetSigningHashAlg(akPubArea)
+	if err != nil {
+		return nil, fmt.Errorf("bad AK public area: %w", err)
+	}
+	if err = internal.CheckHashAlgSupported(signHashAlg, opts.AllowSHA1); err != nil {
+		return nil, fmt.Errorf("in AK public area: %w", err)
+	}
+	extraData, err := internal.AttestationExtraData(attestation, opts.Nonce, opts.NonceHash)
+	if err != nil {
+		return nil, err
+	}
+	additionalKeys, err := internal.AdditionalQuoteKeys(attestation, akPubKey, opts.TrustedAKs)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, quote := range internal.SupportedQuotes(attestation.GetQuotes()) {
+		if err = internal.VerifyQuote(quote, akPubKey, extraData); err != nil {
+			lastErr = fmt.Errorf("failed to verify quote: %w", err)
+			continue
+		}
+		if err = internal.CheckHashAlgSupported(tpm2.Algorithm(quote.GetPcrs().GetHash()), opts.AllowSHA1); err != nil {
+			lastErr = fmt.Errorf("when verifying PCRs: %w", err)
+			continue
+		}
+		pcrs, err := internal.MergeAdditionalQuotes(quote, attestation.GetAdditionalQuotes(), additionalKeys, extraData)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		attest, err := internal.DecodeAttest(quote.GetQuote())
+		if err != nil {
+			return nil, err
+		}
+		return &Result{AKPublic: akPubArea, AK: akPubKey, PCRs: pcrs, Clock: attest.ClockInfo}, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("attestation does not contain a supported quote")
+}
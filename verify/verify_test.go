@@ -0,0 +1,126 @@
+package verify_test
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	"github.com/google/go-tpm-tools/verify"
+)
+
+func TestAttestation(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatalf("failed to generate AK: %v", err)
+	}
+	defer ak.Close()
+
+	nonce := []byte("super secret nonce")
+	attestation, err := ak.Attest(client.AttestOpts{Nonce: nonce, NonceHash: crypto.SHA256})
+	if err != nil {
+		t.Fatalf("failed to attest: %v", err)
+	}
+	opts := verify.Opts{
+		Nonce:      nonce,
+		NonceHash:  crypto.SHA256,
+		TrustedAKs: []crypto.PublicKey{ak.PublicKey()},
+	}
+	result, err := verify.Attestation(attestation, opts)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	// The PCRs of the preferred bank are returned.
+	var want []byte
+	for _, quote := range attestation.GetQuotes() {
+		if quote.GetPcrs().GetHash() == result.PCRs.GetHash() {
+			want = quote.GetPcrs().GetPcrs()[uint32(test.DebugPCR)]
+		}
+	}
+	if got := result.PCRs.GetPcrs()[uint32(test.DebugPCR)]; want == nil || !bytes.Equal(got, want) {
+		t.Errorf("got PCR %d = %x, want the quoted %x", test.DebugPCR, got, want)
+	}
+
+	wrongNonce := opts
+	wrongNonce.Nonce = append(nonce, 0)
+	if _, err := verify.Attestation(attestation, wrongNonce); !errors.Is(err, verify.ErrNonceMismatch) {
+		t.Errorf("using the wrong nonce should fail with ErrNonceMismatch, got %v", err)
+	}
+	wrongNonceHash := opts
+	wrongNonceHash.NonceHash = crypto.SHA512
+	if _, err := verify.Attestation(attestation, wrongNonceHash); !errors.Is(err, verify.ErrNonceMismatch) {
+		t.Errorf("using the wrong nonce hash should fail with ErrNonceMismatch, got %v", err)
+	}
+	untrusted := opts
+	untrusted.TrustedAKs = nil
+	if _, err := verify.Attestation(attestation, untrusted); !errors.Is(err, verify.ErrUntrustedAK) {
+		t.Errorf("using no trusted AKs should fail with ErrUntrustedAK, got %v", err)
+	}
+
+	// Quotes with modified PCR values must not verify.
+	for _, quote := range attestation.GetQuotes() {
+		for index := range quote.GetPcrs().GetPcrs() {
+			quote.GetPcrs().GetPcrs()[index] = make([]byte, len(quote.GetPcrs().GetPcrs()[index]))
+		}
+	}
+	if _, err := verify.Attestation(attestation, opts); err == nil {
+		t.Error("verifying modified PCRs should fail")
+	}
+}
+
+// The package must build without cgo or TPM dependencies, for browsers and
+// WASI runtimes.
+func TestWasmBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping cross-compilation in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skipf("go tool not found: %v", err)
+	}
+	forbidden := []string{
+		"runtime/cgo",
+		"github.com/google/go-attestation",
+		"github.com/google/go-tpm-tools/client",
+		"github.com/google/go-tpm-tools/simulator",
+		"google.golang.org/grpc",
+	}
+	for _, tc := range []struct {
+		goos     string
+		packages []string
+	}{
+		{"js", []string{".", "./wasm"}},
+		{"wasip1", []string{"."}},
+	} {
+		t.Run(tc.goos, func(t *testing.T) {
+			env := append(os.Environ(), "GOOS="+tc.goos, "GOARCH=wasm", "CGO_ENABLED=0")
+			list := exec.Command(goTool, append([]string{"list", "-deps"}, tc.packages...)...)
+			list.Env = env
+			out, err := list.CombinedOutput()
+			if err != nil {
+				t.Fatalf("go list failed: %v\n%s", err, out)
+			}
+			for _, dep := range strings.Fields(string(out)) {
+				for _, prefix := range forbidden {
+					if strings.HasPrefix(dep, prefix) {
+						t.Errorf("depends on %s", dep)
+					}
+				}
+			}
+
+			build := exec.Command(goTool, append([]string{"build", "-o", os.DevNull}, tc.packages...)...)
+			build.Env = env
+			if out, err := build.CombinedOutput(); err != nil {
+				t.Errorf("go build failed: %v\n%s", err, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command wasm exposes verify.Attestation to JavaScript, as a global function:
+//
+//	verifyAttestation(attestation, nonce, trustedAKs, options)
+//
+// The attestation is the serialized Attestation proto, the nonce is the nonce
+// used when calling client.Attest, and trustedAKs is an array of PKIX (DER)
+// encoded public keys; all are Uint8Arrays. The optional options object can
+// set nonceHash to "SHA-256", "SHA-384" or "SHA-512", and allowSHA1 to true.
+//
+// It returns an object with the hash algorithm ("hash") and the hex encoded
+// values ("pcrs", keyed by index) of the verified PCRs, or with the reason
+// ("error") the attestation did not verify.
+//
+// Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o verify.wasm ./verify/wasm
+//
+// and run it with the wasm_exec.js in $(go env GOROOT)/misc/wasm (or lib/wasm).
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"syscall/js"
+
+	"github.com/google/go-tpm/tpm2"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/verify"
+)
+
+var nonceHashes = map[string]crypto.Hash{
+	"SHA-256": crypto.SHA256,
+	"SHA-384": crypto.SHA384,
+	"SHA-512": crypto.SHA512,
+}
+
+func main() {
+	js.Global().Set("verifyAttestation", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		pcrs, hash, err := verifyAttestation(args)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		return map[string]interface{}{"hash": hash, "pcrs": pcrs}
+	}))
+	// Keep the functions available until the page is closed.
+	select {}
+}
+
+func verifyAttestation(args []js.Value) (map[string]interface{}, string, error) {
+	if len(args) < 3 {
+		return nil, "", fmt.Errorf("expected the attestation, nonce and trusted AKs, got %d arguments", len(args))
+	}
+	attestation := &pb.Attestation{}
+	if err := proto.Unmarshal(bytesArg(args[0]), attestation); err != nil {
+		return nil, "", fmt.Errorf("failed to decode attestation: %w", err)
+	}
+	opts := verify.Opts{Nonce: bytesArg(args[1])}
+	for i := 0; i < args[2].Length(); i++ {
+		ak, err := x509.ParsePKIXPublicKey(bytesArg(args[2].Index(i)))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse trusted AK %d: %w", i, err)
+		}
+		opts.TrustedAKs = append(opts.TrustedAKs, ak)
+	}
+	if len(args) > 3 && args[3].Type() == js.TypeObject {
+		if name := args[3].Get("nonceHash"); name.Type() == js.TypeString {
+			hash, ok := nonceHashes[name.String()]
+			if !ok {
+				return nil, "", fmt.Errorf("unsupported nonce hash %q", name.String())
+			}
+			opts.NonceHash = hash
+		}
+		opts.AllowSHA1 = args[3].Get("allowSHA1").Truthy()
+	}
+
+	result, err := verify.Attestation(attestation, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	pcrs := make(map[string]interface{})
+	for index, value := range result.PCRs.GetPcrs() {
+		pcrs[strconv.FormatUint(uint64(index), 10)] = hex.EncodeToString(value)
+	}
+	hash, err := tpm2.Algorithm(result.PCRs.GetHash()).Hash()
+	if err != nil {
+		return nil, "", err
+	}
+	return pcrs, hash.String(), nil
+}
+
+// bytesArg copies a Uint8Array argument.
+func bytesArg(v js.Value) []byte {
+	b := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(b, v)
+	return b
+}
@@ -0,0 +1,106 @@
+package wireguard
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/server"
+)
+
+// RegistryOpts configures how a Registry verifies registrations.
+type RegistryOpts struct {
+	// Options for verifying each registration's Attestation. The Nonce field
+	// is set by the Registry. All other fields (such as TrustedAKs) are used
+	// as provided.
+	VerifyOpts server.VerifyOpts
+	// If non-nil, the machine's MachineState must satisfy this Policy.
+	Policy *pb.Policy
+}
+
+// Peer is a WireGuard peer whose public key was registered after its
+// Attestation verified.
+type Peer struct {
+	PublicKey []byte
+	// The verified MachineState of the peer. Its AkName identifies the peer.
+	State *pb.MachineState
+}
+
+// Registry is the server side of WireGuard key provisioning. It keeps track
+// of the peers whose registrations were verified, with one public key for
+// each AK. It is safe for concurrent use.
+type Registry struct {
+	opts RegistryOpts
+
+	mu sync.Mutex
+	// Peers, keyed by their AK name.
+	peers map[string]*Peer
+}
+
+// NewRegistry creates an empty Registry with the provided options.
+func NewRegistry(opts RegistryOpts) *Registry {
+	return &Registry{opts: opts, peers: map[string]*Peer{}}
+}
+
+// Register verifies a registration from Register, and adds its public key as
+// a peer. The nonce must be the one given to the machine, and must only be
+// used once. If the machine's AK already has a peer (for example, because it
+// generated a new key after its PCRs changed), the old public key is replaced.
+func (r *Registry) Register(registration *pb.WireGuardRegistration, nonce []byte) (*Peer, error) {
+	publicKey := registration.GetPublicKey()
+	if len(publicKey) != KeySize {
+		return nil, fmt.Errorf("public key has size %d, want %d", len(publicKey), KeySize)
+	}
+	opts := r.opts.VerifyOpts
+	opts.Nonce = registrationNonce(nonce, publicKey)
+	state, err := server.VerifyAttestation(registration.GetAttestation(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify attestation: %w", err)
+	}
+	if r.opts.Policy != nil {
+		result, err := server.EvaluatePolicy(state, r.opts.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("machine does not satisfy policy: %w", err)
+		}
+		result.Record(state)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	akName := string(state.GetAkName())
+	for name, peer := range r.peers {
+		if name != akName && bytes.Equal(peer.PublicKey, publicKey) {
+			return nil, fmt.Errorf("public key is already registered by another AK")
+		}
+	}
+	peer := &Peer{PublicKey: publicKey, State: state}
+	r.peers[akName] = peer
+	return peer, nil
+}
+
+// Remove removes the peer of the AK with the given name, for example when the
+// machine is decommissioned.
+func (r *Registry) Remove(akName []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.peers, string(akName))
+}
+
+// Peers returns the currently registered peers, ordered by AK name, to be
+// written to the WireGuard configuration.
+func (r *Registry) Peers() []*Peer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.peers))
+	for name := range r.peers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	peers := make([]*Peer, len(names))
+	for i, name := range names {
+		peers[i] = r.peers[name]
+	}
+	return peers
+}
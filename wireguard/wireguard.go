@@ -0,0 +1,155 @@
+// Package wireguard provisions WireGuard keys for machines with TPMs, so a
+// machine can only join a WireGuard network while it is running in a verified
+// state.
+//
+// The flow between a machine and the server managing the network is:
+//  1. The machine generates a key pair with GenerateKey. The private key is
+//     sealed to the current values of the machine's PCRs, so it can only be
+//     recovered (with PrivateKey) while the machine is in the same state.
+//  2. The machine obtains a fresh nonce from the server, and creates a
+//     WireGuardRegistration with Register. It contains the public key and an
+//     Attestation bound to both the nonce and the public key.
+//  3. The server verifies the registration with a Registry. Only if the
+//     Attestation verifies (and satisfies the server's Policy) is the public
+//     key added as a peer.
+//
+// If the machine's PCRs change (for example, after a kernel update), the old
+// private key can no longer be unsealed. Refresh detects this and generates a
+// new key, which the machine then registers again. The Registry replaces the
+// old public key of the machine's AK with the new one.
+//
+// WireGuard configurations and tools encode keys with base64.StdEncoding.
+package wireguard
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm/tpm2"
+	"golang.org/x/crypto/curve25519"
+)
+
+// KeySize is the size (in bytes) of WireGuard's Curve25519 keys.
+const KeySize = curve25519.ScalarSize
+
+const registrationLabel = "GOTPM WIREGUARD REGISTRATION\x00"
+
+// GenerateKey creates a WireGuard key pair, sealing the private key to the
+// current values of the selected PCRs. The private key is sealed with the ECC
+// Storage Root Key, so the returned WireGuardKey can be stored anywhere.
+func GenerateKey(rw io.ReadWriter, sel tpm2.PCRSelection) (*pb.WireGuardKey, error) {
+	if len(sel.PCRs) == 0 {
+		return nil, fmt.Errorf("the private key must be sealed to at least one PCR")
+	}
+	private := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, private); err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+	// Clamp the private key, as done by WireGuard's own key generation.
+	private[0] &= 248
+	private[31] = (private[31] & 127) | 64
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute public key: %w", err)
+	}
+
+	srk, err := client.StorageRootKeyECC(rw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SRK: %w", err)
+	}
+	defer srk.Close()
+	sealed, err := srk.Seal(private, client.SealOpts{Current: sel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal private key: %w", err)
+	}
+	return &pb.WireGuardKey{PublicKey: public, SealedPrivateKey: sealed}, nil
+}
+
+// PrivateKey unseals the private key of a key pair from GenerateKey. This
+// fails if the PCRs the key was sealed to have changed.
+func PrivateKey(rw io.ReadWriter, key *pb.WireGuardKey) ([]byte, error) {
+	srk, err := client.StorageRootKeyECC(rw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SRK: %w", err)
+	}
+	defer srk.Close()
+	private, err := srk.Unseal(key.GetSealedPrivateKey(), client.UnsealOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal private key: %w", err)
+	}
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute public key: %w", err)
+	}
+	if !bytes.Equal(public, key.GetPublicKey()) {
+		return nil, fmt.Errorf("sealed private key does not match the public key")
+	}
+	return private, nil
+}
+
+// Refresh returns the key if its private key is still sealed to the current
+// values of its PCRs. Otherwise, the PCRs have drifted, and a new key sealed to
+// their current values is returned, along with true. The new key must be
+// registered with the server before it can be used.
+func Refresh(rw io.ReadWriter, key *pb.WireGuardKey) (*pb.WireGuardKey, bool, error) {
+	sealed := key.GetSealedPrivateKey()
+	sel := tpm2.PCRSelection{Hash: tpm2.Algorithm(sealed.GetHash())}
+	for _, pcr := range sealed.GetPcrs() {
+		sel.PCRs = append(sel.PCRs, int(pcr))
+	}
+	pub, err := tpm2.DecodePublic(sealed.GetPub())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode sealed private key: %w", err)
+	}
+	pcrs, err := client.ReadPCRs(rw, sel)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read PCRs: %w", err)
+	}
+	auth, err := internal.PCRSessionAuth(pcrs, client.SessionHashAlg)
+	if err != nil {
+		return nil, false, err
+	}
+	if bytes.Equal(pub.AuthPolicy, auth) {
+		return key, false, nil
+	}
+	newKey, err := GenerateKey(rw, sel)
+	if err != nil {
+		return nil, false, err
+	}
+	return newKey, true, nil
+}
+
+// Register creates a registration of the key's public key, attested by the
+// AK. The nonce must be a fresh nonce obtained from the server.
+func Register(ak *client.Key, key *pb.WireGuardKey, nonce []byte) (*pb.WireGuardRegistration, error) {
+	if len(key.GetPublicKey()) != KeySize {
+		return nil, fmt.Errorf("public key has size %d, want %d", len(key.GetPublicKey()), KeySize)
+	}
+	attestation, err := ak.Attest(client.AttestOpts{
+		Nonce: registrationNonce(nonce, key.GetPublicKey()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attest: %w", err)
+	}
+	return &pb.WireGuardRegistration{
+		PublicKey:   key.GetPublicKey(),
+		Attestation: attestation,
+	}, nil
+}
+
+// registrationNonce binds an attestation to the server's nonce (for
+// freshness) and the public key being registered, so that an attestation
+// cannot be used to register another public key.
+func registrationNonce(nonce, publicKey []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(registrationLabel))
+	h.Write(nonce)
+	h.Write(publicKey)
+	return h.Sum(nil)
+}
@@ -0,0 +1,147 @@
+package wireguard
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+
+	"github.com/google/go-tpm-tools/client"
+	"github.com/google/go-tpm-tools/internal/test"
+	pb "github.com/google/go-tpm-tools/proto/attest"
+	"github.com/google/go-tpm-tools/server"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"golang.org/x/crypto/curve25519"
+	"google.golang.org/protobuf/proto"
+)
+
+var testSel = tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: []int{test.DebugPCR}}
+
+func TestKeyRotation(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	key, err := GenerateKey(rwc, testSel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := PrivateKey(rwc, key)
+	if err != nil {
+		t.Fatalf("PrivateKey() failed: %v", err)
+	}
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(public, key.GetPublicKey()) {
+		t.Error("private key does not match the public key")
+	}
+	same, rotated, err := Refresh(rwc, key)
+	if err != nil {
+		t.Fatalf("Refresh() failed: %v", err)
+	}
+	if rotated || !proto.Equal(same, key) {
+		t.Error("Refresh() should not rotate the key while the PCRs are unchanged")
+	}
+
+	extension := bytes.Repeat([]byte{0xAA}, 32)
+	if err := tpm2.PCRExtend(rwc, tpmutil.Handle(test.DebugPCR), tpm2.AlgSHA256, extension, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PrivateKey(rwc, key); err == nil {
+		t.Error("PrivateKey() should fail after the PCRs changed")
+	}
+	newKey, rotated, err := Refresh(rwc, key)
+	if err != nil {
+		t.Fatalf("Refresh() failed: %v", err)
+	}
+	if !rotated || bytes.Equal(newKey.GetPublicKey(), key.GetPublicKey()) {
+		t.Error("Refresh() should rotate the key after the PCRs changed")
+	}
+	if _, err := PrivateKey(rwc, newKey); err != nil {
+		t.Errorf("PrivateKey() of the rotated key failed: %v", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	ak, err := client.AttestationKeyRSA(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	otherAK, err := client.AttestationKeyECC(rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherAK.Close()
+	registry := NewRegistry(RegistryOpts{
+		VerifyOpts: server.VerifyOpts{TrustedAKs: []crypto.PublicKey{ak.PublicKey(), otherAK.PublicKey()}},
+	})
+
+	key, err := GenerateKey(rwc, testSel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("super secret nonce")
+	registration, err := Register(ak, key, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Register(registration, []byte("wrong nonce")); err == nil {
+		t.Error("registration with the wrong nonce should fail")
+	}
+	stolen := proto.Clone(registration).(*pb.WireGuardRegistration)
+	stolen.PublicKey = bytes.Repeat([]byte{1}, KeySize)
+	if _, err := registry.Register(stolen, nonce); err == nil {
+		t.Error("registration of another public key should fail")
+	}
+	peer, err := registry.Register(registration, nonce)
+	if err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	if !bytes.Equal(peer.PublicKey, key.GetPublicKey()) {
+		t.Errorf("registered public key %x, want %x", peer.PublicKey, key.GetPublicKey())
+	}
+
+	// Another AK cannot claim the same public key.
+	hijack, err := Register(otherAK, key, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Register(hijack, nonce); err == nil {
+		t.Error("registering a public key used by another AK should fail")
+	}
+
+	// A rotated key replaces the old one.
+	newKey, err := GenerateKey(rwc, testSel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registration, err = Register(ak, newKey, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Register(registration, nonce); err != nil {
+		t.Fatalf("Register() of the rotated key failed: %v", err)
+	}
+	peers := registry.Peers()
+	if len(peers) != 1 || !bytes.Equal(peers[0].PublicKey, newKey.GetPublicKey()) {
+		t.Errorf("got peers %v, want only the rotated key", peers)
+	}
+	registry.Remove(peers[0].State.GetAkName())
+	if peers := registry.Peers(); len(peers) != 0 {
+		t.Errorf("got %d peers after removing the only peer", len(peers))
+	}
+}
+
+func TestGenerateKeyWithoutPCRs(t *testing.T) {
+	rwc := test.GetTPM(t)
+	defer client.CheckedClose(t, rwc)
+
+	if _, err := GenerateKey(rwc, tpm2.PCRSelection{Hash: tpm2.AlgSHA256}); err == nil {
+		t.Error("GenerateKey() without PCRs should fail")
+	}
+}